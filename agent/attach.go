@@ -104,9 +104,10 @@ func (s *AttachSession) Signal(sig string) error {
 	return s.mp.Signal(osSignal)
 }
 
-// Resize is a no-op for attach sessions (main process has no PTY).
+// Resize sets the main process's terminal size; it fails when the
+// main process was started without a TTY.
 func (s *AttachSession) Resize(width, height int) error {
-	return nil
+	return s.mp.Resize(width, height)
 }
 
 // Close cleans up the session.
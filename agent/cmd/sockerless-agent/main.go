@@ -12,6 +12,7 @@ func main() {
 	addr := flag.String("addr", ":9111", "listen address")
 	callback := flag.String("callback", "", "reverse connect URL (FaaS mode)")
 	keepAlive := flag.Bool("keep-alive", false, "run the remaining args as a main process and serve until exit")
+	tty := flag.Bool("tty", false, "run the keep-alive main process on a pseudo-terminal")
 	logLevel := flag.String("log-level", "info", "log level (debug, info, warn, error)")
 	flag.Parse()
 
@@ -30,6 +31,7 @@ func main() {
 		Addr:        *addr,
 		Token:       token,
 		KeepAlive:   *keepAlive,
+		Tty:         *tty,
 		CallbackURL: *callback,
 		Args:        flag.Args(),
 	}
@@ -28,6 +28,11 @@ const (
 	TypeLifetimeExpired = "lifetime_expired"
 )
 
+// MainProcessSessionID addresses a TypeResize message to the keep-alive
+// main process rather than an exec or attach session, so a container
+// started with a TTY can be resized with no attach session open.
+const MainProcessSessionID = "main"
+
 // Message is the unified WebSocket message type.
 // All fields are optional depending on the message type.
 type Message struct {
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"sync"
 
+	"github.com/creack/pty"
 	"github.com/rs/zerolog"
 )
 
@@ -56,10 +57,9 @@ func (rb *RingBuffer) Bytes() []byte {
 
 // MainProcess manages the primary process lifecycle in keep-alive mode.
 type MainProcess struct {
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	stdout io.ReadCloser
-	stderr io.ReadCloser
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	ptmx  *os.File // non-nil when started with a TTY
 
 	// Ring buffers capture output before any attach session connects.
 	stdoutBuf *RingBuffer
@@ -82,6 +82,17 @@ type OutputEvent struct {
 
 // NewMainProcess creates and starts the main process.
 func NewMainProcess(logger zerolog.Logger, args []string, env []string) (*MainProcess, error) {
+	return newMainProcess(logger, args, env, false)
+}
+
+// NewMainProcessTTY starts the main process on a pseudo-terminal, the
+// keep-alive counterpart of `docker run -t`. Output arrives as a single
+// stdout stream and Resize sets the terminal size.
+func NewMainProcessTTY(logger zerolog.Logger, args []string, env []string) (*MainProcess, error) {
+	return newMainProcess(logger, args, env, true)
+}
+
+func newMainProcess(logger zerolog.Logger, args []string, env []string, tty bool) (*MainProcess, error) {
 	if len(args) == 0 {
 		args = []string{"/bin/sh"}
 	}
@@ -89,24 +100,8 @@ func NewMainProcess(logger zerolog.Logger, args []string, env []string) (*MainPr
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Env = append(os.Environ(), env...)
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, err
-	}
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
-	}
-
 	mp := &MainProcess{
 		cmd:       cmd,
-		stdin:     stdin,
-		stdout:    stdout,
-		stderr:    stderr,
 		stdoutBuf: NewRingBuffer(ringBufferSize),
 		stderrBuf: NewRingBuffer(ringBufferSize),
 		listeners: make(map[string]chan OutputEvent),
@@ -114,20 +109,45 @@ func NewMainProcess(logger zerolog.Logger, args []string, env []string) (*MainPr
 		logger:    logger,
 	}
 
-	if err := cmd.Start(); err != nil {
-		return nil, err
+	if tty {
+		ptmx, err := pty.Start(cmd)
+		if err != nil {
+			return nil, err
+		}
+		mp.ptmx = ptmx
+		mp.stdin = ptmx
+		mp.fanOutWg.Add(1)
+		go mp.fanOut(ptmx, "stdout", mp.stdoutBuf)
+	} else {
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return nil, err
+		}
+		mp.stdin = stdin
+
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+
+		// Fan-out stdout and stderr — tracked by WaitGroup so wait() can
+		// ensure all output is flushed before signaling done.
+		mp.fanOutWg.Add(2)
+		go mp.fanOut(stdout, "stdout", mp.stdoutBuf)
+		go mp.fanOut(stderr, "stderr", mp.stderrBuf)
 	}
 
 	// Publish the user-process PID so reverse-agent pause/unpause
 	// can SIGSTOP/SIGCONT it.
 	writeMainPIDFile(cmd.Process.Pid)
 
-	// Fan-out stdout and stderr — tracked by WaitGroup so wait() can
-	// ensure all output is flushed before signaling done.
-	mp.fanOutWg.Add(2)
-	go mp.fanOut(stdout, "stdout", mp.stdoutBuf)
-	go mp.fanOut(stderr, "stderr", mp.stderrBuf)
-
 	// Wait for process to exit
 	go mp.wait()
 
@@ -190,6 +210,9 @@ func (mp *MainProcess) wait() {
 	// it to os.Stdout/os.Stderr before signaling done. Without this,
 	// the agent binary can exit before all output is flushed.
 	mp.fanOutWg.Wait()
+	if mp.ptmx != nil {
+		_ = mp.ptmx.Close()
+	}
 
 	// Clear the main-PID file so a stale value can't redirect a
 	// subsequent pause/unpause to a different process.
@@ -255,6 +278,17 @@ func (mp *MainProcess) Signal(sig os.Signal) error {
 	return mp.cmd.Process.Signal(sig)
 }
 
+// Resize sets the terminal size of a main process started with a TTY.
+func (mp *MainProcess) Resize(width, height int) error {
+	if mp.ptmx == nil {
+		return &sessionError{"main process has no TTY"}
+	}
+	return pty.Setsize(mp.ptmx, &pty.Winsize{
+		Cols: uint16(width),
+		Rows: uint16(height),
+	})
+}
+
 // ExitCode returns the exit code if the process has exited.
 func (mp *MainProcess) ExitCode() *int {
 	mp.mu.RLock()
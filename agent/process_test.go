@@ -2,6 +2,7 @@ package agent
 
 import (
 	"bytes"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -135,3 +136,36 @@ func TestMainProcessConcurrentSubscribers(t *testing.T) {
 		t.Fatalf("expected exit code 0, got %v", code)
 	}
 }
+
+func TestMainProcessTTYResize(t *testing.T) {
+	mp, err := NewMainProcessTTY(testLogger(), []string{"/bin/sh", "-c", "read x; stty size"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mp.Resize(120, 40); err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+	if err := mp.WriteStdin([]byte("\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-mp.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("process did not exit")
+	}
+	stdout, _, _ := mp.Subscribe("after-exit")
+	if !strings.Contains(string(stdout), "40 120") {
+		t.Fatalf("stty size output %q, want rows=40 cols=120", stdout)
+	}
+}
+
+func TestMainProcessResizeWithoutTTY(t *testing.T) {
+	mp, err := NewMainProcess(testLogger(), []string{"true"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-mp.Done()
+	if err := mp.Resize(80, 24); err == nil {
+		t.Fatal("expected an error resizing a main process without a TTY")
+	}
+}
@@ -348,6 +348,13 @@ func (rc *ReverseAgentConn) bridge(conn net.Conn, sessionID string, ch chan Mess
 	}
 }
 
+// Resize sets the terminal size of a session's PTY. sessionID is an
+// exec ID, an attach session ID, or MainProcessSessionID. The agent
+// applies it asynchronously and sends no reply.
+func (rc *ReverseAgentConn) Resize(sessionID string, width, height int) error {
+	return rc.SendJSON(Message{Type: TypeResize, ID: sessionID, Width: width, Height: height})
+}
+
 // Close closes the underlying WebSocket connection.
 func (rc *ReverseAgentConn) Close() error {
 	rc.mu.Lock()
@@ -113,11 +113,24 @@ func (rt *Router) handleSignal(msg *Message) {
 }
 
 func (rt *Router) handleResize(msg *Message) {
-	session, ok := rt.registry.Get(msg.ID)
-	if !ok {
-		return
+	var err error
+	if msg.ID == MainProcessSessionID {
+		if rt.mp == nil {
+			rt.logger.Debug().Msg("resize for main process, but none is running")
+			return
+		}
+		err = rt.mp.Resize(msg.Width, msg.Height)
+	} else {
+		session, ok := rt.registry.Get(msg.ID)
+		if !ok {
+			rt.logger.Debug().Str("id", msg.ID).Msg("resize for unknown session")
+			return
+		}
+		err = session.Resize(msg.Width, msg.Height)
+	}
+	if err != nil {
+		rt.logger.Debug().Err(err).Str("id", msg.ID).Msg("failed to resize")
 	}
-	_ = session.Resize(msg.Width, msg.Height)
 }
 
 func (rt *Router) sendError(conn *websocket.Conn, connMu *sync.Mutex, id string, message string) {
@@ -28,6 +28,7 @@ type Config struct {
 	Addr        string
 	Token       string
 	KeepAlive   bool
+	Tty         bool     // run the keep-alive main process on a PTY
 	CallbackURL string   // reverse connect URL (FaaS mode)
 	Args        []string // main process args (after --)
 	Env         []string // extra environment variables
@@ -59,7 +60,7 @@ func NewServer(config Config, logger zerolog.Logger) *Server {
 func (s *Server) ListenAndServe() error {
 	// Start main process in keep-alive mode
 	if s.config.KeepAlive {
-		mp, err := NewMainProcess(s.logger, s.config.Args, s.config.Env)
+		mp, err := s.startMainProcess()
 		if err != nil {
 			return err
 		}
@@ -169,13 +170,22 @@ func (s *Server) reapZombies() {
 	}
 }
 
+// startMainProcess starts the keep-alive main process, on a PTY when
+// the container was created with a TTY.
+func (s *Server) startMainProcess() (*MainProcess, error) {
+	if s.config.Tty {
+		return NewMainProcessTTY(s.logger, s.config.Args, s.config.Env)
+	}
+	return NewMainProcess(s.logger, s.config.Args, s.config.Env)
+}
+
 // ReverseConnect operates the agent in callback mode: instead of listening for
 // incoming connections, it dials out to the backend at callbackURL and handles
 // messages on that connection. Reconnects with backoff on disconnect.
 func (s *Server) ReverseConnect(callbackURL string) error {
 	// Start main process in keep-alive mode
 	if s.config.KeepAlive {
-		mp, err := NewMainProcess(s.logger, s.config.Args, s.config.Env)
+		mp, err := s.startMainProcess()
 		if err != nil {
 			return err
 		}
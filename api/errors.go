@@ -55,6 +55,20 @@ func (e *InvalidParameterError) StatusCode() int {
 	return http.StatusBadRequest
 }
 
+// ForbiddenError indicates a request refused by policy (e.g. an image
+// blocked by the vulnerability admission policy).
+type ForbiddenError struct {
+	Message string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Message
+}
+
+func (e *ForbiddenError) StatusCode() int {
+	return http.StatusForbidden
+}
+
 // NotImplementedError indicates an unimplemented endpoint.
 type NotImplementedError struct {
 	Message string
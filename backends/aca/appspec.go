@@ -52,6 +52,11 @@ func (s *Server) buildAppSpec(ctx context.Context, containers []containerInput)
 			volSeen[volName] = struct{}{}
 		}
 	}
+	if len(containers) > 1 {
+		if err := applyComposeProbes(specs, containers); err != nil {
+			return armappcontainers.ContainerApp{}, err
+		}
+	}
 
 	environmentID := fmt.Sprintf(
 		"/subscriptions/%s/resourceGroups/%s/providers/Microsoft.App/managedEnvironments/%s",
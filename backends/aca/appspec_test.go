@@ -101,3 +101,41 @@ func TestBuildAppSpec_Shape(t *testing.T) {
 		t.Errorf("sockerless-container-id tag missing or empty: %+v", app.Tags)
 	}
 }
+
+// TestBuildAppSpec_ComposeProbes — a service_healthy dependency of a
+// compose unit gets a TCP startup probe on its exposed port.
+func TestBuildAppSpec_ComposeProbes(t *testing.T) {
+	s := newServerForAppSpec(t)
+	web := demoAppContainer("web000000000000000000", "/shop-web-1", "nginx")
+	web.Container.Config.Labels = map[string]string{
+		core.ComposeServiceLabel:   "web",
+		core.ComposeDependsOnLabel: "db:service_healthy:false,cache:service_started:false",
+	}
+	db := demoAppContainer("db0000000000000000000", "/shop-db-1", "postgres")
+	db.IsMain = false
+	db.Container.Config.Labels = map[string]string{core.ComposeServiceLabel: "db"}
+	db.Container.Config.ExposedPorts = map[string]struct{}{"5432/tcp": {}, "9187/udp": {}}
+	db.Container.Config.Healthcheck = &api.HealthcheckConfig{Test: []string{"CMD", "pg_isready"}, Interval: 3e9, Retries: 50}
+	cache := demoAppContainer("cache00000000000000000", "/shop-cache-1", "redis")
+	cache.IsMain = false
+	cache.Container.Config.Labels = map[string]string{core.ComposeServiceLabel: "cache"}
+
+	app, err := s.buildAppSpec(context.Background(), []containerInput{web, db, cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers := app.Properties.Template.Containers
+	probes := containers[1].Probes
+	if len(probes) != 1 || *probes[0].Type != armappcontainers.TypeStartup || *probes[0].TCPSocket.Port != 5432 ||
+		*probes[0].PeriodSeconds != 3 || *probes[0].FailureThreshold != 10 {
+		t.Errorf("db probes = %+v", probes)
+	}
+	if len(containers[0].Probes) != 0 || len(containers[2].Probes) != 0 {
+		t.Error("probes set on services nothing waits for to be healthy")
+	}
+
+	db.Container.Config.Healthcheck = nil
+	if _, err := s.buildAppSpec(context.Background(), []containerInput{web, db, cache}); err == nil {
+		t.Error("service_healthy dependency without a healthcheck accepted")
+	}
+}
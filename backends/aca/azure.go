@@ -16,10 +16,13 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/monitor/azquery"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appcontainers/armappcontainers/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v8"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	azurecommon "github.com/sockerless/azure-common"
+	core "github.com/sockerless/backend-core"
 )
 
 type fakeCredential struct{}
@@ -30,11 +33,14 @@ func (f *fakeCredential) GetToken(_ context.Context, _ policy.TokenRequestOption
 
 // AzureClients holds all Azure SDK clients.
 type AzureClients struct {
-	Jobs              *armappcontainers.JobsClient
-	Executions        *armappcontainers.JobsExecutionsClient
-	ContainerApps     *armappcontainers.ContainerAppsClient // used when Config.UseApp is true
-	Logs              *azquery.LogsClient
-	LogsHTTP          *httpLogsClient // Used when endpoint is HTTP (SDK rejects non-TLS bearer tokens)
+	Jobs          *armappcontainers.JobsClient
+	Executions    *armappcontainers.JobsExecutionsClient
+	ContainerApps *armappcontainers.ContainerAppsClient // used when Config.UseApp is true
+	Logs          *azquery.LogsClient
+	LogsHTTP      *httpLogsClient // Used when endpoint is HTTP (SDK rejects non-TLS bearer tokens)
+	// Metrics reads Azure Monitor platform metrics for `docker stats`
+	// when no reverse agent is connected.
+	Metrics           azurecommon.MetricsQuerier
 	PrivateDNSZones   *armprivatedns.PrivateZonesClient
 	PrivateDNSRecords *armprivatedns.RecordSetsClient
 	NSG               *armnetwork.SecurityGroupsClient
@@ -44,12 +50,18 @@ type AzureClients struct {
 	// configured ACR pull-through cache, parallel to AWS ECR + GCP AR.
 	Registries    *armcontainerregistry.RegistriesClient
 	ACRCacheRules *armcontainerregistry.CacheRulesClient
+	// ACRCredentialSets holds the upstream logins of the cache rules
+	// sockerless creates for registry mirrors.
+	ACRCredentialSets *armcontainerregistry.CredentialSetsClient
 	// Azure Files + managed-env storages for named volumes.
 	// FileShares provisions a share inside the operator-configured
 	// storage account; EnvStorages binds the share to the managed
 	// environment so Container Apps/Jobs can mount it.
 	FileShares  *armstorage.FileSharesClient
 	EnvStorages *armappcontainers.ManagedEnvironmentsStoragesClient
+	// Permissions lists the caller's effective RBAC operations on the
+	// resource group for the `sockerless check` preflight.
+	Permissions *armauthorization.PermissionsClient
 	Cred        azcore.TokenCredential
 }
 
@@ -104,9 +116,14 @@ func newAzureClientsWithEndpoint(subscriptionID string, endpointURL string) (*Az
 						Endpoint: endpointURL,
 						Audience: "https://api.loganalytics.io/",
 					},
+					azquery.ServiceNameMetrics: {
+						Endpoint: endpointURL,
+						Audience: "https://management.azure.com/",
+					},
 				},
 			},
 			InsecureAllowCredentialWithHTTP: true,
+			PerCallPolicies:                 []policy.Policy{azurecommon.CloudAPIMetrics{}},
 		},
 	}
 
@@ -131,6 +148,12 @@ func newAzureClientsWithEndpoint(subscriptionID string, endpointURL string) (*Az
 	if err != nil {
 		return nil, err
 	}
+	metricsClient, err := azquery.NewMetricsClient(cred, &azquery.MetricsClientOptions{
+		ClientOptions: opts.ClientOptions,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	privateZonesClient, err := armprivatedns.NewPrivateZonesClient(subscriptionID, cred, opts)
 	if err != nil {
@@ -156,20 +179,27 @@ func newAzureClientsWithEndpoint(subscriptionID string, endpointURL string) (*Az
 	if err != nil {
 		return nil, err
 	}
+	permissionsClient, err := armauthorization.NewPermissionsClient(subscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	clients := &AzureClients{
 		Jobs:              jobsClient,
 		Executions:        executionsClient,
 		ContainerApps:     containerAppsClient,
 		Logs:              logsClient,
+		Metrics:           metricsClient,
 		PrivateDNSZones:   privateZonesClient,
 		PrivateDNSRecords: recordSetsClient,
 		NSG:               nsgFactory.NewSecurityGroupsClient(),
 		NSGRules:          nsgFactory.NewSecurityRulesClient(),
 		Registries:        acrFactory.NewRegistriesClient(),
 		ACRCacheRules:     acrFactory.NewCacheRulesClient(),
+		ACRCredentialSets: acrFactory.NewCredentialSetsClient(),
 		FileShares:        fileSharesClient,
 		EnvStorages:       envStoragesClient,
+		Permissions:       permissionsClient,
 		Cred:              cred,
 	}
 
@@ -178,6 +208,7 @@ func newAzureClientsWithEndpoint(subscriptionID string, endpointURL string) (*Az
 	// Use a direct HTTP client for non-TLS endpoints.
 	if strings.HasPrefix(endpointURL, "http://") {
 		clients.LogsHTTP = &httpLogsClient{endpoint: endpointURL}
+		clients.Metrics = &azurecommon.HTTPMetricsClient{Endpoint: endpointURL}
 	}
 
 	return clients, nil
@@ -188,48 +219,65 @@ func newAzureClientsDefault(subscriptionID string) (*AzureClients, error) {
 	if err != nil {
 		return nil, err
 	}
+	opts := &arm.ClientOptions{}
+	opts.PerCallPolicies = []policy.Policy{azurecommon.CloudAPIMetrics{}}
+	recorder, err := core.CassetteHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	if recorder != nil {
+		opts.Transport = recorder
+	}
 
-	jobsClient, err := armappcontainers.NewJobsClient(subscriptionID, cred, nil)
+	jobsClient, err := armappcontainers.NewJobsClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	executionsClient, err := armappcontainers.NewJobsExecutionsClient(subscriptionID, cred, nil)
+	executionsClient, err := armappcontainers.NewJobsExecutionsClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	containerAppsClient, err := armappcontainers.NewContainerAppsClient(subscriptionID, cred, nil)
+	containerAppsClient, err := armappcontainers.NewContainerAppsClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	logsClient, err := azquery.NewLogsClient(cred, nil)
+	logsClient, err := azquery.NewLogsClient(cred, &azquery.LogsClientOptions{ClientOptions: opts.ClientOptions})
+	if err != nil {
+		return nil, err
+	}
+	metricsClient, err := azquery.NewMetricsClient(cred, &azquery.MetricsClientOptions{ClientOptions: opts.ClientOptions})
 	if err != nil {
 		return nil, err
 	}
 
-	privateZonesClient, err := armprivatedns.NewPrivateZonesClient(subscriptionID, cred, nil)
+	privateZonesClient, err := armprivatedns.NewPrivateZonesClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
-	recordSetsClient, err := armprivatedns.NewRecordSetsClient(subscriptionID, cred, nil)
+	recordSetsClient, err := armprivatedns.NewRecordSetsClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
-	nsgFactory, err := armnetwork.NewClientFactory(subscriptionID, cred, nil)
+	nsgFactory, err := armnetwork.NewClientFactory(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
-	acrFactory, err := armcontainerregistry.NewClientFactory(subscriptionID, cred, nil)
+	acrFactory, err := armcontainerregistry.NewClientFactory(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
-	fileSharesClient, err := armstorage.NewFileSharesClient(subscriptionID, cred, nil)
+	fileSharesClient, err := armstorage.NewFileSharesClient(subscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+	envStoragesClient, err := armappcontainers.NewManagedEnvironmentsStoragesClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
-	envStoragesClient, err := armappcontainers.NewManagedEnvironmentsStoragesClient(subscriptionID, cred, nil)
+	permissionsClient, err := armauthorization.NewPermissionsClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -239,14 +287,17 @@ func newAzureClientsDefault(subscriptionID string) (*AzureClients, error) {
 		Executions:        executionsClient,
 		ContainerApps:     containerAppsClient,
 		Logs:              logsClient,
+		Metrics:           metricsClient,
 		PrivateDNSZones:   privateZonesClient,
 		PrivateDNSRecords: recordSetsClient,
 		NSG:               nsgFactory.NewSecurityGroupsClient(),
 		NSGRules:          nsgFactory.NewSecurityRulesClient(),
 		Registries:        acrFactory.NewRegistriesClient(),
 		ACRCacheRules:     acrFactory.NewCacheRulesClient(),
+		ACRCredentialSets: acrFactory.NewCredentialSetsClient(),
 		FileShares:        fileSharesClient,
 		EnvStorages:       envStoragesClient,
+		Permissions:       permissionsClient,
 		Cred:              cred,
 	}, nil
 }
@@ -56,9 +56,18 @@ func (s *Server) ContainerCreate(req *api.ContainerCreateRequest) (*api.Containe
 		config.Labels = make(map[string]string)
 	}
 
+	// Images from `docker load` / `docker import` run from the ACR copy
+	// they were pushed to.
+	config.Image = s.images.RegistryRef(config.Image)
+
 	// Resolve the image through the ACR pull-through cache if one is
-	// configured. Falls through to the plain docker ref when
-	// no registry or rule matches; ACA pulls Docker Hub refs directly.
+	// configured, creating the registry's cache rule first when
+	// sockerless manages mirrors. Falls through to the plain docker ref
+	// when no registry or rule matches; ACA pulls Docker Hub refs
+	// directly.
+	if _, err := s.EnsureRegistryMirror(s.ctx(), config.Image); err != nil {
+		return nil, err
+	}
 	if resolved, err := azurecommon.ResolveAzureImageURIWithCache(
 		s.ctx(),
 		s.azure.ACRCacheRules,
@@ -874,6 +883,18 @@ func (s *Server) ImageLoad(r io.Reader) (io.ReadCloser, error) {
 	return s.images.Load(r)
 }
 
+// ImageImport delegates to ImageManager, which pushes the imported
+// image into the cloud registry.
+func (s *Server) ImageImport(r io.Reader, opts core.ImageImportOptions) (io.ReadCloser, error) {
+	return s.images.Import(r, opts)
+}
+
+// ContainerCheckpoint delegates to ImageManager, which pushes the
+// committed image into the cloud registry for `sockerless migrate`.
+func (s *Server) ContainerCheckpoint(ref string) (*core.ContainerCheckpoint, error) {
+	return s.images.Checkpoint(ref)
+}
+
 // VolumeRemove deletes the Azure Files share + managed-env storage
 // resource backing a named volume. The storage account is left in
 // place so other volumes keep working.
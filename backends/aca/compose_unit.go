@@ -0,0 +1,70 @@
+package aca
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appcontainers/armappcontainers/v3"
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// applyComposeProbes maps a compose unit's service_healthy dependencies
+// onto ACA. Container Apps starts a revision's containers together and
+// has no per-container start ordering, so each such dependency gets a
+// TCP startup probe on its exposed port, with the health check's
+// timings: the revision only turns ready once they pass. ACA probes
+// cannot run the Docker health check command itself.
+func applyComposeProbes(specs []*armappcontainers.Container, containers []containerInput) error {
+	unit := make([]api.Container, len(containers))
+	index := make(map[string]int, len(containers))
+	for i, ci := range containers {
+		unit[i] = *ci.Container
+		index[ci.ID] = i
+	}
+	for id, deps := range core.ComposeUnitDependencies(unit) {
+		for _, d := range deps {
+			if d.Condition != core.ComposeServiceHealthy {
+				continue
+			}
+			dep := unit[index[d.ContainerID]]
+			port := exposedPort(dep)
+			if !core.HasHealthcheck(dep) || port == 0 {
+				return &api.InvalidParameterError{Message: fmt.Sprintf(
+					"service %q depends on %q being healthy, which needs a healthcheck and an exposed port for the ACA startup probe",
+					unit[index[id]].Config.Labels[core.ComposeServiceLabel], dep.Config.Labels[core.ComposeServiceLabel])}
+			}
+			interval, timeout, startPeriod, retries := core.HealthcheckTiming(dep.Config.Healthcheck)
+			seconds := func(d time.Duration, lo, hi int32) *int32 {
+				return ptr(min(max(int32(d/time.Second), lo), hi))
+			}
+			specs[index[d.ContainerID]].Probes = []*armappcontainers.ContainerAppProbe{{
+				Type:                ptr(armappcontainers.TypeStartup),
+				TCPSocket:           &armappcontainers.ContainerAppProbeTCPSocket{Port: ptr(int32(port))},
+				InitialDelaySeconds: seconds(startPeriod, 0, 60),
+				PeriodSeconds:       seconds(interval, 1, 240),
+				TimeoutSeconds:      seconds(timeout, 1, 240),
+				FailureThreshold:    ptr(int32(min(max(retries, 1), 10))),
+			}}
+		}
+	}
+	return nil
+}
+
+// exposedPort returns the lowest TCP port c exposes, or 0.
+func exposedPort(c api.Container) int {
+	lowest := 0
+	for key := range c.Config.ExposedPorts {
+		num, proto, _ := strings.Cut(key, "/")
+		port, err := strconv.Atoi(num)
+		if err != nil || (proto != "" && proto != "tcp") {
+			continue
+		}
+		if lowest == 0 || port < lowest {
+			lowest = port
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,20 @@
+package aca
+
+import (
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// ContainerCostShape prices a container at the consumption tier
+// jobspec.go assigns its Job or ContainerApp. Named volumes are Azure
+// Files shares.
+func (s *Server) ContainerCostShape(*api.Container) core.CostShape {
+	cpu, memory := mapCPUTier()
+	mib, _ := core.ParseMemoryMiB(memory)
+	return core.CostShape{
+		Kind:         core.CostKindACA,
+		VCPU:         cpu,
+		MemoryGiB:    float64(mib) / 1024,
+		StorageClass: core.CostStorageAzureFiles,
+	}
+}
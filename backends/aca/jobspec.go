@@ -130,6 +130,11 @@ func (s *Server) buildJobSpec(ctx context.Context, containers []containerInput)
 			volSeen[volName] = struct{}{}
 		}
 	}
+	if len(containers) > 1 {
+		if err := applyComposeProbes(specs, containers); err != nil {
+			return armappcontainers.Job{}, err
+		}
+	}
 
 	// Cloud-side ACA cap on top of any bootstrap timer. ACA's
 	// ReplicaTimeout caps at 7 days (604800s); we honour the shared
@@ -0,0 +1,87 @@
+package aca
+
+import (
+	"github.com/sockerless/api"
+	azurecommon "github.com/sockerless/azure-common"
+	core "github.com/sockerless/backend-core"
+)
+
+// requiredPermissions declares every RBAC operation the ACA backend
+// uses under config's driver selection. `sockerless check` probes the
+// set against the caller's permissions on the resource group; keep it
+// in step with the SDK calls in this package. Exec and the filesystem
+// verbs ride the reverse-agent WebSocket and need no operation.
+func requiredPermissions(config Config) *core.PermissionSet {
+	var set core.PermissionSet
+
+	// Container lifecycle: one ACA Job per container, started as an
+	// execution.
+	set.Add("containers", []string{"create", "run", "start"},
+		"Microsoft.App/jobs/write",
+		"Microsoft.App/jobs/read",
+		"Microsoft.App/jobs/start/action",
+		"Microsoft.App/managedEnvironments/join/action",
+	)
+	set.Add("containers", []string{"stop", "kill"},
+		"Microsoft.App/jobs/executions/read",
+		"Microsoft.App/jobs/stop/action",
+	)
+	set.Add("containers", []string{"rm", "container prune"}, "Microsoft.App/jobs/delete", "Microsoft.App/jobs/read")
+	set.Add("containers", []string{"ps", "inspect", "wait"},
+		"Microsoft.App/jobs/read",
+		"Microsoft.App/jobs/executions/read",
+	)
+	if config.UseApp {
+		driver := "containers app"
+		set.Add(driver, []string{"create", "run", "start"},
+			"Microsoft.App/containerApps/write",
+			"Microsoft.App/containerApps/read",
+			"Microsoft.App/managedEnvironments/join/action",
+		)
+		set.Add(driver, []string{"rm", "container prune"}, "Microsoft.App/containerApps/delete")
+		set.Add(driver, []string{"ps", "inspect"}, "Microsoft.App/containerApps/read")
+	}
+
+	set.Add("logs log-analytics", []string{"logs", "attach", "run"},
+		"Microsoft.OperationalInsights/workspaces/query/read",
+	)
+	azurecommon.AddAzureMonitorStatsPermissions(&set)
+	if config.ImageScan.Scanner == "cloud" {
+		azurecommon.AddDefenderScanPermissions(&set)
+	}
+
+	// Docker networks map to network security groups.
+	set.Add("network nsg", []string{"network create"},
+		"Microsoft.Network/networkSecurityGroups/write",
+		"Microsoft.Network/networkSecurityGroups/read",
+		"Microsoft.Network/networkSecurityGroups/securityRules/write",
+	)
+	set.Add("network nsg", []string{"network rm"}, "Microsoft.Network/networkSecurityGroups/delete")
+	set.Add("network nsg", []string{"network ls", "network inspect"}, "Microsoft.Network/networkSecurityGroups/read")
+
+	if config.NetworkDiscovery == api.NetworkDiscoveryCloudDNS {
+		azurecommon.AddPrivateDNSPermissions(&set)
+		set.Add("network-discovery private-dns", []string{"run --network", "network connect"}, "Microsoft.App/containerApps/read")
+	}
+
+	// Named volumes are Azure Files shares bound to the managed
+	// environment.
+	azurecommon.AddAzureFilesPermissions(&set)
+	set.Add("volumes azure-files", []string{"volume create", "run -v"}, "Microsoft.App/managedEnvironments/storages/write")
+	set.Add("volumes azure-files", []string{"volume rm", "volume prune"}, "Microsoft.App/managedEnvironments/storages/delete")
+
+	azurecommon.AddACRPermissions(&set)
+	if config.ACRName != "" {
+		azurecommon.AddACRMirrorPermissions(&set)
+	}
+	if config.ACRName != "" && config.BuildStorageAccount != "" {
+		azurecommon.AddACRBuildPermissions(&set)
+	}
+	if config.Audit.Sink != "" {
+		azurecommon.AddBlobAuditPermissions(&set)
+	}
+	if config.HA.Mode != "" {
+		azurecommon.AddBlobLeasePermissions(&set)
+	}
+	return &set
+}
@@ -0,0 +1,96 @@
+package aca
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+func permissionIndex(set *core.PermissionSet) map[string]core.PermissionRequirement {
+	out := map[string]core.PermissionRequirement{}
+	for _, r := range set.Requirements() {
+		out[r.Permission] = r
+	}
+	return out
+}
+
+// TestRequiredPermissions_CoversSDKCalls scans this package for Azure
+// client calls and fails when one has no declared RBAC operation, so a
+// new call can't ship without its preflight entry.
+func TestRequiredPermissions_CoversSDKCalls(t *testing.T) {
+	operations := map[string]string{
+		"Jobs.BeginCreateOrUpdate":                  "Microsoft.App/jobs/write",
+		"Jobs.BeginStart":                           "Microsoft.App/jobs/start/action",
+		"Jobs.BeginStopExecution":                   "Microsoft.App/jobs/stop/action",
+		"Jobs.BeginDelete":                          "Microsoft.App/jobs/delete",
+		"Jobs.NewListByResourceGroupPager":          "Microsoft.App/jobs/read",
+		"Executions.NewListPager":                   "Microsoft.App/jobs/executions/read",
+		"ContainerApps.BeginCreateOrUpdate":         "Microsoft.App/containerApps/write",
+		"ContainerApps.BeginDelete":                 "Microsoft.App/containerApps/delete",
+		"ContainerApps.NewListByResourceGroupPager": "Microsoft.App/containerApps/read",
+		"Logs.QueryWorkspace":                       "Microsoft.OperationalInsights/workspaces/query/read",
+		"LogsHTTP.QueryWorkspace":                   "Microsoft.OperationalInsights/workspaces/query/read",
+		"PrivateDNSZones.BeginCreateOrUpdate":       "Microsoft.Network/privateDnsZones/write",
+		"PrivateDNSZones.BeginDelete":               "Microsoft.Network/privateDnsZones/delete",
+		"PrivateDNSZones.Get":                       "Microsoft.Network/privateDnsZones/read",
+		"NSG.BeginCreateOrUpdate":                   "Microsoft.Network/networkSecurityGroups/write",
+		"NSG.BeginDelete":                           "Microsoft.Network/networkSecurityGroups/delete",
+		"NSG.Get":                                   "Microsoft.Network/networkSecurityGroups/read",
+		"NSGRules.BeginCreateOrUpdate":              "Microsoft.Network/networkSecurityGroups/securityRules/write",
+		"EnvStorages.CreateOrUpdate":                "Microsoft.App/managedEnvironments/storages/write",
+		"EnvStorages.Delete":                        "Microsoft.App/managedEnvironments/storages/delete",
+	}
+	declared := permissionIndex(requiredPermissions(Config{
+		UseApp:              true,
+		NetworkDiscovery:    api.NetworkDiscoveryCloudDNS,
+		ACRName:             "registry",
+		BuildStorageAccount: "buildstore",
+	}))
+
+	call := regexp.MustCompile(`\.azure\.(\w+)\.([A-Z]\w+)\(`)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range call.FindAllStringSubmatch(string(src), -1) {
+			op, ok := operations[m[1]+"."+m[2]]
+			if !ok {
+				t.Errorf("%s: unmapped call %s.%s", f, m[1], m[2])
+				continue
+			}
+			if _, ok := declared[op]; !ok {
+				t.Errorf("%s: %s.%s needs %s, which requiredPermissions does not declare", f, m[1], m[2], op)
+			}
+		}
+	}
+}
+
+func TestRequiredPermissions_FollowDriverSelection(t *testing.T) {
+	base := permissionIndex(requiredPermissions(Config{NetworkDiscovery: api.NetworkDiscoveryHostAliases}))
+	for _, op := range []string{
+		"Microsoft.Network/privateDnsZones/A/write",
+		"Microsoft.App/containerApps/write",
+		"Microsoft.ContainerRegistry/registries/scheduleRun/action",
+	} {
+		if _, ok := base[op]; ok {
+			t.Errorf("%s must not be required without the driver that uses it", op)
+		}
+	}
+	verbs := strings.Join(base["Microsoft.App/managedEnvironments/storages/write"].Verbs, ",")
+	if verbs != "volume create,run -v" {
+		t.Errorf("env storage write verbs = %s, want volume create,run -v", verbs)
+	}
+}
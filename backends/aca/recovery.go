@@ -33,6 +33,7 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 
 			if managed && matchesInstance && job.Name != nil {
 				orphans = append(orphans, core.ResourceEntry{
+					ContainerID:  azureTagsToMap(job.Tags)["sockerless-container-id"],
 					Backend:      "aca",
 					ResourceType: "job",
 					ResourceID:   *job.Name,
@@ -2,6 +2,7 @@ package aca
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 
@@ -63,10 +64,28 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 		NCPU:            2,
 		MemTotal:        4294967296,
 	}, logger)
+	s.ComposeUnits = true
+	mirrorAuth, err := config.RegistryMirror.Credentials()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid registry mirror credentials")
+	}
+	if config.ACRName != "" {
+		s.Mirrors = &azurecommon.ACRMirrors{
+			CacheRules:     azureClients.ACRCacheRules,
+			CredentialSets: azureClients.ACRCredentialSets,
+			ResourceGroup:  config.ResourceGroup,
+			Registry:       config.ACRName,
+			KeyVaultURL:    config.MirrorKeyVaultURL,
+			Credential:     azureClients.Cred,
+			Credentials:    mirrorAuth,
+			Logger:         logger,
+		}
+	}
 	s.images = &core.ImageManager{
-		Base:   s.BaseServer,
-		Auth:   azurecommon.NewACRAuthProvider(logger),
-		Logger: logger,
+		Base:           s.BaseServer,
+		Auth:           azurecommon.NewACRAuthProvider(logger),
+		LoadRepository: acaImageRepository(config),
+		Logger:         logger,
 	}
 	s.storageBackings = core.NewStorageBackingRegistry()
 	s.storageBackings.Register(azurecommon.NewAzureFilesEphemeralDriver(config.StorageAccount))
@@ -138,6 +157,10 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 	case api.AccessMechanismAzureAD:
 		s.Access = azurecommon.NewAzureADAccess(azureClients.Cred, config.AccessPrincipal)
 	}
+	s.HealthChecker = core.NewPermissionPreflight(
+		azurecommon.NewRBACPermissionProber(azureClients.Permissions, config.ResourceGroup),
+		requiredPermissions(config),
+	)
 
 	mode := "cloud"
 	if config.EndpointURL != "" {
@@ -161,6 +184,7 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 	// notes). Container-side bootstrap dials SOCKERLESS_CALLBACK_URL →
 	// /v1/aca/reverse?session_id=<container>.
 	s.reverseAgents = core.NewReverseAgentRegistry()
+	s.InstrumentReverseAgents(s.reverseAgents)
 	s.Mux.HandleFunc("/v1/aca/reverse", core.HandleReverseAgentWS(s.reverseAgents, logger))
 	s.Drivers.Exec = &core.ReverseAgentExecDriver{Registry: s.reverseAgents, Logger: logger}
 	s.Drivers.Stream = &core.ReverseAgentStreamDriver{Registry: s.reverseAgents, Logger: logger}
@@ -171,6 +195,40 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 	s.Typed.FSWrite = core.NewReverseAgentFSWriteDriver(s.reverseAgents, "aca")
 	s.Typed.FSExport = core.NewReverseAgentFSExportDriver(s.reverseAgents, "aca")
 	s.Typed.Commit = core.NewReverseAgentCommitDriver(s.BaseServer, s.reverseAgents, "aca")
+	s.StatsProvider = s.newStatsProvider()
+	s.ConfigureCosts(config.Costs, s)
+	var auditSink core.AuditSink
+	if config.Audit.Sink != "" {
+		sink, err := azurecommon.NewBlobAuditSink(azureClients.Cred,
+			fmt.Sprintf("https://%s.blob.core.windows.net", config.StorageAccount), config.Audit.Sink, s.Desc.InstanceID)
+		if err != nil {
+			logger.Warn().Err(err).Msg("audit sink unavailable, audit records kept locally")
+		} else {
+			auditSink = sink
+		}
+	}
+	s.ConfigureAudit(config.Audit, auditSink)
+	if config.HA.Mode != "" {
+		leases, err := azurecommon.NewBlobLeaseStore(azureClients.Cred,
+			fmt.Sprintf("https://%s.blob.core.windows.net", config.StorageAccount), config.HA.Store)
+		if err != nil {
+			logger.Warn().Err(err).Msg("HA lease store unavailable, running as a single instance")
+		} else {
+			s.ConfigureHA(config.HA, leases)
+		}
+	}
+	s.ConfigureImageScanning(config.ImageScan, &azurecommon.DefenderScanner{
+		Endpoint:       config.EndpointURL,
+		Credential:     azureClients.Cred,
+		SubscriptionID: config.SubscriptionID,
+		Resolve:        s.registryImageURI,
+		Digest:         s.images.ImageDigest,
+		PollInterval:   config.PollInterval,
+		Timeout:        core.ImageScanTimeout,
+	}, s.images.WalkImageLayers)
+	if err := s.ConfigureImageVerification(config.ImageVerify, s.images, s.registryImageURI); err != nil {
+		logger.Fatal().Err(err).Msg("invalid image signature policy")
+	}
 
 	// Cloud-native typed Logs via Azure Monitor / Log Analytics.
 	logFactory := func(containerID string) core.CloudLogFetchFunc {
@@ -189,3 +247,28 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 func (s *Server) ctx() context.Context {
 	return context.Background()
 }
+
+// acaImageRepository returns the registry prefix `docker load` /
+// `docker import` push images under: SOCKERLESS_ACA_IMAGE_REPOSITORY,
+// else the ACRName registry.
+func acaImageRepository(config Config) string {
+	if config.ImageRepository != "" {
+		return config.ImageRepository
+	}
+	if config.ACRName == "" {
+		return ""
+	}
+	return config.ACRName + ".azurecr.io"
+}
+
+// registryImageURI maps an image reference to the ACR URI containers
+// run it from: the image Defender for Containers assesses and whose
+// signatures admission verifies. The cache rule of a mirrored registry
+// is created first when missing.
+func (s *Server) registryImageURI(ctx context.Context, ref string) (string, error) {
+	ref = s.images.RegistryRef(ref)
+	if _, err := s.EnsureRegistryMirror(ctx, ref); err != nil {
+		return "", err
+	}
+	return azurecommon.ResolveAzureImageURIWithCache(ctx, s.azure.ACRCacheRules, s.config.ResourceGroup, s.config.ACRName, ref)
+}
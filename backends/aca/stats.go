@@ -0,0 +1,45 @@
+package aca
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/monitor/azquery"
+	azurecommon "github.com/sockerless/azure-common"
+	core "github.com/sockerless/backend-core"
+)
+
+// newStatsProvider builds the `docker stats` source: cgroup counters
+// over the reverse agent when the container's bootstrap is connected,
+// otherwise Azure Monitor platform metrics for its Job or ContainerApp.
+func (s *Server) newStatsProvider() core.StatsProvider {
+	cpu := azurecommon.AzureMonitorMetric{Name: "UsageNanoCores", Aggregation: azquery.AggregationTypeAverage, Scale: 1e-9}
+	return &core.StatsSources{
+		Agents: s.reverseAgents,
+		Cloud: &azurecommon.AzureMonitorStats{
+			Client: s.azure.Metrics,
+			Resource: func(containerID string) (azurecommon.AzureMonitorResource, bool) {
+				base := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.App", s.config.SubscriptionID, s.config.ResourceGroup)
+				if s.config.UseApp {
+					state, ok := s.resolveAppACAState(s.ctx(), containerID)
+					if !ok {
+						return azurecommon.AzureMonitorResource{}, false
+					}
+					return azurecommon.AzureMonitorResource{
+						ID:     base + "/containerApps/" + state.AppName,
+						CPU:    cpu,
+						Memory: azurecommon.AzureMonitorMetric{Name: "WorkingSetBytes", Aggregation: azquery.AggregationTypeAverage, Scale: 1},
+					}, true
+				}
+				state, ok := s.resolveACAState(s.ctx(), containerID)
+				if !ok {
+					return azurecommon.AzureMonitorResource{}, false
+				}
+				return azurecommon.AzureMonitorResource{
+					ID:     base + "/jobs/" + state.JobName,
+					CPU:    cpu,
+					Memory: azurecommon.AzureMonitorMetric{Name: "UsageBytes", Aggregation: azquery.AggregationTypeAverage, Scale: 1},
+				}, true
+			},
+		},
+	}
+}
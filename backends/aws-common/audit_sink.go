@@ -0,0 +1,78 @@
+package awscommon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwltypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that CloudWatchAuditSink implements core.AuditSink.
+var _ core.AuditSink = (*CloudWatchAuditSink)(nil)
+
+// CloudWatchAuditSink ships audit records to a CloudWatch Logs log
+// group, one JSON event per record, in a stream named after the backend
+// instance. The group and stream are created on first write.
+type CloudWatchAuditSink struct {
+	Client *cloudwatchlogs.Client
+	Group  string
+	Stream string
+
+	mu      sync.Mutex
+	created bool
+}
+
+// WriteAudit implements core.AuditSink.
+func (s *CloudWatchAuditSink) WriteAudit(ctx context.Context, records []core.AuditRecord) error {
+	if err := s.ensureStream(ctx); err != nil {
+		return err
+	}
+	events := make([]cwltypes.InputLogEvent, 0, len(records))
+	for _, rec := range records {
+		msg, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		events = append(events, cwltypes.InputLogEvent{
+			Timestamp: aws.Int64(rec.Time.UnixMilli()),
+			Message:   aws.String(string(msg)),
+		})
+	}
+	_, err := s.Client.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String(s.Group),
+		LogStreamName: aws.String(s.Stream),
+		LogEvents:     events,
+	})
+	if err != nil {
+		return fmt.Errorf("put audit events to %s/%s: %w", s.Group, s.Stream, err)
+	}
+	return nil
+}
+
+func (s *CloudWatchAuditSink) ensureStream(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created {
+		return nil
+	}
+	var exists *cwltypes.ResourceAlreadyExistsException
+	if _, err := s.Client.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: aws.String(s.Group),
+	}); err != nil && !errors.As(err, &exists) {
+		return fmt.Errorf("create audit log group %s: %w", s.Group, err)
+	}
+	if _, err := s.Client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(s.Group),
+		LogStreamName: aws.String(s.Stream),
+	}); err != nil && !errors.As(err, &exists) {
+		return fmt.Errorf("create audit log stream %s/%s: %w", s.Group, s.Stream, err)
+	}
+	s.created = true
+	return nil
+}
@@ -0,0 +1,122 @@
+package awscommon
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	core "github.com/sockerless/backend-core"
+)
+
+// CloudAPIMetrics is an aws.Config API option that counts every AWS SDK
+// operation into the backend's sockerless_cloud_api_* metrics, labelled
+// with the SDK's service ID (ECS, Lambda, ECR, ...) and operation name,
+// and records every operation that is not a read in the audit log.
+// Timing covers the whole operation, retries included; an operation
+// that returns an error counts as failed.
+//
+//	cfg.APIOptions = append(cfg.APIOptions, awscommon.CloudAPIMetrics)
+func CloudAPIMetrics(stack *middleware.Stack) error {
+	// After: the service metadata middleware is registered at the front
+	// of the Initialize step, so the IDs are in ctx by the time this runs.
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("SockerlessCloudAPIMetrics",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			start := time.Now()
+			out, md, err := next.HandleInitialize(ctx, in)
+			service, op := awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx)
+			core.ObserveCloudAPICall(service, op, time.Since(start), err != nil)
+			if !core.IsCloudReadOperation(op) {
+				core.AuditCloudCall(ctx, service, op, AuditResource(in.Parameters, out.Result), time.Since(start), err)
+			}
+			return out, md, err
+		}), middleware.After)
+}
+
+// AuditResource names the resource an AWS operation acted on: an ARN in
+// its output (what a create or delete returns, e.g. RunTask's
+// Tasks[0].TaskArn), else the input's ARN, ID or name, else the input
+// field naming the resource (Task, Service, Cluster, ...).
+func AuditResource(input, output any) string {
+	if s := findResourceField(reflect.ValueOf(output), isARNField, 2); s != "" {
+		return s
+	}
+	in := reflect.ValueOf(input)
+	for _, match := range []func(string) bool{
+		isARNField,
+		func(name string) bool { return strings.HasSuffix(name, "Id") || strings.HasSuffix(name, "ID") },
+		func(name string) bool { return strings.HasSuffix(name, "Name") },
+	} {
+		if s := findResourceField(in, match, 0); s != "" {
+			return s
+		}
+	}
+	for _, name := range auditResourceFields {
+		if s := findResourceField(in, func(n string) bool { return n == name }, 0); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// auditResourceFields are input fields that name a resource without an
+// Arn / Id / Name suffix, most specific first.
+var auditResourceFields = []string{"Task", "Service", "TaskDefinition", "Resource", "Cluster"}
+
+func isARNField(name string) bool {
+	return strings.HasSuffix(name, "Arn") || strings.HasSuffix(name, "ARN")
+}
+
+// findResourceField returns the first non-empty string field of v whose
+// name matches, looking depth levels into nested structs and the first
+// element of slices.
+func findResourceField(v reflect.Value, match func(string) bool, depth int) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.IsExported() && match(f.Name) {
+			if s := stringField(v.Field(i)); s != "" {
+				return s
+			}
+		}
+	}
+	if depth == 0 {
+		return ""
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Slice {
+			if fv.Len() == 0 {
+				continue
+			}
+			fv = fv.Index(0)
+		}
+		if s := findResourceField(fv, match, depth-1); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func stringField(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	return ""
+}
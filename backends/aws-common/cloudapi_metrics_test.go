@@ -0,0 +1,35 @@
+package awscommon
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+func TestAuditResource(t *testing.T) {
+	type task struct{ TaskArn *string }
+	type runTaskOutput struct{ Tasks []task }
+	type stopTaskInput struct{ Cluster, Task *string }
+
+	cases := []struct {
+		name          string
+		input, output any
+		want          string
+	}{
+		{"output ARN", &efs.CreateFileSystemInput{CreationToken: aws.String("tok")},
+			&efs.CreateFileSystemOutput{FileSystemArn: aws.String("arn:aws:elasticfilesystem:us-east-1:1:file-system/fs-1"), FileSystemId: aws.String("fs-1")},
+			"arn:aws:elasticfilesystem:us-east-1:1:file-system/fs-1"},
+		{"nested output ARN", &stopTaskInput{}, &runTaskOutput{Tasks: []task{{TaskArn: aws.String("arn:aws:ecs:us-east-1:1:task/c/t1")}}},
+			"arn:aws:ecs:us-east-1:1:task/c/t1"},
+		{"input ID on failure", &efs.DeleteFileSystemInput{FileSystemId: aws.String("fs-1")}, nil, "fs-1"},
+		{"input name", &secretsmanager.DeleteSecretInput{SecretId: aws.String("s")}, &secretsmanager.DeleteSecretOutput{}, "s"},
+		{"resource field over cluster", &stopTaskInput{Cluster: aws.String("c"), Task: aws.String("t1")}, nil, "t1"},
+	}
+	for _, c := range cases {
+		if got := AuditResource(c.input, c.output); got != c.want {
+			t.Errorf("%s: AuditResource = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
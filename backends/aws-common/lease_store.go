@@ -0,0 +1,163 @@
+package awscommon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that DynamoDBLeaseStore implements core.LeaseStore.
+var _ core.LeaseStore = (*DynamoDBLeaseStore)(nil)
+
+// DynamoDBLeaseStore keeps HA leases in a DynamoDB table whose
+// partition key is the string attribute "key". Each item carries a
+// numeric "version" that every write bumps; writes are conditional on
+// it, so two instances can't both win a lease.
+type DynamoDBLeaseStore struct {
+	Client *dynamodb.Client
+	Table  string
+}
+
+// GetLease implements core.LeaseStore.
+func (s *DynamoDBLeaseStore) GetLease(ctx context.Context, key string) (core.Lease, bool, error) {
+	out, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.Table),
+		Key:            map[string]ddbtypes.AttributeValue{"key": &ddbtypes.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return core.Lease{}, false, fmt.Errorf("get lease %s from %s: %w", key, s.Table, err)
+	}
+	if len(out.Item) == 0 {
+		return core.Lease{}, false, nil
+	}
+	return leaseFromItem(out.Item), true, nil
+}
+
+// PutLease implements core.LeaseStore.
+func (s *DynamoDBLeaseStore) PutLease(ctx context.Context, l core.Lease) (core.Lease, error) {
+	next := int64(1)
+	in := &dynamodb.PutItemInput{
+		TableName:                aws.String(s.Table),
+		ExpressionAttributeNames: map[string]string{},
+	}
+	if l.Version == "" {
+		in.ConditionExpression = aws.String("attribute_not_exists(#k)")
+		in.ExpressionAttributeNames["#k"] = "key"
+	} else {
+		cur, err := strconv.ParseInt(l.Version, 10, 64)
+		if err != nil {
+			return core.Lease{}, fmt.Errorf("lease %s: invalid version %q", l.Key, l.Version)
+		}
+		next = cur + 1
+		in.ConditionExpression = aws.String("#v = :v")
+		in.ExpressionAttributeNames["#v"] = "version"
+		in.ExpressionAttributeValues = map[string]ddbtypes.AttributeValue{
+			":v": &ddbtypes.AttributeValueMemberN{Value: l.Version},
+		}
+	}
+	l.Version = strconv.FormatInt(next, 10)
+	in.Item = leaseItem(l)
+	if _, err := s.Client.PutItem(ctx, in); err != nil {
+		return core.Lease{}, s.writeError(l.Key, err)
+	}
+	return l, nil
+}
+
+// DeleteLease implements core.LeaseStore.
+func (s *DynamoDBLeaseStore) DeleteLease(ctx context.Context, l core.Lease) error {
+	_, err := s.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(s.Table),
+		Key:                       map[string]ddbtypes.AttributeValue{"key": &ddbtypes.AttributeValueMemberS{Value: l.Key}},
+		ConditionExpression:       aws.String("#v = :v"),
+		ExpressionAttributeNames:  map[string]string{"#v": "version"},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{":v": &ddbtypes.AttributeValueMemberN{Value: l.Version}},
+	})
+	if err != nil {
+		return s.writeError(l.Key, err)
+	}
+	return nil
+}
+
+// ListLeases implements core.LeaseStore.
+func (s *DynamoDBLeaseStore) ListLeases(ctx context.Context, prefix string) ([]core.Lease, error) {
+	in := &dynamodb.ScanInput{TableName: aws.String(s.Table), ConsistentRead: aws.Bool(true)}
+	if prefix != "" {
+		in.FilterExpression = aws.String("begins_with(#k, :p)")
+		in.ExpressionAttributeNames = map[string]string{"#k": "key"}
+		in.ExpressionAttributeValues = map[string]ddbtypes.AttributeValue{":p": &ddbtypes.AttributeValueMemberS{Value: prefix}}
+	}
+	var leases []core.Lease
+	p := dynamodb.NewScanPaginator(s.Client, in)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list leases in %s: %w", s.Table, err)
+		}
+		for _, item := range page.Items {
+			// Filter here too: not every DynamoDB-compatible endpoint
+			// evaluates FilterExpression.
+			if l := leaseFromItem(item); strings.HasPrefix(l.Key, prefix) {
+				leases = append(leases, l)
+			}
+		}
+	}
+	return leases, nil
+}
+
+func (s *DynamoDBLeaseStore) writeError(key string, err error) error {
+	var failed *ddbtypes.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return core.ErrLeaseConflict
+	}
+	return fmt.Errorf("write lease %s to %s: %w", key, s.Table, err)
+}
+
+func leaseItem(l core.Lease) map[string]ddbtypes.AttributeValue {
+	item := map[string]ddbtypes.AttributeValue{
+		"key":     &ddbtypes.AttributeValueMemberS{Value: l.Key},
+		"holder":  &ddbtypes.AttributeValueMemberS{Value: l.Holder},
+		"version": &ddbtypes.AttributeValueMemberN{Value: l.Version},
+	}
+	for name, v := range map[string]string{"address": l.Address, "origin": l.Origin, "name": l.Name} {
+		if v != "" {
+			item[name] = &ddbtypes.AttributeValueMemberS{Value: v}
+		}
+	}
+	if !l.Expires.IsZero() {
+		item["expires"] = &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(l.Expires.UnixMilli(), 10)}
+	}
+	return item
+}
+
+func leaseFromItem(item map[string]ddbtypes.AttributeValue) core.Lease {
+	str := func(name string) string {
+		switch v := item[name].(type) {
+		case *ddbtypes.AttributeValueMemberS:
+			return v.Value
+		case *ddbtypes.AttributeValueMemberN:
+			return v.Value
+		}
+		return ""
+	}
+	l := core.Lease{
+		Key:     str("key"),
+		Holder:  str("holder"),
+		Address: str("address"),
+		Origin:  str("origin"),
+		Name:    str("name"),
+		Version: str("version"),
+	}
+	if ms, err := strconv.ParseInt(str("expires"), 10, 64); err == nil {
+		l.Expires = time.UnixMilli(ms)
+	}
+	return l
+}
@@ -0,0 +1,253 @@
+package awscommon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/rs/zerolog"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that ECRMirrors implements core.RegistryMirrorManager.
+var _ core.RegistryMirrorManager = (*ECRMirrors)(nil)
+
+// ecrMirrorSecretPrefix is the Secrets Manager name prefix ECR requires
+// for pull-through cache credentials, followed by the prefix that marks
+// the secrets sockerless owns.
+const ecrMirrorSecretPrefix = "ecr-pullthroughcache/sockerless-"
+
+// ECRMirrors manages ECR pull-through cache rules. Upstream credentials
+// are stored in Secrets Manager and referenced by the rule's
+// CredentialArn.
+type ECRMirrors struct {
+	ECR       *ecr.Client
+	Secrets   *secretsmanager.Client
+	AccountID string
+	Region    string
+	// Credentials holds the upstream registry logins
+	// (core.RegistryMirrorConfig.Credentials).
+	Credentials *core.DockerConfig
+	// CachePublicGallery routes public.ecr.aws images, and Docker Hub
+	// library images through their public.ecr.aws/docker/library copy,
+	// via a pull-through cache instead of pulling them directly.
+	CachePublicGallery bool
+	Logger             zerolog.Logger
+}
+
+// Route implements core.RegistryMirrorManager. Docker Hub user/org
+// images need a login for the docker-hub upstream; without one they
+// are not routed and the backend's resolver reports why. Registries
+// ECR has no pull-through upstream for are not routed either.
+func (m *ECRMirrors) Route(ref string) (string, bool) {
+	registry, repo, _ := parseDockerRef(ref)
+	switch {
+	case strings.HasPrefix(ref, "sha256:"):
+		return "", false
+	case strings.Contains(registry, ".dkr.ecr.") && strings.HasSuffix(registry, ".amazonaws.com"):
+		return "", false
+	case registry == "public.ecr.aws":
+		return registry, m.CachePublicGallery
+	case core.MirrorUpstream(registry) == "docker.io":
+		if !strings.Contains(strings.TrimPrefix(repo, "library/"), "/") {
+			return "public.ecr.aws", m.CachePublicGallery
+		}
+		_, _, ok := m.Credentials.GetRegistryAuth("docker.io")
+		return "docker.io", ok
+	default:
+		_, _, ok := ecrUpstream(registry)
+		return registry, ok
+	}
+}
+
+// Ensure implements core.RegistryMirrorManager.
+func (m *ECRMirrors) Ensure(ctx context.Context, upstream string) (core.RegistryMirror, bool, error) {
+	prefix := core.MirrorName(upstream)
+	if rule, ok, err := m.describe(ctx, prefix); err != nil || ok {
+		return m.mirror(rule), false, err
+	}
+
+	kind, url, ok := ecrUpstream(upstream)
+	if !ok {
+		return core.RegistryMirror{}, false, fmt.Errorf("ECR pull-through cache does not support upstream registry %s", upstream)
+	}
+	in := &ecr.CreatePullThroughCacheRuleInput{
+		EcrRepositoryPrefix: aws.String(prefix),
+		UpstreamRegistryUrl: aws.String(url),
+		UpstreamRegistry:    kind,
+	}
+	if user, pass, ok := m.Credentials.GetRegistryAuth(upstream); ok {
+		arn, err := m.putCredential(ctx, prefix, upstream, user, pass)
+		if err != nil {
+			return core.RegistryMirror{}, false, err
+		}
+		in.CredentialArn = aws.String(arn)
+	}
+
+	out, err := m.ECR.CreatePullThroughCacheRule(ctx, in)
+	if err != nil {
+		var exists *ecrtypes.PullThroughCacheRuleAlreadyExistsException
+		if errors.As(err, &exists) {
+			rule, _, err := m.describe(ctx, prefix)
+			return m.mirror(rule), false, err
+		}
+		return core.RegistryMirror{}, false, fmt.Errorf("create pull-through cache rule %s: %w", prefix, err)
+	}
+	m.Logger.Info().Str("prefix", prefix).Str("upstream", url).Msg("created ECR pull-through cache rule")
+	return m.mirror(ecrtypes.PullThroughCacheRule{
+		EcrRepositoryPrefix: out.EcrRepositoryPrefix,
+		UpstreamRegistryUrl: out.UpstreamRegistryUrl,
+		CredentialArn:       out.CredentialArn,
+		CreatedAt:           out.CreatedAt,
+	}), true, nil
+}
+
+// List implements core.RegistryMirrorManager.
+func (m *ECRMirrors) List(ctx context.Context) ([]core.RegistryMirror, error) {
+	var mirrors []core.RegistryMirror
+	p := ecr.NewDescribePullThroughCacheRulesPaginator(m.ECR, &ecr.DescribePullThroughCacheRulesInput{})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("describe pull-through cache rules: %w", err)
+		}
+		for _, rule := range page.PullThroughCacheRules {
+			mirrors = append(mirrors, m.mirror(rule))
+		}
+	}
+	return mirrors, nil
+}
+
+// Remove implements core.RegistryMirrorManager. Cached repositories
+// created under the prefix stay; ECR lifecycle policies expire them.
+func (m *ECRMirrors) Remove(ctx context.Context, mirror core.RegistryMirror) error {
+	if _, err := m.ECR.DeletePullThroughCacheRule(ctx, &ecr.DeletePullThroughCacheRuleInput{
+		EcrRepositoryPrefix: aws.String(mirror.Name),
+	}); err != nil {
+		return fmt.Errorf("delete pull-through cache rule %s: %w", mirror.Name, err)
+	}
+	if strings.Contains(mirror.Credential, ":secret:"+ecrMirrorSecretPrefix) {
+		if _, err := m.Secrets.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{
+			SecretId:                   aws.String(mirror.Credential),
+			ForceDeleteWithoutRecovery: aws.Bool(true),
+		}); err != nil {
+			return fmt.Errorf("delete credential secret of %s: %w", mirror.Name, err)
+		}
+	}
+	return nil
+}
+
+// describe looks up the rule for prefix.
+func (m *ECRMirrors) describe(ctx context.Context, prefix string) (ecrtypes.PullThroughCacheRule, bool, error) {
+	out, err := m.ECR.DescribePullThroughCacheRules(ctx, &ecr.DescribePullThroughCacheRulesInput{
+		EcrRepositoryPrefixes: []string{prefix},
+	})
+	if err != nil {
+		var notFound *ecrtypes.PullThroughCacheRuleNotFoundException
+		if errors.As(err, &notFound) {
+			return ecrtypes.PullThroughCacheRule{}, false, nil
+		}
+		return ecrtypes.PullThroughCacheRule{}, false, fmt.Errorf("describe pull-through cache rule %s: %w", prefix, err)
+	}
+	for _, rule := range out.PullThroughCacheRules {
+		if aws.ToString(rule.EcrRepositoryPrefix) == prefix {
+			return rule, true, nil
+		}
+	}
+	return ecrtypes.PullThroughCacheRule{}, false, nil
+}
+
+// putCredential stores the upstream login in the secret shape ECR
+// reads ({"username", "accessToken"}) and returns its ARN.
+func (m *ECRMirrors) putCredential(ctx context.Context, prefix, upstream, user, pass string) (string, error) {
+	value, _ := json.Marshal(map[string]string{"username": user, "accessToken": pass})
+	name := ecrMirrorSecretPrefix + prefix
+	out, err := m.Secrets.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String(name),
+		Description:  aws.String("Upstream credentials for the " + upstream + " pull-through cache"),
+		SecretString: aws.String(string(value)),
+	})
+	if err == nil {
+		return aws.ToString(out.ARN), nil
+	}
+	var exists *smtypes.ResourceExistsException
+	if !errors.As(err, &exists) {
+		return "", fmt.Errorf("store %s credentials in Secrets Manager: %w", upstream, err)
+	}
+	put, err := m.Secrets.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name),
+		SecretString: aws.String(string(value)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("update %s credentials in Secrets Manager: %w", upstream, err)
+	}
+	return aws.ToString(put.ARN), nil
+}
+
+func (m *ECRMirrors) mirror(rule ecrtypes.PullThroughCacheRule) core.RegistryMirror {
+	prefix := aws.ToString(rule.EcrRepositoryPrefix)
+	upstream := core.MirrorUpstream(aws.ToString(rule.UpstreamRegistryUrl))
+	mirror := core.RegistryMirror{
+		Upstream:   upstream,
+		Name:       prefix,
+		URI:        fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s", m.AccountID, m.Region, prefix),
+		Credential: aws.ToString(rule.CredentialArn),
+		Managed:    prefix == core.MirrorName(upstream),
+	}
+	if rule.CreatedAt != nil {
+		mirror.Created = *rule.CreatedAt
+	}
+	return mirror
+}
+
+// ecrUpstream maps an upstream registry host to ECR's upstream kind and
+// the URL the rule points at. ok is false for registries ECR
+// pull-through cache rules can't point at.
+func ecrUpstream(upstream string) (kind ecrtypes.UpstreamRegistry, url string, ok bool) {
+	switch {
+	case upstream == "public.ecr.aws":
+		return ecrtypes.UpstreamRegistryEcrPublic, upstream, true
+	case upstream == "docker.io":
+		return ecrtypes.UpstreamRegistryDockerHub, "registry-1.docker.io", true
+	case upstream == "ghcr.io":
+		return ecrtypes.UpstreamRegistryGitHubContainerRegistry, upstream, true
+	case upstream == "registry.gitlab.com":
+		return ecrtypes.UpstreamRegistryGitLabContainerRegistry, upstream, true
+	case upstream == "quay.io":
+		return ecrtypes.UpstreamRegistryQuay, upstream, true
+	case upstream == "registry.k8s.io":
+		return ecrtypes.UpstreamRegistryK8s, upstream, true
+	case upstream == "cgr.dev":
+		return ecrtypes.UpstreamRegistryChainguard, upstream, true
+	case strings.HasSuffix(upstream, ".azurecr.io"):
+		return ecrtypes.UpstreamRegistryAzureContainerRegistry, upstream, true
+	}
+	return "", "", false
+}
+
+// parseDockerRef splits a Docker image reference into registry, repo
+// and tag. "nginx:alpine" → ("", "nginx", "alpine").
+func parseDockerRef(ref string) (registry, repo, tag string) {
+	tag = "latest"
+	if i := strings.IndexByte(ref, '/'); i > 0 {
+		prefix := ref[:i]
+		if strings.ContainsAny(prefix, ".:") {
+			registry = prefix
+			ref = ref[i+1:]
+		}
+	}
+	if i := strings.LastIndexByte(ref, ':'); i > 0 {
+		repo = ref[:i]
+		tag = ref[i+1:]
+	} else {
+		repo = ref
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package awscommon
+
+import (
+	"testing"
+
+	core "github.com/sockerless/backend-core"
+)
+
+func TestECRUpstream(t *testing.T) {
+	cases := map[string]struct{ kind, url string }{
+		"docker.io":           {"docker-hub", "registry-1.docker.io"},
+		"ghcr.io":             {"github-container-registry", "ghcr.io"},
+		"registry.gitlab.com": {"gitlab-container-registry", "registry.gitlab.com"},
+		"quay.io":             {"quay", "quay.io"},
+		"registry.k8s.io":     {"k8s", "registry.k8s.io"},
+		"cgr.dev":             {"chainguard", "cgr.dev"},
+		"team.azurecr.io":     {"azure-container-registry", "team.azurecr.io"},
+		"public.ecr.aws":      {"ecr-public", "public.ecr.aws"},
+	}
+	for upstream, want := range cases {
+		kind, url, ok := ecrUpstream(upstream)
+		if !ok || string(kind) != want.kind || url != want.url {
+			t.Errorf("ecrUpstream(%q) = (%q, %q, %v), want (%q, %q, true)", upstream, kind, url, ok, want.kind, want.url)
+		}
+	}
+	for _, upstream := range []string{"mcr.microsoft.com", "k8s.gcr.io", "registry.example.com:5000", "gcr.io"} {
+		if kind, url, ok := ecrUpstream(upstream); ok {
+			t.Errorf("ecrUpstream(%q) = (%q, %q, true), want unsupported", upstream, kind, url)
+		}
+	}
+}
+
+func TestECRMirrorsRoute(t *testing.T) {
+	anon := &core.DockerConfig{Auths: map[string]core.DockerAuthEntry{}}
+	hub := &core.DockerConfig{Auths: map[string]core.DockerAuthEntry{
+		"https://index.docker.io/v1/": {Auth: "dXNlcjpwYXQ="},
+	}}
+	cases := []struct {
+		name         string
+		m            *ECRMirrors
+		ref          string
+		wantUpstream string
+		wantOK       bool
+	}{
+		{"library image", &ECRMirrors{Credentials: anon}, "alpine:3.19", "public.ecr.aws", false},
+		{"library image cached", &ECRMirrors{Credentials: anon, CachePublicGallery: true}, "docker.io/library/alpine", "public.ecr.aws", true},
+		{"org image without login", &ECRMirrors{Credentials: anon}, "myorg/app:v1", "docker.io", false},
+		{"org image with login", &ECRMirrors{Credentials: hub}, "myorg/app:v1", "docker.io", true},
+		{"public gallery", &ECRMirrors{Credentials: anon}, "public.ecr.aws/nginx/nginx", "public.ecr.aws", false},
+		{"public gallery cached", &ECRMirrors{Credentials: anon, CachePublicGallery: true}, "public.ecr.aws/nginx/nginx", "public.ecr.aws", true},
+		{"ghcr", &ECRMirrors{Credentials: anon}, "ghcr.io/owner/repo:v2", "ghcr.io", true},
+		{"unsupported upstream", &ECRMirrors{Credentials: anon}, "mcr.microsoft.com/dotnet/sdk:8.0", "mcr.microsoft.com", false},
+		{"private registry", &ECRMirrors{Credentials: anon}, "registry.example.com:5000/team/app", "registry.example.com:5000", false},
+		{"already ECR", &ECRMirrors{Credentials: anon}, "123456789012.dkr.ecr.eu-west-1.amazonaws.com/app:1", "", false},
+		{"digest", &ECRMirrors{Credentials: anon}, "sha256:abc", "", false},
+	}
+	for _, tc := range cases {
+		upstream, ok := tc.m.Route(tc.ref)
+		if upstream != tc.wantUpstream || ok != tc.wantOK {
+			t.Errorf("%s: Route(%q) = (%q, %v), want (%q, %v)", tc.name, tc.ref, upstream, ok, tc.wantUpstream, tc.wantOK)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Permission preflight for every AWS-product backend. The prober
+// resolves the identity the backend's credentials belong to (STS
+// GetCallerIdentity, with assumed-role sessions mapped back to their
+// IAM role) and evaluates the backend's declared actions against it
+// with iam:SimulatePrincipalPolicy. The shared Add*Permissions helpers
+// declare the actions of the drivers that live in this package so each
+// backend only lists its own service calls.
+
+package awscommon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	core "github.com/sockerless/backend-core"
+)
+
+// IAMPermissionProber implements core.PermissionProber with
+// iam:SimulatePrincipalPolicy. The probe itself needs
+// sts:GetCallerIdentity, iam:SimulatePrincipalPolicy and, for
+// assumed-role credentials, iam:GetRole.
+type IAMPermissionProber struct {
+	IAM *iam.Client
+	STS *sts.Client
+}
+
+// NewIAMPermissionProber constructs a prober over the given clients.
+func NewIAMPermissionProber(iamClient *iam.Client, stsClient *sts.Client) *IAMPermissionProber {
+	return &IAMPermissionProber{IAM: iamClient, STS: stsClient}
+}
+
+// Probe implements core.PermissionProber.
+func (p *IAMPermissionProber) Probe(ctx context.Context, reqs []core.PermissionRequirement) (*core.PermissionProbe, error) {
+	ident, err := p.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("sts:GetCallerIdentity: %w", err)
+	}
+	caller := aws.ToString(ident.Arn)
+	principal, root, err := p.policySourceARN(ctx, caller)
+	if err != nil {
+		return nil, err
+	}
+	probe := &core.PermissionProbe{Principal: principal, Method: "iam:SimulatePrincipalPolicy"}
+	if root {
+		// The account root user holds every action and can't be a
+		// SimulatePrincipalPolicy source.
+		probe.Method = "account root user"
+		return probe, nil
+	}
+
+	// One simulation per resource: the action list is evaluated
+	// against a single ResourceArns value so resource-scoped grants
+	// (iam:PassRole on a specific role) are judged on that resource.
+	var resources []string
+	byResource := map[string][]core.PermissionRequirement{}
+	for _, r := range reqs {
+		if _, ok := byResource[r.Resource]; !ok {
+			resources = append(resources, r.Resource)
+		}
+		byResource[r.Resource] = append(byResource[r.Resource], r)
+	}
+	for _, resource := range resources {
+		group := byResource[resource]
+		decisions, err := p.simulate(ctx, principal, resource, group)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range group {
+			d, ok := decisions[strings.ToLower(r.Permission)]
+			if !ok {
+				return nil, fmt.Errorf("iam:SimulatePrincipalPolicy returned no decision for %s", r.Permission)
+			}
+			if d != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+				probe.Denied = append(probe.Denied, r)
+			}
+		}
+	}
+	return probe, nil
+}
+
+func (p *IAMPermissionProber) simulate(ctx context.Context, principal, resource string, reqs []core.PermissionRequirement) (map[string]iamtypes.PolicyEvaluationDecisionType, error) {
+	in := &iam.SimulatePrincipalPolicyInput{PolicySourceArn: aws.String(principal)}
+	seen := map[string]bool{}
+	for _, r := range reqs {
+		if !seen[r.Permission] {
+			seen[r.Permission] = true
+			in.ActionNames = append(in.ActionNames, r.Permission)
+		}
+	}
+	if resource != "" {
+		in.ResourceArns = []string{resource}
+	}
+	decisions := map[string]iamtypes.PolicyEvaluationDecisionType{}
+	pager := iam.NewSimulatePrincipalPolicyPaginator(p.IAM, in)
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("iam:SimulatePrincipalPolicy for %s: %w", principal, err)
+		}
+		for _, res := range page.EvaluationResults {
+			decisions[strings.ToLower(aws.ToString(res.EvalActionName))] = res.EvalDecision
+		}
+	}
+	return decisions, nil
+}
+
+// policySourceARN maps a caller ARN to the IAM entity
+// SimulatePrincipalPolicy accepts. STS assumed-role session ARNs drop
+// the role path, so the role is looked up to recover its full ARN.
+func (p *IAMPermissionProber) policySourceARN(ctx context.Context, caller string) (string, bool, error) {
+	parts := strings.SplitN(caller, ":", 6)
+	if len(parts) != 6 {
+		return "", false, fmt.Errorf("unrecognised caller ARN %q", caller)
+	}
+	service, resource := parts[2], parts[5]
+	switch {
+	case service == "iam" && resource == "root":
+		return caller, true, nil
+	case service == "iam":
+		return caller, false, nil
+	case service == "sts" && strings.HasPrefix(resource, "assumed-role/"):
+		roleName, _, _ := strings.Cut(strings.TrimPrefix(resource, "assumed-role/"), "/")
+		out, err := p.IAM.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+		if err != nil {
+			return "", false, fmt.Errorf("iam:GetRole %s (resolving %s): %w", roleName, caller, err)
+		}
+		return aws.ToString(out.Role.Arn), false, nil
+	default:
+		return "", false, fmt.Errorf("caller %s is not an IAM user or role; iam:SimulatePrincipalPolicy cannot evaluate it", caller)
+	}
+}
+
+// AddECRPermissions declares the ECR actions behind image pull (token,
+// pull-through cache rules, manifest + layer reads), push / tag
+// (repository creation + layer upload) and rmi.
+func AddECRPermissions(set *core.PermissionSet) {
+	pull := []string{"pull", "create", "run"}
+	set.Add("registry", pull,
+		"ecr:GetAuthorizationToken",
+		"ecr:BatchGetImage",
+		"ecr:GetDownloadUrlForLayer",
+		"ecr:BatchCheckLayerAvailability",
+		"ecr:DescribePullThroughCacheRules",
+		"ecr:CreatePullThroughCacheRule",
+	)
+	set.Add("registry", []string{"push", "tag"},
+		"ecr:GetAuthorizationToken",
+		"ecr:CreateRepository",
+		"ecr:BatchCheckLayerAvailability",
+		"ecr:InitiateLayerUpload",
+		"ecr:UploadLayerPart",
+		"ecr:CompleteLayerUpload",
+		"ecr:PutImage",
+	)
+	set.Add("registry", []string{"rmi"}, "ecr:BatchDeleteImage")
+}
+
+// AddECRScanPermissions declares the actions of the cloud image
+// scanner (ECRScanner): reading findings and starting a basic scan.
+func AddECRScanPermissions(set *core.PermissionSet) {
+	set.Add("image-scanner ecr", []string{"image scan", "create", "pull"},
+		"ecr:DescribeImageScanFindings",
+		"ecr:StartImageScan",
+	)
+}
+
+// AddECRMirrorPermissions declares the actions of the registry mirror
+// manager (ECRMirrors): listing and removing pull-through cache rules
+// and keeping upstream credentials in Secrets Manager.
+func AddECRMirrorPermissions(set *core.PermissionSet) {
+	set.Add("registry mirrors", []string{"pull", "create", "registry mirrors"},
+		"ecr:DescribePullThroughCacheRules",
+		"ecr:CreatePullThroughCacheRule",
+		"secretsmanager:CreateSecret",
+		"secretsmanager:PutSecretValue",
+	)
+	set.Add("registry mirrors", []string{"registry mirrors prune"},
+		"ecr:DeletePullThroughCacheRule",
+		"secretsmanager:DeleteSecret",
+	)
+}
+
+// AddEFSEphemeralPermissions declares the actions of the
+// efs-ephemeral storage backing (EFSManager): the sockerless-owned
+// filesystem, its mount targets and the per-volume access points.
+func AddEFSEphemeralPermissions(set *core.PermissionSet) {
+	driver := "storage " + string(core.BackingEFSEphemeral)
+	set.Add(driver, []string{"volume create", "run -v"},
+		"elasticfilesystem:DescribeFileSystems",
+		"elasticfilesystem:CreateFileSystem",
+		"elasticfilesystem:TagResource",
+		"elasticfilesystem:DescribeMountTargets",
+		"elasticfilesystem:CreateMountTarget",
+		"elasticfilesystem:CreateAccessPoint",
+		"elasticfilesystem:DescribeAccessPoints",
+		// CreateMountTarget places an ENI in each subnet on the
+		// caller's behalf.
+		"ec2:DescribeSubnets",
+		"ec2:CreateNetworkInterface",
+		"ec2:DescribeNetworkInterfaces",
+	)
+	set.Add(driver, []string{"volume ls", "volume inspect"}, "elasticfilesystem:DescribeAccessPoints")
+	set.Add(driver, []string{"volume rm", "volume prune"},
+		"elasticfilesystem:DescribeAccessPoints",
+		"elasticfilesystem:DeleteAccessPoint",
+	)
+}
+
+// AddCloudMapPermissions declares the actions of the service-mesh
+// network-discovery driver (CloudMapDiscovery + CloudMapDNS) and the
+// per-network private DNS namespaces backing it. Cloud Map manages
+// the Route 53 hosted zone and records on the caller's behalf.
+func AddCloudMapPermissions(set *core.PermissionSet) {
+	driver := "network-discovery service-mesh"
+	set.Add(driver, []string{"network create"},
+		"servicediscovery:CreatePrivateDnsNamespace",
+		"servicediscovery:GetOperation",
+		"servicediscovery:ListNamespaces",
+		"route53:CreateHostedZone",
+		"route53:GetHostedZone",
+		"ec2:DescribeVpcs",
+		"ec2:DescribeVpcAttribute",
+	)
+	set.Add(driver, []string{"network rm"},
+		"servicediscovery:ListServices",
+		"servicediscovery:DeleteService",
+		"servicediscovery:DeleteNamespace",
+		"servicediscovery:GetOperation",
+		"servicediscovery:ListNamespaces",
+		"route53:DeleteHostedZone",
+	)
+	set.Add(driver, []string{"run --network", "network connect"},
+		"servicediscovery:GetNamespace",
+		"servicediscovery:ListServices",
+		"servicediscovery:CreateService",
+		"servicediscovery:RegisterInstance",
+		"servicediscovery:ListInstances",
+		"servicediscovery:DiscoverInstances",
+		"route53:ChangeResourceRecordSets",
+		"route53:GetHostedZone",
+	)
+	set.Add(driver, []string{"stop", "rm", "network disconnect"},
+		"servicediscovery:DeregisterInstance",
+		"servicediscovery:DeleteService",
+		"route53:ChangeResourceRecordSets",
+	)
+	set.Add(driver, []string{"network ls", "network inspect"},
+		"servicediscovery:ListNamespaces",
+		"servicediscovery:GetNamespace",
+		"servicediscovery:ListTagsForResource",
+	)
+}
+
+// AddCodeBuildPermissions declares the actions of CodeBuildService:
+// the build context upload to bucket and the CodeBuild run itself.
+func AddCodeBuildPermissions(set *core.PermissionSet, bucket string) {
+	driver := "build codebuild"
+	set.AddOn(driver, "arn:aws:s3:::"+bucket+"/*", []string{"build"}, "s3:PutObject")
+	set.Add(driver, []string{"build"},
+		"codebuild:StartBuild",
+		"codebuild:BatchGetBuilds",
+	)
+}
+
+// AddCloudWatchAuditPermissions declares the actions of
+// CloudWatchAuditSink, which ships the audit log to a log group.
+func AddCloudWatchAuditPermissions(set *core.PermissionSet) {
+	set.Add("audit cloudwatch", []string{"resources history"},
+		"logs:CreateLogGroup",
+		"logs:CreateLogStream",
+		"logs:PutLogEvents",
+	)
+}
+
+// AddDynamoDBLeasePermissions declares the actions of
+// DynamoDBLeaseStore, which multi-instance HA coordinates through.
+func AddDynamoDBLeasePermissions(set *core.PermissionSet) {
+	set.Add("ha dynamodb", []string{"create", "rm", "container prune"},
+		"dynamodb:GetItem",
+		"dynamodb:PutItem",
+		"dynamodb:DeleteItem",
+		"dynamodb:Scan",
+	)
+}
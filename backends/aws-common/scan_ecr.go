@@ -0,0 +1,227 @@
+package awscommon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that ECRScanner implements core.ImageScanner.
+var _ core.ImageScanner = (*ECRScanner)(nil)
+
+// ECRScanner reads ECR image scan findings (basic or enhanced
+// scanning, whichever the registry runs), starting a basic scan when
+// the image has none yet.
+type ECRScanner struct {
+	ecr *ecr.Client
+	// resolve maps a docker image reference to the ECR URI the backend
+	// runs it from.
+	resolve      func(ctx context.Context, ref string) (string, error)
+	pollInterval time.Duration
+	timeout      time.Duration
+}
+
+// NewECRScanner creates an ECRScanner. A scan still running after
+// timeout is reported IN_PROGRESS.
+func NewECRScanner(ecrClient *ecr.Client, resolve func(ctx context.Context, ref string) (string, error), pollInterval, timeout time.Duration) *ECRScanner {
+	return &ECRScanner{ecr: ecrClient, resolve: resolve, pollInterval: pollInterval, timeout: timeout}
+}
+
+// Available reports true; ECR scanning needs no local state.
+func (s *ECRScanner) Available() bool { return true }
+
+// Scan returns the findings for the ECR image ref resolves to.
+func (s *ECRScanner) Scan(ctx context.Context, ref string) (*core.ScanResult, error) {
+	uri, err := s.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	res := &core.ScanResult{ImageRef: uri, Scanner: "ecr"}
+	registryID, repo, imageID, ok := parseECRImageURI(uri)
+	if !ok {
+		res.ScanStatus = core.ScanStatusFailed
+		res.Message = fmt.Sprintf("%s is not in a private ECR repository; ECR scans private repositories only", uri)
+		res.ScanTime = time.Now().UTC()
+		return res, nil
+	}
+
+	started := false
+	deadline := time.Now().Add(s.timeout)
+	for {
+		out, findings, err := s.describeFindings(ctx, registryID, repo, imageID)
+		var notFound *ecrtypes.ScanNotFoundException
+		switch {
+		case errors.As(err, &notFound):
+			// No scan yet, or the one just started is not visible yet.
+			if !started {
+				if err := s.startScan(ctx, registryID, repo, imageID); err != nil {
+					return nil, err
+				}
+				started = true
+			}
+		case err != nil:
+			return nil, MapAWSError(err, "image", uri)
+		default:
+			if out.ImageId != nil && out.ImageId.ImageDigest != nil {
+				res.Digest = aws.ToString(out.ImageId.ImageDigest)
+			}
+			status := ecrtypes.ScanStatusInProgress
+			if out.ImageScanStatus != nil {
+				status = out.ImageScanStatus.Status
+				res.Message = aws.ToString(out.ImageScanStatus.Description)
+			}
+			switch status {
+			case ecrtypes.ScanStatusComplete, ecrtypes.ScanStatusActive:
+				res.ScanStatus = core.ScanStatusComplete
+				res.Message = ""
+				res.Vulnerabilities = findings
+				res.ScanTime = time.Now().UTC()
+				if out.ImageScanFindings != nil && out.ImageScanFindings.ImageScanCompletedAt != nil {
+					res.ScanTime = out.ImageScanFindings.ImageScanCompletedAt.UTC()
+				}
+				return res, nil
+			case ecrtypes.ScanStatusInProgress, ecrtypes.ScanStatusPending:
+			default:
+				res.ScanStatus = core.ScanStatusFailed
+				if res.Message == "" {
+					res.Message = "ECR scan status " + string(status)
+				}
+				res.ScanTime = time.Now().UTC()
+				return res, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			res.ScanStatus = core.ScanStatusInProgress
+			res.ScanTime = time.Now().UTC()
+			return res, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// startScan starts a basic scan. ECR allows one basic scan per image
+// per day, so LimitExceeded means a scan already exists.
+func (s *ECRScanner) startScan(ctx context.Context, registryID, repo string, imageID *ecrtypes.ImageIdentifier) error {
+	_, err := s.ecr.StartImageScan(ctx, &ecr.StartImageScanInput{
+		RegistryId:     aws.String(registryID),
+		RepositoryName: aws.String(repo),
+		ImageId:        imageID,
+	})
+	var limit *ecrtypes.LimitExceededException
+	if err != nil && !errors.As(err, &limit) {
+		return MapAWSError(err, "image", repo)
+	}
+	return nil
+}
+
+// describeFindings reads every page of findings, mapping basic and
+// enhanced findings onto core.Vulnerability.
+func (s *ECRScanner) describeFindings(ctx context.Context, registryID, repo string, imageID *ecrtypes.ImageIdentifier) (*ecr.DescribeImageScanFindingsOutput, []core.Vulnerability, error) {
+	var first *ecr.DescribeImageScanFindingsOutput
+	var vulns []core.Vulnerability
+	var next *string
+	for {
+		out, err := s.ecr.DescribeImageScanFindings(ctx, &ecr.DescribeImageScanFindingsInput{
+			RegistryId:     aws.String(registryID),
+			RepositoryName: aws.String(repo),
+			ImageId:        imageID,
+			NextToken:      next,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if first == nil {
+			first = out
+		}
+		if f := out.ImageScanFindings; f != nil {
+			for _, b := range f.Findings {
+				vulns = append(vulns, basicFinding(b))
+			}
+			for _, e := range f.EnhancedFindings {
+				vulns = append(vulns, enhancedFindings(e)...)
+			}
+		}
+		if out.NextToken == nil {
+			return first, vulns, nil
+		}
+		next = out.NextToken
+	}
+}
+
+func basicFinding(f ecrtypes.ImageScanFinding) core.Vulnerability {
+	v := core.Vulnerability{
+		ID:          aws.ToString(f.Name),
+		Severity:    string(f.Severity),
+		Description: aws.ToString(f.Description),
+		URI:         aws.ToString(f.Uri),
+	}
+	for _, a := range f.Attributes {
+		switch aws.ToString(a.Key) {
+		case "package_name":
+			v.Package = aws.ToString(a.Value)
+		case "package_version":
+			v.Version = aws.ToString(a.Value)
+		}
+	}
+	return v
+}
+
+// enhancedFindings yields one vulnerability per affected package.
+func enhancedFindings(f ecrtypes.EnhancedImageScanFinding) []core.Vulnerability {
+	base := core.Vulnerability{
+		Severity:    aws.ToString(f.Severity),
+		Description: aws.ToString(f.Description),
+	}
+	d := f.PackageVulnerabilityDetails
+	if d == nil {
+		base.ID = aws.ToString(f.Title)
+		return []core.Vulnerability{base}
+	}
+	base.ID = aws.ToString(d.VulnerabilityId)
+	base.URI = aws.ToString(d.SourceUrl)
+	if len(d.VulnerablePackages) == 0 {
+		return []core.Vulnerability{base}
+	}
+	out := make([]core.Vulnerability, 0, len(d.VulnerablePackages))
+	for _, p := range d.VulnerablePackages {
+		v := base
+		v.Package = aws.ToString(p.Name)
+		v.Version = aws.ToString(p.Version)
+		if r := aws.ToString(p.Release); r != "" {
+			v.Version += "-" + r
+		}
+		v.FixVersion = aws.ToString(p.FixedInVersion)
+		out = append(out, v)
+	}
+	return out
+}
+
+// parseECRImageURI splits <account>.dkr.ecr.<region>.amazonaws.com/
+// <repo>[:tag|@digest]. ok is false for anything but a private ECR
+// repository.
+func parseECRImageURI(uri string) (registryID, repo string, id *ecrtypes.ImageIdentifier, ok bool) {
+	host, rest, found := strings.Cut(uri, "/")
+	if !found || !strings.Contains(host, ".dkr.ecr.") || !strings.HasSuffix(host, ".amazonaws.com") {
+		return "", "", nil, false
+	}
+	registryID, _, _ = strings.Cut(host, ".")
+	if name, digest, found := strings.Cut(rest, "@"); found {
+		return registryID, name, &ecrtypes.ImageIdentifier{ImageDigest: aws.String(digest)}, true
+	}
+	tag := "latest"
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, tag = rest[:i], rest[i+1:]
+	}
+	return registryID, rest, &ecrtypes.ImageIdentifier{ImageTag: aws.String(tag)}, true
+}
@@ -0,0 +1,96 @@
+package azurecommon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/appendblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that BlobAuditSink implements core.AuditSink.
+var _ core.AuditSink = (*BlobAuditSink)(nil)
+
+// BlobAuditSink ships audit records to append blobs in a storage
+// account container, as JSON lines, one blob per backend instance per
+// day (<instance>/<yyyy-mm-dd>.jsonl) so no blob reaches the append
+// block limit. The container and blobs are created on first write.
+type BlobAuditSink struct {
+	client    *azblob.Client
+	container string
+	instance  string
+
+	mu      sync.Mutex
+	created map[string]bool // blob names known to exist
+}
+
+// NewBlobAuditSink writes to container in the storage account at
+// serviceURL (https://<account>.blob.core.windows.net).
+func NewBlobAuditSink(cred azcore.TokenCredential, serviceURL, container, instance string) (*BlobAuditSink, error) {
+	client, err := azblob.NewClient(serviceURL, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create audit blob client: %w", err)
+	}
+	return &BlobAuditSink{client: client, container: container, instance: instance, created: map[string]bool{}}, nil
+}
+
+// WriteAudit implements core.AuditSink.
+func (s *BlobAuditSink) WriteAudit(ctx context.Context, records []core.AuditRecord) error {
+	// Records are in time order; split the batch at day boundaries.
+	for len(records) > 0 {
+		day := records[0].Time.UTC().Format("2006-01-02")
+		var buf bytes.Buffer
+		n := 0
+		for ; n < len(records) && records[n].Time.UTC().Format("2006-01-02") == day; n++ {
+			line, err := json.Marshal(records[n])
+			if err != nil {
+				return err
+			}
+			buf.Write(append(line, '\n'))
+		}
+		name := s.instance + "/" + day + ".jsonl"
+		ab, err := s.appendBlob(ctx, name)
+		if err != nil {
+			return err
+		}
+		if _, err := ab.AppendBlock(ctx, streaming.NopCloser(bytes.NewReader(buf.Bytes())), nil); err != nil {
+			return fmt.Errorf("append audit records to %s/%s: %w", s.container, name, err)
+		}
+		records = records[n:]
+	}
+	return nil
+}
+
+func (s *BlobAuditSink) appendBlob(ctx context.Context, name string) (*appendblob.Client, error) {
+	cc := s.client.ServiceClient().NewContainerClient(s.container)
+	ab := cc.NewAppendBlobClient(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created[name] {
+		return ab, nil
+	}
+	if len(s.created) == 0 {
+		if _, err := cc.Create(ctx, nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+			return nil, fmt.Errorf("create audit container %s: %w", s.container, err)
+		}
+	}
+	_, err := ab.Create(ctx, &appendblob.CreateOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+		},
+	})
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobAlreadyExists) {
+		return nil, fmt.Errorf("create audit blob %s/%s: %w", s.container, name, err)
+	}
+	s.created[name] = true
+	return ab, nil
+}
@@ -0,0 +1,115 @@
+package azurecommon
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	core "github.com/sockerless/backend-core"
+)
+
+// CloudAPIMetrics is an Azure SDK per-call pipeline policy that counts
+// every ARM (and Log Analytics / Azure Monitor query) call into the
+// backend's sockerless_cloud_api_* metrics and records every mutation
+// (see ARMMutation) in the audit log. Being per-call rather than
+// per-retry, one call covers all of its retries. Add it to every client's
+// options:
+//
+//	opts.PerCallPolicies = append(opts.PerCallPolicies, azurecommon.CloudAPIMetrics{})
+type CloudAPIMetrics struct{}
+
+// Do implements policy.Policy.
+func (CloudAPIMetrics) Do(req *policy.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := req.Next()
+	raw := req.Raw()
+	service, op := ARMOperation(raw.Method, raw.URL.Path)
+	core.ObserveCloudAPICall(service, op, time.Since(start), err != nil || resp.StatusCode >= 400)
+	if ARMMutation(raw.Method, raw.URL.Path) {
+		auditErr := err
+		if err == nil && resp.StatusCode >= 400 {
+			auditErr = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		core.AuditCloudCall(raw.Context(), service, op, ARMResourceID(raw.URL.Path), time.Since(start), auditErr)
+	}
+	return resp, err
+}
+
+// ARMOperation names an Azure REST call for metrics: the service is the
+// resource provider namespace (Microsoft.App, Microsoft.Web, ...) and
+// the operation is the method followed by the resource types under it,
+// with names dropped, e.g. "POST jobs/start" or "GET containerApps".
+// Calls outside a provider are Microsoft.Resources, except the Log
+// Analytics query API (/v1/workspaces/...), which is
+// Microsoft.OperationalInsights.
+func ARMOperation(method, path string) (service, operation string) {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	service = "Microsoft.Resources"
+	rest := segs
+	// The last provider wins: extension resources such as metrics sit
+	// under another resource's path.
+	for i := len(segs) - 2; i >= 0; i-- {
+		if strings.EqualFold(segs[i], "providers") {
+			service = segs[i+1]
+			rest = segs[i+2:]
+			break
+		}
+	}
+	if service == "Microsoft.Resources" && len(segs) > 1 && segs[0] == "v1" && segs[1] == "workspaces" {
+		service = "Microsoft.OperationalInsights"
+		rest = segs[1:]
+	}
+	// Types and names alternate; a trailing odd segment is an action
+	// (start, stop, listKeys) and lands on a type position.
+	var types []string
+	for i := 0; i < len(rest); i += 2 {
+		if rest[i] != "" {
+			types = append(types, rest[i])
+		}
+	}
+	return service, method + " " + strings.Join(types, "/")
+}
+
+// armResourcePath splits an ARM path into the segments up to and
+// including the last provider namespace and the type / name pairs
+// under it, with a trailing action (start, listKeys) split off.
+func armResourcePath(path string) (prefix, rest []string, action string) {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	rest = segs
+	for i := len(segs) - 2; i >= 0; i-- {
+		if strings.EqualFold(segs[i], "providers") {
+			prefix, rest = segs[:i+2], segs[i+2:]
+			break
+		}
+	}
+	if len(prefix) > 0 && len(rest)%2 == 1 {
+		action, rest = rest[len(rest)-1], rest[:len(rest)-1]
+	}
+	return prefix, rest, action
+}
+
+// ARMMutation reports whether an ARM call changes state: PUT, PATCH and
+// DELETE do, and so do POST actions other than reads (listKeys,
+// query, checkNameAvailability).
+func ARMMutation(method, path string) bool {
+	switch method {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		_, _, action := armResourcePath(path)
+		if action == "" {
+			action = path[strings.LastIndex(path, "/")+1:]
+		}
+		return action == "" || !core.IsCloudReadOperation(strings.ToUpper(action[:1])+action[1:])
+	}
+	return false
+}
+
+// ARMResourceID returns the ARM resource ID a call acted on: the path
+// without any trailing action.
+func ARMResourceID(path string) string {
+	prefix, rest, _ := armResourcePath(path)
+	return "/" + strings.Join(append(prefix[:len(prefix):len(prefix)], rest...), "/")
+}
@@ -0,0 +1,47 @@
+package azurecommon
+
+import "testing"
+
+func TestARMOperation(t *testing.T) {
+	cases := []struct {
+		method, path, service, op string
+	}{
+		{"PUT", "/subscriptions/s/resourceGroups/rg/providers/Microsoft.App/jobs/sockerless-abc", "Microsoft.App", "PUT jobs"},
+		{"POST", "/subscriptions/s/resourceGroups/rg/providers/Microsoft.App/jobs/sockerless-abc/start", "Microsoft.App", "POST jobs/start"},
+		{"GET", "/subscriptions/s/resourceGroups/rg/providers/Microsoft.App/jobs/j/executions/e", "Microsoft.App", "GET jobs/executions"},
+		{"GET", "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Web/sites/f/providers/Microsoft.Insights/metrics", "Microsoft.Insights", "GET metrics"},
+		{"GET", "/subscriptions/s/resourceGroups/rg", "Microsoft.Resources", "GET subscriptions/resourceGroups"},
+		{"POST", "/v1/workspaces/w/query", "Microsoft.OperationalInsights", "POST workspaces/query"},
+	}
+	for _, c := range cases {
+		service, op := ARMOperation(c.method, c.path)
+		if service != c.service || op != c.op {
+			t.Errorf("ARMOperation(%s %s) = %q %q, want %q %q", c.method, c.path, service, op, c.service, c.op)
+		}
+	}
+}
+
+func TestARMMutation(t *testing.T) {
+	const job = "/subscriptions/s/resourceGroups/rg/providers/Microsoft.App/jobs/sockerless-abc"
+	cases := []struct {
+		method, path string
+		mutation     bool
+		resource     string
+	}{
+		{"PUT", job, true, job},
+		{"DELETE", job, true, job},
+		{"POST", job + "/start", true, job},
+		{"POST", job + "/executions/e/stop", true, job + "/executions/e"},
+		{"POST", "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/a/listKeys", false, "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/a"},
+		{"POST", "/v1/workspaces/w/query", false, "/v1/workspaces/w/query"},
+		{"GET", job, false, job},
+	}
+	for _, c := range cases {
+		if got := ARMMutation(c.method, c.path); got != c.mutation {
+			t.Errorf("ARMMutation(%s %s) = %v, want %v", c.method, c.path, got, c.mutation)
+		}
+		if got := ARMResourceID(c.path); got != c.resource {
+			t.Errorf("ARMResourceID(%s) = %q, want %q", c.path, got, c.resource)
+		}
+	}
+}
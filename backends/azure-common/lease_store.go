@@ -0,0 +1,164 @@
+package azurecommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that BlobLeaseStore implements core.LeaseStore.
+var _ core.LeaseStore = (*BlobLeaseStore)(nil)
+
+// BlobLeaseStore keeps HA leases as JSON block blobs (<key>.json) in a
+// storage account container. A blob's ETag is its version: writes
+// carry If-None-Match: * or If-Match, which Blob Storage evaluates
+// atomically. The container is created on first write.
+type BlobLeaseStore struct {
+	client    *azblob.Client
+	container string
+
+	mu      sync.Mutex
+	created bool
+}
+
+// NewBlobLeaseStore keeps leases in container in the storage account
+// at serviceURL (https://<account>.blob.core.windows.net).
+func NewBlobLeaseStore(cred azcore.TokenCredential, serviceURL, container string) (*BlobLeaseStore, error) {
+	client, err := azblob.NewClient(serviceURL, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create lease blob client: %w", err)
+	}
+	return &BlobLeaseStore{client: client, container: container}, nil
+}
+
+func (s *BlobLeaseStore) containerClient() *container.Client {
+	return s.client.ServiceClient().NewContainerClient(s.container)
+}
+
+// GetLease implements core.LeaseStore.
+func (s *BlobLeaseStore) GetLease(ctx context.Context, key string) (core.Lease, bool, error) {
+	resp, err := s.containerClient().NewBlobClient(key+".json").DownloadStream(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return core.Lease{}, false, nil
+	}
+	if err != nil {
+		return core.Lease{}, false, fmt.Errorf("get lease %s from %s: %w", key, s.container, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return core.Lease{}, false, fmt.Errorf("read lease %s from %s: %w", key, s.container, err)
+	}
+	var l core.Lease
+	if err := json.Unmarshal(data, &l); err != nil {
+		return core.Lease{}, false, fmt.Errorf("decode lease %s: %w", key, err)
+	}
+	if resp.ETag != nil {
+		l.Version = string(*resp.ETag)
+	}
+	return l, true, nil
+}
+
+// PutLease implements core.LeaseStore.
+func (s *BlobLeaseStore) PutLease(ctx context.Context, l core.Lease) (core.Lease, error) {
+	if err := s.ensureContainer(ctx); err != nil {
+		return core.Lease{}, err
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return core.Lease{}, err
+	}
+	resp, err := s.containerClient().NewBlockBlobClient(l.Key+".json").UploadBuffer(ctx, data, &blockblob.UploadBufferOptions{
+		AccessConditions: leaseConditions(l),
+		HTTPHeaders:      &blob.HTTPHeaders{BlobContentType: to.Ptr("application/json")},
+	})
+	if err != nil {
+		return core.Lease{}, s.writeError(l.Key, err)
+	}
+	if resp.ETag != nil {
+		l.Version = string(*resp.ETag)
+	}
+	return l, nil
+}
+
+// DeleteLease implements core.LeaseStore.
+func (s *BlobLeaseStore) DeleteLease(ctx context.Context, l core.Lease) error {
+	_, err := s.containerClient().NewBlobClient(l.Key+".json").Delete(ctx, &blob.DeleteOptions{AccessConditions: leaseConditions(l)})
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return core.ErrLeaseConflict
+	}
+	if err != nil {
+		return s.writeError(l.Key, err)
+	}
+	return nil
+}
+
+// ListLeases implements core.LeaseStore.
+func (s *BlobLeaseStore) ListLeases(ctx context.Context, prefix string) ([]core.Lease, error) {
+	var leases []core.Lease
+	pager := s.containerClient().NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: to.Ptr(prefix)})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if bloberror.HasCode(err, bloberror.ContainerNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list leases in %s: %w", s.container, err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil || !strings.HasSuffix(*item.Name, ".json") {
+				continue
+			}
+			l, ok, err := s.GetLease(ctx, strings.TrimSuffix(*item.Name, ".json"))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				leases = append(leases, l)
+			}
+		}
+	}
+	return leases, nil
+}
+
+func (s *BlobLeaseStore) ensureContainer(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created {
+		return nil
+	}
+	if _, err := s.containerClient().Create(ctx, nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		return fmt.Errorf("create lease container %s: %w", s.container, err)
+	}
+	s.created = true
+	return nil
+}
+
+func leaseConditions(l core.Lease) *blob.AccessConditions {
+	mod := &blob.ModifiedAccessConditions{}
+	if l.Version == "" {
+		mod.IfNoneMatch = to.Ptr(azcore.ETagAny)
+	} else {
+		mod.IfMatch = to.Ptr(azcore.ETag(l.Version))
+	}
+	return &blob.AccessConditions{ModifiedAccessConditions: mod}
+}
+
+func (s *BlobLeaseStore) writeError(key string, err error) error {
+	if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists) {
+		return core.ErrLeaseConflict
+	}
+	return fmt.Errorf("write lease %s to %s: %w", key, s.container, err)
+}
@@ -0,0 +1,244 @@
+package azurecommon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+	"github.com/rs/zerolog"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that ACRMirrors implements core.RegistryMirrorManager.
+var _ core.RegistryMirrorManager = (*ACRMirrors)(nil)
+
+// acrMirrorPrefix prefixes the cache rules, credential sets and Key
+// Vault secrets sockerless created.
+const acrMirrorPrefix = "sockerless-"
+
+// keyVaultAPIVersion is the Key Vault data-plane API version secrets
+// are written with.
+const keyVaultAPIVersion = "7.4"
+
+// ACRMirrors manages Azure Container Registry cache rules. Upstream
+// credentials are stored as Key Vault secrets referenced by an ACR
+// credential set; the credential set's system-assigned identity needs
+// the "Key Vault Secrets User" role on the vault to read them.
+type ACRMirrors struct {
+	CacheRules     *armcontainerregistry.CacheRulesClient
+	CredentialSets *armcontainerregistry.CredentialSetsClient
+	ResourceGroup  string
+	Registry       string // ACR name, without .azurecr.io
+	// KeyVaultURL is the vault upstream credentials are written to
+	// (https://<vault>.vault.azure.net). Required only when an upstream
+	// has credentials.
+	KeyVaultURL string
+	Credential  azcore.TokenCredential
+	// Credentials holds the upstream registry logins
+	// (core.RegistryMirrorConfig.Credentials).
+	Credentials *core.DockerConfig
+	Logger      zerolog.Logger
+}
+
+// Route implements core.RegistryMirrorManager: every registry other
+// than ACR itself, Docker Hub included, is pulled through a cache rule.
+func (m *ACRMirrors) Route(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "sha256:") && !strings.Contains(ref, "/") {
+		return "", false
+	}
+	registry, _, _ := parseDockerRef(ref)
+	if strings.HasSuffix(registry, ".azurecr.io") {
+		return "", false
+	}
+	return core.MirrorUpstream(registry), true
+}
+
+// Ensure implements core.RegistryMirrorManager. A rule already caching
+// every repository of upstream counts as its mirror, whoever created it.
+func (m *ACRMirrors) Ensure(ctx context.Context, upstream string) (core.RegistryMirror, bool, error) {
+	source := upstream + "/*"
+	pager := m.CacheRules.NewListPager(m.ResourceGroup, m.Registry, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return core.RegistryMirror{}, false, fmt.Errorf("list cache rules: %w", err)
+		}
+		for _, rule := range page.Value {
+			if rule != nil && rule.Properties != nil && deref(rule.Properties.SourceRepository) == source {
+				return m.mirror(rule), false, nil
+			}
+		}
+	}
+
+	name := acrMirrorPrefix + core.MirrorName(upstream)
+	props := &armcontainerregistry.CacheRuleProperties{
+		SourceRepository: to.Ptr(source),
+		TargetRepository: to.Ptr(core.MirrorName(upstream) + "/*"),
+	}
+	if user, pass, ok := m.Credentials.GetRegistryAuth(upstream); ok {
+		id, err := m.putCredentialSet(ctx, name, upstream, user, pass)
+		if err != nil {
+			return core.RegistryMirror{}, false, err
+		}
+		props.CredentialSetResourceID = to.Ptr(id)
+	}
+	poller, err := m.CacheRules.BeginCreate(ctx, m.ResourceGroup, m.Registry, name, armcontainerregistry.CacheRule{Properties: props}, nil)
+	if err != nil {
+		return core.RegistryMirror{}, false, fmt.Errorf("create cache rule %s: %w", name, err)
+	}
+	resp, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return core.RegistryMirror{}, false, fmt.Errorf("create cache rule %s: %w", name, err)
+	}
+	m.Logger.Info().Str("rule", name).Str("upstream", upstream).Msg("created ACR cache rule")
+	return m.mirror(&resp.CacheRule), true, nil
+}
+
+// List implements core.RegistryMirrorManager.
+func (m *ACRMirrors) List(ctx context.Context) ([]core.RegistryMirror, error) {
+	var mirrors []core.RegistryMirror
+	pager := m.CacheRules.NewListPager(m.ResourceGroup, m.Registry, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list cache rules: %w", err)
+		}
+		for _, rule := range page.Value {
+			if rule != nil && rule.Properties != nil {
+				mirrors = append(mirrors, m.mirror(rule))
+			}
+		}
+	}
+	return mirrors, nil
+}
+
+// Remove implements core.RegistryMirrorManager. Cached repositories
+// created under the rule's target stay in the registry.
+func (m *ACRMirrors) Remove(ctx context.Context, mirror core.RegistryMirror) error {
+	poller, err := m.CacheRules.BeginDelete(ctx, m.ResourceGroup, m.Registry, mirror.Name, nil)
+	if err == nil {
+		_, err = poller.PollUntilDone(ctx, nil)
+	}
+	if err != nil && !IsNotFound(err) {
+		return fmt.Errorf("delete cache rule %s: %w", mirror.Name, err)
+	}
+	if !strings.HasSuffix(mirror.Credential, "/credentialSets/"+mirror.Name) {
+		return nil
+	}
+	csPoller, err := m.CredentialSets.BeginDelete(ctx, m.ResourceGroup, m.Registry, mirror.Name, nil)
+	if err == nil {
+		_, err = csPoller.PollUntilDone(ctx, nil)
+	}
+	if err != nil && !IsNotFound(err) {
+		return fmt.Errorf("delete credential set %s: %w", mirror.Name, err)
+	}
+	for _, secret := range []string{mirror.Name + "-username", mirror.Name + "-password"} {
+		if err := m.keyVault(ctx, http.MethodDelete, secret, nil); err != nil {
+			return fmt.Errorf("delete credential secret of %s: %w", mirror.Name, err)
+		}
+	}
+	return nil
+}
+
+// putCredentialSet writes the upstream login to Key Vault and creates
+// the credential set the cache rule authenticates with, returning its
+// resource ID.
+func (m *ACRMirrors) putCredentialSet(ctx context.Context, name, upstream, user, pass string) (string, error) {
+	if m.KeyVaultURL == "" {
+		return "", fmt.Errorf("storing %s credentials requires a Key Vault; set SOCKERLESS_AZURE_MIRROR_KEYVAULT_URL", upstream)
+	}
+	secrets := map[string]string{name + "-username": user, name + "-password": pass}
+	for secret, value := range secrets {
+		if err := m.keyVault(ctx, http.MethodPut, secret, map[string]string{"value": value}); err != nil {
+			return "", fmt.Errorf("store %s credentials in Key Vault: %w", upstream, err)
+		}
+	}
+	vault := strings.TrimSuffix(m.KeyVaultURL, "/")
+	poller, err := m.CredentialSets.BeginCreate(ctx, m.ResourceGroup, m.Registry, name, armcontainerregistry.CredentialSet{
+		Identity: &armcontainerregistry.IdentityProperties{Type: to.Ptr(armcontainerregistry.ResourceIdentityTypeSystemAssigned)},
+		Properties: &armcontainerregistry.CredentialSetProperties{
+			LoginServer: to.Ptr(upstream),
+			AuthCredentials: []*armcontainerregistry.AuthCredential{{
+				Name:                     to.Ptr(armcontainerregistry.CredentialNameCredential1),
+				UsernameSecretIdentifier: to.Ptr(vault + "/secrets/" + name + "-username"),
+				PasswordSecretIdentifier: to.Ptr(vault + "/secrets/" + name + "-password"),
+			}},
+		},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("create credential set %s: %w", name, err)
+	}
+	resp, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("create credential set %s: %w", name, err)
+	}
+	return deref(resp.ID), nil
+}
+
+// keyVault sends a Key Vault data-plane request for secret. A DELETE of
+// a secret that does not exist succeeds.
+func (m *ACRMirrors) keyVault(ctx context.Context, method, secret string, body any) error {
+	if m.KeyVaultURL == "" {
+		return nil
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reqBody = bytes.NewReader(data)
+	}
+	reqURL := strings.TrimSuffix(m.KeyVaultURL, "/") + "/secrets/" + url.PathEscape(secret) + "?api-version=" + keyVaultAPIVersion
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.Credential != nil {
+		tok, err := m.Credential.GetToken(ctx, policy.TokenRequestOptions{
+			Scopes: []string{"https://vault.azure.net/.default"},
+		})
+		if err != nil {
+			return fmt.Errorf("key vault token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tok.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if method == http.MethodDelete && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s secret %s: HTTP %d: %s", method, secret, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func (m *ACRMirrors) mirror(rule *armcontainerregistry.CacheRule) core.RegistryMirror {
+	name := deref(rule.Name)
+	source, _ := trimWildcardSuffix(deref(rule.Properties.SourceRepository))
+	target, _ := trimWildcardSuffix(deref(rule.Properties.TargetRepository))
+	host, _, _ := strings.Cut(source, "/")
+	mirror := core.RegistryMirror{
+		Upstream:   core.MirrorUpstream(host),
+		Name:       name,
+		URI:        m.Registry + ".azurecr.io/" + strings.TrimSuffix(target, "/"),
+		Credential: deref(rule.Properties.CredentialSetResourceID),
+		Managed:    strings.HasPrefix(name, acrMirrorPrefix),
+	}
+	if rule.Properties.CreationDate != nil {
+		mirror.Created = *rule.Properties.CreationDate
+	}
+	return mirror
+}
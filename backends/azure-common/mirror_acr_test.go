@@ -0,0 +1,51 @@
+package azurecommon
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+)
+
+func TestACRMirrorsRoute(t *testing.T) {
+	m := &ACRMirrors{Registry: "myacr"}
+	cases := map[string]string{
+		"alpine":                       "docker.io",
+		"docker.io/myorg/app:v1":       "docker.io",
+		"ghcr.io/owner/tool:v2":        "ghcr.io",
+		"mcr.microsoft.com/dotnet/sdk": "mcr.microsoft.com",
+		"myacr.azurecr.io/img:tag":     "",
+		"sha256:abc":                   "",
+	}
+	for ref, want := range cases {
+		upstream, ok := m.Route(ref)
+		if upstream != want || ok != (want != "") {
+			t.Errorf("Route(%q) = (%q, %v), want %q", ref, upstream, ok, want)
+		}
+	}
+}
+
+func TestACRMirrorsMirror(t *testing.T) {
+	m := &ACRMirrors{Registry: "myacr"}
+	got := m.mirror(&armcontainerregistry.CacheRule{
+		Name: to.Ptr("sockerless-docker-hub"),
+		Properties: &armcontainerregistry.CacheRuleProperties{
+			SourceRepository:        to.Ptr("docker.io/*"),
+			TargetRepository:        to.Ptr("docker-hub/*"),
+			CredentialSetResourceID: to.Ptr("/subscriptions/s/resourceGroups/rg/providers/Microsoft.ContainerRegistry/registries/myacr/credentialSets/sockerless-docker-hub"),
+		},
+	})
+	if got.Upstream != "docker.io" || got.URI != "myacr.azurecr.io/docker-hub" || !got.Managed {
+		t.Errorf("mirror = %+v", got)
+	}
+	operator := m.mirror(&armcontainerregistry.CacheRule{
+		Name: to.Ptr("dockerhub-library"),
+		Properties: &armcontainerregistry.CacheRuleProperties{
+			SourceRepository: to.Ptr("docker.io/library/*"),
+			TargetRepository: to.Ptr("library/*"),
+		},
+	})
+	if operator.Upstream != "docker.io" || operator.Managed {
+		t.Errorf("operator rule = %+v, want unmanaged docker.io mirror", operator)
+	}
+}
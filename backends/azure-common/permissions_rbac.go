@@ -0,0 +1,191 @@
+// Permission preflight for every Azure-product backend. The prober
+// lists the caller's effective RBAC permissions on the backend's
+// resource group (Microsoft.Authorization/permissions) and matches the
+// backend's declared operations against each role's actions /
+// notActions. The shared Add*Permissions helpers declare the
+// operations of the drivers that live in this package.
+
+package azurecommon
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	core "github.com/sockerless/backend-core"
+)
+
+// RBACPermissionProber implements core.PermissionProber with the
+// resource-group permissions list. Deny assignments are not part of
+// that list and are not evaluated.
+type RBACPermissionProber struct {
+	Permissions   *armauthorization.PermissionsClient
+	ResourceGroup string
+}
+
+// NewRBACPermissionProber constructs a prober for resourceGroup.
+func NewRBACPermissionProber(client *armauthorization.PermissionsClient, resourceGroup string) *RBACPermissionProber {
+	return &RBACPermissionProber{Permissions: client, ResourceGroup: resourceGroup}
+}
+
+// Probe implements core.PermissionProber.
+func (p *RBACPermissionProber) Probe(ctx context.Context, reqs []core.PermissionRequirement) (*core.PermissionProbe, error) {
+	var grants []*armauthorization.Permission
+	pager := p.Permissions.NewListForResourceGroupPager(p.ResourceGroup, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list permissions on resource group %s: %w", p.ResourceGroup, err)
+		}
+		grants = append(grants, page.Value...)
+	}
+	probe := &core.PermissionProbe{
+		Principal: "caller on resource group " + p.ResourceGroup,
+		Method:    "Microsoft.Authorization/permissions",
+	}
+	for _, r := range reqs {
+		if !rbacGranted(grants, r.Permission) {
+			probe.Denied = append(probe.Denied, r)
+		}
+	}
+	return probe, nil
+}
+
+// rbacGranted reports whether any single role grants operation: a
+// notActions entry only masks the actions of the role it appears in.
+func rbacGranted(grants []*armauthorization.Permission, operation string) bool {
+	for _, g := range grants {
+		if g == nil {
+			continue
+		}
+		if anyPatternMatches(g.Actions, operation) && !anyPatternMatches(g.NotActions, operation) {
+			return true
+		}
+		if anyPatternMatches(g.DataActions, operation) && !anyPatternMatches(g.NotDataActions, operation) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyPatternMatches(patterns []*string, operation string) bool {
+	for _, p := range patterns {
+		if p != nil && core.MatchPermissionPattern(*p, operation) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPrivateDNSPermissions declares the operations of the
+// private-dns network-discovery driver and the per-network private
+// zones backing it.
+func AddPrivateDNSPermissions(set *core.PermissionSet) {
+	driver := "network-discovery private-dns"
+	set.Add(driver, []string{"network create"},
+		"Microsoft.Network/privateDnsZones/write",
+		"Microsoft.Network/privateDnsZones/read",
+	)
+	set.Add(driver, []string{"network rm"}, "Microsoft.Network/privateDnsZones/delete")
+	set.Add(driver, []string{"network ls", "network inspect"}, "Microsoft.Network/privateDnsZones/read")
+	set.Add(driver, []string{"run --network", "network connect"},
+		"Microsoft.Network/privateDnsZones/read",
+		"Microsoft.Network/privateDnsZones/A/write",
+		"Microsoft.Network/privateDnsZones/A/read",
+		"Microsoft.Network/privateDnsZones/CNAME/write",
+	)
+	set.Add(driver, []string{"stop", "rm", "network disconnect"},
+		"Microsoft.Network/privateDnsZones/A/delete",
+		"Microsoft.Network/privateDnsZones/CNAME/delete",
+	)
+}
+
+// AddAzureFilesPermissions declares the operations of FileShareManager,
+// which backs each named docker volume with an Azure Files share.
+func AddAzureFilesPermissions(set *core.PermissionSet) {
+	driver := "volumes azure-files"
+	set.Add(driver, []string{"volume create", "run -v"},
+		"Microsoft.Storage/storageAccounts/fileServices/shares/write",
+		"Microsoft.Storage/storageAccounts/fileServices/shares/read",
+	)
+	set.Add(driver, []string{"volume ls", "volume inspect"}, "Microsoft.Storage/storageAccounts/fileServices/shares/read")
+	set.Add(driver, []string{"volume rm", "volume prune"}, "Microsoft.Storage/storageAccounts/fileServices/shares/delete")
+}
+
+// AddAzureMonitorStatsPermissions declares the operation of
+// AzureMonitorStats, which serves `docker stats` from platform metrics
+// when no reverse agent is connected.
+func AddAzureMonitorStatsPermissions(set *core.PermissionSet) {
+	set.Add("stats azure-monitor", []string{"stats"}, "Microsoft.Insights/metrics/read")
+}
+
+// AddACRPermissions declares the Azure Container Registry operations
+// behind image pull (including the pull-through cache rule lookup),
+// push / tag and rmi.
+func AddACRPermissions(set *core.PermissionSet) {
+	set.Add("registry", []string{"pull", "create", "run"},
+		"Microsoft.ContainerRegistry/registries/pull/read",
+		"Microsoft.ContainerRegistry/registries/cacheRules/read",
+	)
+	set.Add("registry", []string{"push", "tag"}, "Microsoft.ContainerRegistry/registries/push/write")
+	set.Add("registry", []string{"rmi"}, "Microsoft.ContainerRegistry/registries/artifacts/delete")
+}
+
+// AddACRMirrorPermissions declares the operations of the registry
+// mirror manager (ACRMirrors): cache rules, the credential sets they
+// authenticate with and the Key Vault secrets holding upstream logins.
+func AddACRMirrorPermissions(set *core.PermissionSet) {
+	set.Add("registry mirrors", []string{"pull", "create", "registry mirrors"},
+		"Microsoft.ContainerRegistry/registries/cacheRules/read",
+		"Microsoft.ContainerRegistry/registries/cacheRules/write",
+		"Microsoft.ContainerRegistry/registries/credentialSets/write",
+		"Microsoft.KeyVault/vaults/secrets/setSecret/action",
+	)
+	set.Add("registry mirrors", []string{"registry mirrors prune"},
+		"Microsoft.ContainerRegistry/registries/cacheRules/delete",
+		"Microsoft.ContainerRegistry/registries/credentialSets/delete",
+		"Microsoft.KeyVault/vaults/secrets/delete",
+	)
+}
+
+// AddACRBuildPermissions declares the operations of ACRBuildService:
+// the build context upload (a blob data action) and the ACR Task run.
+func AddACRBuildPermissions(set *core.PermissionSet) {
+	driver := "build acr-tasks"
+	set.Add(driver, []string{"build"},
+		"Microsoft.Storage/storageAccounts/blobServices/containers/blobs/write",
+		"Microsoft.ContainerRegistry/registries/scheduleRun/action",
+		"Microsoft.ContainerRegistry/registries/runs/read",
+	)
+}
+
+// AddDefenderScanPermissions declares the operation of DefenderScanner,
+// which reads Defender for Containers vulnerability sub-assessments
+// through Resource Graph for image scanning and scan admission.
+func AddDefenderScanPermissions(set *core.PermissionSet) {
+	set.Add("image-scanner defender", []string{"image scan", "create", "pull"},
+		"Microsoft.ResourceGraph/resources/read",
+		"Microsoft.Security/assessments/subAssessments/read",
+	)
+}
+
+// AddBlobAuditPermissions declares the operations of BlobAuditSink:
+// creating its container and appending to its blobs (data actions).
+func AddBlobAuditPermissions(set *core.PermissionSet) {
+	set.Add("audit blob", []string{"resources history"},
+		"Microsoft.Storage/storageAccounts/blobServices/containers/write",
+		"Microsoft.Storage/storageAccounts/blobServices/containers/blobs/write",
+		"Microsoft.Storage/storageAccounts/blobServices/containers/blobs/add/action",
+	)
+}
+
+// AddBlobLeasePermissions declares the operations of BlobLeaseStore,
+// which multi-instance HA coordinates through (data actions).
+func AddBlobLeasePermissions(set *core.PermissionSet) {
+	set.Add("ha blob", []string{"create", "rm", "container prune"},
+		"Microsoft.Storage/storageAccounts/blobServices/containers/write",
+		"Microsoft.Storage/storageAccounts/blobServices/containers/blobs/read",
+		"Microsoft.Storage/storageAccounts/blobServices/containers/blobs/write",
+		"Microsoft.Storage/storageAccounts/blobServices/containers/blobs/delete",
+	)
+}
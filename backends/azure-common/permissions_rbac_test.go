@@ -0,0 +1,38 @@
+package azurecommon
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+)
+
+func TestRBACGranted_NotActionsMaskOnlyTheirRole(t *testing.T) {
+	contributor := &armauthorization.Permission{
+		Actions:    []*string{to.Ptr("*")},
+		NotActions: []*string{to.Ptr("Microsoft.Authorization/*/Write")},
+	}
+	acrPush := &armauthorization.Permission{
+		Actions: []*string{to.Ptr("Microsoft.ContainerRegistry/registries/push/write")},
+	}
+	blobWriter := &armauthorization.Permission{
+		DataActions: []*string{to.Ptr("Microsoft.Storage/storageAccounts/blobServices/containers/blobs/*")},
+	}
+	cases := []struct {
+		grants []*armauthorization.Permission
+		op     string
+		want   bool
+	}{
+		{[]*armauthorization.Permission{contributor}, "Microsoft.App/jobs/write", true},
+		{[]*armauthorization.Permission{contributor}, "Microsoft.Authorization/roleAssignments/write", false},
+		{[]*armauthorization.Permission{acrPush}, "Microsoft.App/jobs/write", false},
+		{[]*armauthorization.Permission{acrPush, contributor}, "Microsoft.ContainerRegistry/registries/push/write", true},
+		{[]*armauthorization.Permission{blobWriter}, "Microsoft.Storage/storageAccounts/blobServices/containers/blobs/write", true},
+		{nil, "Microsoft.App/jobs/read", false},
+	}
+	for _, c := range cases {
+		if got := rbacGranted(c.grants, c.op); got != c.want {
+			t.Errorf("rbacGranted(%s) = %v, want %v", c.op, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package azurecommon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that DefenderScanner implements core.ImageScanner.
+var _ core.ImageScanner = (*DefenderScanner)(nil)
+
+// DefenderScanner reads the container-image vulnerability sub-assessments
+// Microsoft Defender for Containers (MDVM) publishes for ACR images,
+// queried through Azure Resource Graph by manifest digest. Defender
+// assesses images on push and has no on-demand scan.
+type DefenderScanner struct {
+	// Endpoint is the ARM endpoint, https://management.azure.com when
+	// empty. Resource Graph is called directly over REST: the SDK's
+	// bearer-token policy refuses non-TLS simulator endpoints.
+	Endpoint       string
+	Credential     azcore.TokenCredential
+	SubscriptionID string
+	// Resolve maps a docker image reference to the ACR URI the backend
+	// runs it from.
+	Resolve func(ctx context.Context, ref string) (string, error)
+	// Digest returns the manifest digest of an ACR URI; sub-assessments
+	// are keyed by digest, not tag.
+	Digest       func(uri string) (string, error)
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// Available reports true; Defender needs no local state.
+func (s *DefenderScanner) Available() bool { return true }
+
+// Scan polls Resource Graph until Defender has assessed the image. An
+// image with no sub-assessments by the timeout is reported IN_PROGRESS:
+// Defender records nothing for an image it has not assessed, so an
+// empty answer cannot be told apart from a pending one.
+func (s *DefenderScanner) Scan(ctx context.Context, ref string) (*core.ScanResult, error) {
+	uri, err := s.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	res := &core.ScanResult{ImageRef: uri, Scanner: "defender"}
+	host, repo, ok := parseACRImageURI(uri)
+	if !ok {
+		res.ScanStatus = core.ScanStatusFailed
+		res.Message = fmt.Sprintf("%s is not in Azure Container Registry; Defender for Containers assesses ACR images only", uri)
+		res.ScanTime = time.Now().UTC()
+		return res, nil
+	}
+	digest, err := s.Digest(uri)
+	if err != nil {
+		return nil, fmt.Errorf("resolve digest of %s: %w", uri, err)
+	}
+	res.Digest = digest
+	query := strings.Join([]string{
+		"securityresources",
+		"where type == 'microsoft.security/assessments/subassessments'",
+		"where properties.additionalData.artifactDetails.registryHost == '" + host + "'",
+		"where properties.additionalData.artifactDetails.repositoryName == '" + repo + "'",
+		"where properties.additionalData.artifactDetails.digest == '" + digest + "'",
+	}, " | ")
+
+	deadline := time.Now().Add(s.Timeout)
+	for {
+		rows, err := s.query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) > 0 {
+			res.ScanStatus = core.ScanStatusComplete
+			for _, r := range rows {
+				if v, ok := r.vulnerability(); ok {
+					res.Vulnerabilities = append(res.Vulnerabilities, v)
+				}
+			}
+			res.ScanTime = time.Now().UTC()
+			return res, nil
+		}
+		if time.Now().After(deadline) {
+			res.ScanStatus = core.ScanStatusInProgress
+			res.Message = "Defender for Containers has no assessment of " + host + "/" + repo + "@" + digest + " yet; is Defender CSPM or Defender for Containers enabled on the subscription?"
+			res.ScanTime = time.Now().UTC()
+			return res, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.PollInterval):
+		}
+	}
+}
+
+// subAssessmentRow is one Resource Graph row of a container-image
+// vulnerability sub-assessment; only the fields Scan reads.
+type subAssessmentRow struct {
+	Properties struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"displayName"`
+		Description string `json:"description"`
+		Status      struct {
+			Code     string `json:"code"`
+			Severity string `json:"severity"`
+		} `json:"status"`
+		AdditionalData struct {
+			VulnerabilityDetails struct {
+				CveID      string `json:"cveId"`
+				Severity   string `json:"severity"`
+				References []struct {
+					Link string `json:"link"`
+				} `json:"references"`
+			} `json:"vulnerabilityDetails"`
+			SoftwareDetails struct {
+				PackageName  string `json:"packageName"`
+				Version      string `json:"version"`
+				FixedVersion string `json:"fixedVersion"`
+			} `json:"softwareDetails"`
+		} `json:"additionalData"`
+	} `json:"properties"`
+}
+
+// vulnerability maps an Unhealthy row; Healthy rows record findings
+// that no longer apply.
+func (r subAssessmentRow) vulnerability() (core.Vulnerability, bool) {
+	p := r.Properties
+	if !strings.EqualFold(p.Status.Code, "Unhealthy") {
+		return core.Vulnerability{}, false
+	}
+	d := p.AdditionalData
+	v := core.Vulnerability{
+		ID:          d.VulnerabilityDetails.CveID,
+		Package:     d.SoftwareDetails.PackageName,
+		Version:     d.SoftwareDetails.Version,
+		FixVersion:  d.SoftwareDetails.FixedVersion,
+		Severity:    d.VulnerabilityDetails.Severity,
+		Description: p.DisplayName,
+	}
+	if v.ID == "" {
+		v.ID = p.ID
+	}
+	if v.Severity == "" {
+		v.Severity = p.Status.Severity
+	}
+	if p.Description != "" {
+		v.Description = p.Description
+	}
+	if refs := d.VulnerabilityDetails.References; len(refs) > 0 {
+		v.URI = refs[0].Link
+	}
+	return v, true
+}
+
+// query runs a Resource Graph query over the subscription, following
+// $skipToken through every page.
+func (s *DefenderScanner) query(ctx context.Context, query string) ([]subAssessmentRow, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://management.azure.com"
+	}
+	reqURL := strings.TrimSuffix(endpoint, "/") + "/providers/Microsoft.ResourceGraph/resources?api-version=2021-03-01"
+	var rows []subAssessmentRow
+	skipToken := ""
+	for {
+		body := map[string]any{
+			"subscriptions": []string{s.SubscriptionID},
+			"query":         query,
+		}
+		if skipToken != "" {
+			body["options"] = map[string]any{"$skipToken": skipToken}
+		}
+		data, _ := json.Marshal(body)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if s.Credential != nil {
+			tok, err := s.Credential.GetToken(ctx, policy.TokenRequestOptions{
+				Scopes: []string{"https://management.azure.com/.default"},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("resource graph token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tok.Token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("resource graph query: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		}
+		var page struct {
+			Data      []subAssessmentRow `json:"data"`
+			SkipToken string             `json:"$skipToken"`
+		}
+		if err := json.Unmarshal(respBody, &page); err != nil {
+			return nil, fmt.Errorf("resource graph query: %w", err)
+		}
+		rows = append(rows, page.Data...)
+		if page.SkipToken == "" {
+			return rows, nil
+		}
+		skipToken = page.SkipToken
+	}
+}
+
+// parseACRImageURI splits <registry>.azurecr.io/<repo>[:tag|@digest].
+// ok is false for anything but an ACR image.
+func parseACRImageURI(uri string) (host, repo string, ok bool) {
+	host, rest, found := strings.Cut(uri, "/")
+	if !found || !strings.HasSuffix(host, ".azurecr.io") {
+		return "", "", false
+	}
+	if name, _, found := strings.Cut(rest, "@"); found {
+		return host, name, true
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest = rest[:i]
+	}
+	return host, rest, true
+}
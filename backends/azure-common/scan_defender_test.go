@@ -0,0 +1,98 @@
+package azurecommon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/sockerless/backend-core"
+)
+
+func TestDefenderScanner_MapsUnhealthySubAssessments(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/providers/Microsoft.ResourceGraph/resources" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var body struct {
+			Subscriptions []string `json:"subscriptions"`
+			Query         string   `json:"query"`
+			Options       struct {
+				SkipToken string `json:"$skipToken"`
+			} `json:"options"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		queries = append(queries, body.Query)
+		w.Header().Set("Content-Type", "application/json")
+		if body.Options.SkipToken == "" {
+			_, _ = w.Write([]byte(`{"data":[
+				{"properties":{"id":"CVE-1","displayName":"openssl flaw","status":{"code":"Unhealthy","severity":"High"},
+				 "additionalData":{"vulnerabilityDetails":{"cveId":"CVE-1","severity":"Critical","references":[{"link":"https://nvd/CVE-1"}]},
+				 "softwareDetails":{"packageName":"openssl","version":"3.0.11-1","fixedVersion":"3.0.11-2"}}}},
+				{"properties":{"id":"CVE-2","status":{"code":"Healthy","severity":"High"}}}],
+				"$skipToken":"1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"properties":{"id":"CVE-3","status":{"code":"Unhealthy","severity":"Low"}}}]}`))
+	}))
+	defer srv.Close()
+
+	s := &DefenderScanner{
+		Endpoint:       srv.URL,
+		SubscriptionID: "sub",
+		Resolve: func(_ context.Context, ref string) (string, error) {
+			return "myacr.azurecr.io/app:v1", nil
+		},
+		Digest:       func(string) (string, error) { return "sha256:abc", nil },
+		PollInterval: time.Millisecond,
+		Timeout:      time.Second,
+	}
+	res, err := s.Scan(t.Context(), "app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ScanStatus != core.ScanStatusComplete || res.Digest != "sha256:abc" || len(res.Vulnerabilities) != 2 {
+		t.Fatalf("result = %+v", res)
+	}
+	if v := res.Vulnerabilities[0]; v.ID != "CVE-1" || v.Severity != "Critical" || v.Package != "openssl" || v.FixVersion != "3.0.11-2" || v.URI != "https://nvd/CVE-1" {
+		t.Errorf("first finding = %+v", v)
+	}
+	if v := res.Vulnerabilities[1]; v.ID != "CVE-3" || v.Severity != "Low" {
+		t.Errorf("second finding = %+v", v)
+	}
+	for _, want := range []string{"registryHost == 'myacr.azurecr.io'", "repositoryName == 'app'", "digest == 'sha256:abc'"} {
+		if !strings.Contains(queries[0], want) {
+			t.Errorf("query %q lacks %q", queries[0], want)
+		}
+	}
+}
+
+func TestDefenderScanner_NoAssessmentIsInProgress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer srv.Close()
+	s := &DefenderScanner{
+		Endpoint:     srv.URL,
+		Resolve:      func(_ context.Context, ref string) (string, error) { return "myacr.azurecr.io/app@sha256:abc", nil },
+		Digest:       func(string) (string, error) { return "sha256:abc", nil },
+		PollInterval: time.Millisecond,
+		Timeout:      5 * time.Millisecond,
+	}
+	res, err := s.Scan(t.Context(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ScanStatus != core.ScanStatusInProgress {
+		t.Errorf("status = %s, want IN_PROGRESS", res.ScanStatus)
+	}
+
+	s.Resolve = func(_ context.Context, ref string) (string, error) { return "alpine:latest", nil }
+	if res, err = s.Scan(t.Context(), "alpine"); err != nil || res.ScanStatus != core.ScanStatusFailed {
+		t.Errorf("non-ACR image: res = %+v, err = %v", res, err)
+	}
+}
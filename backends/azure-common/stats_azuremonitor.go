@@ -0,0 +1,191 @@
+package azurecommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/monitor/azquery"
+	core "github.com/sockerless/backend-core"
+)
+
+// MetricsQuerier is the azquery.MetricsClient call AzureMonitorStats
+// makes. HTTPMetricsClient implements it for plain-HTTP endpoints.
+type MetricsQuerier interface {
+	QueryResource(ctx context.Context, resourceURI string, options *azquery.MetricsClientQueryResourceOptions) (azquery.MetricsClientQueryResourceResponse, error)
+}
+
+// HTTPMetricsClient calls Microsoft.Insights/metrics directly. azquery
+// v1.2.0 doesn't propagate InsecureAllowCredentialWithHTTP to its
+// BearerTokenPolicy, so the SDK client can't reach a non-TLS endpoint.
+type HTTPMetricsClient struct {
+	Endpoint string
+}
+
+// QueryResource implements MetricsQuerier.
+func (c *HTTPMetricsClient) QueryResource(ctx context.Context, resourceURI string, options *azquery.MetricsClientQueryResourceOptions) (azquery.MetricsClientQueryResourceResponse, error) {
+	q := url.Values{"api-version": {"2024-02-01"}}
+	if options != nil {
+		if options.MetricNames != nil {
+			q.Set("metricnames", *options.MetricNames)
+		}
+		if options.Interval != nil {
+			q.Set("interval", *options.Interval)
+		}
+		if options.Timespan != nil {
+			q.Set("timespan", string(*options.Timespan))
+		}
+		var aggs []string
+		for _, a := range options.Aggregation {
+			aggs = append(aggs, string(*a))
+		}
+		if len(aggs) > 0 {
+			q.Set("aggregation", strings.Join(aggs, ","))
+		}
+	}
+	reqURL := strings.TrimSuffix(c.Endpoint, "/") + "/" + strings.TrimPrefix(resourceURI, "/") + "/providers/Microsoft.Insights/metrics?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return azquery.MetricsClientQueryResourceResponse{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return azquery.MetricsClientQueryResourceResponse{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return azquery.MetricsClientQueryResourceResponse{}, fmt.Errorf("metrics query %s: HTTP %d", resourceURI, resp.StatusCode)
+	}
+	var result azquery.MetricsClientQueryResourceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result.Response); err != nil {
+		return azquery.MetricsClientQueryResourceResponse{}, err
+	}
+	return result, nil
+}
+
+// AzureMonitorMetric is one platform metric and how to read it: the
+// aggregation to request and the factor converting the per-minute
+// value to cores (CPU) or bytes (memory).
+type AzureMonitorMetric struct {
+	Name        string
+	Aggregation azquery.AggregationType
+	Scale       float64
+}
+
+// AzureMonitorResource is the ARM resource a container's metrics
+// publish under, with the metrics that carry its CPU and memory.
+type AzureMonitorResource struct {
+	ID     string
+	CPU    AzureMonitorMetric
+	Memory AzureMonitorMetric
+}
+
+// AzureMonitorStats is the Container Apps / Azure Functions
+// core.StatsProvider used when no reverse agent is connected.
+type AzureMonitorStats struct {
+	Client MetricsQuerier
+	// Resource resolves a container to its ARM resource; false means
+	// the container has no cloud workload yet.
+	Resource func(containerID string) (AzureMonitorResource, bool)
+}
+
+// ContainerMetrics implements core.StatsProvider.
+func (p *AzureMonitorStats) ContainerMetrics(containerID string) (*core.ContainerMetrics, error) {
+	res, ok := p.Resource(containerID)
+	if !ok {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	timespan := azquery.NewTimeInterval(now.Add(-5*time.Minute), now)
+	aggs := []*azquery.AggregationType{to.Ptr(res.CPU.Aggregation)}
+	if res.Memory.Aggregation != res.CPU.Aggregation {
+		aggs = append(aggs, to.Ptr(res.Memory.Aggregation))
+	}
+	resp, err := p.Client.QueryResource(ctx, res.ID, &azquery.MetricsClientQueryResourceOptions{
+		MetricNames: to.Ptr(res.CPU.Name + "," + res.Memory.Name),
+		Aggregation: aggs,
+		Interval:    to.Ptr("PT1M"),
+		Timespan:    &timespan,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("azure monitor metrics: %w", err)
+	}
+	return azureMonitorMetrics(resp.Value, res), nil
+}
+
+// azureMonitorMetrics picks each metric's newest datapoint. Azure
+// Monitor returns one datapoint per grain, oldest first, and grains
+// with no data carry no aggregation value. A datapoint's timestamp is
+// the start of its minute, so SampledAt is that plus the grain.
+func azureMonitorMetrics(metrics []*azquery.Metric, res AzureMonitorResource) *core.ContainerMetrics {
+	m := &core.ContainerMetrics{Source: "azure-monitor"}
+	var cpuAt, memAt time.Time
+	for _, metric := range metrics {
+		if metric == nil || metric.Name == nil || metric.Name.Value == nil {
+			continue
+		}
+		var want AzureMonitorMetric
+		switch *metric.Name.Value {
+		case res.CPU.Name:
+			want = res.CPU
+		case res.Memory.Name:
+			want = res.Memory
+		default:
+			continue
+		}
+		value, at := newestMetricValue(metric, want.Aggregation)
+		if at.IsZero() {
+			continue
+		}
+		if want.Name == res.CPU.Name {
+			m.CPUCores, cpuAt = value*want.Scale, at
+		} else {
+			m.MemBytes, memAt = int64(value*want.Scale), at
+		}
+	}
+	switch {
+	case cpuAt.IsZero() || memAt.IsZero():
+		// No datapoint yet for a new workload; SampledAt stays zero.
+	case cpuAt.Before(memAt):
+		m.SampledAt = cpuAt
+	default:
+		m.SampledAt = memAt
+	}
+	return m
+}
+
+func newestMetricValue(metric *azquery.Metric, agg azquery.AggregationType) (float64, time.Time) {
+	var value float64
+	var at time.Time
+	for _, ts := range metric.TimeSeries {
+		if ts == nil {
+			continue
+		}
+		for _, d := range ts.Data {
+			if d == nil || d.TimeStamp == nil {
+				continue
+			}
+			var v *float64
+			switch agg {
+			case azquery.AggregationTypeAverage:
+				v = d.Average
+			case azquery.AggregationTypeTotal:
+				v = d.Total
+			case azquery.AggregationTypeMaximum:
+				v = d.Maximum
+			}
+			if end := d.TimeStamp.Add(time.Minute); v != nil && end.After(at) {
+				value, at = *v, end
+			}
+		}
+	}
+	return value, at
+}
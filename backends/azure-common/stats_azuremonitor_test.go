@@ -0,0 +1,72 @@
+package azurecommon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/monitor/azquery"
+)
+
+func TestAzureMonitorStats_NewestPointPerMetric(t *testing.T) {
+	const resourceID = "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Web/sites/fn"
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != resourceID+"/providers/Microsoft.Insights/metrics" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[
+			{"name":{"value":"CpuTime"},"timeseries":[{"data":[
+				{"timeStamp":"2026-01-01T12:00:00Z","total":6},
+				{"timeStamp":"2026-01-01T12:01:00Z","total":30},
+				{"timeStamp":"2026-01-01T12:02:00Z"}]}]},
+			{"name":{"value":"MemoryWorkingSet"},"timeseries":[{"data":[
+				{"timeStamp":"2026-01-01T12:00:00Z","average":1048576}]}]}]}`))
+	}))
+	defer srv.Close()
+
+	p := &AzureMonitorStats{
+		Client: &HTTPMetricsClient{Endpoint: srv.URL},
+		Resource: func(string) (AzureMonitorResource, bool) {
+			return AzureMonitorResource{
+				ID:     resourceID,
+				CPU:    AzureMonitorMetric{Name: "CpuTime", Aggregation: azquery.AggregationTypeTotal, Scale: 1.0 / 60},
+				Memory: AzureMonitorMetric{Name: "MemoryWorkingSet", Aggregation: azquery.AggregationTypeAverage, Scale: 1},
+			}, true
+		},
+	}
+	m, err := p.ContainerMetrics("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CPUCores != 0.5 {
+		t.Errorf("CPUCores = %v, want 0.5 from the newest populated grain", m.CPUCores)
+	}
+	if m.MemBytes != 1<<20 {
+		t.Errorf("MemBytes = %d", m.MemBytes)
+	}
+	if want := time.Date(2026, 1, 1, 12, 1, 0, 0, time.UTC); !m.SampledAt.Equal(want) {
+		t.Errorf("SampledAt = %v, want %v (the older metric's grain end)", m.SampledAt, want)
+	}
+	if m.Source != "azure-monitor" {
+		t.Errorf("Source = %q", m.Source)
+	}
+	if !strings.Contains(query, "metricnames=CpuTime%2CMemoryWorkingSet") || !strings.Contains(query, "aggregation=Total%2CAverage") {
+		t.Errorf("query = %s", query)
+	}
+}
+
+func TestHTTPMetricsClient_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	c := &HTTPMetricsClient{Endpoint: srv.URL}
+	if _, err := c.QueryResource(t.Context(), "/subscriptions/s/x", nil); err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+}
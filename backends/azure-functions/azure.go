@@ -2,6 +2,7 @@ package azf
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -11,8 +12,12 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/monitor/azquery"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appservice/armappservice/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	azurecommon "github.com/sockerless/azure-common"
+	core "github.com/sockerless/backend-core"
 )
 
 type fakeCredential struct{}
@@ -27,6 +32,10 @@ type AzureClients struct {
 	Logs    *azquery.LogsClient
 	Cred    azcore.TokenCredential
 
+	// Metrics reads Azure Monitor platform metrics for `docker stats`
+	// when no reverse agent is connected.
+	Metrics azurecommon.MetricsQuerier
+
 	// FileShares provisions sockerless-managed Azure Files shares
 	// (shared with ACA via azurecommon.FileShareManager);
 	// StorageAccounts fetches the access key at mount-attach time so
@@ -40,6 +49,15 @@ type AzureClients struct {
 	// written by azurecommon.PrivateDNSDiscovery.
 	PrivateDNSZones   *armprivatedns.PrivateZonesClient
 	PrivateDNSRecords *armprivatedns.RecordSetsClient
+
+	// Permissions lists the caller's effective RBAC operations on the
+	// resource group for the `sockerless check` preflight.
+	Permissions *armauthorization.PermissionsClient
+
+	// ACR cache rules and credential sets back the registry mirrors
+	// Docker Hub and other upstream images are pulled through.
+	ACRCacheRules     *armcontainerregistry.CacheRulesClient
+	ACRCredentialSets *armcontainerregistry.CredentialSetsClient
 }
 
 // NewAzureClients initializes Azure SDK clients.
@@ -64,9 +82,14 @@ func newAzureClientsWithEndpoint(subscriptionID string, endpointURL string) (*Az
 						Endpoint: endpointURL,
 						Audience: "https://api.loganalytics.io/",
 					},
+					azquery.ServiceNameMetrics: {
+						Endpoint: endpointURL,
+						Audience: "https://management.azure.com/",
+					},
 				},
 			},
 			InsecureAllowCredentialWithHTTP: true,
+			PerCallPolicies:                 []policy.Policy{azurecommon.CloudAPIMetrics{}},
 		},
 	}
 
@@ -81,6 +104,17 @@ func newAzureClientsWithEndpoint(subscriptionID string, endpointURL string) (*Az
 	if err != nil {
 		return nil, err
 	}
+	// azquery v1.2.0 doesn't propagate InsecureAllowCredentialWithHTTP
+	// to its BearerTokenPolicy, so a non-TLS endpoint gets a direct HTTP
+	// metrics client.
+	var metrics azurecommon.MetricsQuerier
+	if strings.HasPrefix(endpointURL, "http://") {
+		metrics = &azurecommon.HTTPMetricsClient{Endpoint: endpointURL}
+	} else if metrics, err = azquery.NewMetricsClient(cred, &azquery.MetricsClientOptions{
+		ClientOptions: opts.ClientOptions,
+	}); err != nil {
+		return nil, err
+	}
 
 	fileShares, err := armstorage.NewFileSharesClient(subscriptionID, cred, opts)
 	if err != nil {
@@ -98,15 +132,27 @@ func newAzureClientsWithEndpoint(subscriptionID string, endpointURL string) (*Az
 	if err != nil {
 		return nil, err
 	}
+	permissions, err := armauthorization.NewPermissionsClient(subscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+	acrFactory, err := armcontainerregistry.NewClientFactory(subscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	return &AzureClients{
 		WebApps:           webAppsClient,
 		Logs:              logsClient,
+		Metrics:           metrics,
 		Cred:              cred,
 		FileShares:        fileShares,
 		StorageAccounts:   storageAccounts,
 		PrivateDNSZones:   privateZones,
 		PrivateDNSRecords: privateRecords,
+		Permissions:       permissions,
+		ACRCacheRules:     acrFactory.NewCacheRulesClient(),
+		ACRCredentialSets: acrFactory.NewCredentialSetsClient(),
 	}, nil
 }
 
@@ -115,30 +161,51 @@ func newAzureClientsDefault(subscriptionID string) (*AzureClients, error) {
 	if err != nil {
 		return nil, err
 	}
+	opts := &arm.ClientOptions{}
+	opts.PerCallPolicies = []policy.Policy{azurecommon.CloudAPIMetrics{}}
+	recorder, err := core.CassetteHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	if recorder != nil {
+		opts.Transport = recorder
+	}
 
-	webAppsClient, err := armappservice.NewWebAppsClient(subscriptionID, cred, nil)
+	webAppsClient, err := armappservice.NewWebAppsClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	logsClient, err := azquery.NewLogsClient(cred, nil)
+	logsClient, err := azquery.NewLogsClient(cred, &azquery.LogsClientOptions{ClientOptions: opts.ClientOptions})
+	if err != nil {
+		return nil, err
+	}
+	metricsClient, err := azquery.NewMetricsClient(cred, &azquery.MetricsClientOptions{ClientOptions: opts.ClientOptions})
 	if err != nil {
 		return nil, err
 	}
 
-	fileShares, err := armstorage.NewFileSharesClient(subscriptionID, cred, nil)
+	fileShares, err := armstorage.NewFileSharesClient(subscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+	storageAccounts, err := armstorage.NewAccountsClient(subscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+	privateZones, err := armprivatedns.NewPrivateZonesClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
-	storageAccounts, err := armstorage.NewAccountsClient(subscriptionID, cred, nil)
+	privateRecords, err := armprivatedns.NewRecordSetsClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
-	privateZones, err := armprivatedns.NewPrivateZonesClient(subscriptionID, cred, nil)
+	permissions, err := armauthorization.NewPermissionsClient(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
-	privateRecords, err := armprivatedns.NewRecordSetsClient(subscriptionID, cred, nil)
+	acrFactory, err := armcontainerregistry.NewClientFactory(subscriptionID, cred, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -146,10 +213,14 @@ func newAzureClientsDefault(subscriptionID string) (*AzureClients, error) {
 	return &AzureClients{
 		WebApps:           webAppsClient,
 		Logs:              logsClient,
+		Metrics:           metricsClient,
 		Cred:              cred,
 		FileShares:        fileShares,
 		StorageAccounts:   storageAccounts,
 		PrivateDNSZones:   privateZones,
 		PrivateDNSRecords: privateRecords,
+		Permissions:       permissions,
+		ACRCacheRules:     acrFactory.NewCacheRulesClient(),
+		ACRCredentialSets: acrFactory.NewCredentialSetsClient(),
 	}, nil
 }
@@ -86,8 +86,14 @@ func (s *Server) ContainerCreate(req *api.ContainerCreateRequest) (*api.Containe
 		config.Labels = make(map[string]string)
 	}
 
-	// Resolve Docker Hub images to ACR or normalize for Azure Functions
-	config.Image = azurecommon.ResolveAzureImageURI(config.Image, s.config.Registry)
+	// Images from `docker load` / `docker import` run from the ACR copy
+	// they were pushed to; registry images from the ACR cache rule
+	// mirroring their registry.
+	image, err := s.registryImageURI(s.ctx(), config.Image)
+	if err != nil {
+		return nil, err
+	}
+	config.Image = image
 
 	originalImage := config.Image
 	if s.useAZFOverlayPath(originalImage) {
@@ -940,6 +946,18 @@ func (s *Server) ImageLoad(r io.Reader) (io.ReadCloser, error) {
 	return s.images.Load(r)
 }
 
+// ImageImport delegates to ImageManager, which pushes the imported
+// image into the cloud registry.
+func (s *Server) ImageImport(r io.Reader, opts core.ImageImportOptions) (io.ReadCloser, error) {
+	return s.images.Import(r, opts)
+}
+
+// ContainerCheckpoint delegates to ImageManager, which pushes the
+// committed image into the cloud registry for `sockerless migrate`.
+func (s *Server) ContainerCheckpoint(ref string) (*core.ContainerCheckpoint, error) {
+	return s.images.Checkpoint(ref)
+}
+
 // AuthLogin handles registry authentication.
 // For ACR registries (*.azurecr.io), logs a warning about using managed identity.
 // For all other registries, delegates to BaseServer directly.
@@ -27,6 +27,12 @@ type Config struct {
 	EndpointURL           string        // Custom endpoint URL
 	PollInterval          time.Duration // Cloud API poll interval (default 2s)
 
+	// ImageRepository is the registry prefix `docker load` and
+	// `docker import` push images under when their tag is not already an ACR
+	// reference. Defaults to the Registry ACR.
+	// Set via SOCKERLESS_AZF_IMAGE_REPOSITORY.
+	ImageRepository string
+
 	// CallbackURL is the reverse-agent WebSocket URL injected into the
 	// function app container env so a bootstrap inside can dial back
 	// to the backend's /v1/azf/reverse endpoint.
@@ -73,6 +79,38 @@ type Config struct {
 	// the actual signing identity comes from azidentity.
 	// Set via SOCKERLESS_AZF_ACCESS_PRINCIPAL.
 	AccessPrincipal string
+
+	// ImageScan selects the vulnerability scanner and the admission
+	// policy applied to create / pull. Set via SOCKERLESS_IMAGE_SCANNER,
+	// SOCKERLESS_VULN_FEED, SOCKERLESS_SCAN_BLOCK_SEVERITY and
+	// SOCKERLESS_SCAN_ENFORCE.
+	ImageScan core.ImageScanConfig
+
+	// ImageVerify is the image signature trust policy applied to
+	// create. Set via the SOCKERLESS_VERIFY_* variables.
+	ImageVerify core.ImageVerifyConfig
+
+	// RegistryMirror holds the upstream registry credentials stored in
+	// the cloud secret store when sockerless creates a pull-through
+	// cache. Set via SOCKERLESS_REGISTRY_MIRROR_AUTH.
+	RegistryMirror core.RegistryMirrorConfig
+
+	// Costs configures per-run cost accounting and budgets. Set via
+	// the SOCKERLESS_COST_* variables.
+	Costs core.CostConfig
+
+	// Audit configures the audit log of cloud mutations. Set via the
+	// SOCKERLESS_AUDIT_* variables; the sink is a blob container in the storage account.
+	Audit core.AuditConfig
+
+	// HA configures multi-instance operation. Set via the SOCKERLESS_HA_*
+	// variables; the lease store is a blob container in the storage account.
+	HA core.HAConfig
+
+	// MirrorKeyVaultURL is the Key Vault registry mirror upstream
+	// credentials are written to (https://<vault>.vault.azure.net).
+	// Set via SOCKERLESS_AZURE_MIRROR_KEYVAULT_URL.
+	MirrorKeyVaultURL string
 }
 
 // ConfigFromEnv loads configuration from environment variables.
@@ -90,6 +128,7 @@ func ConfigFromEnv() Config {
 		BuildContainer:        os.Getenv("SOCKERLESS_AZURE_BUILD_CONTAINER"),
 		BuildPlatform:         envOrDefault("SOCKERLESS_AZURE_BUILD_PLATFORM", "linux/amd64"),
 		EndpointURL:           os.Getenv("SOCKERLESS_ENDPOINT_URL"),
+		ImageRepository:       os.Getenv("SOCKERLESS_AZF_IMAGE_REPOSITORY"),
 		PollInterval:          parseDuration(os.Getenv("SOCKERLESS_POLL_INTERVAL"), 2*time.Second),
 		CallbackURL:           os.Getenv("SOCKERLESS_CALLBACK_URL"),
 		BootstrapBinaryPath:   os.Getenv("SOCKERLESS_AZF_BOOTSTRAP"),
@@ -97,6 +136,13 @@ func ConfigFromEnv() Config {
 		NetworkDiscovery:      networkDiscoveryFromEnv("SOCKERLESS_AZF_NETWORK_DISCOVERY", api.NetworkDiscoveryNATGatewayOnly),
 		Access:                accessFromEnv("SOCKERLESS_AZF_ACCESS", api.AccessMechanismNoneInternal),
 		AccessPrincipal:       os.Getenv("SOCKERLESS_AZF_ACCESS_PRINCIPAL"),
+		ImageScan:             core.ImageScanConfigFromEnv(),
+		ImageVerify:           core.ImageVerifyConfigFromEnv(),
+		RegistryMirror:        core.RegistryMirrorConfigFromEnv(),
+		Costs:                 core.CostConfigFromEnv(),
+		Audit:                 core.AuditConfigFromEnv(),
+		HA:                    core.HAConfigFromEnv(),
+		MirrorKeyVaultURL:     os.Getenv("SOCKERLESS_AZURE_MIRROR_KEYVAULT_URL"),
 	}
 }
 
@@ -162,6 +208,13 @@ func ConfigFromEnvironment(env *core.Environment, sim *core.SimulatorConfig) Con
 	c.Access = accessFromEnv("SOCKERLESS_AZF_ACCESS", api.AccessMechanismNoneInternal)
 	c.AccessPrincipal = os.Getenv("SOCKERLESS_AZF_ACCESS_PRINCIPAL")
 	c.BootstrapBinaryPath = os.Getenv("SOCKERLESS_AZF_BOOTSTRAP")
+	c.ImageScan = core.ImageScanConfigFromEnv()
+	c.ImageVerify = core.ImageVerifyConfigFromEnv()
+	c.RegistryMirror = core.RegistryMirrorConfigFromEnv()
+	c.Costs = core.CostConfigFromEnv()
+	c.Audit = core.AuditConfigFromEnv()
+	c.HA = core.HAConfigFromEnv()
+	c.MirrorKeyVaultURL = os.Getenv("SOCKERLESS_AZURE_MIRROR_KEYVAULT_URL")
 	return c
 }
 
@@ -188,7 +241,28 @@ func (c Config) Validate() error {
 	default:
 		return fmt.Errorf("SOCKERLESS_AZF_ACCESS=%q not supported by azf (one of none-internal, azure-ad required)", c.Access)
 	}
-	return nil
+	if err := c.ImageScan.Validate(); err != nil {
+		return err
+	}
+	if err := c.ImageVerify.Validate(); err != nil {
+		return err
+	}
+	if err := c.RegistryMirror.Validate(); err != nil {
+		return err
+	}
+	if err := c.Costs.Validate(); err != nil {
+		return err
+	}
+	if c.Audit.Sink != "" && c.StorageAccount == "" {
+		return fmt.Errorf("SOCKERLESS_AUDIT_SINK requires SOCKERLESS_AZF_STORAGE_ACCOUNT (the sink's storage account)")
+	}
+	if c.HA.Mode != "" && c.StorageAccount == "" {
+		return fmt.Errorf("SOCKERLESS_HA_MODE requires SOCKERLESS_AZF_STORAGE_ACCOUNT (the lease store's storage account)")
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+	return c.HA.Validate()
 }
 
 func parseDuration(s string, def time.Duration) time.Duration {
@@ -0,0 +1,20 @@
+package azf
+
+import (
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// azfInstanceMemoryGiB is the instance memory size function apps are
+// billed at (the Flex Consumption default).
+const azfInstanceMemoryGiB = 2
+
+// ContainerCostShape prices a container as one execution of its
+// function app. Named volumes are Azure Files shares.
+func (s *Server) ContainerCostShape(*api.Container) core.CostShape {
+	return core.CostShape{
+		Kind:         core.CostKindAzureFunctions,
+		MemoryGiB:    azfInstanceMemoryGiB,
+		StorageClass: core.CostStorageAzureFiles,
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	core "github.com/sockerless/backend-core"
 )
 
 var dockerClient *client.Client
@@ -71,11 +72,19 @@ func requireExe(name string) {
 //	loud on any missing. No pre-creates —
 //	operator owns those resources.
 //
+// SOCKERLESS_TEST_TARGET = replay → harness serves the cassette named by
+//
+//	SOCKERLESS_TEST_CASSETTE in place of a
+//	simulator or cloud, with the sim fixtures.
+//
+// SOCKERLESS_TEST_RECORD=<path> with the sim or cloud target records the
+// backend's endpoint traffic into a cassette for later replay.
+//
 // The Test* functions don't know which target they're running against.
 func TestMain(m *testing.M) {
 	target := requireEnv("SOCKERLESS_TEST_TARGET")
-	if target != "sim" && target != "cloud" {
-		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\" or \"cloud\").\n", target)
+	if target != "sim" && target != "cloud" && target != "replay" {
+		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\", \"cloud\" or \"replay\").\n", target)
 		os.Exit(1)
 	}
 	requireExe("docker")
@@ -198,6 +207,11 @@ ENTRYPOINT ["/opt/sockerless/sockerless-azf-bootstrap"]
 			`{"location":"eastus","sku":{"name":"Standard_LRS"},"kind":"StorageV2","properties":{}}`,
 		)
 
+	case "replay":
+		subscriptionID = "00000000-0000-0000-0000-000000000001"
+		resourceGroup = "sim-rg"
+		storageAccount = "simstorage"
+
 	case "cloud":
 		endpointURL = requireEnv("SOCKERLESS_ENDPOINT_URL")
 		subscriptionID = requireEnv("SOCKERLESS_AZF_SUBSCRIPTION_ID")
@@ -205,6 +219,17 @@ ENTRYPOINT ["/opt/sockerless/sockerless-azf-bootstrap"]
 		storageAccount = requireEnv("SOCKERLESS_AZF_STORAGE_ACCOUNT")
 	}
 
+	// SOCKERLESS_TEST_RECORD records the session into a cassette;
+	// the replay target serves one instead of a simulator or cloud.
+	cassette, err := core.CassetteHarnessFromEnv(target, endpointURL)
+	if err != nil {
+		failClean("ERROR: cassette harness: %v\n", err)
+	}
+	if cassette != nil {
+		fmt.Printf("[cassette] %s %s via %s\n", cassette.Mode, cassette.Path, cassette.URL)
+		endpointURL = cassette.URL
+	}
+
 	backendDir := repoRoot + "/backends/azure-functions"
 	backendBinary := backendDir + "/sockerless-backend-azf"
 	fmt.Println("[backend] Building sockerless-backend-azf...")
@@ -243,7 +268,6 @@ ENTRYPOINT ["/opt/sockerless/sockerless-azf-bootstrap"]
 	}
 	fmt.Printf("[backend] ready on %s\n", backendAddr)
 
-	var err error
 	dockerClient, err = client.NewClientWithOpts(
 		client.WithHost(fmt.Sprintf("tcp://localhost:%d", backendPort)),
 		client.WithAPIVersionNegotiation(),
@@ -254,6 +278,9 @@ ENTRYPOINT ["/opt/sockerless/sockerless-azf-bootstrap"]
 
 	code := m.Run()
 	cleanup()
+	if cassette != nil {
+		code = cassette.Finish(code)
+	}
 	os.Exit(code)
 }
 
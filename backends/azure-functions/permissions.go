@@ -0,0 +1,61 @@
+package azf
+
+import (
+	"github.com/sockerless/api"
+	azurecommon "github.com/sockerless/azure-common"
+	core "github.com/sockerless/backend-core"
+)
+
+// requiredPermissions declares every RBAC operation the Azure Functions
+// backend uses under config's driver selection. `sockerless check`
+// probes the set against the caller's permissions on the resource
+// group; keep it in step with the SDK calls in this package.
+func requiredPermissions(config Config) *core.PermissionSet {
+	var set core.PermissionSet
+
+	// Container lifecycle: one function app per container on the
+	// configured App Service plan.
+	set.Add("containers", []string{"create", "run"},
+		"Microsoft.Web/sites/write",
+		"Microsoft.Web/sites/read",
+		"Microsoft.Web/sites/delete",
+		"Microsoft.Web/serverfarms/read",
+	)
+	set.Add("containers", []string{"rm", "container prune"}, "Microsoft.Web/sites/delete")
+	set.Add("containers", []string{"ps", "inspect"}, "Microsoft.Web/sites/read")
+
+	set.Add("logs log-analytics", []string{"logs", "attach", "run"},
+		"Microsoft.OperationalInsights/workspaces/query/read",
+	)
+	azurecommon.AddAzureMonitorStatsPermissions(&set)
+	if config.ImageScan.Scanner == "cloud" {
+		azurecommon.AddDefenderScanPermissions(&set)
+	}
+
+	if config.NetworkDiscovery == api.NetworkDiscoveryCloudDNS {
+		azurecommon.AddPrivateDNSPermissions(&set)
+	}
+
+	// Named volumes are Azure Files shares mounted into the function
+	// app with the storage account key, fetched at attach time.
+	azurecommon.AddAzureFilesPermissions(&set)
+	set.Add("volumes azure-files", []string{"start", "run -v"},
+		"Microsoft.Storage/storageAccounts/listKeys/action",
+		"Microsoft.Web/sites/config/write",
+	)
+
+	azurecommon.AddACRPermissions(&set)
+	if azfACRName(config.Registry) != "" {
+		azurecommon.AddACRMirrorPermissions(&set)
+	}
+	if azfACRName(config.Registry) != "" && config.BuildStorageAccount != "" {
+		azurecommon.AddACRBuildPermissions(&set)
+	}
+	if config.Audit.Sink != "" {
+		azurecommon.AddBlobAuditPermissions(&set)
+	}
+	if config.HA.Mode != "" {
+		azurecommon.AddBlobLeasePermissions(&set)
+	}
+	return &set
+}
@@ -0,0 +1,69 @@
+package azf
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+func permissionIndex(set *core.PermissionSet) map[string]core.PermissionRequirement {
+	out := map[string]core.PermissionRequirement{}
+	for _, r := range set.Requirements() {
+		out[r.Permission] = r
+	}
+	return out
+}
+
+// TestRequiredPermissions_CoversSDKCalls scans this package for Azure
+// client calls and fails when one has no declared RBAC operation, so a
+// new call can't ship without its preflight entry.
+func TestRequiredPermissions_CoversSDKCalls(t *testing.T) {
+	operations := map[string]string{
+		"WebApps.BeginCreateOrUpdate":         "Microsoft.Web/sites/write",
+		"WebApps.Delete":                      "Microsoft.Web/sites/delete",
+		"WebApps.NewListByResourceGroupPager": "Microsoft.Web/sites/read",
+		"WebApps.UpdateAzureStorageAccounts":  "Microsoft.Web/sites/config/write",
+		"StorageAccounts.ListKeys":            "Microsoft.Storage/storageAccounts/listKeys/action",
+		"Logs.QueryWorkspace":                 "Microsoft.OperationalInsights/workspaces/query/read",
+		"PrivateDNSZones.BeginCreateOrUpdate": "Microsoft.Network/privateDnsZones/write",
+		"PrivateDNSZones.BeginDelete":         "Microsoft.Network/privateDnsZones/delete",
+	}
+	declared := permissionIndex(requiredPermissions(Config{NetworkDiscovery: api.NetworkDiscoveryCloudDNS}))
+
+	call := regexp.MustCompile(`\.azure\.(\w+)\.([A-Z]\w+)\(`)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range call.FindAllStringSubmatch(string(src), -1) {
+			op, ok := operations[m[1]+"."+m[2]]
+			if !ok {
+				t.Errorf("%s: unmapped call %s.%s", f, m[1], m[2])
+				continue
+			}
+			if _, ok := declared[op]; !ok {
+				t.Errorf("%s: %s.%s needs %s, which requiredPermissions does not declare", f, m[1], m[2], op)
+			}
+		}
+	}
+}
+
+func TestRequiredPermissions_PrivateDNSOnlyWithCloudDNS(t *testing.T) {
+	base := permissionIndex(requiredPermissions(Config{NetworkDiscovery: api.NetworkDiscoveryNATGatewayOnly}))
+	if _, ok := base["Microsoft.Network/privateDnsZones/write"]; ok {
+		t.Error("nat-gateway-only discovery must not require Private DNS")
+	}
+}
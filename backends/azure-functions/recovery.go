@@ -38,6 +38,7 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 					resourceID = *site.ID
 				}
 				orphans = append(orphans, core.ResourceEntry{
+					ContainerID:  derefTag(site.Tags["sockerless-container-id"]),
 					Backend:      "azf",
 					ResourceType: "site",
 					ResourceID:   resourceID,
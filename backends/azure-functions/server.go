@@ -2,6 +2,7 @@ package azf
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -64,10 +65,27 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 		NCPU:            2,
 		MemTotal:        4294967296,
 	}, logger)
+	mirrorAuth, err := config.RegistryMirror.Credentials()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid registry mirror credentials")
+	}
+	if name := azfACRName(config.Registry); name != "" {
+		s.Mirrors = &azurecommon.ACRMirrors{
+			CacheRules:     azureClients.ACRCacheRules,
+			CredentialSets: azureClients.ACRCredentialSets,
+			ResourceGroup:  config.ResourceGroup,
+			Registry:       name,
+			KeyVaultURL:    config.MirrorKeyVaultURL,
+			Credential:     azureClients.Cred,
+			Credentials:    mirrorAuth,
+			Logger:         logger,
+		}
+	}
 	s.images = &core.ImageManager{
-		Base:   s.BaseServer,
-		Auth:   azurecommon.NewACRAuthProvider(logger),
-		Logger: logger,
+		Base:           s.BaseServer,
+		Auth:           azurecommon.NewACRAuthProvider(logger),
+		LoadRepository: azfImageRepository(config),
+		Logger:         logger,
 	}
 	s.storageBackings = core.NewStorageBackingRegistry()
 	s.storageBackings.Register(azurecommon.NewAzureFilesEphemeralDriver(config.StorageAccount))
@@ -92,6 +110,10 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 	case api.AccessMechanismAzureAD:
 		s.Access = azurecommon.NewAzureADAccess(azureClients.Cred, config.AccessPrincipal)
 	}
+	s.HealthChecker = core.NewPermissionPreflight(
+		azurecommon.NewRBACPermissionProber(azureClients.Permissions, config.ResourceGroup),
+		requiredPermissions(config),
+	)
 
 	// Network-discovery driver. Selected via Config.NetworkDiscovery
 	// (env: SOCKERLESS_AZF_NETWORK_DISCOVERY). Validated to one of
@@ -153,6 +175,7 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 
 	// Reverse-agent registry + WS endpoint.
 	s.reverseAgents = core.NewReverseAgentRegistry()
+	s.InstrumentReverseAgents(s.reverseAgents)
 	s.Mux.HandleFunc("/v1/azf/reverse", core.HandleReverseAgentWS(s.reverseAgents, logger))
 	s.Drivers.Exec = &core.ReverseAgentExecDriver{Registry: s.reverseAgents, Logger: logger}
 	s.Drivers.Stream = &core.ReverseAgentStreamDriver{Registry: s.reverseAgents, Logger: logger}
@@ -163,6 +186,40 @@ func NewServer(config Config, azureClients *AzureClients, logger zerolog.Logger)
 	s.Typed.FSWrite = core.NewReverseAgentFSWriteDriver(s.reverseAgents, "azf")
 	s.Typed.FSExport = core.NewReverseAgentFSExportDriver(s.reverseAgents, "azf")
 	s.Typed.Commit = core.NewReverseAgentCommitDriver(s.BaseServer, s.reverseAgents, "azf")
+	s.StatsProvider = s.newStatsProvider()
+	s.ConfigureCosts(config.Costs, s)
+	var auditSink core.AuditSink
+	if config.Audit.Sink != "" {
+		sink, err := azurecommon.NewBlobAuditSink(azureClients.Cred,
+			fmt.Sprintf("https://%s.blob.core.windows.net", config.StorageAccount), config.Audit.Sink, s.Desc.InstanceID)
+		if err != nil {
+			logger.Warn().Err(err).Msg("audit sink unavailable, audit records kept locally")
+		} else {
+			auditSink = sink
+		}
+	}
+	s.ConfigureAudit(config.Audit, auditSink)
+	if config.HA.Mode != "" {
+		leases, err := azurecommon.NewBlobLeaseStore(azureClients.Cred,
+			fmt.Sprintf("https://%s.blob.core.windows.net", config.StorageAccount), config.HA.Store)
+		if err != nil {
+			logger.Warn().Err(err).Msg("HA lease store unavailable, running as a single instance")
+		} else {
+			s.ConfigureHA(config.HA, leases)
+		}
+	}
+	s.ConfigureImageScanning(config.ImageScan, &azurecommon.DefenderScanner{
+		Endpoint:       config.EndpointURL,
+		Credential:     azureClients.Cred,
+		SubscriptionID: config.SubscriptionID,
+		Resolve:        s.registryImageURI,
+		Digest:         s.images.ImageDigest,
+		PollInterval:   config.PollInterval,
+		Timeout:        core.ImageScanTimeout,
+	}, s.images.WalkImageLayers)
+	if err := s.ConfigureImageVerification(config.ImageVerify, s.images, s.registryImageURI); err != nil {
+		logger.Fatal().Err(err).Msg("invalid image signature policy")
+	}
 
 	// Cloud-native typed drivers for Logs + Attach. Both go through
 	// Azure Monitor / Log Analytics via a per-container fetcher factory.
@@ -188,3 +245,32 @@ func azfACRName(registry string) string {
 	registry = strings.TrimPrefix(registry, "http://")
 	return strings.TrimSuffix(registry, ".azurecr.io")
 }
+
+// azfImageRepository returns the registry prefix `docker load` /
+// `docker import` push images under: SOCKERLESS_AZF_IMAGE_REPOSITORY,
+// else the Registry ACR.
+func azfImageRepository(config Config) string {
+	if config.ImageRepository != "" {
+		return config.ImageRepository
+	}
+	if name := azfACRName(config.Registry); name != "" {
+		return name + ".azurecr.io"
+	}
+	return ""
+}
+
+// registryImageURI maps an image reference to the ACR URI function
+// apps run it from: the image Defender for Containers assesses and
+// whose signatures admission verifies. With a registry configured the
+// image goes through the cache rule of its upstream, created first
+// when missing.
+func (s *Server) registryImageURI(ctx context.Context, ref string) (string, error) {
+	ref = s.images.RegistryRef(ref)
+	if s.Mirrors == nil {
+		return azurecommon.ResolveAzureImageURI(ref, s.config.Registry), nil
+	}
+	if _, err := s.EnsureRegistryMirror(ctx, ref); err != nil {
+		return "", err
+	}
+	return azurecommon.ResolveAzureImageURIWithCache(ctx, s.azure.ACRCacheRules, s.config.ResourceGroup, azfACRName(s.config.Registry), ref)
+}
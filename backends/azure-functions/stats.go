@@ -0,0 +1,32 @@
+package azf
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/monitor/azquery"
+	azurecommon "github.com/sockerless/azure-common"
+	core "github.com/sockerless/backend-core"
+)
+
+// newStatsProvider builds the `docker stats` source: cgroup counters
+// over the reverse agent when the container's bootstrap is connected,
+// otherwise Azure Monitor platform metrics for its function app.
+// CpuTime is CPU-seconds per one-minute grain, so 1/60 converts the
+// total to cores.
+func (s *Server) newStatsProvider() core.StatsProvider {
+	return &core.StatsSources{
+		Agents: s.reverseAgents,
+		Cloud: &azurecommon.AzureMonitorStats{
+			Client: s.azure.Metrics,
+			Resource: func(containerID string) (azurecommon.AzureMonitorResource, bool) {
+				state, ok := s.AZF.Get(containerID)
+				if !ok || state.ResourceID == "" {
+					return azurecommon.AzureMonitorResource{}, false
+				}
+				return azurecommon.AzureMonitorResource{
+					ID:     state.ResourceID,
+					CPU:    azurecommon.AzureMonitorMetric{Name: "CpuTime", Aggregation: azquery.AggregationTypeTotal, Scale: 1.0 / 60},
+					Memory: azurecommon.AzureMonitorMetric{Name: "MemoryWorkingSet", Aggregation: azquery.AggregationTypeAverage, Scale: 1},
+				}, true
+			},
+		},
+	}
+}
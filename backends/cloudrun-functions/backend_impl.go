@@ -75,8 +75,14 @@ func (s *Server) ContainerCreate(req *api.ContainerCreateRequest) (*api.Containe
 		config.Labels = make(map[string]string)
 	}
 
-	// Resolve Docker Hub images to Artifact Registry remote repository URIs
-	config.Image = gcpcommon.ResolveGCPImageURI(config.Image, s.config.Project, s.config.Region, s.config.EndpointURL)
+	// Images from `docker load` / `docker import` run from the Artifact
+	// Registry copy they were pushed to; registry images from the remote
+	// repository mirroring their registry.
+	image, err := s.registryImageURI(s.ctx(), config.Image)
+	if err != nil {
+		return nil, err
+	}
+	config.Image = image
 
 	hostConfig := api.HostConfig{NetworkMode: "default"}
 	if req.HostConfig != nil {
@@ -1028,6 +1034,10 @@ func (s *Server) ImagePull(ref string, auth string) (io.ReadCloser, error) {
 	if resolved == ref {
 		return s.images.Pull(resolved, auth)
 	}
+	mirrorStatus, err := s.EnsureRegistryMirror(s.ctx(), ref)
+	if err != nil {
+		return nil, err
+	}
 	rc, err := s.images.Pull(resolved, "")
 	if err != nil {
 		return nil, err
@@ -1035,7 +1045,7 @@ func (s *Server) ImagePull(ref string, auth string) (io.ReadCloser, error) {
 	if img, ok := s.Store.ResolveImage(resolved); ok {
 		core.StoreImageWithAliases(s.Store, ref, img)
 	}
-	return rc, nil
+	return core.WithPullStatus(mirrorStatus, rc), nil
 }
 
 // ImageLoad delegates to ImageManager.
@@ -1043,6 +1053,18 @@ func (s *Server) ImageLoad(r io.Reader) (io.ReadCloser, error) {
 	return s.images.Load(r)
 }
 
+// ImageImport delegates to ImageManager, which pushes the imported
+// image into the cloud registry.
+func (s *Server) ImageImport(r io.Reader, opts core.ImageImportOptions) (io.ReadCloser, error) {
+	return s.images.Import(r, opts)
+}
+
+// ContainerCheckpoint delegates to ImageManager, which pushes the
+// committed image into the cloud registry for `sockerless migrate`.
+func (s *Server) ContainerCheckpoint(ref string) (*core.ContainerCheckpoint, error) {
+	return s.images.Checkpoint(ref)
+}
+
 // PodStart starts all containers in a pod by calling ContainerStart for each,
 // which triggers the GCF HTTP invocation. The BaseServer implementation only
 // sets container state to "running" without invoking the function.
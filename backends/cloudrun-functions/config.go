@@ -25,6 +25,14 @@ type Config struct {
 	PollInterval   time.Duration // Cloud API poll interval (default 2s)
 	LogTimeout     time.Duration // Cloud Logging query timeout (default 30s)
 
+	// ImageRepository is the registry prefix `docker load` and
+	// `docker import` push images under when their tag is not already an
+	// Artifact Registry reference. No default: set it to a Docker-format
+	// repository, e.g. terraform's `artifact_registry_repository_url`
+	// output.
+	// Set via SOCKERLESS_GCF_IMAGE_REPOSITORY.
+	ImageRepository string
+
 	// CallbackURL is the reverse-agent WebSocket URL injected into
 	// the function container env so a bootstrap inside can dial back
 	// to the backend's /v1/gcf/reverse endpoint. Empty ⇒ exec/top
@@ -102,6 +110,33 @@ type Config struct {
 	// 121b-finish-C/J).
 	// Set via SOCKERLESS_GCF_NETWORK_DISCOVERY.
 	NetworkDiscovery api.NetworkDiscoveryKind
+
+	// ImageScan selects the vulnerability scanner and the admission
+	// policy applied to create / pull. Set via SOCKERLESS_IMAGE_SCANNER,
+	// SOCKERLESS_VULN_FEED, SOCKERLESS_SCAN_BLOCK_SEVERITY and
+	// SOCKERLESS_SCAN_ENFORCE.
+	ImageScan core.ImageScanConfig
+
+	// ImageVerify is the image signature trust policy applied to
+	// create. Set via the SOCKERLESS_VERIFY_* variables.
+	ImageVerify core.ImageVerifyConfig
+
+	// RegistryMirror holds the upstream registry credentials stored in
+	// the cloud secret store when sockerless creates a pull-through
+	// cache. Set via SOCKERLESS_REGISTRY_MIRROR_AUTH.
+	RegistryMirror core.RegistryMirrorConfig
+
+	// Costs configures per-run cost accounting and budgets. Set via
+	// the SOCKERLESS_COST_* variables.
+	Costs core.CostConfig
+
+	// Audit configures the audit log of cloud mutations. Set via the
+	// SOCKERLESS_AUDIT_* variables; the sink is a Cloud Logging log name.
+	Audit core.AuditConfig
+
+	// HA configures multi-instance operation. Set via the SOCKERLESS_HA_*
+	// variables; the lease store is a GCS bucket[/prefix].
+	HA core.HAConfig
 }
 
 // SharedVolume mirrors `cloudrun.SharedVolume`. GCS bucket backs the
@@ -146,19 +181,20 @@ type PrewarmOverlay struct {
 // ConfigFromEnv loads configuration from environment variables.
 func ConfigFromEnv() Config {
 	return Config{
-		Project:        os.Getenv("SOCKERLESS_GCF_PROJECT"),
-		Region:         envOrDefault("SOCKERLESS_GCF_REGION", "us-central1"),
-		ServiceAccount: os.Getenv("SOCKERLESS_GCF_SERVICE_ACCOUNT"),
-		Timeout:        envOrDefaultInt("SOCKERLESS_GCF_TIMEOUT", 3600),
-		Memory:         envOrDefault("SOCKERLESS_GCF_MEMORY", "4Gi"),
-		CPU:            envOrDefault("SOCKERLESS_GCF_CPU", "1"),
-		BuildBucket:    os.Getenv("SOCKERLESS_GCP_BUILD_BUCKET"),
-		BuildPlatform:  envOrDefault("SOCKERLESS_GCP_BUILD_PLATFORM", "linux/amd64"),
-		EndpointURL:    os.Getenv("SOCKERLESS_ENDPOINT_URL"),
-		PollInterval:   parseDuration(os.Getenv("SOCKERLESS_POLL_INTERVAL"), 2*time.Second),
-		LogTimeout:     parseDuration(os.Getenv("SOCKERLESS_LOG_TIMEOUT"), 30*time.Second),
-		CallbackURL:    os.Getenv("SOCKERLESS_CALLBACK_URL"),
-		EnableCommit:   os.Getenv("SOCKERLESS_ENABLE_COMMIT") == "1",
+		Project:         os.Getenv("SOCKERLESS_GCF_PROJECT"),
+		Region:          envOrDefault("SOCKERLESS_GCF_REGION", "us-central1"),
+		ServiceAccount:  os.Getenv("SOCKERLESS_GCF_SERVICE_ACCOUNT"),
+		Timeout:         envOrDefaultInt("SOCKERLESS_GCF_TIMEOUT", 3600),
+		Memory:          envOrDefault("SOCKERLESS_GCF_MEMORY", "4Gi"),
+		CPU:             envOrDefault("SOCKERLESS_GCF_CPU", "1"),
+		BuildBucket:     os.Getenv("SOCKERLESS_GCP_BUILD_BUCKET"),
+		BuildPlatform:   envOrDefault("SOCKERLESS_GCP_BUILD_PLATFORM", "linux/amd64"),
+		EndpointURL:     os.Getenv("SOCKERLESS_ENDPOINT_URL"),
+		ImageRepository: os.Getenv("SOCKERLESS_GCF_IMAGE_REPOSITORY"),
+		PollInterval:    parseDuration(os.Getenv("SOCKERLESS_POLL_INTERVAL"), 2*time.Second),
+		LogTimeout:      parseDuration(os.Getenv("SOCKERLESS_LOG_TIMEOUT"), 30*time.Second),
+		CallbackURL:     os.Getenv("SOCKERLESS_CALLBACK_URL"),
+		EnableCommit:    os.Getenv("SOCKERLESS_ENABLE_COMMIT") == "1",
 		BootstrapBinaryPath: envOrDefault(
 			"SOCKERLESS_GCF_BOOTSTRAP",
 			"/opt/sockerless/sockerless-gcf-bootstrap",
@@ -168,6 +204,12 @@ func ConfigFromEnv() Config {
 		SharedVolumes:    parseSharedVolumes(os.Getenv("SOCKERLESS_GCP_SHARED_VOLUMES")),
 		VPCConnector:     os.Getenv("SOCKERLESS_GCF_VPC_CONNECTOR"),
 		NetworkDiscovery: networkDiscoveryFromEnv("SOCKERLESS_GCF_NETWORK_DISCOVERY", api.NetworkDiscoveryHostAliases),
+		ImageScan:        core.ImageScanConfigFromEnv(),
+		ImageVerify:      core.ImageVerifyConfigFromEnv(),
+		RegistryMirror:   core.RegistryMirrorConfigFromEnv(),
+		Costs:            core.CostConfigFromEnv(),
+		Audit:            core.AuditConfigFromEnv(),
+		HA:               core.HAConfigFromEnv(),
 	}
 }
 
@@ -344,6 +386,12 @@ func ConfigFromEnvironment(env *core.Environment, sim *core.SimulatorConfig) Con
 		c.EndpointURL = fmt.Sprintf("http://localhost:%d", sim.Port)
 	}
 	c.NetworkDiscovery = networkDiscoveryFromEnv("SOCKERLESS_GCF_NETWORK_DISCOVERY", api.NetworkDiscoveryHostAliases)
+	c.ImageScan = core.ImageScanConfigFromEnv()
+	c.ImageVerify = core.ImageVerifyConfigFromEnv()
+	c.RegistryMirror = core.RegistryMirrorConfigFromEnv()
+	c.Costs = core.CostConfigFromEnv()
+	c.Audit = core.AuditConfigFromEnv()
+	c.HA = core.HAConfigFromEnv()
 	return c
 }
 
@@ -361,7 +409,22 @@ func (c Config) Validate() error {
 	default:
 		return fmt.Errorf("SOCKERLESS_GCF_NETWORK_DISCOVERY=%q not supported by gcf (one of host-aliases, nat-gateway-only required; cloud-dns wiring lives in 121b-finish-J)", c.NetworkDiscovery)
 	}
-	return nil
+	if err := c.ImageScan.Validate(); err != nil {
+		return err
+	}
+	if err := c.ImageVerify.Validate(); err != nil {
+		return err
+	}
+	if err := c.RegistryMirror.Validate(); err != nil {
+		return err
+	}
+	if err := c.Costs.Validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+	return c.HA.Validate()
 }
 
 func parseDuration(s string, def time.Duration) time.Duration {
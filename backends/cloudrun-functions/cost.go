@@ -0,0 +1,22 @@
+package gcf
+
+import (
+	"strconv"
+
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// ContainerCostShape prices a container as one invocation of its
+// function at SOCKERLESS_GCF_CPU / SOCKERLESS_GCF_MEMORY. Named volumes
+// are GCS buckets.
+func (s *Server) ContainerCostShape(*api.Container) core.CostShape {
+	vcpu, _ := strconv.ParseFloat(s.config.CPU, 64)
+	mib, _ := core.ParseMemoryMiB(s.config.Memory)
+	return core.CostShape{
+		Kind:         core.CostKindCloudRunFunctions,
+		VCPU:         vcpu,
+		MemoryGiB:    float64(mib) / 1024,
+		StorageClass: core.CostStorageGCS,
+	}
+}
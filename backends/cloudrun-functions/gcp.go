@@ -8,10 +8,16 @@ import (
 	"strconv"
 
 	functions "cloud.google.com/go/functions/apiv2"
+	"cloud.google.com/go/logging"
 	"cloud.google.com/go/logging/logadmin"
 	run "cloud.google.com/go/run/apiv2"
 	"cloud.google.com/go/storage"
+	gcpcommon "github.com/sockerless/gcp-common"
+	artifactregistry "google.golang.org/api/artifactregistry/v1"
+	containeranalysis "google.golang.org/api/containeranalysis/v1"
+	monitoring "google.golang.org/api/monitoring/v3"
 	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -29,6 +35,16 @@ type GCPClients struct {
 	// backing named volumes (reused across GCP backends via
 	// gcpcommon.BucketManager).
 	Storage *storage.Client
+	// Monitoring reads the underlying Cloud Run service's utilization
+	// for `docker stats` when no reverse agent is connected.
+	Monitoring *monitoring.Service
+	// ContainerAnalysis reads Artifact Analysis vulnerability
+	// occurrences for the cloud image scanner.
+	ContainerAnalysis *containeranalysis.Service
+	// ArtifactRegistry and SecretManager manage the registry mirrors
+	// (remote repositories) and their upstream credentials.
+	ArtifactRegistry *artifactregistry.Service
+	SecretManager    *secretmanager.Service
 }
 
 // NewGCPClients initializes GCP SDK clients.
@@ -49,9 +65,14 @@ func urlHost(rawURL string) (string, error) {
 }
 
 func newGCPClientsWithEndpoint(ctx context.Context, project string, endpointURL string) (*GCPClients, error) {
+	hc, err := gcpcommon.CloudAPIHTTPClient(ctx, false)
+	if err != nil {
+		return nil, err
+	}
 	opts := []option.ClientOption{
 		option.WithEndpoint(endpointURL),
 		option.WithoutAuthentication(),
+		option.WithHTTPClient(hc),
 	}
 
 	functionsClient, err := functions.NewFunctionRESTClient(ctx, opts...)
@@ -76,6 +97,7 @@ func newGCPClientsWithEndpoint(ctx context.Context, project string, endpointURL
 		option.WithEndpoint(grpcAddr),
 		option.WithoutAuthentication(),
 		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		gcpcommon.CloudAPIGRPCOption(),
 	}
 	logAdminClient, err := logadmin.NewClient(ctx, project, logAdminOpts...)
 	if err != nil {
@@ -86,7 +108,7 @@ func newGCPClientsWithEndpoint(ctx context.Context, project string, endpointURL
 
 	// Storage client honours STORAGE_EMULATOR_HOST, not option.WithEndpoint —
 	// same fix as Cloud Run's gcp.go so the JSON-API path is used.
-	storageOpts := []option.ClientOption{option.WithoutAuthentication()}
+	storageOpts := []option.ClientOption{option.WithoutAuthentication(), option.WithHTTPClient(hc)}
 	if host, err := urlHost(endpointURL); err == nil {
 		_ = os.Setenv("STORAGE_EMULATOR_HOST", host)
 	}
@@ -98,11 +120,51 @@ func newGCPClientsWithEndpoint(ctx context.Context, project string, endpointURL
 		return nil, err
 	}
 
+	monitoringService, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		_ = functionsClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
+		return nil, err
+	}
+
+	containerAnalysisService, err := containeranalysis.NewService(ctx, opts...)
+	if err != nil {
+		_ = functionsClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
+		return nil, err
+	}
+
+	artifactRegistryService, err := artifactregistry.NewService(ctx, opts...)
+	if err != nil {
+		_ = functionsClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
+		return nil, err
+	}
+
+	secretManagerService, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		_ = functionsClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
+		return nil, err
+	}
+
 	return &GCPClients{
-		Functions: functionsClient,
-		LogAdmin:  logAdminClient,
-		Services:  servicesClient,
-		Storage:   storageClient,
+		Functions:         functionsClient,
+		LogAdmin:          logAdminClient,
+		Services:          servicesClient,
+		Storage:           storageClient,
+		Monitoring:        monitoringService,
+		ContainerAnalysis: containerAnalysisService,
+		ArtifactRegistry:  artifactRegistryService,
+		SecretManager:     secretManagerService,
 	}, nil
 }
 
@@ -122,36 +184,103 @@ func grpcAddrFromEndpoint(endpointURL string) (string, error) {
 }
 
 func newGCPClientsDefault(ctx context.Context, project string) (*GCPClients, error) {
-	functionsClient, err := functions.NewFunctionClient(ctx)
+	// gRPC clients count calls with an interceptor; the REST clients
+	// share an authenticated, counting HTTP client.
+	hc, err := gcpcommon.CloudAPIHTTPClient(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	grpcOpt := gcpcommon.CloudAPIGRPCOption()
+	restOpt := option.WithHTTPClient(hc)
+
+	functionsClient, err := functions.NewFunctionClient(ctx, grpcOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	servicesClient, err := run.NewServicesClient(ctx, grpcOpt)
+	if err != nil {
+		_ = functionsClient.Close()
+		return nil, err
+	}
+
+	logAdminClient, err := logadmin.NewClient(ctx, project, grpcOpt)
+	if err != nil {
+		_ = functionsClient.Close()
+		_ = servicesClient.Close()
+		return nil, err
+	}
+
+	storageClient, err := storage.NewClient(ctx, restOpt)
+	if err != nil {
+		_ = functionsClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		return nil, err
+	}
+
+	monitoringService, err := monitoring.NewService(ctx, restOpt)
 	if err != nil {
+		_ = functionsClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
 		return nil, err
 	}
 
-	servicesClient, err := run.NewServicesClient(ctx)
+	containerAnalysisService, err := containeranalysis.NewService(ctx, restOpt)
 	if err != nil {
 		_ = functionsClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
 		return nil, err
 	}
 
-	logAdminClient, err := logadmin.NewClient(ctx, project)
+	artifactRegistryService, err := artifactregistry.NewService(ctx, restOpt)
 	if err != nil {
 		_ = functionsClient.Close()
 		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
 		return nil, err
 	}
 
-	storageClient, err := storage.NewClient(ctx)
+	secretManagerService, err := secretmanager.NewService(ctx, restOpt)
 	if err != nil {
 		_ = functionsClient.Close()
 		_ = servicesClient.Close()
 		_ = logAdminClient.Close()
+		_ = storageClient.Close()
 		return nil, err
 	}
 
 	return &GCPClients{
-		Functions: functionsClient,
-		LogAdmin:  logAdminClient,
-		Services:  servicesClient,
-		Storage:   storageClient,
+		Functions:         functionsClient,
+		LogAdmin:          logAdminClient,
+		Services:          servicesClient,
+		Storage:           storageClient,
+		Monitoring:        monitoringService,
+		ContainerAnalysis: containerAnalysisService,
+		ArtifactRegistry:  artifactRegistryService,
+		SecretManager:     secretManagerService,
 	}, nil
 }
+
+// newAuditLoggingClient builds the Cloud Logging client the audit sink
+// writes with. It is separate from the read clients and carries no
+// CloudAPIGRPCOption, so the sink's writes are neither counted nor
+// audited.
+func newAuditLoggingClient(ctx context.Context, project, endpointURL string) (*logging.Client, error) {
+	if endpointURL == "" {
+		return logging.NewClient(ctx, project)
+	}
+	grpcAddr, err := grpcAddrFromEndpoint(endpointURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive gRPC address: %w", err)
+	}
+	return logging.NewClient(ctx, project,
+		option.WithEndpoint(grpcAddr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	core "github.com/sockerless/backend-core"
 )
 
 var dockerClient *client.Client
@@ -86,11 +87,21 @@ func requireExe(name string) {
 //	SOCKERLESS_GCF_BOOTSTRAP) and fails loud
 //	on any missing.
 //
+// SOCKERLESS_TEST_TARGET = replay → harness serves the cassette named by
+//
+//	SOCKERLESS_TEST_CASSETTE in place of a
+//	simulator or cloud, with the sim fixtures.
+//	SOCKERLESS_GCF_BOOTSTRAP names the bootstrap
+//	binary to tar into overlay builds.
+//
+// SOCKERLESS_TEST_RECORD=<path> with the sim or cloud target records the
+// backend's endpoint traffic into a cassette for later replay.
+//
 // The Test* functions don't know which target they're running against.
 func TestMain(m *testing.M) {
 	target := requireEnv("SOCKERLESS_TEST_TARGET")
-	if target != "sim" && target != "cloud" {
-		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\" or \"cloud\").\n", target)
+	if target != "sim" && target != "cloud" && target != "replay" {
+		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\", \"cloud\" or \"replay\").\n", target)
 		os.Exit(1)
 	}
 	requireExe("docker")
@@ -195,7 +206,7 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 	}
 
 	// Per-target endpoint + ARM identifiers + auth/bootstrap paths.
-	var endpointURL, project, buildBucket, saJSONPath, gcfBootstrapPath string
+	var endpointURL, project, buildBucket, saJSONPath, gcfBootstrapPath, imageRepository string
 	switch target {
 	case "sim":
 		// Build simulator
@@ -253,6 +264,10 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		endpointURL = simURL
 		project = "sockerless-test"
 		buildBucket = "sockerless-test-build"
+		// Loaded images are pushed under the docker-hub remote
+		// repository path, which the simulator maps back to the local
+		// daemon's images when it runs them.
+		imageRepository = "us-central1-docker.pkg.dev/" + project + "/docker-hub/library"
 
 		// Pre-create the GCS bucket the backend uses for Cloud Build
 		// context uploads. Real GCS doesn't auto-create buckets; the
@@ -299,14 +314,48 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		}
 		cleanups = append(cleanups, func() { _ = os.Remove(gcfBootstrapPath) })
 
+	case "replay":
+		project = "sockerless-test"
+		buildBucket = "sockerless-test-build"
+		imageRepository = "us-central1-docker.pkg.dev/" + project + "/docker-hub/library"
+		// Overlay image tags carry the bootstrap's hash, which replay
+		// normalises, but the backend still tars the binary.
+		gcfBootstrapPath = requireEnv("SOCKERLESS_GCF_BOOTSTRAP")
+
 	case "cloud":
 		endpointURL = requireEnv("SOCKERLESS_ENDPOINT_URL")
 		project = requireEnv("SOCKERLESS_GCF_PROJECT")
 		buildBucket = requireEnv("SOCKERLESS_GCP_BUILD_BUCKET")
+		imageRepository = requireEnv("SOCKERLESS_GCF_IMAGE_REPOSITORY")
 		saJSONPath = requireEnv("GOOGLE_APPLICATION_CREDENTIALS")
 		gcfBootstrapPath = requireEnv("SOCKERLESS_GCF_BOOTSTRAP")
 	}
 
+	// SOCKERLESS_TEST_RECORD records the session into a cassette;
+	// the replay target serves one instead of a simulator or cloud.
+	cassette, err := core.CassetteHarnessFromEnv(target, endpointURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: cassette harness: %v\n", err)
+		cleanup()
+		os.Exit(1)
+	}
+	if cassette != nil {
+		fmt.Printf("[cassette] %s %s via %s\n", cassette.Mode, cassette.Path, cassette.URL)
+		endpointURL = cassette.URL
+		if target != "cloud" {
+			// The fake SA's token exchange goes through the cassette too.
+			if saJSONPath != "" {
+				_ = os.Remove(saJSONPath)
+			}
+			if saJSONPath, err = writeFakeServiceAccountJSON(cassette.URL + "/token"); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to stage fake SA JSON: %v\n", err)
+				cleanup()
+				os.Exit(1)
+			}
+			cleanups = append(cleanups, func() { _ = os.Remove(saJSONPath) })
+		}
+	}
+
 	// Build backend
 	backendDir := repoRoot + "/backends/cloudrun-functions"
 	backendBinary := backendDir + "/sockerless-backend-gcf"
@@ -340,6 +389,7 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		"SOCKERLESS_LOG_TIMEOUT=2s",
 		"SOCKERLESS_GCF_PROJECT="+project,
 		"SOCKERLESS_GCP_BUILD_BUCKET="+buildBucket,
+		"SOCKERLESS_GCF_IMAGE_REPOSITORY="+imageRepository,
 		"SOCKERLESS_GCP_BUILD_PLATFORM="+overlayPlatform,
 		"GOOGLE_APPLICATION_CREDENTIALS="+saJSONPath,
 		"SOCKERLESS_GCF_BOOTSTRAP="+gcfBootstrapPath,
@@ -376,7 +426,6 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 	// The GCF backend serves the Docker API directly (no separate
 	// frontend binary — in-process wiring per post-P67 architecture).
 	// Point the docker SDK at the backend's TCP address.
-	var err error
 	dockerClient, err = client.NewClientWithOpts(
 		client.WithHost(fmt.Sprintf("tcp://localhost:%d", backendPort)),
 		client.WithAPIVersionNegotiation(),
@@ -434,6 +483,9 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 	step("entering m.Run() — TestMain setup complete")
 	code := m.Run()
 	cleanup()
+	if cassette != nil {
+		code = cassette.Finish(code)
+	}
 	os.Exit(code)
 }
 
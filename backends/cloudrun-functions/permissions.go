@@ -0,0 +1,81 @@
+package gcf
+
+import (
+	core "github.com/sockerless/backend-core"
+	gcpcommon "github.com/sockerless/gcp-common"
+)
+
+// requiredPermissions declares every IAM permission the Cloud Run
+// Functions backend uses under config's driver selection. `sockerless
+// check` probes the set with projects.testIamPermissions; keep it in
+// step with the SDK calls in this package.
+func requiredPermissions(config Config) *core.PermissionSet {
+	var set core.PermissionSet
+
+	// Container lifecycle: a pooled Gen2 function per overlay image,
+	// whose underlying Cloud Run service gets the container's image
+	// swapped in. The overlay is built with Cloud Build from a stub
+	// source staged in BuildBucket.
+	set.Add("containers", []string{"create", "run"},
+		"cloudfunctions.functions.create",
+		"cloudfunctions.functions.get",
+		"cloudfunctions.functions.list",
+		"cloudfunctions.functions.update",
+		"cloudfunctions.operations.get",
+		"run.services.get",
+		"run.services.update",
+		"run.operations.get",
+		"storage.objects.get",
+		"storage.objects.create",
+		"cloudbuild.builds.create",
+		"cloudbuild.builds.get",
+	)
+	set.Add("containers", []string{"start", "run", "exec"}, "run.routes.invoke")
+	set.Add("containers", []string{"rm", "container prune"},
+		"cloudfunctions.functions.delete",
+		"cloudfunctions.functions.get",
+		"cloudfunctions.functions.list",
+		"cloudfunctions.functions.update",
+		"cloudfunctions.operations.get",
+	)
+	set.Add("containers", []string{"ps", "inspect", "wait"},
+		"cloudfunctions.functions.list",
+		"cloudfunctions.functions.get",
+		"run.services.list",
+		"run.services.get",
+	)
+	if config.ServiceAccount != "" {
+		set.Add("containers", []string{"create", "run"}, "iam.serviceAccounts.actAs")
+	}
+	if config.VPCConnector != "" {
+		set.Add("containers", []string{"create", "run"}, "vpcaccess.connectors.use")
+	}
+
+	// Multi-container pods materialise as one Cloud Run service.
+	set.Add("pods", []string{"run --network", "pod create"},
+		"run.services.create",
+		"run.services.delete",
+		"run.operations.get",
+	)
+
+	set.Add("logs cloud-logging", []string{"logs", "attach", "run"}, "logging.logEntries.list")
+	gcpcommon.AddCloudMonitoringStatsPermissions(&set)
+
+	gcpcommon.AddBucketVolumePermissions(&set)
+	gcpcommon.AddGCSSyncPermissions(&set)
+	gcpcommon.AddArtifactRegistryPermissions(&set)
+	gcpcommon.AddArtifactRegistryMirrorPermissions(&set)
+	if config.ImageScan.Scanner == "cloud" {
+		gcpcommon.AddArtifactAnalysisScanPermissions(&set)
+	}
+	if config.BuildBucket != "" {
+		gcpcommon.AddCloudBuildPermissions(&set)
+	}
+	if config.Audit.Sink != "" {
+		gcpcommon.AddCloudLoggingAuditPermissions(&set)
+	}
+	if config.HA.Mode != "" {
+		gcpcommon.AddGCSLeasePermissions(&set)
+	}
+	return &set
+}
@@ -0,0 +1,80 @@
+package gcf
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	core "github.com/sockerless/backend-core"
+)
+
+func permissionIndex(set *core.PermissionSet) map[string]core.PermissionRequirement {
+	out := map[string]core.PermissionRequirement{}
+	for _, r := range set.Requirements() {
+		out[r.Permission] = r
+	}
+	return out
+}
+
+// TestRequiredPermissions_CoversSDKCalls scans this package for GCP
+// client calls and fails when one has no declared IAM permission, so a
+// new call can't ship without its preflight entry.
+func TestRequiredPermissions_CoversSDKCalls(t *testing.T) {
+	permissions := map[string]string{
+		"Functions.CreateFunction": "cloudfunctions.functions.create",
+		"Functions.GetFunction":    "cloudfunctions.functions.get",
+		"Functions.ListFunctions":  "cloudfunctions.functions.list",
+		"Functions.UpdateFunction": "cloudfunctions.functions.update",
+		"Functions.DeleteFunction": "cloudfunctions.functions.delete",
+		"Services.CreateService":   "run.services.create",
+		"Services.GetService":      "run.services.get",
+		"Services.ListServices":    "run.services.list",
+		"Services.UpdateService":   "run.services.update",
+		"Services.DeleteService":   "run.services.delete",
+		"LogAdmin.Entries":         "logging.logEntries.list",
+	}
+	declared := permissionIndex(requiredPermissions(Config{BuildBucket: "bucket"}))
+
+	call := regexp.MustCompile(`\.gcp\.(\w+)\.([A-Z]\w+)\(`)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range call.FindAllStringSubmatch(string(src), -1) {
+			perm, ok := permissions[m[1]+"."+m[2]]
+			if !ok {
+				t.Errorf("%s: unmapped call %s.%s", f, m[1], m[2])
+				continue
+			}
+			if _, ok := declared[perm]; !ok {
+				t.Errorf("%s: %s.%s needs %s, which requiredPermissions does not declare", f, m[1], m[2], perm)
+			}
+		}
+	}
+}
+
+func TestRequiredPermissions_ServiceAccountAndConnector(t *testing.T) {
+	base := permissionIndex(requiredPermissions(Config{}))
+	if _, ok := base["iam.serviceAccounts.actAs"]; ok {
+		t.Error("iam.serviceAccounts.actAs is only required when a service account is configured")
+	}
+	full := permissionIndex(requiredPermissions(Config{
+		ServiceAccount: "runner@p.iam.gserviceaccount.com",
+		VPCConnector:   "projects/p/locations/r/connectors/c",
+	}))
+	for _, perm := range []string{"iam.serviceAccounts.actAs", "vpcaccess.connectors.use"} {
+		if _, ok := full[perm]; !ok {
+			t.Errorf("%s missing with service account and connector configured", perm)
+		}
+	}
+}
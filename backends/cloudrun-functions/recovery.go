@@ -32,7 +32,14 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 		matchesInstance := fn.Labels["sockerless_instance"] == instanceID
 
 		if managed && matchesInstance {
+			// Pooled functions carry their container in
+			// sockerless_allocation; free ones carry none.
+			cid := fn.Labels["sockerless_allocation"]
+			if cid == "" {
+				cid = fn.Labels["sockerless_container_id"]
+			}
 			orphans = append(orphans, core.ResourceEntry{
+				ContainerID:  cid,
 				Backend:      "gcf",
 				ResourceType: "function",
 				ResourceID:   fn.Name,
@@ -114,10 +114,23 @@ func NewServer(config Config, gcpClients *GCPClients, logger zerolog.Logger) *Se
 		NCPU:            2,
 		MemTotal:        4294967296,
 	}, logger)
+	mirrorAuth, err := config.RegistryMirror.Credentials()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid registry mirror credentials")
+	}
+	s.Mirrors = &gcpcommon.ARMirrors{
+		Repositories: gcpClients.ArtifactRegistry,
+		Secrets:      gcpClients.SecretManager,
+		Project:      config.Project,
+		Region:       config.Region,
+		Credentials:  mirrorAuth,
+		Logger:       logger,
+	}
 	s.images = &core.ImageManager{
-		Base:   s.BaseServer,
-		Auth:   gcpcommon.NewARAuthProvider(s.ctx, logger, config.EndpointURL),
-		Logger: logger,
+		Base:           s.BaseServer,
+		Auth:           gcpcommon.NewARAuthProvider(s.ctx, logger, config.EndpointURL),
+		LoadRepository: config.ImageRepository,
+		Logger:         logger,
 	}
 	if svc, err := gcpcommon.NewGCPBuildService(context.Background(), config.Project, config.BuildBucket, "", config.EndpointURL, logger); err == nil && svc != nil {
 		s.images.BuildService = svc
@@ -182,6 +195,11 @@ func NewServer(config Config, gcpClients *GCPClients, logger zerolog.Logger) *Se
 		s.NetworkDiscovery = core.NoOpNetworkDiscovery{}
 	}
 	s.Access = gcpcommon.NewIDTokenAccess(config.ServiceAccount)
+	prober, err := gcpcommon.NewProjectPermissionProber(context.Background(), config.Project, config.EndpointURL)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create permission prober")
+	}
+	s.HealthChecker = core.NewPermissionPreflight(prober, requiredPermissions(config))
 
 	mode := "cloud"
 	if config.EndpointURL != "" {
@@ -202,9 +220,36 @@ func NewServer(config Config, gcpClients *GCPClients, logger zerolog.Logger) *Se
 
 	// Reverse-agent registry + WS endpoint.
 	s.reverseAgents = core.NewReverseAgentRegistry()
+	s.InstrumentReverseAgents(s.reverseAgents)
 	s.Mux.HandleFunc("/v1/gcf/reverse", core.HandleReverseAgentWS(s.reverseAgents, logger))
 	s.Drivers.Exec = &core.ReverseAgentExecDriver{Registry: s.reverseAgents, Logger: logger}
 	s.Drivers.Stream = &core.ReverseAgentStreamDriver{Registry: s.reverseAgents, Logger: logger}
+	s.StatsProvider = s.newStatsProvider()
+	s.ConfigureCosts(config.Costs, s)
+	var auditSink core.AuditSink
+	if config.Audit.Sink != "" {
+		client, err := newAuditLoggingClient(context.Background(), config.Project, config.EndpointURL)
+		if err != nil {
+			logger.Warn().Err(err).Msg("audit sink unavailable, audit records kept locally")
+		} else {
+			auditSink = gcpcommon.NewCloudLoggingAuditSink(client, config.Audit.Sink)
+		}
+	}
+	s.ConfigureAudit(config.Audit, auditSink)
+	if config.HA.Mode != "" {
+		s.ConfigureHA(config.HA, gcpcommon.NewGCSLeaseStore(gcpClients.Storage, config.HA.Store))
+	}
+	s.ConfigureImageScanning(config.ImageScan, &gcpcommon.ArtifactAnalysisScanner{
+		Service:      gcpClients.ContainerAnalysis,
+		Project:      config.Project,
+		Resolve:      s.registryImageURI,
+		Digest:       s.images.ImageDigest,
+		PollInterval: config.PollInterval,
+		Timeout:      core.ImageScanTimeout,
+	}, s.images.WalkImageLayers)
+	if err := s.ConfigureImageVerification(config.ImageVerify, s.images, s.registryImageURI); err != nil {
+		logger.Fatal().Err(err).Msg("invalid image signature policy")
+	}
 	s.Typed.Exec = core.WrapLegacyExec(s.Drivers.Exec, "gcf", "ReverseAgentExec")
 	s.Typed.ProcList = core.NewReverseAgentProcListDriver(s.reverseAgents, "gcf")
 	s.Typed.FSDiff = core.NewReverseAgentFSDiffDriver(s.reverseAgents, "gcf")
@@ -249,3 +294,15 @@ func NewServer(config Config, gcpClients *GCPClients, logger zerolog.Logger) *Se
 func (s *Server) ctx() context.Context {
 	return context.Background()
 }
+
+// registryImageURI maps an image reference to the Artifact Registry
+// URI containers run it from: the image Artifact Analysis scans and
+// whose signatures admission verifies. The remote repository of a
+// mirrored registry is created first when missing.
+func (s *Server) registryImageURI(ctx context.Context, ref string) (string, error) {
+	ref = s.images.RegistryRef(ref)
+	if _, err := s.EnsureRegistryMirror(ctx, ref); err != nil {
+		return "", err
+	}
+	return gcpcommon.ResolveGCPImageURI(ref, s.config.Project, s.config.Region, s.config.EndpointURL), nil
+}
@@ -0,0 +1,33 @@
+package gcf
+
+import (
+	core "github.com/sockerless/backend-core"
+	gcpcommon "github.com/sockerless/gcp-common"
+)
+
+// newStatsProvider builds the `docker stats` source: cgroup counters
+// over the reverse agent when the function's bootstrap is connected,
+// otherwise Cloud Monitoring utilization of the Cloud Run revision a
+// Gen2 function runs on, which publishes under the function's name.
+func (s *Server) newStatsProvider() core.StatsProvider {
+	return &core.StatsSources{
+		Agents: s.reverseAgents,
+		Cloud: &gcpcommon.CloudMonitoringStats{
+			Service: s.gcp.Monitoring,
+			Project: s.config.Project,
+			Resource: func(containerID string) (gcpcommon.CloudRunMonitoredResource, bool) {
+				state, ok := s.resolveGCFFromCloud(s.ctx(), containerID)
+				if !ok || state.FunctionName == "" {
+					return gcpcommon.CloudRunMonitoredResource{}, false
+				}
+				return gcpcommon.CloudRunMonitoredResource{
+					Type:        "cloud_run_revision",
+					NameLabel:   "service_name",
+					Name:        state.FunctionName,
+					CPULimit:    s.config.CPU,
+					MemoryLimit: s.config.Memory,
+				}, true
+			},
+		},
+	}
+}
@@ -81,11 +81,13 @@ func (s *Server) ContainerRename(id string, newName string) error {
 	return s.BaseServer.ContainerRename(id, newName)
 }
 
+// ContainerResize sizes the main process's PTY via the reverse-agent.
 func (s *Server) ContainerResize(id string, h int, w int) error {
-	if _, ok := s.ResolveContainerIDAuto(context.Background(), id); !ok {
-		return &api.NotFoundError{Resource: "container", ID: id}
+	cid, err := s.ResizeTargetContainer(id)
+	if err != nil {
+		return err
 	}
-	return s.BaseServer.ContainerResize(id, h, w)
+	return core.MapResizeErr(core.RunContainerResizeViaAgent(s.reverseAgents, cid, h, w))
 }
 
 func (s *Server) ContainerStats(id string, stream bool) (io.ReadCloser, error) {
@@ -113,8 +115,13 @@ func (s *Server) ExecInspect(id string) (*api.ExecInstance, error) {
 	return s.BaseServer.ExecInspect(id)
 }
 
+// ExecResize sizes the exec session's PTY via the reverse-agent.
 func (s *Server) ExecResize(id string, h int, w int) error {
-	return s.BaseServer.ExecResize(id, h, w)
+	exec, err := s.ResizeTargetExec(id)
+	if err != nil {
+		return err
+	}
+	return core.MapResizeErr(core.RunExecResizeViaAgent(s.reverseAgents, exec.ContainerID, id, h, w))
 }
 
 // Image methods (pass-through via ImageManager)
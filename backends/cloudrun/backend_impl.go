@@ -77,8 +77,14 @@ func (s *Server) ContainerCreate(req *api.ContainerCreateRequest) (*api.Containe
 		config.Labels = make(map[string]string)
 	}
 
-	// Resolve Docker Hub images to Artifact Registry remote repository URIs
-	config.Image = gcpcommon.ResolveGCPImageURI(config.Image, s.config.Project, s.config.Region, s.config.EndpointURL)
+	// Images from `docker load` / `docker import` run from the Artifact
+	// Registry copy they were pushed to; registry images from the remote
+	// repository mirroring their registry.
+	image, err := s.registryImageURI(s.ctx(), config.Image)
+	if err != nil {
+		return nil, err
+	}
+	config.Image = image
 
 	// When BootstrapBinaryPath is configured, COPY the
 	// sockerless-cloudrun-bootstrap into the user's image via Cloud
@@ -344,7 +350,13 @@ func (s *Server) ContainerStart(ref string) error {
 	// (specs/CLOUD_RESOURCE_MAPPING.md § "User-defined network ↔ multi-
 	// container Service revision"). Pure standard-Docker signal: network
 	// membership + Container.Config.OpenStdin. No runner-specific code.
-	netDefer, netMembers := s.shouldDeferOrMaterializeNetworkPod(c)
+	// Pod members (explicit pods, compose projects) are grouped by their
+	// pod instead.
+	var netDefer bool
+	var netMembers []api.Container
+	if _, inPod := s.Store.Pods.GetPodForContainer(id); !inPod {
+		netDefer, netMembers = s.shouldDeferOrMaterializeNetworkPod(c)
+	}
 	netID, _ := s.userDefinedNetworkID(c)
 	s.Logger.Info().
 		Str("container", id).
@@ -1127,6 +1139,10 @@ func (s *Server) ImagePull(ref string, auth string) (io.ReadCloser, error) {
 	// Hub, registry.gitlab.com, etc.) and is invalid for AR. Discard
 	// it so ImageManager.Pull's cloud-auth path mints an AR token
 	// via ARAuthProvider; otherwise AR returns 401.
+	mirrorStatus, err := s.EnsureRegistryMirror(s.ctx(), ref)
+	if err != nil {
+		return nil, err
+	}
 	rc, err := s.images.Pull(resolved, "")
 	if err != nil {
 		return nil, err
@@ -1137,7 +1153,7 @@ func (s *Server) ImagePull(ref string, auth string) (io.ReadCloser, error) {
 	if img, ok := s.Store.ResolveImage(resolved); ok {
 		core.StoreImageWithAliases(s.Store, ref, img)
 	}
-	return rc, nil
+	return core.WithPullStatus(mirrorStatus, rc), nil
 }
 
 // ImageLoad delegates to ImageManager.
@@ -1145,6 +1161,18 @@ func (s *Server) ImageLoad(r io.Reader) (io.ReadCloser, error) {
 	return s.images.Load(r)
 }
 
+// ImageImport delegates to ImageManager, which pushes the imported
+// image into the cloud registry.
+func (s *Server) ImageImport(r io.Reader, opts core.ImageImportOptions) (io.ReadCloser, error) {
+	return s.images.Import(r, opts)
+}
+
+// ContainerCheckpoint delegates to ImageManager, which pushes the
+// committed image into the cloud registry for `sockerless migrate`.
+func (s *Server) ContainerCheckpoint(ref string) (*core.ContainerCheckpoint, error) {
+	return s.images.Checkpoint(ref)
+}
+
 // VolumeRemove deletes the GCS bucket bound to a named volume. When
 // `force` is true, objects are deleted first (GCS refuses to delete
 // non-empty buckets). Cloud Run's Runtime IAM stays intact because
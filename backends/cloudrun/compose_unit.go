@@ -0,0 +1,65 @@
+package cloudrun
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// applyComposeDependencies wires a compose unit's depends_on into the
+// revision: each dependency becomes a container dependsOn, which makes
+// Cloud Run start the dependency first and wait for its startup probe.
+// Cloud Run probes cannot run the Docker health check command, so a
+// service_healthy dependency is probed on the TCP port it exposes with
+// the health check's timings.
+func applyComposeDependencies(specs []*runpb.Container, containers []containerInput) error {
+	unit := make([]api.Container, len(containers))
+	index := make(map[string]int, len(containers))
+	for i, ci := range containers {
+		unit[i] = *ci.Container
+		index[ci.ID] = i
+	}
+	for id, deps := range core.ComposeUnitDependencies(unit) {
+		spec := specs[index[id]]
+		for _, d := range deps {
+			target := index[d.ContainerID]
+			if d.Condition == core.ComposeServiceHealthy {
+				probe, err := composeStartupProbe(unit[index[id]], unit[target])
+				if err != nil {
+					return err
+				}
+				specs[target].StartupProbe = probe
+			}
+			spec.DependsOn = append(spec.DependsOn, specs[target].Name)
+		}
+	}
+	return nil
+}
+
+// composeStartupProbe builds the TCP startup probe standing in for
+// dep's health check.
+func composeStartupProbe(c, dep api.Container) (*runpb.Probe, error) {
+	port := imagePort(&dep)
+	if !core.HasHealthcheck(dep) || port == 0 {
+		return nil, &api.InvalidParameterError{Message: fmt.Sprintf(
+			"service %q depends on %q being healthy, which needs a healthcheck and an exposed port for the Cloud Run startup probe",
+			c.Config.Labels[core.ComposeServiceLabel], dep.Config.Labels[core.ComposeServiceLabel])}
+	}
+	interval, timeout, startPeriod, retries := core.HealthcheckTiming(dep.Config.Healthcheck)
+	seconds := func(d time.Duration, lo, hi int32) int32 {
+		return min(max(int32(d/time.Second), lo), hi)
+	}
+	period := seconds(interval, 1, 240)
+	return &runpb.Probe{
+		InitialDelaySeconds: seconds(startPeriod, 0, 240),
+		PeriodSeconds:       period,
+		TimeoutSeconds:      seconds(timeout, 1, period),
+		FailureThreshold:    int32(max(retries, 1)),
+		ProbeType: &runpb.Probe_TcpSocket{
+			TcpSocket: &runpb.TCPSocketAction{Port: int32(port)},
+		},
+	}, nil
+}
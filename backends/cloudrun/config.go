@@ -22,6 +22,14 @@ type Config struct {
 	PollInterval  time.Duration // Cloud API poll interval (default 2s)
 	LogTimeout    time.Duration // Cloud Logging query timeout (default 30s)
 
+	// ImageRepository is the registry prefix `docker load` and
+	// `docker import` push images under when their tag is not already an
+	// Artifact Registry reference. No default: set it to a Docker-format
+	// repository, e.g. terraform's `artifact_registry_repository_url`
+	// output.
+	// Set via SOCKERLESS_CLOUDRUN_IMAGE_REPOSITORY.
+	ImageRepository string
+
 	// UseService switches container execution from Cloud Run Jobs to
 	// Cloud Run Services with internal ingress. Required for:
 	// Jobs don't have addressable per-execution IPs, so
@@ -92,6 +100,33 @@ type Config struct {
 	// peers share a single backend instance) or nat-gateway-only
 	// (no peer discovery). Set via SOCKERLESS_GCR_NETWORK_DISCOVERY.
 	NetworkDiscovery api.NetworkDiscoveryKind
+
+	// ImageScan selects the vulnerability scanner and the admission
+	// policy applied to create / pull. Set via SOCKERLESS_IMAGE_SCANNER,
+	// SOCKERLESS_VULN_FEED, SOCKERLESS_SCAN_BLOCK_SEVERITY and
+	// SOCKERLESS_SCAN_ENFORCE.
+	ImageScan core.ImageScanConfig
+
+	// ImageVerify is the image signature trust policy applied to
+	// create. Set via the SOCKERLESS_VERIFY_* variables.
+	ImageVerify core.ImageVerifyConfig
+
+	// RegistryMirror holds the upstream registry credentials stored in
+	// the cloud secret store when sockerless creates a pull-through
+	// cache. Set via SOCKERLESS_REGISTRY_MIRROR_AUTH.
+	RegistryMirror core.RegistryMirrorConfig
+
+	// Costs configures per-run cost accounting and budgets. Set via
+	// the SOCKERLESS_COST_* variables.
+	Costs core.CostConfig
+
+	// Audit configures the audit log of cloud mutations. Set via the
+	// SOCKERLESS_AUDIT_* variables; the sink is a Cloud Logging log name.
+	Audit core.AuditConfig
+
+	// HA configures multi-instance operation. Set via the SOCKERLESS_HA_*
+	// variables; the lease store is a GCS bucket[/prefix].
+	HA core.HAConfig
 }
 
 // SharedVolume describes a workspace volume mounted via GCS that the
@@ -134,6 +169,7 @@ func ConfigFromEnv() Config {
 		BuildBucket:         os.Getenv("SOCKERLESS_GCP_BUILD_BUCKET"),
 		BuildPlatform:       envOrDefault("SOCKERLESS_GCP_BUILD_PLATFORM", "linux/amd64"),
 		EndpointURL:         os.Getenv("SOCKERLESS_ENDPOINT_URL"),
+		ImageRepository:     os.Getenv("SOCKERLESS_CLOUDRUN_IMAGE_REPOSITORY"),
 		PollInterval:        parseDuration(os.Getenv("SOCKERLESS_POLL_INTERVAL"), 2*time.Second),
 		LogTimeout:          parseDuration(os.Getenv("SOCKERLESS_LOG_TIMEOUT"), 30*time.Second),
 		UseService:          os.Getenv("SOCKERLESS_GCR_USE_SERVICE") == "1",
@@ -143,6 +179,12 @@ func ConfigFromEnv() Config {
 		BootstrapBinaryPath: os.Getenv("SOCKERLESS_CLOUDRUN_BOOTSTRAP"),
 		ServiceAccount:      os.Getenv("SOCKERLESS_CLOUDRUN_SERVICE_ACCOUNT"),
 		NetworkDiscovery:    networkDiscoveryFromEnv("SOCKERLESS_GCR_NETWORK_DISCOVERY", api.NetworkDiscoveryCloudDNS),
+		ImageScan:           core.ImageScanConfigFromEnv(),
+		ImageVerify:         core.ImageVerifyConfigFromEnv(),
+		RegistryMirror:      core.RegistryMirrorConfigFromEnv(),
+		Costs:               core.CostConfigFromEnv(),
+		Audit:               core.AuditConfigFromEnv(),
+		HA:                  core.HAConfigFromEnv(),
 	}
 }
 
@@ -264,6 +306,12 @@ func ConfigFromEnvironment(env *core.Environment, sim *core.SimulatorConfig) Con
 		c.EndpointURL = fmt.Sprintf("http://localhost:%d", sim.Port)
 	}
 	c.NetworkDiscovery = networkDiscoveryFromEnv("SOCKERLESS_GCR_NETWORK_DISCOVERY", api.NetworkDiscoveryCloudDNS)
+	c.ImageScan = core.ImageScanConfigFromEnv()
+	c.ImageVerify = core.ImageVerifyConfigFromEnv()
+	c.RegistryMirror = core.RegistryMirrorConfigFromEnv()
+	c.Costs = core.CostConfigFromEnv()
+	c.Audit = core.AuditConfigFromEnv()
+	c.HA = core.HAConfigFromEnv()
 	return c
 }
 
@@ -281,7 +329,22 @@ func (c Config) Validate() error {
 	default:
 		return fmt.Errorf("SOCKERLESS_GCR_NETWORK_DISCOVERY=%q not supported by cloudrun (one of cloud-dns, host-aliases, nat-gateway-only required)", c.NetworkDiscovery)
 	}
-	return nil
+	if err := c.ImageScan.Validate(); err != nil {
+		return err
+	}
+	if err := c.ImageVerify.Validate(); err != nil {
+		return err
+	}
+	if err := c.RegistryMirror.Validate(); err != nil {
+		return err
+	}
+	if err := c.Costs.Validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+	return c.HA.Validate()
 }
 
 func parseDuration(s string, def time.Duration) time.Duration {
@@ -0,0 +1,23 @@
+package cloudrun
+
+import (
+	"strconv"
+
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// ContainerCostShape prices a container at the limits jobspec.go gives
+// the main container of its Job or Service. Named volumes are GCS
+// buckets.
+func (s *Server) ContainerCostShape(*api.Container) core.CostShape {
+	cpu, memory := mapCPUMemory()
+	vcpu, _ := strconv.ParseFloat(cpu, 64)
+	mib, _ := core.ParseMemoryMiB(memoryLimitForContainer(memory, true))
+	return core.CostShape{
+		Kind:         core.CostKindCloudRun,
+		VCPU:         vcpu,
+		MemoryGiB:    float64(mib) / 1024,
+		StorageClass: core.CostStorageGCS,
+	}
+}
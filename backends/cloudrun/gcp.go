@@ -11,8 +11,13 @@ import (
 	"cloud.google.com/go/logging/logadmin"
 	run "cloud.google.com/go/run/apiv2"
 	"cloud.google.com/go/storage"
+	gcpcommon "github.com/sockerless/gcp-common"
+	artifactregistry "google.golang.org/api/artifactregistry/v1"
+	containeranalysis "google.golang.org/api/containeranalysis/v1"
 	"google.golang.org/api/dns/v1"
+	monitoring "google.golang.org/api/monitoring/v3"
 	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -36,6 +41,14 @@ type GCPClients struct {
 	LogAdmin   *logadmin.Client
 	Storage    *storage.Client
 	DNS        *dns.Service
+	Monitoring *monitoring.Service
+	// ContainerAnalysis reads Artifact Analysis vulnerability
+	// occurrences for the cloud image scanner.
+	ContainerAnalysis *containeranalysis.Service
+	// ArtifactRegistry and SecretManager manage the registry mirrors
+	// (remote repositories) and their upstream credentials.
+	ArtifactRegistry *artifactregistry.Service
+	SecretManager    *secretmanager.Service
 }
 
 // NewGCPClients initializes GCP SDK clients.
@@ -47,9 +60,14 @@ func NewGCPClients(ctx context.Context, project string, endpointURL string) (*GC
 }
 
 func newGCPClientsWithEndpoint(ctx context.Context, project string, endpointURL string) (*GCPClients, error) {
+	hc, err := gcpcommon.CloudAPIHTTPClient(ctx, false)
+	if err != nil {
+		return nil, err
+	}
 	opts := []option.ClientOption{
 		option.WithEndpoint(endpointURL),
 		option.WithoutAuthentication(),
+		option.WithHTTPClient(hc),
 	}
 
 	jobsClient, err := run.NewJobsRESTClient(ctx, opts...)
@@ -82,6 +100,7 @@ func newGCPClientsWithEndpoint(ctx context.Context, project string, endpointURL
 		option.WithEndpoint(grpcAddr),
 		option.WithoutAuthentication(),
 		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		gcpcommon.CloudAPIGRPCOption(),
 	}
 	logAdminClient, err := logadmin.NewClient(ctx, project, logAdminOpts...)
 	if err != nil {
@@ -97,7 +116,7 @@ func newGCPClientsWithEndpoint(ctx context.Context, project string, endpointURL
 	// makes the client send XML-API-style `/b/...` paths that the GCP
 	// sim doesn't route. Set the env var AND drop WithEndpoint for the
 	// Storage client so the JSON API path is used.
-	storageOpts := []option.ClientOption{option.WithoutAuthentication()}
+	storageOpts := []option.ClientOption{option.WithoutAuthentication(), option.WithHTTPClient(hc)}
 	if host, err := urlHost(endpointURL); err == nil {
 		_ = os.Setenv("STORAGE_EMULATOR_HOST", host)
 	}
@@ -120,64 +139,161 @@ func newGCPClientsWithEndpoint(ctx context.Context, project string, endpointURL
 		return nil, err
 	}
 
+	monitoringService, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		_ = jobsClient.Close()
+		_ = execClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
+		return nil, err
+	}
+
+	containerAnalysisService, err := containeranalysis.NewService(ctx, opts...)
+	if err != nil {
+		_ = jobsClient.Close()
+		_ = execClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
+		return nil, err
+	}
+
+	artifactRegistryService, err := artifactregistry.NewService(ctx, opts...)
+	if err != nil {
+		_ = jobsClient.Close()
+		_ = execClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
+		return nil, err
+	}
+
+	secretManagerService, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		_ = jobsClient.Close()
+		_ = execClient.Close()
+		_ = servicesClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
+		return nil, err
+	}
+
 	return &GCPClients{
-		Jobs:       jobsClient,
-		Executions: execClient,
-		Services:   servicesClient,
-		Logging:    nil, // not used — only logadmin is used for reading logs
-		LogAdmin:   logAdminClient,
-		Storage:    storageClient,
-		DNS:        dnsService,
+		Jobs:              jobsClient,
+		Executions:        execClient,
+		Services:          servicesClient,
+		Logging:           nil, // not used — only logadmin is used for reading logs
+		LogAdmin:          logAdminClient,
+		Storage:           storageClient,
+		DNS:               dnsService,
+		Monitoring:        monitoringService,
+		ContainerAnalysis: containerAnalysisService,
+		ArtifactRegistry:  artifactRegistryService,
+		SecretManager:     secretManagerService,
 	}, nil
 }
 
 func newGCPClientsDefault(ctx context.Context, project string) (*GCPClients, error) {
-	jobsClient, err := run.NewJobsClient(ctx)
+	// gRPC clients count calls with an interceptor; the REST clients
+	// share an authenticated, counting HTTP client.
+	hc, err := gcpcommon.CloudAPIHTTPClient(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	grpcOpt := gcpcommon.CloudAPIGRPCOption()
+	restOpt := option.WithHTTPClient(hc)
+
+	jobsClient, err := run.NewJobsClient(ctx, grpcOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	execClient, err := run.NewExecutionsClient(ctx, grpcOpt)
+	if err != nil {
+		_ = jobsClient.Close()
+		return nil, err
+	}
+
+	servicesClient, err := run.NewServicesClient(ctx, grpcOpt)
+	if err != nil {
+		_ = jobsClient.Close()
+		_ = execClient.Close()
+		return nil, err
+	}
+
+	loggingClient, err := logging.NewClient(ctx, project, grpcOpt)
 	if err != nil {
+		_ = jobsClient.Close()
+		_ = execClient.Close()
+		_ = servicesClient.Close()
+		return nil, err
+	}
+
+	logAdminClient, err := logadmin.NewClient(ctx, project, grpcOpt)
+	if err != nil {
+		_ = jobsClient.Close()
+		_ = execClient.Close()
+		_ = servicesClient.Close()
+		_ = loggingClient.Close()
 		return nil, err
 	}
 
-	execClient, err := run.NewExecutionsClient(ctx)
+	storageClient, err := storage.NewClient(ctx, restOpt)
 	if err != nil {
 		_ = jobsClient.Close()
+		_ = execClient.Close()
+		_ = servicesClient.Close()
+		_ = loggingClient.Close()
+		_ = logAdminClient.Close()
 		return nil, err
 	}
 
-	servicesClient, err := run.NewServicesClient(ctx)
+	dnsService, err := dns.NewService(ctx, restOpt)
 	if err != nil {
 		_ = jobsClient.Close()
 		_ = execClient.Close()
+		_ = servicesClient.Close()
+		_ = loggingClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
 		return nil, err
 	}
 
-	loggingClient, err := logging.NewClient(ctx, project)
+	monitoringService, err := monitoring.NewService(ctx, restOpt)
 	if err != nil {
 		_ = jobsClient.Close()
 		_ = execClient.Close()
 		_ = servicesClient.Close()
+		_ = loggingClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
 		return nil, err
 	}
 
-	logAdminClient, err := logadmin.NewClient(ctx, project)
+	containerAnalysisService, err := containeranalysis.NewService(ctx, restOpt)
 	if err != nil {
 		_ = jobsClient.Close()
 		_ = execClient.Close()
 		_ = servicesClient.Close()
 		_ = loggingClient.Close()
+		_ = logAdminClient.Close()
+		_ = storageClient.Close()
 		return nil, err
 	}
 
-	storageClient, err := storage.NewClient(ctx)
+	artifactRegistryService, err := artifactregistry.NewService(ctx, restOpt)
 	if err != nil {
 		_ = jobsClient.Close()
 		_ = execClient.Close()
 		_ = servicesClient.Close()
 		_ = loggingClient.Close()
 		_ = logAdminClient.Close()
+		_ = storageClient.Close()
 		return nil, err
 	}
 
-	dnsService, err := dns.NewService(ctx)
+	secretManagerService, err := secretmanager.NewService(ctx, restOpt)
 	if err != nil {
 		_ = jobsClient.Close()
 		_ = execClient.Close()
@@ -189,13 +305,17 @@ func newGCPClientsDefault(ctx context.Context, project string) (*GCPClients, err
 	}
 
 	return &GCPClients{
-		Jobs:       jobsClient,
-		Executions: execClient,
-		Services:   servicesClient,
-		Logging:    loggingClient,
-		LogAdmin:   logAdminClient,
-		Storage:    storageClient,
-		DNS:        dnsService,
+		Jobs:              jobsClient,
+		Executions:        execClient,
+		Services:          servicesClient,
+		Logging:           loggingClient,
+		LogAdmin:          logAdminClient,
+		Storage:           storageClient,
+		DNS:               dnsService,
+		Monitoring:        monitoringService,
+		ContainerAnalysis: containerAnalysisService,
+		ArtifactRegistry:  artifactRegistryService,
+		SecretManager:     secretManagerService,
 	}, nil
 }
 
@@ -213,3 +333,21 @@ func grpcAddrFromEndpoint(endpointURL string) (string, error) {
 	}
 	return fmt.Sprintf("%s:%d", host, port+1), nil
 }
+
+// newAuditLoggingClient builds the Cloud Logging client the audit sink
+// writes with. It is separate from the read clients and carries no
+// CloudAPIGRPCOption, so the sink's writes are neither counted nor
+// audited.
+func newAuditLoggingClient(ctx context.Context, project, endpointURL string) (*logging.Client, error) {
+	if endpointURL == "" {
+		return logging.NewClient(ctx, project)
+	}
+	grpcAddr, err := grpcAddrFromEndpoint(endpointURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive gRPC address: %w", err)
+	}
+	return logging.NewClient(ctx, project,
+		option.WithEndpoint(grpcAddr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+}
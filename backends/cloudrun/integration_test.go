@@ -31,6 +31,7 @@ import (
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	core "github.com/sockerless/backend-core"
 )
 
 var dockerClient *client.Client
@@ -78,11 +79,19 @@ func requireExe(name string) {
 //	SOCKERLESS_GCR_PROJECT) and fails loud
 //	on any missing.
 //
+// SOCKERLESS_TEST_TARGET = replay → harness serves the cassette named by
+//
+//	SOCKERLESS_TEST_CASSETTE in place of a
+//	simulator or cloud, with the sim fixtures.
+//
+// SOCKERLESS_TEST_RECORD=<path> with the sim or cloud target records the
+// backend's endpoint traffic into a cassette for later replay.
+//
 // The Test* functions don't know which target they're running against.
 func TestMain(m *testing.M) {
 	target := requireEnv("SOCKERLESS_TEST_TARGET")
-	if target != "sim" && target != "cloud" {
-		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\" or \"cloud\").\n", target)
+	if target != "sim" && target != "cloud" && target != "replay" {
+		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\", \"cloud\" or \"replay\").\n", target)
 		os.Exit(1)
 	}
 	requireExe("docker")
@@ -151,7 +160,7 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		failClean("ERROR: docker build alpine local tags: %v\n%s", err, out)
 	}
 
-	var endpointURL, project, bootstrapPath, buildBucket, saJSONPath string
+	var endpointURL, project, bootstrapPath, buildBucket, saJSONPath, imageRepository string
 	switch target {
 	case "sim":
 		simDir := repoRoot + "/simulators/gcp"
@@ -198,6 +207,10 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		endpointURL = simURL
 		project = "sim-project"
 		buildBucket = "sockerless-test-build"
+		// Loaded images are pushed under the docker-hub remote
+		// repository path, which the simulator maps back to the local
+		// daemon's images when it runs them.
+		imageRepository = "us-central1-docker.pkg.dev/" + project + "/docker-hub/library"
 
 		// Pre-create the GCS bucket the backend uses for Cloud Build
 		// context uploads (overlay path). Real GCS doesn't auto-create
@@ -241,14 +254,41 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 			bootstrapPath = ""
 		}
 
+	case "replay":
+		project = "sim-project"
+		buildBucket = "sockerless-test-build"
+		imageRepository = "us-central1-docker.pkg.dev/" + project + "/docker-hub/library"
+
 	case "cloud":
 		endpointURL = requireEnv("SOCKERLESS_ENDPOINT_URL")
 		project = requireEnv("SOCKERLESS_GCR_PROJECT")
 		bootstrapPath = requireEnv("SOCKERLESS_CLOUDRUN_BOOTSTRAP")
 		buildBucket = requireEnv("SOCKERLESS_GCP_BUILD_BUCKET")
+		imageRepository = requireEnv("SOCKERLESS_CLOUDRUN_IMAGE_REPOSITORY")
 		saJSONPath = requireEnv("GOOGLE_APPLICATION_CREDENTIALS")
 	}
 
+	// SOCKERLESS_TEST_RECORD records the session into a cassette;
+	// the replay target serves one instead of a simulator or cloud.
+	cassette, err := core.CassetteHarnessFromEnv(target, endpointURL)
+	if err != nil {
+		failClean("ERROR: cassette harness: %v\n", err)
+	}
+	if cassette != nil {
+		fmt.Printf("[cassette] %s %s via %s\n", cassette.Mode, cassette.Path, cassette.URL)
+		endpointURL = cassette.URL
+		if target != "cloud" {
+			// The fake SA's token exchange goes through the cassette too.
+			if saJSONPath != "" {
+				_ = os.Remove(saJSONPath)
+			}
+			if saJSONPath, err = writeFakeSAJSONCloudrun(cassette.URL + "/token"); err != nil {
+				failClean("ERROR: stage fake SA JSON: %v\n", err)
+			}
+			cleanups = append(cleanups, func() { _ = os.Remove(saJSONPath) })
+		}
+	}
+
 	backendDir := repoRoot + "/backends/cloudrun"
 	backendBinary := backendDir + "/sockerless-backend-cloudrun"
 	fmt.Println("[backend] Building sockerless-backend-cloudrun...")
@@ -274,6 +314,7 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		"SOCKERLESS_GCR_PROJECT="+project,
 		"SOCKERLESS_CLOUDRUN_BOOTSTRAP="+bootstrapPath,
 		"SOCKERLESS_GCP_BUILD_BUCKET="+buildBucket,
+		"SOCKERLESS_CLOUDRUN_IMAGE_REPOSITORY="+imageRepository,
 		"SOCKERLESS_GCP_BUILD_PLATFORM="+overlayPlatform,
 		// Required at NewServer per Phase 168 (no Path B fallback).
 		// Bootstrap dials back over WebSocket from inside the workload
@@ -305,7 +346,6 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 	}
 	fmt.Printf("[backend] ready on %s\n", backendAddr)
 
-	var err error
 	dockerClient, err = client.NewClientWithOpts(
 		client.WithHost(fmt.Sprintf("tcp://localhost:%d", backendPort)),
 		client.WithAPIVersionNegotiation(),
@@ -326,6 +366,9 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 
 	code := m.Run()
 	cleanup()
+	if cassette != nil {
+		code = cassette.Finish(code)
+	}
 	os.Exit(code)
 }
 
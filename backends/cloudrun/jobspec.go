@@ -219,6 +219,11 @@ func (s *Server) buildJobSpec(ctx context.Context, containers []containerInput)
 		}
 	}
 	injectPersistEnv(specs, persistEntries)
+	if len(containers) > 1 {
+		if err := applyComposeDependencies(specs, containers); err != nil {
+			return nil, err
+		}
+	}
 
 	// Cloud-side cap on top of the bootstrap-side timer. Both layers
 	// share the same intent value (core.JobTimeoutDefault). Cloud Run
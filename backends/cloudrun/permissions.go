@@ -0,0 +1,73 @@
+package cloudrun
+
+import (
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+	gcpcommon "github.com/sockerless/gcp-common"
+)
+
+// requiredPermissions declares every IAM permission the Cloud Run
+// backend uses under config's driver selection. `sockerless check`
+// probes the set with projects.testIamPermissions; keep it in step with
+// the SDK calls in this package. Exec and the filesystem verbs ride the
+// reverse-agent WebSocket (or the pod-Service invoke when UseService is
+// set) and need no permission beyond run.routes.invoke.
+func requiredPermissions(config Config) *core.PermissionSet {
+	var set core.PermissionSet
+
+	// Container lifecycle: one Cloud Run Job per container, started by
+	// RunJob. Create / run / delete return long-running operations.
+	set.Add("containers", []string{"create", "run", "start"},
+		"run.jobs.create",
+		"run.jobs.run",
+		"run.jobs.list",
+		"run.operations.get",
+	)
+	set.Add("containers", []string{"stop", "kill"}, "run.executions.list", "run.executions.cancel")
+	set.Add("containers", []string{"rm", "container prune"}, "run.jobs.delete", "run.jobs.list", "run.operations.get")
+	set.Add("containers", []string{"ps", "inspect", "wait"},
+		"run.jobs.list",
+		"run.executions.get",
+		"run.executions.list",
+	)
+	if config.ServiceAccount != "" {
+		set.Add("containers", []string{"create", "run"}, "iam.serviceAccounts.actAs")
+	}
+	if config.VPCConnector != "" {
+		set.Add("containers", []string{"create", "run"}, "vpcaccess.connectors.use")
+	}
+
+	// UseService runs containers as Cloud Run Services and routes exec
+	// through an authenticated invoke of the service URL.
+	if config.UseService {
+		driver := "containers service"
+		set.Add(driver, []string{"create", "run", "start"}, "run.services.create", "run.services.get", "run.operations.get")
+		set.Add(driver, []string{"rm", "container prune"}, "run.services.delete", "run.services.list")
+		set.Add(driver, []string{"ps", "inspect"}, "run.services.list")
+		set.Add(driver, []string{"exec", "cp", "top"}, "run.services.get", "run.routes.invoke")
+	}
+
+	set.Add("logs cloud-logging", []string{"logs", "attach", "run"}, "logging.logEntries.list")
+	gcpcommon.AddCloudMonitoringStatsPermissions(&set)
+
+	if config.NetworkDiscovery == api.NetworkDiscoveryCloudDNS {
+		gcpcommon.AddCloudDNSPermissions(&set)
+	}
+	gcpcommon.AddBucketVolumePermissions(&set)
+	gcpcommon.AddGCSSyncPermissions(&set)
+	gcpcommon.AddArtifactRegistryPermissions(&set)
+	gcpcommon.AddArtifactRegistryMirrorPermissions(&set)
+	if config.ImageScan.Scanner == "cloud" {
+		gcpcommon.AddArtifactAnalysisScanPermissions(&set)
+	}
+	if config.BuildBucket != "" {
+		gcpcommon.AddCloudBuildPermissions(&set)
+	}
+	if config.Audit.Sink != "" {
+		gcpcommon.AddCloudLoggingAuditPermissions(&set)
+	}
+	if config.HA.Mode != "" {
+		gcpcommon.AddGCSLeasePermissions(&set)
+	}
+	return &set
+}
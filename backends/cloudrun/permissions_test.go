@@ -0,0 +1,106 @@
+package cloudrun
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+func permissionIndex(set *core.PermissionSet) map[string]core.PermissionRequirement {
+	out := map[string]core.PermissionRequirement{}
+	for _, r := range set.Requirements() {
+		out[r.Permission] = r
+	}
+	return out
+}
+
+// TestRequiredPermissions_CoversSDKCalls scans this package for GCP
+// client calls and fails when one has no declared IAM permission, so a
+// new call can't ship without its preflight entry.
+func TestRequiredPermissions_CoversSDKCalls(t *testing.T) {
+	permissions := map[string]string{
+		"Jobs.CreateJob":             "run.jobs.create",
+		"Jobs.RunJob":                "run.jobs.run",
+		"Jobs.DeleteJob":             "run.jobs.delete",
+		"Jobs.ListJobs":              "run.jobs.list",
+		"Executions.GetExecution":    "run.executions.get",
+		"Executions.ListExecutions":  "run.executions.list",
+		"Executions.CancelExecution": "run.executions.cancel",
+		"Services.CreateService":     "run.services.create",
+		"Services.GetService":        "run.services.get",
+		"Services.ListServices":      "run.services.list",
+		"Services.DeleteService":     "run.services.delete",
+		"LogAdmin.Entries":           "logging.logEntries.list",
+	}
+	declared := permissionIndex(requiredPermissions(Config{
+		UseService:       true,
+		NetworkDiscovery: api.NetworkDiscoveryCloudDNS,
+		BuildBucket:      "bucket",
+	}))
+
+	call := regexp.MustCompile(`\.gcp\.(\w+)\.([A-Z]\w+)\(`)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range call.FindAllStringSubmatch(string(src), -1) {
+			perm, ok := permissions[m[1]+"."+m[2]]
+			if !ok {
+				t.Errorf("%s: unmapped call %s.%s", f, m[1], m[2])
+				continue
+			}
+			if _, ok := declared[perm]; !ok {
+				t.Errorf("%s: %s.%s needs %s, which requiredPermissions does not declare", f, m[1], m[2], perm)
+			}
+		}
+	}
+}
+
+func TestRequiredPermissions_FollowDriverSelection(t *testing.T) {
+	base := permissionIndex(requiredPermissions(Config{NetworkDiscovery: api.NetworkDiscoveryHostAliases}))
+	for _, perm := range []string{"dns.resourceRecordSets.create", "run.services.create", "cloudbuild.builds.create", "iam.serviceAccounts.actAs"} {
+		if _, ok := base[perm]; ok {
+			t.Errorf("%s must not be required without the driver that uses it", perm)
+		}
+	}
+	if !containsString(base["run.jobs.run"].Verbs, "run") {
+		t.Errorf("run.jobs.run verbs = %v, want run", base["run.jobs.run"].Verbs)
+	}
+
+	full := permissionIndex(requiredPermissions(Config{
+		UseService:       true,
+		ServiceAccount:   "runner@p.iam.gserviceaccount.com",
+		NetworkDiscovery: api.NetworkDiscoveryCloudDNS,
+		BuildBucket:      "bucket",
+	}))
+	if !containsString(full["run.routes.invoke"].Verbs, "exec") {
+		t.Errorf("run.routes.invoke verbs = %v, want exec", full["run.routes.invoke"].Verbs)
+	}
+	for _, perm := range []string{"dns.resourceRecordSets.create", "cloudbuild.builds.create", "iam.serviceAccounts.actAs"} {
+		if _, ok := full[perm]; !ok {
+			t.Errorf("%s missing under full driver selection", perm)
+		}
+	}
+}
+
+func containsString(list []string, v string) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -30,7 +30,14 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 		matchesInstance := job.Labels["sockerless_instance"] == instanceID
 
 		if managed && matchesInstance {
+			// Labels cap values at 63 characters; the annotation
+			// carries the full container ID.
+			cid := job.Annotations["sockerless_container_id"]
+			if cid == "" {
+				cid = job.Labels["sockerless_container_id"]
+			}
 			orphans = append(orphans, core.ResourceEntry{
+				ContainerID:  cid,
 				Backend:      "cloudrun",
 				ResourceType: "job",
 				ResourceID:   job.Name,
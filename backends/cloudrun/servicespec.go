@@ -56,6 +56,11 @@ func (s *Server) buildServiceSpec(ctx context.Context, containers []containerInp
 		}
 	}
 	injectPersistEnv(specs, persistEntries)
+	if len(containers) > 1 {
+		if err := applyComposeDependencies(specs, containers); err != nil {
+			return nil, err
+		}
+	}
 
 	// Multi-container revision: inject SOCKERLESS_HOST_ALIASES into the
 	// main container's env so the bootstrap can write `127.0.0.1 <alias>`
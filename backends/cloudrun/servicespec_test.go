@@ -127,3 +127,44 @@ func TestBuildServiceSpec_NoVPCConnector(t *testing.T) {
 		t.Fatalf("expected nil VpcAccess when connector unset, got %+v", svc.Template.VpcAccess)
 	}
 }
+
+// TestBuildServiceSpec_ComposeDependencies — a compose unit's depends_on
+// becomes container dependsOn, and a service_healthy dependency gets a
+// TCP startup probe on its exposed port with the health check timings.
+func TestBuildServiceSpec_ComposeDependencies(t *testing.T) {
+	s := newServerForSpec(t, "")
+	web := demoContainer("web000000000000000000", "/shop-web-1", "nginx")
+	web.Container.Config.Labels = map[string]string{
+		core.ComposeServiceLabel:   "web",
+		core.ComposeDependsOnLabel: "db:service_healthy:false,cache:service_started:false",
+	}
+	db := demoContainer("db0000000000000000000", "/shop-db-1", "postgres")
+	db.IsMain = false
+	db.Container.Config.Labels = map[string]string{core.ComposeServiceLabel: "db"}
+	db.Container.Config.ExposedPorts = map[string]struct{}{"5432/tcp": {}}
+	db.Container.Config.Healthcheck = &api.HealthcheckConfig{Test: []string{"CMD", "pg_isready"}, Interval: 2e9, Retries: 15}
+	cache := demoContainer("cache00000000000000000", "/shop-cache-1", "redis")
+	cache.IsMain = false
+	cache.Container.Config.Labels = map[string]string{core.ComposeServiceLabel: "cache"}
+
+	svc, err := s.buildServiceSpec(context.Background(), []containerInput{web, db, cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers := svc.Template.Containers
+	if got := containers[0].DependsOn; len(got) != 2 || got[0] != containers[1].Name || got[1] != containers[2].Name {
+		t.Errorf("main dependsOn = %v", got)
+	}
+	probe := containers[1].StartupProbe
+	if probe == nil || probe.GetTcpSocket().GetPort() != 5432 || probe.PeriodSeconds != 2 || probe.FailureThreshold != 15 {
+		t.Errorf("db startup probe = %+v", probe)
+	}
+	if containers[2].StartupProbe != nil {
+		t.Error("startup probe on a service nothing waits for to be healthy")
+	}
+
+	db.Container.Config.ExposedPorts = nil
+	if _, err := s.buildServiceSpec(context.Background(), []containerInput{web, db, cache}); err == nil {
+		t.Error("service_healthy dependency without an exposed port accepted")
+	}
+}
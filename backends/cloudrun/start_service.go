@@ -441,8 +441,10 @@ func (s *Server) startMultiContainerServiceTyped(_ string, podContainers []api.C
 		// them (gitlab-runner v17.5 spawns a new script-runner per
 		// stage; each stage's revision needs its own copy of the
 		// sidecar). The script-runner itself (OpenStdin=true) gets
-		// deleted normally — it's per-stage and not re-bundled.
-		if pc.Config.OpenStdin {
+		// deleted normally — it's per-stage and not re-bundled, as are
+		// pod members, which the pod's unit serves from then on.
+		_, inPod := s.Store.Pods.GetPodForContainer(pc.ID)
+		if pc.Config.OpenStdin || inPod {
 			s.PendingCreates.Delete(pc.ID)
 		}
 		s.CloudRun.Update(pc.ID, func(state *CloudRunState) {
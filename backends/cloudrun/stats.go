@@ -0,0 +1,40 @@
+package cloudrun
+
+import (
+	"path"
+
+	core "github.com/sockerless/backend-core"
+	gcpcommon "github.com/sockerless/gcp-common"
+)
+
+// newStatsProvider builds the `docker stats` source: cgroup counters
+// over the reverse agent when the container's bootstrap is connected,
+// otherwise Cloud Monitoring utilization for the container's Job or
+// Service, scaled by the limits jobspec.go assigns the main container.
+func (s *Server) newStatsProvider() core.StatsProvider {
+	cpu, memory := mapCPUMemory()
+	return &core.StatsSources{
+		Agents: s.reverseAgents,
+		Cloud: &gcpcommon.CloudMonitoringStats{
+			Service: s.gcp.Monitoring,
+			Project: s.config.Project,
+			Resource: func(containerID string) (gcpcommon.CloudRunMonitoredResource, bool) {
+				res := gcpcommon.CloudRunMonitoredResource{CPULimit: cpu, MemoryLimit: memoryLimitForContainer(memory, true)}
+				if s.config.UseService {
+					state, ok := s.resolveServiceCloudRunState(s.ctx(), containerID)
+					if !ok {
+						return res, false
+					}
+					res.Type, res.NameLabel, res.Name = "cloud_run_revision", "service_name", path.Base(state.ServiceName)
+					return res, true
+				}
+				state, ok := s.resolveCloudRunState(s.ctx(), containerID)
+				if !ok {
+					return res, false
+				}
+				res.Type, res.NameLabel, res.Name = "cloud_run_job", "job_name", path.Base(state.JobName)
+				return res, true
+			},
+		},
+	}
+}
@@ -0,0 +1,518 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog"
+	"github.com/sockerless/api"
+)
+
+// Audit log of cloud mutations. Every call a backend makes to its cloud
+// that is not a read (create, delete, run, start, stop, update, tag, ...)
+// is recorded with the docker API request that caused it, the client
+// that sent that request, the container, the cloud operation, the
+// resource it acted on and its outcome. The per-cloud SDK hooks that
+// count sockerless_cloud_api_* metrics call AuditCloudCall; records go
+// to an append-only, rotated JSONL file and optionally to a cloud log
+// sink, and are queried with GET /internal/v1/resources/history.
+//
+// Attribution. Requests get an ID (the client's X-Request-Id, or a
+// generated one echoed back in X-Request-Id) and are carried in the
+// request context. Most backend code calls the cloud with a background
+// context, so when the call's context carries no request the record is
+// attributed from the requests in flight: the one for the container
+// that owns the resource (per the ResourceRegistry, or the container's
+// short ID in the resource name), else the only mutating request in
+// flight. Such records are marked inferred. Calls made with no request
+// in flight (reapers, pool janitors, startup recovery) carry no request
+// ID.
+
+// Audit outcomes.
+const (
+	AuditOutcomeOK    = "ok"
+	AuditOutcomeError = "error"
+)
+
+// maxAuditMemory bounds the records kept in memory when no audit log
+// file is configured; the oldest are dropped first.
+const maxAuditMemory = 10000
+
+// Cloud sink batching: records are shipped in batches of up to
+// auditSinkBatch, at least every auditSinkInterval.
+const (
+	auditSinkBuffer   = 4096
+	auditSinkBatch    = 100
+	auditSinkInterval = 2 * time.Second
+	auditSinkTimeout  = 30 * time.Second
+)
+
+// AuditRecord is one cloud mutation.
+type AuditRecord struct {
+	Time        time.Time `json:"time"`
+	Backend     string    `json:"backend"`
+	InstanceID  string    `json:"instanceId,omitempty"`
+	RequestID   string    `json:"requestId,omitempty"`
+	Client      string    `json:"client,omitempty"`    // TLS client certificate CN, else remote address
+	UserAgent   string    `json:"userAgent,omitempty"` // of the docker request
+	Method      string    `json:"method,omitempty"`    // docker request method and path
+	Path        string    `json:"path,omitempty"`
+	ContainerID string    `json:"containerId,omitempty"`
+	// Inferred is set when the request was matched from the requests in
+	// flight rather than carried by the cloud call's context.
+	Inferred   bool   `json:"inferred,omitempty"`
+	Service    string `json:"service"`
+	Operation  string `json:"operation"`
+	Resource   string `json:"resource,omitempty"` // ARN, ARM ID or resource name
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// AuditConfig configures the audit log on a backend.
+type AuditConfig struct {
+	// LogPath is the append-only JSONL audit log. Empty keeps the last
+	// records in memory only.
+	LogPath string
+	// MaxBytes is the size at which the log is rotated (0 = never);
+	// MaxFiles is how many rotated files are kept.
+	MaxBytes int64
+	MaxFiles int
+	// Sink is the cloud log destination records are also shipped to:
+	// a CloudWatch Logs log group (AWS), a Cloud Logging log name (GCP)
+	// or a blob container in the backend's storage account (Azure).
+	// Empty ships nowhere.
+	Sink string
+}
+
+// AuditConfigFromEnv reads SOCKERLESS_AUDIT_LOG,
+// SOCKERLESS_AUDIT_LOG_MAX_MB (default 100),
+// SOCKERLESS_AUDIT_LOG_MAX_FILES (default 5) and SOCKERLESS_AUDIT_SINK.
+func AuditConfigFromEnv() AuditConfig {
+	c := AuditConfig{
+		LogPath:  strings.TrimSpace(os.Getenv("SOCKERLESS_AUDIT_LOG")),
+		MaxBytes: 100 << 20,
+		MaxFiles: 5,
+		Sink:     strings.TrimSpace(os.Getenv("SOCKERLESS_AUDIT_SINK")),
+	}
+	if v := strings.TrimSpace(os.Getenv("SOCKERLESS_AUDIT_LOG_MAX_MB")); v != "" {
+		mb, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(mb) || mb < 0 {
+			mb = -1 // rejected by Validate
+		}
+		c.MaxBytes = int64(mb * (1 << 20))
+	}
+	if v := strings.TrimSpace(os.Getenv("SOCKERLESS_AUDIT_LOG_MAX_FILES")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			n = -1 // rejected by Validate
+		}
+		c.MaxFiles = n
+	}
+	return c
+}
+
+// Validate checks the rotation limits.
+func (c AuditConfig) Validate() error {
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("SOCKERLESS_AUDIT_LOG_MAX_MB must be a non-negative number of MiB")
+	}
+	if c.MaxFiles < 0 {
+		return fmt.Errorf("SOCKERLESS_AUDIT_LOG_MAX_FILES must be a non-negative integer")
+	}
+	return nil
+}
+
+// AuditSink ships audit records to a cloud log service.
+type AuditSink interface {
+	WriteAudit(ctx context.Context, records []AuditRecord) error
+}
+
+// auditRequest identifies the docker API request cloud calls are made
+// for.
+type auditRequest struct {
+	id          string
+	client      string
+	userAgent   string
+	method      string
+	path        string
+	containerID string
+	mutating    bool // not GET or HEAD
+}
+
+type auditRequestKey struct{}
+
+// auditSuppressKey marks the context of the sink's own writes so they
+// are not audited in turn.
+type auditSuppressKey struct{}
+
+func auditRequestFrom(ctx context.Context) *auditRequest {
+	if ctx == nil {
+		return nil
+	}
+	req, _ := ctx.Value(auditRequestKey{}).(*auditRequest)
+	return req
+}
+
+// auditor records cloud mutations. It is process-wide, like
+// cloudAPIMetrics, because the SDK hooks that feed it are installed on
+// clients built before the BaseServer.
+var auditor = newAuditRecorder()
+
+type auditRecorder struct {
+	mu         sync.Mutex
+	backend    string
+	instanceID string
+	registry   *ResourceRegistry
+	log        *AuditLog
+	memory     []AuditRecord // when log is nil
+	inflight   map[*auditRequest]struct{}
+	sink       chan AuditRecord
+	dropped    int // records the sink buffer had no room for
+	logger     zerolog.Logger
+}
+
+func newAuditRecorder() *auditRecorder {
+	return &auditRecorder{inflight: make(map[*auditRequest]struct{}), logger: zerolog.Nop()}
+}
+
+// IsCloudReadOperation reports whether an AWS or gRPC operation name
+// (DescribeTasks, GetFunction, ListJobs, BatchGetImage) is a read. The
+// SDK hooks audit every other operation.
+func IsCloudReadOperation(op string) bool {
+	for _, p := range cloudReadPrefixes {
+		if rest, ok := strings.CutPrefix(op, p); ok && (rest == "" || unicode.IsUpper(rune(rest[0]))) {
+			return true
+		}
+	}
+	return false
+}
+
+var cloudReadPrefixes = []string{
+	"Get", "List", "Describe", "Head", "Search", "Lookup", "Query", "Filter",
+	"Select", "Scan", "Validate", "Test", "Check", "Estimate", "Preview",
+	"Wait", "Read", "Poll", "Receive", "Simulate", "Discover",
+	"BatchGet", "BatchCheck",
+}
+
+// AuditCloudCall records one cloud mutation. The per-cloud SDK hooks
+// call it for every call that is not a read; resource is the ARN, ARM
+// ID or resource name the call acted on, and err is the call's error
+// (an HTTP status >= 400 counts as one).
+func AuditCloudCall(ctx context.Context, service, operation, resource string, d time.Duration, err error) {
+	if ctx != nil && ctx.Value(auditSuppressKey{}) != nil {
+		return
+	}
+	rec := AuditRecord{
+		Time:       time.Now().UTC(),
+		Service:    service,
+		Operation:  operation,
+		Resource:   resource,
+		Outcome:    AuditOutcomeOK,
+		DurationMs: d.Milliseconds(),
+	}
+	if err != nil {
+		rec.Outcome = AuditOutcomeError
+		rec.Error = err.Error()
+	}
+	auditor.record(ctx, rec)
+}
+
+func (a *auditRecorder) record(ctx context.Context, rec AuditRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec.Backend, rec.InstanceID = a.backend, a.instanceID
+	req, containerID, inferred := a.attribute(ctx, rec.Resource)
+	if req != nil {
+		rec.RequestID, rec.Client, rec.UserAgent = req.id, req.client, req.userAgent
+		rec.Method, rec.Path = req.method, req.path
+		rec.Inferred = inferred
+	}
+	rec.ContainerID = containerID
+
+	if a.log != nil {
+		if err := a.log.Append(rec); err != nil {
+			a.logger.Warn().Err(err).Msg("audit log write failed")
+		}
+	} else {
+		if len(a.memory) >= maxAuditMemory {
+			a.memory = append(a.memory[:0], a.memory[len(a.memory)-maxAuditMemory+1:]...)
+		}
+		a.memory = append(a.memory, rec)
+	}
+	if a.sink != nil {
+		select {
+		case a.sink <- rec:
+		default:
+			a.dropped++
+		}
+	}
+}
+
+// attribute finds the request a cloud call was made for, and the
+// container it concerns. Called with a.mu held.
+func (a *auditRecorder) attribute(ctx context.Context, resource string) (req *auditRequest, containerID string, inferred bool) {
+	if req = auditRequestFrom(ctx); req != nil {
+		containerID = req.containerID
+		if containerID == "" {
+			containerID = a.registry.ContainerFor(resource)
+		}
+		return req, containerID, false
+	}
+	containerID = a.registry.ContainerFor(resource)
+	if containerID == "" && resource != "" {
+		for r := range a.inflight {
+			if r.containerID != "" && strings.Contains(resource, shortContainerID(r.containerID)) {
+				containerID = r.containerID
+				break
+			}
+		}
+	}
+	if containerID != "" {
+		for r := range a.inflight {
+			if r.containerID == containerID && r.mutating {
+				return r, containerID, true
+			}
+		}
+		return nil, containerID, false
+	}
+	var only *auditRequest
+	for r := range a.inflight {
+		if !r.mutating {
+			continue
+		}
+		if only != nil {
+			return nil, "", false
+		}
+		only = r
+	}
+	if only != nil {
+		return only, only.containerID, true
+	}
+	return nil, "", false
+}
+
+func (a *auditRecorder) begin(req *auditRequest) {
+	a.mu.Lock()
+	a.inflight[req] = struct{}{}
+	a.mu.Unlock()
+}
+
+func (a *auditRecorder) end(req *auditRequest) {
+	a.mu.Lock()
+	delete(a.inflight, req)
+	a.mu.Unlock()
+}
+
+// records returns the recorded records that match, oldest first.
+func (a *auditRecorder) records(match func(AuditRecord) bool) ([]AuditRecord, error) {
+	a.mu.Lock()
+	log := a.log
+	var out []AuditRecord
+	if log == nil {
+		for _, rec := range a.memory {
+			if match(rec) {
+				out = append(out, rec)
+			}
+		}
+	}
+	a.mu.Unlock()
+	if log != nil {
+		return log.Records(match)
+	}
+	return out, nil
+}
+
+// runSink ships records to sink in batches until ch is closed.
+func (a *auditRecorder) runSink(sink AuditSink, ch <-chan AuditRecord) {
+	ticker := time.NewTicker(auditSinkInterval)
+	defer ticker.Stop()
+	var batch []AuditRecord
+	flush := func() {
+		a.mu.Lock()
+		dropped := a.dropped
+		a.dropped = 0
+		a.mu.Unlock()
+		if dropped > 0 {
+			a.logger.Warn().Int("records", dropped).Msg("audit sink buffer full, records not shipped")
+		}
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), auditSuppressKey{}, true), auditSinkTimeout)
+		if err := sink.WriteAudit(ctx, batch); err != nil {
+			a.logger.Warn().Err(err).Int("records", len(batch)).Msg("audit sink write failed")
+		}
+		cancel()
+		batch = nil
+	}
+	for {
+		select {
+		case rec, ok := <-ch:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, rec)
+			if len(batch) >= auditSinkBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// ConfigureAudit points the process's audit recorder at this backend:
+// records are stamped with its driver and instance ID, resources are
+// matched to containers through its ResourceRegistry, and they are
+// written to the configured log and shipped to sink (nil = no cloud
+// sink). A log that cannot be opened is logged and records are kept in
+// memory.
+func (s *BaseServer) ConfigureAudit(c AuditConfig, sink AuditSink) {
+	var log *AuditLog
+	if c.LogPath != "" {
+		l, err := OpenAuditLog(c.LogPath, c.MaxBytes, c.MaxFiles)
+		if err != nil {
+			s.Logger.Warn().Err(err).Str("log", c.LogPath).Msg("audit log unavailable, keeping audit records in memory")
+		} else {
+			log = l
+		}
+	}
+	var ch chan AuditRecord
+	if sink != nil {
+		ch = make(chan AuditRecord, auditSinkBuffer)
+	}
+
+	auditor.mu.Lock()
+	old, oldSink := auditor.log, auditor.sink
+	auditor.backend, auditor.instanceID = s.Desc.Driver, s.Desc.InstanceID
+	auditor.registry = s.Registry
+	auditor.log, auditor.sink = log, ch
+	auditor.logger = s.Logger
+	auditor.mu.Unlock()
+
+	if old != nil {
+		_ = old.Close()
+	}
+	if oldSink != nil {
+		close(oldSink)
+	}
+	if sink != nil {
+		go auditor.runSink(sink, ch)
+	}
+}
+
+// auditMiddleware gives each request an ID, echoed in X-Request-Id, and
+// carries it (with the client identity and the container the path
+// names) in the request context for AuditCloudCall. It must wrap the
+// handler that strips the API version prefix.
+func (s *BaseServer) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get("X-Request-Id"))
+		if id == "" || len(id) > 128 {
+			id = GenerateID()[:32]
+		}
+		w.Header().Set("X-Request-Id", id)
+		req := &auditRequest{
+			id:          id,
+			client:      auditClient(r),
+			userAgent:   r.UserAgent(),
+			method:      r.Method,
+			path:        r.URL.Path,
+			containerID: s.auditContainer(r.URL.Path),
+			mutating:    r.Method != http.MethodGet && r.Method != http.MethodHead,
+		}
+		auditor.begin(req)
+		defer auditor.end(req)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), auditRequestKey{}, req)))
+	})
+}
+
+// auditClient identifies who sent r: the verified TLS client
+// certificate's common name, else the remote address (unix for the
+// local socket).
+func auditClient(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && r.TLS.PeerCertificates[0].Subject.CommonName != "" {
+		return r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if host == "" || host == "@" {
+		return "unix"
+	}
+	return host
+}
+
+// auditContainer returns the ID of the container a Docker or libpod
+// API path names (/containers/{id}/..., /exec/{id}/...), or "".
+func (s *BaseServer) auditContainer(path string) string {
+	if loc := versionPrefix.FindStringIndex(path); loc != nil {
+		path = path[loc[1]-1:]
+	}
+	path = strings.TrimPrefix(path, "/libpod")
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segs) < 2 {
+		return ""
+	}
+	switch segs[0] {
+	case "containers":
+		switch segs[1] {
+		case "create", "json", "prune", "stats":
+			return ""
+		}
+		if id, ok := s.Store.ResolveContainerID(segs[1]); ok {
+			return id
+		}
+	case "exec":
+		if e, ok := s.Store.Execs.Get(segs[1]); ok {
+			return e.ContainerID
+		}
+	}
+	return ""
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// handleResourceHistory serves GET /internal/v1/resources/history?id=,
+// the audit records for a resource (any part of its ARN, ARM ID or
+// name), a container (ID, ID prefix or name) or a request ID, oldest
+// first.
+func (s *BaseServer) handleResourceHistory(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		WriteError(w, &api.InvalidParameterError{Message: "id is required"})
+		return
+	}
+	containerID, _ := s.Store.ResolveContainerID(id)
+	recs, err := auditor.records(func(rec AuditRecord) bool {
+		if rec.RequestID == id || strings.Contains(rec.Resource, id) || strings.HasPrefix(rec.ContainerID, id) {
+			return true
+		}
+		return containerID != "" && (rec.ContainerID == containerID || strings.Contains(rec.Resource, shortContainerID(containerID)))
+	})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if recs == nil {
+		recs = []AuditRecord{}
+	}
+	WriteJSON(w, http.StatusOK, recs)
+}
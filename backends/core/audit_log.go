@@ -0,0 +1,139 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// AuditLog is an append-only JSONL file of audit records with size-based
+// rotation: when a write would take the file past maxBytes it is renamed
+// to path.1 (path.1 to path.2, and so on) and a new file is started.
+// Only maxFiles rotated files are kept; the oldest is overwritten.
+type AuditLog struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+// OpenAuditLog opens (or creates) the log at path for appending.
+// maxBytes <= 0 disables rotation.
+func OpenAuditLog(path string, maxBytes int64, maxFiles int) (*AuditLog, error) {
+	l := &AuditLog{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *AuditLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	l.f, l.size = f, st.Size()
+	return nil
+}
+
+// Append writes rec as one line, rotating first if the line would not
+// fit.
+func (l *AuditLog) Append(rec AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return errors.New("audit log closed")
+	}
+	if l.maxBytes > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotate shifts path.N to path.N+1 for the kept files and starts a new
+// path. Called with l.mu held.
+func (l *AuditLog) rotate() error {
+	_ = l.f.Close()
+	l.f = nil
+	if l.maxFiles > 0 {
+		for i := l.maxFiles - 1; i >= 1; i-- {
+			_ = os.Rename(l.rotatedPath(i), l.rotatedPath(i+1))
+		}
+		if err := os.Rename(l.path, l.rotatedPath(1)); err != nil {
+			return fmt.Errorf("rotate audit log: %w", err)
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return fmt.Errorf("rotate audit log: %w", err)
+	}
+	return l.open()
+}
+
+func (l *AuditLog) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// Records returns the records in the rotated files and the current file
+// that match, oldest first. Lines that do not parse are skipped.
+func (l *AuditLog) Records(match func(AuditRecord) bool) ([]AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []AuditRecord
+	for i := l.maxFiles; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path = l.rotatedPath(i)
+		}
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read audit log: %w", err)
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			var rec AuditRecord
+			if json.Unmarshal(sc.Bytes(), &rec) == nil && match(rec) {
+				out = append(out, rec)
+			}
+		}
+		err = sc.Err()
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read audit log: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// Close closes the current file.
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sockerless/api"
+)
+
+// useTestAuditor swaps in a fresh process-wide recorder for the test.
+func useTestAuditor(t *testing.T) {
+	t.Helper()
+	prev := auditor
+	auditor = newAuditRecorder()
+	t.Cleanup(func() { auditor = prev })
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := OpenAuditLog(path, 300, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < 10; i++ {
+		if err := l.Append(AuditRecord{Service: "ECS", Operation: "RunTask", Resource: string(rune('a' + i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		st, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Size() > 300 {
+			t.Errorf("%s is %d bytes, over the 300 byte limit", p, st.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("kept more than 2 rotated files: %v", err)
+	}
+	recs, err := l.Records(func(AuditRecord) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) == 0 || recs[len(recs)-1].Resource != "j" {
+		t.Fatalf("records not oldest first or newest missing: %+v", recs)
+	}
+	for i := 1; i < len(recs); i++ {
+		if recs[i].Resource <= recs[i-1].Resource {
+			t.Fatalf("records out of order: %+v", recs)
+		}
+	}
+}
+
+func TestAuditAttribution(t *testing.T) {
+	useTestAuditor(t)
+	s := NewBaseServer(NewStore(), BackendDescriptor{ID: "t", Name: "t", Driver: "ecs-fargate", InstanceID: "i1"}, zerolog.Nop())
+	cid := "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"
+	s.Store.Containers.Put(cid, api.Container{ID: cid, Name: "/web"})
+	s.Store.ContainerNames.Put("/web", cid)
+	s.Registry.Register(ResourceEntry{ContainerID: cid, ResourceID: "arn:aws:ecs:us-east-1:1:task/c/t1"})
+	s.ConfigureAudit(AuditConfig{}, nil)
+
+	// A cloud call made with a background context while the start
+	// request is in flight is attributed to it through the registry.
+	handler := s.auditMiddleware(stripVersionPrefix(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AuditCloudCall(r.Context(), "ECS", "RegisterTaskDefinition", "sockerless-abcdef012345", time.Millisecond, nil)
+		AuditCloudCall(context.Background(), "ECS", "StopTask", "t1", time.Millisecond, errors.New("boom"))
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	req := httptest.NewRequest(http.MethodPost, "/v1.44/containers/web/start", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("X-Request-Id") != "req-1" {
+		t.Fatalf("X-Request-Id = %q", rec.Header().Get("X-Request-Id"))
+	}
+
+	// No request in flight: a reaper's delete carries no request.
+	AuditCloudCall(context.Background(), "ECS", "DeleteService", "sockerless-other", time.Millisecond, nil)
+
+	recs, _ := auditor.records(func(AuditRecord) bool { return true })
+	if len(recs) != 3 {
+		t.Fatalf("got %d records: %+v", len(recs), recs)
+	}
+	if r := recs[0]; r.RequestID != "req-1" || r.Inferred || r.ContainerID != cid || r.Backend != "ecs-fargate" || r.InstanceID != "i1" || r.Outcome != AuditOutcomeOK {
+		t.Errorf("context record: %+v", r)
+	}
+	if r := recs[1]; r.RequestID != "req-1" || !r.Inferred || r.ContainerID != cid || r.Outcome != AuditOutcomeError || r.Error != "boom" {
+		t.Errorf("inferred record: %+v", r)
+	}
+	if r := recs[2]; r.RequestID != "" || r.ContainerID != "" {
+		t.Errorf("background record: %+v", r)
+	}
+
+	// Sink writes are not audited.
+	AuditCloudCall(context.WithValue(context.Background(), auditSuppressKey{}, true), "CloudWatchLogs", "PutLogEvents", "", 0, nil)
+
+	hist := httptest.NewRecorder()
+	s.Mux.ServeHTTP(hist, httptest.NewRequest(http.MethodGet, "/internal/v1/resources/history?id=web", nil))
+	var got []AuditRecord
+	if err := json.Unmarshal(hist.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Operation != "RegisterTaskDefinition" || got[1].Operation != "StopTask" {
+		t.Errorf("history for the container: %+v", got)
+	}
+	hist = httptest.NewRecorder()
+	s.Mux.ServeHTTP(hist, httptest.NewRequest(http.MethodGet, "/internal/v1/resources/history", nil))
+	if hist.Code != http.StatusBadRequest {
+		t.Errorf("history without id: status %d", hist.Code)
+	}
+}
+
+func TestAuditSoleRequestInference(t *testing.T) {
+	useTestAuditor(t)
+	get := &auditRequest{id: "get", mutating: false}
+	post := &auditRequest{id: "post", mutating: true}
+	auditor.begin(get)
+	auditor.begin(post)
+	AuditCloudCall(context.Background(), "Lambda", "CreateFunction", "fn", 0, nil)
+	other := &auditRequest{id: "other", mutating: true}
+	auditor.begin(other)
+	AuditCloudCall(context.Background(), "Lambda", "DeleteFunction", "fn", 0, nil)
+
+	recs, _ := auditor.records(func(AuditRecord) bool { return true })
+	if len(recs) != 2 || recs[0].RequestID != "post" || !recs[0].Inferred {
+		t.Fatalf("sole mutating request not inferred: %+v", recs)
+	}
+	if recs[1].RequestID != "" {
+		t.Errorf("ambiguous call attributed to %q", recs[1].RequestID)
+	}
+}
+
+func TestIsCloudReadOperation(t *testing.T) {
+	for op, read := range map[string]bool{
+		"DescribeTasks": true, "GetFunction": true, "ListJobs": true, "BatchGetImage": true, "Get": true,
+		"RunTask": false, "CreateService": false, "DeleteFunction": false, "Invoke": false,
+		"BatchDeleteImage": false, "Getaway": false, "PutLogEvents": false,
+	} {
+		if IsCloudReadOperation(op) != read {
+			t.Errorf("IsCloudReadOperation(%q) = %v, want %v", op, !read, read)
+		}
+	}
+}
@@ -194,6 +194,16 @@ func (s *BaseServer) ContainerList(opts api.ContainerListOptions) ([]*api.Contai
 				}
 			}
 		}
+		// Include compose unit members (not surfaced by the cloud resource)
+		listed := make(map[string]bool, len(containers))
+		for _, c := range containers {
+			listed[c.ID] = true
+		}
+		for _, m := range s.Store.Pods.UnitMembers() {
+			if !listed[m.Container.ID] {
+				containers = append(containers, s.composeMemberView(context.Background(), m))
+			}
+		}
 	} else {
 		containers = s.Store.Containers.List()
 	}
@@ -874,59 +884,6 @@ func (s *BaseServer) ContainerPrune(filters map[string][]string) (*api.Container
 	}, nil
 }
 
-// ContainerStats returns resource usage stats for a container.
-func (s *BaseServer) ContainerStats(ref string, stream bool) (io.ReadCloser, error) {
-	c, ok := s.ResolveContainerAuto(context.Background(), ref)
-	if !ok {
-		return nil, &api.NotFoundError{Resource: "container", ID: ref}
-	}
-	id := c.ID
-	memLimit := int64(1073741824)
-	if c.HostConfig.Memory > 0 {
-		memLimit = c.HostConfig.Memory
-	}
-
-	if !stream || !c.State.Running {
-		now := time.Now().UTC()
-		entry := s.buildStatsEntry(id, now, "0001-01-01T00:00:00Z", memLimit)
-		data, _ := json.Marshal(entry)
-		return io.NopCloser(bytes.NewReader(data)), nil
-	}
-
-	pr, pw := io.Pipe()
-	go func() {
-		enc := json.NewEncoder(pw)
-		preread := "0001-01-01T00:00:00Z"
-		for {
-			now := time.Now().UTC()
-			entry := s.buildStatsEntry(id, now, preread, memLimit)
-			if err := enc.Encode(entry); err != nil {
-				pw.CloseWithError(err)
-				return
-			}
-			preread = now.Format(time.RFC3339Nano)
-
-			time.Sleep(1 * time.Second)
-			// Check if container has stopped
-			if ch, ok := s.Store.WaitChs.Load(id); ok {
-				select {
-				case <-ch.(chan struct{}):
-					_ = pw.Close()
-					return
-				default:
-					// Still running — continue streaming
-				}
-			} else if cur, ok := s.ResolveContainerAuto(context.Background(), id); ok && !cur.State.Running {
-				// No WaitCh but CloudState confirms stopped
-				_ = pw.Close()
-				return
-			}
-			// If neither WaitCh nor CloudState says stopped, keep streaming
-		}
-	}()
-	return pr, nil
-}
-
 // ContainerRename renames a container.
 func (s *BaseServer) ContainerRename(ref string, newName string) error {
 	c, ok := s.ResolveContainerAuto(context.Background(), ref)
@@ -1332,103 +1289,6 @@ func (s *BaseServer) ImageInspect(name string) (*api.Image, error) {
 	return &img, nil
 }
 
-// ImageLoad loads an image from a tar archive.
-// Preserves layer tarballs in LayerContent store for subsequent push.
-func (s *BaseServer) ImageLoad(r io.Reader) (io.ReadCloser, error) {
-	result := parseImageTarFull(r)
-
-	var repoTags []string
-	var imgConfig *api.ContainerConfig
-	if result != nil {
-		repoTags = result.RepoTags
-		imgConfig = result.Config
-	}
-
-	if len(repoTags) == 0 {
-		repoTags = []string{"loaded:latest"}
-	}
-
-	// Deduplicate: remove existing image with same tags
-	for _, tag := range repoTags {
-		if existing, ok := s.Store.ResolveImage(tag); ok {
-			s.Store.Images.Delete(existing.ID)
-		}
-	}
-
-	id := "sha256:" + GenerateID()
-	nowStr := time.Now().UTC().Format(time.RFC3339Nano)
-
-	// Compute real layer digests and sizes from preserved content.
-	var layers []string
-	var manifestLayers []ManifestLayerEntry
-	var totalSize int64
-	if result != nil && len(result.Layers) > 0 {
-		for layerPath, content := range result.Layers {
-			digest := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
-			layers = append(layers, digest)
-			totalSize += int64(len(content))
-			// Store layer content for subsequent OCI push
-			s.Store.LayerContent.Store(digest, content)
-			manifestLayers = append(manifestLayers, ManifestLayerEntry{
-				Digest:    digest,
-				Size:      int64(len(content)),
-				MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip",
-			})
-			_ = layerPath
-		}
-	}
-	if len(layers) == 0 {
-		layers = []string{"sha256:" + GenerateID()}
-	}
-
-	img := api.Image{
-		ID:           id,
-		RepoTags:     repoTags,
-		Created:      nowStr,
-		Size:         totalSize,
-		VirtualSize:  totalSize,
-		Architecture: "amd64",
-		Os:           "linux",
-		Config: api.ContainerConfig{
-			Labels: make(map[string]string),
-		},
-		RootFS: api.RootFS{
-			Type:   "layers",
-			Layers: layers,
-		},
-		GraphDriver: api.GraphDriverData{
-			Name: "overlay2",
-			Data: map[string]string{
-				"MergedDir": "/var/lib/sockerless/overlay2/" + id[7:19] + "/merged",
-				"UpperDir":  "/var/lib/sockerless/overlay2/" + id[7:19] + "/diff",
-				"WorkDir":   "/var/lib/sockerless/overlay2/" + id[7:19] + "/work",
-			},
-		},
-		Metadata: api.ImageMetadata{LastTagTime: nowStr},
-	}
-
-	if imgConfig != nil {
-		mergeImageConfig(&img.Config, imgConfig)
-	}
-
-	for _, tag := range repoTags {
-		StoreImageWithAliases(s.Store, tag, img)
-	}
-	s.Store.Images.Put(id, img)
-	if len(manifestLayers) > 0 {
-		s.Store.ImageManifestLayers.Store(id, manifestLayers)
-	}
-
-	displayTag := repoTags[0]
-	s.emitEvent("image", "load", id, map[string]string{"name": displayTag})
-
-	var buf bytes.Buffer
-	json.NewEncoder(&buf).Encode(map[string]string{
-		"stream": fmt.Sprintf("Loaded image: %s\n", displayTag),
-	})
-	return io.NopCloser(&buf), nil
-}
-
 // ImageTag tags an image.
 func (s *BaseServer) ImageTag(source string, repo string, tag string) error {
 	if repo == "" {
@@ -1569,11 +1429,17 @@ func (s *BaseServer) ImageRemove(name string, force bool, prune bool) ([]*api.Im
 	}
 
 	result = append(result, &api.ImageDeleteResponse{Untagged: resolvedTag})
-	s.Store.Images.Delete(resolvedTag)
-	s.emitEvent("image", "untag", img.ID, map[string]string{"name": resolvedTag})
-	remaining := make([]string, 0, len(img.RepoTags)-1)
+	s.untagImage(img, resolvedTag)
+	return result, nil
+}
+
+// untagImage removes tag from img, which keeps its other tags.
+func (s *BaseServer) untagImage(img api.Image, tag string) {
+	s.Store.Images.Delete(tag)
+	s.emitEvent("image", "untag", img.ID, map[string]string{"name": tag})
+	remaining := make([]string, 0, len(img.RepoTags))
 	for _, t := range img.RepoTags {
-		if t != resolvedTag {
+		if t != tag {
 			remaining = append(remaining, t)
 		}
 	}
@@ -1592,7 +1458,6 @@ func (s *BaseServer) ImageRemove(name string, force bool, prune bool) ([]*api.Im
 			s.Store.Images.Put(e.Key, img)
 		}
 	}
-	return result, nil
 }
 
 // resolveTagForRemoval matches the user-supplied ref against the image's
@@ -15,25 +15,27 @@ import (
 	"github.com/sockerless/api"
 )
 
-// ContainerResize is a no-op on cloud backends — TTY size events
-// (`SIGWINCH`) don't propagate through Cloud Run / Fargate / ACA /
-// Lambda to the running container, so accepting the resize and
-// silently dropping it would be a fake. The local docker backend
-// overrides this with the real `dockerd.ContainerResize` call.
+// ContainerResize is the fallback for backends with no in-container
+// path to the main process's PTY — the cloud platforms don't propagate
+// window-size events themselves, so accepting the resize and silently
+// dropping it would be a fake. Reverse-agent backends override with
+// RunContainerResizeViaAgent; the local docker backend with the real
+// `dockerd.ContainerResize` call.
 func (s *BaseServer) ContainerResize(id string, h, w int) error {
-	if _, ok := s.ResolveContainerIDAuto(context.Background(), id); !ok {
-		return &api.NotFoundError{Resource: "container", ID: id}
+	if _, err := s.ResizeTargetContainer(id); err != nil {
+		return err
 	}
-	return &api.NotImplementedError{Message: "TTY resize is not supported on cloud backends — clouds don't propagate SIGWINCH to managed containers"}
+	return &api.NotImplementedError{Message: "TTY resize needs an in-container agent holding the main process's PTY; this backend has none"}
 }
 
-// ExecResize — same shape as ContainerResize. Local docker backend
-// overrides with `dockerd.ContainerExecResize`.
+// ExecResize — same shape as ContainerResize. Reverse-agent backends
+// override with RunExecResizeViaAgent, ECS with the SSM session's size
+// message, and the local docker backend with `dockerd.ContainerExecResize`.
 func (s *BaseServer) ExecResize(id string, h, w int) error {
-	if _, ok := s.Store.Execs.Get(id); !ok {
-		return &api.NotFoundError{Resource: "exec instance", ID: id}
+	if _, err := s.ResizeTargetExec(id); err != nil {
+		return err
 	}
-	return &api.NotImplementedError{Message: "TTY resize is not supported on cloud backends — clouds don't propagate SIGWINCH to managed exec sessions"}
+	return &api.NotImplementedError{Message: "TTY resize needs an in-container agent holding the exec session's PTY; this backend has none"}
 }
 
 // ContainerPutArchive extracts a tar archive to the container filesystem.
@@ -390,6 +392,13 @@ func (s *BaseServer) ImageBuild(opts api.ImageBuildOptions, context io.Reader) (
 
 // ImagePush pushes an image to a registry via OCI protocol, or reports an error.
 func (s *BaseServer) ImagePush(name string, tag string, auth string) (io.ReadCloser, error) {
+	return s.pushImage(name, tag, auth, "")
+}
+
+// pushImage is ImagePush with an optional registry endpoint override,
+// which ImageManager.Push supplies for operator-configured cloud
+// registry endpoints.
+func (s *BaseServer) pushImage(name, tag, auth, endpoint string) (io.ReadCloser, error) {
 	img, ok := s.Store.ResolveImage(name)
 	if !ok {
 		return nil, &api.NotFoundError{Resource: "image", ID: name}
@@ -414,16 +423,22 @@ func (s *BaseServer) ImagePush(name string, tag string, auth string) (io.ReadClo
 			if v, ok := s.Store.ImageManifestLayers.Load(img.ID); ok {
 				manifestLayers = v.([]ManifestLayerEntry)
 			}
+			var configBlob []byte
+			if v, ok := s.Store.ImageConfigBlobs.Load(img.ID); ok {
+				configBlob = v.([]byte)
+			}
 			opts := OCIPushOptions{
 				Registry:       registry,
 				Repository:     repo,
 				Tag:            tag,
 				AuthToken:      auth,
+				Endpoint:       endpoint,
 				ImageLayers:    img.RootFS.Layers,
 				ManifestLayers: manifestLayers,
 				Architecture:   img.Architecture,
 				OS:             img.Os,
 				Config:         cfgBlob,
+				ConfigBlob:     configBlob,
 				LayerContent: func(digest string) ([]byte, bool) {
 					if v, ok := s.Store.LayerContent.Load(digest); ok {
 						return v.([]byte), true
@@ -454,16 +469,6 @@ func (s *BaseServer) ImagePush(name string, tag string, auth string) (io.ReadClo
 	return pr, nil
 }
 
-// ImageSave is not implemented on cloud backends. Cloud registries
-// don't serve a single multi-blob tarball; reconstructing one would
-// require pulling the manifest plus every layer blob and retaring
-// locally. Operators should use `crane export` or `skopeo copy`
-// against the registry directly. Local docker backend overrides this
-// with `dockerd.ImageSave`.
-func (s *BaseServer) ImageSave(names []string) (io.ReadCloser, error) {
-	return nil, &api.NotImplementedError{Message: "docker image save is not supported against cloud registries — use `crane export` or `skopeo copy` to read images directly from ECR / Artifact Registry / ACR"}
-}
-
 // ImageSearch is not implemented on cloud backends. Docker Hub's
 // search API isn't reachable through ECR / Artifact Registry / ACR;
 // cloud registries have no equivalent free-text search. Operators
@@ -510,40 +515,8 @@ func (s *BaseServer) ContainerCommit(req *api.ContainerCommitRequest) (*api.Cont
 	}
 
 	// Apply changes (Dockerfile instructions)
-	for _, change := range req.Changes {
-		change = strings.TrimSpace(change)
-		if change == "" {
-			continue
-		}
-		parts := strings.SplitN(change, " ", 2)
-		if len(parts) < 2 {
-			continue
-		}
-		instruction, value := strings.ToUpper(parts[0]), parts[1]
-		switch instruction {
-		case "CMD":
-			imgConfig.Cmd = parseJSONOrShell(value)
-		case "ENTRYPOINT":
-			imgConfig.Entrypoint = parseJSONOrShell(value)
-		case "ENV":
-			k, v, _ := strings.Cut(value, "=")
-			imgConfig.Env = append(imgConfig.Env, k+"="+v)
-		case "WORKDIR":
-			imgConfig.WorkingDir = value
-		case "USER":
-			imgConfig.User = value
-		case "LABEL":
-			k, v, _ := strings.Cut(value, "=")
-			if imgConfig.Labels == nil {
-				imgConfig.Labels = map[string]string{}
-			}
-			imgConfig.Labels[k] = strings.Trim(v, "\"")
-		case "EXPOSE":
-			if imgConfig.ExposedPorts == nil {
-				imgConfig.ExposedPorts = map[string]struct{}{}
-			}
-			imgConfig.ExposedPorts[value+"/tcp"] = struct{}{}
-		}
+	if err := applyImageChanges(&imgConfig, req.Changes); err != nil {
+		return nil, err
 	}
 
 	// Generate image ID
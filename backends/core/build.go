@@ -503,7 +503,9 @@ func (s *BaseServer) handleImageBuild(w http.ResponseWriter, r *http.Request) {
 		Backend: s.Desc.Driver,
 		Logger:  s.Logger,
 	}
+	start := time.Now()
 	rc, err := s.Typed.Build.Build(dctx, opts, r.Body)
+	rc, err = s.Prom.timeBuild(start, rc, err)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -0,0 +1,899 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Cassettes capture the HTTP exchanges between a backend and its cloud
+// so a session can be replayed offline as a regression test. A
+// cassette is a JSONL file with one CassetteInteraction per line in the
+// order the requests were sent. Credentials are scrubbed before a line
+// is written; see specs/RECORD_REPLAY.md.
+
+// CassetteRequest is the recorded half of an exchange the backend sent.
+type CassetteRequest struct {
+	Method       string      `json:"method"`
+	Host         string      `json:"host"`
+	Path         string      `json:"path"`
+	Query        string      `json:"query,omitempty"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"` // "base64" for non-UTF-8 bodies
+}
+
+// CassetteResponse is the cloud's answer to a CassetteRequest.
+type CassetteResponse struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// CassetteInteraction is one recorded request/response pair.
+type CassetteInteraction struct {
+	Seq        int              `json:"seq"`
+	RecordedAt time.Time        `json:"recordedAt"`
+	DurationMs int64            `json:"durationMs"`
+	Request    CassetteRequest  `json:"request"`
+	Response   CassetteResponse `json:"response"`
+}
+
+// --- Scrubbing ---
+
+const cassetteRedacted = "REDACTED"
+
+// cassetteSecretHeaders carry credentials and are never written.
+var cassetteSecretHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Amz-Security-Token",
+	"X-Api-Key",
+	"X-Functions-Key",
+	"X-Goog-Iam-Authorization-Token",
+	"X-Ms-Authorization-Auxiliary",
+}
+
+// cassetteSecretParams are signature and key query parameters (SigV4
+// presigned URLs, Azure SAS tokens, Functions keys, API keys).
+var cassetteSecretParams = map[string]bool{
+	"x-amz-signature":      true,
+	"x-amz-credential":     true,
+	"x-amz-security-token": true,
+	"sig":                  true,
+	"code":                 true,
+	"key":                  true,
+}
+
+// cassetteSecretFragments mark a JSON or form field as a credential
+// when its lowercased name contains one of them.
+var cassetteSecretFragments = []string{
+	"password", "secret", "token", "accesskey", "privatekey",
+	"connectionstring", "sastoken", "apikey", "authorization",
+}
+
+// cassettePagingFields contain "token" but are pagination or
+// idempotency cursors, not credentials.
+var cassettePagingFields = map[string]bool{
+	"nexttoken":          true,
+	"nextpagetoken":      true,
+	"pagetoken":          true,
+	"continuationtoken":  true,
+	"clienttoken":        true,
+	"clientrequesttoken": true,
+	"idempotencytoken":   true,
+}
+
+// cassetteSecretPatterns catch credentials wherever they appear in a
+// body: AWS access key IDs and JWTs (OAuth access and ID tokens).
+var cassetteSecretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`),
+}
+
+func cassetteSecretField(name string) bool {
+	n := strings.ToLower(name)
+	if cassettePagingFields[n] {
+		return false
+	}
+	for _, f := range cassetteSecretFragments {
+		if strings.Contains(n, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func scrubCassetteHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range cassetteSecretHeaders {
+		if _, ok := out[k]; ok {
+			out[k] = []string{cassetteRedacted}
+		}
+	}
+	return out
+}
+
+func scrubCassetteQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	changed := false
+	for k := range q {
+		if cassetteSecretParams[strings.ToLower(k)] || cassetteSecretField(k) {
+			q[k] = []string{cassetteRedacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return raw
+	}
+	return q.Encode()
+}
+
+// scrubCassetteBody redacts credential fields of JSON and form bodies
+// and credential-shaped strings anywhere else.
+func scrubCassetteBody(contentType string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	if v, ok := decodeCassetteJSON(body); ok {
+		if scrubCassetteJSON(v) {
+			if b, err := json.Marshal(v); err == nil {
+				body = b
+			}
+		}
+	} else if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if q, err := url.ParseQuery(string(body)); err == nil {
+			changed := false
+			for k := range q {
+				if cassetteSecretField(k) {
+					q[k] = []string{cassetteRedacted}
+					changed = true
+				}
+			}
+			if changed {
+				body = []byte(q.Encode())
+			}
+		}
+	}
+	for _, re := range cassetteSecretPatterns {
+		body = re.ReplaceAll(body, []byte(cassetteRedacted))
+	}
+	return body
+}
+
+func decodeCassetteJSON(body []byte) (any, bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil, false
+	}
+	d := json.NewDecoder(bytes.NewReader(trimmed))
+	d.UseNumber()
+	var v any
+	if d.Decode(&v) != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// cassetteEnvName normalises an environment variable name so
+// API_KEY or AWS_SECRET_ACCESS_KEY match the secret fragments.
+var cassetteEnvName = strings.NewReplacer("_", "", "-", "")
+
+// scrubCassetteEnvPair redacts the value of a {"name": …, "value": …}
+// pair whose name is a credential — container environment in ECS task
+// definitions, Cloud Run and ACA, where runner registration tokens and
+// CI secrets travel — and reports whether it did.
+func scrubCassetteEnvPair(m map[string]any) bool {
+	var name, value string
+	for k := range m {
+		switch strings.ToLower(k) {
+		case "name":
+			name = k
+		case "value":
+			value = k
+		}
+	}
+	if name == "" || value == "" {
+		return false
+	}
+	n, _ := m[name].(string)
+	if _, isString := m[value].(string); !isString || !cassetteSecretField(cassetteEnvName.Replace(n)) {
+		return false
+	}
+	m[value] = cassetteRedacted
+	return true
+}
+
+// scrubCassetteJSON redacts string values of credential fields in
+// place and reports whether anything changed.
+func scrubCassetteJSON(v any) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]any:
+		if scrubCassetteEnvPair(t) {
+			changed = true
+		}
+		for k, child := range t {
+			if _, isString := child.(string); isString && cassetteSecretField(k) {
+				t[k] = cassetteRedacted
+				changed = true
+				continue
+			}
+			if scrubCassetteJSON(child) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, child := range t {
+			if scrubCassetteJSON(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func encodeCassetteBody(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+func decodeCassetteBody(body, encoding string) []byte {
+	if encoding == "base64" {
+		b, err := base64.StdEncoding.DecodeString(body)
+		if err == nil {
+			return b
+		}
+	}
+	return []byte(body)
+}
+
+func newCassetteRequest(r *http.Request, body []byte) CassetteRequest {
+	cr := CassetteRequest{
+		Method: r.Method,
+		Host:   r.Host,
+		Path:   r.URL.Path,
+		Query:  scrubCassetteQuery(r.URL.RawQuery),
+		Header: scrubCassetteHeader(r.Header),
+	}
+	if cr.Host == "" {
+		cr.Host = r.URL.Host
+	}
+	cr.Body, cr.BodyEncoding = encodeCassetteBody(scrubCassetteBody(r.Header.Get("Content-Type"), body))
+	return cr
+}
+
+// --- Matching ---
+
+// cassetteIgnoredFields are request fields that differ on every run
+// without changing what is asked (idempotency tokens, request IDs).
+var cassetteIgnoredFields = map[string]bool{
+	"clienttoken":        true,
+	"clientrequesttoken": true,
+	"idempotencytoken":   true,
+	"requestid":          true,
+}
+
+// cassetteVolatile normalises identifiers sockerless generates per
+// run — container IDs, their 12-character short form, 16-character
+// content hashes (overlay image tags, endpoint IDs), UUIDs and
+// timestamps — so a replayed session matches its recording although
+// it creates containers with different IDs.
+var cassetteVolatile = []struct {
+	re   *regexp.Regexp
+	with string
+}{
+	{regexp.MustCompile(`[0-9a-f]{64}`), "{id}"},
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "{uuid}"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})`), "{time}"},
+	{regexp.MustCompile(`\b[0-9a-f]{16}\b`), "{id16}"},
+	{regexp.MustCompile(`\b[0-9a-f]{12}\b`), "{id12}"},
+}
+
+func normaliseCassetteText(s string) string {
+	for _, v := range cassetteVolatile {
+		s = v.re.ReplaceAllString(s, v.with)
+	}
+	return s
+}
+
+func dropCassetteIgnored(v any) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if cassetteIgnoredFields[strings.ToLower(k)] {
+				delete(t, k)
+				continue
+			}
+			dropCassetteIgnored(child)
+		}
+	case []any:
+		for _, child := range t {
+			dropCassetteIgnored(child)
+		}
+	}
+}
+
+// normaliseCassetteBody canonicalises a scrubbed request body: JSON is
+// re-encoded with sorted keys and form bodies with sorted parameters,
+// both without the ignored fields.
+func normaliseCassetteBody(contentType string, body []byte) string {
+	if v, ok := decodeCassetteJSON(body); ok {
+		dropCassetteIgnored(v)
+		if b, err := json.Marshal(v); err == nil {
+			return normaliseCassetteText(string(b))
+		}
+	}
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if q, err := url.ParseQuery(string(body)); err == nil {
+			for k := range q {
+				if cassetteIgnoredFields[strings.ToLower(k)] {
+					delete(q, k)
+				}
+			}
+			return normaliseCassetteText(q.Encode())
+		}
+	}
+	return normaliseCassetteText(string(bytes.TrimSpace(body)))
+}
+
+// cassetteKey is what a replayed request is matched on: method, path,
+// query and normalised body. Hosts and headers are ignored so a
+// recording made against the real cloud replays against a local
+// endpoint. Binary bodies (build contexts, uploaded files) embed
+// timestamps and are matched on method, path and query alone.
+func (cr CassetteRequest) cassetteKey() string {
+	path := cr.Path
+	if p, err := url.PathUnescape(path); err == nil {
+		path = p
+	}
+	query := ""
+	if q, err := url.ParseQuery(cr.Query); err == nil {
+		for k := range q {
+			lk := strings.ToLower(k)
+			if strings.HasPrefix(lk, "x-amz-") || cassetteSecretParams[lk] {
+				delete(q, k)
+			}
+		}
+		query = q.Encode()
+	}
+	body := "{binary}"
+	if cr.BodyEncoding != "base64" {
+		body = normaliseCassetteBody(cr.Header.Get("Content-Type"), []byte(cr.Body))
+	}
+	return cr.Method + " " + normaliseCassetteText(path) + "?" + normaliseCassetteText(query) + "\n" + body
+}
+
+// --- Recording ---
+
+// CassetteRecorder is an http.RoundTripper that forwards to Next and
+// appends every completed exchange to a cassette file. Each line is
+// written as soon as its response is read, so a cassette survives a
+// backend that is killed rather than shut down. Upgraded connections
+// (exec and attach streams) pass through unrecorded.
+type CassetteRecorder struct {
+	Next http.RoundTripper
+
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	seq int
+}
+
+// NewCassetteRecorder creates (or truncates) the cassette at path and
+// records the exchanges sent through next, http.DefaultTransport when
+// nil.
+func NewCassetteRecorder(path string, next http.RoundTripper) (*CassetteRecorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open cassette: %w", err)
+	}
+	return &CassetteRecorder{Next: next, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *CassetteRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Upgrade") != "" {
+		return c.Next.RoundTrip(req)
+	}
+	var reqBody []byte
+	out := req
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		out = req.Clone(req.Context())
+		out.Body = io.NopCloser(bytes.NewReader(b))
+		out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+	}
+
+	start := time.Now()
+	resp, err := c.Next.RoundTrip(out)
+	if err != nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		return resp, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	ia := CassetteInteraction{
+		RecordedAt: start.UTC(),
+		DurationMs: time.Since(start).Milliseconds(),
+		Request:    newCassetteRequest(req, reqBody),
+		Response: CassetteResponse{
+			Status: resp.StatusCode,
+			Header: scrubCassetteHeader(resp.Header),
+		},
+	}
+	ia.Response.Body, ia.Response.BodyEncoding = encodeCassetteBody(scrubCassetteBody(resp.Header.Get("Content-Type"), respBody))
+	if err := c.append(ia); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *CassetteRecorder) append(ia CassetteInteraction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.f == nil {
+		return errors.New("cassette recorder is closed")
+	}
+	c.seq++
+	ia.Seq = c.seq
+	line, err := json.Marshal(ia)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := c.w.Write(line); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// Recorded returns the number of interactions written so far.
+func (c *CassetteRecorder) Recorded() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seq
+}
+
+// Close flushes and closes the cassette file.
+func (c *CassetteRecorder) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.f == nil {
+		return nil
+	}
+	err := c.w.Flush()
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+	c.f = nil
+	return err
+}
+
+// NewCassetteProxy returns a reverse proxy to upstream that records
+// through rec. Point a backend's SOCKERLESS_ENDPOINT_URL at it.
+func NewCassetteProxy(upstream *url.URL, rec *CassetteRecorder) http.Handler {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+		},
+		Transport: rec,
+	}
+}
+
+var (
+	processCassetteOnce sync.Once
+	processCassette     *CassetteRecorder
+	processCassetteErr  error
+)
+
+// CassetteHTTPClient returns an HTTP client recording to the cassette
+// named by SOCKERLESS_RECORD_CASSETTE, or nil when it is unset. Cloud
+// SDK clients built on it are recorded after request signing, which
+// is how sessions against the real cloud are captured: a recording
+// proxy in front of SOCKERLESS_ENDPOINT_URL would break SigV4 and
+// bearer-token audiences. Every client of the process shares the one
+// cassette.
+func CassetteHTTPClient() (*http.Client, error) {
+	path := strings.TrimSpace(os.Getenv("SOCKERLESS_RECORD_CASSETTE"))
+	if path == "" {
+		return nil, nil
+	}
+	processCassetteOnce.Do(func() {
+		processCassette, processCassetteErr = NewCassetteRecorder(path, nil)
+	})
+	if processCassetteErr != nil {
+		return nil, processCassetteErr
+	}
+	return &http.Client{Transport: processCassette}, nil
+}
+
+// --- Replay ---
+
+// LoadCassette reads every interaction of the cassette at path.
+func LoadCassette(path string) ([]CassetteInteraction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []CassetteInteraction
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var ia CassetteInteraction
+		if err := json.Unmarshal(sc.Bytes(), &ia); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		out = append(out, ia)
+	}
+	return out, sc.Err()
+}
+
+// CassetteMiss is a request the replayer could not serve, or a
+// recorded interaction it never served.
+type CassetteMiss struct {
+	Seq    int    `json:"seq,omitempty"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   string `json:"body,omitempty"`
+}
+
+func (m CassetteMiss) String() string {
+	s := m.Method + " " + m.Path
+	if m.Seq > 0 {
+		s = "#" + strconv.Itoa(m.Seq) + " " + s
+	}
+	if m.Body != "" {
+		b := m.Body
+		if len(b) > 200 {
+			b = b[:200] + "…"
+		}
+		s += " " + b
+	}
+	return s
+}
+
+// CassetteReport summarises a replay.
+type CassetteReport struct {
+	Interactions int            `json:"interactions"`
+	Served       int            `json:"served"`
+	Repeated     int            `json:"repeated"` // polls answered with an already-served interaction
+	Unmatched    []CassetteMiss `json:"unmatched"`
+	Unused       []CassetteMiss `json:"unused"`
+}
+
+// Err is non-nil when a request had no recorded interaction: the
+// backend under test asked the cloud something the recorded session
+// did not.
+func (r CassetteReport) Err() error {
+	if len(r.Unmatched) == 0 {
+		return nil
+	}
+	return fmt.Errorf("cassette replay: %d unmatched request(s), first: %s", len(r.Unmatched), r.Unmatched[0])
+}
+
+func (r CassetteReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cassette replay: %d interactions, %d served, %d repeated, %d unmatched, %d unused",
+		r.Interactions, r.Served, r.Repeated, len(r.Unmatched), len(r.Unused))
+	for _, m := range r.Unmatched {
+		fmt.Fprintf(&b, "\n  unmatched: %s", m)
+	}
+	for _, m := range r.Unused {
+		fmt.Fprintf(&b, "\n  unused:    %s", m)
+	}
+	return b.String()
+}
+
+// CassetteReplayer serves recorded responses. A request is answered
+// by the first unserved interaction with the same match key, in
+// recorded order; once all of them are served the last is repeated,
+// so a backend that polls a status more often than the recording did
+// keeps seeing the final state. Requests without a recorded
+// interaction get 599 and are reported as unmatched.
+type CassetteReplayer struct {
+	mu           sync.Mutex
+	interactions []CassetteInteraction
+	queues       map[string][]int
+	served       []int
+	repeated     int
+	unmatched    []CassetteMiss
+}
+
+// NewCassetteReplayer indexes interactions for replay.
+func NewCassetteReplayer(interactions []CassetteInteraction) *CassetteReplayer {
+	r := &CassetteReplayer{
+		interactions: interactions,
+		queues:       make(map[string][]int),
+		served:       make([]int, len(interactions)),
+	}
+	for i, ia := range interactions {
+		k := ia.Request.cassetteKey()
+		r.queues[k] = append(r.queues[k], i)
+	}
+	return r
+}
+
+// ServeHTTP implements http.Handler.
+func (r *CassetteReplayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	cr := newCassetteRequest(req, body)
+	key := cr.cassetteKey()
+
+	r.mu.Lock()
+	idx := -1
+	queue := r.queues[key]
+	for _, i := range queue {
+		if r.served[i] == 0 {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 && len(queue) > 0 {
+		idx = queue[len(queue)-1]
+		r.repeated++
+	}
+	if idx < 0 {
+		r.unmatched = append(r.unmatched, CassetteMiss{Method: cr.Method, Path: cr.Path, Body: cr.Body})
+		r.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(599)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"message": fmt.Sprintf("cassette: no recorded interaction for %s %s", cr.Method, cr.Path),
+		})
+		return
+	}
+	r.served[idx]++
+	resp := r.interactions[idx].Response
+	r.mu.Unlock()
+
+	for k, vs := range resp.Header {
+		switch http.CanonicalHeaderKey(k) {
+		case "Content-Length", "Transfer-Encoding", "Connection":
+			continue
+		}
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(decodeCassetteBody(resp.Body, resp.BodyEncoding))
+}
+
+// Report returns what was served, repeated, unmatched and unused so far.
+func (r *CassetteReplayer) Report() CassetteReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := CassetteReport{
+		Interactions: len(r.interactions),
+		Repeated:     r.repeated,
+		Unmatched:    append([]CassetteMiss(nil), r.unmatched...),
+	}
+	for i, n := range r.served {
+		if n > 0 {
+			rep.Served++
+			continue
+		}
+		req := r.interactions[i].Request
+		rep.Unused = append(rep.Unused, CassetteMiss{Seq: r.interactions[i].Seq, Method: req.Method, Path: req.Path, Body: req.Body})
+	}
+	sort.Slice(rep.Unused, func(i, j int) bool { return rep.Unused[i].Seq < rep.Unused[j].Seq })
+	return rep
+}
+
+// --- Integration test harness ---
+
+// CassetteHarness sits between an integration test's backend and its
+// cloud endpoint. In record mode it proxies to the real endpoint and
+// writes a cassette; in replay mode it serves one and no cloud or
+// simulator is needed.
+type CassetteHarness struct {
+	// URL is what the backend's SOCKERLESS_ENDPOINT_URL is set to.
+	URL  string
+	Mode string // "record" or "replay"
+	Path string
+
+	srv    *http.Server
+	bridge net.Listener
+	rec    *CassetteRecorder
+	rep    *CassetteReplayer
+}
+
+// CassetteHarnessFromEnv starts the harness the integration test
+// environment asks for, or returns nil when it asks for none:
+//
+//   - SOCKERLESS_TEST_TARGET=replay replays SOCKERLESS_TEST_CASSETTE;
+//     upstream is ignored.
+//   - SOCKERLESS_TEST_RECORD=<path> with the sim or cloud target
+//     records the session against upstream to <path>.
+func CassetteHarnessFromEnv(target, upstream string) (*CassetteHarness, error) {
+	if target == "replay" {
+		path := strings.TrimSpace(os.Getenv("SOCKERLESS_TEST_CASSETTE"))
+		if path == "" {
+			return nil, errors.New("SOCKERLESS_TEST_TARGET=replay needs SOCKERLESS_TEST_CASSETTE")
+		}
+		return StartCassetteReplay(path)
+	}
+	if path := strings.TrimSpace(os.Getenv("SOCKERLESS_TEST_RECORD")); path != "" {
+		return StartCassetteRecord(path, upstream)
+	}
+	return nil, nil
+}
+
+// StartCassetteReplay serves the cassette at path on a local port.
+func StartCassetteReplay(path string) (*CassetteHarness, error) {
+	interactions, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	h := &CassetteHarness{Mode: "replay", Path: path, rep: NewCassetteReplayer(interactions)}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	h.serve(ln, h.rep)
+	return h, nil
+}
+
+// StartCassetteRecord proxies a local port to upstream, recording to
+// path. When upstream has an explicit port the next port is bridged
+// too, unrecorded: the GCP backends reach the simulator's Cloud
+// Logging gRPC server at the endpoint's port + 1.
+func StartCassetteRecord(path, upstream string) (*CassetteHarness, error) {
+	u, err := url.Parse(upstream)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream %q", upstream)
+	}
+	rec, err := NewCassetteRecorder(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	h := &CassetteHarness{Mode: "record", Path: path, rec: rec}
+	ln, bridge, err := listenCassettePair(u.Port() != "")
+	if err != nil {
+		_ = rec.Close()
+		return nil, err
+	}
+	if bridge != nil {
+		h.bridge = bridge
+		up, _ := strconv.Atoi(u.Port())
+		go bridgeCassetteTCP(bridge, net.JoinHostPort(u.Hostname(), strconv.Itoa(up+1)))
+	}
+	h.serve(ln, NewCassetteProxy(u, rec))
+	return h, nil
+}
+
+func (h *CassetteHarness) serve(ln net.Listener, handler http.Handler) {
+	h.URL = "http://" + ln.Addr().String()
+	h.srv = &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = h.srv.Serve(ln) }()
+}
+
+// listenCassettePair listens on a local port and, when pair is set,
+// on the port after it as well.
+func listenCassettePair(pair bool) (net.Listener, net.Listener, error) {
+	for attempt := 0; attempt < 20; attempt++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, nil, err
+		}
+		if !pair {
+			return ln, nil, nil
+		}
+		next := ln.Addr().(*net.TCPAddr).Port + 1
+		bridge, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(next)))
+		if err == nil {
+			return ln, bridge, nil
+		}
+		_ = ln.Close()
+	}
+	return nil, nil, errors.New("no free adjacent port pair for the cassette proxy")
+}
+
+func bridgeCassetteTCP(ln net.Listener, target string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			up, err := net.Dial("tcp", target)
+			if err != nil {
+				return
+			}
+			defer up.Close()
+			done := make(chan struct{}, 2)
+			go func() { _, _ = io.Copy(up, conn); done <- struct{}{} }()
+			go func() { _, _ = io.Copy(conn, up); done <- struct{}{} }()
+			<-done
+		}()
+	}
+}
+
+// Finish closes the harness once the tests ran, prints what was
+// recorded or the replay report, and returns the exit code: a clean
+// run whose replay had unmatched requests fails.
+func (h *CassetteHarness) Finish(code int) int {
+	report, err := h.Close()
+	switch {
+	case h.Mode == "record":
+		fmt.Fprintf(os.Stderr, "[cassette] recorded %d interactions to %s\n", report.Interactions, h.Path)
+	default:
+		fmt.Fprintf(os.Stderr, "[cassette] %s\n", report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[cassette] %v\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// Close stops the harness. For a replay it returns the report and
+// the report's Err; for a recording, the number of interactions in
+// Report.Interactions.
+func (h *CassetteHarness) Close() (CassetteReport, error) {
+	_ = h.srv.Close()
+	if h.bridge != nil {
+		_ = h.bridge.Close()
+	}
+	if h.rep != nil {
+		rep := h.rep.Report()
+		return rep, rep.Err()
+	}
+	n := h.rec.Recorded()
+	return CassetteReport{Interactions: n}, h.rec.Close()
+}
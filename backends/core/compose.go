@@ -0,0 +1,287 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/sockerless/api"
+)
+
+// Labels docker compose sets on every container it creates.
+const (
+	ComposeProjectLabel   = "com.docker.compose.project"
+	ComposeServiceLabel   = "com.docker.compose.service"
+	ComposeNumberLabel    = "com.docker.compose.container-number"
+	ComposeOneoffLabel    = "com.docker.compose.oneoff"
+	ComposeDependsOnLabel = "com.docker.compose.depends_on"
+)
+
+// depends_on conditions carried in ComposeDependsOnLabel.
+const (
+	ComposeServiceStarted   = "service_started"
+	ComposeServiceHealthy   = "service_healthy"
+	ComposeServiceCompleted = "service_completed_successfully"
+)
+
+// ComposeDependency is one entry of a service's depends_on.
+type ComposeDependency struct {
+	Service   string
+	Condition string // one of the ComposeService* conditions
+	Restart   bool
+}
+
+// ParseComposeDependsOn parses the depends_on label compose writes as
+// comma-separated "service:condition:restart" entries. An entry without
+// a condition waits for service_started.
+func ParseComposeDependsOn(label string) []ComposeDependency {
+	var deps []ComposeDependency
+	for _, entry := range strings.Split(label, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if parts[0] == "" {
+			continue
+		}
+		dep := ComposeDependency{Service: parts[0], Condition: ComposeServiceStarted}
+		if len(parts) > 1 && parts[1] != "" {
+			dep.Condition = parts[1]
+		}
+		if len(parts) > 2 {
+			dep.Restart = parts[2] == "true"
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+// ComposeUnitDependency is a depends_on entry resolved to the unit
+// member it names.
+type ComposeUnitDependency struct {
+	ContainerID string
+	Condition   string
+}
+
+// ComposeUnitDependencies resolves each unit member's depends_on to the
+// other members, keyed by container ID. Dependencies on services outside
+// the unit are dropped: compose sequences those itself.
+func ComposeUnitDependencies(unit []api.Container) map[string][]ComposeUnitDependency {
+	byService := make(map[string]string, len(unit))
+	for _, c := range unit {
+		byService[c.Config.Labels[ComposeServiceLabel]] = c.ID
+	}
+	deps := make(map[string][]ComposeUnitDependency)
+	for _, c := range unit {
+		for _, d := range ParseComposeDependsOn(c.Config.Labels[ComposeDependsOnLabel]) {
+			if id, ok := byService[d.Service]; ok && id != c.ID {
+				deps[c.ID] = append(deps[c.ID], ComposeUnitDependency{ContainerID: id, Condition: d.Condition})
+			}
+		}
+	}
+	return deps
+}
+
+// composeUnitProject returns the compose project a new container with
+// labels joins as a unit member. One-off `compose run` containers and
+// scale replicas beyond the first run standalone: a unit has one
+// container per service, so a replica is its own cloud resource and
+// reaches the unit over the project network, not localhost.
+func composeUnitProject(labels map[string]string) (string, bool) {
+	project := labels[ComposeProjectLabel]
+	if project == "" || labels[ComposeServiceLabel] == "" || strings.EqualFold(labels[ComposeOneoffLabel], "true") {
+		return "", false
+	}
+	if n := labels[ComposeNumberLabel]; n != "" && n != "1" {
+		return "", false
+	}
+	return project, true
+}
+
+// composeUnitPod returns the pod a container created with labels joins,
+// or nil when it runs standalone: the backend does not group compose
+// projects, the container is not a unit member, or the project's unit
+// is already materialised (services added after `compose up` started).
+func (s *BaseServer) composeUnitPod(labels map[string]string) *PodContext {
+	if !s.ComposeUnits {
+		return nil
+	}
+	project, ok := composeUnitProject(labels)
+	if !ok {
+		return nil
+	}
+	pod := s.Store.Pods.EnsurePod("compose-"+project, map[string]string{ComposeProjectLabel: project})
+	if pod.Labels[ComposeProjectLabel] != project || s.Store.Pods.Unit(pod.ID) != nil {
+		return nil
+	}
+	return pod
+}
+
+// composeUnitStart is PodDeferredStart for a compose project's pod.
+// compose creates every container before starting any, so the first
+// start materialises the whole project; the in-cloud dependency wiring
+// (ComposeUnitDependencies) keeps depends_on ordering. Later starts of
+// members of a running unit are deferred. Services that a one-shot
+// dependency gates run standalone under compose's own sequencing.
+func (s *BaseServer) composeUnitStart(pod *PodContext, containerID string) (bool, []api.Container) {
+	if unit := s.Store.Pods.Unit(pod.ID); unit != nil {
+		if main, ok := s.ResolveContainerAuto(context.Background(), pod.MainID); ok && main.State.Running {
+			return true, nil
+		}
+		// The unit was stopped: run it again from the recorded members.
+		s.Store.Pods.SetUnit(pod.ID, unit)
+		return false, unit
+	}
+
+	var members []api.Container
+	for _, cid := range pod.ContainerIDs {
+		if s.PendingCreates != nil {
+			if c, ok := s.PendingCreates.Get(cid); ok {
+				members = append(members, c)
+				continue
+			}
+		}
+		if c, ok := s.Store.Containers.Get(cid); ok {
+			members = append(members, c)
+		}
+	}
+	unit, standalone := composeUnitOrder(members)
+	isStandalone := false
+	for _, c := range standalone {
+		s.Store.Pods.RemoveContainer(pod.ID, c.ID)
+		if c.ID == containerID {
+			isStandalone = true
+		}
+	}
+	if isStandalone || len(unit) <= 1 {
+		return false, nil
+	}
+	s.Store.Pods.SetUnit(pod.ID, unit)
+	return false, unit
+}
+
+// composeUnitOrder splits a project's containers into the unit, ordered
+// main first and then dependencies before dependents, and the containers
+// that run standalone: targets of service_completed_successfully (a
+// cloud unit's containers run for its lifetime) and every service that
+// waits on one, directly or not. The main container is a service no
+// other member depends on, preferring one that publishes ports.
+func composeUnitOrder(members []api.Container) (unit, standalone []api.Container) {
+	byService := make(map[string]api.Container, len(members))
+	deps := make(map[string][]ComposeDependency, len(members))
+	for _, c := range members {
+		svc := c.Config.Labels[ComposeServiceLabel]
+		byService[svc] = c
+		deps[svc] = ParseComposeDependsOn(c.Config.Labels[ComposeDependsOnLabel])
+	}
+
+	excluded := make(map[string]bool)
+	for _, ds := range deps {
+		for _, d := range ds {
+			if d.Condition == ComposeServiceCompleted {
+				excluded[d.Service] = true
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for svc, ds := range deps {
+			for _, d := range ds {
+				if excluded[d.Service] && !excluded[svc] {
+					excluded[svc] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	services := make([]string, 0, len(byService))
+	for svc := range byService {
+		services = append(services, svc)
+	}
+	sort.Strings(services)
+
+	dependedOn := make(map[string]bool)
+	for _, svc := range services {
+		if excluded[svc] {
+			standalone = append(standalone, byService[svc])
+			continue
+		}
+		for _, d := range deps[svc] {
+			dependedOn[d.Service] = true
+		}
+	}
+
+	main := ""
+	for _, svc := range services {
+		if excluded[svc] || dependedOn[svc] {
+			continue
+		}
+		if main == "" || (len(byService[main].Config.ExposedPorts) == 0 && len(byService[svc].Config.ExposedPorts) > 0) {
+			main = svc
+		}
+	}
+	if main == "" {
+		// depends_on cycle; compose rejects these, keep a stable order.
+		for _, svc := range services {
+			if !excluded[svc] {
+				main = svc
+				break
+			}
+		}
+	}
+	if main == "" {
+		return nil, standalone
+	}
+
+	unit = append(unit, byService[main])
+	visited := map[string]bool{main: true}
+	var visit func(svc string)
+	visit = func(svc string) {
+		if visited[svc] || excluded[svc] {
+			return
+		}
+		if _, ok := byService[svc]; !ok {
+			return
+		}
+		visited[svc] = true
+		for _, d := range deps[svc] {
+			visit(d.Service)
+		}
+		unit = append(unit, byService[svc])
+	}
+	for _, d := range deps[main] {
+		visit(d.Service)
+	}
+	for _, svc := range services {
+		visit(svc)
+	}
+	return unit, standalone
+}
+
+// composeMemberView returns a non-main unit member as inspect and ps
+// report it. The member shares the unit's lifecycle, so its state is
+// the main container's. No health check runs against a member on its
+// own; only a member others depend on being healthy has a probe: the
+// health check itself on ECS, a TCP startup probe on its port on Cloud
+// Run and ACA. The unit only runs once that probe passes, so such a
+// member reports healthy while the unit runs, and the log names the
+// probe. Other members report no health.
+func (s *BaseServer) composeMemberView(ctx context.Context, m UnitMember) api.Container {
+	c := m.Container
+	main, ok := s.ResolveContainerAuto(ctx, m.MainID)
+	if !ok {
+		c.State.Status = "exited"
+		c.State.Running = false
+		c.State.Health = nil
+		return c
+	}
+	c.State = main.State
+	c.State.Pid = 0
+	c.State.Health = nil
+	if m.Gated && HasHealthcheck(c) && main.State.Running {
+		c.State.Health = &api.HealthState{Status: "healthy", Log: []api.HealthLog{{
+			Start:  main.State.StartedAt,
+			End:    main.State.StartedAt,
+			Output: "platform startup probe passed before the compose unit started",
+		}}}
+	}
+	return c
+}
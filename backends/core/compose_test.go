@@ -0,0 +1,233 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sockerless/api"
+)
+
+func composeContainer(id, service, dependsOn string) api.Container {
+	return api.Container{
+		ID:   id,
+		Name: "/proj-" + service + "-1",
+		Config: api.ContainerConfig{
+			Labels: map[string]string{
+				ComposeProjectLabel:   "proj",
+				ComposeServiceLabel:   service,
+				ComposeNumberLabel:    "1",
+				ComposeDependsOnLabel: dependsOn,
+			},
+		},
+		State: api.ContainerState{Status: "created"},
+	}
+}
+
+func unitIDs(unit []api.Container) []string {
+	ids := make([]string, 0, len(unit))
+	for _, c := range unit {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestParseComposeDependsOn(t *testing.T) {
+	deps := ParseComposeDependsOn("db:service_healthy:true,cache,migrate:service_completed_successfully:false")
+	want := []ComposeDependency{
+		{Service: "db", Condition: ComposeServiceHealthy, Restart: true},
+		{Service: "cache", Condition: ComposeServiceStarted},
+		{Service: "migrate", Condition: ComposeServiceCompleted},
+	}
+	if len(deps) != len(want) {
+		t.Fatalf("deps = %+v", deps)
+	}
+	for i := range want {
+		if deps[i] != want[i] {
+			t.Errorf("deps[%d] = %+v, want %+v", i, deps[i], want[i])
+		}
+	}
+	if deps := ParseComposeDependsOn(""); len(deps) != 0 {
+		t.Errorf("empty label parsed to %+v", deps)
+	}
+}
+
+func TestComposeUnitOrder_MainFirstDependenciesBeforeDependents(t *testing.T) {
+	web := composeContainer("web", "web", "api:service_started:false")
+	web.Config.ExposedPorts = map[string]struct{}{"80/tcp": {}}
+	members := []api.Container{
+		composeContainer("db", "db", ""),
+		composeContainer("api", "api", "db:service_healthy:false,cache:service_started:false"),
+		composeContainer("cache", "cache", ""),
+		composeContainer("worker", "worker", "db:service_healthy:false"),
+		web,
+	}
+	unit, standalone := composeUnitOrder(members)
+	if len(standalone) != 0 {
+		t.Errorf("standalone = %v", unitIDs(standalone))
+	}
+	got := unitIDs(unit)
+	want := []string{"web", "db", "cache", "api", "worker"}
+	if len(got) != len(want) {
+		t.Fatalf("unit = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unit = %v, want %v", got, want)
+		}
+	}
+
+	deps := ComposeUnitDependencies(unit)
+	if d := deps["api"]; len(d) != 2 || d[0] != (ComposeUnitDependency{ContainerID: "db", Condition: ComposeServiceHealthy}) {
+		t.Errorf("api deps = %+v", d)
+	}
+	if len(deps["db"]) != 0 {
+		t.Errorf("db deps = %+v", deps["db"])
+	}
+}
+
+func TestComposeUnitOrder_OneShotDependenciesRunStandalone(t *testing.T) {
+	members := []api.Container{
+		composeContainer("db", "db", ""),
+		composeContainer("migrate", "migrate", "db:service_healthy:false"),
+		composeContainer("app", "app", "migrate:service_completed_successfully:false,db:service_healthy:false"),
+		composeContainer("admin", "admin", "app:service_started:false"),
+		composeContainer("metrics", "metrics", "db:service_started:false"),
+	}
+	unit, standalone := composeUnitOrder(members)
+	if got := unitIDs(unit); len(got) != 2 || got[0] != "metrics" || got[1] != "db" {
+		t.Errorf("unit = %v, want [metrics db]", got)
+	}
+	if got := unitIDs(standalone); len(got) != 3 {
+		t.Errorf("standalone = %v, want admin, app and migrate", got)
+	}
+}
+
+func TestComposeCreate_GroupsFirstReplicaOfEachService(t *testing.T) {
+	s := newTestBaseServer()
+	s.ComposeUnits = true
+
+	create := func(name string, labels map[string]string) string {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"Image": "alpine", "Labels": labels})
+		rec := httptest.NewRecorder()
+		s.Mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/create?name="+name, bytes.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", name, rec.Code, rec.Body)
+		}
+		var resp api.ContainerCreateResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp.ID
+	}
+	labels := func(service, number string) map[string]string {
+		return map[string]string{ComposeProjectLabel: "shop", ComposeServiceLabel: service, ComposeNumberLabel: number}
+	}
+	web := create("shop-web-1", labels("web", "1"))
+	db := create("shop-db-1", labels("db", "1"))
+	replica := create("shop-web-2", labels("web", "2"))
+	oneoff := labels("web", "1")
+	oneoff[ComposeOneoffLabel] = "True"
+	run := create("shop-web-run", oneoff)
+
+	pod, ok := s.Store.Pods.GetPod("compose-shop")
+	if !ok {
+		t.Fatal("compose project pod not created")
+	}
+	if len(pod.ContainerIDs) != 2 || pod.ContainerIDs[0] != web || pod.ContainerIDs[1] != db {
+		t.Errorf("pod members = %v, want web and db", pod.ContainerIDs)
+	}
+	for _, id := range []string{replica, run} {
+		if _, inPod := s.Store.Pods.GetPodForContainer(id); inPod {
+			t.Errorf("container %s joined the unit", id)
+		}
+	}
+
+	s.Store.Pods.RemoveContainer(pod.ID, web)
+	s.Store.Pods.RemoveContainer(pod.ID, db)
+	if s.Store.Pods.Exists("compose-shop") {
+		t.Error("empty compose pod survived the last removal")
+	}
+}
+
+func TestComposeCreate_DisabledWithoutComposeUnits(t *testing.T) {
+	s := newTestBaseServer()
+	body, _ := json.Marshal(map[string]any{"Image": "alpine", "Labels": map[string]string{ComposeProjectLabel: "shop", ComposeServiceLabel: "web"}})
+	rec := httptest.NewRecorder()
+	s.Mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/create?name=web", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	if s.Store.Pods.Exists("compose-shop") {
+		t.Error("compose pod created on a backend without compose units")
+	}
+}
+
+func TestComposeUnitStart_MaterialisesOnFirstStartAndServesMembers(t *testing.T) {
+	s := newSpecTestServer()
+	s.ComposeUnits = true
+	cloud := &mockCloudState{}
+	s.CloudState = cloud
+
+	db := composeContainer("db0000000001", "db", "")
+	db.Config.Healthcheck = &api.HealthcheckConfig{Test: []string{"CMD-SHELL", "pg_isready"}}
+	cache := composeContainer("cache0000001", "cache", "")
+	cache.Config.Healthcheck = &api.HealthcheckConfig{Test: []string{"CMD", "redis-cli", "ping"}}
+	web := composeContainer("web000000001", "web", "db:service_healthy:false,cache:service_started:false")
+	pod := s.Store.Pods.EnsurePod("compose-proj", map[string]string{ComposeProjectLabel: "proj"})
+	for _, c := range []api.Container{db, cache, web} {
+		s.PendingCreates.Put(c.ID, c)
+		_ = s.Store.Pods.AddContainer(pod.ID, c.ID)
+	}
+
+	// compose starts the dependency first; that start runs the unit.
+	shouldDefer, unit := s.PodDeferredStart(db.ID)
+	if shouldDefer || len(unit) != 3 || unit[0].ID != web.ID {
+		t.Fatalf("first start: defer=%v unit=%v, want web-led unit", shouldDefer, unitIDs(unit))
+	}
+	if pod.MainID != web.ID {
+		t.Errorf("MainID = %q", pod.MainID)
+	}
+
+	// The backend moved both out of PendingCreates; only the main
+	// container is surfaced by the cloud.
+	s.PendingCreates.Delete(db.ID)
+	s.PendingCreates.Delete(cache.ID)
+	s.PendingCreates.Delete(web.ID)
+	running := web
+	running.State = api.ContainerState{Status: "running", Running: true, StartedAt: "2026-01-01T00:00:00Z", Pid: 7}
+	cloud.containers = []api.Container{running}
+
+	got, ok := s.ResolveContainerAuto(context.Background(), "proj-db-1")
+	if !ok {
+		t.Fatal("unit member not resolvable by name")
+	}
+	if !got.State.Running || got.State.Health == nil || got.State.Health.Status != "healthy" || len(got.State.Health.Log) != 1 || got.State.Pid != 0 {
+		t.Errorf("member state = %+v", got.State)
+	}
+	// Nothing waits on cache being healthy, so no probe ran against it.
+	if got, _ := s.ResolveContainerAuto(context.Background(), cache.ID); !got.State.Running || got.State.Health != nil {
+		t.Errorf("unprobed member state = %+v", got.State)
+	}
+	list, err := s.ContainerList(api.ContainerListOptions{All: true})
+	if err != nil || len(list) != 3 {
+		t.Fatalf("list = %d entries, err %v", len(list), err)
+	}
+
+	if shouldDefer, _ := s.PodDeferredStart(web.ID); !shouldDefer {
+		t.Error("start of a member of the running unit was not deferred")
+	}
+
+	// compose stop / start runs the recorded unit again.
+	stopped := web
+	stopped.State = api.ContainerState{Status: "exited", ExitCode: 0}
+	cloud.containers = []api.Container{stopped}
+	if got, _ := s.ResolveContainerAuto(context.Background(), db.ID); got.State.Running || got.State.Health != nil {
+		t.Errorf("member of stopped unit = %+v", got.State)
+	}
+	if shouldDefer, unit := s.PodDeferredStart(db.ID); shouldDefer || len(unit) != 3 {
+		t.Errorf("restart: defer=%v unit=%v", shouldDefer, unitIDs(unit))
+	}
+}
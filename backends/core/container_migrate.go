@@ -0,0 +1,496 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// ContainerCheckpoint is what `sockerless migrate` carries from the
+// source backend to the target: the committed image plus the container
+// settings and named volumes needed to recreate it there.
+type ContainerCheckpoint struct {
+	ID      string `json:"Id"`
+	Name    string `json:"Name"`
+	Image   string `json:"Image"` // the committed image, as the source's /images/get serves it
+	Running bool   `json:"Running"`
+
+	Config     api.ContainerConfig `json:"Config"`
+	HostConfig api.HostConfig      `json:"HostConfig"`
+	// Networks maps each network the container is attached to onto
+	// the aliases it answers to there.
+	Networks map[string][]string `json:"Networks"`
+	Volumes  []CheckpointVolume  `json:"Volumes"`
+}
+
+// CheckpointVolume is one named volume mounted into a checkpointed
+// container. Its contents are read from the source container at
+// Destination when the target restores it.
+type CheckpointVolume struct {
+	Name        string            `json:"Name"`
+	Destination string            `json:"Destination"`
+	Labels      map[string]string `json:"Labels,omitempty"`
+}
+
+// ContainerCheckpointer is implemented by backends that can capture a
+// container for migration. BaseServer commits through the backend's
+// ContainerCommit; ImageManager.Checkpoint also pushes the committed
+// image into the cloud registry so the target can `docker save` it.
+type ContainerCheckpointer interface {
+	ContainerCheckpoint(ref string) (*ContainerCheckpoint, error)
+}
+
+// MigrateRequest is the body of POST /internal/v1/containers/migrate,
+// sent to the target backend.
+type MigrateRequest struct {
+	// Source is the management address of the backend currently
+	// running the container. It must be reachable from the target.
+	Source    string `json:"Source"`
+	Container string `json:"Container"`
+}
+
+// MigrateResponse describes the recreated container on the target.
+type MigrateResponse struct {
+	ID       string   `json:"Id"`
+	SourceID string   `json:"SourceId"`
+	Name     string   `json:"Name"`
+	Image    string   `json:"Image"`
+	Volumes  []string `json:"Volumes"`
+	Started  bool     `json:"Started"`
+}
+
+// MigrationSeedLabel marks the short-lived container the target runs
+// to copy volume contents into its own storage backing.
+const MigrationSeedLabel = "com.sockerless.migrate.seed"
+
+// DefaultMigrationSeedImage runs the volume seed container when
+// SOCKERLESS_MIGRATE_SEED_IMAGE is unset. The seed needs a shell, tar
+// and a command that idles; the migrated image may have none of them
+// (distroless, scratch).
+const DefaultMigrationSeedImage = "busybox:1.36"
+
+func migrationSeedImage() string {
+	if v := strings.TrimSpace(os.Getenv("SOCKERLESS_MIGRATE_SEED_IMAGE")); v != "" {
+		return v
+	}
+	return DefaultMigrationSeedImage
+}
+
+// migrationUndo collects removals of what a migration created on the
+// target, run newest first when a later step fails so a failed
+// migration leaves the target as it found it.
+type migrationUndo []func()
+
+func (u *migrationUndo) add(f func()) { *u = append(*u, f) }
+
+func (u migrationUndo) run() {
+	for i := len(u) - 1; i >= 0; i-- {
+		u[i]()
+	}
+}
+
+// migrationClient talks to the source backend. Commits and image
+// archives can take minutes, so there is no whole-request timeout;
+// the handler's request context bounds the migration instead.
+var migrationClient = &http.Client{}
+
+// ContainerCheckpoint commits ref's filesystem to a new image tagged
+// `sockerless-migrate/<name>:<unix time>` and records what the target
+// needs to recreate it. The container must be running: the commit and
+// the later volume reads go through the in-container agent.
+func (s *BaseServer) ContainerCheckpoint(ref string) (*ContainerCheckpoint, error) {
+	c, err := s.self.ContainerInspect(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !c.State.Running {
+		return nil, &api.ConflictError{Message: fmt.Sprintf("Container %s is not running; migration reads its filesystem and volumes through the in-container agent", c.ID)}
+	}
+	cp := checkpointFromContainer(c)
+
+	repo := "sockerless-migrate/" + strings.ToLower(cp.Name)
+	tag := strconv.FormatInt(time.Now().Unix(), 10)
+	if _, err := s.self.ContainerCommit(&api.ContainerCommitRequest{
+		Container: c.ID,
+		Repo:      repo,
+		Tag:       tag,
+		Comment:   "sockerless migrate checkpoint of " + cp.Name,
+	}); err != nil {
+		return nil, err
+	}
+	cp.Image = repo + ":" + tag
+	cp.Config.Image = cp.Image
+
+	for i, v := range cp.Volumes {
+		vol, err := s.self.VolumeInspect(v.Name)
+		if err != nil {
+			return nil, fmt.Errorf("volume %s: %w", v.Name, err)
+		}
+		cp.Volumes[i].Labels = vol.Labels
+	}
+	return cp, nil
+}
+
+// checkpointFromContainer copies the parts of c that ContainerCreate
+// on another backend takes back: config, host config, network aliases
+// and named-volume mounts.
+func checkpointFromContainer(c *api.Container) *ContainerCheckpoint {
+	cp := &ContainerCheckpoint{
+		ID:         c.ID,
+		Name:       strings.TrimPrefix(c.Name, "/"),
+		Running:    c.State.Running,
+		Config:     c.Config,
+		HostConfig: c.HostConfig,
+		Networks:   map[string][]string{},
+	}
+	for name, ep := range c.NetworkSettings.Networks {
+		var aliases []string
+		if ep != nil {
+			aliases = ep.Aliases
+		}
+		cp.Networks[name] = aliases
+	}
+	for _, m := range c.Mounts {
+		if m.Type == "volume" && m.Name != "" {
+			cp.Volumes = append(cp.Volumes, CheckpointVolume{Name: m.Name, Destination: m.Destination})
+		}
+	}
+	return cp
+}
+
+// MigrateContainer recreates a container from another sockerless
+// backend on this one:
+//
+//  1. the source commits the container (POST .../checkpoint);
+//  2. the committed image is streamed from the source's `docker save`
+//     into this backend's `docker load`, which pushes it to the cloud
+//     registry;
+//  3. each named volume is created here, and its contents are copied
+//     from the source container into a seed container that mounts it,
+//     landing in this backend's storage backing (EFS, GCS, Azure Files);
+//  4. the container is created with the same name, labels, env and
+//     network aliases, and started if it was running.
+//
+// The source container is left as it is; the caller removes it once
+// the migration has succeeded. If a step fails, the image, networks,
+// volumes and container this migration created here are removed again.
+func (s *BaseServer) MigrateContainer(ctx context.Context, req MigrateRequest) (_ *MigrateResponse, err error) {
+	if req.Source == "" || req.Container == "" {
+		return nil, &api.InvalidParameterError{Message: "migrate requires Source and Container"}
+	}
+	source := strings.TrimRight(req.Source, "/")
+
+	cp, err := fetchCheckpoint(ctx, source, req.Container)
+	if err != nil {
+		return nil, err
+	}
+
+	var undo migrationUndo
+	defer func() {
+		if err != nil {
+			undo.run()
+		}
+	}()
+
+	loaded := cp.Image
+	if _, ok := s.Store.ResolveImage(loaded); !ok {
+		undo.add(func() { s.removeMigrationImage(loaded) })
+	}
+	if err := s.loadMigrationImage(ctx, source, loaded); err != nil {
+		return nil, err
+	}
+	image, err := s.admitImage(ctx, cp.Image, ScanEnforceCreate)
+	if err != nil {
+		return nil, err
+	}
+	cp.Image = image
+
+	for network := range cp.Networks {
+		if _, err := s.self.NetworkInspect(network); err == nil {
+			continue
+		}
+		created, err := s.self.NetworkCreate(&api.NetworkCreateRequest{Name: network})
+		if err != nil {
+			return nil, fmt.Errorf("create network %s: %w", network, err)
+		}
+		undo.add(func() {
+			if err := s.self.NetworkRemove(created.ID); err != nil {
+				s.Logger.Warn().Err(err).Str("network", network).Msg("failed to roll back migrated network")
+			}
+		})
+	}
+
+	if err := s.restoreMigrationVolumes(ctx, source, cp, &undo); err != nil {
+		return nil, err
+	}
+
+	created, err := s.self.ContainerCreate(migrationCreateRequest(cp))
+	if err != nil {
+		return nil, err
+	}
+	undo.add(func() {
+		if err := s.self.ContainerRemove(created.ID, true); err != nil {
+			s.Logger.Warn().Err(err).Str("container", created.ID).Msg("failed to roll back migrated container")
+		}
+	})
+	resp := &MigrateResponse{ID: created.ID, SourceID: cp.ID, Name: cp.Name, Image: cp.Image}
+	for _, v := range cp.Volumes {
+		resp.Volumes = append(resp.Volumes, v.Name)
+	}
+	if cp.Running {
+		if err := s.checkCostBudget(ctx, created.ID); err != nil {
+			return nil, err
+		}
+		if err := s.self.ContainerStart(created.ID); err != nil {
+			return nil, fmt.Errorf("start %s: %w", cp.Name, err)
+		}
+		resp.Started = true
+	}
+	return resp, nil
+}
+
+func (s *BaseServer) removeMigrationImage(ref string) {
+	if _, err := s.self.ImageRemove(ref, true, false); err != nil {
+		s.Logger.Warn().Err(err).Str("image", ref).Msg("failed to remove migration image")
+	}
+}
+
+// migrationCreateRequest builds the ContainerCreate request that
+// recreates cp: its config and host config as they were, attached to
+// the same networks under the same aliases.
+func migrationCreateRequest(cp *ContainerCheckpoint) *api.ContainerCreateRequest {
+	config := cp.Config
+	config.Image = cp.Image
+	hostConfig := cp.HostConfig
+	req := &api.ContainerCreateRequest{
+		ContainerConfig: &config,
+		HostConfig:      &hostConfig,
+		Name:            cp.Name,
+	}
+	if len(cp.Networks) > 0 {
+		req.NetworkingConfig = &api.NetworkingConfig{EndpointsConfig: map[string]*api.EndpointSettings{}}
+		for network, aliases := range cp.Networks {
+			req.NetworkingConfig.EndpointsConfig[network] = &api.EndpointSettings{Aliases: aliases}
+		}
+	}
+	return req
+}
+
+// loadMigrationImage streams the source's `docker save` of image into
+// this backend's ImageLoad and fails on an error line in its output.
+func (s *BaseServer) loadMigrationImage(ctx context.Context, source, image string) error {
+	body, err := migrationSourceStream(ctx, source, "/internal/v1/images/get?names="+url.QueryEscape(image))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	rc, err := s.self.ImageLoad(body)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", image, err)
+	}
+	return drainMigrationProgress(rc, "load "+image)
+}
+
+// drainMigrationProgress reads a load or pull progress stream to the
+// end, failing on the first {"error": ...} line.
+func drainMigrationProgress(rc io.ReadCloser, what string) error {
+	defer rc.Close()
+	dec := json.NewDecoder(rc)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: read progress: %w", what, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("%s: %s", what, msg.Error)
+		}
+	}
+}
+
+// restoreMigrationVolumes creates cp's named volumes here and copies
+// their contents in through a seed container mounting them, so the data
+// lands in this backend's storage backing. The seed runs the helper
+// image (migrationSeedImage), not the migrated one, which may have no
+// shell or tar. The seed, and the helper image when it was pulled for
+// it, are removed afterwards either way; volumes created here are
+// added to undo.
+func (s *BaseServer) restoreMigrationVolumes(ctx context.Context, source string, cp *ContainerCheckpoint, undo *migrationUndo) error {
+	if len(cp.Volumes) == 0 {
+		return nil
+	}
+	binds := make([]string, 0, len(cp.Volumes))
+	for _, v := range cp.Volumes {
+		if _, err := s.self.VolumeInspect(v.Name); err != nil {
+			if _, err := s.self.VolumeCreate(&api.VolumeCreateRequest{Name: v.Name, Labels: v.Labels}); err != nil {
+				return fmt.Errorf("create volume %s: %w", v.Name, err)
+			}
+			name := v.Name
+			undo.add(func() {
+				if err := s.self.VolumeRemove(name, true); err != nil {
+					s.Logger.Warn().Err(err).Str("volume", name).Msg("failed to roll back migrated volume")
+				}
+			})
+		}
+		binds = append(binds, v.Name+":"+v.Destination)
+	}
+
+	seedImage := migrationSeedImage()
+	if _, ok := s.Store.ResolveImage(seedImage); !ok {
+		rc, err := s.self.ImagePull(seedImage, "")
+		if err != nil {
+			return fmt.Errorf("pull volume seed image %s: %w", seedImage, err)
+		}
+		defer s.removeMigrationImage(seedImage)
+		if err := drainMigrationProgress(rc, "pull volume seed image "+seedImage); err != nil {
+			return err
+		}
+	}
+	seed, err := s.self.ContainerCreate(&api.ContainerCreateRequest{
+		ContainerConfig: &api.ContainerConfig{
+			Image:      seedImage,
+			Entrypoint: []string{"tail"},
+			Cmd:        []string{"-f", "/dev/null"},
+			Labels:     map[string]string{MigrationSeedLabel: cp.Name},
+		},
+		HostConfig: &api.HostConfig{Binds: binds},
+		Name:       cp.Name + "-migrate-seed",
+	})
+	if err != nil {
+		return fmt.Errorf("create volume seed container: %w", err)
+	}
+	defer func() {
+		if err := s.self.ContainerRemove(seed.ID, true); err != nil {
+			s.Logger.Warn().Err(err).Str("container", seed.ID).Msg("failed to remove migration seed container")
+		}
+	}()
+	if err := s.self.ContainerStart(seed.ID); err != nil {
+		return fmt.Errorf("start volume seed container: %w", err)
+	}
+
+	for _, v := range cp.Volumes {
+		if err := s.copyMigrationVolume(ctx, source, cp.ID, seed.ID, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyMigrationVolume pipes `docker cp SOURCE:<dest>` into
+// `docker cp - SEED:<parent of dest>`. The archive's top entry is the
+// mount directory itself, so extracting at the parent lands it in place.
+func (s *BaseServer) copyMigrationVolume(ctx context.Context, source, sourceID, seedID string, v CheckpointVolume) error {
+	body, err := migrationSourceStream(ctx, source, "/internal/v1/containers/"+url.PathEscape(sourceID)+"/archive?path="+url.QueryEscape(v.Destination))
+	if err != nil {
+		return fmt.Errorf("read volume %s: %w", v.Name, err)
+	}
+	defer body.Close()
+	if err := s.self.ContainerPutArchive(seedID, path.Dir(v.Destination), false, body); err != nil {
+		return fmt.Errorf("write volume %s: %w", v.Name, err)
+	}
+	return nil
+}
+
+// fetchCheckpoint asks the source backend to checkpoint ref.
+func fetchCheckpoint(ctx context.Context, source, ref string) (*ContainerCheckpoint, error) {
+	p := "/internal/v1/containers/" + url.PathEscape(ref) + "/checkpoint"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, source+p, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := migrationClient.Do(req)
+	if err != nil {
+		return nil, &api.ServerError{Message: fmt.Sprintf("migration source %s: %v", source, err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &api.NotFoundError{Resource: "container", ID: ref}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, migrationSourceError(resp, p)
+	}
+	var cp ContainerCheckpoint
+	if err := json.NewDecoder(resp.Body).Decode(&cp); err != nil {
+		return nil, &api.ServerError{Message: fmt.Sprintf("migration source %s: decode checkpoint: %v", source, err)}
+	}
+	return &cp, nil
+}
+
+// migrationSourceStream GETs p from the source backend and returns the
+// response body for the caller to stream and close.
+func migrationSourceStream(ctx context.Context, source, p string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source+p, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := migrationClient.Do(req)
+	if err != nil {
+		return nil, &api.ServerError{Message: fmt.Sprintf("migration source %s: %v", source, err)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, migrationSourceError(resp, p)
+	}
+	return resp.Body, nil
+}
+
+// migrationSourceError carries a conflict or unsupported operation on
+// the source through unchanged; anything else is a server error here.
+func migrationSourceError(resp *http.Response, p string) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e api.ErrorResponse
+	msg := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &e) == nil && e.Message != "" {
+		msg = e.Message
+	}
+	msg = fmt.Sprintf("migration source %s: %s", strings.SplitN(p, "?", 2)[0], msg)
+	switch resp.StatusCode {
+	case http.StatusConflict:
+		return &api.ConflictError{Message: msg}
+	case http.StatusNotImplemented:
+		return &api.NotImplementedError{Message: msg}
+	default:
+		return &api.ServerError{Message: msg}
+	}
+}
+
+func (s *BaseServer) handleContainerCheckpoint(w http.ResponseWriter, r *http.Request) {
+	cp, ok := s.self.(ContainerCheckpointer)
+	if !ok {
+		WriteError(w, &api.NotImplementedError{Message: "container checkpoint is not supported by the " + s.Desc.Driver + " backend"})
+		return
+	}
+	result, err := cp.ContainerCheckpoint(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, result)
+}
+
+func (s *BaseServer) handleContainerMigrate(w http.ResponseWriter, r *http.Request) {
+	var req MigrateRequest
+	if err := ReadJSON(r, &req); err != nil {
+		WriteError(w, &api.InvalidParameterError{Message: err.Error()})
+		return
+	}
+	resp, err := s.MigrateContainer(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusCreated, resp)
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/api"
+)
+
+func TestCheckpointFromContainer_RecreateRequest(t *testing.T) {
+	c := &api.Container{
+		ID:   "abc123",
+		Name: "/job",
+		Config: api.ContainerConfig{
+			Image:  "alpine",
+			Env:    []string{"STEP=7"},
+			Labels: map[string]string{"team": "data"},
+		},
+		HostConfig: api.HostConfig{Binds: []string{"work:/work", "/etc/hosts:/etc/hosts:ro"}},
+		State:      api.ContainerState{Running: true},
+		NetworkSettings: api.NetworkSettings{Networks: map[string]*api.EndpointSettings{
+			"jobs": {Aliases: []string{"worker"}},
+		}},
+		Mounts: buildMounts(api.HostConfig{Binds: []string{"work:/work", "/etc/hosts:/etc/hosts:ro"}}),
+	}
+	cp := checkpointFromContainer(c)
+	if cp.Name != "job" || !cp.Running {
+		t.Fatalf("checkpoint = %+v", cp)
+	}
+	if len(cp.Volumes) != 1 || cp.Volumes[0].Name != "work" || cp.Volumes[0].Destination != "/work" {
+		t.Errorf("volumes = %+v, want only the named volume", cp.Volumes)
+	}
+
+	cp.Image = "registry.example/sockerless-migrate/job:1"
+	req := migrationCreateRequest(cp)
+	if req.Name != "job" || req.Image != cp.Image {
+		t.Errorf("create name=%q image=%q", req.Name, req.Image)
+	}
+	if req.Env[0] != "STEP=7" || req.Labels["team"] != "data" {
+		t.Errorf("create config = %+v", req.ContainerConfig)
+	}
+	if ep := req.NetworkingConfig.EndpointsConfig["jobs"]; ep == nil || len(ep.Aliases) != 1 || ep.Aliases[0] != "worker" {
+		t.Errorf("endpoints = %+v", req.NetworkingConfig.EndpointsConfig)
+	}
+	if c.Config.Image != "alpine" {
+		t.Error("building the create request modified the source container")
+	}
+}
+
+func TestMigrateContainer_LoadsImageAndRecreates(t *testing.T) {
+	const image = "sockerless-migrate/job:1"
+	archive := dockerArchiveForTest(t, image, "")
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/internal/v1/containers/job/checkpoint":
+			WriteJSON(w, http.StatusOK, &ContainerCheckpoint{
+				ID:       "src-id",
+				Name:     "job",
+				Image:    image,
+				Config:   api.ContainerConfig{Image: image, Env: []string{"STEP=7"}, Labels: map[string]string{"team": "data"}},
+				Networks: map[string][]string{"jobs": {"worker"}},
+			})
+		case r.URL.Path == "/internal/v1/images/get" && r.URL.Query().Get("names") == image:
+			_, _ = w.Write(archive)
+		default:
+			t.Errorf("unexpected source request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer src.Close()
+
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+	resp, err := s.MigrateContainer(t.Context(), MigrateRequest{Source: src.URL, Container: "job"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.SourceID != "src-id" || resp.Name != "job" || resp.Started {
+		t.Errorf("response = %+v", resp)
+	}
+	if _, ok := s.Store.ResolveImage(image); !ok {
+		t.Error("committed image was not loaded")
+	}
+	if _, ok := s.Store.ResolveNetwork("jobs"); !ok {
+		t.Error("network jobs was not created")
+	}
+	c, ok := s.ResolveContainerAuto(t.Context(), "job")
+	if !ok {
+		t.Fatal("container not recreated under its name")
+	}
+	if c.Config.Labels["team"] != "data" || len(c.Config.Env) == 0 || c.Config.Env[0] != "STEP=7" {
+		t.Errorf("config = %+v", c.Config)
+	}
+	if ep := c.NetworkSettings.Networks["jobs"]; ep == nil || len(ep.Aliases) == 0 || ep.Aliases[0] != "worker" {
+		t.Errorf("networks = %+v", c.NetworkSettings.Networks)
+	}
+}
+
+func TestMigrateContainer_RollsBackOnFailure(t *testing.T) {
+	const image = "sockerless-migrate/job:1"
+	archive := dockerArchiveForTest(t, image, "")
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal/v1/containers/job/checkpoint":
+			WriteJSON(w, http.StatusOK, &ContainerCheckpoint{
+				ID:       "src-id",
+				Name:     "job",
+				Image:    image,
+				Config:   api.ContainerConfig{Image: image},
+				Networks: map[string][]string{"jobs": {"worker"}},
+			})
+		case "/internal/v1/images/get":
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer src.Close()
+
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+	StoreImageWithAliases(s.Store, "alpine", api.Image{ID: "sha256:" + strings.Repeat("c", 64), RepoTags: []string{"alpine:latest"}})
+	// The name is taken here, so the recreate fails after the image
+	// and network were set up.
+	if _, err := s.ContainerCreate(&api.ContainerCreateRequest{ContainerConfig: &api.ContainerConfig{Image: "alpine"}, Name: "job"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var conflict *api.ConflictError
+	if _, err := s.MigrateContainer(t.Context(), MigrateRequest{Source: src.URL, Container: "job"}); !errors.As(err, &conflict) {
+		t.Fatalf("err = %v, want the create's ConflictError", err)
+	}
+	if _, ok := s.Store.ResolveImage(image); ok {
+		t.Error("loaded image was not removed")
+	}
+	if _, ok := s.Store.ResolveNetwork("jobs"); ok {
+		t.Error("created network was not removed")
+	}
+	if _, ok := s.Store.ResolveImage("alpine"); !ok {
+		t.Error("pre-existing image was removed")
+	}
+}
+
+func TestMigrateContainer_SourceErrors(t *testing.T) {
+	status := http.StatusNotFound
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(api.ErrorResponse{Message: "Container job is not running"})
+	}))
+	defer src.Close()
+	s := newTestServer(&testExecDriver{})
+
+	var notFound *api.NotFoundError
+	if _, err := s.MigrateContainer(t.Context(), MigrateRequest{Source: src.URL, Container: "job"}); !errors.As(err, &notFound) {
+		t.Errorf("404 from source: err = %v, want NotFoundError", err)
+	}
+	status = http.StatusConflict
+	var conflict *api.ConflictError
+	if _, err := s.MigrateContainer(t.Context(), MigrateRequest{Source: src.URL, Container: "job"}); !errors.As(err, &conflict) {
+		t.Errorf("409 from source: err = %v, want ConflictError", err)
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sockerless/agent"
+	"github.com/sockerless/api"
+)
+
+// ResizeTargetContainer resolves a `docker container resize` target and
+// checks it the way dockerd does: the container must be running and
+// created with a TTY, since only then does its main process hold a PTY.
+// Returns the full container ID.
+func (s *BaseServer) ResizeTargetContainer(ref string) (string, error) {
+	c, ok := s.ResolveContainerAuto(context.Background(), ref)
+	if !ok {
+		return "", &api.NotFoundError{Resource: "container", ID: ref}
+	}
+	if !c.State.Running {
+		return "", &api.ConflictError{Message: fmt.Sprintf("Container %s is not running", c.ID)}
+	}
+	if !c.Config.Tty {
+		return "", &api.ConflictError{Message: fmt.Sprintf("Container %s was not created with a TTY", c.ID)}
+	}
+	return c.ID, nil
+}
+
+// ResizeTargetExec looks up a `docker exec` resize target. The exec
+// must have been started; its PTY lives only as long as the process.
+func (s *BaseServer) ResizeTargetExec(id string) (api.ExecInstance, error) {
+	exec, ok := s.Store.Execs.Get(id)
+	if !ok {
+		return api.ExecInstance{}, &api.NotFoundError{Resource: "exec instance", ID: id}
+	}
+	if !exec.Running {
+		return api.ExecInstance{}, &api.ConflictError{Message: fmt.Sprintf("Exec %s is not running", id)}
+	}
+	return exec, nil
+}
+
+// MapResizeErr converts an error from RunContainerResizeViaAgent /
+// RunExecResizeViaAgent into the corresponding Docker-API error.
+func MapResizeErr(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case ErrNoReverseAgent:
+		return &api.NotImplementedError{Message: "TTY resize requires a reverse-agent bootstrap inside the container (SOCKERLESS_CALLBACK_URL); no session registered"}
+	default:
+		return &api.ServerError{Message: fmt.Sprintf("resize via reverse-agent: %v", err)}
+	}
+}
+
+// RunContainerResizeViaAgent sizes the PTY of the container's main
+// process. The in-container agent applies it when it runs the main
+// process itself with a TTY (`sockerless-agent -keep-alive -tty`).
+func RunContainerResizeViaAgent(reg *ReverseAgentRegistry, containerID string, h, w int) error {
+	return sendResize(reg, containerID, agent.MainProcessSessionID, h, w)
+}
+
+// RunExecResizeViaAgent sizes the PTY of a running `docker exec`
+// session; the agent session ID is the exec ID.
+func RunExecResizeViaAgent(reg *ReverseAgentRegistry, containerID, execID string, h, w int) error {
+	return sendResize(reg, containerID, execID, h, w)
+}
+
+func sendResize(reg *ReverseAgentRegistry, containerID, sessionID string, h, w int) error {
+	if reg == nil {
+		return ErrNoReverseAgent
+	}
+	rc, ok := reg.Resolve(containerID)
+	if !ok {
+		return ErrNoReverseAgent
+	}
+	return rc.Resize(sessionID, w, h)
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/sockerless/agent"
+	"github.com/sockerless/api"
+)
+
+func TestResizeTargetContainer(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	createTestContainer(s, "plain", nil)
+	createTestContainer(s, "tty", nil)
+	s.Store.Containers.Update("tty", func(c *api.Container) { c.Config.Tty = true })
+	createTestContainer(s, "stopped", nil)
+	s.Store.Containers.Update("stopped", func(c *api.Container) {
+		c.Config.Tty = true
+		c.State.Running = false
+	})
+
+	if id, err := s.ResizeTargetContainer("tty"); err != nil || id != "tty" {
+		t.Fatalf("tty container: id=%q err=%v", id, err)
+	}
+	for _, ref := range []string{"plain", "stopped"} {
+		var conflict *api.ConflictError
+		if _, err := s.ResizeTargetContainer(ref); !errors.As(err, &conflict) {
+			t.Errorf("%s: err = %v, want ConflictError", ref, err)
+		}
+	}
+	var notFound *api.NotFoundError
+	if _, err := s.ResizeTargetContainer("missing"); !errors.As(err, &notFound) {
+		t.Errorf("missing: err = %v, want NotFoundError", err)
+	}
+}
+
+func TestRunExecResizeViaAgent_SendsResizeToSession(t *testing.T) {
+	reg := NewReverseAgentRegistry()
+	srv := httptest.NewServer(HandleReverseAgentWS(reg, zerolog.Nop()))
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?session_id=c1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := reg.WaitForAgent(ctx, "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunExecResizeViaAgent(reg, "c1", "exec-1", 40, 120); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunContainerResizeViaAgent(reg, "c1", 50, 160); err != nil {
+		t.Fatal(err)
+	}
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for _, want := range []agent.Message{
+		{Type: agent.TypeResize, ID: "exec-1", Width: 120, Height: 40},
+		{Type: agent.TypeResize, ID: agent.MainProcessSessionID, Width: 160, Height: 50},
+	} {
+		var got agent.Message
+		if err := ws.ReadJSON(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Type != want.Type || got.ID != want.ID || got.Width != want.Width || got.Height != want.Height {
+			t.Errorf("message = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestRunExecResizeViaAgent_NoSession(t *testing.T) {
+	err := RunExecResizeViaAgent(NewReverseAgentRegistry(), "c1", "exec-1", 24, 80)
+	if err != ErrNoReverseAgent {
+		t.Fatalf("err = %v, want ErrNoReverseAgent", err)
+	}
+	var notImpl *api.NotImplementedError
+	if !errors.As(MapResizeErr(err), &notImpl) {
+		t.Errorf("MapResizeErr = %v, want NotImplementedError", MapResizeErr(err))
+	}
+}
@@ -0,0 +1,317 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// statsInterval is the gap between frames of a streaming `docker
+// stats`, matching dockerd.
+const statsInterval = time.Second
+
+// zeroStatsTime is the read / preread value docker reports when there
+// is no earlier sample.
+const zeroStatsTime = "0001-01-01T00:00:00Z"
+
+// agentStatsScript prints the container's CPU, memory and process
+// counters in labelled sections: cgroup v2 files, their cgroup v1
+// equivalents, and /proc as the last resort for sandboxes (gVisor,
+// Firecracker) that expose no cgroup hierarchy.
+const agentStatsScript = `for f in /sys/fs/cgroup/cpu.stat /sys/fs/cgroup/memory.current /sys/fs/cgroup/cpuacct/cpuacct.usage /sys/fs/cgroup/memory/memory.usage_in_bytes /proc/meminfo; do
+  [ -r "$f" ] && { echo "==> $f"; cat "$f"; }
+done
+echo "==> /proc/stat"; head -n 1 /proc/stat
+echo "==> pids"; ls -d /proc/[0-9]* 2>/dev/null | wc -l`
+
+// RunContainerStatsViaAgent samples the container's cgroup counters
+// over the reverse-agent WS. Used by every backend with a reverse-agent
+// path; the values are live, so SampledAt is now.
+func RunContainerStatsViaAgent(reg *ReverseAgentRegistry, containerID string) (*ContainerMetrics, error) {
+	if reg == nil {
+		return nil, ErrNoReverseAgent
+	}
+	stdout, stderr, exit, err := reg.RunAndCapture(containerID, "stats-"+containerID, []string{"sh", "-c", agentStatsScript}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if exit != 0 {
+		return nil, fmt.Errorf("stats sampling failed with exit %d: %s", exit, strings.TrimSpace(string(stderr)))
+	}
+	m, err := ParseAgentStats(string(stdout))
+	if err != nil {
+		return nil, err
+	}
+	m.SampledAt = time.Now().UTC()
+	m.Source = "agent"
+	return m, nil
+}
+
+// ParseAgentStats reads the sections agentStatsScript prints. cgroup
+// counters win over /proc: the cgroup scopes usage to the container,
+// /proc/stat and /proc/meminfo only match it inside a single-tenant
+// sandbox.
+func ParseAgentStats(raw string) (*ContainerMetrics, error) {
+	sections := make(map[string][]string)
+	var cur string
+	sc := bufio.NewScanner(strings.NewReader(raw))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if name, ok := strings.CutPrefix(line, "==> "); ok {
+			cur = name
+			continue
+		}
+		if cur != "" && line != "" {
+			sections[cur] = append(sections[cur], line)
+		}
+	}
+
+	m := &ContainerMetrics{}
+	cpuFound, memFound := false, false
+	for _, l := range sections["/sys/fs/cgroup/cpu.stat"] {
+		if v, ok := strings.CutPrefix(l, "usage_usec "); ok {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				m.CPUNanos, cpuFound = n*1000, true
+			}
+		}
+	}
+	if !cpuFound {
+		if n, ok := firstInt(sections["/sys/fs/cgroup/cpuacct/cpuacct.usage"]); ok {
+			m.CPUNanos, cpuFound = n, true
+		}
+	}
+	if !cpuFound {
+		if lines := sections["/proc/stat"]; len(lines) > 0 {
+			// cpu user nice system idle iowait irq softirq steal — in
+			// USER_HZ (100 Hz) ticks; idle and iowait are not usage.
+			f := strings.Fields(lines[0])
+			if len(f) >= 5 && f[0] == "cpu" {
+				var ticks int64
+				for i, v := range f[1:] {
+					if i == 3 || i == 4 || i >= 8 {
+						continue
+					}
+					n, _ := strconv.ParseInt(v, 10, 64)
+					ticks += n
+				}
+				m.CPUNanos, cpuFound = ticks*int64(10*time.Millisecond), true
+			}
+		}
+	}
+
+	if n, ok := firstInt(sections["/sys/fs/cgroup/memory.current"]); ok {
+		m.MemBytes, memFound = n, true
+	} else if n, ok := firstInt(sections["/sys/fs/cgroup/memory/memory.usage_in_bytes"]); ok {
+		m.MemBytes, memFound = n, true
+	} else if lines := sections["/proc/meminfo"]; len(lines) > 0 {
+		info := make(map[string]int64)
+		for _, l := range lines {
+			f := strings.Fields(l)
+			if len(f) >= 2 {
+				n, _ := strconv.ParseInt(f[1], 10, 64)
+				info[strings.TrimSuffix(f[0], ":")] = n * 1024
+			}
+		}
+		if total, ok := info["MemTotal"]; ok {
+			if avail, ok := info["MemAvailable"]; ok {
+				m.MemBytes, memFound = total-avail, true
+			}
+		}
+	}
+
+	if n, ok := firstInt(sections["pids"]); ok {
+		m.PIDs = int(n)
+	}
+	if !cpuFound || !memFound {
+		return nil, fmt.Errorf("stats sampling: no readable cgroup or /proc counters in the container")
+	}
+	return m, nil
+}
+
+func firstInt(lines []string) (int64, bool) {
+	if len(lines) == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(lines[0]), 10, 64)
+	return n, err == nil
+}
+
+// statsReading is the part of a stats frame the next frame reports as
+// preread / precpu_stats.
+type statsReading struct {
+	read        time.Time
+	cpuNanos    int64
+	systemNanos int64
+}
+
+// statsSampler turns successive StatsProvider answers into docker
+// stats frames for one container. Rate-only sources (CPUCores) are
+// integrated into a cumulative counter between frames so
+// precpu_stats deltas stay meaningful; a failed query repeats the last
+// good values and says so in the frame's metadata.
+type statsSampler struct {
+	s        *BaseServer
+	id       string
+	name     string
+	cpus     int
+	memLimit int64
+	prev     *statsReading
+	last     *ContainerMetrics
+}
+
+func (s *BaseServer) newStatsSampler(c api.Container) *statsSampler {
+	memLimit := int64(1073741824) // 1 GiB default
+	if c.HostConfig.Memory > 0 {
+		memLimit = c.HostConfig.Memory
+	}
+	return &statsSampler{s: s, id: c.ID, name: c.Name, cpus: onlineCPUs(c.HostConfig), memLimit: memLimit}
+}
+
+// onlineCPUs is the CPU count the container's workload was sized
+// with: its CPU limit rounded up (cloud state reports a task's or
+// revision's vCPU there), or 1 when it has none.
+func onlineCPUs(hc api.HostConfig) int {
+	switch {
+	case hc.NanoCPUs > 0:
+		return int((hc.NanoCPUs + 1e9 - 1) / 1e9)
+	case hc.CPUShares > 0:
+		return int((hc.CPUShares + 1023) / 1024)
+	}
+	return 1
+}
+
+// frame samples the provider and returns the stats object for now.
+func (ss *statsSampler) frame(now time.Time) map[string]any {
+	meta := map[string]any{"source": "none"}
+	var m *ContainerMetrics
+	if ss.s.StatsProvider != nil {
+		var err error
+		m, err = ss.s.StatsProvider.ContainerMetrics(ss.id)
+		if err != nil {
+			meta["error"] = err.Error()
+			m = ss.last
+		} else if m != nil {
+			ss.last = m
+		}
+	}
+	if m == nil {
+		m = &ContainerMetrics{}
+	}
+	if m.Source != "" {
+		meta["source"] = m.Source
+	}
+	if !m.SampledAt.IsZero() {
+		meta["sampled_at"] = m.SampledAt.UTC().Format(time.RFC3339Nano)
+		meta["staleness_seconds"] = now.Sub(m.SampledAt).Seconds()
+	}
+
+	// system_cpu_usage counts every online CPU, as the host's would:
+	// docker computes CPU% as cpu delta / system delta * online_cpus.
+	cur := statsReading{read: now, cpuNanos: m.CPUNanos, systemNanos: now.UnixNano() * int64(ss.cpus)}
+	prev := statsReading{}
+	preread := zeroStatsTime
+	if ss.prev != nil {
+		prev = *ss.prev
+		preread = prev.read.Format(time.RFC3339Nano)
+		if m.CPUNanos == 0 && m.CPUCores > 0 {
+			cur.cpuNanos = prev.cpuNanos + int64(m.CPUCores*float64(now.Sub(prev.read)))
+		}
+		// A source switch (agent connected or gone) restarts the
+		// counter; report a zero delta rather than a negative one.
+		if cur.cpuNanos < prev.cpuNanos {
+			prev.cpuNanos = cur.cpuNanos
+		}
+	}
+	ss.prev = &cur
+
+	// No source measures network bytes, so "networks" is left out
+	// rather than reported as zero counters.
+	return map[string]any{
+		"id":      ss.id,
+		"name":    ss.name,
+		"read":    now.Format(time.RFC3339Nano),
+		"preread": preread,
+		"cpu_stats": map[string]any{
+			"cpu_usage":        map[string]any{"total_usage": cur.cpuNanos},
+			"online_cpus":      ss.cpus,
+			"system_cpu_usage": cur.systemNanos,
+		},
+		"precpu_stats": map[string]any{
+			"cpu_usage":        map[string]any{"total_usage": prev.cpuNanos},
+			"online_cpus":      ss.cpus,
+			"system_cpu_usage": prev.systemNanos,
+		},
+		"memory_stats": map[string]any{
+			"usage": m.MemBytes,
+			"limit": ss.memLimit,
+		},
+		"pids_stats": map[string]any{
+			"current": m.PIDs,
+		},
+		"sockerless": meta,
+	}
+}
+
+// ContainerStats returns resource usage stats for a container. A
+// one-shot read carries the previous one-shot's reading into
+// precpu_stats; a stream emits a frame every statsInterval until the
+// container stops or the reader is closed.
+func (s *BaseServer) ContainerStats(ref string, stream bool) (io.ReadCloser, error) {
+	c, ok := s.ResolveContainerAuto(context.Background(), ref)
+	if !ok {
+		return nil, &api.NotFoundError{Resource: "container", ID: ref}
+	}
+	ss := s.newStatsSampler(c)
+
+	if !stream || !c.State.Running {
+		if v, ok := s.Store.PrevCPUStats.Load(c.ID); ok {
+			ss.prev = v.(*statsReading)
+		}
+		data, err := json.Marshal(ss.frame(time.Now().UTC()))
+		if err != nil {
+			return nil, err
+		}
+		s.Store.PrevCPUStats.Store(c.ID, ss.prev)
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		enc := json.NewEncoder(pw)
+		for {
+			if err := enc.Encode(ss.frame(time.Now().UTC())); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			time.Sleep(statsInterval)
+			if !s.statsContainerRunning(c.ID) {
+				_ = pw.Close()
+				return
+			}
+		}
+	}()
+	return pr, nil
+}
+
+// statsContainerRunning reports whether a stats stream should keep
+// going: the container still exists and has not exited.
+func (s *BaseServer) statsContainerRunning(id string) bool {
+	if ch, ok := s.Store.WaitChs.Load(id); ok {
+		select {
+		case <-ch.(chan struct{}):
+			return false
+		default:
+			return true
+		}
+	}
+	cur, ok := s.ResolveContainerAuto(context.Background(), id)
+	return ok && cur.State.Running
+}
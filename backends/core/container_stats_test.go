@@ -0,0 +1,249 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+type fakeStatsProvider struct {
+	calls   atomic.Int32
+	metrics func(n int32) *ContainerMetrics
+}
+
+func (p *fakeStatsProvider) ContainerMetrics(string) (*ContainerMetrics, error) {
+	return p.metrics(p.calls.Add(1)), nil
+}
+
+type statsFrame struct {
+	Read     string `json:"read"`
+	Preread  string `json:"preread"`
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage int64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		OnlineCPUs     int   `json:"online_cpus"`
+		SystemCPUUsage int64 `json:"system_cpu_usage"`
+	} `json:"cpu_stats"`
+	PreCPUStats struct {
+		CPUUsage struct {
+			TotalUsage int64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemCPUUsage int64 `json:"system_cpu_usage"`
+	} `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage int64 `json:"usage"`
+	} `json:"memory_stats"`
+	Networks   map[string]any `json:"networks"`
+	Sockerless struct {
+		Source           string  `json:"source"`
+		SampledAt        string  `json:"sampled_at"`
+		StalenessSeconds float64 `json:"staleness_seconds"`
+		Error            string  `json:"error"`
+	} `json:"sockerless"`
+}
+
+func TestParseAgentStats(t *testing.T) {
+	cases := []struct {
+		name     string
+		raw      string
+		cpu, mem int64
+		pids     int
+		wantErr  bool
+	}{
+		{
+			name: "cgroup v2",
+			raw:  "==> /sys/fs/cgroup/cpu.stat\nusage_usec 2500\nuser_usec 2000\n==> /sys/fs/cgroup/memory.current\n4096\n==> /proc/meminfo\nMemTotal: 100 kB\nMemAvailable: 50 kB\n==> /proc/stat\ncpu 1 2 3 4 5 6 7 8 9 10\n==> pids\n3\n",
+			cpu:  2500000, mem: 4096, pids: 3,
+		},
+		{
+			name: "cgroup v1",
+			raw:  "==> /sys/fs/cgroup/cpuacct/cpuacct.usage\n123456\n==> /sys/fs/cgroup/memory/memory.usage_in_bytes\n8192\n==> pids\n1\n",
+			cpu:  123456, mem: 8192, pids: 1,
+		},
+		{
+			name: "proc only",
+			raw:  "==> /proc/meminfo\nMemTotal: 1000 kB\nMemFree: 10 kB\nMemAvailable: 400 kB\n==> /proc/stat\ncpu 10 1 5 900 30 2 2 0 0 0\n==> pids\n2\n",
+			cpu:  20 * int64(10*time.Millisecond), mem: 600 * 1024, pids: 2,
+		},
+		{
+			name:    "no counters",
+			raw:     "==> pids\n1\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := ParseAgentStats(tc.raw)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.CPUNanos != tc.cpu || m.MemBytes != tc.mem || m.PIDs != tc.pids {
+				t.Errorf("got cpu=%d mem=%d pids=%d, want cpu=%d mem=%d pids=%d", m.CPUNanos, m.MemBytes, m.PIDs, tc.cpu, tc.mem, tc.pids)
+			}
+		})
+	}
+}
+
+func TestContainerStats_StreamCarriesPreviousFrame(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	createTestContainer(s, "stats-stream", nil)
+	s.StatsProvider = &fakeStatsProvider{metrics: func(n int32) *ContainerMetrics {
+		return &ContainerMetrics{CPUNanos: int64(n) * 1e8, MemBytes: 1 << 20, SampledAt: time.Now(), Source: "agent"}
+	}}
+
+	rc, err := s.ContainerStats("stats-stream", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	dec := json.NewDecoder(rc)
+	var first, second statsFrame
+	if err := dec.Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&second); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Preread != zeroStatsTime {
+		t.Errorf("first preread = %q, want %q", first.Preread, zeroStatsTime)
+	}
+	if second.Preread != first.Read {
+		t.Errorf("second preread = %q, want the first read %q", second.Preread, first.Read)
+	}
+	if second.PreCPUStats.CPUUsage.TotalUsage != first.CPUStats.CPUUsage.TotalUsage {
+		t.Errorf("precpu = %d, want %d", second.PreCPUStats.CPUUsage.TotalUsage, first.CPUStats.CPUUsage.TotalUsage)
+	}
+	if second.CPUStats.CPUUsage.TotalUsage != 2e8 {
+		t.Errorf("cpu = %d, want 2e8", second.CPUStats.CPUUsage.TotalUsage)
+	}
+	if second.Sockerless.Source != "agent" {
+		t.Errorf("source = %q", second.Sockerless.Source)
+	}
+}
+
+func TestContainerStats_StreamEndsWhenContainerStops(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	createTestContainer(s, "stats-stop", nil)
+	exitCh := make(chan struct{})
+	s.Store.WaitChs.Store("stats-stop", exitCh)
+
+	rc, err := s.ContainerStats("stats-stop", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	close(exitCh)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(rc)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("stream ended with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end after the container stopped")
+	}
+}
+
+func TestContainerStats_CloudRateIntegratedWithStaleness(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	createTestContainer(s, "stats-cloud", nil)
+	s.Store.Containers.Update("stats-cloud", func(c *api.Container) {
+		c.HostConfig.NanoCPUs = 1.5e9
+		c.NetworkSettings.Networks["bridge"] = &api.EndpointSettings{NetworkID: "bridge"}
+	})
+	sampled := time.Now().Add(-90 * time.Second)
+	cloud := &fakeStatsProvider{metrics: func(int32) *ContainerMetrics {
+		return &ContainerMetrics{CPUCores: 0.5, MemBytes: 64 << 20, SampledAt: sampled, Source: "cloudwatch"}
+	}}
+	s.StatsProvider = &StatsSources{Cloud: cloud}
+
+	read := func() statsFrame {
+		rc, err := s.ContainerStats("stats-cloud", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		var f statsFrame
+		if err := json.NewDecoder(rc).Decode(&f); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	first := read()
+	time.Sleep(50 * time.Millisecond)
+	second := read()
+
+	if got := cloud.calls.Load(); got != 1 {
+		t.Errorf("cloud queried %d times, want 1 within the refresh window", got)
+	}
+	if second.Preread != first.Read {
+		t.Errorf("preread = %q, want %q", second.Preread, first.Read)
+	}
+	delta := second.CPUStats.CPUUsage.TotalUsage - second.PreCPUStats.CPUUsage.TotalUsage
+	if delta < int64(20*time.Millisecond) || delta > int64(time.Second) {
+		t.Errorf("cpu delta = %d ns, want ~0.5 cores over the gap between reads", delta)
+	}
+	if second.Sockerless.Source != "cloudwatch" || second.Sockerless.StalenessSeconds < 90 {
+		t.Errorf("metadata = %+v, want cloudwatch with staleness >= 90s", second.Sockerless)
+	}
+	if second.MemoryStats.Usage != 64<<20 {
+		t.Errorf("memory = %d", second.MemoryStats.Usage)
+	}
+	if second.CPUStats.OnlineCPUs != 2 {
+		t.Errorf("online_cpus = %d, want 2 for a 1.5 CPU limit", second.CPUStats.OnlineCPUs)
+	}
+	// docker stats: cpu delta / system delta * online_cpus * 100.
+	systemDelta := second.CPUStats.SystemCPUUsage - second.PreCPUStats.SystemCPUUsage
+	percent := float64(delta) / float64(systemDelta) * float64(second.CPUStats.OnlineCPUs) * 100
+	if percent < 49 || percent > 51 {
+		t.Errorf("cpu percent = %.1f, want 50 for 0.5 cores", percent)
+	}
+	if second.Networks != nil {
+		t.Errorf("networks = %v, want none without a network source", second.Networks)
+	}
+}
+
+func TestHandleContainerStats_StreamsOverHTTP(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	createTestContainer(s, "stats-http", nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.SetPathValue("id", "stats-http")
+		s.handleContainerStats(w, r)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?stream=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	dec := json.NewDecoder(resp.Body)
+	for i := 0; i < 2; i++ {
+		var f statsFrame
+		if err := dec.Decode(&f); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,809 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// Cost accounting. Every container run is priced from the shape of the
+// cloud workload the backend launched for it (vCPU, memory, ephemeral
+// storage, mounted volumes) and its runtime, using a versioned local
+// price table. Runs are attributed to the container, its compose
+// project and its labels; per-label budgets can refuse ContainerStart
+// once they are spent. The figures are estimates from list prices —
+// free tiers, discounts and data transfer are not modelled.
+
+// Workload kinds: the billing model a container run is priced under.
+const (
+	CostKindFargate           = "fargate"
+	CostKindFargateSpot       = "fargate-spot"
+	CostKindLambda            = "lambda"
+	CostKindCloudRun          = "cloudrun"
+	CostKindCloudRunFunctions = "cloudrun-functions"
+	CostKindACA               = "aca"
+	CostKindAzureFunctions    = "azure-functions"
+)
+
+// Storage classes named volumes are billed under.
+const (
+	CostStorageEFS        = "efs"
+	CostStorageGCS        = "gcs"
+	CostStorageAzureFiles = "azure-files"
+)
+
+// Cost report groupings; any other grouping is "label:<key>".
+const (
+	CostGroupContainer = "container"
+	CostGroupProject   = "project"
+	CostGroupBackend   = "backend"
+	costGroupLabel     = "label:"
+)
+
+// Budget periods.
+const (
+	CostPeriodMonth = "month"
+	CostPeriodDay   = "day"
+	CostPeriodTotal = "total"
+)
+
+// secondsPerMonth converts GiB-month storage prices to GiB-seconds
+// (730 hours, the month the cloud price sheets use).
+const secondsPerMonth = 730 * 3600
+
+// maxCostRuns bounds the finished runs kept in memory; the oldest are
+// dropped first.
+const maxCostRuns = 50000
+
+// CostRates are the list prices of one workload kind, in the table's
+// currency.
+type CostRates struct {
+	VCPUSecond           float64 `json:"vcpuSecond,omitempty"`
+	GiBSecond            float64 `json:"gibSecond,omitempty"`          // memory
+	Request              float64 `json:"request,omitempty"`            // per invocation
+	EphemeralGiBSecond   float64 `json:"ephemeralGibSecond,omitempty"` // beyond IncludedEphemeralGiB
+	IncludedEphemeralGiB float64 `json:"includedEphemeralGib,omitempty"`
+	MinimumSeconds       float64 `json:"minimumSeconds,omitempty"` // shortest billed run
+}
+
+// PriceTable is a versioned set of list prices. Version is reported
+// with every cost figure so estimates made under different tables can
+// be told apart.
+type PriceTable struct {
+	Version   string               `json:"version"`
+	Currency  string               `json:"currency"`
+	Workloads map[string]CostRates `json:"workloads"`
+	Storage   map[string]float64   `json:"storage"` // per GiB-month
+}
+
+// DefaultPriceTable returns the built-in on-demand list prices
+// (us-east-1, us-central1, eastus).
+func DefaultPriceTable() *PriceTable {
+	return &PriceTable{
+		Version:  "2026-10-01",
+		Currency: "USD",
+		Workloads: map[string]CostRates{
+			CostKindFargate: {
+				VCPUSecond: 0.04048 / 3600, GiBSecond: 0.004445 / 3600,
+				EphemeralGiBSecond: 0.000111 / 3600, IncludedEphemeralGiB: 20, MinimumSeconds: 60,
+			},
+			CostKindFargateSpot: {
+				VCPUSecond: 0.01214398 / 3600, GiBSecond: 0.00133325 / 3600,
+				EphemeralGiBSecond: 0.000111 / 3600, IncludedEphemeralGiB: 20, MinimumSeconds: 60,
+			},
+			CostKindLambda: {
+				GiBSecond: 0.0000166667, Request: 0.20 / 1e6,
+				EphemeralGiBSecond: 0.0000000309, IncludedEphemeralGiB: 0.5,
+			},
+			CostKindCloudRun:          {VCPUSecond: 0.000018, GiBSecond: 0.000002},
+			CostKindCloudRunFunctions: {VCPUSecond: 0.000024, GiBSecond: 0.0000025, Request: 0.40 / 1e6},
+			CostKindACA:               {VCPUSecond: 0.000024, GiBSecond: 0.000003},
+			CostKindAzureFunctions:    {GiBSecond: 0.000026, Request: 0.40 / 1e6},
+		},
+		Storage: map[string]float64{
+			CostStorageEFS:        0.30,
+			CostStorageGCS:        0.020,
+			CostStorageAzureFiles: 0.06,
+		},
+	}
+}
+
+// LoadPriceTable reads a JSON price table from path and lays it over
+// the built-in one: workload kinds and storage classes it names replace
+// the defaults, the rest are kept. The file must carry a version.
+func LoadPriceTable(path string) (*PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file PriceTable
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if file.Version == "" {
+		return nil, fmt.Errorf("%s: price table has no version", path)
+	}
+	pt := DefaultPriceTable()
+	pt.Version = file.Version
+	if file.Currency != "" {
+		pt.Currency = file.Currency
+	}
+	for kind, rates := range file.Workloads {
+		pt.Workloads[kind] = rates
+	}
+	for class, price := range file.Storage {
+		pt.Storage[class] = price
+	}
+	return pt, nil
+}
+
+// CostShape is the billed shape of a container's cloud workload.
+type CostShape struct {
+	Kind         string  `json:"kind"`
+	VCPU         float64 `json:"vcpu,omitempty"`
+	MemoryGiB    float64 `json:"memoryGib,omitempty"`
+	EphemeralGiB float64 `json:"ephemeralGib,omitempty"`
+	// StorageClass is what the backend's named volumes are billed as;
+	// StorageGiB is the size attributed to the run's mounted volumes.
+	StorageClass string  `json:"storageClass,omitempty"`
+	StorageGiB   float64 `json:"storageGib,omitempty"`
+	// Unit names the workload when several containers share it (a
+	// pod's single task): the shape is then the whole workload's and
+	// Share the fraction of it this container is billed for, so the
+	// members of a unit add up to one workload.
+	Unit  string  `json:"unit,omitempty"`
+	Share float64 `json:"share,omitempty"`
+}
+
+// CostShaper is implemented by backends to report the shape they
+// launch a container's workload with.
+type CostShaper interface {
+	ContainerCostShape(c *api.Container) CostShape
+}
+
+// CostAmount is a priced run or group, broken down by dimension.
+type CostAmount struct {
+	CPU       float64 `json:"cpu"`
+	Memory    float64 `json:"memory"`
+	Requests  float64 `json:"requests"`
+	Ephemeral float64 `json:"ephemeral"`
+	Storage   float64 `json:"storage"`
+	Total     float64 `json:"total"`
+}
+
+func (a *CostAmount) add(b CostAmount) {
+	a.CPU += b.CPU
+	a.Memory += b.Memory
+	a.Requests += b.Requests
+	a.Ephemeral += b.Ephemeral
+	a.Storage += b.Storage
+	a.Total += b.Total
+}
+
+// Estimate prices shape running for seconds with requests invocations.
+// Shapes of a kind the table does not know cost nothing. A shared
+// shape is billed at its Share; its StorageGiB is the container's own.
+func (pt *PriceTable) Estimate(shape CostShape, seconds float64, requests int) CostAmount {
+	rates, ok := pt.Workloads[shape.Kind]
+	if !ok {
+		return CostAmount{}
+	}
+	if seconds < rates.MinimumSeconds && requests > 0 {
+		seconds = rates.MinimumSeconds
+	}
+	a := CostAmount{
+		CPU:      shape.VCPU * seconds * rates.VCPUSecond,
+		Memory:   shape.MemoryGiB * seconds * rates.GiBSecond,
+		Requests: float64(requests) * rates.Request,
+	}
+	if extra := shape.EphemeralGiB - rates.IncludedEphemeralGiB; extra > 0 {
+		a.Ephemeral = extra * seconds * rates.EphemeralGiBSecond
+	}
+	if shape.Share > 0 {
+		a.CPU *= shape.Share
+		a.Memory *= shape.Share
+		a.Requests *= shape.Share
+		a.Ephemeral *= shape.Share
+	}
+	if shape.StorageGiB > 0 {
+		a.Storage = shape.StorageGiB * seconds * pt.Storage[shape.StorageClass] / secondsPerMonth
+	}
+	a.Total = a.CPU + a.Memory + a.Requests + a.Ephemeral + a.Storage
+	return a
+}
+
+// CostRun is one start-to-stop run of a container.
+type CostRun struct {
+	ContainerID string            `json:"containerId"`
+	Name        string            `json:"name,omitempty"`
+	Backend     string            `json:"backend"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Shape       CostShape         `json:"shape"`
+	Started     time.Time         `json:"started"`
+	Finished    time.Time         `json:"finished,omitempty"`
+}
+
+// window prices the part of r inside [since, until]. The invocation
+// and any minimum billed duration count where the run started.
+func (r *CostRun) window(pt *PriceTable, since, until time.Time) (CostAmount, float64, bool) {
+	end := r.Finished
+	if end.IsZero() || end.After(until) {
+		end = until
+	}
+	start := r.Started
+	if start.Before(since) {
+		start = since
+	}
+	if r.Started.After(until) || end.Before(start) {
+		return CostAmount{}, 0, false
+	}
+	seconds := end.Sub(start).Seconds()
+	requests := 0
+	if !r.Started.Before(since) && !r.Started.After(until) {
+		requests = 1
+	}
+	return pt.Estimate(r.Shape, seconds, requests), seconds, true
+}
+
+// CostBudget caps spending per budget period on containers carrying
+// Label=Value. Value "*" gives every value of Label its own limit.
+type CostBudget struct {
+	Label string  `json:"label"`
+	Value string  `json:"value"`
+	Limit float64 `json:"limit"`
+}
+
+// ParseCostBudgets parses "label=value:limit" entries separated by
+// commas, e.g. "team=payments:50,com.docker.compose.project=*:5".
+func ParseCostBudgets(spec string) ([]CostBudget, error) {
+	var out []CostBudget
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		sel, limitStr, ok := cutLast(entry, ":")
+		label, value, hasValue := strings.Cut(sel, "=")
+		if !ok || !hasValue || label == "" || value == "" {
+			return nil, fmt.Errorf("budget %q: want label=value:limit", entry)
+		}
+		limit, err := strconv.ParseFloat(limitStr, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("budget %q: limit must be a non-negative number", entry)
+		}
+		out = append(out, CostBudget{Label: label, Value: value, Limit: limit})
+	}
+	return out, nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// CostConfig configures cost accounting on a backend.
+type CostConfig struct {
+	// PricesPath is a JSON price table laid over the built-in one.
+	PricesPath string
+	// LedgerPath is an append-only JSONL file of finished runs, replayed
+	// on startup so totals and budgets survive a restart. Empty keeps
+	// the ledger in memory.
+	LedgerPath string
+	// Budgets is the ParseCostBudgets spec; BudgetPeriod is month
+	// (default), day or total.
+	Budgets      string
+	BudgetPeriod string
+	// VolumeGiB is the size each mounted named volume is billed at.
+	// sockerless does not meter volume contents, so this is the
+	// operator's estimate (default 1 GiB).
+	VolumeGiB float64
+}
+
+// CostConfigFromEnv reads SOCKERLESS_COST_PRICES, SOCKERLESS_COST_LEDGER,
+// SOCKERLESS_COST_BUDGETS, SOCKERLESS_COST_BUDGET_PERIOD and
+// SOCKERLESS_COST_VOLUME_GIB.
+func CostConfigFromEnv() CostConfig {
+	c := CostConfig{
+		PricesPath:   strings.TrimSpace(os.Getenv("SOCKERLESS_COST_PRICES")),
+		LedgerPath:   strings.TrimSpace(os.Getenv("SOCKERLESS_COST_LEDGER")),
+		Budgets:      os.Getenv("SOCKERLESS_COST_BUDGETS"),
+		BudgetPeriod: strings.ToLower(strings.TrimSpace(os.Getenv("SOCKERLESS_COST_BUDGET_PERIOD"))),
+		VolumeGiB:    1,
+	}
+	if v := strings.TrimSpace(os.Getenv("SOCKERLESS_COST_VOLUME_GIB")); v != "" {
+		gib, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			gib = math.NaN() // rejected by Validate
+		}
+		c.VolumeGiB = gib
+	}
+	return c
+}
+
+// Validate loads the price table and parses the budgets.
+func (c CostConfig) Validate() error {
+	if c.PricesPath != "" {
+		if _, err := LoadPriceTable(c.PricesPath); err != nil {
+			return fmt.Errorf("SOCKERLESS_COST_PRICES: %w", err)
+		}
+	}
+	if _, err := ParseCostBudgets(c.Budgets); err != nil {
+		return fmt.Errorf("SOCKERLESS_COST_BUDGETS: %w", err)
+	}
+	switch c.BudgetPeriod {
+	case "", CostPeriodMonth, CostPeriodDay, CostPeriodTotal:
+	default:
+		return fmt.Errorf("SOCKERLESS_COST_BUDGET_PERIOD=%q not supported (one of month, day, total)", c.BudgetPeriod)
+	}
+	if math.IsNaN(c.VolumeGiB) || c.VolumeGiB < 0 {
+		return fmt.Errorf("SOCKERLESS_COST_VOLUME_GIB must be a non-negative number of GiB")
+	}
+	return nil
+}
+
+// CostTracker records container runs and prices them.
+type CostTracker struct {
+	mu        sync.Mutex
+	prices    *PriceTable
+	budgets   []CostBudget
+	period    string
+	volumeGiB float64
+	runs      []CostRun           // finished, oldest first
+	open      map[string]*CostRun // running, by container ID
+	ledger    *os.File
+	now       func() time.Time
+}
+
+// NewCostTracker builds a tracker from a validated config, replaying
+// the ledger file when one is configured.
+func NewCostTracker(c CostConfig) (*CostTracker, error) {
+	t := &CostTracker{
+		prices:    DefaultPriceTable(),
+		period:    c.BudgetPeriod,
+		volumeGiB: c.VolumeGiB,
+		open:      make(map[string]*CostRun),
+		now:       time.Now,
+	}
+	if t.period == "" {
+		t.period = CostPeriodMonth
+	}
+	if c.PricesPath != "" {
+		pt, err := LoadPriceTable(c.PricesPath)
+		if err != nil {
+			return nil, err
+		}
+		t.prices = pt
+	}
+	budgets, err := ParseCostBudgets(c.Budgets)
+	if err != nil {
+		return nil, err
+	}
+	t.budgets = budgets
+	if c.LedgerPath != "" {
+		if err := t.replay(c.LedgerPath); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(c.LedgerPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open cost ledger: %w", err)
+		}
+		t.ledger = f
+	}
+	return t, nil
+}
+
+// replay loads finished runs from the ledger. Lines that do not parse
+// (a torn final write) are skipped.
+func (t *CostTracker) replay(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read cost ledger: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var run CostRun
+		if json.Unmarshal(sc.Bytes(), &run) == nil && run.ContainerID != "" && !run.Finished.IsZero() {
+			t.appendRun(run)
+		}
+	}
+	return sc.Err()
+}
+
+func (t *CostTracker) appendRun(run CostRun) {
+	if len(t.runs) >= maxCostRuns {
+		t.runs = t.runs[1:]
+	}
+	t.runs = append(t.runs, run)
+}
+
+// Start opens a run for c at started. A run already open for c is left
+// as is.
+func (t *CostTracker) Start(c *api.Container, backend string, shape CostShape, started time.Time) {
+	for _, m := range c.Mounts {
+		if m.Type == "volume" && m.Name != "" {
+			shape.StorageGiB += t.volumeGiB
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.open[c.ID]; ok {
+		return
+	}
+	t.open[c.ID] = &CostRun{
+		ContainerID: c.ID,
+		Name:        strings.TrimPrefix(c.Name, "/"),
+		Backend:     backend,
+		Labels:      c.Config.Labels,
+		Shape:       shape,
+		Started:     started,
+	}
+}
+
+// Running reports whether a run is open for id.
+func (t *CostTracker) Running(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.open[id]
+	return ok
+}
+
+// Stop closes the open run of id, if any, and appends it to the ledger.
+func (t *CostTracker) Stop(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	run, ok := t.open[id]
+	if !ok {
+		return
+	}
+	delete(t.open, id)
+	run.Finished = t.now()
+	t.appendRun(*run)
+	if t.ledger != nil {
+		if line, err := json.Marshal(run); err == nil {
+			_, _ = t.ledger.Write(append(line, '\n'))
+		}
+	}
+}
+
+// Close closes the ledger file.
+func (t *CostTracker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ledger == nil {
+		return nil
+	}
+	err := t.ledger.Close()
+	t.ledger = nil
+	return err
+}
+
+// CostGroup is one row of a cost report.
+type CostGroup struct {
+	Key     string     `json:"key"`
+	Cost    CostAmount `json:"cost"`
+	Runs    int        `json:"runs"`
+	Running int        `json:"running"`
+	Seconds float64    `json:"seconds"`
+}
+
+// BudgetStatus is a budget's spending in the current period.
+type BudgetStatus struct {
+	Label       string    `json:"label"`
+	Value       string    `json:"value"`
+	Limit       float64   `json:"limit"`
+	Spent       float64   `json:"spent"`
+	Remaining   float64   `json:"remaining"`
+	Exhausted   bool      `json:"exhausted"`
+	PeriodStart time.Time `json:"periodStart,omitempty"`
+}
+
+// CostReport is the response of /internal/v1/costs.
+type CostReport struct {
+	PriceVersion string         `json:"priceVersion"`
+	Currency     string         `json:"currency"`
+	GroupBy      string         `json:"groupBy"`
+	Since        time.Time      `json:"since,omitempty"`
+	Until        time.Time      `json:"until"`
+	Total        CostAmount     `json:"total"`
+	Groups       []CostGroup    `json:"groups"`
+	Budgets      []BudgetStatus `json:"budgets"`
+}
+
+// ValidCostGroup reports whether groupBy is a supported grouping.
+func ValidCostGroup(groupBy string) bool {
+	switch groupBy {
+	case CostGroupContainer, CostGroupProject, CostGroupBackend:
+		return true
+	}
+	return strings.HasPrefix(groupBy, costGroupLabel) && len(groupBy) > len(costGroupLabel)
+}
+
+// groupKey is the key run is attributed to under groupBy; empty when
+// the run lacks the project or label grouped on.
+func groupKey(run *CostRun, groupBy string) string {
+	switch groupBy {
+	case CostGroupContainer:
+		id := run.ContainerID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		if run.Name != "" {
+			return id + " " + run.Name
+		}
+		return id
+	case CostGroupProject:
+		return run.Labels[ComposeProjectLabel]
+	case CostGroupBackend:
+		return run.Backend
+	}
+	return run.Labels[strings.TrimPrefix(groupBy, costGroupLabel)]
+}
+
+// Report prices every run overlapping [since, now] grouped by groupBy.
+// Runs without the grouped project or label are reported under "".
+func (t *CostTracker) Report(groupBy string, since time.Time) CostReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	rep := CostReport{
+		PriceVersion: t.prices.Version,
+		Currency:     t.prices.Currency,
+		GroupBy:      groupBy,
+		Since:        since,
+		Until:        now,
+		Groups:       []CostGroup{},
+	}
+	groups := map[string]*CostGroup{}
+	t.eachRun(func(run *CostRun, running bool) {
+		cost, seconds, ok := run.window(t.prices, since, now)
+		if !ok {
+			return
+		}
+		key := groupKey(run, groupBy)
+		g := groups[key]
+		if g == nil {
+			g = &CostGroup{Key: key}
+			groups[key] = g
+		}
+		g.Cost.add(cost)
+		g.Seconds += seconds
+		g.Runs++
+		if running {
+			g.Running++
+		}
+		rep.Total.add(cost)
+	})
+	for _, g := range groups {
+		rep.Groups = append(rep.Groups, *g)
+	}
+	sort.Slice(rep.Groups, func(i, j int) bool {
+		if rep.Groups[i].Cost.Total != rep.Groups[j].Cost.Total {
+			return rep.Groups[i].Cost.Total > rep.Groups[j].Cost.Total
+		}
+		return rep.Groups[i].Key < rep.Groups[j].Key
+	})
+	rep.Budgets = t.budgetStatusLocked(now)
+	return rep
+}
+
+func (t *CostTracker) eachRun(fn func(run *CostRun, running bool)) {
+	for i := range t.runs {
+		fn(&t.runs[i], false)
+	}
+	for _, run := range t.open {
+		fn(run, true)
+	}
+}
+
+// periodStart is the start of the budget period containing now.
+func (t *CostTracker) periodStart(now time.Time) time.Time {
+	now = now.UTC()
+	switch t.period {
+	case CostPeriodDay:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	case CostPeriodTotal:
+		return time.Time{}
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// budgetStatusLocked reports every budget; a wildcard budget reports
+// one status per label value seen this period.
+func (t *CostTracker) budgetStatusLocked(now time.Time) []BudgetStatus {
+	out := []BudgetStatus{}
+	if len(t.budgets) == 0 {
+		return out
+	}
+	start := t.periodStart(now)
+	for _, b := range t.budgets {
+		spent := map[string]float64{}
+		if b.Value != "*" {
+			spent[b.Value] = 0
+		}
+		t.eachRun(func(run *CostRun, _ bool) {
+			v, ok := run.Labels[b.Label]
+			if !ok || (b.Value != "*" && v != b.Value) {
+				return
+			}
+			if cost, _, ok := run.window(t.prices, start, now); ok {
+				spent[v] += cost.Total
+			}
+		})
+		values := make([]string, 0, len(spent))
+		for v := range spent {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		for _, v := range values {
+			out = append(out, BudgetStatus{
+				Label:       b.Label,
+				Value:       v,
+				Limit:       b.Limit,
+				Spent:       spent[v],
+				Remaining:   math.Max(b.Limit-spent[v], 0),
+				Exhausted:   spent[v] >= b.Limit,
+				PeriodStart: start,
+			})
+		}
+	}
+	return out
+}
+
+// CheckBudget refuses a container carrying labels once any budget that
+// covers it is spent for the current period.
+func (t *CostTracker) CheckBudget(labels map[string]string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.budgets) == 0 {
+		return nil
+	}
+	for _, st := range t.budgetStatusLocked(t.now()) {
+		if v, ok := labels[st.Label]; !st.Exhausted || !ok || v != st.Value {
+			continue
+		}
+		return &api.ForbiddenError{Message: fmt.Sprintf(
+			"cost budget for %s=%s exhausted: spent %.2f of %.2f %s this %s",
+			st.Label, st.Value, st.Spent, st.Limit, t.prices.Currency, t.periodName())}
+	}
+	return nil
+}
+
+func (t *CostTracker) periodName() string {
+	if t.period == CostPeriodTotal {
+		return "period"
+	}
+	return t.period
+}
+
+var errNoCostAccounting = &api.NotImplementedError{Message: "cost accounting is not available on this backend"}
+
+// ConfigureCosts turns on cost accounting with shaper reporting the
+// workload shapes. A ledger that cannot be opened is logged and the
+// tracker falls back to memory.
+func (s *BaseServer) ConfigureCosts(c CostConfig, shaper CostShaper) {
+	t, err := NewCostTracker(c)
+	if err != nil && c.LedgerPath != "" {
+		s.Logger.Warn().Err(err).Str("ledger", c.LedgerPath).Msg("cost ledger unavailable, keeping costs in memory")
+		c.LedgerPath = ""
+		t, err = NewCostTracker(c)
+	}
+	if err != nil {
+		s.Logger.Warn().Err(err).Msg("cost accounting disabled")
+		return
+	}
+	s.Costs = t
+	s.CostShaper = shaper
+}
+
+// observeCost feeds container start / die / destroy events to the cost
+// tracker. A die for a container with no open run (started before the
+// backend restarted) opens one at the container's recorded start time
+// first, so the run is still accounted.
+func (s *BaseServer) observeCost(action, id string) {
+	if s.Costs == nil || s.CostShaper == nil {
+		return
+	}
+	switch action {
+	case "start":
+		if c, ok := s.ResolveContainerAuto(context.Background(), id); ok {
+			s.Costs.Start(&c, s.Desc.Driver, s.CostShaper.ContainerCostShape(&c), s.Costs.now())
+		}
+	case "die", "destroy":
+		if !s.Costs.Running(id) && action == "die" {
+			c, ok := s.ResolveContainerAuto(context.Background(), id)
+			if !ok {
+				return
+			}
+			started, err := time.Parse(time.RFC3339Nano, c.State.StartedAt)
+			if err != nil || started.Year() < 2000 {
+				return
+			}
+			s.Costs.Start(&c, s.Desc.Driver, s.CostShaper.ContainerCostShape(&c), started)
+		}
+		s.Costs.Stop(id)
+	}
+}
+
+// checkCostBudget applies the budgets to a container about to start.
+// Every start path — start, restart, pod start and migration — runs
+// it before handing the container to the backend.
+func (s *BaseServer) checkCostBudget(ctx context.Context, ref string) error {
+	if s.Costs == nil {
+		return nil
+	}
+	c, ok := s.ResolveContainerAuto(ctx, ref)
+	if !ok {
+		return nil // ContainerStart reports the missing container
+	}
+	return s.Costs.CheckBudget(c.Config.Labels)
+}
+
+// checkPodCostBudget applies the budgets to every stopped member of a
+// pod about to start.
+func (s *BaseServer) checkPodCostBudget(name string) error {
+	if s.Costs == nil {
+		return nil
+	}
+	pod, ok := s.Store.Pods.GetPod(name)
+	if !ok {
+		return nil // PodStart reports the missing pod
+	}
+	for _, cid := range pod.ContainerIDs {
+		c, ok := s.Store.Containers.Get(cid)
+		if !ok || c.State.Running {
+			continue
+		}
+		if err := s.Costs.CheckBudget(c.Config.Labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleCosts serves GET /internal/v1/costs?group_by=&since=. since is
+// RFC 3339 or a duration back from now ("24h"); the default is the
+// start of the budget period.
+func (s *BaseServer) handleCosts(w http.ResponseWriter, r *http.Request) {
+	if s.Costs == nil {
+		WriteError(w, errNoCostAccounting)
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = CostGroupContainer
+	}
+	if !ValidCostGroup(groupBy) {
+		WriteError(w, &api.InvalidParameterError{Message: fmt.Sprintf("group_by %q not supported (container, project, backend, label:<key>)", groupBy)})
+		return
+	}
+	since := s.Costs.periodStart(s.Costs.now())
+	if v := r.URL.Query().Get("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			since = s.Costs.now().Add(-d)
+		} else if ts, err := time.Parse(time.RFC3339, v); err == nil {
+			since = ts
+		} else {
+			WriteError(w, &api.InvalidParameterError{Message: fmt.Sprintf("since %q: want RFC 3339 time or duration", v)})
+			return
+		}
+	}
+	WriteJSON(w, http.StatusOK, s.Costs.Report(groupBy, since))
+}
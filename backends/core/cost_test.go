@@ -0,0 +1,272 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// fixedShape prices every container as one Fargate vCPU with 2 GiB.
+type fixedShape struct{}
+
+func (fixedShape) ContainerCostShape(*api.Container) CostShape {
+	return CostShape{Kind: CostKindFargate, VCPU: 1, MemoryGiB: 2, EphemeralGiB: 20, StorageClass: CostStorageEFS}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestPriceTableEstimate(t *testing.T) {
+	pt := DefaultPriceTable()
+
+	hour := pt.Estimate(CostShape{Kind: CostKindFargate, VCPU: 1, MemoryGiB: 2}, 3600, 1)
+	if !near(hour.Total, 0.04048+2*0.004445) {
+		t.Errorf("fargate hour = %v, want %v", hour.Total, 0.04048+2*0.004445)
+	}
+	short := pt.Estimate(CostShape{Kind: CostKindFargate, VCPU: 1, MemoryGiB: 2}, 5, 1)
+	if !near(short.Total, hour.Total/60) {
+		t.Errorf("5s fargate run = %v, want the 1-minute minimum %v", short.Total, hour.Total/60)
+	}
+
+	lambda := pt.Estimate(CostShape{Kind: CostKindLambda, MemoryGiB: 1, EphemeralGiB: 0.5}, 10, 1)
+	if !near(lambda.Memory, 10*0.0000166667) || !near(lambda.Requests, 0.2e-6) || lambda.Ephemeral != 0 {
+		t.Errorf("lambda = %+v", lambda)
+	}
+
+	storage := pt.Estimate(CostShape{Kind: CostKindCloudRun, StorageClass: CostStorageGCS, StorageGiB: 10}, secondsPerMonth, 0)
+	if !near(storage.Storage, 0.20) {
+		t.Errorf("10 GiB GCS for a month = %v, want 0.20", storage.Storage)
+	}
+
+	task := CostShape{Kind: CostKindFargate, VCPU: 2, MemoryGiB: 4, EphemeralGiB: 30, StorageClass: CostStorageEFS, Unit: "web"}
+	whole := pt.Estimate(task, 3600, 1)
+	task.Share, task.StorageGiB = 0.25, 10
+	member := pt.Estimate(task, 3600, 1)
+	if !near(member.CPU+member.Memory+member.Ephemeral, whole.Total/4) || member.Storage == 0 {
+		t.Errorf("quarter share of %v = %+v", whole.Total, member)
+	}
+
+	if got := pt.Estimate(CostShape{Kind: "unknown", VCPU: 4}, 3600, 1); got.Total != 0 {
+		t.Errorf("unknown kind priced at %v", got.Total)
+	}
+}
+
+func TestLoadPriceTableOverlaysDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prices.json")
+	_ = os.WriteFile(path, []byte(`{"version":"acme-2026","workloads":{"lambda":{"gibSecond":0.00001}}}`), 0o600)
+
+	pt, err := LoadPriceTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Version != "acme-2026" || pt.Currency != "USD" {
+		t.Errorf("version/currency = %s/%s", pt.Version, pt.Currency)
+	}
+	if pt.Workloads[CostKindLambda].GiBSecond != 0.00001 {
+		t.Errorf("lambda not overridden: %+v", pt.Workloads[CostKindLambda])
+	}
+	if pt.Workloads[CostKindFargate] != DefaultPriceTable().Workloads[CostKindFargate] {
+		t.Error("fargate default not kept")
+	}
+
+	_ = os.WriteFile(path, []byte(`{"workloads":{}}`), 0o600)
+	if _, err := LoadPriceTable(path); err == nil || !strings.Contains(err.Error(), "no version") {
+		t.Errorf("unversioned table: err = %v", err)
+	}
+}
+
+func TestParseCostBudgets(t *testing.T) {
+	got, err := ParseCostBudgets(" team=payments:50, com.docker.compose.project=*:5 ,url=http://x:0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CostBudget{
+		{Label: "team", Value: "payments", Limit: 50},
+		{Label: "com.docker.compose.project", Value: "*", Limit: 5},
+		{Label: "url", Value: "http://x", Limit: 0.5},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("budget %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	for _, bad := range []string{"team:5", "team=x", "=x:5", "team=x:-1", "team=x:lots"} {
+		if _, err := ParseCostBudgets(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestCostConfigValidate(t *testing.T) {
+	cases := map[string]CostConfig{
+		"budget": {Budgets: "team"},
+		"period": {BudgetPeriod: "week"},
+		"volume": {VolumeGiB: math.NaN()},
+		"prices": {PricesPath: filepath.Join(t.TempDir(), "missing.json")},
+	}
+	for name, c := range cases {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if err := (CostConfig{Budgets: "team=ci:5", BudgetPeriod: "day", VolumeGiB: 2}).Validate(); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+}
+
+// costClock is a settable clock for the tracker.
+type costClock struct{ t time.Time }
+
+func (c *costClock) now() time.Time { return c.t }
+
+func TestCostTrackerReportGroups(t *testing.T) {
+	clock := &costClock{t: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)}
+	tr, err := NewCostTracker(CostConfig{VolumeGiB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.now = clock.now
+
+	shape := CostShape{Kind: CostKindCloudRun, VCPU: 1, MemoryGiB: 1}
+	web := &api.Container{ID: strings.Repeat("a", 64), Name: "/shop-web-1", Config: api.ContainerConfig{Labels: map[string]string{ComposeProjectLabel: "shop", "team": "retail"}}}
+	db := &api.Container{ID: strings.Repeat("b", 64), Name: "/shop-db-1", Config: api.ContainerConfig{Labels: map[string]string{ComposeProjectLabel: "shop"}}}
+	job := &api.Container{ID: strings.Repeat("c", 64), Name: "/job", Config: api.ContainerConfig{Labels: map[string]string{"team": "retail"}},
+		Mounts: []api.MountPoint{{Type: "volume", Name: "cache"}}}
+
+	tr.Start(web, "cloudrun", shape, clock.t)
+	tr.Start(db, "cloudrun", shape, clock.t)
+	tr.Start(job, "cloudrun", CostShape{Kind: CostKindCloudRun, VCPU: 2, MemoryGiB: 1, StorageClass: CostStorageGCS}, clock.t)
+	clock.t = clock.t.Add(100 * time.Second)
+	tr.Stop(db.ID)
+	tr.Stop(job.ID)
+	clock.t = clock.t.Add(100 * time.Second)
+
+	perSecond := 0.000018 + 0.000002
+	rep := tr.Report(CostGroupProject, time.Time{})
+	if rep.PriceVersion != DefaultPriceTable().Version {
+		t.Errorf("price version = %q", rep.PriceVersion)
+	}
+	groups := map[string]CostGroup{}
+	for _, g := range rep.Groups {
+		groups[g.Key] = g
+	}
+	if g := groups["shop"]; g.Runs != 2 || g.Running != 1 || !near(g.Cost.Total, 300*perSecond) {
+		t.Errorf("shop = %+v, want 2 runs, 1 running, %v", g, 300*perSecond)
+	}
+	jobCost := 100*(2*0.000018+0.000002) + 100*0.020/secondsPerMonth
+	if g := groups[""]; g.Runs != 1 || !near(g.Cost.Total, jobCost) || g.Cost.Storage == 0 {
+		t.Errorf("unattributed = %+v, want job at %v with storage", g, jobCost)
+	}
+
+	byTeam := tr.Report("label:team", time.Time{})
+	if byTeam.Groups[0].Key != "retail" || !near(byTeam.Groups[0].Cost.Total, 200*perSecond+jobCost) {
+		t.Errorf("by team = %+v", byTeam.Groups)
+	}
+
+	recent := tr.Report(CostGroupContainer, clock.t.Add(-50*time.Second))
+	if len(recent.Groups) != 1 || !strings.HasPrefix(recent.Groups[0].Key, "aaaaaaaaaaaa shop-web-1") || !near(recent.Total.Total, 50*perSecond) {
+		t.Errorf("last 50s = %+v", recent.Groups)
+	}
+}
+
+func TestCostTrackerLedgerReplay(t *testing.T) {
+	ledger := filepath.Join(t.TempDir(), "costs.jsonl")
+	tr, err := NewCostTracker(CostConfig{LedgerPath: ledger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &api.Container{ID: strings.Repeat("d", 64), Config: api.ContainerConfig{Labels: map[string]string{"team": "ci"}}}
+	tr.Start(c, "lambda", CostShape{Kind: CostKindLambda, MemoryGiB: 1}, time.Now().Add(-time.Minute))
+	tr.Stop(c.ID)
+	_ = tr.Close()
+
+	f, _ := os.OpenFile(ledger, os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = f.WriteString(`{"containerId":"torn`)
+	f.Close()
+
+	again, err := NewCostTracker(CostConfig{LedgerPath: ledger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	rep := again.Report("label:team", time.Time{})
+	if len(rep.Groups) != 1 || rep.Groups[0].Key != "ci" || rep.Groups[0].Runs != 1 || rep.Total.Total <= 0 {
+		t.Errorf("replayed report = %+v", rep)
+	}
+}
+
+func TestCostBudgetRefusesContainerStart(t *testing.T) {
+	s := newTestBaseServer()
+	s.ConfigureCosts(CostConfig{Budgets: "team=ci:0.001,team=*:100"}, fixedShape{})
+	if s.Costs == nil {
+		t.Fatal("cost accounting not configured")
+	}
+	clock := &costClock{t: time.Now()}
+	s.Costs.now = clock.now
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		rec := httptest.NewRecorder()
+		s.Mux.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewReader(data)))
+		return rec
+	}
+	create := func(name string) string {
+		t.Helper()
+		rec := do(http.MethodPost, "/containers/create?name="+name, map[string]any{"Image": "alpine", "Labels": map[string]string{"team": "ci"}})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", name, rec.Code, rec.Body)
+		}
+		var resp api.ContainerCreateResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp.ID
+	}
+
+	first := create("first")
+	if rec := do(http.MethodPost, "/containers/"+first+"/start", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("first start: %d %s", rec.Code, rec.Body)
+	}
+	clock.t = clock.t.Add(2 * time.Minute)
+	if rec := do(http.MethodPost, "/containers/"+first+"/stop", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("stop: %d %s", rec.Code, rec.Body)
+	}
+
+	second := create("second")
+	rec := do(http.MethodPost, "/containers/"+second+"/start", nil)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "team=ci exhausted") {
+		t.Fatalf("start over budget: %d %s", rec.Code, rec.Body)
+	}
+	rec = do(http.MethodPost, "/containers/"+first+"/restart", nil)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "team=ci exhausted") {
+		t.Fatalf("restart over budget: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = do(http.MethodGet, "/internal/v1/costs?group_by=label:team", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("costs: %d %s", rec.Code, rec.Body)
+	}
+	var rep CostReport
+	_ = json.Unmarshal(rec.Body.Bytes(), &rep)
+	if len(rep.Groups) != 1 || rep.Groups[0].Key != "ci" || rep.Groups[0].Runs != 1 {
+		t.Errorf("groups = %+v", rep.Groups)
+	}
+	if len(rep.Budgets) != 2 || !rep.Budgets[0].Exhausted || rep.Budgets[1].Exhausted || rep.Budgets[1].Value != "ci" {
+		t.Errorf("budgets = %+v", rep.Budgets)
+	}
+
+	if rec := do(http.MethodGet, "/internal/v1/costs?group_by=image", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad group_by: %d", rec.Code)
+	}
+}
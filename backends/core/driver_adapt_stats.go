@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"io"
 )
@@ -42,6 +43,12 @@ func (a *legacyStatsAdapter) Stats(dctx DriverContext, stream bool, w io.Writer)
 		return err
 	}
 	defer rc.Close()
+	// A stream only ends when the container stops; closing the reader
+	// when the client goes away stops the producer too.
+	if dctx.Ctx != nil {
+		stop := context.AfterFunc(dctx.Ctx, func() { _ = rc.Close() })
+		defer stop()
+	}
 	_, err = io.Copy(w, rc)
 	if errors.Is(err, io.EOF) {
 		return nil
@@ -100,6 +100,9 @@ func (s *BaseServer) EmitEvent(eventType, action, actorID string, attrs map[stri
 
 // emitEvent is a convenience method on BaseServer to publish a Docker-compatible event.
 func (s *BaseServer) emitEvent(eventType, action, actorID string, attrs map[string]string) {
+	if eventType == "container" {
+		s.observeCost(action, actorID)
+	}
 	if s.EventBus == nil {
 		return
 	}
@@ -0,0 +1,614 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Multi-instance high availability. Several instances of one backend
+// serve the same cloud account behind a load balancer and coordinate
+// through a LeaseStore (a DynamoDB table, a GCS bucket or a blob
+// container written with compare-and-swap):
+//
+//	instance/<id>   heartbeat of a live instance and the URL peers reach
+//	                it at; expires after the lease TTL
+//	leader          the elected leader; expires after the lease TTL
+//	container/<id>  the instance that owns a container; held while the
+//	                owner's heartbeat is live
+//	pool/<name>     an instance's claim on a pooled cloud resource
+//
+// Pending creates, exec sessions and reverse-agent connections live in
+// the owner's memory, so in active-active mode every instance serves
+// the docker API and forwards requests for a container another live
+// instance owns (and reverse-agent dial-backs for it) to that owner. In
+// active-passive mode followers forward every request to the leader.
+// The leader takes over the leases of instances whose heartbeat has
+// expired and re-registers the cloud resources of the containers it
+// won, so they can be inspected, stopped and removed again.
+
+// HA modes.
+const (
+	HAModeActiveActive  = "active-active"
+	HAModeActivePassive = "active-passive"
+)
+
+// Lease keys.
+const (
+	haLeaderKey       = "leader"
+	haInstancePrefix  = "instance/"
+	haContainerPrefix = "container/"
+)
+
+const (
+	haDefaultLeaseTTL = 30 * time.Second
+	haMinLeaseTTL     = 3 * time.Second
+)
+
+// ErrLeaseConflict is returned by a LeaseStore write whose expected
+// version no longer matches the stored lease: another instance wrote
+// it first.
+var ErrLeaseConflict = errors.New("lease changed concurrently")
+
+// Lease is one entry in the LeaseStore.
+type Lease struct {
+	Key     string `json:"key"`
+	Holder  string `json:"holder"`            // instance ID
+	Address string `json:"address,omitempty"` // holder's advertise URL
+	// Origin is, for container leases, the instance ID the container's
+	// cloud resources are tagged with (sockerless-instance). It stays
+	// put when the lease is taken over.
+	Origin string `json:"origin,omitempty"`
+	Name   string `json:"name,omitempty"` // container leases: container name without "/"
+	// Expires is when the lease lapses; zero means it is held for as
+	// long as the holder's instance lease is live.
+	Expires time.Time `json:"expires"`
+	// Version is the store's compare-and-swap token for the lease as
+	// read; empty for a lease not yet written.
+	Version string `json:"-"`
+}
+
+// LeaseStore is the shared, strongly consistent lease table instances
+// coordinate through.
+type LeaseStore interface {
+	// GetLease returns the lease at key, or false if there is none.
+	GetLease(ctx context.Context, key string) (Lease, bool, error)
+	// PutLease writes l if the stored lease still has l.Version (an
+	// empty Version: no lease may exist at l.Key) and returns it with
+	// its new Version, or ErrLeaseConflict.
+	PutLease(ctx context.Context, l Lease) (Lease, error)
+	// DeleteLease removes l if the stored lease still has l.Version,
+	// or returns ErrLeaseConflict.
+	DeleteLease(ctx context.Context, l Lease) error
+	// ListLeases returns the leases whose key starts with prefix.
+	ListLeases(ctx context.Context, prefix string) ([]Lease, error)
+}
+
+// HAConfig configures multi-instance operation.
+type HAConfig struct {
+	// Mode is HAModeActiveActive, HAModeActivePassive or empty for a
+	// single instance.
+	Mode string
+	// Store names the lease store: a DynamoDB table (AWS), a GCS
+	// bucket with an optional /prefix (GCP) or a blob container in the
+	// backend's storage account (Azure).
+	Store string
+	// AdvertiseURL is the URL other instances reach this one at.
+	AdvertiseURL string
+	// LeaseTTL is how long a heartbeat or leadership lasts without
+	// renewal; instances renew at a third of it.
+	LeaseTTL time.Duration
+}
+
+// HAConfigFromEnv reads SOCKERLESS_HA_MODE, SOCKERLESS_HA_STORE,
+// SOCKERLESS_HA_ADVERTISE_URL and SOCKERLESS_HA_LEASE_TTL (a Go
+// duration, default 30s).
+func HAConfigFromEnv() HAConfig {
+	c := HAConfig{
+		Mode:         strings.TrimSpace(os.Getenv("SOCKERLESS_HA_MODE")),
+		Store:        strings.TrimSpace(os.Getenv("SOCKERLESS_HA_STORE")),
+		AdvertiseURL: strings.TrimSpace(os.Getenv("SOCKERLESS_HA_ADVERTISE_URL")),
+		LeaseTTL:     haDefaultLeaseTTL,
+	}
+	if v := strings.TrimSpace(os.Getenv("SOCKERLESS_HA_LEASE_TTL")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			d = -1 // rejected by Validate
+		}
+		c.LeaseTTL = d
+	}
+	return c
+}
+
+// Validate checks the mode and that a configured mode has a lease
+// store and an advertise URL.
+func (c HAConfig) Validate() error {
+	switch c.Mode {
+	case "":
+		return nil
+	case HAModeActiveActive, HAModeActivePassive:
+	default:
+		return fmt.Errorf("SOCKERLESS_HA_MODE must be %s or %s, got %q", HAModeActiveActive, HAModeActivePassive, c.Mode)
+	}
+	if c.Store == "" {
+		return fmt.Errorf("SOCKERLESS_HA_STORE is required with SOCKERLESS_HA_MODE=%s", c.Mode)
+	}
+	u, err := url.Parse(c.AdvertiseURL)
+	if c.AdvertiseURL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("SOCKERLESS_HA_ADVERTISE_URL must be the http(s) URL other instances reach this one at")
+	}
+	if c.LeaseTTL < haMinLeaseTTL {
+		return fmt.Errorf("SOCKERLESS_HA_LEASE_TTL must be a duration of at least %s", haMinLeaseTTL)
+	}
+	return nil
+}
+
+// HACoordinator holds this instance's heartbeat, tracks the leader and
+// the live instances, and owns container and pool leases. A nil
+// coordinator is a single instance that owns everything.
+type HACoordinator struct {
+	config HAConfig
+	leases LeaseStore
+	self   string
+	server *BaseServer
+	logger zerolog.Logger
+
+	mu       sync.Mutex
+	instance Lease            // this instance's heartbeat as last written
+	leader   Lease            // leader lease as last read
+	peers    map[string]Lease // live instances by ID, this one included
+}
+
+// ConfigureHA joins this instance to the group that shares leases
+// (nil HA when c.Mode is empty). Heartbeats, election and takeover
+// start with RecoverRegistry.
+func (s *BaseServer) ConfigureHA(c HAConfig, leases LeaseStore) {
+	if c.Mode == "" || leases == nil {
+		return
+	}
+	s.HA = &HACoordinator{
+		config: c,
+		leases: leases,
+		self:   s.Desc.InstanceID,
+		server: s,
+		logger: s.Logger.With().Str("component", "ha").Logger(),
+		peers:  map[string]Lease{},
+	}
+	s.Logger.Info().Str("mode", c.Mode).Str("store", c.Store).Str("advertise", c.AdvertiseURL).Msg("high availability enabled")
+}
+
+// haCtx keeps lease traffic (a heartbeat every few seconds) out of the
+// audit log; it is still counted in the cloud API metrics.
+func haCtx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, auditSuppressKey{}, true)
+}
+
+// start writes the first heartbeat, takes over the containers of dead
+// instances and keeps heartbeat, election and (on the leader) takeover
+// running until ctx is done.
+func (h *HACoordinator) start(ctx context.Context, scanner CloudScanner) {
+	h.tick(ctx, scanner, true)
+	go func() {
+		t := time.NewTicker(h.config.LeaseTTL / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				h.tick(ctx, scanner, false)
+			}
+		}
+	}()
+}
+
+func (h *HACoordinator) tick(ctx context.Context, scanner CloudScanner, startup bool) {
+	ctx = haCtx(ctx)
+	if err := h.heartbeat(ctx); err != nil {
+		h.logger.Warn().Err(err).Msg("heartbeat failed")
+	}
+	if err := h.refreshPeers(ctx); err != nil {
+		h.logger.Warn().Err(err).Msg("list instances failed")
+	}
+	if err := h.elect(ctx); err != nil {
+		h.logger.Warn().Err(err).Msg("leader election failed")
+	}
+	if startup || h.IsLeader() {
+		if err := h.takeOver(ctx, scanner); err != nil {
+			h.logger.Warn().Err(err).Msg("takeover failed")
+		}
+	}
+}
+
+func (h *HACoordinator) heartbeat(ctx context.Context) error {
+	h.mu.Lock()
+	l := h.instance
+	h.mu.Unlock()
+	l.Key = haInstancePrefix + h.self
+	l.Holder, l.Address = h.self, h.config.AdvertiseURL
+	l.Expires = time.Now().Add(h.config.LeaseTTL)
+	written, err := h.leases.PutLease(ctx, l)
+	if errors.Is(err, ErrLeaseConflict) {
+		// Only this instance writes its heartbeat; a conflict means a
+		// peer declared it dead and took its leases over.
+		h.logger.Warn().Msg("heartbeat lapsed and was cleared by a peer; containers this instance owned have moved")
+		cur, ok, gerr := h.leases.GetLease(ctx, l.Key)
+		if gerr != nil {
+			return gerr
+		}
+		l.Version = ""
+		if ok {
+			l.Version = cur.Version
+		}
+		written, err = h.leases.PutLease(ctx, l)
+	}
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.instance = written
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *HACoordinator) refreshPeers(ctx context.Context) error {
+	all, err := h.leases.ListLeases(ctx, haInstancePrefix)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	peers := make(map[string]Lease, len(all))
+	for _, l := range all {
+		if l.Expires.After(now) {
+			peers[l.Holder] = l
+		}
+	}
+	h.mu.Lock()
+	h.peers = peers
+	h.mu.Unlock()
+	return nil
+}
+
+// elect renews this instance's leadership, or takes the leader lease
+// when it is vacant or has lapsed.
+func (h *HACoordinator) elect(ctx context.Context) error {
+	cur, ok, err := h.leases.GetLease(ctx, haLeaderKey)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if ok && cur.Holder != h.self && cur.Expires.After(now) {
+		h.setLeader(cur)
+		return nil
+	}
+	l := Lease{Key: haLeaderKey, Holder: h.self, Address: h.config.AdvertiseURL, Expires: now.Add(h.config.LeaseTTL)}
+	if ok {
+		l.Version = cur.Version
+	}
+	written, err := h.leases.PutLease(ctx, l)
+	if errors.Is(err, ErrLeaseConflict) {
+		if cur, ok, err = h.leases.GetLease(ctx, haLeaderKey); err == nil && ok {
+			h.setLeader(cur)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if !ok || cur.Holder != h.self {
+		h.logger.Info().Msg("elected leader")
+	}
+	h.setLeader(written)
+	return nil
+}
+
+func (h *HACoordinator) setLeader(l Lease) {
+	h.mu.Lock()
+	h.leader = l
+	h.mu.Unlock()
+}
+
+// IsLeader reports whether this instance holds the leader lease. A
+// single instance always leads.
+func (h *HACoordinator) IsLeader() bool {
+	if h == nil {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.leader.Holder == h.self && h.leader.Expires.After(time.Now())
+}
+
+// leaderAddress returns the current leader's advertise URL, or false
+// when no instance holds a live leader lease.
+func (h *HACoordinator) leaderAddress() (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.leader.Holder == "" || !h.leader.Expires.After(time.Now()) {
+		return "", false
+	}
+	return h.leader.Address, true
+}
+
+// alive reports whether holder is this instance or has a live
+// heartbeat.
+func (h *HACoordinator) alive(holder string) bool {
+	if holder == h.self {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.peers[holder]
+	return ok && l.Expires.After(time.Now())
+}
+
+// livePeers returns the other live instances, ordered by ID.
+func (h *HACoordinator) livePeers() []Lease {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	var out []Lease
+	for id, l := range h.peers {
+		if id != h.self && l.Expires.After(now) {
+			out = append(out, l)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Holder < out[j].Holder })
+	return out
+}
+
+// Acquire takes the lease at key for this instance unless a live
+// instance holds it, and reports whether this instance holds it now.
+// Backends claim pooled cloud resources with it where the cloud has
+// no compare-and-swap of its own. A nil coordinator always holds it.
+func (h *HACoordinator) Acquire(ctx context.Context, key string) (bool, error) {
+	if h == nil {
+		return true, nil
+	}
+	ctx = haCtx(ctx)
+	cur, ok, err := h.leases.GetLease(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if ok && cur.Holder == h.self {
+		return true, nil
+	}
+	if ok && h.alive(cur.Holder) {
+		return false, nil
+	}
+	l := Lease{Key: key, Holder: h.self, Address: h.config.AdvertiseURL, Origin: h.self}
+	if ok {
+		l.Version = cur.Version
+	}
+	if _, err := h.leases.PutLease(ctx, l); err != nil {
+		if errors.Is(err, ErrLeaseConflict) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Release drops this instance's lease at key; a lease held by another
+// instance (or none) is left alone.
+func (h *HACoordinator) Release(ctx context.Context, key string) error {
+	if h == nil {
+		return nil
+	}
+	ctx = haCtx(ctx)
+	cur, ok, err := h.leases.GetLease(ctx, key)
+	if err != nil || !ok || cur.Holder != h.self {
+		return err
+	}
+	if err := h.leases.DeleteLease(ctx, cur); err != nil && !errors.Is(err, ErrLeaseConflict) {
+		return err
+	}
+	return nil
+}
+
+// haClaimContainer records this instance as the owner of a container
+// it just created. A failure is logged: the container exists either
+// way and stays reachable through this instance.
+func (s *BaseServer) haClaimContainer(ctx context.Context, id string) {
+	h := s.HA
+	if h == nil || id == "" {
+		return
+	}
+	name := ""
+	if c, ok := s.PendingCreates.Get(id); ok {
+		name = c.Name
+	} else if c, ok := s.Store.Containers.Get(id); ok {
+		name = c.Name
+	}
+	l := Lease{
+		Key:     haContainerPrefix + id,
+		Holder:  h.self,
+		Address: h.config.AdvertiseURL,
+		Origin:  h.self,
+		Name:    strings.TrimPrefix(name, "/"),
+	}
+	if _, err := h.leases.PutLease(haCtx(ctx), l); err != nil {
+		h.logger.Warn().Err(err).Str("container", id).Msg("claim container lease failed")
+	}
+}
+
+// haReleaseContainer drops the lease of a removed container.
+func (s *BaseServer) haReleaseContainer(ctx context.Context, id string) {
+	if s.HA == nil || id == "" {
+		return
+	}
+	if err := s.HA.Release(ctx, haContainerPrefix+id); err != nil {
+		s.HA.logger.Warn().Err(err).Str("container", id).Msg("release container lease failed")
+	}
+}
+
+// takeOver moves every lease held by a dead instance to this one, then
+// re-registers the cloud resources of the containers this instance
+// owns but does not track yet (those it just won, and after a restart
+// those it won before).
+func (h *HACoordinator) takeOver(ctx context.Context, scanner CloudScanner) error {
+	all, err := h.leases.ListLeases(ctx, "")
+	if err != nil {
+		return err
+	}
+	var taken []string
+	dead := map[string]bool{}
+	for i, l := range all {
+		if l.Key == haLeaderKey || strings.HasPrefix(l.Key, haInstancePrefix) || h.alive(l.Holder) {
+			continue
+		}
+		dead[l.Holder] = true
+		won := l
+		won.Holder, won.Address = h.self, h.config.AdvertiseURL
+		written, err := h.leases.PutLease(ctx, won)
+		if errors.Is(err, ErrLeaseConflict) {
+			continue // another instance got there first
+		}
+		if err != nil {
+			return err
+		}
+		all[i] = written
+		taken = append(taken, l.Key)
+	}
+	if len(taken) > 0 {
+		holders := make([]string, 0, len(dead))
+		for id := range dead {
+			holders = append(holders, id)
+		}
+		sort.Strings(holders)
+		h.logger.Warn().Strs("from", holders).Strs("leases", taken).Msg("took over leases of dead instances")
+	}
+
+	// Lapsed heartbeats of instances whose leases are gone.
+	if instances, err := h.leases.ListLeases(ctx, haInstancePrefix); err == nil {
+		for _, l := range instances {
+			if l.Holder != h.self && !l.Expires.After(time.Now()) {
+				_ = h.leases.DeleteLease(ctx, l)
+			}
+		}
+	}
+
+	return h.adopt(ctx, all, scanner)
+}
+
+// adopt registers the cloud resources of containers this instance
+// holds leases for but were created by another instance, scanning each
+// origin instance's tagged resources.
+func (h *HACoordinator) adopt(ctx context.Context, leases []Lease, scanner CloudScanner) error {
+	if scanner == nil {
+		return nil
+	}
+	s := h.server
+	tracked := map[string]bool{}
+	for _, e := range s.Registry.ListAll() {
+		tracked[e.ContainerID] = true
+	}
+	byOrigin := map[string]map[string]string{} // origin → container ID → name
+	for _, l := range leases {
+		id, ok := strings.CutPrefix(l.Key, haContainerPrefix)
+		if !ok || l.Holder != h.self || l.Origin == "" || l.Origin == h.self || tracked[id] {
+			continue
+		}
+		if byOrigin[l.Origin] == nil {
+			byOrigin[l.Origin] = map[string]string{}
+		}
+		byOrigin[l.Origin][id] = l.Name
+	}
+	adopted := 0
+	for origin, owned := range byOrigin {
+		before := len(s.Registry.ListAll())
+		if err := RecoverOnStartup(ctx, s.Registry, ownedScanner{scanner, owned}, origin); err != nil {
+			return fmt.Errorf("adopt containers of %s: %w", origin, err)
+		}
+		adopted += len(s.Registry.ListAll()) - before
+		// A container with no cloud resources left is gone (removed
+		// while its owner was dying, or never started); drop its lease
+		// rather than scan for it on every tick.
+		found := map[string]bool{}
+		for _, e := range s.Registry.ListAll() {
+			found[e.ContainerID] = true
+		}
+		for id := range owned {
+			if !found[id] {
+				if err := h.Release(ctx, haContainerPrefix+id); err != nil {
+					h.logger.Warn().Err(err).Str("container", id).Msg("release lease of vanished container failed")
+				}
+			}
+		}
+	}
+	if adopted > 0 {
+		n := ReconstructContainerState(s.Store, s.Registry)
+		h.logger.Info().Int("resources", adopted).Int("containers", n).Msg("adopted containers of dead instances")
+	}
+	return nil
+}
+
+// ownedScanner narrows an origin instance's scan to the containers
+// this instance took over. Entries carry the container ID from the
+// resource's tags, which clouds with short label values truncate, so a
+// prefix of an owned ID matches.
+type ownedScanner struct {
+	CloudScanner
+	owned map[string]string // container ID → name
+}
+
+func (o ownedScanner) ScanOrphanedResources(ctx context.Context, instanceID string) ([]ResourceEntry, error) {
+	entries, err := o.CloudScanner.ScanOrphanedResources(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	var out []ResourceEntry
+	for _, e := range entries {
+		if e.ContainerID == "" {
+			continue
+		}
+		for id, name := range o.owned {
+			if !strings.HasPrefix(id, e.ContainerID) {
+				continue
+			}
+			e.ContainerID = id
+			if name != "" && e.Metadata["name"] == "" {
+				md := make(map[string]string, len(e.Metadata)+1)
+				for k, v := range e.Metadata {
+					md[k] = v
+				}
+				md["name"] = "/" + name
+				e.Metadata = md
+			}
+			out = append(out, e)
+			break
+		}
+	}
+	return out, nil
+}
+
+// HAStatus is the GET /internal/v1/ha response.
+type HAStatus struct {
+	Mode       string  `json:"mode"`
+	InstanceID string  `json:"instanceId"`
+	Leader     string  `json:"leader,omitempty"`
+	IsLeader   bool    `json:"isLeader"`
+	Instances  []Lease `json:"instances"`
+}
+
+func (h *HACoordinator) status() HAStatus {
+	st := HAStatus{Mode: h.config.Mode, InstanceID: h.self, IsLeader: h.IsLeader(), Instances: []Lease{}}
+	h.mu.Lock()
+	if h.leader.Expires.After(time.Now()) {
+		st.Leader = h.leader.Holder
+	}
+	for _, l := range h.peers {
+		st.Instances = append(st.Instances, l)
+	}
+	h.mu.Unlock()
+	sort.Slice(st.Instances, func(i, j int) bool { return st.Instances[i].Holder < st.Instances[j].Holder })
+	return st
+}
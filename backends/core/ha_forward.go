@@ -0,0 +1,224 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// haForwardedHeader marks a request one instance forwarded to another;
+// the receiver serves it, so a stale lease cannot bounce it around.
+const haForwardedHeader = "X-Sockerless-Forwarded"
+
+// haPeerProbeTimeout bounds the exec lookup on each peer.
+const haPeerProbeTimeout = 3 * time.Second
+
+// reverseAgentPath matches the FaaS bootstraps' dial-back endpoints
+// (/v1/lambda/reverse, /v1/cloudrun/reverse, ...).
+var reverseAgentPath = regexp.MustCompile(`^/v1/[a-z-]+/reverse$`)
+
+// haMiddleware forwards a request to the instance that must serve it:
+// in active-passive mode the leader; in active-active mode the live
+// owner of the container, exec instance or reverse-agent session the
+// request names. Everything else is served here. It must wrap the
+// handler that strips the API version prefix.
+func (s *BaseServer) haMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := s.HA
+		if h == nil || r.Header.Get(haForwardedHeader) != "" || haLocalPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if h.config.Mode == HAModeActivePassive {
+			if h.IsLeader() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			addr, ok := h.leaderAddress()
+			if !ok {
+				writeHAError(w, http.StatusServiceUnavailable, "no sockerless instance is leader yet; retry shortly")
+				return
+			}
+			h.forward(w, r, addr)
+			return
+		}
+		if addr := s.haOwnerAddress(r); addr != "" {
+			h.forward(w, r, addr)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// haLocalPath reports whether path describes this instance rather than
+// the backend as a whole: its metrics, health and HA status.
+func haLocalPath(path string) bool {
+	switch path {
+	case "/metrics", "/internal/v1/ha", "/internal/v1/healthz":
+		return true
+	}
+	return false
+}
+
+// haOwnerAddress returns the advertise URL of the live instance other
+// than this one that owns what r names, or "".
+func (s *BaseServer) haOwnerAddress(r *http.Request) string {
+	h := s.HA
+	ctx := haCtx(r.Context())
+	if reverseAgentPath.MatchString(r.URL.Path) {
+		// session_id is the container ID.
+		return h.ownerAddress(s.haContainerLease(ctx, r.URL.Query().Get("session_id")))
+	}
+	path := r.URL.Path
+	if loc := versionPrefix.FindStringIndex(path); loc != nil {
+		path = path[loc[1]-1:]
+	}
+	path = strings.TrimPrefix(path, "/libpod")
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segs) < 2 {
+		return ""
+	}
+	switch segs[0] {
+	case "containers":
+		switch segs[1] {
+		case "create", "json", "prune":
+			return ""
+		}
+		return h.ownerAddress(s.haContainerLease(ctx, segs[1]))
+	case "exec":
+		if _, ok := s.Store.Execs.Get(segs[1]); ok {
+			return ""
+		}
+		return h.findExec(r.Context(), segs[1])
+	}
+	return ""
+}
+
+// haContainerLease returns the lease of the container ref names: by ID
+// when this instance knows the container, else by ID, unique ID prefix
+// or name among all container leases.
+func (s *BaseServer) haContainerLease(ctx context.Context, ref string) (Lease, bool) {
+	h := s.HA
+	if ref == "" {
+		return Lease{}, false
+	}
+	id, ok := s.Store.ResolveContainerID(ref)
+	if !ok {
+		if _, pending := s.PendingCreates.Get(ref); pending {
+			id, ok = ref, true
+		}
+	}
+	if ok {
+		l, found, err := h.leases.GetLease(ctx, haContainerPrefix+id)
+		if err != nil {
+			h.logger.Warn().Err(err).Str("container", id).Msg("container lease lookup failed")
+		}
+		return l, found && err == nil
+	}
+	all, err := h.leases.ListLeases(ctx, haContainerPrefix)
+	if err != nil {
+		h.logger.Warn().Err(err).Msg("container lease list failed")
+		return Lease{}, false
+	}
+	name := strings.TrimPrefix(ref, "/")
+	var match []Lease
+	for _, l := range all {
+		cid := strings.TrimPrefix(l.Key, haContainerPrefix)
+		if cid == ref || l.Name == name {
+			return l, true
+		}
+		if strings.HasPrefix(cid, ref) {
+			match = append(match, l)
+		}
+	}
+	if len(match) == 1 {
+		return match[0], true
+	}
+	return Lease{}, false
+}
+
+// ownerAddress returns where to forward a request for the container
+// leased by l: its holder's URL when that is a live peer, else "" (this
+// instance owns it, nobody does, or its owner is dead and about to be
+// taken over, in which case serving here is the best left).
+func (h *HACoordinator) ownerAddress(l Lease, ok bool) string {
+	if !ok || l.Holder == h.self || !h.alive(l.Holder) {
+		return ""
+	}
+	return l.Address
+}
+
+// findExec asks each live peer whether it holds exec instance id, and
+// returns the URL of the first that does. Exec instances are short-
+// lived and per-instance, so they are looked up rather than leased.
+func (h *HACoordinator) findExec(ctx context.Context, id string) string {
+	for _, p := range h.livePeers() {
+		pctx, cancel := context.WithTimeout(ctx, haPeerProbeTimeout)
+		req, err := http.NewRequestWithContext(pctx, http.MethodGet, strings.TrimSuffix(p.Address, "/")+"/exec/"+url.PathEscape(id)+"/json", nil)
+		if err != nil {
+			cancel()
+			continue
+		}
+		req.Header.Set(haForwardedHeader, h.self)
+		resp, err := http.DefaultClient.Do(req)
+		cancel()
+		if err != nil {
+			continue
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return p.Address
+		}
+	}
+	return ""
+}
+
+// forward proxies r to the instance at address, connection upgrades
+// (attach, exec start, reverse-agent WebSockets) and streamed
+// responses included. The owner records the same request ID.
+func (h *HACoordinator) forward(w http.ResponseWriter, r *http.Request, address string) {
+	target, err := url.Parse(address)
+	if err != nil {
+		writeHAError(w, http.StatusBadGateway, "invalid peer address "+address)
+		return
+	}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			pr.Out.Header.Set(haForwardedHeader, h.self)
+			if req := auditRequestFrom(pr.In.Context()); req != nil {
+				pr.Out.Header.Set("X-Request-Id", req.id)
+			}
+		},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			h.logger.Warn().Err(err).Str("peer", address).Str("path", r.URL.Path).Msg("forward to peer failed")
+			writeHAError(w, http.StatusBadGateway, "forward to "+address+": "+err.Error())
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+func writeHAError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(api.ErrorResponse{Message: msg})
+}
+
+// handleHAStatus serves GET /internal/v1/ha: the mode, this instance,
+// the leader and the live instances.
+func (s *BaseServer) handleHAStatus(w http.ResponseWriter, r *http.Request) {
+	if s.HA == nil {
+		writeHAError(w, http.StatusNotFound, "high availability is not configured (SOCKERLESS_HA_MODE)")
+		return
+	}
+	WriteJSON(w, http.StatusOK, s.HA.status())
+}
@@ -0,0 +1,326 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sockerless/api"
+)
+
+// memLeaseStore is an in-memory LeaseStore with the same
+// compare-and-swap semantics as the cloud stores.
+type memLeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]Lease
+	next   int
+}
+
+func newMemLeaseStore() *memLeaseStore {
+	return &memLeaseStore{leases: map[string]Lease{}}
+}
+
+func (m *memLeaseStore) GetLease(_ context.Context, key string) (Lease, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.leases[key]
+	return l, ok, nil
+}
+
+func (m *memLeaseStore) PutLease(_ context.Context, l Lease) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.leases[l.Key].Version != l.Version {
+		return Lease{}, ErrLeaseConflict
+	}
+	m.next++
+	l.Version = strconv.Itoa(m.next)
+	m.leases[l.Key] = l
+	return l, nil
+}
+
+func (m *memLeaseStore) DeleteLease(_ context.Context, l Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.leases[l.Key]
+	if !ok || cur.Version != l.Version {
+		return ErrLeaseConflict
+	}
+	delete(m.leases, l.Key)
+	return nil
+}
+
+func (m *memLeaseStore) ListLeases(_ context.Context, prefix string) ([]Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Lease
+	for k, l := range m.leases {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+// expire makes holder look dead: its heartbeat and any leadership it
+// holds have lapsed.
+func (m *memLeaseStore) expire(holder string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, l := range m.leases {
+		if l.Holder == holder && !l.Expires.IsZero() {
+			l.Expires = time.Now().Add(-time.Second)
+			m.leases[k] = l
+		}
+	}
+}
+
+func newHATestServer(t *testing.T, id, mode, advertise string, leases LeaseStore) *BaseServer {
+	t.Helper()
+	s := NewBaseServer(NewStore(), BackendDescriptor{ID: id, Name: id, Driver: "ecs-fargate", InstanceID: id}, zerolog.Nop())
+	s.ConfigureHA(HAConfig{Mode: mode, Store: "leases", AdvertiseURL: advertise, LeaseTTL: 3 * time.Second}, leases)
+	return s
+}
+
+func TestHAConfigValidate(t *testing.T) {
+	ok := HAConfig{Mode: HAModeActiveActive, Store: "t", AdvertiseURL: "http://10.0.0.1:2375", LeaseTTL: 30 * time.Second}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	if err := (HAConfig{}).Validate(); err != nil {
+		t.Fatalf("disabled HA rejected: %v", err)
+	}
+	for name, c := range map[string]HAConfig{
+		"mode":      {Mode: "primary", Store: "t", AdvertiseURL: ok.AdvertiseURL, LeaseTTL: ok.LeaseTTL},
+		"store":     {Mode: HAModeActivePassive, AdvertiseURL: ok.AdvertiseURL, LeaseTTL: ok.LeaseTTL},
+		"advertise": {Mode: HAModeActivePassive, Store: "t", AdvertiseURL: "10.0.0.1:2375", LeaseTTL: ok.LeaseTTL},
+		"ttl":       {Mode: HAModeActivePassive, Store: "t", AdvertiseURL: ok.AdvertiseURL, LeaseTTL: time.Second},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: invalid config accepted", name)
+		}
+	}
+}
+
+func TestHALeaderElectionAndFailover(t *testing.T) {
+	ctx := context.Background()
+	leases := newMemLeaseStore()
+	a := newHATestServer(t, "a", HAModeActivePassive, "http://a:2375", leases)
+	b := newHATestServer(t, "b", HAModeActivePassive, "http://b:2375", leases)
+
+	a.HA.tick(ctx, nil, true)
+	b.HA.tick(ctx, nil, true)
+	if !a.HA.IsLeader() || b.HA.IsLeader() {
+		t.Fatalf("leaders: a=%v b=%v, want only a", a.HA.IsLeader(), b.HA.IsLeader())
+	}
+	if addr, ok := b.HA.leaderAddress(); !ok || addr != "http://a:2375" {
+		t.Fatalf("b sees leader at %q, %v", addr, ok)
+	}
+	if st := b.HA.status(); len(st.Instances) != 2 || st.Leader != "a" {
+		t.Fatalf("status = %+v", st)
+	}
+
+	leases.expire("a")
+	b.HA.tick(ctx, nil, false)
+	if !b.HA.IsLeader() {
+		t.Fatal("b did not take over leadership from the dead leader")
+	}
+	// a comes back: it rejoins as a follower.
+	a.HA.tick(ctx, nil, false)
+	if a.HA.IsLeader() {
+		t.Fatal("a reclaimed leadership from a live leader")
+	}
+}
+
+func TestHATakeOverAdoptsContainers(t *testing.T) {
+	ctx := context.Background()
+	leases := newMemLeaseStore()
+	a := newHATestServer(t, "a", HAModeActiveActive, "http://a:2375", leases)
+	a.HA.tick(ctx, nil, true)
+
+	cid := "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"
+	gone := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	a.PendingCreates.Put(cid, api.Container{ID: cid, Name: "/web"})
+	a.haClaimContainer(ctx, cid)
+	a.haClaimContainer(ctx, gone)
+	if held, _ := a.HA.Acquire(ctx, "pool/fn-1"); !held {
+		t.Fatal("a could not take a free pool lease")
+	}
+	leases.expire("a")
+
+	// The dead instance's cloud resources carry a truncated ID, as
+	// labels with short values do.
+	scanner := &mockScanner{orphans: []ResourceEntry{{
+		ContainerID:  cid[:63],
+		Backend:      "cloudrun",
+		ResourceType: "job",
+		ResourceID:   "projects/p/locations/l/jobs/sockerless-abcdef012345",
+		InstanceID:   "a",
+		CreatedAt:    time.Now(),
+	}}}
+	b := newHATestServer(t, "b", HAModeActiveActive, "http://b:2375", leases)
+	b.HA.tick(ctx, scanner, true)
+
+	l, ok, _ := leases.GetLease(ctx, haContainerPrefix+cid)
+	if !ok || l.Holder != "b" || l.Origin != "a" || l.Address != "http://b:2375" {
+		t.Fatalf("container lease after takeover = %+v, %v", l, ok)
+	}
+	if l, ok, _ := leases.GetLease(ctx, "pool/fn-1"); !ok || l.Holder != "b" {
+		t.Fatalf("pool lease after takeover = %+v, %v", l, ok)
+	}
+	if _, ok, _ := leases.GetLease(ctx, haContainerPrefix+gone); ok {
+		t.Fatal("lease of a container with no cloud resources was kept")
+	}
+	if _, ok, _ := leases.GetLease(ctx, haInstancePrefix+"a"); ok {
+		t.Fatal("dead instance's heartbeat was not removed")
+	}
+	c, ok := b.Store.ResolveContainer("web")
+	if !ok || c.ID != cid {
+		t.Fatalf("adopted container not in store: %+v, %v", c, ok)
+	}
+	if entries := b.Registry.ListActive(); len(entries) != 1 || entries[0].ContainerID != cid {
+		t.Fatalf("registry after adoption = %+v", entries)
+	}
+
+	// b now owns it: removal drops the lease.
+	b.haReleaseContainer(ctx, cid)
+	if _, ok, _ := leases.GetLease(ctx, haContainerPrefix+cid); ok {
+		t.Fatal("lease kept after remove")
+	}
+}
+
+func TestHAAcquireRelease(t *testing.T) {
+	ctx := context.Background()
+	leases := newMemLeaseStore()
+	a := newHATestServer(t, "a", HAModeActiveActive, "http://a:2375", leases)
+	b := newHATestServer(t, "b", HAModeActiveActive, "http://b:2375", leases)
+	a.HA.tick(ctx, nil, true)
+	b.HA.tick(ctx, nil, true)
+
+	if held, err := a.HA.Acquire(ctx, "pool/fn"); err != nil || !held {
+		t.Fatalf("a acquire = %v, %v", held, err)
+	}
+	if held, err := b.HA.Acquire(ctx, "pool/fn"); err != nil || held {
+		t.Fatalf("b acquired a lease a holds: %v, %v", held, err)
+	}
+	if err := b.HA.Release(ctx, "pool/fn"); err != nil {
+		t.Fatal(err)
+	}
+	if l, ok, _ := leases.GetLease(ctx, "pool/fn"); !ok || l.Holder != "a" {
+		t.Fatal("b released a's lease")
+	}
+	if err := a.HA.Release(ctx, "pool/fn"); err != nil {
+		t.Fatal(err)
+	}
+	if held, _ := b.HA.Acquire(ctx, "pool/fn"); !held {
+		t.Fatal("b could not take a released lease")
+	}
+
+	var single *HACoordinator
+	if held, err := single.Acquire(ctx, "pool/fn"); err != nil || !held {
+		t.Fatal("a single instance must always hold its leases")
+	}
+}
+
+func TestHAForwardsToOwner(t *testing.T) {
+	ctx := context.Background()
+	leases := newMemLeaseStore()
+
+	var forwarded []string
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r.Method+" "+r.URL.Path+" from "+r.Header.Get(haForwardedHeader))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer owner.Close()
+
+	a := newHATestServer(t, "a", HAModeActiveActive, owner.URL, leases)
+	a.HA.tick(ctx, nil, true)
+	cid := "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"
+	a.PendingCreates.Put(cid, api.Container{ID: cid, Name: "/web"})
+	a.haClaimContainer(ctx, cid)
+
+	b := newHATestServer(t, "b", HAModeActiveActive, "http://b:2375", leases)
+	b.HA.tick(ctx, nil, true)
+	served := 0
+	handler := b.haMiddleware(stripVersionPrefix(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	for _, path := range []string{
+		"/v1.44/containers/web/stop",
+		"/v1.44/containers/abcdef012345/json",
+		"/v1/lambda/reverse?session_id=" + cid,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: status %d, want the owner's 204", path, w.Code)
+		}
+	}
+	if len(forwarded) != 3 || forwarded[0] != "POST /v1.44/containers/web/stop from b" {
+		t.Fatalf("owner received %q", forwarded)
+	}
+
+	// Unowned containers, listings and already-forwarded requests are
+	// served here.
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1.44/containers/other/json", nil),
+		httptest.NewRequest(http.MethodGet, "/v1.44/containers/json", nil),
+		httptest.NewRequest(http.MethodGet, "/internal/v1/ha", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1.44/containers/web/stop", nil)
+	req.Header.Set(haForwardedHeader, "c")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if served != 4 || len(forwarded) != 3 {
+		t.Fatalf("served locally %d, forwarded %d; want 4 and 3", served, len(forwarded))
+	}
+
+	// Once the owner is dead its containers are served here.
+	leases.expire("a")
+	b.HA.refreshPeers(ctx)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1.44/containers/web/stop", nil))
+	if served != 5 {
+		t.Fatal("request for a dead owner's container was not served locally")
+	}
+}
+
+func TestHAActivePassiveForwardsToLeader(t *testing.T) {
+	ctx := context.Background()
+	leases := newMemLeaseStore()
+	var got string
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Path
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer leader.Close()
+
+	a := newHATestServer(t, "a", HAModeActivePassive, leader.URL, leases)
+	b := newHATestServer(t, "b", HAModeActivePassive, "http://b:2375", leases)
+	handler := b.haMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("follower served %s", r.URL.Path)
+	}))
+
+	// No leader yet.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1.44/containers/create", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status without a leader = %d, want 503", w.Code)
+	}
+
+	a.HA.tick(ctx, nil, true)
+	b.HA.tick(ctx, nil, true)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1.44/containers/create", nil))
+	if w.Code != http.StatusCreated || got != "/v1.44/containers/create" {
+		t.Fatalf("follower forward: status %d, leader got %q", w.Code, got)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -81,3 +82,70 @@ func parseJSONOrShell(value string) []string {
 	}
 	return []string{"/bin/sh", "-c", value}
 }
+
+// applyImageChanges applies the Dockerfile instructions of a
+// `docker commit --change` / `docker import --change` list to cfg.
+// Instructions docker does not accept as changes are rejected.
+func applyImageChanges(cfg *api.ContainerConfig, changes []string) error {
+	for _, change := range changes {
+		change = strings.TrimSpace(change)
+		if change == "" {
+			continue
+		}
+		parts := strings.SplitN(change, " ", 2)
+		if len(parts) < 2 {
+			return &api.InvalidParameterError{Message: fmt.Sprintf("change %q has no value", change)}
+		}
+		instruction, value := strings.ToUpper(parts[0]), strings.TrimSpace(parts[1])
+		switch instruction {
+		case "CMD":
+			cfg.Cmd = parseJSONOrShell(value)
+		case "ENTRYPOINT":
+			cfg.Entrypoint = parseJSONOrShell(value)
+		case "ENV":
+			k, v, _ := strings.Cut(value, "=")
+			cfg.Env = append(cfg.Env, k+"="+v)
+		case "WORKDIR":
+			cfg.WorkingDir = value
+		case "USER":
+			cfg.User = value
+		case "LABEL":
+			k, v, _ := strings.Cut(value, "=")
+			if cfg.Labels == nil {
+				cfg.Labels = map[string]string{}
+			}
+			cfg.Labels[k] = strings.Trim(v, "\"")
+		case "EXPOSE":
+			if cfg.ExposedPorts == nil {
+				cfg.ExposedPorts = map[string]struct{}{}
+			}
+			port := value
+			if !strings.Contains(port, "/") {
+				port += "/tcp"
+			}
+			cfg.ExposedPorts[port] = struct{}{}
+		case "VOLUME":
+			if cfg.Volumes == nil {
+				cfg.Volumes = map[string]struct{}{}
+			}
+			for _, v := range parseJSONOrFields(value) {
+				cfg.Volumes[v] = struct{}{}
+			}
+		case "STOPSIGNAL":
+			cfg.StopSignal = value
+		default:
+			return &api.InvalidParameterError{Message: fmt.Sprintf("%s is not a valid change command", instruction)}
+		}
+	}
+	return nil
+}
+
+// parseJSONOrFields parses value as a JSON array, falling back to
+// whitespace-separated words (VOLUME's two forms).
+func parseJSONOrFields(value string) []string {
+	var result []string
+	if err := json.Unmarshal([]byte(value), &result); err == nil {
+		return result
+	}
+	return strings.Fields(value)
+}
@@ -83,21 +83,41 @@ func (s *BaseServer) handleContainerCreate(w http.ResponseWriter, r *http.Reques
 		req.Labels["sockerless-pod"] = pod.Name
 	}
 
+	if req.ContainerConfig != nil {
+		image, err := s.admitImage(r.Context(), req.Image, ScanEnforceCreate)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		req.Image = image
+	}
+
 	resp, err := s.self.ContainerCreate(&req)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
 
-	// Explicit pod association (validated above)
+	// Compose services of one project share a pod so the backend can
+	// run them as one cloud unit.
+	if podMeta == nil && req.ContainerConfig != nil {
+		podMeta = s.composeUnitPod(req.Labels)
+	}
+
+	// Explicit or compose pod association
 	if podMeta != nil {
 		_ = s.Store.Pods.AddContainer(podMeta.ID, resp.ID)
 	}
+	s.haClaimContainer(r.Context(), resp.ID)
 
 	WriteJSON(w, http.StatusCreated, resp)
 }
 
 func (s *BaseServer) handleContainerStart(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkCostBudget(r.Context(), r.PathValue("id")); err != nil {
+		WriteError(w, err)
+		return
+	}
 	if err := s.self.ContainerStart(r.PathValue("id")); err != nil {
 		WriteError(w, err)
 		return
@@ -150,10 +170,15 @@ func (s *BaseServer) handleContainerKill(w http.ResponseWriter, r *http.Request)
 
 func (s *BaseServer) handleContainerRemove(w http.ResponseWriter, r *http.Request) {
 	force := r.URL.Query().Get("force") == "1" || r.URL.Query().Get("force") == "true"
+	var id string
+	if s.HA != nil {
+		id, _ = s.ResolveContainerIDAuto(r.Context(), r.PathValue("id"))
+	}
 	if err := s.self.ContainerRemove(r.PathValue("id"), force); err != nil {
 		WriteError(w, err)
 		return
 	}
+	s.haReleaseContainer(r.Context(), id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -163,6 +188,10 @@ func (s *BaseServer) handleContainerRestart(w http.ResponseWriter, r *http.Reque
 		v, _ := strconv.Atoi(t)
 		timeout = &v
 	}
+	if err := s.checkCostBudget(r.Context(), r.PathValue("id")); err != nil {
+		WriteError(w, err)
+		return
+	}
 	if err := s.self.ContainerRestart(r.PathValue("id"), timeout); err != nil {
 		WriteError(w, err)
 		return
@@ -282,36 +282,9 @@ func (s *BaseServer) handleDockerInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *BaseServer) handleDockerImageCreate(w http.ResponseWriter, r *http.Request) {
-	// docker import: fromSrc is set (e.g. "-" for stdin tar)
+	// docker import: fromSrc is "-" and the body is the rootfs tarball
 	if fromSrc := r.URL.Query().Get("fromSrc"); fromSrc != "" {
-		rc, err := s.self.ImageLoad(r.Body)
-		if err != nil {
-			WriteError(w, err)
-			return
-		}
-		defer rc.Close()
-
-		// Forward repo/tag by tagging the loaded image
-		repo := r.URL.Query().Get("repo")
-		if repo != "" {
-			body, _ := io.ReadAll(rc)
-			loaded := strings.TrimPrefix(string(body), "{\"stream\":\"Loaded image: ")
-			if idx := strings.Index(loaded, "\""); idx > 0 {
-				loaded = strings.TrimSpace(loaded[:idx])
-				if loaded != "" {
-					tag := r.URL.Query().Get("tag")
-					_ = s.self.ImageTag(loaded, repo, tag)
-				}
-			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			w.Write(body)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		FlushingCopy(w, rc)
+		s.handleDockerImageImport(w, r, fromSrc)
 		return
 	}
 
@@ -340,7 +313,7 @@ func (s *BaseServer) handleDockerImageCreate(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	dctx := DriverContext{Ctx: r.Context(), Backend: s.Desc.Driver, Logger: s.Logger}
-	rc, err := s.Typed.Registry.Pull(dctx, parsed, auth)
+	rc, err := s.pullImage(dctx, parsed, auth)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -349,7 +322,7 @@ func (s *BaseServer) handleDockerImageCreate(w http.ResponseWriter, r *http.Requ
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	FlushingCopy(w, rc)
+	FlushingCopy(w, s.admitPulledImage(r.Context(), ref, rc))
 }
 
 // handleDockerImageCatchAll handles /images/{name}/json, /images/{name}/tag, etc.
@@ -390,7 +363,7 @@ func (s *BaseServer) handleDockerImageInspect(w http.ResponseWriter, _ *http.Req
 		WriteError(w, err)
 		return
 	}
-	WriteJSON(w, http.StatusOK, img)
+	WriteJSON(w, http.StatusOK, s.withImageScan(img))
 }
 
 func (s *BaseServer) handleDockerImageTag(w http.ResponseWriter, r *http.Request, name string) {
@@ -413,6 +386,37 @@ func (s *BaseServer) handleDockerImageHistory(w http.ResponseWriter, _ *http.Req
 	WriteJSON(w, http.StatusOK, result)
 }
 
+// handleDockerImageImport serves `docker import` through the backend's
+// ImageImporter.
+func (s *BaseServer) handleDockerImageImport(w http.ResponseWriter, r *http.Request, fromSrc string) {
+	defer r.Body.Close()
+	if fromSrc != "-" {
+		WriteError(w, &api.InvalidParameterError{Message: "docker import from a URL is not supported; stream the tarball instead (docker import - < rootfs.tar)"})
+		return
+	}
+	importer, ok := s.self.(ImageImporter)
+	if !ok {
+		WriteError(w, &api.NotImplementedError{Message: "docker import is not supported by the " + s.Desc.Driver + " backend"})
+		return
+	}
+	q := r.URL.Query()
+	rc, err := importer.ImageImport(r.Body, ImageImportOptions{
+		Repo:     q.Get("repo"),
+		Tag:      q.Get("tag"),
+		Message:  q.Get("message"),
+		Changes:  q["changes"],
+		Platform: q.Get("platform"),
+	})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	FlushingCopy(w, rc)
+}
+
 func (s *BaseServer) handleDockerImagePush(w http.ResponseWriter, r *http.Request, name string) {
 	tag := r.URL.Query().Get("tag")
 	auth := r.Header.Get("X-Registry-Auth")
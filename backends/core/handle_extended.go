@@ -61,6 +61,9 @@ func (s *BaseServer) handleContainerPrune(w http.ResponseWriter, r *http.Request
 		WriteError(w, err)
 		return
 	}
+	for _, id := range resp.ContainersDeleted {
+		s.haReleaseContainer(r.Context(), id)
+	}
 	WriteJSON(w, http.StatusOK, resp)
 }
 
@@ -71,138 +74,51 @@ func (s *BaseServer) handleContainerStats(w http.ResponseWriter, r *http.Request
 		WriteError(w, &api.NotFoundError{Resource: "container", ID: ref})
 		return
 	}
-	id := c.ID
 	// Docker clients send the stream flag as "true" / "false" (newer
 	// SDKs) or "1" / "0" (older). Normalise both forms — anything that
 	// parses as "no stream" is treated as one-shot.
 	streamRaw := r.URL.Query().Get("stream")
 	stream := streamRaw != "false" && streamRaw != "0"
 
-	// Streaming `docker stats` is an accepted gap on cloud backends
-	// (see specs/CLOUD_RESOURCE_MAPPING.md § Acceptable gaps): cloud
-	// metrics surface with 30–60 s+ lag, so a "stream" would be a
-	// high-latency polling reskin that misleads callers. Local docker
-	// backend overrides this handler with a real streaming impl. For
-	// every cloud backend (CloudState set), fall back to a single
-	// snapshot regardless of the stream flag.
-	if stream && s.CloudState != nil {
-		WriteError(w, &api.NotImplementedError{Message: "streaming `docker stats` is not supported on cloud backends — use `docker stats --no-stream` for one-shot metrics (cloud metrics lag 30-60s)"})
-		return
-	}
-
-	memLimit := int64(1073741824) // 1 GiB default
-	if c.HostConfig.Memory > 0 {
-		memLimit = c.HostConfig.Memory
-	}
-
-	if !c.State.Running {
-		now := time.Now().UTC()
-		WriteJSON(w, http.StatusOK, s.buildStatsEntry(id, now, "0001-01-01T00:00:00Z", memLimit))
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	enc := json.NewEncoder(w)
-	now := time.Now().UTC()
-	entry := s.buildStatsEntry(id, now, "0001-01-01T00:00:00Z", memLimit)
-	_ = enc.Encode(entry)
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
+	dctx := DriverContext{
+		Ctx:       r.Context(),
+		Container: c,
+		Backend:   s.Desc.Driver,
+		Logger:    s.Logger,
 	}
-}
-
-// buildStatsEntry constructs a Docker-compatible stats JSON object.
-// Uses StatsProvider for real metrics when available.
-func (s *BaseServer) buildStatsEntry(containerID string, now time.Time, preread string, memLimit int64) map[string]any {
-	var memUsage int64
-	var cpuNanos int64
-	var pids int
-
-	// Fetch real metrics from cloud provider if available.
-	if s.StatsProvider != nil {
-		if m, err := s.StatsProvider.ContainerMetrics(containerID); err == nil && m != nil {
-			cpuNanos = m.CPUNanos
-			memUsage = m.MemBytes
-			pids = m.PIDs
+	sw := &statsResponseWriter{w: w}
+	if err := s.Typed.Stats.Stats(dctx, stream, sw); err != nil {
+		if !sw.started {
+			WriteError(w, err)
+			return
 		}
+		s.Logger.Debug().Err(err).Str("container", c.ID).Msg("stats stream ended with error")
 	}
-
-	systemNanos := now.UnixNano()
-
-	// Load previous CPU reading for precpu_stats
-	var prevCPU, prevSys int64
-	if prev, ok := s.Store.PrevCPUStats.Load(containerID); ok {
-		p := prev.(*prevCPUStats)
-		prevCPU = p.CPUNanos
-		prevSys = p.SystemCPUNanos
-	}
-	s.Store.PrevCPUStats.Store(containerID, &prevCPUStats{
-		CPUNanos:       cpuNanos,
-		SystemCPUNanos: systemNanos,
-	})
-
-	// Look up container name (try cloud state first for stateless backends)
-	var name string
-	if c, ok := s.ResolveContainerAuto(context.Background(), containerID); ok {
-		name = c.Name
-	}
-
-	return map[string]any{
-		"id":      containerID,
-		"name":    name,
-		"read":    now.Format(time.RFC3339Nano),
-		"preread": preread,
-		"cpu_stats": map[string]any{
-			"cpu_usage": map[string]any{
-				"total_usage": cpuNanos,
-			},
-			"online_cpus":      1,
-			"system_cpu_usage": systemNanos,
-		},
-		"precpu_stats": map[string]any{
-			"cpu_usage": map[string]any{
-				"total_usage": prevCPU,
-			},
-			"online_cpus":      1,
-			"system_cpu_usage": prevSys,
-		},
-		"memory_stats": map[string]any{
-			"usage": memUsage,
-			"limit": memLimit,
-		},
-		"pids_stats": map[string]any{
-			"current": pids,
-		},
-		"networks": s.buildNetworkStats(containerID),
+	if !sw.started {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
 	}
 }
 
-// prevCPUStats holds the previous CPU stats reading for a container.
-type prevCPUStats struct {
-	CPUNanos       int64
-	SystemCPUNanos int64
+// statsResponseWriter defers the 200 until the stats driver writes its
+// first frame, so an error before any output still gets a proper
+// status, and flushes every frame to the client as it is written.
+type statsResponseWriter struct {
+	w       http.ResponseWriter
+	started bool
 }
 
-// buildNetworkStats returns per-network zero-value stats for a container.
-func (s *BaseServer) buildNetworkStats(containerID string) map[string]any {
-	netStats := make(map[string]any)
-	if c, ok := s.Store.Containers.Get(containerID); ok {
-		for netName := range c.NetworkSettings.Networks {
-			netStats[netName] = map[string]any{
-				"rx_bytes":   0,
-				"rx_packets": 0,
-				"rx_errors":  0,
-				"rx_dropped": 0,
-				"tx_bytes":   0,
-				"tx_packets": 0,
-				"tx_errors":  0,
-				"tx_dropped": 0,
-			}
-		}
+func (sw *statsResponseWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.w.Header().Set("Content-Type", "application/json")
+		sw.w.WriteHeader(http.StatusOK)
+		sw.started = true
+	}
+	n, err := sw.w.Write(p)
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
 	}
-	return netStats
+	return n, err
 }
 
 func (s *BaseServer) handleContainerRename(w http.ResponseWriter, r *http.Request) {
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"archive/tar"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -55,6 +54,14 @@ func StoreImageWithAliases(store *Store, ref string, img api.Image) {
 
 // --- Default image pull (delegates to s.self for virtual dispatch) ---
 
+// pullImage pulls through the registry driver, timing the pull into
+// sockerless_image_pull_duration_seconds once the stream is closed.
+func (s *BaseServer) pullImage(dctx DriverContext, ref ImageRef, auth string) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := s.Typed.Registry.Pull(dctx, ref, auth)
+	return s.Prom.timePull(start, rc, err)
+}
+
 func (s *BaseServer) handleImagePull(w http.ResponseWriter, r *http.Request) {
 	var req api.ImagePullRequest
 	if err := ReadJSON(r, &req); err != nil {
@@ -68,7 +75,7 @@ func (s *BaseServer) handleImagePull(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	dctx := DriverContext{Ctx: r.Context(), Backend: s.Desc.Driver, Logger: s.Logger}
-	rc, err := s.Typed.Registry.Pull(dctx, parsed, req.Auth)
+	rc, err := s.pullImage(dctx, parsed, req.Auth)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -77,12 +84,7 @@ func (s *BaseServer) handleImagePull(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if _, err := io.Copy(w, rc); err != nil {
-		s.Logger.Debug().Err(err).Msg("image pull stream copy failed — client likely disconnected")
-	}
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
-	}
+	FlushingCopy(w, s.admitPulledImage(r.Context(), req.Reference, rc))
 }
 
 // --- Common image handlers ---
@@ -94,7 +96,7 @@ func (s *BaseServer) handleImageInspect(w http.ResponseWriter, r *http.Request)
 		WriteError(w, &api.NotFoundError{Resource: "image", ID: name})
 		return
 	}
-	WriteJSON(w, http.StatusOK, img)
+	WriteJSON(w, http.StatusOK, s.withImageScan(&img))
 }
 
 func (s *BaseServer) handleImageLoad(w http.ResponseWriter, r *http.Request) {
@@ -120,104 +122,6 @@ func (s *BaseServer) handleImageLoad(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// imageLoadResult holds parsed data from a docker save tar archive.
-type imageLoadResult struct {
-	RepoTags []string
-	Config   *api.ContainerConfig
-	Layers   map[string][]byte // layer path → content (e.g. "abc123/layer.tar" → bytes)
-}
-
-// parseImageTarFull parses a docker save tar and preserves layer content.
-// Handles two on-disk layouts:
-//
-//   - Classic docker save layout: config blob at root as `<digest>.json`,
-//     layers under `<digest>/layer.tar`. manifest.json's Config field is
-//     a bare filename like `1ab49....json`.
-//
-//   - OCI v1 layout (modern docker / BuildKit): blobs under
-//     `blobs/sha256/<digest>` with no extension; manifest.json's Config
-//     field is a path like `blobs/sha256/<digest>`. Layer .tar.gz blobs
-//     also live under `blobs/sha256/`.
-//
-// Both formats use the same outer manifest.json structure ([{Config,
-// RepoTags, Layers}]); the difference is just where the referenced
-// blobs live in the tar. We index every file we encounter by its full
-// path so the manifest's Config/Layers references resolve regardless
-// of layout.
-func parseImageTarFull(body io.Reader) *imageLoadResult {
-	tr := tar.NewReader(body)
-	var manifestData []byte
-	allFiles := make(map[string][]byte)
-
-	for {
-		hdr, err := tr.Next()
-		if err != nil {
-			break
-		}
-		if hdr.Typeflag == tar.TypeDir {
-			continue
-		}
-		data, _ := io.ReadAll(tr)
-		if hdr.Name == "manifest.json" {
-			manifestData = data
-			continue
-		}
-		allFiles[hdr.Name] = data
-	}
-
-	if manifestData == nil {
-		return nil
-	}
-
-	var manifest []struct {
-		Config   string   `json:"Config"`
-		RepoTags []string `json:"RepoTags"`
-		Layers   []string `json:"Layers"`
-	}
-	if json.Unmarshal(manifestData, &manifest) != nil || len(manifest) == 0 {
-		return nil
-	}
-
-	// Layer files: index under both their classic key
-	// (`<digest>/layer.tar`) and OCI key (`blobs/sha256/<digest>`).
-	// Callers downstream may key by either path.
-	layerFiles := make(map[string][]byte)
-	for _, layerPath := range manifest[0].Layers {
-		if data, ok := allFiles[layerPath]; ok {
-			layerFiles[layerPath] = data
-		}
-	}
-
-	result := &imageLoadResult{
-		RepoTags: manifest[0].RepoTags,
-		Layers:   layerFiles,
-	}
-
-	// Parse config blob for container config. The blob path comes
-	// directly from manifest.json's Config field — same lookup works
-	// for both classic (`<digest>.json`) and OCI (`blobs/sha256/<digest>`)
-	// layouts because we indexed every file in the tar. Reuses the
-	// canonical `ociImageConfig` schema from registry.go so the
-	// in-tar (`docker save`) and over-the-wire (registry-pull) parsers
-	// share one source of truth for the OCI image-config shape.
-	if configData, ok := allFiles[manifest[0].Config]; ok {
-		var ociCfg ociImageConfig
-		if json.Unmarshal(configData, &ociCfg) == nil {
-			result.Config = &api.ContainerConfig{
-				Env:          ociCfg.Config.Env,
-				Cmd:          ociCfg.Config.Cmd,
-				Entrypoint:   ociCfg.Config.Entrypoint,
-				WorkingDir:   ociCfg.Config.WorkingDir,
-				User:         ociCfg.Config.User,
-				Labels:       ociCfg.Config.Labels,
-				ExposedPorts: ociCfg.Config.ExposedPorts,
-			}
-		}
-	}
-
-	return result
-}
-
 func (s *BaseServer) handleImageTag(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
 	img, ok := s.Store.ResolveImage(name)
@@ -84,6 +84,7 @@ func (s *BaseServer) handleLibpodContainerRemove(w http.ResponseWriter, r *http.
 		WriteError(w, err)
 		return
 	}
+	s.haReleaseContainer(r.Context(), id)
 
 	WriteJSON(w, http.StatusOK, []map[string]any{
 		{"Id": id, "Err": nil},
@@ -188,6 +189,15 @@ func (s *BaseServer) handleLibpodContainerCreate(w http.ResponseWriter, r *http.
 		}
 	}
 
+	if req.ContainerConfig != nil {
+		image, err := s.admitImage(r.Context(), req.Image, ScanEnforceCreate)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		req.Image = image
+	}
+
 	resp, err := s.self.ContainerCreate(&req)
 	if err != nil {
 		WriteError(w, err)
@@ -198,6 +208,7 @@ func (s *BaseServer) handleLibpodContainerCreate(w http.ResponseWriter, r *http.
 		pod, _ := s.Store.Pods.GetPod(podRef)
 		_ = s.Store.Pods.AddContainer(pod.ID, resp.ID)
 	}
+	s.haClaimContainer(r.Context(), resp.ID)
 
 	WriteJSON(w, http.StatusCreated, resp)
 }
@@ -222,7 +233,7 @@ func (s *BaseServer) handleLibpodImagePull(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	dctx := DriverContext{Ctx: r.Context(), Backend: s.Desc.Driver, Logger: s.Logger}
-	rc, err := s.Typed.Registry.Pull(dctx, parsed, auth)
+	rc, err := s.pullImage(dctx, parsed, auth)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -230,7 +241,12 @@ func (s *BaseServer) handleLibpodImagePull(w http.ResponseWriter, r *http.Reques
 	defer rc.Close()
 
 	// Consume the Docker-format pull stream (discard progress)
-	_, _ = io.Copy(io.Discard, rc)
+	out := s.admitPulledImage(r.Context(), ref, rc)
+	_, _ = io.Copy(io.Discard, out)
+	if err := out.Err(); err != nil {
+		WriteError(w, err)
+		return
+	}
 
 	// Resolve the pulled image to get its ID
 	imageID := ""
@@ -86,6 +86,11 @@ type CheckResult struct {
 	Name   string `json:"name"`
 	Status string `json:"status"` // "ok" or "error"
 	Detail string `json:"detail,omitempty"`
+	// Permission, Resource and Verbs are set on permission-preflight
+	// failures: the missing cloud permission and the docker verbs it breaks.
+	Permission string   `json:"permission,omitempty"`
+	Resource   string   `json:"resource,omitempty"`
+	Verbs      []string `json:"verbs,omitempty"`
 }
 
 // HealthChecker is an interface for backend-specific health checks.
@@ -102,6 +102,10 @@ func (s *BaseServer) handlePodExists(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *BaseServer) handlePodStart(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkPodCostBudget(r.PathValue("name")); err != nil {
+		WriteError(w, err)
+		return
+	}
 	resp, err := s.self.PodStart(r.PathValue("name"))
 	if err != nil {
 		WriteError(w, err)
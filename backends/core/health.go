@@ -213,6 +213,33 @@ func (s *BaseServer) execHealthCheck(ctx context.Context, containerID string, cm
 	return exitCode, buf.String()
 }
 
+// HasHealthcheck reports whether c declares a Docker health check other
+// than NONE.
+func HasHealthcheck(c api.Container) bool {
+	hc := c.Config.Healthcheck
+	return hc != nil && parseHealthcheckCmd(hc.Test) != nil
+}
+
+// HealthcheckTiming returns hc's interval, timeout, start period and
+// retries with Docker's defaults filled in, for backends that hand the
+// check to a cloud-side probe.
+func HealthcheckTiming(hc *api.HealthcheckConfig) (interval, timeout, startPeriod time.Duration, retries int) {
+	interval, timeout, startPeriod, retries = defaultHealthInterval, defaultHealthTimeout, defaultHealthStartPeriod, defaultHealthRetries
+	if hc.Interval > 0 {
+		interval = time.Duration(hc.Interval)
+	}
+	if hc.Timeout > 0 {
+		timeout = time.Duration(hc.Timeout)
+	}
+	if hc.StartPeriod > 0 {
+		startPeriod = time.Duration(hc.StartPeriod)
+	}
+	if hc.Retries > 0 {
+		retries = hc.Retries
+	}
+	return interval, timeout, startPeriod, retries
+}
+
 // parseHealthcheckCmd converts a Healthcheck.Test array into an exec command.
 // Returns nil for NONE or invalid formats.
 func parseHealthcheckCmd(test []string) []string {
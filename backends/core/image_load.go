@@ -0,0 +1,672 @@
+package core
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// ImageImportOptions carries the `docker import` query parameters.
+type ImageImportOptions struct {
+	Repo     string
+	Tag      string
+	Message  string
+	Changes  []string // Dockerfile instructions, as for `docker commit --change`
+	Platform string   // os/arch; empty selects the backend's architecture
+}
+
+// ImageImporter is implemented by backends that build an image from a
+// rootfs tarball (`docker import`). BaseServer implements it over the
+// in-process image cache; cloud backends route it through
+// ImageManager.Import so the image lands in their registry.
+type ImageImporter interface {
+	ImageImport(r io.Reader, opts ImageImportOptions) (io.ReadCloser, error)
+}
+
+// loadedImage is one image read from a `docker load` archive or built
+// by `docker import`: the original config blob (whose digest is the
+// image ID) and its layers ready for upload.
+type loadedImage struct {
+	repoTags []string
+	config   []byte
+	layers   []loadedLayer
+}
+
+// loadedLayer is a layer blob in the gzip form it is pushed in, with
+// the digest of that blob and the diff_id of the uncompressed tar.
+type loadedLayer struct {
+	blob   []byte
+	digest string
+	diffID string
+}
+
+// ociLayoutIndex is the index.json of an OCI image layout, or a nested
+// image index blob inside one.
+type ociLayoutIndex struct {
+	Manifests []ociLayoutDescriptor `json:"manifests"`
+}
+
+type ociLayoutDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Platform    *manifestPlatform `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ImageLoad loads the images of a docker-archive or OCI-layout tarball
+// into the image cache. ImageManager.Load also pushes them into the
+// backend's cloud registry.
+func (s *BaseServer) ImageLoad(r io.Reader) (io.ReadCloser, error) {
+	images, err := s.loadImageArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	return loadedImagesStream(images), nil
+}
+
+// ImageImport builds a single-layer image from a rootfs tarball and
+// stores it in the image cache. ImageManager.Import also pushes it
+// into the backend's cloud registry.
+func (s *BaseServer) ImageImport(r io.Reader, opts ImageImportOptions) (io.ReadCloser, error) {
+	img, err := s.importImage(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return importedImageStream(img), nil
+}
+
+// loadImageArchive parses the archive and stores every image in it.
+// Nothing is stored unless the whole archive is valid.
+func (s *BaseServer) loadImageArchive(r io.Reader) ([]api.Image, error) {
+	parsed, err := readImageArchive(r, s.Desc.Architecture)
+	if err != nil {
+		return nil, err
+	}
+	images := make([]api.Image, 0, len(parsed))
+	for _, li := range parsed {
+		images = append(images, s.storeLoadedImage(li, "load"))
+	}
+	return images, nil
+}
+
+// importImage turns a rootfs tarball (plain, gzip or bzip2) into an
+// image whose config is built from opts.Changes.
+func (s *BaseServer) importImage(r io.Reader, opts ImageImportOptions) (api.Image, error) {
+	osName, arch := "linux", s.Desc.Architecture
+	if opts.Platform != "" {
+		parts := strings.Split(opts.Platform, "/")
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return api.Image{}, &api.InvalidParameterError{Message: fmt.Sprintf("invalid platform %q: want os/arch", opts.Platform)}
+		}
+		osName, arch = parts[0], parts[1]
+	}
+	if arch == "" {
+		return api.Image{}, &api.ServerError{Message: "docker import: backend architecture is not set"}
+	}
+
+	var cfg api.ContainerConfig
+	if err := applyImageChanges(&cfg, opts.Changes); err != nil {
+		return api.Image{}, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return api.Image{}, fmt.Errorf("read import source: %w", err)
+	}
+	if len(data) == 0 {
+		return api.Image{}, &api.InvalidParameterError{Message: "docker import: the rootfs tarball is empty"}
+	}
+	layer, err := prepareLoadedLayer(data)
+	if err != nil {
+		return api.Image{}, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	comment := opts.Message
+	if comment == "" {
+		comment = "Imported from -"
+	}
+	config, err := json.Marshal(struct {
+		Architecture string           `json:"architecture"`
+		OS           string           `json:"os"`
+		Created      string           `json:"created"`
+		Config       *imageConfigBlob `json:"config,omitempty"`
+		RootFS       ociRootFS        `json:"rootfs"`
+		History      []ociHistoryItem `json:"history"`
+	}{
+		Architecture: arch,
+		OS:           osName,
+		Created:      now,
+		Config:       imageConfigFromAPI(cfg),
+		RootFS:       ociRootFS{Type: "layers", DiffIDs: []string{layer.diffID}},
+		History:      []ociHistoryItem{{Created: now, Comment: comment}},
+	})
+	if err != nil {
+		return api.Image{}, fmt.Errorf("marshal import config: %w", err)
+	}
+
+	var repoTags []string
+	if opts.Repo != "" {
+		tag := opts.Tag
+		if tag == "" {
+			tag = "latest"
+		}
+		repoTags = []string{opts.Repo + ":" + tag}
+	}
+	return s.storeLoadedImage(&loadedImage{repoTags: repoTags, config: config, layers: []loadedLayer{layer}}, "import"), nil
+}
+
+// storeLoadedImage records li in the image cache along with the layer
+// blobs, manifest entries and config blob ImagePush needs to upload it.
+// A tag that named a different image moves to this one.
+func (s *BaseServer) storeLoadedImage(li *loadedImage, action string) api.Image {
+	var oc ociImageConfig
+	_ = json.Unmarshal(li.config, &oc) // validated by readImageArchive / importImage
+	id := fmt.Sprintf("sha256:%x", sha256.Sum256(li.config))
+
+	repoTags := li.repoTags
+	if existing, ok := s.Store.Images.Get(id); ok {
+		repoTags = append(append([]string{}, existing.RepoTags...), li.repoTags...)
+	}
+	seen := make(map[string]bool, len(repoTags))
+	uniqueTags := repoTags[:0:0]
+	for _, t := range repoTags {
+		if !seen[t] {
+			seen[t] = true
+			uniqueTags = append(uniqueTags, t)
+		}
+	}
+	repoTags = uniqueTags
+	for _, tag := range li.repoTags {
+		if prev, ok := s.Store.Images.Get(tag); ok && prev.ID != id {
+			s.untagImage(prev, tag)
+		}
+	}
+
+	diffIDs := make([]string, len(li.layers))
+	manifestLayers := make([]ManifestLayerEntry, len(li.layers))
+	var size int64
+	for i, l := range li.layers {
+		diffIDs[i] = l.diffID
+		manifestLayers[i] = ManifestLayerEntry{Digest: l.digest, Size: int64(len(l.blob)), MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip"}
+		size += int64(len(l.blob))
+		s.Store.LayerContent.Store(l.digest, l.blob)
+	}
+	s.Store.ImageManifestLayers.Store(id, manifestLayers)
+	s.Store.ImageConfigBlobs.Store(id, li.config)
+
+	history := make([]ImageHistoryItem, 0, len(oc.History))
+	for _, h := range oc.History {
+		history = append(history, ImageHistoryItem(h))
+	}
+	if len(history) > 0 {
+		s.Store.ImageHistory.Store(id, history)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	created := oc.Created
+	if created == "" {
+		created = now
+	}
+	cfg := api.ContainerConfig{
+		Env:          oc.Config.Env,
+		Cmd:          oc.Config.Cmd,
+		Entrypoint:   oc.Config.Entrypoint,
+		WorkingDir:   oc.Config.WorkingDir,
+		User:         oc.Config.User,
+		Labels:       oc.Config.Labels,
+		ExposedPorts: oc.Config.ExposedPorts,
+		Volumes:      oc.Config.Volumes,
+		StopSignal:   oc.Config.StopSignal,
+	}
+	if cfg.Labels == nil {
+		cfg.Labels = make(map[string]string)
+	}
+	img := api.Image{
+		ID:           id,
+		RepoTags:     repoTags,
+		Created:      created,
+		Size:         size,
+		VirtualSize:  size,
+		Architecture: oc.Architecture,
+		Os:           oc.OS,
+		Author:       oc.Author,
+		Config:       cfg,
+		RootFS:       api.RootFS{Type: "layers", Layers: diffIDs},
+		GraphDriver: api.GraphDriverData{
+			Name: "overlay2",
+			Data: map[string]string{
+				"MergedDir": "/var/lib/sockerless/overlay2/" + id[7:19] + "/merged",
+				"UpperDir":  "/var/lib/sockerless/overlay2/" + id[7:19] + "/diff",
+				"WorkDir":   "/var/lib/sockerless/overlay2/" + id[7:19] + "/work",
+			},
+		},
+		Metadata: api.ImageMetadata{LastTagTime: now},
+	}
+	s.Store.Images.Put(id, img)
+	for _, tag := range repoTags {
+		StoreImageWithAliases(s.Store, tag, img)
+	}
+
+	name := id
+	if len(li.repoTags) > 0 {
+		name = li.repoTags[0]
+	}
+	s.emitEvent("image", action, id, map[string]string{"name": name})
+	return img
+}
+
+// readImageArchive parses a `docker save` tarball — docker-archive
+// (manifest.json) or OCI image layout (index.json) — into images ready
+// to store and push. For OCI image indexes the entry for linux/arch is
+// taken. Each image's layers are checked against its config's
+// rootfs.diff_ids.
+func readImageArchive(r io.Reader, arch string) ([]*loadedImage, error) {
+	r, err := decompressedArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	files, err := readArchiveFiles(r)
+	if err != nil {
+		return nil, err
+	}
+	var images []*loadedImage
+	switch {
+	case files["manifest.json"] != nil:
+		images, err = readDockerArchive(files)
+	case files["index.json"] != nil:
+		images, err = readOCILayout(files, arch)
+	default:
+		return nil, &api.InvalidParameterError{Message: "docker load: archive has neither manifest.json (docker-archive) nor index.json (OCI layout)"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, &api.InvalidParameterError{Message: "docker load: archive contains no images"}
+	}
+	return images, nil
+}
+
+// decompressedArchive unwraps a gzip or bzip2 compressed archive, as
+// `docker load -i image.tar.gz` sends it.
+func decompressedArchive(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: invalid gzip stream: %v", err)}
+		}
+		return zr, nil
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(br), nil
+	}
+	return br, nil
+}
+
+// readArchiveFiles reads every regular file of the tarball keyed by its
+// cleaned path. Symlinks and hard links, which the legacy docker-archive
+// layout uses to share layer.tar files, resolve to their target's bytes.
+func readArchiveFiles(r io.Reader) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	links := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: read archive: %v", err)}
+		}
+		name := archiveFileName(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: read %s: %v", hdr.Name, err)}
+			}
+			files[name] = data
+		case tar.TypeSymlink:
+			links[name] = archiveFileName(path.Join(path.Dir(name), hdr.Linkname))
+		case tar.TypeLink:
+			links[name] = archiveFileName(hdr.Linkname)
+		}
+	}
+	for name, target := range links {
+		for i := 0; i < 8 && files[target] == nil && links[target] != ""; i++ {
+			target = links[target]
+		}
+		if data, ok := files[target]; ok {
+			files[name] = data
+		}
+	}
+	return files, nil
+}
+
+func archiveFileName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// readDockerArchive reads the images listed in a docker-archive's
+// manifest.json. Both the legacy (`<id>/layer.tar`, `<id>.json`) and
+// the blobs/sha256 layouts are covered: entries are paths in the tar.
+func readDockerArchive(files map[string][]byte) ([]*loadedImage, error) {
+	var entries []dockerArchiveManifest
+	if err := json.Unmarshal(files["manifest.json"], &entries); err != nil {
+		return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: invalid manifest.json: %v", err)}
+	}
+	images := make([]*loadedImage, 0, len(entries))
+	for _, e := range entries {
+		config, ok := files[archiveFileName(e.Config)]
+		if !ok {
+			return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: config %s is missing from the archive", e.Config)}
+		}
+		blobs := make([][]byte, len(e.Layers))
+		for i, p := range e.Layers {
+			if blobs[i], ok = files[archiveFileName(p)]; !ok {
+				return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: layer %s is missing from the archive", p)}
+			}
+		}
+		img, err := newLoadedImage(e.RepoTags, config, blobs)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// readOCILayout reads the images referenced by an OCI layout's
+// index.json. Tags come from the containerd image-name annotation or a
+// fully-qualified org.opencontainers.image.ref.name; a bare ref.name
+// tag has no repository and the image loads untagged.
+func readOCILayout(files map[string][]byte, arch string) ([]*loadedImage, error) {
+	var index ociLayoutIndex
+	if err := json.Unmarshal(files["index.json"], &index); err != nil {
+		return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: invalid index.json: %v", err)}
+	}
+	var images []*loadedImage
+	for _, desc := range index.Manifests {
+		manifest, err := ociLayoutManifest(files, desc, arch)
+		if err != nil {
+			return nil, err
+		}
+		config, ok := files[archiveBlobPath(manifest.Config.Digest)]
+		if !ok {
+			return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: config blob %s is missing from the archive", manifest.Config.Digest)}
+		}
+		blobs := make([][]byte, len(manifest.Layers))
+		for i, l := range manifest.Layers {
+			if blobs[i], ok = files[archiveBlobPath(l.Digest)]; !ok {
+				return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: layer blob %s is missing from the archive", l.Digest)}
+			}
+		}
+		var repoTags []string
+		if name := desc.Annotations["io.containerd.image.name"]; name != "" {
+			repoTags = []string{name}
+		} else if name := desc.Annotations["org.opencontainers.image.ref.name"]; strings.ContainsAny(name, "/:") {
+			repoTags = []string{name}
+		}
+		img, err := newLoadedImage(repoTags, config, blobs)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// ociLayoutManifest returns the image manifest desc points at, picking
+// the linux/arch entry when desc is itself an image index.
+func ociLayoutManifest(files map[string][]byte, desc ociLayoutDescriptor, arch string) (*singleManifest, error) {
+	for depth := 0; depth < 4; depth++ {
+		body, ok := files[archiveBlobPath(desc.Digest)]
+		if !ok {
+			return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: manifest blob %s is missing from the archive", desc.Digest)}
+		}
+		if !strings.Contains(desc.MediaType, "image.index") && !strings.Contains(desc.MediaType, "manifest.list") {
+			var m singleManifest
+			if err := json.Unmarshal(body, &m); err != nil || m.Config.Digest == "" {
+				return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: invalid image manifest %s", desc.Digest)}
+			}
+			return &m, nil
+		}
+		var nested ociLayoutIndex
+		if err := json.Unmarshal(body, &nested); err != nil {
+			return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: invalid image index %s: %v", desc.Digest, err)}
+		}
+		found := false
+		available := make([]string, 0, len(nested.Manifests))
+		for _, m := range nested.Manifests {
+			if m.Platform == nil {
+				continue
+			}
+			if m.Platform.OS == "linux" && m.Platform.Architecture == arch {
+				desc, found = m, true
+				break
+			}
+			available = append(available, m.Platform.OS+"/"+m.Platform.Architecture)
+		}
+		if !found {
+			return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: image index %s has no linux/%s entry; available platforms: %s", desc.Digest, arch, strings.Join(available, ", "))}
+		}
+	}
+	return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: image index nesting too deep at %s", desc.Digest)}
+}
+
+// newLoadedImage prepares each layer blob for upload and checks the
+// layers against config's rootfs.diff_ids, which a registry client
+// verifies on pull.
+func newLoadedImage(repoTags []string, config []byte, blobs [][]byte) (*loadedImage, error) {
+	var oc ociImageConfig
+	if err := json.Unmarshal(config, &oc); err != nil {
+		return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: invalid image config: %v", err)}
+	}
+	if len(oc.RootFS.DiffIDs) != len(blobs) {
+		return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: config lists %d layers, archive has %d", len(oc.RootFS.DiffIDs), len(blobs))}
+	}
+	layers := make([]loadedLayer, len(blobs))
+	for i, b := range blobs {
+		l, err := prepareLoadedLayer(b)
+		if err != nil {
+			return nil, err
+		}
+		if l.diffID != oc.RootFS.DiffIDs[i] {
+			return nil, &api.InvalidParameterError{Message: fmt.Sprintf("docker load: layer %d has diff_id %s, config says %s", i, l.diffID, oc.RootFS.DiffIDs[i])}
+		}
+		layers[i] = l
+	}
+	return &loadedImage{repoTags: repoTags, config: config, layers: layers}, nil
+}
+
+// prepareLoadedLayer returns data as a gzip layer blob. A gzip blob is
+// kept byte-for-byte (its diff_id is the digest of the decompressed
+// tar); an uncompressed or bzip2 tar is gzipped.
+func prepareLoadedLayer(data []byte) (loadedLayer, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return loadedLayer{}, &api.InvalidParameterError{Message: fmt.Sprintf("layer: invalid gzip stream: %v", err)}
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, zr); err != nil {
+			return loadedLayer{}, &api.InvalidParameterError{Message: fmt.Sprintf("layer: invalid gzip stream: %v", err)}
+		}
+		return loadedLayer{
+			blob:   data,
+			digest: fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+			diffID: "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		}, nil
+	case bytes.HasPrefix(data, []byte("BZh")):
+		tarData, err := io.ReadAll(bzip2.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return loadedLayer{}, &api.InvalidParameterError{Message: fmt.Sprintf("layer: invalid bzip2 stream: %v", err)}
+		}
+		return gzipLoadedLayer(tarData)
+	case bytes.HasPrefix(data, []byte{0x28, 0xb5, 0x2f, 0xfd}), bytes.HasPrefix(data, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return loadedLayer{}, &api.InvalidParameterError{Message: "layer: zstd and xz compression are not supported; supply a plain, gzip or bzip2 tar"}
+	}
+	if _, err := tar.NewReader(bytes.NewReader(data)).Next(); err != nil && err != io.EOF {
+		return loadedLayer{}, &api.InvalidParameterError{Message: fmt.Sprintf("layer is not a tar archive: %v", err)}
+	}
+	return gzipLoadedLayer(data)
+}
+
+func gzipLoadedLayer(tarData []byte) (loadedLayer, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(tarData); err != nil {
+		return loadedLayer{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return loadedLayer{}, err
+	}
+	blob := buf.Bytes()
+	return loadedLayer{
+		blob:   blob,
+		digest: fmt.Sprintf("sha256:%x", sha256.Sum256(blob)),
+		diffID: fmt.Sprintf("sha256:%x", sha256.Sum256(tarData)),
+	}, nil
+}
+
+// loadedImagesStream is the `docker load` progress stream: one line per
+// tag, or per image ID for untagged images.
+func loadedImagesStream(images []api.Image) io.ReadCloser {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, img := range images {
+		if len(img.RepoTags) == 0 {
+			_ = enc.Encode(map[string]string{"stream": "Loaded image ID: " + img.ID + "\n"})
+			continue
+		}
+		for _, tag := range img.RepoTags {
+			_ = enc.Encode(map[string]string{"stream": "Loaded image: " + tag + "\n"})
+		}
+	}
+	return io.NopCloser(&buf)
+}
+
+// importedImageStream is the `docker import` response; the CLI prints
+// the status, the new image ID.
+func importedImageStream(img api.Image) io.ReadCloser {
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(map[string]string{"status": img.ID})
+	return io.NopCloser(&buf)
+}
+
+// publishLoadedImages pushes images into the backend's cloud registry
+// and records the reference each one runs from. A tag already in the
+// cloud registry is pushed as-is; any other tag, and an untagged
+// image, is retagged under LoadRepository first.
+func (m *ImageManager) publishLoadedImages(images []api.Image) ([]api.Image, error) {
+	out := make([]api.Image, 0, len(images))
+	for _, img := range images {
+		refs := img.RepoTags
+		if len(refs) == 0 {
+			refs = []string{"sockerless-loaded:" + strings.TrimPrefix(img.ID, "sha256:")}
+		}
+		published := ""
+		for _, ref := range refs {
+			target, err := m.loadTarget(ref)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := ParseImageRef(target)
+			if err != nil {
+				return nil, &api.InvalidParameterError{Message: fmt.Sprintf("registry reference %q for %s: %v", target, ref, err)}
+			}
+			if target != ref {
+				if err := m.Base.ImageTag(img.ID, parsed.FullName(), parsed.Tag); err != nil {
+					return nil, err
+				}
+			}
+			if err := m.pushLoadedRef(parsed); err != nil {
+				return nil, fmt.Errorf("push %s to %s: %w", ref, target, err)
+			}
+			if published == "" {
+				published = target
+			}
+		}
+		m.Base.Store.PublishedImages.Store(img.ID, published)
+		if updated, ok := m.Base.Store.Images.Get(img.ID); ok {
+			img = updated
+		}
+		out = append(out, img)
+	}
+	return out, nil
+}
+
+// loadTarget returns the cloud-registry reference ref is pushed to.
+func (m *ImageManager) loadTarget(ref string) (string, error) {
+	parsed, err := ParseImageRef(ref)
+	if err != nil {
+		return "", &api.InvalidParameterError{Message: fmt.Sprintf("invalid image reference %q: %v", ref, err)}
+	}
+	if m.Auth != nil && m.Auth.IsCloudRegistry(parsed.Domain) {
+		return ref, nil
+	}
+	if m.LoadRepository == "" {
+		return "", &api.NotImplementedError{Message: fmt.Sprintf("%s is not in the backend's cloud registry and no image repository is configured for loaded images; set SOCKERLESS_<BACKEND>_IMAGE_REPOSITORY or tag the image with a registry reference", ref)}
+	}
+	path := strings.TrimPrefix(parsed.Path, "library/")
+	tag := parsed.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return strings.TrimRight(m.LoadRepository, "/") + "/" + path + ":" + tag, nil
+}
+
+// pushLoadedRef pushes ref through the backend's RegistryDriver and
+// turns an error line in the progress stream into an error.
+func (m *ImageManager) pushLoadedRef(ref ImageRef) error {
+	dctx := DriverContext{Ctx: context.Background(), Backend: m.Base.Desc.Driver, Logger: m.Base.Logger}
+	rc, err := m.Base.Typed.Registry.Push(dctx, ref, "")
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	dec := json.NewDecoder(rc)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read push progress: %w", err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("%s", msg.Error)
+		}
+	}
+}
+
+// RegistryRef returns the cloud-registry reference ContainerCreate
+// should run for image: where `docker load` / `docker import` pushed
+// it, or image unchanged.
+func (m *ImageManager) RegistryRef(image string) string {
+	img, ok := m.Base.Store.ResolveImage(image)
+	if !ok {
+		return image
+	}
+	if v, ok := m.Base.Store.PublishedImages.Load(img.ID); ok {
+		return v.(string)
+	}
+	return image
+}
@@ -0,0 +1,316 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/sockerless/api"
+)
+
+// loadTestTar builds an in-memory tarball from name → content pairs.
+func loadTestTar(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f[0], Mode: 0o644, Size: int64(len(f[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func loadTestGzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// dockerArchiveForTest returns a docker-archive holding one image tagged
+// tag whose single layer is stored uncompressed, as `docker save` does.
+func dockerArchiveForTest(t *testing.T, tag string, diffID string) []byte {
+	t.Helper()
+	layer := loadTestTar(t, [2]string{"hello.txt", "hello"})
+	if diffID == "" {
+		diffID = sha256Digest(layer)
+	}
+	config, _ := json.Marshal(map[string]any{
+		"architecture": "amd64",
+		"os":           "linux",
+		"config":       map[string]any{"Cmd": []string{"cat", "/hello.txt"}},
+		"rootfs":       map[string]any{"type": "layers", "diff_ids": []string{diffID}},
+	})
+	manifest, _ := json.Marshal([]dockerArchiveManifest{{
+		Config:   "config.json",
+		RepoTags: []string{tag},
+		Layers:   []string{"layer/layer.tar"},
+	}})
+	return loadTestTar(t,
+		[2]string{"config.json", string(config)},
+		[2]string{"layer/layer.tar", string(layer)},
+		[2]string{"manifest.json", string(manifest)},
+	)
+}
+
+func TestImageLoad_DockerArchive(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+
+	rc, err := s.ImageLoad(bytes.NewReader(dockerArchiveForTest(t, "app:v1", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := io.ReadAll(rc)
+	if !strings.Contains(string(out), "Loaded image: app:v1") {
+		t.Errorf("load output = %s", out)
+	}
+
+	img, ok := s.Store.ResolveImage("app:v1")
+	if !ok {
+		t.Fatal("app:v1 not in the image cache")
+	}
+	if len(img.Config.Cmd) != 2 || img.Config.Cmd[0] != "cat" {
+		t.Errorf("Cmd = %v", img.Config.Cmd)
+	}
+	v, ok := s.Store.ImageManifestLayers.Load(img.ID)
+	if !ok {
+		t.Fatal("no manifest layers recorded")
+	}
+	layers := v.([]ManifestLayerEntry)
+	if len(layers) != 1 {
+		t.Fatalf("manifest layers = %+v", layers)
+	}
+	blob, ok := s.Store.LayerContent.Load(layers[0].Digest)
+	if !ok {
+		t.Fatal("layer blob not cached")
+	}
+	// The uncompressed layer is stored gzipped for the registry push.
+	if b := blob.([]byte); len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+		t.Errorf("cached layer is not gzip")
+	}
+	if sha256Digest(blob.([]byte)) != layers[0].Digest {
+		t.Errorf("cached layer digest does not match its manifest entry")
+	}
+}
+
+func TestImageLoad_GzipCompressedArchive(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+
+	archive := loadTestGzip(t, dockerArchiveForTest(t, "app:v1", ""))
+	if _, err := s.ImageLoad(bytes.NewReader(archive)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Store.ResolveImage("app:v1"); !ok {
+		t.Fatal("app:v1 not in the image cache")
+	}
+}
+
+func TestImageLoad_RejectsDiffIDMismatch(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+
+	_, err := s.ImageLoad(bytes.NewReader(dockerArchiveForTest(t, "app:v1", "sha256:"+strings.Repeat("0", 64))))
+	if err == nil {
+		t.Fatal("expected diff_id mismatch error")
+	}
+	if _, ok := s.Store.ResolveImage("app:v1"); ok {
+		t.Error("image stored despite the invalid archive")
+	}
+}
+
+func TestImageLoad_OCILayout(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "arm64"
+
+	layerTar := loadTestTar(t, [2]string{"etc/os-release", "ID=test"})
+	layer := loadTestGzip(t, layerTar)
+	config, _ := json.Marshal(map[string]any{
+		"architecture": "arm64",
+		"os":           "linux",
+		"rootfs":       map[string]any{"type": "layers", "diff_ids": []string{sha256Digest(layerTar)}},
+	})
+	manifest, _ := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        map[string]any{"mediaType": "application/vnd.oci.image.config.v1+json", "digest": sha256Digest(config), "size": len(config)},
+		"layers":        []map[string]any{{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": sha256Digest(layer), "size": len(layer)}},
+	})
+	index, _ := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"manifests": []map[string]any{{
+			"mediaType":   "application/vnd.oci.image.manifest.v1+json",
+			"digest":      sha256Digest(manifest),
+			"size":        len(manifest),
+			"platform":    map[string]string{"os": "linux", "architecture": "arm64"},
+			"annotations": map[string]string{"io.containerd.image.name": "registry.example.com/team/tool:1.0"},
+		}},
+	})
+	archive := loadTestTar(t,
+		[2]string{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+		[2]string{"index.json", string(index)},
+		[2]string{archiveBlobPath(sha256Digest(manifest)), string(manifest)},
+		[2]string{archiveBlobPath(sha256Digest(config)), string(config)},
+		[2]string{archiveBlobPath(sha256Digest(layer)), string(layer)},
+	)
+
+	if _, err := s.ImageLoad(bytes.NewReader(archive)); err != nil {
+		t.Fatal(err)
+	}
+	img, ok := s.Store.ResolveImage("registry.example.com/team/tool:1.0")
+	if !ok {
+		t.Fatal("OCI layout image not in the image cache")
+	}
+	if img.ID != sha256Digest(config) {
+		t.Errorf("ID = %s, want the config digest %s", img.ID, sha256Digest(config))
+	}
+	v, _ := s.Store.ImageManifestLayers.Load(img.ID)
+	if layers := v.([]ManifestLayerEntry); len(layers) != 1 || layers[0].Digest != sha256Digest(layer) {
+		t.Errorf("gzip layer was not kept verbatim: %+v", layers)
+	}
+}
+
+func TestImageImport_AppliesChanges(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+
+	rootfs := loadTestTar(t, [2]string{"bin/app", "#!/bin/sh"})
+	rc, err := s.ImageImport(bytes.NewReader(rootfs), ImageImportOptions{
+		Repo:    "imported/app",
+		Tag:     "v2",
+		Changes: []string{`CMD ["/bin/app"]`, "ENV MODE=prod", "EXPOSE 8080", "WORKDIR /srv"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status map[string]string
+	if err := json.NewDecoder(rc).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+
+	img, ok := s.Store.ResolveImage("imported/app:v2")
+	if !ok {
+		t.Fatal("imported image not in the image cache")
+	}
+	if status["status"] != img.ID {
+		t.Errorf("status = %q, want %s", status["status"], img.ID)
+	}
+	if len(img.Config.Cmd) != 1 || img.Config.Cmd[0] != "/bin/app" {
+		t.Errorf("Cmd = %v", img.Config.Cmd)
+	}
+	if len(img.Config.Env) != 1 || img.Config.Env[0] != "MODE=prod" {
+		t.Errorf("Env = %v", img.Config.Env)
+	}
+	if _, ok := img.Config.ExposedPorts["8080/tcp"]; !ok {
+		t.Errorf("ExposedPorts = %v", img.Config.ExposedPorts)
+	}
+	if img.Config.WorkingDir != "/srv" {
+		t.Errorf("WorkingDir = %q", img.Config.WorkingDir)
+	}
+}
+
+func TestImageImport_RejectsUnknownChange(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+
+	_, err := s.ImageImport(bytes.NewReader(loadTestTar(t, [2]string{"a", "b"})), ImageImportOptions{Changes: []string{"RUN make"}})
+	if _, ok := err.(*api.InvalidParameterError); !ok {
+		t.Fatalf("err = %v, want InvalidParameterError", err)
+	}
+}
+
+// loadTestRegistry accepts OCI pushes and records the manifest tags
+// written.
+func loadTestRegistry(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var manifests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/blobs/uploads/"):
+			w.Header().Set("Location", r.URL.Path+"upload-1")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/blobs/uploads/"):
+			body, _ := io.ReadAll(r.Body)
+			if sha256Digest(body) != r.URL.Query().Get("digest") {
+				http.Error(w, "digest mismatch", http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/"):
+			mu.Lock()
+			manifests = append(manifests, strings.TrimPrefix(r.URL.Path, "/v2/"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), manifests...)
+	}
+}
+
+func TestImageManagerLoad_PushesToLoadRepository(t *testing.T) {
+	srv, pushed := loadTestRegistry(t)
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+	m := &ImageManager{
+		Base:           s,
+		Auth:           &saveTestAuth{registry: "registry.example.com", endpoint: srv.URL},
+		Logger:         zerolog.Nop(),
+		LoadRepository: "registry.example.com/loaded",
+	}
+	s.Typed.Registry = WrapLegacyRegistry(nil, m.Push, "test", "image-manager")
+
+	rc, err := m.Load(bytes.NewReader(dockerArchiveForTest(t, "app:v1", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(rc)
+
+	if got := pushed(); len(got) != 1 || got[0] != "loaded/app/manifests/v1" {
+		t.Fatalf("pushed manifests = %v", got)
+	}
+	if got := m.RegistryRef("app:v1"); got != "registry.example.com/loaded/app:v1" {
+		t.Errorf("RegistryRef = %q", got)
+	}
+}
+
+func TestImageManagerLoad_RequiresRepository(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+	m := &ImageManager{Base: s, Auth: &saveTestAuth{registry: "registry.example.com"}, Logger: zerolog.Nop()}
+
+	_, err := m.Load(bytes.NewReader(dockerArchiveForTest(t, "app:v1", "")))
+	if _, ok := err.(*api.NotImplementedError); !ok {
+		t.Fatalf("err = %v, want NotImplementedError", err)
+	}
+}
@@ -136,11 +136,29 @@ type ImageManager struct {
 	Base         *BaseServer       // base implementation for in-memory operations
 	Auth         AuthProvider      // cloud-specific auth and sync (nil = no cloud integration)
 	BuildService CloudBuildService // cloud build delegation (nil = local Dockerfile parse only)
-	Logger       zerolog.Logger
+	// LoadRepository is the registry repository prefix that
+	// `docker load` / `docker import` push images into when their tag
+	// is not already a cloud-registry reference, e.g.
+	// "123456789012.dkr.ecr.us-east-1.amazonaws.com" or
+	// "us-central1-docker.pkg.dev/proj/repo". Empty means such images
+	// are rejected.
+	LoadRepository string
+	Logger         zerolog.Logger
 }
 
-// Pull pulls an image, using cloud auth if available.
+// Pull pulls an image, using cloud auth if available. When the image
+// is run through a registry mirror, the mirror is created first if it
+// is missing and the progress stream opens with a hit/miss line. The
+// pull itself reads the upstream registry, so a mirror that can't be
+// created doesn't fail it: the failure is logged and reported on the
+// progress stream, and the next pull or create tries again.
 func (m *ImageManager) Pull(ref string, auth string) (io.ReadCloser, error) {
+	mirrorStatus, err := m.Base.EnsureRegistryMirror(context.Background(), ref)
+	if err != nil {
+		m.Logger.Warn().Err(err).Str("ref", ref).Msg("registry mirror unavailable, pulling without it")
+		mirrorStatus = fmt.Sprintf("Mirror unavailable: %v", err)
+	}
+
 	cloudAuthToken := ""
 	if m.Auth != nil {
 		registry, _, _ := splitImageRefRegistry(ref)
@@ -194,7 +212,7 @@ func (m *ImageManager) Pull(ref string, auth string) (io.ReadCloser, error) {
 		}
 	}
 
-	return result, nil
+	return WithPullStatus(mirrorStatus, result), nil
 }
 
 // mergeImageConfig merges fetched config fields into the stored config.
@@ -266,7 +284,12 @@ func (m *ImageManager) Push(name string, tag string, auth string) (io.ReadCloser
 		}
 	}
 
-	return m.Base.ImagePush(name, tag, auth)
+	endpoint := ""
+	if endpointProvider, ok := m.Auth.(RegistryEndpointProvider); ok {
+		registry, _, _ := splitImageRefRegistry(name)
+		endpoint = endpointProvider.RegistryEndpoint(registry)
+	}
+	return m.Base.pushImage(name, tag, auth, endpoint)
 }
 
 // Tag tags an image and syncs the new tag to the cloud registry.
@@ -442,9 +465,51 @@ func (m *ImageManager) Inspect(name string) (*api.Image, error) {
 	return m.Base.ImageInspect(name)
 }
 
-// Load delegates to BaseServer.
+// Load loads a docker-archive or OCI-layout tarball and pushes every
+// image in it into the cloud registry, so ContainerCreate can run it.
 func (m *ImageManager) Load(r io.Reader) (io.ReadCloser, error) {
-	return m.Base.ImageLoad(r)
+	images, err := m.Base.loadImageArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	if images, err = m.publishLoadedImages(images); err != nil {
+		return nil, err
+	}
+	return loadedImagesStream(images), nil
+}
+
+// Import builds an image from a rootfs tarball and pushes it into the
+// cloud registry, so ContainerCreate can run it.
+func (m *ImageManager) Import(r io.Reader, opts ImageImportOptions) (io.ReadCloser, error) {
+	img, err := m.Base.importImage(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	images, err := m.publishLoadedImages([]api.Image{img})
+	if err != nil {
+		return nil, err
+	}
+	return importedImageStream(images[0]), nil
+}
+
+// Checkpoint commits a container for migration and pushes the
+// committed image into the cloud registry; the checkpoint names the
+// registry reference, which the target reads back with `docker save`.
+func (m *ImageManager) Checkpoint(ref string) (*ContainerCheckpoint, error) {
+	cp, err := m.Base.ContainerCheckpoint(ref)
+	if err != nil {
+		return nil, err
+	}
+	img, ok := m.Base.Store.ResolveImage(cp.Image)
+	if !ok {
+		return nil, &api.NotFoundError{Resource: "image", ID: cp.Image}
+	}
+	if _, err := m.publishLoadedImages([]api.Image{img}); err != nil {
+		return nil, err
+	}
+	cp.Image = m.RegistryRef(cp.Image)
+	cp.Config.Image = cp.Image
+	return cp, nil
 }
 
 // List delegates to BaseServer.
@@ -462,9 +527,55 @@ func (m *ImageManager) Prune(filters map[string][]string) (*api.ImagePruneRespon
 	return m.Base.ImagePrune(filters)
 }
 
-// Save delegates to BaseServer.
+// Save streams a docker-archive of names, reading cloud-registry
+// images with the provider's credentials and endpoint.
 func (m *ImageManager) Save(names []string) (io.ReadCloser, error) {
-	return m.Base.ImageSave(names)
+	return m.Base.saveImageArchive(names, m.registryAccess)
+}
+
+// WalkImageLayers walks the layers of ref for the offline scanner,
+// reading cloud-registry images with the provider's credentials.
+func (m *ImageManager) WalkImageLayers(ctx context.Context, ref string, fn func(io.Reader) error) error {
+	return m.Base.walkImageLayers(ctx, ref, m.registryAccess, fn)
+}
+
+// ImageDigest returns the digest of ref's manifest for the backend's
+// platform, as recorded by the registry. Cloud scanners key their
+// findings by it.
+func (m *ImageManager) ImageDigest(ref string) (string, error) {
+	rc := parseImageRef(ref)
+	basicAuth, endpoint, err := m.registryAccess(ref)
+	if err != nil {
+		return "", err
+	}
+	rc.Endpoint = endpoint
+	token, err := getRegistryToken(rc, basicAuth)
+	if err != nil {
+		return "", fmt.Errorf("registry auth for %s: %w", rc.Registry, err)
+	}
+	minfo, err := getManifestInfoForPlatform(rc, token, "linux", m.Base.Desc.Architecture)
+	if err != nil {
+		return "", fmt.Errorf("manifest %s: %w", ref, err)
+	}
+	return minfo.manifestDigest, nil
+}
+
+// registryAccess returns the provider's credential and endpoint for
+// the registry serving ref; other registries are read anonymously.
+func (m *ImageManager) registryAccess(ref string) (string, string, error) {
+	registry, _, _ := splitImageRefRegistry(ref)
+	endpoint := ""
+	if endpointProvider, ok := m.Auth.(RegistryEndpointProvider); ok {
+		endpoint = endpointProvider.RegistryEndpoint(registry)
+	}
+	if m.Auth == nil || !m.Auth.IsCloudRegistry(registry) {
+		return "", endpoint, nil
+	}
+	token, err := m.Auth.GetToken(registry)
+	if err != nil {
+		return "", "", fmt.Errorf("registry auth for %s: %w", registry, err)
+	}
+	return ecrBasicCredential(token), endpoint, nil
 }
 
 // Search delegates to BaseServer.
@@ -0,0 +1,279 @@
+package core
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// registryBlobClient fetches layer blobs for `docker save`. Layers
+// can be gigabytes, so unlike registryClient there is no whole-request
+// timeout — only the wait for response headers is bounded.
+var registryBlobClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// registryAccessFunc returns the basic-auth credential and network
+// endpoint override for the registry serving ref. Empty values mean
+// anonymous access and the registry hostname itself.
+type registryAccessFunc func(ref string) (basicAuth, endpoint string, err error)
+
+// savedImage is one image resolved for `docker save`: the registry
+// manifest for the backend's platform plus its config blob. Layers are
+// streamed from the registry when the archive is written.
+type savedImage struct {
+	repoTags []string
+	rc       registryConfig
+	token    string
+	manifest *manifestInfo
+	config   []byte
+}
+
+// dockerArchiveManifest is one entry of a docker-archive manifest.json.
+type dockerArchiveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// ImageSave streams a docker-archive tar of the named images, read
+// from the registries they were pulled from with anonymous access.
+// ImageManager.Save adds the backend's cloud registry credentials.
+func (s *BaseServer) ImageSave(names []string) (io.ReadCloser, error) {
+	return s.saveImageArchive(names, nil)
+}
+
+// saveImageArchive resolves every name before returning so a missing
+// image or registry failure surfaces as an HTTP error rather than a
+// truncated tar; layer blobs are then streamed into the archive one at
+// a time.
+func (s *BaseServer) saveImageArchive(names []string, access registryAccessFunc) (io.ReadCloser, error) {
+	if len(names) == 0 {
+		return nil, &api.InvalidParameterError{Message: "docker image save requires at least one image name"}
+	}
+	if s.Desc.Architecture == "" {
+		return nil, &api.ServerError{Message: "docker image save: backend architecture is not set"}
+	}
+	images := make([]*savedImage, 0, len(names))
+	for _, name := range names {
+		img, err := s.resolveSavedImage(name, access)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeDockerArchive(pw, images))
+	}()
+	return pr, nil
+}
+
+// resolveSavedImage maps name to a local image and fetches its
+// manifest and config from the source registry. The registry lookup
+// is pinned to the manifest digest recorded at pull time when there is
+// one, and the config digest must match the local image ID — a tag
+// that has moved since the pull is an error, not a silent swap.
+func (s *BaseServer) resolveSavedImage(name string, access registryAccessFunc) (*savedImage, error) {
+	img, ok := s.Store.ResolveImage(name)
+	if !ok {
+		return nil, &api.NotFoundError{Resource: "image", ID: name}
+	}
+
+	// Saving by tag records that tag; saving by ID records none,
+	// matching docker.
+	var repoTags []string
+	for _, t := range img.RepoTags {
+		if t == name || t == name+":latest" {
+			repoTags = []string{t}
+			break
+		}
+	}
+	source := ""
+	switch {
+	case len(repoTags) > 0:
+		source = repoTags[0]
+	case len(img.RepoTags) > 0:
+		source = img.RepoTags[0]
+	default:
+		return nil, &api.ConflictError{Message: fmt.Sprintf("image %s has no registry reference to save from", name)}
+	}
+
+	rc := parseImageRef(source)
+	basicAuth := ""
+	if access != nil {
+		var err error
+		if basicAuth, rc.Endpoint, err = access(source); err != nil {
+			return nil, fmt.Errorf("save %s: %w", name, err)
+		}
+	}
+	for _, rd := range img.RepoDigests {
+		at := strings.LastIndex(rd, "@")
+		if at < 0 {
+			continue
+		}
+		drc := parseImageRef(rd[:at])
+		if drc.Registry == rc.Registry && drc.Repository == rc.Repository {
+			rc.Tag = rd[at+1:]
+			break
+		}
+	}
+
+	token, err := getRegistryToken(rc, basicAuth)
+	if err != nil {
+		return nil, fmt.Errorf("save %s: registry auth: %w", name, err)
+	}
+	minfo, err := getManifestInfoForPlatform(rc, token, "linux", s.Desc.Architecture)
+	if err != nil {
+		return nil, fmt.Errorf("save %s: manifest: %w", name, err)
+	}
+	if minfo.configDigest != img.ID {
+		return nil, &api.ConflictError{Message: fmt.Sprintf("save %s: %s/%s@%s resolves to config %s, not the local image %s — re-pull the image", name, rc.Registry, rc.Repository, rc.Tag, minfo.configDigest, img.ID)}
+	}
+	config, _, err := registryGet(registryURL(rc, "blobs", minfo.configDigest), token, nil)
+	if err != nil {
+		return nil, fmt.Errorf("save %s: config: %w", name, err)
+	}
+	if got := fmt.Sprintf("sha256:%x", sha256.Sum256(config)); got != minfo.configDigest {
+		return nil, fmt.Errorf("save %s: config digest mismatch: got %s, want %s", name, got, minfo.configDigest)
+	}
+	return &savedImage{repoTags: repoTags, rc: rc, token: token, manifest: minfo, config: config}, nil
+}
+
+// writeDockerArchive writes images as a docker-archive: config and
+// layer blobs under blobs/sha256/ (each written once even when images
+// share it), then manifest.json and the legacy repositories file.
+// Layers stay compressed as stored in the registry; docker load
+// decompresses them and checks the config's diff_ids.
+func writeDockerArchive(w io.Writer, images []*savedImage) error {
+	tw := tar.NewWriter(w)
+	for _, dir := range []string{"blobs/", "blobs/sha256/"} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir, Mode: 0o755, ModTime: time.Unix(0, 0)}); err != nil {
+			return err
+		}
+	}
+
+	written := make(map[string]bool)
+	manifest := make([]dockerArchiveManifest, 0, len(images))
+	repositories := make(map[string]map[string]string)
+	for _, img := range images {
+		cfgPath := archiveBlobPath(img.manifest.configDigest)
+		if !written[cfgPath] {
+			if err := writeTarFile(tw, cfgPath, img.config); err != nil {
+				return err
+			}
+			written[cfgPath] = true
+		}
+
+		layers := make([]string, len(img.manifest.layerDigests))
+		for i, digest := range img.manifest.layerDigests {
+			layers[i] = archiveBlobPath(digest)
+			if written[layers[i]] {
+				continue
+			}
+			if err := copyLayerBlob(tw, img.rc, img.token, digest, img.manifest.layerSizes[i], layers[i]); err != nil {
+				return err
+			}
+			written[layers[i]] = true
+		}
+
+		manifest = append(manifest, dockerArchiveManifest{Config: cfgPath, RepoTags: img.repoTags, Layers: layers})
+		if len(layers) == 0 {
+			continue
+		}
+		top := strings.TrimPrefix(img.manifest.layerDigests[len(layers)-1], "sha256:")
+		for _, t := range img.repoTags {
+			repo, tag := splitArchiveRepoTag(t)
+			if repositories[repo] == nil {
+				repositories[repo] = make(map[string]string)
+			}
+			repositories[repo][tag] = top
+		}
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "manifest.json", manifestJSON); err != nil {
+		return err
+	}
+	repositoriesJSON, err := json.Marshal(repositories)
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "repositories", repositoriesJSON); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// copyLayerBlob streams one layer blob from the registry into the
+// archive, verifying its size against the manifest and its digest
+// against the content.
+func copyLayerBlob(tw *tar.Writer, rc registryConfig, token, digest string, size int64, name string) error {
+	req, err := http.NewRequest(http.MethodGet, registryURL(rc, "blobs", digest), nil)
+	if err != nil {
+		return fmt.Errorf("create blob request: %w", err)
+	}
+	setRegistryAuth(req, token)
+	resp, err := registryBlobClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch blob %s: %w", digest, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("fetch blob %s: HTTP %d: %s", digest, resp.StatusCode, string(body))
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: size, ModTime: time.Unix(0, 0)}); err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tw, h), io.LimitReader(resp.Body, size+1))
+	if err != nil {
+		return fmt.Errorf("stream blob %s: %w", digest, err)
+	}
+	if n != size {
+		return fmt.Errorf("blob %s: got %d bytes, manifest says %d", digest, n, size)
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != digest {
+		return fmt.Errorf("blob digest mismatch for %s: got %s", digest, got)
+	}
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Unix(0, 0)}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func archiveBlobPath(digest string) string {
+	return "blobs/sha256/" + strings.TrimPrefix(digest, "sha256:")
+}
+
+// splitArchiveRepoTag splits repo:tag on the last colon after the last
+// slash so registry ports stay part of the repository.
+func splitArchiveRepoTag(ref string) (repo, tag string) {
+	slash := strings.LastIndex(ref, "/")
+	if colon := strings.LastIndex(ref[slash+1:], ":"); colon >= 0 {
+		return ref[:slash+1+colon], ref[slash+2+colon:]
+	}
+	return ref, "latest"
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/api"
+)
+
+// saveTestAuth is a cloud AuthProvider that routes one registry
+// hostname to a local mock registry.
+type saveTestAuth struct {
+	registry string
+	endpoint string
+}
+
+func (a *saveTestAuth) GetToken(string) (string, error)             { return "", nil }
+func (a *saveTestAuth) IsCloudRegistry(registry string) bool        { return registry == a.registry }
+func (a *saveTestAuth) OnPush(string, string, string, string) error { return nil }
+func (a *saveTestAuth) OnTag(string, string, string, string) error  { return nil }
+func (a *saveTestAuth) OnRemove(string, string, []string) error     { return nil }
+func (a *saveTestAuth) RegistryEndpoint(registry string) string     { return a.endpoint }
+
+func sha256Digest(b []byte) string { return fmt.Sprintf("sha256:%x", sha256.Sum256(b)) }
+
+func saveTestBlobHandler(body []byte) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(body) }
+}
+
+// saveTestRegistry serves a two-platform index for app:v1 whose arm64
+// entry has two layers, and returns the arm64 config digest.
+func saveTestRegistry(t *testing.T) (*httptest.Server, string, [][]byte) {
+	t.Helper()
+	layers := [][]byte{[]byte("layer-one-gzip"), []byte("layer-two-gzip")}
+	config, _ := json.Marshal(map[string]any{"architecture": "arm64", "os": "linux"})
+	manifest, _ := json.Marshal(map[string]any{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"config":    map[string]any{"digest": sha256Digest(config), "size": len(config)},
+		"layers": []map[string]any{
+			{"digest": sha256Digest(layers[0]), "size": len(layers[0])},
+			{"digest": sha256Digest(layers[1]), "size": len(layers[1])},
+		},
+	})
+	index, _ := json.Marshal(map[string]any{
+		"mediaType": "application/vnd.oci.image.index.v1+json",
+		"manifests": []map[string]any{
+			{"digest": "sha256:" + strings.Repeat("0", 64), "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+			{"digest": sha256Digest(manifest), "platform": map[string]string{"os": "linux", "architecture": "arm64"}},
+		},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/team/app/manifests/v1", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+		_, _ = w.Write(index)
+	})
+	mux.HandleFunc("/v2/team/app/manifests/"+sha256Digest(manifest), func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		_, _ = w.Write(manifest)
+	})
+	mux.HandleFunc("/v2/team/app/blobs/"+sha256Digest(config), saveTestBlobHandler(config))
+	for _, l := range layers {
+		mux.HandleFunc("/v2/team/app/blobs/"+sha256Digest(l), saveTestBlobHandler(l))
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, sha256Digest(config), layers
+}
+
+func TestImageSave_StreamsDockerArchiveForBackendPlatform(t *testing.T) {
+	srv, configDigest, layers := saveTestRegistry(t)
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "arm64"
+	ref := "registry.example.com/team/app:v1"
+	img := api.Image{ID: configDigest, RepoTags: []string{ref}}
+	s.Store.Images.Put(img.ID, img)
+	s.Store.Images.Put(ref, img)
+	m := &ImageManager{Base: s, Auth: &saveTestAuth{registry: "registry.example.com", endpoint: srv.URL}}
+
+	rc, err := m.Save([]string{ref})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(tr)
+		files[hdr.Name] = body
+	}
+
+	var manifest []dockerArchiveManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if len(manifest) != 1 || len(manifest[0].RepoTags) != 1 || manifest[0].RepoTags[0] != ref {
+		t.Fatalf("manifest.json = %+v", manifest)
+	}
+	if got := sha256Digest(files[manifest[0].Config]); got != configDigest {
+		t.Errorf("config blob digest = %s, want %s", got, configDigest)
+	}
+	if len(manifest[0].Layers) != 2 {
+		t.Fatalf("layers = %v", manifest[0].Layers)
+	}
+	for i, p := range manifest[0].Layers {
+		if string(files[p]) != string(layers[i]) {
+			t.Errorf("layer %d (%s) = %q", i, p, files[p])
+		}
+	}
+	var repositories map[string]map[string]string
+	if err := json.Unmarshal(files["repositories"], &repositories); err != nil {
+		t.Fatalf("repositories: %v", err)
+	}
+	if repositories["registry.example.com/team/app"]["v1"] == "" {
+		t.Errorf("repositories = %v", repositories)
+	}
+}
+
+func TestImageSave_RejectsMovedTag(t *testing.T) {
+	srv, _, _ := saveTestRegistry(t)
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "arm64"
+	ref := "registry.example.com/team/app:v1"
+	img := api.Image{ID: "sha256:" + strings.Repeat("a", 64), RepoTags: []string{ref}}
+	s.Store.Images.Put(ref, img)
+	m := &ImageManager{Base: s, Auth: &saveTestAuth{registry: "registry.example.com", endpoint: srv.URL}}
+
+	_, err := m.Save([]string{ref})
+	if _, ok := err.(*api.ConflictError); !ok {
+		t.Fatalf("err = %v, want ConflictError", err)
+	}
+}
+
+func TestImageSave_UnknownImage(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Desc.Architecture = "amd64"
+	_, err := s.ImageSave([]string{"missing:latest"})
+	if _, ok := err.(*api.NotFoundError); !ok {
+		t.Fatalf("err = %v, want NotFoundError", err)
+	}
+}
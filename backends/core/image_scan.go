@@ -0,0 +1,472 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// ImageScanner reports the known vulnerabilities of an image. Cloud
+// backends use their registry's native scanner (ECR image scanning,
+// Artifact Analysis, Defender for Containers); OfflineScanner matches
+// the image's installed packages against a local feed file.
+type ImageScanner interface {
+	Scan(ctx context.Context, imageRef string) (*ScanResult, error)
+	// Available reports whether the scanner can run a scan now.
+	Available() bool
+}
+
+// feedVersioner is implemented by scanners whose vulnerability data can
+// change under a cached result. A result whose FeedVersion differs from
+// the current one is rescanned.
+type feedVersioner interface {
+	FeedVersion() string
+}
+
+// Scan statuses.
+const (
+	ScanStatusComplete   = "COMPLETE"
+	ScanStatusInProgress = "IN_PROGRESS"
+	ScanStatusFailed     = "FAILED"
+)
+
+// Severities, highest first. Provider-specific spellings are mapped
+// onto these by NormalizeSeverity; anything unrecognised is UNKNOWN,
+// which ranks below INFORMATIONAL and never blocks admission.
+const (
+	SeverityCritical      = "CRITICAL"
+	SeverityHigh          = "HIGH"
+	SeverityMedium        = "MEDIUM"
+	SeverityLow           = "LOW"
+	SeverityInformational = "INFORMATIONAL"
+	SeverityUnknown       = "UNKNOWN"
+)
+
+// ScanResult is the outcome of one image scan.
+type ScanResult struct {
+	ImageRef        string          `json:"ImageRef"`
+	Digest          string          `json:"Digest,omitempty"`
+	Scanner         string          `json:"Scanner"`
+	ScanStatus      string          `json:"ScanStatus"`
+	Message         string          `json:"Message,omitempty"`     // why a scan FAILED
+	FeedVersion     string          `json:"FeedVersion,omitempty"` // vulnerability data the scan matched against
+	Vulnerabilities []Vulnerability `json:"Vulnerabilities"`
+	Summary         map[string]int  `json:"Summary"` // severity → finding count
+	ScanTime        time.Time       `json:"ScanTime"`
+
+	cachedAt time.Time // when ScanImage stored the result
+}
+
+// Vulnerability is one finding against an installed package.
+type Vulnerability struct {
+	ID          string `json:"ID"`
+	Package     string `json:"Package"`
+	Version     string `json:"Version"`
+	Severity    string `json:"Severity"`
+	FixVersion  string `json:"FixVersion,omitempty"`
+	Description string `json:"Description,omitempty"`
+	URI         string `json:"URI,omitempty"`
+}
+
+// NormalizeSeverity maps a provider severity onto the Severity*
+// constants.
+func NormalizeSeverity(s string) string {
+	switch u := strings.ToUpper(strings.TrimSpace(s)); u {
+	case SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInformational:
+		return u
+	case "MODERATE":
+		return SeverityMedium
+	case "INFO", "NEGLIGIBLE", "MINIMAL":
+		return SeverityInformational
+	default:
+		return SeverityUnknown
+	}
+}
+
+func severityRank(s string) int {
+	switch s {
+	case SeverityCritical:
+		return 5
+	case SeverityHigh:
+		return 4
+	case SeverityMedium:
+		return 3
+	case SeverityLow:
+		return 2
+	case SeverityInformational:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// finish normalises severities, sorts findings most severe first and
+// fills in the summary.
+func (r *ScanResult) finish() {
+	r.Summary = map[string]int{}
+	for i := range r.Vulnerabilities {
+		v := &r.Vulnerabilities[i]
+		v.Severity = NormalizeSeverity(v.Severity)
+		r.Summary[v.Severity]++
+	}
+	sort.SliceStable(r.Vulnerabilities, func(i, j int) bool {
+		a, b := r.Vulnerabilities[i], r.Vulnerabilities[j]
+		if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		return a.ID < b.ID
+	})
+	if r.Vulnerabilities == nil {
+		r.Vulnerabilities = []Vulnerability{}
+	}
+}
+
+// ImageScanTimeout bounds how long a cloud scanner waits for a scan it
+// started; a scan still running after it is reported IN_PROGRESS.
+const ImageScanTimeout = 2 * time.Minute
+
+// DefaultScanMaxAge is how long a complete scan result is reused when
+// SOCKERLESS_SCAN_MAX_AGE is unset. Vulnerabilities published after a
+// scan only surface once the image is scanned again.
+const DefaultScanMaxAge = 24 * time.Hour
+
+// Operations the admission policy can gate.
+const (
+	ScanEnforceCreate = "create"
+	ScanEnforcePull   = "pull"
+)
+
+// ScanPolicy blocks images with findings at or above BlockSeverity.
+// The zero value admits everything.
+type ScanPolicy struct {
+	BlockSeverity string
+	OnCreate      bool
+	OnPull        bool
+	// MaxAge bounds how long a complete result is reused before the
+	// image is scanned again; zero means DefaultScanMaxAge.
+	MaxAge time.Duration
+}
+
+func (p ScanPolicy) maxAge() time.Duration {
+	if p.MaxAge > 0 {
+		return p.MaxAge
+	}
+	return DefaultScanMaxAge
+}
+
+func (p ScanPolicy) enforces(op string) bool {
+	if p.BlockSeverity == "" {
+		return false
+	}
+	switch op {
+	case ScanEnforceCreate:
+		return p.OnCreate
+	case ScanEnforcePull:
+		return p.OnPull
+	}
+	return false
+}
+
+// ImageScanConfig selects the scanner and admission policy of a
+// backend.
+type ImageScanConfig struct {
+	// Scanner is "cloud" (the backend registry's native scanner),
+	// "offline" (OfflineScanner over FeedPath) or "" for none.
+	Scanner  string
+	FeedPath string
+	// BlockSeverity turns on admission: images with a finding at or
+	// above it are refused for the operations in Enforce.
+	BlockSeverity string
+	Enforce       []string
+	// MaxAge is how long a complete scan result is reused.
+	MaxAge time.Duration
+}
+
+// ImageScanConfigFromEnv reads SOCKERLESS_IMAGE_SCANNER,
+// SOCKERLESS_VULN_FEED, SOCKERLESS_SCAN_BLOCK_SEVERITY,
+// SOCKERLESS_SCAN_ENFORCE (comma-separated; defaults to "create" when a
+// block severity is set) and SOCKERLESS_SCAN_MAX_AGE (a Go duration,
+// default 24h).
+func ImageScanConfigFromEnv() ImageScanConfig {
+	c := ImageScanConfig{
+		Scanner:       strings.TrimSpace(os.Getenv("SOCKERLESS_IMAGE_SCANNER")),
+		FeedPath:      strings.TrimSpace(os.Getenv("SOCKERLESS_VULN_FEED")),
+		BlockSeverity: strings.ToUpper(strings.TrimSpace(os.Getenv("SOCKERLESS_SCAN_BLOCK_SEVERITY"))),
+		MaxAge:        DefaultScanMaxAge,
+	}
+	if v := strings.TrimSpace(os.Getenv("SOCKERLESS_SCAN_MAX_AGE")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			d = -1 // rejected by Validate
+		}
+		c.MaxAge = d
+	}
+	for _, op := range strings.Split(os.Getenv("SOCKERLESS_SCAN_ENFORCE"), ",") {
+		if op = strings.TrimSpace(op); op != "" {
+			c.Enforce = append(c.Enforce, op)
+		}
+	}
+	if c.BlockSeverity != "" && len(c.Enforce) == 0 {
+		c.Enforce = []string{ScanEnforceCreate}
+	}
+	return c
+}
+
+// Validate rejects unknown scanners, severities and operations, and a
+// policy without a scanner to enforce it.
+func (c ImageScanConfig) Validate() error {
+	if c.MaxAge < 0 {
+		return fmt.Errorf("SOCKERLESS_SCAN_MAX_AGE must be a positive duration")
+	}
+	switch c.Scanner {
+	case "", "cloud":
+	case "offline":
+		if c.FeedPath == "" {
+			return fmt.Errorf("SOCKERLESS_IMAGE_SCANNER=offline requires SOCKERLESS_VULN_FEED")
+		}
+		if _, err := loadVulnFeed(c.FeedPath); err != nil {
+			return fmt.Errorf("SOCKERLESS_VULN_FEED: %w", err)
+		}
+	default:
+		return fmt.Errorf("SOCKERLESS_IMAGE_SCANNER=%q not supported (one of cloud, offline)", c.Scanner)
+	}
+	if c.BlockSeverity == "" {
+		if len(c.Enforce) > 0 {
+			return fmt.Errorf("SOCKERLESS_SCAN_ENFORCE requires SOCKERLESS_SCAN_BLOCK_SEVERITY")
+		}
+		return nil
+	}
+	if severityRank(c.BlockSeverity) == 0 {
+		return fmt.Errorf("SOCKERLESS_SCAN_BLOCK_SEVERITY=%q not supported (one of CRITICAL, HIGH, MEDIUM, LOW, INFORMATIONAL)", c.BlockSeverity)
+	}
+	if c.Scanner == "" {
+		return fmt.Errorf("SOCKERLESS_SCAN_BLOCK_SEVERITY requires SOCKERLESS_IMAGE_SCANNER")
+	}
+	for _, op := range c.Enforce {
+		if op != ScanEnforceCreate && op != ScanEnforcePull {
+			return fmt.Errorf("SOCKERLESS_SCAN_ENFORCE: %q not supported (create, pull)", op)
+		}
+	}
+	return nil
+}
+
+// ConfigureImageScanning installs the scanner and policy selected by c.
+// cloud is the backend's registry-native scanner; layers feeds the
+// offline scanner and should carry the backend's registry credentials.
+func (s *BaseServer) ConfigureImageScanning(c ImageScanConfig, cloud ImageScanner, layers ImageLayerWalker) {
+	switch c.Scanner {
+	case "cloud":
+		s.Scanner = cloud
+	case "offline":
+		s.Scanner = &OfflineScanner{FeedPath: c.FeedPath, Layers: layers}
+	}
+	s.ScanPolicy = ScanPolicy{BlockSeverity: c.BlockSeverity, MaxAge: c.MaxAge}
+	for _, op := range c.Enforce {
+		switch op {
+		case ScanEnforceCreate:
+			s.ScanPolicy.OnCreate = true
+		case ScanEnforcePull:
+			s.ScanPolicy.OnPull = true
+		}
+	}
+}
+
+// scanCacheKey keys Store.ImageScans by image ID so every tag of an
+// image shares one result; refs not present locally key by name.
+func (s *BaseServer) scanCacheKey(ref string) string {
+	if img, ok := s.Store.ResolveImage(ref); ok {
+		return img.ID
+	}
+	return ref
+}
+
+// ScanImage scans ref with the configured scanner. A cached complete
+// result is returned unless refresh is set, it is older than the
+// policy's MaxAge, or the scanner's feed has changed since.
+func (s *BaseServer) ScanImage(ctx context.Context, ref string, refresh bool) (*ScanResult, error) {
+	if s.Scanner == nil {
+		return nil, &api.NotImplementedError{Message: "image scanning is not configured on this backend (set SOCKERLESS_IMAGE_SCANNER)"}
+	}
+	if !s.Scanner.Available() {
+		return nil, &api.ServerError{Message: "image scanner is not available"}
+	}
+	key := s.scanCacheKey(ref)
+	if !refresh {
+		if v, ok := s.Store.ImageScans.Load(key); ok && s.scanFresh(v.(*ScanResult)) {
+			return v.(*ScanResult), nil
+		}
+	}
+	res, err := s.Scanner.Scan(ctx, ref)
+	if err != nil {
+		if _, ok := err.(api.StatusCoder); ok {
+			return nil, err
+		}
+		return nil, &api.ServerError{Message: fmt.Sprintf("scan %s: %v", ref, err)}
+	}
+	res.finish()
+	if fv, ok := s.Scanner.(feedVersioner); ok && res.FeedVersion == "" {
+		res.FeedVersion = fv.FeedVersion()
+	}
+	res.cachedAt = time.Now()
+	s.Store.ImageScans.Store(key, res)
+	return res, nil
+}
+
+// scanFresh reports whether a cached result can stand in for a scan.
+func (s *BaseServer) scanFresh(res *ScanResult) bool {
+	if res.ScanStatus != ScanStatusComplete || time.Since(res.cachedAt) > s.ScanPolicy.maxAge() {
+		return false
+	}
+	if fv, ok := s.Scanner.(feedVersioner); ok && fv.FeedVersion() != res.FeedVersion {
+		return false
+	}
+	return true
+}
+
+// admitImage applies the signature trust policy (create only) and the
+// vulnerability admission policy to op on ref, and returns the
+// reference to run: pinned to the verified digest when a trust policy
+// is set, else ref. Admission fails closed: a scan that errors or has
+// not completed refuses the image like a blocking finding does.
+func (s *BaseServer) admitImage(ctx context.Context, ref, op string) (string, error) {
+	run := ref
+	if op == ScanEnforceCreate {
+		var err error
+		if run, err = s.admitSignedImage(ctx, ref); err != nil {
+			return "", err
+		}
+	}
+	if !s.ScanPolicy.enforces(op) {
+		return run, nil
+	}
+	// Scan what will run: under a trust policy that is the verified
+	// digest, not whatever the tag points at now.
+	res, err := s.ScanImage(ctx, run, false)
+	if err != nil {
+		return "", err
+	}
+	if res.ScanStatus != ScanStatusComplete {
+		msg := fmt.Sprintf("image %s refused: vulnerability scan is %s", ref, res.ScanStatus)
+		if res.Message != "" {
+			msg += ": " + res.Message
+		}
+		return "", &api.ForbiddenError{Message: msg}
+	}
+	threshold := severityRank(s.ScanPolicy.BlockSeverity)
+	var ids []string
+	for _, v := range res.Vulnerabilities {
+		if severityRank(v.Severity) >= threshold {
+			ids = append(ids, v.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return run, nil
+	}
+	shown := ids
+	if len(shown) > 5 {
+		shown = shown[:5]
+	}
+	return "", &api.ForbiddenError{Message: fmt.Sprintf("image %s refused by vulnerability policy: %d finding(s) at or above %s (%s)", ref, len(ids), s.ScanPolicy.BlockSeverity, strings.Join(shown, ", "))}
+}
+
+// admitPulledImage applies the pull policy to the image pulled by the
+// stream rc. The stream passes through as it arrives; once it ends the
+// image is admitted, or removed again with the refusal appended as a
+// final {"error": ...} line. A pull that failed inside the stream
+// passes through as is.
+func (s *BaseServer) admitPulledImage(ctx context.Context, ref string, rc io.Reader) *admittedPull {
+	return &admittedPull{s: s, ctx: ctx, ref: ref, r: rc}
+}
+
+// admittedPull is a pull stream followed by its admission verdict.
+type admittedPull struct {
+	s   *BaseServer
+	ctx context.Context
+	ref string
+	r   io.Reader
+
+	verdict *bytes.Reader // set once r is drained
+	err     error         // why the image was refused
+}
+
+func (p *admittedPull) Read(b []byte) (int, error) {
+	if p.verdict == nil {
+		n, err := p.r.Read(b)
+		if err != io.EOF {
+			return n, err
+		}
+		p.verdict = bytes.NewReader(p.admit())
+		if n > 0 {
+			return n, nil
+		}
+	}
+	return p.verdict.Read(b)
+}
+
+// Err returns the refusal once the stream has been read to the end.
+func (p *admittedPull) Err() error { return p.err }
+
+// admit runs the pull policy and returns the error line to append.
+func (p *admittedPull) admit() []byte {
+	s := p.s
+	if !s.ScanPolicy.enforces(ScanEnforcePull) {
+		return nil
+	}
+	if _, ok := s.Store.ResolveImage(p.ref); !ok {
+		return nil
+	}
+	if _, err := s.admitImage(p.ctx, p.ref, ScanEnforcePull); err != nil {
+		if _, rerr := s.self.ImageRemove(p.ref, true, false); rerr != nil {
+			s.Logger.Warn().Err(rerr).Str("image", p.ref).Msg("failed to remove image refused by vulnerability policy")
+		}
+		p.err = err
+		line, _ := json.Marshal(map[string]any{
+			"errorDetail": map[string]string{"message": err.Error()},
+			"error":       err.Error(),
+		})
+		return append(line, '\n')
+	}
+	return nil
+}
+
+// imageInspectWithScan is the image inspect body with the last scan
+// result attached.
+type imageInspectWithScan struct {
+	*api.Image
+	SockerlessScan *ScanResult `json:"SockerlessScan,omitempty"`
+}
+
+func (s *BaseServer) withImageScan(img *api.Image) any {
+	if img == nil {
+		return img
+	}
+	if v, ok := s.Store.ImageScans.Load(img.ID); ok {
+		return imageInspectWithScan{Image: img, SockerlessScan: v.(*ScanResult)}
+	}
+	return img
+}
+
+// handleImageScan serves GET (cached result, scanning on a miss) and
+// POST (forced rescan) /internal/v1/images/scan?name=.
+func (s *BaseServer) handleImageScan(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		WriteError(w, &api.InvalidParameterError{Message: "name is required"})
+		return
+	}
+	res, err := s.ScanImage(r.Context(), name, r.Method == http.MethodPost)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,659 @@
+package core
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// ImageLayerWalker calls fn with each layer of ref as an uncompressed
+// tar stream, base layer first.
+type ImageLayerWalker func(ctx context.Context, ref string, fn func(layer io.Reader) error) error
+
+// OfflineScanner matches the packages installed in an image (dpkg and
+// apk databases) against a local vulnerability feed. It needs no cloud
+// service, so it works on every backend and against the simulators.
+type OfflineScanner struct {
+	FeedPath string
+	Layers   ImageLayerWalker
+}
+
+// vulnFeed is the offline feed file:
+//
+//	{"vulnerabilities": [{"id": "CVE-2024-0001", "ecosystem": "debian",
+//	  "package": "openssl", "fixed": "3.0.11-1~deb12u2",
+//	  "severity": "HIGH", "description": "...", "uri": "..."}]}
+//
+// ecosystem is the distribution ID from the image's os-release
+// (debian, ubuntu, alpine, ...). An entry applies to the listed
+// affected versions when given, otherwise to every version below
+// fixed, otherwise to every version.
+type vulnFeed struct {
+	Vulnerabilities []vulnFeedEntry `json:"vulnerabilities"`
+}
+
+type vulnFeedEntry struct {
+	ID          string   `json:"id"`
+	Ecosystem   string   `json:"ecosystem"`
+	Package     string   `json:"package"`
+	Fixed       string   `json:"fixed,omitempty"`
+	Affected    []string `json:"affected,omitempty"`
+	Severity    string   `json:"severity"`
+	Description string   `json:"description,omitempty"`
+	URI         string   `json:"uri,omitempty"`
+}
+
+func loadVulnFeed(p string) (*vulnFeed, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var feed vulnFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", p, err)
+	}
+	for i, e := range feed.Vulnerabilities {
+		if e.ID == "" || e.Ecosystem == "" || e.Package == "" {
+			return nil, fmt.Errorf("parse %s: entry %d needs id, ecosystem and package", p, i)
+		}
+	}
+	return &feed, nil
+}
+
+// FeedVersion identifies the feed file's current contents by its size
+// and modification time, so results matched against an older feed are
+// rescanned.
+func (o *OfflineScanner) FeedVersion() string {
+	fi, err := os.Stat(o.FeedPath)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano())
+}
+
+// Available reports whether the feed file exists.
+func (o *OfflineScanner) Available() bool {
+	_, err := os.Stat(o.FeedPath)
+	return err == nil && o.Layers != nil
+}
+
+// Scan reads the image's package databases and matches them against
+// the feed, which is re-read on every scan so feed updates apply
+// without a restart.
+func (o *OfflineScanner) Scan(ctx context.Context, ref string) (*ScanResult, error) {
+	feed, err := loadVulnFeed(o.FeedPath)
+	if err != nil {
+		return nil, &api.ServerError{Message: fmt.Sprintf("vulnerability feed: %v", err)}
+	}
+	var inv packageInventory
+	if err := o.Layers(ctx, ref, inv.addLayer); err != nil {
+		return nil, err
+	}
+	pkgs := inv.packages()
+	res := &ScanResult{
+		ImageRef:   ref,
+		Scanner:    "offline",
+		ScanStatus: ScanStatusComplete,
+		ScanTime:   time.Now().UTC(),
+	}
+	if len(pkgs) == 0 {
+		res.Message = "no dpkg or apk package database in the image; nothing to match"
+	}
+	for _, p := range pkgs {
+		for _, e := range feed.Vulnerabilities {
+			if e.Ecosystem != p.ecosystem || (e.Package != p.name && e.Package != p.source) || !e.affects(p.version, p.compareVersions) {
+				continue
+			}
+			res.Vulnerabilities = append(res.Vulnerabilities, Vulnerability{
+				ID:          e.ID,
+				Package:     p.name,
+				Version:     p.version,
+				Severity:    e.Severity,
+				FixVersion:  e.Fixed,
+				Description: e.Description,
+				URI:         e.URI,
+			})
+		}
+	}
+	return res, nil
+}
+
+func (e vulnFeedEntry) affects(version string, compare func(a, b string) int) bool {
+	if len(e.Affected) > 0 {
+		for _, v := range e.Affected {
+			if v == version {
+				return true
+			}
+		}
+		return false
+	}
+	if e.Fixed != "" {
+		return compare(version, e.Fixed) < 0
+	}
+	return true
+}
+
+// Files the inventory reads out of the image.
+const (
+	dpkgStatusPath    = "var/lib/dpkg/status"
+	dpkgStatusDirPath = "var/lib/dpkg/status.d"
+	apkInstalledPath  = "lib/apk/db/installed"
+)
+
+var osReleasePaths = []string{"etc/os-release", "usr/lib/os-release"}
+
+// packageInventory collects the package database files across layers.
+// Later layers replace earlier copies and whiteouts remove them, so the
+// result is what the container's merged filesystem holds.
+type packageInventory struct {
+	files map[string][]byte
+}
+
+type installedPackage struct {
+	name, source, version, ecosystem string
+	apk                              bool // from the apk database, so apk version rules apply
+}
+
+func (p installedPackage) compareVersions(a, b string) int {
+	if p.apk {
+		return compareApkVersions(a, b)
+	}
+	return compareDebianVersions(a, b)
+}
+
+func inventoryTracks(name string) bool {
+	switch name {
+	case dpkgStatusPath, apkInstalledPath, osReleasePaths[0], osReleasePaths[1]:
+		return true
+	}
+	return path.Dir(name) == dpkgStatusDirPath
+}
+
+func (inv *packageInventory) addLayer(layer io.Reader) error {
+	if inv.files == nil {
+		inv.files = map[string][]byte{}
+	}
+	tr := tar.NewReader(layer)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read layer: %w", err)
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		dir, base := path.Split(name)
+		if base == ".wh..wh..opq" {
+			for f := range inv.files {
+				if strings.HasPrefix(f, dir) {
+					delete(inv.files, f)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(base, ".wh.") {
+			target := dir + strings.TrimPrefix(base, ".wh.")
+			for f := range inv.files {
+				if f == target || strings.HasPrefix(f, target+"/") {
+					delete(inv.files, f)
+				}
+			}
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg || !inventoryTracks(name) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		inv.files[name] = data
+	}
+}
+
+// packages parses the collected databases. The ecosystem comes from
+// os-release, falling back to the database's home distribution.
+func (inv *packageInventory) packages() []installedPackage {
+	osID := ""
+	for _, p := range osReleasePaths {
+		if data, ok := inv.files[p]; ok {
+			osID = osReleaseID(data)
+			break
+		}
+	}
+	var pkgs []installedPackage
+	for name, data := range inv.files {
+		switch {
+		case name == dpkgStatusPath || path.Dir(name) == dpkgStatusDirPath:
+			eco := osID
+			if eco == "" {
+				eco = "debian"
+			}
+			pkgs = append(pkgs, parseDpkgStatus(data, eco)...)
+		case name == apkInstalledPath:
+			eco := osID
+			if eco == "" {
+				eco = "alpine"
+			}
+			pkgs = append(pkgs, parseApkInstalled(data, eco)...)
+		}
+	}
+	return pkgs
+}
+
+func osReleaseID(data []byte) string {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(sc.Text()), "ID="); ok {
+			return strings.Trim(v, `"'`)
+		}
+	}
+	return ""
+}
+
+// parseDpkgStatus reads dpkg status paragraphs. Entries in status.d
+// (distroless images) carry no Status field and count as installed.
+func parseDpkgStatus(data []byte, ecosystem string) []installedPackage {
+	var pkgs []installedPackage
+	for _, para := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n\n") {
+		fields := map[string]string{}
+		for _, line := range strings.Split(para, "\n") {
+			if line == "" || line[0] == ' ' || line[0] == '\t' {
+				continue
+			}
+			if k, v, ok := strings.Cut(line, ":"); ok {
+				fields[k] = strings.TrimSpace(v)
+			}
+		}
+		if fields["Package"] == "" || fields["Version"] == "" {
+			continue
+		}
+		if st, ok := fields["Status"]; ok && !strings.HasSuffix(st, " installed") {
+			continue
+		}
+		source, _, _ := strings.Cut(fields["Source"], " ")
+		pkgs = append(pkgs, installedPackage{name: fields["Package"], source: source, version: fields["Version"], ecosystem: ecosystem})
+	}
+	return pkgs
+}
+
+// parseApkInstalled reads the apk database: P: name, V: version,
+// o: origin package, one blank-line-separated block per package.
+func parseApkInstalled(data []byte, ecosystem string) []installedPackage {
+	var pkgs []installedPackage
+	var cur installedPackage
+	flush := func() {
+		if cur.name != "" && cur.version != "" {
+			cur.ecosystem = ecosystem
+			cur.apk = true
+			pkgs = append(pkgs, cur)
+		}
+		cur = installedPackage{}
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "P":
+			cur.name = v
+		case "V":
+			cur.version = v
+		case "o":
+			cur.source = v
+		}
+	}
+	flush()
+	return pkgs
+}
+
+// compareDebianVersions orders versions the way dpkg does
+// ([epoch:]upstream[-revision]).
+func compareDebianVersions(a, b string) int {
+	ea, ua, ra := splitDebianVersion(a)
+	eb, ub, rb := splitDebianVersion(b)
+	if ea != eb {
+		if ea < eb {
+			return -1
+		}
+		return 1
+	}
+	if c := compareVersionPart(ua, ub); c != 0 {
+		return c
+	}
+	return compareVersionPart(ra, rb)
+}
+
+func splitDebianVersion(v string) (epoch int, upstream, revision string) {
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		for _, c := range e {
+			if c < '0' || c > '9' {
+				epoch = 0
+				break
+			}
+			epoch = epoch*10 + int(c-'0')
+		}
+		v = rest
+	}
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// compareVersionPart alternates non-digit and digit runs; in non-digit
+// runs '~' sorts before everything, even the end of the string, and
+// letters sort before other characters.
+func compareVersionPart(a, b string) int {
+	for a != "" || b != "" {
+		var na, nb string
+		na, a = splitRun(a, false)
+		nb, b = splitRun(b, false)
+		if c := compareLexical(na, nb); c != 0 {
+			return c
+		}
+		na, a = splitRun(a, true)
+		nb, b = splitRun(b, true)
+		if c := compareNumeric(na, nb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func splitRun(s string, digits bool) (run, rest string) {
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9') == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func lexicalOrder(c byte, ok bool) int {
+	switch {
+	case !ok:
+		return 0
+	case c == '~':
+		return -1
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+func compareLexical(a, b string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var ca, cb byte
+		if i < len(a) {
+			ca = a[i]
+		}
+		if i < len(b) {
+			cb = b[i]
+		}
+		oa, ob := lexicalOrder(ca, i < len(a)), lexicalOrder(cb, i < len(b))
+		if oa != ob {
+			if oa < ob {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// apkSuffixes ranks the apk version suffixes: pre-releases sort below
+// the bare version, post-releases above it.
+var apkSuffixes = map[string]int{
+	"alpha": -4, "beta": -3, "pre": -2, "rc": -1,
+	"cvs": 1, "svn": 2, "git": 3, "hg": 4, "p": 5,
+}
+
+// apk version token types, in the order apk-tools gives them: at the
+// first differing type the version whose token type sorts later is
+// the lower one, except that a pre-release suffix is always lower.
+const (
+	apkTokenDigit = iota
+	apkTokenLetter
+	apkTokenSuffix
+	apkTokenSuffixNo
+	apkTokenRevision
+	apkTokenEnd
+)
+
+type apkToken struct {
+	typ  int
+	val  string
+	rank int // apkTokenSuffix only
+}
+
+// apkVersionTokens splits digits{.digits}[letter]{_suffix[n]}[~hash][-rN]
+// into tokens; ok is false for a version that doesn't follow it.
+func apkVersionTokens(v string) (tokens []apkToken, ok bool) {
+	rev := ""
+	if i := strings.LastIndex(v, "-r"); i >= 0 {
+		if n, rest := splitRun(v[i+2:], true); n != "" && rest == "" {
+			v, rev = v[:i], n
+		}
+	}
+	v, _, _ = strings.Cut(v, "~") // commit hash; not ordered
+	for first := true; first || strings.HasPrefix(v, "."); first = false {
+		var n string
+		if n, v = splitRun(strings.TrimPrefix(v, "."), true); n == "" {
+			return nil, false
+		}
+		tokens = append(tokens, apkToken{typ: apkTokenDigit, val: n})
+	}
+	if v != "" && v[0] >= 'a' && v[0] <= 'z' {
+		tokens = append(tokens, apkToken{typ: apkTokenLetter, val: v[:1]})
+		v = v[1:]
+	}
+	for strings.HasPrefix(v, "_") {
+		i := 1
+		for i < len(v) && v[i] >= 'a' && v[i] <= 'z' {
+			i++
+		}
+		rank, known := apkSuffixes[v[1:i]]
+		if !known {
+			return nil, false
+		}
+		tokens = append(tokens, apkToken{typ: apkTokenSuffix, val: v[1:i], rank: rank})
+		var n string
+		if n, v = splitRun(v[i:], true); n != "" {
+			tokens = append(tokens, apkToken{typ: apkTokenSuffixNo, val: n})
+		}
+	}
+	if v != "" {
+		return nil, false
+	}
+	if rev != "" {
+		tokens = append(tokens, apkToken{typ: apkTokenRevision, val: rev})
+	}
+	return append(tokens, apkToken{typ: apkTokenEnd}), true
+}
+
+// compareApkVersions orders versions the way apk does (1.0_rc1 <
+// 1.0 < 1.0-r1 < 1.0_p1 < 1.0.1). Versions apk itself would reject
+// fall back to the dpkg rules.
+func compareApkVersions(a, b string) int {
+	ta, okA := apkVersionTokens(a)
+	tb, okB := apkVersionTokens(b)
+	if !okA || !okB {
+		return compareDebianVersions(a, b)
+	}
+	for i := 0; ; i++ {
+		x, y := ta[i], tb[i]
+		if x.typ != y.typ {
+			switch {
+			case x.typ == apkTokenSuffix && x.rank < 0:
+				return -1
+			case y.typ == apkTokenSuffix && y.rank < 0:
+				return 1
+			case x.typ > y.typ:
+				return -1
+			default:
+				return 1
+			}
+		}
+		c := 0
+		switch x.typ {
+		case apkTokenEnd:
+			return 0
+		case apkTokenDigit:
+			// Past the first component a leading zero makes the
+			// component a fraction: 1.05 < 1.1.
+			if i > 0 && (x.val[0] == '0' || y.val[0] == '0') {
+				c = strings.Compare(x.val, y.val)
+			} else {
+				c = compareNumeric(x.val, y.val)
+			}
+		case apkTokenLetter:
+			c = strings.Compare(x.val, y.val)
+		case apkTokenSuffix:
+			c = x.rank - y.rank
+		default:
+			c = compareNumeric(x.val, y.val)
+		}
+		if c != 0 {
+			if c < 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+}
+
+// WalkImageLayers walks the layers of ref for the offline scanner,
+// reading the registry anonymously when the image is not held locally.
+// ImageManager.WalkImageLayers adds the cloud registry credentials.
+func (s *BaseServer) WalkImageLayers(ctx context.Context, ref string, fn func(io.Reader) error) error {
+	return s.walkImageLayers(ctx, ref, nil, fn)
+}
+
+// walkImageLayers serves a local image from Store.LayerContent and
+// fetches any layer it does not hold (and every layer of an image not
+// held locally) from the image's registry.
+func (s *BaseServer) walkImageLayers(ctx context.Context, ref string, access registryAccessFunc, fn func(io.Reader) error) error {
+	source := ref
+	var digests []string
+	if img, ok := s.Store.ResolveImage(ref); ok {
+		v, ok := s.Store.ImageManifestLayers.Load(img.ID)
+		if !ok {
+			return &api.ConflictError{Message: fmt.Sprintf("image %s has no layer data to scan", ref)}
+		}
+		for _, l := range v.([]ManifestLayerEntry) {
+			digests = append(digests, l.Digest)
+		}
+		if len(img.RepoTags) > 0 {
+			source = img.RepoTags[0]
+		}
+	}
+
+	var rc registryConfig
+	token := ""
+	connected := false
+	connect := func() error {
+		if connected {
+			return nil
+		}
+		rc = parseImageRef(source)
+		basicAuth := ""
+		if access != nil {
+			var err error
+			if basicAuth, rc.Endpoint, err = access(source); err != nil {
+				return err
+			}
+		}
+		var err error
+		if token, err = getRegistryToken(rc, basicAuth); err != nil {
+			return fmt.Errorf("registry auth for %s: %w", source, err)
+		}
+		connected = true
+		return nil
+	}
+
+	if digests == nil {
+		if s.Desc.Architecture == "" {
+			return &api.ServerError{Message: "scan: backend architecture is not set"}
+		}
+		if err := connect(); err != nil {
+			return err
+		}
+		minfo, err := getManifestInfoForPlatform(rc, token, "linux", s.Desc.Architecture)
+		if err != nil {
+			return &api.NotFoundError{Resource: "image", ID: ref}
+		}
+		digests = minfo.layerDigests
+	}
+
+	for _, d := range digests {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var blob []byte
+		if v, ok := s.Store.LayerContent.Load(d); ok {
+			blob = v.([]byte)
+		} else {
+			if err := connect(); err != nil {
+				return err
+			}
+			var err error
+			if blob, err = FetchLayerBlob(rc, token, d); err != nil {
+				return err
+			}
+		}
+		layer, err := uncompressedLayer(blob)
+		if err != nil {
+			return fmt.Errorf("layer %s: %w", d, err)
+		}
+		if err := fn(layer); err != nil {
+			return fmt.Errorf("layer %s: %w", d, err)
+		}
+	}
+	return nil
+}
+
+// uncompressedLayer returns a tar reader over a gzip or uncompressed
+// layer blob.
+func uncompressedLayer(blob []byte) (io.Reader, error) {
+	switch {
+	case bytes.HasPrefix(blob, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(bytes.NewReader(blob))
+	case bytes.HasPrefix(blob, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return nil, fmt.Errorf("zstd-compressed layers are not supported by the offline scanner")
+	default:
+		return bytes.NewReader(blob), nil
+	}
+}
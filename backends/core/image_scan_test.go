@@ -0,0 +1,381 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// scanTestLayer builds a gzip layer tar holding files.
+func scanTestLayer(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write([]byte(body))
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	return buf.Bytes()
+}
+
+func writeScanTestFeed(t *testing.T, entries []vulnFeedEntry) string {
+	t.Helper()
+	data, _ := json.Marshal(vulnFeed{Vulnerabilities: entries})
+	p := filepath.Join(t.TempDir(), "feed.json")
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+const scanTestDpkgStatus = `Package: libssl3
+Status: install ok installed
+Source: openssl (3.0.11-1~deb12u1)
+Version: 3.0.11-1~deb12u1
+
+Package: zlib1g
+Status: install ok installed
+Source: zlib
+Version: 1:1.2.13.dfsg-1
+
+Package: removed-pkg
+Status: deinstall ok config-files
+Version: 1.0-1
+`
+
+func TestOfflineScanner_MatchesInstalledPackages(t *testing.T) {
+	feed := writeScanTestFeed(t, []vulnFeedEntry{
+		{ID: "CVE-1", Ecosystem: "debian", Package: "openssl", Fixed: "3.0.11-1~deb12u2", Severity: "high"},
+		{ID: "CVE-2", Ecosystem: "debian", Package: "zlib", Fixed: "1:1.2.13.dfsg-1", Severity: "CRITICAL"},
+		{ID: "CVE-3", Ecosystem: "debian", Package: "removed-pkg", Severity: "CRITICAL"},
+		{ID: "CVE-4", Ecosystem: "alpine", Package: "libssl3", Severity: "CRITICAL"},
+		{ID: "CVE-5", Ecosystem: "debian", Package: "zlib1g", Affected: []string{"1:1.2.13.dfsg-1"}, Severity: "moderate"},
+	})
+	layers := [][]byte{
+		scanTestLayer(t, map[string]string{
+			"etc/os-release":      "PRETTY_NAME=\"Debian GNU/Linux 12\"\nID=debian\n",
+			"var/lib/dpkg/status": "Package: stale\nStatus: install ok installed\nVersion: 0.1\n",
+		}),
+		scanTestLayer(t, map[string]string{"var/lib/dpkg/status": scanTestDpkgStatus}),
+	}
+	s := newTestServer(&testExecDriver{})
+	img := api.Image{ID: "sha256:" + strings.Repeat("d", 64), RepoTags: []string{"debian:12"}}
+	StoreImageWithAliases(s.Store, "debian:12", img)
+	var entries []ManifestLayerEntry
+	for _, l := range layers {
+		entries = append(entries, ManifestLayerEntry{Digest: sha256Digest(l), Size: int64(len(l))})
+		s.Store.LayerContent.Store(sha256Digest(l), l)
+	}
+	s.Store.ImageManifestLayers.Store(img.ID, entries)
+	o := &OfflineScanner{FeedPath: feed, Layers: s.WalkImageLayers}
+
+	res, err := o.Scan(t.Context(), "debian:12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.finish()
+	if res.ScanStatus != ScanStatusComplete || len(res.Vulnerabilities) != 2 {
+		t.Fatalf("result = %+v", res)
+	}
+	if v := res.Vulnerabilities[0]; v.ID != "CVE-1" || v.Package != "libssl3" || v.Severity != SeverityHigh || v.FixVersion != "3.0.11-1~deb12u2" {
+		t.Errorf("first finding = %+v", v)
+	}
+	if v := res.Vulnerabilities[1]; v.ID != "CVE-5" || v.Severity != SeverityMedium {
+		t.Errorf("second finding = %+v", v)
+	}
+	if res.Summary[SeverityHigh] != 1 || res.Summary[SeverityMedium] != 1 {
+		t.Errorf("summary = %v", res.Summary)
+	}
+}
+
+func TestPackageInventory_ApkAndWhiteouts(t *testing.T) {
+	var inv packageInventory
+	layers := [][]byte{
+		scanTestLayer(t, map[string]string{
+			"lib/apk/db/installed": "P:musl\nV:1.2.4-r2\no:musl\n\nP:busybox\nV:1.36.1-r5\n",
+			"var/lib/dpkg/status":  "Package: old\nStatus: install ok installed\nVersion: 1\n",
+		}),
+		scanTestLayer(t, map[string]string{"var/lib/dpkg/.wh.status": ""}),
+	}
+	for _, l := range layers {
+		r, _ := uncompressedLayer(l)
+		if err := inv.addLayer(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkgs := inv.packages()
+	if len(pkgs) != 2 {
+		t.Fatalf("packages = %+v", pkgs)
+	}
+	for _, p := range pkgs {
+		if p.ecosystem != "alpine" || !p.apk {
+			t.Errorf("%s ecosystem = %q (apk %v), want alpine", p.name, p.ecosystem, p.apk)
+		}
+	}
+	fixed := vulnFeedEntry{Fixed: "1.2.4_rc1-r0"}
+	if p := pkgs[0]; fixed.affects("1.2.4-r0", p.compareVersions) {
+		t.Error("1.2.4-r0 is past the 1.2.4_rc1-r0 fix under apk ordering")
+	}
+}
+
+func TestCompareDebianVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1:1.0", "2.0", 1},
+		{"3.0.11-1~deb12u1", "3.0.11-1~deb12u2", -1},
+		{"1.2.4-r2", "1.2.4-r10", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0-1", "1.0+b1-1", -1},
+	} {
+		if got := compareDebianVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compare(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := compareDebianVersions(tc.b, tc.a); got != -tc.want {
+			t.Errorf("compare(%q, %q) = %d, want %d", tc.b, tc.a, got, -tc.want)
+		}
+	}
+}
+
+func TestCompareApkVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.4-r2", "1.2.4-r10", -1},
+		{"1.2.4_rc1-r0", "1.2.4-r0", -1},
+		{"1.2.4_alpha2", "1.2.4_beta1", -1},
+		{"1.2.4_beta", "1.2.4_rc1", -1},
+		{"1.2.4_p1-r0", "1.2.4-r5", 1},
+		{"1.2.4_p1", "1.2.5", -1},
+		{"1.2.4", "1.2.4-r0", -1},
+		{"1.2.4-r3", "1.2.4.1-r0", -1},
+		{"1.2.4a", "1.2.4b", -1},
+		{"1.2.4_git20240101", "1.2.4", 1},
+		{"1.05", "1.1", -1},
+		{"3.1.4-r5", "3.1.4-r5", 0},
+	} {
+		if got := compareApkVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compare(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := compareApkVersions(tc.b, tc.a); got != -tc.want {
+			t.Errorf("compare(%q, %q) = %d, want %d", tc.b, tc.a, got, -tc.want)
+		}
+	}
+}
+
+// fakeScanner returns a fixed result and counts scans.
+type fakeScanner struct {
+	result *ScanResult
+	scans  int
+}
+
+func (f *fakeScanner) Available() bool { return true }
+func (f *fakeScanner) Scan(_ context.Context, ref string) (*ScanResult, error) {
+	f.scans++
+	res := *f.result
+	res.ImageRef = ref
+	res.Vulnerabilities = append([]Vulnerability(nil), f.result.Vulnerabilities...)
+	return &res, nil
+}
+
+func TestAdmission_BlocksContainerCreateAtThreshold(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	img := api.Image{ID: "sha256:" + strings.Repeat("a", 64), RepoTags: []string{"app:v1"}}
+	StoreImageWithAliases(s.Store, "app:v1", img)
+	scanner := &fakeScanner{result: &ScanResult{ScanStatus: ScanStatusComplete, Vulnerabilities: []Vulnerability{
+		{ID: "CVE-9", Package: "openssl", Severity: "HIGH"},
+		{ID: "CVE-8", Package: "zlib", Severity: "LOW"},
+	}}}
+	s.Scanner = scanner
+	s.ScanPolicy = ScanPolicy{BlockSeverity: SeverityHigh, OnCreate: true}
+
+	create := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(api.ContainerCreateRequest{ContainerConfig: &api.ContainerConfig{Image: "app:v1"}})
+		w := httptest.NewRecorder()
+		s.handleContainerCreate(w, httptest.NewRequest(http.MethodPost, "/containers/create", bytes.NewReader(body)))
+		return w
+	}
+	if w := create(); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "CVE-9") {
+		t.Fatalf("create = %d %s, want 403 naming CVE-9", w.Code, w.Body.String())
+	}
+
+	s.ScanPolicy.BlockSeverity = SeverityCritical
+	if w := create(); w.Code != http.StatusCreated {
+		t.Fatalf("create below threshold = %d %s", w.Code, w.Body.String())
+	}
+	if scanner.scans != 1 {
+		t.Errorf("scans = %d, want the cached result reused", scanner.scans)
+	}
+
+	w := httptest.NewRecorder()
+	s.handleImageInspect(w, httptest.NewRequest(http.MethodGet, "/internal/v1/images/inspect?name=app:v1", nil))
+	var inspect struct {
+		ID             string `json:"Id"`
+		SockerlessScan *ScanResult
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &inspect); err != nil {
+		t.Fatal(err)
+	}
+	if inspect.ID != img.ID || inspect.SockerlessScan == nil || inspect.SockerlessScan.Summary[SeverityHigh] != 1 {
+		t.Errorf("inspect = %s", w.Body.String())
+	}
+}
+
+func TestAdmission_IncompleteScanIsRefused(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	s.Scanner = &fakeScanner{result: &ScanResult{ScanStatus: ScanStatusFailed, Message: "unsupported image"}}
+	s.ScanPolicy = ScanPolicy{BlockSeverity: SeverityCritical, OnCreate: true}
+	var forbidden *api.ForbiddenError
+	if _, err := s.admitImage(t.Context(), "app:v1", ScanEnforceCreate); !errors.As(err, &forbidden) {
+		t.Fatalf("err = %v, want ForbiddenError", err)
+	}
+	if _, err := s.admitImage(t.Context(), "app:v1", ScanEnforcePull); err != nil {
+		t.Errorf("pull is not enforced: err = %v", err)
+	}
+}
+
+func TestAdmission_PullStreamsThenAppendsVerdict(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	StoreImageWithAliases(s.Store, "app:v1", api.Image{ID: "sha256:" + strings.Repeat("b", 64), RepoTags: []string{"app:v1"}})
+	s.Scanner = &fakeScanner{result: &ScanResult{ScanStatus: ScanStatusComplete, Vulnerabilities: []Vulnerability{
+		{ID: "CVE-9", Package: "openssl", Severity: "CRITICAL"},
+	}}}
+	s.ScanPolicy = ScanPolicy{BlockSeverity: SeverityHigh, OnPull: true}
+
+	pr, pw := io.Pipe()
+	out := s.admitPulledImage(t.Context(), "app:v1", pr)
+	progress := `{"status":"Pulling from library/app"}` + "\n"
+	go func() { _, _ = pw.Write([]byte(progress)) }()
+	buf := make([]byte, 256)
+	n, err := out.Read(buf)
+	if err != nil || string(buf[:n]) != progress {
+		t.Fatalf("first read = %q, %v; want progress before the stream ends", buf[:n], err)
+	}
+
+	_ = pw.Close()
+	rest, err := io.ReadAll(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg struct {
+		Error       string `json:"error"`
+		ErrorDetail struct {
+			Message string `json:"message"`
+		} `json:"errorDetail"`
+	}
+	if err := json.Unmarshal(rest, &msg); err != nil || !strings.Contains(msg.Error, "CVE-9") || msg.ErrorDetail.Message != msg.Error {
+		t.Fatalf("verdict = %q (%v)", rest, err)
+	}
+	var forbidden *api.ForbiddenError
+	if !errors.As(out.Err(), &forbidden) {
+		t.Errorf("Err = %v, want ForbiddenError", out.Err())
+	}
+	if _, ok := s.Store.ResolveImage("app:v1"); ok {
+		t.Error("refused image still stored")
+	}
+}
+
+// versionedScanner is a fakeScanner with a feed version.
+type versionedScanner struct {
+	fakeScanner
+	version string
+}
+
+func (v *versionedScanner) FeedVersion() string { return v.version }
+
+func TestScanImage_RescansStaleResults(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	scanner := &versionedScanner{fakeScanner: fakeScanner{result: &ScanResult{ScanStatus: ScanStatusComplete}}, version: "1"}
+	s.Scanner = scanner
+	scan := func() *ScanResult {
+		t.Helper()
+		res, err := s.ScanImage(t.Context(), "app:v1", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if res := scan(); res.FeedVersion != "1" {
+		t.Errorf("FeedVersion = %q", res.FeedVersion)
+	}
+	scan()
+	if scanner.scans != 1 {
+		t.Fatalf("scans = %d, want the cached result reused", scanner.scans)
+	}
+
+	scanner.version = "2"
+	if res := scan(); scanner.scans != 2 || res.FeedVersion != "2" {
+		t.Errorf("feed changed: scans = %d, FeedVersion = %q", scanner.scans, res.FeedVersion)
+	}
+
+	v, _ := s.Store.ImageScans.Load("app:v1")
+	v.(*ScanResult).cachedAt = time.Now().Add(-DefaultScanMaxAge - time.Minute)
+	scan()
+	if scanner.scans != 3 {
+		t.Errorf("expired: scans = %d, want a rescan", scanner.scans)
+	}
+	s.ScanPolicy.MaxAge = 48 * time.Hour
+	v, _ = s.Store.ImageScans.Load("app:v1")
+	v.(*ScanResult).cachedAt = time.Now().Add(-DefaultScanMaxAge - time.Minute)
+	scan()
+	if scanner.scans != 3 {
+		t.Errorf("within MaxAge: scans = %d, want the cached result reused", scanner.scans)
+	}
+}
+
+func TestScanImage_NotConfigured(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	var notImpl *api.NotImplementedError
+	if _, err := s.ScanImage(t.Context(), "app:v1", false); !errors.As(err, &notImpl) {
+		t.Errorf("err = %v, want NotImplementedError", err)
+	}
+}
+
+func TestImageScanConfig_Validate(t *testing.T) {
+	feed := writeScanTestFeed(t, nil)
+	for _, tc := range []struct {
+		name string
+		cfg  ImageScanConfig
+		ok   bool
+	}{
+		{"none", ImageScanConfig{}, true},
+		{"cloud with policy", ImageScanConfig{Scanner: "cloud", BlockSeverity: "HIGH", Enforce: []string{"create", "pull"}}, true},
+		{"offline", ImageScanConfig{Scanner: "offline", FeedPath: feed}, true},
+		{"offline without feed", ImageScanConfig{Scanner: "offline"}, false},
+		{"offline missing feed", ImageScanConfig{Scanner: "offline", FeedPath: feed + ".missing"}, false},
+		{"unknown scanner", ImageScanConfig{Scanner: "trivy"}, false},
+		{"policy without scanner", ImageScanConfig{BlockSeverity: "HIGH", Enforce: []string{"create"}}, false},
+		{"bad severity", ImageScanConfig{Scanner: "cloud", BlockSeverity: "SEVERE", Enforce: []string{"create"}}, false},
+		{"bad operation", ImageScanConfig{Scanner: "cloud", BlockSeverity: "HIGH", Enforce: []string{"start"}}, false},
+		{"bad max age", ImageScanConfig{Scanner: "cloud", MaxAge: -1}, false},
+	} {
+		if err := tc.cfg.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package core
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sockerless/api"
+)
+
+// Image signature verification. With a trust policy configured,
+// container create refuses images that carry no valid signature in the
+// backend's registry. Signatures are read from the cosign tag scheme
+// (<repo>:sha256-<hex>.sig, attestations under .att) and from OCI
+// referrers of the image manifest, which carry Notation signatures and
+// cosign's OCI 1.1 artifacts.
+
+// Signature artifact media types.
+const (
+	cosignSimpleSigningType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	dsseEnvelopeType        = "application/vnd.dsse.envelope.v1+json"
+	notationArtifactType    = "application/vnd.cncf.notary.signature"
+	notationJWSType         = "application/jose+json"
+	notationCOSEType        = "application/cose"
+)
+
+// ImageVerifyConfig selects the signature trust policy of a backend.
+// The zero value turns verification off.
+type ImageVerifyConfig struct {
+	// KeysPath is a PEM file of cosign public keys (ECDSA, RSA or
+	// Ed25519); a signature made by any of them is trusted.
+	KeysPath string
+	// Identities are keyless signers as "<issuer>=<subject regexp>",
+	// matched against the OIDC issuer and SAN of the signing
+	// certificate. The regexp is anchored.
+	Identities []string
+	// KeylessRoots is a PEM bundle of the CA issuing keyless signing
+	// certificates (the Fulcio root).
+	KeylessRoots string
+	// RekorKey is the PEM public key of the transparency log; required
+	// with Identities. Keyless signatures must carry a log entry signed
+	// by it, and their short-lived certificate is checked at the logged
+	// time.
+	RekorKey string
+	// NotationRoots is a PEM bundle of the CAs Notation signatures must
+	// chain to.
+	NotationRoots string
+	// RequireProvenance also requires a trusted SLSA provenance
+	// attestation for the image.
+	RequireProvenance bool
+}
+
+// ImageVerifyConfigFromEnv reads SOCKERLESS_VERIFY_KEYS,
+// SOCKERLESS_VERIFY_IDENTITIES (comma-separated),
+// SOCKERLESS_VERIFY_KEYLESS_ROOTS, SOCKERLESS_VERIFY_REKOR_KEY,
+// SOCKERLESS_VERIFY_NOTATION_ROOTS and SOCKERLESS_VERIFY_PROVENANCE=1.
+func ImageVerifyConfigFromEnv() ImageVerifyConfig {
+	c := ImageVerifyConfig{
+		KeysPath:          strings.TrimSpace(os.Getenv("SOCKERLESS_VERIFY_KEYS")),
+		KeylessRoots:      strings.TrimSpace(os.Getenv("SOCKERLESS_VERIFY_KEYLESS_ROOTS")),
+		RekorKey:          strings.TrimSpace(os.Getenv("SOCKERLESS_VERIFY_REKOR_KEY")),
+		NotationRoots:     strings.TrimSpace(os.Getenv("SOCKERLESS_VERIFY_NOTATION_ROOTS")),
+		RequireProvenance: os.Getenv("SOCKERLESS_VERIFY_PROVENANCE") == "1",
+	}
+	for _, id := range strings.Split(os.Getenv("SOCKERLESS_VERIFY_IDENTITIES"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			c.Identities = append(c.Identities, id)
+		}
+	}
+	return c
+}
+
+// Enabled reports whether c trusts any signer.
+func (c ImageVerifyConfig) Enabled() bool {
+	return c.KeysPath != "" || len(c.Identities) > 0 || c.NotationRoots != ""
+}
+
+// Validate loads the keys and roots c names and rejects settings that
+// have nothing to apply to.
+func (c ImageVerifyConfig) Validate() error {
+	if !c.Enabled() {
+		if c.KeylessRoots != "" || c.RekorKey != "" || c.RequireProvenance {
+			return fmt.Errorf("SOCKERLESS_VERIFY_KEYLESS_ROOTS, SOCKERLESS_VERIFY_REKOR_KEY and SOCKERLESS_VERIFY_PROVENANCE require a trusted signer (SOCKERLESS_VERIFY_KEYS, SOCKERLESS_VERIFY_IDENTITIES or SOCKERLESS_VERIFY_NOTATION_ROOTS)")
+		}
+		return nil
+	}
+	_, err := c.load()
+	return err
+}
+
+// verifyPolicy is a loaded ImageVerifyConfig.
+type verifyPolicy struct {
+	keys              []trustedKey
+	identities        []keylessIdentity
+	keylessRoots      *x509.CertPool
+	rekorKey          crypto.PublicKey
+	notationRoots     *x509.CertPool
+	requireProvenance bool
+}
+
+type trustedKey struct {
+	id  string // "key sha256:<first 16 hex of the PKIX digest>"
+	pub crypto.PublicKey
+}
+
+type keylessIdentity struct {
+	issuer  string
+	subject *regexp.Regexp
+}
+
+func (c ImageVerifyConfig) load() (*verifyPolicy, error) {
+	p := &verifyPolicy{requireProvenance: c.RequireProvenance}
+	if c.KeysPath != "" {
+		keys, err := loadPublicKeys(c.KeysPath)
+		if err != nil {
+			return nil, fmt.Errorf("SOCKERLESS_VERIFY_KEYS: %w", err)
+		}
+		p.keys = keys
+	}
+	for _, id := range c.Identities {
+		issuer, subject, ok := strings.Cut(id, "=")
+		if !ok || issuer == "" || subject == "" {
+			return nil, fmt.Errorf("SOCKERLESS_VERIFY_IDENTITIES: %q is not <issuer>=<subject regexp>", id)
+		}
+		re, err := regexp.Compile("^(?:" + subject + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("SOCKERLESS_VERIFY_IDENTITIES: subject of %q: %w", id, err)
+		}
+		p.identities = append(p.identities, keylessIdentity{issuer: issuer, subject: re})
+	}
+	switch {
+	case len(p.identities) > 0 && c.KeylessRoots == "":
+		return nil, fmt.Errorf("SOCKERLESS_VERIFY_IDENTITIES requires SOCKERLESS_VERIFY_KEYLESS_ROOTS")
+	case len(p.identities) > 0 && c.RekorKey == "":
+		// Keyless certificates live minutes; without a logged signing
+		// time there is no moment to check them at.
+		return nil, fmt.Errorf("SOCKERLESS_VERIFY_IDENTITIES requires SOCKERLESS_VERIFY_REKOR_KEY")
+	case len(p.identities) == 0 && c.KeylessRoots != "":
+		return nil, fmt.Errorf("SOCKERLESS_VERIFY_KEYLESS_ROOTS requires SOCKERLESS_VERIFY_IDENTITIES")
+	case len(p.identities) == 0 && c.RekorKey != "":
+		return nil, fmt.Errorf("SOCKERLESS_VERIFY_REKOR_KEY requires SOCKERLESS_VERIFY_IDENTITIES")
+	}
+	if c.KeylessRoots != "" {
+		pool, err := loadCertPool(c.KeylessRoots)
+		if err != nil {
+			return nil, fmt.Errorf("SOCKERLESS_VERIFY_KEYLESS_ROOTS: %w", err)
+		}
+		p.keylessRoots = pool
+	}
+	if c.RekorKey != "" {
+		keys, err := loadPublicKeys(c.RekorKey)
+		if err != nil {
+			return nil, fmt.Errorf("SOCKERLESS_VERIFY_REKOR_KEY: %w", err)
+		}
+		p.rekorKey = keys[0].pub
+	}
+	if c.NotationRoots != "" {
+		pool, err := loadCertPool(c.NotationRoots)
+		if err != nil {
+			return nil, fmt.Errorf("SOCKERLESS_VERIFY_NOTATION_ROOTS: %w", err)
+		}
+		p.notationRoots = pool
+	}
+	return p, nil
+}
+
+// loadPublicKeys reads every PUBLIC KEY block of a PEM file.
+func loadPublicKeys(path string) ([]trustedKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []trustedKey
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		sum := sha256.Sum256(block.Bytes)
+		keys = append(keys, trustedKey{id: fmt.Sprintf("key sha256:%x", sum[:8]), pub: pub})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s holds no PEM public key", path)
+	}
+	return keys, nil
+}
+
+// loadCertPool reads a PEM certificate bundle.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s holds no PEM certificate", path)
+	}
+	return pool, nil
+}
+
+// VerifyResult is the outcome of verifying one image.
+type VerifyResult struct {
+	ImageRef   string              `json:"ImageRef"`
+	Digest     string              `json:"Digest"`
+	Verified   bool                `json:"Verified"`
+	Message    string              `json:"Message,omitempty"` // why the image is not verified
+	Signatures []VerifiedSignature `json:"Signatures"`
+	Provenance []VerifiedSignature `json:"Provenance,omitempty"`
+}
+
+// VerifiedSignature is one trusted signature or attestation.
+type VerifiedSignature struct {
+	Format        string `json:"Format"` // cosign, notation, in-toto
+	Signer        string `json:"Signer"` // key ID, "<issuer> <subject>" or certificate subject
+	Digest        string `json:"Digest"` // manifest digest it covers
+	Source        string `json:"Source"` // tag or referrer it was read from
+	PredicateType string `json:"PredicateType,omitempty"`
+}
+
+// ImageVerifier checks images against a trust policy, reading
+// signatures from the registry the backend runs images from.
+type ImageVerifier struct {
+	policy  *verifyPolicy
+	resolve func(ctx context.Context, ref string) (string, error)
+	access  registryAccessFunc
+	arch    string
+	// verified caches passing results by repository@digest; a digest
+	// names immutable content, so a signature once trusted stays so
+	// for the life of the process.
+	verified sync.Map
+}
+
+// signatureLayer is one layer of a signature artifact, with its blob.
+type signatureLayer struct {
+	source       string
+	artifactType string
+	mediaType    string
+	annotations  map[string]string
+	blob         []byte
+}
+
+// signatureManifest is the part of an OCI manifest signature
+// artifacts use.
+type signatureManifest struct {
+	ArtifactType string `json:"artifactType"`
+	Config       struct {
+		MediaType string `json:"mediaType"`
+	} `json:"config"`
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// referrersIndex is the OCI image index the referrers API returns.
+type referrersIndex struct {
+	Manifests []struct {
+		Digest       string `json:"digest"`
+		ArtifactType string `json:"artifactType"`
+	} `json:"manifests"`
+}
+
+// maxSignatureArtifacts bounds the referrers and layers read per image.
+const maxSignatureArtifacts = 64
+
+// Verify checks ref. Registry failures are returned as errors; an
+// image that fails the policy is a result with Verified false.
+func (v *ImageVerifier) Verify(ctx context.Context, ref string) (*VerifyResult, error) {
+	uri := ref
+	if v.resolve != nil {
+		var err error
+		if uri, err = v.resolve(ctx, ref); err != nil {
+			return nil, err
+		}
+	}
+	rc := parseImageRef(uri)
+	if _, digest, ok := strings.Cut(uri, "@"); ok {
+		rc.Tag = digest
+	}
+	basicAuth, endpoint, err := v.access(uri)
+	if err != nil {
+		return nil, err
+	}
+	rc.Endpoint = endpoint
+	token, err := getRegistryToken(rc, basicAuth)
+	if err != nil {
+		return nil, fmt.Errorf("registry auth for %s: %w", rc.Registry, err)
+	}
+	digests, err := v.subjectDigests(rc, token)
+	if err != nil {
+		return nil, err
+	}
+	key := rc.Registry + "/" + rc.Repository + "@" + digests[0]
+	if cached, ok := v.verified.Load(key); ok {
+		return cached.(*VerifyResult), nil
+	}
+
+	res := &VerifyResult{ImageRef: uri, Digest: digests[0], Signatures: []VerifiedSignature{}}
+	var rejected []string
+	for _, digest := range digests {
+		layers, err := v.signatureLayers(rc, token, digest)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range layers {
+			sig, err := v.policy.verifyLayer(l, digest)
+			if err != nil {
+				rejected = append(rejected, l.source+": "+err.Error())
+				continue
+			}
+			sig.Source = l.source
+			if sig.Format == "in-toto" {
+				res.Provenance = append(res.Provenance, sig)
+			} else {
+				res.Signatures = append(res.Signatures, sig)
+			}
+		}
+	}
+	switch {
+	case len(res.Signatures) == 0 && len(rejected) == 0:
+		res.Message = fmt.Sprintf("no signatures found for %s in %s/%s", strings.Join(digests, ", "), rc.Registry, rc.Repository)
+	case len(res.Signatures) == 0:
+		res.Message = fmt.Sprintf("no trusted signature for %s (%s)", digests[0], strings.Join(rejected, "; "))
+	case v.policy.requireProvenance && len(res.Provenance) == 0:
+		res.Message = fmt.Sprintf("no trusted SLSA provenance attestation for %s", digests[0])
+		if len(rejected) > 0 {
+			res.Message += " (" + strings.Join(rejected, "; ") + ")"
+		}
+	default:
+		res.Verified = true
+		v.verified.Store(key, res)
+	}
+	return res, nil
+}
+
+// subjectDigests returns the digest of the manifest rc names and, for
+// an index, of its entry for the backend's platform; a signature over
+// either covers the image the backend runs.
+func (v *ImageVerifier) subjectDigests(rc registryConfig, token string) ([]string, error) {
+	body, mediaType, err := registryGet(registryURL(rc, "manifests", rc.Tag), token, []string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("manifest %s/%s:%s: %w", rc.Registry, rc.Repository, rc.Tag, err)
+	}
+	digests := []string{fmt.Sprintf("sha256:%x", sha256.Sum256(body))}
+	if strings.HasPrefix(rc.Tag, "sha256:") && rc.Tag != digests[0] {
+		return nil, fmt.Errorf("manifest %s/%s@%s has digest %s", rc.Registry, rc.Repository, rc.Tag, digests[0])
+	}
+	if !strings.Contains(mediaType, "manifest.list") && !strings.Contains(mediaType, "image.index") {
+		return digests, nil
+	}
+	var ml manifestList
+	if err := json.Unmarshal(body, &ml); err != nil {
+		return nil, fmt.Errorf("decode manifest list: %w", err)
+	}
+	arch := v.arch
+	if arch == "" {
+		arch = "amd64"
+	}
+	for _, m := range ml.Manifests {
+		if m.Platform.OS == "linux" && m.Platform.Architecture == arch {
+			digests = append(digests, m.Digest)
+			break
+		}
+	}
+	return digests, nil
+}
+
+// signatureLayers collects the signature layers attached to digest:
+// the cosign .sig tag (and .att when provenance is required), then the
+// referrers, read through the referrers API or, on registries without
+// it, the sha256-<hex> referrers tag.
+func (v *ImageVerifier) signatureLayers(rc registryConfig, token, digest string) ([]signatureLayer, error) {
+	tagBase := strings.Replace(digest, ":", "-", 1)
+	suffixes := []string{".sig"}
+	if v.policy.requireProvenance {
+		suffixes = append(suffixes, ".att")
+	}
+	var layers []signatureLayer
+	for _, suffix := range suffixes {
+		found, err := v.artifactLayers(rc, token, tagBase+suffix, "tag "+tagBase+suffix, "")
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, found...)
+	}
+
+	body, _, err := registryGet(registryURL(rc, "referrers", digest), token, []string{"application/vnd.oci.image.index.v1+json"})
+	source := "referrer"
+	if isRegistryNotFound(err) {
+		body, _, err = registryGet(registryURL(rc, "manifests", tagBase), token, []string{"application/vnd.oci.image.index.v1+json"})
+		source = "referrers tag " + tagBase + " entry"
+		if isRegistryNotFound(err) {
+			return layers, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("referrers of %s: %w", digest, err)
+	}
+	var idx referrersIndex
+	if err := json.Unmarshal(body, &idx); err != nil {
+		return nil, fmt.Errorf("decode referrers of %s: %w", digest, err)
+	}
+	if len(idx.Manifests) > maxSignatureArtifacts {
+		idx.Manifests = idx.Manifests[:maxSignatureArtifacts]
+	}
+	for _, m := range idx.Manifests {
+		found, err := v.artifactLayers(rc, token, m.Digest, source+" "+m.Digest, m.ArtifactType)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, found...)
+	}
+	return layers, nil
+}
+
+// artifactLayers reads the signature layers of the manifest at
+// reference. A missing manifest has none; layers of media types no
+// verifier reads are skipped without fetching their blobs.
+func (v *ImageVerifier) artifactLayers(rc registryConfig, token, reference, source, artifactType string) ([]signatureLayer, error) {
+	body, _, err := registryGet(registryURL(rc, "manifests", reference), token, []string{
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	})
+	if isRegistryNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signature manifest %s: %w", reference, err)
+	}
+	var m signatureManifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("decode signature manifest %s: %w", reference, err)
+	}
+	if artifactType == "" {
+		artifactType = m.ArtifactType
+	}
+	if artifactType == "" {
+		artifactType = m.Config.MediaType
+	}
+	var layers []signatureLayer
+	for i, l := range m.Layers {
+		if i == maxSignatureArtifacts {
+			break
+		}
+		switch l.MediaType {
+		case cosignSimpleSigningType, dsseEnvelopeType, notationJWSType, notationCOSEType:
+		default:
+			continue
+		}
+		if l.MediaType == dsseEnvelopeType && !v.policy.requireProvenance {
+			continue
+		}
+		blob, err := FetchLayerBlob(rc, token, l.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("signature %s: %w", reference, err)
+		}
+		layers = append(layers, signatureLayer{
+			source:       source,
+			artifactType: artifactType,
+			mediaType:    l.MediaType,
+			annotations:  l.Annotations,
+			blob:         blob,
+		})
+	}
+	return layers, nil
+}
+
+// ConfigureImageVerification installs the trust policy c selects.
+// images reads the backend's registry with its credentials; resolve
+// maps a reference to the registry URI the backend runs it from.
+func (s *BaseServer) ConfigureImageVerification(c ImageVerifyConfig, images *ImageManager, resolve func(ctx context.Context, ref string) (string, error)) error {
+	if !c.Enabled() {
+		return nil
+	}
+	p, err := c.load()
+	if err != nil {
+		return err
+	}
+	s.Verifier = &ImageVerifier{policy: p, resolve: resolve, access: images.registryAccess, arch: s.Desc.Architecture}
+	return nil
+}
+
+// VerifyImage checks ref against the trust policy.
+func (s *BaseServer) VerifyImage(ctx context.Context, ref string) (*VerifyResult, error) {
+	if s.Verifier == nil {
+		return nil, &api.NotImplementedError{Message: "image signature verification is not configured on this backend (set SOCKERLESS_VERIFY_KEYS, SOCKERLESS_VERIFY_IDENTITIES or SOCKERLESS_VERIFY_NOTATION_ROOTS)"}
+	}
+	res, err := s.Verifier.Verify(ctx, ref)
+	if err != nil {
+		if _, ok := err.(api.StatusCoder); ok {
+			return nil, err
+		}
+		return nil, &api.ServerError{Message: fmt.Sprintf("verify signatures of %s: %v", ref, err)}
+	}
+	return res, nil
+}
+
+// admitSignedImage refuses ref unless it passes the trust policy, and
+// returns the reference to run: the verified repository pinned to the
+// verified digest, so a tag moved after verification can't swap in an
+// unsigned image. Without a policy ref is returned as is.
+func (s *BaseServer) admitSignedImage(ctx context.Context, ref string) (string, error) {
+	if s.Verifier == nil {
+		return ref, nil
+	}
+	res, err := s.VerifyImage(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if !res.Verified {
+		return "", &api.ForbiddenError{Message: fmt.Sprintf("image %s refused by signature policy: %s", ref, res.Message)}
+	}
+	pinned := pinImageDigest(res.ImageRef, res.Digest)
+	if img, ok := s.Store.ResolveImage(ref); ok {
+		// The pinned reference carries the pulled image's config.
+		s.Store.Images.Put(pinned, img)
+	}
+	return pinned, nil
+}
+
+// pinImageDigest replaces the tag or digest of ref with digest.
+func pinImageDigest(ref, digest string) string {
+	r, err := ParseImageRef(ref)
+	if err != nil {
+		return ref
+	}
+	r.Tag, r.Digest = "", digest
+	return r.String()
+}
+
+// handleImageVerify serves GET /internal/v1/images/verify?name=.
+func (s *BaseServer) handleImageVerify(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		WriteError(w, &api.InvalidParameterError{Message: "name is required"})
+		return
+	}
+	res, err := s.VerifyImage(r.Context(), name)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, res)
+}
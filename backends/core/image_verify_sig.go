@@ -0,0 +1,516 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Cosign layer annotations.
+const (
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	cosignCertAnnotation      = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation     = "dev.sigstore.cosign/chain"
+	cosignBundleAnnotation    = "dev.sigstore.cosign/bundle"
+)
+
+// Fulcio certificate extensions naming the OIDC issuer: the original
+// raw-string form and its DER-encoded successor.
+var (
+	oidFulcioIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// verifyLayer checks one signature layer against the policy and
+// digest, the manifest digest the layer must cover.
+func (p *verifyPolicy) verifyLayer(l signatureLayer, digest string) (VerifiedSignature, error) {
+	switch l.mediaType {
+	case cosignSimpleSigningType:
+		return p.verifyCosignSignature(l, digest)
+	case dsseEnvelopeType:
+		return p.verifyAttestation(l, digest)
+	case notationJWSType:
+		if l.artifactType != notationArtifactType {
+			return VerifiedSignature{}, fmt.Errorf("JWS layer in a %q artifact is not a Notation signature", l.artifactType)
+		}
+		return p.verifyNotation(l, digest)
+	case notationCOSEType:
+		return VerifiedSignature{}, errors.New("COSE Notation envelopes are not supported; sign with --signature-format jws")
+	}
+	return VerifiedSignature{}, fmt.Errorf("unsupported signature media type %s", l.mediaType)
+}
+
+// verifyCosignSignature checks a cosign simple-signing payload and the
+// signature over it.
+func (p *verifyPolicy) verifyCosignSignature(l signatureLayer, digest string) (VerifiedSignature, error) {
+	var payload struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+			Type string `json:"type"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(l.blob, &payload); err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode signature payload: %w", err)
+	}
+	if payload.Critical.Type != "cosign container image signature" {
+		return VerifiedSignature{}, fmt.Errorf("payload type is %q, not a cosign container image signature", payload.Critical.Type)
+	}
+	if payload.Critical.Image.DockerManifestDigest != digest {
+		return VerifiedSignature{}, fmt.Errorf("signs %s, not %s", payload.Critical.Image.DockerManifestDigest, digest)
+	}
+	sig, err := base64.StdEncoding.DecodeString(l.annotations[cosignSignatureAnnotation])
+	if err != nil || len(sig) == 0 {
+		return VerifiedSignature{}, errors.New("missing or malformed " + cosignSignatureAnnotation + " annotation")
+	}
+	signer, err := p.verifySigner(l.annotations, l.blob, sig, nil)
+	if err != nil {
+		return VerifiedSignature{}, err
+	}
+	return VerifiedSignature{Format: "cosign", Signer: signer, Digest: digest}, nil
+}
+
+// verifyAttestation checks a DSSE-enveloped in-toto statement: a
+// trusted signature, an SLSA provenance predicate and a subject naming
+// digest.
+func (p *verifyPolicy) verifyAttestation(l signatureLayer, digest string) (VerifiedSignature, error) {
+	var env struct {
+		PayloadType string `json:"payloadType"`
+		Payload     string `json:"payload"`
+		Signatures  []struct {
+			Sig string `json:"sig"`
+		} `json:"signatures"`
+	}
+	if err := json.Unmarshal(l.blob, &env); err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode DSSE envelope: %w", err)
+	}
+	if env.PayloadType != "application/vnd.in-toto+json" {
+		return VerifiedSignature{}, fmt.Errorf("attestation payload type is %q, not in-toto", env.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode DSSE payload: %w", err)
+	}
+	pae := dssePAE(env.PayloadType, payload)
+	signer := ""
+	err = errors.New("DSSE envelope has no signatures")
+	for _, s := range env.Signatures {
+		sig, derr := base64.StdEncoding.DecodeString(s.Sig)
+		if derr != nil {
+			err = fmt.Errorf("decode DSSE signature: %w", derr)
+			continue
+		}
+		if signer, err = p.verifySigner(l.annotations, pae, sig, payload); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return VerifiedSignature{}, err
+	}
+
+	var statement struct {
+		PredicateType string `json:"predicateType"`
+		Subject       []struct {
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+	}
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode in-toto statement: %w", err)
+	}
+	if !strings.HasPrefix(statement.PredicateType, "https://slsa.dev/provenance/") {
+		return VerifiedSignature{}, fmt.Errorf("attestation predicate is %s, not SLSA provenance", statement.PredicateType)
+	}
+	for _, subj := range statement.Subject {
+		if "sha256:"+subj.Digest["sha256"] == digest {
+			return VerifiedSignature{Format: "in-toto", Signer: signer, Digest: digest, PredicateType: statement.PredicateType}, nil
+		}
+	}
+	return VerifiedSignature{}, fmt.Errorf("provenance subjects do not include %s", digest)
+}
+
+// dssePAE is the DSSE pre-authentication encoding signatures cover.
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// verifySigner checks sig over message against the trusted keys, then
+// against the keyless certificate the annotations carry. dssePayload
+// is the raw attestation payload a transparency-log entry binds, nil
+// for signatures over message itself.
+func (p *verifyPolicy) verifySigner(annotations map[string]string, message, sig, dssePayload []byte) (string, error) {
+	for _, k := range p.keys {
+		if verifyMessage(k.pub, message, sig) == nil {
+			return k.id, nil
+		}
+	}
+	if annotations[cosignCertAnnotation] == "" {
+		if len(p.keys) == 0 {
+			return "", errors.New("signature has no keyless certificate and no public keys are trusted")
+		}
+		return "", errors.New("signature does not verify with any trusted key")
+	}
+	if len(p.identities) == 0 {
+		return "", errors.New("keyless signature, but no keyless identities are trusted")
+	}
+	return p.verifyKeyless(annotations, message, sig, dssePayload)
+}
+
+// verifyKeyless checks a signature made with a short-lived signing
+// certificate: the chain to the keyless roots, the signer identity and,
+// with a Rekor key configured, the transparency-log entry whose time
+// the certificate must have been valid at.
+func (p *verifyPolicy) verifyKeyless(annotations map[string]string, message, sig, dssePayload []byte) (string, error) {
+	certs, err := parseCertsPEM(annotations[cosignCertAnnotation])
+	if err != nil {
+		return "", fmt.Errorf("signing certificate: %w", err)
+	}
+	cert := certs[0]
+	intermediates := x509.NewCertPool()
+	if chain := annotations[cosignChainAnnotation]; chain != "" {
+		chainCerts, err := parseCertsPEM(chain)
+		if err != nil {
+			return "", fmt.Errorf("certificate chain: %w", err)
+		}
+		for _, c := range chainCerts {
+			intermediates.AddCert(c)
+		}
+	}
+	at, err := p.verifyRekorBundle(annotations[cosignBundleAnnotation], message, sig, dssePayload)
+	if err != nil {
+		return "", err
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         p.keylessRoots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return "", fmt.Errorf("signing certificate: %w", err)
+	}
+	issuer, subject := certIdentity(cert)
+	trusted := false
+	for _, id := range p.identities {
+		if id.issuer == issuer && id.subject.MatchString(subject) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return "", fmt.Errorf("signer %s (issuer %s) is not a trusted identity", subject, issuer)
+	}
+	if err := verifyMessage(cert.PublicKey, message, sig); err != nil {
+		return "", fmt.Errorf("signature does not verify with the signing certificate: %w", err)
+	}
+	return issuer + " " + subject, nil
+}
+
+// rekorPayload is the log entry a Rekor SignedEntryTimestamp signs,
+// its fields in canonical-JSON (sorted) order.
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+type rekorHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// verifyRekorBundle checks the log entry cosign attached to a keyless
+// signature: the log's signature over it and that the entry records
+// this signature. It returns the time the entry was logged.
+func (p *verifyPolicy) verifyRekorBundle(bundle string, message, sig, dssePayload []byte) (time.Time, error) {
+	if bundle == "" {
+		return time.Time{}, errors.New("keyless signature has no transparency-log bundle")
+	}
+	var b struct {
+		SignedEntryTimestamp string       `json:"SignedEntryTimestamp"`
+		Payload              rekorPayload `json:"Payload"`
+	}
+	if err := json.Unmarshal([]byte(bundle), &b); err != nil {
+		return time.Time{}, fmt.Errorf("decode transparency-log bundle: %w", err)
+	}
+	set, err := base64.StdEncoding.DecodeString(b.SignedEntryTimestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decode SignedEntryTimestamp: %w", err)
+	}
+	canonical, _ := json.Marshal(b.Payload)
+	if err := verifyMessage(p.rekorKey, canonical, set); err != nil {
+		return time.Time{}, fmt.Errorf("transparency-log entry is not signed by the trusted log key: %w", err)
+	}
+	body, err := base64.StdEncoding.DecodeString(b.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decode transparency-log entry: %w", err)
+	}
+	var entry struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Data struct {
+				Hash rekorHash `json:"hash"`
+			} `json:"data"`
+			Signature struct {
+				Content string `json:"content"`
+			} `json:"signature"`
+			Content struct {
+				PayloadHash rekorHash `json:"payloadHash"`
+			} `json:"content"`
+			PayloadHash rekorHash `json:"payloadHash"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, fmt.Errorf("decode transparency-log entry: %w", err)
+	}
+	var want, got rekorHash
+	switch entry.Kind {
+	case "hashedrekord":
+		if dssePayload != nil {
+			return time.Time{}, errors.New("attestation is logged as a hashedrekord entry")
+		}
+		if entry.Spec.Signature.Content != base64.StdEncoding.EncodeToString(sig) {
+			return time.Time{}, errors.New("transparency-log entry records a different signature")
+		}
+		sum := sha256.Sum256(message)
+		want, got = rekorHash{"sha256", hex.EncodeToString(sum[:])}, entry.Spec.Data.Hash
+	case "intoto", "dsse":
+		if dssePayload == nil {
+			return time.Time{}, fmt.Errorf("signature is logged as a %s entry", entry.Kind)
+		}
+		sum := sha256.Sum256(dssePayload)
+		want, got = rekorHash{"sha256", hex.EncodeToString(sum[:])}, entry.Spec.PayloadHash
+		if entry.Kind == "intoto" {
+			got = entry.Spec.Content.PayloadHash
+		}
+	default:
+		return time.Time{}, fmt.Errorf("unsupported transparency-log entry kind %q", entry.Kind)
+	}
+	if got != want {
+		return time.Time{}, errors.New("transparency-log entry records a different payload")
+	}
+	return time.Unix(b.Payload.IntegratedTime, 0), nil
+}
+
+// certIdentity returns the OIDC issuer and subject (email or URI SAN)
+// of a keyless signing certificate.
+func certIdentity(cert *x509.Certificate) (issuer, subject string) {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			var s string
+			if _, err := asn1.Unmarshal(ext.Value, &s); err == nil {
+				issuer = s
+			}
+		case ext.Id.Equal(oidFulcioIssuer) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		subject = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		subject = cert.URIs[0].String()
+	}
+	return issuer, subject
+}
+
+// verifyNotation checks a Notation JWS signature envelope: the x5c
+// chain to the Notation roots, the signature and the target artifact.
+func (p *verifyPolicy) verifyNotation(l signatureLayer, digest string) (VerifiedSignature, error) {
+	if p.notationRoots == nil {
+		return VerifiedSignature{}, errors.New("Notation signatures are not trusted (SOCKERLESS_VERIFY_NOTATION_ROOTS is not set)")
+	}
+	var env struct {
+		Payload   string `json:"payload"`
+		Protected string `json:"protected"`
+		Signature string `json:"signature"`
+		Header    struct {
+			X5C []string `json:"x5c"`
+		} `json:"header"`
+	}
+	if err := json.Unmarshal(l.blob, &env); err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode JWS envelope: %w", err)
+	}
+	protected, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode JWS protected header: %w", err)
+	}
+	var hdr struct {
+		Alg    string     `json:"alg"`
+		Cty    string     `json:"cty"`
+		Crit   []string   `json:"crit"`
+		Scheme string     `json:"io.cncf.notary.signingScheme"`
+		Expiry *time.Time `json:"io.cncf.notary.expiry"`
+	}
+	if err := json.Unmarshal(protected, &hdr); err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode JWS protected header: %w", err)
+	}
+	if hdr.Cty != "application/vnd.cncf.notary.payload.v1+json" {
+		return VerifiedSignature{}, fmt.Errorf("JWS content type %q is not a Notation payload", hdr.Cty)
+	}
+	if hdr.Scheme != "notary.x509" {
+		return VerifiedSignature{}, fmt.Errorf("Notation signing scheme %q is not supported", hdr.Scheme)
+	}
+	for _, c := range hdr.Crit {
+		if c != "io.cncf.notary.signingScheme" && c != "io.cncf.notary.expiry" {
+			return VerifiedSignature{}, fmt.Errorf("critical JWS header %q is not supported", c)
+		}
+	}
+	if hdr.Expiry != nil && time.Now().After(*hdr.Expiry) {
+		return VerifiedSignature{}, fmt.Errorf("signature expired at %s", hdr.Expiry.Format(time.RFC3339))
+	}
+	if len(env.Header.X5C) == 0 {
+		return VerifiedSignature{}, errors.New("JWS envelope has no x5c certificate chain")
+	}
+	var chain []*x509.Certificate
+	for _, der := range env.Header.X5C {
+		raw, err := base64.StdEncoding.DecodeString(der)
+		if err != nil {
+			return VerifiedSignature{}, fmt.Errorf("decode x5c: %w", err)
+		}
+		c, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return VerifiedSignature{}, fmt.Errorf("parse x5c: %w", err)
+		}
+		chain = append(chain, c)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         p.notationRoots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return VerifiedSignature{}, fmt.Errorf("signing certificate: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode JWS signature: %w", err)
+	}
+	if err := verifyJWS(hdr.Alg, chain[0].PublicKey, []byte(env.Protected+"."+env.Payload), sig); err != nil {
+		return VerifiedSignature{}, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode JWS payload: %w", err)
+	}
+	var target struct {
+		TargetArtifact struct {
+			Digest string `json:"digest"`
+		} `json:"targetArtifact"`
+	}
+	if err := json.Unmarshal(payload, &target); err != nil {
+		return VerifiedSignature{}, fmt.Errorf("decode Notation payload: %w", err)
+	}
+	if target.TargetArtifact.Digest != digest {
+		return VerifiedSignature{}, fmt.Errorf("signs %s, not %s", target.TargetArtifact.Digest, digest)
+	}
+	return VerifiedSignature{Format: "notation", Signer: chain[0].Subject.String(), Digest: digest}, nil
+}
+
+// verifyMessage checks a cosign-style signature: ASN.1 ECDSA or
+// PKCS #1 v1.5 RSA over SHA-256, or Ed25519 over the message.
+func verifyMessage(pub crypto.PublicKey, message, sig []byte) error {
+	sum := sha256.Sum256(message)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, sum[:], sig) {
+			return errors.New("ECDSA signature mismatch")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, message, sig) {
+			return errors.New("Ed25519 signature mismatch")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported public key type %T", pub)
+}
+
+// verifyJWS checks a JWS signature of the PS* and ES* algorithms
+// Notation signs with.
+func verifyJWS(alg string, pub crypto.PublicKey, input, sig []byte) error {
+	var hash crypto.Hash
+	if len(alg) == 5 {
+		switch alg[2:] {
+		case "256":
+			hash = crypto.SHA256
+		case "384":
+			hash = crypto.SHA384
+		case "512":
+			hash = crypto.SHA512
+		}
+	}
+	if hash == 0 {
+		return fmt.Errorf("JWS algorithm %q is not supported", alg)
+	}
+	h := hash.New()
+	h.Write(input)
+	sum := h.Sum(nil)
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if alg[:2] != "PS" {
+			break
+		}
+		if err := rsa.VerifyPSS(k, hash, sum, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}); err != nil {
+			return fmt.Errorf("JWS signature mismatch: %w", err)
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if alg[:2] != "ES" {
+			break
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("JWS signature mismatch: bad ECDSA signature length")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, sum, r, s) {
+			return errors.New("JWS signature mismatch")
+		}
+		return nil
+	}
+	return fmt.Errorf("JWS algorithm %s does not match the %T signing key", alg, pub)
+}
+
+// parseCertsPEM parses a PEM certificate bundle.
+func parseCertsPEM(data string) ([]*x509.Certificate, error) {
+	rest := []byte(data)
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate")
+	}
+	return certs, nil
+}
@@ -0,0 +1,711 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// verifyTestRegistry is an in-memory OCI registry serving manifests,
+// blobs and, unless noReferrersAPI is set, the referrers API.
+type verifyTestRegistry struct {
+	mu             sync.Mutex
+	manifests      map[string]verifyTestManifest // repo:reference
+	blobs          map[string][]byte             // digest
+	noReferrersAPI bool
+}
+
+type verifyTestManifest struct {
+	mediaType string
+	body      []byte
+}
+
+func newVerifyTestRegistry(t *testing.T) (*verifyTestRegistry, *httptest.Server) {
+	reg := &verifyTestRegistry{manifests: map[string]verifyTestManifest{}, blobs: map[string][]byte{}}
+	srv := httptest.NewServer(reg)
+	t.Cleanup(srv.Close)
+	return reg, srv
+}
+
+func (f *verifyTestRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case strings.Contains(path, "/manifests/"):
+		repo, ref, _ := strings.Cut(path, "/manifests/")
+		m, ok := f.manifests[repo+":"+ref]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Write(m.body)
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := strings.Cut(path, "/blobs/")
+		b, ok := f.blobs[digest]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	case strings.Contains(path, "/referrers/") && !f.noReferrersAPI:
+		repo, digest, _ := strings.Cut(path, "/referrers/")
+		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+		json.NewEncoder(w).Encode(f.referrers(repo, digest))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// referrers builds the referrers index of digest; f.mu is held.
+func (f *verifyTestRegistry) referrers(repo, digest string) map[string]any {
+	manifests := []map[string]any{}
+	seen := map[string]bool{}
+	for key, m := range f.manifests {
+		name, ref, _ := strings.Cut(key, ":")
+		if name != repo || !strings.HasPrefix(ref, "sha256:") || seen[ref] {
+			continue
+		}
+		var body struct {
+			ArtifactType string `json:"artifactType"`
+			Subject      struct {
+				Digest string `json:"digest"`
+			} `json:"subject"`
+		}
+		if json.Unmarshal(m.body, &body) != nil || body.Subject.Digest != digest {
+			continue
+		}
+		seen[ref] = true
+		manifests = append(manifests, map[string]any{
+			"mediaType":    m.mediaType,
+			"digest":       ref,
+			"size":         len(m.body),
+			"artifactType": body.ArtifactType,
+		})
+	}
+	return map[string]any{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.index.v1+json", "manifests": manifests}
+}
+
+func (f *verifyTestRegistry) putBlob(data []byte) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	f.blobs[digest] = data
+	return map[string]any{"digest": digest, "size": len(data)}
+}
+
+// putManifest stores v under tag (when set) and its digest.
+func (f *verifyTestRegistry) putManifest(repo, tag, mediaType string, v any) string {
+	body, _ := json.Marshal(v)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.manifests[repo+":"+digest] = verifyTestManifest{mediaType, body}
+	if tag != "" {
+		f.manifests[repo+":"+tag] = verifyTestManifest{mediaType, body}
+	}
+	return digest
+}
+
+// putImage stores a single-layer image as repo:tag.
+func (f *verifyTestRegistry) putImage(repo, tag string) string {
+	config := f.putBlob([]byte(`{"architecture":"amd64","os":"linux"}`))
+	config["mediaType"] = "application/vnd.oci.image.config.v1+json"
+	layer := f.putBlob([]byte("layer-" + tag))
+	layer["mediaType"] = "application/vnd.oci.image.layer.v1.tar+gzip"
+	return f.putManifest(repo, tag, "application/vnd.oci.image.manifest.v1+json", map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        config,
+		"layers":        []any{layer},
+	})
+}
+
+// putArtifact stores a one-layer signature artifact. With subject set
+// it is a referrer of subject; otherwise it is tagged tag.
+func (f *verifyTestRegistry) putArtifact(repo, tag, subject, artifactType, layerType string, blob []byte, annotations map[string]string) {
+	layer := f.putBlob(blob)
+	layer["mediaType"] = layerType
+	layer["annotations"] = annotations
+	config := f.putBlob([]byte("{}"))
+	config["mediaType"] = "application/vnd.oci.empty.v1+json"
+	m := map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        config,
+		"layers":        []any{layer},
+	}
+	if artifactType != "" {
+		m["artifactType"] = artifactType
+	}
+	if subject != "" {
+		m["subject"] = map[string]any{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": subject}
+	}
+	f.putManifest(repo, tag, "application/vnd.oci.image.manifest.v1+json", m)
+}
+
+// newTestVerifier builds an ImageVerifier reading srv anonymously.
+func newTestVerifier(t *testing.T, c ImageVerifyConfig, srv *httptest.Server) *ImageVerifier {
+	t.Helper()
+	p, err := c.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ImageVerifier{
+		policy: p,
+		access: func(string) (string, string, error) { return "", srv.URL, nil },
+		arch:   "amd64",
+	}
+}
+
+// testImageRef names repo:tag on the test registry; the host only has
+// to look like a registry, requests go to the access endpoint.
+func testImageRef(repo, tag string) string {
+	return "registry.test/" + repo + ":" + tag
+}
+
+func writeTestPEM(t *testing.T, blockType string, ders ...[]byte) string {
+	t.Helper()
+	var buf []byte
+	for _, der := range ders {
+		buf = append(buf, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})...)
+	}
+	path := filepath.Join(t.TempDir(), "trust.pem")
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func publicKeyPEM(t *testing.T, pubs ...crypto.PublicKey) string {
+	t.Helper()
+	var ders [][]byte
+	for _, pub := range pubs {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ders = append(ders, der)
+	}
+	return writeTestPEM(t, "PUBLIC KEY", ders...)
+}
+
+// signCosign signs message the way cosign does for the key type.
+func signCosign(t *testing.T, key crypto.Signer, message []byte) []byte {
+	t.Helper()
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		sig, _ := key.Sign(rand.Reader, message, crypto.Hash(0))
+		return sig
+	}
+	sum := sha256.Sum256(message)
+	sig, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func cosignPayload(digest string) []byte {
+	return []byte(`{"critical":{"identity":{"docker-reference":"registry.test/app"},"image":{"docker-manifest-digest":"` + digest + `"},"type":"cosign container image signature"},"optional":null}`)
+}
+
+// putCosignSignature attaches a cosign signature of digest under the
+// sha256-<hex>.sig tag.
+func (f *verifyTestRegistry) putCosignSignature(repo, digest string, payload, sig []byte, extra map[string]string) {
+	ann := map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)}
+	for k, v := range extra {
+		ann[k] = v
+	}
+	f.putArtifact(repo, strings.Replace(digest, ":", "-", 1)+".sig", "", "", cosignSimpleSigningType, payload, ann)
+}
+
+func mustECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestVerifyImage_CosignKeys(t *testing.T) {
+	ecKey := mustECDSAKey(t)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := publicKeyPEM(t, ecKey.Public(), edKey.Public(), rsaKey.Public())
+	for _, tc := range []struct {
+		name string
+		key  crypto.Signer
+	}{{"ecdsa", ecKey}, {"ed25519", edKey}, {"rsa", rsaKey}} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg, srv := newVerifyTestRegistry(t)
+			digest := reg.putImage("app", "v1")
+			payload := cosignPayload(digest)
+			reg.putCosignSignature("app", digest, payload, signCosign(t, tc.key, payload), nil)
+
+			res, err := newTestVerifier(t, ImageVerifyConfig{KeysPath: keys}, srv).Verify(t.Context(), testImageRef("app", "v1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Verified || res.Digest != digest || len(res.Signatures) != 1 || !strings.HasPrefix(res.Signatures[0].Signer, "key sha256:") {
+				t.Fatalf("result = %+v", res)
+			}
+		})
+	}
+}
+
+func TestVerifyImage_RefusesUntrustedSignatures(t *testing.T) {
+	trusted := mustECDSAKey(t)
+	other := mustECDSAKey(t)
+	reg, srv := newVerifyTestRegistry(t)
+	v := newTestVerifier(t, ImageVerifyConfig{KeysPath: publicKeyPEM(t, trusted.Public())}, srv)
+
+	unsigned := reg.putImage("app", "unsigned")
+	res, err := v.Verify(t.Context(), testImageRef("app", "unsigned"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Verified || !strings.Contains(res.Message, "no signatures found for "+unsigned) {
+		t.Errorf("unsigned: %+v", res)
+	}
+
+	wrongKey := reg.putImage("app", "wrong-key")
+	payload := cosignPayload(wrongKey)
+	reg.putCosignSignature("app", wrongKey, payload, signCosign(t, other, payload), nil)
+	res, err = v.Verify(t.Context(), testImageRef("app", "wrong-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Verified || !strings.Contains(res.Message, "does not verify with any trusted key") {
+		t.Errorf("wrong key: %+v", res)
+	}
+
+	// A valid signature of another image copied onto this one.
+	copied := reg.putImage("app", "copied")
+	payload = cosignPayload(unsigned)
+	reg.putCosignSignature("app", copied, payload, signCosign(t, trusted, payload), nil)
+	res, err = v.Verify(t.Context(), testImageRef("app", "copied"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Verified || !strings.Contains(res.Message, "signs "+unsigned+", not "+copied) {
+		t.Errorf("copied: %+v", res)
+	}
+}
+
+// testCA issues code-signing certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	path string // PEM of cert
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key := mustECDSAKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sockerless test root"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, path: writeTestPEM(t, "CERTIFICATE", der)}
+}
+
+// issue signs a code-signing leaf for pub valid from notBefore for ten
+// minutes, like a keyless certificate.
+func (ca *testCA) issue(t *testing.T, pub crypto.PublicKey, notBefore time.Time, mutate func(*x509.Certificate)) []byte {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(10 * time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	mutate(tmpl)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, pub, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// keylessCert issues a certificate for a GitHub Actions workflow
+// identity, as Fulcio would.
+func (ca *testCA) keylessCert(t *testing.T, pub crypto.PublicKey, notBefore time.Time, workflow string) string {
+	t.Helper()
+	issuer, _ := asn1.Marshal("https://token.actions.githubusercontent.com")
+	der := ca.issue(t, pub, notBefore, func(c *x509.Certificate) {
+		u, _ := url.Parse(workflow)
+		c.URIs = []*url.URL{u}
+		c.ExtraExtensions = []pkix.Extension{{Id: oidFulcioIssuerV2, Value: issuer}}
+	})
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// rekorBundle logs a hashedrekord entry for sig over message at
+// integrated, signed with the log key.
+func rekorBundle(t *testing.T, logKey *ecdsa.PrivateKey, message, sig []byte, integrated time.Time) string {
+	t.Helper()
+	sum := sha256.Sum256(message)
+	body, _ := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"data":      map[string]any{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(sum[:])}},
+			"signature": map[string]any{"content": base64.StdEncoding.EncodeToString(sig)},
+		},
+	})
+	payload := rekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: integrated.Unix(),
+		LogID:          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+		LogIndex:       42,
+	}
+	canonical, _ := json.Marshal(payload)
+	set := signCosign(t, logKey, canonical)
+	bundle, _ := json.Marshal(map[string]any{"SignedEntryTimestamp": base64.StdEncoding.EncodeToString(set), "Payload": payload})
+	return string(bundle)
+}
+
+func TestVerifyImage_KeylessIdentity(t *testing.T) {
+	ca := newTestCA(t)
+	logKey := mustECDSAKey(t)
+	const workflow = "https://github.com/acme/app/.github/workflows/release.yml@refs/heads/main"
+	cfg := ImageVerifyConfig{
+		Identities:   []string{"https://token.actions.githubusercontent.com=https://github.com/acme/.*@refs/heads/main"},
+		KeylessRoots: ca.path,
+		RekorKey:     publicKeyPEM(t, logKey.Public()),
+	}
+
+	sign := func(reg *verifyTestRegistry, tag, workflow string, certStart, logged time.Time) {
+		digest := reg.putImage("app", tag)
+		ephemeral := mustECDSAKey(t)
+		payload := cosignPayload(digest)
+		sig := signCosign(t, ephemeral, payload)
+		reg.putCosignSignature("app", digest, payload, sig, map[string]string{
+			cosignCertAnnotation:   ca.keylessCert(t, ephemeral.Public(), certStart, workflow),
+			cosignBundleAnnotation: rekorBundle(t, logKey, payload, sig, logged),
+		})
+	}
+	reg, srv := newVerifyTestRegistry(t)
+	v := newTestVerifier(t, cfg, srv)
+
+	// The certificate expired long ago but was valid when logged.
+	signedAt := time.Now().Add(-3 * time.Hour)
+	sign(reg, "v1", workflow, signedAt, signedAt.Add(time.Minute))
+	res, err := v.Verify(t.Context(), testImageRef("app", "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Verified || res.Signatures[0].Signer != "https://token.actions.githubusercontent.com "+workflow {
+		t.Fatalf("keyless: %+v", res)
+	}
+
+	sign(reg, "fork", "https://github.com/mallory/app/.github/workflows/release.yml@refs/heads/main", signedAt, signedAt.Add(time.Minute))
+	if res, _ := v.Verify(t.Context(), testImageRef("app", "fork")); res == nil || res.Verified || !strings.Contains(res.Message, "not a trusted identity") {
+		t.Errorf("untrusted identity: %+v", res)
+	}
+
+	sign(reg, "late", workflow, signedAt, signedAt.Add(time.Hour))
+	if res, _ := v.Verify(t.Context(), testImageRef("app", "late")); res == nil || res.Verified || !strings.Contains(res.Message, "signing certificate") {
+		t.Errorf("logged after expiry: %+v", res)
+	}
+
+	// A currently valid certificate still needs a log entry.
+	digest := reg.putImage("app", "unlogged")
+	ephemeral := mustECDSAKey(t)
+	payload := cosignPayload(digest)
+	reg.putCosignSignature("app", digest, payload, signCosign(t, ephemeral, payload), map[string]string{
+		cosignCertAnnotation: ca.keylessCert(t, ephemeral.Public(), time.Now().Add(-time.Minute), workflow),
+	})
+	if res, _ := v.Verify(t.Context(), testImageRef("app", "unlogged")); res == nil || res.Verified || !strings.Contains(res.Message, "no transparency-log bundle") {
+		t.Errorf("unlogged: %+v", res)
+	}
+}
+
+// notationSignature builds a Notation JWS envelope over digest signed
+// by key with certificate chain x5c.
+func notationSignature(t *testing.T, key *ecdsa.PrivateKey, x5c [][]byte, digest string) []byte {
+	t.Helper()
+	protected, _ := json.Marshal(map[string]any{
+		"alg":                          "ES256",
+		"cty":                          "application/vnd.cncf.notary.payload.v1+json",
+		"crit":                         []string{"io.cncf.notary.signingScheme"},
+		"io.cncf.notary.signingScheme": "notary.x509",
+		"io.cncf.notary.signingTime":   time.Now().Format(time.RFC3339),
+	})
+	payload, _ := json.Marshal(map[string]any{"targetArtifact": map[string]any{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": digest, "size": 100,
+	}})
+	input := base64.RawURLEncoding.EncodeToString(protected) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	var chain []string
+	for _, der := range x5c {
+		chain = append(chain, base64.StdEncoding.EncodeToString(der))
+	}
+	env, _ := json.Marshal(map[string]any{
+		"payload":   base64.RawURLEncoding.EncodeToString(payload),
+		"protected": base64.RawURLEncoding.EncodeToString(protected),
+		"header":    map[string]any{"x5c": chain, "io.cncf.notary.signingAgent": "notation-go/1.3.0"},
+		"signature": base64.RawURLEncoding.EncodeToString(sig),
+	})
+	return env
+}
+
+func TestVerifyImage_NotationReferrer(t *testing.T) {
+	ca := newTestCA(t)
+	key := mustECDSAKey(t)
+	leaf := ca.issue(t, key.Public(), time.Now().Add(-time.Hour), func(c *x509.Certificate) {
+		c.Subject = pkix.Name{CommonName: "release signer", Organization: []string{"acme"}}
+		c.NotAfter = time.Now().Add(time.Hour)
+	})
+	for _, referrersAPI := range []bool{true, false} {
+		t.Run(fmt.Sprintf("referrersAPI=%v", referrersAPI), func(t *testing.T) {
+			reg, srv := newVerifyTestRegistry(t)
+			reg.noReferrersAPI = !referrersAPI
+			digest := reg.putImage("app", "v1")
+			reg.putArtifact("app", "", digest, notationArtifactType, notationJWSType,
+				notationSignature(t, key, [][]byte{leaf, ca.cert.Raw}, digest),
+				map[string]string{"io.cncf.notary.x509chain.thumbprint#S256": "[]"})
+			if !referrersAPI {
+				// Registries without the API keep an index of the
+				// referrers under the sha256-<hex> tag.
+				reg.mu.Lock()
+				idx, _ := json.Marshal(reg.referrers("app", digest))
+				reg.mu.Unlock()
+				reg.putManifest("app", strings.Replace(digest, ":", "-", 1), "application/vnd.oci.image.index.v1+json", json.RawMessage(idx))
+			}
+
+			res, err := newTestVerifier(t, ImageVerifyConfig{NotationRoots: ca.path}, srv).Verify(t.Context(), testImageRef("app", "v1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Verified || res.Signatures[0].Format != "notation" || res.Signatures[0].Signer != "CN=release signer,O=acme" {
+				t.Fatalf("result = %+v", res)
+			}
+
+			// The same signature is not trusted under another root.
+			res, err = newTestVerifier(t, ImageVerifyConfig{NotationRoots: newTestCA(t).path}, srv).Verify(t.Context(), testImageRef("app", "v1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Verified || !strings.Contains(res.Message, "signing certificate") {
+				t.Errorf("foreign root: %+v", res)
+			}
+		})
+	}
+}
+
+// provenanceEnvelope is a DSSE envelope of an SLSA provenance
+// statement about digest, signed by key.
+func provenanceEnvelope(t *testing.T, key crypto.Signer, predicateType, digest string) []byte {
+	t.Helper()
+	statement, _ := json.Marshal(map[string]any{
+		"_type":         "https://in-toto.io/Statement/v1",
+		"predicateType": predicateType,
+		"subject":       []any{map[string]any{"name": "registry.test/app", "digest": map[string]string{"sha256": strings.TrimPrefix(digest, "sha256:")}}},
+		"predicate":     map[string]any{"buildDefinition": map[string]any{"buildType": "https://actions.github.io/buildtypes/workflow/v1"}},
+	})
+	sig := signCosign(t, key, dssePAE("application/vnd.in-toto+json", statement))
+	env, _ := json.Marshal(map[string]any{
+		"payloadType": "application/vnd.in-toto+json",
+		"payload":     base64.StdEncoding.EncodeToString(statement),
+		"signatures":  []any{map[string]string{"keyid": "", "sig": base64.StdEncoding.EncodeToString(sig)}},
+	})
+	return env
+}
+
+func TestVerifyImage_RequiresProvenance(t *testing.T) {
+	key := mustECDSAKey(t)
+	reg, srv := newVerifyTestRegistry(t)
+	v := newTestVerifier(t, ImageVerifyConfig{KeysPath: publicKeyPEM(t, key.Public()), RequireProvenance: true}, srv)
+	digest := reg.putImage("app", "v1")
+	payload := cosignPayload(digest)
+	reg.putCosignSignature("app", digest, payload, signCosign(t, key, payload), nil)
+
+	res, err := v.Verify(t.Context(), testImageRef("app", "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Verified || !strings.Contains(res.Message, "no trusted SLSA provenance attestation") {
+		t.Fatalf("without attestation: %+v", res)
+	}
+
+	attTag := strings.Replace(digest, ":", "-", 1) + ".att"
+	reg.putArtifact("app", attTag, "", "", dsseEnvelopeType,
+		provenanceEnvelope(t, key, "https://spdx.dev/Document", digest), map[string]string{"predicateType": "https://spdx.dev/Document"})
+	if res, _ := v.Verify(t.Context(), testImageRef("app", "v1")); res == nil || res.Verified || !strings.Contains(res.Message, "not SLSA provenance") {
+		t.Fatalf("SBOM attestation: %+v", res)
+	}
+
+	reg.putArtifact("app", attTag, "", "", dsseEnvelopeType,
+		provenanceEnvelope(t, key, "https://slsa.dev/provenance/v1", digest), map[string]string{"predicateType": "https://slsa.dev/provenance/v1"})
+	res, err = v.Verify(t.Context(), testImageRef("app", "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Verified || len(res.Provenance) != 1 || res.Provenance[0].PredicateType != "https://slsa.dev/provenance/v1" {
+		t.Fatalf("with attestation: %+v", res)
+	}
+}
+
+func TestVerifyImage_SignedIndexCoversPlatformImage(t *testing.T) {
+	key := mustECDSAKey(t)
+	reg, srv := newVerifyTestRegistry(t)
+	platform := reg.putImage("app", "")
+	index := reg.putManifest("app", "multi", "application/vnd.oci.image.index.v1+json", map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.index.v1+json",
+		"manifests": []any{map[string]any{
+			"mediaType": "application/vnd.oci.image.manifest.v1+json",
+			"digest":    platform,
+			"platform":  map[string]string{"os": "linux", "architecture": "amd64"},
+		}},
+	})
+	payload := cosignPayload(index)
+	reg.putCosignSignature("app", index, payload, signCosign(t, key, payload), nil)
+
+	res, err := newTestVerifier(t, ImageVerifyConfig{KeysPath: publicKeyPEM(t, key.Public())}, srv).Verify(t.Context(), "registry.test/app@"+platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Verified {
+		t.Fatalf("platform digest alone has no signature: %+v", res)
+	}
+	res, err = newTestVerifier(t, ImageVerifyConfig{KeysPath: publicKeyPEM(t, key.Public())}, srv).Verify(t.Context(), testImageRef("app", "multi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Verified || res.Digest != index {
+		t.Fatalf("index: %+v", res)
+	}
+}
+
+func TestAdmission_RefusesUnsignedImageOnCreate(t *testing.T) {
+	key := mustECDSAKey(t)
+	reg, srv := newVerifyTestRegistry(t)
+	reg.putImage("app", "v1")
+	s := newTestServer(&testExecDriver{})
+	s.Verifier = newTestVerifier(t, ImageVerifyConfig{KeysPath: publicKeyPEM(t, key.Public())}, srv)
+
+	_, err := s.admitImage(t.Context(), testImageRef("app", "v1"), ScanEnforceCreate)
+	var forbidden *api.ForbiddenError
+	if !errors.As(err, &forbidden) || !strings.Contains(err.Error(), "refused by signature policy: no signatures found") {
+		t.Fatalf("err = %v, want ForbiddenError", err)
+	}
+	if _, err := s.admitImage(t.Context(), testImageRef("app", "v1"), ScanEnforcePull); err != nil {
+		t.Errorf("pull is not gated: err = %v", err)
+	}
+
+	srv.Close()
+	var serverErr *api.ServerError
+	if _, err := s.admitImage(t.Context(), testImageRef("app", "v1"), ScanEnforceCreate); !errors.As(err, &serverErr) {
+		t.Errorf("registry down: err = %v, want ServerError", err)
+	}
+}
+
+func TestAdmission_PinsVerifiedDigestOnCreate(t *testing.T) {
+	key := mustECDSAKey(t)
+	reg, srv := newVerifyTestRegistry(t)
+	digest := reg.putImage("app", "v1")
+	payload := cosignPayload(digest)
+	reg.putCosignSignature("app", digest, payload, signCosign(t, key, payload), nil)
+	s := newTestServer(&testExecDriver{})
+	s.Verifier = newTestVerifier(t, ImageVerifyConfig{KeysPath: publicKeyPEM(t, key.Public())}, srv)
+	s.Scanner = &fakeScanner{result: &ScanResult{ScanStatus: ScanStatusComplete}}
+	s.ScanPolicy = ScanPolicy{BlockSeverity: SeverityCritical, OnCreate: true}
+	img := api.Image{ID: "sha256:" + strings.Repeat("a", 64), Config: api.ContainerConfig{Env: []string{"A=1"}}}
+	StoreImageWithAliases(s.Store, testImageRef("app", "v1"), img)
+
+	ref, err := s.admitImage(t.Context(), testImageRef("app", "v1"), ScanEnforceCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "registry.test/app@" + digest; ref != want {
+		t.Fatalf("ref = %q, want %q", ref, want)
+	}
+	// The scan covers the pinned digest that runs, not the tag.
+	if v, ok := s.Store.ImageScans.Load(img.ID); !ok || v.(*ScanResult).ImageRef != ref {
+		t.Errorf("scanned %+v, want %s", v, ref)
+	}
+	if got, ok := s.Store.ResolveImage(ref); !ok || got.ID != img.ID {
+		t.Errorf("pinned ref resolves to %+v, %v", got, ok)
+	}
+
+	if ref, err := s.admitImage(t.Context(), testImageRef("app", "v1"), ScanEnforcePull); err != nil || ref != testImageRef("app", "v1") {
+		t.Errorf("pull: ref = %q, err = %v", ref, err)
+	}
+}
+
+func TestImageVerifyConfig_Validate(t *testing.T) {
+	key := publicKeyPEM(t, mustECDSAKey(t).Public())
+	root := newTestCA(t).path
+	for _, tc := range []struct {
+		name string
+		cfg  ImageVerifyConfig
+		ok   bool
+	}{
+		{"off", ImageVerifyConfig{}, true},
+		{"keys", ImageVerifyConfig{KeysPath: key, RequireProvenance: true}, true},
+		{"keyless", ImageVerifyConfig{Identities: []string{"https://accounts.google.com=.*@acme.com"}, KeylessRoots: root, RekorKey: key}, true},
+		{"notation", ImageVerifyConfig{NotationRoots: root}, true},
+		{"missing keys file", ImageVerifyConfig{KeysPath: key + ".missing"}, false},
+		{"certificate as key", ImageVerifyConfig{KeysPath: root}, false},
+		{"identity without roots", ImageVerifyConfig{Identities: []string{"https://accounts.google.com=dev@acme.com"}}, false},
+		{"identity without rekor key", ImageVerifyConfig{Identities: []string{"https://accounts.google.com=dev@acme.com"}, KeylessRoots: root}, false},
+		{"malformed identity", ImageVerifyConfig{Identities: []string{"dev@acme.com"}, KeylessRoots: root}, false},
+		{"bad subject regexp", ImageVerifyConfig{Identities: []string{"https://accounts.google.com=(dev"}, KeylessRoots: root}, false},
+		{"rekor without identities", ImageVerifyConfig{KeysPath: key, RekorKey: key}, false},
+		{"provenance without signer", ImageVerifyConfig{RequireProvenance: true}, false},
+	} {
+		if err := tc.cfg.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+}
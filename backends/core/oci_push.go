@@ -19,6 +19,10 @@ type OCIPushOptions struct {
 	Repository string // e.g. "myproject/myrepo/myimage"
 	Tag        string // e.g. "latest"
 	AuthToken  string // Bearer token or empty
+	// Endpoint overrides the registry's network address
+	// (scheme://host[:port]) when it differs from Registry, e.g. an
+	// operator-configured Artifact Registry endpoint.
+	Endpoint string
 	// LayerContent provides real layer data (keyed by digest).
 	LayerContent func(digest string) ([]byte, bool)
 	// ImageLayers is the ordered list of diff_ids (uncompressed layer
@@ -53,6 +57,11 @@ type OCIPushOptions struct {
 	// WorkingDir, …) that gets serialised into the OCI config blob.
 	// Optional — when nil, the config object in the manifest is empty.
 	Config *imageConfigBlob
+	// ConfigBlob, when set, is uploaded verbatim as the image config
+	// instead of one built from Architecture / OS / Config /
+	// ImageLayers. Images from `docker load` carry their original
+	// config so the pushed image keeps its ID (the blob's digest).
+	ConfigBlob []byte
 }
 
 // imageConfigBlob is the shape that gets serialised into an OCI image
@@ -65,8 +74,10 @@ type imageConfigBlob struct {
 	Env          []string            `json:"Env,omitempty"`
 	Cmd          []string            `json:"Cmd,omitempty"`
 	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
 	WorkingDir   string              `json:"WorkingDir,omitempty"`
 	Labels       map[string]string   `json:"Labels,omitempty"`
+	StopSignal   string              `json:"StopSignal,omitempty"`
 }
 
 // imageConfigFromAPI converts the stored api.ContainerConfig into the
@@ -74,7 +85,7 @@ type imageConfigBlob struct {
 // config is empty so OCIPush serialises an empty `config: {}` per the
 // OCI spec.
 func imageConfigFromAPI(c api.ContainerConfig) *imageConfigBlob {
-	if c.User == "" && len(c.Env) == 0 && len(c.Cmd) == 0 && len(c.Entrypoint) == 0 && c.WorkingDir == "" && len(c.Labels) == 0 && len(c.ExposedPorts) == 0 {
+	if c.User == "" && len(c.Env) == 0 && len(c.Cmd) == 0 && len(c.Entrypoint) == 0 && c.WorkingDir == "" && len(c.Labels) == 0 && len(c.ExposedPorts) == 0 && len(c.Volumes) == 0 && c.StopSignal == "" {
 		return nil
 	}
 	out := &imageConfigBlob{
@@ -82,8 +93,10 @@ func imageConfigFromAPI(c api.ContainerConfig) *imageConfigBlob {
 		Env:        c.Env,
 		Cmd:        c.Cmd,
 		Entrypoint: c.Entrypoint,
+		Volumes:    c.Volumes,
 		WorkingDir: c.WorkingDir,
 		Labels:     c.Labels,
+		StopSignal: c.StopSignal,
 	}
 	if len(c.ExposedPorts) > 0 {
 		out.ExposedPorts = make(map[string]struct{}, len(c.ExposedPorts))
@@ -115,42 +128,27 @@ var ociPushClient = &http.Client{
 // `rootfs.diff_ids` in the config blob is built from `ImageLayers` so
 // it matches the manifest's layer list.
 func OCIPush(opts OCIPushOptions) (*OCIPushResult, error) {
-	baseURL := fmt.Sprintf("https://%s/v2/%s", opts.Registry, opts.Repository)
+	host := "https://" + opts.Registry
+	if opts.Endpoint != "" {
+		host = strings.TrimRight(opts.Endpoint, "/")
+	}
+	baseURL := fmt.Sprintf("%s/v2/%s", host, opts.Repository)
 
 	// 1. Check registry connectivity
 	if err := ociPing(baseURL, opts.AuthToken); err != nil {
 		return nil, fmt.Errorf("registry ping failed: %w", err)
 	}
 
-	// 2. Build the OCI config blob from the caller's metadata.
-	// rootfs.diff_ids must match the layers actually uploaded
-	// below — clients use this to verify the manifest's chain-of-trust.
-	arch := opts.Architecture
-	if arch == "" {
-		arch = "amd64"
-	}
-	osName := opts.OS
-	if osName == "" {
-		osName = "linux"
-	}
-	var cfg any
-	if opts.Config != nil {
-		cfg = opts.Config
-	} else {
-		cfg = map[string]any{}
-	}
-	configJSON, err := json.Marshal(map[string]any{
-		"architecture": arch,
-		"os":           osName,
-		"created":      time.Now().UTC().Format(time.RFC3339),
-		"config":       cfg,
-		"rootfs": map[string]any{
-			"type":     "layers",
-			"diff_ids": opts.ImageLayers,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("marshal config: %w", err)
+	// 2. Build the OCI config blob from the caller's metadata, unless
+	// the caller supplied the original. rootfs.diff_ids must match the
+	// layers actually uploaded below — clients use this to verify the
+	// manifest's chain-of-trust.
+	configJSON := opts.ConfigBlob
+	if configJSON == nil {
+		var err error
+		if configJSON, err = ociConfigJSON(opts); err != nil {
+			return nil, fmt.Errorf("marshal config: %w", err)
+		}
 	}
 	configDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(configJSON))
 
@@ -259,6 +257,36 @@ func OCIPush(opts OCIPushOptions) (*OCIPushResult, error) {
 	}, nil
 }
 
+// ociConfigJSON builds the config blob OCIPush uploads when the caller
+// has no original: platform defaults, runtime config and the rootfs
+// diff_ids of ImageLayers.
+func ociConfigJSON(opts OCIPushOptions) ([]byte, error) {
+	arch := opts.Architecture
+	if arch == "" {
+		arch = "amd64"
+	}
+	osName := opts.OS
+	if osName == "" {
+		osName = "linux"
+	}
+	var cfg any
+	if opts.Config != nil {
+		cfg = opts.Config
+	} else {
+		cfg = map[string]any{}
+	}
+	return json.Marshal(map[string]any{
+		"architecture": arch,
+		"os":           osName,
+		"created":      time.Now().UTC().Format(time.RFC3339),
+		"config":       cfg,
+		"rootfs": map[string]any{
+			"type":     "layers",
+			"diff_ids": opts.ImageLayers,
+		},
+	})
+}
+
 // ociPing checks registry connectivity via GET /v2/.
 func ociPing(baseURL, authToken string) error {
 	// Use the base registry URL (/v2/) for ping, not /v2/{repo}/
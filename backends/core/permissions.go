@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Permission preflight.
+//
+// Each cloud backend declares every cloud action its selected drivers
+// call (container lifecycle, exec, storage backing, network discovery,
+// build, registry). A PermissionProber asks the cloud's own policy
+// evaluator whether the running principal holds them — AWS
+// iam:SimulatePrincipalPolicy, GCP projects.testIamPermissions, Azure
+// Microsoft.Authorization/permissions — so a missing grant shows up in
+// `sockerless check` instead of halfway through a CI job.
+
+// PermissionRequirement is one cloud permission the backend calls,
+// together with the drivers that call it and the docker verbs that
+// fail without it.
+type PermissionRequirement struct {
+	Permission string   `json:"permission"`         // cloud-native name: "ecs:RunTask", "run.jobs.run", "Microsoft.App/jobs/start/action"
+	Resource   string   `json:"resource,omitempty"` // resource the grant is probed against; empty = provider default scope
+	Drivers    []string `json:"drivers"`            // driver selections that need it: "containers", "exec", "storage efs-ephemeral", …
+	Verbs      []string `json:"verbs"`              // docker verbs that fail without it: "run", "exec", "network create", …
+}
+
+// PermissionSet accumulates PermissionRequirements. Declaring the same
+// permission (on the same resource) from several drivers merges their
+// drivers and verbs into one requirement.
+type PermissionSet struct {
+	reqs  []PermissionRequirement
+	index map[string]int
+}
+
+// Add declares that driver calls each permission on the provider's
+// default scope, and that verbs fail without it.
+func (p *PermissionSet) Add(driver string, verbs []string, permissions ...string) {
+	p.AddOn(driver, "", verbs, permissions...)
+}
+
+// AddOn is Add for permissions that are granted per resource (an IAM
+// role for iam:PassRole, a bucket for s3:PutObject).
+func (p *PermissionSet) AddOn(driver, resource string, verbs []string, permissions ...string) {
+	if p.index == nil {
+		p.index = map[string]int{}
+	}
+	for _, perm := range permissions {
+		key := perm + "\x00" + resource
+		i, ok := p.index[key]
+		if !ok {
+			i = len(p.reqs)
+			p.index[key] = i
+			p.reqs = append(p.reqs, PermissionRequirement{Permission: perm, Resource: resource})
+		}
+		r := &p.reqs[i]
+		r.Drivers = appendUnique(r.Drivers, driver)
+		for _, v := range verbs {
+			r.Verbs = appendUnique(r.Verbs, v)
+		}
+	}
+}
+
+// Requirements returns the declared requirements sorted by permission.
+func (p *PermissionSet) Requirements() []PermissionRequirement {
+	out := make([]PermissionRequirement, len(p.reqs))
+	copy(out, p.reqs)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Permission != out[j].Permission {
+			return out[i].Permission < out[j].Permission
+		}
+		return out[i].Resource < out[j].Resource
+	})
+	return out
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, e := range list {
+		if e == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
+
+// PermissionProbe is the outcome of asking the cloud about a set of
+// requirements.
+type PermissionProbe struct {
+	Principal string                  // identity the grants were evaluated for
+	Method    string                  // cloud API used: "iam:SimulatePrincipalPolicy", …
+	Denied    []PermissionRequirement // requirements the principal does not hold
+}
+
+// PermissionProber evaluates requirements against the cloud's policy
+// engine for the identity the backend runs as. It returns an error
+// when the evaluation itself fails (the probe API is unreachable or
+// refused); an evaluated-but-denied permission is not an error.
+type PermissionProber interface {
+	Probe(ctx context.Context, reqs []PermissionRequirement) (*PermissionProbe, error)
+}
+
+// PermissionPreflight is a HealthChecker that probes a backend's
+// declared permissions and reports each missing one against the
+// docker verbs it breaks.
+type PermissionPreflight struct {
+	Prober       PermissionProber
+	Requirements []PermissionRequirement
+}
+
+// NewPermissionPreflight returns a preflight over the requirements in set.
+func NewPermissionPreflight(prober PermissionProber, set *PermissionSet) *PermissionPreflight {
+	return &PermissionPreflight{Prober: prober, Requirements: set.Requirements()}
+}
+
+// RunChecks implements HealthChecker. It emits one "permissions"
+// summary result plus one failing result per denied permission.
+func (p *PermissionPreflight) RunChecks(ctx context.Context) []CheckResult {
+	probe, err := p.Prober.Probe(ctx, p.Requirements)
+	if err != nil {
+		return []CheckResult{{
+			Name:   "permissions",
+			Status: "error",
+			Detail: fmt.Sprintf("permission probe failed: %v", err),
+		}}
+	}
+	if len(probe.Denied) == 0 {
+		return []CheckResult{{
+			Name:   "permissions",
+			Status: "ok",
+			Detail: fmt.Sprintf("%d permissions granted to %s (%s)", len(p.Requirements), probe.Principal, probe.Method),
+		}}
+	}
+	results := []CheckResult{{
+		Name:   "permissions",
+		Status: "error",
+		Detail: fmt.Sprintf("%d of %d permissions missing for %s (%s)", len(probe.Denied), len(p.Requirements), probe.Principal, probe.Method),
+	}}
+	for _, d := range probe.Denied {
+		target := d.Permission
+		if d.Resource != "" {
+			target += " on " + d.Resource
+		}
+		results = append(results, CheckResult{
+			Name:       "permission " + d.Permission,
+			Status:     "error",
+			Detail:     fmt.Sprintf("%s not granted; breaks docker %s (%s)", target, strings.Join(d.Verbs, ", docker "), strings.Join(d.Drivers, ", ")),
+			Permission: d.Permission,
+			Resource:   d.Resource,
+			Verbs:      d.Verbs,
+		})
+	}
+	return results
+}
+
+// MatchPermissionPattern reports whether a policy pattern such as
+// "ecs:*", "Microsoft.App/jobs/*" or "*" grants permission. Matching
+// is case-insensitive, "*" matches any run of characters and "?" a
+// single character — the wildcard grammar IAM and Azure RBAC share.
+func MatchPermissionPattern(pattern, permission string) bool {
+	return matchWildcard(strings.ToLower(pattern), strings.ToLower(permission))
+}
+
+func matchWildcard(p, s string) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			for len(p) > 0 && p[0] == '*' {
+				p = p[1:]
+			}
+			if p == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchWildcard(p, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != p[0] {
+				return false
+			}
+		}
+		p, s = p[1:], s[1:]
+	}
+	return s == ""
+}
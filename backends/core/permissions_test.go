@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeProber struct {
+	denied map[string]bool
+	err    error
+	got    []PermissionRequirement
+}
+
+func (f *fakeProber) Probe(ctx context.Context, reqs []PermissionRequirement) (*PermissionProbe, error) {
+	f.got = reqs
+	if f.err != nil {
+		return nil, f.err
+	}
+	probe := &PermissionProbe{Principal: "arn:aws:iam::123456789012:role/ci", Method: "fake"}
+	for _, r := range reqs {
+		if f.denied[r.Permission] {
+			probe.Denied = append(probe.Denied, r)
+		}
+	}
+	return probe, nil
+}
+
+func TestPermissionSet_MergesDriversAndVerbs(t *testing.T) {
+	var set PermissionSet
+	set.Add("containers", []string{"run", "start"}, "ecs:RunTask", "ecs:DescribeTasks")
+	set.Add("exec", []string{"exec"}, "ecs:ExecuteCommand", "ecs:DescribeTasks")
+	set.AddOn("containers", "arn:aws:iam::1:role/task", []string{"run"}, "iam:PassRole")
+	set.AddOn("containers", "arn:aws:iam::1:role/exec", []string{"run"}, "iam:PassRole")
+
+	reqs := set.Requirements()
+	var names []string
+	for _, r := range reqs {
+		names = append(names, r.Permission)
+	}
+	want := []string{"ecs:DescribeTasks", "ecs:ExecuteCommand", "ecs:RunTask", "iam:PassRole", "iam:PassRole"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("permissions = %v, want %v", names, want)
+	}
+	describe := reqs[0]
+	if !reflect.DeepEqual(describe.Drivers, []string{"containers", "exec"}) {
+		t.Errorf("DescribeTasks drivers = %v", describe.Drivers)
+	}
+	if !reflect.DeepEqual(describe.Verbs, []string{"run", "start", "exec"}) {
+		t.Errorf("DescribeTasks verbs = %v", describe.Verbs)
+	}
+	if reqs[3].Resource != "arn:aws:iam::1:role/exec" || reqs[4].Resource != "arn:aws:iam::1:role/task" {
+		t.Errorf("PassRole resources = %q, %q", reqs[3].Resource, reqs[4].Resource)
+	}
+}
+
+func TestPermissionPreflight_AllGranted(t *testing.T) {
+	var set PermissionSet
+	set.Add("containers", []string{"run"}, "ecs:RunTask", "ecs:StopTask")
+	prober := &fakeProber{}
+	results := NewPermissionPreflight(prober, &set).RunChecks(context.Background())
+	if len(results) != 1 || results[0].Status != "ok" {
+		t.Fatalf("results = %+v, want one ok summary", results)
+	}
+	if !strings.Contains(results[0].Detail, "2 permissions granted") {
+		t.Errorf("detail = %q", results[0].Detail)
+	}
+	if len(prober.got) != 2 {
+		t.Errorf("prober saw %d requirements, want 2", len(prober.got))
+	}
+}
+
+func TestPermissionPreflight_ReportsMissingPerVerb(t *testing.T) {
+	var set PermissionSet
+	set.Add("containers", []string{"run"}, "ecs:RunTask")
+	set.Add("exec", []string{"exec", "cp"}, "ecs:ExecuteCommand")
+	prober := &fakeProber{denied: map[string]bool{"ecs:ExecuteCommand": true}}
+
+	results := NewPermissionPreflight(prober, &set).RunChecks(context.Background())
+	if len(results) != 2 {
+		t.Fatalf("results = %+v, want summary + one denial", results)
+	}
+	if results[0].Name != "permissions" || results[0].Status != "error" {
+		t.Errorf("summary = %+v", results[0])
+	}
+	miss := results[1]
+	if miss.Permission != "ecs:ExecuteCommand" || miss.Status != "error" {
+		t.Errorf("denial = %+v", miss)
+	}
+	if !reflect.DeepEqual(miss.Verbs, []string{"exec", "cp"}) {
+		t.Errorf("verbs = %v", miss.Verbs)
+	}
+	if !strings.Contains(miss.Detail, "docker exec, docker cp") || !strings.Contains(miss.Detail, "(exec)") {
+		t.Errorf("detail = %q", miss.Detail)
+	}
+}
+
+func TestPermissionPreflight_ProbeErrorFailsCheck(t *testing.T) {
+	var set PermissionSet
+	set.Add("containers", []string{"run"}, "run.jobs.run")
+	prober := &fakeProber{err: errors.New("AccessDenied")}
+	results := NewPermissionPreflight(prober, &set).RunChecks(context.Background())
+	if len(results) != 1 || results[0].Status != "error" || !strings.Contains(results[0].Detail, "AccessDenied") {
+		t.Fatalf("results = %+v", results)
+	}
+}
+
+func TestMatchPermissionPattern(t *testing.T) {
+	cases := []struct {
+		pattern, perm string
+		want          bool
+	}{
+		{"*", "ecs:RunTask", true},
+		{"ecs:*", "ecs:RunTask", true},
+		{"ECS:run*", "ecs:RunTask", true},
+		{"ecs:Describe*", "ecs:RunTask", false},
+		{"ecs:?unTask", "ecs:RunTask", true},
+		{"Microsoft.App/jobs/*", "Microsoft.App/jobs/start/action", true},
+		{"Microsoft.App/*/read", "Microsoft.App/jobs/read", true},
+		{"Microsoft.App/*/read", "Microsoft.App/jobs/write", false},
+		{"ecs:RunTask", "ecs:RunTasks", false},
+	}
+	for _, c := range cases {
+		if got := MatchPermissionPattern(c.pattern, c.perm); got != c.want {
+			t.Errorf("MatchPermissionPattern(%q, %q) = %v, want %v", c.pattern, c.perm, got, c.want)
+		}
+	}
+}
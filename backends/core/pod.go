@@ -4,6 +4,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/sockerless/api"
 )
 
 // PodContext tracks containers that should run in a single cloud task/job.
@@ -19,6 +21,35 @@ type PodContext struct {
 	SharedNS     []string          `json:"sharedNs,omitempty"` // default: ["ipc","net","uts"]
 	InfraID      string            `json:"infraId,omitempty"`
 	Created      string            `json:"created"`
+	// MainID is the unit member the cloud resource is tagged with once
+	// a compose project has been materialised; empty before that.
+	MainID string `json:"mainId,omitempty"`
+	// unit holds the create-time records of a materialised compose
+	// unit, main first. The cloud resource only surfaces the main
+	// container, so the others are served from here.
+	unit []api.Container
+}
+
+// UnitMember is a non-main container of a materialised compose unit.
+type UnitMember struct {
+	Container api.Container
+	MainID    string
+	// Gated is set when another member depends on this one being
+	// healthy, so the backend put a platform probe on it that gates the
+	// unit's start.
+	Gated bool
+}
+
+func newUnitMember(pod *PodContext, c api.Container) UnitMember {
+	m := UnitMember{Container: c, MainID: pod.MainID}
+	for _, deps := range ComposeUnitDependencies(pod.unit) {
+		for _, d := range deps {
+			if d.ContainerID == c.ID && d.Condition == ComposeServiceHealthy {
+				m.Gated = true
+			}
+		}
+	}
+	return m
 }
 
 // PodRegistry tracks pods with O(1) lookups by name, container ID, and network.
@@ -50,7 +81,10 @@ func (pr *PodRegistry) CreatePod(name string, labels map[string]string) *PodCont
 func (pr *PodRegistry) CreatePodWithOpts(name string, labels map[string]string, hostname string, sharedNS []string) *PodContext {
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
+	return pr.createPodLocked(name, labels, hostname, sharedNS)
+}
 
+func (pr *PodRegistry) createPodLocked(name string, labels map[string]string, hostname string, sharedNS []string) *PodContext {
 	id := GenerateID()
 	if labels == nil {
 		labels = make(map[string]string)
@@ -72,6 +106,19 @@ func (pr *PodRegistry) CreatePodWithOpts(name string, labels map[string]string,
 	return pod
 }
 
+// EnsurePod returns the pod named name, creating it with labels when it
+// does not exist. Lookup and creation happen under one lock so
+// concurrent creates of the same name share a pod.
+func (pr *PodRegistry) EnsurePod(name string, labels map[string]string) *PodContext {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if id, ok := pr.byName[name]; ok {
+		return pr.pods[id]
+	}
+	return pr.createPodLocked(name, labels, "", nil)
+}
+
 // AddContainer adds a container to the pod. Idempotent — adding the same
 // container twice is a no-op.
 func (pr *PodRegistry) AddContainer(podID, containerID string) error {
@@ -112,7 +159,23 @@ func (pr *PodRegistry) RemoveContainer(podID, containerID string) {
 			break
 		}
 	}
+	for i, c := range pod.unit {
+		if c.ID == containerID {
+			pod.unit = append(pod.unit[:i:i], pod.unit[i+1:]...)
+			break
+		}
+	}
 	delete(pr.byContainer, containerID)
+
+	// A compose project's pod exists only for its containers; the
+	// last removal (compose down) drops it.
+	if len(pod.ContainerIDs) == 0 && pod.Labels[ComposeProjectLabel] != "" {
+		if pod.NetworkName != "" {
+			delete(pr.byNetwork, pod.NetworkName)
+		}
+		delete(pr.byName, pod.Name)
+		delete(pr.pods, podID)
+	}
 }
 
 // GetPod looks up a pod by ID first, then by name.
@@ -204,6 +267,72 @@ func (pr *PodRegistry) MarkStarted(podID, containerID string) (bool, []string) {
 	return len(pod.StartedIDs) < len(pod.ContainerIDs), append([]string{}, pod.ContainerIDs...)
 }
 
+// SetUnit records that the pod has been materialised as one cloud unit
+// made of members, main first, and marks every member started.
+func (pr *PodRegistry) SetUnit(podID string, members []api.Container) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pod, ok := pr.pods[podID]
+	if !ok || len(members) == 0 {
+		return
+	}
+	pod.MainID = members[0].ID
+	pod.unit = append([]api.Container{}, members...)
+	pod.StartedIDs = pod.StartedIDs[:0]
+	for _, c := range members {
+		pod.StartedIDs = append(pod.StartedIDs, c.ID)
+	}
+	pod.Status = "running"
+}
+
+// Unit returns the members of the pod's materialised unit, main first;
+// nil before the pod is materialised.
+func (pr *PodRegistry) Unit(podID string) []api.Container {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	pod, ok := pr.pods[podID]
+	if !ok || len(pod.unit) == 0 {
+		return nil
+	}
+	return append([]api.Container{}, pod.unit...)
+}
+
+// UnitMember finds a non-main unit member by ID, name or ID prefix.
+func (pr *PodRegistry) UnitMember(ref string) (UnitMember, bool) {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	for _, pod := range pr.pods {
+		for _, c := range pod.unit {
+			if c.ID == pod.MainID {
+				continue
+			}
+			if c.ID == ref || c.Name == ref || c.Name == "/"+ref || (len(ref) >= 3 && strings.HasPrefix(c.ID, ref)) {
+				return newUnitMember(pod, c), true
+			}
+		}
+	}
+	return UnitMember{}, false
+}
+
+// UnitMembers returns every non-main member of every materialised unit.
+func (pr *PodRegistry) UnitMembers() []UnitMember {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	var result []UnitMember
+	for _, pod := range pr.pods {
+		for _, c := range pod.unit {
+			if c.ID != pod.MainID {
+				result = append(result, newUnitMember(pod, c))
+			}
+		}
+	}
+	return result
+}
+
 // ListPods returns all pods.
 func (pr *PodRegistry) ListPods() []*PodContext {
 	pr.mu.RLock()
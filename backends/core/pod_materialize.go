@@ -17,6 +17,9 @@ func (s *BaseServer) PodDeferredStart(containerID string) (shouldDefer bool, pod
 	if !inPod || len(pod.ContainerIDs) <= 1 {
 		return false, nil
 	}
+	if pod.Labels[ComposeProjectLabel] != "" {
+		return s.composeUnitStart(pod, containerID)
+	}
 
 	shouldDefer, allIDs := s.Store.Pods.MarkStarted(pod.ID, containerID)
 	if shouldDefer {
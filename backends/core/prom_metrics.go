@@ -0,0 +1,286 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Label names shared by every sockerless /metrics endpoint, bleephub
+// included, so one dashboard query works across backends.
+//
+//	backend    driver name (ecs-fargate, lambda, cloudrun-jobs, aca-jobs, ...; bleephub)
+//	method     HTTP method of a served request
+//	route      mux pattern that served it, without the method
+//	code       HTTP status code
+//	state      container state (created, running, paused, ...)
+//	service    cloud API service (AWS service ID, ARM provider, GCP API)
+//	operation  cloud API operation
+//	result     ok or error
+var (
+	promRequestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+	promWaitBuckets    = []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300, 600}
+	promImageBuckets   = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+)
+
+// containerStates are always exported so a state with no containers
+// reads 0 instead of disappearing.
+var containerStates = []string{"created", "running", "paused", "restarting", "removing", "exited", "dead"}
+
+// PromMetrics is a backend's Prometheus metric set, served on
+// GET /metrics. Its recording methods are no-ops on a nil receiver, so
+// servers built without NewBaseServer (tests) need not set one.
+type PromMetrics struct {
+	Registry *PromRegistry
+
+	requestDuration *PromHistogram
+	bootstrapWait   *PromHistogram
+	poolClaims      *PromCounter
+	buildDuration   *PromHistogram
+	pullDuration    *PromHistogram
+
+	mu            sync.Mutex
+	reverseAgents *ReverseAgentRegistry
+}
+
+func newPromMetrics(s *BaseServer) *PromMetrics {
+	r := NewPromRegistry()
+	m := &PromMetrics{Registry: r}
+	m.requestDuration = r.Histogram("sockerless_http_request_duration_seconds",
+		"Latency of requests served by the backend API, by route.",
+		promRequestBuckets, "method", "route", "code")
+	r.GaugeFunc("sockerless_containers",
+		"Containers known to the backend, by state.",
+		[]string{"state"}, func(set func(float64, ...string)) {
+			counts := map[string]int{}
+			for _, st := range containerStates {
+				counts[st] = 0
+			}
+			for _, c := range s.Store.Containers.List() {
+				counts[c.State.Status]++
+			}
+			for st, n := range counts {
+				if st != "" {
+					set(float64(n), st)
+				}
+			}
+		})
+	r.GaugeFunc("sockerless_reverse_agent_sessions",
+		"Reverse-agent WebSocket sessions currently connected.",
+		nil, func(set func(float64, ...string)) {
+			m.mu.Lock()
+			ra := m.reverseAgents
+			m.mu.Unlock()
+			if ra != nil {
+				set(float64(ra.Len()))
+			}
+		})
+	m.bootstrapWait = r.Histogram("sockerless_bootstrap_wait_seconds",
+		"Time spent waiting for a container's bootstrap to dial back its reverse agent.",
+		promWaitBuckets, "result")
+	m.poolClaims = r.Counter("sockerless_pool_claims_total",
+		"Function pool claims on container create, by result (hit or miss).",
+		"result")
+	m.buildDuration = r.Histogram("sockerless_image_build_duration_seconds",
+		"Duration of image builds, including streaming the build output.",
+		promImageBuckets, "result")
+	m.pullDuration = r.Histogram("sockerless_image_pull_duration_seconds",
+		"Duration of image pulls, including streaming the pull progress.",
+		promImageBuckets, "result")
+	return m
+}
+
+// ObservePoolClaim counts one function pool lookup on container create.
+func (m *PromMetrics) ObservePoolClaim(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.poolClaims.Inc(result)
+}
+
+// InstrumentReverseAgents exports the registry's session count and
+// bootstrap wait times. Backends with a reverse-agent registry call it
+// once after creating the registry.
+func (s *BaseServer) InstrumentReverseAgents(r *ReverseAgentRegistry) {
+	if s.Prom == nil {
+		return
+	}
+	s.Prom.mu.Lock()
+	s.Prom.reverseAgents = r
+	s.Prom.mu.Unlock()
+	r.SetWaitObserver(func(d time.Duration, err error) {
+		s.Prom.bootstrapWait.Observe(d.Seconds(), promResult(err))
+	})
+}
+
+func promResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// promRoute returns the mux pattern that served r without its method,
+// or "unmatched". Patterns keep the label bounded; raw paths carry IDs.
+func promRoute(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+	return r.Pattern
+}
+
+// PromMiddleware records request latency by route into m. It must wrap
+// the mux directly (or a handler that passes the same *http.Request
+// through) so the matched pattern is visible once the handler returns.
+func PromMiddleware(m *PromMetrics, next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		m.requestDuration.Observe(time.Since(start).Seconds(), r.Method, promRoute(r), strconv.Itoa(rec.status))
+	})
+}
+
+// timedImageStream observes an image operation's duration when its
+// output stream is closed, so the figure covers the whole pull or
+// build rather than just the call that started it.
+type timedImageStream struct {
+	io.ReadCloser
+	h     *PromHistogram
+	start time.Time
+	err   error
+	once  sync.Once
+}
+
+func (t *timedImageStream) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+	return n, err
+}
+
+func (t *timedImageStream) Close() error {
+	t.once.Do(func() {
+		t.h.Observe(time.Since(t.start).Seconds(), promResult(t.err))
+	})
+	return t.ReadCloser.Close()
+}
+
+// timePull and timeBuild wrap the result of an image pull or build.
+func (m *PromMetrics) timePull(start time.Time, rc io.ReadCloser, err error) (io.ReadCloser, error) {
+	if m == nil {
+		return rc, err
+	}
+	return timeImageOp(m.pullDuration, start, rc, err)
+}
+
+func (m *PromMetrics) timeBuild(start time.Time, rc io.ReadCloser, err error) (io.ReadCloser, error) {
+	if m == nil {
+		return rc, err
+	}
+	return timeImageOp(m.buildDuration, start, rc, err)
+}
+
+// timeImageOp observes a failed call immediately and a stream when it
+// is closed.
+func timeImageOp(h *PromHistogram, start time.Time, rc io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil {
+		h.Observe(time.Since(start).Seconds(), "error")
+		return nil, err
+	}
+	return &timedImageStream{ReadCloser: rc, h: h, start: start}, nil
+}
+
+// cloudAPIMetrics counts calls to the cloud provider. It is process-wide
+// (like the cassette recorder) because SDK clients are built before the
+// BaseServer that serves /metrics; every backend process hosts one.
+var cloudAPIMetrics = newCloudAPIMetrics()
+
+type cloudAPIRegistry struct {
+	*PromRegistry
+	calls    *PromCounter
+	errors   *PromCounter
+	duration *PromHistogram
+}
+
+func newCloudAPIMetrics() *cloudAPIRegistry {
+	r := NewPromRegistry()
+	return &cloudAPIRegistry{
+		PromRegistry: r,
+		calls: r.Counter("sockerless_cloud_api_calls_total",
+			"Cloud provider API calls, by service and operation.",
+			"service", "operation"),
+		errors: r.Counter("sockerless_cloud_api_errors_total",
+			"Cloud provider API calls that failed or returned an HTTP error status.",
+			"service", "operation"),
+		duration: r.Histogram("sockerless_cloud_api_call_duration_seconds",
+			"Duration of cloud provider API calls, including SDK retries.",
+			promRequestBuckets, "service", "operation"),
+	}
+}
+
+// ObserveCloudAPICall records one cloud API call. The per-cloud SDK
+// hooks (AWS middleware, Azure pipeline policy, GCP gRPC interceptor
+// and HTTP transport) call it; failed is true for transport errors and
+// HTTP statuses >= 400.
+func ObserveCloudAPICall(service, operation string, d time.Duration, failed bool) {
+	if service == "" {
+		service = "unknown"
+	}
+	if operation == "" {
+		operation = "unknown"
+	}
+	cloudAPIMetrics.calls.Inc(service, operation)
+	if failed {
+		cloudAPIMetrics.errors.Inc(service, operation)
+	}
+	cloudAPIMetrics.duration.Observe(d.Seconds(), service, operation)
+}
+
+// CloudAPITransport wraps an HTTP transport so every request is counted
+// as a cloud API call. classify names the service and operation from
+// the request; next nil means http.DefaultTransport.
+func CloudAPITransport(next http.RoundTripper, classify func(*http.Request) (service, operation string)) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return cloudAPITransport{next: next, classify: classify}
+}
+
+type cloudAPITransport struct {
+	next     http.RoundTripper
+	classify func(*http.Request) (string, string)
+}
+
+func (t cloudAPITransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	service, op := t.classify(req)
+	ObserveCloudAPICall(service, op, time.Since(start), err != nil || resp.StatusCode >= 400)
+	return resp, err
+}
+
+// handlePromMetrics serves GET /metrics in the Prometheus text format.
+func (s *BaseServer) handlePromMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", PromContentType)
+	if s.Prom != nil {
+		if err := s.Prom.Registry.WriteText(w, "backend", s.Desc.Driver); err != nil {
+			return
+		}
+	}
+	_ = cloudAPIMetrics.WriteText(w, "backend", s.Desc.Driver)
+}
@@ -0,0 +1,281 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PromContentType is the media type of the Prometheus text exposition
+// format (0.0.4) written by PromRegistry.WriteText. OpenMetrics scrapers
+// accept it as a fallback.
+const PromContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PromRegistry is a minimal in-process Prometheus registry: counter and
+// histogram vectors plus gauges collected at scrape time. It exists so
+// the backends can serve /metrics without a client library dependency;
+// it implements only what sockerless exports.
+type PromRegistry struct {
+	mu       sync.Mutex
+	families []promFamily
+}
+
+type promFamily interface {
+	write(w *bufio.Writer, constLabels string)
+}
+
+// NewPromRegistry creates an empty registry.
+func NewPromRegistry() *PromRegistry {
+	return &PromRegistry{}
+}
+
+func (r *PromRegistry) register(f promFamily) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families = append(r.families, f)
+}
+
+// WriteText writes every family in registration order. constLabels are
+// name/value pairs prepended to every series, e.g. "backend", "ecs".
+func (r *PromRegistry) WriteText(w io.Writer, constLabels ...string) error {
+	r.mu.Lock()
+	families := append([]promFamily(nil), r.families...)
+	r.mu.Unlock()
+
+	var cl strings.Builder
+	for i := 0; i+1 < len(constLabels); i += 2 {
+		writePromLabel(&cl, constLabels[i], constLabels[i+1])
+	}
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw, cl.String())
+	}
+	return bw.Flush()
+}
+
+// promSeriesKey joins label values into a map key. 0xff never appears
+// in valid UTF-8, so distinct value lists never collide.
+func promSeriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// PromCounter is a counter vector.
+type PromCounter struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	series     map[string]*promCounterSeries
+}
+
+type promCounterSeries struct {
+	values []string
+	v      float64
+}
+
+// Counter registers a counter vector with the given label names.
+func (r *PromRegistry) Counter(name, help string, labels ...string) *PromCounter {
+	c := &PromCounter{name: name, help: help, labels: labels, series: map[string]*promCounterSeries{}}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values.
+func (c *PromCounter) Inc(values ...string) { c.Add(1, values...) }
+
+// Add adds v to the series with the given label values. The values
+// must match the vector's label names in number and order.
+func (c *PromCounter) Add(v float64, values ...string) {
+	key := promSeriesKey(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &promCounterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.v += v
+}
+
+// Value returns the current value of one series (0 if never incremented).
+func (c *PromCounter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[promSeriesKey(values)]; ok {
+		return s.v
+	}
+	return 0
+}
+
+func (c *PromCounter) write(w *bufio.Writer, constLabels string) {
+	writePromHeader(w, c.name, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedPromKeys(c.series) {
+		s := c.series[key]
+		writePromSample(w, c.name, constLabels, c.labels, s.values, "", "", s.v)
+	}
+}
+
+// PromHistogram is a histogram vector with fixed upper bounds.
+type PromHistogram struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*promHistogramSeries
+}
+
+type promHistogramSeries struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative; last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Histogram registers a histogram vector. buckets are the ascending
+// upper bounds; +Inf is implicit.
+func (r *PromRegistry) Histogram(name, help string, buckets []float64, labels ...string) *PromHistogram {
+	h := &PromHistogram{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*promHistogramSeries{}}
+	r.register(h)
+	return h
+}
+
+// Observe records v (in the histogram's unit, seconds for durations)
+// against the series with the given label values.
+func (h *PromHistogram) Observe(v float64, values ...string) {
+	key := promSeriesKey(values)
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &promHistogramSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations of one series.
+func (h *PromHistogram) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[promSeriesKey(values)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *PromHistogram) write(w *bufio.Writer, constLabels string) {
+	writePromHeader(w, h.name, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedPromKeys(h.series) {
+		s := h.series[key]
+		var cum uint64
+		for i, le := range h.buckets {
+			cum += s.counts[i]
+			writePromSample(w, h.name+"_bucket", constLabels, h.labels, s.values, "le", formatPromFloat(le), float64(cum))
+		}
+		writePromSample(w, h.name+"_bucket", constLabels, h.labels, s.values, "le", "+Inf", float64(s.count))
+		writePromSample(w, h.name+"_sum", constLabels, h.labels, s.values, "", "", s.sum)
+		writePromSample(w, h.name+"_count", constLabels, h.labels, s.values, "", "", float64(s.count))
+	}
+}
+
+// PromGaugeFunc is a gauge vector whose samples are produced at scrape
+// time by a collect callback.
+type PromGaugeFunc struct {
+	name, help string
+	labels     []string
+	collect    func(set func(v float64, values ...string))
+}
+
+// GaugeFunc registers a gauge vector collected on every scrape. collect
+// calls set once per series; a series it doesn't set is not exported.
+func (r *PromRegistry) GaugeFunc(name, help string, labels []string, collect func(set func(v float64, values ...string))) {
+	r.register(&PromGaugeFunc{name: name, help: help, labels: labels, collect: collect})
+}
+
+func (g *PromGaugeFunc) write(w *bufio.Writer, constLabels string) {
+	writePromHeader(w, g.name, g.help, "gauge")
+	type sample struct {
+		values []string
+		v      float64
+	}
+	samples := map[string]sample{}
+	g.collect(func(v float64, values ...string) {
+		samples[promSeriesKey(values)] = sample{values: values, v: v}
+	})
+	for _, key := range sortedPromKeys(samples) {
+		s := samples[key]
+		writePromSample(w, g.name, constLabels, g.labels, s.values, "", "", s.v)
+	}
+}
+
+func sortedPromKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var promHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func writePromHeader(w *bufio.Writer, name, help, typ string) {
+	w.WriteString("# HELP " + name + " " + promHelpEscaper.Replace(help) + "\n")
+	w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func writePromLabel(b *strings.Builder, name, value string) {
+	if b.Len() > 0 {
+		b.WriteByte(',')
+	}
+	b.WriteString(name)
+	b.WriteString(`="`)
+	b.WriteString(promLabelEscaper.Replace(value))
+	b.WriteByte('"')
+}
+
+// writePromSample writes one sample line. extraName/extraValue carry the
+// histogram "le" label, which goes last.
+func writePromSample(w *bufio.Writer, name, constLabels string, labels, values []string, extraName, extraValue string, v float64) {
+	var b strings.Builder
+	b.WriteString(constLabels)
+	for i, l := range labels {
+		val := ""
+		if i < len(values) {
+			val = values[i]
+		}
+		writePromLabel(&b, l, val)
+	}
+	if extraName != "" {
+		writePromLabel(&b, extraName, extraValue)
+	}
+	w.WriteString(name)
+	if b.Len() > 0 {
+		w.WriteString("{" + b.String() + "}")
+	}
+	w.WriteString(" " + formatPromFloat(v) + "\n")
+}
+
+func formatPromFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
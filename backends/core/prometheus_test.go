@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sockerless/api"
+)
+
+func TestPromRegistryWriteText(t *testing.T) {
+	r := NewPromRegistry()
+	c := r.Counter("x_total", "Things.\nCounted.", "kind")
+	c.Inc(`a"b`)
+	c.Add(2, "plain")
+	h := r.Histogram("x_seconds", "Durations.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(3, "get")
+	r.GaugeFunc("x_open", "Open things.", nil, func(set func(float64, ...string)) { set(4) })
+
+	var b strings.Builder
+	if err := r.WriteText(&b, "backend", "ecs-fargate"); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP x_total Things.\nCounted.
+# TYPE x_total counter
+x_total{backend="ecs-fargate",kind="a\"b"} 1
+x_total{backend="ecs-fargate",kind="plain"} 2
+# HELP x_seconds Durations.
+# TYPE x_seconds histogram
+x_seconds_bucket{backend="ecs-fargate",op="get",le="0.1"} 1
+x_seconds_bucket{backend="ecs-fargate",op="get",le="1"} 2
+x_seconds_bucket{backend="ecs-fargate",op="get",le="+Inf"} 3
+x_seconds_sum{backend="ecs-fargate",op="get"} 3.55
+x_seconds_count{backend="ecs-fargate",op="get"} 3
+# HELP x_open Open things.
+# TYPE x_open gauge
+x_open{backend="ecs-fargate"} 4
+`
+	if b.String() != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestPromMetricsEndpoint(t *testing.T) {
+	s := NewBaseServer(NewStore(), BackendDescriptor{ID: "t", Name: "t", Driver: "lambda"}, zerolog.Nop())
+	s.Store.Containers.Put("c1", api.Container{ID: "c1", State: api.ContainerState{Status: "running"}})
+
+	agents := NewReverseAgentRegistry()
+	s.InstrumentReverseAgents(agents)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = agents.WaitForAgent(ctx, "c1")
+
+	s.Prom.ObservePoolClaim(true)
+	s.Prom.ObservePoolClaim(false)
+	ObserveCloudAPICall("Lambda", "Invoke", 20*time.Millisecond, false)
+	ObserveCloudAPICall("Lambda", "Invoke", 20*time.Millisecond, true)
+
+	handler := PromMiddleware(s.Prom, stripVersionPrefix(s.Mux))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1.44/containers/c1/json", nil))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != PromContentType {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		`sockerless_http_request_duration_seconds_count{backend="lambda",method="GET",route="/containers/{id}/json",code="200"} 1`,
+		`sockerless_containers{backend="lambda",state="running"} 1`,
+		`sockerless_containers{backend="lambda",state="exited"} 0`,
+		`sockerless_reverse_agent_sessions{backend="lambda"} 0`,
+		`sockerless_bootstrap_wait_seconds_count{backend="lambda",result="error"} 1`,
+		`sockerless_pool_claims_total{backend="lambda",result="hit"} 1`,
+		`sockerless_pool_claims_total{backend="lambda",result="miss"} 1`,
+		`sockerless_cloud_api_errors_total{backend="lambda",service="Lambda",operation="Invoke"} 1`,
+		"# TYPE sockerless_image_pull_duration_seconds histogram",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestTimeImageOp(t *testing.T) {
+	h := NewPromRegistry().Histogram("pull_seconds", "", promImageBuckets, "result")
+	if _, err := timeImageOp(h, time.Now(), nil, errors.New("denied")); err == nil || h.Count("error") != 1 {
+		t.Fatalf("failed pull: err %v, count %d", err, h.Count("error"))
+	}
+	rc, err := timeImageOp(h, time.Now(), nopCloser{strings.NewReader("{}")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Count("ok") != 0 {
+		t.Fatal("observed before the stream was closed")
+	}
+	_ = rc.Close()
+	_ = rc.Close()
+	if h.Count("ok") != 1 {
+		t.Errorf("ok count = %d, want 1", h.Count("ok"))
+	}
+}
+
+type nopCloser struct{ *strings.Reader }
+
+func (nopCloser) Close() error { return nil }
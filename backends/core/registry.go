@@ -3,6 +3,7 @@ package core
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -481,6 +482,8 @@ type ociContainerConfig struct {
 	User         string              `json:"User"`
 	Labels       map[string]string   `json:"Labels"`
 	ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+	Volumes      map[string]struct{} `json:"Volumes"`
+	StopSignal   string              `json:"StopSignal"`
 }
 
 type ociRootFS struct {
@@ -508,6 +511,12 @@ type manifestInfo struct {
 // getManifestInfo resolves the image manifest to get config digest, layer info,
 // and manifest digest.
 func getManifestInfo(rc registryConfig, token string) (*manifestInfo, error) {
+	return getManifestInfoForPlatform(rc, token, "linux", "amd64")
+}
+
+// getManifestInfoForPlatform is getManifestInfo with the manifest-list
+// entry selected for osName/arch instead of linux/amd64.
+func getManifestInfoForPlatform(rc registryConfig, token, osName, arch string) (*manifestInfo, error) {
 	manifestURL := registryURL(rc, "manifests", rc.Tag)
 
 	// Try manifest list first (for multi-arch images)
@@ -528,17 +537,17 @@ func getManifestInfo(rc registryConfig, token string) (*manifestInfo, error) {
 			return nil, fmt.Errorf("decode manifest list: %w", err)
 		}
 
-		// Find amd64/linux manifest. Sockerless backends (Lambda,
-		// Fargate, Cloud Run, ACA) all run linux/amd64 — picking any
-		// other platform would silently mismatch the runtime and
-		// produce confusing exec errors at run time. The previous
-		// fallback to ml.Manifests[0] silently took whatever was
-		// first (often arm64/linux on multi-arch images), and the
-		// resulting container would crash with `exec format error`.
-		// Now we return a clear error listing what was available.
+		// Find the requested platform's manifest (linux/amd64 for
+		// pulls). Picking any other platform would silently mismatch
+		// the runtime and produce confusing exec errors at run time.
+		// The previous fallback to ml.Manifests[0] silently took
+		// whatever was first (often arm64/linux on multi-arch images),
+		// and the resulting container would crash with `exec format
+		// error`. Now we return a clear error listing what was
+		// available.
 		digest := ""
 		for _, m := range ml.Manifests {
-			if m.Platform.Architecture == "amd64" && m.Platform.OS == "linux" {
+			if m.Platform.Architecture == arch && m.Platform.OS == osName {
 				digest = m.Digest
 				break
 			}
@@ -548,7 +557,7 @@ func getManifestInfo(rc registryConfig, token string) (*manifestInfo, error) {
 			for _, m := range ml.Manifests {
 				available = append(available, fmt.Sprintf("%s/%s", m.Platform.OS, m.Platform.Architecture))
 			}
-			return nil, fmt.Errorf("manifest list has no linux/amd64 entry — sockerless backends are linux/amd64; available platforms: %s", strings.Join(available, ", "))
+			return nil, fmt.Errorf("manifest list has no %s/%s entry; available platforms: %s", osName, arch, strings.Join(available, ", "))
 		}
 
 		// Fetch the platform-specific manifest
@@ -656,6 +665,22 @@ func registryURL(rc registryConfig, kind, reference string) string {
 	return fmt.Sprintf("%s/v2/%s/%s/%s", base, rc.Repository, kind, reference)
 }
 
+// registryStatusError is a registry response other than 200 OK.
+type registryStatusError struct {
+	Status int
+	URL    string
+}
+
+func (e *registryStatusError) Error() string {
+	return fmt.Sprintf("registry returned %d for %s", e.Status, e.URL)
+}
+
+// isRegistryNotFound reports whether err is a registry 404.
+func isRegistryNotFound(err error) bool {
+	var se *registryStatusError
+	return errors.As(err, &se) && se.Status == http.StatusNotFound
+}
+
 // registryGet performs an authenticated GET request to a registry endpoint.
 func registryGet(url, token string, accept []string) ([]byte, string, error) {
 	req, err := http.NewRequest("GET", url, nil)
@@ -678,7 +703,7 @@ func registryGet(url, token string, accept []string) ([]byte, string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("registry returned %d for %s", resp.StatusCode, url)
+		return nil, "", &registryStatusError{Status: resp.StatusCode, URL: url}
 	}
 
 	body, err := io.ReadAll(resp.Body)
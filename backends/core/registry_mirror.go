@@ -0,0 +1,225 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sockerless/api"
+)
+
+// RegistryMirror is one pull-through cache in the backend's cloud
+// registry: an ECR pull-through cache rule, an Artifact Registry remote
+// repository or an ACR cache rule proxying an upstream registry.
+type RegistryMirror struct {
+	Upstream   string    `json:"Upstream"`             // upstream registry host; Docker Hub is "docker.io"
+	Name       string    `json:"Name"`                 // ECR repository prefix, AR repository ID or ACR cache rule name
+	URI        string    `json:"URI"`                  // registry path mirrored images are addressed under
+	Credential string    `json:"Credential,omitempty"` // secret holding the upstream credentials
+	Managed    bool      `json:"Managed"`              // created by sockerless; only managed mirrors are pruned
+	Created    time.Time `json:"Created,omitempty"`
+}
+
+// RegistryMirrorManager creates and removes the pull-through caches a
+// backend rewrites public image references to. Implemented per cloud
+// in the shared cloud modules.
+type RegistryMirrorManager interface {
+	// Route returns the upstream registry whose mirror ref is pulled
+	// through on this backend; false when ref is used as-is.
+	Route(ref string) (upstream string, ok bool)
+	// Ensure returns the mirror of upstream, creating it and storing
+	// the upstream's credentials in the cloud secret store when it
+	// does not exist yet. created reports whether this call made it.
+	Ensure(ctx context.Context, upstream string) (mirror RegistryMirror, created bool, err error)
+	// List returns every mirror in the registry, managed or not.
+	List(ctx context.Context) ([]RegistryMirror, error)
+	// Remove deletes m and the credential secret sockerless stored
+	// for it.
+	Remove(ctx context.Context, m RegistryMirror) error
+}
+
+// RegistryMirrorConfig holds the upstream credentials sockerless
+// stores when it creates a mirror.
+type RegistryMirrorConfig struct {
+	// AuthFile is a Docker config.json whose "auths" entries hold the
+	// upstream registry credentials. Empty means mirrors are created
+	// without credentials, which only public upstreams accept.
+	AuthFile string
+}
+
+// RegistryMirrorConfigFromEnv reads SOCKERLESS_REGISTRY_MIRROR_AUTH.
+func RegistryMirrorConfigFromEnv() RegistryMirrorConfig {
+	return RegistryMirrorConfig{AuthFile: strings.TrimSpace(os.Getenv("SOCKERLESS_REGISTRY_MIRROR_AUTH"))}
+}
+
+// Validate loads the credentials file c names.
+func (c RegistryMirrorConfig) Validate() error {
+	_, err := c.Credentials()
+	return err
+}
+
+// Credentials loads the upstream credentials. Unlike LoadDockerConfig
+// a configured file that does not exist is an error.
+func (c RegistryMirrorConfig) Credentials() (*DockerConfig, error) {
+	if c.AuthFile == "" {
+		return &DockerConfig{Auths: map[string]DockerAuthEntry{}}, nil
+	}
+	if _, err := os.Stat(c.AuthFile); err != nil {
+		return nil, fmt.Errorf("SOCKERLESS_REGISTRY_MIRROR_AUTH: %w", err)
+	}
+	cfg, err := LoadDockerConfig(c.AuthFile)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKERLESS_REGISTRY_MIRROR_AUTH %s: %w", c.AuthFile, err)
+	}
+	return cfg, nil
+}
+
+// MirrorUpstream normalises a registry host to the form mirrors are
+// keyed by: every Docker Hub alias becomes "docker.io".
+func MirrorUpstream(registry string) string {
+	if registry == "" || isDockerHub(registry) {
+		return "docker.io"
+	}
+	return registry
+}
+
+// MirrorName is the resource name sockerless gives the mirror of
+// upstream: "docker-hub" for Docker Hub, else the host with dots and
+// the port separator replaced by dashes ("ghcr.io" → "ghcr-io").
+func MirrorName(upstream string) string {
+	if upstream == "docker.io" {
+		return "docker-hub"
+	}
+	return strings.NewReplacer(".", "-", ":", "-").Replace(upstream)
+}
+
+// EnsureRegistryMirror makes sure the mirror ref is pulled through exists
+// and returns the pull progress line reporting a hit (the mirror was
+// already configured) or a miss (sockerless created it just now).
+// Empty when ref does not go through a mirror on this backend.
+func (s *BaseServer) EnsureRegistryMirror(ctx context.Context, ref string) (string, error) {
+	if s.Mirrors == nil {
+		return "", nil
+	}
+	upstream, ok := s.Mirrors.Route(ref)
+	if !ok {
+		return "", nil
+	}
+	m, created, err := s.Mirrors.Ensure(ctx, upstream)
+	if err != nil {
+		if _, ok := err.(api.StatusCoder); ok {
+			return "", err
+		}
+		return "", &api.ServerError{Message: fmt.Sprintf("registry mirror for %s: %v", upstream, err)}
+	}
+	if created {
+		return fmt.Sprintf("Mirror miss: created %s for %s", m.URI, upstream), nil
+	}
+	return fmt.Sprintf("Mirror hit: %s via %s", upstream, m.URI), nil
+}
+
+// WithPullStatus prepends a status line to a pull progress stream.
+func WithPullStatus(status string, rc io.ReadCloser) io.ReadCloser {
+	if status == "" {
+		return rc
+	}
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(map[string]any{"status": status})
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(&buf, rc), rc}
+}
+
+// PruneRegistryMirrors removes the managed mirrors nothing routes
+// through: no image in the store, and no container the cloud reports
+// for any instance of this backend, whether by its upstream reference
+// or by the mirror's own URI. Without cloud state a mirror cannot be
+// shown unused, so pruning is refused; all removes every managed
+// mirror regardless.
+func (s *BaseServer) PruneRegistryMirrors(ctx context.Context, all bool) ([]RegistryMirror, error) {
+	if s.Mirrors == nil {
+		return nil, errNoRegistryMirrors
+	}
+	mirrors, err := s.Mirrors.List(ctx)
+	if err != nil {
+		return nil, &api.ServerError{Message: fmt.Sprintf("list registry mirrors: %v", err)}
+	}
+	inUse := map[string]bool{}
+	if !all {
+		if s.CloudState == nil {
+			return nil, &api.ConflictError{Message: "cannot tell which registry mirrors are in use without the backend's cloud state; prune with all=1 to remove every managed mirror"}
+		}
+		containers, err := s.CloudState.ListContainers(ctx, true, nil)
+		if err != nil {
+			return nil, &api.ServerError{Message: fmt.Sprintf("list containers: %v", err)}
+		}
+		var refs []string
+		for _, img := range s.Store.Images.List() {
+			refs = append(refs, img.RepoTags...)
+		}
+		for _, c := range append(containers, s.Store.Containers.List()...) {
+			refs = append(refs, c.Image, c.Config.Image)
+		}
+		for _, ref := range refs {
+			if ref == "" {
+				continue
+			}
+			if upstream, ok := s.Mirrors.Route(ref); ok {
+				inUse[upstream] = true
+			}
+			for _, m := range mirrors {
+				if m.URI != "" && strings.HasPrefix(ref, m.URI+"/") {
+					inUse[m.Upstream] = true
+				}
+			}
+		}
+	}
+	removed := []RegistryMirror{}
+	for _, m := range mirrors {
+		if !m.Managed || inUse[m.Upstream] {
+			continue
+		}
+		if err := s.Mirrors.Remove(ctx, m); err != nil {
+			return removed, &api.ServerError{Message: fmt.Sprintf("remove registry mirror %s: %v", m.Name, err)}
+		}
+		s.Logger.Info().Str("mirror", m.Name).Str("upstream", m.Upstream).Msg("pruned registry mirror")
+		removed = append(removed, m)
+	}
+	return removed, nil
+}
+
+var errNoRegistryMirrors = &api.NotImplementedError{Message: "registry mirrors are not managed by this backend"}
+
+// handleRegistryMirrorList serves GET /internal/v1/registry/mirrors.
+func (s *BaseServer) handleRegistryMirrorList(w http.ResponseWriter, r *http.Request) {
+	if s.Mirrors == nil {
+		WriteError(w, errNoRegistryMirrors)
+		return
+	}
+	mirrors, err := s.Mirrors.List(r.Context())
+	if err != nil {
+		WriteError(w, &api.ServerError{Message: fmt.Sprintf("list registry mirrors: %v", err)})
+		return
+	}
+	if mirrors == nil {
+		mirrors = []RegistryMirror{}
+	}
+	WriteJSON(w, http.StatusOK, mirrors)
+}
+
+// handleRegistryMirrorPrune serves POST /internal/v1/registry/mirrors/prune[?all=1].
+func (s *BaseServer) handleRegistryMirrorPrune(w http.ResponseWriter, r *http.Request) {
+	removed, err := s.PruneRegistryMirrors(r.Context(), r.URL.Query().Get("all") == "1")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"removed": removed})
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/api"
+)
+
+// fakeMirrors routes every ref on a registry host through a mirror
+// named after it, recording creations and removals.
+type fakeMirrors struct {
+	mirrors   map[string]RegistryMirror
+	removed   []string
+	ensureErr error
+}
+
+func (f *fakeMirrors) Route(ref string) (string, bool) {
+	rc := parseImageRef(ref)
+	if rc.Registry == "mirror.cloud" {
+		return "", false
+	}
+	return MirrorUpstream(rc.Registry), true
+}
+
+func (f *fakeMirrors) Ensure(_ context.Context, upstream string) (RegistryMirror, bool, error) {
+	if f.ensureErr != nil {
+		return RegistryMirror{}, false, f.ensureErr
+	}
+	if m, ok := f.mirrors[upstream]; ok {
+		return m, false, nil
+	}
+	m := RegistryMirror{Upstream: upstream, Name: MirrorName(upstream), URI: "mirror.cloud/" + MirrorName(upstream), Managed: true}
+	f.mirrors[upstream] = m
+	return m, true, nil
+}
+
+func (f *fakeMirrors) List(context.Context) ([]RegistryMirror, error) {
+	var out []RegistryMirror
+	for _, m := range f.mirrors {
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func (f *fakeMirrors) Remove(_ context.Context, m RegistryMirror) error {
+	delete(f.mirrors, m.Upstream)
+	f.removed = append(f.removed, m.Name)
+	return nil
+}
+
+func TestEnsureRegistryMirror_ReportsHitAndMiss(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	if status, err := s.EnsureRegistryMirror(t.Context(), "alpine"); err != nil || status != "" {
+		t.Fatalf("no manager: status %q err %v", status, err)
+	}
+	s.Mirrors = &fakeMirrors{mirrors: map[string]RegistryMirror{}}
+
+	status, err := s.EnsureRegistryMirror(t.Context(), "myorg/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "Mirror miss: created mirror.cloud/docker-hub for docker.io" {
+		t.Errorf("first pull: %q", status)
+	}
+	status, err = s.EnsureRegistryMirror(t.Context(), "docker.io/library/alpine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "Mirror hit: docker.io via mirror.cloud/docker-hub" {
+		t.Errorf("second pull: %q", status)
+	}
+	if status, _ := s.EnsureRegistryMirror(t.Context(), "mirror.cloud/docker-hub/alpine"); status != "" {
+		t.Errorf("unrouted ref: %q", status)
+	}
+}
+
+func TestImageManagerPull_ReportsMirrorFailureAndPulls(t *testing.T) {
+	const ref = "ghcr.io/org/tool:1"
+	imageMetadataCache.Lock()
+	imageMetadataCache.m[ref] = &ImageMetadataResult{Config: &api.ContainerConfig{Cmd: []string{"tool"}}, ConfigDigest: "sha256:toolcfg"}
+	imageMetadataCache.Unlock()
+	t.Cleanup(func() {
+		imageMetadataCache.Lock()
+		delete(imageMetadataCache.m, ref)
+		imageMetadataCache.Unlock()
+	})
+
+	s := newTestServer(&testExecDriver{})
+	s.Mirrors = &fakeMirrors{mirrors: map[string]RegistryMirror{}, ensureErr: errors.New("AccessDenied")}
+	m := &ImageManager{Base: s}
+	rc, err := m.Pull(ref, "")
+	if err != nil {
+		t.Fatalf("pull must not fail on a mirror error: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	first := strings.SplitN(string(data), "\n", 2)[0]
+	if !strings.Contains(first, "Mirror unavailable: registry mirror for ghcr.io: AccessDenied") {
+		t.Errorf("first progress line = %q", first)
+	}
+	if _, ok := s.Store.ResolveImage(ref); !ok {
+		t.Error("image not in the store after the pull")
+	}
+}
+
+func TestWithPullStatus_PrependsStatusLine(t *testing.T) {
+	rc := WithPullStatus("Mirror hit: docker.io via m", io.NopCloser(strings.NewReader(`{"status":"Pulling from alpine"}`+"\n")))
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[0] != `{"status":"Mirror hit: docker.io via m"}` {
+		t.Errorf("stream = %q", data)
+	}
+}
+
+func TestPruneRegistryMirrors_KeepsInUseAndUnmanaged(t *testing.T) {
+	s := newTestServer(&testExecDriver{})
+	f := &fakeMirrors{mirrors: map[string]RegistryMirror{
+		"docker.io":       {Upstream: "docker.io", Name: "docker-hub", URI: "mirror.cloud/docker-hub", Managed: true},
+		"ghcr.io":         {Upstream: "ghcr.io", Name: "ghcr-io", URI: "mirror.cloud/ghcr-io", Managed: true},
+		"quay.io":         {Upstream: "quay.io", Name: "quay-io", URI: "mirror.cloud/quay-io", Managed: true},
+		"registry.k8s.io": {Upstream: "registry.k8s.io", Name: "registry-k8s-io", URI: "mirror.cloud/registry-k8s-io", Managed: true},
+		"gcr.io":          {Upstream: "gcr.io", Name: "operator-gcr"},
+	}}
+	s.Mirrors = f
+	s.Store.Images.Put("sha256:a", api.Image{ID: "sha256:a", RepoTags: []string{"alpine:latest"}})
+
+	var conflict *api.ConflictError
+	if _, err := s.PruneRegistryMirrors(t.Context(), false); !errors.As(err, &conflict) {
+		t.Fatalf("without cloud state: err = %v, want ConflictError", err)
+	}
+	if len(f.removed) != 0 {
+		t.Fatalf("removed %v without cloud state", f.removed)
+	}
+
+	// Containers of every instance, as the cloud reports them: one by
+	// its upstream reference, one by the mirror URI it was rewritten to.
+	s.CloudState = &mockCloudState{containers: []api.Container{
+		{ID: "c1", Config: api.ContainerConfig{Image: "quay.io/org/tool:1"}},
+		{ID: "c2", Image: "mirror.cloud/ghcr-io/org/app:2"},
+	}}
+	removed, err := s.PruneRegistryMirrors(t.Context(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Name != "registry-k8s-io" {
+		t.Errorf("removed = %+v, want registry-k8s-io only", removed)
+	}
+
+	removed, err = s.PruneRegistryMirrors(t.Context(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 3 {
+		t.Errorf("prune all removed %+v, want the three remaining managed mirrors", removed)
+	}
+	if _, ok := f.mirrors["gcr.io"]; !ok {
+		t.Error("unmanaged mirror was pruned")
+	}
+}
+
+func TestRegistryMirrorEndpoints(t *testing.T) {
+	s := newTestBaseServer()
+	rec := httptest.NewRecorder()
+	s.Mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/internal/v1/registry/mirrors", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("no manager: status %d", rec.Code)
+	}
+
+	s.Mirrors = &fakeMirrors{mirrors: map[string]RegistryMirror{
+		"ghcr.io": {Upstream: "ghcr.io", Name: "ghcr-io", URI: "mirror.cloud/ghcr-io", Managed: true},
+	}}
+	rec = httptest.NewRecorder()
+	s.Mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/internal/v1/registry/mirrors", nil))
+	var mirrors []RegistryMirror
+	if err := json.Unmarshal(rec.Body.Bytes(), &mirrors); err != nil || len(mirrors) != 1 || mirrors[0].URI != "mirror.cloud/ghcr-io" {
+		t.Fatalf("list: %d %s", rec.Code, rec.Body)
+	}
+
+	s.CloudState = &mockCloudState{}
+	rec = httptest.NewRecorder()
+	s.Mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/internal/v1/registry/mirrors/prune", nil))
+	var resp struct{ Removed []RegistryMirror }
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Removed) != 1 {
+		t.Fatalf("prune: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRegistryMirrorConfig_Credentials(t *testing.T) {
+	if _, err := (RegistryMirrorConfig{AuthFile: filepath.Join(t.TempDir(), "missing.json")}).Credentials(); err == nil {
+		t.Error("missing auth file accepted")
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpwYXQ="}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := RegistryMirrorConfig{AuthFile: path}.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, pass, ok := cfg.GetRegistryAuth(MirrorUpstream("registry-1.docker.io")); !ok || user != "user" || pass != "pat" {
+		t.Errorf("docker hub credentials = %q %q %v", user, pass, ok)
+	}
+}
@@ -32,6 +32,10 @@ func (s *BaseServer) ResolveContainerAuto(ctx context.Context, ref string) (api.
 		if err == nil && ok {
 			return c, true
 		}
+		// Compose unit members the cloud resource does not surface
+		if m, ok := s.Store.Pods.UnitMember(ref); ok {
+			return s.composeMemberView(ctx, m), true
+		}
 	}
 
 	// Legacy Store path (for Docker passthrough backend and tests without CloudState)
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -70,6 +71,27 @@ func (rr *ResourceRegistry) Get(resourceID string) (ResourceEntry, bool) {
 	return ResourceEntry{}, false
 }
 
+// ContainerFor returns the container that owns resource, or "". The
+// resource may be the registered ID or an ARN / path ending in it (or
+// the reverse), since cloud APIs name the same resource both ways.
+// A nil registry owns nothing.
+func (rr *ResourceRegistry) ContainerFor(resource string) string {
+	if rr == nil || resource == "" {
+		return ""
+	}
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	if e, ok := rr.entries[resource]; ok {
+		return e.ContainerID
+	}
+	for id, e := range rr.entries {
+		if strings.HasSuffix(resource, "/"+id) || strings.HasSuffix(id, "/"+resource) {
+			return e.ContainerID
+		}
+	}
+	return ""
+}
+
 // IsCleanedUp reports whether the given resource is marked cleaned up
 // in the registry. Returns false when the resource is unknown.
 func (rr *ResourceRegistry) IsCleanedUp(resourceID string) bool {
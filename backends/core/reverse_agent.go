@@ -37,6 +37,9 @@ type ReverseAgentRegistry struct {
 	// the operator sees an actionable error instead of a generic
 	// timeout or 500 (BUG-1053).
 	lifetimeExpired map[string]struct{}
+	// waitObserver, when set, is told how long each WaitForAgent call
+	// that had to wait for the bootstrap took (see SetWaitObserver).
+	waitObserver func(time.Duration, error)
 }
 
 // NewReverseAgentRegistry creates an empty registry.
@@ -48,6 +51,23 @@ func NewReverseAgentRegistry() *ReverseAgentRegistry {
 	}
 }
 
+// SetWaitObserver registers fn to be called whenever WaitForAgent
+// returns after waiting for a session that wasn't registered yet, with
+// the time waited and the context error if it gave up. Calls that find
+// the session already connected are not observed.
+func (r *ReverseAgentRegistry) SetWaitObserver(fn func(time.Duration, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.waitObserver = fn
+}
+
+// Len returns the number of connected reverse-agent sessions.
+func (r *ReverseAgentRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.sessions)
+}
+
 // MarkLifetimeExpired records that the in-container bootstrap for
 // `id` is about to be torn down by its FaaS platform (Lambda 15min,
 // GCF Gen2 60min, etc.). Called by HandleReverseAgentWS when the
@@ -104,10 +124,15 @@ func (r *ReverseAgentRegistry) WaitForAgent(ctx context.Context, id string) erro
 	}
 	ch := make(chan struct{})
 	r.waiters[id] = append(r.waiters[id], ch)
+	observe := r.waitObserver
 	r.mu.Unlock()
 
+	start := time.Now()
 	select {
 	case <-ch:
+		if observe != nil {
+			observe(time.Since(start), nil)
+		}
 		return nil
 	case <-ctx.Done():
 		// Remove this specific waiter channel; other waiters for the
@@ -125,6 +150,9 @@ func (r *ReverseAgentRegistry) WaitForAgent(ctx context.Context, id string) erro
 			delete(r.waiters, id)
 		}
 		r.mu.Unlock()
+		if observe != nil {
+			observe(time.Since(start), ctx.Err())
+		}
 		return ctx.Err()
 	}
 }
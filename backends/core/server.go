@@ -54,6 +54,7 @@ type BaseServer struct {
 	Registry         *ResourceRegistry
 	StartedAt        time.Time
 	Metrics          *Metrics
+	Prom             *PromMetrics // Prometheus metrics served on GET /metrics
 	HealthChecker    HealthChecker
 	EventBus         *EventBus
 	ProviderInfo     *ProviderInfo
@@ -63,6 +64,14 @@ type BaseServer struct {
 	NetworkDiscovery NetworkDiscoveryDriver     // name → reachable peer (defaults to NoOp/nat-gateway-only when unset)
 	DNS              DNSDriver                  // workload resolver config (defaults to NoOp/none when unset)
 	Access           AccessDriver               // ingress auth + caller-side signer (defaults to NoneInternal when unset)
+	Scanner          ImageScanner               // vulnerability scanner (nil = scanning not configured)
+	ScanPolicy       ScanPolicy                 // admission policy applied to create / pull (zero = admit all)
+	Verifier         *ImageVerifier             // image signature trust policy applied to create (nil = verification off)
+	Mirrors          RegistryMirrorManager      // pull-through caches images are rewritten to (nil = backend has no registry mirrors)
+	ComposeUnits     bool                       // group each compose project into one multi-container cloud unit
+	Costs            *CostTracker               // per-run cost accounting and budgets (nil = not configured)
+	CostShaper       CostShaper                 // billed workload shape of a container (set with Costs)
+	HA               *HACoordinator             // multi-instance leases and leader election (nil = single instance)
 	self             api.Backend                // virtual dispatch target for overrideable methods
 }
 
@@ -115,6 +124,7 @@ func NewBaseServer(store *Store, desc BackendDescriptor, logger zerolog.Logger)
 		DNS:              NoOpDNS{},
 		Access:           NoneInternalAccess{},
 	}
+	s.Prom = newPromMetrics(s)
 	s.self = s
 	s.InitDrivers()
 	store.RestartHook = s.handleRestartPolicy
@@ -227,14 +237,18 @@ func (s *BaseServer) registerRoutes() {
 	s.Mux.HandleFunc("GET /internal/v1/status", s.handleMgmtStatus)
 	s.Mux.HandleFunc("GET /internal/v1/containers/summary", s.handleContainerSummary)
 	s.Mux.HandleFunc("GET /internal/v1/metrics", s.handleMetrics)
+	s.Mux.HandleFunc("GET /metrics", s.handlePromMetrics)
 	s.Mux.HandleFunc("GET /internal/v1/check", s.handleCheck)
 	s.Mux.HandleFunc("GET /internal/v1/provider", s.handleMgmtProvider)
 	s.Mux.HandleFunc("POST /internal/v1/reload", s.handleReload)
+	s.Mux.HandleFunc("GET /internal/v1/costs", s.handleCosts)
+	s.Mux.HandleFunc("GET /internal/v1/ha", s.handleHAStatus)
 
 	// Resource registry
 	s.Mux.HandleFunc("GET /internal/v1/resources", s.handleResourceList)
 	s.Mux.HandleFunc("GET /internal/v1/resources/orphaned", s.handleResourceOrphaned)
 	s.Mux.HandleFunc("POST /internal/v1/resources/cleanup", s.handleResourceCleanup)
+	s.Mux.HandleFunc("GET /internal/v1/resources/history", s.handleResourceHistory)
 	// Podman Libpod pod API
 	s.Mux.HandleFunc("POST /internal/v1/libpod/pods/create", s.handlePodCreate)
 	s.Mux.HandleFunc("GET /internal/v1/libpod/pods/json", s.handlePodList)
@@ -317,8 +331,15 @@ func (s *BaseServer) registerRoutes() {
 	s.Mux.HandleFunc("GET /internal/v1/images/{name}/get", s.handleImageSave)
 	s.Mux.HandleFunc("GET /internal/v1/images/search", s.handleImageSearch)
 	s.Mux.HandleFunc("POST /internal/v1/images/prune", s.handleImagePrune)
+	s.Mux.HandleFunc("GET /internal/v1/images/scan", s.handleImageScan)
+	s.Mux.HandleFunc("POST /internal/v1/images/scan", s.handleImageScan)
+	s.Mux.HandleFunc("GET /internal/v1/images/verify", s.handleImageVerify)
+	s.Mux.HandleFunc("GET /internal/v1/registry/mirrors", s.handleRegistryMirrorList)
+	s.Mux.HandleFunc("POST /internal/v1/registry/mirrors/prune", s.handleRegistryMirrorPrune)
 
 	s.Mux.HandleFunc("POST /internal/v1/commit", s.handleContainerCommit)
+	s.Mux.HandleFunc("POST /internal/v1/containers/{id}/checkpoint", s.handleContainerCheckpoint)
+	s.Mux.HandleFunc("POST /internal/v1/containers/migrate", s.handleContainerMigrate)
 
 	// System
 	s.Mux.HandleFunc("GET /internal/v1/events", s.handleSystemEvents)
@@ -417,11 +438,17 @@ func (s *BaseServer) RegisterUI(fsys fs.FS) {
 }
 
 // RecoverRegistry loads persisted registry state and scans the cloud for orphaned resources.
+// With HA configured it also takes over the containers of dead instances.
 func (s *BaseServer) RecoverRegistry(ctx context.Context, scanner CloudScanner) error {
 	s.Logger.Info().Msg("recovering resource registry")
 	if err := RecoverOnStartup(ctx, s.Registry, scanner, s.Desc.InstanceID); err != nil {
 		return fmt.Errorf("registry recovery failed: %w", err)
 	}
+	if s.HA != nil {
+		// Joins the group and takes over the containers of instances
+		// that died; keeps running for the backend's lifetime.
+		s.HA.start(ctx, scanner)
+	}
 	active := s.Registry.ListActive()
 	recovered := ReconstructContainerState(s.Store, s.Registry)
 	s.Logger.Info().
@@ -432,7 +459,7 @@ func (s *BaseServer) RecoverRegistry(ctx context.Context, scanner CloudScanner)
 }
 
 // LoggingMiddleware logs HTTP requests at Info level with method, path, status, and duration.
-// Skips known noise paths (heartbeats, ping, version, info) to keep the signal high.
+// Skips known noise paths (heartbeats, ping, version, info, metrics scrapes) to keep the signal high.
 //
 // Logs on REQUEST ENTRY too — hijacked connections (POST /containers/{id}/attach,
 // POST /exec/{id}/start) take over the TCP stream and may never fire the
@@ -442,7 +469,7 @@ func (s *BaseServer) RecoverRegistry(ctx context.Context, scanner CloudScanner)
 func LoggingMiddleware(logger zerolog.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
-		isNoise := path == "/_ping" || path == "/libpod/_ping" || path == "/version" || path == "/libpod/version" || path == "/info"
+		isNoise := path == "/_ping" || path == "/libpod/_ping" || path == "/version" || path == "/libpod/version" || path == "/info" || path == "/metrics"
 		if !isNoise {
 			logger.Info().
 				Str("method", r.Method).
@@ -497,8 +524,8 @@ func (s *BaseServer) ListenAndServe(addr, certFile, keyFile string) error {
 		s.Logger.Info().Int("active_resources", len(active)).Msg("active resource registry entries (in-memory; populated by cloud scan on RecoverOnStartup)")
 	}
 
-	wrapped := stripVersionPrefix(s.Mux)
-	handler := otelhttp.NewHandler(LoggingMiddleware(s.Logger, MetricsMiddleware(s.Metrics, wrapped)), "sockerless-backend")
+	wrapped := PromMiddleware(s.Prom, stripVersionPrefix(s.Mux))
+	handler := otelhttp.NewHandler(LoggingMiddleware(s.Logger, MetricsMiddleware(s.Metrics, s.auditMiddleware(s.haMiddleware(wrapped)))), "sockerless-backend")
 
 	if strings.HasPrefix(addr, "/") {
 		os.Remove(addr)
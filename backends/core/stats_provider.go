@@ -1,10 +1,24 @@
 package core
 
+import (
+	"sync"
+	"time"
+)
+
 // ContainerMetrics holds real resource usage metrics for a container.
 type ContainerMetrics struct {
-	CPUNanos int64 // Cumulative CPU usage in nanoseconds
-	MemBytes int64 // Current memory usage in bytes
-	PIDs     int   // Number of running processes
+	CPUNanos int64   // Cumulative CPU usage in nanoseconds
+	CPUCores float64 // Average cores in use over the source's period, for rate-only sources
+	MemBytes int64   // Current memory usage in bytes
+	PIDs     int     // Number of running processes
+
+	// SampledAt is when the source measured the values — the end of
+	// the metric period for cloud monitoring. Zero means the source
+	// has no datapoint yet.
+	SampledAt time.Time
+	// Source names where the values came from ("agent",
+	// "cloudwatch", "cloud-monitoring", "azure-monitor").
+	Source string
 }
 
 // StatsProvider fetches real resource metrics for a container.
@@ -14,3 +28,50 @@ type ContainerMetrics struct {
 type StatsProvider interface {
 	ContainerMetrics(containerID string) (*ContainerMetrics, error)
 }
+
+// DefaultCloudMetricsRefresh is how long StatsSources reuses a cloud
+// monitoring answer. Cloud metrics are published once a minute, so
+// querying for every streamed frame only burns API quota.
+const DefaultCloudMetricsRefresh = 30 * time.Second
+
+// StatsSources is the StatsProvider cloud backends install: cgroup
+// counters sampled through the reverse agent when one is connected,
+// otherwise the backend's cloud monitoring query, cached for
+// CloudRefresh (DefaultCloudMetricsRefresh when zero).
+type StatsSources struct {
+	Agents       *ReverseAgentRegistry
+	Cloud        StatsProvider
+	CloudRefresh time.Duration
+
+	cache sync.Map // containerID → cloudMetricsEntry
+}
+
+type cloudMetricsEntry struct {
+	metrics *ContainerMetrics
+	err     error
+	fetched time.Time
+}
+
+// ContainerMetrics implements StatsProvider.
+func (p *StatsSources) ContainerMetrics(containerID string) (*ContainerMetrics, error) {
+	if p.Agents != nil {
+		if _, ok := p.Agents.Resolve(containerID); ok {
+			return RunContainerStatsViaAgent(p.Agents, containerID)
+		}
+	}
+	if p.Cloud == nil {
+		return nil, nil
+	}
+	refresh := p.CloudRefresh
+	if refresh <= 0 {
+		refresh = DefaultCloudMetricsRefresh
+	}
+	if v, ok := p.cache.Load(containerID); ok {
+		if e := v.(cloudMetricsEntry); time.Since(e.fetched) < refresh {
+			return e.metrics, e.err
+		}
+	}
+	m, err := p.Cloud.ContainerMetrics(containerID)
+	p.cache.Store(containerID, cloudMetricsEntry{metrics: m, err: err, fetched: time.Now()})
+	return m, err
+}
@@ -178,7 +178,7 @@ type Store struct {
 	HealthChecks      sync.Map // containerID → context.CancelFunc
 	BuildContexts     sync.Map // imageID → string (temp dir with COPY files at destination paths)
 	TmpfsDirs         sync.Map // containerID → []string (tmpfs temp dir paths)
-	PrevCPUStats      sync.Map // containerID → *prevCPUStats
+	PrevCPUStats      sync.Map // containerID → *statsReading
 	ImageHistory      sync.Map // imageID → []ImageHistoryItem (real build history)
 	LayerContent      sync.Map // layerDigest → []byte (preserved layer tarballs from docker load)
 	// ImageManifestLayers tracks the source registry's manifest layer
@@ -191,10 +191,22 @@ type Store struct {
 	// LayerContent (which holds the blob bytes keyed by the same
 	// compressed digest).
 	ImageManifestLayers sync.Map // imageID → []ManifestLayerEntry
-	IPAlloc             *IPAllocator
-	RenameMu            sync.Mutex
-	RestartHook         func(containerID string, exitCode int) bool
-	pidCounter          atomic.Int64 // incrementing PID counter
+	// ImageConfigBlobs holds the original config blob of images read
+	// from a `docker load` archive or built by `docker import`. The
+	// image ID is that blob's digest, so ImagePush uploads it verbatim
+	// rather than re-serialising the config.
+	ImageConfigBlobs sync.Map // imageID → []byte
+	// PublishedImages records the cloud-registry reference that
+	// ImageManager.Load / Import pushed each image to. Backends run
+	// that reference when a container is created from the image.
+	PublishedImages sync.Map // imageID → string
+	// ImageScans caches the last vulnerability scan of each image,
+	// keyed by image ID (or by reference for images not held locally).
+	ImageScans  sync.Map // imageID → *ScanResult
+	IPAlloc     *IPAllocator
+	RenameMu    sync.Mutex
+	RestartHook func(containerID string, exitCode int) bool
+	pidCounter  atomic.Int64 // incrementing PID counter
 }
 
 // InvocationResult captures the outcome of a single FaaS invocation so
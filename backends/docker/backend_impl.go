@@ -22,6 +22,7 @@ import (
 	"github.com/docker/go-connections/nat"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
 )
 
 // Compile-time check that Server implements api.Backend.
@@ -399,6 +400,23 @@ func (s *Server) ImageLoad(r io.Reader) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
+// ImageImport creates an image from a rootfs tarball on the daemon.
+func (s *Server) ImageImport(r io.Reader, opts core.ImageImportOptions) (io.ReadCloser, error) {
+	ref := opts.Repo
+	if ref != "" && opts.Tag != "" {
+		ref += ":" + opts.Tag
+	}
+	rc, err := s.docker.ImageImport(context.Background(), image.ImportSource{Source: r, SourceName: "-"}, ref, image.ImportOptions{
+		Message:  opts.Message,
+		Changes:  opts.Changes,
+		Platform: opts.Platform,
+	})
+	if err != nil {
+		return nil, mapDockerError(err)
+	}
+	return rc, nil
+}
+
 // ImageTag tags an image.
 func (s *Server) ImageTag(source string, repo string, tag string) error {
 	ref := repo
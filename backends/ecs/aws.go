@@ -9,12 +9,18 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/codebuild"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/efs"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/servicediscovery"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	awscommon "github.com/sockerless/aws-common"
+	core "github.com/sockerless/backend-core"
 )
 
 // AWSClients holds all AWS SDK clients.
@@ -28,6 +34,10 @@ type AWSClients struct {
 	EC2               *ec2.Client
 	CodeBuild         *codebuild.Client
 	S3                *s3.Client
+	Secrets           *secretsmanager.Client // registry mirror upstream credentials
+	IAM               *iam.Client            // permission preflight (SimulatePrincipalPolicy)
+	STS               *sts.Client            // permission preflight (caller identity)
+	DynamoDB          *dynamodb.Client       // HA lease store (SOCKERLESS_HA_STORE)
 }
 
 // NewAWSClients initializes AWS SDK clients from config.
@@ -41,11 +51,19 @@ func NewAWSClients(ctx context.Context, region string, endpointURL string) (*AWS
 			credentials.NewStaticCredentialsProvider("test", "test", ""),
 		))
 	}
+	recorder, err := core.CassetteHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	if recorder != nil {
+		opts = append(opts, awsconfig.WithHTTPClient(recorder))
+	}
 
 	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
+	cfg.APIOptions = append(cfg.APIOptions, awscommon.CloudAPIMetrics)
 
 	if endpointURL != "" {
 		return newClientsWithEndpoint(cfg, endpointURL), nil
@@ -64,6 +82,10 @@ func newClientsFromConfig(cfg aws.Config) *AWSClients {
 		EC2:               ec2.NewFromConfig(cfg),
 		CodeBuild:         codebuild.NewFromConfig(cfg),
 		S3:                s3.NewFromConfig(cfg),
+		Secrets:           secretsmanager.NewFromConfig(cfg),
+		IAM:               iam.NewFromConfig(cfg),
+		STS:               sts.NewFromConfig(cfg),
+		DynamoDB:          dynamodb.NewFromConfig(cfg),
 	}
 }
 
@@ -78,5 +100,9 @@ func newClientsWithEndpoint(cfg aws.Config, endpoint string) *AWSClients {
 		EC2:               ec2.NewFromConfig(cfg, func(o *ec2.Options) { o.BaseEndpoint = aws.String(endpoint) }),
 		CodeBuild:         codebuild.NewFromConfig(cfg, func(o *codebuild.Options) { o.BaseEndpoint = aws.String(endpoint) }),
 		S3:                s3.NewFromConfig(cfg, func(o *s3.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		Secrets:           secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		IAM:               iam.NewFromConfig(cfg, func(o *iam.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		STS:               sts.NewFromConfig(cfg, func(o *sts.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		DynamoDB:          dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) { o.BaseEndpoint = aws.String(endpoint) }),
 	}
 }
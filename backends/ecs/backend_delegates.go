@@ -170,8 +170,20 @@ func (s *Server) ExecInspect(id string) (*api.ExecInstance, error) {
 	return s.BaseServer.ExecInspect(id)
 }
 
+// ExecResize sends the size to the exec's SSM session, which sets it
+// on the session's PTY inside the task.
 func (s *Server) ExecResize(id string, h int, w int) error {
-	return s.BaseServer.ExecResize(id, h, w)
+	if _, err := s.ResizeTargetExec(id); err != nil {
+		return err
+	}
+	v, ok := s.ssmSessions.Load(id)
+	if !ok {
+		return &api.ConflictError{Message: fmt.Sprintf("Exec %s has no open SSM session", id)}
+	}
+	if err := v.(*ssmDecoder).Resize(w, h); err != nil {
+		return &api.ServerError{Message: fmt.Sprintf("resize via SSM: %v", err)}
+	}
+	return nil
 }
 
 // --- Non-container pass-through methods ---
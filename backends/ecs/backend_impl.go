@@ -169,6 +169,10 @@ func (s *Server) ContainerCreate(req *api.ContainerCreateRequest) (*api.Containe
 	}
 	hostConfig.Binds = translatedBinds
 
+	if _, err := capacityProviderStrategy(config.Labels); err != nil {
+		return nil, err
+	}
+
 	path := ""
 	var args []string
 	if len(config.Entrypoint) > 0 {
@@ -391,8 +395,16 @@ func (s *Server) ContainerStart(ref string) error {
 
 	// Run ECS task before marking container as running, so docker ps
 	// doesn't show a false-positive running state if RunTask fails.
+	// `--restart always|unless-stopped` runs under an ECS service so the
+	// scheduler replaces the task when it exits (or Spot reclaims it).
 	taskDefARN := ecsState.TaskDefARN
-	taskARN, clusterARN, err := s.runECSTask(id, taskDefARN, &c)
+	var taskARN, clusterARN, serviceARN string
+	var err error
+	if runsAsService(c.HostConfig.RestartPolicy) {
+		taskARN, clusterARN, serviceARN, err = s.startECSService(id, taskDefARN, &c)
+	} else {
+		taskARN, clusterARN, err = s.runECSTask(id, taskDefARN, &c)
+	}
 	if err != nil {
 		// Best-effort cleanup of orphaned task definition
 		_, _ = s.aws.ECS.DeregisterTaskDefinition(s.ctx(), &awsecs.DeregisterTaskDefinitionInput{
@@ -407,6 +419,7 @@ func (s *Server) ContainerStart(ref string) error {
 	s.ECS.Update(id, func(state *ECSState) {
 		state.TaskARN = taskARN
 		state.ClusterARN = clusterARN
+		state.ServiceARN = serviceARN
 	})
 
 	// Wait for task to reach RUNNING — only then is the ENI's private IP
@@ -443,6 +456,13 @@ func (s *Server) ContainerStart(ref string) error {
 		// so subsequent inspect/ps reads come from CloudState (which
 		// reflects the actual STOPPED state).
 		s.PendingCreates.Delete(id)
+		// A service task that already exited is about to be replaced
+		// by the scheduler; keep following it instead of reporting the
+		// container as finished.
+		if serviceARN != "" {
+			go s.watchServiceTasks(id, ecsServiceName(id), taskARN, exitCh)
+			return nil
+		}
 		if ch, ok := s.Store.WaitChs.LoadAndDelete(id); ok {
 			close(ch.(chan struct{}))
 		}
@@ -475,7 +495,11 @@ func (s *Server) ContainerStart(ref string) error {
 	}
 
 	// Start background poller to detect task exit
-	go s.pollTaskExit(id, taskARN, exitCh)
+	if serviceARN != "" {
+		go s.watchServiceTasks(id, ecsServiceName(id), taskARN, exitCh)
+	} else {
+		go s.pollTaskExit(id, taskARN, exitCh)
+	}
 
 	return nil
 }
@@ -570,6 +594,11 @@ func (s *Server) ContainerStop(ref string, timeout *int) error {
 		cluster = ecsState.ClusterARN
 	}
 	taskARN := ecsState.TaskARN
+	if svcName := s.containerServiceName(c); svcName != "" {
+		if err := s.scaleServiceToZero(svcName); err != nil {
+			return &api.ServerError{Message: fmt.Sprintf("docker stop %s: %v", ref, err)}
+		}
+	}
 	if _, err := s.aws.ECS.StopTask(s.ctx(), &awsecs.StopTaskInput{
 		Cluster: aws.String(cluster),
 		Task:    aws.String(taskARN),
@@ -639,6 +668,9 @@ func (s *Server) ContainerKill(ref string, signal string) error {
 				{Key: aws.String("sockerless-kill-signal"), Value: aws.String(signal)},
 			},
 		})
+		if svcName := s.containerServiceName(c); svcName != "" {
+			_ = s.scaleServiceToZero(svcName)
+		}
 		_, _ = s.aws.ECS.StopTask(s.ctx(), &awsecs.StopTaskInput{
 			Cluster: aws.String(cluster),
 			Task:    aws.String(ecsState.TaskARN),
@@ -701,6 +733,10 @@ func (s *Server) ContainerRemove(ref string, force bool) error {
 
 	s.StopHealthCheck(id)
 
+	if svcName := s.containerServiceName(c); svcName != "" {
+		s.deleteECSService(id, svcName)
+	}
+
 	// Deregister task definition. Read from cache when available; on
 	// cache miss (post-restart) derive TaskDefinitionArn from the running
 	// task via DescribeTasks/.
@@ -830,6 +866,11 @@ func (s *Server) ContainerRestart(ref string, timeout *int) error {
 			if ecsState.ClusterARN != "" {
 				cluster = ecsState.ClusterARN
 			}
+			// ContainerStart scales the service back up on the fresh
+			// task definition.
+			if svcName := s.containerServiceName(c); svcName != "" {
+				_ = s.scaleServiceToZero(svcName)
+			}
 			_, _ = s.aws.ECS.StopTask(s.ctx(), &awsecs.StopTaskInput{
 				Cluster: aws.String(cluster),
 				Task:    aws.String(ecsState.TaskARN),
@@ -992,6 +1033,18 @@ func (s *Server) ImageLoad(r io.Reader) (io.ReadCloser, error) {
 	return s.images.Load(r)
 }
 
+// ImageImport delegates to ImageManager, which pushes the imported
+// image into the cloud registry.
+func (s *Server) ImageImport(r io.Reader, opts core.ImageImportOptions) (io.ReadCloser, error) {
+	return s.images.Import(r, opts)
+}
+
+// ContainerCheckpoint delegates to ImageManager, which pushes the
+// committed image into the cloud registry for `sockerless migrate`.
+func (s *Server) ContainerCheckpoint(ref string) (*core.ContainerCheckpoint, error) {
+	return s.images.Checkpoint(ref)
+}
+
 // VolumeRemove deletes the EFS access point bound to a named volume.
 // The backing filesystem is left in place so other volumes keep
 // working; whole-filesystem reclamation (`docker system prune --volumes`
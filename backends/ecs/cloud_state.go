@@ -602,6 +602,14 @@ func taskToContainer(task ecstypes.Task, tags map[string]string, td ecstypes.Tas
 		}
 	}
 
+	// Tasks the service scheduler launched belong to a container with a
+	// restart policy. ECS doesn't record `always` from `unless-stopped`
+	// (both keep one replica), so report `always`.
+	var restartPolicy api.RestartPolicy
+	if strings.HasPrefix(aws.ToString(task.Group), "service:") {
+		restartPolicy.Name = "always"
+	}
+
 	// Map the task-def's CPU/Memory tier back to Docker's HostConfig
 	// fields so `docker inspect` reflects the operator's `-m`/`--cpus`
 	// request. Fargate stores `task.Memory` as MB (string) and
@@ -640,9 +648,10 @@ func taskToContainer(task ecstypes.Task, tags map[string]string, td ecstypes.Tas
 			WorkingDir: workingDir,
 		},
 		HostConfig: api.HostConfig{
-			NetworkMode: networkName,
-			Memory:      memBytes,
-			NanoCPUs:    nanoCPUs,
+			NetworkMode:   networkName,
+			Memory:        memBytes,
+			NanoCPUs:      nanoCPUs,
+			RestartPolicy: restartPolicy,
 		},
 		NetworkSettings: api.NetworkSettings{
 			Networks: map[string]*api.EndpointSettings{
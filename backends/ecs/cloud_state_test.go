@@ -126,6 +126,14 @@ func TestTaskToContainer_BasicFields(t *testing.T) {
 	if c.State.Status != "running" {
 		t.Fatalf("expected status 'running', got %q", c.State.Status)
 	}
+	if runsAsService(c.HostConfig.RestartPolicy) {
+		t.Fatalf("one-shot task reported restart policy %q", c.HostConfig.RestartPolicy.Name)
+	}
+
+	task.Group = aws.String("service:sockerless-abc123def456")
+	if c := taskToContainer(task, tags, ecstypes.TaskDefinition{}); !runsAsService(c.HostConfig.RestartPolicy) {
+		t.Fatalf("service task restart policy = %q, want a service policy", c.HostConfig.RestartPolicy.Name)
+	}
 }
 
 func TestTaskToContainer_LabelsFromTags(t *testing.T) {
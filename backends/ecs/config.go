@@ -34,6 +34,13 @@ type Config struct {
 	CpuArchitecture string
 	PollInterval    time.Duration // Cloud API poll interval (default 2s)
 
+	// ImageRepository is the registry prefix `docker load` and
+	// `docker import` push images under when their tag is not already an ECR
+	// reference. Defaults to the account's ECR registry derived from
+	// ExecutionRoleARN and Region, one ECR repository per image name.
+	// Set via SOCKERLESS_ECS_IMAGE_REPOSITORY.
+	ImageRepository string
+
 	// SharedVolumes maps host bind-mount paths the calling docker
 	// client sees (in its own container's filesystem) to EFS access
 	// points already mounted in the calling task at the same path.
@@ -57,6 +64,33 @@ type Config struct {
 	// registry) or nat-gateway-only (no peer discovery).
 	// Set via SOCKERLESS_ECS_NETWORK_DISCOVERY.
 	NetworkDiscovery api.NetworkDiscoveryKind
+
+	// ImageScan selects the vulnerability scanner and the admission
+	// policy applied to create / pull. Set via SOCKERLESS_IMAGE_SCANNER,
+	// SOCKERLESS_VULN_FEED, SOCKERLESS_SCAN_BLOCK_SEVERITY and
+	// SOCKERLESS_SCAN_ENFORCE.
+	ImageScan core.ImageScanConfig
+
+	// ImageVerify is the image signature trust policy applied to
+	// create. Set via the SOCKERLESS_VERIFY_* variables.
+	ImageVerify core.ImageVerifyConfig
+
+	// RegistryMirror holds the upstream registry credentials stored in
+	// the cloud secret store when sockerless creates a pull-through
+	// cache. Set via SOCKERLESS_REGISTRY_MIRROR_AUTH.
+	RegistryMirror core.RegistryMirrorConfig
+
+	// Costs configures per-run cost accounting and budgets. Set via
+	// the SOCKERLESS_COST_* variables.
+	Costs core.CostConfig
+
+	// Audit configures the audit log of cloud mutations. Set via the
+	// SOCKERLESS_AUDIT_* variables; the sink is a CloudWatch Logs log group.
+	Audit core.AuditConfig
+
+	// HA configures multi-instance operation. Set via the SOCKERLESS_HA_*
+	// variables; the lease store is a DynamoDB table.
+	HA core.HAConfig
 }
 
 // SharedVolume describes a workspace volume mounted via EFS that the
@@ -87,10 +121,17 @@ func ConfigFromEnv() Config {
 		CodeBuildProject: os.Getenv("SOCKERLESS_AWS_CODEBUILD_PROJECT"),
 		BuildBucket:      os.Getenv("SOCKERLESS_AWS_BUILD_BUCKET"),
 		EndpointURL:      os.Getenv("SOCKERLESS_ENDPOINT_URL"),
+		ImageRepository:  os.Getenv("SOCKERLESS_ECS_IMAGE_REPOSITORY"),
 		CpuArchitecture:  os.Getenv("SOCKERLESS_ECS_CPU_ARCHITECTURE"),
 		PollInterval:     parseDuration(os.Getenv("SOCKERLESS_POLL_INTERVAL"), 2*time.Second),
 		SharedVolumes:    parseSharedVolumes(os.Getenv("SOCKERLESS_ECS_SHARED_VOLUMES")),
 		NetworkDiscovery: networkDiscoveryFromEnv("SOCKERLESS_ECS_NETWORK_DISCOVERY", api.NetworkDiscoveryServiceMesh),
+		ImageScan:        core.ImageScanConfigFromEnv(),
+		ImageVerify:      core.ImageVerifyConfigFromEnv(),
+		RegistryMirror:   core.RegistryMirrorConfigFromEnv(),
+		Costs:            core.CostConfigFromEnv(),
+		Audit:            core.AuditConfigFromEnv(),
+		HA:               core.HAConfigFromEnv(),
 	}
 }
 
@@ -213,6 +254,12 @@ func ConfigFromEnvironment(env *core.Environment, sim *core.SimulatorConfig) Con
 		c.EndpointURL = fmt.Sprintf("http://localhost:%d", sim.Port)
 	}
 	c.NetworkDiscovery = networkDiscoveryFromEnv("SOCKERLESS_ECS_NETWORK_DISCOVERY", api.NetworkDiscoveryServiceMesh)
+	c.ImageScan = core.ImageScanConfigFromEnv()
+	c.ImageVerify = core.ImageVerifyConfigFromEnv()
+	c.RegistryMirror = core.RegistryMirrorConfigFromEnv()
+	c.Costs = core.CostConfigFromEnv()
+	c.Audit = core.AuditConfigFromEnv()
+	c.HA = core.HAConfigFromEnv()
 	return c
 }
 
@@ -239,7 +286,22 @@ func (c Config) Validate() error {
 	default:
 		return fmt.Errorf("SOCKERLESS_ECS_NETWORK_DISCOVERY=%q not supported by ecs (one of service-mesh, host-aliases, nat-gateway-only required)", c.NetworkDiscovery)
 	}
-	return nil
+	if err := c.ImageScan.Validate(); err != nil {
+		return err
+	}
+	if err := c.ImageVerify.Validate(); err != nil {
+		return err
+	}
+	if err := c.RegistryMirror.Validate(); err != nil {
+		return err
+	}
+	if err := c.Costs.Validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+	return c.HA.Validate()
 }
 
 func envOrDefault(key, def string) string {
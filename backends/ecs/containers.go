@@ -15,6 +15,73 @@ import (
 // runECSTask runs a single ECS task with the given task definition.
 // Returns the task ARN and cluster ARN.
 func (s *Server) runECSTask(containerID, taskDefARN string, c *api.Container) (taskARN, clusterARN string, err error) {
+	launch, err := s.taskLaunchParams(containerID, c)
+	if err != nil {
+		return "", "", err
+	}
+
+	input := &awsecs.RunTaskInput{
+		Cluster:                  aws.String(s.config.Cluster),
+		TaskDefinition:           aws.String(taskDefARN),
+		Count:                    aws.Int32(1),
+		Tags:                     launch.tags,
+		CapacityProviderStrategy: launch.capacityProviderStrategy,
+		// ECS Exec must be enabled at task launch time for
+		// docker exec to work. Combined with task-role ssmmessages:*
+		// permissions this allows the in-task SSM agent to
+		// dial back to Session Manager.
+		EnableExecuteCommand: true,
+		NetworkConfiguration: launch.networkConfiguration,
+	}
+	// ECS rejects a launch type alongside a capacity provider strategy.
+	if len(launch.capacityProviderStrategy) == 0 {
+		input.LaunchType = ecstypes.LaunchTypeFargate
+	}
+	runResult, err := s.aws.ECS.RunTask(s.ctx(), input)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to run task: %w", err)
+	}
+
+	if len(runResult.Tasks) == 0 {
+		msg := "no tasks launched"
+		if len(runResult.Failures) > 0 {
+			msg = aws.ToString(runResult.Failures[0].Reason)
+		}
+		return "", "", fmt.Errorf("failed to launch task: %s", msg)
+	}
+
+	taskARN = aws.ToString(runResult.Tasks[0].TaskArn)
+	clusterARN = aws.ToString(runResult.Tasks[0].ClusterArn)
+
+	s.Registry.Register(core.ResourceEntry{
+		ContainerID:  containerID,
+		Backend:      "ecs",
+		ResourceType: "task",
+		ResourceID:   taskARN,
+		InstanceID:   s.Desc.InstanceID,
+		CreatedAt:    time.Now(),
+		Metadata:     map[string]string{"image": c.Image, "name": c.Name, "taskArn": taskARN},
+	})
+
+	return taskARN, clusterARN, nil
+}
+
+// launchParams holds the per-container RunTask / CreateService
+// inputs shared by the one-shot task and service launch paths.
+type launchParams struct {
+	tags                     []ecstypes.Tag
+	networkConfiguration     *ecstypes.NetworkConfiguration
+	capacityProviderStrategy []ecstypes.CapacityProviderStrategyItem
+}
+
+// taskLaunchParams builds the sockerless tag set, awsvpc network
+// configuration and capacity provider strategy for a container.
+func (s *Server) taskLaunchParams(containerID string, c *api.Container) (launchParams, error) {
+	strategy, err := capacityProviderStrategy(c.Config.Labels)
+	if err != nil {
+		return launchParams{}, err
+	}
+
 	assignPublicIP := ecstypes.AssignPublicIpDisabled
 	if s.config.AssignPublicIP {
 		assignPublicIP = ecstypes.AssignPublicIpEnabled
@@ -63,51 +130,17 @@ func (s *Server) runECSTask(containerID, taskDefARN string, c *api.Container) (t
 		securityGroups = append(securityGroups, s.config.SecurityGroups...)
 	}
 
-	runResult, err := s.aws.ECS.RunTask(s.ctx(), &awsecs.RunTaskInput{
-		Cluster:        aws.String(s.config.Cluster),
-		TaskDefinition: aws.String(taskDefARN),
-		LaunchType:     ecstypes.LaunchTypeFargate,
-		Count:          aws.Int32(1),
-		Tags:           mapToECSTags(tags.AsMap()),
-		// ECS Exec must be enabled at task launch time for
-		// docker exec to work. Combined with task-role ssmmessages:*
-		// permissions this allows the in-task SSM agent to
-		// dial back to Session Manager.
-		EnableExecuteCommand: true,
-		NetworkConfiguration: &ecstypes.NetworkConfiguration{
+	return launchParams{
+		tags: mapToECSTags(tags.AsMap()),
+		networkConfiguration: &ecstypes.NetworkConfiguration{
 			AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{
 				Subnets:        s.config.Subnets,
 				SecurityGroups: securityGroups,
 				AssignPublicIp: assignPublicIP,
 			},
 		},
-	})
-	if err != nil {
-		return "", "", fmt.Errorf("failed to run task: %w", err)
-	}
-
-	if len(runResult.Tasks) == 0 {
-		msg := "no tasks launched"
-		if len(runResult.Failures) > 0 {
-			msg = aws.ToString(runResult.Failures[0].Reason)
-		}
-		return "", "", fmt.Errorf("failed to launch task: %s", msg)
-	}
-
-	taskARN = aws.ToString(runResult.Tasks[0].TaskArn)
-	clusterARN = aws.ToString(runResult.Tasks[0].ClusterArn)
-
-	s.Registry.Register(core.ResourceEntry{
-		ContainerID:  containerID,
-		Backend:      "ecs",
-		ResourceType: "task",
-		ResourceID:   taskARN,
-		InstanceID:   s.Desc.InstanceID,
-		CreatedAt:    time.Now(),
-		Metadata:     map[string]string{"image": c.Image, "name": c.Name, "taskArn": taskARN},
-	})
-
-	return taskARN, clusterARN, nil
+		capacityProviderStrategy: strategy,
+	}, nil
 }
 
 // waitForTaskRunning polls ECS until the task reaches RUNNING state
@@ -242,6 +275,7 @@ func (s *Server) pollTaskExit(containerID, taskARN string, exitCh chan struct{})
 			result, err := s.aws.ECS.DescribeTasks(s.ctx(), &awsecs.DescribeTasksInput{
 				Cluster: aws.String(s.config.Cluster),
 				Tasks:   []string{taskARN},
+				Include: []ecstypes.TaskField{ecstypes.TaskFieldTags},
 			})
 			if err != nil {
 				continue
@@ -251,6 +285,12 @@ func (s *Server) pollTaskExit(containerID, taskARN string, exitCh chan struct{})
 			}
 
 			task := result.Tasks[0]
+			if aws.ToString(task.LastStatus) == "STOPPED" && task.StopCode != ecstypes.TaskStopCodeUserInitiated {
+				// docker stop / kill / rm go through StopTask and emit
+				// their own die; anything else (the workload exiting,
+				// a Spot reclaim) is reported here.
+				s.emitTaskDie(containerID, task)
+			}
 			// Apply task status on every poll (updates IP for RUNNING, exits for STOPPED)
 			s.applyTaskStatus(containerID, task)
 			if aws.ToString(task.LastStatus) == "STOPPED" {
@@ -0,0 +1,82 @@
+package ecs
+
+import (
+	"strconv"
+
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// fargateEphemeralGiB is the task ephemeral storage sockerless leaves at
+// the Fargate default, all of it inside the free allowance.
+const fargateEphemeralGiB = 20
+
+// ContainerCostShape prices a container as the Fargate task launched
+// for it: the smallest valid CPU/memory combination covering its
+// limits, on FARGATE_SPOT when sockerless.capacity=spot. Named volumes
+// are EFS access points.
+//
+// Pod and compose-unit members run as one task, so a member is priced
+// as that task keyed on the pod, billed at its share: the vCPU of the
+// task its own limits would get, over the sum of all members'.
+func (s *Server) ContainerCostShape(c *api.Container) core.CostShape {
+	members := []containerInput{{ID: c.ID, Container: c, IsMain: true}}
+	unit := ""
+	share := 0.0
+	if pod, ok := s.Store.Pods.GetPodForContainer(c.ID); ok && len(pod.ContainerIDs) > 1 {
+		members = members[:0]
+		var own, total float64
+		for _, id := range pod.ContainerIDs {
+			m, ok := s.podMember(id)
+			if !ok {
+				continue
+			}
+			members = append(members, containerInput{ID: id, Container: m, IsMain: len(members) == 0})
+			units := fargateVCPU([]containerInput{{ID: id, Container: m, IsMain: true}})
+			total += units
+			if id == c.ID {
+				own = units
+			}
+		}
+		if own > 0 && total > 0 {
+			unit, share = pod.Name, own/total
+		} else {
+			members = []containerInput{{ID: c.ID, Container: c, IsMain: true}}
+		}
+	}
+	cpu, memory := fargateResources(members)
+	units, _ := strconv.ParseFloat(cpu, 64)
+	mib, _ := strconv.ParseFloat(memory, 64)
+	kind := core.CostKindFargate
+	if members[0].Container.Config.Labels[capacityLabel] == "spot" {
+		kind = core.CostKindFargateSpot
+	}
+	return core.CostShape{
+		Kind:         kind,
+		VCPU:         units / 1024,
+		MemoryGiB:    mib / 1024,
+		EphemeralGiB: fargateEphemeralGiB,
+		StorageClass: core.CostStorageEFS,
+		Unit:         unit,
+		Share:        share,
+	}
+}
+
+// podMember returns a pod member as created, whether or not its task
+// has been launched yet.
+func (s *Server) podMember(id string) (*api.Container, bool) {
+	if c, ok := s.PendingCreates.Get(id); ok {
+		return &c, true
+	}
+	if c, ok := s.Store.Containers.Get(id); ok {
+		return &c, true
+	}
+	return nil, false
+}
+
+// fargateVCPU is the CPU units of the task containers would get.
+func fargateVCPU(containers []containerInput) float64 {
+	cpu, _ := fargateResources(containers)
+	units, _ := strconv.ParseFloat(cpu, 64)
+	return units
+}
@@ -173,6 +173,8 @@ func (s *Server) cloudExecStart(exec *api.ExecInstance, c *api.Container, tty bo
 	// headers since `docker exec` expects that framing.
 	dec := newSSMDecoder(bridge)
 	dec.capture = openSSMCapture(ecsState.TaskARN, cmd)
+	s.ssmSessions.Store(exec.ID, dec)
+	dec.onClose = func() { s.ssmSessions.CompareAndDelete(exec.ID, dec) }
 	if !tty {
 		return &muxBridge{rwc: dec}, nil
 	}
@@ -226,6 +228,7 @@ type ssmDecoder struct {
 	closeErr error
 	debug    bool // when true, fprintf'd to stderr
 	capture  *ssmFrameCapture
+	onClose  func() // drops the session from Server.ssmSessions
 }
 
 func newSSMDecoder(wire io.ReadWriteCloser) *ssmDecoder {
@@ -329,7 +332,23 @@ func (d *ssmDecoder) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
-func (d *ssmDecoder) Close() error { return d.wire.Close() }
+// Resize sends the terminal size to the session's PTY, as the SSM
+// plugin does on SIGWINCH.
+func (d *ssmDecoder) Resize(cols, rows int) error {
+	out, err := buildSSMSize(cols, rows)
+	if err != nil {
+		return err
+	}
+	_, err = d.wire.Write(out)
+	return err
+}
+
+func (d *ssmDecoder) Close() error {
+	if d.onClose != nil {
+		d.onClose()
+	}
+	return d.wire.Close()
+}
 
 // wsBridge adapts a gorilla/websocket.Conn to io.ReadWriteCloser.
 type wsBridge struct {
@@ -40,22 +40,19 @@ func TestExtractAccountID(t *testing.T) {
 	}
 }
 
-// upstreamRegistryFor maps known public registry hostnames to ECR's
-// UpstreamRegistry enum. Used for ghcr.io / quay.io / etc. routing —
-// Docker Hub is handled inline in resolveImageURI by rewriting to AWS
-// Public Gallery without involving a pull-through cache.
-func TestUpstreamRegistryFor(t *testing.T) {
-	cases := map[string]string{
-		"ghcr.io":           "github-container-registry",
-		"quay.io":           "quay",
-		"registry.k8s.io":   "k8s",
-		"k8s.gcr.io":        "k8s",
-		"mcr.microsoft.com": "azure-container-registry",
-		"public.ecr.aws":    "ecr-public",
+func TestLoadImageRepository(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"explicit", Config{ImageRepository: "111.dkr.ecr.us-east-1.amazonaws.com/loaded", ExecutionRoleARN: "arn:aws:iam::222:role/x", Region: "eu-west-1"}, "111.dkr.ecr.us-east-1.amazonaws.com/loaded"},
+		{"account registry", Config{ExecutionRoleARN: "arn:aws:iam::123456789012:role/exec", Region: "eu-west-1"}, "123456789012.dkr.ecr.eu-west-1.amazonaws.com"},
+		{"no role", Config{Region: "eu-west-1"}, ""},
 	}
-	for host, want := range cases {
-		if got := string(upstreamRegistryFor(host)); got != want {
-			t.Errorf("upstreamRegistryFor(%q) = %q, want %q", host, got, want)
+	for _, tc := range cases {
+		if got := loadImageRepository(tc.cfg); got != tc.want {
+			t.Errorf("%s: loadImageRepository = %q, want %q", tc.name, got, tc.want)
 		}
 	}
 }
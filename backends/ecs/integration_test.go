@@ -17,6 +17,7 @@ import (
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	core "github.com/sockerless/backend-core"
 )
 
 var dockerClient *client.Client
@@ -63,11 +64,19 @@ func requireExe(name string) {
 //	SOCKERLESS_ECS_CPU_ARCHITECTURE) and fails
 //	loud on any missing.
 //
+// SOCKERLESS_TEST_TARGET = replay → harness serves the cassette named by
+//
+//	SOCKERLESS_TEST_CASSETTE in place of a
+//	simulator or cloud, with the sim fixtures.
+//
+// SOCKERLESS_TEST_RECORD=<path> with the sim or cloud target records the
+// backend's endpoint traffic into a cassette for later replay.
+//
 // The Test* functions don't know which target they're running against.
 func TestMain(m *testing.M) {
 	target := requireEnv("SOCKERLESS_TEST_TARGET")
-	if target != "sim" && target != "cloud" {
-		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\" or \"cloud\").\n", target)
+	if target != "sim" && target != "cloud" && target != "replay" {
+		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\", \"cloud\" or \"replay\").\n", target)
 		os.Exit(1)
 	}
 	requireExe("docker")
@@ -149,7 +158,7 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		cpuArch = "ARM64"
 
 		// Create ECS cluster in simulator (sim fixture).
-		body := fmt.Sprintf(`{"clusterName":"%s"}`, cluster)
+		body := fmt.Sprintf(`{"clusterName":"%s","capacityProviders":["FARGATE","FARGATE_SPOT"]}`, cluster)
 		req, _ := http.NewRequest("POST", simURL+"/", strings.NewReader(body))
 		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
 		req.Header.Set("X-Amz-Target", "AmazonEC2ContainerServiceV20141113.CreateCluster")
@@ -160,6 +169,12 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		resp.Body.Close()
 		fmt.Printf("[sim] Created ECS cluster %q\n", cluster)
 
+	case "replay":
+		cluster = "sim-cluster"
+		subnets = "subnet-0123456789abcdef0"
+		executionRoleARN = "arn:aws:iam::000000000000:role/sim"
+		cpuArch = "ARM64"
+
 	case "cloud":
 		endpointURL = requireEnv("SOCKERLESS_ENDPOINT_URL")
 		cluster = requireEnv("SOCKERLESS_ECS_CLUSTER")
@@ -168,6 +183,17 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 		cpuArch = requireEnv("SOCKERLESS_ECS_CPU_ARCHITECTURE")
 	}
 
+	// SOCKERLESS_TEST_RECORD records the session into a cassette;
+	// the replay target serves one instead of a simulator or cloud.
+	cassette, err := core.CassetteHarnessFromEnv(target, endpointURL)
+	if err != nil {
+		failClean("ERROR: cassette harness: %v\n", err)
+	}
+	if cassette != nil {
+		fmt.Printf("[cassette] %s %s via %s\n", cassette.Mode, cassette.Path, cassette.URL)
+		endpointURL = cassette.URL
+	}
+
 	backendDir := repoRoot + "/backends/ecs"
 	backendBinary := backendDir + "/sockerless-backend-ecs"
 	fmt.Println("[backend] Building sockerless-backend-ecs...")
@@ -205,7 +231,6 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 	}
 	fmt.Printf("[backend] ready on %s\n", backendAddr)
 
-	var err error
 	dockerClient, err = client.NewClientWithOpts(
 		client.WithHost(fmt.Sprintf("tcp://localhost:%d", backendPort)),
 		client.WithAPIVersionNegotiation(),
@@ -216,6 +241,9 @@ ENTRYPOINT ["/usr/local/bin/eval-arithmetic"]
 
 	code := m.Run()
 	cleanup()
+	if cassette != nil {
+		code = cassette.Finish(code)
+	}
 	os.Exit(code)
 }
 
@@ -0,0 +1,94 @@
+package ecs
+
+import (
+	"github.com/sockerless/api"
+	awscommon "github.com/sockerless/aws-common"
+	core "github.com/sockerless/backend-core"
+)
+
+// requiredPermissions declares every IAM action the ECS backend calls
+// under config's driver selection. `sockerless check` probes the set
+// with iam:SimulatePrincipalPolicy; keep it in step with the SDK calls
+// in this package.
+func requiredPermissions(config Config) *core.PermissionSet {
+	var set core.PermissionSet
+
+	// Container lifecycle: one task definition + RunTask per container.
+	set.Add("containers", []string{"run", "start", "restart"},
+		"ecs:RegisterTaskDefinition",
+		"ecs:DeregisterTaskDefinition",
+		"ecs:RunTask",
+		"ecs:DescribeTasks",
+		"ecs:TagResource",
+	)
+	for _, role := range []string{config.ExecutionRoleARN, config.TaskRoleARN} {
+		if role != "" {
+			set.AddOn("containers", role, []string{"run", "start", "restart"}, "iam:PassRole")
+		}
+	}
+	set.Add("containers", []string{"stop", "kill", "restart"}, "ecs:StopTask", "ecs:DescribeTasks")
+	set.Add("containers", []string{"kill", "rename"}, "ecs:TagResource")
+	set.Add("containers", []string{"rm", "container prune"},
+		"ecs:StopTask",
+		"ecs:ListTasks",
+		"ecs:DescribeTasks",
+		"ecs:DeregisterTaskDefinition",
+	)
+	set.Add("containers", []string{"ps", "inspect", "wait"},
+		"ecs:ListTasks",
+		"ecs:DescribeTasks",
+		"ecs:ListTagsForResource",
+		"ecs:ListServices",
+		"ecs:DescribeServices",
+	)
+	set.Add("containers", []string{"info"}, "ecs:DescribeClusters")
+
+	// Restart policies always / unless-stopped run as ECS services.
+	set.Add("containers", []string{"run --restart"},
+		"ecs:CreateService",
+		"ecs:UpdateService",
+		"ecs:DescribeServices",
+		"ecs:DeleteService",
+		"ecs:ListTasks",
+	)
+
+	set.Add("logs cloudwatch", []string{"logs", "attach", "run"}, "logs:GetLogEvents")
+	set.Add("stats container-insights", []string{"stats"}, "cloudwatch:GetMetricData")
+
+	// Exec and every typed driver routed through SSM ExecuteCommand.
+	set.Add("exec ssm", []string{"exec", "cp", "top", "diff", "export", "pause", "unpause"},
+		"ecs:ExecuteCommand",
+		"ecs:DescribeTasks",
+	)
+
+	// Docker networks map to VPC security groups.
+	set.Add("network security-group", []string{"network create"},
+		"ec2:DescribeSubnets",
+		"ec2:CreateSecurityGroup",
+		"ec2:CreateTags",
+		"ec2:DescribeSecurityGroups",
+		"ec2:AuthorizeSecurityGroupIngress",
+	)
+	set.Add("network security-group", []string{"network rm"}, "ec2:DeleteSecurityGroup")
+	set.Add("network security-group", []string{"network ls", "network inspect"}, "ec2:DescribeSecurityGroups")
+
+	if config.NetworkDiscovery == api.NetworkDiscoveryServiceMesh {
+		awscommon.AddCloudMapPermissions(&set)
+	}
+	awscommon.AddEFSEphemeralPermissions(&set)
+	awscommon.AddECRPermissions(&set)
+	awscommon.AddECRMirrorPermissions(&set)
+	if config.ImageScan.Scanner == "cloud" {
+		awscommon.AddECRScanPermissions(&set)
+	}
+	if config.CodeBuildProject != "" && config.BuildBucket != "" {
+		awscommon.AddCodeBuildPermissions(&set, config.BuildBucket)
+	}
+	if config.Audit.Sink != "" {
+		awscommon.AddCloudWatchAuditPermissions(&set)
+	}
+	if config.HA.Mode != "" {
+		awscommon.AddDynamoDBLeasePermissions(&set)
+	}
+	return &set
+}
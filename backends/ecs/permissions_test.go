@@ -0,0 +1,119 @@
+package ecs
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+func permissionIndex(set *core.PermissionSet) map[string]core.PermissionRequirement {
+	out := map[string]core.PermissionRequirement{}
+	for _, r := range set.Requirements() {
+		out[r.Permission] = r
+	}
+	return out
+}
+
+// TestRequiredPermissions_CoversSDKCalls scans this package for AWS SDK
+// calls and fails when one has no declared IAM action, so a new call
+// can't ship without its preflight entry.
+func TestRequiredPermissions_CoversSDKCalls(t *testing.T) {
+	prefixes := map[string]string{
+		"ECS":               "ecs",
+		"CloudWatch":        "logs",
+		"CloudWatchMetrics": "cloudwatch",
+		"EC2":               "ec2",
+		"ECR":               "ecr",
+		"ServiceDiscovery":  "servicediscovery",
+	}
+	declared := permissionIndex(requiredPermissions(Config{
+		NetworkDiscovery: api.NetworkDiscoveryServiceMesh,
+		CodeBuildProject: "proj",
+		BuildBucket:      "bucket",
+	}))
+
+	call := regexp.MustCompile(`\.aws\.(\w+)\.([A-Z]\w+)\(`)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range call.FindAllStringSubmatch(string(src), -1) {
+			prefix, ok := prefixes[m[1]]
+			if !ok {
+				t.Errorf("%s: call on unmapped client %s.%s", f, m[1], m[2])
+				continue
+			}
+			action := prefix + ":" + m[2]
+			if _, ok := declared[action]; !ok {
+				t.Errorf("%s: %s.%s calls %s, which requiredPermissions does not declare", f, m[1], m[2], action)
+			}
+		}
+	}
+}
+
+func TestRequiredPermissions_FollowDriverSelection(t *testing.T) {
+	base := permissionIndex(requiredPermissions(Config{NetworkDiscovery: api.NetworkDiscoveryHostAliases}))
+	if _, ok := base["servicediscovery:RegisterInstance"]; ok {
+		t.Error("host-aliases discovery must not require Cloud Map")
+	}
+	if _, ok := base["codebuild:StartBuild"]; ok {
+		t.Error("CodeBuild is only required when a project and bucket are configured")
+	}
+	if _, ok := base["iam:PassRole"]; ok {
+		t.Error("iam:PassRole is only required when task or execution roles are configured")
+	}
+	exec := base["ecs:ExecuteCommand"]
+	if !containsString(exec.Verbs, "exec") || !containsString(exec.Verbs, "cp") {
+		t.Errorf("ecs:ExecuteCommand verbs = %v, want exec and cp", exec.Verbs)
+	}
+	if !containsString(base["elasticfilesystem:CreateAccessPoint"].Verbs, "volume create") {
+		t.Error("efs-ephemeral backing must declare elasticfilesystem:CreateAccessPoint for volume create")
+	}
+
+	full := requiredPermissions(Config{
+		NetworkDiscovery: api.NetworkDiscoveryServiceMesh,
+		ExecutionRoleARN: "arn:aws:iam::123456789012:role/exec",
+		CodeBuildProject: "proj",
+		BuildBucket:      "bucket",
+	})
+	var passRole, putObject []string
+	for _, r := range full.Requirements() {
+		switch r.Permission {
+		case "iam:PassRole":
+			passRole = append(passRole, r.Resource)
+		case "s3:PutObject":
+			putObject = append(putObject, r.Resource)
+		}
+	}
+	if len(passRole) != 1 || passRole[0] != "arn:aws:iam::123456789012:role/exec" {
+		t.Errorf("iam:PassRole resources = %v", passRole)
+	}
+	if len(putObject) != 1 || putObject[0] != "arn:aws:s3:::bucket/*" {
+		t.Errorf("s3:PutObject resources = %v", putObject)
+	}
+	if _, ok := permissionIndex(full)["servicediscovery:RegisterInstance"]; !ok {
+		t.Error("service-mesh discovery must require servicediscovery:RegisterInstance")
+	}
+}
+
+func containsString(list []string, v string) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
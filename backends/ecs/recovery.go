@@ -10,8 +10,16 @@ import (
 	core "github.com/sockerless/backend-core"
 )
 
-// ScanOrphanedResources discovers Sockerless-managed ECS tasks.
+// ScanOrphanedResources discovers Sockerless-managed ECS services and
+// tasks.
 func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) ([]core.ResourceEntry, error) {
+	// Services first: a service's task stopped on its own is just
+	// replaced by the scheduler, so the service is what must be found.
+	orphans, err := s.scanOrphanedServices(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
 	listResult, err := s.aws.ECS.ListTasks(ctx, &awsecs.ListTasksInput{
 		Cluster: aws.String(s.config.Cluster),
 	})
@@ -20,7 +28,7 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 	}
 
 	if len(listResult.TaskArns) == 0 {
-		return nil, nil
+		return orphans, nil
 	}
 
 	descResult, err := s.aws.ECS.DescribeTasks(ctx, &awsecs.DescribeTasksInput{
@@ -31,7 +39,6 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 		return nil, err
 	}
 
-	var orphans []core.ResourceEntry
 	for _, task := range descResult.Tasks {
 		// Skip STOPPED / DEPROVISIONING tasks — they're already
 		// terminated and ECS will expire them after ~1h. Treating them
@@ -53,19 +60,10 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 			continue
 		}
 
-		managed := false
-		matchesInstance := false
-		for _, tag := range tagsResult.Tags {
-			if aws.ToString(tag.Key) == "sockerless-managed" && aws.ToString(tag.Value) == "true" {
-				managed = true
-			}
-			if aws.ToString(tag.Key) == "sockerless-instance" && aws.ToString(tag.Value) == instanceID {
-				matchesInstance = true
-			}
-		}
-
-		if managed && matchesInstance {
+		tags := tagsToMap(tagsResult.Tags)
+		if tags["sockerless-managed"] == "true" && tags["sockerless-instance"] == instanceID {
 			orphans = append(orphans, core.ResourceEntry{
+				ContainerID:  tags["sockerless-container-id"],
 				Backend:      "ecs",
 				ResourceType: "task",
 				ResourceID:   taskARN,
@@ -78,23 +76,85 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 	return orphans, nil
 }
 
+// scanOrphanedServices lists the cluster's services carrying this
+// instance's sockerless tags.
+func (s *Server) scanOrphanedServices(ctx context.Context, instanceID string) ([]core.ResourceEntry, error) {
+	listResult, err := s.aws.ECS.ListServices(ctx, &awsecs.ListServicesInput{
+		Cluster: aws.String(s.config.Cluster),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []core.ResourceEntry
+	arns := listResult.ServiceArns
+	for i := 0; i < len(arns); i += 10 {
+		descResult, err := s.aws.ECS.DescribeServices(ctx, &awsecs.DescribeServicesInput{
+			Cluster:  aws.String(s.config.Cluster),
+			Services: arns[i:minInt(i+10, len(arns))],
+			Include:  []ecstypes.ServiceField{ecstypes.ServiceFieldTags},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range descResult.Services {
+			tags := tagsToMap(svc.Tags)
+			if tags["sockerless-managed"] != "true" || tags["sockerless-instance"] != instanceID {
+				continue
+			}
+			orphans = append(orphans, core.ResourceEntry{
+				ContainerID:  tags["sockerless-container-id"],
+				Backend:      "ecs",
+				ResourceType: "service",
+				ResourceID:   aws.ToString(svc.ServiceArn),
+				InstanceID:   instanceID,
+				CreatedAt:    time.Now(),
+			})
+		}
+	}
+	return orphans, nil
+}
+
 // SyncResources queries ECS for the current status of all tracked tasks
-// and updates the registry (mark stopped tasks, remove deleted ones).
+// and services and updates the registry (mark stopped tasks and deleted
+// services as cleaned up).
 func (s *Server) SyncResources(ctx context.Context, registry *core.ResourceRegistry) error {
 	active := registry.ListActive()
 	if len(active) == 0 {
 		return nil
 	}
 
-	// Collect task ARNs to check
-	var arns []string
+	// Collect task and service ARNs to check
+	var arns, serviceARNs []string
 	for _, entry := range active {
-		if entry.ResourceType == "task" {
+		switch entry.ResourceType {
+		case "task":
 			arns = append(arns, entry.ResourceID)
+		case "service":
+			serviceARNs = append(serviceARNs, entry.ResourceID)
 		}
 	}
-	if len(arns) == 0 {
-		return nil
+
+	// DescribeServices supports up to 10 services per call
+	for i := 0; i < len(serviceARNs); i += 10 {
+		batch := serviceARNs[i:minInt(i+10, len(serviceARNs))]
+		result, err := s.aws.ECS.DescribeServices(ctx, &awsecs.DescribeServicesInput{
+			Cluster:  aws.String(s.config.Cluster),
+			Services: batch,
+		})
+		if err != nil {
+			s.Logger.Warn().Err(err).Msg("resync: DescribeServices failed")
+			continue
+		}
+		found := make(map[string]string) // arn → status
+		for _, svc := range result.Services {
+			found[aws.ToString(svc.ServiceArn)] = aws.ToString(svc.Status)
+		}
+		for _, arn := range batch {
+			if status, exists := found[arn]; !exists || status == "INACTIVE" {
+				registry.MarkCleanedUp(arn)
+			}
+		}
 	}
 
 	// DescribeTasks supports up to 100 ARNs per call
@@ -132,8 +192,17 @@ func (s *Server) SyncResources(ctx context.Context, registry *core.ResourceRegis
 	return nil
 }
 
-// CleanupResource stops an ECS task.
+// CleanupResource stops an ECS task, or force-deletes an ECS service
+// (which stops its tasks).
 func (s *Server) CleanupResource(ctx context.Context, entry core.ResourceEntry) error {
+	if entry.ResourceType == "service" {
+		_, err := s.aws.ECS.DeleteService(ctx, &awsecs.DeleteServiceInput{
+			Cluster: aws.String(s.config.Cluster),
+			Service: aws.String(entry.ResourceID),
+			Force:   aws.Bool(true),
+		})
+		return err
+	}
 	_, err := s.aws.ECS.StopTask(ctx, &awsecs.StopTaskInput{
 		Cluster: aws.String(s.config.Cluster),
 		Task:    aws.String(entry.ResourceID),
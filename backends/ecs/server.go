@@ -29,6 +29,9 @@ type Server struct {
 	// the buffered bytes into the task definition's command override
 	// (Fargate has no remote stdin channel for a running task).
 	stdinPipes sync.Map
+	// ssmSessions maps a running exec ID to its SSM session decoder so
+	// ExecResize can send the session a size frame.
+	ssmSessions sync.Map
 }
 
 // NewServer creates a new ECS backend server.
@@ -69,10 +72,24 @@ func NewServer(config Config, awsClients *AWSClients, logger zerolog.Logger) *Se
 	s.storageBackings.Register(awscommon.NewEFSEphemeralDriver(s.efs))
 	s.storageBackings.Register(core.NewMemoryDriver(64))
 	ecrAuth := awscommon.NewECRAuthProvider(awsClients.ECR, logger, s.ctx)
+	mirrorAuth, err := config.RegistryMirror.Credentials()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid registry mirror credentials")
+	}
+	s.ComposeUnits = true
+	s.Mirrors = &awscommon.ECRMirrors{
+		ECR:         awsClients.ECR,
+		Secrets:     awsClients.Secrets,
+		AccountID:   extractAccountID(config.ExecutionRoleARN),
+		Region:      config.Region,
+		Credentials: mirrorAuth,
+		Logger:      logger,
+	}
 	s.images = &core.ImageManager{
-		Base:   s.BaseServer,
-		Auth:   ecrAuth,
-		Logger: logger,
+		Base:           s.BaseServer,
+		Auth:           ecrAuth,
+		LoadRepository: loadImageRepository(config),
+		Logger:         logger,
 	}
 	if svc := awscommon.NewCodeBuildService(
 		awsClients.CodeBuild, awsClients.S3,
@@ -81,7 +98,22 @@ func NewServer(config Config, awsClients *AWSClients, logger zerolog.Logger) *Se
 		s.images.BuildService = svc
 	}
 	s.SetSelf(s)
-	s.StatsProvider = &ecsStatsProvider{server: s}
+	s.StatsProvider = &core.StatsSources{Cloud: &ecsStatsProvider{server: s}}
+	s.ConfigureCosts(config.Costs, s)
+	var auditSink core.AuditSink
+	if config.Audit.Sink != "" {
+		auditSink = &awscommon.CloudWatchAuditSink{Client: awsClients.CloudWatch, Group: config.Audit.Sink, Stream: s.Desc.InstanceID}
+	}
+	s.ConfigureAudit(config.Audit, auditSink)
+	if config.HA.Mode != "" {
+		s.ConfigureHA(config.HA, &awscommon.DynamoDBLeaseStore{Client: awsClients.DynamoDB, Table: config.HA.Store})
+	}
+	s.ConfigureImageScanning(config.ImageScan,
+		awscommon.NewECRScanner(awsClients.ECR, s.resolveImageURI, config.PollInterval, core.ImageScanTimeout),
+		s.images.WalkImageLayers)
+	if err := s.ConfigureImageVerification(config.ImageVerify, s.images, s.resolveImageURI); err != nil {
+		logger.Fatal().Err(err).Msg("invalid image signature policy")
+	}
 	// Network-discovery driver. Selected via Config.NetworkDiscovery
 	// (env: SOCKERLESS_ECS_NETWORK_DISCOVERY). Validated to one of
 	// service-mesh / host-aliases / nat-gateway-only by Config.Validate.
@@ -126,6 +158,10 @@ func NewServer(config Config, awsClients *AWSClients, logger zerolog.Logger) *Se
 		s.NetworkDiscovery = core.NoOpNetworkDiscovery{}
 	}
 	s.Access = awscommon.NewIAMRoleAccess(config.TaskRoleARN)
+	s.HealthChecker = core.NewPermissionPreflight(
+		awscommon.NewIAMPermissionProber(awsClients.IAM, awsClients.STS),
+		requiredPermissions(config),
+	)
 	s.CloudState = &ecsCloudState{
 		ecs:      awsClients.ECS,
 		ecr:      awsClients.ECR,
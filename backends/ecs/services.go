@@ -0,0 +1,353 @@
+package ecs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecs "github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// capacityLabel selects the Fargate capacity provider a container's
+// task runs on. Unset keeps the plain FARGATE launch type.
+const capacityLabel = "sockerless.capacity"
+
+// capacityProviderStrategy maps the sockerless.capacity label onto an
+// ECS capacity provider strategy. `spot` places the task on
+// FARGATE_SPOT; `ondemand` (alias `fargate`) pins it to the FARGATE
+// provider. Both providers must be associated with the cluster.
+func capacityProviderStrategy(labels map[string]string) ([]ecstypes.CapacityProviderStrategyItem, error) {
+	var provider string
+	switch v := labels[capacityLabel]; v {
+	case "":
+		return nil, nil
+	case "spot":
+		provider = "FARGATE_SPOT"
+	case "ondemand", "fargate":
+		provider = "FARGATE"
+	default:
+		return nil, &api.InvalidParameterError{Message: fmt.Sprintf(
+			"invalid %s label %q: must be one of spot, ondemand", capacityLabel, v)}
+	}
+	return []ecstypes.CapacityProviderStrategyItem{
+		{CapacityProvider: aws.String(provider), Weight: 1},
+	}, nil
+}
+
+// runsAsService reports whether a container's restart policy is
+// mapped onto an ECS service rather than a one-shot task. `always` and
+// `unless-stopped` both mean "keep one replica running", which is what
+// the service scheduler does; `on-failure` keeps its retry budget and
+// therefore stays on RunTask.
+func runsAsService(policy api.RestartPolicy) bool {
+	switch policy.Name {
+	case "always", "unless-stopped":
+		return true
+	}
+	return false
+}
+
+// ecsServiceName is the deterministic service name for a container, so
+// a restarted backend finds the service again without local state.
+func ecsServiceName(containerID string) string {
+	return "sockerless-" + containerID[:12]
+}
+
+// startECSService creates (or scales back up) the container's ECS
+// service and waits for the scheduler to place its task. Returns the
+// task, cluster and service ARNs.
+func (s *Server) startECSService(containerID, taskDefARN string, c *api.Container) (taskARN, clusterARN, serviceARN string, err error) {
+	launch, err := s.taskLaunchParams(containerID, c)
+	if err != nil {
+		return "", "", "", err
+	}
+	name := ecsServiceName(containerID)
+
+	existing, err := s.describeECSService(s.ctx(), name)
+	if err != nil {
+		return "", "", "", err
+	}
+	var svc *ecstypes.Service
+	if existing != nil {
+		// docker start after docker stop: the service is still ACTIVE
+		// at desiredCount 0; roll it onto the fresh task definition.
+		out, err := s.aws.ECS.UpdateService(s.ctx(), &awsecs.UpdateServiceInput{
+			Cluster:                  aws.String(s.config.Cluster),
+			Service:                  aws.String(name),
+			TaskDefinition:           aws.String(taskDefARN),
+			DesiredCount:             aws.Int32(1),
+			CapacityProviderStrategy: launch.capacityProviderStrategy,
+			NetworkConfiguration:     launch.networkConfiguration,
+		})
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to update service: %w", err)
+		}
+		svc = out.Service
+	} else {
+		input := &awsecs.CreateServiceInput{
+			Cluster:                  aws.String(s.config.Cluster),
+			ServiceName:              aws.String(name),
+			TaskDefinition:           aws.String(taskDefARN),
+			DesiredCount:             aws.Int32(1),
+			CapacityProviderStrategy: launch.capacityProviderStrategy,
+			NetworkConfiguration:     launch.networkConfiguration,
+			EnableExecuteCommand:     true,
+			// Service tags carry the sockerless-container-id the
+			// cloud-state reader keys on; propagate them onto every
+			// task the scheduler launches, replacements included.
+			Tags:          launch.tags,
+			PropagateTags: ecstypes.PropagateTagsService,
+		}
+		if len(launch.capacityProviderStrategy) == 0 {
+			input.LaunchType = ecstypes.LaunchTypeFargate
+		}
+		out, err := s.aws.ECS.CreateService(s.ctx(), input)
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to create service: %w", err)
+		}
+		svc = out.Service
+	}
+	serviceARN = aws.ToString(svc.ServiceArn)
+	clusterARN = aws.ToString(svc.ClusterArn)
+
+	s.Registry.Register(core.ResourceEntry{
+		ContainerID:  containerID,
+		Backend:      "ecs",
+		ResourceType: "service",
+		ResourceID:   serviceARN,
+		InstanceID:   s.Desc.InstanceID,
+		CreatedAt:    time.Now(),
+		Metadata:     map[string]string{"image": c.Image, "name": c.Name, "serviceName": name},
+	})
+
+	taskARN, err = s.waitForServiceTask(s.ctx(), name, nil, 2*time.Minute)
+	if err != nil {
+		return "", "", "", err
+	}
+	s.Registry.Register(core.ResourceEntry{
+		ContainerID:  containerID,
+		Backend:      "ecs",
+		ResourceType: "task",
+		ResourceID:   taskARN,
+		InstanceID:   s.Desc.InstanceID,
+		CreatedAt:    time.Now(),
+		Metadata:     map[string]string{"image": c.Image, "name": c.Name, "taskArn": taskARN, "serviceName": name},
+	})
+	return taskARN, clusterARN, serviceARN, nil
+}
+
+// describeECSService returns the named service, or nil when it does
+// not exist or has been deleted (INACTIVE).
+func (s *Server) describeECSService(ctx context.Context, name string) (*ecstypes.Service, error) {
+	out, err := s.aws.ECS.DescribeServices(ctx, &awsecs.DescribeServicesInput{
+		Cluster:  aws.String(s.config.Cluster),
+		Services: []string{name},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe service %s: %w", name, err)
+	}
+	for i := range out.Services {
+		if aws.ToString(out.Services[i].Status) != "INACTIVE" {
+			return &out.Services[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// containerServiceName returns the ECS service backing a container, or
+// "" for containers launched as one-shot tasks. The in-memory ARN is
+// authoritative; after a backend restart the deterministic name is
+// looked up in ECS instead — only for containers whose restart policy
+// runs them as a service, so one-shot containers never reach ECS.
+func (s *Server) containerServiceName(c api.Container) string {
+	containerID := c.ID
+	if state, ok := s.ECS.Get(containerID); ok && state.ServiceARN != "" {
+		return ecsServiceName(containerID)
+	}
+	if !runsAsService(c.HostConfig.RestartPolicy) {
+		return ""
+	}
+	svc, err := s.describeECSService(s.ctx(), ecsServiceName(containerID))
+	if err != nil || svc == nil {
+		return ""
+	}
+	s.ECS.Update(containerID, func(state *ECSState) {
+		state.ServiceARN = aws.ToString(svc.ServiceArn)
+	})
+	return aws.ToString(svc.ServiceName)
+}
+
+// waitForServiceTask polls the service for a RUNNING-desired task that
+// isn't in skip. The scheduler places tasks asynchronously, so neither
+// CreateService nor a replacement after a task exit returns the ARN.
+func (s *Server) waitForServiceTask(ctx context.Context, serviceName string, skip map[string]bool, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := s.aws.ECS.ListTasks(ctx, &awsecs.ListTasksInput{
+			Cluster:       aws.String(s.config.Cluster),
+			ServiceName:   aws.String(serviceName),
+			DesiredStatus: ecstypes.DesiredStatusRunning,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to list tasks for service %s: %w", serviceName, err)
+		}
+		for _, arn := range out.TaskArns {
+			if !skip[arn] {
+				return arn, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("service %s did not place a task within %s", serviceName, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(s.config.PollInterval):
+		}
+	}
+}
+
+// scaleServiceToZero stops the scheduler from replacing the
+// container's task. docker stop / kill / restart all end the running
+// replica on purpose; without this the service would start another.
+func (s *Server) scaleServiceToZero(serviceName string) error {
+	_, err := s.aws.ECS.UpdateService(s.ctx(), &awsecs.UpdateServiceInput{
+		Cluster:      aws.String(s.config.Cluster),
+		Service:      aws.String(serviceName),
+		DesiredCount: aws.Int32(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scale service %s to zero: %w", serviceName, err)
+	}
+	return nil
+}
+
+// deleteECSService force-deletes the container's service on docker rm.
+func (s *Server) deleteECSService(containerID, serviceName string) {
+	out, err := s.aws.ECS.DeleteService(s.ctx(), &awsecs.DeleteServiceInput{
+		Cluster: aws.String(s.config.Cluster),
+		Service: aws.String(serviceName),
+		Force:   aws.Bool(true),
+	})
+	if err != nil {
+		var notFound *ecstypes.ServiceNotFoundException
+		if !errors.As(err, &notFound) {
+			s.Logger.Warn().Err(err).Str("container", containerID[:12]).Msg("failed to delete ECS service")
+		}
+		return
+	}
+	s.Registry.MarkCleanedUp(aws.ToString(out.Service.ServiceArn))
+}
+
+// watchServiceTasks follows a service-backed container across task
+// replacements. Each scheduler replacement surfaces as die + start
+// (the restart-policy shape docker clients expect) and bumps the
+// restart count. Returns when the container is stopped through the API
+// (exitCh closed) or the service no longer wants a replica.
+func (s *Server) watchServiceTasks(containerID, serviceName, taskARN string, exitCh chan struct{}) {
+	ticker := time.NewTicker(s.config.PollInterval * 2)
+	defer ticker.Stop()
+
+	seen := map[string]bool{}
+	for {
+		select {
+		case <-exitCh:
+			return
+		case <-ticker.C:
+		}
+
+		result, err := s.aws.ECS.DescribeTasks(s.ctx(), &awsecs.DescribeTasksInput{
+			Cluster: aws.String(s.config.Cluster),
+			Tasks:   []string{taskARN},
+			Include: []ecstypes.TaskField{ecstypes.TaskFieldTags},
+		})
+		if err != nil || len(result.Tasks) == 0 {
+			continue
+		}
+		task := result.Tasks[0]
+		if aws.ToString(task.LastStatus) != "STOPPED" {
+			continue
+		}
+		seen[taskARN] = true
+		// Scale-down stops come from docker stop / kill / restart,
+		// which emit their own events.
+		if task.StopCode != ecstypes.TaskStopCodeServiceSchedulerInitiated {
+			s.emitTaskDie(containerID, task)
+		}
+
+		svc, err := s.describeECSService(s.ctx(), serviceName)
+		if err != nil || svc == nil || svc.DesiredCount == 0 {
+			s.applyTaskStatus(containerID, task)
+			return
+		}
+
+		next, err := s.waitForServiceTask(s.ctx(), serviceName, seen, 10*time.Minute)
+		if err != nil {
+			s.Logger.Warn().Err(err).Str("container", containerID[:12]).Msg("service did not replace stopped task")
+			s.applyTaskStatus(containerID, task)
+			return
+		}
+		select {
+		case <-exitCh:
+			return
+		default:
+		}
+
+		restartCount := 1
+		if state, ok := s.ECS.Get(containerID); ok {
+			restartCount = state.RestartCount + 1
+		}
+		_, _ = s.aws.ECS.TagResource(s.ctx(), &awsecs.TagResourceInput{
+			ResourceArn: aws.String(next),
+			Tags: []ecstypes.Tag{
+				{Key: aws.String("sockerless-restart-count"), Value: aws.String(strconv.Itoa(restartCount))},
+			},
+		})
+		s.ECS.Update(containerID, func(state *ECSState) {
+			state.TaskARN = next
+			state.RestartCount = restartCount
+		})
+		s.Registry.MarkCleanedUp(taskARN)
+		s.Registry.Register(core.ResourceEntry{
+			ContainerID:  containerID,
+			Backend:      "ecs",
+			ResourceType: "task",
+			ResourceID:   next,
+			InstanceID:   s.Desc.InstanceID,
+			CreatedAt:    time.Now(),
+			Metadata:     map[string]string{"taskArn": next, "serviceName": serviceName},
+		})
+		taskARN = next
+
+		attrs := map[string]string{}
+		if name := tagsToMap(task.Tags)["sockerless-name"]; name != "" {
+			attrs["name"] = strings.TrimPrefix(name, "/")
+		}
+		s.EmitEvent("container", "start", containerID, attrs)
+	}
+}
+
+// emitTaskDie reports a task stop the backend didn't initiate. Spot
+// reclaims carry reason=spot-interruption so event consumers can tell
+// a capacity loss from the workload exiting on its own.
+func (s *Server) emitTaskDie(containerID string, task ecstypes.Task) {
+	tags := tagsToMap(task.Tags)
+	attrs := map[string]string{
+		"exitCode": strconv.Itoa(mapTaskStatus(task, tags).ExitCode),
+	}
+	if name := tags["sockerless-name"]; name != "" {
+		attrs["name"] = strings.TrimPrefix(name, "/")
+	}
+	if task.StopCode == ecstypes.TaskStopCodeSpotInterruption {
+		attrs["reason"] = "spot-interruption"
+		attrs["stopCode"] = string(task.StopCode)
+	}
+	s.EmitEvent("container", "die", containerID, attrs)
+}
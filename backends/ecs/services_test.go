@@ -0,0 +1,69 @@
+package ecs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/sockerless/api"
+)
+
+func TestCapacityProviderStrategy(t *testing.T) {
+	tests := []struct {
+		label    string
+		provider string
+	}{
+		{"", ""},
+		{"spot", "FARGATE_SPOT"},
+		{"ondemand", "FARGATE"},
+		{"fargate", "FARGATE"},
+	}
+	for _, tt := range tests {
+		labels := map[string]string{}
+		if tt.label != "" {
+			labels[capacityLabel] = tt.label
+		}
+		strategy, err := capacityProviderStrategy(labels)
+		if err != nil {
+			t.Fatalf("label %q: unexpected error: %v", tt.label, err)
+		}
+		if tt.provider == "" {
+			if strategy != nil {
+				t.Fatalf("label %q: expected no strategy, got %+v", tt.label, strategy)
+			}
+			continue
+		}
+		if len(strategy) != 1 || aws.ToString(strategy[0].CapacityProvider) != tt.provider || strategy[0].Weight != 1 {
+			t.Fatalf("label %q: expected [%s weight 1], got %+v", tt.label, tt.provider, strategy)
+		}
+	}
+}
+
+func TestCapacityProviderStrategy_Invalid(t *testing.T) {
+	_, err := capacityProviderStrategy(map[string]string{capacityLabel: "preemptible"})
+	var invalid *api.InvalidParameterError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected InvalidParameterError, got %v", err)
+	}
+}
+
+func TestRunsAsService(t *testing.T) {
+	for name, want := range map[string]bool{
+		"":               false,
+		"no":             false,
+		"on-failure":     false,
+		"always":         true,
+		"unless-stopped": true,
+	} {
+		if got := runsAsService(api.RestartPolicy{Name: name}); got != want {
+			t.Errorf("runsAsService(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestECSServiceName(t *testing.T) {
+	id := "abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890"
+	if got := ecsServiceName(id); got != "sockerless-abcdef123456" {
+		t.Fatalf("unexpected service name %q", got)
+	}
+}
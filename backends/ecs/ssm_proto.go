@@ -12,6 +12,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -47,6 +48,10 @@ const (
 	ssmPayloadExitCode = uint32(12)
 )
 
+// ssmPayloadSize is the input_stream_data PayloadType carrying a
+// terminal size as JSON (`{"cols":W,"rows":H}`).
+const ssmPayloadSize = uint32(3)
+
 // ssmFrame is a parsed SSM AgentMessage / ClientMessage.
 type ssmFrame struct {
 	HeaderLength   uint32
@@ -177,12 +182,31 @@ func ssmFrameDigestOK(f *ssmFrame) bool {
 }
 
 // inputSequence is a monotonically-increasing per-process sequence number
-// for outbound input_stream_data frames.
-var inputSequence int64
+// for outbound input_stream_data frames. Atomic because stdin and
+// resize frames are built from different goroutines.
+var inputSequence atomic.Int64
 
 // buildSSMInput wraps a stdin payload in an `input_stream_data`
 // AgentMessage with PayloadType=1 (raw bytes).
 func buildSSMInput(payload []byte) ([]byte, error) {
+	return buildSSMInputFrame(ssmPayloadOutput, payload)
+}
+
+// buildSSMSize wraps a terminal size in an `input_stream_data`
+// AgentMessage with PayloadType=3, the frame session-manager-plugin
+// sends on SIGWINCH.
+func buildSSMSize(cols, rows int) ([]byte, error) {
+	payload, err := json.Marshal(struct {
+		Cols uint32 `json:"cols"`
+		Rows uint32 `json:"rows"`
+	}{uint32(cols), uint32(rows)})
+	if err != nil {
+		return nil, err
+	}
+	return buildSSMInputFrame(ssmPayloadSize, payload)
+}
+
+func buildSSMInputFrame(payloadType uint32, payload []byte) ([]byte, error) {
 	digest := sha256.Sum256(payload)
 	out := make([]byte, ssmFixedHeaderLen+len(payload))
 	binary.BigEndian.PutUint32(out[0:4], ssmHeaderLen)
@@ -195,8 +219,7 @@ func buildSSMInput(payload []byte) ([]byte, error) {
 
 	binary.BigEndian.PutUint32(out[36:40], 1)
 	binary.BigEndian.PutUint64(out[40:48], uint64(time.Now().UnixMilli()))
-	inputSequence++
-	binary.BigEndian.PutUint64(out[48:56], uint64(inputSequence))
+	binary.BigEndian.PutUint64(out[48:56], uint64(inputSequence.Add(1)))
 	binary.BigEndian.PutUint64(out[56:64], ssmFlagsSynFin)
 
 	id := uuid.New()
@@ -206,7 +229,7 @@ func buildSSMInput(payload []byte) ([]byte, error) {
 	copy(out[72:80], idBytes[0:8])  // MSL
 
 	copy(out[80:112], digest[:])
-	binary.BigEndian.PutUint32(out[112:116], ssmPayloadOutput) // PayloadType=1 (raw)
+	binary.BigEndian.PutUint32(out[112:116], payloadType)
 	binary.BigEndian.PutUint32(out[116:120], uint32(len(payload)))
 	copy(out[ssmFixedHeaderLen:], payload)
 	return out, nil
@@ -149,6 +149,29 @@ func TestBuildSSMInput_UUIDWireOrder(t *testing.T) {
 	}
 }
 
+// TestBuildSSMSize_RoundTrip — the resize frame is input_stream_data
+// with PayloadType=3 and the plugin's {"cols","rows"} JSON.
+func TestBuildSSMSize_RoundTrip(t *testing.T) {
+	wire, err := buildSSMSize(120, 40)
+	if err != nil {
+		t.Fatalf("buildSSMSize: %v", err)
+	}
+	parsed, err := parseSSMFrame(wire)
+	if err != nil {
+		t.Fatalf("re-parse: %v", err)
+	}
+	if parsed.MessageType != ssmMTInputStreamData || parsed.PayloadType != ssmPayloadSize {
+		t.Fatalf("frame = %q payloadType=%d, want input_stream_data payloadType=3", parsed.MessageType, parsed.PayloadType)
+	}
+	var size struct{ Cols, Rows int }
+	if err := json.Unmarshal(parsed.Payload, &size); err != nil {
+		t.Fatal(err)
+	}
+	if size.Cols != 120 || size.Rows != 40 {
+		t.Errorf("size = %+v, want cols=120 rows=40", size)
+	}
+}
+
 func TestBuildSSMAck_RoundTrip(t *testing.T) {
 	recv := &ssmFrame{
 		MessageType:    "output_stream_data",
@@ -2,6 +2,7 @@ package ecs
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
@@ -11,8 +12,9 @@ import (
 	core "github.com/sockerless/backend-core"
 )
 
-// ecsStatsProvider fetches real container metrics from CloudWatch Container Insights.
-// Replaces all-zero synthetic stats with real CloudWatch data.
+// ecsStatsProvider fetches task metrics from CloudWatch Container
+// Insights. ECS tasks have no reverse agent, so this is the only
+// source behind core.StatsSources.
 type ecsStatsProvider struct {
 	server *Server
 }
@@ -77,30 +79,34 @@ func (p *ecsStatsProvider) ContainerMetrics(containerID string) (*core.Container
 		},
 	})
 	if err != nil {
-		// CloudWatch may not have data yet for new tasks. Return zeros
-		// rather than invent a PID count — docker stats will show 0/0
-		// until real metrics arrive.
-		return &core.ContainerMetrics{}, nil
+		return nil, fmt.Errorf("cloudwatch GetMetricData: %w", err)
 	}
 
-	var cpuUnits, memMB float64
+	// Container Insights publishes one-minute averages: CpuUtilized in
+	// CPU units (1024 per vCPU), MemoryUtilized in MiB. Values come
+	// newest first; SampledAt is the newest period both metrics cover.
+	m := &core.ContainerMetrics{Source: "cloudwatch"}
+	var cpuAt, memAt time.Time
 	for _, r := range result.MetricDataResults {
-		if len(r.Values) == 0 {
+		if len(r.Values) == 0 || len(r.Timestamps) == 0 {
 			continue
 		}
-		latest := r.Values[0]
 		switch aws.ToString(r.Id) {
 		case "cpu":
-			cpuUnits = latest
+			m.CPUCores = r.Values[0] / 1024
+			cpuAt = r.Timestamps[0]
 		case "mem":
-			memMB = latest
+			m.MemBytes = int64(r.Values[0] * 1024 * 1024)
+			memAt = r.Timestamps[0]
 		}
 	}
-
-	// Return real CloudWatch metrics or zeros; don't fake usage from allocation
-	return &core.ContainerMetrics{
-		CPUNanos: int64(cpuUnits * 1e9 / 1024),
-		MemBytes: int64(memMB * 1024 * 1024),
-		PIDs:     0,
-	}, nil
+	switch {
+	case cpuAt.IsZero() || memAt.IsZero():
+		// No datapoint yet for a new task; SampledAt stays zero.
+	case cpuAt.Before(memAt):
+		m.SampledAt = cpuAt
+	default:
+		m.SampledAt = memAt
+	}
+	return m, nil
 }
@@ -7,6 +7,7 @@ type ECSState struct {
 	ClusterARN       string   // Cluster ARN
 	SecurityGroupIDs []string // Security groups from network associations (multiple networks)
 	ServiceID        string   // Cloud Map service ID for service discovery
+	ServiceARN       string   // ECS service ARN for restart-policy containers
 	RestartCount     int      // Next value for sockerless-restart-count tag on RunTask
 	// OpenStdin: container was created with OpenStdin && AttachStdin
 	// (gitlab-runner / `docker run -i` pattern). The attach driver
@@ -168,6 +168,11 @@ func (s *Server) registerTaskDefinition(ctx context.Context, containers []contai
 		allDefs = append(allDefs, def)
 		allVolumes = append(allVolumes, vols...)
 	}
+	if len(containers) > 1 {
+		if err := applyComposeDependencies(allDefs, containers); err != nil {
+			return "", err
+		}
+	}
 
 	// Family name uses the first (main) container ID
 	family := fmt.Sprintf("sockerless-%s", containers[0].ID[:12])
@@ -221,6 +226,58 @@ func (s *Server) registerTaskDefinition(ctx context.Context, containers []contai
 	return aws.ToString(result.TaskDefinition.TaskDefinitionArn), nil
 }
 
+// applyComposeDependencies wires a compose unit's depends_on into the
+// task definition: each dependency becomes a container dependsOn and a
+// service_healthy dependency gets the Docker health check as its ECS
+// healthCheck, so Fargate starts containers in compose order. A unit
+// holds no service_completed_successfully dependencies: their targets
+// and dependents run standalone (core composeUnitOrder).
+func applyComposeDependencies(defs []ecstypes.ContainerDefinition, containers []containerInput) error {
+	unit := make([]api.Container, len(containers))
+	index := make(map[string]int, len(containers))
+	for i, ci := range containers {
+		unit[i] = *ci.Container
+		index[ci.ID] = i
+	}
+	for id, deps := range core.ComposeUnitDependencies(unit) {
+		def := &defs[index[id]]
+		for _, d := range deps {
+			target := index[d.ContainerID]
+			condition := ecstypes.ContainerConditionStart
+			if d.Condition == core.ComposeServiceHealthy {
+				if !core.HasHealthcheck(unit[target]) {
+					return &api.InvalidParameterError{Message: fmt.Sprintf("service %q depends on %q being healthy, which has no healthcheck",
+						unit[index[id]].Config.Labels[core.ComposeServiceLabel], unit[target].Config.Labels[core.ComposeServiceLabel])}
+				}
+				condition = ecstypes.ContainerConditionHealthy
+				defs[target].HealthCheck = ecsHealthCheck(unit[target].Config.Healthcheck)
+			}
+			def.DependsOn = append(def.DependsOn, ecstypes.ContainerDependency{
+				ContainerName: defs[target].Name,
+				Condition:     condition,
+			})
+		}
+	}
+	return nil
+}
+
+// ecsHealthCheck converts a Docker health check to the ECS form. Both
+// use the same CMD / CMD-SHELL test syntax; timings are clamped to the
+// ranges ECS accepts.
+func ecsHealthCheck(hc *api.HealthcheckConfig) *ecstypes.HealthCheck {
+	interval, timeout, startPeriod, retries := core.HealthcheckTiming(hc)
+	seconds := func(d time.Duration, lo, hi int32) *int32 {
+		return aws.Int32(min(max(int32(d/time.Second), lo), hi))
+	}
+	return &ecstypes.HealthCheck{
+		Command:     hc.Test,
+		Interval:    seconds(interval, 5, 300),
+		Timeout:     seconds(timeout, 2, 60),
+		Retries:     aws.Int32(min(max(int32(retries), 1), 10)),
+		StartPeriod: seconds(startPeriod, 0, 300),
+	}
+}
+
 // fargateResource is a valid Fargate CPU/memory combination.
 type fargateResource struct {
 	cpu        int64   // in CPU units (256 = 0.25 vCPU)
@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
 )
 
 // testServer returns a minimal Server with config fields needed by buildContainerDef.
@@ -287,3 +289,45 @@ func TestSanitizeContainerName(t *testing.T) {
 		}
 	}
 }
+
+func TestApplyComposeDependencies(t *testing.T) {
+	compose := func(id, service, dependsOn string) containerInput {
+		return containerInput{ID: id, IsMain: id == "web", Container: &api.Container{
+			ID:   id,
+			Name: "/shop-" + service + "-1",
+			Config: api.ContainerConfig{Labels: map[string]string{
+				core.ComposeProjectLabel:   "shop",
+				core.ComposeServiceLabel:   service,
+				core.ComposeDependsOnLabel: dependsOn,
+			}},
+		}}
+	}
+	web := compose("web", "web", "db:service_healthy:false,cache:service_started:false")
+	db := compose("db", "db", "")
+	db.Container.Config.Healthcheck = &api.HealthcheckConfig{Test: []string{"CMD-SHELL", "pg_isready"}, Interval: 2e9, Retries: 20}
+	cache := compose("cache", "cache", "")
+	inputs := []containerInput{web, db, cache}
+	defs := []ecstypes.ContainerDefinition{{Name: aws.String("main")}, {Name: aws.String("shop-db-1")}, {Name: aws.String("shop-cache-1")}}
+
+	if err := applyComposeDependencies(defs, inputs); err != nil {
+		t.Fatal(err)
+	}
+	deps := defs[0].DependsOn
+	if len(deps) != 2 || aws.ToString(deps[0].ContainerName) != "shop-db-1" || deps[0].Condition != ecstypes.ContainerConditionHealthy ||
+		aws.ToString(deps[1].ContainerName) != "shop-cache-1" || deps[1].Condition != ecstypes.ContainerConditionStart {
+		t.Errorf("main dependsOn = %+v", deps)
+	}
+	hc := defs[1].HealthCheck
+	if hc == nil || hc.Command[0] != "CMD-SHELL" || aws.ToInt32(hc.Interval) != 5 || aws.ToInt32(hc.Retries) != 10 {
+		t.Errorf("db healthCheck = %+v", hc)
+	}
+	if defs[2].HealthCheck != nil {
+		t.Error("health check set on a service nothing waits for to be healthy")
+	}
+
+	db.Container.Config.Healthcheck = nil
+	defs = []ecstypes.ContainerDefinition{{Name: aws.String("main")}, {Name: aws.String("shop-db-1")}, {Name: aws.String("shop-cache-1")}}
+	if err := applyComposeDependencies(defs, inputs); err == nil {
+		t.Error("service_healthy on a service without a healthcheck accepted")
+	}
+}
@@ -0,0 +1,48 @@
+package gcpcommon
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/logging"
+	core "github.com/sockerless/backend-core"
+)
+
+// Compile-time check that CloudLoggingAuditSink implements core.AuditSink.
+var _ core.AuditSink = (*CloudLoggingAuditSink)(nil)
+
+// CloudLoggingAuditSink ships audit records to a Cloud Logging log as
+// JSON payloads; failed mutations are logged at ERROR severity. Its
+// client must not carry CloudAPIGRPCOption: the logger writes in the
+// background, outside the context that keeps sink writes out of the
+// audit log.
+type CloudLoggingAuditSink struct {
+	Logger *logging.Logger
+	Name   string
+}
+
+// NewCloudLoggingAuditSink writes to the log named logName in the
+// client's project.
+func NewCloudLoggingAuditSink(client *logging.Client, logName string) *CloudLoggingAuditSink {
+	return &CloudLoggingAuditSink{Logger: client.Logger(logName), Name: logName}
+}
+
+// WriteAudit implements core.AuditSink.
+func (s *CloudLoggingAuditSink) WriteAudit(_ context.Context, records []core.AuditRecord) error {
+	for _, rec := range records {
+		severity := logging.Info
+		if rec.Outcome == core.AuditOutcomeError {
+			severity = logging.Error
+		}
+		s.Logger.Log(logging.Entry{
+			Timestamp: rec.Time,
+			Severity:  severity,
+			Labels:    map[string]string{"backend": rec.Backend, "operation": rec.Operation},
+			Payload:   rec,
+		})
+	}
+	if err := s.Logger.Flush(); err != nil {
+		return fmt.Errorf("write audit entries to %s: %w", s.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package gcpcommon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	core "github.com/sockerless/backend-core"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CloudAPIGRPCOption is a client option for the gRPC clients (Cloud
+// Run, Cloud Functions, Logging) that counts every unary call into the
+// backend's sockerless_cloud_api_* metrics and records every call that
+// is not a read in the audit log. The service is the API's host name
+// prefix (run, cloudfunctions, logging) and the operation the RPC
+// method (RunJob, CreateFunction).
+func CloudAPIGRPCOption() option.ClientOption {
+	return option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(
+		func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			start := time.Now()
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			service, op := GRPCOperation(cc.Target(), method)
+			core.ObserveCloudAPICall(service, op, time.Since(start), err != nil)
+			if !core.IsCloudReadOperation(op) {
+				core.AuditCloudCall(ctx, service, op, GRPCResourceName(req), time.Since(start), err)
+			}
+			return err
+		}))
+}
+
+// CloudAPIHTTPClient returns an HTTP client for the REST clients (DNS,
+// Monitoring, Artifact Registry, Storage, ...) that counts every request
+// into the sockerless_cloud_api_* metrics and records mutations (see
+// RESTMutation) in the audit log. With authenticate set it
+// carries Application Default Credentials for the cloud-platform scope,
+// so it can stand in for the client the libraries would build; against
+// an endpoint (simulator) it is unauthenticated.
+func CloudAPIHTTPClient(ctx context.Context, authenticate bool) (*http.Client, error) {
+	tr := core.CloudAPITransport(restAuditTransport{next: http.DefaultTransport}, func(r *http.Request) (string, string) {
+		return RESTOperation(r.Method, r.URL.Host, r.URL.Path)
+	})
+	if authenticate {
+		var err error
+		tr, err = htransport.NewTransport(ctx, tr, option.WithScopes("https://www.googleapis.com/auth/cloud-platform"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &http.Client{Transport: tr}, nil
+}
+
+// GRPCOperation names a gRPC call for metrics from the connection target
+// ("run.googleapis.com:443") and full method
+// ("/google.cloud.run.v2.Jobs/RunJob"). A target that isn't a Google API
+// host (the simulator) falls back to the proto package's API name.
+func GRPCOperation(target, fullMethod string) (service, operation string) {
+	svc, op, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if service = googleAPIService(target); service == "" {
+		// google.cloud.run.v2.Jobs → run, google.logging.v2.LoggingServiceV2 → logging
+		parts := strings.Split(svc, ".")
+		for i := len(parts) - 2; i >= 0; i-- {
+			if p := parts[i]; !gcpAPIVersion.MatchString(p) && p != "google" && p != "cloud" {
+				service = p
+				break
+			}
+		}
+	}
+	return service, op
+}
+
+var gcpAPIVersion = regexp.MustCompile(`^v\d+((alpha|beta)\d*)?(p\d+(alpha|beta)\d*)?$`)
+
+// googleAPIService returns "run" for run.googleapis.com[:443] and the
+// regional run.us-central1.rep.googleapis.com, or "" for other hosts.
+func googleAPIService(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimPrefix(host, "dns:///")
+	if !strings.HasSuffix(host, ".googleapis.com") {
+		return ""
+	}
+	name, _, _ := strings.Cut(host, ".")
+	return name
+}
+
+// restCollectionService maps a top-level REST collection to its API for
+// requests sent to a simulator endpoint, where the host doesn't say.
+var restCollectionService = map[string]string{
+	"jobs":         "run",
+	"executions":   "run",
+	"services":     "run",
+	"revisions":    "run",
+	"functions":    "cloudfunctions",
+	"repositories": "artifactregistry",
+	"secrets":      "secretmanager",
+	"occurrences":  "containeranalysis",
+	"notes":        "containeranalysis",
+	"timeSeries":   "monitoring",
+	"managedZones": "dns",
+}
+
+// RESTOperation names a Google REST call for metrics. The operation is
+// the method followed by the collections in the resource path, with IDs
+// dropped and any custom verb kept: "POST jobs:run",
+// "GET managedZones/changes", "POST b/o" (a Storage upload).
+func RESTOperation(method, host, path string) (service, operation string) {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	var prefix []string
+	rest := segs
+	for i, s := range segs {
+		if gcpAPIVersion.MatchString(s) {
+			prefix, rest = segs[:i], segs[i+1:]
+			break
+		}
+	}
+	verb := ""
+	if n := len(rest); n > 0 {
+		if base, v, ok := strings.Cut(rest[n-1], ":"); ok {
+			rest[n-1], verb = base, ":"+v
+		}
+	}
+	var types []string
+	for i := 0; i < len(rest); i += 2 {
+		if rest[i] != "" {
+			types = append(types, rest[i])
+		}
+	}
+
+	service = googleAPIService(host)
+	if service == "" {
+		// /storage/v1/..., /upload/storage/v1/..., /dns/v1/...
+		for i := len(prefix) - 1; i >= 0 && service == ""; i-- {
+			if prefix[i] != "upload" {
+				service = prefix[i]
+			}
+		}
+		for _, t := range types {
+			if service != "" {
+				break
+			}
+			service = restCollectionService[t]
+		}
+	}
+	for len(types) > 0 && (types[0] == "projects" || types[0] == "locations") {
+		types = types[1:]
+	}
+	return service, method + " " + strings.Join(types, "/") + verb
+}
+
+// GRPCResourceName names the resource a gRPC request acts on: its name
+// (or IAM resource) field, else parent/<collection>/<id> for a create
+// (CreateServiceRequest's service_id → .../services/<id>), else the
+// name of the resource message it carries, else its parent.
+func GRPCResourceName(req any) string {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return ""
+	}
+	r := m.ProtoReflect()
+	fields := r.Descriptor().Fields()
+	str := func(r protoreflect.Message, name protoreflect.Name) string {
+		fd := r.Descriptor().Fields().ByName(name)
+		if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+			return ""
+		}
+		return r.Get(fd).String()
+	}
+	if name := str(r, "name"); name != "" {
+		return name
+	}
+	if name := str(r, "resource"); name != "" {
+		return name
+	}
+	parent := str(r, "parent")
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		coll, ok := strings.CutSuffix(string(fd.Name()), "_id")
+		if !ok || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+			continue
+		}
+		if id := r.Get(fd).String(); id != "" {
+			return strings.TrimPrefix(parent+"/"+grpcCollection(coll)+"/"+id, "/")
+		}
+	}
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() || !r.Has(fd) {
+			continue
+		}
+		if name := str(r.Get(fd).Message(), "name"); name != "" {
+			return name
+		}
+	}
+	return parent
+}
+
+// grpcCollection turns a proto ID field's stem into its REST collection:
+// service → services, worker_pool → workerPools.
+func grpcCollection(stem string) string {
+	parts := strings.Split(stem, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "") + "s"
+}
+
+// restAuditTransport records REST mutations in the audit log.
+type restAuditTransport struct{ next http.RoundTripper }
+
+func (t restAuditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !RESTMutation(req.Method, req.URL.Path) {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	auditErr := err
+	if err == nil && resp.StatusCode >= 400 {
+		auditErr = fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	service, op := RESTOperation(req.Method, req.URL.Host, req.URL.Path)
+	core.AuditCloudCall(req.Context(), service, op, RESTResourceName(req.URL), time.Since(start), auditErr)
+	return resp, err
+}
+
+// RESTMutation reports whether a Google REST call changes state: any
+// method but GET and HEAD, except custom-verb reads such as
+// "entries:list" or "timeSeries:query".
+func RESTMutation(method, path string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		verb := []rune(path[i+1:])
+		if len(verb) > 0 {
+			verb[0] = unicode.ToUpper(verb[0])
+			return !core.IsCloudReadOperation(string(verb))
+		}
+	}
+	return true
+}
+
+// RESTResourceName returns the resource a Google REST call acted on:
+// the path after the API version without any custom verb, with the
+// new resource's ID appended for a create on a collection
+// (?jobId=, ?serviceId=, a Storage upload's ?name=).
+func RESTResourceName(u *url.URL) string {
+	segs := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, s := range segs {
+		if gcpAPIVersion.MatchString(s) {
+			segs = segs[i+1:]
+			break
+		}
+	}
+	if n := len(segs); n > 0 {
+		segs[n-1], _, _ = strings.Cut(segs[n-1], ":")
+	}
+	if len(segs)%2 == 1 {
+		q := u.Query()
+		keys := make([]string, 0, len(q))
+		for key := range q {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// requestId is an idempotency token, not the resource's ID.
+			if (strings.HasSuffix(key, "Id") && key != "requestId") || key == "name" {
+				if id := q.Get(key); id != "" {
+					segs = append(segs, id)
+					break
+				}
+			}
+		}
+	}
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,88 @@
+package gcpcommon
+
+import (
+	"net/url"
+	"testing"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestGRPCOperation(t *testing.T) {
+	cases := []struct {
+		target, method, service, op string
+	}{
+		{"run.googleapis.com:443", "/google.cloud.run.v2.Jobs/RunJob", "run", "RunJob"},
+		{"cloudfunctions.googleapis.com:443", "/google.cloud.functions.v2.FunctionService/CreateFunction", "cloudfunctions", "CreateFunction"},
+		{"127.0.0.1:4567", "/google.logging.v2.LoggingServiceV2/ListLogEntries", "logging", "ListLogEntries"},
+	}
+	for _, c := range cases {
+		service, op := GRPCOperation(c.target, c.method)
+		if service != c.service || op != c.op {
+			t.Errorf("GRPCOperation(%s, %s) = %q %q, want %q %q", c.target, c.method, service, op, c.service, c.op)
+		}
+	}
+}
+
+func TestRESTOperation(t *testing.T) {
+	cases := []struct {
+		method, host, path, service, op string
+	}{
+		{"POST", "127.0.0.1:4566", "/v2/projects/p/locations/us-central1/jobs/sockerless-abc:run", "run", "POST jobs:run"},
+		{"GET", "127.0.0.1:4566", "/v2/projects/p/locations/us-central1/jobs/j/executions/e", "run", "GET jobs/executions"},
+		{"POST", "dns.googleapis.com", "/dns/v1/projects/p/managedZones/z/changes", "dns", "POST managedZones/changes"},
+		{"POST", "127.0.0.1:4566", "/upload/storage/v1/b/bucket/o", "storage", "POST b/o"},
+		{"GET", "artifactregistry.googleapis.com", "/v1/projects/p/locations/l/repositories/r", "artifactregistry", "GET repositories"},
+		{"GET", "127.0.0.1:4566", "/v1/projects/p/occurrences", "containeranalysis", "GET occurrences"},
+	}
+	for _, c := range cases {
+		service, op := RESTOperation(c.method, c.host, c.path)
+		if service != c.service || op != c.op {
+			t.Errorf("RESTOperation(%s %s%s) = %q %q, want %q %q", c.method, c.host, c.path, service, op, c.service, c.op)
+		}
+	}
+}
+
+func TestGRPCResourceName(t *testing.T) {
+	const parent = "projects/p/locations/us-central1"
+	cases := []struct {
+		req  any
+		want string
+	}{
+		{&runpb.RunJobRequest{Name: parent + "/jobs/j"}, parent + "/jobs/j"},
+		{&runpb.CreateJobRequest{Parent: parent, JobId: "sockerless-abc", Job: &runpb.Job{}}, parent + "/jobs/sockerless-abc"},
+		{&runpb.UpdateServiceRequest{Service: &runpb.Service{Name: parent + "/services/s"}}, parent + "/services/s"},
+		{"not a proto", ""},
+	}
+	for _, c := range cases {
+		if got := GRPCResourceName(c.req); got != c.want {
+			t.Errorf("GRPCResourceName(%T) = %q, want %q", c.req, got, c.want)
+		}
+	}
+}
+
+func TestRESTMutation(t *testing.T) {
+	cases := []struct {
+		method, rawURL string
+		mutation       bool
+		resource       string
+	}{
+		{"POST", "http://127.0.0.1:4566/v2/projects/p/locations/l/jobs?jobId=sockerless-abc", true, "projects/p/locations/l/jobs/sockerless-abc"},
+		{"POST", "http://127.0.0.1:4566/v2/projects/p/locations/l/jobs/j:run", true, "projects/p/locations/l/jobs/j"},
+		{"DELETE", "https://run.googleapis.com/v2/projects/p/locations/l/services/s?requestId=x", true, "projects/p/locations/l/services/s"},
+		{"POST", "https://logging.googleapis.com/v2/entries:list", false, "entries"},
+		{"POST", "http://127.0.0.1:4566/upload/storage/v1/b/bucket/o?uploadType=media&name=ctx.tar", true, "b/bucket/o/ctx.tar"},
+		{"GET", "http://127.0.0.1:4566/v2/projects/p/locations/l/jobs/j", false, "projects/p/locations/l/jobs/j"},
+	}
+	for _, c := range cases {
+		u, err := url.Parse(c.rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := RESTMutation(c.method, u.Path); got != c.mutation {
+			t.Errorf("RESTMutation(%s %s) = %v, want %v", c.method, u.Path, got, c.mutation)
+		}
+		if got := RESTResourceName(u); got != c.resource {
+			t.Errorf("RESTResourceName(%s) = %q, want %q", c.rawURL, got, c.resource)
+		}
+	}
+}
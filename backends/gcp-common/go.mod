@@ -4,6 +4,7 @@ go 1.25.0
 
 require (
 	cloud.google.com/go/cloudbuild v1.30.0
+	cloud.google.com/go/logging v1.18.0
 	cloud.google.com/go/run v1.21.0
 	cloud.google.com/go/storage v1.62.2
 	github.com/rs/zerolog v1.35.1
@@ -11,6 +12,8 @@ require (
 	github.com/sockerless/backend-core v0.0.0
 	golang.org/x/oauth2 v0.36.0
 	google.golang.org/api v0.279.0
+	google.golang.org/grpc v1.81.0
+	google.golang.org/protobuf v1.36.11
 )
 
 require (
@@ -72,8 +75,6 @@ require (
 	google.golang.org/genproto v0.0.0-20260504160031-60b97b32f348 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20260504160031-60b97b32f348 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20260504160031-60b97b32f348 // indirect
-	google.golang.org/grpc v1.81.0 // indirect
-	google.golang.org/protobuf v1.36.11 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
@@ -1,14 +1,19 @@
 package gcpcommon
 
-import "strings"
+import (
+	"strings"
+
+	core "github.com/sockerless/backend-core"
+)
 
 // ResolveGCPImageURI converts a Docker image reference to an Artifact Registry URI
 // that Cloud Run and Cloud Run Functions can use.
 //
 // If the image is already an Artifact Registry or GCR URI, it is returned as-is.
-// Otherwise, the reference is rewritten to point to an Artifact Registry remote
-// repository that proxies Docker Hub. The remote repository ("docker-hub") must
-// be pre-configured at the project level.
+// Otherwise, the reference is rewritten to point to the Artifact Registry
+// remote repository that proxies its registry (ARMirrorRepo: "docker-hub" for
+// Docker Hub, "ghcr-io" for ghcr.io, ...). The backend's ARMirrors creates the
+// remote repository on first pull.
 //
 // `endpointURL` is accepted by older call sites but does not alter image
 // resolution. A custom cloud endpoint only changes where SDK requests go; it
@@ -19,6 +24,7 @@ import "strings"
 //	"alpine:latest"        → "{region}-docker.pkg.dev/{project}/docker-hub/library/alpine:latest"
 //	"nginx:alpine"         → "{region}-docker.pkg.dev/{project}/docker-hub/library/nginx:alpine"
 //	"myorg/app:v1"         → "{region}-docker.pkg.dev/{project}/docker-hub/myorg/app:v1"
+//	"ghcr.io/org/tool:v2"  → "{region}-docker.pkg.dev/{project}/ghcr-io/org/tool:v2"
 //	"{region}-docker.pkg.dev/{project}/my-repo/img:tag" → used as-is
 //	"gcr.io/{project}/img:tag"                          → used as-is
 func ResolveGCPImageURI(ref, project, region, endpointURL string) string {
@@ -30,7 +36,7 @@ func ResolveGCPImageURI(ref, project, region, endpointURL string) string {
 	}
 
 	// Already a GCR URI — use as-is
-	if strings.HasSuffix(strings.SplitN(ref, "/", 2)[0], ".gcr.io") {
+	if isGCPImageRegistry(strings.SplitN(ref, "/", 2)[0]) {
 		return ref
 	}
 
@@ -50,26 +56,12 @@ func ResolveGCPImageURI(ref, project, region, endpointURL string) string {
 	registry, repo, tag := parseDockerRef(ref)
 
 	// Cloud Run only accepts images from gcr.io / docker.pkg.dev /
-	// docker.io. Other registries must be mirrored via AR remote
-	// repositories named after the registry. Add a mapping per remote
-	// repo created in terraform/modules/cloudrun/main.tf.
-	var arRepo string
-	switch registry {
-	case "", "docker.io", "registry-1.docker.io":
-		arRepo = "docker-hub"
+	// docker.io. Every other registry is pulled through its AR remote
+	// repository.
+	arRepo := ARMirrorRepo(core.MirrorUpstream(registry))
+	if arRepo == "docker-hub" && !strings.Contains(repo, "/") {
 		// Docker Hub library images: "alpine" → "library/alpine"
-		if !strings.Contains(repo, "/") {
-			repo = "library/" + repo
-		}
-	case "registry.gitlab.com":
-		// gitlab-runner-helper image lives here; AR remote-proxy
-		// `gitlab-registry` proxies registry.gitlab.com.
-		arRepo = "gitlab-registry"
-	default:
-		// Other registries (ghcr.io, quay.io, etc.) — return as-is.
-		// Cloud Run will reject if not on its allow-list, but that's
-		// the operator's responsibility to set up an AR proxy for.
-		return ref
+		repo = "library/" + repo
 	}
 
 	// Rewrite to Artifact Registry remote repository URI
@@ -52,3 +52,35 @@ func TestResolveGCPImageURI_CustomEndpointKeepsCloudSemantics(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestResolveGCPImageURI_OtherRegistryUsesMirrorRepo(t *testing.T) {
+	cases := map[string]string{
+		"ghcr.io/owner/tool:v2":              "us-central1-docker.pkg.dev/proj/ghcr-io/owner/tool:v2",
+		"quay.io/org/img":                    "us-central1-docker.pkg.dev/proj/quay-io/org/img:latest",
+		"registry.gitlab.com/grp/helper:x86": "us-central1-docker.pkg.dev/proj/gitlab-registry/grp/helper:x86",
+	}
+	for ref, want := range cases {
+		if got := ResolveGCPImageURI(ref, "proj", "us-central1", ""); got != want {
+			t.Errorf("ResolveGCPImageURI(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestARMirrorsRoute(t *testing.T) {
+	m := &ARMirrors{}
+	cases := map[string]string{
+		"alpine":                             "docker.io",
+		"docker.io/myorg/app:v1":             "docker.io",
+		"ghcr.io/owner/tool:v2":              "ghcr.io",
+		"registry.gitlab.com/grp/helper:x86": "registry.gitlab.com",
+		"us-central1-docker.pkg.dev/p/r/i:1": "",
+		"gcr.io/p/i:1":                       "",
+		"sha256:abc":                         "",
+	}
+	for ref, want := range cases {
+		upstream, ok := m.Route(ref)
+		if upstream != want || ok != (want != "") {
+			t.Errorf("Route(%q) = (%q, %v), want %q", ref, upstream, ok, want)
+		}
+	}
+}
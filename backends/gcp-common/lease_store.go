@@ -0,0 +1,153 @@
+package gcpcommon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	core "github.com/sockerless/backend-core"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// Compile-time check that GCSLeaseStore implements core.LeaseStore.
+var _ core.LeaseStore = (*GCSLeaseStore)(nil)
+
+// GCSLeaseStore keeps HA leases as JSON objects in a GCS bucket, one
+// object per lease (<prefix>/<key>.json). An object's generation is its
+// version: writes carry a DoesNotExist or GenerationMatch precondition,
+// which GCS evaluates atomically.
+type GCSLeaseStore struct {
+	Client *storage.Client
+	Bucket string
+	Prefix string // object name prefix, without a trailing slash
+}
+
+// NewGCSLeaseStore parses store as "bucket" or "bucket/prefix".
+func NewGCSLeaseStore(client *storage.Client, store string) *GCSLeaseStore {
+	bucket, prefix, _ := strings.Cut(store, "/")
+	return &GCSLeaseStore{Client: client, Bucket: bucket, Prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *GCSLeaseStore) object(key string) string {
+	if s.Prefix == "" {
+		return key + ".json"
+	}
+	return s.Prefix + "/" + key + ".json"
+}
+
+// GetLease implements core.LeaseStore.
+func (s *GCSLeaseStore) GetLease(ctx context.Context, key string) (core.Lease, bool, error) {
+	r, err := s.Client.Bucket(s.Bucket).Object(s.object(key)).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return core.Lease{}, false, nil
+	}
+	if err != nil {
+		return core.Lease{}, false, fmt.Errorf("get lease %s from gs://%s: %w", key, s.Bucket, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return core.Lease{}, false, fmt.Errorf("read lease %s from gs://%s: %w", key, s.Bucket, err)
+	}
+	var l core.Lease
+	if err := json.Unmarshal(data, &l); err != nil {
+		return core.Lease{}, false, fmt.Errorf("decode lease %s: %w", key, err)
+	}
+	l.Version = strconv.FormatInt(r.Attrs.Generation, 10)
+	return l, true, nil
+}
+
+// PutLease implements core.LeaseStore.
+func (s *GCSLeaseStore) PutLease(ctx context.Context, l core.Lease) (core.Lease, error) {
+	cond, err := s.conditions(l)
+	if err != nil {
+		return core.Lease{}, err
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return core.Lease{}, err
+	}
+	w := s.Client.Bucket(s.Bucket).Object(s.object(l.Key)).If(cond).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return core.Lease{}, s.writeError(l.Key, err)
+	}
+	if err := w.Close(); err != nil {
+		return core.Lease{}, s.writeError(l.Key, err)
+	}
+	l.Version = strconv.FormatInt(w.Attrs().Generation, 10)
+	return l, nil
+}
+
+// DeleteLease implements core.LeaseStore.
+func (s *GCSLeaseStore) DeleteLease(ctx context.Context, l core.Lease) error {
+	cond, err := s.conditions(l)
+	if err != nil {
+		return err
+	}
+	err = s.Client.Bucket(s.Bucket).Object(s.object(l.Key)).If(cond).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return core.ErrLeaseConflict
+	}
+	if err != nil {
+		return s.writeError(l.Key, err)
+	}
+	return nil
+}
+
+// ListLeases implements core.LeaseStore.
+func (s *GCSLeaseStore) ListLeases(ctx context.Context, prefix string) ([]core.Lease, error) {
+	q := &storage.Query{Prefix: strings.TrimSuffix(s.object(prefix), ".json")}
+	it := s.Client.Bucket(s.Bucket).Objects(ctx, q)
+	var leases []core.Lease
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list leases in gs://%s: %w", s.Bucket, err)
+		}
+		if !strings.HasSuffix(attrs.Name, ".json") {
+			continue
+		}
+		key := strings.TrimSuffix(attrs.Name, ".json")
+		if s.Prefix != "" {
+			key = strings.TrimPrefix(key, s.Prefix+"/")
+		}
+		l, ok, err := s.GetLease(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			leases = append(leases, l)
+		}
+	}
+	return leases, nil
+}
+
+func (s *GCSLeaseStore) conditions(l core.Lease) (storage.Conditions, error) {
+	if l.Version == "" {
+		return storage.Conditions{DoesNotExist: true}, nil
+	}
+	gen, err := strconv.ParseInt(l.Version, 10, 64)
+	if err != nil {
+		return storage.Conditions{}, fmt.Errorf("lease %s: invalid version %q", l.Key, l.Version)
+	}
+	return storage.Conditions{GenerationMatch: gen}, nil
+}
+
+func (s *GCSLeaseStore) writeError(key string, err error) error {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) && gErr.Code == 412 {
+		return core.ErrLeaseConflict
+	}
+	return fmt.Errorf("write lease %s to gs://%s: %w", key, s.Bucket, err)
+}
@@ -0,0 +1,263 @@
+package gcpcommon
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	core "github.com/sockerless/backend-core"
+	artifactregistry "google.golang.org/api/artifactregistry/v1"
+	"google.golang.org/api/googleapi"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// Compile-time check that ARMirrors implements core.RegistryMirrorManager.
+var _ core.RegistryMirrorManager = (*ARMirrors)(nil)
+
+// arMirrorLabel marks the remote repositories and credential secrets
+// sockerless created.
+const arMirrorLabel = "sockerless_managed"
+
+// arOperationTimeout caps how long Ensure and Remove wait for the
+// repository create / delete operation.
+const arOperationTimeout = 2 * time.Minute
+
+// ARMirrors manages Artifact Registry remote repositories. Upstream
+// credentials are stored as Secret Manager secret versions referenced
+// by the repository's usernamePasswordCredentials.
+type ARMirrors struct {
+	Repositories *artifactregistry.Service
+	Secrets      *secretmanager.Service
+	Project      string
+	Region       string
+	// Credentials holds the upstream registry logins
+	// (core.RegistryMirrorConfig.Credentials).
+	Credentials *core.DockerConfig
+	Logger      zerolog.Logger
+}
+
+// ARMirrorRepo is the remote repository ID the mirror of upstream
+// lives under. registry.gitlab.com keeps the "gitlab-registry" name the
+// terraform modules provision.
+func ARMirrorRepo(upstream string) string {
+	if upstream == "registry.gitlab.com" {
+		return "gitlab-registry"
+	}
+	return core.MirrorName(upstream)
+}
+
+// isGCPImageRegistry reports whether Cloud Run pulls from registry
+// directly: Artifact Registry and Container Registry hosts.
+func isGCPImageRegistry(registry string) bool {
+	return registry == "gcr.io" || core.IsGCPRegistry(registry)
+}
+
+// Route implements core.RegistryMirrorManager: every registry outside
+// Artifact Registry / Container Registry, Docker Hub included, is
+// pulled through a remote repository.
+func (m *ARMirrors) Route(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "sha256:") && !strings.Contains(ref, "/") {
+		return "", false
+	}
+	registry, _, _ := parseDockerRef(ref)
+	if isGCPImageRegistry(registry) {
+		return "", false
+	}
+	return core.MirrorUpstream(registry), true
+}
+
+// Ensure implements core.RegistryMirrorManager.
+func (m *ARMirrors) Ensure(ctx context.Context, upstream string) (core.RegistryMirror, bool, error) {
+	id := ARMirrorRepo(upstream)
+	name := m.parent() + "/repositories/" + id
+	repo, err := m.Repositories.Projects.Locations.Repositories.Get(name).Context(ctx).Do()
+	if err == nil {
+		return m.mirror(repo), false, nil
+	}
+	if !IsNotFound(err) {
+		return core.RegistryMirror{}, false, fmt.Errorf("get remote repository %s: %w", id, err)
+	}
+
+	docker := &artifactregistry.DockerRepository{PublicRepository: "DOCKER_HUB"}
+	if upstream != "docker.io" {
+		docker = &artifactregistry.DockerRepository{
+			CustomRepository: &artifactregistry.GoogleDevtoolsArtifactregistryV1RemoteRepositoryConfigDockerRepositoryCustomRepository{
+				Uri: "https://" + upstream,
+			},
+		}
+	}
+	remote := &artifactregistry.RemoteRepositoryConfig{
+		Description:      "Pull-through cache of " + upstream,
+		DockerRepository: docker,
+	}
+	if user, pass, ok := m.Credentials.GetRegistryAuth(upstream); ok {
+		version, err := m.putCredential(ctx, id, upstream, pass)
+		if err != nil {
+			return core.RegistryMirror{}, false, err
+		}
+		remote.UpstreamCredentials = &artifactregistry.UpstreamCredentials{
+			UsernamePasswordCredentials: &artifactregistry.UsernamePasswordCredentials{
+				Username:              user,
+				PasswordSecretVersion: version,
+			},
+		}
+	}
+
+	op, err := m.Repositories.Projects.Locations.Repositories.Create(m.parent(), &artifactregistry.Repository{
+		Format:                 "DOCKER",
+		Mode:                   "REMOTE_REPOSITORY",
+		Description:            "sockerless registry mirror of " + upstream,
+		Labels:                 map[string]string{arMirrorLabel: "true"},
+		RemoteRepositoryConfig: remote,
+	}).RepositoryId(id).Context(ctx).Do()
+	if err != nil {
+		var gErr *googleapi.Error
+		if errors.As(err, &gErr) && gErr.Code == 409 {
+			repo, err := m.Repositories.Projects.Locations.Repositories.Get(name).Context(ctx).Do()
+			if err != nil {
+				return core.RegistryMirror{}, false, fmt.Errorf("get remote repository %s: %w", id, err)
+			}
+			return m.mirror(repo), false, nil
+		}
+		return core.RegistryMirror{}, false, fmt.Errorf("create remote repository %s: %w", id, err)
+	}
+	if err := m.wait(ctx, op); err != nil {
+		return core.RegistryMirror{}, false, fmt.Errorf("create remote repository %s: %w", id, err)
+	}
+	repo, err = m.Repositories.Projects.Locations.Repositories.Get(name).Context(ctx).Do()
+	if err != nil {
+		return core.RegistryMirror{}, false, fmt.Errorf("get remote repository %s: %w", id, err)
+	}
+	m.Logger.Info().Str("repository", id).Str("upstream", upstream).Msg("created Artifact Registry remote repository")
+	return m.mirror(repo), true, nil
+}
+
+// List implements core.RegistryMirrorManager. Only remote Docker
+// repositories are mirrors; standard repositories are skipped.
+func (m *ARMirrors) List(ctx context.Context) ([]core.RegistryMirror, error) {
+	var mirrors []core.RegistryMirror
+	err := m.Repositories.Projects.Locations.Repositories.List(m.parent()).Pages(ctx, func(page *artifactregistry.ListRepositoriesResponse) error {
+		for _, repo := range page.Repositories {
+			if repo.Mode == "REMOTE_REPOSITORY" && repo.Format == "DOCKER" {
+				mirrors = append(mirrors, m.mirror(repo))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list repositories: %w", err)
+	}
+	return mirrors, nil
+}
+
+// Remove implements core.RegistryMirrorManager. Deleting the
+// repository drops its cached images with it.
+func (m *ARMirrors) Remove(ctx context.Context, mirror core.RegistryMirror) error {
+	op, err := m.Repositories.Projects.Locations.Repositories.Delete(m.parent() + "/repositories/" + mirror.Name).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("delete remote repository %s: %w", mirror.Name, err)
+	}
+	if err := m.wait(ctx, op); err != nil {
+		return fmt.Errorf("delete remote repository %s: %w", mirror.Name, err)
+	}
+	if secret, _, ok := strings.Cut(mirror.Credential, "/versions/"); ok && strings.HasSuffix(secret, "/secrets/"+arMirrorSecretID(mirror.Name)) {
+		if _, err := m.Secrets.Projects.Secrets.Delete(secret).Context(ctx).Do(); err != nil && !IsNotFound(err) {
+			return fmt.Errorf("delete credential secret of %s: %w", mirror.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *ARMirrors) parent() string {
+	return fmt.Sprintf("projects/%s/locations/%s", m.Project, m.Region)
+}
+
+// arMirrorSecretID is the Secret Manager secret holding the upstream
+// password of the mirror repository id.
+func arMirrorSecretID(id string) string {
+	return "sockerless-mirror-" + id
+}
+
+// putCredential adds the upstream password as a new version of the
+// mirror's secret, creating the secret on first use, and returns the
+// version name.
+func (m *ARMirrors) putCredential(ctx context.Context, id, upstream, pass string) (string, error) {
+	parent := "projects/" + m.Project
+	secretID := arMirrorSecretID(id)
+	_, err := m.Secrets.Projects.Secrets.Create(parent, &secretmanager.Secret{
+		Labels:      map[string]string{arMirrorLabel: "true"},
+		Replication: &secretmanager.Replication{Automatic: &secretmanager.Automatic{}},
+	}).SecretId(secretID).Context(ctx).Do()
+	var gErr *googleapi.Error
+	if err != nil && !(errors.As(err, &gErr) && gErr.Code == 409) {
+		return "", fmt.Errorf("store %s credentials in Secret Manager: %w", upstream, err)
+	}
+	version, err := m.Secrets.Projects.Secrets.AddVersion(parent+"/secrets/"+secretID, &secretmanager.AddSecretVersionRequest{
+		Payload: &secretmanager.SecretPayload{Data: base64.StdEncoding.EncodeToString([]byte(pass))},
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("store %s credentials in Secret Manager: %w", upstream, err)
+	}
+	return version.Name, nil
+}
+
+// wait polls op until it is done.
+func (m *ARMirrors) wait(ctx context.Context, op *artifactregistry.Operation) error {
+	deadline := time.Now().Add(arOperationTimeout)
+	for !op.Done {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("operation %s did not finish within %s", op.Name, arOperationTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+		var err error
+		if op, err = m.Repositories.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("get operation: %w", err)
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("%s", op.Error.Message)
+	}
+	return nil
+}
+
+func (m *ARMirrors) mirror(repo *artifactregistry.Repository) core.RegistryMirror {
+	id := repo.Name[strings.LastIndex(repo.Name, "/")+1:]
+	mirror := core.RegistryMirror{
+		Upstream: arRepoUpstream(repo),
+		Name:     id,
+		URI:      fmt.Sprintf("%s-docker.pkg.dev/%s/%s", m.Region, m.Project, id),
+		Managed:  repo.Labels[arMirrorLabel] == "true",
+	}
+	if rc := repo.RemoteRepositoryConfig; rc != nil && rc.UpstreamCredentials != nil && rc.UpstreamCredentials.UsernamePasswordCredentials != nil {
+		mirror.Credential = rc.UpstreamCredentials.UsernamePasswordCredentials.PasswordSecretVersion
+	}
+	if t, err := time.Parse(time.RFC3339, repo.CreateTime); err == nil {
+		mirror.Created = t
+	}
+	return mirror
+}
+
+// arRepoUpstream returns the upstream registry host a remote repository
+// proxies.
+func arRepoUpstream(repo *artifactregistry.Repository) string {
+	rc := repo.RemoteRepositoryConfig
+	if rc == nil || rc.DockerRepository == nil {
+		return ""
+	}
+	if rc.DockerRepository.PublicRepository == "DOCKER_HUB" {
+		return "docker.io"
+	}
+	if custom := rc.DockerRepository.CustomRepository; custom != nil {
+		host := strings.TrimPrefix(strings.TrimPrefix(custom.Uri, "https://"), "http://")
+		return core.MirrorUpstream(strings.TrimSuffix(host, "/"))
+	}
+	return ""
+}
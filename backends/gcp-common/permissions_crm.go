@@ -0,0 +1,193 @@
+// Permission preflight for every GCP-product backend. The prober asks
+// Cloud Resource Manager projects.testIamPermissions which of the
+// backend's declared permissions the caller holds on the configured
+// project; the shared Add*Permissions helpers declare the permissions
+// of the drivers that live in this package.
+
+package gcpcommon
+
+import (
+	"context"
+	"fmt"
+
+	core "github.com/sockerless/backend-core"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
+)
+
+// testIamPermissionsBatch is the per-call permission limit of
+// projects.testIamPermissions.
+const testIamPermissionsBatch = 100
+
+// ProjectPermissionProber implements core.PermissionProber with
+// projects.testIamPermissions. Grants made only on individual
+// resources (a single bucket, one service account) are not visible
+// at project scope and report as missing.
+type ProjectPermissionProber struct {
+	CRM     *cloudresourcemanager.Service
+	Project string
+}
+
+// NewProjectPermissionProber constructs a prober for project. A
+// non-empty endpointURL targets a simulator without authentication.
+func NewProjectPermissionProber(ctx context.Context, project, endpointURL string) (*ProjectPermissionProber, error) {
+	var opts []option.ClientOption
+	if endpointURL != "" {
+		opts = append(opts, option.WithEndpoint(endpointURL), option.WithoutAuthentication())
+	}
+	crm, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cloudresourcemanager client: %w", err)
+	}
+	return &ProjectPermissionProber{CRM: crm, Project: project}, nil
+}
+
+// Probe implements core.PermissionProber.
+func (p *ProjectPermissionProber) Probe(ctx context.Context, reqs []core.PermissionRequirement) (*core.PermissionProbe, error) {
+	var perms []string
+	seen := map[string]bool{}
+	for _, r := range reqs {
+		if !seen[r.Permission] {
+			seen[r.Permission] = true
+			perms = append(perms, r.Permission)
+		}
+	}
+	granted := map[string]bool{}
+	for start := 0; start < len(perms); start += testIamPermissionsBatch {
+		end := min(start+testIamPermissionsBatch, len(perms))
+		resp, err := p.CRM.Projects.TestIamPermissions(p.Project, &cloudresourcemanager.TestIamPermissionsRequest{
+			Permissions: perms[start:end],
+		}).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("projects.testIamPermissions on %s: %w", p.Project, err)
+		}
+		for _, g := range resp.Permissions {
+			granted[g] = true
+		}
+	}
+	probe := &core.PermissionProbe{
+		Principal: "caller on projects/" + p.Project,
+		Method:    "projects.testIamPermissions",
+	}
+	for _, r := range reqs {
+		if !granted[r.Permission] {
+			probe.Denied = append(probe.Denied, r)
+		}
+	}
+	return probe, nil
+}
+
+// AddArtifactRegistryPermissions declares the Artifact Registry
+// permissions behind image pull, push / tag and rmi.
+func AddArtifactRegistryPermissions(set *core.PermissionSet) {
+	set.Add("registry", []string{"pull", "create", "run"}, "artifactregistry.repositories.downloadArtifacts")
+	set.Add("registry", []string{"push", "tag"}, "artifactregistry.repositories.uploadArtifacts")
+	set.Add("registry", []string{"rmi"}, "artifactregistry.repositories.deleteArtifacts")
+}
+
+// AddArtifactRegistryMirrorPermissions declares the permissions of the
+// registry mirror manager (ARMirrors): remote repositories and the
+// Secret Manager secrets holding upstream credentials.
+func AddArtifactRegistryMirrorPermissions(set *core.PermissionSet) {
+	set.Add("registry mirrors", []string{"pull", "create", "registry mirrors"},
+		"artifactregistry.repositories.get",
+		"artifactregistry.repositories.create",
+		"artifactregistry.repositories.list",
+		"secretmanager.secrets.create",
+		"secretmanager.versions.add",
+	)
+	set.Add("registry mirrors", []string{"registry mirrors prune"},
+		"artifactregistry.repositories.delete",
+		"secretmanager.secrets.delete",
+	)
+}
+
+// AddBucketVolumePermissions declares the permissions of BucketManager,
+// which backs each named docker volume with a sockerless-labelled GCS
+// bucket.
+func AddBucketVolumePermissions(set *core.PermissionSet) {
+	driver := "volumes gcs-bucket"
+	set.Add(driver, []string{"volume create", "run -v"}, "storage.buckets.list", "storage.buckets.create")
+	set.Add(driver, []string{"volume ls", "volume inspect"}, "storage.buckets.list")
+	set.Add(driver, []string{"volume rm", "volume prune"},
+		"storage.buckets.list",
+		"storage.buckets.delete",
+		"storage.objects.list",
+		"storage.objects.delete",
+	)
+}
+
+// AddGCSSyncPermissions declares the permissions of the gcs-sync
+// storage backing, which tars the volume to an object before each
+// exec and restores it afterwards.
+func AddGCSSyncPermissions(set *core.PermissionSet) {
+	set.Add("storage "+string(core.BackingGCSSync), []string{"exec", "run -v"},
+		"storage.objects.create",
+		"storage.objects.get",
+		"storage.objects.delete",
+		"storage.objects.list",
+	)
+}
+
+// AddCloudDNSPermissions declares the permissions of the cloud-dns
+// network-discovery driver and the per-network private managed zones
+// backing it.
+func AddCloudDNSPermissions(set *core.PermissionSet) {
+	driver := "network-discovery cloud-dns"
+	set.Add(driver, []string{"network create"}, "dns.managedZones.create", "dns.managedZones.get", "dns.networks.bindPrivateDNSZone")
+	set.Add(driver, []string{"network rm"}, "dns.managedZones.delete", "dns.resourceRecordSets.list", "dns.resourceRecordSets.delete", "dns.changes.create")
+	set.Add(driver, []string{"network ls", "network inspect"}, "dns.managedZones.get")
+	set.Add(driver, []string{"run --network", "network connect"},
+		"dns.managedZones.get",
+		"dns.resourceRecordSets.create",
+		"dns.resourceRecordSets.list",
+		"dns.changes.create",
+		"run.services.get",
+	)
+	set.Add(driver, []string{"stop", "rm", "network disconnect"},
+		"dns.resourceRecordSets.list",
+		"dns.resourceRecordSets.delete",
+		"dns.changes.create",
+	)
+}
+
+// AddCloudBuildPermissions declares the permissions of GCPBuildService:
+// the build context upload and the Cloud Build run.
+func AddCloudBuildPermissions(set *core.PermissionSet) {
+	driver := "build cloudbuild"
+	set.Add(driver, []string{"build"},
+		"storage.objects.create",
+		"cloudbuild.builds.create",
+		"cloudbuild.builds.get",
+	)
+}
+
+// AddCloudMonitoringStatsPermissions declares the permission of
+// CloudMonitoringStats, the `docker stats` source when no reverse
+// agent is connected.
+func AddCloudMonitoringStatsPermissions(set *core.PermissionSet) {
+	set.Add("stats cloud-monitoring", []string{"stats"}, "monitoring.timeSeries.list")
+}
+
+// AddArtifactAnalysisScanPermissions declares the permission of
+// ArtifactAnalysisScanner, the cloud image scanner.
+func AddArtifactAnalysisScanPermissions(set *core.PermissionSet) {
+	set.Add("image-scanner artifact-analysis", []string{"image scan", "create", "pull"}, "containeranalysis.occurrences.list")
+}
+
+// AddCloudLoggingAuditPermissions declares the permission of
+// CloudLoggingAuditSink, which ships the audit log to Cloud Logging.
+func AddCloudLoggingAuditPermissions(set *core.PermissionSet) {
+	set.Add("audit cloud-logging", []string{"resources history"}, "logging.logEntries.create")
+}
+
+// AddGCSLeasePermissions declares the permissions of GCSLeaseStore,
+// which multi-instance HA coordinates through.
+func AddGCSLeasePermissions(set *core.PermissionSet) {
+	set.Add("ha gcs", []string{"create", "rm", "container prune"},
+		"storage.objects.get",
+		"storage.objects.create",
+		"storage.objects.delete",
+		"storage.objects.list",
+	)
+}
@@ -0,0 +1,181 @@
+package gcpcommon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	core "github.com/sockerless/backend-core"
+	containeranalysis "google.golang.org/api/containeranalysis/v1"
+)
+
+// Compile-time check that ArtifactAnalysisScanner implements core.ImageScanner.
+var _ core.ImageScanner = (*ArtifactAnalysisScanner)(nil)
+
+// ArtifactAnalysisScanner reads the vulnerability occurrences Artifact
+// Analysis records for an Artifact Registry image. Scanning itself is
+// automatic on push (containerscanning.googleapis.com); the image's
+// DISCOVERY occurrence reports whether it has finished.
+type ArtifactAnalysisScanner struct {
+	Service *containeranalysis.Service
+	Project string
+	// Resolve maps a docker image reference to the Artifact Registry
+	// URI the backend runs it from.
+	Resolve func(ctx context.Context, ref string) (string, error)
+	// Digest returns the manifest digest of an Artifact Registry URI;
+	// occurrences are keyed by digest, not tag.
+	Digest       func(uri string) (string, error)
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// Available reports true; Artifact Analysis needs no local state.
+func (s *ArtifactAnalysisScanner) Available() bool { return true }
+
+// Scan waits for the image's discovery occurrence to finish, then
+// returns its vulnerability occurrences.
+func (s *ArtifactAnalysisScanner) Scan(ctx context.Context, ref string) (*core.ScanResult, error) {
+	uri, err := s.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	res := &core.ScanResult{ImageRef: uri, Scanner: "artifact-analysis"}
+	if !core.IsGCPRegistry(strings.SplitN(uri, "/", 2)[0]) {
+		res.ScanStatus = core.ScanStatusFailed
+		res.Message = fmt.Sprintf("%s is not in Artifact Registry; Artifact Analysis scans Artifact Registry images only", uri)
+		res.ScanTime = time.Now().UTC()
+		return res, nil
+	}
+	digest, err := s.Digest(uri)
+	if err != nil {
+		return nil, fmt.Errorf("resolve digest of %s: %w", uri, err)
+	}
+	res.Digest = digest
+	resourceURL := "https://" + imageRepository(uri) + "@" + digest
+
+	deadline := time.Now().Add(s.Timeout)
+	for {
+		status, message, err := s.discoveryStatus(ctx, resourceURL)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case "FINISHED_SUCCESS", "COMPLETE":
+			vulns, err := s.vulnerabilities(ctx, resourceURL)
+			if err != nil {
+				return nil, err
+			}
+			res.ScanStatus = core.ScanStatusComplete
+			res.Vulnerabilities = vulns
+			res.ScanTime = time.Now().UTC()
+			return res, nil
+		case "FINISHED_FAILED", "FINISHED_UNSUPPORTED":
+			res.ScanStatus = core.ScanStatusFailed
+			res.Message = "Artifact Analysis " + status
+			if message != "" {
+				res.Message += ": " + message
+			}
+			res.ScanTime = time.Now().UTC()
+			return res, nil
+		}
+		if time.Now().After(deadline) {
+			res.ScanStatus = core.ScanStatusInProgress
+			if status == "" {
+				res.Message = "no discovery occurrence for " + resourceURL + "; is automatic scanning (containerscanning.googleapis.com) enabled?"
+			}
+			res.ScanTime = time.Now().UTC()
+			return res, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.PollInterval):
+		}
+	}
+}
+
+// discoveryStatus returns the analysis status of the image's
+// DISCOVERY occurrence, "" when there is none yet.
+func (s *ArtifactAnalysisScanner) discoveryStatus(ctx context.Context, resourceURL string) (string, string, error) {
+	resp, err := s.Service.Projects.Occurrences.List("projects/" + s.Project).
+		Filter(fmt.Sprintf(`resourceUrl=%q AND kind="DISCOVERY"`, resourceURL)).
+		Context(ctx).Do()
+	if err != nil {
+		return "", "", MapGCPError(err, "image", resourceURL)
+	}
+	for _, o := range resp.Occurrences {
+		if d := o.Discovery; d != nil {
+			message := ""
+			if d.AnalysisStatusError != nil {
+				message = d.AnalysisStatusError.Message
+			}
+			return d.AnalysisStatus, message, nil
+		}
+	}
+	return "", "", nil
+}
+
+// vulnerabilities lists every VULNERABILITY occurrence of the image,
+// one core.Vulnerability per affected package.
+func (s *ArtifactAnalysisScanner) vulnerabilities(ctx context.Context, resourceURL string) ([]core.Vulnerability, error) {
+	var out []core.Vulnerability
+	err := s.Service.Projects.Occurrences.List("projects/"+s.Project).
+		Filter(fmt.Sprintf(`resourceUrl=%q AND kind="VULNERABILITY"`, resourceURL)).
+		Pages(ctx, func(resp *containeranalysis.ListOccurrencesResponse) error {
+			for _, o := range resp.Occurrences {
+				out = append(out, occurrenceVulnerabilities(o)...)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, MapGCPError(err, "image", resourceURL)
+	}
+	return out, nil
+}
+
+func occurrenceVulnerabilities(o *containeranalysis.Occurrence) []core.Vulnerability {
+	v := o.Vulnerability
+	if v == nil {
+		return nil
+	}
+	base := core.Vulnerability{
+		// noteName is projects/goog-vulnz/notes/CVE-2024-0001.
+		ID:          o.NoteName[strings.LastIndex(o.NoteName, "/")+1:],
+		Severity:    v.EffectiveSeverity,
+		Description: v.ShortDescription,
+	}
+	if base.Severity == "" || base.Severity == "SEVERITY_UNSPECIFIED" {
+		base.Severity = v.Severity
+	}
+	if len(v.RelatedUrls) > 0 {
+		base.URI = v.RelatedUrls[0].Url
+	}
+	if len(v.PackageIssue) == 0 {
+		return []core.Vulnerability{base}
+	}
+	out := make([]core.Vulnerability, 0, len(v.PackageIssue))
+	for _, p := range v.PackageIssue {
+		f := base
+		f.Package = p.AffectedPackage
+		if p.AffectedVersion != nil {
+			f.Version = p.AffectedVersion.FullName
+		}
+		if p.FixedVersion != nil && p.FixedVersion.Kind != "MAXIMUM" {
+			f.FixVersion = p.FixedVersion.FullName
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// imageRepository strips the tag or digest from an image URI.
+func imageRepository(uri string) string {
+	if name, _, ok := strings.Cut(uri, "@"); ok {
+		return name
+	}
+	if i := strings.LastIndex(uri, ":"); i > strings.LastIndex(uri, "/") {
+		return uri[:i]
+	}
+	return uri
+}
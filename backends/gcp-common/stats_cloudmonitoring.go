@@ -0,0 +1,137 @@
+package gcpcommon
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	core "github.com/sockerless/backend-core"
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// CloudRunMonitoredResource names the Cloud Monitoring resource a
+// container's metrics publish under, plus the limits Cloud Run's
+// utilization fractions are relative to.
+type CloudRunMonitoredResource struct {
+	Type        string // "cloud_run_job" or "cloud_run_revision"
+	NameLabel   string // "job_name" or "service_name"
+	Name        string
+	CPULimit    string // Cloud Run form: "1", "500m"
+	MemoryLimit string // Cloud Run form: "512Mi", "4Gi"
+}
+
+// CloudMonitoringStats is the Cloud Run / Cloud Functions
+// core.StatsProvider used when no reverse agent is connected. Cloud
+// Run publishes only utilization fractions, so CPU and memory are the
+// newest one-minute mean times the container's allocation.
+type CloudMonitoringStats struct {
+	Service *monitoring.Service
+	Project string
+	// Resource resolves a container to its monitored resource; false
+	// means the container has no cloud workload yet.
+	Resource func(containerID string) (CloudRunMonitoredResource, bool)
+}
+
+// ContainerMetrics implements core.StatsProvider.
+func (p *CloudMonitoringStats) ContainerMetrics(containerID string) (*core.ContainerMetrics, error) {
+	res, ok := p.Resource(containerID)
+	if !ok {
+		return nil, nil
+	}
+	cores, err := parseCloudRunCPU(res.CPULimit)
+	if err != nil {
+		return nil, err
+	}
+	memMiB, err := core.ParseMemoryMiB(res.MemoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := &core.ContainerMetrics{Source: "cloud-monitoring"}
+	cpu, cpuAt, err := p.newestUtilization(ctx, res, "cpu")
+	if err != nil {
+		return nil, err
+	}
+	mem, memAt, err := p.newestUtilization(ctx, res, "memory")
+	if err != nil {
+		return nil, err
+	}
+	m.CPUCores = cpu * cores
+	m.MemBytes = int64(mem * float64(memMiB) * 1024 * 1024)
+	switch {
+	case cpuAt.IsZero() || memAt.IsZero():
+		// No datapoint yet for a new workload; SampledAt stays zero.
+	case cpuAt.Before(memAt):
+		m.SampledAt = cpuAt
+	default:
+		m.SampledAt = memAt
+	}
+	return m, nil
+}
+
+// newestUtilization returns the newest one-minute mean of
+// run.googleapis.com/container/<kind>/utilizations across the
+// resource's instances, and the end of its period.
+func (p *CloudMonitoringStats) newestUtilization(ctx context.Context, res CloudRunMonitoredResource, kind string) (float64, time.Time, error) {
+	now := time.Now().UTC()
+	filter := fmt.Sprintf(`metric.type="run.googleapis.com/container/%s/utilizations" AND resource.type="%s" AND resource.labels.%s="%s"`,
+		kind, res.Type, res.NameLabel, res.Name)
+	resp, err := p.Service.Projects.TimeSeries.List("projects/" + p.Project).
+		Filter(filter).
+		IntervalStartTime(now.Add(-5 * time.Minute).Format(time.RFC3339)).
+		IntervalEndTime(now.Format(time.RFC3339)).
+		AggregationAlignmentPeriod("60s").
+		AggregationPerSeriesAligner("ALIGN_MEAN").
+		AggregationCrossSeriesReducer("REDUCE_MEAN").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("cloud monitoring timeSeries.list: %w", err)
+	}
+	return newestPoint(resp.TimeSeries)
+}
+
+// newestPoint picks the latest double point across series. Points come
+// newest first within a series.
+func newestPoint(series []*monitoring.TimeSeries) (float64, time.Time, error) {
+	var value float64
+	var at time.Time
+	for _, ts := range series {
+		if len(ts.Points) == 0 {
+			continue
+		}
+		pt := ts.Points[0]
+		if pt.Value == nil || pt.Value.DoubleValue == nil || pt.Interval == nil {
+			continue
+		}
+		end, err := time.Parse(time.RFC3339Nano, pt.Interval.EndTime)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("cloud monitoring point end time %q: %w", pt.Interval.EndTime, err)
+		}
+		if end.After(at) {
+			value, at = *pt.Value.DoubleValue, end
+		}
+	}
+	return value, at, nil
+}
+
+// parseCloudRunCPU converts a Cloud Run CPU limit ("1", "2", "500m")
+// to cores.
+func parseCloudRunCPU(limit string) (float64, error) {
+	if v, ok := strings.CutSuffix(limit, "m"); ok {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CPU limit %q", limit)
+		}
+		return n / 1000, nil
+	}
+	n, err := strconv.ParseFloat(limit, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CPU limit %q", limit)
+	}
+	return n, nil
+}
@@ -0,0 +1,73 @@
+package gcpcommon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+func TestCloudMonitoringStats_ScalesUtilizationByAllocation(t *testing.T) {
+	end := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var filters []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/projects/proj/timeSeries" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		filter := r.URL.Query().Get("filter")
+		filters = append(filters, filter)
+		value := 0.5
+		if strings.Contains(filter, "/memory/") {
+			value = 0.25
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"timeSeries": []map[string]any{{
+			"points": []map[string]any{{
+				"interval": map[string]any{"endTime": end.Format(time.RFC3339)},
+				"value":    map[string]any{"doubleValue": value},
+			}},
+		}}})
+	}))
+	defer srv.Close()
+	svc, err := monitoring.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &CloudMonitoringStats{
+		Service: svc,
+		Project: "proj",
+		Resource: func(string) (CloudRunMonitoredResource, bool) {
+			return CloudRunMonitoredResource{Type: "cloud_run_job", NameLabel: "job_name", Name: "job-1", CPULimit: "2", MemoryLimit: "4Gi"}, true
+		},
+	}
+	m, err := p.ContainerMetrics("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CPUCores != 1 || m.MemBytes != 1<<30 {
+		t.Errorf("got cores=%v mem=%d, want 1 core and 1 GiB", m.CPUCores, m.MemBytes)
+	}
+	if !m.SampledAt.Equal(end) || m.Source != "cloud-monitoring" {
+		t.Errorf("got sampled=%v source=%q", m.SampledAt, m.Source)
+	}
+	if len(filters) != 2 || !strings.Contains(filters[0], `resource.labels.job_name="job-1"`) {
+		t.Errorf("filters = %q", filters)
+	}
+}
+
+func TestParseCloudRunCPU(t *testing.T) {
+	for in, want := range map[string]float64{"1": 1, "2": 2, "500m": 0.5} {
+		if got, err := parseCloudRunCPU(in); err != nil || got != want {
+			t.Errorf("parseCloudRunCPU(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseCloudRunCPU("lots"); err == nil {
+		t.Error("expected error for an invalid CPU limit")
+	}
+}
@@ -6,23 +6,34 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/codebuild"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/efs"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/servicediscovery"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	awscommon "github.com/sockerless/aws-common"
+	core "github.com/sockerless/backend-core"
 )
 
 // AWSClients holds all AWS SDK clients for the Lambda backend.
 type AWSClients struct {
 	Lambda     *lambda.Client
 	CloudWatch *cloudwatchlogs.Client
-	ECR        *ecr.Client
-	CodeBuild  *codebuild.Client
-	S3         *s3.Client
+	// CloudWatchMetrics reads Lambda Insights metrics for `docker
+	// stats` when no reverse agent is connected.
+	CloudWatchMetrics *cloudwatch.Client
+	ECR               *ecr.Client
+	CodeBuild         *codebuild.Client
+	S3                *s3.Client
+	Secrets           *secretsmanager.Client // registry mirror upstream credentials
 	// EFS client backs named-volume provisioning via
 	// awscommon.EFSManager (shared with ECS) + Function.FileSystemConfigs[]
 	// attach on CreateFunction.
@@ -36,6 +47,12 @@ type AWSClients struct {
 	// EC2 client backs VPC ID resolution from configured subnet IDs
 	// (Cloud Map private DNS namespace creation requires VpcId).
 	EC2 *ec2.Client
+	// IAM + STS back the permission preflight: caller identity and
+	// iam:SimulatePrincipalPolicy.
+	IAM *iam.Client
+	STS *sts.Client
+	// DynamoDB backs the HA lease store (SOCKERLESS_HA_STORE).
+	DynamoDB *dynamodb.Client
 }
 
 // NewAWSClients initializes AWS SDK clients from config.
@@ -49,11 +66,19 @@ func NewAWSClients(ctx context.Context, region string, endpointURL string) (*AWS
 			credentials.NewStaticCredentialsProvider("test", "test", ""),
 		))
 	}
+	recorder, err := core.CassetteHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	if recorder != nil {
+		opts = append(opts, awsconfig.WithHTTPClient(recorder))
+	}
 
 	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
+	cfg.APIOptions = append(cfg.APIOptions, awscommon.CloudAPIMetrics)
 
 	if endpointURL != "" {
 		return newClientsWithEndpoint(cfg, endpointURL), nil
@@ -63,26 +88,36 @@ func NewAWSClients(ctx context.Context, region string, endpointURL string) (*AWS
 
 func newClientsFromConfig(cfg aws.Config) *AWSClients {
 	return &AWSClients{
-		Lambda:           lambda.NewFromConfig(cfg),
-		CloudWatch:       cloudwatchlogs.NewFromConfig(cfg),
-		ECR:              ecr.NewFromConfig(cfg),
-		CodeBuild:        codebuild.NewFromConfig(cfg),
-		S3:               s3.NewFromConfig(cfg),
-		EFS:              efs.NewFromConfig(cfg),
-		ServiceDiscovery: servicediscovery.NewFromConfig(cfg),
-		EC2:              ec2.NewFromConfig(cfg),
+		Lambda:            lambda.NewFromConfig(cfg),
+		CloudWatch:        cloudwatchlogs.NewFromConfig(cfg),
+		CloudWatchMetrics: cloudwatch.NewFromConfig(cfg),
+		ECR:               ecr.NewFromConfig(cfg),
+		CodeBuild:         codebuild.NewFromConfig(cfg),
+		S3:                s3.NewFromConfig(cfg),
+		Secrets:           secretsmanager.NewFromConfig(cfg),
+		EFS:               efs.NewFromConfig(cfg),
+		ServiceDiscovery:  servicediscovery.NewFromConfig(cfg),
+		EC2:               ec2.NewFromConfig(cfg),
+		IAM:               iam.NewFromConfig(cfg),
+		STS:               sts.NewFromConfig(cfg),
+		DynamoDB:          dynamodb.NewFromConfig(cfg),
 	}
 }
 
 func newClientsWithEndpoint(cfg aws.Config, endpoint string) *AWSClients {
 	return &AWSClients{
-		Lambda:           lambda.NewFromConfig(cfg, func(o *lambda.Options) { o.BaseEndpoint = aws.String(endpoint) }),
-		CloudWatch:       cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) { o.BaseEndpoint = aws.String(endpoint) }),
-		ECR:              ecr.NewFromConfig(cfg, func(o *ecr.Options) { o.BaseEndpoint = aws.String(endpoint) }),
-		CodeBuild:        codebuild.NewFromConfig(cfg, func(o *codebuild.Options) { o.BaseEndpoint = aws.String(endpoint) }),
-		S3:               s3.NewFromConfig(cfg, func(o *s3.Options) { o.BaseEndpoint = aws.String(endpoint) }),
-		EFS:              efs.NewFromConfig(cfg, func(o *efs.Options) { o.BaseEndpoint = aws.String(endpoint) }),
-		ServiceDiscovery: servicediscovery.NewFromConfig(cfg, func(o *servicediscovery.Options) { o.BaseEndpoint = aws.String(endpoint) }),
-		EC2:              ec2.NewFromConfig(cfg, func(o *ec2.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		Lambda:            lambda.NewFromConfig(cfg, func(o *lambda.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		CloudWatch:        cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		CloudWatchMetrics: cloudwatch.NewFromConfig(cfg, func(o *cloudwatch.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		ECR:               ecr.NewFromConfig(cfg, func(o *ecr.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		CodeBuild:         codebuild.NewFromConfig(cfg, func(o *codebuild.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		S3:                s3.NewFromConfig(cfg, func(o *s3.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		Secrets:           secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		EFS:               efs.NewFromConfig(cfg, func(o *efs.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		ServiceDiscovery:  servicediscovery.NewFromConfig(cfg, func(o *servicediscovery.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		EC2:               ec2.NewFromConfig(cfg, func(o *ec2.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		IAM:               iam.NewFromConfig(cfg, func(o *iam.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		STS:               sts.NewFromConfig(cfg, func(o *sts.Options) { o.BaseEndpoint = aws.String(endpoint) }),
+		DynamoDB:          dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) { o.BaseEndpoint = aws.String(endpoint) }),
 	}
 }
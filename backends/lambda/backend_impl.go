@@ -298,7 +298,10 @@ func (s *Server) ContainerCreate(req *api.ContainerCreateRequest) (*api.Containe
 		// invokes the existing function; ContainerRemove returns it to the
 		// pool (or deletes if pool is over POOL_MAX).
 		if s.config.PoolMax > 0 {
-			if claimed, claimErr := s.claimFreeFunction(s.ctx(), contentTag, id, name); claimErr == nil && claimed != "" {
+			claimed, claimErr := s.claimFreeFunction(s.ctx(), contentTag, id, name)
+			hit := claimErr == nil && claimed != ""
+			s.Prom.ObservePoolClaim(hit)
+			if hit {
 				return s.finishPoolHitContainerCreate(id, container, claimed, contentTag, name, config.Image)
 			}
 		}
@@ -1240,6 +1243,18 @@ func (s *Server) ImageLoad(r io.Reader) (io.ReadCloser, error) {
 	return s.images.Load(r)
 }
 
+// ImageImport delegates to ImageManager, which pushes the imported
+// image into the cloud registry.
+func (s *Server) ImageImport(r io.Reader, opts core.ImageImportOptions) (io.ReadCloser, error) {
+	return s.images.Import(r, opts)
+}
+
+// ContainerCheckpoint delegates to ImageManager, which pushes the
+// committed image into the cloud registry for `sockerless migrate`.
+func (s *Server) ContainerCheckpoint(ref string) (*core.ContainerCheckpoint, error) {
+	return s.images.Checkpoint(ref)
+}
+
 // ImageBuild delegates to the shared ImageManager.
 func (s *Server) ImageBuild(opts api.ImageBuildOptions, buildContext io.Reader) (io.ReadCloser, error) {
 	return s.images.Build(opts, buildContext)
@@ -30,6 +30,13 @@ type Config struct {
 	PollInterval     time.Duration // Cloud API poll interval (default 2s)
 	CallbackURL      string        // Reverse-agent callback URL injected into Lambda functions; must be reachable from Lambda (public or VPC endpoint). Empty => exec unsupported.
 
+	// ImageRepository is the registry prefix `docker load` and
+	// `docker import` push images under when their tag is not already an ECR
+	// reference. Defaults to the account's ECR registry derived from
+	// RoleARN and Region, one ECR repository per image name.
+	// Set via SOCKERLESS_LAMBDA_IMAGE_REPOSITORY.
+	ImageRepository string
+
 	// Overlay image build. Used when CallbackURL is set, to layer the
 	// agent + bootstrap binaries on top of the user's requested image
 	// so `docker exec` can reach a running invocation. Paths are
@@ -109,6 +116,33 @@ type Config struct {
 	// VPC-bound).
 	// Set via SOCKERLESS_LAMBDA_NETWORK_DISCOVERY.
 	NetworkDiscovery api.NetworkDiscoveryKind
+
+	// ImageScan selects the vulnerability scanner and the admission
+	// policy applied to create / pull. Set via SOCKERLESS_IMAGE_SCANNER,
+	// SOCKERLESS_VULN_FEED, SOCKERLESS_SCAN_BLOCK_SEVERITY and
+	// SOCKERLESS_SCAN_ENFORCE.
+	ImageScan core.ImageScanConfig
+
+	// ImageVerify is the image signature trust policy applied to
+	// create. Set via the SOCKERLESS_VERIFY_* variables.
+	ImageVerify core.ImageVerifyConfig
+
+	// RegistryMirror holds the upstream registry credentials stored in
+	// the cloud secret store when sockerless creates a pull-through
+	// cache. Set via SOCKERLESS_REGISTRY_MIRROR_AUTH.
+	RegistryMirror core.RegistryMirrorConfig
+
+	// Costs configures per-run cost accounting and budgets. Set via
+	// the SOCKERLESS_COST_* variables.
+	Costs core.CostConfig
+
+	// Audit configures the audit log of cloud mutations. Set via the
+	// SOCKERLESS_AUDIT_* variables; the sink is a CloudWatch Logs log group.
+	Audit core.AuditConfig
+
+	// HA configures multi-instance operation. Set via the SOCKERLESS_HA_*
+	// variables; the lease store is a DynamoDB table.
+	HA core.HAConfig
 }
 
 // SharedVolume describes a workspace volume mounted via EFS that the
@@ -147,6 +181,7 @@ func ConfigFromEnv() Config {
 		CodeBuildProject:     firstNonEmpty(os.Getenv("SOCKERLESS_LAMBDA_CODEBUILD_PROJECT"), os.Getenv("SOCKERLESS_CODEBUILD_PROJECT"), os.Getenv("SOCKERLESS_AWS_CODEBUILD_PROJECT")),
 		BuildBucket:          firstNonEmpty(os.Getenv("SOCKERLESS_LAMBDA_BUILD_BUCKET"), os.Getenv("SOCKERLESS_BUILD_BUCKET"), os.Getenv("SOCKERLESS_AWS_BUILD_BUCKET")),
 		EndpointURL:          os.Getenv("SOCKERLESS_ENDPOINT_URL"),
+		ImageRepository:      os.Getenv("SOCKERLESS_LAMBDA_IMAGE_REPOSITORY"),
 		PollInterval:         parseDuration(os.Getenv("SOCKERLESS_POLL_INTERVAL"), 2*time.Second),
 		CallbackURL:          os.Getenv("SOCKERLESS_CALLBACK_URL"),
 		AgentBinaryPath:      envOrDefault("SOCKERLESS_AGENT_BINARY", "/opt/sockerless/sockerless-agent"),
@@ -158,6 +193,12 @@ func ConfigFromEnv() Config {
 		SharedVolumes:        parseSharedVolumes(os.Getenv("SOCKERLESS_LAMBDA_SHARED_VOLUMES")),
 		PoolMax:              envOrDefaultInt("SOCKERLESS_LAMBDA_POOL_MAX", 10),
 		NetworkDiscovery:     networkDiscoveryFromEnv("SOCKERLESS_LAMBDA_NETWORK_DISCOVERY", api.NetworkDiscoveryNATGatewayOnly),
+		ImageScan:            core.ImageScanConfigFromEnv(),
+		ImageVerify:          core.ImageVerifyConfigFromEnv(),
+		RegistryMirror:       core.RegistryMirrorConfigFromEnv(),
+		Costs:                core.CostConfigFromEnv(),
+		Audit:                core.AuditConfigFromEnv(),
+		HA:                   core.HAConfigFromEnv(),
 	}
 }
 
@@ -291,6 +332,12 @@ func ConfigFromEnvironment(env *core.Environment, sim *core.SimulatorConfig) Con
 		c.EndpointURL = fmt.Sprintf("http://localhost:%d", sim.Port)
 	}
 	c.NetworkDiscovery = networkDiscoveryFromEnv("SOCKERLESS_LAMBDA_NETWORK_DISCOVERY", api.NetworkDiscoveryNATGatewayOnly)
+	c.ImageScan = core.ImageScanConfigFromEnv()
+	c.ImageVerify = core.ImageVerifyConfigFromEnv()
+	c.RegistryMirror = core.RegistryMirrorConfigFromEnv()
+	c.Costs = core.CostConfigFromEnv()
+	c.Audit = core.AuditConfigFromEnv()
+	c.HA = core.HAConfigFromEnv()
 	return c
 }
 
@@ -314,7 +361,22 @@ func (c Config) Validate() error {
 	if c.NetworkDiscovery == api.NetworkDiscoveryServiceMesh && len(c.SubnetIDs) == 0 {
 		return fmt.Errorf("SOCKERLESS_LAMBDA_NETWORK_DISCOVERY=service-mesh requires SOCKERLESS_LAMBDA_SUBNETS — Cloud Map private DNS namespaces are bound to a VPC, resolved from the first configured subnet")
 	}
-	return nil
+	if err := c.ImageScan.Validate(); err != nil {
+		return err
+	}
+	if err := c.ImageVerify.Validate(); err != nil {
+		return err
+	}
+	if err := c.RegistryMirror.Validate(); err != nil {
+		return err
+	}
+	if err := c.Costs.Validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+	return c.HA.Validate()
 }
 
 func envOrDefault(key, def string) string {
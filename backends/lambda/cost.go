@@ -0,0 +1,22 @@
+package lambda
+
+import (
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// lambdaEphemeralGiB is the /tmp size functions are created with (the
+// Lambda default, inside the free allowance).
+const lambdaEphemeralGiB = 0.5
+
+// ContainerCostShape prices a container as one invocation of its
+// function at the configured memory size. Named volumes are EFS access
+// points.
+func (s *Server) ContainerCostShape(*api.Container) core.CostShape {
+	return core.CostShape{
+		Kind:         core.CostKindLambda,
+		MemoryGiB:    float64(s.config.MemorySize) / 1024,
+		EphemeralGiB: lambdaEphemeralGiB,
+		StorageClass: core.CostStorageEFS,
+	}
+}
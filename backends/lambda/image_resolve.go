@@ -5,9 +5,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/ecr"
-	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	core "github.com/sockerless/backend-core"
 )
 
 // resolveImageURI converts a Docker image reference to a registry that
@@ -23,17 +21,21 @@ import (
 //     ECR pull-through cache that targets the AWS Public Gallery
 //     mirror at `public.ecr.aws/docker/library/`. AWS hosts the
 //     mirror; no Docker Hub credentials needed.
-//  3. Other public registries (`public.ecr.aws/...`, `ghcr.io/...`,
-//     `quay.io/...`, etc.) route through their own pull-through cache
-//     rules — none need credentials when caching public images.
-//  4. Docker Hub user/org refs (`myorg/myapp`) are rejected with a
-//     clear error because (a) AWS Public Gallery only mirrors
-//     `library/` and (b) Docker Hub user-image pull-through needs
-//     Docker Hub PAT-backed auth which the project's
-//     no-credentials-on-disk discipline avoids by design. Operators
-//     who need such an image should `docker push` it to their ECR
-//     repo first.
+//  3. Registries ECR pull-through supports (`public.ecr.aws/...`,
+//     `ghcr.io/...`, `quay.io/...`, etc.) route through the cache rule
+//     for the upstream, which s.Mirrors creates on first use. Any other
+//     registry is rejected: Lambda can't pull from it.
+//  4. Docker Hub user/org refs (`myorg/myapp`) go through a docker-hub
+//     pull-through cache when SOCKERLESS_REGISTRY_MIRROR_AUTH holds a
+//     Docker Hub login (ECR requires one for that upstream; it is
+//     stored in Secrets Manager). Without one they are rejected with a
+//     clear error — AWS Public Gallery only mirrors `library/`.
 func (s *Server) resolveImageURI(ctx context.Context, ref string) (string, error) {
+	// Images from `docker load` / `docker import` run from the ECR
+	// copy they were pushed to.
+	if published := s.images.RegistryRef(ref); published != ref {
+		return published, nil
+	}
 	if strings.Contains(ref, ".dkr.ecr.") && strings.Contains(ref, ".amazonaws.com") {
 		return ref, nil
 	}
@@ -57,102 +59,36 @@ func (s *Server) resolveImageURI(ctx context.Context, ref string) (string, error
 		)
 	}
 
-	// Decompose the reference. For public.ecr.aws/<path> we treat it
-	// the same as any registry-prefixed ref so it goes through a
-	// pull-through cache (Lambda needs single-arch ECR-hosted images).
 	registry, repo, tag := parseDockerRef(ref)
 
-	var cachePrefix string
-	var upstreamURL string
-	var upstreamKind ecrtypes.UpstreamRegistry
-
-	switch registry {
-	case "", "docker.io", "registry-1.docker.io":
-		// Library images live on AWS Public Gallery as `docker/library/<name>`.
-		// Strip the explicit `library/` prefix some clients emit
-		// (gitlab-runner resolves `alpine:latest` to
-		// `docker.io/library/alpine:latest` before Cmd dispatch) so the
-		// "user/org image rejected" guard below doesn't false-positive.
-		repo = strings.TrimPrefix(repo, "library/")
-		if strings.Contains(repo, "/") {
-			return ref, fmt.Errorf("docker hub user/org image %q is not on AWS Public Gallery; push it to your ECR repository first (sockerless avoids Docker Hub PAT credentials by design — use `docker push <ecr-uri>` to host the image yourself, or reference its public.ecr.aws equivalent if one exists)", ref)
-		}
-		upstreamURL = "public.ecr.aws"
-		upstreamKind = ecrtypes.UpstreamRegistryEcrPublic
-		repo = "docker/library/" + repo
-		cachePrefix = strings.ReplaceAll(upstreamURL, ".", "-")
-	case "public.ecr.aws":
-		upstreamURL = "public.ecr.aws"
-		upstreamKind = ecrtypes.UpstreamRegistryEcrPublic
-		cachePrefix = strings.ReplaceAll(upstreamURL, ".", "-")
-	default:
-		cachePrefix = strings.ReplaceAll(registry, ".", "-")
-		upstreamURL = registry
-		upstreamKind = upstreamRegistryFor(registry)
+	upstream, mirrored := s.Mirrors.Route(ref)
+	if !mirrored && upstream != "docker.io" && upstream != "public.ecr.aws" {
+		return ref, fmt.Errorf("image %q is on %s, which ECR pull-through cache does not support; push it to your ECR repository first", ref, upstream)
 	}
-
-	if err := s.ensurePullThroughCache(ctx, cachePrefix, upstreamURL, upstreamKind); err != nil {
-		return ref, fmt.Errorf("ECR pull-through cache setup for %q: %w", cachePrefix, err)
+	if !mirrored {
+		return ref, fmt.Errorf("docker hub user/org image %q is not on AWS Public Gallery; push it to your ECR repository first, or add a Docker Hub login to the SOCKERLESS_REGISTRY_MIRROR_AUTH config file so sockerless can create a docker-hub pull-through cache", ref)
+	}
+	if upstream == "public.ecr.aws" && core.MirrorUpstream(registry) == "docker.io" {
+		// Library images live on AWS Public Gallery as
+		// `docker/library/<name>`. Strip the explicit `library/` prefix
+		// some clients emit (gitlab-runner resolves `alpine:latest` to
+		// `docker.io/library/alpine:latest` before Cmd dispatch).
+		repo = "docker/library/" + strings.TrimPrefix(repo, "library/")
 	}
 
-	accountID := extractAccountID(s.config.RoleARN)
-	if accountID == "" {
+	if extractAccountID(s.config.RoleARN) == "" {
 		return "", fmt.Errorf("cannot determine AWS account ID from role ARN %q", s.config.RoleARN)
 	}
+	mirror, _, err := s.Mirrors.Ensure(ctx, upstream)
+	if err != nil {
+		return ref, fmt.Errorf("ECR pull-through cache for %s: %w", upstream, err)
+	}
 
-	ecrURI := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s/%s:%s", accountID, s.config.Region, cachePrefix, repo, tag)
+	ecrURI := fmt.Sprintf("%s/%s:%s", mirror.URI, repo, tag)
 	s.Logger.Info().Str("original", ref).Str("ecr", ecrURI).Msg("resolved image to ECR pull-through cache URI")
 	return ecrURI, nil
 }
 
-// upstreamRegistryFor maps a hostname to ECR's UpstreamRegistry enum.
-func upstreamRegistryFor(registry string) ecrtypes.UpstreamRegistry {
-	switch registry {
-	case "ghcr.io":
-		return ecrtypes.UpstreamRegistryGitHubContainerRegistry
-	case "quay.io":
-		return ecrtypes.UpstreamRegistryQuay
-	case "registry.k8s.io", "k8s.gcr.io":
-		return ecrtypes.UpstreamRegistryK8s
-	case "mcr.microsoft.com":
-		return ecrtypes.UpstreamRegistryAzureContainerRegistry
-	case "public.ecr.aws":
-		return ecrtypes.UpstreamRegistryEcrPublic
-	default:
-		return ecrtypes.UpstreamRegistryEcrPublic
-	}
-}
-
-// ensurePullThroughCache creates an ECR pull-through cache rule if it
-// doesn't exist. No CredentialArn is set — sockerless only routes
-// public registries through pull-through cache (Docker Hub library
-// refs go via AWS Public Gallery, see resolveImageURI). Operators who
-// need authenticated upstreams should provision the rule and the
-// secret out of band; sockerless will pick up the existing rule via
-// the describe call above and use it as-is.
-func (s *Server) ensurePullThroughCache(ctx context.Context, prefix, upstreamURL string, upstreamKind ecrtypes.UpstreamRegistry) error {
-	rules, err := s.aws.ECR.DescribePullThroughCacheRules(ctx, &ecr.DescribePullThroughCacheRulesInput{
-		EcrRepositoryPrefixes: []string{prefix},
-	})
-	if err == nil && len(rules.PullThroughCacheRules) > 0 {
-		return nil
-	}
-
-	in := &ecr.CreatePullThroughCacheRuleInput{
-		EcrRepositoryPrefix: aws.String(prefix),
-		UpstreamRegistryUrl: aws.String(upstreamURL),
-		UpstreamRegistry:    upstreamKind,
-	}
-	if _, err := s.aws.ECR.CreatePullThroughCacheRule(ctx, in); err != nil {
-		if strings.Contains(err.Error(), "PullThroughCacheRuleAlreadyExists") {
-			return nil
-		}
-		return fmt.Errorf("create pull-through cache rule: %w", err)
-	}
-	s.Logger.Info().Str("prefix", prefix).Str("upstream", upstreamURL).Msg("created ECR pull-through cache rule")
-	return nil
-}
-
 // parseDockerRef splits "nginx:alpine" into ("", "nginx", "alpine").
 func parseDockerRef(ref string) (registry, repo, tag string) {
 	tag = "latest"
@@ -194,6 +130,21 @@ func (s *Server) overlayECRRepo() (string, error) {
 	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/sockerless-live-lambda", accountID, s.config.Region), nil
 }
 
+// loadImageRepository returns the registry prefix `docker load` /
+// `docker import` push images under: SOCKERLESS_LAMBDA_IMAGE_REPOSITORY,
+// else the account's ECR registry derived from the function role ARN.
+// ECR repositories are created per image name on push.
+func loadImageRepository(config Config) string {
+	if config.ImageRepository != "" {
+		return config.ImageRepository
+	}
+	accountID := extractAccountID(config.RoleARN)
+	if accountID == "" || config.Region == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", accountID, config.Region)
+}
+
 // extractAccountID returns the AWS account ID from an IAM ARN.
 // "arn:aws:iam::123456789012:role/name" → "123456789012"
 func extractAccountID(arn string) string {
@@ -17,6 +17,7 @@ import (
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	core "github.com/sockerless/backend-core"
 )
 
 var dockerClient *client.Client
@@ -68,11 +69,19 @@ func requireExe(name string) {
 //	SOCKERLESS_LAMBDA_ARCHITECTURE) and fails
 //	loud on any missing.
 //
+// SOCKERLESS_TEST_TARGET = replay → harness serves the cassette named by
+//
+//	SOCKERLESS_TEST_CASSETTE in place of a
+//	simulator or cloud, with the sim fixtures.
+//
+// SOCKERLESS_TEST_RECORD=<path> with the sim or cloud target records the
+// backend's endpoint traffic into a cassette for later replay.
+//
 // The Test* functions don't know which target they're running against.
 func TestMain(m *testing.M) {
 	target := requireEnv("SOCKERLESS_TEST_TARGET")
-	if target != "sim" && target != "cloud" {
-		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\" or \"cloud\").\n", target)
+	if target != "sim" && target != "cloud" && target != "replay" {
+		fmt.Fprintf(os.Stderr, "ERROR: SOCKERLESS_TEST_TARGET=%q is invalid (want \"sim\", \"cloud\" or \"replay\").\n", target)
 		os.Exit(1)
 	}
 	requireExe("docker")
@@ -150,6 +159,11 @@ ENTRYPOINT ["/usr/local/bin/sockerless-lambda-bootstrap"]
 		overlayImage = agentTestImageName
 		arch = "arm64"
 
+	case "replay":
+		roleARN = "arn:aws:iam::000000000000:role/sim"
+		overlayImage = agentTestImageName
+		arch = "arm64"
+
 	case "cloud":
 		endpointURL = requireEnv("SOCKERLESS_ENDPOINT_URL")
 		roleARN = requireEnv("SOCKERLESS_LAMBDA_ROLE_ARN")
@@ -158,6 +172,17 @@ ENTRYPOINT ["/usr/local/bin/sockerless-lambda-bootstrap"]
 		agentTestImageName = overlayImage
 	}
 
+	// SOCKERLESS_TEST_RECORD records the session into a cassette;
+	// the replay target serves one instead of a simulator or cloud.
+	cassette, err := core.CassetteHarnessFromEnv(target, endpointURL)
+	if err != nil {
+		failClean("ERROR: cassette harness: %v\n", err)
+	}
+	if cassette != nil {
+		fmt.Printf("[cassette] %s %s via %s\n", cassette.Mode, cassette.Path, cassette.URL)
+		endpointURL = cassette.URL
+	}
+
 	backendDir := repoRoot + "/backends/lambda"
 	backendBinary := backendDir + "/sockerless-backend-lambda"
 	fmt.Println("[backend] Building sockerless-backend-lambda...")
@@ -197,7 +222,6 @@ ENTRYPOINT ["/usr/local/bin/sockerless-lambda-bootstrap"]
 	}
 	fmt.Printf("[backend] ready on %s\n", backendAddr)
 
-	var err error
 	dockerClient, err = client.NewClientWithOpts(
 		client.WithHost(fmt.Sprintf("tcp://localhost:%d", backendPort)),
 		client.WithAPIVersionNegotiation(),
@@ -208,6 +232,9 @@ ENTRYPOINT ["/usr/local/bin/sockerless-lambda-bootstrap"]
 
 	code := m.Run()
 	cleanup()
+	if cassette != nil {
+		code = cassette.Finish(code)
+	}
 	os.Exit(code)
 }
 
@@ -0,0 +1,63 @@
+package lambda
+
+import (
+	"github.com/sockerless/api"
+	awscommon "github.com/sockerless/aws-common"
+	core "github.com/sockerless/backend-core"
+)
+
+// requiredPermissions declares every IAM action the Lambda backend
+// calls under config's driver selection. `sockerless check` probes the
+// set with iam:SimulatePrincipalPolicy; keep it in step with the SDK
+// calls in this package. Exec and the filesystem verbs ride the
+// reverse-agent WebSocket and need no cloud permission.
+func requiredPermissions(config Config) *core.PermissionSet {
+	var set core.PermissionSet
+
+	// Container lifecycle: one function per container (or a claimed
+	// free-pool function), started by Invoke.
+	set.Add("containers", []string{"create", "run"},
+		"lambda:CreateFunction",
+		"lambda:GetFunction",
+		"lambda:ListFunctions",
+		"lambda:ListTags",
+		"lambda:TagResource",
+	)
+	if config.RoleARN != "" {
+		set.AddOn("containers", config.RoleARN, []string{"create", "run"}, "iam:PassRole")
+	}
+	set.Add("containers", []string{"start", "run"}, "lambda:GetFunction", "lambda:InvokeFunction", "lambda:TagResource")
+	set.Add("containers", []string{"stop", "kill"}, "lambda:UpdateFunctionConfiguration")
+	set.Add("containers", []string{"rm", "container prune"},
+		"lambda:DeleteFunction",
+		"lambda:ListFunctions",
+		"lambda:ListTags",
+		"lambda:UntagResource",
+	)
+	set.Add("containers", []string{"ps", "inspect", "wait"}, "lambda:ListFunctions", "lambda:ListTags")
+
+	set.Add("logs cloudwatch", []string{"logs", "attach", "run"}, "logs:DescribeLogStreams", "logs:GetLogEvents")
+	set.Add("stats lambda-insights", []string{"stats"}, "cloudwatch:GetMetricData")
+
+	if config.NetworkDiscovery == api.NetworkDiscoveryServiceMesh {
+		awscommon.AddCloudMapPermissions(&set)
+		set.Add("network-discovery service-mesh", []string{"network create"}, "ec2:DescribeSubnets")
+	}
+	awscommon.AddEFSEphemeralPermissions(&set)
+	awscommon.AddECRPermissions(&set)
+	awscommon.AddECRMirrorPermissions(&set)
+	set.Add("registry", []string{"images"}, "ecr:DescribeRepositories", "ecr:DescribeImages")
+	if config.ImageScan.Scanner == "cloud" {
+		awscommon.AddECRScanPermissions(&set)
+	}
+	if config.CodeBuildProject != "" && config.BuildBucket != "" {
+		awscommon.AddCodeBuildPermissions(&set, config.BuildBucket)
+	}
+	if config.Audit.Sink != "" {
+		awscommon.AddCloudWatchAuditPermissions(&set)
+	}
+	if config.HA.Mode != "" {
+		awscommon.AddDynamoDBLeasePermissions(&set)
+	}
+	return &set
+}
@@ -0,0 +1,97 @@
+package lambda
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/api"
+	core "github.com/sockerless/backend-core"
+)
+
+// TestRequiredPermissions_CoversSDKCalls scans this package for AWS SDK
+// calls and fails when one has no declared IAM action, so a new call
+// can't ship without its preflight entry.
+func TestRequiredPermissions_CoversSDKCalls(t *testing.T) {
+	prefixes := map[string]string{
+		"Lambda":            "lambda",
+		"CloudWatch":        "logs",
+		"CloudWatchMetrics": "cloudwatch",
+		"EC2":               "ec2",
+		"ECR":               "ecr",
+		"ServiceDiscovery":  "servicediscovery",
+	}
+	// IAM action names that differ from the SDK operation name.
+	renamed := map[string]string{"lambda:Invoke": "lambda:InvokeFunction"}
+
+	declared := map[string]bool{}
+	for _, r := range requiredPermissions(Config{
+		NetworkDiscovery: api.NetworkDiscoveryServiceMesh,
+		CodeBuildProject: "proj",
+		BuildBucket:      "bucket",
+	}).Requirements() {
+		declared[r.Permission] = true
+	}
+
+	call := regexp.MustCompile(`\.aws\.(\w+)\.([A-Z]\w+)\(`)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range call.FindAllStringSubmatch(string(src), -1) {
+			prefix, ok := prefixes[m[1]]
+			if !ok {
+				t.Errorf("%s: call on unmapped client %s.%s", f, m[1], m[2])
+				continue
+			}
+			action := prefix + ":" + m[2]
+			if r, ok := renamed[action]; ok {
+				action = r
+			}
+			if !declared[action] {
+				t.Errorf("%s: %s.%s calls %s, which requiredPermissions does not declare", f, m[1], m[2], action)
+			}
+		}
+	}
+}
+
+func TestRequiredPermissions_FollowDriverSelection(t *testing.T) {
+	index := func(set *core.PermissionSet) map[string]core.PermissionRequirement {
+		out := map[string]core.PermissionRequirement{}
+		for _, r := range set.Requirements() {
+			out[r.Permission] = r
+		}
+		return out
+	}
+	nat := index(requiredPermissions(Config{NetworkDiscovery: api.NetworkDiscoveryNATGatewayOnly}))
+	if _, ok := nat["servicediscovery:CreatePrivateDnsNamespace"]; ok {
+		t.Error("nat-gateway-only discovery must not require Cloud Map")
+	}
+	if _, ok := nat["codebuild:StartBuild"]; ok {
+		t.Error("CodeBuild is only required when a project and bucket are configured")
+	}
+	if _, ok := nat["lambda:InvokeFunction"]; !ok {
+		t.Error("lambda:InvokeFunction is always required")
+	}
+
+	withRole := requiredPermissions(Config{RoleARN: "arn:aws:iam::123456789012:role/fn"})
+	var found bool
+	for _, r := range withRole.Requirements() {
+		if r.Permission == "iam:PassRole" {
+			found = r.Resource == "arn:aws:iam::123456789012:role/fn"
+		}
+	}
+	if !found {
+		t.Error("iam:PassRole must be probed against the configured function role")
+	}
+}
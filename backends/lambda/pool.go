@@ -20,14 +20,13 @@ import (
 //   sockerless-allocation=<containerID>— claimed by this container; missing/empty = free
 //
 // Lambda doesn't expose etag / RevisionId-based CAS on tag mutations the
-// way GCP and Azure do. Concurrent claims from multiple sockerless
-// instances would technically race; in single-instance deployments
-// (the documented norm) the race is impossible. Operators running
-// multiple sockerless backends against the same AWS account should
-// either (a) pin POOL_MAX=0 to disable pool reuse, or (b) tolerate
-// the rare double-claim — the second container's invoke runs against
-// the same function, which is idempotent for sockerless's
-// invoke-then-rm cycle.
+// way GCP and Azure do, so tags alone can't stop two sockerless
+// instances claiming the same function. With SOCKERLESS_HA_MODE set,
+// a claim first takes the HA lease pool/<function> (a conditional
+// write to the lease table) and holds it until the function is
+// released; the instance that takes over a dead one's containers
+// inherits their pool leases. Without HA, a single instance is assumed
+// and the lease is a no-op.
 
 // claimFreeFunction lists sockerless-managed Lambda functions for the
 // given overlay-content-tag and claims one whose `sockerless-allocation`
@@ -67,10 +66,24 @@ func (s *Server) claimFreeFunction(ctx context.Context, contentTag, containerID,
 				continue
 			}
 			// Claim by tagging. Lambda's TagResource is idempotent —
-			// last-write-wins. With multi-instance concurrent claims a
-			// later sockerless might win the same function; documented
-			// limitation (see file header).
-			_, err := s.aws.Lambda.TagResource(ctx, &awslambda.TagResourceInput{
+			// last-write-wins — so with HA the pool lease decides
+			// which instance may tag (see file header).
+			held, err := s.HA.Acquire(ctx, poolLeaseKey(fnName))
+			if err != nil {
+				return "", fmt.Errorf("acquire pool lease for %s: %w", fnName, err)
+			}
+			if !held {
+				continue
+			}
+			if s.HA != nil {
+				// Tags read before the lease was won may be stale.
+				tags, terr = s.aws.Lambda.ListTags(ctx, &awslambda.ListTagsInput{Resource: aws.String(fnArn)})
+				if terr != nil || tags.Tags["sockerless-allocation"] != "" {
+					s.releasePoolLease(ctx, fnName)
+					continue
+				}
+			}
+			_, err = s.aws.Lambda.TagResource(ctx, &awslambda.TagResourceInput{
 				Resource: aws.String(fnArn),
 				Tags: map[string]string{
 					"sockerless-allocation":   shortAllocLabelLambda(containerID),
@@ -79,6 +92,7 @@ func (s *Server) claimFreeFunction(ctx context.Context, contentTag, containerID,
 				},
 			})
 			if err != nil {
+				s.releasePoolLease(ctx, fnName)
 				continue
 			}
 			return fnName, nil
@@ -137,10 +151,12 @@ func (s *Server) releaseOrDeleteFunction(ctx context.Context, fnName, contentTag
 		})
 		if err != nil {
 			if strings.Contains(err.Error(), "ResourceNotFoundException") {
+				s.releasePoolLease(ctx, fnName)
 				return nil
 			}
 			return fmt.Errorf("delete function %s: %w", fnName, err)
 		}
+		s.releasePoolLease(ctx, fnName)
 		return nil
 	}
 
@@ -158,13 +174,29 @@ func (s *Server) releaseOrDeleteFunction(ctx context.Context, fnName, contentTag
 		// job is to ensure the cloud is in the "gone" state, and a
 		// missing function already satisfies that.
 		if strings.Contains(err.Error(), "ResourceNotFoundException") {
+			s.releasePoolLease(ctx, fnName)
 			return nil
 		}
 		return fmt.Errorf("release function %s: %w", fnName, err)
 	}
+	s.releasePoolLease(ctx, fnName)
 	return nil
 }
 
+// poolLeaseKey is the HA lease that guards claims on pooled function
+// fnName.
+func poolLeaseKey(fnName string) string {
+	return "pool/" + fnName
+}
+
+// releasePoolLease drops this instance's claim on fnName. A failure
+// only delays the next claim until takeover or a later release.
+func (s *Server) releasePoolLease(ctx context.Context, fnName string) {
+	if err := s.HA.Release(ctx, poolLeaseKey(fnName)); err != nil {
+		s.Logger.Warn().Err(err).Str("function", fnName).Msg("release pool lease failed")
+	}
+}
+
 // functionARN constructs an ARN from a function name. Lambda accepts
 // either name or ARN for most operations; UntagResource specifically
 // requires an ARN, hence this helper.
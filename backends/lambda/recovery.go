@@ -34,6 +34,7 @@ func (s *Server) ScanOrphanedResources(ctx context.Context, instanceID string) (
 
 			if managed && matchesInstance {
 				orphans = append(orphans, core.ResourceEntry{
+					ContainerID:  tagsResult.Tags["sockerless-container-id"],
 					Backend:      "lambda",
 					ResourceType: "function",
 					ResourceID:   arn,
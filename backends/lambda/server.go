@@ -72,10 +72,24 @@ func NewServer(config Config, awsClients *AWSClients, logger zerolog.Logger) *Se
 	s.storageBackings.Register(awscommon.NewEFSEphemeralDriver(s.efs))
 	s.storageBackings.Register(core.NewMemoryDriver(64))
 	ecrAuth := awscommon.NewECRAuthProvider(awsClients.ECR, logger, s.ctx)
+	mirrorAuth, err := config.RegistryMirror.Credentials()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid registry mirror credentials")
+	}
+	s.Mirrors = &awscommon.ECRMirrors{
+		ECR:                awsClients.ECR,
+		Secrets:            awsClients.Secrets,
+		AccountID:          extractAccountID(config.RoleARN),
+		Region:             config.Region,
+		Credentials:        mirrorAuth,
+		CachePublicGallery: true,
+		Logger:             logger,
+	}
 	s.images = &core.ImageManager{
-		Base:   s.BaseServer,
-		Auth:   ecrAuth,
-		Logger: logger,
+		Base:           s.BaseServer,
+		Auth:           ecrAuth,
+		LoadRepository: loadImageRepository(config),
+		Logger:         logger,
 	}
 	if svc := awscommon.NewCodeBuildService(
 		awsClients.CodeBuild, awsClients.S3,
@@ -85,7 +99,28 @@ func NewServer(config Config, awsClients *AWSClients, logger zerolog.Logger) *Se
 	}
 	s.SetSelf(s)
 	s.CloudState = &lambdaCloudState{server: s}
+	s.StatsProvider = &core.StatsSources{Agents: s.reverseAgents, Cloud: &lambdaStatsProvider{server: s}}
+	s.InstrumentReverseAgents(s.reverseAgents)
+	s.ConfigureCosts(config.Costs, s)
+	var auditSink core.AuditSink
+	if config.Audit.Sink != "" {
+		auditSink = &awscommon.CloudWatchAuditSink{Client: awsClients.CloudWatch, Group: config.Audit.Sink, Stream: s.Desc.InstanceID}
+	}
+	s.ConfigureAudit(config.Audit, auditSink)
+	if config.HA.Mode != "" {
+		s.ConfigureHA(config.HA, &awscommon.DynamoDBLeaseStore{Client: awsClients.DynamoDB, Table: config.HA.Store})
+	}
+	s.ConfigureImageScanning(config.ImageScan,
+		awscommon.NewECRScanner(awsClients.ECR, s.resolveImageURI, config.PollInterval, core.ImageScanTimeout),
+		s.images.WalkImageLayers)
+	if err := s.ConfigureImageVerification(config.ImageVerify, s.images, s.resolveImageURI); err != nil {
+		logger.Fatal().Err(err).Msg("invalid image signature policy")
+	}
 	s.Access = awscommon.NewIAMRoleAccess(config.RoleARN)
+	s.HealthChecker = core.NewPermissionPreflight(
+		awscommon.NewIAMPermissionProber(awsClients.IAM, awsClients.STS),
+		requiredPermissions(config),
+	)
 
 	// Network-discovery driver. Selected via Config.NetworkDiscovery
 	// (env: SOCKERLESS_LAMBDA_NETWORK_DISCOVERY). Validated to one of
@@ -0,0 +1,93 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	core "github.com/sockerless/backend-core"
+)
+
+// lambdaStatsProvider fetches function metrics from CloudWatch Lambda
+// Insights. It backs core.StatsSources when the invocation's reverse
+// agent is not connected; functions without the Lambda Insights
+// extension publish nothing and report no datapoint.
+type lambdaStatsProvider struct {
+	server *Server
+}
+
+func (p *lambdaStatsProvider) ContainerMetrics(containerID string) (*core.ContainerMetrics, error) {
+	state, ok := p.server.Lambda.Get(containerID)
+	if !ok || state.FunctionName == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	start := now.Add(-5 * time.Minute)
+	dimensions := []cwtypes.Dimension{
+		{Name: aws.String("function_name"), Value: aws.String(state.FunctionName)},
+	}
+	query := func(id, metric, stat string) cwtypes.MetricDataQuery {
+		return cwtypes.MetricDataQuery{
+			Id: aws.String(id),
+			MetricStat: &cwtypes.MetricStat{
+				Metric: &cwtypes.Metric{
+					Namespace:  aws.String("LambdaInsights"),
+					MetricName: aws.String(metric),
+					Dimensions: dimensions,
+				},
+				Period: aws.Int32(60),
+				Stat:   aws.String(stat),
+			},
+		}
+	}
+	result, err := p.server.aws.CloudWatchMetrics.GetMetricData(ctx, &cloudwatch.GetMetricDataInput{
+		StartTime: &start,
+		EndTime:   &now,
+		MetricDataQueries: []cwtypes.MetricDataQuery{
+			query("cpu", "cpu_total_time", "Sum"),
+			query("mem", "used_memory_max", "Maximum"),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloudwatch GetMetricData: %w", err)
+	}
+	return lambdaInsightsMetrics(result.MetricDataResults), nil
+}
+
+// lambdaInsightsMetrics converts one-minute Lambda Insights results:
+// cpu_total_time is CPU milliseconds summed over the period,
+// used_memory_max is MB. Values come newest first; SampledAt is the
+// newest period both metrics cover.
+func lambdaInsightsMetrics(results []cwtypes.MetricDataResult) *core.ContainerMetrics {
+	m := &core.ContainerMetrics{Source: "cloudwatch"}
+	var cpuAt, memAt time.Time
+	for _, r := range results {
+		if len(r.Values) == 0 || len(r.Timestamps) == 0 {
+			continue
+		}
+		switch aws.ToString(r.Id) {
+		case "cpu":
+			m.CPUCores = r.Values[0] / float64(time.Minute/time.Millisecond)
+			cpuAt = r.Timestamps[0]
+		case "mem":
+			m.MemBytes = int64(r.Values[0] * 1024 * 1024)
+			memAt = r.Timestamps[0]
+		}
+	}
+	switch {
+	case cpuAt.IsZero() || memAt.IsZero():
+		// No datapoint yet, or Lambda Insights is not enabled.
+	case cpuAt.Before(memAt):
+		m.SampledAt = cpuAt
+	default:
+		m.SampledAt = memAt
+	}
+	return m
+}
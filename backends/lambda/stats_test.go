@@ -0,0 +1,41 @@
+package lambda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// TestLambdaInsightsMetrics converts summed CPU milliseconds to cores
+// and reports the older of the two newest timestamps.
+func TestLambdaInsightsMetrics(t *testing.T) {
+	cpuAt := time.Date(2026, 1, 1, 12, 1, 0, 0, time.UTC)
+	memAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := lambdaInsightsMetrics([]cwtypes.MetricDataResult{
+		{Id: aws.String("cpu"), Values: []float64{30000, 1}, Timestamps: []time.Time{cpuAt, memAt}},
+		{Id: aws.String("mem"), Values: []float64{128}, Timestamps: []time.Time{memAt}},
+	})
+	if m.CPUCores != 0.5 {
+		t.Errorf("CPUCores = %v, want 0.5", m.CPUCores)
+	}
+	if m.MemBytes != 128<<20 {
+		t.Errorf("MemBytes = %d", m.MemBytes)
+	}
+	if !m.SampledAt.Equal(memAt) {
+		t.Errorf("SampledAt = %v, want %v", m.SampledAt, memAt)
+	}
+	if m.Source != "cloudwatch" {
+		t.Errorf("Source = %q", m.Source)
+	}
+}
+
+// TestLambdaInsightsMetrics_NoDatapoints leaves SampledAt zero when
+// Lambda Insights has published nothing.
+func TestLambdaInsightsMetrics_NoDatapoints(t *testing.T) {
+	m := lambdaInsightsMetrics([]cwtypes.MetricDataResult{{Id: aws.String("cpu")}, {Id: aws.String("mem")}})
+	if !m.SampledAt.IsZero() || m.CPUCores != 0 || m.MemBytes != 0 {
+		t.Errorf("got %+v, want empty metrics", m)
+	}
+}
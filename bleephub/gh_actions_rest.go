@@ -67,6 +67,22 @@ func runStatus(internal string) string {
 	}
 }
 
+// workflowRunStatus is the GitHub status of wf. Like real GitHub, a
+// running workflow stays `queued` until a runner picks up one of its
+// jobs — the signal runner dispatchers poll `?status=queued` for.
+func workflowRunStatus(wf *Workflow) string {
+	status := runStatus(wf.Status)
+	if status != "in_progress" || len(wf.Jobs) == 0 {
+		return status
+	}
+	for _, j := range wf.Jobs {
+		if j.Status != "pending" && j.Status != "queued" {
+			return status
+		}
+	}
+	return "queued"
+}
+
 // runConclusion maps internal Workflow.Result → GitHub's nullable
 // conclusion field (`success`, `failure`, `cancelled`, `skipped`,
 // `timed_out`, etc.). Returned as nil for in-flight runs.
@@ -115,7 +131,7 @@ func workflowRunJSON(wf *Workflow, baseURL, repoName string) map[string]any {
 	}
 	apiBase := fmt.Sprintf("%s/api/v3/repos/%s", baseURL, repoPath)
 	htmlBase := fmt.Sprintf("%s/%s", baseURL, repoPath)
-	status := runStatus(wf.Status)
+	status := workflowRunStatus(wf)
 	return map[string]any{
 		"id":                   int64(wf.RunID),
 		"name":                 wf.Name,
@@ -329,7 +345,7 @@ func (s *Server) handleListWorkflowRuns(w http.ResponseWriter, r *http.Request)
 		if wf.RepoFullName != "" && wf.RepoFullName != repo {
 			continue
 		}
-		if statusFilter != "" && runStatus(wf.Status) != statusFilter {
+		if statusFilter != "" && workflowRunStatus(wf) != statusFilter {
 			continue
 		}
 		if branchFilter != "" && headBranchOf(wf) != branchFilter {
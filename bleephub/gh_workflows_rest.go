@@ -135,7 +135,7 @@ func (s *Server) handleListWorkflowFileRuns(w http.ResponseWriter, r *http.Reque
 		if run.Name != wf.Name {
 			continue
 		}
-		if statusFilter != "" && runStatus(run.Status) != statusFilter {
+		if statusFilter != "" && workflowRunStatus(run) != statusFilter {
 			continue
 		}
 		if branchFilter != "" && headBranchOf(run) != branchFilter {
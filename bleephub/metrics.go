@@ -15,12 +15,18 @@ type Metrics struct {
 	ActiveWorkflows     int64            `json:"active_workflows"`
 	ActiveSessions      int64            `json:"active_sessions"`
 	StartedAt           time.Time        `json:"-"`
+
+	// Prometheus histogram series (see prometheus.go).
+	requestSeries map[string]*promHistogram
+	jobSeries     map[string]*promHistogram
 }
 
 func NewMetrics() *Metrics {
 	return &Metrics{
 		JobCompletions: make(map[string]int64),
 		StartedAt:      time.Now(),
+		requestSeries:  make(map[string]*promHistogram),
+		jobSeries:      make(map[string]*promHistogram),
 	}
 }
 
@@ -48,6 +54,7 @@ func (m *Metrics) RecordJobDispatch() {
 func (m *Metrics) RecordJobCompletion(result string, duration time.Duration) {
 	m.mu.Lock()
 	m.JobCompletions[result]++
+	observePromHistogram(m.jobSeries, promJobBuckets, duration.Seconds(), "result", result)
 	if len(m.JobDurations) < 1000 {
 		m.JobDurations = append(m.JobDurations, duration)
 	} else {
@@ -1,8 +1,13 @@
 package bleephub
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/rs/zerolog"
 )
 
 func TestMetricsSubmitIncrement(t *testing.T) {
@@ -49,3 +54,31 @@ func TestMetricsActiveSessions(t *testing.T) {
 		t.Errorf("sessions = %d, want 1", snap.ActiveSessions)
 	}
 }
+
+func TestMetricsPrometheus(t *testing.T) {
+	s := NewServer("127.0.0.1:0", zerolog.Nop())
+	s.metrics.RecordWorkflowSubmit()
+	s.metrics.RecordJobCompletion("success", 90*time.Second)
+	s.metrics.SetActiveSessions(2)
+
+	handler := s.promMiddleware(s.prefixStripMiddleware(s.mux))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		`sockerless_http_request_duration_seconds_count{backend="bleephub",method="GET",route="/health",code="200"} 1`,
+		`sockerless_workflow_submissions_total{backend="bleephub"} 1`,
+		`sockerless_job_completions_total{backend="bleephub",result="success"} 1`,
+		`sockerless_job_duration_seconds_bucket{backend="bleephub",result="success",le="60"} 0`,
+		`sockerless_job_duration_seconds_bucket{backend="bleephub",result="success",le="120"} 1`,
+		`sockerless_runner_sessions{backend="bleephub"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package bleephub
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prometheus exposition for GET /metrics. bleephub is a standalone module,
+// so this is a small writer of its own; metric and label names follow the
+// sockerless backends' /metrics (backend-core prom_metrics.go) so one
+// dashboard covers both: every series carries backend="bleephub", and
+// request latency is sockerless_http_request_duration_seconds by
+// method, route and code.
+
+const promContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	promRequestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+	promJobBuckets     = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+)
+
+// promHistogram holds one series' per-bucket (non-cumulative) counts;
+// the last count is +Inf.
+type promHistogram struct {
+	labels []string // name, value pairs
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func observePromHistogram(series map[string]*promHistogram, buckets []float64, v float64, labels ...string) {
+	key := strings.Join(labels, "\xff")
+	h, ok := series[key]
+	if !ok {
+		h = &promHistogram{labels: labels, counts: make([]uint64, len(buckets)+1)}
+		series[key] = h
+	}
+	h.counts[sort.SearchFloat64s(buckets, v)]++
+	h.sum += v
+	h.count++
+}
+
+// RecordRequest records one served request for the latency histogram.
+func (m *Metrics) RecordRequest(method, route string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observePromHistogram(m.requestSeries, promRequestBuckets, d.Seconds(),
+		"method", method, "route", route, "code", strconv.Itoa(code))
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	p := promWriter{w: bw}
+
+	p.histogram("sockerless_http_request_duration_seconds", "Latency of requests served by bleephub, by route.", promRequestBuckets, m.requestSeries)
+
+	p.header("sockerless_workflow_submissions_total", "Workflows submitted.", "counter")
+	p.sample("sockerless_workflow_submissions_total", float64(m.WorkflowSubmissions))
+	p.header("sockerless_workflows_active", "Workflows submitted and not yet complete.", "gauge")
+	p.sample("sockerless_workflows_active", float64(m.ActiveWorkflows))
+	p.header("sockerless_job_dispatches_total", "Jobs dispatched to runners.", "counter")
+	p.sample("sockerless_job_dispatches_total", float64(m.JobDispatches))
+	p.header("sockerless_job_completions_total", "Jobs completed, by result (success, failure, cancelled, ...).", "counter")
+	results := make([]string, 0, len(m.JobCompletions))
+	for r := range m.JobCompletions {
+		results = append(results, r)
+	}
+	sort.Strings(results)
+	for _, r := range results {
+		p.sample("sockerless_job_completions_total", float64(m.JobCompletions[r]), "result", r)
+	}
+	p.histogram("sockerless_job_duration_seconds", "Job run time from dispatch to completion, by result.", promJobBuckets, m.jobSeries)
+	p.header("sockerless_runner_sessions", "Runner sessions currently connected.", "gauge")
+	p.sample("sockerless_runner_sessions", float64(m.ActiveSessions))
+	return bw.Flush()
+}
+
+type promWriter struct{ w *bufio.Writer }
+
+func (p promWriter) header(name, help, typ string) {
+	p.w.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " " + typ + "\n")
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// sample writes name{backend="bleephub",labels...} v. labels are
+// name, value pairs.
+func (p promWriter) sample(name string, v float64, labels ...string) {
+	p.w.WriteString(name + `{backend="bleephub"`)
+	for i := 0; i+1 < len(labels); i += 2 {
+		p.w.WriteString("," + labels[i] + `="` + promLabelEscaper.Replace(labels[i+1]) + `"`)
+	}
+	p.w.WriteString("} " + strconv.FormatFloat(v, 'g', -1, 64) + "\n")
+}
+
+func (p promWriter) histogram(name, help string, buckets []float64, series map[string]*promHistogram) {
+	p.header(name, help, "histogram")
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h := series[k]
+		var cum uint64
+		for i, le := range buckets {
+			cum += h.counts[i]
+			p.sample(name+"_bucket", float64(cum), append(h.labels[:len(h.labels):len(h.labels)], "le", strconv.FormatFloat(le, 'g', -1, 64))...)
+		}
+		p.sample(name+"_bucket", float64(h.count), append(h.labels[:len(h.labels):len(h.labels)], "le", "+Inf")...)
+		p.sample(name+"_sum", h.sum, h.labels...)
+		p.sample(name+"_count", float64(h.count), h.labels...)
+	}
+}
+
+// promMiddleware records request latency by matched mux pattern. It wraps
+// prefixStripMiddleware, which passes the same *http.Request to the mux,
+// so r.Pattern is set once the handler returns.
+func (s *Server) promMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: 200}
+		next.ServeHTTP(rw, r)
+		route := "unmatched"
+		if r.Pattern != "" {
+			route = r.Pattern
+			if _, path, ok := strings.Cut(route, " "); ok {
+				route = path
+			}
+		}
+		s.metrics.RecordRequest(r.Method, route, rw.status, time.Since(start))
+	})
+}
+
+func (s *Server) handlePromMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", promContentType)
+	_ = s.metrics.WritePrometheus(w)
+}
@@ -63,11 +63,15 @@ func (s *Server) handleRenewRequest(w http.ResponseWriter, r *http.Request) {
 	json.NewDecoder(r.Body).Decode(&body)
 
 	s.store.mu.Lock()
-	if job.Status == "queued" {
+	acquired := job.Status == "queued"
+	if acquired {
 		job.Status = "running"
 	}
 	job.LockedUntil = time.Now().Add(1 * time.Hour)
 	s.store.mu.Unlock()
+	if acquired {
+		s.markWorkflowJobRunning(job.ID)
+	}
 
 	s.logger.Info().
 		Str("method", r.Method).
@@ -168,6 +168,7 @@ func (s *Server) registerRoutes() {
 
 	// Management API (metrics, status, dashboard data)
 	s.mux.HandleFunc("GET /internal/metrics", s.handleInternalMetrics)
+	s.mux.HandleFunc("GET /metrics", s.handlePromMetrics)
 	s.mux.HandleFunc("GET /internal/status", s.handleInternalStatus)
 	s.registerMgmtRoutes()
 
@@ -228,7 +229,7 @@ func (s *Server) handleInternalStatus(w http.ResponseWriter, r *http.Request) {
 
 // ListenAndServe starts the HTTP server (crash-only, no graceful shutdown).
 func (s *Server) ListenAndServe() error {
-	inner := s.prefixStripMiddleware(s.mux)
+	inner := s.promMiddleware(s.prefixStripMiddleware(s.mux))
 	ghWrapped := s.ghHeadersMiddleware(inner)
 	handler := otelhttp.NewHandler(s.loggingMiddleware(ghWrapped), "bleephub")
 
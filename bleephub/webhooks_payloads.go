@@ -200,3 +200,15 @@ func senderPayload(user *User) map[string]interface{} {
 		"avatar_url": user.AvatarURL,
 	}
 }
+
+// buildWorkflowJobPayload builds the `workflow_job` event payload
+// (action: queued | in_progress | completed). The job object is the same
+// shape GET .../actions/jobs/{job_id} returns.
+func buildWorkflowJobPayload(repo *Repo, jobJSON map[string]interface{}, action string) map[string]interface{} {
+	return attachInstallationBlock(map[string]interface{}{
+		"action":       action,
+		"workflow_job": jobJSON,
+		"repository":   repoPayload(repo),
+		"sender":       nil,
+	}, nil)
+}
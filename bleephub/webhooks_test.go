@@ -1,6 +1,7 @@
 package bleephub
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -680,3 +681,102 @@ func pushTestCommit(t *testing.T, owner, repoName string) {
 		t.Fatalf("push: %v", err)
 	}
 }
+
+func TestWebhookWorkflowJobEvent(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []map[string]interface{}
+	var signatures []string
+
+	url, cleanup := startWebhookReceiver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var p map[string]interface{}
+		json.Unmarshal(body, &p)
+		mu.Lock()
+		payloads = append(payloads, p)
+		if r.Header.Get("X-Hub-Signature-256") == computeHMACSignature("job-secret", body) {
+			signatures = append(signatures, r.Header.Get("X-GitHub-Event"))
+		}
+		mu.Unlock()
+		w.WriteHeader(200)
+	}))
+	defer cleanup()
+
+	createWebhookTestRepo(t, "wh-jobs")
+	resp := ghPost(t, "/api/v3/repos/admin/wh-jobs/hooks", defaultToken, map[string]interface{}{
+		"config": map[string]interface{}{"url": url, "secret": "job-secret"},
+		"events": []string{"workflow_job"},
+		"active": true,
+	})
+	resp.Body.Close()
+
+	wfJSON, _ := json.Marshal(map[string]string{
+		"workflow": "name: wh-jobs\njobs:\n  build:\n    runs-on: [self-hosted, sockerless-ecs]\n    steps:\n      - run: echo hi",
+		"repo":     "admin/wh-jobs",
+	})
+	resp2, err := http.Post(testBaseURL+"/api/v3/bleephub/workflow", "application/json", bytes.NewReader(wfJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var submitted map[string]interface{}
+	json.NewDecoder(resp2.Body).Decode(&submitted)
+	resp2.Body.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	var job map[string]interface{}
+	for time.Now().Before(deadline) && job == nil {
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		for _, p := range payloads {
+			if p["action"] == "queued" {
+				job, _ = p["workflow_job"].(map[string]interface{})
+			}
+		}
+		mu.Unlock()
+	}
+	if job == nil {
+		t.Fatal("no workflow_job queued delivery")
+	}
+	if job["status"] != "queued" {
+		t.Errorf("job status = %v, want queued", job["status"])
+	}
+	labels, _ := job["labels"].([]interface{})
+	if len(labels) != 2 || labels[1] != "sockerless-ecs" {
+		t.Errorf("job labels = %v", job["labels"])
+	}
+	mu.Lock()
+	if len(signatures) == 0 || signatures[0] != "workflow_job" {
+		t.Errorf("signed workflow_job deliveries = %v", signatures)
+	}
+	mu.Unlock()
+
+	// The run reports queued until a runner picks up the job.
+	runsResp := ghGet(t, "/api/v3/repos/admin/wh-jobs/actions/runs?status=queued", defaultToken)
+	runs := decodeJSON(t, runsResp)
+	if runs["total_count"] != float64(1) {
+		t.Errorf("queued runs = %v, want 1", runs["total_count"])
+	}
+
+	// Cancelling the run completes the queued job.
+	wfID, _ := submitted["workflowId"].(string)
+	resp3, err := http.Post(testBaseURL+"/api/v3/bleephub/workflows/"+wfID+"/cancel", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp3.Body.Close()
+	deadline = time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		done := false
+		for _, p := range payloads {
+			if p["action"] == "completed" {
+				done = true
+			}
+		}
+		mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("no workflow_job completed delivery after cancel")
+}
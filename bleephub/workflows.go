@@ -307,6 +307,7 @@ func (s *Server) dispatchReadyJobs(ctx context.Context, wf *Workflow, serverURL
 
 		// Dispatch collected jobs outside the lock (dispatchWorkflowJob acquires its own locks)
 		for _, wfJob := range toDispatch {
+			s.emitWorkflowJobEvent(wf, wfJob, "queued")
 			s.dispatchWorkflowJob(ctx, wf, wfJob, serverURL, defaultImage)
 		}
 
@@ -370,6 +371,45 @@ func (s *Server) dispatchWorkflowJob(ctx context.Context, wf *Workflow, wfJob *W
 		Msg("workflow job dispatched")
 }
 
+// emitWorkflowJobEvent delivers a `workflow_job` webhook for wfJob to
+// the hooks of the workflow's repository. Runner dispatchers listen for
+// action=queued to spawn a runner for the job.
+func (s *Server) emitWorkflowJobEvent(wf *Workflow, wfJob *WorkflowJob, action string) {
+	if wf.RepoFullName == "" {
+		return
+	}
+	baseURL := wf.Env["__serverURL"]
+	if baseURL == "" {
+		baseURL = "http://" + s.addr
+	}
+	parts := splitRepoKeyParts(wf.RepoFullName)
+	s.store.mu.RLock()
+	jobJSON := workflowJobJSON(wf, wfJob, baseURL, wf.RepoFullName)
+	s.store.mu.RUnlock()
+	payload := buildWorkflowJobPayload(s.store.GetRepo(parts[0], parts[1]), jobJSON, action)
+	s.emitWebhookEvent(wf.RepoFullName, "workflow_job", action, payload)
+}
+
+// markWorkflowJobRunning moves the workflow job backing the agent job
+// jobID from queued to running once a runner has acquired it.
+func (s *Server) markWorkflowJobRunning(jobID string) {
+	s.store.mu.Lock()
+	var foundWf *Workflow
+	var foundJob *WorkflowJob
+	for _, wf := range s.store.Workflows {
+		for _, wfJob := range wf.Jobs {
+			if wfJob.JobID == jobID && wfJob.Status == "queued" {
+				wfJob.Status = "running"
+				foundWf, foundJob = wf, wfJob
+			}
+		}
+	}
+	s.store.mu.Unlock()
+	if foundJob != nil {
+		s.emitWorkflowJobEvent(foundWf, foundJob, "in_progress")
+	}
+}
+
 // onJobCompleted is called when a job finishes. It updates the workflow
 // and dispatches any newly-ready dependent jobs.
 func (s *Server) onJobCompleted(ctx context.Context, jobID, result string) {
@@ -427,6 +467,8 @@ func (s *Server) onJobCompleted(ctx context.Context, jobID, result string) {
 	}
 	s.store.mu.Unlock()
 
+	s.emitWorkflowJobEvent(foundWf, foundJob, "completed")
+
 	if s.metrics != nil {
 		duration := time.Since(foundWf.CreatedAt)
 		s.metrics.RecordJobCompletion(foundJob.Result, duration)
@@ -497,8 +539,12 @@ func (s *Server) onJobCompleted(ctx context.Context, jobID, result string) {
 // cancelWorkflow cancels all pending/queued jobs and marks the workflow as cancelled.
 func (s *Server) cancelWorkflow(wf *Workflow) {
 	s.store.mu.Lock()
+	var cancelledQueued []*WorkflowJob
 	for _, wfJob := range wf.Jobs {
 		if wfJob.Status == "pending" || wfJob.Status == "queued" {
+			if wfJob.Status == "queued" {
+				cancelledQueued = append(cancelledQueued, wfJob)
+			}
 			wfJob.Status = "completed"
 			wfJob.Result = "cancelled"
 		}
@@ -507,6 +553,10 @@ func (s *Server) cancelWorkflow(wf *Workflow) {
 	wf.Result = "cancelled"
 	s.store.mu.Unlock()
 
+	for _, wfJob := range cancelledQueued {
+		s.emitWorkflowJobEvent(wf, wfJob, "completed")
+	}
+
 	if wf.cancelTimeout != nil {
 		wf.cancelTimeout()
 	}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// registerAuthAPI wires login / logout / identity and the audit trail.
+//
+// Routes:
+//
+//	GET    /api/v1/auth/providers     (public) which login methods are on
+//	POST   /api/v1/auth/login         (public) local user → session cookie
+//	POST   /api/v1/auth/logout        (public) clear the session cookie
+//	GET    /api/v1/auth/me            the caller's name, role and method
+//	GET    /api/v1/auth/oidc/login    (public) redirect to the provider
+//	GET    /api/v1/auth/oidc/callback (public) provider redirect back
+//	GET    /api/v1/audit?limit=N      recent audit entries (admin)
+//
+// Enforcement itself lives in withAuth (auth_policy.go).
+func registerAuthAPI(mux *http.ServeMux, auth *Authenticator, audit *AuditLog) {
+	mux.HandleFunc("GET /api/v1/auth/providers", handleAuthProviders(auth))
+	mux.HandleFunc("POST /api/v1/auth/login", handleAuthLogin(auth, audit))
+	mux.HandleFunc("POST /api/v1/auth/logout", handleAuthLogout(auth))
+	mux.HandleFunc("GET /api/v1/auth/me", handleAuthMe(auth))
+	mux.HandleFunc("GET /api/v1/auth/oidc/login", handleOIDCLogin(auth))
+	mux.HandleFunc("GET /api/v1/auth/oidc/callback", handleOIDCCallback(auth, audit))
+	mux.HandleFunc("GET /api/v1/audit", handleAuditList(audit))
+}
+
+// authProviders tells the SPA which login options to render.
+type authProviders struct {
+	Enabled  bool   `json:"enabled"`
+	Password bool   `json:"password"`
+	OIDC     string `json:"oidc,omitempty"`
+}
+
+func handleAuthProviders(auth *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := authProviders{Enabled: auth.Enabled()}
+		if auth.Enabled() {
+			out.Password = len(auth.cfg.Users) > 0
+			if auth.oidc != nil {
+				out.OIDC = auth.oidc.cfg.Name
+			}
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+func handleAuthLogin(auth *Authenticator, audit *AuditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.Enabled() {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "authentication is disabled"})
+			return
+		}
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := decodeJSON(r.Body, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+			return
+		}
+		entry := AuditEntry{User: req.Username, Action: "POST /api/v1/auth/login", Path: r.URL.Path, Remote: remoteHost(r)}
+		p, ok := auth.Login(req.Username, req.Password)
+		if !ok {
+			entry.Status, entry.Detail = http.StatusUnauthorized, "bad credentials"
+			audit.Record(entry)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid username or password"})
+			return
+		}
+		auth.SetSession(w, r, p)
+		entry.Role, entry.Auth, entry.Status, entry.Detail = p.Role, p.Method, http.StatusOK, "login"
+		audit.Record(entry)
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+func handleAuthLogout(auth *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth.ClearSession(w)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "logged out"})
+	}
+}
+
+func handleAuthMe(auth *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := principalFrom(r.Context())
+		if !ok {
+			// Only reachable when the handler runs without withAuth.
+			p, _ = auth.Authenticate(r)
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+func handleOIDCLogin(auth *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if auth.oidc == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "OIDC login is not configured"})
+			return
+		}
+		if err := auth.oidc.Begin(w, r, safeNext(r.URL.Query().Get("next"))); err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		}
+	}
+}
+
+func handleOIDCCallback(auth *Authenticator, audit *AuditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if auth.oidc == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "OIDC login is not configured"})
+			return
+		}
+		entry := AuditEntry{Action: "GET /api/v1/auth/oidc/callback", Path: r.URL.Path, Remote: remoteHost(r), Auth: "oidc"}
+		user, next, err := auth.oidc.Finish(r)
+		if err != nil {
+			entry.User, entry.Status, entry.Detail = "-", http.StatusUnauthorized, err.Error()
+			audit.Record(entry)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "login failed: " + err.Error()})
+			return
+		}
+		entry.User = user
+		role := auth.oidc.cfg.roleFor(user)
+		if !role.IsValid() {
+			entry.Status, entry.Detail = http.StatusForbidden, "no role for user"
+			audit.Record(entry)
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "user " + user + " has no admin role"})
+			return
+		}
+		p := Principal{Name: user, Role: role, Method: "oidc"}
+		auth.SetSession(w, r, p)
+		entry.Role, entry.Status, entry.Detail = role, http.StatusFound, "login"
+		audit.Record(entry)
+		http.Redirect(w, r, safeNext(next), http.StatusFound)
+	}
+}
+
+// safeNext keeps post-login redirects on this host: only absolute
+// paths, never protocol-relative (`//evil`) or backslash tricks.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.Contains(next, `\`) {
+		return "/ui/"
+	}
+	return next
+}
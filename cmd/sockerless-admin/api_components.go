@@ -19,6 +19,7 @@ func registerAPI(mux *http.ServeMux, reg *Registry, procMgr *ProcessManager, pro
 	mux.HandleFunc("GET /api/v1/containers", handleContainers(reg, client))
 	mux.HandleFunc("GET /api/v1/resources", handleResources(reg, client))
 	mux.HandleFunc("POST /api/v1/resources/cleanup", handleResourceCleanup(reg, client))
+	mux.HandleFunc("GET /api/v1/costs", handleCosts(reg, client))
 	mux.HandleFunc("GET /api/v1/contexts", handleContexts())
 
 	// Process management
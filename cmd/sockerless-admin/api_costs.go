@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// costAmount mirrors the backend's per-dimension cost breakdown.
+type costAmount struct {
+	CPU       float64 `json:"cpu"`
+	Memory    float64 `json:"memory"`
+	Requests  float64 `json:"requests"`
+	Ephemeral float64 `json:"ephemeral"`
+	Storage   float64 `json:"storage"`
+	Total     float64 `json:"total"`
+}
+
+func (a *costAmount) add(b costAmount) {
+	a.CPU += b.CPU
+	a.Memory += b.Memory
+	a.Requests += b.Requests
+	a.Ephemeral += b.Ephemeral
+	a.Storage += b.Storage
+	a.Total += b.Total
+}
+
+// costGroup is one row of a backend's `/internal/v1/costs` report.
+type costGroup struct {
+	Key     string     `json:"key"`
+	Cost    costAmount `json:"cost"`
+	Runs    int        `json:"runs"`
+	Running int        `json:"running"`
+	Seconds float64    `json:"seconds"`
+}
+
+// budgetStatus is a backend budget's spending in its current period.
+type budgetStatus struct {
+	Backend     string  `json:"backend"`
+	Label       string  `json:"label"`
+	Value       string  `json:"value"`
+	Limit       float64 `json:"limit"`
+	Spent       float64 `json:"spent"`
+	Remaining   float64 `json:"remaining"`
+	Exhausted   bool    `json:"exhausted"`
+	PeriodStart string  `json:"periodStart,omitempty"`
+}
+
+// costSource reports whether a backend's costs were included and
+// under which price table they were estimated.
+type costSource struct {
+	Backend      string  `json:"backend"`
+	OK           bool    `json:"ok"`
+	Error        string  `json:"error,omitempty"`
+	PriceVersion string  `json:"priceVersion,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+	Total        float64 `json:"total"`
+}
+
+// costsResponse is the merged report of every backend.
+type costsResponse struct {
+	GroupBy string         `json:"groupBy"`
+	Total   costAmount     `json:"total"`
+	Groups  []costGroup    `json:"groups"`
+	Budgets []budgetStatus `json:"budgets"`
+	Sources []costSource   `json:"sources"`
+}
+
+// handleCosts merges `/internal/v1/costs` across every registered
+// backend. Groups with the same key on different backends (a compose
+// project or label value spread over clouds) are summed; budgets stay
+// per backend since each backend enforces its own.
+func handleCosts(reg *Registry, client *http.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := url.Values{}
+		groupBy := r.URL.Query().Get("group_by")
+		if groupBy == "" {
+			groupBy = "container"
+		}
+		q.Set("group_by", groupBy)
+		if since := r.URL.Query().Get("since"); since != "" {
+			q.Set("since", since)
+		}
+		path := "/internal/v1/costs?" + q.Encode()
+
+		var (
+			mu      sync.Mutex
+			wg      sync.WaitGroup
+			sources []costSource
+			budgets []budgetStatus
+			groups  = map[string]*costGroup{}
+			total   costAmount
+		)
+		for _, b := range reg.ListByType("backend") {
+			wg.Add(1)
+			go func(name, addr string) {
+				defer wg.Done()
+				src := costSource{Backend: name}
+				var rep struct {
+					PriceVersion string         `json:"priceVersion"`
+					Currency     string         `json:"currency"`
+					Total        costAmount     `json:"total"`
+					Groups       []costGroup    `json:"groups"`
+					Budgets      []budgetStatus `json:"budgets"`
+				}
+				body, status, err := proxyGET(client, addr, path)
+				switch {
+				case err != nil:
+					src.Error = err.Error()
+				case status != http.StatusOK:
+					src.Error = upstreamError(body, status)
+				default:
+					if err := json.Unmarshal(body, &rep); err != nil {
+						src.Error = "decode: " + err.Error()
+					} else {
+						src.OK = true
+						src.PriceVersion, src.Currency, src.Total = rep.PriceVersion, rep.Currency, rep.Total.Total
+					}
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				sources = append(sources, src)
+				if !src.OK {
+					return
+				}
+				total.add(rep.Total)
+				for _, g := range rep.Groups {
+					m := groups[g.Key]
+					if m == nil {
+						m = &costGroup{Key: g.Key}
+						groups[g.Key] = m
+					}
+					m.Cost.add(g.Cost)
+					m.Runs += g.Runs
+					m.Running += g.Running
+					m.Seconds += g.Seconds
+				}
+				for _, bs := range rep.Budgets {
+					bs.Backend = name
+					budgets = append(budgets, bs)
+				}
+			}(b.Name, b.Addr)
+		}
+		wg.Wait()
+
+		resp := costsResponse{
+			GroupBy: groupBy,
+			Total:   total,
+			Groups:  make([]costGroup, 0, len(groups)),
+			Budgets: budgets,
+			Sources: sources,
+		}
+		for _, g := range groups {
+			resp.Groups = append(resp.Groups, *g)
+		}
+		sort.Slice(resp.Groups, func(i, j int) bool {
+			if resp.Groups[i].Cost.Total != resp.Groups[j].Cost.Total {
+				return resp.Groups[i].Cost.Total > resp.Groups[j].Cost.Total
+			}
+			return resp.Groups[i].Key < resp.Groups[j].Key
+		})
+		sort.Slice(resp.Sources, func(i, j int) bool { return resp.Sources[i].Backend < resp.Sources[j].Backend })
+		sort.SliceStable(resp.Budgets, func(i, j int) bool { return resp.Budgets[i].Backend < resp.Budgets[j].Backend })
+		if resp.Budgets == nil {
+			resp.Budgets = []budgetStatus{}
+		}
+		if resp.Sources == nil {
+			resp.Sources = []costSource{}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// upstreamError extracts the Docker-style error message of a failed
+// backend call.
+func upstreamError(body []byte, status int) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		return fmt.Sprintf("upstream HTTP %d: %s", status, e.Message)
+	}
+	return fmt.Sprintf("upstream HTTP %d", status)
+}
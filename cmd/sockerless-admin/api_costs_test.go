@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeCostBackend serves a fixed /internal/v1/costs report and records
+// the query it was asked.
+func fakeCostBackend(t *testing.T, report string, status int, query *string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/v1/costs" {
+			http.NotFound(w, r)
+			return
+		}
+		if query != nil {
+			*query = r.URL.RawQuery
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(report))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandleCostsMergesBackends(t *testing.T) {
+	var query string
+	ecs := fakeCostBackend(t, `{"priceVersion":"2026-10-01","currency":"USD","total":{"cpu":1,"total":3},
+		"groups":[{"key":"shop","cost":{"cpu":1,"total":2},"runs":2,"running":1,"seconds":60},{"key":"","cost":{"total":1},"runs":1}],
+		"budgets":[{"label":"team","value":"ci","limit":5,"spent":3,"remaining":2}]}`, http.StatusOK, &query)
+	cloudrun := fakeCostBackend(t, `{"priceVersion":"2026-10-01","currency":"USD","total":{"total":4},
+		"groups":[{"key":"shop","cost":{"memory":4,"total":4},"runs":1,"seconds":30}],"budgets":[]}`, http.StatusOK, nil)
+	docker := fakeCostBackend(t, `{"message":"cost accounting is not available on this backend"}`, http.StatusNotImplemented, nil)
+
+	reg := NewRegistry()
+	reg.Add(Component{Name: "ecs", Type: "backend", Addr: ecs.URL})
+	reg.Add(Component{Name: "cloudrun", Type: "backend", Addr: cloudrun.URL})
+	reg.Add(Component{Name: "docker", Type: "backend", Addr: docker.URL})
+	reg.Add(Component{Name: "sim-aws", Type: "simulator", Addr: "http://127.0.0.1:1"})
+
+	rec := httptest.NewRecorder()
+	handleCosts(reg, http.DefaultClient)(rec, httptest.NewRequest(http.MethodGet, "/api/v1/costs?group_by=project&since=24h", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if query != "group_by=project&since=24h" {
+		t.Errorf("upstream query = %q", query)
+	}
+
+	var resp costsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total.Total != 7 || len(resp.Groups) != 2 {
+		t.Fatalf("total = %v, groups = %+v", resp.Total.Total, resp.Groups)
+	}
+	shop := resp.Groups[0]
+	if shop.Key != "shop" || shop.Cost.Total != 6 || shop.Cost.CPU != 1 || shop.Cost.Memory != 4 || shop.Runs != 3 || shop.Running != 1 || shop.Seconds != 90 {
+		t.Errorf("shop = %+v", shop)
+	}
+	if len(resp.Budgets) != 1 || resp.Budgets[0].Backend != "ecs" || resp.Budgets[0].Spent != 3 {
+		t.Errorf("budgets = %+v", resp.Budgets)
+	}
+	if len(resp.Sources) != 3 {
+		t.Fatalf("sources = %+v", resp.Sources)
+	}
+	for _, src := range resp.Sources {
+		switch src.Backend {
+		case "docker":
+			if src.OK || src.Error != "upstream HTTP 501: cost accounting is not available on this backend" {
+				t.Errorf("docker source = %+v", src)
+			}
+		default:
+			if !src.OK || src.PriceVersion != "2026-10-01" {
+				t.Errorf("%s source = %+v", src.Backend, src)
+			}
+		}
+	}
+}
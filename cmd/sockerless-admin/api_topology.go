@@ -26,11 +26,15 @@ import (
 //	POST   /api/v1/topology/projects/{project}/instances/{instance}/stop
 //	POST   /api/v1/topology/projects/{project}/instances/{instance}/rebuild
 //	POST   /api/v1/topology/allocate-port?kind=<sim|backend|bleephub>
+//	POST   /api/v1/topology/plan
+//	POST   /api/v1/topology/apply
 //
 // Lifecycle endpoints shell `make {start|stop|rebuild}-component` (see
 // make/components.mk). Components stay decoupled — the make targets
 // invoke the binaries with their normal env vars; admin doesn't talk
 // to running components beyond the public surface (/v1/health etc).
+// Plan / apply converge to a posted sockerless.yaml document; see
+// topology_plan.go.
 //
 // `lifecycle` may be nil in tests that only exercise the read +
 // replace + allocate-port surface; lifecycle handlers fail with 503
@@ -59,6 +63,9 @@ func registerTopologyAPI(mux *http.ServeMux, mgr *TopologyManager, lifecycle *In
 	mux.HandleFunc("GET /api/v1/topology/config-metadata", handleConfigMetadata())
 	mux.HandleFunc("PUT /api/v1/topology/projects/{project}/instances/{instance}/config", handleInstanceConfigUpdate(mgr))
 	mux.HandleFunc("POST /api/v1/topology/projects/{project}/instances/{instance}/reload", handleInstanceReload(mgr, lifecycle))
+	applier := NewTopologyApplier(mgr, lifecycle)
+	mux.HandleFunc("POST /api/v1/topology/plan", handleTopologyPlan(applier))
+	mux.HandleFunc("POST /api/v1/topology/apply", handleTopologyApply(applier))
 }
 
 func handleTopologyGet(mgr *TopologyManager) http.HandlerFunc {
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// topologyDocMaxBody caps a plan/apply request body. A sockerless.yaml
+// with hundreds of instances is well under this.
+const topologyDocMaxBody = 1 << 20
+
+// planResponse is the plan endpoint's body: the structured plan plus
+// its rendered text, so the CLI prints the plan as admin renders it.
+type planResponse struct {
+	TopologyPlan
+	Text string `json:"text"`
+}
+
+// applyResponse adds the rendered plan text to ApplyResult.
+type applyResponse struct {
+	ApplyResult
+	Text string `json:"text"`
+}
+
+// readTopologyDoc decodes a plan/apply body. The body is a
+// sockerless.yaml document; JSON is accepted too, being valid YAML.
+func readTopologyDoc(w http.ResponseWriter, r *http.Request) (Topology, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, topologyDocMaxBody))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "read body: " + err.Error()})
+		return Topology{}, false
+	}
+	desired, err := ParseTopologyYAML(data)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return Topology{}, false
+	}
+	return desired, true
+}
+
+// handleTopologyPlan diffs the posted document against the running
+// topology without changing anything.
+func handleTopologyPlan(applier *TopologyApplier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		desired, ok := readTopologyDoc(w, r)
+		if !ok {
+			return
+		}
+		plan, err := applier.Plan(desired)
+		if err != nil {
+			writeJSON(w, topologyReplaceStatus(err), map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, planResponse{TopologyPlan: plan, Text: plan.String()})
+	}
+}
+
+// handleTopologyApply converges to the posted document. A failed
+// apply answers 500 with the partial result, including whether the
+// rollback restored the previous state.
+func handleTopologyApply(applier *TopologyApplier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if applier.ctl == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "lifecycle not configured"})
+			return
+		}
+		desired, ok := readTopologyDoc(w, r)
+		if !ok {
+			return
+		}
+		// A client hanging up mid-apply mustn't cancel the rollback.
+		res, err := applier.Apply(context.WithoutCancel(r.Context()), desired)
+		if err != nil && res.Error == "" {
+			// Failed before any step ran: validation or planning.
+			writeJSON(w, topologyReplaceStatus(err), map[string]string{"error": err.Error()})
+			return
+		}
+		status := http.StatusOK
+		if err != nil {
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, applyResponse{ApplyResult: res, Text: res.Plan.String()})
+	}
+}
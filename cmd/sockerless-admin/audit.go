@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one audited call: who did what, and how it ended.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	Role Role      `json:"role,omitempty"`
+	// Auth is how the caller authenticated (password, token, oidc,
+	// none); empty when authentication failed.
+	Auth string `json:"auth,omitempty"`
+	// Action is the matched route, e.g. "POST /api/v1/processes/{name}/start";
+	// Path is the concrete URL path.
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	Remote string `json:"remote,omitempty"`
+	// Detail carries a denial reason or the login outcome.
+	Detail string `json:"detail,omitempty"`
+}
+
+const auditRecent = 500
+
+// AuditLog appends entries as JSON lines to a file and keeps the most
+// recent ones in memory for GET /api/v1/audit.
+type AuditLog struct {
+	mu     sync.Mutex
+	f      *os.File
+	recent []AuditEntry
+}
+
+// DefaultAuditLogPath is where audit entries go when the auth config
+// doesn't name a file.
+func DefaultAuditLogPath() string {
+	return filepath.Join(sockerlessDir(), "admin-audit.log")
+}
+
+// OpenAuditLog opens (appending) or creates path. An empty path keeps
+// entries in memory only.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	a := &AuditLog{}
+	if path == "" {
+		return a, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	a.f = f
+	return a, nil
+}
+
+// Record appends e. A failed write is logged, not returned: the call
+// being audited has already happened.
+func (a *AuditLog) Record(e AuditEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.recent = append(a.recent, e)
+	if len(a.recent) > auditRecent {
+		a.recent = a.recent[len(a.recent)-auditRecent:]
+	}
+	if a.f == nil {
+		return
+	}
+	line, _ := json.Marshal(e)
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		log.Printf("audit: write failed: %v", err)
+	}
+}
+
+// Recent returns up to n of the latest entries, oldest first.
+func (a *AuditLog) Recent(n int) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n <= 0 || n > len(a.recent) {
+		n = len(a.recent)
+	}
+	return append([]AuditEntry(nil), a.recent[len(a.recent)-n:]...)
+}
+
+// Close flushes and closes the file.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return nil
+	}
+	return a.f.Close()
+}
+
+func handleAuditList(audit *AuditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		writeJSON(w, http.StatusOK, audit.Recent(n))
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Role is an admin access level. Each role can do everything the
+// roles below it can.
+type Role string
+
+const (
+	// RoleViewer reads: topology, status, logs, metrics.
+	RoleViewer Role = "viewer"
+	// RoleOperator also drives lifecycle: start / stop / reload /
+	// rebuild of processes, projects and instances.
+	RoleOperator Role = "operator"
+	// RoleAdmin also changes configuration, deletes resources and
+	// proxies raw HTTP into instances.
+	RoleAdmin Role = "admin"
+)
+
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// Allows reports whether r is at least need.
+func (r Role) Allows(need Role) bool { return r.rank() > 0 && r.rank() >= need.rank() }
+
+// IsValid reports whether r is one of the three roles.
+func (r Role) IsValid() bool { return r.rank() > 0 }
+
+// Principal is the caller a request was authenticated as.
+type Principal struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+	// Method is how the caller authenticated: password, token, oidc,
+	// or none when auth is disabled.
+	Method string `json:"method"`
+}
+
+type principalKey struct{}
+
+// principalFrom returns the caller the auth middleware attached to ctx.
+func principalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// AuthConfig is admin-auth.yaml. Absent file = auth disabled.
+type AuthConfig struct {
+	// SessionKey signs session cookies (hex, ≥32 bytes). Empty = a
+	// random key per start, which logs everyone out on restart.
+	// Rotating it revokes every session.
+	SessionKey string `yaml:"session_key,omitempty"`
+	// SessionTTL bounds a login; default 12h.
+	SessionTTL string      `yaml:"session_ttl,omitempty"`
+	Users      []AuthUser  `yaml:"users,omitempty"`
+	Tokens     []AuthToken `yaml:"tokens,omitempty"`
+	OIDC       *OIDCConfig `yaml:"oidc,omitempty"`
+	AuditLog   string      `yaml:"audit_log,omitempty"`
+	sessionTTL time.Duration
+}
+
+// AuthUser is a local user. PasswordHash comes from
+// `sockerless-admin --hash-password`.
+type AuthUser struct {
+	Name         string `yaml:"name"`
+	PasswordHash string `yaml:"password_hash"`
+	Role         Role   `yaml:"role"`
+}
+
+// AuthToken is a static API token, sent as `Authorization: Bearer`.
+// Only its SHA-256 is stored.
+type AuthToken struct {
+	Name        string `yaml:"name"`
+	TokenSHA256 string `yaml:"token_sha256"`
+	Role        Role   `yaml:"role"`
+}
+
+// DefaultAuthConfigPath is where admin looks when --auth-config isn't
+// given.
+func DefaultAuthConfigPath() string {
+	return filepath.Join(sockerlessDir(), "admin-auth.yaml")
+}
+
+// LoadAuthConfig reads and validates an auth config. Returns
+// (nil, nil) when the file doesn't exist.
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg AuthConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks roles, hashes and names, and fills defaults.
+func (c *AuthConfig) Validate() error {
+	c.sessionTTL = 12 * time.Hour
+	if c.SessionTTL != "" {
+		d, err := time.ParseDuration(c.SessionTTL)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("session_ttl: invalid duration %q", c.SessionTTL)
+		}
+		c.sessionTTL = d
+	}
+	if c.SessionKey != "" {
+		key, err := hex.DecodeString(c.SessionKey)
+		if err != nil || len(key) < 32 {
+			return errors.New("session_key: want at least 32 hex-encoded bytes")
+		}
+	}
+	seen := map[string]bool{}
+	for i, u := range c.Users {
+		if u.Name == "" || seen[u.Name] {
+			return fmt.Errorf("users[%d]: missing or duplicate name %q", i, u.Name)
+		}
+		seen[u.Name] = true
+		if !u.Role.IsValid() {
+			return fmt.Errorf("user %q: unknown role %q (viewer, operator or admin)", u.Name, u.Role)
+		}
+		if _, _, _, err := parsePasswordHash(u.PasswordHash); err != nil {
+			return fmt.Errorf("user %q: %w", u.Name, err)
+		}
+	}
+	for i, t := range c.Tokens {
+		if t.Name == "" {
+			return fmt.Errorf("tokens[%d]: missing name", i)
+		}
+		if !t.Role.IsValid() {
+			return fmt.Errorf("token %q: unknown role %q (viewer, operator or admin)", t.Name, t.Role)
+		}
+		if sum, err := hex.DecodeString(t.TokenSHA256); err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("token %q: token_sha256 must be 64 hex characters", t.Name)
+		}
+	}
+	if c.OIDC != nil {
+		if err := c.OIDC.validate(); err != nil {
+			return fmt.Errorf("oidc: %w", err)
+		}
+	}
+	return nil
+}
+
+// Password hashes are `pbkdf2-sha256$<iterations>$<salt>$<key>`, with
+// salt and key in unpadded base64.
+const (
+	passwordHashScheme = "pbkdf2-sha256"
+	passwordIterations = 600_000
+	passwordKeyLen     = 32
+)
+
+// HashPassword derives a storable hash for password with a fresh salt.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyLen)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$%d$%s$%s", passwordHashScheme, passwordIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func parsePasswordHash(h string) (iter int, salt, key []byte, err error) {
+	parts := strings.Split(h, "$")
+	if len(parts) != 4 || parts[0] != passwordHashScheme {
+		return 0, nil, nil, errors.New("password_hash: want pbkdf2-sha256$<iterations>$<salt>$<key> (see --hash-password)")
+	}
+	iter, err = strconv.Atoi(parts[1])
+	if err != nil || iter < 1 {
+		return 0, nil, nil, errors.New("password_hash: bad iteration count")
+	}
+	enc := base64.RawStdEncoding
+	if salt, err = enc.DecodeString(parts[2]); err != nil {
+		return 0, nil, nil, errors.New("password_hash: bad salt")
+	}
+	if key, err = enc.DecodeString(parts[3]); err != nil || len(key) == 0 {
+		return 0, nil, nil, errors.New("password_hash: bad key")
+	}
+	return iter, salt, key, nil
+}
+
+// dummyPasswordHash is checked against for unknown usernames.
+var dummyPasswordHash = fmt.Sprintf("%s$%d$%s$%s", passwordHashScheme, passwordIterations,
+	"AAAAAAAAAAAAAAAAAAAAAA", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+
+// checkPassword reports whether password matches hash.
+func checkPassword(hash, password string) bool {
+	iter, salt, want, err := parsePasswordHash(hash)
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iter, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// Authenticator resolves the caller of a request. A nil config means
+// auth is disabled and every caller is an anonymous admin.
+type Authenticator struct {
+	cfg        *AuthConfig
+	sessionKey []byte
+	oidc       *oidcProvider
+	now        func() time.Time
+}
+
+const sessionCookie = "sockerless_admin_session"
+
+// NewAuthenticator builds an Authenticator from cfg (nil = disabled).
+func NewAuthenticator(cfg *AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{cfg: cfg, now: time.Now}
+	if cfg == nil {
+		return a, nil
+	}
+	if cfg.SessionKey != "" {
+		a.sessionKey, _ = hex.DecodeString(cfg.SessionKey)
+	} else {
+		a.sessionKey = make([]byte, 32)
+		if _, err := rand.Read(a.sessionKey); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.OIDC != nil {
+		a.oidc = newOIDCProvider(cfg.OIDC)
+	}
+	return a, nil
+}
+
+// Enabled reports whether requests need credentials.
+func (a *Authenticator) Enabled() bool { return a.cfg != nil }
+
+// Authenticate resolves the caller from a bearer token or a session
+// cookie. ok is false when neither is present or valid.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, bool) {
+	if a.cfg == nil {
+		return Principal{Name: "anonymous", Role: RoleAdmin, Method: "none"}, true
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		tok, found := strings.CutPrefix(auth, "Bearer ")
+		if !found {
+			return Principal{}, false
+		}
+		return a.tokenPrincipal(tok)
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		return a.sessionPrincipal(c.Value)
+	}
+	return Principal{}, false
+}
+
+func (a *Authenticator) tokenPrincipal(tok string) (Principal, bool) {
+	sum := sha256.Sum256([]byte(tok))
+	for _, t := range a.cfg.Tokens {
+		want, _ := hex.DecodeString(t.TokenSHA256)
+		if subtle.ConstantTimeCompare(sum[:], want) == 1 {
+			return Principal{Name: t.Name, Role: t.Role, Method: "token"}, true
+		}
+	}
+	return Principal{}, false
+}
+
+// Login checks a local user's password.
+func (a *Authenticator) Login(name, password string) (Principal, bool) {
+	if a.cfg == nil {
+		return Principal{}, false
+	}
+	for _, u := range a.cfg.Users {
+		if u.Name == name {
+			if checkPassword(u.PasswordHash, password) {
+				return Principal{Name: u.Name, Role: u.Role, Method: "password"}, true
+			}
+			return Principal{}, false
+		}
+	}
+	// Unknown user: spend the same hashing time so response latency
+	// doesn't reveal which usernames exist.
+	checkPassword(dummyPasswordHash, password)
+	return Principal{}, false
+}
+
+// session is the signed cookie payload. The role isn't stored: it's
+// resolved from the config on every request, so removing a user or
+// changing their role takes effect on restart without waiting for
+// cookies to expire.
+type session struct {
+	Name    string `json:"n"`
+	Method  string `json:"m"`
+	Expires int64  `json:"e"`
+}
+
+// SetSession issues a session cookie for p.
+func (a *Authenticator) SetSession(w http.ResponseWriter, r *http.Request, p Principal) {
+	exp := a.now().Add(a.cfg.sessionTTL)
+	payload, _ := json.Marshal(session{Name: p.Name, Method: p.Method, Expires: exp.Unix()})
+	value := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(a.sign(payload))
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  exp,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSession expires the session cookie.
+func (a *Authenticator) ClearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
+
+func (a *Authenticator) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, a.sessionKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (a *Authenticator) sessionPrincipal(value string) (Principal, bool) {
+	enc, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return Principal{}, false
+	}
+	payload, err1 := base64.RawURLEncoding.DecodeString(enc)
+	mac, err2 := base64.RawURLEncoding.DecodeString(sig)
+	if err1 != nil || err2 != nil || !hmac.Equal(mac, a.sign(payload)) {
+		return Principal{}, false
+	}
+	var s session
+	if json.Unmarshal(payload, &s) != nil || a.now().Unix() >= s.Expires {
+		return Principal{}, false
+	}
+	switch s.Method {
+	case "password":
+		for _, u := range a.cfg.Users {
+			if u.Name == s.Name {
+				return Principal{Name: u.Name, Role: u.Role, Method: s.Method}, true
+			}
+		}
+	case "oidc":
+		if a.oidc != nil {
+			if role := a.oidc.cfg.roleFor(s.Name); role.IsValid() {
+				return Principal{Name: s.Name, Role: role, Method: s.Method}, true
+			}
+		}
+	}
+	return Principal{}, false
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig configures browser login through an OpenID Connect
+// provider, or any OAuth 2.0 authorization-code provider with a
+// userinfo-style endpoint — GitHub and bleephub's OAuth apps included.
+// Set Issuer to discover the endpoints, or set the three URLs.
+type OIDCConfig struct {
+	// Name labels the login button ("Sign in with <name>").
+	Name         string   `yaml:"name,omitempty"`
+	Issuer       string   `yaml:"issuer,omitempty"`
+	AuthorizeURL string   `yaml:"authorize_url,omitempty"`
+	TokenURL     string   `yaml:"token_url,omitempty"`
+	UserinfoURL  string   `yaml:"userinfo_url,omitempty"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret,omitempty"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes,omitempty"`
+	// UsernameClaim is the userinfo field that names the user;
+	// default preferred_username (`login` for GitHub / bleephub).
+	UsernameClaim string `yaml:"username_claim,omitempty"`
+	// Roles maps usernames to roles. Users not listed get
+	// DefaultRole; empty DefaultRole denies them.
+	Roles       map[string]Role `yaml:"roles,omitempty"`
+	DefaultRole Role            `yaml:"default_role,omitempty"`
+}
+
+func (c *OIDCConfig) validate() error {
+	if c.ClientID == "" || c.RedirectURL == "" {
+		return errors.New("client_id and redirect_url are required")
+	}
+	explicit := c.AuthorizeURL != "" && c.TokenURL != "" && c.UserinfoURL != ""
+	if c.Issuer == "" && !explicit {
+		return errors.New("set issuer, or all of authorize_url, token_url and userinfo_url")
+	}
+	for user, role := range c.Roles {
+		if !role.IsValid() {
+			return fmt.Errorf("roles[%q]: unknown role %q", user, role)
+		}
+	}
+	if c.DefaultRole != "" && !c.DefaultRole.IsValid() {
+		return fmt.Errorf("default_role: unknown role %q", c.DefaultRole)
+	}
+	if c.Name == "" {
+		c.Name = "SSO"
+	}
+	if c.UsernameClaim == "" {
+		c.UsernameClaim = "preferred_username"
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "profile"}
+	}
+	return nil
+}
+
+// roleFor maps a provider username to a role; "" = no access.
+func (c *OIDCConfig) roleFor(user string) Role {
+	if r, ok := c.Roles[user]; ok {
+		return r
+	}
+	return c.DefaultRole
+}
+
+// oidcProvider runs the authorization-code flow. Endpoints found via
+// discovery are cached after the first successful fetch, so admin
+// starts even while the provider is down.
+type oidcProvider struct {
+	cfg    *OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	endpoints *oidcEndpoints
+}
+
+type oidcEndpoints struct {
+	Authorize string `json:"authorization_endpoint"`
+	Token     string `json:"token_endpoint"`
+	Userinfo  string `json:"userinfo_endpoint"`
+}
+
+const oidcStateCookie = "sockerless_admin_oidc_state"
+
+func newOIDCProvider(cfg *OIDCConfig) *oidcProvider {
+	p := &oidcProvider{cfg: cfg, client: tracedHTTPClient(10 * time.Second)}
+	if cfg.AuthorizeURL != "" && cfg.TokenURL != "" && cfg.UserinfoURL != "" {
+		p.endpoints = &oidcEndpoints{Authorize: cfg.AuthorizeURL, Token: cfg.TokenURL, Userinfo: cfg.UserinfoURL}
+	}
+	return p
+}
+
+func (p *oidcProvider) resolve(ctx context.Context) (oidcEndpoints, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.endpoints != nil {
+		return *p.endpoints, nil
+	}
+	var doc oidcEndpoints
+	if err := p.getJSON(ctx, strings.TrimRight(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return oidcEndpoints{}, fmt.Errorf("discovery: %w", err)
+	}
+	if doc.Authorize == "" || doc.Token == "" || doc.Userinfo == "" {
+		return oidcEndpoints{}, errors.New("discovery: provider lacks authorization, token or userinfo endpoint")
+	}
+	p.endpoints = &doc
+	return doc, nil
+}
+
+// Begin redirects the browser to the provider. next is where the
+// callback sends the browser after login.
+func (p *oidcProvider) Begin(w http.ResponseWriter, r *http.Request, next string) error {
+	ep, err := p.resolve(r.Context())
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	state := base64.RawURLEncoding.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state + "|" + next,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.cfg.ClientID},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"scope":         {strings.Join(p.cfg.Scopes, " ")},
+		"state":         {state},
+	}
+	sep := "?"
+	if strings.Contains(ep.Authorize, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, ep.Authorize+sep+q.Encode(), http.StatusFound)
+	return nil
+}
+
+// Finish handles the provider's redirect back: checks state, trades
+// the code for an access token and reads the username from userinfo.
+// Returns the username and the post-login destination.
+func (p *oidcProvider) Finish(r *http.Request) (user, next string, err error) {
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		return "", "", errors.New("login state missing or expired; start again")
+	}
+	state, next, _ := strings.Cut(c.Value, "|")
+	if got := r.URL.Query().Get("state"); subtle.ConstantTimeCompare([]byte(got), []byte(state)) != 1 {
+		return "", "", errors.New("login state mismatch")
+	}
+	if e := r.URL.Query().Get("error"); e != "" {
+		return "", "", fmt.Errorf("provider: %s", e)
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		return "", "", errors.New("provider returned no code")
+	}
+	ep, err := p.resolve(r.Context())
+	if err != nil {
+		return "", "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+	}
+	req, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, ep.Token, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub-style endpoints answer form-encoded without this.
+	req.Header.Set("Accept", "application/json")
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.doJSON(req, &tok); err != nil {
+		return "", "", fmt.Errorf("token exchange: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", "", fmt.Errorf("token exchange: %s", orDefault(tok.Error, "no access_token"))
+	}
+
+	var info map[string]any
+	if err := p.getJSON(r.Context(), ep.Userinfo, tok.AccessToken, &info); err != nil {
+		return "", "", fmt.Errorf("userinfo: %w", err)
+	}
+	user, _ = info[p.cfg.UsernameClaim].(string)
+	if user == "" {
+		return "", "", fmt.Errorf("userinfo: no %q claim", p.cfg.UsernameClaim)
+	}
+	return user, next, nil
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, u, bearer string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	return p.doJSON(req, v)
+}
+
+func (p *oidcProvider) doJSON(req *http.Request, v any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
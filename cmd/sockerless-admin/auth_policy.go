@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// routeRoles is the per-route role policy, keyed by the mux pattern a
+// request matched. Routes not listed fall back to defaultRouteRole:
+// reads need viewer, anything else needs admin. A new mutating route
+// is therefore admin-only until it's deliberately listed here.
+var routeRoles = map[string]Role{
+	// Lifecycle: operator.
+	"POST /api/v1/processes/{name}/start":                                   RoleOperator,
+	"POST /api/v1/processes/{name}/stop":                                    RoleOperator,
+	"POST /api/v1/projects/{name}/start":                                    RoleOperator,
+	"POST /api/v1/projects/{name}/stop":                                     RoleOperator,
+	"POST /api/v1/components/{name}/reload":                                 RoleOperator,
+	"POST /api/v1/topology/projects/{project}/instances/{instance}/start":   RoleOperator,
+	"POST /api/v1/topology/projects/{project}/instances/{instance}/stop":    RoleOperator,
+	"POST /api/v1/topology/projects/{project}/instances/{instance}/reload":  RoleOperator,
+	"POST /api/v1/topology/projects/{project}/instances/{instance}/rebuild": RoleOperator,
+	"POST /api/v1/topology/allocate-port":                                   RoleOperator,
+	// Plan only reads, though it's a POST.
+	"POST /api/v1/topology/plan": RoleViewer,
+	// The audit trail names users and what they did.
+	"GET /api/v1/audit": RoleAdmin,
+
+	// Everything else that isn't a GET stays admin via the default,
+	// notably: PUT /api/v1/topology, topology CRUD + config, apply,
+	// POST /api/v1/topology/.../proxy, /api/v1/cleanup/*,
+	// /api/v1/resources/cleanup, project create / delete.
+}
+
+// publicRoutes answer without credentials: the login surface, and
+// logout (clearing a cookie needs no identity).
+var publicRoutes = map[string]bool{
+	"GET /api/v1/auth/providers":     true,
+	"POST /api/v1/auth/login":        true,
+	"POST /api/v1/auth/logout":       true,
+	"GET /api/v1/auth/oidc/login":    true,
+	"GET /api/v1/auth/oidc/callback": true,
+}
+
+func defaultRouteRole(method string) Role {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RoleViewer
+	}
+	return RoleAdmin
+}
+
+// requiredRole returns the role a matched pattern needs.
+func requiredRole(pattern, method string) Role {
+	if r, ok := routeRoles[pattern]; ok {
+		return r
+	}
+	return defaultRouteRole(method)
+}
+
+func isMutating(method string) bool {
+	return defaultRouteRole(method) == RoleAdmin
+}
+
+// withAuth enforces authentication and the route policy on /api/
+// requests, and audits every mutating call and every denial. Non-API
+// paths (the SPA bundle, the / redirect) pass through: the SPA itself
+// holds nothing, and its API calls are checked here.
+func withAuth(mux *http.ServeMux, auth *Authenticator, audit *AuditLog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		_, pattern := mux.Handler(r)
+		if pattern == "" || publicRoutes[pattern] {
+			// Unknown paths 404 / 405 without revealing anything.
+			mux.ServeHTTP(w, r)
+			return
+		}
+		entry := AuditEntry{Action: pattern, Path: r.URL.Path, Remote: remoteHost(r)}
+
+		p, ok := auth.Authenticate(r)
+		if !ok {
+			if isMutating(r.Method) {
+				entry.User, entry.Status, entry.Detail = "-", http.StatusUnauthorized, "unauthenticated"
+				audit.Record(entry)
+			}
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+			return
+		}
+		entry.User, entry.Role, entry.Auth = p.Name, p.Role, p.Method
+
+		need := requiredRole(pattern, r.Method)
+		if !p.Role.Allows(need) {
+			entry.Status, entry.Detail = http.StatusForbidden, "needs role "+string(need)
+			audit.Record(entry)
+			writeJSON(w, http.StatusForbidden, map[string]string{
+				"error": "role " + string(p.Role) + " cannot " + pattern + " (needs " + string(need) + ")",
+			})
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+		if !isMutating(r.Method) {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+		entry.Status = rec.status
+		audit.Record(entry)
+	})
+}
+
+// statusRecorder captures the status a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach Flush etc.
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cheapHash builds a password_hash with few iterations so tests
+// don't spend seconds in PBKDF2.
+func cheapHash(t *testing.T, password string) string {
+	t.Helper()
+	salt := []byte("0123456789abcdef")
+	key, err := pbkdf2.Key(sha256.New, password, salt, 1000, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$1000$%s$%s", enc.EncodeToString(salt), enc.EncodeToString(key))
+}
+
+func tokenHash(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:])
+}
+
+// setupAuthServer wires the topology surface behind withAuth with a
+// viewer token, an operator token and an admin user.
+func setupAuthServer(t *testing.T, cfg *AuthConfig) (http.Handler, *AuditLog) {
+	t.Helper()
+	if cfg == nil {
+		cfg = &AuthConfig{
+			Users: []AuthUser{{Name: "alice", PasswordHash: cheapHash(t, "s3cret"), Role: RoleAdmin}},
+			Tokens: []AuthToken{
+				{Name: "dash", TokenSHA256: tokenHash("viewer-token"), Role: RoleViewer},
+				{Name: "ci", TokenSHA256: tokenHash("operator-token"), Role: RoleOperator},
+			},
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	auth, err := NewAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err := OpenAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = audit.Close() })
+	_, mux := setupTopologyServer(t)
+	registerAuthAPI(mux, auth, audit)
+	return withAuth(mux, auth, audit), audit
+}
+
+func do(h http.Handler, method, path, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestPasswordHashRoundTrip(t *testing.T) {
+	h := cheapHash(t, "hunter2")
+	if !checkPassword(h, "hunter2") {
+		t.Error("correct password rejected")
+	}
+	if checkPassword(h, "hunter3") {
+		t.Error("wrong password accepted")
+	}
+	if _, _, _, err := parsePasswordHash("plaintext"); err == nil {
+		t.Error("non-pbkdf2 hash should fail to parse")
+	}
+}
+
+func TestAuthConfigValidate(t *testing.T) {
+	cases := map[string]AuthConfig{
+		"bad role":     {Tokens: []AuthToken{{Name: "x", TokenSHA256: tokenHash("x"), Role: "root"}}},
+		"bad token":    {Tokens: []AuthToken{{Name: "x", TokenSHA256: "abc", Role: RoleViewer}}},
+		"bad hash":     {Users: []AuthUser{{Name: "u", PasswordHash: "nope", Role: RoleViewer}}},
+		"short key":    {SessionKey: "abcd"},
+		"oidc no urls": {OIDC: &OIDCConfig{ClientID: "c", RedirectURL: "http://x/cb"}},
+	}
+	for name, cfg := range cases {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: want validation error", name)
+		}
+	}
+}
+
+func TestAuthRoutePolicy(t *testing.T) {
+	h, audit := setupAuthServer(t, nil)
+
+	cases := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/api/v1/topology", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/topology", "wrong-token", http.StatusUnauthorized},
+		{"GET", "/api/v1/topology", "viewer-token", http.StatusOK},
+		// Plan is a read despite being a POST.
+		{"POST", "/api/v1/topology/plan", "viewer-token", http.StatusOK},
+		{"POST", "/api/v1/topology/projects/p/instances/i/start", "viewer-token", http.StatusForbidden},
+		// Operator passes the policy; the test server has no lifecycle.
+		{"POST", "/api/v1/topology/projects/p/instances/i/start", "operator-token", http.StatusServiceUnavailable},
+		{"PUT", "/api/v1/topology", "operator-token", http.StatusForbidden},
+		{"POST", "/api/v1/topology/projects/p/instances/i/proxy", "operator-token", http.StatusForbidden},
+		{"GET", "/api/v1/audit", "operator-token", http.StatusForbidden},
+		{"GET", "/api/v1/auth/providers", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		body := ""
+		if strings.HasSuffix(c.path, "/plan") {
+			body = "projects: []\n"
+		}
+		if w := do(h, c.method, c.path, c.token, body); w.Code != c.want {
+			t.Errorf("%s %s (%s) = %d, want %d; body=%s", c.method, c.path, c.token, w.Code, c.want, w.Body.String())
+		}
+	}
+
+	// Mutating calls and denials are audited; plain reads aren't.
+	var actions []string
+	for _, e := range audit.Recent(0) {
+		actions = append(actions, fmt.Sprintf("%s %s %d", e.User, e.Action, e.Status))
+	}
+	want := []string{
+		"dash POST /api/v1/topology/plan 200",
+		"dash POST /api/v1/topology/projects/{project}/instances/{instance}/start 403",
+		"ci POST /api/v1/topology/projects/{project}/instances/{instance}/start 503",
+		"ci PUT /api/v1/topology 403",
+		"ci POST /api/v1/topology/projects/{project}/instances/{instance}/proxy 403",
+		"ci GET /api/v1/audit 403",
+	}
+	if strings.Join(actions, "\n") != strings.Join(want, "\n") {
+		t.Errorf("audit =\n%s\nwant\n%s", strings.Join(actions, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestAuthPasswordLoginSession(t *testing.T) {
+	h, audit := setupAuthServer(t, nil)
+
+	if w := do(h, "POST", "/api/v1/auth/login", "", `{"username":"alice","password":"nope"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bad password: status %d", w.Code)
+	}
+	w := do(h, "POST", "/api/v1/auth/login", "", `{"username":"alice","password":"s3cret"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d body=%s", w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("want one HttpOnly session cookie, got %+v", cookies)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/auth/me", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var me Principal
+	_ = json.Unmarshal(w.Body.Bytes(), &me)
+	if w.Code != http.StatusOK || me.Name != "alice" || me.Role != RoleAdmin || me.Method != "password" {
+		t.Errorf("me = %d %+v", w.Code, me)
+	}
+
+	// A tampered cookie is rejected.
+	bad := *cookies[0]
+	bad.Value = strings.Replace(bad.Value, ".", "x.", 1)
+	req = httptest.NewRequest("GET", "/api/v1/auth/me", nil)
+	req.AddCookie(&bad)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("tampered cookie: status %d, want 401", w.Code)
+	}
+
+	entries := audit.Recent(0)
+	if len(entries) != 2 || entries[0].Detail != "bad credentials" || entries[1].Detail != "login" {
+		t.Errorf("login attempts not audited: %+v", entries)
+	}
+}
+
+func TestAuthDisabledIsAnonymousAdmin(t *testing.T) {
+	auth, _ := NewAuthenticator(nil)
+	audit, _ := OpenAuditLog("")
+	_, mux := setupTopologyServer(t)
+	registerAuthAPI(mux, auth, audit)
+	h := withAuth(mux, auth, audit)
+
+	if w := do(h, "PUT", "/api/v1/topology", "", `{"projects":[]}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT without auth config: status %d", w.Code)
+	}
+	if e := audit.Recent(0); len(e) != 1 || e[0].User != "anonymous" || e[0].Auth != "none" {
+		t.Errorf("mutating call should still be audited: %+v", e)
+	}
+}
+
+// TestAuthOIDCLogin runs the authorization-code flow against a fake
+// provider shaped like bleephub's OAuth app endpoints.
+func TestAuthOIDCLogin(t *testing.T) {
+	idp := http.NewServeMux()
+	idp.HandleFunc("GET /login/oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=the-code&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	idp.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.FormValue("code") != "the-code" || r.FormValue("client_id") != "client" {
+			writeJSON(w, http.StatusOK, map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"access_token": "gho_abc", "token_type": "bearer"})
+	})
+	idp.HandleFunc("GET /api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"login": "admin", "id": 1})
+	})
+	srv := httptest.NewServer(idp)
+	defer srv.Close()
+
+	h, _ := setupAuthServer(t, &AuthConfig{OIDC: &OIDCConfig{
+		Name:          "bleephub",
+		AuthorizeURL:  srv.URL + "/login/oauth/authorize",
+		TokenURL:      srv.URL + "/login/oauth/access_token",
+		UserinfoURL:   srv.URL + "/api/v3/user",
+		ClientID:      "client",
+		RedirectURL:   "http://admin.test/api/v1/auth/oidc/callback",
+		UsernameClaim: "login",
+		Roles:         map[string]Role{"admin": RoleOperator},
+	}})
+
+	w := do(h, "GET", "/api/v1/auth/oidc/login?next=/ui/topology", "", "")
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: status %d", w.Code)
+	}
+	stateCookie := w.Result().Cookies()[0]
+
+	// Follow the provider's redirect back to admin.
+	resp, err := (&http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}).Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	cb, _ := url.Parse(resp.Header.Get("Location"))
+
+	req := httptest.NewRequest("GET", "/api/v1/auth/oidc/callback?"+cb.RawQuery, nil)
+	req.AddCookie(stateCookie)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/ui/topology" {
+		t.Fatalf("callback: status %d location %q body=%s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("callback set no session cookie")
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/auth/me", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var me Principal
+	_ = json.Unmarshal(w.Body.Bytes(), &me)
+	if me.Name != "admin" || me.Role != RoleOperator || me.Method != "oidc" {
+		t.Errorf("me = %+v", me)
+	}
+
+	// A callback whose state doesn't match the cookie is refused.
+	req = httptest.NewRequest("GET", "/api/v1/auth/oidc/callback?code=the-code&state=forged", nil)
+	req.AddCookie(stateCookie)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("forged state: status %d, want 401", w.Code)
+	}
+}
+
+func TestSafeNext(t *testing.T) {
+	for in, want := range map[string]string{
+		"/ui/topology":      "/ui/topology",
+		"":                  "/ui/",
+		"//evil.example":    "/ui/",
+		"https://evil.test": "/ui/",
+		`/\evil.example`:    "/ui/",
+	} {
+		if got := safeNext(in); got != want {
+			t.Errorf("safeNext(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
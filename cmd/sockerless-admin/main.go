@@ -42,6 +42,8 @@ func main() {
 	flag.Var(&backends, "backend", "backend component as name=addr (repeatable)")
 	flag.Var(&simulators, "simulator", "simulator component as name=addr (repeatable)")
 	bleephubAddr := flag.String("bleephub", "", "bleephub coordinator address")
+	authConfigPath := flag.String("auth-config", DefaultAuthConfigPath(), "path to admin-auth.yaml (missing file = auth disabled)")
+	hashPassword := flag.Bool("hash-password", false, "read a password from stdin, print its password_hash for admin-auth.yaml, and exit")
 	showVersion := flag.Bool("version", false, "print version and exit")
 
 	flag.Parse()
@@ -50,6 +52,39 @@ func main() {
 		fmt.Printf("sockerless-admin %s\n", version)
 		os.Exit(0)
 	}
+	if *hashPassword {
+		pw, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("read password: %v", err)
+		}
+		h, err := HashPassword(strings.TrimRight(string(pw), "\r\n"))
+		if err != nil {
+			log.Fatalf("hash password: %v", err)
+		}
+		fmt.Println(h)
+		os.Exit(0)
+	}
+
+	authCfg, err := LoadAuthConfig(*authConfigPath)
+	if err != nil {
+		log.Fatalf("auth config: %v", err)
+	}
+	auth, err := NewAuthenticator(authCfg)
+	if err != nil {
+		log.Fatalf("auth: %v", err)
+	}
+	auditPath := DefaultAuditLogPath()
+	if authCfg != nil && authCfg.AuditLog != "" {
+		auditPath = authCfg.AuditLog
+	}
+	audit, err := OpenAuditLog(auditPath)
+	if err != nil {
+		log.Fatalf("audit log: %v", err)
+	}
+	defer func() { _ = audit.Close() }()
+	if !auth.Enabled() {
+		log.Printf("WARNING: authentication disabled (no %s); every caller is admin", *authConfigPath)
+	}
 
 	reg := NewRegistry()
 	procMgr := NewProcessManager(reg)
@@ -98,6 +133,7 @@ func main() {
 	mux := http.NewServeMux()
 	registerAPI(mux, reg, procMgr, projectMgr)
 	registerTopologyAPI(mux, topologyMgr, NewInstanceLifecycle("", 0))
+	registerAuthAPI(mux, auth, audit)
 	obsCfg := loadObservabilityConfig()
 	mux.HandleFunc("GET /api/v1/observability", handleObservabilityConfig(obsCfg))
 	registerUI(mux)
@@ -119,7 +155,7 @@ func main() {
 	// otelhttp.NewHandler at the outermost layer captures every
 	// admin API + UI request as a span. Spans emit to a no-op
 	// tracer unless OTEL_EXPORTER_OTLP_ENDPOINT is set.
-	handler := otelhttp.NewHandler(mux, "sockerless-admin")
+	handler := otelhttp.NewHandler(withAuth(mux, auth, audit), "sockerless-admin")
 	srv := &http.Server{Addr: *addr, Handler: handler}
 
 	// Handle graceful shutdown
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PlanAction is what a TopologyPlan step does to one instance (or, for
+// project-level steps, to a project entry in sockerless.yaml).
+type PlanAction string
+
+const (
+	// PlanCreate adds a declared instance (or project) and starts it.
+	PlanCreate PlanAction = "create"
+	// PlanUpdate applies a changed spec: stop when running, then start
+	// with the new spec.
+	PlanUpdate PlanAction = "update"
+	// PlanStart starts a declared, unchanged instance that isn't running.
+	PlanStart PlanAction = "start"
+	// PlanDelete stops (when running) and removes an instance no longer
+	// declared, or removes a project.
+	PlanDelete PlanAction = "delete"
+)
+
+// PlanStep is one entry of a TopologyPlan. Instance is empty for
+// project-level steps.
+type PlanStep struct {
+	Action   PlanAction   `json:"action"`
+	Project  string       `json:"project"`
+	Instance string       `json:"instance,omitempty"`
+	Kind     InstanceKind `json:"kind,omitempty"`
+	// Running is the instance's state when the plan was computed;
+	// update and delete only stop running instances.
+	Running bool `json:"running,omitempty"`
+	// Changes lists per-field differences for update steps, e.g.
+	// "port: 4566 → 4567".
+	Changes []string `json:"changes,omitempty"`
+}
+
+// TopologyPlan is the diff between a desired topology and what admin
+// is running. Steps are in execution order: deletes (backends before
+// the sims they point at), then creates / updates / starts (sims
+// before the backends that point at them). Port-range edits have no
+// lifecycle effect and are listed in ConfigChanges.
+type TopologyPlan struct {
+	Steps         []PlanStep `json:"steps"`
+	ConfigChanges []string   `json:"config_changes,omitempty"`
+
+	desired Topology
+}
+
+// Empty reports whether applying the plan would change nothing.
+func (p TopologyPlan) Empty() bool {
+	return len(p.Steps) == 0 && len(p.ConfigChanges) == 0
+}
+
+// String renders the plan for humans: one line per step, with
+// update changes indented below their step.
+func (p TopologyPlan) String() string {
+	if p.Empty() {
+		return "No changes. Running topology matches the desired state.\n"
+	}
+	var b strings.Builder
+	counts := map[PlanAction]int{}
+	for _, s := range p.Steps {
+		target := s.Project
+		if s.Instance != "" {
+			target += "/" + s.Instance
+			counts[s.Action]++
+		}
+		symbol := map[PlanAction]string{PlanCreate: "+", PlanUpdate: "~", PlanStart: ">", PlanDelete: "-"}[s.Action]
+		note := ""
+		switch {
+		case s.Instance == "":
+			note = " (project)"
+		case s.Action == PlanUpdate && s.Running:
+			note = " (restart)"
+		case s.Action == PlanDelete && s.Running:
+			note = " (stop)"
+		}
+		fmt.Fprintf(&b, "  %s %-7s %s%s\n", symbol, s.Action, target, note)
+		for _, c := range s.Changes {
+			fmt.Fprintf(&b, "        %s\n", c)
+		}
+	}
+	for _, c := range p.ConfigChanges {
+		fmt.Fprintf(&b, "  ~ config  %s\n", c)
+	}
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d to start, %d to delete.\n",
+		counts[PlanCreate], counts[PlanUpdate], counts[PlanStart], counts[PlanDelete])
+	return b.String()
+}
+
+// ParseTopologyYAML decodes a desired-state document in the
+// sockerless.yaml schema.
+func ParseTopologyYAML(data []byte) (Topology, error) {
+	var t Topology
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return Topology{}, fmt.Errorf("parse topology: %w", err)
+	}
+	return t, nil
+}
+
+// kindOrder ranks kinds by dependency: sims come up first because
+// backends point at them; bleephub depends on neither.
+func kindOrder(k InstanceKind) int {
+	switch k {
+	case InstanceKindSim:
+		return 0
+	case InstanceKindBleephub:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// ComputePlan diffs desired against current (the topology admin
+// holds) and the live process state. running reports whether an
+// instance's process is up. desired is validated first; an invalid
+// document yields no plan. An omitted `ports:` block means the default
+// ranges, as it does for the file admin loads at startup.
+func ComputePlan(current, desired Topology, running func(Instance) bool) (TopologyPlan, error) {
+	desired.Ports.Ranges = effectiveRanges(desired.Ports.Ranges)
+	if err := desired.Validate(); err != nil {
+		return TopologyPlan{}, fmt.Errorf("validate: %w", err)
+	}
+	plan := TopologyPlan{desired: deepCopyTopology(&desired)}
+
+	curProjects := map[string]ProjectConfig{}
+	for _, p := range current.Projects {
+		curProjects[p.Name] = p
+	}
+	desProjects := map[string]ProjectConfig{}
+	for _, p := range desired.Projects {
+		desProjects[p.Name] = p
+	}
+
+	var down, up []PlanStep
+	for _, p := range current.Projects {
+		want, kept := desProjects[p.Name]
+		for _, inst := range p.Instances {
+			if kept && findInstance(want, inst.Name) != nil {
+				continue
+			}
+			down = append(down, PlanStep{
+				Action: PlanDelete, Project: p.Name, Instance: inst.Name, Kind: inst.Kind,
+				Running: running(inst),
+			})
+		}
+		if !kept {
+			down = append(down, PlanStep{Action: PlanDelete, Project: p.Name})
+		}
+	}
+	for _, p := range desired.Projects {
+		had, existed := curProjects[p.Name]
+		if !existed {
+			up = append(up, PlanStep{Action: PlanCreate, Project: p.Name})
+		}
+		for _, inst := range p.Instances {
+			var old *Instance
+			if existed {
+				old = findInstance(had, inst.Name)
+			}
+			switch {
+			case old == nil:
+				up = append(up, PlanStep{Action: PlanCreate, Project: p.Name, Instance: inst.Name, Kind: inst.Kind})
+			default:
+				changes := instanceChanges(*old, inst, simPortOf(had, *old), simPortOf(p, inst))
+				isUp := running(*old)
+				switch {
+				case len(changes) > 0:
+					up = append(up, PlanStep{
+						Action: PlanUpdate, Project: p.Name, Instance: inst.Name, Kind: inst.Kind,
+						Running: isUp, Changes: changes,
+					})
+				case !isUp:
+					up = append(up, PlanStep{Action: PlanStart, Project: p.Name, Instance: inst.Name, Kind: inst.Kind})
+				}
+			}
+		}
+	}
+
+	// Project-level steps sort ahead of their instances on the way up
+	// and behind them on the way down.
+	sort.SliceStable(down, func(i, j int) bool {
+		a, b := down[i], down[j]
+		if (a.Instance == "") != (b.Instance == "") {
+			return b.Instance == ""
+		}
+		return kindOrder(a.Kind) > kindOrder(b.Kind)
+	})
+	sort.SliceStable(up, func(i, j int) bool {
+		a, b := up[i], up[j]
+		if (a.Instance == "") != (b.Instance == "") {
+			return a.Instance == ""
+		}
+		return kindOrder(a.Kind) < kindOrder(b.Kind)
+	})
+	plan.Steps = append(down, up...)
+
+	was, now := effectiveRanges(current.Ports.Ranges), desired.Ports.Ranges
+	for _, kind := range AllInstanceKinds {
+		a, had := was[kind]
+		b, has := now[kind]
+		switch {
+		case had && !has:
+			plan.ConfigChanges = append(plan.ConfigChanges, fmt.Sprintf("ports.ranges.%s: %d-%d → (none)", kind, a.From, a.To))
+		case !had && has:
+			plan.ConfigChanges = append(plan.ConfigChanges, fmt.Sprintf("ports.ranges.%s: (none) → %d-%d", kind, b.From, b.To))
+		case a != b:
+			plan.ConfigChanges = append(plan.ConfigChanges, fmt.Sprintf("ports.ranges.%s: %d-%d → %d-%d", kind, a.From, a.To, b.From, b.To))
+		}
+	}
+	return plan, nil
+}
+
+// effectiveRanges substitutes the defaults admin seeds at startup when
+// a file has no `ports:` block.
+func effectiveRanges(r map[InstanceKind]PortRange) map[InstanceKind]PortRange {
+	if len(r) == 0 {
+		return DefaultPortRanges()
+	}
+	return r
+}
+
+func findInstance(p ProjectConfig, name string) *Instance {
+	for i := range p.Instances {
+		if p.Instances[i].Name == name {
+			return &p.Instances[i]
+		}
+	}
+	return nil
+}
+
+// simPortOf resolves a backend's Sim ref to the sim's port within p;
+// 0 when there's no link.
+func simPortOf(p ProjectConfig, inst Instance) int {
+	if inst.Kind != InstanceKindBackend || inst.Sim == "" {
+		return 0
+	}
+	if sim := findInstance(p, inst.Sim); sim != nil {
+		return sim.Port
+	}
+	return 0
+}
+
+// instanceChanges lists the differences between two specs of the same
+// instance that need a restart to take effect. A backend whose sim
+// moved ports restarts too: SIM_PORT is fixed at start time.
+func instanceChanges(old, next Instance, oldSimPort, nextSimPort int) []string {
+	var out []string
+	diff := func(field string, a, b any) {
+		if a != b {
+			out = append(out, fmt.Sprintf("%s: %v → %v", field, orNone(a), orNone(b)))
+		}
+	}
+	diff("kind", old.Kind, next.Kind)
+	diff("cloud", old.Cloud, next.Cloud)
+	diff("backend", old.Backend, next.Backend)
+	diff("port", old.Port, next.Port)
+	diff("sim", old.Sim, next.Sim)
+	if old.Sim == next.Sim {
+		diff("sim port", oldSimPort, nextSimPort)
+	}
+	keys := map[string]bool{}
+	for k := range old.Config {
+		keys[k] = true
+	}
+	for k := range next.Config {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		was, had := old.Config[k]
+		now, has := next.Config[k]
+		switch {
+		case had && !has:
+			out = append(out, fmt.Sprintf("config.%s: removed", k))
+		case !had && has:
+			out = append(out, fmt.Sprintf("config.%s: added", k))
+		case was != now:
+			out = append(out, fmt.Sprintf("config.%s: changed", k))
+		}
+	}
+	return out
+}
+
+// orNone prints zero values as "(none)" so a cleared field reads as
+// such rather than as an empty string or 0.
+func orNone(v any) any {
+	if v == reflect.Zero(reflect.TypeOf(v)).Interface() {
+		return "(none)"
+	}
+	return v
+}
+
+// instanceController is the slice of InstanceLifecycle apply drives;
+// tests substitute a fake.
+type instanceController interface {
+	Start(ctx context.Context, project string, inst Instance, simPort int) error
+	Stop(ctx context.Context, inst Instance) error
+}
+
+// TopologyApplier converges admin's topology and the running
+// instances to a desired document. One apply runs at a time.
+type TopologyApplier struct {
+	mgr     *TopologyManager
+	ctl     instanceController
+	running func(Instance) bool
+
+	mu sync.Mutex
+}
+
+// NewTopologyApplier binds an applier to mgr. Running state comes
+// from the instances' pidfiles, as on the status endpoint.
+func NewTopologyApplier(mgr *TopologyManager, lifecycle *InstanceLifecycle) *TopologyApplier {
+	a := &TopologyApplier{
+		mgr:     mgr,
+		running: func(inst Instance) bool { return readInstanceStatus(inst).Running },
+	}
+	if lifecycle != nil {
+		a.ctl = lifecycle
+	}
+	return a
+}
+
+// Plan diffs desired against the current topology and running state.
+func (a *TopologyApplier) Plan(desired Topology) (TopologyPlan, error) {
+	return ComputePlan(a.mgr.Get(), desired, a.running)
+}
+
+// ApplyResult reports what Apply did. On failure Error names the step
+// that failed and RolledBack says whether the previous topology and
+// instance states were restored; RollbackErrors lists anything the
+// rollback itself couldn't undo.
+type ApplyResult struct {
+	Plan           TopologyPlan `json:"plan"`
+	Done           []string     `json:"done"`
+	Error          string       `json:"error,omitempty"`
+	RolledBack     bool         `json:"rolled_back,omitempty"`
+	RollbackErrors []string     `json:"rollback_errors,omitempty"`
+}
+
+// placed pairs an instance spec with the project it starts in and the
+// sim port it starts with — what Start needs to redo it in rollback.
+type placed struct {
+	project string
+	inst    Instance
+	simPort int
+}
+
+// Apply computes the plan and carries it out in three phases: stop
+// running instances that are deleted or updated (backends first),
+// persist the desired topology, then start created, updated and
+// stopped instances (sims first). If any step fails, everything done
+// so far is undone in reverse — started instances stopped, the old
+// topology restored, stopped instances started again — and the
+// returned error names the failed step.
+func (a *TopologyApplier) Apply(ctx context.Context, desired Topology) (ApplyResult, error) {
+	if a.ctl == nil {
+		return ApplyResult{}, fmt.Errorf("lifecycle not configured")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current := a.mgr.Get()
+	plan, err := ComputePlan(current, desired, a.running)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	res := ApplyResult{Plan: plan}
+	if plan.Empty() {
+		return res, nil
+	}
+
+	var stopped, started []placed
+	wrote := false
+	fail := func(step string, err error) (ApplyResult, error) {
+		res.Error = fmt.Sprintf("%s: %v", step, err)
+		res.RollbackErrors = a.rollback(ctx, current, wrote, stopped, started)
+		res.RolledBack = len(res.RollbackErrors) == 0
+		return res, fmt.Errorf("apply failed at %s: %w", step, err)
+	}
+
+	// Updates sit in the plan's sim-first half; stopping goes the
+	// other way so no backend outlives the sim it points at.
+	var toStop []PlanStep
+	for _, s := range plan.Steps {
+		if s.Instance != "" && s.Running && (s.Action == PlanDelete || s.Action == PlanUpdate) {
+			toStop = append(toStop, s)
+		}
+	}
+	sort.SliceStable(toStop, func(i, j int) bool { return kindOrder(toStop[i].Kind) > kindOrder(toStop[j].Kind) })
+	for _, s := range toStop {
+		p := placedIn(current, s.Project, s.Instance)
+		if err := a.ctl.Stop(ctx, p.inst); err != nil {
+			return fail("stop "+s.Project+"/"+s.Instance, err)
+		}
+		stopped = append(stopped, p)
+		res.Done = append(res.Done, "stopped "+s.Project+"/"+s.Instance)
+	}
+
+	next := plan.desired
+	preserveCreatedAt(&next, current)
+	if err := a.mgr.Replace(next); err != nil {
+		return fail("write topology", err)
+	}
+	wrote = true
+	res.Done = append(res.Done, "wrote "+a.mgr.Path())
+
+	for _, s := range plan.Steps {
+		if s.Instance == "" || s.Action == PlanDelete {
+			continue
+		}
+		p := placedIn(next, s.Project, s.Instance)
+		// Recorded before Start so a half-started instance is stopped
+		// on rollback too.
+		started = append(started, p)
+		if err := a.ctl.Start(ctx, p.project, p.inst, p.simPort); err != nil {
+			return fail("start "+s.Project+"/"+s.Instance, err)
+		}
+		res.Done = append(res.Done, "started "+s.Project+"/"+s.Instance)
+	}
+	return res, nil
+}
+
+// rollback undoes a partial apply. Best-effort: every step is tried
+// and failures are returned rather than aborting the rest.
+func (a *TopologyApplier) rollback(ctx context.Context, previous Topology, wrote bool, stopped, started []placed) []string {
+	var errs []string
+	for i := len(started) - 1; i >= 0; i-- {
+		p := started[i]
+		if err := a.ctl.Stop(ctx, p.inst); err != nil {
+			errs = append(errs, fmt.Sprintf("stop %s/%s: %v", p.project, p.inst.Name, err))
+		}
+	}
+	if wrote {
+		if err := a.mgr.Replace(previous); err != nil {
+			errs = append(errs, fmt.Sprintf("restore topology: %v", err))
+		}
+	}
+	// Stops ran backends-first; restart in reverse so sims come back
+	// before the backends that point at them.
+	for i := len(stopped) - 1; i >= 0; i-- {
+		p := stopped[i]
+		if err := a.ctl.Start(ctx, p.project, p.inst, p.simPort); err != nil {
+			errs = append(errs, fmt.Sprintf("restart %s/%s: %v", p.project, p.inst.Name, err))
+		}
+	}
+	for _, e := range errs {
+		log.Printf("topology apply rollback: %s", e)
+	}
+	return errs
+}
+
+// placedIn looks up an instance the plan references. The plan was
+// computed from t, so the lookup can't miss.
+func placedIn(t Topology, project, instance string) placed {
+	for _, p := range t.Projects {
+		if p.Name != project {
+			continue
+		}
+		inst := findInstance(p, instance)
+		return placed{project: project, inst: *inst, simPort: simPortOf(p, *inst)}
+	}
+	panic("topology plan references unknown instance " + project + "/" + instance)
+}
+
+// preserveCreatedAt carries each kept project's created_at over when
+// the desired document omits it, so a git-managed file doesn't have
+// to track admin's timestamps.
+func preserveCreatedAt(next *Topology, current Topology) {
+	created := map[string]string{}
+	for _, p := range current.Projects {
+		created[p.Name] = p.CreatedAt
+	}
+	for i := range next.Projects {
+		if next.Projects[i].CreatedAt == "" {
+			next.Projects[i].CreatedAt = created[next.Projects[i].Name]
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeController records Start/Stop calls and tracks which instances
+// are "running" so the applier's running func can see them.
+type fakeController struct {
+	calls   []string
+	up      map[string]bool
+	failOn  string
+	simPort map[string]int
+}
+
+func newFakeController() *fakeController {
+	return &fakeController{up: map[string]bool{}, simPort: map[string]int{}}
+}
+
+func (f *fakeController) Start(_ context.Context, project string, inst Instance, simPort int) error {
+	f.calls = append(f.calls, "start "+inst.Name)
+	if inst.Name == f.failOn {
+		f.failOn = "" // fail once; the rollback's restart succeeds
+		return errors.New("boom")
+	}
+	f.up[inst.Name] = true
+	f.simPort[inst.Name] = simPort
+	return nil
+}
+
+func (f *fakeController) Stop(_ context.Context, inst Instance) error {
+	f.calls = append(f.calls, "stop "+inst.Name)
+	delete(f.up, inst.Name)
+	return nil
+}
+
+func newTestApplier(t *testing.T, ctl *fakeController) (*TopologyManager, *TopologyApplier) {
+	t.Helper()
+	mgr := NewTopologyManager(filepath.Join(t.TempDir(), "sockerless.yaml"))
+	if err := mgr.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return mgr, &TopologyApplier{
+		mgr:     mgr,
+		ctl:     ctl,
+		running: func(inst Instance) bool { return ctl.up[inst.Name] },
+	}
+}
+
+func awsProject(simPort int, config map[string]string) ProjectConfig {
+	return ProjectConfig{Name: "p", Instances: []Instance{
+		{Name: "be", Kind: InstanceKindBackend, Cloud: CloudAWS, Backend: BackendECS, Port: 3300, Sim: "sim", Config: config},
+		{Name: "sim", Kind: InstanceKindSim, Cloud: CloudAWS, Port: simPort},
+		{Name: "hub", Kind: InstanceKindBleephub, Port: 5500},
+	}}
+}
+
+func stepNames(p TopologyPlan) []string {
+	out := make([]string, 0, len(p.Steps))
+	for _, s := range p.Steps {
+		out = append(out, string(s.Action)+" "+s.Project+"/"+s.Instance)
+	}
+	return out
+}
+
+func TestComputePlanOrdersByDependency(t *testing.T) {
+	current := Topology{Projects: []ProjectConfig{awsProject(4566, nil)}}
+	desired := Topology{Projects: []ProjectConfig{{Name: "q", Instances: []Instance{
+		{Name: "be2", Kind: InstanceKindBackend, Cloud: CloudAWS, Backend: BackendLambda, Port: 3301, Sim: "sim2"},
+		{Name: "sim2", Kind: InstanceKindSim, Cloud: CloudAWS, Port: 4567},
+	}}}}
+	running := func(Instance) bool { return true }
+
+	plan, err := ComputePlan(current, desired, running)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	want := []string{
+		"delete p/be", "delete p/hub", "delete p/sim", "delete p/",
+		"create q/", "create q/sim2", "create q/be2",
+	}
+	if got := stepNames(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %v, want %v", got, want)
+	}
+	if !strings.Contains(plan.String(), "Plan: 2 to create, 0 to update, 0 to start, 3 to delete.") {
+		t.Errorf("summary missing from:\n%s", plan)
+	}
+}
+
+func TestComputePlanUpdateAndStart(t *testing.T) {
+	current := Topology{Projects: []ProjectConfig{awsProject(4566, map[string]string{"SOCKERLESS_LOG_LEVEL": "info"})}}
+	// Moving the sim changes the backend's SIM_PORT too; hub is
+	// unchanged but down, so it's only started.
+	desired := Topology{Projects: []ProjectConfig{awsProject(4570, map[string]string{"SOCKERLESS_LOG_LEVEL": "debug"})}}
+	running := func(inst Instance) bool { return inst.Name != "hub" }
+
+	plan, err := ComputePlan(current, desired, running)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	want := []string{"update p/sim", "start p/hub", "update p/be"}
+	if got := stepNames(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("steps = %v, want %v", got, want)
+	}
+	be := plan.Steps[2]
+	wantChanges := []string{"sim port: 4566 → 4570", "config.SOCKERLESS_LOG_LEVEL: changed"}
+	if !reflect.DeepEqual(be.Changes, wantChanges) {
+		t.Errorf("backend changes = %v, want %v", be.Changes, wantChanges)
+	}
+	if !be.Running {
+		t.Errorf("running backend update should be marked for restart")
+	}
+}
+
+func TestComputePlanNoChanges(t *testing.T) {
+	top := Topology{Projects: []ProjectConfig{awsProject(4566, nil)}}
+	plan, err := ComputePlan(top, top, func(Instance) bool { return true })
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if !plan.Empty() {
+		t.Errorf("identical topology with everything running should plan nothing, got %v %v", stepNames(plan), plan.ConfigChanges)
+	}
+}
+
+func TestComputePlanRejectsInvalid(t *testing.T) {
+	bad := Topology{Projects: []ProjectConfig{{Name: "p", Instances: []Instance{
+		{Name: "be", Kind: InstanceKindBackend, Cloud: CloudAWS, Backend: BackendECS, Port: 3300, Sim: "missing"},
+	}}}}
+	_, err := ComputePlan(Topology{}, bad, func(Instance) bool { return false })
+	if err == nil || !strings.HasPrefix(err.Error(), "validate:") {
+		t.Errorf("want validate: error, got %v", err)
+	}
+}
+
+func TestApplyStartsSimsBeforeBackends(t *testing.T) {
+	ctl := newFakeController()
+	mgr, applier := newTestApplier(t, ctl)
+
+	res, err := applier.Apply(context.Background(), Topology{Projects: []ProjectConfig{awsProject(4566, nil)}})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	want := []string{"start sim", "start hub", "start be"}
+	if !reflect.DeepEqual(ctl.calls, want) {
+		t.Errorf("calls = %v, want %v", ctl.calls, want)
+	}
+	if ctl.simPort["be"] != 4566 {
+		t.Errorf("backend started with sim port %d, want 4566", ctl.simPort["be"])
+	}
+	if got := mgr.Get(); len(got.Projects) != 1 || len(got.Projects[0].Instances) != 3 {
+		t.Errorf("topology not persisted: %+v", got)
+	}
+	if len(res.Done) != 4 {
+		t.Errorf("done = %v, want write + 3 starts", res.Done)
+	}
+
+	// Converged: a second apply is a no-op.
+	ctl.calls = nil
+	if res, err := applier.Apply(context.Background(), mgr.Get()); err != nil || !res.Plan.Empty() || len(ctl.calls) != 0 {
+		t.Errorf("re-apply should be a no-op: err=%v plan=%v calls=%v", err, stepNames(res.Plan), ctl.calls)
+	}
+}
+
+func TestApplyRollsBackOnFailure(t *testing.T) {
+	ctl := newFakeController()
+	mgr, applier := newTestApplier(t, ctl)
+	old := Topology{Projects: []ProjectConfig{awsProject(4566, nil)}}
+	if _, err := applier.Apply(context.Background(), old); err != nil {
+		t.Fatalf("seed apply: %v", err)
+	}
+
+	ctl.calls = nil
+	ctl.failOn = "be"
+	res, err := applier.Apply(context.Background(), Topology{Projects: []ProjectConfig{awsProject(4570, nil)}})
+	if err == nil {
+		t.Fatal("apply should fail when a start fails")
+	}
+	want := []string{
+		// stop the instances being updated, backend first
+		"stop be", "stop sim",
+		// start the new spec, sim first; the backend fails
+		"start sim", "start be",
+		// rollback: stop what this apply started, restart what it stopped
+		"stop be", "stop sim",
+		"start sim", "start be",
+	}
+	if !reflect.DeepEqual(ctl.calls, want) {
+		t.Errorf("calls = %v\nwant    %v", ctl.calls, want)
+	}
+	if !res.RolledBack || !strings.Contains(res.Error, "start p/be") {
+		t.Errorf("result = %+v, want rolled back with failed step named", res)
+	}
+	if got := mgr.Get(); !reflect.DeepEqual(got.Projects[0].Instances, old.Projects[0].Instances) {
+		t.Errorf("topology not restored: %+v", got.Projects[0].Instances)
+	}
+	if !ctl.up["sim"] || !ctl.up["be"] || ctl.simPort["be"] != 4566 {
+		t.Errorf("old instances should be running again on the old sim port: up=%v be sim port=%d", ctl.up, ctl.simPort["be"])
+	}
+}
+
+func TestAPITopologyPlan(t *testing.T) {
+	_, mux := setupTopologyServer(t)
+	doc := `
+projects:
+  - name: p
+    instances:
+      - name: sim
+        kind: sim
+        cloud: aws
+        port: 4566
+`
+	req := httptest.NewRequest("POST", "/api/v1/topology/plan", strings.NewReader(doc))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	var got planResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Steps) != 2 || !strings.Contains(got.Text, "+ create  p/sim") {
+		t.Errorf("plan = %+v", got)
+	}
+}
+
+func TestAPITopologyApplyWithoutLifecycle(t *testing.T) {
+	_, mux := setupTopologyServer(t)
+	req := httptest.NewRequest("POST", "/api/v1/topology/apply", strings.NewReader("projects: []\n"))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
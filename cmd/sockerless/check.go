@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 )
 
 func cmdCheck() {
@@ -24,6 +26,11 @@ func cmdCheck() {
 			Name   string `json:"name"`
 			Status string `json:"status"`
 			Detail string `json:"detail"`
+
+			// Set on permission-preflight failures.
+			Permission string   `json:"permission"`
+			Resource   string   `json:"resource"`
+			Verbs      []string `json:"verbs"`
 		} `json:"checks"`
 	}
 	if err := json.Unmarshal(data, &resp); err != nil {
@@ -32,7 +39,17 @@ func cmdCheck() {
 	}
 
 	allOk := true
+	missingByVerb := map[string][]string{}
 	for _, c := range resp.Checks {
+		if c.Status != "ok" && c.Permission != "" {
+			perm := c.Permission
+			if c.Resource != "" {
+				perm += " on " + c.Resource
+			}
+			for _, v := range c.Verbs {
+				missingByVerb[v] = append(missingByVerb[v], perm)
+			}
+		}
 		icon := "OK"
 		if c.Status != "ok" {
 			icon = "FAIL"
@@ -45,6 +62,19 @@ func cmdCheck() {
 		fmt.Printf("  [%s] %s%s\n", icon, c.Name, detail)
 	}
 
+	if len(missingByVerb) > 0 {
+		verbs := make([]string, 0, len(missingByVerb))
+		for v := range missingByVerb {
+			verbs = append(verbs, v)
+		}
+		sort.Strings(verbs)
+		fmt.Println()
+		fmt.Println("  Docker verbs that will fail:")
+		for _, v := range verbs {
+			fmt.Printf("    docker %s: %s\n", v, strings.Join(missingByVerb[v], ", "))
+		}
+	}
+
 	if !allOk {
 		os.Exit(1)
 	}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// costReport is the subset of GET /internal/v1/costs the CLI prints.
+type costReport struct {
+	PriceVersion string `json:"priceVersion"`
+	Currency     string `json:"currency"`
+	GroupBy      string `json:"groupBy"`
+	Total        struct {
+		Total float64 `json:"total"`
+	} `json:"total"`
+	Groups []struct {
+		Key  string `json:"key"`
+		Cost struct {
+			Total float64 `json:"total"`
+		} `json:"cost"`
+		Runs    int     `json:"runs"`
+		Running int     `json:"running"`
+		Seconds float64 `json:"seconds"`
+	} `json:"groups"`
+	Budgets []struct {
+		Label     string  `json:"label"`
+		Value     string  `json:"value"`
+		Limit     float64 `json:"limit"`
+		Spent     float64 `json:"spent"`
+		Exhausted bool    `json:"exhausted"`
+	} `json:"budgets"`
+}
+
+func cmdCosts(args []string) {
+	fs := flag.NewFlagSet("costs", flag.ExitOnError)
+	groupBy := fs.String("group-by", "container", "container, project, backend or label:<key>")
+	since := fs.String("since", "", "RFC 3339 time or duration back from now (default: start of the budget period)")
+	_ = fs.Parse(args)
+
+	addr := activeAddr()
+	if addr == "" {
+		fmt.Fprintln(os.Stderr, "error: no server address configured in active context")
+		os.Exit(1)
+	}
+
+	q := url.Values{"group_by": {*groupBy}}
+	if *since != "" {
+		q.Set("since", *since)
+	}
+	data, err := mgmtGet(addr, "/internal/v1/costs?"+q.Encode())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var rep costReport
+	if err := json.Unmarshal(data, &rep); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%-40s  %5s  %7s  %10s  %12s\n", "GROUP", "RUNS", "RUNNING", "HOURS", "COST ("+rep.Currency+")")
+	for _, g := range rep.Groups {
+		key := g.Key
+		if key == "" {
+			key = "(none)"
+		}
+		fmt.Printf("%-40s  %5d  %7d  %10.2f  %12.4f\n", key, g.Runs, g.Running, g.Seconds/3600, g.Cost.Total)
+	}
+	fmt.Printf("%-40s  %5s  %7s  %10s  %12.4f\n", "TOTAL", "", "", "", rep.Total.Total)
+	fmt.Printf("\nEstimated from price table %s\n", rep.PriceVersion)
+
+	if len(rep.Budgets) > 0 {
+		fmt.Printf("\n%-40s  %12s  %12s  %s\n", "BUDGET", "SPENT", "LIMIT", "STATUS")
+		for _, b := range rep.Budgets {
+			status := "ok"
+			if b.Exhausted {
+				status = "exhausted"
+			}
+			fmt.Printf("%-40s  %12.4f  %12.4f  %s\n", b.Label+"="+b.Value, b.Spent, b.Limit, status)
+		}
+	}
+}
@@ -47,6 +47,10 @@ func main() {
 		cmdMetrics()
 	case "resources":
 		cmdResources(os.Args[2:])
+	case "registry":
+		cmdRegistry(os.Args[2:])
+	case "costs":
+		cmdCosts(os.Args[2:])
 	case "simulator", "sim":
 		cmdSimulator(os.Args[2:])
 	case "config":
@@ -56,8 +60,12 @@ func main() {
 			fmt.Fprintln(os.Stderr, "Usage: sockerless config migrate [--write]")
 			os.Exit(1)
 		}
+	case "topology":
+		cmdTopology(os.Args[2:])
 	case "check":
 		cmdCheck()
+	case "migrate":
+		cmdMigrate(os.Args[2:])
 	case "version":
 		fmt.Printf("sockerless %s\n", version)
 	default:
@@ -78,7 +86,11 @@ Commands:
   ps        List containers
   metrics   Show server metrics
   resources Manage cloud resources
+  registry  Manage registry pull-through mirrors
+  costs     Show estimated cloud costs and budgets
+  topology  Plan/apply a sockerless.yaml against sockerless-admin
   check     Run backend health checks
+  migrate   Move a container to another context's backend
   version   Print version`)
 }
 
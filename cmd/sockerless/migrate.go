@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// cmdMigrate moves a container from the active context's backend to
+// another context's backend. The target backend does the work (see
+// POST /internal/v1/containers/migrate): it checkpoints the container
+// on the source, loads the committed image into its own registry,
+// copies named volumes into its own storage and recreates the
+// container. The CLI then removes the source container.
+func cmdMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := fs.String("to", "", "target context (required)")
+	keepSource := fs.Bool("keep-source", false, "leave the source container in place")
+	_ = fs.Parse(args)
+	// Accept flags after the container name too.
+	if fs.NArg() < 1 {
+		migrateUsage()
+	}
+	container := fs.Arg(0)
+	_ = fs.Parse(fs.Args()[1:])
+	if *to == "" || fs.NArg() > 0 {
+		migrateUsage()
+	}
+
+	source := activeAddr()
+	if source == "" {
+		fmt.Fprintln(os.Stderr, "error: no server address configured in active context")
+		os.Exit(1)
+	}
+	cfg := requireConfigFile()
+	env, ok := cfg.Environments[*to]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: context %q not found\n", *to)
+		os.Exit(1)
+	}
+	if env.Addr == "" {
+		fmt.Fprintf(os.Stderr, "error: context %q has no server address\n", *to)
+		os.Exit(1)
+	}
+	if *to == activeContextName() || env.Addr == source {
+		fmt.Fprintln(os.Stderr, "error: --to names the active context; migrate needs a different backend")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Migrating %s to %s (%s)...\n", container, *to, env.Backend)
+	body, _ := json.Marshal(map[string]string{"Source": source, "Container": container})
+	data, err := mgmtDo(http.MethodPost, env.Addr, "/internal/v1/containers/migrate", body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	var resp struct {
+		ID       string   `json:"Id"`
+		SourceID string   `json:"SourceId"`
+		Name     string   `json:"Name"`
+		Image    string   `json:"Image"`
+		Volumes  []string `json:"Volumes"`
+		Started  bool     `json:"Started"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  image:     %s\n", resp.Image)
+	if len(resp.Volumes) > 0 {
+		fmt.Printf("  volumes:   %s\n", strings.Join(resp.Volumes, ", "))
+	}
+	state := "created"
+	if resp.Started {
+		state = "started"
+	}
+	fmt.Printf("  container: %s %s on %s\n", shortID(resp.ID), state, *to)
+
+	if *keepSource {
+		return
+	}
+	if _, err := mgmtDo(http.MethodDelete, source, "/internal/v1/containers/"+url.PathEscape(resp.SourceID)+"?force=true", nil); err != nil {
+		fmt.Fprintf(os.Stderr, "error: migrated, but removing the source container failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  removed %s from the source backend\n", shortID(resp.SourceID))
+}
+
+func migrateUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: sockerless migrate <container> --to <context> [--keep-source]")
+	os.Exit(1)
+}
+
+// mgmtDo sends a management request without a timeout; migration
+// commits and copies whole filesystems.
+func mgmtDo(method, addr, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, addr+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, e.Message)
+		}
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(data))
+	}
+	return data, nil
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// registryMirror is one entry of GET /internal/v1/registry/mirrors.
+type registryMirror struct {
+	Upstream   string    `json:"Upstream"`
+	Name       string    `json:"Name"`
+	URI        string    `json:"URI"`
+	Credential string    `json:"Credential"`
+	Managed    bool      `json:"Managed"`
+	Created    time.Time `json:"Created"`
+}
+
+func cmdRegistry(args []string) {
+	if len(args) < 1 || args[0] != "mirrors" {
+		registryUsage()
+		os.Exit(1)
+	}
+	sub := "ls"
+	if len(args) > 1 {
+		sub = args[1]
+	}
+	switch sub {
+	case "list", "ls":
+		registryMirrorsList()
+	case "prune":
+		fs := flag.NewFlagSet("registry mirrors prune", flag.ExitOnError)
+		all := fs.Bool("all", false, "also remove mirrors that images and containers still pull through")
+		_ = fs.Parse(args[2:])
+		registryMirrorsPrune(*all)
+	default:
+		registryUsage()
+		os.Exit(1)
+	}
+}
+
+func registryUsage() {
+	fmt.Fprintln(os.Stderr, `Usage: sockerless registry mirrors <subcommand>
+
+Subcommands:
+  list          List the pull-through caches in the backend's registry
+  prune [--all] Remove sockerless-managed mirrors no image or container uses`)
+}
+
+func registryMirrorsList() {
+	addr := activeAddr()
+	if addr == "" {
+		fmt.Fprintln(os.Stderr, "error: no server address configured in active context")
+		os.Exit(1)
+	}
+
+	data, err := mgmtGet(addr, "/internal/v1/registry/mirrors")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var mirrors []registryMirror
+	if err := json.Unmarshal(data, &mirrors); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(mirrors) == 0 {
+		fmt.Println("No registry mirrors")
+		return
+	}
+
+	fmt.Printf("%-24s  %-24s  %-7s  %-11s  %s\n", "UPSTREAM", "NAME", "MANAGED", "CREDENTIALS", "URI")
+	for _, m := range mirrors {
+		managed, creds := "no", "no"
+		if m.Managed {
+			managed = "yes"
+		}
+		if m.Credential != "" {
+			creds = "yes"
+		}
+		fmt.Printf("%-24s  %-24s  %-7s  %-11s  %s\n", m.Upstream, m.Name, managed, creds, m.URI)
+	}
+}
+
+func registryMirrorsPrune(all bool) {
+	addr := activeAddr()
+	if addr == "" {
+		fmt.Fprintln(os.Stderr, "error: no server address configured in active context")
+		os.Exit(1)
+	}
+
+	path := "/internal/v1/registry/mirrors/prune"
+	if all {
+		path += "?all=1"
+	}
+	data, err := mgmtPost(addr, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resp struct {
+		Removed []registryMirror `json:"removed"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not parse prune response: %v\n", err)
+		os.Exit(1)
+	}
+	for _, m := range resp.Removed {
+		fmt.Printf("Removed %s (%s)\n", m.Name, m.Upstream)
+	}
+	fmt.Printf("Removed %d registry mirror(s)\n", len(resp.Removed))
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"time"
 )
 
 func cmdResources(args []string) {
@@ -18,6 +20,12 @@ func cmdResources(args []string) {
 		resourcesOrphaned()
 	case "cleanup":
 		resourcesCleanup()
+	case "history":
+		if len(args) != 2 {
+			resourcesUsage()
+			os.Exit(1)
+		}
+		resourcesHistory(args[1])
 	default:
 		resourcesUsage()
 		os.Exit(1)
@@ -30,7 +38,8 @@ func resourcesUsage() {
 Subcommands:
   list       List active cloud resources
   orphaned   List orphaned cloud resources
-  cleanup    Clean up orphaned resources`)
+  cleanup    Clean up orphaned resources
+  history    Show the cloud mutations for a resource, container or request ID`)
 }
 
 func resourcesList() {
@@ -126,3 +135,62 @@ func resourcesCleanup() {
 	cleaned, _ := resp["cleaned"].(float64)
 	fmt.Printf("Cleaned up %d resource(s)\n", int(cleaned))
 }
+
+// auditRecord is the subset of GET /internal/v1/resources/history the
+// CLI prints.
+type auditRecord struct {
+	Time        time.Time `json:"time"`
+	RequestID   string    `json:"requestId"`
+	Client      string    `json:"client"`
+	ContainerID string    `json:"containerId"`
+	Inferred    bool      `json:"inferred"`
+	Service     string    `json:"service"`
+	Operation   string    `json:"operation"`
+	Resource    string    `json:"resource"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error"`
+}
+
+func resourcesHistory(id string) {
+	addr := activeAddr()
+	if addr == "" {
+		fmt.Fprintln(os.Stderr, "error: no server address configured in active context")
+		os.Exit(1)
+	}
+
+	data, err := mgmtGet(addr, "/internal/v1/resources/history?id="+url.QueryEscape(id))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var records []auditRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("No cloud mutations recorded for %s\n", id)
+		return
+	}
+
+	fmt.Printf("%-20s  %-33s  %-15s  %-12s  %-32s  %-7s  %s\n", "TIME", "REQUEST", "CLIENT", "CONTAINER", "OPERATION", "OUTCOME", "RESOURCE")
+	for _, r := range records {
+		req := r.RequestID
+		if req == "" {
+			req = "-"
+		} else if r.Inferred {
+			req += "?" // matched from the requests in flight
+		}
+		cid := r.ContainerID
+		if len(cid) > 12 {
+			cid = cid[:12]
+		}
+		fmt.Printf("%-20s  %-33s  %-15s  %-12s  %-32s  %-7s  %s\n",
+			r.Time.Local().Format(time.RFC3339), req, r.Client, cid, r.Service+" "+r.Operation, r.Outcome, r.Resource)
+		if r.Error != "" {
+			fmt.Printf("%20s  error: %s\n", "", r.Error)
+		}
+	}
+}
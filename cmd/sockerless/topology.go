@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// topologyApplyTimeout bounds an apply round-trip. Each step shells a
+// `make` target on the admin host, so this is minutes, not the 5s the
+// management endpoints use.
+const topologyApplyTimeout = 15 * time.Minute
+
+// topologyResult is the subset of the admin plan/apply response the
+// CLI prints.
+type topologyResult struct {
+	Text           string   `json:"text"`
+	Done           []string `json:"done"`
+	Error          string   `json:"error"`
+	RolledBack     bool     `json:"rolled_back"`
+	RollbackErrors []string `json:"rollback_errors"`
+}
+
+func cmdTopology(args []string) {
+	if len(args) < 1 || (args[0] != "plan" && args[0] != "apply") {
+		topologyUsage()
+		os.Exit(1)
+	}
+	sub := args[0]
+	fs := flag.NewFlagSet("topology "+sub, flag.ExitOnError)
+	file := fs.String("f", "sockerless.yaml", "desired topology file")
+	admin := fs.String("admin", "http://localhost:9090", "sockerless-admin address")
+	token := fs.String("token", os.Getenv("SOCKERLESS_ADMIN_TOKEN"), "admin API token (plan needs viewer, apply needs admin)")
+	_ = fs.Parse(args[1:])
+
+	doc, err := os.ReadFile(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	res, err := postTopology(strings.TrimRight(*admin, "/")+"/api/v1/topology/"+sub, *token, doc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(res.Text)
+	if sub == "plan" {
+		return
+	}
+	for _, d := range res.Done {
+		fmt.Printf("  done: %s\n", d)
+	}
+	if res.Error == "" {
+		fmt.Println("Apply complete.")
+		return
+	}
+	fmt.Fprintf(os.Stderr, "error: apply failed: %s\n", res.Error)
+	if res.RolledBack {
+		fmt.Fprintln(os.Stderr, "Rolled back to the previous topology.")
+	} else {
+		fmt.Fprintln(os.Stderr, "Rollback incomplete:")
+		for _, e := range res.RollbackErrors {
+			fmt.Fprintf(os.Stderr, "  %s\n", e)
+		}
+	}
+	os.Exit(1)
+}
+
+func topologyUsage() {
+	fmt.Fprintln(os.Stderr, `Usage: sockerless topology <plan|apply> [-f sockerless.yaml] [--admin http://localhost:9090] [--token $SOCKERLESS_ADMIN_TOKEN]
+
+Subcommands:
+  plan   Show what apply would change, without changing anything
+  apply  Converge sockerless-admin's instances to the file; rolls back on failure`)
+}
+
+// postTopology sends a topology document to admin's plan or apply
+// endpoint. A failed apply answers 500 with a result body, which is
+// returned rather than treated as a transport error.
+func postTopology(url, token string, doc []byte) (topologyResult, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(doc))
+	if err != nil {
+		return topologyResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: topologyApplyTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return topologyResult{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return topologyResult{}, err
+	}
+	var res topologyResult
+	if err := json.Unmarshal(body, &res); err != nil || (resp.StatusCode != http.StatusOK && res.Text == "") {
+		return topologyResult{}, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return res, nil
+}
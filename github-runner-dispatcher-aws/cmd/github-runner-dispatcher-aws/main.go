@@ -13,7 +13,16 @@
 //	  - --token defaults to $GITHUB_TOKEN; missing → fail with guidance.
 //	  - Scopes verified at startup; missing → fail with `gh auth refresh`
 //	    instructions.
-//	  - Stateless 15-s polling loop; dedup via 5-min seen-set.
+//	  - Job sources: `workflow_job` webhooks on --webhook-addr (optional,
+//	    HMAC-verified with --webhook-secret) plus a reconciliation poll
+//	    every --reconcile-interval; dedup via 5-min seen-set re-seeded
+//	    from container labels before every poll.
+//	  - Warm pool: labels with `min_idle` / `max_idle` keep idle
+//	    pre-registered runners; `max_concurrency` caps live runners per
+//	    label (jobs over the cap wait for the next pass). Pool state is
+//	    re-read from container labels, so a restart resumes it.
+//	  - Metrics: --metrics-addr serves queue wait, hit rate and runner
+//	    counts on /metrics in the Prometheus text format.
 //	  - Failure handling: log + skip; the next poll retries.
 //	  - Logs to stdout only.
 package main
@@ -33,7 +42,9 @@ import (
 	"github.com/sockerless/github-runner-dispatcher-aws/internal/config"
 	"github.com/sockerless/github-runner-dispatcher-aws/internal/spawner"
 	"github.com/sockerless/github-runner-dispatcher-aws/pkg/poller"
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
 	"github.com/sockerless/github-runner-dispatcher-aws/pkg/scopes"
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/webhook"
 )
 
 func main() {
@@ -49,6 +60,10 @@ func run() error {
 	configPath := flag.String("config", "", "path to dispatcher config.toml; default ~/.sockerless/dispatcher/config.toml")
 	once := flag.Bool("once", false, "run a single poll cycle and exit (smoke / debug)")
 	cleanupOnly := flag.Bool("cleanup-only", false, "run a single GC sweep (containers + GitHub runners) and exit; no polling")
+	webhookAddr := flag.String("webhook-addr", "", "listen address for the workflow_job webhook ingress (e.g. :8088); empty = poll only")
+	webhookSecret := flag.String("webhook-secret", os.Getenv("GITHUB_WEBHOOK_SECRET"), "webhook secret; default $GITHUB_WEBHOOK_SECRET; required with --webhook-addr")
+	reconcileEvery := flag.Duration("reconcile-interval", 0, "reconciliation poll cadence; default 60s polling only, 2m with --webhook-addr")
+	metricsAddr := flag.String("metrics-addr", "", "listen address for the Prometheus /metrics endpoint (e.g. :9090); empty = off")
 	flag.Parse()
 
 	if *repo == "" || !strings.Contains(*repo, "/") {
@@ -57,6 +72,9 @@ func run() error {
 	if *token == "" {
 		return fmt.Errorf("github token is empty — set $GITHUB_TOKEN, run `gh auth token | …`, or pass --token=…")
 	}
+	if *webhookAddr != "" && *webhookSecret == "" {
+		return fmt.Errorf("--webhook-addr needs a secret — set $GITHUB_WEBHOOK_SECRET or pass --webhook-secret=… (the same value as the repo hook's secret)")
+	}
 
 	cfgPath := *configPath
 	if cfgPath == "" {
@@ -80,8 +98,8 @@ func run() error {
 	if err := scopes.Verify(ctx, http.DefaultClient, *token); err != nil {
 		return err
 	}
-	log.Printf("dispatcher ready: repo=%s labels=%d once=%v cleanup-only=%v",
-		*repo, len(cfg.Labels), *once, *cleanupOnly)
+	log.Printf("dispatcher ready: repo=%s labels=%d once=%v cleanup-only=%v webhook=%q",
+		*repo, len(cfg.Labels), *once, *cleanupOnly, *webhookAddr)
 
 	gh := poller.New(http.DefaultClient, *token, *repo)
 	loop := newDispatchLoop(gh, cfg)
@@ -106,9 +124,25 @@ func run() error {
 		return loop.Cleanup(ctx)
 	}
 
+	// Pool metrics (queue wait, hit rate, runner counts) for a
+	// Prometheus scrape.
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", loop.pool.Handler("github-aws"))
+		srv := &http.Server{Addr: *metricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("metrics: %v", err)
+				cancel()
+			}
+		}()
+		defer srv.Close()
+		log.Printf("metrics listening on %s/metrics", *metricsAddr)
+	}
+
 	// Graceful shutdown: when the signal context fires, drain
-	// in-flight runners + reap our GitHub runner registrations so
-	// next-run state matches reality.
+	// in-flight runners (warm pool included) + reap our GitHub runner
+	// registrations so next-run state matches reality.
 	defer func() {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -117,82 +151,159 @@ func run() error {
 		}
 	}()
 
-	ticker := time.NewTicker(gh.PollInterval())
+	// Webhook ingress: deliveries land on `jobs`, which this goroutine
+	// drains alongside the reconciliation ticker so dedup stays
+	// single-threaded. Buffered so a burst of deliveries is acknowledged
+	// inside GitHub's 10-s delivery timeout while spawns run.
+	jobs := make(chan poller.Job, 64)
+	interval := gh.PollInterval()
+	if *webhookAddr != "" {
+		h, err := webhook.New(*webhookSecret, *repo, jobs)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle(webhook.Path, h)
+		srv := &http.Server{Addr: *webhookAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("webhook ingress: %v", err)
+				cancel()
+			}
+		}()
+		defer srv.Close()
+		log.Printf("webhook ingress listening on %s%s", *webhookAddr, webhook.Path)
+		// Reconciliation only catches missed deliveries; keep it under
+		// the seen-set TTL so a re-seed is always fresher than expiry.
+		interval = 2 * time.Minute
+	}
+	if *reconcileEvery > 0 {
+		interval = *reconcileEvery
+	}
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	cleanupTicker := time.NewTicker(2 * time.Minute) // cheaper than a poll; reaps offline runners + dead containers
 	defer cleanupTicker.Stop()
+	// The pool ticker runs regardless of config; ReconcilePool is a
+	// no-op when no label has a pool or concurrency cap.
+	poolTicker := time.NewTicker(poolInterval)
+	defer poolTicker.Stop()
+	if err := loop.Step(ctx); err != nil {
+		log.Printf("poll error (continuing): %v", err)
+	}
+	loop.ReconcilePool(ctx)
 	for {
-		if err := loop.Step(ctx); err != nil {
-			log.Printf("poll error (continuing): %v", err)
-		}
 		select {
 		case <-ctx.Done():
 			return nil
+		case job := <-jobs:
+			loop.dispatch(ctx, job)
 		case <-cleanupTicker.C:
 			if err := loop.Cleanup(ctx); err != nil {
 				log.Printf("cleanup error (continuing): %v", err)
 			}
+			loop.LogPoolStats()
+		case <-poolTicker.C:
+			loop.ReconcilePool(ctx)
 		case <-ticker.C:
+			if err := loop.Step(ctx); err != nil {
+				log.Printf("poll error (continuing): %v", err)
+			}
 		}
 	}
 }
 
-// dispatchLoop pulls one batch of queued jobs, spawns one runner
-// per job, and marks each spawn as seen on success.
+// poolInterval is how often ReconcilePool tops up and trims the warm
+// pools. Shorter than a poll: a hit drains a warm runner immediately.
+const poolInterval = 30 * time.Second
+
+// dispatchLoop spawns one runner per queued job — from a poll batch
+// (Step) or a webhook delivery (dispatch) — and marks each spawn as
+// seen on success. Jobs a warm runner can take are marked without a
+// spawn.
 type dispatchLoop struct {
-	gh  *poller.Client
-	cfg config.Config
+	gh      *poller.Client
+	cfg     config.Config
+	pool    *pool.Pool
+	warmSeq int // disambiguates warm runner names spawned in the same second
 }
 
 func newDispatchLoop(gh *poller.Client, cfg config.Config) *dispatchLoop {
-	return &dispatchLoop{gh: gh, cfg: cfg}
+	p := pool.New()
+	for _, l := range cfg.Labels {
+		p.Configure(l.Name, l.Policy)
+	}
+	return &dispatchLoop{gh: gh, cfg: cfg, pool: p}
 }
 
+// Step is one reconciliation pass: re-seed the seen-set from live
+// containers (so a job whose runner outlived the TTL isn't respawned),
+// poll for queued jobs, and dispatch each one.
 func (d *dispatchLoop) Step(ctx context.Context) error {
+	d.seedSeen(ctx, false)
 	jobs, err := d.gh.PollOnce(ctx)
 	if err != nil {
 		return err
 	}
-	if len(jobs) == 0 {
-		return nil
-	}
 	for _, job := range jobs {
-		label := pickKnownLabel(job.Labels, d.cfg)
-		if label == nil {
-			log.Printf("skip job %d (%s): no matching dispatcher label in %v", job.JobID, job.Name, job.Labels)
-			d.gh.Mark(job.JobID) // dedup so the same skip doesn't repeat every cycle
-			continue
-		}
-		if err := spawner.Liveness(ctx, label.DockerHost); err != nil {
-			log.Printf("skip job %d (%s): docker daemon at %s unreachable: %v", job.JobID, label.Name, label.DockerHost, err)
-			continue // do NOT mark — retry next cycle once the daemon is back
-		}
-		regToken, err := d.gh.MintRegistrationToken(ctx)
-		if err != nil {
-			log.Printf("skip job %d: mint registration token: %v", job.JobID, err)
-			continue
-		}
-		runnerName := fmt.Sprintf("dispatcher-%d-%d", job.JobID, time.Now().Unix())
-		cid, err := spawner.Spawn(ctx, spawner.Request{
-			DockerHost: label.DockerHost,
-			Image:      label.Image,
-			RegToken:   regToken,
-			Repo:       job.Repo,
-			RunnerName: runnerName,
-			Labels:     job.Labels,
-			JobID:      job.JobID,
-		})
-		if err != nil {
-			log.Printf("skip job %d: spawn: %v", job.JobID, err)
-			continue
-		}
-		log.Printf("spawned runner for job %d (%s) on %s: container=%s name=%s url=%s",
-			job.JobID, label.Name, label.DockerHost, shortID(cid), runnerName, job.JobURL)
-		d.gh.Mark(job.JobID)
+		d.dispatch(ctx, job)
 	}
 	return nil
 }
 
+// dispatch spawns a runner for one queued job. Failures are logged and
+// leave the job unmarked so the next reconciliation pass retries it.
+func (d *dispatchLoop) dispatch(ctx context.Context, job poller.Job) {
+	if d.gh.IsMarked(job.JobID) {
+		log.Printf("skip job %d (%s): already dispatched", job.JobID, job.Name)
+		return
+	}
+	label := pickKnownLabel(job.Labels, d.cfg)
+	if label == nil {
+		log.Printf("skip job %d (%s): no matching dispatcher label in %v", job.JobID, job.Name, job.Labels)
+		d.gh.Mark(job.JobID) // dedup so the same skip doesn't repeat every cycle
+		return
+	}
+	if err := spawner.Liveness(ctx, label.DockerHost); err != nil {
+		log.Printf("skip job %d (%s): docker daemon at %s unreachable: %v", job.JobID, label.Name, label.DockerHost, err)
+		return // do NOT mark — retry next cycle once the daemon is back
+	}
+	switch d.pool.Admit(label.Name, job.JobID, job.Labels, job.QueuedAt) {
+	case pool.Hit:
+		log.Printf("job %d (%s): warm-pool hit, an idle runner will take it", job.JobID, label.Name)
+		d.gh.Mark(job.JobID)
+		return
+	case pool.Defer:
+		log.Printf("defer job %d (%s): max_concurrency %d reached", job.JobID, label.Name, label.MaxConcurrency)
+		return // do NOT mark — retry once a runner finishes
+	}
+	regToken, err := d.gh.MintRegistrationToken(ctx)
+	if err != nil {
+		log.Printf("skip job %d: mint registration token: %v", job.JobID, err)
+		return
+	}
+	runnerName := fmt.Sprintf("dispatcher-%d-%d", job.JobID, time.Now().Unix())
+	cid, err := spawner.Spawn(ctx, spawner.Request{
+		DockerHost: label.DockerHost,
+		Image:      label.Image,
+		RegToken:   regToken,
+		Repo:       job.Repo,
+		RunnerName: runnerName,
+		Labels:     job.Labels,
+		JobID:      job.JobID,
+		Pool:       label.Name,
+	})
+	if err != nil {
+		log.Printf("skip job %d: spawn: %v", job.JobID, err)
+		return
+	}
+	d.pool.Spawned(label.Name, job.JobID, job.QueuedAt)
+	log.Printf("spawned runner for job %d (%s) on %s: container=%s name=%s url=%s",
+		job.JobID, label.Name, label.DockerHost, shortID(cid), runnerName, job.JobURL)
+	d.gh.Mark(job.JobID)
+}
+
 func pickKnownLabel(labels []string, cfg config.Config) *config.Label {
 	for _, l := range labels {
 		if got := cfg.LookupLabel(l); got != nil {
@@ -215,11 +326,19 @@ func shortID(s string) string {
 // daemon being down at startup is normal (Liveness will skip it on
 // the next poll), so we log and move on.
 func (d *dispatchLoop) RecoverState(ctx context.Context) {
+	d.seedSeen(ctx, true)
+}
+
+// seedSeen adds the job ID of every running / created dispatcher
+// container to the seen-set. Step calls it quietly before each poll.
+func (d *dispatchLoop) seedSeen(ctx context.Context, verbose bool) {
 	now := d.gh.Now()
 	for _, label := range d.cfg.Labels {
 		managed, err := spawner.ListManaged(ctx, label.DockerHost)
 		if err != nil {
-			log.Printf("recover: list managed on %s failed: %v", label.DockerHost, err)
+			if verbose {
+				log.Printf("recover: list managed on %s failed: %v", label.DockerHost, err)
+			}
 			continue
 		}
 		for _, m := range managed {
@@ -228,8 +347,10 @@ func (d *dispatchLoop) RecoverState(ctx context.Context) {
 			}
 			if m.State == "running" || m.State == "created" {
 				d.gh.Seen.Add(m.JobID, now)
-				log.Printf("recover: seen-set restored for job %d (container %s, state=%s, host=%s)",
-					m.JobID, shortID(m.ContainerID), m.State, label.DockerHost)
+				if verbose {
+					log.Printf("recover: seen-set restored for job %d (container %s, state=%s, host=%s)",
+						m.JobID, shortID(m.ContainerID), m.State, label.DockerHost)
+				}
 			}
 		}
 	}
@@ -333,3 +454,122 @@ func (d *dispatchLoop) Shutdown(ctx context.Context) error {
 	}
 	return nil
 }
+
+// ReconcilePool brings every label with a pool or concurrency cap in
+// line with its policy. Members are re-read from container labels and
+// joined with GitHub's runner list for busy state, so the pool
+// survives a dispatcher restart. Idle warm runners past idle_ttl are
+// retired, and new ones spawned up to the planned count. Errors are
+// logged; the next pass retries.
+func (d *dispatchLoop) ReconcilePool(ctx context.Context) {
+	var active []config.Label
+	for _, l := range d.cfg.Labels {
+		if l.Policy.Active() {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+	runners, err := d.gh.ListRunners(ctx)
+	if err != nil {
+		log.Printf("pool: list github runners: %v", err)
+		return
+	}
+	byName := make(map[string]poller.Runner, len(runners))
+	for _, r := range runners {
+		byName[r.Name] = r
+	}
+	for i := range active {
+		label := &active[i]
+		managed, err := spawner.ListManaged(ctx, label.DockerHost)
+		if err != nil {
+			log.Printf("pool: list managed on %s: %v", label.DockerHost, err)
+			continue
+		}
+		var members []pool.Member
+		containers := map[string]spawner.Managed{}
+		for _, m := range managed {
+			if !m.Live() || m.Pool != label.Name {
+				continue
+			}
+			containers[m.RunnerName] = m
+			members = append(members, pool.Member{
+				Name:      m.RunnerName,
+				Warm:      m.Warm,
+				SpawnedAt: m.SpawnedAt,
+				Busy:      byName[m.RunnerName].Busy,
+			})
+		}
+		d.pool.Observe(label.Name, members)
+		plan := d.pool.Plan(label.Name)
+		for _, name := range plan.Retire {
+			d.retire(ctx, label, containers[name], byName)
+		}
+		for n := 0; n < plan.Spawn; n++ {
+			if err := d.spawnWarm(ctx, label); err != nil {
+				log.Printf("pool: spawn warm runner for %s: %v", label.Name, err)
+				break
+			}
+		}
+	}
+}
+
+// retire removes one idle warm runner. The GitHub registration goes
+// first: GitHub refuses to delete a runner that has just taken a job,
+// which leaves the container running it.
+func (d *dispatchLoop) retire(ctx context.Context, label *config.Label, m spawner.Managed, byName map[string]poller.Runner) {
+	if r, ok := byName[m.RunnerName]; ok {
+		if err := d.gh.DeleteRunner(ctx, r.ID); err != nil {
+			log.Printf("pool: keep %s: delete runner: %v", m.RunnerName, err)
+			return
+		}
+	}
+	if err := spawner.StopAndRemove(ctx, label.DockerHost, m.ContainerID); err != nil {
+		log.Printf("pool: stop %s (%s): %v", m.RunnerName, shortID(m.ContainerID), err)
+		return
+	}
+	log.Printf("pool: retired idle warm runner %s for %s", m.RunnerName, label.Name)
+}
+
+// spawnWarm starts one warm runner for label: registered with only the
+// pool's label and no job ID, so GitHub hands it the next matching job.
+func (d *dispatchLoop) spawnWarm(ctx context.Context, label *config.Label) error {
+	if err := spawner.Liveness(ctx, label.DockerHost); err != nil {
+		return err
+	}
+	regToken, err := d.gh.MintRegistrationToken(ctx)
+	if err != nil {
+		return fmt.Errorf("mint registration token: %w", err)
+	}
+	d.warmSeq++
+	runnerName := fmt.Sprintf("dispatcher-warm-%d-%d", time.Now().Unix(), d.warmSeq)
+	cid, err := spawner.Spawn(ctx, spawner.Request{
+		DockerHost:  label.DockerHost,
+		Image:       label.Image,
+		RegToken:    regToken,
+		Repo:        d.gh.Repo,
+		RunnerName:  runnerName,
+		Labels:      []string{label.Name},
+		IdleSeconds: int(label.Policy.RunnerIdleTimeout().Seconds()),
+		Pool:        label.Name,
+		Warm:        true,
+	})
+	if err != nil {
+		return err
+	}
+	d.pool.WarmSpawned(label.Name)
+	log.Printf("pool: spawned warm runner for %s on %s: container=%s name=%s",
+		label.Name, label.DockerHost, shortID(cid), runnerName)
+	return nil
+}
+
+// LogPoolStats logs queue wait and hit rate for every label with a
+// pool or concurrency cap.
+func (d *dispatchLoop) LogPoolStats() {
+	for _, st := range d.pool.Stats() {
+		if d.pool.Policy(st.Label).Active() {
+			log.Printf("pool: %s", st)
+		}
+	}
+}
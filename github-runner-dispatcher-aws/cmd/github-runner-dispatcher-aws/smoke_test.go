@@ -126,3 +126,35 @@ func TestSmokeDispatchLoopMatchesLabelButLivenessFails(t *testing.T) {
 		t.Fatalf("liveness-fail path must not mark job 8001 as seen")
 	}
 }
+
+// TestSmokeReconcilePoolKeepsRunnersWhenDaemonUnreachable: a pooled
+// label whose docker_host can't be listed is skipped for the pass —
+// no warm runner is retired (its container may be running a job) and
+// none is spawned.
+func TestSmokeReconcilePoolKeepsRunnersWhenDaemonUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/actions/runners"):
+			fmt.Fprint(w, `{"runners":[
+				{"id":21, "name":"dispatcher-warm-1700000000-1", "status":"online"}
+			]}`)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	gh := poller.New(srv.Client(), "tok", "owner/repo")
+	gh.APIBase = srv.URL
+	label := config.Label{
+		Name:       "sockerless-test",
+		DockerHost: "tcp://127.0.0.1:1",
+		Image:      "test/image:latest",
+	}
+	label.MinIdle = 1
+	loop := newDispatchLoop(gh, config.Config{Labels: []config.Label{label}})
+	loop.ReconcilePool(context.Background())
+	if st := loop.pool.Stats(); len(st) != 1 || st[0].Live != 0 {
+		t.Fatalf("stats after a skipped pass = %+v", st)
+	}
+}
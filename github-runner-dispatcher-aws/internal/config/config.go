@@ -10,9 +10,14 @@
 //	name        = "sockerless-lambda"
 //	docker_host = "tcp://localhost:3376"
 //	image       = "729079515331.dkr.ecr.eu-west-1.amazonaws.com/sockerless-live:runner-amd64"
+//	min_idle    = 2
+//	max_idle    = 6
+//	max_concurrency = 20
 //
 // `name` is matched against the `runs-on:` label on each queued
-// workflow_job. CLI flags override individual entries; config file is
+// workflow_job. The optional warm-pool keys (`min_idle`, `max_idle`,
+// `max_concurrency`, `idle_ttl`, `cold_start`) are documented on
+// pool.Policy. CLI flags override individual entries; config file is
 // optional (empty config means "no labels mapped — every job is
 // skipped with a warning").
 package config
@@ -23,6 +28,8 @@ import (
 	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
 )
 
 // Label maps a runs-on label to a docker daemon + runner image.
@@ -30,6 +37,7 @@ type Label struct {
 	Name       string `toml:"name"`
 	DockerHost string `toml:"docker_host"`
 	Image      string `toml:"image"`
+	pool.Policy
 }
 
 // Config is the on-disk dispatcher config.
@@ -69,6 +77,9 @@ func Load(path string) (Config, error) {
 		if l.Image == "" {
 			return Config{}, fmt.Errorf("label %q: image is required", l.Name)
 		}
+		if err := l.Policy.Validate(); err != nil {
+			return Config{}, fmt.Errorf("label %q: %w", l.Name, err)
+		}
 	}
 	return cfg, nil
 }
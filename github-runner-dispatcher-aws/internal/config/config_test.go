@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadValid(t *testing.T) {
@@ -38,6 +39,33 @@ image = "ecr.example/sockerless-live:runner-amd64"
 	}
 }
 
+func TestLoadPoolPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	body := `
+[[label]]
+name = "sockerless-ecs"
+docker_host = "tcp://localhost:3375"
+image = "ecr.example/sockerless-live:runner-amd64"
+min_idle = 2
+max_idle = 6
+max_concurrency = 20
+idle_ttl = "5m"
+cold_start = "45s"
+`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	p := cfg.Labels[0].Policy
+	if p.MinIdle != 2 || p.MaxIdle != 6 || p.MaxConcurrency != 20 ||
+		p.IdleTTL != 5*time.Minute || p.ColdStart != 45*time.Second {
+		t.Fatalf("policy = %+v", p)
+	}
+}
+
 func TestLoadMissingFile(t *testing.T) {
 	cfg, err := Load(filepath.Join(t.TempDir(), "no-such-file.toml"))
 	if err != nil {
@@ -59,6 +87,12 @@ image = "img"`,
 		"missing image": `[[label]]
 name = "x"
 docker_host = "tcp://x"`,
+		"max_idle below min_idle": `[[label]]
+name = "x"
+docker_host = "tcp://x"
+image = "img"
+min_idle = 3
+max_idle = 1`,
 	}
 	for name, body := range cases {
 		t.Run(name, func(t *testing.T) {
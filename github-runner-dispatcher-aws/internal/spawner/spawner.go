@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Labels stamped on every spawned container so a restarted dispatcher
@@ -33,6 +35,12 @@ const (
 	LabelRunnerName = "sockerless.dispatcher.runner_name"
 	LabelManagedBy  = "sockerless.dispatcher.managed_by"
 	LabelManagedVal = "github-runner-dispatcher"
+	// Warm-pool labels: the config label a runner serves (cold spawns
+	// carry it too, for max_concurrency), whether it was spawned ahead
+	// of demand, and when — the idle_ttl clock after a restart.
+	LabelPool      = "sockerless.dispatcher.pool"
+	LabelWarm      = "sockerless.dispatcher.warm"
+	LabelSpawnedAt = "sockerless.dispatcher.spawned_at"
 )
 
 // Request is one spawn directive.
@@ -43,8 +51,10 @@ type Request struct {
 	Repo        string // owner/repo for runner registration
 	RunnerName  string // unique name; logs / Actions UI uses it
 	Labels      []string
-	IdleSeconds int   // seconds to wait for the runner to register; 0 → 60 s default
-	JobID       int64 // GitHub workflow_job ID — written to LabelJobID for restart recovery
+	IdleSeconds int    // seconds to wait for the runner to register; 0 → 60 s default
+	JobID       int64  // GitHub workflow_job ID — written to LabelJobID for restart recovery; 0 for warm runners
+	Pool        string // config label name — written to LabelPool
+	Warm        bool   // warm-pool runner, spawned without a job
 }
 
 // Spawn shells out to `docker run -d`. Returns the container ID on
@@ -76,6 +86,9 @@ func Spawn(ctx context.Context, req Request) (string, error) {
 		"--label", LabelManagedBy + "=" + LabelManagedVal,
 		"--label", fmt.Sprintf("%s=%d", LabelJobID, req.JobID),
 		"--label", LabelRunnerName + "=" + req.RunnerName,
+		"--label", LabelPool + "=" + req.Pool,
+		"--label", LabelWarm + "=" + strconv.FormatBool(req.Warm),
+		"--label", fmt.Sprintf("%s=%d", LabelSpawnedAt, time.Now().Unix()),
 		"-e", "RUNNER_REG_TOKEN=" + req.RegToken,
 		"-e", "RUNNER_REPO=" + req.Repo,
 		"-e", "RUNNER_NAME=" + req.RunnerName,
@@ -118,6 +131,15 @@ type Managed struct {
 	RunnerName  string
 	State       string // "running", "exited", "created", …
 	DockerHost  string
+	Pool        string
+	Warm        bool
+	SpawnedAt   time.Time
+}
+
+// Live reports whether the container still holds (or is about to
+// hold) a runner.
+func (m Managed) Live() bool {
+	return m.State == "running" || m.State == "created"
 }
 
 // ListManaged returns every container on the daemon at DockerHost that
@@ -128,7 +150,8 @@ func ListManaged(ctx context.Context, dockerHost string) ([]Managed, error) {
 	args := []string{
 		"ps", "-a",
 		"--filter", "label=" + LabelManagedBy + "=" + LabelManagedVal,
-		"--format", "{{.ID}}|{{.State}}|{{.Label \"" + LabelJobID + "\"}}|{{.Label \"" + LabelRunnerName + "\"}}",
+		"--format", "{{.ID}}|{{.State}}|{{.Label \"" + LabelJobID + "\"}}|{{.Label \"" + LabelRunnerName + "\"}}|" +
+			"{{.Label \"" + LabelPool + "\"}}|{{.Label \"" + LabelWarm + "\"}}|{{.Label \"" + LabelSpawnedAt + "\"}}",
 	}
 	cmd := exec.CommandContext(ctx, "docker", args...)
 	cmd.Env = append(os.Environ(), "DOCKER_HOST="+dockerHost)
@@ -136,28 +159,44 @@ func ListManaged(ctx context.Context, dockerHost string) ([]Managed, error) {
 	if err != nil {
 		return nil, fmt.Errorf("docker ps on %s: %v: %s", dockerHost, err, strings.TrimSpace(string(out)))
 	}
+	return parsePS(string(out), dockerHost), nil
+}
+
+// parsePS parses ListManaged's `docker ps` format. Containers from
+// dispatchers predating the pool labels have the trailing fields
+// empty: no pool, cold, zero spawn time.
+func parsePS(out, dockerHost string) []Managed {
 	var managed []Managed
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
 		if line == "" {
 			continue
 		}
-		parts := strings.SplitN(line, "|", 4)
+		parts := strings.SplitN(line, "|", 7)
 		if len(parts) < 4 {
 			continue
 		}
+		for len(parts) < 7 {
+			parts = append(parts, "")
+		}
 		var jobID int64
 		if v := strings.TrimSpace(parts[2]); v != "" {
 			fmt.Sscanf(v, "%d", &jobID)
 		}
-		managed = append(managed, Managed{
+		m := Managed{
 			ContainerID: parts[0],
 			State:       parts[1],
 			JobID:       jobID,
 			RunnerName:  parts[3],
 			DockerHost:  dockerHost,
-		})
+			Pool:        parts[4],
+			Warm:        parts[5] == "true",
+		}
+		if secs, err := strconv.ParseInt(strings.TrimSpace(parts[6]), 10, 64); err == nil && secs > 0 {
+			m.SpawnedAt = time.Unix(secs, 0)
+		}
+		managed = append(managed, m)
 	}
-	return managed, nil
+	return managed
 }
 
 // StopAndRemove stops a container (timeout 10 s) and removes it.
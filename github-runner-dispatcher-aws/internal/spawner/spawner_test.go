@@ -35,3 +35,21 @@ func TestLivenessUnreachable(t *testing.T) {
 		t.Errorf("error should mention docker info: %v", err)
 	}
 }
+
+func TestParsePSPoolLabels(t *testing.T) {
+	out := "abc|running|0|dispatcher-warm-1700000000-1|sockerless-ecs|true|1700000000\n" +
+		"def|exited|7001|dispatcher-7001-1\n"
+	got := parsePS(out, "tcp://h")
+	if len(got) != 2 {
+		t.Fatalf("parsed %d containers, want 2: %+v", len(got), got)
+	}
+	w := got[0]
+	if !w.Warm || w.Pool != "sockerless-ecs" || w.SpawnedAt.Unix() != 1700000000 || !w.Live() {
+		t.Errorf("warm container = %+v", w)
+	}
+	// Containers spawned before the pool labels existed parse as cold.
+	c := got[1]
+	if c.Warm || c.Pool != "" || !c.SpawnedAt.IsZero() || c.JobID != 7001 || c.Live() {
+		t.Errorf("legacy container = %+v", c)
+	}
+}
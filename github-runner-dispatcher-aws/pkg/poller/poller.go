@@ -3,13 +3,14 @@
 //
 // API path: `GET /repos/{owner}/{repo}/actions/runs?status=queued`
 // followed by `GET /repos/{owner}/{repo}/actions/runs/{run_id}/jobs`
-// per run. The two-call shape mirrors the production webhook payload —
-// 110b switches to the webhook ingress without the consumers having
-// to change.
+// per run. With the webhook ingress (pkg/webhook) enabled, the
+// dispatchers run PollOnce as a slow reconciliation pass that catches
+// missed deliveries; without it, PollOnce is the only job source.
 //
-// Stateless: dedup is a 5-min TTL `seen` set keyed by job ID. A
-// caller restart loses the set and may double-spawn; the runner image
-// entrypoint's 60-s idle timeout cleans up duplicates.
+// Dedup is a 5-min TTL `seen` set keyed by job ID, shared by both job
+// sources. The dispatchers re-seed it from cloud-side labels on start
+// and before every reconciliation pass, so a restart does not
+// double-spawn a job whose runner is still alive.
 package poller
 
 import (
@@ -29,13 +30,13 @@ import (
 
 // Job is the dispatcher's view of a queued workflow_job.
 type Job struct {
-	JobID    int64    // GitHub workflow_job ID — used as dedup key
-	RunID    int64    // parent workflow run
-	Name     string   // job display name (logging only)
-	Labels   []string // requested runs-on labels
-	Repo     string   // owner/repo
-	JobURL   string   // GitHub URL to the job (logging only)
-	QueuedAt time.Time
+	JobID    int64     // GitHub workflow_job ID — used as dedup key
+	RunID    int64     // parent workflow run
+	Name     string    // job display name (logging only)
+	Labels   []string  // requested runs-on labels
+	Repo     string    // owner/repo
+	JobURL   string    // GitHub URL to the job (logging only)
+	QueuedAt time.Time // when GitHub queued the job (created_at); poll / delivery time if absent
 }
 
 // Client is a thin wrapper over net/http with a token + API base URL.
@@ -136,7 +137,7 @@ func (c *Client) PollOnce(ctx context.Context) ([]Job, error) {
 				Labels:   j.Labels,
 				Repo:     c.Repo,
 				JobURL:   j.HTMLURL,
-				QueuedAt: c.Now(),
+				QueuedAt: queuedAt(j.CreatedAt, c.Now()),
 			})
 		}
 		// If the run has zero queued jobs (all done or in-flight) OR
@@ -182,6 +183,12 @@ func (c *Client) Mark(jobID int64) {
 	c.Seen.Add(jobID, c.Now())
 }
 
+// IsMarked reports whether `jobID` is in the seen-set. The dispatch
+// loop checks it for webhook-delivered jobs, which skip PollOnce.
+func (c *Client) IsMarked(jobID int64) bool {
+	return c.Seen.Has(jobID)
+}
+
 // PollInterval is the cadence the dispatcher's main loop should call
 // PollOnce at. Mirrors the spec (15 s).
 func (c *Client) PollInterval() time.Duration { return c.pollWait }
@@ -284,6 +291,17 @@ type apiJob struct {
 	Status  string   `json:"status"`
 	Labels  []string `json:"labels"`
 	HTMLURL string   `json:"html_url"`
+	// CreatedAt is when GitHub queued the job; QueuedAt falls back to
+	// the poll time when it's missing.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// queuedAt returns the job's GitHub queue time, or now.
+func queuedAt(created, now time.Time) time.Time {
+	if created.IsZero() {
+		return now
+	}
+	return created
 }
 
 // seenSet is a TTL-bounded dedup set. Entries older than `ttl` are
@@ -0,0 +1,143 @@
+package pool
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prometheus exposition for a dispatcher's GET /metrics. The
+// dispatchers are standalone modules, so this is a small writer of its
+// own in the same shape as bleephub's: every series carries
+// dispatcher="<name>" and pool="<label>", and names share the
+// sockerless_ prefix of the backends' /metrics.
+
+const promContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// queueWaitBuckets are the queue-wait histogram's upper bounds in
+// seconds: a hit is served within a poll or webhook delivery, a cold
+// spawn waits for the next poll when deferred.
+var queueWaitBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+
+// waitHistogram holds per-bucket (non-cumulative) counts of queue
+// waits; the last count is +Inf.
+type waitHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *waitHistogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(queueWaitBuckets)+1)
+	}
+	v := d.Seconds()
+	h.counts[sort.SearchFloat64s(queueWaitBuckets, v)]++
+	h.sum += v
+	h.count++
+}
+
+// Handler serves every label's pool metrics in the Prometheus text
+// format. dispatcher names the binary in each series
+// (github-aws, github-gcp, github-azure, gitlab).
+func (p *Pool) Handler(dispatcher string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", promContentType)
+		_ = p.WritePrometheus(w, dispatcher)
+	})
+}
+
+// WritePrometheus writes every label's pool metrics:
+//
+//   - sockerless_dispatcher_jobs_total{decision} — jobs admitted, by
+//     hit, cold or defer (counter; hit rate is hit / (hit + cold))
+//   - sockerless_dispatcher_pool_hit_ratio — hits over hits + cold
+//     spawns since the dispatcher started (gauge)
+//   - sockerless_dispatcher_queue_wait_seconds{decision} — time from
+//     the job being queued to a hit or a cold spawn (histogram)
+//   - sockerless_dispatcher_runners{state} — live, idle and busy
+//     runners at the latest pass (gauge)
+//   - sockerless_dispatcher_pool_desired_idle — the idle target (gauge)
+func (p *Pool) WritePrometheus(w io.Writer, dispatcher string) error {
+	stats := p.Stats()
+	p.mu.Lock()
+	hists := make(map[string][2]waitHistogram, len(p.labels))
+	for label, s := range p.labels {
+		hists[label] = [2]waitHistogram{s.hitWait.clone(), s.coldWait.clone()}
+	}
+	p.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	m := promWriter{w: bw, dispatcher: dispatcher}
+
+	m.header("sockerless_dispatcher_jobs_total", "Queued jobs admitted, by decision (hit, cold, defer).", "counter")
+	for _, st := range stats {
+		m.sample("sockerless_dispatcher_jobs_total", float64(st.Hits), st.Label, "decision", "hit")
+		m.sample("sockerless_dispatcher_jobs_total", float64(st.Misses), st.Label, "decision", "cold")
+		m.sample("sockerless_dispatcher_jobs_total", float64(st.Deferred), st.Label, "decision", "defer")
+	}
+	m.header("sockerless_dispatcher_pool_hit_ratio", "Jobs served by a warm runner over jobs served, since start.", "gauge")
+	for _, st := range stats {
+		m.sample("sockerless_dispatcher_pool_hit_ratio", st.HitRate(), st.Label)
+	}
+	m.header("sockerless_dispatcher_queue_wait_seconds", "Time from a job being queued to a warm-pool hit or a cold spawn.", "histogram")
+	for _, st := range stats {
+		h := hists[st.Label]
+		m.histogram("sockerless_dispatcher_queue_wait_seconds", h[0], st.Label, "decision", "hit")
+		m.histogram("sockerless_dispatcher_queue_wait_seconds", h[1], st.Label, "decision", "cold")
+	}
+	m.header("sockerless_dispatcher_runners", "Runners of the label at the latest pass, by state (live, idle, busy).", "gauge")
+	for _, st := range stats {
+		m.sample("sockerless_dispatcher_runners", float64(st.Live), st.Label, "state", "live")
+		m.sample("sockerless_dispatcher_runners", float64(st.Idle), st.Label, "state", "idle")
+		m.sample("sockerless_dispatcher_runners", float64(st.Busy), st.Label, "state", "busy")
+	}
+	m.header("sockerless_dispatcher_pool_desired_idle", "Idle warm runners the pool is sizing towards.", "gauge")
+	for _, st := range stats {
+		m.sample("sockerless_dispatcher_pool_desired_idle", float64(st.Desired), st.Label)
+	}
+	return bw.Flush()
+}
+
+func (h waitHistogram) clone() waitHistogram {
+	h.counts = append([]uint64(nil), h.counts...)
+	return h
+}
+
+type promWriter struct {
+	w          *bufio.Writer
+	dispatcher string
+}
+
+func (p promWriter) header(name, help, typ string) {
+	p.w.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " " + typ + "\n")
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// sample writes name{dispatcher=…,pool=…,labels...} v. labels are
+// name, value pairs.
+func (p promWriter) sample(name string, v float64, pool string, labels ...string) {
+	p.w.WriteString(name + `{dispatcher="` + promLabelEscaper.Replace(p.dispatcher) + `",pool="` + promLabelEscaper.Replace(pool) + `"`)
+	for i := 0; i+1 < len(labels); i += 2 {
+		p.w.WriteString("," + labels[i] + `="` + promLabelEscaper.Replace(labels[i+1]) + `"`)
+	}
+	p.w.WriteString("} " + strconv.FormatFloat(v, 'g', -1, 64) + "\n")
+}
+
+func (p promWriter) histogram(name string, h waitHistogram, pool string, labels ...string) {
+	var cum uint64
+	for i, le := range queueWaitBuckets {
+		if h.counts != nil {
+			cum += h.counts[i]
+		}
+		p.sample(name+"_bucket", float64(cum), pool, append(labels[:len(labels):len(labels)], "le", strconv.FormatFloat(le, 'g', -1, 64))...)
+	}
+	p.sample(name+"_bucket", float64(h.count), pool, append(labels[:len(labels):len(labels)], "le", "+Inf")...)
+	p.sample(name+"_sum", h.sum, pool, labels...)
+	p.sample(name+"_count", float64(h.count), pool, labels...)
+}
@@ -0,0 +1,58 @@
+package pool
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerServesHitRateAndQueueWait(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 1})
+	p.Observe("ecs", []Member{warm("w1", c.t)})
+
+	p.Admit("ecs", 1, []string{"ecs"}, c.t.Add(-2*time.Second)) // hit, waited 2s
+	p.Admit("ecs", 2, []string{"ecs"}, c.t.Add(-40*time.Second))
+	p.Spawned("ecs", 2, c.t.Add(-40*time.Second)) // cold, waited 40s
+
+	rec := httptest.NewRecorder()
+	p.Handler("github-aws").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE sockerless_dispatcher_jobs_total counter",
+		`sockerless_dispatcher_jobs_total{dispatcher="github-aws",pool="ecs",decision="hit"} 1`,
+		`sockerless_dispatcher_jobs_total{dispatcher="github-aws",pool="ecs",decision="cold"} 1`,
+		`sockerless_dispatcher_pool_hit_ratio{dispatcher="github-aws",pool="ecs"} 0.5`,
+		"# TYPE sockerless_dispatcher_queue_wait_seconds histogram",
+		`sockerless_dispatcher_queue_wait_seconds_bucket{dispatcher="github-aws",pool="ecs",decision="hit",le="5"} 1`,
+		`sockerless_dispatcher_queue_wait_seconds_bucket{dispatcher="github-aws",pool="ecs",decision="cold",le="30"} 0`,
+		`sockerless_dispatcher_queue_wait_seconds_bucket{dispatcher="github-aws",pool="ecs",decision="cold",le="60"} 1`,
+		`sockerless_dispatcher_queue_wait_seconds_sum{dispatcher="github-aws",pool="ecs",decision="cold"} 40`,
+		`sockerless_dispatcher_queue_wait_seconds_count{dispatcher="github-aws",pool="ecs",decision="hit"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestCoversIsPluggable(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Covers = func(label string, jobLabels []string) bool { return len(jobLabels) == 1 && jobLabels[0] == label }
+	p.Configure("ecs", Policy{MinIdle: 1})
+	p.Observe("ecs", []Member{warm("w1", c.t)})
+
+	if got := p.Admit("ecs", 1, []string{"ecs", "self-hosted"}, c.t); got != Cold {
+		t.Fatalf("extra label under a strict rule = %s, want cold", got)
+	}
+	if got := p.Admit("ecs", 2, []string{"ecs"}, c.t); got != Hit {
+		t.Fatalf("exact label = %s, want hit", got)
+	}
+}
@@ -0,0 +1,519 @@
+// Package pool is the dispatchers' warm-runner pool and concurrency
+// planner. It is backend-agnostic: each dispatcher lists its runners
+// from cloud-side labels (docker containers, Cloud Run Jobs, ACA Jobs),
+// hands them to Observe, and carries out the Plan — spawning warm
+// runners or retiring idle ones — with its own spawner.
+//
+// A warm runner is registered with GitHub before any job exists for
+// it, labelled with only the pool's runs-on label. GitHub hands the
+// next matching queued job to whichever idle runner it likes, so the
+// pool doesn't pick a runner per job; it predicts. Admit counts a job
+// as a pool hit when an idle warm runner can take it (and the
+// dispatcher skips the cold spawn), as a miss when it needs a cold
+// runner, and defers it when the label is at max_concurrency so the
+// next reconciliation pass retries it.
+//
+// Sizing, per label, on every Plan:
+//
+//	desired idle = clamp(max(min_idle, rate × cold_start + backlog), 0, max_idle)
+//
+// where rate is the job-arrival rate over the last ScaleWindow and
+// backlog is the number of jobs still waiting on a cold runner or a
+// concurrency slot. Idle warm runners above the desired count are
+// retired once they've been idle for idle_ttl, oldest first. Every
+// spawn is capped so the label's live runners (warm, busy, starting)
+// never exceed max_concurrency.
+//
+// The pool holds no durable state. Members are re-read from cloud
+// labels on every Observe, so a restarted dispatcher resumes with the
+// same pool; only the arrival rate and the hit / wait statistics start
+// over. Those statistics are served in the Prometheus text format by
+// Handler (metrics.go).
+//
+// The GitLab dispatcher uses the same pool with tags for labels; it
+// sets Pool.Covers to GitLab's rule, where a runner takes any job
+// whose tags are a subset of its own.
+package pool
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScaleWindow is how far back job arrivals count towards the arrival
+// rate.
+const ScaleWindow = 10 * time.Minute
+
+// claimTTL bounds how long a hit waits to show up as a busy runner
+// before the pool stops reserving a warm runner for it.
+const claimTTL = 2 * time.Minute
+
+// waitSamples is how many recent queue-wait samples Stats summarises.
+const waitSamples = 256
+
+// Policy is the per-label pool and concurrency config. Embedded in
+// each dispatcher's `[[label]]` config entry:
+//
+//	min_idle        = 2       # warm runners kept idle at all times
+//	max_idle        = 6       # autoscaling ceiling; default min_idle
+//	max_concurrency = 20      # cap on live runners for the label; 0 = unlimited
+//	idle_ttl        = "10m"   # idle warm runners above target retire after this
+//	cold_start      = "90s"   # expected spawn → registered latency
+//
+// All fields are optional; the zero Policy disables the pool and the
+// cap, which is the pre-pool behaviour.
+type Policy struct {
+	MinIdle        int           `toml:"min_idle"`
+	MaxIdle        int           `toml:"max_idle"`
+	MaxConcurrency int           `toml:"max_concurrency"`
+	IdleTTL        time.Duration `toml:"idle_ttl"`
+	ColdStart      time.Duration `toml:"cold_start"`
+}
+
+// Validate checks the policy for contradictions. Called by each
+// dispatcher's config.Load.
+func (p Policy) Validate() error {
+	switch {
+	case p.MinIdle < 0, p.MaxIdle < 0, p.MaxConcurrency < 0:
+		return fmt.Errorf("min_idle, max_idle and max_concurrency must not be negative")
+	case p.IdleTTL < 0, p.ColdStart < 0:
+		return fmt.Errorf("idle_ttl and cold_start must not be negative")
+	case p.MaxIdle > 0 && p.MaxIdle < p.MinIdle:
+		return fmt.Errorf("max_idle (%d) is below min_idle (%d)", p.MaxIdle, p.MinIdle)
+	case p.MaxConcurrency > 0 && p.MinIdle > p.MaxConcurrency:
+		return fmt.Errorf("min_idle (%d) exceeds max_concurrency (%d)", p.MinIdle, p.MaxConcurrency)
+	}
+	return nil
+}
+
+// Enabled reports whether the label keeps warm runners at all.
+func (p Policy) Enabled() bool { return p.MinIdle > 0 || p.MaxIdle > 0 }
+
+// Active reports whether the pool has anything to do for the label:
+// warm runners or a concurrency cap.
+func (p Policy) Active() bool { return p.Enabled() || p.MaxConcurrency > 0 }
+
+func (p Policy) maxIdle() int {
+	if p.MaxIdle > 0 {
+		return p.MaxIdle
+	}
+	return p.MinIdle
+}
+
+func (p Policy) idleTTL() time.Duration {
+	if p.IdleTTL > 0 {
+		return p.IdleTTL
+	}
+	return 10 * time.Minute
+}
+
+func (p Policy) coldStart() time.Duration {
+	if p.ColdStart > 0 {
+		return p.ColdStart
+	}
+	return 90 * time.Second
+}
+
+// RunnerIdleTimeout is how long a warm runner should wait for a job
+// before exiting on its own. The dispatcher normally retires it first;
+// this is the backstop when no dispatcher is left to do so.
+func (p Policy) RunnerIdleTimeout() time.Duration {
+	return p.idleTTL() + p.coldStart()
+}
+
+// Member is one live runner of a label as the dispatcher sees it:
+// recovered from cloud-side labels and joined with GitHub's runner
+// list. Terminated runners are not members.
+type Member struct {
+	Name      string    // runner name — the GitHub runner name and a cloud label
+	Warm      bool      // spawned ahead of demand, without a job ID
+	SpawnedAt time.Time // from the spawn-time label
+	Busy      bool      // GitHub reports the runner running a job
+}
+
+// Decision is Admit's verdict for one queued job.
+type Decision int
+
+const (
+	// Cold: spawn a runner for the job (pool miss).
+	Cold Decision = iota
+	// Hit: an idle warm runner will take the job; don't spawn.
+	Hit
+	// Defer: the label is at max_concurrency; leave the job queued.
+	Defer
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Hit:
+		return "hit"
+	case Defer:
+		return "defer"
+	}
+	return "cold"
+}
+
+// Plan is what the dispatcher should do for a label right now.
+type Plan struct {
+	Spawn  int      // warm runners to start
+	Retire []string // idle warm runners to retire, by Name
+}
+
+// Pool tracks every label's members, demand and statistics. Safe for
+// concurrent use; the dispatchers call it from their single dispatch
+// goroutine.
+type Pool struct {
+	Now func() time.Time
+	// Covers reports whether a warm runner of a label can run a job
+	// asking for jobLabels. New sets the package Covers (GitHub's
+	// runs-on rule).
+	Covers func(label string, jobLabels []string) bool
+
+	mu     sync.Mutex
+	labels map[string]*labelState
+}
+
+type labelState struct {
+	policy   Policy
+	members  []Member
+	busy     map[string]bool     // members busy at the previous Observe
+	claims   []time.Time         // hits not yet seen as busy runners
+	spawned  int                 // cold spawns since the last Observe
+	warming  int                 // warm spawns since the last Observe
+	arrivals map[int64]time.Time // job ID → first Admit, within ScaleWindow
+	waiting  map[int64]time.Time // job ID → cold spawn / defer, not yet running
+	deferred map[int64]bool      // jobs counted in Deferred
+	hits     uint64
+	misses   uint64
+	defers   uint64
+	waits    []time.Duration // ring of the latest waitSamples queue waits
+	next     int
+	hitWait  waitHistogram // every hit's queue wait, for /metrics
+	coldWait waitHistogram // every cold spawn's queue wait, for /metrics
+}
+
+// New returns an empty Pool.
+func New() *Pool {
+	return &Pool{Now: time.Now, Covers: Covers, labels: map[string]*labelState{}}
+}
+
+// Configure sets a label's policy. Labels never configured behave as
+// the zero Policy.
+func (p *Pool) Configure(label string, policy Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state(label).policy = policy
+}
+
+// Policy returns a label's policy.
+func (p *Pool) Policy(label string) Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state(label).policy
+}
+
+func (p *Pool) state(label string) *labelState {
+	s, ok := p.labels[label]
+	if !ok {
+		s = &labelState{
+			busy:     map[string]bool{},
+			arrivals: map[int64]time.Time{},
+			waiting:  map[int64]time.Time{},
+			deferred: map[int64]bool{},
+		}
+		p.labels[label] = s
+	}
+	return s
+}
+
+// Observe replaces a label's members with a fresh listing. Pending
+// spawns are dropped (the listing includes them now), and each warm
+// runner that turned busy since the previous Observe settles one
+// outstanding hit.
+func (p *Pool) Observe(label string, members []Member) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.state(label)
+	now := p.Now()
+
+	busy := map[string]bool{}
+	settled := 0
+	for _, m := range members {
+		if !m.Busy {
+			continue
+		}
+		busy[m.Name] = true
+		if m.Warm && !s.busy[m.Name] {
+			settled++
+		}
+	}
+	claims := s.claims[:0]
+	for _, c := range s.claims {
+		if settled > 0 {
+			settled--
+			continue
+		}
+		if now.Sub(c) < claimTTL {
+			claims = append(claims, c)
+		}
+	}
+	s.claims = claims
+	s.busy = busy
+	s.members = append([]Member(nil), members...)
+	s.spawned, s.warming = 0, 0
+
+	// A job waits until its cold runner has had cold_start to pick it
+	// up; deferred jobs wait until they're admitted again.
+	for id, at := range s.waiting {
+		if !s.deferred[id] && now.Sub(at) > s.policy.coldStart() {
+			delete(s.waiting, id)
+		}
+	}
+}
+
+// Admit decides how a queued job for `label` is served. `labels` are
+// the job's runs-on labels; a warm runner only carries the pool label
+// and GitHub's default self-hosted labels, so a job asking for more
+// can't be a hit. A Hit is final (the job is served); after Cold the
+// dispatcher calls Spawned once its spawn succeeds.
+func (p *Pool) Admit(label string, jobID int64, labels []string, queuedAt time.Time) Decision {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.state(label)
+	now := p.Now()
+	if _, ok := s.arrivals[jobID]; !ok {
+		s.arrivals[jobID] = now
+	}
+
+	if s.policy.Enabled() && p.Covers(label, labels) && s.available() > 0 {
+		s.claims = append(s.claims, now)
+		s.hits++
+		s.recordWait(now, queuedAt, &s.hitWait)
+		delete(s.waiting, jobID)
+		delete(s.deferred, jobID)
+		return Hit
+	}
+	if max := s.policy.MaxConcurrency; max > 0 && s.live() >= max {
+		if !s.deferred[jobID] {
+			s.deferred[jobID] = true
+			s.defers++
+		}
+		s.waiting[jobID] = now
+		return Defer
+	}
+	return Cold
+}
+
+// Spawned records a successful cold spawn for a job Admit returned
+// Cold for.
+func (p *Pool) Spawned(label string, jobID int64, queuedAt time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.state(label)
+	now := p.Now()
+	s.spawned++
+	s.misses++
+	s.recordWait(now, queuedAt, &s.coldWait)
+	delete(s.deferred, jobID)
+	s.waiting[jobID] = now
+}
+
+// WarmSpawned records a successful warm spawn.
+func (p *Pool) WarmSpawned(label string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state(label).warming++
+}
+
+// Plan computes the warm spawns and retirements for a label from the
+// latest Observe plus everything admitted or spawned since.
+func (p *Pool) Plan(label string) Plan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.state(label)
+	now := p.Now()
+	s.prune(now)
+
+	desired := s.desired()
+	available := s.available()
+	var plan Plan
+	if spawn := desired - available; spawn > 0 {
+		if max := s.policy.MaxConcurrency; max > 0 {
+			if room := max - s.live(); spawn > room {
+				spawn = room
+			}
+		}
+		if spawn > 0 {
+			plan.Spawn = spawn
+		}
+	}
+	// Never retire while a hit is outstanding: the claimed runner isn't
+	// busy yet and can't be told apart from the idle ones.
+	if excess := available - desired; excess > 0 && len(s.claims) == 0 {
+		ttl := s.policy.idleTTL()
+		for _, m := range s.idleWarm() {
+			if excess == 0 {
+				break
+			}
+			if now.Sub(m.SpawnedAt) < ttl {
+				continue
+			}
+			plan.Retire = append(plan.Retire, m.Name)
+			excess--
+		}
+	}
+	return plan
+}
+
+// Covers reports whether a warm runner registered with only `label`
+// can run a job asking for `jobLabels`: every requested label must be
+// the pool label or one of the labels GitHub adds to every self-hosted
+// runner.
+func Covers(label string, jobLabels []string) bool {
+	for _, l := range jobLabels {
+		switch strings.ToLower(l) {
+		case strings.ToLower(label), "self-hosted", "linux", "x64", "arm64":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// idleWarm returns the label's idle warm members, oldest first.
+func (s *labelState) idleWarm() []Member {
+	var idle []Member
+	for _, m := range s.members {
+		if m.Warm && !m.Busy {
+			idle = append(idle, m)
+		}
+	}
+	sort.Slice(idle, func(i, k int) bool { return idle[i].SpawnedAt.Before(idle[k].SpawnedAt) })
+	return idle
+}
+
+// available is the number of warm runners free for the next job.
+func (s *labelState) available() int {
+	return len(s.idleWarm()) + s.warming - len(s.claims)
+}
+
+// live counts every runner holding a concurrency slot.
+func (s *labelState) live() int {
+	return len(s.members) + s.spawned + s.warming
+}
+
+func (s *labelState) desired() int {
+	if !s.policy.Enabled() {
+		return 0
+	}
+	rate := float64(len(s.arrivals)) / ScaleWindow.Seconds()
+	want := int(math.Ceil(rate*s.policy.coldStart().Seconds())) + len(s.waiting)
+	if want < s.policy.MinIdle {
+		want = s.policy.MinIdle
+	}
+	if max := s.policy.maxIdle(); want > max {
+		want = max
+	}
+	return want
+}
+
+func (s *labelState) prune(now time.Time) {
+	for id, at := range s.arrivals {
+		if now.Sub(at) > ScaleWindow {
+			delete(s.arrivals, id)
+		}
+	}
+	for id, at := range s.waiting {
+		if s.deferred[id] && now.Sub(at) > ScaleWindow {
+			// Deferred job never re-admitted: gone from the queue.
+			delete(s.waiting, id)
+			delete(s.deferred, id)
+		}
+	}
+}
+
+func (s *labelState) recordWait(now, queuedAt time.Time, hist *waitHistogram) {
+	if queuedAt.IsZero() {
+		return
+	}
+	wait := now.Sub(queuedAt)
+	if wait < 0 {
+		wait = 0
+	}
+	hist.observe(wait)
+	if len(s.waits) < waitSamples {
+		s.waits = append(s.waits, wait)
+		return
+	}
+	s.waits[s.next] = wait
+	s.next = (s.next + 1) % waitSamples
+}
+
+// Stats is a label's pool state and counters since the dispatcher
+// started.
+type Stats struct {
+	Label    string
+	Live     int // members plus spawns not yet listed
+	Idle     int // idle warm runners, listed or starting
+	Busy     int // members GitHub reports busy
+	Desired  int // idle target from the latest demand
+	Hits     uint64
+	Misses   uint64
+	Deferred uint64 // jobs held back by max_concurrency
+	// QueueWait summarises the time from a job being queued on GitHub
+	// to the dispatcher serving it (hit, or cold spawn issued), over
+	// the latest waitSamples jobs. A cold job still waits cold_start
+	// more for its runner; a hit doesn't.
+	QueueWaitP50 time.Duration
+	QueueWaitP95 time.Duration
+	QueueWaitMax time.Duration
+}
+
+// HitRate is Hits over Hits + Misses, 0 before the first job.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("label=%s live=%d idle=%d busy=%d desired=%d hits=%d misses=%d deferred=%d hit-rate=%.0f%% queue-wait p50=%s p95=%s max=%s",
+		s.Label, s.Live, s.Idle, s.Busy, s.Desired, s.Hits, s.Misses, s.Deferred, s.HitRate()*100,
+		s.QueueWaitP50.Round(time.Second), s.QueueWaitP95.Round(time.Second), s.QueueWaitMax.Round(time.Second))
+}
+
+// Stats returns every known label's stats, sorted by label.
+func (p *Pool) Stats() []Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.Now()
+	var out []Stats
+	for label, s := range p.labels {
+		s.prune(now)
+		st := Stats{
+			Label:    label,
+			Live:     s.live(),
+			Idle:     len(s.idleWarm()) + s.warming,
+			Busy:     len(s.busy),
+			Desired:  s.desired(),
+			Hits:     s.hits,
+			Misses:   s.misses,
+			Deferred: s.defers,
+		}
+		if n := len(s.waits); n > 0 {
+			sorted := append([]time.Duration(nil), s.waits...)
+			sort.Slice(sorted, func(i, k int) bool { return sorted[i] < sorted[k] })
+			st.QueueWaitP50 = sorted[(n-1)/2]
+			st.QueueWaitP95 = sorted[(n-1)*95/100]
+			st.QueueWaitMax = sorted[n-1]
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Label < out[k].Label })
+	return out
+}
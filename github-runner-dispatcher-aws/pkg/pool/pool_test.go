@@ -0,0 +1,208 @@
+package pool
+
+import (
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time             { return c.t }
+func (c *clock) advance(d time.Duration)    { c.t = c.t.Add(d) }
+func newTestPool(c *clock) *Pool            { p := New(); p.Now = c.now; return p }
+func warm(name string, at time.Time) Member { return Member{Name: name, Warm: true, SpawnedAt: at} }
+
+func TestPlanFillsMinIdle(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 2})
+
+	if got := p.Plan("ecs"); got.Spawn != 2 || len(got.Retire) != 0 {
+		t.Fatalf("empty pool plan = %+v, want spawn 2", got)
+	}
+	p.WarmSpawned("ecs")
+	p.WarmSpawned("ecs")
+	if got := p.Plan("ecs"); got.Spawn != 0 {
+		t.Fatalf("plan after two warm spawns = %+v, want nothing", got)
+	}
+	p.Observe("ecs", []Member{warm("w1", c.t), warm("w2", c.t)})
+	if got := p.Plan("ecs"); got.Spawn != 0 {
+		t.Fatalf("plan with two idle members = %+v, want nothing", got)
+	}
+}
+
+func TestAdmitHitThenReplenish(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 1})
+	p.Observe("ecs", []Member{warm("w1", c.t)})
+
+	queued := c.t.Add(-3 * time.Second)
+	if d := p.Admit("ecs", 1, []string{"self-hosted", "ecs"}, queued); d != Hit {
+		t.Fatalf("first job = %s, want hit", d)
+	}
+	if d := p.Admit("ecs", 2, []string{"ecs"}, queued); d != Cold {
+		t.Fatalf("second job = %s, want cold (warm runner already claimed)", d)
+	}
+	if got := p.Plan("ecs"); got.Spawn < 1 {
+		t.Fatalf("plan after a hit = %+v, want a replacement", got)
+	}
+
+	// The claimed runner turns busy: the claim settles and the pool
+	// still wants one idle runner.
+	p.Observe("ecs", []Member{{Name: "w1", Warm: true, SpawnedAt: c.t, Busy: true}})
+	if got := p.Plan("ecs"); got.Spawn < 1 {
+		t.Fatalf("plan with the only warm runner busy = %+v, want a spawn", got)
+	}
+}
+
+func TestAdmitExtraLabelsAreCold(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 1})
+	p.Observe("ecs", []Member{warm("w1", c.t)})
+
+	if d := p.Admit("ecs", 1, []string{"ecs", "gpu"}, c.t); d != Cold {
+		t.Fatalf("job needing gpu = %s, want cold", d)
+	}
+}
+
+func TestAdmitDefersAtMaxConcurrency(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MaxConcurrency: 2})
+	p.Observe("ecs", []Member{{Name: "a", Busy: true}, {Name: "b", Busy: true}})
+
+	for i := 0; i < 3; i++ {
+		if d := p.Admit("ecs", 7, []string{"ecs"}, c.t); d != Defer {
+			t.Fatalf("admit #%d = %s, want defer", i, d)
+		}
+	}
+	if st := p.Stats()[0]; st.Deferred != 1 {
+		t.Fatalf("deferred = %d, want 1 (one job, admitted three times)", st.Deferred)
+	}
+
+	p.Observe("ecs", []Member{{Name: "a", Busy: true}})
+	if d := p.Admit("ecs", 7, []string{"ecs"}, c.t); d != Cold {
+		t.Fatalf("admit with a free slot = %s, want cold", d)
+	}
+	p.Spawned("ecs", 7, c.t)
+	if d := p.Admit("ecs", 8, []string{"ecs"}, c.t); d != Defer {
+		t.Fatalf("admit after the spawn filled the cap = %s, want defer", d)
+	}
+}
+
+func TestPlanCapsWarmSpawnsAtMaxConcurrency(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 3, MaxConcurrency: 4})
+	p.Observe("ecs", []Member{{Name: "a", Busy: true}, {Name: "b", Busy: true}})
+
+	if got := p.Plan("ecs"); got.Spawn != 2 {
+		t.Fatalf("plan = %+v, want spawn 2 (4 max - 2 busy)", got)
+	}
+}
+
+func TestPlanRetiresIdleAfterTTL(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 1, MaxIdle: 4, IdleTTL: 5 * time.Minute})
+	start := c.t
+	p.Observe("ecs", []Member{warm("old1", start), warm("old2", start.Add(time.Second)), warm("new", start.Add(4*time.Minute))})
+
+	if got := p.Plan("ecs"); len(got.Retire) != 0 {
+		t.Fatalf("plan before TTL = %+v, want no retirements", got)
+	}
+	c.advance(5*time.Minute + 30*time.Second)
+	got := p.Plan("ecs")
+	if len(got.Retire) != 2 || got.Retire[0] != "old1" || got.Retire[1] != "old2" {
+		t.Fatalf("plan after TTL = %+v, want old1 and old2 retired", got)
+	}
+}
+
+func TestPlanNoRetireWhileClaimOutstanding(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 1, IdleTTL: time.Minute})
+	p.Observe("ecs", []Member{warm("w1", c.t), warm("w2", c.t), warm("w3", c.t)})
+	c.advance(2 * time.Minute)
+	p.Admit("ecs", 1, []string{"ecs"}, c.t)
+
+	if got := p.Plan("ecs"); len(got.Retire) != 0 {
+		t.Fatalf("plan with an unsettled hit = %+v, want no retirements", got)
+	}
+}
+
+func TestDesiredScalesWithArrivals(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 1, MaxIdle: 5, ColdStart: time.Minute})
+
+	// 20 jobs in the window at 60 s cold start → 2 expected per cold
+	// start, all served long ago (no backlog).
+	for i := int64(1); i <= 20; i++ {
+		p.Admit("ecs", i, []string{"ecs"}, c.t)
+		p.Spawned("ecs", i, c.t)
+	}
+	c.advance(2 * time.Minute)
+	p.Observe("ecs", nil)
+	if got := p.Plan("ecs"); got.Spawn != 2 {
+		t.Fatalf("plan = %+v, want spawn 2 from the arrival rate", got)
+	}
+
+	// A fresh burst adds backlog; capped at max_idle.
+	for i := int64(100); i < 110; i++ {
+		p.Admit("ecs", i, []string{"ecs"}, c.t)
+		p.Spawned("ecs", i, c.t)
+	}
+	if got := p.Plan("ecs"); got.Spawn != 5 {
+		t.Fatalf("plan under burst = %+v, want spawn 5 (max_idle)", got)
+	}
+
+	// Past the window with nothing new, back to min_idle.
+	c.advance(ScaleWindow + time.Minute)
+	p.Observe("ecs", nil)
+	if got := p.Plan("ecs"); got.Spawn != 1 {
+		t.Fatalf("plan after quiet window = %+v, want spawn 1 (min_idle)", got)
+	}
+}
+
+func TestStatsHitRateAndQueueWait(t *testing.T) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	p := newTestPool(c)
+	p.Configure("ecs", Policy{MinIdle: 1})
+	p.Observe("ecs", []Member{warm("w1", c.t)})
+
+	p.Admit("ecs", 1, []string{"ecs"}, c.t.Add(-2*time.Second)) // hit, waited 2s
+	p.Admit("ecs", 2, []string{"ecs"}, c.t.Add(-10*time.Second))
+	p.Spawned("ecs", 2, c.t.Add(-10*time.Second)) // miss, waited 10s
+
+	st := p.Stats()
+	if len(st) != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+	s := st[0]
+	if s.Hits != 1 || s.Misses != 1 || s.HitRate() != 0.5 {
+		t.Fatalf("hits=%d misses=%d rate=%v", s.Hits, s.Misses, s.HitRate())
+	}
+	if s.QueueWaitP50 != 2*time.Second || s.QueueWaitMax != 10*time.Second {
+		t.Fatalf("queue wait p50=%s max=%s", s.QueueWaitP50, s.QueueWaitMax)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	bad := []Policy{
+		{MinIdle: -1},
+		{MinIdle: 3, MaxIdle: 2},
+		{MinIdle: 5, MaxConcurrency: 4},
+		{IdleTTL: -time.Second},
+	}
+	for _, p := range bad {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", p)
+		}
+	}
+	if err := (Policy{MinIdle: 2, MaxIdle: 6, MaxConcurrency: 20}).Validate(); err != nil {
+		t.Errorf("valid policy: %v", err)
+	}
+}
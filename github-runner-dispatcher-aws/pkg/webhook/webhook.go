@@ -0,0 +1,182 @@
+// Package webhook is the dispatchers' HTTP ingress for GitHub
+// `workflow_job` webhooks. A delivery with action=queued becomes one
+// poller.Job on the channel the dispatch loop drains, so a webhook job
+// takes exactly the path a polled one does (label match, seen-set
+// dedup, spawn, Mark).
+//
+// Every delivery must carry a valid `X-Hub-Signature-256` HMAC of the
+// body under the hook's secret; anything else is rejected with 401
+// before the payload is parsed. The handler does no dedup of its own:
+// GitHub redelivers on timeouts and operators redeliver by hand, and
+// both land on the dispatch loop's seen-set, which is rebuilt from
+// cloud-side labels on restart.
+//
+// Missed deliveries (dispatcher down, GitHub outage) are caught by the
+// reconciliation poll the dispatchers keep running at a slower cadence.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/poller"
+)
+
+// Path is where the dispatchers mount the handler.
+const Path = "/webhook"
+
+// maxPayload is GitHub's cap on webhook payloads (25 MB).
+const maxPayload = 25 << 20
+
+// Handler receives `workflow_job` deliveries for one repository.
+type Handler struct {
+	Secret []byte // webhook secret; required
+	Repo   string // owner/repo; deliveries for other repos are ignored
+	Jobs   chan<- poller.Job
+	Now    func() time.Time
+}
+
+// New builds a Handler feeding jobs. secret and repo are required.
+func New(secret, repo string, jobs chan<- poller.Job) (*Handler, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("webhook secret is required")
+	}
+	if repo == "" {
+		return nil, fmt.Errorf("webhook repo is required")
+	}
+	return &Handler{Secret: []byte(secret), Repo: repo, Jobs: jobs, Now: time.Now}, nil
+}
+
+// ServeHTTP implements http.Handler. Responses: 204 for ping, 202 for a
+// queued job handed to the dispatch loop or a delivery that needs no
+// action, 401 for a bad signature, 503 when the dispatch loop did not
+// take the job before the request was cancelled (GitHub will show the
+// failed delivery; the reconciliation poll picks the job up).
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayload+1))
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxPayload {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !Verify(h.Secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid X-Hub-Signature-256", http.StatusUnauthorized)
+		return
+	}
+
+	delivery := r.Header.Get("X-GitHub-Delivery")
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "ping":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "workflow_job":
+	default:
+		log.Printf("webhook: ignoring %q delivery %s", event, delivery)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	job, ok, err := h.parse(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err := h.enqueue(r.Context(), job); err != nil {
+		log.Printf("webhook: job %d from delivery %s not queued: %v", job.JobID, delivery, err)
+		http.Error(w, "dispatcher busy", http.StatusServiceUnavailable)
+		return
+	}
+	log.Printf("webhook: queued job %d (%s) from delivery %s", job.JobID, job.Name, delivery)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) enqueue(ctx context.Context, job poller.Job) error {
+	select {
+	case h.Jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parse decodes a workflow_job payload. ok is false for actions other
+// than queued and for other repositories' jobs.
+func (h *Handler) parse(body []byte) (job poller.Job, ok bool, err error) {
+	var p struct {
+		Action      string `json:"action"`
+		WorkflowJob struct {
+			ID        int64     `json:"id"`
+			RunID     int64     `json:"run_id"`
+			Name      string    `json:"name"`
+			Status    string    `json:"status"`
+			Labels    []string  `json:"labels"`
+			HTMLURL   string    `json:"html_url"`
+			CreatedAt time.Time `json:"created_at"`
+		} `json:"workflow_job"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return poller.Job{}, false, fmt.Errorf("decode workflow_job payload: %w", err)
+	}
+	if p.Action != "queued" {
+		return poller.Job{}, false, nil
+	}
+	if !strings.EqualFold(p.Repository.FullName, h.Repo) {
+		log.Printf("webhook: ignoring job %d of %s (dispatching for %s)", p.WorkflowJob.ID, p.Repository.FullName, h.Repo)
+		return poller.Job{}, false, nil
+	}
+	if p.WorkflowJob.ID == 0 {
+		return poller.Job{}, false, fmt.Errorf("workflow_job payload has no job id")
+	}
+	if p.WorkflowJob.CreatedAt.IsZero() {
+		p.WorkflowJob.CreatedAt = h.Now()
+	}
+	return poller.Job{
+		JobID:    p.WorkflowJob.ID,
+		RunID:    p.WorkflowJob.RunID,
+		Name:     p.WorkflowJob.Name,
+		Labels:   p.WorkflowJob.Labels,
+		Repo:     h.Repo,
+		JobURL:   p.WorkflowJob.HTMLURL,
+		QueuedAt: p.WorkflowJob.CreatedAt,
+	}, true, nil
+}
+
+// Verify reports whether signature (an `X-Hub-Signature-256` header
+// value, "sha256=<hex>") is the HMAC-SHA256 of body under secret.
+func Verify(secret, body []byte, signature string) bool {
+	hexSig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/poller"
+)
+
+const queuedPayload = `{
+	"action": "queued",
+	"workflow_job": {"id": 7001, "run_id": 42, "name": "build", "status": "queued",
+		"labels": ["self-hosted", "sockerless-ecs"], "html_url": "https://gh/o/r/runs/42/job/7001"},
+	"repository": {"full_name": "owner/repo"}
+}`
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func deliver(t *testing.T, h http.Handler, event, sig, body string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", "d-1")
+	if sig != "" {
+		req.Header.Set("X-Hub-Signature-256", sig)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestHandlerQueuedJob(t *testing.T) {
+	jobs := make(chan poller.Job, 1)
+	h, err := New("s3cret", "owner/repo", jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := deliver(t, h, "workflow_job", sign("s3cret", queuedPayload), queuedPayload); code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", code)
+	}
+	select {
+	case j := <-jobs:
+		if j.JobID != 7001 || j.RunID != 42 || j.Name != "build" || j.Repo != "owner/repo" {
+			t.Fatalf("job = %+v", j)
+		}
+		if len(j.Labels) != 2 || j.Labels[1] != "sockerless-ecs" {
+			t.Fatalf("labels = %v", j.Labels)
+		}
+	default:
+		t.Fatal("no job on channel")
+	}
+}
+
+func TestHandlerRejectsBadSignature(t *testing.T) {
+	jobs := make(chan poller.Job, 1)
+	h, _ := New("s3cret", "owner/repo", jobs)
+	for name, sig := range map[string]string{
+		"missing":    "",
+		"wrong key":  sign("other", queuedPayload),
+		"sha1":       "sha1=" + strings.TrimPrefix(sign("s3cret", queuedPayload), "sha256="),
+		"not hex":    "sha256=zz",
+		"other body": sign("s3cret", queuedPayload+" "),
+	} {
+		if code := deliver(t, h, "workflow_job", sig, queuedPayload); code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, code)
+		}
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("unsigned delivery queued a job")
+	}
+}
+
+func TestHandlerIgnoresNonQueued(t *testing.T) {
+	jobs := make(chan poller.Job, 1)
+	h, _ := New("s3cret", "owner/repo", jobs)
+
+	completed := strings.Replace(queuedPayload, `"action": "queued"`, `"action": "completed"`, 1)
+	if code := deliver(t, h, "workflow_job", sign("s3cret", completed), completed); code != http.StatusAccepted {
+		t.Fatalf("completed: status = %d", code)
+	}
+	otherRepo := strings.Replace(queuedPayload, "owner/repo", "someone/else", 1)
+	if code := deliver(t, h, "workflow_job", sign("s3cret", otherRepo), otherRepo); code != http.StatusAccepted {
+		t.Fatalf("other repo: status = %d", code)
+	}
+	if code := deliver(t, h, "ping", sign("s3cret", `{}`), `{}`); code != http.StatusNoContent {
+		t.Fatalf("ping: status = %d", code)
+	}
+	if code := deliver(t, h, "push", sign("s3cret", `{}`), `{}`); code != http.StatusAccepted {
+		t.Fatalf("push: status = %d", code)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("ignored delivery queued a job: %+v", <-jobs)
+	}
+}
+
+func TestNewRequiresSecret(t *testing.T) {
+	if _, err := New("", "owner/repo", nil); err == nil {
+		t.Fatal("New with empty secret succeeded")
+	}
+}
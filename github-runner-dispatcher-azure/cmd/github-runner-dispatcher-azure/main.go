@@ -5,8 +5,11 @@
 // is the running instance).
 //
 // Same flag surface (`--repo`, `--token`, `--config`, `--once`,
-// `--cleanup-only`) and reuses the upstream poller / scopes via the
+// `--cleanup-only`, `--webhook-addr`, `--webhook-secret`,
+// `--reconcile-interval`, `--metrics-addr`) and reuses the upstream poller / scopes /
+// webhook ingress / warm pool via the
 // `replace github.com/sockerless/github-runner-dispatcher-aws` directive.
+// Warm-pool state is re-read from ACA Job tags after a restart.
 package main
 
 import (
@@ -22,7 +25,9 @@ import (
 	"time"
 
 	"github.com/sockerless/github-runner-dispatcher-aws/pkg/poller"
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
 	"github.com/sockerless/github-runner-dispatcher-aws/pkg/scopes"
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/webhook"
 	"github.com/sockerless/github-runner-dispatcher-azure/internal/config"
 	"github.com/sockerless/github-runner-dispatcher-azure/internal/spawner"
 )
@@ -40,6 +45,10 @@ func run() error {
 	configPath := flag.String("config", "", "path to dispatcher config.toml; default ~/.sockerless/dispatcher-azure/config.toml")
 	once := flag.Bool("once", false, "run a single poll cycle and exit (smoke / debug)")
 	cleanupOnly := flag.Bool("cleanup-only", false, "run a single GC sweep (ACA Jobs + GitHub runners) and exit; no polling")
+	webhookAddr := flag.String("webhook-addr", "", "listen address for the workflow_job webhook ingress (e.g. :8088); empty = poll only")
+	webhookSecret := flag.String("webhook-secret", os.Getenv("GITHUB_WEBHOOK_SECRET"), "webhook secret; default $GITHUB_WEBHOOK_SECRET; required with --webhook-addr")
+	reconcileEvery := flag.Duration("reconcile-interval", 0, "reconciliation poll cadence; default 60s polling only, 2m with --webhook-addr")
+	metricsAddr := flag.String("metrics-addr", "", "listen address for the Prometheus /metrics endpoint (e.g. :9090); empty = off")
 	flag.Parse()
 
 	if *repo == "" || !strings.Contains(*repo, "/") {
@@ -48,6 +57,9 @@ func run() error {
 	if *token == "" {
 		return fmt.Errorf("github token is empty — set $GITHUB_TOKEN, run `gh auth token | …`, or pass --token=…")
 	}
+	if *webhookAddr != "" && *webhookSecret == "" {
+		return fmt.Errorf("--webhook-addr needs a secret — set $GITHUB_WEBHOOK_SECRET or pass --webhook-secret=… (the same value as the repo hook's secret)")
+	}
 
 	cfgPath := *configPath
 	if cfgPath == "" {
@@ -71,8 +83,8 @@ func run() error {
 	if err := scopes.Verify(ctx, http.DefaultClient, *token); err != nil {
 		return err
 	}
-	log.Printf("dispatcher-azure ready: repo=%s labels=%d once=%v cleanup-only=%v",
-		*repo, len(cfg.Labels), *once, *cleanupOnly)
+	log.Printf("dispatcher-azure ready: repo=%s labels=%d once=%v cleanup-only=%v webhook=%q",
+		*repo, len(cfg.Labels), *once, *cleanupOnly, *webhookAddr)
 
 	gh := poller.New(http.DefaultClient, *token, *repo)
 	loop := newDispatchLoop(gh, cfg)
@@ -89,6 +101,24 @@ func run() error {
 		return loop.Cleanup(ctx)
 	}
 
+	// Pool metrics (queue wait, hit rate, runner counts) for a
+	// Prometheus scrape.
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", loop.pool.Handler("github-azure"))
+		srv := &http.Server{Addr: *metricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("metrics: %v", err)
+				cancel()
+			}
+		}()
+		defer srv.Close()
+		log.Printf("metrics listening on %s/metrics", *metricsAddr)
+	}
+
+	// Shutdown only sweeps terminated Jobs: warm runners stay
+	// registered so the next dispatcher instance resumes the pool.
 	defer func() {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -97,77 +127,148 @@ func run() error {
 		}
 	}()
 
-	ticker := time.NewTicker(gh.PollInterval())
+	// Webhook ingress: same shape as the AWS dispatcher — deliveries
+	// land on `jobs`, drained here next to the reconciliation ticker.
+	jobs := make(chan poller.Job, 64)
+	interval := gh.PollInterval()
+	if *webhookAddr != "" {
+		h, err := webhook.New(*webhookSecret, *repo, jobs)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle(webhook.Path, h)
+		srv := &http.Server{Addr: *webhookAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("webhook ingress: %v", err)
+				cancel()
+			}
+		}()
+		defer srv.Close()
+		log.Printf("webhook ingress listening on %s%s", *webhookAddr, webhook.Path)
+		interval = 2 * time.Minute
+	}
+	if *reconcileEvery > 0 {
+		interval = *reconcileEvery
+	}
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	cleanupTicker := time.NewTicker(2 * time.Minute)
 	defer cleanupTicker.Stop()
+	poolTicker := time.NewTicker(poolInterval)
+	defer poolTicker.Stop()
+	if err := loop.Step(ctx); err != nil {
+		log.Printf("poll error (continuing): %v", err)
+	}
+	loop.ReconcilePool(ctx)
 	for {
-		if err := loop.Step(ctx); err != nil {
-			log.Printf("poll error (continuing): %v", err)
-		}
 		select {
 		case <-ctx.Done():
 			return nil
+		case job := <-jobs:
+			loop.dispatch(ctx, job)
 		case <-cleanupTicker.C:
 			if err := loop.Cleanup(ctx); err != nil {
 				log.Printf("cleanup error (continuing): %v", err)
 			}
+			loop.LogPoolStats()
+		case <-poolTicker.C:
+			loop.ReconcilePool(ctx)
 		case <-ticker.C:
+			if err := loop.Step(ctx); err != nil {
+				log.Printf("poll error (continuing): %v", err)
+			}
 		}
 	}
 }
 
+// poolInterval is how often ReconcilePool tops up and trims the warm
+// pools.
+const poolInterval = 30 * time.Second
+
 type dispatchLoop struct {
-	gh  *poller.Client
-	cfg config.Config
+	gh      *poller.Client
+	cfg     config.Config
+	pool    *pool.Pool
+	warmSeq int // disambiguates warm runner names spawned in the same second
 }
 
 func newDispatchLoop(gh *poller.Client, cfg config.Config) *dispatchLoop {
-	return &dispatchLoop{gh: gh, cfg: cfg}
+	p := pool.New()
+	for _, l := range cfg.Labels {
+		p.Configure(l.Name, l.Policy)
+	}
+	return &dispatchLoop{gh: gh, cfg: cfg, pool: p}
 }
 
+// Step is one reconciliation pass: re-seed the seen-set from managed
+// ACA Jobs, poll for queued jobs, and dispatch each one.
 func (d *dispatchLoop) Step(ctx context.Context) error {
+	d.seedSeen(ctx, false)
 	jobs, err := d.gh.PollOnce(ctx)
 	if err != nil {
 		return err
 	}
 	for _, job := range jobs {
-		label := pickKnownLabel(job.Labels, d.cfg)
-		if label == nil {
-			log.Printf("skip job %d (%s): no matching dispatcher label in %v", job.JobID, job.Name, job.Labels)
-			d.gh.Mark(job.JobID)
-			continue
-		}
-		regToken, err := d.gh.MintRegistrationToken(ctx)
-		if err != nil {
-			log.Printf("skip job %d: mint registration token: %v", job.JobID, err)
-			continue
-		}
-		runnerName := fmt.Sprintf("dispatcher-azure-%d-%d", job.JobID, time.Now().Unix())
-		jobARMID, err := spawner.Spawn(ctx, spawner.Request{
-			SubscriptionID:  label.SubscriptionID,
-			ResourceGroup:   label.ResourceGroup,
-			Environment:     label.Environment,
-			Location:        label.Location,
-			Image:           label.Image,
-			ManagedIdentity: label.ManagedIdentity,
-			RegToken:        regToken,
-			Repo:            job.Repo,
-			RunnerName:      runnerName,
-			Labels:          job.Labels,
-			JobID:           job.JobID,
-		})
-		if err != nil {
-			log.Printf("skip job %d: spawn (%s/%s): %v", job.JobID, label.SubscriptionID, label.ResourceGroup, err)
-			continue
-		}
-		log.Printf("spawned ACA Job for job %d (%s) in %s/%s: armid=%s url=%s",
-			job.JobID, label.Name, label.SubscriptionID, label.ResourceGroup, jobARMID, job.JobURL)
-		d.gh.Mark(job.JobID)
+		d.dispatch(ctx, job)
 	}
 	return nil
 }
 
+// dispatch starts an ACA Job for one queued job. Failures are logged
+// and leave the job unmarked so the next pass retries it.
+func (d *dispatchLoop) dispatch(ctx context.Context, job poller.Job) {
+	if d.gh.IsMarked(job.JobID) {
+		log.Printf("skip job %d (%s): already dispatched", job.JobID, job.Name)
+		return
+	}
+	label := pickKnownLabel(job.Labels, d.cfg)
+	if label == nil {
+		log.Printf("skip job %d (%s): no matching dispatcher label in %v", job.JobID, job.Name, job.Labels)
+		d.gh.Mark(job.JobID)
+		return
+	}
+	switch d.pool.Admit(label.Name, job.JobID, job.Labels, job.QueuedAt) {
+	case pool.Hit:
+		log.Printf("job %d (%s): warm-pool hit, an idle runner will take it", job.JobID, label.Name)
+		d.gh.Mark(job.JobID)
+		return
+	case pool.Defer:
+		log.Printf("defer job %d (%s): max_concurrency %d reached", job.JobID, label.Name, label.MaxConcurrency)
+		return
+	}
+	regToken, err := d.gh.MintRegistrationToken(ctx)
+	if err != nil {
+		log.Printf("skip job %d: mint registration token: %v", job.JobID, err)
+		return
+	}
+	runnerName := fmt.Sprintf("dispatcher-azure-%d-%d", job.JobID, time.Now().Unix())
+	jobARMID, err := spawner.Spawn(ctx, spawner.Request{
+		SubscriptionID:  label.SubscriptionID,
+		ResourceGroup:   label.ResourceGroup,
+		Environment:     label.Environment,
+		Location:        label.Location,
+		Image:           label.Image,
+		ManagedIdentity: label.ManagedIdentity,
+		RegToken:        regToken,
+		Repo:            job.Repo,
+		RunnerName:      runnerName,
+		Labels:          job.Labels,
+		JobID:           job.JobID,
+		Pool:            label.Name,
+	})
+	if err != nil {
+		log.Printf("skip job %d: spawn (%s/%s): %v", job.JobID, label.SubscriptionID, label.ResourceGroup, err)
+		return
+	}
+	d.pool.Spawned(label.Name, job.JobID, job.QueuedAt)
+	log.Printf("spawned ACA Job for job %d (%s) in %s/%s: armid=%s url=%s",
+		job.JobID, label.Name, label.SubscriptionID, label.ResourceGroup, jobARMID, job.JobURL)
+	d.gh.Mark(job.JobID)
+}
+
 func pickKnownLabel(labels []string, cfg config.Config) *config.Label {
 	for _, l := range labels {
 		if got := cfg.LookupLabel(l); got != nil {
@@ -178,6 +279,12 @@ func pickKnownLabel(labels []string, cfg config.Config) *config.Label {
 }
 
 func (d *dispatchLoop) RecoverState(ctx context.Context) {
+	d.seedSeen(ctx, true)
+}
+
+// seedSeen adds the job ID of every dispatcher-tagged ACA Job to the
+// seen-set. Step calls it quietly before each poll.
+func (d *dispatchLoop) seedSeen(ctx context.Context, verbose bool) {
 	now := d.gh.Now()
 	seen := map[string]bool{}
 	for _, label := range d.cfg.Labels {
@@ -188,7 +295,9 @@ func (d *dispatchLoop) RecoverState(ctx context.Context) {
 		seen[key] = true
 		managed, err := spawner.ListManaged(ctx, label.SubscriptionID, label.ResourceGroup)
 		if err != nil {
-			log.Printf("recover: list managed on %s failed: %v", key, err)
+			if verbose {
+				log.Printf("recover: list managed on %s failed: %v", key, err)
+			}
 			continue
 		}
 		for _, m := range managed {
@@ -196,12 +305,20 @@ func (d *dispatchLoop) RecoverState(ctx context.Context) {
 				continue
 			}
 			d.gh.Seen.Add(m.JobID, now)
-			log.Printf("recover: seen-set restored for job %d (aca-job %s, state=%s)",
-				m.JobID, m.JobName, m.State)
+			if verbose {
+				log.Printf("recover: seen-set restored for job %d (aca-job %s, state=%s)",
+					m.JobID, m.JobName, m.State)
+			}
 		}
 	}
 }
 
+// Cleanup deletes dispatcher-tagged ACA Jobs whose runner is done.
+// The Job resource is preserved by ACA after every execution; without
+// sweep, the resource group accumulates one Job per workflow_job.
+// Keyed off the latest execution's status, not the Job's provisioning
+// state — that reads Succeeded as soon as the Job exists, which would
+// delete running and warm runners.
 func (d *dispatchLoop) Cleanup(ctx context.Context) error {
 	seen := map[string]bool{}
 	for _, label := range d.cfg.Labels {
@@ -216,7 +333,7 @@ func (d *dispatchLoop) Cleanup(ctx context.Context) error {
 			continue
 		}
 		for _, m := range managed {
-			if !isTerminalProvisioningState(m.State) {
+			if !m.Terminal() {
 				continue
 			}
 			if err := spawner.Delete(ctx, label.SubscriptionID, label.ResourceGroup, m.JobName); err != nil {
@@ -229,15 +346,129 @@ func (d *dispatchLoop) Cleanup(ctx context.Context) error {
 	return nil
 }
 
-// isTerminalProvisioningState returns true for ACA Job
-// `ProvisioningState` values that indicate the Job's last execution
-// has ended (Job exists but isn't running anything). The Job
-// resource itself is preserved by ACA after every execution; without
-// sweep, the resource group accumulates one Job per workflow_job.
-func isTerminalProvisioningState(state string) bool {
-	switch strings.ToLower(state) {
-	case "succeeded", "failed", "canceled":
-		return true
+// ReconcilePool brings every label with a pool or concurrency cap in
+// line with its policy. Members are re-read from ACA Job tags and
+// joined with GitHub's runner list for busy state, so the pool
+// survives a dispatcher restart. Errors are logged; the next pass
+// retries.
+func (d *dispatchLoop) ReconcilePool(ctx context.Context) {
+	var active []config.Label
+	for _, l := range d.cfg.Labels {
+		if l.Policy.Active() {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+	runners, err := d.gh.ListRunners(ctx)
+	if err != nil {
+		log.Printf("pool: list github runners: %v", err)
+		return
+	}
+	byName := make(map[string]poller.Runner, len(runners))
+	for _, r := range runners {
+		byName[r.Name] = r
+	}
+	// Labels sharing a (subscription, resource group) share one listing.
+	listed := map[string][]spawner.Managed{}
+	for i := range active {
+		label := &active[i]
+		key := label.SubscriptionID + "/" + label.ResourceGroup
+		managed, ok := listed[key]
+		if !ok {
+			managed, err = spawner.ListManaged(ctx, label.SubscriptionID, label.ResourceGroup)
+			if err != nil {
+				log.Printf("pool: list managed on %s: %v", key, err)
+				continue
+			}
+			listed[key] = managed
+		}
+		var members []pool.Member
+		jobs := map[string]spawner.Managed{}
+		for _, m := range managed {
+			if m.Terminal() || m.Pool != label.Name {
+				continue
+			}
+			jobs[m.RunnerName] = m
+			members = append(members, pool.Member{
+				Name:      m.RunnerName,
+				Warm:      m.Warm,
+				SpawnedAt: m.SpawnedAt,
+				Busy:      byName[m.RunnerName].Busy,
+			})
+		}
+		d.pool.Observe(label.Name, members)
+		plan := d.pool.Plan(label.Name)
+		for _, name := range plan.Retire {
+			d.retire(ctx, label, jobs[name], byName)
+		}
+		for n := 0; n < plan.Spawn; n++ {
+			if err := d.spawnWarm(ctx, label); err != nil {
+				log.Printf("pool: spawn warm runner for %s: %v", label.Name, err)
+				break
+			}
+		}
+	}
+}
+
+// retire removes one idle warm runner. The GitHub registration goes
+// first: GitHub refuses to delete a runner that has just taken a job,
+// which leaves its ACA Job running.
+func (d *dispatchLoop) retire(ctx context.Context, label *config.Label, m spawner.Managed, byName map[string]poller.Runner) {
+	if r, ok := byName[m.RunnerName]; ok {
+		if err := d.gh.DeleteRunner(ctx, r.ID); err != nil {
+			log.Printf("pool: keep %s: delete runner: %v", m.RunnerName, err)
+			return
+		}
+	}
+	if err := spawner.Delete(ctx, label.SubscriptionID, label.ResourceGroup, m.JobName); err != nil {
+		log.Printf("pool: delete %s: %v", m.JobName, err)
+		return
+	}
+	log.Printf("pool: retired idle warm runner %s for %s", m.RunnerName, label.Name)
+}
+
+// spawnWarm starts one warm ACA Job for label: registered with only
+// the pool's label and no job ID, so GitHub hands it the next matching
+// job.
+func (d *dispatchLoop) spawnWarm(ctx context.Context, label *config.Label) error {
+	regToken, err := d.gh.MintRegistrationToken(ctx)
+	if err != nil {
+		return fmt.Errorf("mint registration token: %w", err)
+	}
+	d.warmSeq++
+	runnerName := fmt.Sprintf("dispatcher-azure-warm-%d-%d", time.Now().Unix(), d.warmSeq)
+	jobARMID, err := spawner.Spawn(ctx, spawner.Request{
+		SubscriptionID:  label.SubscriptionID,
+		ResourceGroup:   label.ResourceGroup,
+		Environment:     label.Environment,
+		Location:        label.Location,
+		Image:           label.Image,
+		ManagedIdentity: label.ManagedIdentity,
+		RegToken:        regToken,
+		Repo:            d.gh.Repo,
+		RunnerName:      runnerName,
+		Labels:          []string{label.Name},
+		Pool:            label.Name,
+		Warm:            true,
+		IdleTimeout:     label.Policy.RunnerIdleTimeout(),
+	})
+	if err != nil {
+		return err
+	}
+	d.pool.WarmSpawned(label.Name)
+	log.Printf("pool: spawned warm ACA Job for %s in %s/%s: armid=%s",
+		label.Name, label.SubscriptionID, label.ResourceGroup, jobARMID)
+	return nil
+}
+
+// LogPoolStats logs queue wait and hit rate for every label with a
+// pool or concurrency cap.
+func (d *dispatchLoop) LogPoolStats() {
+	for _, st := range d.pool.Stats() {
+		if d.pool.Policy(st.Label).Active() {
+			log.Printf("pool: %s", st)
+		}
 	}
-	return false
 }
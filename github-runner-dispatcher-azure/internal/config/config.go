@@ -9,10 +9,14 @@
 //	location         = "eastus2"
 //	image            = "myacr.azurecr.io/runners/runner:latest"
 //	managed_identity = "/subscriptions/.../userAssignedIdentities/runner-id"
+//	min_idle         = 1
+//	max_concurrency  = 10
 //
 // Mirror of the GCP config schema; replaces (project, region, service
 // account) with (subscription, resource group, environment, location,
-// managed identity).
+// managed identity). The optional warm-pool keys (`min_idle`,
+// `max_idle`, `max_concurrency`, `idle_ttl`, `cold_start`) are
+// documented on pool.Policy.
 package config
 
 import (
@@ -21,6 +25,8 @@ import (
 	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
 )
 
 // Label maps a runs-on label to an ACA Container Apps Environment +
@@ -33,6 +39,7 @@ type Label struct {
 	Location        string `toml:"location"`
 	Image           string `toml:"image"`
 	ManagedIdentity string `toml:"managed_identity"`
+	pool.Policy
 }
 
 // Config is the on-disk dispatcher config.
@@ -81,6 +88,9 @@ func Load(path string) (Config, error) {
 		if l.Image == "" {
 			return Config{}, fmt.Errorf("label %q: image is required", l.Name)
 		}
+		if err := l.Policy.Validate(); err != nil {
+			return Config{}, fmt.Errorf("label %q: %w", l.Name, err)
+		}
 	}
 	return cfg, nil
 }
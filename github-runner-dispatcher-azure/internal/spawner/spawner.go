@@ -7,7 +7,8 @@
 //
 // Mirror of `github-runner-dispatcher-gcp/internal/spawner` adapted
 // to ACA's two-step shape (Job is the template; JobExecution is the
-// running instance). State recovery uses the Job's resource tags.
+// running instance). State recovery uses the Job's resource tags plus
+// the status of the Job's latest execution.
 package spawner
 
 import (
@@ -15,7 +16,9 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
@@ -32,6 +35,12 @@ const (
 	TagRunnerName = "sockerless-dispatcher-runner-name"
 	TagManagedBy  = "sockerless-dispatcher-managed-by"
 	TagManagedVal = "github-runner-dispatcher-azure"
+	// Warm-pool tags: the config label a runner serves, whether it was
+	// spawned ahead of demand, and when (unix seconds) — the idle_ttl
+	// clock after a restart.
+	TagPool      = "sockerless-dispatcher-pool"
+	TagWarm      = "sockerless-dispatcher-warm"
+	TagSpawnedAt = "sockerless-dispatcher-spawned-at"
 )
 
 // Request is one spawn directive.
@@ -45,7 +54,13 @@ type Request struct {
 	Repo           string // owner/repo for runner registration
 	RunnerName     string // unique name; logs / Actions UI uses it
 	Labels         []string
-	JobID          int64 // GitHub workflow_job ID
+	JobID          int64  // GitHub workflow_job ID; 0 for warm runners
+	Pool           string // config label name — written to TagPool
+	Warm           bool   // warm-pool runner, spawned without a job
+	// IdleTimeout — how long a warm runner may sit before its job
+	// arrives; added to the replica timeout so waiting doesn't eat
+	// into the job's 6 h.
+	IdleTimeout time.Duration
 	// ManagedIdentity is the resource ID of a user-assigned managed
 	// identity the Job execution runs as. Required for the Job to
 	// pull from a private ACR or write to other ARM resources.
@@ -98,13 +113,16 @@ func Spawn(ctx context.Context, req Request) (string, error) {
 			TagManagedBy:  to.Ptr(TagManagedVal),
 			TagJobID:      to.Ptr(fmt.Sprintf("%d", req.JobID)),
 			TagRunnerName: to.Ptr(sanitizeTagValue(req.RunnerName)),
+			TagPool:       to.Ptr(sanitizeTagValue(req.Pool)),
+			TagWarm:       to.Ptr(strconv.FormatBool(req.Warm)),
+			TagSpawnedAt:  to.Ptr(strconv.FormatInt(time.Now().Unix(), 10)),
 		},
 		Properties: &armappcontainers.JobProperties{
 			EnvironmentID: to.Ptr(req.Environment),
 			Configuration: &armappcontainers.JobConfiguration{
 				TriggerType:         to.Ptr(armappcontainers.TriggerTypeManual),
-				ReplicaTimeout:      to.Ptr[int32](21600), // 6h ceiling matches GitHub Actions max job duration
-				ReplicaRetryLimit:   to.Ptr[int32](0),     // one shot
+				ReplicaTimeout:      to.Ptr(int32(21600 + req.IdleTimeout/time.Second)), // 6h ceiling matches GitHub Actions max job duration
+				ReplicaRetryLimit:   to.Ptr[int32](0),                                   // one shot
 				ManualTriggerConfig: &armappcontainers.JobConfigurationManualTriggerConfig{Parallelism: to.Ptr[int32](1), ReplicaCompletionCount: to.Ptr[int32](1)},
 			},
 			Template: &armappcontainers.JobTemplate{
@@ -177,6 +195,30 @@ type Managed struct {
 	JobID      int64
 	RunnerName string
 	State      string // ACA provisioning state
+	// Execution is the status of the Job's latest execution ("Running",
+	// "Succeeded", …), empty when it has none. ProvisioningState only
+	// tracks the Job resource — it reads Succeeded while the runner is
+	// still running.
+	Execution string
+	Pool      string
+	Warm      bool
+	SpawnedAt time.Time
+}
+
+// Terminal reports whether the Job is done with its runner: its
+// provisioning failed, or its latest execution ended.
+func (m Managed) Terminal() bool {
+	switch strings.ToLower(m.State) {
+	case "failed", "canceled":
+		return true
+	}
+	switch m.Execution {
+	case string(armappcontainers.JobExecutionRunningStateSucceeded),
+		string(armappcontainers.JobExecutionRunningStateFailed),
+		string(armappcontainers.JobExecutionRunningStateStopped):
+		return true
+	}
+	return false
 }
 
 // ListManaged returns every ACA Job under (subscription, resource
@@ -190,6 +232,10 @@ func ListManaged(ctx context.Context, subscriptionID, resourceGroup string) ([]M
 	if err != nil {
 		return nil, fmt.Errorf("jobs client: %w", err)
 	}
+	execClient, err := armappcontainers.NewJobsExecutionsClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("jobs executions client: %w", err)
+	}
 	pager := jobsClient.NewListByResourceGroupPager(resourceGroup, nil)
 	var managed []Managed
 	for pager.More() {
@@ -201,30 +247,61 @@ func ListManaged(ctx context.Context, subscriptionID, resourceGroup string) ([]M
 			if j.Tags[TagManagedBy] == nil || *j.Tags[TagManagedBy] != TagManagedVal {
 				continue
 			}
-			var jobID int64
-			if j.Tags[TagJobID] != nil {
-				fmt.Sscanf(*j.Tags[TagJobID], "%d", &jobID)
+			m := Managed{
+				JobARMID:   ptrStr(j.ID),
+				JobName:    ptrStr(j.Name),
+				RunnerName: ptrStr(j.Tags[TagRunnerName]),
+				Pool:       ptrStr(j.Tags[TagPool]),
+				Warm:       ptrStr(j.Tags[TagWarm]) == "true",
 			}
-			runnerName := ""
-			if j.Tags[TagRunnerName] != nil {
-				runnerName = *j.Tags[TagRunnerName]
+			fmt.Sscanf(ptrStr(j.Tags[TagJobID]), "%d", &m.JobID)
+			if secs, err := strconv.ParseInt(ptrStr(j.Tags[TagSpawnedAt]), 10, 64); err == nil && secs > 0 {
+				m.SpawnedAt = time.Unix(secs, 0)
 			}
-			state := ""
 			if j.Properties != nil && j.Properties.ProvisioningState != nil {
-				state = string(*j.Properties.ProvisioningState)
+				m.State = string(*j.Properties.ProvisioningState)
 			}
-			managed = append(managed, Managed{
-				JobARMID:   ptrStr(j.ID),
-				JobName:    ptrStr(j.Name),
-				JobID:      jobID,
-				RunnerName: runnerName,
-				State:      state,
-			})
+			m.Execution = latestExecution(ctx, execClient, resourceGroup, m.JobName)
+			managed = append(managed, m)
 		}
 	}
 	return managed, nil
 }
 
+// latestExecution returns the status of the Job's most recently
+// started execution, or "" when it has none. A listing error reads as
+// "Unknown", which Terminal treats as still running — cleanup waits a
+// sweep rather than deleting a live runner.
+func latestExecution(ctx context.Context, cli *armappcontainers.JobsExecutionsClient, resourceGroup, jobName string) string {
+	pager := cli.NewListPager(resourceGroup, jobName, nil)
+	var latest *armappcontainers.JobExecution
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return string(armappcontainers.JobExecutionRunningStateUnknown)
+		}
+		for _, ex := range page.Value {
+			if ex.Properties == nil || ex.Properties.Status == nil {
+				continue
+			}
+			if latest == nil || startedAfter(ex, latest) {
+				latest = ex
+			}
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return string(*latest.Properties.Status)
+}
+
+func startedAfter(a, b *armappcontainers.JobExecution) bool {
+	if a.Properties.StartTime == nil || b.Properties.StartTime == nil {
+		return false
+	}
+	return a.Properties.StartTime.After(*b.Properties.StartTime)
+}
+
 // Delete removes an ACA Job. Tolerates NOT_FOUND.
 func Delete(ctx context.Context, subscriptionID, resourceGroup, jobName string) error {
 	cred, err := azidentity.NewDefaultAzureCredential(nil)
@@ -19,8 +19,13 @@
 //	  github-runner-dispatcher-gcp --repo owner/repo --token {}
 //
 // Same flag surface as the AWS dispatcher (`--repo`, `--token`,
-// `--config`, `--once`, `--cleanup-only`); config schema documented
-// in `internal/config/config.go`.
+// `--config`, `--once`, `--cleanup-only`, `--webhook-addr`,
+// `--webhook-secret`, `--reconcile-interval`, `--metrics-addr`); config schema
+// documented in `internal/config/config.go`, including the per-label
+// warm pool (`min_idle`, `max_concurrency`, …) whose state is re-read
+// from Cloud Run Job labels after a restart. On Cloud Run the
+// `workflow_job` webhook ingress is served on $PORT next to /healthz
+// whenever a webhook secret is configured.
 package main
 
 import (
@@ -36,7 +41,9 @@ import (
 	"time"
 
 	"github.com/sockerless/github-runner-dispatcher-aws/pkg/poller"
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
 	"github.com/sockerless/github-runner-dispatcher-aws/pkg/scopes"
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/webhook"
 	"github.com/sockerless/github-runner-dispatcher-gcp/internal/config"
 	"github.com/sockerless/github-runner-dispatcher-gcp/internal/spawner"
 )
@@ -54,6 +61,10 @@ func run() error {
 	configPath := flag.String("config", "", "path to dispatcher config.toml; default ~/.sockerless/dispatcher-gcp/config.toml")
 	once := flag.Bool("once", false, "run a single poll cycle and exit (smoke / debug)")
 	cleanupOnly := flag.Bool("cleanup-only", false, "run a single GC sweep (Cloud Run Jobs + GitHub runners) and exit; no polling")
+	webhookAddr := flag.String("webhook-addr", "", "listen address for the workflow_job webhook ingress; default $PORT when a webhook secret is set")
+	webhookSecret := flag.String("webhook-secret", os.Getenv("GITHUB_WEBHOOK_SECRET"), "webhook secret; default $GITHUB_WEBHOOK_SECRET")
+	reconcileEvery := flag.Duration("reconcile-interval", 0, "reconciliation poll cadence; default 60s polling only, 2m with the webhook ingress")
+	metricsAddr := flag.String("metrics-addr", "", "listen address for the Prometheus /metrics endpoint (e.g. :9090); empty = off")
 	flag.Parse()
 
 	// Cloud Run / serverless deployment: --repo and --token can come
@@ -70,15 +81,50 @@ func run() error {
 		return fmt.Errorf("github token is empty — set $GITHUB_TOKEN, run `gh auth token | …`, or pass --token=…")
 	}
 
+	if *webhookAddr != "" && *webhookSecret == "" {
+		return fmt.Errorf("--webhook-addr needs a secret — set $GITHUB_WEBHOOK_SECRET or pass --webhook-secret=… (the same value as the repo hook's secret)")
+	}
+
+	// Webhook ingress: deliveries land on `jobs`, drained by the main
+	// loop alongside the reconciliation poll so dedup stays
+	// single-threaded. Cloud Run exposes only $PORT, so that's where
+	// the handler goes unless --webhook-addr says otherwise.
+	jobs := make(chan poller.Job, 64)
+	var hook *webhook.Handler
+	if *webhookSecret != "" {
+		h, err := webhook.New(*webhookSecret, *repo, jobs)
+		if err != nil {
+			return err
+		}
+		hook = h
+		if *webhookAddr == "" && os.Getenv("PORT") == "" {
+			return fmt.Errorf("webhook secret is set but there is nowhere to serve it — set $PORT or --webhook-addr")
+		}
+	}
+	if hook != nil && *webhookAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(webhook.Path, hook)
+		go func() {
+			log.Printf("webhook ingress listening on %s%s", *webhookAddr, webhook.Path)
+			if err := http.ListenAndServe(*webhookAddr, mux); err != nil {
+				log.Fatalf("webhook listen: %v", err)
+			}
+		}()
+	}
+
 	// $PORT is set by Cloud Run; the container must bind it within
 	// the startup probe budget or the revision is killed. Tiny
-	// /healthz responder; the polling loop runs in a goroutine.
+	// /healthz responder (plus the webhook ingress when enabled); the
+	// polling loop runs in a goroutine.
 	if port := os.Getenv("PORT"); port != "" {
 		mux := http.NewServeMux()
 		mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("ok"))
 		})
+		if hook != nil && *webhookAddr == "" {
+			mux.Handle(webhook.Path, hook)
+		}
 		go func() {
 			log.Printf("dispatcher-gcp http listening on :%s", port)
 			if err := http.ListenAndServe(":"+port, mux); err != nil {
@@ -135,8 +181,8 @@ func run() error {
 			}
 		}
 	}
-	log.Printf("dispatcher-gcp ready: repo=%s labels=%d once=%v cleanup-only=%v",
-		*repo, len(cfg.Labels), *once, *cleanupOnly)
+	log.Printf("dispatcher-gcp ready: repo=%s labels=%d once=%v cleanup-only=%v webhook=%v",
+		*repo, len(cfg.Labels), *once, *cleanupOnly, hook != nil)
 
 	gh := poller.New(http.DefaultClient, *token, *repo)
 	loop := newDispatchLoop(gh, cfg)
@@ -153,6 +199,24 @@ func run() error {
 		return loop.Cleanup(ctx)
 	}
 
+	// Pool metrics (queue wait, hit rate, runner counts) for a
+	// Prometheus scrape.
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", loop.pool.Handler("github-gcp"))
+		srv := &http.Server{Addr: *metricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("metrics: %v", err)
+				cancel()
+			}
+		}()
+		defer srv.Close()
+		log.Printf("metrics listening on %s/metrics", *metricsAddr)
+	}
+
+	// Shutdown only sweeps terminated Jobs: warm runners stay
+	// registered so the next dispatcher instance resumes the pool.
 	defer func() {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -163,99 +227,149 @@ func run() error {
 
 	cleanupTicker := time.NewTicker(2 * time.Minute)
 	defer cleanupTicker.Stop()
+	poolTicker := time.NewTicker(poolInterval)
+	defer poolTicker.Stop()
+	loop.ReconcilePool(ctx)
 	pollEvery := gh.PollInterval()
+	if hook != nil {
+		// Reconciliation only catches missed deliveries; keep it under
+		// the seen-set TTL so a re-seed is always fresher than expiry.
+		pollEvery = 2 * time.Minute
+	}
+	if *reconcileEvery > 0 {
+		pollEvery = *reconcileEvery
+	}
 	// rateLimitedUntil tracks the wall-clock time before which Step() must
 	// not be called again. Set when a poll returns a rate-limit error;
-	// honored across BOTH the normal pollEvery tick AND the cleanup tick
-	// so cleanup firing every 2m doesn't re-trigger GitHub calls.
+	// the poll timer is re-armed past it, and webhook-delivered jobs
+	// and the cleanup tick don't touch the poll schedule at all.
 	var rateLimitedUntil time.Time
+	pollTimer := time.NewTimer(0)
+	defer pollTimer.Stop()
 	for {
-		nextPoll := pollEvery
-		if !time.Now().Before(rateLimitedUntil) {
-			if err := loop.Step(ctx); err != nil {
-				if wait, ok := poller.AsRateLimit(err); ok {
-					log.Printf("poll error: rate-limited, sleeping %s (upstream reset + 10%% + 1s): %v", wait, err)
-					nextPoll = wait
-					rateLimitedUntil = time.Now().Add(wait)
-				} else {
-					log.Printf("poll error (continuing): %v", err)
-				}
-			}
-		} else {
-			// Skip Step entirely while we're inside the rate-limit window.
-			// Wait out the remaining time before retrying.
-			nextPoll = time.Until(rateLimitedUntil)
-			if nextPoll < pollEvery {
-				nextPoll = pollEvery
-			}
-		}
 		select {
 		case <-ctx.Done():
 			return nil
+		case job := <-jobs:
+			loop.dispatch(ctx, job)
 		case <-cleanupTicker.C:
 			// Cleanup queries Cloud Run Jobs API (not GitHub), so it's
-			// safe to run during a GitHub rate-limit window. The loop
-			// re-enters and the rateLimitedUntil guard above prevents
-			// the next Step() from re-firing the GitHub poll.
+			// safe to run during a GitHub rate-limit window.
 			if err := loop.Cleanup(ctx); err != nil {
 				log.Printf("cleanup error (continuing): %v", err)
 			}
-		case <-time.After(nextPoll):
+			loop.LogPoolStats()
+		case <-poolTicker.C:
+			// ReconcilePool lists GitHub runners; sit out a rate-limit
+			// window like the poll does.
+			if time.Now().After(rateLimitedUntil) {
+				loop.ReconcilePool(ctx)
+			}
+		case <-pollTimer.C:
+			nextPoll := pollEvery
+			if !time.Now().Before(rateLimitedUntil) {
+				if err := loop.Step(ctx); err != nil {
+					if wait, ok := poller.AsRateLimit(err); ok {
+						log.Printf("poll error: rate-limited, sleeping %s (upstream reset + 10%% + 1s): %v", wait, err)
+						nextPoll = wait
+						rateLimitedUntil = time.Now().Add(wait)
+					} else {
+						log.Printf("poll error (continuing): %v", err)
+					}
+				}
+			} else if until := time.Until(rateLimitedUntil); until > nextPoll {
+				nextPoll = until
+			}
+			pollTimer.Reset(nextPoll)
 		}
 	}
 }
 
+// poolInterval is how often ReconcilePool tops up and trims the warm
+// pools.
+const poolInterval = 30 * time.Second
+
 type dispatchLoop struct {
-	gh  *poller.Client
-	cfg config.Config
+	gh      *poller.Client
+	cfg     config.Config
+	pool    *pool.Pool
+	warmSeq int // disambiguates warm runner names spawned in the same second
 }
 
 func newDispatchLoop(gh *poller.Client, cfg config.Config) *dispatchLoop {
-	return &dispatchLoop{gh: gh, cfg: cfg}
+	p := pool.New()
+	for _, l := range cfg.Labels {
+		p.Configure(l.Name, l.Policy)
+	}
+	return &dispatchLoop{gh: gh, cfg: cfg, pool: p}
 }
 
+// Step is one reconciliation pass: re-seed the seen-set from active
+// Cloud Run Jobs, poll for queued jobs, and dispatch each one.
 func (d *dispatchLoop) Step(ctx context.Context) error {
+	d.seedSeen(ctx, false)
 	jobs, err := d.gh.PollOnce(ctx)
 	if err != nil {
 		return err
 	}
 	for _, job := range jobs {
-		label := pickKnownLabel(job.Labels, d.cfg)
-		if label == nil {
-			log.Printf("skip job %d (%s): no matching dispatcher label in %v", job.JobID, job.Name, job.Labels)
-			d.gh.Mark(job.JobID)
-			continue
-		}
-		regToken, err := d.gh.MintRegistrationToken(ctx)
-		if err != nil {
-			log.Printf("skip job %d: mint registration token: %v", job.JobID, err)
-			continue
-		}
-		runnerName := fmt.Sprintf("dispatcher-gcp-%d-%d", job.JobID, time.Now().Unix())
-		fullName, err := spawner.Spawn(ctx, spawner.Request{
-			Project:                label.Project,
-			Region:                 label.Region,
-			Image:                  label.Image,
-			ServiceAccount:         label.ServiceAccount,
-			RegToken:               regToken,
-			Repo:                   job.Repo,
-			RunnerName:             runnerName,
-			Labels:                 job.Labels,
-			JobID:                  job.JobID,
-			RunnerWorkspaceBucket:  label.RunnerWorkspaceBucket,
-			RunnerWorkspaceBacking: label.RunnerWorkspaceBacking,
-		})
-		if err != nil {
-			log.Printf("skip job %d: spawn (%s/%s): %v", job.JobID, label.Project, label.Region, err)
-			continue
-		}
-		log.Printf("spawned Cloud Run Job for job %d (%s) at %s/%s: name=%s url=%s",
-			job.JobID, label.Name, label.Project, label.Region, fullName, job.JobURL)
-		d.gh.Mark(job.JobID)
+		d.dispatch(ctx, job)
 	}
 	return nil
 }
 
+// dispatch creates a Cloud Run Job for one queued job. Failures are
+// logged and leave the job unmarked so the next pass retries it.
+func (d *dispatchLoop) dispatch(ctx context.Context, job poller.Job) {
+	if d.gh.IsMarked(job.JobID) {
+		log.Printf("skip job %d (%s): already dispatched", job.JobID, job.Name)
+		return
+	}
+	label := pickKnownLabel(job.Labels, d.cfg)
+	if label == nil {
+		log.Printf("skip job %d (%s): no matching dispatcher label in %v", job.JobID, job.Name, job.Labels)
+		d.gh.Mark(job.JobID)
+		return
+	}
+	switch d.pool.Admit(label.Name, job.JobID, job.Labels, job.QueuedAt) {
+	case pool.Hit:
+		log.Printf("job %d (%s): warm-pool hit, an idle runner will take it", job.JobID, label.Name)
+		d.gh.Mark(job.JobID)
+		return
+	case pool.Defer:
+		log.Printf("defer job %d (%s): max_concurrency %d reached", job.JobID, label.Name, label.MaxConcurrency)
+		return
+	}
+	regToken, err := d.gh.MintRegistrationToken(ctx)
+	if err != nil {
+		log.Printf("skip job %d: mint registration token: %v", job.JobID, err)
+		return
+	}
+	runnerName := fmt.Sprintf("dispatcher-gcp-%d-%d", job.JobID, time.Now().Unix())
+	fullName, err := spawner.Spawn(ctx, spawner.Request{
+		Project:                label.Project,
+		Region:                 label.Region,
+		Image:                  label.Image,
+		ServiceAccount:         label.ServiceAccount,
+		RegToken:               regToken,
+		Repo:                   job.Repo,
+		RunnerName:             runnerName,
+		Labels:                 job.Labels,
+		JobID:                  job.JobID,
+		Pool:                   label.Name,
+		RunnerWorkspaceBucket:  label.RunnerWorkspaceBucket,
+		RunnerWorkspaceBacking: label.RunnerWorkspaceBacking,
+	})
+	if err != nil {
+		log.Printf("skip job %d: spawn (%s/%s): %v", job.JobID, label.Project, label.Region, err)
+		return
+	}
+	d.pool.Spawned(label.Name, job.JobID, job.QueuedAt)
+	log.Printf("spawned Cloud Run Job for job %d (%s) at %s/%s: name=%s url=%s",
+		job.JobID, label.Name, label.Project, label.Region, fullName, job.JobURL)
+	d.gh.Mark(job.JobID)
+}
+
 func pickKnownLabel(labels []string, cfg config.Config) *config.Label {
 	for _, l := range labels {
 		if got := cfg.LookupLabel(l); got != nil {
@@ -270,6 +384,12 @@ func pickKnownLabel(labels []string, cfg config.Config) *config.Label {
 // startup. A per-(project, region) error is logged and skipped — a
 // transient API outage is normal and the next poll will re-check.
 func (d *dispatchLoop) RecoverState(ctx context.Context) {
+	d.seedSeen(ctx, true)
+}
+
+// seedSeen adds the job ID of every dispatcher-managed Cloud Run
+// Job to the seen-set. Step calls it quietly before each poll.
+func (d *dispatchLoop) seedSeen(ctx context.Context, verbose bool) {
 	now := d.gh.Now()
 	seen := map[string]bool{}
 	for _, label := range d.cfg.Labels {
@@ -280,7 +400,9 @@ func (d *dispatchLoop) RecoverState(ctx context.Context) {
 		seen[key] = true
 		managed, err := spawner.ListManaged(ctx, label.Project, label.Region)
 		if err != nil {
-			log.Printf("recover: list managed on %s failed: %v", key, err)
+			if verbose {
+				log.Printf("recover: list managed on %s failed: %v", key, err)
+			}
 			continue
 		}
 		for _, m := range managed {
@@ -288,8 +410,10 @@ func (d *dispatchLoop) RecoverState(ctx context.Context) {
 				continue
 			}
 			d.gh.Seen.Add(m.JobID, now)
-			log.Printf("recover: seen-set restored for job %d (cloud-run-job %s, state=%s)",
-				m.JobID, m.JobName, m.State)
+			if verbose {
+				log.Printf("recover: seen-set restored for job %d (cloud-run-job %s, state=%s)",
+					m.JobID, m.JobName, m.State)
+			}
 		}
 	}
 }
@@ -374,6 +498,133 @@ func (d *dispatchLoop) Cleanup(ctx context.Context) error {
 	return nil
 }
 
+// ReconcilePool brings every label with a pool or concurrency cap in
+// line with its policy. Members are re-read from Cloud Run Job labels
+// and joined with GitHub's runner list for busy state, so the pool
+// survives a dispatcher restart. Errors are logged; the next pass
+// retries.
+func (d *dispatchLoop) ReconcilePool(ctx context.Context) {
+	var active []config.Label
+	for _, l := range d.cfg.Labels {
+		if l.Policy.Active() {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+	runners, err := d.gh.ListRunners(ctx)
+	if err != nil {
+		log.Printf("pool: list github runners: %v", err)
+		return
+	}
+	byName := make(map[string]poller.Runner, len(runners))
+	for _, r := range runners {
+		byName[r.Name] = r
+	}
+	// Labels sharing a (project, region) share one listing.
+	listed := map[string][]spawner.Managed{}
+	for i := range active {
+		label := &active[i]
+		key := label.Project + "/" + label.Region
+		managed, ok := listed[key]
+		if !ok {
+			managed, err = spawner.ListManaged(ctx, label.Project, label.Region)
+			if err != nil {
+				log.Printf("pool: list managed on %s: %v", key, err)
+				continue
+			}
+			listed[key] = managed
+		}
+		var members []pool.Member
+		jobs := map[string]spawner.Managed{}
+		for _, m := range managed {
+			if !m.Live() || !m.InPool(label.Name) {
+				continue
+			}
+			jobs[m.RunnerName] = m
+			members = append(members, pool.Member{
+				Name:      m.RunnerName,
+				Warm:      m.Warm,
+				SpawnedAt: m.SpawnedAt,
+				Busy:      byName[m.RunnerName].Busy,
+			})
+		}
+		d.pool.Observe(label.Name, members)
+		plan := d.pool.Plan(label.Name)
+		for _, name := range plan.Retire {
+			d.retire(ctx, label, jobs[name], byName)
+		}
+		for n := 0; n < plan.Spawn; n++ {
+			if err := d.spawnWarm(ctx, label); err != nil {
+				log.Printf("pool: spawn warm runner for %s: %v", label.Name, err)
+				break
+			}
+		}
+	}
+}
+
+// retire removes one idle warm runner. The GitHub registration goes
+// first: GitHub refuses to delete a runner that has just taken a job,
+// which leaves its Cloud Run Job running.
+func (d *dispatchLoop) retire(ctx context.Context, label *config.Label, m spawner.Managed, byName map[string]poller.Runner) {
+	if r, ok := byName[m.RunnerName]; ok {
+		if err := d.gh.DeleteRunner(ctx, r.ID); err != nil {
+			log.Printf("pool: keep %s: delete runner: %v", m.RunnerName, err)
+			return
+		}
+	}
+	if err := spawner.Delete(ctx, m.JobName); err != nil {
+		log.Printf("pool: delete %s: %v", m.JobName, err)
+		return
+	}
+	log.Printf("pool: retired idle warm runner %s for %s", m.RunnerName, label.Name)
+}
+
+// spawnWarm creates one warm Cloud Run Job for label: registered with
+// only the pool's label and no job ID, so GitHub hands it the next
+// matching job.
+func (d *dispatchLoop) spawnWarm(ctx context.Context, label *config.Label) error {
+	regToken, err := d.gh.MintRegistrationToken(ctx)
+	if err != nil {
+		return fmt.Errorf("mint registration token: %w", err)
+	}
+	d.warmSeq++
+	runnerName := fmt.Sprintf("dispatcher-gcp-warm-%d-%d", time.Now().Unix(), d.warmSeq)
+	fullName, err := spawner.Spawn(ctx, spawner.Request{
+		Project:                label.Project,
+		Region:                 label.Region,
+		Image:                  label.Image,
+		ServiceAccount:         label.ServiceAccount,
+		RegToken:               regToken,
+		Repo:                   d.gh.Repo,
+		RunnerName:             runnerName,
+		Labels:                 []string{label.Name},
+		Pool:                   label.Name,
+		Warm:                   true,
+		IdleTimeout:            label.Policy.RunnerIdleTimeout(),
+		RunnerWorkspaceBucket:  label.RunnerWorkspaceBucket,
+		RunnerWorkspaceBacking: label.RunnerWorkspaceBacking,
+	})
+	if err != nil {
+		return err
+	}
+	d.pool.WarmSpawned(label.Name)
+	log.Printf("pool: spawned warm Cloud Run Job for %s at %s/%s: name=%s",
+		label.Name, label.Project, label.Region, fullName)
+	return nil
+}
+
+// LogPoolStats logs queue wait and hit rate for every label with a
+// pool or concurrency cap.
+func (d *dispatchLoop) LogPoolStats() {
+	for _, st := range d.pool.Stats() {
+		if d.pool.Policy(st.Label).Active() {
+			log.Printf("pool: %s", st)
+		}
+	}
+}
+
 // isTerminalJobState returns true for execution states that indicate
 // the runner-task has finished. State strings come from spawner.
 // executionStateForJob — values are EXECUTION_SUCCEEDED /
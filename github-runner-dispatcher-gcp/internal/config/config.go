@@ -7,6 +7,8 @@
 //	gcp_region       = "us-central1"
 //	image            = "us-central1-docker.pkg.dev/my-project/runners/runner:latest"
 //	service_account  = "github-runners@my-project.iam.gserviceaccount.com"
+//	min_idle         = 1
+//	max_concurrency  = 10
 //
 //	[[label]]
 //	name             = "sockerless-gcf"
@@ -17,7 +19,9 @@
 //
 // Same shape as the AWS dispatcher's config but with GCP-side
 // addressing (project + region + service account) replacing the
-// docker-host indirection. CLI flags override individual entries;
+// docker-host indirection. The optional warm-pool keys (`min_idle`,
+// `max_idle`, `max_concurrency`, `idle_ttl`, `cold_start`) are shared
+// with the AWS dispatcher and documented on pool.Policy. CLI flags override individual entries;
 // config file is optional (empty config means "no labels mapped —
 // every job is skipped with a warning").
 package config
@@ -28,6 +32,8 @@ import (
 	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
 )
 
 // Label maps a runs-on label to a GCP project + region + runner image.
@@ -69,6 +75,7 @@ type Label struct {
 	// Required when RunnerWorkspaceBucket is set — no automatic fallback
 	// per the storage-backing no-fallbacks directive.
 	RunnerWorkspaceBacking string `toml:"runner_workspace_backing"`
+	pool.Policy
 }
 
 // Config is the on-disk dispatcher config.
@@ -124,6 +131,9 @@ func Load(path string) (Config, error) {
 				return Config{}, fmt.Errorf("label %q: runner_workspace_backing %q invalid (must be %q or %q)", l.Name, l.RunnerWorkspaceBacking, "gcs-fuse", "gcs-sync")
 			}
 		}
+		if err := l.Policy.Validate(); err != nil {
+			return Config{}, fmt.Errorf("label %q: %w", l.Name, err)
+		}
 	}
 	return cfg, nil
 }
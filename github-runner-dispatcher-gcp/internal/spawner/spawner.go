@@ -22,6 +22,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,6 +40,12 @@ const (
 	LabelRunnerName = "sockerless-dispatcher-runner-name"
 	LabelManagedBy  = "sockerless-dispatcher-managed-by"
 	LabelManagedVal = "github-runner-dispatcher-gcp"
+	// Warm-pool labels: the config label a runner serves (sanitized),
+	// whether it was spawned ahead of demand, and when (unix seconds)
+	// — the idle_ttl clock after a restart.
+	LabelPool      = "sockerless-dispatcher-pool"
+	LabelWarm      = "sockerless-dispatcher-warm"
+	LabelSpawnedAt = "sockerless-dispatcher-spawned-at"
 )
 
 // OwnerRunnerTaskLabel is the GCP label key sockerless writes on every
@@ -61,8 +68,14 @@ type Request struct {
 	Repo           string // owner/repo for runner registration
 	RunnerName     string // unique name; logs / Actions UI uses it
 	Labels         []string
-	JobID          int64  // GitHub workflow_job ID — written to LabelJobID for restart recovery
+	JobID          int64  // GitHub workflow_job ID — written to LabelJobID for restart recovery; 0 for warm runners
 	ServiceAccount string // GCP service account email (Job execution identity)
+	Pool           string // config label name — written to LabelPool
+	Warm           bool   // warm-pool runner, spawned without a job
+	// IdleTimeout — how long a warm runner may sit before its job
+	// arrives; added to the task timeout so waiting doesn't eat into
+	// the job's hour.
+	IdleTimeout time.Duration
 	// RunnerWorkspaceBucket — when set, attach a Cloud Run native
 	// `Volume{Gcs{Bucket}}` at /tmp/runner-work on the spawned
 	// runner-task so step-script files written by the runner agent
@@ -179,7 +192,7 @@ func Spawn(ctx context.Context, req Request) (string, error) {
 			// Cloud Run Job task_timeout default 10 min; bump to 1h to
 			// fit a real CI pipeline. This is a Cloud Run resource
 			// limit, not a sockerless-shaped concern.
-			Timeout: durationpb.New(3600 * time.Second),
+			Timeout: durationpb.New(3600*time.Second + req.IdleTimeout),
 		},
 	}
 	if req.ServiceAccount != "" {
@@ -197,6 +210,9 @@ func Spawn(ctx context.Context, req Request) (string, error) {
 				LabelManagedBy:  LabelManagedVal,
 				LabelJobID:      fmt.Sprintf("%d", req.JobID),
 				LabelRunnerName: sanitizeLabelValue(req.RunnerName),
+				LabelPool:       sanitizeLabelValue(req.Pool),
+				LabelWarm:       strconv.FormatBool(req.Warm),
+				LabelSpawnedAt:  strconv.FormatInt(time.Now().Unix(), 10),
 			},
 			Template: template,
 		},
@@ -261,7 +277,21 @@ type Managed struct {
 	JobName    string // full resource name `projects/.../jobs/<id>`
 	JobID      int64  // GitHub workflow_job ID from labels
 	RunnerName string
-	State      string // "active", "deleted", … (Cloud Run Job state)
+	State      string // latest execution state — see executionStateForJob
+	Pool       string // LabelPool value (sanitized config label name)
+	Warm       bool
+	SpawnedAt  time.Time
+}
+
+// Live reports whether the Job's runner-task hasn't finished: its
+// execution is running or not yet started.
+func (m Managed) Live() bool {
+	return m.State == "EXECUTION_RUNNING" || m.State == "NO_EXECUTION"
+}
+
+// InPool reports whether the Job was spawned for config label `label`.
+func (m Managed) InPool(label string) bool {
+	return m.Pool != "" && m.Pool == sanitizeLabelValue(label)
 }
 
 // ListManaged returns every Cloud Run Job under (project, region)
@@ -284,18 +314,29 @@ func ListManaged(ctx context.Context, project, region string) ([]Managed, error)
 		if j.Labels[LabelManagedBy] != LabelManagedVal {
 			continue
 		}
-		var jobID int64
-		fmt.Sscanf(j.Labels[LabelJobID], "%d", &jobID)
-		managed = append(managed, Managed{
-			JobName:    j.Name,
-			JobID:      jobID,
-			RunnerName: j.Labels[LabelRunnerName],
-			State:      executionStateForJob(ctx, j),
-		})
+		managed = append(managed, fromLabels(j.Name, j.Labels, executionStateForJob(ctx, j)))
 	}
 	return managed, nil
 }
 
+// fromLabels rebuilds a Managed from a Job's labels. Jobs created
+// before the pool labels existed come back cold, with no pool and a
+// zero spawn time.
+func fromLabels(name string, labels map[string]string, state string) Managed {
+	m := Managed{
+		JobName:    name,
+		RunnerName: labels[LabelRunnerName],
+		State:      state,
+		Pool:       labels[LabelPool],
+		Warm:       labels[LabelWarm] == "true",
+	}
+	fmt.Sscanf(labels[LabelJobID], "%d", &m.JobID)
+	if secs, err := strconv.ParseInt(labels[LabelSpawnedAt], 10, 64); err == nil && secs > 0 {
+		m.SpawnedAt = time.Unix(secs, 0)
+	}
+	return m
+}
+
 // executionStateForJob returns the state of the Job's most-recent
 // Execution. A Cloud Run Job's `TerminalCondition` reflects the JOB
 // DEFINITION's reconciliation state (Ready / NotReady), NOT the
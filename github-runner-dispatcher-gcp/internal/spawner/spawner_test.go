@@ -31,3 +31,27 @@ func TestJobIDFromRunnerName_deterministic(t *testing.T) {
 		t.Errorf("not deterministic: %s vs %s", a, b)
 	}
 }
+
+func TestFromLabelsPool(t *testing.T) {
+	m := fromLabels("projects/p/locations/r/jobs/gh-abc-0", map[string]string{
+		LabelManagedBy:  LabelManagedVal,
+		LabelJobID:      "0",
+		LabelRunnerName: "dispatcher-gcp-warm-1700000000-1",
+		LabelPool:       sanitizeLabelValue("Sockerless-CloudRun"),
+		LabelWarm:       "true",
+		LabelSpawnedAt:  "1700000000",
+	}, "EXECUTION_RUNNING")
+	if !m.Warm || !m.InPool("Sockerless-CloudRun") || m.SpawnedAt.Unix() != 1700000000 || !m.Live() {
+		t.Errorf("warm job = %+v", m)
+	}
+
+	// Jobs created before the pool labels existed parse as cold and
+	// belong to no pool.
+	legacy := fromLabels("projects/p/locations/r/jobs/gh-def-9001", map[string]string{
+		LabelJobID:      "9001",
+		LabelRunnerName: "dispatcher-gcp-9001-1700000000",
+	}, "EXECUTION_SUCCEEDED")
+	if legacy.Warm || legacy.InPool("") || legacy.JobID != 9001 || legacy.Live() {
+		t.Errorf("legacy job = %+v", legacy)
+	}
+}
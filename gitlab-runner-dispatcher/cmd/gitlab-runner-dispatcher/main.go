@@ -0,0 +1,618 @@
+// gitlab-runner-dispatcher is a laptop-foreground binary that watches a
+// GitLab project for pending CI jobs and spawns one ephemeral
+// gitlab-runner container per job — the GitLab counterpart of
+// github-runner-dispatcher. Each tag in config.toml maps to a sockerless
+// daemon (ECS, Cloud Run Jobs, ACA Jobs backend), so the runner lands on
+// the cloud instead of a long-lived `gitlab-runner` with the docker
+// executor.
+//
+// Usage:
+//
+//		GITLAB_TOKEN=glpat-… gitlab-runner-dispatcher --project group/project
+//
+//	  - --project is mandatory (no default; explicit-only).
+//	  - --token defaults to $GITLAB_TOKEN; missing → fail with guidance.
+//	  - Scopes verified at startup (`api`, or read_api + create_runner +
+//	    manage_runner); missing → fail with instructions.
+//	  - Job sources: Job Hooks on --webhook-addr (optional, checked
+//	    against --webhook-secret) plus a reconciliation poll every
+//	    --reconcile-interval; dedup via 5-min seen-set re-seeded from
+//	    container labels before every poll.
+//	  - Per job: `POST /user/runners` creates a runner locked to the
+//	    project and the job's tags; its `glrt-` token goes to the spawned
+//	    container, which runs one job and exits.
+//	  - Warm pool: tags with `min_idle` / `max_idle` keep idle runners
+//	    created with only that tag; `max_concurrency` caps live runners
+//	    per tag (jobs over the cap wait for the next pass). Pool state is
+//	    re-read from container labels, so a restart resumes it.
+//	  - Metrics: --metrics-addr serves queue wait, hit rate and runner
+//	    counts on /metrics in the Prometheus text format.
+//	  - Failure handling: log + skip; the next poll retries.
+//	  - Logs to stdout only.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
+	"github.com/sockerless/gitlab-runner-dispatcher/internal/config"
+	"github.com/sockerless/gitlab-runner-dispatcher/internal/spawner"
+	"github.com/sockerless/gitlab-runner-dispatcher/pkg/gitlab"
+	"github.com/sockerless/gitlab-runner-dispatcher/pkg/webhook"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "gitlab-runner-dispatcher: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	project := flag.String("project", "", "group/project path or numeric ID (mandatory; no default)")
+	token := flag.String("token", os.Getenv("GITLAB_TOKEN"), "GitLab PAT; default $GITLAB_TOKEN")
+	serverURL := flag.String("gitlab-url", envOr("GITLAB_URL", "https://gitlab.com"), "GitLab instance URL; default $GITLAB_URL or https://gitlab.com")
+	configPath := flag.String("config", "", "path to dispatcher config.toml; default ~/.sockerless/gitlab-dispatcher/config.toml")
+	once := flag.Bool("once", false, "run a single poll cycle and exit (smoke / debug)")
+	cleanupOnly := flag.Bool("cleanup-only", false, "run a single GC sweep (containers + GitLab runners) and exit; no polling")
+	idleSeconds := flag.Int("idle-seconds", 60, "seconds a spawned runner waits for its job before exiting")
+	webhookAddr := flag.String("webhook-addr", "", "listen address for the Job Hook ingress (e.g. :8089); empty = poll only")
+	webhookSecret := flag.String("webhook-secret", os.Getenv("GITLAB_WEBHOOK_SECRET"), "webhook secret token; default $GITLAB_WEBHOOK_SECRET; required with --webhook-addr")
+	reconcileEvery := flag.Duration("reconcile-interval", 0, "reconciliation poll cadence; default 30s polling only, 2m with --webhook-addr")
+	metricsAddr := flag.String("metrics-addr", "", "listen address for the Prometheus /metrics endpoint (e.g. :9090); empty = off")
+	flag.Parse()
+
+	if *project == "" {
+		return fmt.Errorf("--project group/project is required (e.g. --project e6qu/sockerless)")
+	}
+	if *token == "" {
+		return fmt.Errorf("gitlab token is empty — set $GITLAB_TOKEN or pass --token=… (a PAT with the `api` scope)")
+	}
+	if *webhookAddr != "" && *webhookSecret == "" {
+		return fmt.Errorf("--webhook-addr needs a secret — set $GITLAB_WEBHOOK_SECRET or pass --webhook-secret=… (the same value as the project hook's secret token)")
+	}
+
+	cfgPath := *configPath
+	if cfgPath == "" {
+		def, err := config.DefaultPath()
+		if err != nil {
+			return err
+		}
+		cfgPath = def
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config %s: %w", cfgPath, err)
+	}
+	if len(cfg.Tags) == 0 {
+		log.Printf("warning: no tag entries in %s; every pending job will be skipped (write a config.toml — see internal/config doc)", cfgPath)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	gl := gitlab.New(http.DefaultClient, *serverURL, *token, *project)
+	if err := gl.VerifyToken(ctx); err != nil {
+		return err
+	}
+	if err := gl.ResolveProject(ctx); err != nil {
+		return err
+	}
+	log.Printf("dispatcher ready: gitlab=%s project=%s (id=%d) tags=%d once=%v cleanup-only=%v webhook=%q",
+		gl.ServerURL, gl.Project, gl.ProjectID, len(cfg.Tags), *once, *cleanupOnly, *webhookAddr)
+
+	loop := newDispatchLoop(gl, cfg)
+	loop.idleSeconds = *idleSeconds
+
+	// State recovery: rebuild the seen-set from running containers
+	// labelled by a previous instance of the dispatcher. Docker is the
+	// source of truth for "have I already spawned a runner for this
+	// job ID".
+	loop.RecoverState(ctx)
+
+	if *cleanupOnly {
+		return loop.Cleanup(ctx)
+	}
+
+	if *once {
+		if err := loop.Step(ctx); err != nil {
+			return err
+		}
+		return loop.Cleanup(ctx)
+	}
+
+	// Pool metrics (queue wait, hit rate, runner counts) for a
+	// Prometheus scrape.
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", loop.pool.Handler("gitlab"))
+		srv := &http.Server{Addr: *metricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("metrics: %v", err)
+				cancel()
+			}
+		}()
+		defer srv.Close()
+		log.Printf("metrics listening on %s/metrics", *metricsAddr)
+	}
+
+	// Graceful shutdown: stop in-flight runners (warm pool included)
+	// and delete their GitLab runners so next-run state matches reality.
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := loop.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown error: %v", err)
+		}
+	}()
+
+	// Webhook ingress: deliveries land on `jobs`, which this goroutine
+	// drains alongside the reconciliation ticker so dedup and runner
+	// creation stay single-threaded. Buffered so a burst of deliveries
+	// is acknowledged inside GitLab's webhook timeout while spawns run.
+	jobs := make(chan gitlab.Job, 64)
+	interval := gl.PollInterval()
+	if *webhookAddr != "" {
+		h, err := webhook.New(*webhookSecret, gl, jobs)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle(webhook.Path, h)
+		srv := &http.Server{Addr: *webhookAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("webhook ingress: %v", err)
+				cancel()
+			}
+		}()
+		defer srv.Close()
+		log.Printf("webhook ingress listening on %s%s", *webhookAddr, webhook.Path)
+		// Reconciliation only catches missed deliveries; keep it under
+		// the seen-set TTL so a re-seed is always fresher than expiry.
+		interval = 2 * time.Minute
+	}
+	if *reconcileEvery > 0 {
+		interval = *reconcileEvery
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	cleanupTicker := time.NewTicker(2 * time.Minute) // reaps orphan runners + dead containers
+	defer cleanupTicker.Stop()
+	// The pool ticker runs regardless of config; ReconcilePool is a
+	// no-op when no tag has a pool or concurrency cap.
+	poolTicker := time.NewTicker(poolInterval)
+	defer poolTicker.Stop()
+	if err := loop.Step(ctx); err != nil {
+		log.Printf("poll error (continuing): %v", err)
+	}
+	loop.ReconcilePool(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case job := <-jobs:
+			loop.dispatch(ctx, job)
+		case <-cleanupTicker.C:
+			if err := loop.Cleanup(ctx); err != nil {
+				log.Printf("cleanup error (continuing): %v", err)
+			}
+			loop.LogPoolStats()
+		case <-poolTicker.C:
+			loop.ReconcilePool(ctx)
+		case <-ticker.C:
+			if err := loop.Step(ctx); err != nil {
+				log.Printf("poll error (continuing): %v", err)
+			}
+		}
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// poolInterval is how often ReconcilePool tops up and trims the warm
+// pools. Shorter than a poll: a hit drains a warm runner immediately.
+const poolInterval = 30 * time.Second
+
+// dispatchLoop creates a GitLab runner and spawns its container for
+// each pending job — from a poll batch (Step) or a Job Hook (dispatch) —
+// and marks each spawn as seen on success. Jobs a warm runner can take
+// are marked without a spawn.
+type dispatchLoop struct {
+	gl          *gitlab.Client
+	cfg         config.Config
+	pool        *pool.Pool
+	idleSeconds int
+	warmSeq     int // disambiguates warm runner names created in the same second
+	// rateLimitedUntil holds polling off after a 429 until GitLab's
+	// Retry-After has passed; webhook jobs still dispatch meanwhile.
+	rateLimitedUntil time.Time
+}
+
+func newDispatchLoop(gl *gitlab.Client, cfg config.Config) *dispatchLoop {
+	p := pool.New()
+	p.Covers = coversTags
+	for _, t := range cfg.Tags {
+		p.Configure(t.Name, t.Policy)
+	}
+	return &dispatchLoop{gl: gl, cfg: cfg, pool: p, idleSeconds: 60}
+}
+
+// coversTags is GitLab's matching rule for a warm runner created with
+// only `tag`: it takes any job whose tags are a subset of its own.
+func coversTags(tag string, jobTags []string) bool {
+	for _, t := range jobTags {
+		if t != tag {
+			return false
+		}
+	}
+	return true
+}
+
+// Step is one reconciliation pass: re-seed the seen-set from live
+// containers (so a job whose runner outlived the TTL isn't respawned),
+// poll for pending jobs, and dispatch each one.
+func (d *dispatchLoop) Step(ctx context.Context) error {
+	if now := d.gl.Now(); now.Before(d.rateLimitedUntil) {
+		log.Printf("poll skipped: rate limited for another %s", d.rateLimitedUntil.Sub(now).Round(time.Second))
+		return nil
+	}
+	d.seedSeen(ctx, false)
+	jobs, err := d.gl.PollOnce(ctx)
+	if err != nil {
+		if wait, ok := gitlab.AsRateLimit(err); ok {
+			d.rateLimitedUntil = d.gl.Now().Add(wait)
+		}
+		return err
+	}
+	for _, job := range jobs {
+		d.dispatch(ctx, job)
+	}
+	return nil
+}
+
+// dispatch creates a runner and spawns its container for one pending
+// job. Failures are logged and leave the job unmarked so the next
+// reconciliation pass retries it; a runner whose container never
+// started is deleted straight away.
+func (d *dispatchLoop) dispatch(ctx context.Context, job gitlab.Job) {
+	if d.gl.IsMarked(job.JobID) {
+		log.Printf("skip job %d (%s): already dispatched", job.JobID, job.Name)
+		return
+	}
+	tag := pickKnownTag(job.Tags, d.cfg)
+	if tag == nil {
+		log.Printf("skip job %d (%s): no matching dispatcher tag in %v", job.JobID, job.Name, job.Tags)
+		d.gl.Mark(job.JobID) // dedup so the same skip doesn't repeat every cycle
+		return
+	}
+	if err := spawner.Liveness(ctx, tag.DockerHost); err != nil {
+		log.Printf("skip job %d (%s): docker daemon at %s unreachable: %v", job.JobID, tag.Name, tag.DockerHost, err)
+		return // do NOT mark — retry next cycle once the daemon is back
+	}
+	switch d.pool.Admit(tag.Name, job.JobID, job.Tags, job.QueuedAt) {
+	case pool.Hit:
+		log.Printf("job %d (%s): warm-pool hit, an idle runner will take it", job.JobID, tag.Name)
+		d.gl.Mark(job.JobID)
+		return
+	case pool.Defer:
+		log.Printf("defer job %d (%s): max_concurrency %d reached", job.JobID, tag.Name, tag.MaxConcurrency)
+		return // do NOT mark — retry once a runner finishes
+	}
+	runnerName := fmt.Sprintf("%s%d-%d", gitlab.DispatcherRunnerPrefix, job.JobID, time.Now().Unix())
+	runner, err := d.gl.CreateRunner(ctx, runnerName, job.Tags)
+	if err != nil {
+		log.Printf("skip job %d: create runner: %v", job.JobID, err)
+		return
+	}
+	cid, err := spawner.Spawn(ctx, spawner.Request{
+		DockerHost:  tag.DockerHost,
+		Image:       tag.Image,
+		ServerURL:   d.gl.ServerURL,
+		RunnerToken: runner.Token,
+		RunnerID:    runner.ID,
+		RunnerName:  runnerName,
+		Tags:        job.Tags,
+		IdleSeconds: d.idleSeconds,
+		JobID:       job.JobID,
+		Pool:        tag.Name,
+	})
+	if err != nil {
+		log.Printf("skip job %d: spawn: %v", job.JobID, err)
+		if err := d.gl.DeleteRunner(ctx, runner.ID); err != nil {
+			log.Printf("job %d: delete unused runner %d: %v (the cleanup sweep retries)", job.JobID, runner.ID, err)
+		}
+		return
+	}
+	d.pool.Spawned(tag.Name, job.JobID, job.QueuedAt)
+	log.Printf("spawned runner for job %d (%s) on %s: container=%s name=%s runner=%d url=%s",
+		job.JobID, tag.Name, tag.DockerHost, shortID(cid), runnerName, runner.ID, job.WebURL)
+	d.gl.Mark(job.JobID)
+}
+
+func pickKnownTag(tags []string, cfg config.Config) *config.Tag {
+	for _, t := range tags {
+		if got := cfg.LookupTag(t); got != nil {
+			return got
+		}
+	}
+	return nil
+}
+
+func shortID(s string) string {
+	if len(s) > 12 {
+		return s[:12]
+	}
+	return s
+}
+
+// RecoverState re-populates the seen-set from running dispatcher
+// containers across every configured docker_host. Run once at
+// startup. A daemon being down at startup is normal (Liveness skips it
+// on the next poll), so per-host errors are logged, not fatal.
+func (d *dispatchLoop) RecoverState(ctx context.Context) {
+	d.seedSeen(ctx, true)
+}
+
+// seedSeen adds the job ID of every running / created dispatcher
+// container to the seen-set. Step calls it quietly before each poll.
+func (d *dispatchLoop) seedSeen(ctx context.Context, verbose bool) {
+	now := d.gl.Now()
+	for _, host := range d.cfg.DockerHosts() {
+		managed, err := spawner.ListManaged(ctx, host)
+		if err != nil {
+			if verbose {
+				log.Printf("recover: list managed on %s failed: %v", host, err)
+			}
+			continue
+		}
+		for _, m := range managed {
+			if m.JobID == 0 || !m.Live() {
+				continue
+			}
+			d.gl.Seen.Add(m.JobID, now)
+			if verbose {
+				log.Printf("recover: seen-set restored for job %d (container %s, runner=%d, state=%s, host=%s)",
+					m.JobID, shortID(m.ContainerID), m.RunnerID, m.State, host)
+			}
+		}
+	}
+}
+
+// Cleanup is the dispatcher's GC sweep — runs on a 2-min cadence and
+// once with --once / --cleanup-only. Two parts:
+//
+//  1. **Container reap**: across every configured docker_host, `docker
+//     rm` dispatcher-managed containers in `exited` / `dead` state.
+//     `--rm` covers the happy path; this catches runners whose
+//     entrypoint died before `--rm` could fire.
+//
+//  2. **GitLab-runner reap**: delete every dispatcher-prefixed project
+//     runner whose ID isn't on a live container. GitLab only marks a
+//     runner offline after it has been silent for a while, so the
+//     container labels — not the runner status — decide. Skipped when
+//     any docker_host could not be listed: a runner on an unreachable
+//     daemon may still be running its job. DELETE on an already-gone
+//     runner returns 404, which counts as success.
+//
+// Errors are logged and swallowed; the next sweep retries.
+func (d *dispatchLoop) Cleanup(ctx context.Context) error {
+	live := map[int64]bool{}
+	complete := true
+	for _, host := range d.cfg.DockerHosts() {
+		managed, err := spawner.ListManaged(ctx, host)
+		if err != nil {
+			log.Printf("cleanup: list managed on %s failed: %v", host, err)
+			complete = false
+			continue
+		}
+		for _, m := range managed {
+			if m.Live() {
+				live[m.RunnerID] = true
+				continue
+			}
+			if m.State == "exited" || m.State == "dead" || m.State == "removing" {
+				if err := spawner.StopAndRemove(ctx, host, m.ContainerID); err != nil {
+					log.Printf("cleanup: rm %s on %s: %v", shortID(m.ContainerID), host, err)
+					continue
+				}
+				log.Printf("cleanup: removed %s container %s (job=%d, runner=%s)",
+					m.State, shortID(m.ContainerID), m.JobID, m.RunnerName)
+			}
+		}
+	}
+	if !complete {
+		log.Printf("cleanup: skipping gitlab runner reap until every docker_host answers")
+		return nil
+	}
+
+	runners, err := d.gl.ListRunners(ctx)
+	if err != nil {
+		log.Printf("cleanup: list gitlab runners: %v", err)
+		return nil
+	}
+	for _, r := range runners {
+		if !gitlab.IsDispatcherRunner(r) || live[r.ID] {
+			continue
+		}
+		if err := d.gl.DeleteRunner(ctx, r.ID); err != nil {
+			log.Printf("cleanup: delete runner %s (id=%d): %v", r.Description, r.ID, err)
+			continue
+		}
+		log.Printf("cleanup: deleted orphan runner %s (id=%d, status=%s)", r.Description, r.ID, r.Status)
+	}
+	return nil
+}
+
+// Shutdown is the graceful-stop path. Stops every dispatcher-managed
+// container across every configured docker_host and deletes every
+// dispatcher-prefixed GitLab runner. Best-effort — individual failures
+// are logged but don't abort the rest of the teardown.
+func (d *dispatchLoop) Shutdown(ctx context.Context) error {
+	log.Printf("shutdown: draining dispatcher containers + runners")
+	for _, host := range d.cfg.DockerHosts() {
+		managed, err := spawner.ListManaged(ctx, host)
+		if err != nil {
+			log.Printf("shutdown: list managed on %s: %v", host, err)
+			continue
+		}
+		for _, m := range managed {
+			if err := spawner.StopAndRemove(ctx, host, m.ContainerID); err != nil {
+				log.Printf("shutdown: stop %s on %s: %v", shortID(m.ContainerID), host, err)
+				continue
+			}
+			log.Printf("shutdown: stopped %s (job=%d, runner=%s)", shortID(m.ContainerID), m.JobID, m.RunnerName)
+		}
+	}
+	runners, err := d.gl.ListRunners(ctx)
+	if err != nil {
+		log.Printf("shutdown: list gitlab runners: %v", err)
+		return nil
+	}
+	for _, r := range runners {
+		if !gitlab.IsDispatcherRunner(r) {
+			continue
+		}
+		if err := d.gl.DeleteRunner(ctx, r.ID); err != nil {
+			log.Printf("shutdown: delete runner %s (id=%d): %v", r.Description, r.ID, err)
+			continue
+		}
+		log.Printf("shutdown: deleted runner %s (id=%d)", r.Description, r.ID)
+	}
+	return nil
+}
+
+// ReconcilePool brings every tag with a pool or concurrency cap in
+// line with its policy. Members are re-read from container labels and
+// joined with the project's running jobs for busy state, so the pool
+// survives a dispatcher restart. Idle warm runners past idle_ttl are
+// retired, and new ones spawned up to the planned count. Errors are
+// logged; the next pass retries.
+func (d *dispatchLoop) ReconcilePool(ctx context.Context) {
+	var active []config.Tag
+	for _, t := range d.cfg.Tags {
+		if t.Policy.Active() {
+			active = append(active, t)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+	busy, err := d.gl.BusyRunners(ctx)
+	if err != nil {
+		log.Printf("pool: list running jobs: %v", err)
+		return
+	}
+	for i := range active {
+		tag := &active[i]
+		managed, err := spawner.ListManaged(ctx, tag.DockerHost)
+		if err != nil {
+			log.Printf("pool: list managed on %s: %v", tag.DockerHost, err)
+			continue
+		}
+		var members []pool.Member
+		containers := map[string]spawner.Managed{}
+		for _, m := range managed {
+			if !m.Live() || m.Pool != tag.Name {
+				continue
+			}
+			containers[m.RunnerName] = m
+			members = append(members, pool.Member{
+				Name:      m.RunnerName,
+				Warm:      m.Warm,
+				SpawnedAt: m.SpawnedAt,
+				Busy:      busy[m.RunnerID],
+			})
+		}
+		d.pool.Observe(tag.Name, members)
+		plan := d.pool.Plan(tag.Name)
+		for _, name := range plan.Retire {
+			d.retire(ctx, tag, containers[name])
+		}
+		for n := 0; n < plan.Spawn; n++ {
+			if err := d.spawnWarm(ctx, tag); err != nil {
+				log.Printf("pool: spawn warm runner for %s: %v", tag.Name, err)
+				break
+			}
+		}
+	}
+}
+
+// retire removes one idle warm runner. The GitLab runner goes first so
+// no job is handed to it while its container stops.
+func (d *dispatchLoop) retire(ctx context.Context, tag *config.Tag, m spawner.Managed) {
+	if m.RunnerID != 0 {
+		if err := d.gl.DeleteRunner(ctx, m.RunnerID); err != nil {
+			log.Printf("pool: keep %s: delete runner %d: %v", m.RunnerName, m.RunnerID, err)
+			return
+		}
+	}
+	if err := spawner.StopAndRemove(ctx, tag.DockerHost, m.ContainerID); err != nil {
+		log.Printf("pool: stop %s (%s): %v", m.RunnerName, shortID(m.ContainerID), err)
+		return
+	}
+	log.Printf("pool: retired idle warm runner %s for %s", m.RunnerName, tag.Name)
+}
+
+// spawnWarm starts one warm runner for tag: created with only the
+// pool's tag and no job ID, so GitLab hands it the next job whose tags
+// it covers.
+func (d *dispatchLoop) spawnWarm(ctx context.Context, tag *config.Tag) error {
+	if err := spawner.Liveness(ctx, tag.DockerHost); err != nil {
+		return err
+	}
+	d.warmSeq++
+	runnerName := fmt.Sprintf("%swarm-%d-%d", gitlab.DispatcherRunnerPrefix, time.Now().Unix(), d.warmSeq)
+	tags := []string{tag.Name}
+	runner, err := d.gl.CreateRunner(ctx, runnerName, tags)
+	if err != nil {
+		return fmt.Errorf("create runner: %w", err)
+	}
+	cid, err := spawner.Spawn(ctx, spawner.Request{
+		DockerHost:  tag.DockerHost,
+		Image:       tag.Image,
+		ServerURL:   d.gl.ServerURL,
+		RunnerToken: runner.Token,
+		RunnerID:    runner.ID,
+		RunnerName:  runnerName,
+		Tags:        tags,
+		IdleSeconds: int(tag.Policy.RunnerIdleTimeout().Seconds()),
+		Pool:        tag.Name,
+		Warm:        true,
+	})
+	if err != nil {
+		if err := d.gl.DeleteRunner(ctx, runner.ID); err != nil {
+			log.Printf("pool: delete unused runner %d: %v (the cleanup sweep retries)", runner.ID, err)
+		}
+		return err
+	}
+	d.pool.WarmSpawned(tag.Name)
+	log.Printf("pool: spawned warm runner for %s on %s: container=%s name=%s runner=%d",
+		tag.Name, tag.DockerHost, shortID(cid), runnerName, runner.ID)
+	return nil
+}
+
+// LogPoolStats logs queue wait and hit rate for every tag with a pool
+// or concurrency cap.
+func (d *dispatchLoop) LogPoolStats() {
+	for _, st := range d.pool.Stats() {
+		if d.pool.Policy(st.Label).Active() {
+			log.Printf("pool: %s", st)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
+	"github.com/sockerless/gitlab-runner-dispatcher/internal/config"
+	"github.com/sockerless/gitlab-runner-dispatcher/pkg/gitlab"
+	"github.com/sockerless/gitlab-runner-dispatcher/pkg/gitlabtest"
+)
+
+func newTestClient(t *testing.T, srv *gitlabtest.Server, project string) *gitlab.Client {
+	t.Helper()
+	gl := gitlab.New(nil, srv.URL, srv.Token, project)
+	if err := gl.ResolveProject(context.Background()); err != nil {
+		t.Fatalf("ResolveProject: %v", err)
+	}
+	return gl
+}
+
+// TestSmokeDispatchLoopSkipsUnknownTag verifies the main loop's
+// "no matching tag → skip + mark seen" path end-to-end through the
+// GitLab client. Sockerless / docker is not involved (the configured
+// tag set is empty so the spawn step never runs).
+func TestSmokeDispatchLoopSkipsUnknownTag(t *testing.T) {
+	srv := gitlabtest.NewServer()
+	defer srv.Close()
+	srv.AddProject("group/app")
+	jobID := srv.AddJob("group/app", "build", "docker")
+
+	gl := newTestClient(t, srv, "group/app")
+	loop := newDispatchLoop(gl, config.Config{}) // empty config — no tag maps
+	if err := loop.Step(context.Background()); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if !gl.IsMarked(jobID) {
+		t.Fatalf("unmatched job %d should be marked seen", jobID)
+	}
+	if err := loop.Step(context.Background()); err != nil {
+		t.Fatalf("Step 2: %v", err)
+	}
+	if got := srv.Runners(); len(got) != 0 {
+		t.Fatalf("no runner should be created for an unmatched job: %+v", got)
+	}
+}
+
+// TestSmokeDispatchLoopMatchesTagButLivenessFails ensures that a
+// matched tag whose docker_host is unreachable surfaces as log-and-skip
+// before any GitLab runner is created, without marking the job — so
+// the next poll retries once the daemon is back up.
+func TestSmokeDispatchLoopMatchesTagButLivenessFails(t *testing.T) {
+	srv := gitlabtest.NewServer()
+	defer srv.Close()
+	srv.AddProject("group/app")
+	jobID := srv.AddJob("group/app", "test", "sockerless-test")
+
+	gl := newTestClient(t, srv, "group/app")
+	cfg := config.Config{Tags: []config.Tag{{
+		Name:       "sockerless-test",
+		DockerHost: "tcp://127.0.0.1:1", // reserved port, definitely unreachable
+		Image:      "test/image:latest",
+	}}}
+	loop := newDispatchLoop(gl, cfg)
+	if err := loop.Step(context.Background()); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if gl.IsMarked(jobID) {
+		t.Fatalf("liveness-fail path must not mark job %d as seen", jobID)
+	}
+	if got := srv.Runners(); len(got) != 0 {
+		t.Fatalf("no runner should be created when the daemon is down: %+v", got)
+	}
+}
+
+// TestSmokeCleanupReapsOrphanRunners verifies the GC sweep: with no
+// live dispatcher container, every dispatcher-prefixed runner is
+// deleted whatever its status, and runners the dispatcher didn't create
+// are left alone. No docker_host is configured, so no docker calls are
+// issued.
+func TestSmokeCleanupReapsOrphanRunners(t *testing.T) {
+	srv := gitlabtest.NewServer()
+	defer srv.Close()
+	srv.AddProject("group/app")
+	gl := newTestClient(t, srv, "group/app")
+
+	ctx := context.Background()
+	orphan, err := gl.CreateRunner(ctx, "dispatcher-7001-1", []string{"sockerless-ecs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	online, err := gl.CreateRunner(ctx, "dispatcher-7002-1", []string{"sockerless-ecs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetRunnerStatus(online.ID, "online")
+	manual, err := gl.CreateRunner(ctx, "laptop-runner", []string{"docker"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loop := newDispatchLoop(gl, config.Config{})
+	if err := loop.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	left := map[int64]bool{}
+	for _, r := range srv.Runners() {
+		left[r.ID] = true
+	}
+	if left[orphan.ID] || left[online.ID] {
+		t.Errorf("dispatcher runners without a container should be deleted: %+v", srv.Runners())
+	}
+	if !left[manual.ID] {
+		t.Errorf("non-dispatcher runner %d must NOT be deleted", manual.ID)
+	}
+}
+
+// TestSmokeCleanupKeepsRunnersWhenDaemonUnreachable: if a docker_host
+// can't be listed, its containers may still be running jobs, so no
+// GitLab runner is reaped on that sweep.
+func TestSmokeCleanupKeepsRunnersWhenDaemonUnreachable(t *testing.T) {
+	srv := gitlabtest.NewServer()
+	defer srv.Close()
+	srv.AddProject("group/app")
+	gl := newTestClient(t, srv, "group/app")
+
+	ctx := context.Background()
+	if _, err := gl.CreateRunner(ctx, "dispatcher-7001-1", []string{"sockerless-test"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Tags: []config.Tag{{
+		Name:       "sockerless-test",
+		DockerHost: "tcp://127.0.0.1:1",
+		Image:      "test/image:latest",
+	}}}
+	loop := newDispatchLoop(gl, cfg)
+	if err := loop.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if got := srv.Runners(); len(got) != 1 {
+		t.Fatalf("runner must survive a sweep with an unreachable daemon: %+v", got)
+	}
+}
+
+// TestSmokePoolUsesGitLabTagRule checks the pool wiring: a warm runner
+// created with only the pool's tag covers jobs whose tags are a subset
+// of it, busy state comes from the project's running jobs, and a hit's
+// queue wait (from the job's created_at) lands on /metrics.
+func TestSmokePoolUsesGitLabTagRule(t *testing.T) {
+	srv := gitlabtest.NewServer()
+	defer srv.Close()
+	srv.AddProject("group/app")
+	plain := srv.AddJob("group/app", "test", "sockerless-ecs")
+	extra := srv.AddJob("group/app", "big", "sockerless-ecs", "gpu")
+
+	if !coversTags("sockerless-ecs", []string{"sockerless-ecs"}) || !coversTags("sockerless-ecs", nil) {
+		t.Fatal("a warm runner covers jobs tagged with its tag or untagged")
+	}
+	if coversTags("sockerless-ecs", []string{"sockerless-ecs", "gpu"}) {
+		t.Fatal("a warm runner can't take a job asking for a tag it lacks")
+	}
+
+	gl := newTestClient(t, srv, "group/app")
+	ctx := context.Background()
+	srv.StartJob(plain, 4242)
+	busy, err := gl.BusyRunners(ctx)
+	if err != nil {
+		t.Fatalf("BusyRunners: %v", err)
+	}
+	if !busy[4242] || len(busy) != 1 {
+		t.Fatalf("busy = %v, want runner 4242 only", busy)
+	}
+
+	cfg := config.Config{Tags: []config.Tag{{
+		Name: "sockerless-ecs", DockerHost: "tcp://127.0.0.1:1", Image: "img",
+		Policy: pool.Policy{MinIdle: 1},
+	}}}
+	loop := newDispatchLoop(gl, cfg)
+	loop.pool.Observe("sockerless-ecs", []pool.Member{{Name: "dispatcher-warm-1-1", Warm: true}})
+	job, err := gl.GetJob(ctx, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.QueuedAt.IsZero() || time.Since(job.QueuedAt) > time.Minute {
+		t.Fatalf("QueuedAt = %v, want the job's created_at", job.QueuedAt)
+	}
+	if got := loop.pool.Admit("sockerless-ecs", job.JobID, job.Tags, job.QueuedAt); got != pool.Cold {
+		t.Fatalf("job tagged gpu = %v, want cold", got)
+	}
+	if got := loop.pool.Admit("sockerless-ecs", plain, []string{"sockerless-ecs"}, job.QueuedAt); got != pool.Hit {
+		t.Fatalf("job tagged sockerless-ecs = %v, want hit", got)
+	}
+
+	var buf strings.Builder
+	if err := loop.pool.WritePrometheus(&buf, "gitlab"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`sockerless_dispatcher_jobs_total{dispatcher="gitlab",pool="sockerless-ecs",decision="hit"} 1`,
+		`sockerless_dispatcher_queue_wait_seconds_count{dispatcher="gitlab",pool="sockerless-ecs",decision="hit"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, buf.String())
+		}
+	}
+}
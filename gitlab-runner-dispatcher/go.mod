@@ -0,0 +1,10 @@
+module github.com/sockerless/gitlab-runner-dispatcher
+
+go 1.25
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/sockerless/github-runner-dispatcher-aws v0.0.0
+)
+
+replace github.com/sockerless/github-runner-dispatcher-aws => ../github-runner-dispatcher-aws
@@ -0,0 +1,117 @@
+// Package config loads dispatcher TOML config from
+// `~/.sockerless/gitlab-dispatcher/config.toml`. Schema:
+//
+//	[[tag]]
+//	name        = "sockerless-ecs"
+//	docker_host = "tcp://localhost:3375"
+//	image       = "729079515331.dkr.ecr.eu-west-1.amazonaws.com/sockerless-live:gitlab-runner-ecs"
+//
+//	[[tag]]
+//	name        = "sockerless-cloudrun"
+//	docker_host = "tcp://localhost:3377"
+//	image       = "us-central1-docker.pkg.dev/my-project/sockerless-live/gitlab-runner:cloudrun"
+//	min_idle    = 2
+//	max_idle    = 6
+//	max_concurrency = 20
+//
+// `name` is matched against the `tags:` of each pending GitLab job, the
+// same way the GitHub dispatchers match `runs-on:` labels. `docker_host`
+// is the sockerless daemon (context) that fronts the target backend —
+// ECS, Cloud Run Jobs, ACA Jobs — and `image` is the gitlab-runner
+// image spawned there. The optional warm-pool keys (`min_idle`,
+// `max_idle`, `max_concurrency`, `idle_ttl`, `cold_start`) are the
+// GitHub dispatchers' pool.Policy. Config file is optional (empty
+// config means "no tags mapped — every job is skipped with a
+// warning").
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/sockerless/github-runner-dispatcher-aws/pkg/pool"
+)
+
+// Tag maps a GitLab job tag to a sockerless daemon + runner image.
+type Tag struct {
+	Name       string `toml:"name"`
+	DockerHost string `toml:"docker_host"`
+	Image      string `toml:"image"`
+	pool.Policy
+}
+
+// Config is the on-disk dispatcher config.
+type Config struct {
+	Tags []Tag `toml:"tag"`
+}
+
+// DefaultPath returns the standard config path under
+// `~/.sockerless/gitlab-dispatcher/config.toml`. Errors when HOME is
+// unset.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home dir: %w", err)
+	}
+	return filepath.Join(home, ".sockerless", "gitlab-dispatcher", "config.toml"), nil
+}
+
+// Load reads the config from `path`. Returns an empty Config (not an
+// error) if the file does not exist — empty config is a valid state.
+func Load(path string) (Config, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return Config{}, nil
+	} else if err != nil {
+		return Config{}, fmt.Errorf("stat %s: %w", path, err)
+	}
+	var cfg Config
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		return Config{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i, t := range cfg.Tags {
+		if t.Name == "" {
+			return Config{}, fmt.Errorf("tag entry #%d: name is required", i+1)
+		}
+		if seen[t.Name] {
+			return Config{}, fmt.Errorf("tag %q: defined twice", t.Name)
+		}
+		seen[t.Name] = true
+		if t.DockerHost == "" {
+			return Config{}, fmt.Errorf("tag %q: docker_host is required", t.Name)
+		}
+		if t.Image == "" {
+			return Config{}, fmt.Errorf("tag %q: image is required", t.Name)
+		}
+		if err := t.Policy.Validate(); err != nil {
+			return Config{}, fmt.Errorf("tag %q: %w", t.Name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// LookupTag returns the Tag entry matching `name`, or nil.
+func (c Config) LookupTag(name string) *Tag {
+	for i := range c.Tags {
+		if c.Tags[i].Name == name {
+			return &c.Tags[i]
+		}
+	}
+	return nil
+}
+
+// DockerHosts returns each distinct docker_host once, in config order.
+func (c Config) DockerHosts() []string {
+	var hosts []string
+	seen := map[string]bool{}
+	for _, t := range c.Tags {
+		if !seen[t.DockerHost] {
+			seen[t.DockerHost] = true
+			hosts = append(hosts, t.DockerHost)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadValid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	body := `
+[[tag]]
+name = "sockerless-ecs"
+docker_host = "tcp://localhost:3375"
+image = "ecr.example/sockerless-live:gitlab-runner-ecs"
+
+[[tag]]
+name = "sockerless-ecs-large"
+docker_host = "tcp://localhost:3375"
+image = "ecr.example/sockerless-live:gitlab-runner-ecs"
+
+[[tag]]
+name = "sockerless-aca"
+docker_host = "tcp://localhost:3378"
+image = "acr.example/gitlab-runner:aca"
+min_idle = 1
+max_concurrency = 4
+idle_ttl = "5m"
+`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Tags) != 3 {
+		t.Fatalf("want 3 tags, got %d", len(cfg.Tags))
+	}
+	if tag := cfg.LookupTag("sockerless-aca"); tag == nil || tag.DockerHost != "tcp://localhost:3378" {
+		t.Fatalf("LookupTag(aca) = %+v", tag)
+	}
+	if tag := cfg.LookupTag("sockerless-aca"); tag.MinIdle != 1 || tag.MaxConcurrency != 4 || tag.IdleTTL != 5*time.Minute || !tag.Policy.Enabled() {
+		t.Fatalf("aca pool policy = %+v", tag.Policy)
+	}
+	if cfg.LookupTag("sockerless-ecs").Policy.Active() {
+		t.Fatal("ecs has no pool keys and should be inactive")
+	}
+	if tag := cfg.LookupTag("nope"); tag != nil {
+		t.Fatalf("LookupTag(nope) should return nil, got %+v", tag)
+	}
+	if hosts := cfg.DockerHosts(); len(hosts) != 2 || hosts[0] != "tcp://localhost:3375" {
+		t.Fatalf("DockerHosts = %v", hosts)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "no-such-file.toml"))
+	if err != nil {
+		t.Fatalf("missing file should not error, got %v", err)
+	}
+	if len(cfg.Tags) != 0 {
+		t.Fatalf("missing file should yield empty config, got %+v", cfg)
+	}
+}
+
+func TestLoadValidationErrors(t *testing.T) {
+	cases := map[string]string{
+		"missing name": `[[tag]]
+docker_host = "tcp://x"
+image = "img"`,
+		"missing docker_host": `[[tag]]
+name = "x"
+image = "img"`,
+		"missing image": `[[tag]]
+name = "x"
+docker_host = "tcp://x"`,
+		"min_idle over max_concurrency": `[[tag]]
+name = "x"
+docker_host = "tcp://x"
+image = "img"
+min_idle = 5
+max_concurrency = 2`,
+		"duplicate name": `[[tag]]
+name = "x"
+docker_host = "tcp://x"
+image = "img"
+[[tag]]
+name = "x"
+docker_host = "tcp://y"
+image = "img"`,
+	}
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.toml")
+			if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Fatalf("Load should fail for %q", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Package spawner runs the gitlab-runner image via the local `docker`
+// CLI (`docker run --rm -d …`) against the sockerless daemon a tag maps
+// to. Kept dependency-free — same coupling as the GitHub dispatcher's
+// spawner: Docker public API / CLI only. Sockerless turns the container
+// into the backend's primitive (ECS task, Cloud Run Job, ACA Job) and
+// carries the labels below onto the cloud resource, so `docker ps
+// --filter label=…` against the same daemon rediscovers the fleet after
+// a dispatcher restart.
+//
+// One container per pending job, plus the warm runners a tag's pool
+// keeps idle (no job; GitLab hands them the next job whose tags they
+// cover). Container lifecycle:
+//  1. `docker run -d --pull never <image>` with the runner's `glrt-`
+//     token from `POST /user/runners` (returns container ID).
+//  2. The image's bootstrap registers with GitLab, and with
+//     GITLAB_RUNNER_MAX_BUILDS=1 runs exactly one job
+//     (`gitlab-runner run-single --max-builds 1`), then exits.
+//  3. `--wait-timeout` (GITLAB_RUNNER_IDLE_SECONDS) exits the runner if
+//     no job arrives — duplicate-spawn races become benign.
+package spawner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels stamped on every spawned container so a restarted dispatcher
+// can rediscover its state from the docker daemon (no on-disk
+// dispatcher state). The JobID label is parsed back into the seen-set;
+// the RunnerID label ties the container to its GitLab runner for the
+// orphan sweep.
+const (
+	LabelJobID      = "sockerless.gitlab-dispatcher.job_id"
+	LabelRunnerID   = "sockerless.gitlab-dispatcher.runner_id"
+	LabelRunnerName = "sockerless.gitlab-dispatcher.runner_name"
+	LabelManagedBy  = "sockerless.gitlab-dispatcher.managed_by"
+	LabelManagedVal = "gitlab-runner-dispatcher"
+	// Warm-pool labels: the config tag a runner serves (cold spawns
+	// carry it too, for max_concurrency), whether it was spawned ahead
+	// of demand, and when — the idle_ttl clock after a restart.
+	LabelPool      = "sockerless.gitlab-dispatcher.pool"
+	LabelWarm      = "sockerless.gitlab-dispatcher.warm"
+	LabelSpawnedAt = "sockerless.gitlab-dispatcher.spawned_at"
+)
+
+// Request is one spawn directive.
+type Request struct {
+	DockerHost  string // tcp://host:port of the sockerless daemon
+	Image       string // gitlab-runner image URI
+	ServerURL   string // GitLab instance URL, e.g. https://gitlab.com
+	RunnerToken string // `glrt-` authentication token of the runner created for this job
+	RunnerID    int64  // GitLab runner ID — written to LabelRunnerID
+	RunnerName  string // unique name; GitLab UI uses it
+	Tags        []string
+	IdleSeconds int    // seconds to wait for the job; 0 → 60 s default
+	JobID       int64  // GitLab job ID — written to LabelJobID for restart recovery; 0 for warm runners
+	Pool        string // config tag name — written to LabelPool
+	Warm        bool   // warm-pool runner, spawned without a job
+}
+
+// Spawn shells out to `docker run -d`. Returns the container ID.
+func Spawn(ctx context.Context, req Request) (string, error) {
+	args, err := runArgs(req)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = append(os.Environ(), "DOCKER_HOST="+req.DockerHost)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker run: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	id := strings.TrimSpace(string(out))
+	if id == "" {
+		return "", fmt.Errorf("docker run returned empty container ID")
+	}
+	return id, nil
+}
+
+func runArgs(req Request) ([]string, error) {
+	switch {
+	case req.DockerHost == "":
+		return nil, fmt.Errorf("docker host required")
+	case req.Image == "":
+		return nil, fmt.Errorf("image required")
+	case req.ServerURL == "":
+		return nil, fmt.Errorf("gitlab server URL required")
+	case req.RunnerToken == "":
+		return nil, fmt.Errorf("runner token required")
+	case req.RunnerName == "":
+		return nil, fmt.Errorf("runner name required")
+	}
+	idle := req.IdleSeconds
+	if idle <= 0 {
+		idle = 60
+	}
+	return []string{
+		"run", "--rm", "-d",
+		"--pull", "never",
+		"--name", req.RunnerName,
+		"--label", LabelManagedBy + "=" + LabelManagedVal,
+		"--label", fmt.Sprintf("%s=%d", LabelJobID, req.JobID),
+		"--label", fmt.Sprintf("%s=%d", LabelRunnerID, req.RunnerID),
+		"--label", LabelRunnerName + "=" + req.RunnerName,
+		"--label", LabelPool + "=" + req.Pool,
+		"--label", LabelWarm + "=" + strconv.FormatBool(req.Warm),
+		"--label", fmt.Sprintf("%s=%d", LabelSpawnedAt, time.Now().Unix()),
+		"-e", "GITLAB_URL=" + req.ServerURL,
+		"-e", "GITLAB_RUNNER_TOKEN=" + req.RunnerToken,
+		"-e", "GITLAB_RUNNER_NAME=" + req.RunnerName,
+		"-e", "GITLAB_RUNNER_TAGS=" + strings.Join(req.Tags, ","),
+		"-e", "GITLAB_RUNNER_MAX_BUILDS=1",
+		"-e", fmt.Sprintf("GITLAB_RUNNER_IDLE_SECONDS=%d", idle),
+		req.Image,
+	}, nil
+}
+
+// Liveness reports whether the docker daemon at DockerHost answers
+// `docker info`. Used to skip a job when the daemon is down.
+func Liveness(ctx context.Context, dockerHost string) error {
+	cmd := exec.CommandContext(ctx, "docker", "info", "--format", "{{.ServerVersion}}")
+	cmd.Env = append(os.Environ(), "DOCKER_HOST="+dockerHost)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker info on %s: %v: %s", dockerHost, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Managed describes a container the dispatcher previously spawned
+// (matched via LabelManagedBy).
+type Managed struct {
+	ContainerID string
+	JobID       int64
+	RunnerID    int64
+	RunnerName  string
+	State       string // "running", "exited", "created", …
+	DockerHost  string
+	Pool        string
+	Warm        bool
+	SpawnedAt   time.Time
+}
+
+// Live reports whether the container still holds (or is about to
+// hold) its job.
+func (m Managed) Live() bool {
+	return m.State == "running" || m.State == "created"
+}
+
+// ListManaged returns every container on the daemon at dockerHost that
+// carries the dispatcher's managed-by label, regardless of state.
+func ListManaged(ctx context.Context, dockerHost string) ([]Managed, error) {
+	args := []string{
+		"ps", "-a",
+		"--filter", "label=" + LabelManagedBy + "=" + LabelManagedVal,
+		"--format", "{{.ID}}|{{.State}}|{{.Label \"" + LabelJobID + "\"}}|{{.Label \"" + LabelRunnerID + "\"}}|{{.Label \"" + LabelRunnerName + "\"}}|" +
+			"{{.Label \"" + LabelPool + "\"}}|{{.Label \"" + LabelWarm + "\"}}|{{.Label \"" + LabelSpawnedAt + "\"}}",
+	}
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = append(os.Environ(), "DOCKER_HOST="+dockerHost)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("docker ps on %s: %v: %s", dockerHost, err, strings.TrimSpace(string(out)))
+	}
+	return parsePS(string(out), dockerHost), nil
+}
+
+// parsePS parses ListManaged's `docker ps` format. Containers from
+// dispatchers predating the pool labels have the trailing fields
+// empty: no pool, cold, zero spawn time.
+func parsePS(out, dockerHost string) []Managed {
+	var managed []Managed
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, "|", 8)
+		if len(parts) < 5 {
+			continue
+		}
+		for len(parts) < 8 {
+			parts = append(parts, "")
+		}
+		jobID, _ := strconv.ParseInt(strings.TrimSpace(parts[2]), 10, 64)
+		runnerID, _ := strconv.ParseInt(strings.TrimSpace(parts[3]), 10, 64)
+		m := Managed{
+			ContainerID: parts[0],
+			State:       parts[1],
+			JobID:       jobID,
+			RunnerID:    runnerID,
+			RunnerName:  parts[4],
+			DockerHost:  dockerHost,
+			Pool:        parts[5],
+			Warm:        parts[6] == "true",
+		}
+		if secs, err := strconv.ParseInt(strings.TrimSpace(parts[7]), 10, 64); err == nil && secs > 0 {
+			m.SpawnedAt = time.Unix(secs, 0)
+		}
+		managed = append(managed, m)
+	}
+	return managed
+}
+
+// StopAndRemove stops a container (timeout 10 s) and removes it.
+// Tolerates already-gone containers so the sweep is idempotent.
+func StopAndRemove(ctx context.Context, dockerHost, containerID string) error {
+	stop := exec.CommandContext(ctx, "docker", "stop", "-t", "10", containerID)
+	stop.Env = append(os.Environ(), "DOCKER_HOST="+dockerHost)
+	_ = stop.Run() // already-exited / gone is fine; rm below decides
+	rm := exec.CommandContext(ctx, "docker", "rm", "-f", containerID)
+	rm.Env = append(os.Environ(), "DOCKER_HOST="+dockerHost)
+	if out, err := rm.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(out))
+		// `--rm` containers self-remove on stop.
+		if strings.Contains(msg, "No such container") {
+			return nil
+		}
+		return fmt.Errorf("docker rm %s: %v: %s", containerID, err, msg)
+	}
+	return nil
+}
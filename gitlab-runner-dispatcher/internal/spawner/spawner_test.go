@@ -0,0 +1,88 @@
+package spawner
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSpawnRequiredFields(t *testing.T) {
+	full := Request{DockerHost: "tcp://x", Image: "img", ServerURL: "https://gl", RunnerToken: "glrt-x", RunnerName: "rn"}
+	cases := map[string]func(*Request){
+		"missing host":   func(r *Request) { r.DockerHost = "" },
+		"missing image":  func(r *Request) { r.Image = "" },
+		"missing server": func(r *Request) { r.ServerURL = "" },
+		"missing token":  func(r *Request) { r.RunnerToken = "" },
+		"missing runner": func(r *Request) { r.RunnerName = "" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := full
+			mutate(&req)
+			if _, err := Spawn(context.Background(), req); err == nil {
+				t.Fatalf("Spawn should fail: %s", name)
+			}
+		})
+	}
+}
+
+func TestRunArgsStampsRecoveryLabels(t *testing.T) {
+	args, err := runArgs(Request{
+		DockerHost: "tcp://x", Image: "img", ServerURL: "https://gitlab.example",
+		RunnerToken: "glrt-abc", RunnerID: 77, RunnerName: "dispatcher-42-1",
+		Tags: []string{"sockerless-ecs", "large"}, JobID: 42, Pool: "sockerless-ecs",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(args, " ")
+	for _, want := range []string{
+		LabelManagedBy + "=" + LabelManagedVal,
+		LabelJobID + "=42",
+		LabelRunnerID + "=77",
+		LabelPool + "=sockerless-ecs",
+		LabelWarm + "=false",
+		LabelSpawnedAt + "=",
+		"GITLAB_RUNNER_TOKEN=glrt-abc",
+		"GITLAB_RUNNER_TAGS=sockerless-ecs,large",
+		"GITLAB_RUNNER_MAX_BUILDS=1",
+		"GITLAB_RUNNER_IDLE_SECONDS=60",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("docker run args missing %q: %s", want, joined)
+		}
+	}
+	if args[len(args)-1] != "img" {
+		t.Errorf("image must be the last arg: %v", args)
+	}
+}
+
+func TestParsePS(t *testing.T) {
+	out := "abc123|running|42|77|dispatcher-42-1|sockerless-ecs|false|1700000000\n" +
+		"def456|exited|43||dispatcher-43-1\n" +
+		"ghi789|running|0|78|dispatcher-warm-1700000001-1|sockerless-ecs|true|1700000001\n\n"
+	got := parsePS(out, "tcp://x")
+	if len(got) != 3 {
+		t.Fatalf("parsePS = %+v", got)
+	}
+	if got[0].JobID != 42 || got[0].RunnerID != 77 || !got[0].Live() || got[0].Warm || got[0].Pool != "sockerless-ecs" {
+		t.Errorf("first = %+v", got[0])
+	}
+	// Containers spawned before the pool labels existed parse as cold.
+	if got[1].RunnerID != 0 || got[1].Live() || got[1].Pool != "" || !got[1].SpawnedAt.IsZero() {
+		t.Errorf("second = %+v", got[1])
+	}
+	if !got[2].Warm || got[2].JobID != 0 || got[2].SpawnedAt.Unix() != 1700000001 {
+		t.Errorf("warm = %+v", got[2])
+	}
+}
+
+func TestLivenessUnreachable(t *testing.T) {
+	err := Liveness(context.Background(), "tcp://127.0.0.1:1")
+	if err == nil {
+		t.Fatal("Liveness against unreachable host should error")
+	}
+	if !strings.Contains(err.Error(), "docker info") {
+		t.Errorf("error should mention docker info: %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+// Package gitlab is the dispatcher's GitLab REST v4 client: pending-job
+// polling, job lookup for webhook events, per-job runner creation via
+// `POST /user/runners`, and the runner list / delete calls the GC
+// sweep needs.
+//
+// API path: `GET /projects/{id}/jobs?scope[]=pending` returns every
+// pending job of the project with its `tag_list`, so one call per poll
+// covers the whole project (GitLab has no run → jobs fan-out like
+// GitHub). With the webhook ingress (pkg/webhook) enabled, PollOnce is
+// the slow reconciliation pass; without it, it's the only job source.
+//
+// Dedup is a 5-min TTL `seen` set keyed by job ID, shared by both job
+// sources. The dispatcher re-seeds it from container labels on start
+// and before every reconciliation pass, so a restart does not
+// double-spawn a job whose runner is still alive.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job is the dispatcher's view of a pending GitLab CI job.
+type Job struct {
+	JobID      int64     // GitLab job ID — used as dedup key
+	PipelineID int64     // parent pipeline
+	Name       string    // job name (logging only)
+	Stage      string    // pipeline stage (logging only)
+	Status     string    // "pending" for jobs PollOnce returns
+	Tags       []string  // job `tags:` — matched against config [[tag]] entries
+	Project    string    // group/project path
+	WebURL     string    // GitLab URL to the job (logging only)
+	QueuedAt   time.Time // job `created_at`; fetch time when GitLab omits it
+}
+
+// Client is a thin wrapper over net/http with a token, the GitLab
+// instance URL, and the project the dispatcher serves.
+type Client struct {
+	HTTP      *http.Client
+	Token     string
+	ServerURL string // e.g. https://gitlab.com — handed to spawned runners
+	APIBase   string // ServerURL + "/api/v4"; override for tests
+	Project   string // "group/project" (nested groups allowed)
+	ProjectID int64  // resolved by ResolveProject; required by CreateRunner
+	Now       func() time.Time
+	Seen      *seenSet
+	pollWait  time.Duration
+}
+
+// New builds a Client. `serverURL` defaults to https://gitlab.com;
+// `project` is the group/project path (or numeric ID) and is required.
+func New(httpc *http.Client, serverURL, token, project string) *Client {
+	if httpc == nil {
+		httpc = http.DefaultClient
+	}
+	if serverURL == "" {
+		serverURL = "https://gitlab.com"
+	}
+	serverURL = strings.TrimRight(serverURL, "/")
+	return &Client{
+		HTTP:      httpc,
+		Token:     token,
+		ServerURL: serverURL,
+		APIBase:   serverURL + "/api/v4",
+		Project:   project,
+		Now:       time.Now,
+		Seen:      newSeenSet(5 * time.Minute),
+		// One call per poll (no per-pipeline fan-out), well inside
+		// GitLab.com's 2000 req/min authenticated limit.
+		pollWait: 30 * time.Second,
+	}
+}
+
+// ResolveProject looks up the project by path and records its numeric
+// ID (needed by `POST /user/runners`) and canonical path.
+func (c *Client) ResolveProject(ctx context.Context) error {
+	body, err := c.do(ctx, http.MethodGet, c.APIBase+"/projects/"+url.PathEscape(c.Project), nil, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("resolve project %s: %w", c.Project, err)
+	}
+	var p struct {
+		ID                int64  `json:"id"`
+		PathWithNamespace string `json:"path_with_namespace"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return fmt.Errorf("decode project: %w", err)
+	}
+	if p.ID == 0 {
+		return fmt.Errorf("resolve project %s: response has no id", c.Project)
+	}
+	c.ProjectID = p.ID
+	if p.PathWithNamespace != "" {
+		c.Project = p.PathWithNamespace
+	}
+	return nil
+}
+
+// PollOnce fetches the project's pending jobs and returns every one
+// not already in the seen-set. Filling the seen-set is the caller's
+// responsibility (call Mark(jobID) after a successful spawn) so a
+// failed spawn lets the next poll retry.
+func (c *Client) PollOnce(ctx context.Context) ([]Job, error) {
+	u := fmt.Sprintf("%s/jobs?scope[]=pending&per_page=100", c.projectURL())
+	body, err := c.do(ctx, http.MethodGet, u, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []apiJob
+	if err := json.Unmarshal(body, &jobs); err != nil {
+		return nil, fmt.Errorf("decode jobs: %w", err)
+	}
+	var pending []Job
+	for _, j := range jobs {
+		if j.Status != "pending" || c.Seen.Has(j.ID) {
+			continue
+		}
+		pending = append(pending, c.toJob(j))
+	}
+	return pending, nil
+}
+
+// GetJob fetches one job. The webhook ingress calls it for every
+// pending Job Hook: the hook payload doesn't carry the job's tags, and
+// re-reading the job also confirms it is still pending.
+func (c *Client) GetJob(ctx context.Context, jobID int64) (Job, error) {
+	body, err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/jobs/%d", c.projectURL(), jobID), nil, http.StatusOK)
+	if err != nil {
+		return Job{}, err
+	}
+	var j apiJob
+	if err := json.Unmarshal(body, &j); err != nil {
+		return Job{}, fmt.Errorf("decode job: %w", err)
+	}
+	return c.toJob(j), nil
+}
+
+// Mark records `jobID` so subsequent polls skip it for the seen-set's
+// TTL. Caller is expected to call this after a successful spawn.
+func (c *Client) Mark(jobID int64) {
+	c.Seen.Add(jobID, c.Now())
+}
+
+// IsMarked reports whether `jobID` is in the seen-set. The dispatch
+// loop checks it for webhook-delivered jobs, which skip PollOnce.
+func (c *Client) IsMarked(jobID int64) bool {
+	return c.Seen.Has(jobID)
+}
+
+// PollInterval is the cadence the dispatcher's main loop should call
+// PollOnce at when the webhook ingress is off.
+func (c *Client) PollInterval() time.Duration { return c.pollWait }
+
+func (c *Client) projectURL() string {
+	id := url.PathEscape(c.Project)
+	if c.ProjectID != 0 {
+		id = strconv.FormatInt(c.ProjectID, 10)
+	}
+	return c.APIBase + "/projects/" + id
+}
+
+func (c *Client) toJob(j apiJob) Job {
+	job := Job{
+		JobID:      j.ID,
+		PipelineID: j.Pipeline.ID,
+		Name:       j.Name,
+		Stage:      j.Stage,
+		Status:     j.Status,
+		Tags:       j.TagList,
+		Project:    c.Project,
+		WebURL:     j.WebURL,
+		QueuedAt:   c.Now(),
+	}
+	if j.CreatedAt != nil {
+		job.QueuedAt = *j.CreatedAt
+	}
+	return job
+}
+
+// do issues one API call and returns the body when the status matches
+// `want`. Non-matching responses become an *APIError.
+func (c *Client) do(ctx context.Context, method, u string, payload interface{}, want int) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.Token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode != want {
+		return nil, fmt.Errorf("%s %s: %w", method, u, newAPIError(resp, data))
+	}
+	return data, nil
+}
+
+// APIError is a non-success GitLab response. RetryAfter is set from
+// the `Retry-After` header GitLab sends with 429s.
+type APIError struct {
+	Status     int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Body)
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	if len(e.Body) > 2048 {
+		e.Body = e.Body[:2048]
+	}
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+	}
+	return e
+}
+
+// AsRateLimit reports whether err is a GitLab 429 and returns the wait
+// to honour before the next call (Retry-After, or 60 s without one).
+func AsRateLimit(err error) (time.Duration, bool) {
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Status != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if ae.RetryAfter > 0 {
+		return ae.RetryAfter, true
+	}
+	return time.Minute, true
+}
+
+// IsNotFound reports whether err is a GitLab 404.
+func IsNotFound(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.Status == http.StatusNotFound
+}
+
+type apiJob struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Stage     string     `json:"stage"`
+	Status    string     `json:"status"`
+	TagList   []string   `json:"tag_list"`
+	WebURL    string     `json:"web_url"`
+	CreatedAt *time.Time `json:"created_at"`
+	Pipeline  struct {
+		ID int64 `json:"id"`
+	} `json:"pipeline"`
+	Runner *struct {
+		ID int64 `json:"id"`
+	} `json:"runner"`
+}
+
+// seenSet is a TTL-bounded dedup set. Entries older than `ttl` are
+// purged on lookup so the set self-prunes without a background loop.
+type seenSet struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	m   map[int64]time.Time
+}
+
+func newSeenSet(ttl time.Duration) *seenSet {
+	return &seenSet{ttl: ttl, m: make(map[int64]time.Time)}
+}
+
+func (s *seenSet) Add(id int64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[id] = now
+}
+
+func (s *seenSet) Has(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, t := range s.m {
+		if now.Sub(t) > s.ttl {
+			delete(s.m, k)
+		}
+	}
+	_, ok := s.m[id]
+	return ok
+}
@@ -0,0 +1,90 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// The happy paths are covered against pkg/gitlabtest from the webhook
+// and cmd smoke tests; these cover the error plumbing.
+
+func TestPollOnceSkipsSeen(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"id":1, "name":"a", "status":"pending", "tag_list":["x"], "pipeline":{"id":9}},
+			{"id":2, "name":"b", "status":"pending", "tag_list":["y"], "pipeline":{"id":9}}
+		]`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.Client(), srv.URL, "tok", "group/app")
+	c.ProjectID = 5
+	c.Mark(1)
+	jobs, err := c.PollOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].JobID != 2 || jobs[0].PipelineID != 9 || jobs[0].Tags[0] != "y" {
+		t.Fatalf("jobs = %+v", jobs)
+	}
+}
+
+func TestAsRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "17")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := New(srv.Client(), srv.URL, "tok", "group/app")
+	_, err := c.PollOnce(context.Background())
+	wait, ok := AsRateLimit(err)
+	if !ok || wait != 17*time.Second {
+		t.Fatalf("AsRateLimit = %v, %v (err %v)", wait, ok, err)
+	}
+	if _, ok := AsRateLimit(errors.New("boom")); ok {
+		t.Fatal("plain error is not a rate limit")
+	}
+}
+
+func TestDeleteRunnerNotFoundIsSuccess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := New(srv.Client(), srv.URL, "tok", "group/app")
+	if err := c.DeleteRunner(context.Background(), 3); err != nil {
+		t.Fatalf("DeleteRunner on a gone runner: %v", err)
+	}
+}
+
+func TestVerifyTokenScopes(t *testing.T) {
+	scopes := `["read_api","create_runner"]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"active":true,"revoked":false,"scopes":%s}`, scopes)
+	}))
+	defer srv.Close()
+
+	c := New(srv.Client(), srv.URL, "tok", "group/app")
+	if err := c.VerifyToken(context.Background()); err == nil {
+		t.Fatal("token without manage_runner should fail")
+	}
+	scopes = `["read_api","create_runner","manage_runner"]`
+	if err := c.VerifyToken(context.Background()); err != nil {
+		t.Fatalf("granular scopes: %v", err)
+	}
+	scopes = `["api"]`
+	if err := c.VerifyToken(context.Background()); err != nil {
+		t.Fatalf("api scope: %v", err)
+	}
+}
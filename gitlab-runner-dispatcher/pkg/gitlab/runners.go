@@ -0,0 +1,114 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Runner is the dispatcher's view of a project runner. Used by the GC
+// sweep to reap dispatcher-created runners whose container is gone.
+type Runner struct {
+	ID          int64  `json:"id"`
+	Description string `json:"description"`
+	Status      string `json:"status"` // "online" / "offline" / "stale" / "never_contacted"
+	Paused      bool   `json:"paused"`
+	RunnerType  string `json:"runner_type"`
+}
+
+// RunnerToken is the response of `POST /user/runners`: the new
+// runner's ID and its `glrt-` authentication token.
+type RunnerToken struct {
+	ID    int64  `json:"id"`
+	Token string `json:"token"`
+}
+
+// CreateRunner creates a project runner scoped to `tags` (untagged
+// jobs excluded, locked to the project) and returns its authentication
+// token. This is GitLab 16+'s replacement for registration tokens; the
+// spawned runner passes the token to `gitlab-runner register --token`.
+// Requires ResolveProject to have run.
+func (c *Client) CreateRunner(ctx context.Context, description string, tags []string) (RunnerToken, error) {
+	if c.ProjectID == 0 {
+		return RunnerToken{}, fmt.Errorf("create runner: project ID not resolved")
+	}
+	body, err := c.do(ctx, http.MethodPost, c.APIBase+"/user/runners", map[string]interface{}{
+		"runner_type":  "project_type",
+		"project_id":   c.ProjectID,
+		"description":  description,
+		"tag_list":     strings.Join(tags, ","),
+		"run_untagged": false,
+		"locked":       true,
+	}, http.StatusCreated)
+	if err != nil {
+		return RunnerToken{}, err
+	}
+	var rt RunnerToken
+	if err := json.Unmarshal(body, &rt); err != nil {
+		return RunnerToken{}, fmt.Errorf("decode runner token: %w", err)
+	}
+	if rt.Token == "" {
+		return RunnerToken{}, fmt.Errorf("gitlab returned empty runner token")
+	}
+	return rt, nil
+}
+
+// ListRunners hits `GET /projects/{id}/runners?type=project_type` and
+// returns the project's own runners. Paginates implicitly via
+// per_page=100; the dispatcher's pool is expected to stay below that.
+func (c *Client) ListRunners(ctx context.Context) ([]Runner, error) {
+	body, err := c.do(ctx, http.MethodGet, c.projectURL()+"/runners?type=project_type&per_page=100", nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	var runners []Runner
+	if err := json.Unmarshal(body, &runners); err != nil {
+		return nil, fmt.Errorf("decode runners: %w", err)
+	}
+	return runners, nil
+}
+
+// BusyRunners returns the IDs of the runners running one of the
+// project's jobs, from `GET /projects/{id}/jobs?scope[]=running`.
+// GitLab's runner list has no busy flag; the warm pool uses this to
+// tell an idle warm runner from one that has taken a job.
+func (c *Client) BusyRunners(ctx context.Context) (map[int64]bool, error) {
+	body, err := c.do(ctx, http.MethodGet, c.projectURL()+"/jobs?scope[]=running&per_page=100", nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []apiJob
+	if err := json.Unmarshal(body, &jobs); err != nil {
+		return nil, fmt.Errorf("decode jobs: %w", err)
+	}
+	busy := map[int64]bool{}
+	for _, j := range jobs {
+		if j.Runner != nil && j.Runner.ID != 0 {
+			busy[j.Runner.ID] = true
+		}
+	}
+	return busy, nil
+}
+
+// DeleteRunner removes a runner. 404 (already gone) is success.
+func (c *Client) DeleteRunner(ctx context.Context, runnerID int64) error {
+	_, err := c.do(ctx, http.MethodDelete, fmt.Sprintf("%s/runners/%d", c.APIBase, runnerID), nil, http.StatusNoContent)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// DispatcherRunnerPrefix is the description prefix the dispatcher
+// gives every runner it creates. Lets the GC sweep target only its own
+// runners and ignore unrelated runners on the same project.
+const DispatcherRunnerPrefix = "dispatcher-"
+
+// IsDispatcherRunner reports whether the runner was created by this
+// dispatcher (matches the `dispatcher-<jobID>-<unix>` and
+// `dispatcher-warm-<unix>-<n>` descriptions).
+func IsDispatcherRunner(r Runner) bool {
+	return strings.HasPrefix(r.Description, DispatcherRunnerPrefix)
+}
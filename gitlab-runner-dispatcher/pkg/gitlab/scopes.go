@@ -0,0 +1,60 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RequiredScopes are the PAT scopes the dispatcher needs when the
+// token doesn't carry `api` (which implies all of them):
+//
+//   - `read_api` to list pending jobs and project runners.
+//   - `create_runner` for `POST /user/runners`.
+//   - `manage_runner` for `DELETE /runners/{id}` in the GC sweep.
+var RequiredScopes = []string{"read_api", "create_runner", "manage_runner"}
+
+// VerifyToken hits `GET /personal_access_tokens/self` and asserts the
+// token is active and carries `api` or every entry in RequiredScopes.
+// Fail-loud-with-instructions: a token missing scopes would otherwise
+// fail silently on every poll.
+func (c *Client) VerifyToken(ctx context.Context) error {
+	if c.Token == "" {
+		return fmt.Errorf("gitlab token is empty — set $GITLAB_TOKEN or pass --token=…")
+	}
+	body, err := c.do(ctx, http.MethodGet, c.APIBase+"/personal_access_tokens/self", nil, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("verify gitlab token: %w", err)
+	}
+	var tok struct {
+		Active  bool     `json:"active"`
+		Revoked bool     `json:"revoked"`
+		Scopes  []string `json:"scopes"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return fmt.Errorf("decode token info: %w", err)
+	}
+	if !tok.Active || tok.Revoked {
+		return fmt.Errorf("gitlab token is inactive or revoked — issue a new PAT with the `api` scope (or %s)", strings.Join(RequiredScopes, " + "))
+	}
+	granted := map[string]bool{}
+	for _, s := range tok.Scopes {
+		granted[s] = true
+	}
+	if granted["api"] {
+		return nil
+	}
+	var missing []string
+	for _, s := range RequiredScopes {
+		if !granted[s] {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("gitlab token is missing scopes %v (granted %v) — issue a PAT with `api`, or with %s",
+			missing, tok.Scopes, strings.Join(RequiredScopes, " + "))
+	}
+	return nil
+}
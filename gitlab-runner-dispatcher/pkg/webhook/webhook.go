@@ -0,0 +1,136 @@
+// Package webhook is the dispatcher's HTTP ingress for GitLab Job
+// Hooks (`X-Gitlab-Event: Job Hook`). A hook for a pending job becomes
+// one gitlab.Job on the channel the dispatch loop drains, so a webhook
+// job takes exactly the path a polled one does (tag match, seen-set
+// dedup, spawn, Mark).
+//
+// GitLab doesn't sign deliveries; it echoes the hook's secret token in
+// `X-Gitlab-Token`. Every delivery must carry the configured secret
+// (constant-time compare) or it's rejected with 401 before the payload
+// is parsed. The Job Hook payload has no tag list, so the handler
+// re-reads the job from the API — which also confirms it's still
+// pending before anything is spawned.
+//
+// Missed deliveries are caught by the reconciliation poll the
+// dispatcher keeps running at a slower cadence.
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/sockerless/gitlab-runner-dispatcher/pkg/gitlab"
+)
+
+// Path is where the dispatcher mounts the handler.
+const Path = "/webhook"
+
+// maxPayload caps Job Hook bodies; they're a few KB in practice.
+const maxPayload = 1 << 20
+
+// Handler receives Job Hook deliveries for one project.
+type Handler struct {
+	Secret []byte
+	GitLab *gitlab.Client // job lookup; its ProjectID scopes deliveries
+	Jobs   chan<- gitlab.Job
+}
+
+// New builds a Handler feeding jobs. secret is required and gl must
+// have its project resolved.
+func New(secret string, gl *gitlab.Client, jobs chan<- gitlab.Job) (*Handler, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("webhook secret is required")
+	}
+	if gl == nil || gl.ProjectID == 0 {
+		return nil, fmt.Errorf("webhook needs a gitlab client with a resolved project")
+	}
+	return &Handler{Secret: []byte(secret), GitLab: gl, Jobs: jobs}, nil
+}
+
+// ServeHTTP implements http.Handler. Responses: 202 for a pending job
+// handed to the dispatch loop or a delivery that needs no action, 401
+// for a wrong token, 502 when the job lookup fails, 503 when the
+// dispatch loop did not take the job before the request was cancelled.
+// GitLab shows non-2xx deliveries in the hook's Recent events; the
+// reconciliation poll picks those jobs up.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), h.Secret) != 1 {
+		http.Error(w, "invalid X-Gitlab-Token", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayload+1))
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxPayload {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if event := r.Header.Get("X-Gitlab-Event"); event != "Job Hook" {
+		log.Printf("webhook: ignoring %q delivery", event)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	var p struct {
+		ObjectKind  string `json:"object_kind"`
+		BuildID     int64  `json:"build_id"`
+		BuildStatus string `json:"build_status"`
+		ProjectID   int64  `json:"project_id"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		http.Error(w, "decode job hook payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if p.ObjectKind != "build" || p.BuildStatus != "pending" {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if p.ProjectID != h.GitLab.ProjectID {
+		log.Printf("webhook: ignoring job %d of project %d (dispatching for %d)", p.BuildID, p.ProjectID, h.GitLab.ProjectID)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if p.BuildID == 0 {
+		http.Error(w, "job hook payload has no build_id", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.GitLab.GetJob(r.Context(), p.BuildID)
+	if err != nil {
+		log.Printf("webhook: look up job %d: %v", p.BuildID, err)
+		http.Error(w, "job lookup failed", http.StatusBadGateway)
+		return
+	}
+	if job.Status != "pending" {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err := h.enqueue(r.Context(), job); err != nil {
+		log.Printf("webhook: job %d not queued: %v", job.JobID, err)
+		http.Error(w, "dispatcher busy", http.StatusServiceUnavailable)
+		return
+	}
+	log.Printf("webhook: queued job %d (%s) tags=%v", job.JobID, job.Name, job.Tags)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) enqueue(ctx context.Context, job gitlab.Job) error {
+	select {
+	case h.Jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
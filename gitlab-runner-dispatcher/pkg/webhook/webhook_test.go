@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sockerless/gitlab-runner-dispatcher/pkg/gitlab"
+	"github.com/sockerless/gitlab-runner-dispatcher/pkg/gitlabtest"
+)
+
+// setup starts a GitLab stand-in with project group/app and a hook
+// pointing at a Handler with secret "s3cret"; the hook uses hookToken.
+func setup(t *testing.T, hookToken string) (*gitlabtest.Server, chan gitlab.Job) {
+	t.Helper()
+	gl := gitlabtest.NewServer()
+	t.Cleanup(gl.Close)
+	gl.AddProject("group/app")
+
+	client := gitlab.New(nil, gl.URL, gl.Token, "group/app")
+	if err := client.ResolveProject(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	jobs := make(chan gitlab.Job, 4)
+	h, err := New("s3cret", client, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ingress := httptest.NewServer(h)
+	t.Cleanup(ingress.Close)
+	gl.AddHook("group/app", ingress.URL+Path, hookToken)
+	return gl, jobs
+}
+
+func TestHandlerPendingJob(t *testing.T) {
+	gl, jobs := setup(t, "s3cret")
+	id := gl.AddJob("group/app", "build", "sockerless-ecs", "large")
+
+	select {
+	case j := <-jobs:
+		if j.JobID != id || j.Name != "build" || j.Project != "group/app" {
+			t.Fatalf("job = %+v", j)
+		}
+		if len(j.Tags) != 2 || j.Tags[0] != "sockerless-ecs" {
+			t.Fatalf("tags = %v (must come from the API, the hook has none)", j.Tags)
+		}
+	default:
+		t.Fatal("no job on channel")
+	}
+	if d := gl.Deliveries(); len(d) != 1 || d[0].StatusCode != http.StatusAccepted {
+		t.Fatalf("deliveries = %+v", d)
+	}
+}
+
+func TestHandlerRejectsWrongToken(t *testing.T) {
+	gl, jobs := setup(t, "wrong")
+	gl.AddJob("group/app", "build", "sockerless-ecs")
+
+	if d := gl.Deliveries(); len(d) != 1 || d[0].StatusCode != http.StatusUnauthorized {
+		t.Fatalf("deliveries = %+v, want one 401", d)
+	}
+	if len(jobs) != 0 {
+		t.Fatal("job queued despite wrong token")
+	}
+}
+
+func TestHandlerIgnoresNonPending(t *testing.T) {
+	gl, jobs := setup(t, "s3cret")
+	id := gl.AddJob("group/app", "build", "sockerless-ecs")
+	<-jobs
+	gl.SetJobStatus(id, "running")
+	gl.SetJobStatus(id, "success")
+
+	if len(jobs) != 0 {
+		t.Fatal("non-pending job event must not be queued")
+	}
+	for _, d := range gl.Deliveries() {
+		if d.StatusCode != http.StatusAccepted {
+			t.Fatalf("delivery %+v, want 202", d)
+		}
+	}
+}
+
+func TestHandlerIgnoresOtherEvents(t *testing.T) {
+	client := gitlab.New(nil, "http://unused", "tok", "group/app")
+	client.ProjectID = 1
+	jobs := make(chan gitlab.Job, 1)
+	h, err := New("s3cret", client, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(`{"object_kind":"pipeline"}`))
+	req.Header.Set("X-Gitlab-Event", "Pipeline Hook")
+	req.Header.Set("X-Gitlab-Token", "s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted || len(jobs) != 0 {
+		t.Fatalf("status = %d, queued = %d", rec.Code, len(jobs))
+	}
+}
+
+func TestNewRequiresSecretAndProject(t *testing.T) {
+	client := gitlab.New(nil, "", "tok", "group/app")
+	if _, err := New("", client, nil); err == nil {
+		t.Error("New without a secret should fail")
+	}
+	if _, err := New("s3cret", client, nil); err == nil {
+		t.Error("New with an unresolved project should fail")
+	}
+}
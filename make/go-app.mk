@@ -19,6 +19,9 @@
 #   GO_BUILD_FLAGS   : extra `go build` flags
 #   GO_ENV           : env prepended to every go invocation (e.g. GOWORK=off)
 #   FAAS_SMOKE_TESTS : regexp for the backend's sim-backed FaaS smoke tests
+#   CASSETTE         : cassette for test-integration-record / -replay
+#                      (default testdata/cassettes/integration.jsonl)
+#   RUN              : `go test -run` filter for test-integration-replay
 #
 # Convention: this file lives at <repo>/make/go-app.mk. Leaf Makefiles
 # discover the repo root by counting up from $(CURDIR) — but to keep
@@ -36,7 +39,7 @@ include $(REPO_ROOT)/make/colors.mk
 
 # ── Standardized targets ────────────────────────────────────────────
 
-.PHONY: install build build-noui embed run dev test test-integration test-faas-smoke lint clean
+.PHONY: install build build-noui embed run dev test test-integration test-integration-record test-integration-replay test-faas-smoke lint clean
 
 install: ## install Go module deps
 	$(GO_ENV) go mod download
@@ -106,6 +109,14 @@ test: ## run unit tests
 test-integration: ## run integration tests against the local simulator
 	$(GO_ENV) SOCKERLESS_TEST_TARGET=sim go test -tags noui -v -timeout 15m ./...
 
+CASSETTE ?= testdata/cassettes/integration.jsonl
+
+test-integration-record: ## run integration tests against the local simulator, recording the backend's endpoint traffic to CASSETTE
+	$(GO_ENV) SOCKERLESS_TEST_TARGET=sim SOCKERLESS_TEST_RECORD=$(abspath $(CASSETTE)) go test -tags noui -v -count=1 -timeout 15m $(if $(RUN),-run '$(RUN)') ./...
+
+test-integration-replay: ## replay CASSETTE against the backend — no simulator or cloud needed
+	$(GO_ENV) SOCKERLESS_TEST_TARGET=replay SOCKERLESS_TEST_CASSETTE=$(abspath $(CASSETTE)) go test -tags noui -v -count=1 -timeout 15m $(if $(RUN),-run '$(RUN)') ./...
+
 test-faas-smoke: ## run this backend's sim-backed FaaS runner smoke test
 ifndef FAAS_SMOKE_TESTS
 	@printf "$(COLOR_DIM)$(APP_NAME): no FaaS smoke test configured.$(COLOR_RESET)\n"
@@ -269,3 +269,81 @@ func TestECS_CLI_TagAndUntagTask(t *testing.T) {
 		assert.NotEqual(t, "sockerless-name", tag.Key, "untagged key should not be present")
 	}
 }
+
+func TestECS_CLI_ServiceOnFargateSpot(t *testing.T) {
+	runCLI(t, awsCLI("ecs", "create-cluster", "--cluster-name", "cli-svc-cluster",
+		"--capacity-providers", "FARGATE", "FARGATE_SPOT",
+		"--default-capacity-provider-strategy", "capacityProvider=FARGATE_SPOT,weight=1"))
+
+	out := runCLI(t, awsCLI("ecs", "register-task-definition",
+		"--family", "cli-svc-task",
+		"--requires-compatibilities", "FARGATE",
+		"--network-mode", "awsvpc",
+		"--cpu", "256",
+		"--memory", "512",
+		"--container-definitions", `[{"name": "app", "image": "alpine:latest", "command": ["tail", "-f", "/dev/null"]}]`,
+		"--output", "json",
+	))
+	var td struct {
+		TaskDefinition struct {
+			TaskDefinitionArn string `json:"taskDefinitionArn"`
+		} `json:"taskDefinition"`
+	}
+	parseJSON(t, out, &td)
+
+	// No launch type or strategy: the cluster default (Spot) applies.
+	runCLI(t, awsCLI("ecs", "create-service",
+		"--cluster", "cli-svc-cluster",
+		"--service-name", "cli-svc",
+		"--task-definition", td.TaskDefinition.TaskDefinitionArn,
+		"--desired-count", "2",
+		"--network-configuration", `awsvpcConfiguration={subnets=[subnet-0123456789abcdef0]}`,
+		"--output", "json",
+	))
+
+	type svcDesc struct {
+		Services []struct {
+			Status       string `json:"status"`
+			RunningCount int    `json:"runningCount"`
+			Deployments  []struct {
+				RolloutState string `json:"rolloutState"`
+			} `json:"deployments"`
+		} `json:"services"`
+	}
+	require.Eventually(t, func() bool {
+		var d svcDesc
+		parseJSON(t, runCLI(t, awsCLI("ecs", "describe-services",
+			"--cluster", "cli-svc-cluster", "--services", "cli-svc", "--output", "json")), &d)
+		return len(d.Services) == 1 && d.Services[0].RunningCount == 2 &&
+			len(d.Services[0].Deployments) == 1 && d.Services[0].Deployments[0].RolloutState == "COMPLETED"
+	}, 60*time.Second, 500*time.Millisecond)
+
+	out = runCLI(t, awsCLI("ecs", "list-tasks", "--cluster", "cli-svc-cluster",
+		"--service-name", "cli-svc", "--output", "json"))
+	var list struct {
+		TaskArns []string `json:"taskArns"`
+	}
+	parseJSON(t, out, &list)
+	require.Len(t, list.TaskArns, 2)
+
+	out = runCLI(t, awsCLI(append([]string{"ecs", "describe-tasks", "--cluster", "cli-svc-cluster",
+		"--output", "json", "--tasks"}, list.TaskArns...)...))
+	var tasks struct {
+		Tasks []struct {
+			CapacityProviderName string `json:"capacityProviderName"`
+		} `json:"tasks"`
+	}
+	parseJSON(t, out, &tasks)
+	for _, task := range tasks.Tasks {
+		assert.Equal(t, "FARGATE_SPOT", task.CapacityProviderName)
+	}
+
+	out = runCLI(t, awsCLI("ecs", "list-services", "--cluster", "cli-svc-cluster", "--output", "json"))
+	assert.Contains(t, out, "service/cli-svc-cluster/cli-svc")
+
+	runCLI(t, awsCLI("ecs", "update-service", "--cluster", "cli-svc-cluster",
+		"--service", "cli-svc", "--desired-count", "0"))
+	out = runCLI(t, awsCLI("ecs", "delete-service", "--cluster", "cli-svc-cluster",
+		"--service", "cli-svc", "--output", "json"))
+	assert.True(t, strings.Contains(out, `"DRAINING"`) || strings.Contains(out, `"INACTIVE"`), out)
+}
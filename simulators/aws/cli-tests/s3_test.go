@@ -1,6 +1,7 @@
 package aws_cli_test
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
@@ -68,3 +69,73 @@ func TestS3_RemoveBucket(t *testing.T) {
 	out := runCLI(t, awsS3CLI("s3", "ls"))
 	assert.NotContains(t, out, "remove-test-bucket")
 }
+
+func TestS3_MultipartCopy(t *testing.T) {
+	runCLI(t, awsS3CLI("s3", "mb", "s3://multipart-test-bucket"))
+
+	// 20 MiB is above the CLI's 8 MiB multipart threshold.
+	content := bytes.Repeat([]byte("0123456789abcdef"), 20<<20/16)
+	localFile := filepath.Join(tmpDir, "large.bin")
+	require.NoError(t, os.WriteFile(localFile, content, 0644))
+
+	runCLI(t, awsS3CLI("s3", "cp", localFile, "s3://multipart-test-bucket/large.bin"))
+
+	var head struct {
+		ContentLength int64  `json:"ContentLength"`
+		ETag          string `json:"ETag"`
+	}
+	parseJSON(t, runCLI(t, awsS3CLI("s3api", "head-object",
+		"--bucket", "multipart-test-bucket", "--key", "large.bin")), &head)
+	assert.Equal(t, int64(len(content)), head.ContentLength)
+	assert.Regexp(t, `-\d+"$`, head.ETag, "multipart ETag carries the part count")
+
+	downloadFile := filepath.Join(tmpDir, "large-downloaded.bin")
+	runCLI(t, awsS3CLI("s3", "cp", "s3://multipart-test-bucket/large.bin", downloadFile))
+	data, err := os.ReadFile(downloadFile)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(content, data), "downloaded object differs from upload")
+
+	runCLI(t, awsS3CLI("s3", "rm", "s3://multipart-test-bucket/large.bin"))
+	runCLI(t, awsS3CLI("s3", "rb", "s3://multipart-test-bucket"))
+}
+
+func TestS3_MultipartAbort(t *testing.T) {
+	runCLI(t, awsS3CLI("s3", "mb", "s3://multipart-abort-bucket"))
+
+	var create struct {
+		UploadId string `json:"UploadId"`
+	}
+	parseJSON(t, runCLI(t, awsS3CLI("s3api", "create-multipart-upload",
+		"--bucket", "multipart-abort-bucket", "--key", "pending.bin")), &create)
+	require.NotEmpty(t, create.UploadId)
+
+	partFile := filepath.Join(tmpDir, "part1.bin")
+	require.NoError(t, os.WriteFile(partFile, []byte("part one"), 0644))
+	runCLI(t, awsS3CLI("s3api", "upload-part",
+		"--bucket", "multipart-abort-bucket", "--key", "pending.bin",
+		"--upload-id", create.UploadId, "--part-number", "1", "--body", partFile))
+
+	var parts struct {
+		Parts []struct {
+			PartNumber int   `json:"PartNumber"`
+			Size       int64 `json:"Size"`
+		} `json:"Parts"`
+	}
+	parseJSON(t, runCLI(t, awsS3CLI("s3api", "list-parts",
+		"--bucket", "multipart-abort-bucket", "--key", "pending.bin",
+		"--upload-id", create.UploadId)), &parts)
+	require.Len(t, parts.Parts, 1)
+	assert.Equal(t, int64(len("part one")), parts.Parts[0].Size)
+
+	out := runCLI(t, awsS3CLI("s3api", "list-multipart-uploads", "--bucket", "multipart-abort-bucket"))
+	assert.Contains(t, out, create.UploadId)
+
+	runCLI(t, awsS3CLI("s3api", "abort-multipart-upload",
+		"--bucket", "multipart-abort-bucket", "--key", "pending.bin",
+		"--upload-id", create.UploadId))
+
+	out = runCLI(t, awsS3CLI("s3api", "list-multipart-uploads", "--bucket", "multipart-abort-bucket"))
+	assert.NotContains(t, out, create.UploadId)
+
+	runCLI(t, awsS3CLI("s3", "rb", "s3://multipart-abort-bucket"))
+}
@@ -20,9 +20,11 @@ func handleDashboardSummary(w http.ResponseWriter, _ *http.Request) {
 		"provider": "aws",
 		"services": map[string]int{
 			"ecs_tasks":        ecsTasks.Len(),
+			"ecs_services":     ecsServices.Len(),
 			"lambda_functions": lambdaFunctions.Len(),
 			"ecr_repositories": ecrRepositories.Len(),
 			"s3_buckets":       s3Buckets_.Len(),
+			"s3_uploads":       s3Uploads.Len(),
 			"cw_log_groups":    cwLogGroups.Len(),
 		},
 	})
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/base64"
 	"net/http"
+	"strings"
 	"time"
 
 	sim "github.com/sockerless/simulator"
@@ -43,6 +44,7 @@ type ECRPullThroughCacheRule struct {
 	EcrRepositoryPrefix string `json:"ecrRepositoryPrefix"`
 	UpstreamRegistryUrl string `json:"upstreamRegistryUrl"`
 	UpstreamRegistry    string `json:"upstreamRegistry,omitempty"`
+	CredentialArn       string `json:"credentialArn,omitempty"`
 	RegistryId          string `json:"registryId"`
 	CreatedAt           int64  `json:"createdAt"`
 	UpdatedAt           int64  `json:"updatedAt,omitempty"`
@@ -106,6 +108,7 @@ func handleECRCreatePullThroughCacheRule(w http.ResponseWriter, r *http.Request)
 		EcrRepositoryPrefix string `json:"ecrRepositoryPrefix"`
 		UpstreamRegistryUrl string `json:"upstreamRegistryUrl"`
 		UpstreamRegistry    string `json:"upstreamRegistry"`
+		CredentialArn       string `json:"credentialArn"`
 		RegistryId          string `json:"registryId"`
 	}
 	if err := sim.ReadJSON(r, &req); err != nil {
@@ -116,6 +119,20 @@ func handleECRCreatePullThroughCacheRule(w http.ResponseWriter, r *http.Request)
 		sim.AWSError(w, "InvalidParameterException", "ecrRepositoryPrefix and upstreamRegistryUrl are required", http.StatusBadRequest)
 		return
 	}
+	if req.CredentialArn != "" {
+		// Real ECR only reads credentials from secrets named under the
+		// ecr-pullthroughcache/ prefix.
+		secret, ok := lookupSecretByArn(req.CredentialArn)
+		if !ok {
+			sim.AWSError(w, "SecretNotFoundException", "The specified secret does not exist", http.StatusBadRequest)
+			return
+		}
+		if !strings.HasPrefix(secret.Name, "ecr-pullthroughcache/") {
+			sim.AWSError(w, "UnableToAccessSecretException",
+				"The secret name must begin with the ecr-pullthroughcache/ prefix", http.StatusBadRequest)
+			return
+		}
+	}
 	if _, exists := ecrPullThroughCacheRules.Get(req.EcrRepositoryPrefix); exists {
 		sim.AWSError(w, "PullThroughCacheRuleAlreadyExistsException",
 			"A pull-through cache rule with the given prefix already exists",
@@ -131,6 +148,7 @@ func handleECRCreatePullThroughCacheRule(w http.ResponseWriter, r *http.Request)
 		EcrRepositoryPrefix: req.EcrRepositoryPrefix,
 		UpstreamRegistryUrl: req.UpstreamRegistryUrl,
 		UpstreamRegistry:    req.UpstreamRegistry,
+		CredentialArn:       req.CredentialArn,
 		RegistryId:          regID,
 		CreatedAt:           now,
 		UpdatedAt:           now,
@@ -141,6 +159,7 @@ func handleECRCreatePullThroughCacheRule(w http.ResponseWriter, r *http.Request)
 		"ecrRepositoryPrefix": rule.EcrRepositoryPrefix,
 		"upstreamRegistryUrl": rule.UpstreamRegistryUrl,
 		"upstreamRegistry":    rule.UpstreamRegistry,
+		"credentialArn":       rule.CredentialArn,
 		"registryId":          rule.RegistryId,
 		"createdAt":           rule.CreatedAt,
 	})
@@ -207,6 +226,7 @@ func handleECRDeletePullThroughCacheRule(w http.ResponseWriter, r *http.Request)
 		"ecrRepositoryPrefix": rule.EcrRepositoryPrefix,
 		"upstreamRegistryUrl": rule.UpstreamRegistryUrl,
 		"upstreamRegistry":    rule.UpstreamRegistry,
+		"credentialArn":       rule.CredentialArn,
 		"registryId":          rule.RegistryId,
 		"createdAt":           rule.CreatedAt,
 	})
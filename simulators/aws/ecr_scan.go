@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// ECRImageScanFinding is one basic-scanning finding.
+type ECRImageScanFinding struct {
+	Name        string                `json:"name"`
+	Severity    string                `json:"severity"`
+	Description string                `json:"description,omitempty"`
+	Uri         string                `json:"uri,omitempty"`
+	Attributes  []ECRFindingAttribute `json:"attributes,omitempty"`
+}
+
+// ECRFindingAttribute is a finding attribute (package_name,
+// package_version, CVSS2_SCORE, …).
+type ECRFindingAttribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ECRImageScan is the scan state of one image, keyed repo@digest.
+type ECRImageScan struct {
+	RepositoryName string                `json:"repositoryName"`
+	ImageDigest    string                `json:"imageDigest"`
+	Status         string                `json:"status"`
+	CompletedAt    int64                 `json:"completedAt"`
+	Findings       []ECRImageScanFinding `json:"findings"`
+}
+
+var (
+	ecrImageScans sim.Store[ECRImageScan]
+	// ecrSeededFindings holds findings registered through
+	// /sim/v1/ecr/scan-findings for images not scanned yet, keyed
+	// repo@digest; StartImageScan reports them.
+	ecrSeededFindings sim.Store[[]ECRImageScanFinding]
+)
+
+func registerECRScanning(r *sim.AWSRouter, srv *sim.Server) {
+	ecrImageScans = sim.MakeStore[ECRImageScan](srv.DB(), "ecr_image_scans")
+	ecrSeededFindings = sim.MakeStore[[]ECRImageScanFinding](srv.DB(), "ecr_seeded_findings")
+
+	r.Register("AmazonEC2ContainerRegistry_V20150921.StartImageScan", handleECRStartImageScan)
+	r.Register("AmazonEC2ContainerRegistry_V20150921.DescribeImageScanFindings", handleECRDescribeImageScanFindings)
+
+	// Findings seeding. The sim has no vulnerability database, so a
+	// basic scan finds nothing unless a test registers findings for
+	// the image digest first; a scan that already completed is
+	// updated in place.
+	srv.HandleFunc("POST /sim/v1/ecr/scan-findings", handleECRSeedScanFindings)
+}
+
+// ecrScanImage resolves the request's repository + imageId to a stored
+// image, writing ImageNotFoundException when missing.
+func ecrScanImage(w http.ResponseWriter, repo, tag, digest string) (ECRImageDetail, bool) {
+	key := repo + ":" + tag
+	if digest != "" {
+		key = repo + ":" + digest
+	}
+	img, ok := ecrImages.Get(key)
+	if !ok {
+		sim.AWSErrorf(w, "ImageNotFoundException", http.StatusBadRequest,
+			"The image with imageId {imageDigest:'%s', imageTag:'%s'} does not exist within the repository with name '%s'", digest, tag, repo)
+	}
+	return img, ok
+}
+
+type ecrScanRequest struct {
+	RepositoryName string `json:"repositoryName"`
+	ImageId        struct {
+		ImageTag    string `json:"imageTag"`
+		ImageDigest string `json:"imageDigest"`
+	} `json:"imageId"`
+}
+
+// handleECRStartImageScan runs a basic scan. Real ECR takes a while
+// and allows one scan per image per day; the sim completes straight
+// away with the seeded findings.
+func handleECRStartImageScan(w http.ResponseWriter, r *http.Request) {
+	var req ecrScanRequest
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	img, ok := ecrScanImage(w, req.RepositoryName, req.ImageId.ImageTag, req.ImageId.ImageDigest)
+	if !ok {
+		return
+	}
+	key := img.RepositoryName + "@" + img.ImageDigest
+	findings, _ := ecrSeededFindings.Get(key)
+	ecrImageScans.Put(key, ECRImageScan{
+		RepositoryName: img.RepositoryName,
+		ImageDigest:    img.ImageDigest,
+		Status:         "COMPLETE",
+		CompletedAt:    time.Now().Unix(),
+		Findings:       findings,
+	})
+	sim.WriteJSON(w, http.StatusOK, map[string]any{
+		"registryId":      img.RegistryId,
+		"repositoryName":  img.RepositoryName,
+		"imageId":         map[string]string{"imageDigest": img.ImageDigest, "imageTag": req.ImageId.ImageTag},
+		"imageScanStatus": map[string]string{"status": "IN_PROGRESS"},
+	})
+}
+
+func handleECRDescribeImageScanFindings(w http.ResponseWriter, r *http.Request) {
+	var req ecrScanRequest
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	img, ok := ecrScanImage(w, req.RepositoryName, req.ImageId.ImageTag, req.ImageId.ImageDigest)
+	if !ok {
+		return
+	}
+	scan, ok := ecrImageScans.Get(img.RepositoryName + "@" + img.ImageDigest)
+	if !ok {
+		sim.AWSErrorf(w, "ScanNotFoundException", http.StatusBadRequest,
+			"Image scan does not exist for the image with '{imageDigest:'%s'}' in the repository with name '%s'", img.ImageDigest, img.RepositoryName)
+		return
+	}
+	counts := map[string]int{}
+	for _, f := range scan.Findings {
+		counts[f.Severity]++
+	}
+	findings := scan.Findings
+	if findings == nil {
+		findings = []ECRImageScanFinding{}
+	}
+	sim.WriteJSON(w, http.StatusOK, map[string]any{
+		"registryId":      img.RegistryId,
+		"repositoryName":  img.RepositoryName,
+		"imageId":         map[string]string{"imageDigest": img.ImageDigest, "imageTag": req.ImageId.ImageTag},
+		"imageScanStatus": map[string]string{"status": scan.Status, "description": "The scan was completed successfully."},
+		"imageScanFindings": map[string]any{
+			"imageScanCompletedAt":         scan.CompletedAt,
+			"vulnerabilitySourceUpdatedAt": scan.CompletedAt,
+			"findings":                     findings,
+			"findingSeverityCounts":        counts,
+		},
+	})
+}
+
+// handleECRSeedScanFindings registers the findings a scan of the image
+// reports: {"repositoryName", "imageDigest", "findings": [...]}.
+func handleECRSeedScanFindings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RepositoryName string                `json:"repositoryName"`
+		ImageDigest    string                `json:"imageDigest"`
+		Findings       []ECRImageScanFinding `json:"findings"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.RepositoryName == "" || req.ImageDigest == "" {
+		http.Error(w, "repositoryName and imageDigest are required", http.StatusBadRequest)
+		return
+	}
+	key := req.RepositoryName + "@" + req.ImageDigest
+	ecrSeededFindings.Put(key, req.Findings)
+	ecrImageScans.Update(key, func(s *ECRImageScan) {
+		s.Findings = req.Findings
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -26,6 +27,11 @@ type ECSCluster struct {
 	PendingTasksCount                 int    `json:"pendingTasksCount"`
 	ActiveServicesCount               int    `json:"activeServicesCount"`
 	RegisteredContainerInstancesCount int    `json:"registeredContainerInstancesCount"`
+	// CapacityProviders are the providers associated with the cluster
+	// (CreateCluster / PutClusterCapacityProviders); a capacity
+	// provider strategy may only name these.
+	CapacityProviders               []string                          `json:"capacityProviders,omitempty"`
+	DefaultCapacityProviderStrategy []ECSCapacityProviderStrategyItem `json:"defaultCapacityProviderStrategy,omitempty"`
 }
 
 type ECSContainerDefinition struct {
@@ -144,6 +150,8 @@ type ECSTask struct {
 	Cpu                  string                `json:"cpu,omitempty"`
 	Memory               string                `json:"memory,omitempty"`
 	Group                string                `json:"group,omitempty"`
+	StartedBy            string                `json:"startedBy,omitempty"`
+	CapacityProviderName string                `json:"capacityProviderName,omitempty"`
 	EnableExecuteCommand bool                  `json:"enableExecuteCommand,omitempty"`
 	NetworkConfiguration *ECSTaskNetworkConfig `json:"networkConfiguration,omitempty"`
 }
@@ -198,6 +206,7 @@ func registerECS(r *sim.AWSRouter, srv *sim.Server) {
 	r.Register("AmazonEC2ContainerServiceV20141113.TagResource", handleECSTagResource)
 	r.Register("AmazonEC2ContainerServiceV20141113.UntagResource", handleECSUntagResource)
 	r.Register("AmazonEC2ContainerServiceV20141113.ExecuteCommand", handleECSExecuteCommand(srv))
+	registerECSServices(r, srv)
 
 	// Static WebSocket route for ECS exec sessions (session ID is a path param)
 	srv.HandleFunc("GET /ecs-exec/{sessionId}", func(w http.ResponseWriter, r *http.Request) {
@@ -256,7 +265,9 @@ func registerECS(r *sim.AWSRouter, srv *sim.Server) {
 
 func handleECSCreateCluster(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ClusterName string `json:"clusterName"`
+		ClusterName                     string                            `json:"clusterName"`
+		CapacityProviders               []string                          `json:"capacityProviders"`
+		DefaultCapacityProviderStrategy []ECSCapacityProviderStrategyItem `json:"defaultCapacityProviderStrategy"`
 	}
 	if err := sim.ReadJSON(r, &req); err != nil {
 		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
@@ -271,6 +282,9 @@ func handleECSCreateCluster(w http.ResponseWriter, r *http.Request) {
 		ClusterName: req.ClusterName,
 		Status:      "ACTIVE",
 	}
+	if !setClusterCapacityProviders(w, &cluster, req.CapacityProviders, req.DefaultCapacityProviderStrategy) {
+		return
+	}
 	ecsClusters.Put(req.ClusterName, cluster)
 
 	sim.WriteJSON(w, http.StatusOK, map[string]any{
@@ -310,6 +324,9 @@ func handleECSDescribeClusters(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 			cluster.RunningTasksCount = runningCount
+			cluster.ActiveServicesCount = len(ecsServices.Filter(func(svc ECSService) bool {
+				return svc.ClusterArn == cluster.ClusterArn && svc.Status == "ACTIVE"
+			}))
 			clusters = append(clusters, cluster)
 		} else {
 			failures = append(failures, map[string]string{
@@ -472,15 +489,19 @@ func handleECSDescribeTaskDefinition(w http.ResponseWriter, r *http.Request) {
 
 func handleECSRunTask(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Cluster              string   `json:"cluster"`
-		TaskDefinition       string   `json:"taskDefinition"`
-		Count                int      `json:"count"`
-		LaunchType           string   `json:"launchType"`
-		Group                string   `json:"group"`
-		Tags                 []ECSTag `json:"tags,omitempty"`
-		PropagateTags        string   `json:"propagateTags,omitempty"`
-		EnableExecuteCommand bool     `json:"enableExecuteCommand,omitempty"`
-		NetworkConfiguration *struct {
+		Cluster        string   `json:"cluster"`
+		TaskDefinition string   `json:"taskDefinition"`
+		Count          int      `json:"count"`
+		LaunchType     string   `json:"launchType"`
+		Group          string   `json:"group"`
+		StartedBy      string   `json:"startedBy"`
+		Tags           []ECSTag `json:"tags,omitempty"`
+		// CapacityProviderStrategy places the tasks on FARGATE /
+		// FARGATE_SPOT; it is mutually exclusive with LaunchType.
+		CapacityProviderStrategy []ECSCapacityProviderStrategyItem `json:"capacityProviderStrategy,omitempty"`
+		PropagateTags            string                            `json:"propagateTags,omitempty"`
+		EnableExecuteCommand     bool                              `json:"enableExecuteCommand,omitempty"`
+		NetworkConfiguration     *struct {
 			AwsvpcConfiguration *struct {
 				Subnets        []string `json:"subnets"`
 				SecurityGroups []string `json:"securityGroups"`
@@ -519,24 +540,7 @@ func handleECSRunTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Resolve task definition
-	tdKey := req.TaskDefinition
-	if strings.HasPrefix(tdKey, "arn:") {
-		parts := strings.Split(tdKey, "/")
-		if len(parts) > 1 {
-			tdKey = parts[len(parts)-1]
-		}
-	}
-	if !strings.Contains(tdKey, ":") {
-		ecsRevisionMu.Lock()
-		rev, exists := ecsRevisions[tdKey]
-		ecsRevisionMu.Unlock()
-		if exists {
-			tdKey = fmt.Sprintf("%s:%d", tdKey, rev)
-		}
-	}
-
-	td, ok := ecsTaskDefinitions.Get(tdKey)
+	td, ok := resolveECSTaskDefinition(req.TaskDefinition)
 	if !ok {
 		sim.AWSErrorf(w, "ClientException", http.StatusBadRequest,
 			"Unable to describe task definition: %s", req.TaskDefinition)
@@ -554,317 +558,367 @@ func handleECSRunTask(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Real ECS validates the subnet exists in EC2 and uses its CIDR for
-	// task IP assignment. Pull the requested subnet up front; surface a
-	// clean InvalidParameterException when the caller passes one we
-	// don't know about (matches real AWS InvalidSubnetID.NotFound).
-	var requestedSubnet string
-	if req.NetworkConfiguration != nil && req.NetworkConfiguration.AwsvpcConfiguration != nil &&
-		len(req.NetworkConfiguration.AwsvpcConfiguration.Subnets) > 0 {
-		requestedSubnet = req.NetworkConfiguration.AwsvpcConfiguration.Subnets[0]
+	var network *ECSTaskNetworkConfig
+	if req.NetworkConfiguration != nil && req.NetworkConfiguration.AwsvpcConfiguration != nil {
+		vpc := req.NetworkConfiguration.AwsvpcConfiguration
+		network = &ECSTaskNetworkConfig{
+			AwsvpcConfiguration: &ECSTaskVpcConfig{
+				Subnets:        vpc.Subnets,
+				SecurityGroups: vpc.SecurityGroups,
+				AssignPublicIp: vpc.AssignPublicIp,
+			},
+		}
+	}
+
+	strategy, ok := resolveCapacityProviderStrategy(w, cluster, req.LaunchType, req.CapacityProviderStrategy)
+	if !ok {
+		return
+	}
+
+	// Merge tags: request tags take priority, then inherited from task def
+	var taskTags []ECSTag
+	if req.PropagateTags == "TASK_DEFINITION" && len(td.Tags) > 0 {
+		taskTags = append(taskTags, td.Tags...)
 	}
+	taskTags = append(taskTags, req.Tags...)
 
 	var tasks []ECSTask
+	placed := map[string]int{}
 	for i := 0; i < req.Count; i++ {
-		_ = i
-		taskID := generateUUID()
-		taskArn := fmt.Sprintf("arn:aws:ecs:"+awsRegion()+":"+awsAccountID()+":task/%s/%s", clusterName, taskID)
-
-		eniID := generateUUID()
-		var privateIP, subnetID string
-		if requestedSubnet != "" {
-			ip, ipErr := AllocateSubnetIP(requestedSubnet)
-			if ipErr != nil {
-				sim.AWSError(w, "InvalidParameterException", ipErr.Error(), http.StatusBadRequest)
-				return
-			}
-			privateIP = ip
-			subnetID = requestedSubnet
+		l := ecsTaskLaunch{
+			LaunchType:           req.LaunchType,
+			Group:                req.Group,
+			StartedBy:            req.StartedBy,
+			Tags:                 taskTags,
+			EnableExecuteCommand: req.EnableExecuteCommand,
+			Network:              network,
 		}
-		createdAt := float64(time.Now().Unix())
-
-		var containers []ECSTaskContainer
-		for _, cd := range td.ContainerDefinitions {
-			containers = append(containers, ECSTaskContainer{
-				ContainerArn: fmt.Sprintf("arn:aws:ecs:"+awsRegion()+":"+awsAccountID()+":container/%s", generateUUID()),
-				Name:         cd.Name,
-				LastStatus:   "PROVISIONING",
-				NetworkInterfaces: []ECSNetworkInterface{
-					{
-						AttachmentId:       eniID,
-						PrivateIpv4Address: privateIP,
-					},
-				},
-			})
+		if len(strategy) > 0 {
+			l.CapacityProvider = pickCapacityProvider(strategy, placed)
+			l.LaunchType = "FARGATE"
+			placed[l.CapacityProvider]++
 		}
-
-		// Merge tags: request tags take priority, then inherited from task def
-		var taskTags []ECSTag
-		if req.PropagateTags == "TASK_DEFINITION" && len(td.Tags) > 0 {
-			taskTags = append(taskTags, td.Tags...)
+		task, err := launchECSTask(cluster, td, l)
+		if err != nil {
+			sim.AWSError(w, "InvalidParameterException", err.Error(), http.StatusBadRequest)
+			return
 		}
-		taskTags = append(taskTags, req.Tags...)
+		tasks = append(tasks, task)
+	}
 
-		attachmentDetails := []ECSKeyValuePair{
-			{Name: "privateIPv4Address", Value: privateIP},
-		}
-		if subnetID != "" {
-			attachmentDetails = append([]ECSKeyValuePair{{Name: "subnetId", Value: subnetID}}, attachmentDetails...)
-		}
+	sim.WriteJSON(w, http.StatusOK, map[string]any{
+		"tasks":    tasks,
+		"failures": []any{},
+	})
+}
 
-		task := ECSTask{
-			TaskArn:              taskArn,
-			TaskDefinitionArn:    td.TaskDefinitionArn,
-			ClusterArn:           cluster.ClusterArn,
-			LastStatus:           "PROVISIONING",
-			DesiredStatus:        "RUNNING",
-			Containers:           containers,
-			CreatedAt:            &createdAt,
-			Tags:                 taskTags,
-			LaunchType:           req.LaunchType,
-			Cpu:                  td.Cpu,
-			Memory:               td.Memory,
-			Group:                req.Group,
-			EnableExecuteCommand: req.EnableExecuteCommand,
-			Attachments: []ECSAttachment{
+// ecsTaskLaunch carries the per-task settings RunTask and the service
+// scheduler pass to launchECSTask.
+type ecsTaskLaunch struct {
+	LaunchType           string
+	CapacityProvider     string
+	Group                string
+	StartedBy            string
+	Tags                 []ECSTag
+	EnableExecuteCommand bool
+	Network              *ECSTaskNetworkConfig
+}
+
+// launchECSTask creates one task from td on cluster and drives it
+// PROVISIONING → PENDING → RUNNING in the background, starting the real
+// container; the task moves to STOPPED when the container exits.
+func launchECSTask(cluster ECSCluster, td ECSTaskDefinition, l ecsTaskLaunch) (ECSTask, error) {
+	// Real ECS validates the subnet exists in EC2 and uses its CIDR for
+	// task IP assignment; an unknown subnet fails the launch.
+	var requestedSubnet string
+	if l.Network != nil && l.Network.AwsvpcConfiguration != nil && len(l.Network.AwsvpcConfiguration.Subnets) > 0 {
+		requestedSubnet = l.Network.AwsvpcConfiguration.Subnets[0]
+	}
+
+	taskID := generateUUID()
+	taskArn := fmt.Sprintf("arn:aws:ecs:"+awsRegion()+":"+awsAccountID()+":task/%s/%s", cluster.ClusterName, taskID)
+
+	eniID := generateUUID()
+	var privateIP, subnetID string
+	if requestedSubnet != "" {
+		ip, ipErr := AllocateSubnetIP(requestedSubnet)
+		if ipErr != nil {
+			return ECSTask{}, ipErr
+		}
+		privateIP = ip
+		subnetID = requestedSubnet
+	}
+	createdAt := float64(time.Now().Unix())
+
+	var containers []ECSTaskContainer
+	for _, cd := range td.ContainerDefinitions {
+		containers = append(containers, ECSTaskContainer{
+			ContainerArn: fmt.Sprintf("arn:aws:ecs:"+awsRegion()+":"+awsAccountID()+":container/%s", generateUUID()),
+			Name:         cd.Name,
+			LastStatus:   "PROVISIONING",
+			NetworkInterfaces: []ECSNetworkInterface{
 				{
-					Id:      eniID,
-					Type:    "ElasticNetworkInterface",
-					Status:  "ATTACHING",
-					Details: attachmentDetails,
+					AttachmentId:       eniID,
+					PrivateIpv4Address: privateIP,
 				},
 			},
-		}
+		})
+	}
 
-		// Store VPC network configuration from request
-		if req.NetworkConfiguration != nil && req.NetworkConfiguration.AwsvpcConfiguration != nil {
-			vpc := req.NetworkConfiguration.AwsvpcConfiguration
-			task.NetworkConfiguration = &ECSTaskNetworkConfig{
-				AwsvpcConfiguration: &ECSTaskVpcConfig{
-					Subnets:        vpc.Subnets,
-					SecurityGroups: vpc.SecurityGroups,
-					AssignPublicIp: vpc.AssignPublicIp,
-				},
-			}
-		}
+	attachmentDetails := []ECSKeyValuePair{
+		{Name: "privateIPv4Address", Value: privateIP},
+	}
+	if subnetID != "" {
+		attachmentDetails = append([]ECSKeyValuePair{{Name: "subnetId", Value: subnetID}}, attachmentDetails...)
+	}
+
+	task := ECSTask{
+		TaskArn:              taskArn,
+		TaskDefinitionArn:    td.TaskDefinitionArn,
+		ClusterArn:           cluster.ClusterArn,
+		LastStatus:           "PROVISIONING",
+		DesiredStatus:        "RUNNING",
+		Containers:           containers,
+		CreatedAt:            &createdAt,
+		Tags:                 l.Tags,
+		LaunchType:           l.LaunchType,
+		CapacityProviderName: l.CapacityProvider,
+		Cpu:                  td.Cpu,
+		Memory:               td.Memory,
+		Group:                l.Group,
+		StartedBy:            l.StartedBy,
+		EnableExecuteCommand: l.EnableExecuteCommand,
+		NetworkConfiguration: l.Network,
+		Attachments: []ECSAttachment{
+			{
+				Id:      eniID,
+				Type:    "ElasticNetworkInterface",
+				Status:  "ATTACHING",
+				Details: attachmentDetails,
+			},
+		},
+	}
 
-		ecsTasks.Put(taskID, task)
-		tasks = append(tasks, task)
+	ecsTasks.Put(taskID, task)
 
-		// Simulate async transition: PROVISIONING → PENDING → RUNNING
-		go func(id string, td ECSTaskDefinition, taskTags []ECSTag) {
-			// PROVISIONING → PENDING
-			time.Sleep(100 * time.Millisecond)
-			ecsTasks.Update(id, func(t *ECSTask) {
-				t.LastStatus = "PENDING"
-				for j := range t.Containers {
-					t.Containers[j].LastStatus = "PENDING"
+	// Simulate async transition: PROVISIONING → PENDING → RUNNING
+	go func(id string, td ECSTaskDefinition, taskTags []ECSTag) {
+		// PROVISIONING → PENDING
+		time.Sleep(100 * time.Millisecond)
+		ecsTasks.Update(id, func(t *ECSTask) {
+			t.LastStatus = "PENDING"
+			for j := range t.Containers {
+				t.Containers[j].LastStatus = "PENDING"
+			}
+		})
+
+		// PENDING → RUNNING
+		time.Sleep(400 * time.Millisecond)
+
+		// Extract image, entrypoint, command, and env from first container definition
+		var imageURI string
+		var entrypoint, args []string
+		var cmdEnv map[string]string
+		if len(td.ContainerDefinitions) > 0 {
+			cd := td.ContainerDefinitions[0]
+			imageURI = cd.Image
+			entrypoint = cd.EntryPoint
+			args = cd.Command
+			if len(cd.Environment) > 0 {
+				cmdEnv = make(map[string]string, len(cd.Environment))
+				for _, ev := range cd.Environment {
+					cmdEnv[ev.Name] = ev.Value
 				}
-			})
+			}
+		}
 
-			// PENDING → RUNNING
-			time.Sleep(400 * time.Millisecond)
+		// Mark task as RUNNING before starting containers
+		now := time.Now().Unix()
+		ecsTasks.Update(id, func(t *ECSTask) {
+			t.LastStatus = "RUNNING"
+			t.Connectivity = "CONNECTED"
+			t.StartedAt = &now
+			for j := range t.Containers {
+				t.Containers[j].LastStatus = "RUNNING"
+			}
+			for j := range t.Attachments {
+				t.Attachments[j].Status = "ATTACHED"
+			}
+		})
+		ecsTaskChanged(id)
+		scheduleSpotInterruption(id)
 
-			// Extract image, entrypoint, command, and env from first container definition
-			var imageURI string
-			var entrypoint, args []string
-			var cmdEnv map[string]string
-			if len(td.ContainerDefinitions) > 0 {
-				cd := td.ContainerDefinitions[0]
-				imageURI = cd.Image
-				entrypoint = cd.EntryPoint
-				args = cd.Command
-				if len(cd.Environment) > 0 {
-					cmdEnv = make(map[string]string, len(cd.Environment))
-					for _, ev := range cd.Environment {
-						cmdEnv[ev.Name] = ev.Value
-					}
-				}
+		// Inject CloudWatch logs for containers with awslogs log driver,
+		// and pick a sink for the real container we start below.
+		var sink sim.LogSink = discardLogSink{}
+		for _, cd := range td.ContainerDefinitions {
+			if cd.LogConfiguration == nil || cd.LogConfiguration.LogDriver != "awslogs" {
+				continue
+			}
+			logGroup := cd.LogConfiguration.Options["awslogs-group"]
+			streamPrefix := cd.LogConfiguration.Options["awslogs-stream-prefix"]
+			if logGroup == "" || streamPrefix == "" {
+				continue
+			}
+			logStreamName := fmt.Sprintf("%s/%s/%s", streamPrefix, cd.Name, id)
+			nowMs := time.Now().UnixMilli()
+
+			// Create log group if not exists
+			if _, exists := cwLogGroups.Get(logGroup); !exists {
+				cwLogGroups.Put(logGroup, CWLogGroup{
+					LogGroupName: logGroup,
+					Arn:          cwLogGroupArn(logGroup),
+					CreationTime: nowMs,
+				})
 			}
 
-			// Mark task as RUNNING before starting containers
-			now := time.Now().Unix()
-			ecsTasks.Update(id, func(t *ECSTask) {
-				t.LastStatus = "RUNNING"
-				t.Connectivity = "CONNECTED"
-				t.StartedAt = &now
-				for j := range t.Containers {
-					t.Containers[j].LastStatus = "RUNNING"
-				}
-				for j := range t.Attachments {
-					t.Attachments[j].Status = "ATTACHED"
-				}
+			// Create log stream
+			key := cwEventsKey(logGroup, logStreamName)
+			cwLogStreams.Put(key, CWLogStream{
+				LogStreamName:       logStreamName,
+				LogGroupName:        logGroup,
+				CreationTime:        nowMs,
+				FirstEventTimestamp: nowMs,
+				LastEventTimestamp:  nowMs,
+				Arn:                 cwLogStreamArn(logGroup, logStreamName),
+				UploadSequenceToken: "1",
 			})
 
-			// Inject CloudWatch logs for containers with awslogs log driver,
-			// and pick a sink for the real container we start below.
-			var sink sim.LogSink = discardLogSink{}
-			for _, cd := range td.ContainerDefinitions {
-				if cd.LogConfiguration == nil || cd.LogConfiguration.LogDriver != "awslogs" {
-					continue
-				}
-				logGroup := cd.LogConfiguration.Options["awslogs-group"]
-				streamPrefix := cd.LogConfiguration.Options["awslogs-stream-prefix"]
-				if logGroup == "" || streamPrefix == "" {
-					continue
-				}
-				logStreamName := fmt.Sprintf("%s/%s/%s", streamPrefix, cd.Name, id)
-				nowMs := time.Now().UnixMilli()
-
-				// Create log group if not exists
-				if _, exists := cwLogGroups.Get(logGroup); !exists {
-					cwLogGroups.Put(logGroup, CWLogGroup{
-						LogGroupName: logGroup,
-						Arn:          cwLogGroupArn(logGroup),
-						CreationTime: nowMs,
-					})
-				}
+			// Insert initial log event
+			cmdDesc := strings.Join(append(entrypoint, args...), " ")
+			if cmdDesc == "" {
+				cmdDesc = "container started"
+			}
+			cwLogEvents.Put(key, []CWLogEvent{
+				{
+					Timestamp:     nowMs,
+					Message:       cmdDesc,
+					IngestionTime: nowMs,
+				},
+			})
 
-				// Create log stream
-				key := cwEventsKey(logGroup, logStreamName)
-				cwLogStreams.Put(key, CWLogStream{
-					LogStreamName:       logStreamName,
-					LogGroupName:        logGroup,
-					CreationTime:        nowMs,
-					FirstEventTimestamp: nowMs,
-					LastEventTimestamp:  nowMs,
-					Arn:                 cwLogStreamArn(logGroup, logStreamName),
-					UploadSequenceToken: "1",
-				})
+			sink = &cwLogSink{logGroup: logGroup, logStream: logStreamName}
+			break
+		}
 
-				// Insert initial log event
-				cmdDesc := strings.Join(append(entrypoint, args...), " ")
-				if cmdDesc == "" {
-					cmdDesc = "container started"
+		// Always start the real container when an image is specified —
+		// task lifecycle (RUNNING → STOPPED) depends on handle.Wait()
+		// returning, regardless of whether logs are configured.
+		if imageURI != "" {
+			wantTTY := false
+			for _, tag := range taskTags {
+				if tag.Key == "sockerless-tty" && tag.Value == "true" {
+					wantTTY = true
+					break
 				}
-				cwLogEvents.Put(key, []CWLogEvent{
-					{
-						Timestamp:     nowMs,
-						Message:       cmdDesc,
-						IngestionTime: nowMs,
-					},
-				})
-
-				sink = &cwLogSink{logGroup: logGroup, logStream: logStreamName}
-				break
 			}
-
-			// Always start the real container when an image is specified —
-			// task lifecycle (RUNNING → STOPPED) depends on handle.Wait()
-			// returning, regardless of whether logs are configured.
-			if imageURI != "" {
-				wantTTY := false
-				for _, tag := range taskTags {
-					if tag.Key == "sockerless-tty" && tag.Value == "true" {
-						wantTTY = true
-						break
+			// Build bind mounts from task definition volumes + container mount points.
+			// For EFS volumes, translate to a real host path backed by the
+			// simulator's EFS slice (file system or access point root
+			// directory); otherwise fall through to a named Docker volume.
+			var binds []string
+			volMap := make(map[string]string) // volume name → docker bind source
+			for _, v := range td.Volumes {
+				if v.EfsVolumeConfiguration != nil {
+					cfg := v.EfsVolumeConfiguration
+					var host string
+					if cfg.AuthorizationConfig != nil && cfg.AuthorizationConfig.AccessPointId != "" {
+						host = EFSAccessPointHostDir(cfg.AuthorizationConfig.AccessPointId)
 					}
-				}
-				// Build bind mounts from task definition volumes + container mount points.
-				// For EFS volumes, translate to a real host path backed by the
-				// simulator's EFS slice (file system or access point root
-				// directory); otherwise fall through to a named Docker volume.
-				var binds []string
-				volMap := make(map[string]string) // volume name → docker bind source
-				for _, v := range td.Volumes {
-					if v.EfsVolumeConfiguration != nil {
-						cfg := v.EfsVolumeConfiguration
-						var host string
-						if cfg.AuthorizationConfig != nil && cfg.AuthorizationConfig.AccessPointId != "" {
-							host = EFSAccessPointHostDir(cfg.AuthorizationConfig.AccessPointId)
-						}
-						if host == "" && cfg.FileSystemId != "" {
-							host = EFSFileSystemHostDir(cfg.FileSystemId)
-							if cfg.RootDirectory != "" && cfg.RootDirectory != "/" {
-								host = fmt.Sprintf("%s/%s", host, strings.TrimPrefix(cfg.RootDirectory, "/"))
-							}
-						}
-						if host != "" {
-							volMap[v.Name] = host
-							continue
+					if host == "" && cfg.FileSystemId != "" {
+						host = EFSFileSystemHostDir(cfg.FileSystemId)
+						if cfg.RootDirectory != "" && cfg.RootDirectory != "/" {
+							host = fmt.Sprintf("%s/%s", host, strings.TrimPrefix(cfg.RootDirectory, "/"))
 						}
 					}
-					volMap[v.Name] = v.Name // fall back to named Docker volume
+					if host != "" {
+						volMap[v.Name] = host
+						continue
+					}
 				}
-				if len(td.ContainerDefinitions) > 0 {
-					for _, mp := range td.ContainerDefinitions[0].MountPoints {
-						if src, ok := volMap[mp.SourceVolume]; ok {
-							bind := src + ":" + mp.ContainerPath
-							if mp.ReadOnly {
-								bind += ":ro"
-							}
-							binds = append(binds, bind)
+				volMap[v.Name] = v.Name // fall back to named Docker volume
+			}
+			if len(td.ContainerDefinitions) > 0 {
+				for _, mp := range td.ContainerDefinitions[0].MountPoints {
+					if src, ok := volMap[mp.SourceVolume]; ok {
+						bind := src + ":" + mp.ContainerPath
+						if mp.ReadOnly {
+							bind += ":ro"
 						}
+						binds = append(binds, bind)
 					}
 				}
+			}
 
-				// Architecture: sim's primary capacity is linux/arm64.
-				// taskDef.RuntimePlatform.CpuArchitecture is not yet
-				// honoured here — the sim runs a single arch.
-				// Host metadata: AWS SDK respects
-				// AWS_EC2_METADATA_SERVICE_ENDPOINT + ECS_CONTAINER_METADATA_URI_V4.
-				envWithMetadata := mergeEnv(cmdEnv, hostMetadataEnv(id))
-				handle, err := sim.StartContainerSync(sim.ContainerConfig{
-					Image:        sim.ResolveLocalImage(imageURI),
-					Architecture: "linux/arm64",
-					Command:      entrypoint,
-					Args:         args,
-					Env:          envWithMetadata,
-					Name:         fmt.Sprintf("sockerless-sim-aws-task-%s", id[:12]),
-					Labels:       map[string]string{"sockerless-sim-task": id},
-					Tty:          wantTTY,
-					OpenStdin:    wantTTY,
-					Binds:        binds,
-					ExtraHosts:   hostMetadataExtraHosts(),
-					Sandbox:      sim.SandboxFargate, // BUG-1077: real Fargate restrictions.
-				}, sink)
-				if err != nil {
+			// Architecture: sim's primary capacity is linux/arm64.
+			// taskDef.RuntimePlatform.CpuArchitecture is not yet
+			// honoured here — the sim runs a single arch.
+			// Host metadata: AWS SDK respects
+			// AWS_EC2_METADATA_SERVICE_ENDPOINT + ECS_CONTAINER_METADATA_URI_V4.
+			envWithMetadata := mergeEnv(cmdEnv, hostMetadataEnv(id))
+			handle, err := sim.StartContainerSync(sim.ContainerConfig{
+				Image:        sim.ResolveLocalImage(imageURI),
+				Architecture: "linux/arm64",
+				Command:      entrypoint,
+				Args:         args,
+				Env:          envWithMetadata,
+				Name:         fmt.Sprintf("sockerless-sim-aws-task-%s", id[:12]),
+				Labels:       map[string]string{"sockerless-sim-task": id},
+				Tty:          wantTTY,
+				OpenStdin:    wantTTY,
+				Binds:        binds,
+				ExtraHosts:   hostMetadataExtraHosts(),
+				Sandbox:      sim.SandboxFargate, // BUG-1077: real Fargate restrictions.
+			}, sink)
+			if err != nil {
+				stoppedAt := time.Now().Unix()
+				ecsTasks.Update(id, func(t *ECSTask) {
+					t.LastStatus = "STOPPED"
+					t.DesiredStatus = "STOPPED"
+					t.StoppedAt = &stoppedAt
+					t.StopCode = "EssentialContainerExited"
+					t.StoppedReason = fmt.Sprintf("Container start failed: %v", err)
+					exitCode := -1
+					for j := range t.Containers {
+						t.Containers[j].LastStatus = "STOPPED"
+						t.Containers[j].ExitCode = &exitCode
+					}
+				})
+				ecsTaskChanged(id)
+			} else {
+				ecsProcessHandles.Store(id, handle)
+
+				go func(taskID string, handle *sim.ContainerHandle) {
+					result := handle.Wait()
+					ecsProcessHandles.Delete(taskID)
 					stoppedAt := time.Now().Unix()
-					ecsTasks.Update(id, func(t *ECSTask) {
+					exited := false
+					ecsTasks.Update(taskID, func(t *ECSTask) {
+						if t.LastStatus == "STOPPED" {
+							return // already stopped
+						}
+						exited = true
 						t.LastStatus = "STOPPED"
 						t.DesiredStatus = "STOPPED"
 						t.StoppedAt = &stoppedAt
 						t.StopCode = "EssentialContainerExited"
-						t.StoppedReason = fmt.Sprintf("Container start failed: %v", err)
-						exitCode := -1
+						t.StoppedReason = "Essential container in task exited"
+						exitCode := result.ExitCode
 						for j := range t.Containers {
 							t.Containers[j].LastStatus = "STOPPED"
 							t.Containers[j].ExitCode = &exitCode
 						}
 					})
-				} else {
-					ecsProcessHandles.Store(id, handle)
-
-					go func(taskID string, handle *sim.ContainerHandle) {
-						result := handle.Wait()
-						ecsProcessHandles.Delete(taskID)
-						stoppedAt := time.Now().Unix()
-						ecsTasks.Update(taskID, func(t *ECSTask) {
-							if t.LastStatus == "STOPPED" {
-								return // already stopped
-							}
-							t.LastStatus = "STOPPED"
-							t.DesiredStatus = "STOPPED"
-							t.StoppedAt = &stoppedAt
-							t.StopCode = "EssentialContainerExited"
-							t.StoppedReason = "Essential container in task exited"
-							exitCode := result.ExitCode
-							for j := range t.Containers {
-								t.Containers[j].LastStatus = "STOPPED"
-								t.Containers[j].ExitCode = &exitCode
-							}
-						})
-					}(id, handle)
-				}
+					if exited {
+						ecsTaskChanged(taskID)
+					}
+				}(id, handle)
 			}
+		}
 
-		}(taskID, td, taskTags)
-	}
+	}(taskID, td, l.Tags)
 
-	sim.WriteJSON(w, http.StatusOK, map[string]any{
-		"tasks":    tasks,
-		"failures": []any{},
-	})
+	return task, nil
 }
 
 func handleECSDescribeTasks(w http.ResponseWriter, r *http.Request) {
@@ -930,38 +984,43 @@ func handleECSStopTask(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Stop running container if any
+	if !stopECSTask(taskID, "UserInitiated", req.Reason, 0) {
+		sim.AWSErrorf(w, "InvalidParameterException", http.StatusBadRequest,
+			"Task not found: %s", req.Task)
+		return
+	}
+	ecsTaskChanged(taskID)
+
+	task, _ := ecsTasks.Get(taskID)
+	sim.WriteJSON(w, http.StatusOK, map[string]any{
+		"task": task,
+	})
+}
+
+// stopECSTask stops the task's container and records the stop. reason
+// may be empty to keep the task's existing StoppedReason. It reports
+// whether the task exists.
+func stopECSTask(taskID, stopCode, reason string, exitCode int) bool {
 	if v, ok := ecsProcessHandles.LoadAndDelete(taskID); ok {
 		handle := v.(*sim.ContainerHandle)
 		sim.StopContainer(handle.ContainerID)
 	}
 
 	now := time.Now().Unix()
-	found := ecsTasks.Update(taskID, func(t *ECSTask) {
+	return ecsTasks.Update(taskID, func(t *ECSTask) {
 		t.DesiredStatus = "STOPPED"
 		t.LastStatus = "STOPPED"
 		t.StoppedAt = &now
-		t.StopCode = "UserInitiated"
-		if req.Reason != "" {
-			t.StoppedReason = req.Reason
+		t.StopCode = stopCode
+		if reason != "" {
+			t.StoppedReason = reason
 		}
-		exitCode := 0
 		for j := range t.Containers {
+			code := exitCode
 			t.Containers[j].LastStatus = "STOPPED"
-			t.Containers[j].ExitCode = &exitCode
+			t.Containers[j].ExitCode = &code
 		}
 	})
-
-	if !found {
-		sim.AWSErrorf(w, "InvalidParameterException", http.StatusBadRequest,
-			"Task not found: %s", req.Task)
-		return
-	}
-
-	task, _ := ecsTasks.Get(taskID)
-	sim.WriteJSON(w, http.StatusOK, map[string]any{
-		"task": task,
-	})
 }
 
 func handleECSListTasks(w http.ResponseWriter, r *http.Request) {
@@ -969,6 +1028,8 @@ func handleECSListTasks(w http.ResponseWriter, r *http.Request) {
 		Cluster       string `json:"cluster"`
 		Family        string `json:"family"`
 		DesiredStatus string `json:"desiredStatus"`
+		ServiceName   string `json:"serviceName"`
+		StartedBy     string `json:"startedBy"`
 	}
 	if err := sim.ReadJSON(r, &req); err != nil {
 		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
@@ -1002,6 +1063,12 @@ func handleECSListTasks(w http.ResponseWriter, r *http.Request) {
 		if req.DesiredStatus != "" && t.DesiredStatus != req.DesiredStatus {
 			return false
 		}
+		if req.ServiceName != "" && t.Group != "service:"+ecsResourceName(req.ServiceName) {
+			return false
+		}
+		if req.StartedBy != "" && t.StartedBy != req.StartedBy {
+			return false
+		}
 		return true
 	})
 
@@ -1045,6 +1112,13 @@ func handleECSDeleteCluster(w http.ResponseWriter, r *http.Request) {
 			"Cluster not found: %s", req.Cluster)
 		return
 	}
+	if len(ecsServices.Filter(func(svc ECSService) bool {
+		return svc.ClusterArn == cluster.ClusterArn && svc.Status != "INACTIVE"
+	})) > 0 {
+		sim.AWSError(w, "ClusterContainsServicesException",
+			"The Cluster cannot be deleted while Services are active.", http.StatusBadRequest)
+		return
+	}
 
 	cluster.Status = "INACTIVE"
 	ecsClusters.Delete(name)
@@ -1112,7 +1186,21 @@ func handleECSTagResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Other resource types (cluster, service, container-instance) —
+	// Service ARN: tag the service.
+	if strings.Contains(req.ResourceArn, ":service/") {
+		svc, ok := ecsServiceByArn(req.ResourceArn)
+		if !ok {
+			sim.AWSError(w, "ServiceNotFoundException", "Service not found: "+req.ResourceArn, http.StatusBadRequest)
+			return
+		}
+		ecsServices.Update(ecsServiceKey(svc.ClusterArn, svc.ServiceName), func(s *ECSService) {
+			s.Tags = mergeECSTagsByKey(s.Tags, req.Tags)
+		})
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+		return
+	}
+
+	// Other resource types (cluster, container-instance) —
 	// not used by sockerless today; surface a clear error rather
 	// than silently succeeding (no fakes / no fallbacks).
 	sim.AWSError(w, "InvalidParameterException", "tag-target type not implemented in sim: "+req.ResourceArn, http.StatusBadRequest)
@@ -1178,6 +1266,18 @@ func handleECSUntagResource(w http.ResponseWriter, r *http.Request) {
 		sim.WriteJSON(w, http.StatusOK, map[string]any{})
 		return
 	}
+	if strings.Contains(req.ResourceArn, ":service/") {
+		svc, ok := ecsServiceByArn(req.ResourceArn)
+		if !ok {
+			sim.AWSError(w, "ServiceNotFoundException", "Service not found: "+req.ResourceArn, http.StatusBadRequest)
+			return
+		}
+		ecsServices.Update(ecsServiceKey(svc.ClusterArn, svc.ServiceName), func(s *ECSService) {
+			s.Tags = keep(s.Tags)
+		})
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+		return
+	}
 	sim.AWSError(w, "InvalidParameterException", "untag-target type not implemented in sim: "+req.ResourceArn, http.StatusBadRequest)
 }
 
@@ -1230,6 +1330,10 @@ func handleECSListTagsForResource(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if svc, ok := ecsServiceByArn(req.ResourceArn); ok {
+		tags = svc.Tags
+	}
+
 	if tags == nil {
 		tags = []ECSTag{}
 	}
@@ -1437,6 +1541,20 @@ func handleECSExecWebSocket(sessionID string) http.HandlerFunc {
 						if mt != ssmMTInputStreamData {
 							continue
 						}
+						if ssmInputPayloadType(msg) == ssmPayloadSize {
+							// Terminal size, not stdin. The exec has a
+							// PTY only when created with Tty, so a
+							// resize of a plain exec fails and is dropped
+							// as ssm-agent drops it.
+							var size struct {
+								Cols uint `json:"cols"`
+								Rows uint `json:"rows"`
+							}
+							if json.Unmarshal(payload, &size) == nil {
+								_ = cli.ContainerExecResize(r.Context(), execResp.ID, dockercontainer.ResizeOptions{Height: size.Rows, Width: size.Cols})
+							}
+							continue
+						}
 						if len(payload) > 0 {
 							if _, werr := attach.Conn.Write(payload); werr != nil {
 								return
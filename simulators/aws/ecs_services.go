@@ -0,0 +1,1001 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// ECSCapacityProviderStrategyItem is one entry of a capacity provider
+// strategy. Base tasks are placed on the provider first; the rest are
+// spread by weight.
+type ECSCapacityProviderStrategyItem struct {
+	CapacityProvider string `json:"capacityProvider"`
+	Weight           int    `json:"weight"`
+	Base             int    `json:"base,omitempty"`
+}
+
+// ECSService is a long-running ECS service: the scheduler keeps
+// DesiredCount tasks of the primary deployment running, replacing any
+// task that stops.
+type ECSService struct {
+	ServiceArn               string                            `json:"serviceArn"`
+	ServiceName              string                            `json:"serviceName"`
+	ClusterArn               string                            `json:"clusterArn"`
+	TaskDefinition           string                            `json:"taskDefinition"`
+	DesiredCount             int                               `json:"desiredCount"`
+	RunningCount             int                               `json:"runningCount"`
+	PendingCount             int                               `json:"pendingCount"`
+	Status                   string                            `json:"status"` // ACTIVE, DRAINING, INACTIVE
+	LaunchType               string                            `json:"launchType,omitempty"`
+	CapacityProviderStrategy []ECSCapacityProviderStrategyItem `json:"capacityProviderStrategy,omitempty"`
+	NetworkConfiguration     *ECSTaskNetworkConfig             `json:"networkConfiguration,omitempty"`
+	Deployments              []ECSDeployment                   `json:"deployments"`
+	Events                   []ECSServiceEvent                 `json:"events"`
+	SchedulingStrategy       string                            `json:"schedulingStrategy"`
+	PropagateTags            string                            `json:"propagateTags,omitempty"`
+	EnableExecuteCommand     bool                              `json:"enableExecuteCommand"`
+	Tags                     []ECSTag                          `json:"tags,omitempty"`
+	CreatedAt                float64                           `json:"createdAt"`
+}
+
+// ECSDeployment is one rollout of a service. Deployments[0] is always
+// the PRIMARY one; older ACTIVE deployments are drained once the
+// primary reaches its desired count.
+type ECSDeployment struct {
+	Id                       string                            `json:"id"`
+	Status                   string                            `json:"status"` // PRIMARY, ACTIVE
+	TaskDefinition           string                            `json:"taskDefinition"`
+	DesiredCount             int                               `json:"desiredCount"`
+	PendingCount             int                               `json:"pendingCount"`
+	RunningCount             int                               `json:"runningCount"`
+	FailedTasks              int                               `json:"failedTasks"`
+	LaunchType               string                            `json:"launchType,omitempty"`
+	CapacityProviderStrategy []ECSCapacityProviderStrategyItem `json:"capacityProviderStrategy,omitempty"`
+	NetworkConfiguration     *ECSTaskNetworkConfig             `json:"networkConfiguration,omitempty"`
+	RolloutState             string                            `json:"rolloutState"` // IN_PROGRESS, COMPLETED
+	RolloutStateReason       string                            `json:"rolloutStateReason"`
+	CreatedAt                float64                           `json:"createdAt"`
+	UpdatedAt                float64                           `json:"updatedAt"`
+}
+
+// ECSServiceEvent is a service event message, newest first.
+type ECSServiceEvent struct {
+	Id        string  `json:"id"`
+	CreatedAt float64 `json:"createdAt"`
+	Message   string  `json:"message"`
+}
+
+// Fargate's built-in capacity providers. Custom (Auto Scaling group)
+// capacity providers aren't simulated.
+var fargateCapacityProviders = []string{"FARGATE", "FARGATE_SPOT"}
+
+const (
+	// maxECSServiceEvents bounds the events list like ECS does.
+	maxECSServiceEvents = 100
+	// ecsServiceHealthyRuntime is how long a service task must run
+	// before its exit stops counting towards the start-failure
+	// throttle.
+	ecsServiceHealthyRuntime = 10 * time.Second
+	ecsServiceMaxBackoff     = 5 * time.Minute
+)
+
+var (
+	ecsServices sim.Store[ECSService]
+
+	// ecsServiceMu serialises scheduler passes so two reconciles never
+	// launch replacements for the same stopped task.
+	ecsServiceMu sync.Mutex
+	// ecsServiceThrottles holds the start-failure backoff per service
+	// key. It is scheduler state, not part of the API shape.
+	ecsServiceThrottles = map[string]*ecsServiceThrottle{}
+
+	// ecsSpotInterruptionAfter is SIM_ECS_SPOT_INTERRUPTION_AFTER;
+	// zero disables automatic Spot interruptions.
+	ecsSpotInterruptionAfter time.Duration
+)
+
+type ecsServiceThrottle struct {
+	failures int
+	until    time.Time
+	timer    *time.Timer
+}
+
+func registerECSServices(r *sim.AWSRouter, srv *sim.Server) {
+	ecsServices = sim.MakeStore[ECSService](srv.DB(), "ecs_services")
+
+	if v := os.Getenv("SIM_ECS_SPOT_INTERRUPTION_AFTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("SIM_ECS_SPOT_INTERRUPTION_AFTER=%q: want a positive duration such as 30s", v)
+		}
+		ecsSpotInterruptionAfter = d
+	}
+
+	r.Register("AmazonEC2ContainerServiceV20141113.CreateService", handleECSCreateService)
+	r.Register("AmazonEC2ContainerServiceV20141113.UpdateService", handleECSUpdateService)
+	r.Register("AmazonEC2ContainerServiceV20141113.DescribeServices", handleECSDescribeServices)
+	r.Register("AmazonEC2ContainerServiceV20141113.ListServices", handleECSListServices)
+	r.Register("AmazonEC2ContainerServiceV20141113.DeleteService", handleECSDeleteService)
+	r.Register("AmazonEC2ContainerServiceV20141113.DescribeCapacityProviders", handleECSDescribeCapacityProviders)
+	r.Register("AmazonEC2ContainerServiceV20141113.PutClusterCapacityProviders", handleECSPutClusterCapacityProviders)
+
+	// Spot interruption trigger. Real Fargate reclaims Spot capacity
+	// with a two-minute warning; the sim stops the task straight away
+	// with stopCode SpotInterruption so tests can exercise the
+	// replacement path deterministically. SIM_ECS_SPOT_INTERRUPTION_AFTER
+	// additionally interrupts every FARGATE_SPOT task after it has run
+	// for that long.
+	srv.HandleFunc("POST /sim/v1/ecs/spot-interruptions", handleECSSpotInterruptions)
+}
+
+// ecsResourceName returns the trailing name of an ECS ARN, or ref
+// itself when it is already a bare name.
+func ecsResourceName(ref string) string {
+	if strings.HasPrefix(ref, "arn:") {
+		if i := strings.LastIndex(ref, "/"); i >= 0 {
+			return ref[i+1:]
+		}
+	}
+	return ref
+}
+
+func ecsServiceKey(clusterRef, serviceName string) string {
+	return ecsResourceName(clusterRef) + "/" + serviceName
+}
+
+// ecsServiceByArn resolves arn:aws:ecs:…:service/{cluster}/{name}.
+func ecsServiceByArn(arn string) (ECSService, bool) {
+	_, rest, ok := strings.Cut(arn, ":service/")
+	if !ok {
+		return ECSService{}, false
+	}
+	cluster, name, ok := strings.Cut(rest, "/")
+	if !ok {
+		return ECSService{}, false
+	}
+	return ecsServices.Get(cluster + "/" + name)
+}
+
+// ecsClusterFromRequest resolves the request's cluster (name or ARN,
+// default "default"), writing ClusterNotFoundException when missing.
+func ecsClusterFromRequest(w http.ResponseWriter, ref string) (ECSCluster, bool) {
+	if ref == "" {
+		ref = "default"
+	}
+	cluster, ok := ecsClusters.Get(ecsResourceName(ref))
+	if !ok {
+		sim.AWSErrorf(w, "ClusterNotFoundException", http.StatusBadRequest, "Cluster not found: %s", ref)
+	}
+	return cluster, ok
+}
+
+// resolveECSTaskDefinition looks up a task definition by ARN,
+// family:revision or family (latest ACTIVE revision).
+func resolveECSTaskDefinition(ref string) (ECSTaskDefinition, bool) {
+	key := ecsResourceName(ref)
+	if !strings.Contains(key, ":") {
+		ecsRevisionMu.Lock()
+		rev, exists := ecsRevisions[key]
+		ecsRevisionMu.Unlock()
+		if exists {
+			key = fmt.Sprintf("%s:%d", key, rev)
+		}
+	}
+	return ecsTaskDefinitions.Get(key)
+}
+
+// setClusterCapacityProviders validates and applies a cluster's
+// capacity provider associations and default strategy.
+func setClusterCapacityProviders(w http.ResponseWriter, cluster *ECSCluster, providers []string, def []ECSCapacityProviderStrategyItem) bool {
+	for _, p := range providers {
+		if !slices.Contains(fargateCapacityProviders, p) {
+			sim.AWSErrorf(w, "InvalidParameterException", http.StatusBadRequest,
+				"The specified capacity provider %q is not supported by the simulator; only FARGATE and FARGATE_SPOT are available.", p)
+			return false
+		}
+	}
+	probe := *cluster
+	probe.CapacityProviders = providers
+	if len(def) > 0 && !validCapacityProviderStrategy(w, probe, def) {
+		return false
+	}
+	cluster.CapacityProviders = providers
+	cluster.DefaultCapacityProviderStrategy = def
+	return true
+}
+
+// validCapacityProviderStrategy applies the ECS rules for a strategy:
+// every provider associated with the cluster, weights 0-1000, at most
+// one base, and at least one positive weight.
+func validCapacityProviderStrategy(w http.ResponseWriter, cluster ECSCluster, strategy []ECSCapacityProviderStrategyItem) bool {
+	bases, weighted := 0, false
+	for _, item := range strategy {
+		if !slices.Contains(cluster.CapacityProviders, item.CapacityProvider) {
+			sim.AWSError(w, "InvalidParameterException",
+				"The specified capacity provider strategy cannot contain a capacity provider that is not associated with the cluster. Associate the capacity provider with the cluster or specify a valid capacity provider and try again.",
+				http.StatusBadRequest)
+			return false
+		}
+		if item.Weight < 0 || item.Weight > 1000 || item.Base < 0 || item.Base > 100000 {
+			sim.AWSErrorf(w, "InvalidParameterException", http.StatusBadRequest,
+				"Capacity provider %s has an out-of-range weight or base.", item.CapacityProvider)
+			return false
+		}
+		if item.Base > 0 {
+			bases++
+		}
+		if item.Weight > 0 {
+			weighted = true
+		}
+	}
+	if bases > 1 {
+		sim.AWSError(w, "InvalidParameterException",
+			"Only one capacity provider in a capacity provider strategy can have a base defined.", http.StatusBadRequest)
+		return false
+	}
+	if !weighted {
+		sim.AWSError(w, "InvalidParameterException",
+			"At least one capacity provider in a capacity provider strategy must have a weight greater than 0.", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// resolveCapacityProviderStrategy picks the strategy a RunTask /
+// CreateService uses: the explicit one, else the cluster default when
+// no launch type was given. Specifying both a launch type and a
+// strategy is rejected, as in ECS.
+func resolveCapacityProviderStrategy(w http.ResponseWriter, cluster ECSCluster, launchType string, strategy []ECSCapacityProviderStrategyItem) ([]ECSCapacityProviderStrategyItem, bool) {
+	if launchType != "" && len(strategy) > 0 {
+		sim.AWSError(w, "InvalidParameterException",
+			"Specifying both a launch type and capacity provider strategy is not supported. Remove one and try again.",
+			http.StatusBadRequest)
+		return nil, false
+	}
+	if launchType != "" {
+		return nil, true
+	}
+	if len(strategy) == 0 {
+		strategy = cluster.DefaultCapacityProviderStrategy
+	}
+	if len(strategy) > 0 && !validCapacityProviderStrategy(w, cluster, strategy) {
+		return nil, false
+	}
+	return strategy, true
+}
+
+// pickCapacityProvider chooses the provider for the next task given
+// how many tasks each provider already holds: unmet bases first, then
+// the provider whose weighted share stays lowest after taking the
+// task, ties going to the heavier weight.
+func pickCapacityProvider(strategy []ECSCapacityProviderStrategyItem, placed map[string]int) string {
+	for _, item := range strategy {
+		if placed[item.CapacityProvider] < item.Base {
+			return item.CapacityProvider
+		}
+	}
+	best, bestLoad, bestWeight := "", 0.0, 0
+	for _, item := range strategy {
+		if item.Weight == 0 {
+			continue
+		}
+		load := float64(placed[item.CapacityProvider]-item.Base+1) / float64(item.Weight)
+		if best == "" || load < bestLoad || (load == bestLoad && item.Weight > bestWeight) {
+			best, bestLoad, bestWeight = item.CapacityProvider, load, item.Weight
+		}
+	}
+	return best
+}
+
+func newECSDeploymentID() string {
+	return fmt.Sprintf("ecs-svc/%019d", rand.Int64N(1e18)+1e18)
+}
+
+func ecsNow() float64 { return float64(time.Now().UnixMilli()) / 1000 }
+
+// newECSDeployment builds the PRIMARY deployment for svc's current
+// settings.
+func newECSDeployment(svc ECSService) ECSDeployment {
+	now := ecsNow()
+	id := newECSDeploymentID()
+	return ECSDeployment{
+		Id:                       id,
+		Status:                   "PRIMARY",
+		TaskDefinition:           svc.TaskDefinition,
+		DesiredCount:             svc.DesiredCount,
+		LaunchType:               svc.LaunchType,
+		CapacityProviderStrategy: svc.CapacityProviderStrategy,
+		NetworkConfiguration:     svc.NetworkConfiguration,
+		RolloutState:             "IN_PROGRESS",
+		RolloutStateReason:       "ECS deployment " + id + " in progress.",
+		CreatedAt:                now,
+		UpdatedAt:                now,
+	}
+}
+
+// addECSServiceEvent prepends an event, trimming the oldest.
+func addECSServiceEvent(svc *ECSService, format string, args ...any) {
+	ev := ECSServiceEvent{Id: generateUUID(), CreatedAt: ecsNow(), Message: fmt.Sprintf(format, args...)}
+	svc.Events = append([]ECSServiceEvent{ev}, svc.Events...)
+	if len(svc.Events) > maxECSServiceEvents {
+		svc.Events = svc.Events[:maxECSServiceEvents]
+	}
+}
+
+func ecsTaskShortID(arn string) string { return ecsResourceName(arn) }
+
+// ecsServiceTasks returns the service's tasks the scheduler still
+// wants running.
+func ecsServiceTasks(svc ECSService) []ECSTask {
+	group := "service:" + svc.ServiceName
+	return ecsTasks.Filter(func(t ECSTask) bool {
+		return t.ClusterArn == svc.ClusterArn && t.Group == group && t.DesiredStatus == "RUNNING"
+	})
+}
+
+// ecsTaskChanged is called whenever a task reaches RUNNING or STOPPED.
+// Service tasks feed the start-failure throttle and trigger a
+// scheduler pass so stopped tasks are replaced.
+func ecsTaskChanged(taskID string) {
+	t, ok := ecsTasks.Get(taskID)
+	if !ok {
+		return
+	}
+	name, ok := strings.CutPrefix(t.Group, "service:")
+	if !ok {
+		return
+	}
+	key := ecsServiceKey(t.ClusterArn, name)
+	if t.LastStatus == "STOPPED" {
+		recordECSServiceTaskStop(key, t)
+	}
+	go reconcileECSService(key)
+}
+
+// recordECSServiceTaskStop logs the stop on the service and updates
+// the throttle: a task that exits before ecsServiceHealthyRuntime
+// doubles the delay before the next replacement (capped at
+// ecsServiceMaxBackoff); one that ran longer resets it.
+func recordECSServiceTaskStop(key string, t ECSTask) {
+	ecsServiceMu.Lock()
+	defer ecsServiceMu.Unlock()
+	if t.StopCode == "ServiceSchedulerInitiated" {
+		return
+	}
+	ecsServices.Update(key, func(svc *ECSService) {
+		if t.StopCode == "SpotInterruption" {
+			addECSServiceEvent(svc, "(service %s) (task %s) was stopped by a Spot interruption.", svc.ServiceName, ecsTaskShortID(t.TaskArn))
+		} else {
+			addECSServiceEvent(svc, "(service %s) has stopped 1 running tasks: (task %s).", svc.ServiceName, ecsTaskShortID(t.TaskArn))
+		}
+		if t.StopCode != "EssentialContainerExited" {
+			return
+		}
+		th := ecsServiceThrottles[key]
+		if th == nil {
+			th = &ecsServiceThrottle{}
+			ecsServiceThrottles[key] = th
+		}
+		ran := time.Duration(0)
+		if t.StartedAt != nil && t.StoppedAt != nil {
+			ran = time.Duration(*t.StoppedAt-*t.StartedAt) * time.Second
+		}
+		if ran >= ecsServiceHealthyRuntime {
+			th.failures = 0
+			th.until = time.Time{}
+			return
+		}
+		th.failures++
+		for j := range svc.Deployments {
+			if svc.Deployments[j].Id == t.StartedBy {
+				svc.Deployments[j].FailedTasks++
+			}
+		}
+		delay := time.Second << min(th.failures-1, 16)
+		th.until = time.Now().Add(min(delay, ecsServiceMaxBackoff))
+		if th.failures > 1 {
+			addECSServiceEvent(svc, "(service %s) is unable to consistently start tasks successfully. For more information, see the Troubleshooting section of the Amazon ECS Developer Guide.", svc.ServiceName)
+		}
+	})
+}
+
+// reconcileECSService runs one scheduler pass for a service: it drains
+// deleted services, scales the primary deployment to DesiredCount,
+// retires older deployments once the primary is fully running, and
+// refreshes the counts and rollout state.
+func reconcileECSService(key string) {
+	ecsServiceMu.Lock()
+	defer ecsServiceMu.Unlock()
+
+	svc, ok := ecsServices.Get(key)
+	if !ok || svc.Status == "INACTIVE" {
+		return
+	}
+	cluster, clusterOK := ecsClusters.Get(ecsResourceName(svc.ClusterArn))
+
+	live := ecsServiceTasks(svc)
+	if svc.Status != "ACTIVE" || !clusterOK {
+		for _, t := range live {
+			stopECSTask(ecsTaskShortID(t.TaskArn), "ServiceSchedulerInitiated", "Service "+svc.ServiceName+" is being deleted.", 0)
+		}
+		svc.Status = "INACTIVE"
+		svc.RunningCount, svc.PendingCount = 0, 0
+		svc.Deployments = []ECSDeployment{}
+		ecsServices.Put(key, svc)
+		delete(ecsServiceThrottles, key)
+		return
+	}
+
+	primary := svc.Deployments[0]
+	var mine []ECSTask
+	for _, t := range live {
+		if t.StartedBy == primary.Id {
+			mine = append(mine, t)
+		}
+	}
+
+	// Scale down: stop the newest surplus tasks.
+	if extra := len(mine) - svc.DesiredCount; extra > 0 {
+		sort.Slice(mine, func(i, j int) bool { return *mine[i].CreatedAt > *mine[j].CreatedAt })
+		var ids []string
+		for _, t := range mine[:extra] {
+			id := ecsTaskShortID(t.TaskArn)
+			stopECSTask(id, "ServiceSchedulerInitiated", "Scaling activity initiated by (deployment "+primary.Id+")", 0)
+			ids = append(ids, "(task "+id+")")
+		}
+		mine = mine[extra:]
+		addECSServiceEvent(&svc, "(service %s) has stopped %d running tasks: %s.", svc.ServiceName, extra, strings.Join(ids, " "))
+	}
+
+	// Scale up, unless the start-failure throttle is holding off.
+	if need := svc.DesiredCount - len(mine); need > 0 {
+		th := ecsServiceThrottles[key]
+		if th != nil && time.Now().Before(th.until) {
+			if th.timer == nil {
+				th.timer = time.AfterFunc(time.Until(th.until), func() {
+					ecsServiceMu.Lock()
+					th.timer = nil
+					ecsServiceMu.Unlock()
+					reconcileECSService(key)
+				})
+			}
+		} else if td, ok := resolveECSTaskDefinition(primary.TaskDefinition); ok {
+			placed := map[string]int{}
+			for _, t := range mine {
+				placed[t.CapacityProviderName]++
+			}
+			var tags []ECSTag
+			switch svc.PropagateTags {
+			case "SERVICE":
+				tags = svc.Tags
+			case "TASK_DEFINITION":
+				tags = td.Tags
+			}
+			var ids []string
+			for i := 0; i < need; i++ {
+				l := ecsTaskLaunch{
+					LaunchType:           primary.LaunchType,
+					Group:                "service:" + svc.ServiceName,
+					StartedBy:            primary.Id,
+					Tags:                 tags,
+					EnableExecuteCommand: svc.EnableExecuteCommand,
+					Network:              primary.NetworkConfiguration,
+				}
+				if len(primary.CapacityProviderStrategy) > 0 {
+					l.CapacityProvider = pickCapacityProvider(primary.CapacityProviderStrategy, placed)
+					l.LaunchType = "FARGATE"
+					placed[l.CapacityProvider]++
+				}
+				t, err := launchECSTask(cluster, td, l)
+				if err != nil {
+					addECSServiceEvent(&svc, "(service %s) was unable to place a task. Reason: %v.", svc.ServiceName, err)
+					break
+				}
+				ids = append(ids, "(task "+ecsTaskShortID(t.TaskArn)+")")
+			}
+			if len(ids) > 0 {
+				addECSServiceEvent(&svc, "(service %s) has started %d tasks: %s.", svc.ServiceName, len(ids), strings.Join(ids, " "))
+			}
+		} else {
+			addECSServiceEvent(&svc, "(service %s) was unable to place a task because task definition %s is not available.", svc.ServiceName, primary.TaskDefinition)
+		}
+	}
+
+	// Retire older deployments once the primary is fully running.
+	primaryRunning := 0
+	for _, t := range ecsServiceTasks(svc) {
+		if t.StartedBy == primary.Id && t.LastStatus == "RUNNING" {
+			primaryRunning++
+		}
+	}
+	if primaryRunning >= svc.DesiredCount && len(svc.Deployments) > 1 {
+		for _, t := range ecsServiceTasks(svc) {
+			if t.StartedBy != primary.Id {
+				stopECSTask(ecsTaskShortID(t.TaskArn), "ServiceSchedulerInitiated", "Task stopped by deployment "+primary.Id+".", 0)
+			}
+		}
+		svc.Deployments = svc.Deployments[:1]
+	}
+
+	// Refresh counts.
+	now := ecsNow()
+	svc.RunningCount, svc.PendingCount = 0, 0
+	for i := range svc.Deployments {
+		d := &svc.Deployments[i]
+		d.RunningCount, d.PendingCount = 0, 0
+		if i == 0 {
+			d.DesiredCount = svc.DesiredCount
+		} else {
+			d.DesiredCount = 0
+		}
+		for _, t := range ecsServiceTasks(svc) {
+			if t.StartedBy != d.Id {
+				continue
+			}
+			if t.LastStatus == "RUNNING" {
+				d.RunningCount++
+			} else {
+				d.PendingCount++
+			}
+		}
+		svc.RunningCount += d.RunningCount
+		svc.PendingCount += d.PendingCount
+		d.UpdatedAt = now
+	}
+	d := &svc.Deployments[0]
+	steady := len(svc.Deployments) == 1 && d.RunningCount == svc.DesiredCount && d.PendingCount == 0
+	if steady && d.RolloutState == "IN_PROGRESS" {
+		d.RolloutState = "COMPLETED"
+		d.RolloutStateReason = "ECS deployment " + d.Id + " completed."
+		addECSServiceEvent(&svc, "(service %s) (deployment %s) deployment completed.", svc.ServiceName, d.Id)
+	}
+	steadyMsg := fmt.Sprintf("(service %s) has reached a steady state.", svc.ServiceName)
+	if steady && (len(svc.Events) == 0 || svc.Events[0].Message != steadyMsg) {
+		addECSServiceEvent(&svc, "%s", steadyMsg)
+	}
+	ecsServices.Put(key, svc)
+}
+
+// interruptSpotTask stops a RUNNING FARGATE_SPOT task the way a Spot
+// reclaim does. Fargate sends SIGTERM, so the container exits 143.
+func interruptSpotTask(taskID string) bool {
+	t, ok := ecsTasks.Get(taskID)
+	if !ok || t.CapacityProviderName != "FARGATE_SPOT" || t.LastStatus != "RUNNING" {
+		return false
+	}
+	stopECSTask(taskID, "SpotInterruption", "Your Spot Task was interrupted.", 143)
+	ecsTaskChanged(taskID)
+	return true
+}
+
+// scheduleSpotInterruption arms the SIM_ECS_SPOT_INTERRUPTION_AFTER
+// timer for a FARGATE_SPOT task that just reached RUNNING.
+func scheduleSpotInterruption(taskID string) {
+	if ecsSpotInterruptionAfter == 0 {
+		return
+	}
+	t, ok := ecsTasks.Get(taskID)
+	if !ok || t.CapacityProviderName != "FARGATE_SPOT" {
+		return
+	}
+	time.AfterFunc(ecsSpotInterruptionAfter, func() { interruptSpotTask(taskID) })
+}
+
+func handleECSSpotInterruptions(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Cluster string   `json:"cluster"`
+		Tasks   []string `json:"tasks"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	cluster, ok := ecsClusters.Get(ecsResourceName(cmpOr(req.Cluster, "default")))
+	if !ok {
+		http.Error(w, "cluster not found: "+req.Cluster, http.StatusNotFound)
+		return
+	}
+
+	var ids []string
+	if len(req.Tasks) == 0 {
+		for _, t := range ecsTasks.Filter(func(t ECSTask) bool {
+			return t.ClusterArn == cluster.ClusterArn && t.CapacityProviderName == "FARGATE_SPOT" && t.LastStatus == "RUNNING"
+		}) {
+			ids = append(ids, ecsTaskShortID(t.TaskArn))
+		}
+	} else {
+		for _, ref := range req.Tasks {
+			id := ecsTaskShortID(ref)
+			t, ok := ecsTasks.Get(id)
+			if !ok || t.ClusterArn != cluster.ClusterArn {
+				http.Error(w, "task not found in cluster: "+ref, http.StatusNotFound)
+				return
+			}
+			if t.CapacityProviderName != "FARGATE_SPOT" || t.LastStatus != "RUNNING" {
+				http.Error(w, fmt.Sprintf("task %s is not a RUNNING FARGATE_SPOT task (capacity provider %q, status %s)",
+					ref, t.CapacityProviderName, t.LastStatus), http.StatusConflict)
+				return
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	interrupted := []string{}
+	for _, id := range ids {
+		if interruptSpotTask(id) {
+			t, _ := ecsTasks.Get(id)
+			interrupted = append(interrupted, t.TaskArn)
+		}
+	}
+	sim.WriteJSON(w, http.StatusOK, map[string]any{"interruptedTasks": interrupted})
+}
+
+func cmpOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// ecsServiceRequest is the shared body of CreateService / UpdateService.
+type ecsServiceRequest struct {
+	Cluster                  string                            `json:"cluster"`
+	ServiceName              string                            `json:"serviceName"`
+	Service                  string                            `json:"service"`
+	TaskDefinition           string                            `json:"taskDefinition"`
+	DesiredCount             *int                              `json:"desiredCount"`
+	LaunchType               string                            `json:"launchType"`
+	CapacityProviderStrategy []ECSCapacityProviderStrategyItem `json:"capacityProviderStrategy"`
+	SchedulingStrategy       string                            `json:"schedulingStrategy"`
+	PropagateTags            string                            `json:"propagateTags"`
+	EnableExecuteCommand     *bool                             `json:"enableExecuteCommand"`
+	ForceNewDeployment       bool                              `json:"forceNewDeployment"`
+	Tags                     []ECSTag                          `json:"tags"`
+	NetworkConfiguration     *struct {
+		AwsvpcConfiguration *ECSTaskVpcConfig `json:"awsvpcConfiguration"`
+	} `json:"networkConfiguration"`
+}
+
+func (req ecsServiceRequest) network() *ECSTaskNetworkConfig {
+	if req.NetworkConfiguration == nil || req.NetworkConfiguration.AwsvpcConfiguration == nil {
+		return nil
+	}
+	return &ECSTaskNetworkConfig{AwsvpcConfiguration: req.NetworkConfiguration.AwsvpcConfiguration}
+}
+
+// validECSServiceNetwork rejects unknown security groups and awsvpc
+// task definitions without a network configuration.
+func validECSServiceNetwork(w http.ResponseWriter, td ECSTaskDefinition, network *ECSTaskNetworkConfig) bool {
+	if network == nil {
+		if td.NetworkMode == "awsvpc" {
+			sim.AWSError(w, "InvalidParameterException",
+				"Network Configuration must be provided when networkMode 'awsvpc' is specified.", http.StatusBadRequest)
+			return false
+		}
+		return true
+	}
+	for _, sgID := range network.AwsvpcConfiguration.SecurityGroups {
+		if _, ok := ec2SecurityGroups.Get(sgID); !ok {
+			sim.AWSErrorf(w, "InvalidParameterException", http.StatusBadRequest,
+				"The security group '%s' does not exist", sgID)
+			return false
+		}
+	}
+	return true
+}
+
+func handleECSCreateService(w http.ResponseWriter, r *http.Request) {
+	var req ecsServiceRequest
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ServiceName == "" || req.TaskDefinition == "" {
+		sim.AWSError(w, "InvalidParameterException", "serviceName and taskDefinition are required", http.StatusBadRequest)
+		return
+	}
+	if req.SchedulingStrategy != "" && req.SchedulingStrategy != "REPLICA" {
+		sim.AWSErrorf(w, "InvalidParameterException", http.StatusBadRequest,
+			"Scheduling strategy %s is not supported by the simulator; only REPLICA services are simulated.", req.SchedulingStrategy)
+		return
+	}
+	cluster, ok := ecsClusterFromRequest(w, req.Cluster)
+	if !ok {
+		return
+	}
+	td, ok := resolveECSTaskDefinition(req.TaskDefinition)
+	if !ok {
+		sim.AWSErrorf(w, "ClientException", http.StatusBadRequest,
+			"Unable to describe task definition: %s", req.TaskDefinition)
+		return
+	}
+	network := req.network()
+	if !validECSServiceNetwork(w, td, network) {
+		return
+	}
+	strategy, ok := resolveCapacityProviderStrategy(w, cluster, req.LaunchType, req.CapacityProviderStrategy)
+	if !ok {
+		return
+	}
+	desired := 0
+	if req.DesiredCount != nil {
+		desired = *req.DesiredCount
+	}
+	if desired < 0 {
+		sim.AWSError(w, "InvalidParameterException", "desiredCount must be 0 or greater", http.StatusBadRequest)
+		return
+	}
+
+	key := ecsServiceKey(cluster.ClusterName, req.ServiceName)
+	ecsServiceMu.Lock()
+	if existing, ok := ecsServices.Get(key); ok && existing.Status != "INACTIVE" {
+		ecsServiceMu.Unlock()
+		sim.AWSError(w, "InvalidParameterException", "Creation of service was not idempotent.", http.StatusBadRequest)
+		return
+	}
+	svc := ECSService{
+		ServiceArn:               ecsArn("service", cluster.ClusterName+"/"+req.ServiceName),
+		ServiceName:              req.ServiceName,
+		ClusterArn:               cluster.ClusterArn,
+		TaskDefinition:           td.TaskDefinitionArn,
+		DesiredCount:             desired,
+		Status:                   "ACTIVE",
+		LaunchType:               req.LaunchType,
+		CapacityProviderStrategy: strategy,
+		NetworkConfiguration:     network,
+		SchedulingStrategy:       "REPLICA",
+		PropagateTags:            req.PropagateTags,
+		EnableExecuteCommand:     req.EnableExecuteCommand != nil && *req.EnableExecuteCommand,
+		Tags:                     req.Tags,
+		CreatedAt:                ecsNow(),
+		Events:                   []ECSServiceEvent{},
+	}
+	svc.Deployments = []ECSDeployment{newECSDeployment(svc)}
+	ecsServices.Put(key, svc)
+	delete(ecsServiceThrottles, key)
+	ecsServiceMu.Unlock()
+
+	reconcileECSService(key)
+	svc, _ = ecsServices.Get(key)
+	sim.WriteJSON(w, http.StatusOK, map[string]any{"service": svc})
+}
+
+func handleECSUpdateService(w http.ResponseWriter, r *http.Request) {
+	var req ecsServiceRequest
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	cluster, ok := ecsClusterFromRequest(w, req.Cluster)
+	if !ok {
+		return
+	}
+	key := ecsServiceKey(cluster.ClusterName, ecsResourceName(req.Service))
+
+	ecsServiceMu.Lock()
+	svc, ok := ecsServices.Get(key)
+	if !ok {
+		ecsServiceMu.Unlock()
+		sim.AWSError(w, "ServiceNotFoundException", "Service not found.", http.StatusBadRequest)
+		return
+	}
+	if svc.Status != "ACTIVE" {
+		ecsServiceMu.Unlock()
+		sim.AWSError(w, "ServiceNotActiveException", "Service was not ACTIVE.", http.StatusBadRequest)
+		return
+	}
+
+	redeploy := req.ForceNewDeployment
+	if req.TaskDefinition != "" {
+		td, ok := resolveECSTaskDefinition(req.TaskDefinition)
+		if !ok {
+			ecsServiceMu.Unlock()
+			sim.AWSErrorf(w, "ClientException", http.StatusBadRequest,
+				"Unable to describe task definition: %s", req.TaskDefinition)
+			return
+		}
+		if td.TaskDefinitionArn != svc.TaskDefinition {
+			svc.TaskDefinition = td.TaskDefinitionArn
+			redeploy = true
+		}
+	}
+	if network := req.network(); network != nil {
+		td, _ := resolveECSTaskDefinition(svc.TaskDefinition)
+		if !validECSServiceNetwork(w, td, network) {
+			ecsServiceMu.Unlock()
+			return
+		}
+		svc.NetworkConfiguration = network
+		redeploy = true
+	}
+	if len(req.CapacityProviderStrategy) > 0 {
+		strategy, ok := resolveCapacityProviderStrategy(w, cluster, "", req.CapacityProviderStrategy)
+		if !ok {
+			ecsServiceMu.Unlock()
+			return
+		}
+		svc.CapacityProviderStrategy = strategy
+		svc.LaunchType = ""
+		redeploy = true
+	}
+	if req.DesiredCount != nil {
+		if *req.DesiredCount < 0 {
+			ecsServiceMu.Unlock()
+			sim.AWSError(w, "InvalidParameterException", "desiredCount must be 0 or greater", http.StatusBadRequest)
+			return
+		}
+		svc.DesiredCount = *req.DesiredCount
+	}
+	if req.EnableExecuteCommand != nil {
+		svc.EnableExecuteCommand = *req.EnableExecuteCommand
+	}
+	if req.PropagateTags != "" {
+		svc.PropagateTags = req.PropagateTags
+	}
+	if redeploy {
+		for i := range svc.Deployments {
+			svc.Deployments[i].Status = "ACTIVE"
+		}
+		svc.Deployments = append([]ECSDeployment{newECSDeployment(svc)}, svc.Deployments...)
+		delete(ecsServiceThrottles, key)
+	}
+	ecsServices.Put(key, svc)
+	ecsServiceMu.Unlock()
+
+	reconcileECSService(key)
+	svc, _ = ecsServices.Get(key)
+	sim.WriteJSON(w, http.StatusOK, map[string]any{"service": svc})
+}
+
+func handleECSDescribeServices(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Cluster  string   `json:"cluster"`
+		Services []string `json:"services"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	cluster, ok := ecsClusterFromRequest(w, req.Cluster)
+	if !ok {
+		return
+	}
+	services := []ECSService{}
+	failures := []map[string]string{}
+	for _, ref := range req.Services {
+		if svc, ok := ecsServices.Get(ecsServiceKey(cluster.ClusterName, ecsResourceName(ref))); ok {
+			services = append(services, svc)
+			continue
+		}
+		failures = append(failures, map[string]string{
+			"arn":    ecsArn("service", cluster.ClusterName+"/"+ecsResourceName(ref)),
+			"reason": "MISSING",
+		})
+	}
+	sim.WriteJSON(w, http.StatusOK, map[string]any{"services": services, "failures": failures})
+}
+
+func handleECSListServices(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Cluster    string `json:"cluster"`
+		LaunchType string `json:"launchType"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	cluster, ok := ecsClusterFromRequest(w, req.Cluster)
+	if !ok {
+		return
+	}
+	arns := []string{}
+	for _, svc := range ecsServices.Filter(func(svc ECSService) bool {
+		return svc.ClusterArn == cluster.ClusterArn && svc.Status != "INACTIVE" &&
+			(req.LaunchType == "" || svc.LaunchType == req.LaunchType)
+	}) {
+		arns = append(arns, svc.ServiceArn)
+	}
+	sort.Strings(arns)
+	sim.WriteJSON(w, http.StatusOK, map[string]any{"serviceArns": arns})
+}
+
+func handleECSDeleteService(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Cluster string `json:"cluster"`
+		Service string `json:"service"`
+		Force   bool   `json:"force"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	cluster, ok := ecsClusterFromRequest(w, req.Cluster)
+	if !ok {
+		return
+	}
+	key := ecsServiceKey(cluster.ClusterName, ecsResourceName(req.Service))
+
+	ecsServiceMu.Lock()
+	svc, ok := ecsServices.Get(key)
+	if !ok || svc.Status == "INACTIVE" {
+		ecsServiceMu.Unlock()
+		sim.AWSError(w, "ServiceNotFoundException", "Service not found.", http.StatusBadRequest)
+		return
+	}
+	if svc.DesiredCount > 0 && !req.Force {
+		ecsServiceMu.Unlock()
+		sim.AWSError(w, "InvalidParameterException",
+			"The service cannot be stopped while it is scaled above 0.", http.StatusBadRequest)
+		return
+	}
+	svc.Status = "DRAINING"
+	svc.DesiredCount = 0
+	ecsServices.Put(key, svc)
+	ecsServiceMu.Unlock()
+
+	// Respond with the DRAINING view; the scheduler pass that follows
+	// stops the remaining tasks and marks the service INACTIVE.
+	sim.WriteJSON(w, http.StatusOK, map[string]any{"service": svc})
+	go reconcileECSService(key)
+}
+
+func handleECSDescribeCapacityProviders(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CapacityProviders []string `json:"capacityProviders"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	names := req.CapacityProviders
+	if len(names) == 0 {
+		names = fargateCapacityProviders
+	}
+	providers := []map[string]any{}
+	failures := []map[string]string{}
+	for _, ref := range names {
+		name := ecsResourceName(ref)
+		if !slices.Contains(fargateCapacityProviders, name) {
+			failures = append(failures, map[string]string{"arn": ecsArn("capacity-provider", name), "reason": "MISSING"})
+			continue
+		}
+		providers = append(providers, map[string]any{
+			"capacityProviderArn": ecsArn("capacity-provider", name),
+			"name":                name,
+			"status":              "ACTIVE",
+			"tags":                []ECSTag{},
+		})
+	}
+	sim.WriteJSON(w, http.StatusOK, map[string]any{"capacityProviders": providers, "failures": failures})
+}
+
+func handleECSPutClusterCapacityProviders(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Cluster                         string                            `json:"cluster"`
+		CapacityProviders               []string                          `json:"capacityProviders"`
+		DefaultCapacityProviderStrategy []ECSCapacityProviderStrategyItem `json:"defaultCapacityProviderStrategy"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AWSError(w, "InvalidParameterException", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	cluster, ok := ecsClusterFromRequest(w, req.Cluster)
+	if !ok {
+		return
+	}
+	if !setClusterCapacityProviders(w, &cluster, req.CapacityProviders, req.DefaultCapacityProviderStrategy) {
+		return
+	}
+	ecsClusters.Put(cluster.ClusterName, cluster)
+	sim.WriteJSON(w, http.StatusOK, map[string]any{"cluster": cluster})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// iamPolicyDocument is the subset of the IAM policy grammar the
+// simulator evaluates: Allow / Deny statements over Action / NotAction
+// and Resource / NotResource glob lists. Conditions are not evaluated.
+type iamPolicyDocument struct {
+	Statement iamStatements `json:"Statement"`
+}
+
+type iamStatement struct {
+	Effect      string       `json:"Effect"`
+	Action      iamStringSet `json:"Action"`
+	NotAction   iamStringSet `json:"NotAction"`
+	Resource    iamStringSet `json:"Resource"`
+	NotResource iamStringSet `json:"NotResource"`
+}
+
+// iamStatements accepts a single statement object or an array.
+type iamStatements []iamStatement
+
+func (s *iamStatements) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '{' {
+		var one iamStatement
+		if err := json.Unmarshal(b, &one); err != nil {
+			return err
+		}
+		*s = iamStatements{one}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]iamStatement)(s))
+}
+
+// iamStringSet accepts a single string or an array of strings.
+type iamStringSet []string
+
+func (s *iamStringSet) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var one string
+		if err := json.Unmarshal(b, &one); err != nil {
+			return err
+		}
+		*s = iamStringSet{one}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(s))
+}
+
+// iamGlobMatch matches IAM wildcards (* and ?). Action names compare
+// case-insensitively, resources case-sensitively.
+func iamGlobMatch(pattern, value string, foldCase bool) bool {
+	if foldCase {
+		pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+	}
+	// path.Match treats '/' as a separator; IAM wildcards span it.
+	pattern = strings.ReplaceAll(pattern, "/", "\x00")
+	value = strings.ReplaceAll(value, "/", "\x00")
+	pattern = strings.NewReplacer("[", "\\[", "]", "\\]").Replace(pattern)
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+func iamAnyMatch(patterns []string, value string, foldCase bool) bool {
+	for _, p := range patterns {
+		if iamGlobMatch(p, value, foldCase) {
+			return true
+		}
+	}
+	return false
+}
+
+func (st iamStatement) applies(action, resource string) bool {
+	if len(st.Action) > 0 && !iamAnyMatch(st.Action, action, true) {
+		return false
+	}
+	if len(st.NotAction) > 0 && iamAnyMatch(st.NotAction, action, true) {
+		return false
+	}
+	if len(st.Resource) > 0 && !iamAnyMatch(st.Resource, resource, false) {
+		return false
+	}
+	if len(st.NotResource) > 0 && iamAnyMatch(st.NotResource, resource, false) {
+		return false
+	}
+	return true
+}
+
+// iamEvaluate returns the SimulatePrincipalPolicy decision for action on
+// resource: an explicit Deny wins, then any Allow, else implicitDeny.
+func iamEvaluate(docs []iamPolicyDocument, action, resource string) string {
+	allowed := false
+	for _, doc := range docs {
+		for _, st := range doc.Statement {
+			if !st.applies(action, resource) {
+				continue
+			}
+			switch st.Effect {
+			case "Deny":
+				return "explicitDeny"
+			case "Allow":
+				allowed = true
+			}
+		}
+	}
+	if allowed {
+		return "allowed"
+	}
+	return "implicitDeny"
+}
+
+// handleIAMSimulatePrincipalPolicy evaluates ActionNames x ResourceArns
+// against the principal's policies. The simulator's own caller identity
+// (see sts.go) is unrestricted, matching the simulator's lack of IAM
+// enforcement; roles are evaluated from their inline policies plus any
+// PolicyInputList documents.
+func handleIAMSimulatePrincipalPolicy(w http.ResponseWriter, r *http.Request) {
+	source := r.FormValue("PolicySourceArn")
+	actions := iamReadList(r, "ActionNames")
+	resources := iamReadList(r, "ResourceArns")
+	if len(resources) == 0 {
+		resources = []string{"*"}
+	}
+	if source == "" || len(actions) == 0 {
+		iamErrorXML(w, "InvalidInput", "PolicySourceArn and ActionNames are required.", http.StatusBadRequest)
+		return
+	}
+
+	var docs []iamPolicyDocument
+	unrestricted := source == fmt.Sprintf("arn:aws:iam::%s:user/simulator", awsAccountID())
+	if !unrestricted {
+		roles := iamRoles.Filter(func(ro IAMRole) bool { return ro.Arn == source })
+		if len(roles) == 0 {
+			iamErrorXML(w, "NoSuchEntity", fmt.Sprintf("The principal %s cannot be found.", source), http.StatusNotFound)
+			return
+		}
+		roleName := roles[0].RoleName
+		policies := iamRolePolicies.Filter(func(p IAMRolePolicy) bool { return p.RoleName == roleName })
+		raw := make([]string, 0, len(policies))
+		for _, p := range policies {
+			raw = append(raw, p.PolicyDocument)
+		}
+		raw = append(raw, iamReadList(r, "PolicyInputList")...)
+		for _, d := range raw {
+			var doc iamPolicyDocument
+			if err := json.Unmarshal([]byte(d), &doc); err != nil {
+				iamErrorXML(w, "MalformedPolicyDocument", fmt.Sprintf("Policy document is not valid JSON: %v", err), http.StatusBadRequest)
+				return
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	var members strings.Builder
+	for _, action := range actions {
+		for _, resource := range resources {
+			decision := "allowed"
+			if !unrestricted {
+				decision = iamEvaluate(docs, action, resource)
+			}
+			fmt.Fprintf(&members, "<member><EvalActionName>%s</EvalActionName><EvalResourceName>%s</EvalResourceName><EvalDecision>%s</EvalDecision></member>",
+				html.EscapeString(action), html.EscapeString(resource), decision)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<SimulatePrincipalPolicyResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/">
+  <SimulatePrincipalPolicyResult>
+    <EvaluationResults>%s</EvaluationResults>
+    <IsTruncated>false</IsTruncated>
+  </SimulatePrincipalPolicyResult>
+  <ResponseMetadata><RequestId>%s</RequestId></ResponseMetadata>
+</SimulatePrincipalPolicyResponse>`, members.String(), generateUUID())
+}
@@ -59,6 +59,7 @@ func main() {
 	awsRouter := sim.NewAWSRouter()
 	registerECS(awsRouter, srv)
 	registerECR(awsRouter, srv)
+	registerECRScanning(awsRouter, srv)
 	registerCloudWatchLogs(awsRouter, srv)
 	registerCloudMap(awsRouter, srv)
 	registerSecretsManager(awsRouter, srv)
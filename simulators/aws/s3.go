@@ -3,10 +3,12 @@ package main
 import (
 	"bytes"
 	"crypto/md5"
+	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -28,6 +30,13 @@ type S3Object struct {
 	LastModified time.Time
 	Size         int64
 	Metadata     map[string]string
+	// DataPath holds the payload on disk instead of Data for objects
+	// assembled by CompleteMultipartUpload, which may be many GB.
+	DataPath          string
+	ChecksumAlgorithm string
+	Checksum          string
+	ChecksumType      string // FULL_OBJECT or COMPOSITE
+	PartsCount        int
 }
 
 // XML response types for S3
@@ -96,6 +105,9 @@ func registerS3(srv *sim.Server) {
 	mux.HandleFunc("GET /s3/{bucket}/{key...}", handleS3GetObject)
 	mux.HandleFunc("HEAD /s3/{bucket}/{key...}", handleS3HeadObject)
 	mux.HandleFunc("DELETE /s3/{bucket}/{key...}", handleS3DeleteObject)
+	mux.HandleFunc("POST /s3/{bucket}/{key...}", handleS3PostObject)
+
+	registerS3Multipart(srv)
 }
 
 func handleS3ListBuckets(w http.ResponseWriter, r *http.Request) {
@@ -197,6 +209,9 @@ func handleS3GetBucket(w http.ResponseWriter, r *http.Request) {
 	// `r.URL.Query().Has(...)` is the right check, not value-based.
 	q := r.URL.Query()
 	switch {
+	case q.Has("uploads"):
+		handleS3ListMultipartUploads(w, r, bucket)
+		return
 	case q.Has("policy"):
 		// No policy set on any sim bucket today; real S3 returns 404 + NoSuchBucketPolicy.
 		sim.S3ErrorXML(w, "NoSuchBucketPolicy", "The bucket policy does not exist",
@@ -339,6 +354,10 @@ func handleS3GetBucket(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleS3PutObject(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("uploadId") {
+		handleS3UploadPart(w, r)
+		return
+	}
 	bucket := sim.PathParam(r, "bucket")
 	key := sim.PathParam(r, "key")
 
@@ -348,32 +367,37 @@ func handleS3PutObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	checksum, code, msg := requestS3Checksum(r)
+	if code != "" {
+		sim.S3ErrorXML(w, code, msg, key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+	payload, trailers := s3PayloadReader(r)
+
 	defer r.Body.Close()
-	body, err := io.ReadAll(r.Body)
+	md5h := md5.New()
+	body, err := io.ReadAll(io.TeeReader(payload, io.MultiWriter(md5h, checksum)))
 	if err != nil {
 		sim.S3ErrorXML(w, "InternalError", "Failed to read request body",
 			key, sim.RequestID(r.Context()), http.StatusInternalServerError)
 		return
 	}
+	if code, msg := verifyS3ContentMD5(r, md5h.Sum(nil)); code != "" {
+		sim.S3ErrorXML(w, code, msg, key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+	if code, msg := checksum.verify(trailers); code != "" {
+		sim.S3ErrorXML(w, code, msg, key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
 
-	hash := md5.Sum(body)
-	etag := fmt.Sprintf("\"%x\"", hash)
+	etag := fmt.Sprintf("\"%x\"", md5h.Sum(nil))
 
 	contentType := r.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	// Collect user metadata from x-amz-meta-* headers
-	metadata := make(map[string]string)
-	for k, v := range r.Header {
-		lower := strings.ToLower(k)
-		if strings.HasPrefix(lower, "x-amz-meta-") && len(v) > 0 {
-			metaKey := strings.TrimPrefix(lower, "x-amz-meta-")
-			metadata[metaKey] = v[0]
-		}
-	}
-
 	obj := S3Object{
 		Key:          s3ObjectKey(bucket, key),
 		Data:         body,
@@ -381,19 +405,68 @@ func handleS3PutObject(w http.ResponseWriter, r *http.Request) {
 		ETag:         etag,
 		LastModified: time.Now(),
 		Size:         int64(len(body)),
-		Metadata:     metadata,
+		Metadata:     s3UserMetadata(r),
 	}
-	storeKey := s3ObjectKey(bucket, key)
-	s3Objects.Put(storeKey, obj)
+	if checksum != nil {
+		obj.ChecksumAlgorithm = checksum.alg
+		obj.Checksum = checksum.sum()
+		obj.ChecksumType = "FULL_OBJECT"
+		w.Header().Set(s3ChecksumHeader(checksum.alg), obj.Checksum)
+	}
+	putS3Object(obj)
 
 	w.Header().Set("ETag", etag)
 	w.WriteHeader(http.StatusOK)
 }
 
+// s3UserMetadata collects user metadata from x-amz-meta-* headers.
+func s3UserMetadata(r *http.Request) map[string]string {
+	metadata := make(map[string]string)
+	for k, v := range r.Header {
+		lower := strings.ToLower(k)
+		if strings.HasPrefix(lower, "x-amz-meta-") && len(v) > 0 {
+			metaKey := strings.TrimPrefix(lower, "x-amz-meta-")
+			metadata[metaKey] = v[0]
+		}
+	}
+	return metadata
+}
+
+// verifyS3ContentMD5 checks the optional Content-MD5 header against the
+// received payload's digest.
+func verifyS3ContentMD5(r *http.Request, sum []byte) (string, string) {
+	v := r.Header.Get("Content-MD5")
+	if v == "" {
+		return "", ""
+	}
+	want, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(want) != md5.Size {
+		return "InvalidDigest", "The Content-MD5 you specified was invalid."
+	}
+	if !bytes.Equal(want, sum) {
+		return "BadDigest", "The Content-MD5 you specified did not match what we received."
+	}
+	return "", ""
+}
+
+// putS3Object stores obj, removing the on-disk payload of the object it
+// replaces.
+func putS3Object(obj S3Object) {
+	if old, ok := s3Objects.Get(obj.Key); ok && old.DataPath != "" && old.DataPath != obj.DataPath {
+		_ = os.Remove(old.DataPath)
+	}
+	s3Objects.Put(obj.Key, obj)
+}
+
 func handleS3GetObject(w http.ResponseWriter, r *http.Request) {
 	bucket := sim.PathParam(r, "bucket")
 	key := sim.PathParam(r, "key")
 
+	if r.URL.Query().Has("uploadId") {
+		handleS3ListParts(w, r)
+		return
+	}
+
 	storeKey := s3ObjectKey(bucket, key)
 	obj, ok := s3Objects.Get(storeKey)
 	if !ok {
@@ -405,12 +478,25 @@ func handleS3GetObject(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", obj.ContentType)
 	w.Header().Set("ETag", obj.ETag)
 	w.Header().Set("Last-Modified", obj.LastModified.UTC().Format(http.TimeFormat))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.Size))
+	writeS3ChecksumHeaders(w, r, obj)
 
 	for k, v := range obj.Metadata {
 		w.Header().Set("x-amz-meta-"+k, v)
 	}
 
+	// ServeContent sets Content-Length (and honours Range, which the
+	// SDK download managers use to fetch large objects in parts).
+	if obj.DataPath != "" {
+		f, err := os.Open(obj.DataPath)
+		if err != nil {
+			sim.S3ErrorXML(w, "InternalError", fmt.Sprintf("read object data: %v", err),
+				key, sim.RequestID(r.Context()), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		http.ServeContent(w, r, key, obj.LastModified, f)
+		return
+	}
 	http.ServeContent(w, r, key, obj.LastModified, bytes.NewReader(obj.Data))
 }
 
@@ -429,6 +515,7 @@ func handleS3HeadObject(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("ETag", obj.ETag)
 	w.Header().Set("Last-Modified", obj.LastModified.UTC().Format(http.TimeFormat))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.Size))
+	writeS3ChecksumHeaders(w, r, obj)
 
 	for k, v := range obj.Metadata {
 		w.Header().Set("x-amz-meta-"+k, v)
@@ -441,7 +528,15 @@ func handleS3DeleteObject(w http.ResponseWriter, r *http.Request) {
 	bucket := sim.PathParam(r, "bucket")
 	key := sim.PathParam(r, "key")
 
+	if r.URL.Query().Has("uploadId") {
+		handleS3AbortMultipartUpload(w, r)
+		return
+	}
+
 	storeKey := s3ObjectKey(bucket, key)
+	if obj, ok := s3Objects.Get(storeKey); ok && obj.DataPath != "" {
+		_ = os.Remove(obj.DataPath)
+	}
 	s3Objects.Delete(storeKey)
 
 	// S3 returns 204 even if the object didn't exist
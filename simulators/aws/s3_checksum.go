@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// S3 flexible checksums. SDKs send one of these per PutObject /
+// UploadPart, either as an x-amz-checksum-<alg> header or — for
+// streamed bodies — as a trailer after an aws-chunked payload. The
+// value is the base64 of the big-endian digest.
+
+var s3ChecksumAlgorithms = []string{"CRC32", "CRC32C", "CRC64NVME", "SHA1", "SHA256"}
+
+var crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+func newS3ChecksumHash(alg string) hash.Hash {
+	switch alg {
+	case "CRC32":
+		return crc32.NewIEEE()
+	case "CRC32C":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "CRC64NVME":
+		return crc64.New(crc64NVMETable)
+	case "SHA1":
+		return sha1.New()
+	case "SHA256":
+		return sha256.New()
+	}
+	return nil
+}
+
+// s3ChecksumHeader is the header / trailer / XML field suffix for an
+// algorithm: x-amz-checksum-crc32, <ChecksumCRC32>, etc.
+func s3ChecksumHeader(alg string) string {
+	return "x-amz-checksum-" + strings.ToLower(alg)
+}
+
+// s3Checksum accumulates the request's declared checksum algorithm
+// while the payload streams through.
+type s3Checksum struct {
+	alg      string
+	expected string // from the header; trailer values arrive after the body
+	h        hash.Hash
+}
+
+func (c *s3Checksum) Write(p []byte) (int, error) {
+	if c == nil {
+		return len(p), nil
+	}
+	return c.h.Write(p)
+}
+
+func (c *s3Checksum) sum() string {
+	return base64.StdEncoding.EncodeToString(c.h.Sum(nil))
+}
+
+// requestS3Checksum resolves the checksum algorithm a request declares,
+// if any. Returns an S3 error code + message when it names one the sim
+// doesn't know.
+func requestS3Checksum(r *http.Request) (*s3Checksum, string, string) {
+	alg := strings.ToUpper(r.Header.Get("x-amz-sdk-checksum-algorithm"))
+	var expected string
+	for _, a := range s3ChecksumAlgorithms {
+		if v := r.Header.Get(s3ChecksumHeader(a)); v != "" {
+			alg, expected = a, v
+			break
+		}
+	}
+	if trailer := strings.ToLower(r.Header.Get("x-amz-trailer")); strings.HasPrefix(trailer, "x-amz-checksum-") {
+		alg = strings.ToUpper(strings.TrimPrefix(trailer, "x-amz-checksum-"))
+	}
+	if alg == "" {
+		return nil, "", ""
+	}
+	h := newS3ChecksumHash(alg)
+	if h == nil {
+		return nil, "InvalidRequest", fmt.Sprintf("Value for x-amz-checksum-algorithm header is invalid: %s", alg)
+	}
+	return &s3Checksum{alg: alg, expected: expected, h: h}, "", ""
+}
+
+// verify compares the computed digest with the header or trailer value.
+func (c *s3Checksum) verify(trailers textproto.MIMEHeader) (string, string) {
+	if c == nil {
+		return "", ""
+	}
+	expected := c.expected
+	if v := trailers.Get(s3ChecksumHeader(c.alg)); v != "" {
+		expected = v
+	}
+	if expected != "" && expected != c.sum() {
+		return "BadDigest", fmt.Sprintf("The %s you specified did not match the calculated checksum.", c.alg)
+	}
+	return "", ""
+}
+
+// s3PayloadReader returns the decoded request payload. aws-chunked
+// bodies (Content-Encoding: aws-chunked, or a STREAMING-* content
+// sha256) are unframed; the returned trailers are filled once the
+// reader hits EOF.
+func s3PayloadReader(r *http.Request) (io.Reader, textproto.MIMEHeader) {
+	trailers := textproto.MIMEHeader{}
+	chunked := strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") ||
+		strings.HasPrefix(r.Header.Get("x-amz-content-sha256"), "STREAMING-")
+	if !chunked {
+		return r.Body, trailers
+	}
+	return &awsChunkedReader{br: bufio.NewReader(r.Body), trailers: trailers}, trailers
+}
+
+// awsChunkedReader decodes the aws-chunked framing:
+//
+//	<hex-size>[;chunk-signature=<sig>]\r\n<data>\r\n ... 0[;...]\r\n<trailers>\r\n
+type awsChunkedReader struct {
+	br        *bufio.Reader
+	remaining int64
+	done      bool
+	trailers  textproto.MIMEHeader
+}
+
+func (a *awsChunkedReader) Read(p []byte) (int, error) {
+	for a.remaining == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+		if err := a.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > a.remaining {
+		p = p[:a.remaining]
+	}
+	n, err := a.br.Read(p)
+	a.remaining -= int64(n)
+	if a.remaining == 0 && err == nil {
+		if _, err := a.br.Discard(2); err != nil {
+			return n, fmt.Errorf("aws-chunked: missing chunk terminator: %w", err)
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (a *awsChunkedReader) nextChunk() error {
+	line, err := a.br.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("aws-chunked: read chunk header: %w", err)
+	}
+	sizeField, _, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("aws-chunked: bad chunk size %q", sizeField)
+	}
+	if size > 0 {
+		a.remaining = size
+		return nil
+	}
+	a.done = true
+	trailers, err := textproto.NewReader(a.br).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("aws-chunked: read trailers: %w", err)
+	}
+	for k, v := range trailers {
+		a.trailers[k] = v
+	}
+	return nil
+}
+
+// s3CompositeChecksum is the multipart "checksum of checksums": the
+// algorithm applied to the concatenated raw part digests, suffixed
+// with the part count.
+func s3CompositeChecksum(alg string, partSums []string) (string, error) {
+	h := newS3ChecksumHash(alg)
+	for _, s := range partSums {
+		raw, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		h.Write(raw)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(partSums)), nil
+}
+
+// writeS3ChecksumHeaders echoes a stored checksum when the caller opted
+// in with x-amz-checksum-mode: ENABLED, as S3 does on Get/HeadObject.
+func writeS3ChecksumHeaders(w http.ResponseWriter, r *http.Request, obj S3Object) {
+	if obj.ChecksumAlgorithm == "" || !strings.EqualFold(r.Header.Get("x-amz-checksum-mode"), "ENABLED") {
+		return
+	}
+	w.Header().Set(s3ChecksumHeader(obj.ChecksumAlgorithm), obj.Checksum)
+	w.Header().Set("x-amz-checksum-type", obj.ChecksumType)
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// S3 multipart upload: CreateMultipartUpload / UploadPart /
+// CompleteMultipartUpload / AbortMultipartUpload / ListParts /
+// ListMultipartUploads. Parts and assembled objects live on disk under
+// s3DataRoot() so multi-GB uploads don't sit in memory or in SQLite.
+
+const (
+	s3MinPartSize = 5 << 20 // every part but the last
+	s3MaxParts    = 10000
+)
+
+// S3MultipartUpload is an in-progress multipart upload.
+type S3MultipartUpload struct {
+	UploadID          string
+	Bucket            string
+	Key               string
+	Initiated         time.Time
+	ContentType       string
+	Metadata          map[string]string
+	ChecksumAlgorithm string
+	ChecksumType      string
+	Parts             map[int]S3Part
+}
+
+// S3Part is an uploaded part; the payload is at s3PartPath.
+type S3Part struct {
+	PartNumber   int
+	ETag         string
+	Size         int64
+	LastModified time.Time
+	Checksum     string
+}
+
+var s3Uploads sim.Store[S3MultipartUpload]
+
+// s3DataRoot is the on-disk directory for multipart parts and the
+// objects assembled from them.
+func s3DataRoot() string {
+	if dir := os.Getenv("SIM_S3_DATA_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "sockerless-sim-s3")
+}
+
+func s3PartPath(uploadID string, partNumber int) string {
+	return filepath.Join(s3DataRoot(), "uploads", uploadID, strconv.Itoa(partNumber))
+}
+
+func registerS3Multipart(srv *sim.Server) {
+	s3Uploads = sim.MakeStore[S3MultipartUpload](srv.DB(), "s3_multipart_uploads")
+	for _, dir := range []string{"uploads", "objects"} {
+		if err := os.MkdirAll(filepath.Join(s3DataRoot(), dir), 0o755); err != nil {
+			log.Fatalf("S3 data dir: %v", err)
+		}
+	}
+}
+
+// XML shapes
+
+// s3ChecksumFields are the per-algorithm checksum elements shared by the
+// multipart request and response bodies.
+type s3ChecksumFields struct {
+	ChecksumCRC32     string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C    string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumCRC64NVME string `xml:"ChecksumCRC64NVME,omitempty"`
+	ChecksumSHA1      string `xml:"ChecksumSHA1,omitempty"`
+	ChecksumSHA256    string `xml:"ChecksumSHA256,omitempty"`
+}
+
+func (f *s3ChecksumFields) set(alg, value string) {
+	switch alg {
+	case "CRC32":
+		f.ChecksumCRC32 = value
+	case "CRC32C":
+		f.ChecksumCRC32C = value
+	case "CRC64NVME":
+		f.ChecksumCRC64NVME = value
+	case "SHA1":
+		f.ChecksumSHA1 = value
+	case "SHA256":
+		f.ChecksumSHA256 = value
+	}
+}
+
+func (f s3ChecksumFields) get(alg string) string {
+	switch alg {
+	case "CRC32":
+		return f.ChecksumCRC32
+	case "CRC32C":
+		return f.ChecksumCRC32C
+	case "CRC64NVME":
+		return f.ChecksumCRC64NVME
+	case "SHA1":
+		return f.ChecksumSHA1
+	case "SHA256":
+		return f.ChecksumSHA256
+	}
+	return ""
+}
+
+type s3InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type s3CompleteMultipartUpload struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+		s3ChecksumFields
+	} `xml:"Part"`
+}
+
+type s3CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+	s3ChecksumFields
+	ChecksumType string `xml:"ChecksumType,omitempty"`
+}
+
+type s3PartInfo struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	s3ChecksumFields
+}
+
+type s3ListPartsResult struct {
+	XMLName              xml.Name     `xml:"ListPartsResult"`
+	Xmlns                string       `xml:"xmlns,attr"`
+	Bucket               string       `xml:"Bucket"`
+	Key                  string       `xml:"Key"`
+	UploadID             string       `xml:"UploadId"`
+	Initiator            s3Owner      `xml:"Initiator"`
+	Owner                s3Owner      `xml:"Owner"`
+	StorageClass         string       `xml:"StorageClass"`
+	PartNumberMarker     int          `xml:"PartNumberMarker"`
+	NextPartNumberMarker int          `xml:"NextPartNumberMarker"`
+	MaxParts             int          `xml:"MaxParts"`
+	IsTruncated          bool         `xml:"IsTruncated"`
+	ChecksumAlgorithm    string       `xml:"ChecksumAlgorithm,omitempty"`
+	ChecksumType         string       `xml:"ChecksumType,omitempty"`
+	Parts                []s3PartInfo `xml:"Part"`
+}
+
+type s3UploadInfo struct {
+	Key               string  `xml:"Key"`
+	UploadID          string  `xml:"UploadId"`
+	Initiator         s3Owner `xml:"Initiator"`
+	Owner             s3Owner `xml:"Owner"`
+	StorageClass      string  `xml:"StorageClass"`
+	Initiated         string  `xml:"Initiated"`
+	ChecksumAlgorithm string  `xml:"ChecksumAlgorithm,omitempty"`
+	ChecksumType      string  `xml:"ChecksumType,omitempty"`
+}
+
+type s3ListMultipartUploadsResult struct {
+	XMLName            xml.Name       `xml:"ListMultipartUploadsResult"`
+	Xmlns              string         `xml:"xmlns,attr"`
+	Bucket             string         `xml:"Bucket"`
+	KeyMarker          string         `xml:"KeyMarker"`
+	UploadIDMarker     string         `xml:"UploadIdMarker"`
+	NextKeyMarker      string         `xml:"NextKeyMarker,omitempty"`
+	NextUploadIDMarker string         `xml:"NextUploadIdMarker,omitempty"`
+	Prefix             string         `xml:"Prefix,omitempty"`
+	MaxUploads         int            `xml:"MaxUploads"`
+	IsTruncated        bool           `xml:"IsTruncated"`
+	Uploads            []s3UploadInfo `xml:"Upload"`
+}
+
+const s3Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+func s3SimOwner() s3Owner {
+	return s3Owner{ID: awsAccountID(), DisplayName: "simulator"}
+}
+
+// handleS3PostObject dispatches POST /{bucket}/{key}: ?uploads starts a
+// multipart upload, ?uploadId=… completes one.
+func handleS3PostObject(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	switch {
+	case q.Has("uploads"):
+		handleS3CreateMultipartUpload(w, r)
+	case q.Has("uploadId"):
+		handleS3CompleteMultipartUpload(w, r)
+	default:
+		sim.S3ErrorXML(w, "MethodNotAllowed", "The specified method is not allowed against this resource.",
+			sim.PathParam(r, "key"), sim.RequestID(r.Context()), http.StatusMethodNotAllowed)
+	}
+}
+
+func handleS3CreateMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	bucket := sim.PathParam(r, "bucket")
+	key := sim.PathParam(r, "key")
+	if _, ok := s3Buckets_.Get(bucket); !ok {
+		sim.S3ErrorXML(w, "NoSuchBucket", "The specified bucket does not exist",
+			bucket, sim.RequestID(r.Context()), http.StatusNotFound)
+		return
+	}
+
+	alg := strings.ToUpper(r.Header.Get("x-amz-checksum-algorithm"))
+	checksumType := strings.ToUpper(r.Header.Get("x-amz-checksum-type"))
+	if alg != "" {
+		if newS3ChecksumHash(alg) == nil {
+			sim.S3ErrorXML(w, "InvalidRequest", fmt.Sprintf("Value for x-amz-checksum-algorithm header is invalid: %s", alg),
+				key, sim.RequestID(r.Context()), http.StatusBadRequest)
+			return
+		}
+		if checksumType == "" {
+			checksumType = "COMPOSITE"
+			if alg == "CRC64NVME" {
+				checksumType = "FULL_OBJECT"
+			}
+		}
+		fullObjectOnly := alg == "CRC64NVME"
+		crc := strings.HasPrefix(alg, "CRC")
+		if (checksumType == "FULL_OBJECT" && !crc) || (checksumType == "COMPOSITE" && fullObjectOnly) ||
+			(checksumType != "FULL_OBJECT" && checksumType != "COMPOSITE") {
+			sim.S3ErrorXML(w, "InvalidRequest", fmt.Sprintf("The %s checksum type cannot be used with the %s checksum algorithm.",
+				checksumType, strings.ToLower(alg)), key, sim.RequestID(r.Context()), http.StatusBadRequest)
+			return
+		}
+	} else if checksumType != "" {
+		sim.S3ErrorXML(w, "InvalidRequest", "The x-amz-checksum-type header can only be used with the x-amz-checksum-algorithm header.",
+			key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	upload := S3MultipartUpload{
+		UploadID:          strings.ReplaceAll(generateUUID(), "-", ""),
+		Bucket:            bucket,
+		Key:               key,
+		Initiated:         time.Now().UTC(),
+		ContentType:       contentType,
+		Metadata:          s3UserMetadata(r),
+		ChecksumAlgorithm: alg,
+		ChecksumType:      checksumType,
+		Parts:             map[int]S3Part{},
+	}
+	if err := os.MkdirAll(filepath.Dir(s3PartPath(upload.UploadID, 1)), 0o755); err != nil {
+		sim.S3ErrorXML(w, "InternalError", fmt.Sprintf("create upload dir: %v", err),
+			key, sim.RequestID(r.Context()), http.StatusInternalServerError)
+		return
+	}
+	s3Uploads.Put(upload.UploadID, upload)
+
+	if alg != "" {
+		w.Header().Set("x-amz-checksum-algorithm", alg)
+		w.Header().Set("x-amz-checksum-type", checksumType)
+	}
+	sim.WriteXML(w, http.StatusOK, s3InitiateMultipartUploadResult{
+		Xmlns:    s3Xmlns,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: upload.UploadID,
+	})
+}
+
+// s3UploadFromRequest resolves ?uploadId against the bucket/key in the
+// path, writing NoSuchUpload when it doesn't match a live upload.
+func s3UploadFromRequest(w http.ResponseWriter, r *http.Request) (S3MultipartUpload, bool) {
+	bucket := sim.PathParam(r, "bucket")
+	key := sim.PathParam(r, "key")
+	id := r.URL.Query().Get("uploadId")
+	upload, ok := s3Uploads.Get(id)
+	if !ok || upload.Bucket != bucket || upload.Key != key {
+		sim.S3ErrorXML(w, "NoSuchUpload",
+			"The specified upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.",
+			id, sim.RequestID(r.Context()), http.StatusNotFound)
+		return S3MultipartUpload{}, false
+	}
+	return upload, true
+}
+
+func handleS3UploadPart(w http.ResponseWriter, r *http.Request) {
+	key := sim.PathParam(r, "key")
+	upload, ok := s3UploadFromRequest(w, r)
+	if !ok {
+		return
+	}
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > s3MaxParts {
+		sim.S3ErrorXML(w, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive",
+			key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+
+	checksum, code, msg := requestS3Checksum(r)
+	if code != "" {
+		sim.S3ErrorXML(w, code, msg, key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+	if upload.ChecksumAlgorithm != "" && (checksum == nil || checksum.alg != upload.ChecksumAlgorithm) {
+		got := "null"
+		if checksum != nil {
+			got = strings.ToLower(checksum.alg)
+		}
+		sim.S3ErrorXML(w, "InvalidRequest", fmt.Sprintf("Checksum Type mismatch occurred, expected checksum Type: %s, actual checksum Type: %s",
+			strings.ToLower(upload.ChecksumAlgorithm), got), key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+	payload, trailers := s3PayloadReader(r)
+	defer r.Body.Close()
+
+	// Stream to a temp file beside the final path, then rename, so a
+	// retried part never leaves a half-written payload behind.
+	path := s3PartPath(upload.UploadID, partNumber)
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".part-*")
+	if err != nil {
+		sim.S3ErrorXML(w, "InternalError", fmt.Sprintf("create part file: %v", err),
+			key, sim.RequestID(r.Context()), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	md5h := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmp, md5h, checksum), payload)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		sim.S3ErrorXML(w, "IncompleteBody", fmt.Sprintf("read part body: %v", err),
+			key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+	if code, msg := verifyS3ContentMD5(r, md5h.Sum(nil)); code != "" {
+		sim.S3ErrorXML(w, code, msg, key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+	if code, msg := checksum.verify(trailers); code != "" {
+		sim.S3ErrorXML(w, code, msg, key, sim.RequestID(r.Context()), http.StatusBadRequest)
+		return
+	}
+
+	part := S3Part{
+		PartNumber:   partNumber,
+		ETag:         fmt.Sprintf("\"%x\"", md5h.Sum(nil)),
+		Size:         size,
+		LastModified: time.Now().UTC(),
+	}
+	if checksum != nil {
+		w.Header().Set(s3ChecksumHeader(checksum.alg), checksum.sum())
+		if upload.ChecksumAlgorithm != "" {
+			part.Checksum = checksum.sum()
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		sim.S3ErrorXML(w, "InternalError", fmt.Sprintf("store part: %v", err),
+			key, sim.RequestID(r.Context()), http.StatusInternalServerError)
+		return
+	}
+	// The upload may have been aborted or completed while the body
+	// streamed in.
+	if !s3Uploads.Update(upload.UploadID, func(u *S3MultipartUpload) {
+		if u.Parts == nil {
+			u.Parts = map[int]S3Part{}
+		}
+		u.Parts[partNumber] = part
+	}) {
+		_ = os.Remove(path)
+		sim.S3ErrorXML(w, "NoSuchUpload",
+			"The specified upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.",
+			upload.UploadID, sim.RequestID(r.Context()), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("ETag", part.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleS3CompleteMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	bucket := sim.PathParam(r, "bucket")
+	key := sim.PathParam(r, "key")
+	upload, ok := s3UploadFromRequest(w, r)
+	if !ok {
+		return
+	}
+	reqID := sim.RequestID(r.Context())
+
+	var req s3CompleteMultipartUpload
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		sim.S3ErrorXML(w, "MalformedXML",
+			"The XML you provided was not well-formed or did not validate against our published schema",
+			key, reqID, http.StatusBadRequest)
+		return
+	}
+
+	invalidPart := func() {
+		sim.S3ErrorXML(w, "InvalidPart",
+			"One or more of the specified parts could not be found.  The part may not have been uploaded, or the specified entity tag may not match the part's entity tag.",
+			key, reqID, http.StatusBadRequest)
+	}
+	parts := make([]S3Part, 0, len(req.Parts))
+	for i, p := range req.Parts {
+		if i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			sim.S3ErrorXML(w, "InvalidPartOrder",
+				"The list of parts was not in ascending order. Parts must be ordered by part number.",
+				key, reqID, http.StatusBadRequest)
+			return
+		}
+		stored, ok := upload.Parts[p.PartNumber]
+		if !ok || strings.Trim(p.ETag, `"`) != strings.Trim(stored.ETag, `"`) {
+			invalidPart()
+			return
+		}
+		if upload.ChecksumType == "COMPOSITE" {
+			if sent := p.get(upload.ChecksumAlgorithm); sent != "" && sent != stored.Checksum {
+				invalidPart()
+				return
+			}
+		}
+		parts = append(parts, stored)
+	}
+	for _, p := range parts[:len(parts)-1] {
+		if p.Size < s3MinPartSize {
+			sim.S3ErrorXML(w, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.",
+				key, reqID, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Assemble the object, computing the full-object checksum on the
+	// way through when the upload asked for one.
+	objPath := filepath.Join(s3DataRoot(), "objects", upload.UploadID)
+	out, err := os.Create(objPath)
+	if err != nil {
+		sim.S3ErrorXML(w, "InternalError", fmt.Sprintf("create object file: %v", err), key, reqID, http.StatusInternalServerError)
+		return
+	}
+	var full *s3Checksum
+	if upload.ChecksumType == "FULL_OBJECT" {
+		full = &s3Checksum{alg: upload.ChecksumAlgorithm, h: newS3ChecksumHash(upload.ChecksumAlgorithm)}
+	}
+	var size int64
+	partMD5s := md5.New()
+	partSums := make([]string, 0, len(parts))
+	for _, p := range parts {
+		n, err := s3AppendPart(out, full, s3PartPath(upload.UploadID, p.PartNumber))
+		if err != nil {
+			out.Close()
+			os.Remove(objPath)
+			sim.S3ErrorXML(w, "InternalError", fmt.Sprintf("assemble part %d: %v", p.PartNumber, err), key, reqID, http.StatusInternalServerError)
+			return
+		}
+		size += n
+		raw, _ := hex.DecodeString(strings.Trim(p.ETag, `"`))
+		partMD5s.Write(raw)
+		partSums = append(partSums, p.Checksum)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(objPath)
+		sim.S3ErrorXML(w, "InternalError", fmt.Sprintf("write object: %v", err), key, reqID, http.StatusInternalServerError)
+		return
+	}
+
+	var checksum string
+	switch upload.ChecksumType {
+	case "FULL_OBJECT":
+		checksum = full.sum()
+		if sent := r.Header.Get(s3ChecksumHeader(upload.ChecksumAlgorithm)); sent != "" && sent != checksum {
+			os.Remove(objPath)
+			sim.S3ErrorXML(w, "BadDigest", fmt.Sprintf("The %s you specified did not match the calculated checksum.", upload.ChecksumAlgorithm),
+				key, reqID, http.StatusBadRequest)
+			return
+		}
+	case "COMPOSITE":
+		checksum, err = s3CompositeChecksum(upload.ChecksumAlgorithm, partSums)
+		if err != nil {
+			os.Remove(objPath)
+			sim.S3ErrorXML(w, "InternalError", fmt.Sprintf("composite checksum: %v", err), key, reqID, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Claim the upload before publishing the object so a concurrent
+	// Complete or Abort can't also win.
+	if !s3Uploads.Delete(upload.UploadID) {
+		os.Remove(objPath)
+		sim.S3ErrorXML(w, "NoSuchUpload",
+			"The specified upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.",
+			upload.UploadID, reqID, http.StatusNotFound)
+		return
+	}
+	_ = os.RemoveAll(filepath.Dir(s3PartPath(upload.UploadID, 1)))
+
+	etag := fmt.Sprintf("\"%x-%d\"", partMD5s.Sum(nil), len(parts))
+	putS3Object(S3Object{
+		Key:               s3ObjectKey(bucket, key),
+		ContentType:       upload.ContentType,
+		ETag:              etag,
+		LastModified:      time.Now(),
+		Size:              size,
+		Metadata:          upload.Metadata,
+		DataPath:          objPath,
+		ChecksumAlgorithm: upload.ChecksumAlgorithm,
+		Checksum:          checksum,
+		ChecksumType:      upload.ChecksumType,
+		PartsCount:        len(parts),
+	})
+
+	result := s3CompleteMultipartUploadResult{
+		Xmlns:        s3Xmlns,
+		Location:     fmt.Sprintf("http://%s/s3/%s/%s", r.Host, bucket, key),
+		Bucket:       bucket,
+		Key:          key,
+		ETag:         etag,
+		ChecksumType: upload.ChecksumType,
+	}
+	result.set(upload.ChecksumAlgorithm, checksum)
+	sim.WriteXML(w, http.StatusOK, result)
+}
+
+// s3AppendPart copies a part file onto the assembled object.
+func s3AppendPart(dst io.Writer, full *s3Checksum, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(io.MultiWriter(dst, full), f)
+}
+
+func handleS3AbortMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	upload, ok := s3UploadFromRequest(w, r)
+	if !ok {
+		return
+	}
+	s3Uploads.Delete(upload.UploadID)
+	_ = os.RemoveAll(filepath.Dir(s3PartPath(upload.UploadID, 1)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleS3ListParts(w http.ResponseWriter, r *http.Request) {
+	upload, ok := s3UploadFromRequest(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	maxParts := 1000
+	if v := q.Get("max-parts"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < maxParts {
+			maxParts = n
+		}
+	}
+	marker, _ := strconv.Atoi(q.Get("part-number-marker"))
+
+	numbers := make([]int, 0, len(upload.Parts))
+	for n := range upload.Parts {
+		if n > marker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+
+	result := s3ListPartsResult{
+		Xmlns:             s3Xmlns,
+		Bucket:            upload.Bucket,
+		Key:               upload.Key,
+		UploadID:          upload.UploadID,
+		Initiator:         s3SimOwner(),
+		Owner:             s3SimOwner(),
+		StorageClass:      "STANDARD",
+		PartNumberMarker:  marker,
+		MaxParts:          maxParts,
+		ChecksumAlgorithm: upload.ChecksumAlgorithm,
+		ChecksumType:      upload.ChecksumType,
+		Parts:             []s3PartInfo{},
+	}
+	if len(numbers) > maxParts {
+		numbers = numbers[:maxParts]
+		result.IsTruncated = true
+	}
+	for _, n := range numbers {
+		p := upload.Parts[n]
+		info := s3PartInfo{
+			PartNumber:   p.PartNumber,
+			LastModified: p.LastModified.Format(time.RFC3339),
+			ETag:         p.ETag,
+			Size:         p.Size,
+		}
+		info.set(upload.ChecksumAlgorithm, p.Checksum)
+		result.Parts = append(result.Parts, info)
+		result.NextPartNumberMarker = n
+	}
+	sim.WriteXML(w, http.StatusOK, result)
+}
+
+func handleS3ListMultipartUploads(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	maxUploads := 1000
+	if v := q.Get("max-uploads"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < maxUploads {
+			maxUploads = n
+		}
+	}
+	uploads := s3Uploads.Filter(func(u S3MultipartUpload) bool {
+		return u.Bucket == bucket && strings.HasPrefix(u.Key, prefix)
+	})
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		return uploads[i].Initiated.Before(uploads[j].Initiated)
+	})
+
+	result := s3ListMultipartUploadsResult{
+		Xmlns:          s3Xmlns,
+		Bucket:         bucket,
+		KeyMarker:      q.Get("key-marker"),
+		UploadIDMarker: q.Get("upload-id-marker"),
+		Prefix:         prefix,
+		MaxUploads:     maxUploads,
+		Uploads:        []s3UploadInfo{},
+	}
+	for _, u := range uploads {
+		if result.KeyMarker != "" && u.Key <= result.KeyMarker {
+			continue
+		}
+		if len(result.Uploads) == maxUploads {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, s3UploadInfo{
+			Key:               u.Key,
+			UploadID:          u.UploadID,
+			Initiator:         s3SimOwner(),
+			Owner:             s3SimOwner(),
+			StorageClass:      "STANDARD",
+			Initiated:         u.Initiated.Format(time.RFC3339),
+			ChecksumAlgorithm: u.ChecksumAlgorithm,
+			ChecksumType:      u.ChecksumType,
+		})
+		result.NextKeyMarker = u.Key
+		result.NextUploadIDMarker = u.UploadID
+	}
+	sim.WriteXML(w, http.StatusOK, result)
+}
@@ -1,11 +1,15 @@
 package aws_sdk_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -143,6 +147,49 @@ func TestECR_PullThroughCacheDelete(t *testing.T) {
 	assert.Contains(t, err.Error(), "PullThroughCacheRuleNotFound")
 }
 
+// TestECR_PullThroughCacheCredentialArn verifies rules keep the
+// Secrets Manager credential they authenticate upstream with, and that
+// secrets outside the ecr-pullthroughcache/ prefix are rejected.
+func TestECR_PullThroughCacheCredentialArn(t *testing.T) {
+	sm := smClient()
+	secret, err := sm.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String("ecr-pullthroughcache/ghcr-cred"),
+		SecretString: aws.String(`{"username":"u","accessToken":"t"}`),
+	})
+	require.NoError(t, err)
+	other, err := sm.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String("not-ecr-ghcr-cred"),
+		SecretString: aws.String(`{"username":"u","accessToken":"t"}`),
+	})
+	require.NoError(t, err)
+
+	client := ecrClient()
+	_, err = client.CreatePullThroughCacheRule(ctx, &ecr.CreatePullThroughCacheRuleInput{
+		EcrRepositoryPrefix: aws.String("ghcr-bad-cred"),
+		UpstreamRegistryUrl: aws.String("ghcr.io"),
+		UpstreamRegistry:    ecrtypes.UpstreamRegistryGitHubContainerRegistry,
+		CredentialArn:       other.ARN,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "UnableToAccessSecret")
+
+	out, err := client.CreatePullThroughCacheRule(ctx, &ecr.CreatePullThroughCacheRuleInput{
+		EcrRepositoryPrefix: aws.String("ghcr-io"),
+		UpstreamRegistryUrl: aws.String("ghcr.io"),
+		UpstreamRegistry:    ecrtypes.UpstreamRegistryGitHubContainerRegistry,
+		CredentialArn:       secret.ARN,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, aws.ToString(secret.ARN), aws.ToString(out.CredentialArn))
+
+	described, err := client.DescribePullThroughCacheRules(ctx, &ecr.DescribePullThroughCacheRulesInput{
+		EcrRepositoryPrefixes: []string{"ghcr-io"},
+	})
+	require.NoError(t, err)
+	require.Len(t, described.PullThroughCacheRules, 1)
+	assert.Equal(t, aws.ToString(secret.ARN), aws.ToString(described.PullThroughCacheRules[0].CredentialArn))
+}
+
 func TestECR_LifecyclePolicy(t *testing.T) {
 	client := ecrClient()
 
@@ -164,3 +211,54 @@ func TestECR_LifecyclePolicy(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotEmpty(t, *getOut.LifecyclePolicyText)
 }
+
+// TestECR_ImageScanFindings covers the path sockerless's ECRScanner
+// takes: DescribeImageScanFindings answers ScanNotFoundException until
+// StartImageScan runs, then reports the findings seeded for the digest.
+func TestECR_ImageScanFindings(t *testing.T) {
+	client := ecrClient()
+	_, err := client.CreateRepository(ctx, &ecr.CreateRepositoryInput{RepositoryName: aws.String("scan-repo")})
+	require.NoError(t, err)
+	put, err := client.PutImage(ctx, &ecr.PutImageInput{
+		RepositoryName: aws.String("scan-repo"),
+		ImageTag:       aws.String("v1"),
+		ImageManifest:  aws.String(`{"schemaVersion":2}`),
+	})
+	require.NoError(t, err)
+	digest := aws.ToString(put.Image.ImageId.ImageDigest)
+	imageID := &ecrtypes.ImageIdentifier{ImageTag: aws.String("v1")}
+
+	_, err = client.DescribeImageScanFindings(ctx, &ecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String("scan-repo"), ImageId: imageID,
+	})
+	var notFound *ecrtypes.ScanNotFoundException
+	require.ErrorAs(t, err, &notFound)
+
+	body, _ := json.Marshal(map[string]any{
+		"repositoryName": "scan-repo",
+		"imageDigest":    digest,
+		"findings": []map[string]any{{
+			"name":       "CVE-2024-0001",
+			"severity":   "HIGH",
+			"attributes": []map[string]string{{"key": "package_name", "value": "openssl"}},
+		}},
+	})
+	resp, err := http.Post(baseURL+"/sim/v1/ecr/scan-findings", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	_, err = client.StartImageScan(ctx, &ecr.StartImageScanInput{RepositoryName: aws.String("scan-repo"), ImageId: imageID})
+	require.NoError(t, err)
+	out, err := client.DescribeImageScanFindings(ctx, &ecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String("scan-repo"), ImageId: imageID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ecrtypes.ScanStatusComplete, out.ImageScanStatus.Status)
+	assert.Equal(t, digest, aws.ToString(out.ImageId.ImageDigest))
+	require.Len(t, out.ImageScanFindings.Findings, 1)
+	f := out.ImageScanFindings.Findings[0]
+	assert.Equal(t, "CVE-2024-0001", aws.ToString(f.Name))
+	assert.Equal(t, ecrtypes.FindingSeverityHigh, f.Severity)
+	assert.Equal(t, int32(1), out.ImageScanFindings.FindingSeverityCounts["HIGH"])
+}
@@ -0,0 +1,386 @@
+package aws_sdk_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ecsTestNetwork = &ecstypes.NetworkConfiguration{
+	AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{
+		Subnets: []string{"subnet-0123456789abcdef0"},
+	},
+}
+
+// ecsServiceSetup creates a cluster with both Fargate capacity
+// providers and registers a Fargate task definition running command.
+func ecsServiceSetup(t *testing.T, name string, command ...string) (*ecs.Client, string, string) {
+	t.Helper()
+	client := ecsClient()
+	cluster := name + "-cluster"
+	_, err := client.CreateCluster(ctx, &ecs.CreateClusterInput{
+		ClusterName:       aws.String(cluster),
+		CapacityProviders: []string{"FARGATE", "FARGATE_SPOT"},
+	})
+	require.NoError(t, err)
+
+	td, err := client.RegisterTaskDefinition(ctx, &ecs.RegisterTaskDefinitionInput{
+		Family:                  aws.String(name + "-task"),
+		RequiresCompatibilities: []ecstypes.Compatibility{ecstypes.CompatibilityFargate},
+		NetworkMode:             ecstypes.NetworkModeAwsvpc,
+		Cpu:                     aws.String("256"),
+		Memory:                  aws.String("512"),
+		ContainerDefinitions: []ecstypes.ContainerDefinition{{
+			Name:    aws.String("app"),
+			Image:   aws.String("alpine:latest"),
+			Command: command,
+		}},
+	})
+	require.NoError(t, err)
+	return client, cluster, *td.TaskDefinition.TaskDefinitionArn
+}
+
+func describeService(t *testing.T, client *ecs.Client, cluster, service string) ecstypes.Service {
+	t.Helper()
+	out, err := client.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(cluster),
+		Services: []string{service},
+	})
+	require.NoError(t, err)
+	require.Len(t, out.Services, 1)
+	return out.Services[0]
+}
+
+// waitServiceSteady polls until the service has a single COMPLETED
+// deployment running desired tasks.
+func waitServiceSteady(t *testing.T, client *ecs.Client, cluster, service string, desired int32) ecstypes.Service {
+	t.Helper()
+	var svc ecstypes.Service
+	require.Eventually(t, func() bool {
+		svc = describeService(t, client, cluster, service)
+		return svc.RunningCount == desired && svc.PendingCount == 0 && len(svc.Deployments) == 1 &&
+			svc.Deployments[0].RolloutState == ecstypes.DeploymentRolloutStateCompleted
+	}, 60*time.Second, 250*time.Millisecond, "service %s never reached a steady state", service)
+	return svc
+}
+
+func serviceTaskArns(t *testing.T, client *ecs.Client, cluster, service string) []string {
+	t.Helper()
+	out, err := client.ListTasks(ctx, &ecs.ListTasksInput{
+		Cluster:       aws.String(cluster),
+		ServiceName:   aws.String(service),
+		DesiredStatus: ecstypes.DesiredStatusRunning,
+	})
+	require.NoError(t, err)
+	return out.TaskArns
+}
+
+func eventMessages(svc ecstypes.Service) string {
+	var msgs []string
+	for _, e := range svc.Events {
+		msgs = append(msgs, *e.Message)
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func TestECS_Service_ReplacesStoppedTask(t *testing.T) {
+	client, cluster, td := ecsServiceSetup(t, "svc-replace", "tail", "-f", "/dev/null")
+
+	out, err := client.CreateService(ctx, &ecs.CreateServiceInput{
+		Cluster:              aws.String(cluster),
+		ServiceName:          aws.String("web"),
+		TaskDefinition:       aws.String(td),
+		DesiredCount:         aws.Int32(2),
+		LaunchType:           ecstypes.LaunchTypeFargate,
+		NetworkConfiguration: ecsTestNetwork,
+		PropagateTags:        ecstypes.PropagateTagsService,
+		Tags:                 []ecstypes.Tag{{Key: aws.String("team"), Value: aws.String("ci")}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ACTIVE", *out.Service.Status)
+	assert.Contains(t, *out.Service.ServiceArn, ":service/"+cluster+"/web")
+
+	waitServiceSteady(t, client, cluster, "web", 2)
+	before := serviceTaskArns(t, client, cluster, "web")
+	require.Len(t, before, 2)
+
+	// Service tags propagate to the tasks it launches.
+	tasks, err := client.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+		Cluster: aws.String(cluster), Tasks: before, Include: []ecstypes.TaskField{ecstypes.TaskFieldTags},
+	})
+	require.NoError(t, err)
+	for _, task := range tasks.Tasks {
+		assert.Equal(t, "service:web", *task.Group)
+		assert.True(t, strings.HasPrefix(*task.StartedBy, "ecs-svc/"), *task.StartedBy)
+		require.Len(t, task.Tags, 1)
+		assert.Equal(t, "team", *task.Tags[0].Key)
+	}
+
+	_, err = client.StopTask(ctx, &ecs.StopTaskInput{Cluster: aws.String(cluster), Task: aws.String(before[0])})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		arns := serviceTaskArns(t, client, cluster, "web")
+		svc := describeService(t, client, cluster, "web")
+		return len(arns) == 2 && !slices.Contains(arns, before[0]) && svc.RunningCount == 2
+	}, 60*time.Second, 250*time.Millisecond, "stopped task was not replaced")
+
+	svc := describeService(t, client, cluster, "web")
+	assert.Contains(t, eventMessages(svc), "(service web) has started 1 tasks")
+	assert.Contains(t, eventMessages(svc), "(service web) has reached a steady state.")
+
+	clusters, err := client.DescribeClusters(ctx, &ecs.DescribeClustersInput{Clusters: []string{cluster}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), clusters.Clusters[0].ActiveServicesCount)
+
+	_, err = client.DeleteCluster(ctx, &ecs.DeleteClusterInput{Cluster: aws.String(cluster)})
+	var inUse *ecstypes.ClusterContainsServicesException
+	assert.ErrorAs(t, err, &inUse)
+}
+
+func TestECS_Service_UpdateRollsDeploymentAndDelete(t *testing.T) {
+	client, cluster, td1 := ecsServiceSetup(t, "svc-rollout", "tail", "-f", "/dev/null")
+	_, err := client.CreateService(ctx, &ecs.CreateServiceInput{
+		Cluster:              aws.String(cluster),
+		ServiceName:          aws.String("api"),
+		TaskDefinition:       aws.String(td1),
+		DesiredCount:         aws.Int32(1),
+		LaunchType:           ecstypes.LaunchTypeFargate,
+		NetworkConfiguration: ecsTestNetwork,
+	})
+	require.NoError(t, err)
+	first := waitServiceSteady(t, client, cluster, "api", 1)
+	oldTasks := serviceTaskArns(t, client, cluster, "api")
+
+	// A new task definition revision starts a new PRIMARY deployment;
+	// the old one drains once the new tasks are running.
+	_, _, td2 := ecsServiceSetup(t, "svc-rollout", "sleep", "3600")
+	upd, err := client.UpdateService(ctx, &ecs.UpdateServiceInput{
+		Cluster:        aws.String(cluster),
+		Service:        aws.String("api"),
+		TaskDefinition: aws.String(td2),
+		DesiredCount:   aws.Int32(2),
+	})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(upd.Service.Deployments), 2)
+	assert.Equal(t, "PRIMARY", *upd.Service.Deployments[0].Status)
+	assert.Equal(t, td2, *upd.Service.Deployments[0].TaskDefinition)
+
+	svc := waitServiceSteady(t, client, cluster, "api", 2)
+	assert.Equal(t, td2, *svc.TaskDefinition)
+	assert.NotEqual(t, *first.Deployments[0].Id, *svc.Deployments[0].Id)
+	assert.Contains(t, eventMessages(svc), "deployment completed")
+	for _, arn := range serviceTaskArns(t, client, cluster, "api") {
+		assert.NotContains(t, oldTasks, arn)
+	}
+
+	// A scaled-up service can't be deleted without force.
+	_, err = client.DeleteService(ctx, &ecs.DeleteServiceInput{Cluster: aws.String(cluster), Service: aws.String("api")})
+	require.Error(t, err)
+
+	_, err = client.UpdateService(ctx, &ecs.UpdateServiceInput{
+		Cluster: aws.String(cluster), Service: aws.String("api"), DesiredCount: aws.Int32(0),
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(serviceTaskArns(t, client, cluster, "api")) == 0
+	}, 30*time.Second, 250*time.Millisecond)
+
+	del, err := client.DeleteService(ctx, &ecs.DeleteServiceInput{Cluster: aws.String(cluster), Service: aws.String("api")})
+	require.NoError(t, err)
+	assert.Equal(t, "DRAINING", *del.Service.Status)
+	require.Eventually(t, func() bool {
+		return *describeService(t, client, cluster, "api").Status == "INACTIVE"
+	}, 30*time.Second, 250*time.Millisecond)
+
+	list, err := client.ListServices(ctx, &ecs.ListServicesInput{Cluster: aws.String(cluster)})
+	require.NoError(t, err)
+	assert.Empty(t, list.ServiceArns)
+
+	_, err = client.UpdateService(ctx, &ecs.UpdateServiceInput{
+		Cluster: aws.String(cluster), Service: aws.String("api"), DesiredCount: aws.Int32(1),
+	})
+	var notActive *ecstypes.ServiceNotActiveException
+	assert.ErrorAs(t, err, &notActive)
+}
+
+func TestECS_Service_CrashLoopIsThrottled(t *testing.T) {
+	client, cluster, td := ecsServiceSetup(t, "svc-crash", "sh", "-c", "exit 1")
+	_, err := client.CreateService(ctx, &ecs.CreateServiceInput{
+		Cluster:              aws.String(cluster),
+		ServiceName:          aws.String("crashy"),
+		TaskDefinition:       aws.String(td),
+		DesiredCount:         aws.Int32(1),
+		LaunchType:           ecstypes.LaunchTypeFargate,
+		NetworkConfiguration: ecsTestNetwork,
+	})
+	require.NoError(t, err)
+
+	var svc ecstypes.Service
+	require.Eventually(t, func() bool {
+		svc = describeService(t, client, cluster, "crashy")
+		return strings.Contains(eventMessages(svc), "is unable to consistently start tasks successfully")
+	}, 60*time.Second, 500*time.Millisecond)
+	assert.GreaterOrEqual(t, svc.Deployments[0].FailedTasks, int32(2))
+	assert.Equal(t, ecstypes.DeploymentRolloutStateInProgress, svc.Deployments[0].RolloutState)
+
+	_, err = client.DeleteService(ctx, &ecs.DeleteServiceInput{
+		Cluster: aws.String(cluster), Service: aws.String("crashy"), Force: aws.Bool(true),
+	})
+	require.NoError(t, err)
+}
+
+func TestECS_CapacityProviderStrategy_Placement(t *testing.T) {
+	client, cluster, td := ecsServiceSetup(t, "cp-spread", "tail", "-f", "/dev/null")
+
+	providers, err := client.DescribeCapacityProviders(ctx, &ecs.DescribeCapacityProvidersInput{})
+	require.NoError(t, err)
+	var names []string
+	for _, p := range providers.CapacityProviders {
+		names = append(names, *p.Name)
+		assert.Equal(t, ecstypes.CapacityProviderStatusActive, p.Status)
+	}
+	assert.ElementsMatch(t, []string{"FARGATE", "FARGATE_SPOT"}, names)
+
+	// One on-demand task as the base, the rest weighted 1:3 towards Spot.
+	run, err := client.RunTask(ctx, &ecs.RunTaskInput{
+		Cluster:        aws.String(cluster),
+		TaskDefinition: aws.String(td),
+		Count:          aws.Int32(4),
+		CapacityProviderStrategy: []ecstypes.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE"), Base: 1, Weight: 1},
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 3},
+		},
+		NetworkConfiguration: ecsTestNetwork,
+	})
+	require.NoError(t, err)
+	require.Len(t, run.Tasks, 4)
+	placed := map[string]int{}
+	for _, task := range run.Tasks {
+		placed[*task.CapacityProviderName]++
+		assert.Equal(t, ecstypes.LaunchTypeFargate, task.LaunchType)
+	}
+	assert.Equal(t, map[string]int{"FARGATE": 1, "FARGATE_SPOT": 3}, placed)
+
+	// The cluster default strategy applies when neither a launch type
+	// nor a strategy is given.
+	_, err = client.PutClusterCapacityProviders(ctx, &ecs.PutClusterCapacityProvidersInput{
+		Cluster:           aws.String(cluster),
+		CapacityProviders: []string{"FARGATE", "FARGATE_SPOT"},
+		DefaultCapacityProviderStrategy: []ecstypes.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1},
+		},
+	})
+	require.NoError(t, err)
+	run, err = client.RunTask(ctx, &ecs.RunTaskInput{
+		Cluster: aws.String(cluster), TaskDefinition: aws.String(td), NetworkConfiguration: ecsTestNetwork,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "FARGATE_SPOT", *run.Tasks[0].CapacityProviderName)
+}
+
+func TestECS_CapacityProviderStrategy_Validation(t *testing.T) {
+	client := ecsClient()
+	_, err := client.CreateCluster(ctx, &ecs.CreateClusterInput{ClusterName: aws.String("cp-validate-cluster")})
+	require.NoError(t, err)
+	td, err := client.RegisterTaskDefinition(ctx, &ecs.RegisterTaskDefinitionInput{
+		Family:               aws.String("cp-validate-task"),
+		ContainerDefinitions: []ecstypes.ContainerDefinition{{Name: aws.String("app"), Image: aws.String("alpine:latest")}},
+	})
+	require.NoError(t, err)
+	tdArn := td.TaskDefinition.TaskDefinitionArn
+
+	// FARGATE_SPOT isn't associated with this cluster.
+	_, err = client.RunTask(ctx, &ecs.RunTaskInput{
+		Cluster:        aws.String("cp-validate-cluster"),
+		TaskDefinition: tdArn,
+		CapacityProviderStrategy: []ecstypes.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not associated with the cluster")
+
+	_, err = client.RunTask(ctx, &ecs.RunTaskInput{
+		Cluster:        aws.String("cp-validate-cluster"),
+		TaskDefinition: tdArn,
+		LaunchType:     ecstypes.LaunchTypeFargate,
+		CapacityProviderStrategy: []ecstypes.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE"), Weight: 1},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "launch type and capacity provider strategy")
+
+	_, err = client.PutClusterCapacityProviders(ctx, &ecs.PutClusterCapacityProvidersInput{
+		Cluster:                         aws.String("cp-validate-cluster"),
+		CapacityProviders:               []string{"FARGATE"},
+		DefaultCapacityProviderStrategy: []ecstypes.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE"), Weight: 0}},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "weight greater than 0")
+}
+
+// spotInterrupt calls the simulator's Spot interruption trigger.
+func spotInterrupt(t *testing.T, cluster string, tasks ...string) []string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"cluster": cluster, "tasks": tasks})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/sim/v1/ecs/spot-interruptions", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var out struct {
+		InterruptedTasks []string `json:"interruptedTasks"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	return out.InterruptedTasks
+}
+
+func TestECS_SpotInterruption_ServiceReplacesTask(t *testing.T) {
+	client, cluster, td := ecsServiceSetup(t, "spot-svc", "tail", "-f", "/dev/null")
+	_, err := client.CreateService(ctx, &ecs.CreateServiceInput{
+		Cluster:        aws.String(cluster),
+		ServiceName:    aws.String("runner"),
+		TaskDefinition: aws.String(td),
+		DesiredCount:   aws.Int32(1),
+		CapacityProviderStrategy: []ecstypes.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1},
+		},
+		NetworkConfiguration: ecsTestNetwork,
+	})
+	require.NoError(t, err)
+	waitServiceSteady(t, client, cluster, "runner", 1)
+	victim := serviceTaskArns(t, client, cluster, "runner")[0]
+
+	interrupted := spotInterrupt(t, cluster)
+	require.Equal(t, []string{victim}, interrupted)
+
+	desc, err := client.DescribeTasks(ctx, &ecs.DescribeTasksInput{Cluster: aws.String(cluster), Tasks: []string{victim}})
+	require.NoError(t, err)
+	task := desc.Tasks[0]
+	assert.Equal(t, "STOPPED", *task.LastStatus)
+	assert.Equal(t, ecstypes.TaskStopCodeSpotInterruption, task.StopCode)
+	assert.Equal(t, "Your Spot Task was interrupted.", *task.StoppedReason)
+	require.NotNil(t, task.Containers[0].ExitCode)
+	assert.Equal(t, int32(143), *task.Containers[0].ExitCode)
+
+	require.Eventually(t, func() bool {
+		arns := serviceTaskArns(t, client, cluster, "runner")
+		return len(arns) == 1 && arns[0] != victim &&
+			describeService(t, client, cluster, "runner").RunningCount == 1
+	}, 60*time.Second, 250*time.Millisecond, "interrupted Spot task was not replaced")
+	assert.Contains(t, eventMessages(describeService(t, client, cluster, "runner")),
+		fmt.Sprintf("(task %s) was stopped by a Spot interruption", victim[strings.LastIndex(victim, "/")+1:]))
+}
@@ -0,0 +1,192 @@
+package aws_sdk_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simFault mirrors the /sim/v1/faults wire shape.
+type simFault struct {
+	ID          string  `json:"id,omitempty"`
+	Service     string  `json:"service,omitempty"`
+	Action      string  `json:"action,omitempty"`
+	Resource    string  `json:"resource,omitempty"`
+	Method      string  `json:"method,omitempty"`
+	Kind        string  `json:"kind"`
+	Status      int     `json:"status,omitempty"`
+	Code        string  `json:"code,omitempty"`
+	Latency     string  `json:"latency,omitempty"`
+	Probability float64 `json:"probability,omitempty"`
+	Count       int     `json:"count,omitempty"`
+	After       int     `json:"after,omitempty"`
+	Matched     int     `json:"matched,omitempty"`
+	Hits        int     `json:"hits,omitempty"`
+}
+
+// injectFaults replaces the simulator's fault set for the duration of
+// the test.
+func injectFaults(t *testing.T, faults ...simFault) {
+	t.Helper()
+	body, err := json.Marshal(faults)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, baseURL+"/sim/v1/faults", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	msg, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(msg))
+	t.Cleanup(func() {
+		req, _ := http.NewRequest(http.MethodDelete, baseURL+"/sim/v1/faults", nil)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+}
+
+func faultHits(t *testing.T, id string) int {
+	t.Helper()
+	resp, err := http.Get(baseURL + "/sim/v1/faults/" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var f simFault
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&f))
+	return f.Hits
+}
+
+// fastRetryer is the SDK's standard retryer with backoff shrunk so
+// retry tests don't sleep for seconds.
+func fastRetryer() aws.Retryer {
+	return retry.AddWithMaxBackoffDelay(retry.NewStandard(), 10*time.Millisecond)
+}
+
+func TestFaults_ThrottlingRetriedBySDK(t *testing.T) {
+	injectFaults(t, simFault{ID: "throttle", Service: "ecs", Action: "DescribeClusters", Kind: "error", Count: 2})
+
+	c := ecs.NewFromConfig(sdkConfig(), func(o *ecs.Options) {
+		o.BaseEndpoint = aws.String(baseURL)
+		o.Retryer = fastRetryer()
+	})
+	out, err := c.DescribeClusters(ctx, &ecs.DescribeClustersInput{})
+	require.NoError(t, err, "standard retryer must absorb two throttles")
+	assert.NotNil(t, out)
+	assert.Equal(t, 2, faultHits(t, "throttle"))
+
+	// Other actions on the same service are not selected.
+	_, err = c.DescribeCapacityProviders(ctx, &ecs.DescribeCapacityProvidersInput{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, faultHits(t, "throttle"))
+}
+
+func TestFaults_ThrottlingExhaustsRetries(t *testing.T) {
+	injectFaults(t, simFault{ID: "throttle", Service: "ecs", Kind: "error"})
+
+	c := ecs.NewFromConfig(sdkConfig(), func(o *ecs.Options) {
+		o.BaseEndpoint = aws.String(baseURL)
+		o.Retryer = fastRetryer()
+	})
+	_, err := c.DescribeClusters(ctx, &ecs.DescribeClustersInput{})
+	require.Error(t, err)
+	var apiErr smithy.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "ThrottlingException", apiErr.ErrorCode())
+	assert.Equal(t, 3, faultHits(t, "throttle"), "standard retryer makes 3 attempts")
+}
+
+func TestFaults_QueryProtocolThrottling(t *testing.T) {
+	injectFaults(t, simFault{ID: "sts", Service: "sts", Action: "GetCallerIdentity", Kind: "error", Count: 1})
+
+	c := sts.NewFromConfig(sdkConfig(), func(o *sts.Options) {
+		o.BaseEndpoint = aws.String(baseURL)
+		o.Retryer = fastRetryer()
+	})
+	_, err := c.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, faultHits(t, "sts"))
+}
+
+func TestFaults_S3SlowDown(t *testing.T) {
+	c := s3Client()
+	_, err := c.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("fault-slowdown")})
+	require.NoError(t, err)
+	_, err = c.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("fault-slowdown"),
+		Key:    aws.String("k"),
+		Body:   strings.NewReader("payload"),
+	})
+	require.NoError(t, err)
+
+	injectFaults(t, simFault{ID: "slow", Resource: "/s3/fault-slowdown/*", Method: "GET", Kind: "error", Count: 2})
+	out, err := c.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("fault-slowdown"), Key: aws.String("k")},
+		func(o *s3.Options) { o.Retryer = fastRetryer() })
+	require.NoError(t, err)
+	body, _ := io.ReadAll(out.Body)
+	out.Body.Close()
+	assert.Equal(t, "payload", string(body))
+	assert.Equal(t, 2, faultHits(t, "slow"))
+}
+
+func TestFaults_DroppedConnectionRetried(t *testing.T) {
+	injectFaults(t, simFault{ID: "drop", Service: "ecs", Action: "DescribeClusters", Kind: "drop", Count: 1})
+
+	c := ecs.NewFromConfig(sdkConfig(), func(o *ecs.Options) {
+		o.BaseEndpoint = aws.String(baseURL)
+		o.Retryer = fastRetryer()
+	})
+	_, err := c.DescribeClusters(ctx, &ecs.DescribeClustersInput{})
+	require.NoError(t, err, "connection reset is retryable")
+	assert.Equal(t, 1, faultHits(t, "drop"))
+}
+
+func TestFaults_Latency(t *testing.T) {
+	injectFaults(t, simFault{Service: "sts", Kind: "latency", Latency: "300ms"})
+
+	start := time.Now()
+	_, err := stsClient().GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}
+
+func TestFaults_StaleRead(t *testing.T) {
+	c := ssmClient()
+	name := aws.String("/faults/stale")
+	_, err := c.PutParameter(ctx, &ssm.PutParameterInput{Name: name, Type: ssmtypes.ParameterTypeString, Value: aws.String("v1")})
+	require.NoError(t, err)
+	t.Cleanup(func() { c.DeleteParameter(ctx, &ssm.DeleteParameterInput{Name: name}) })
+
+	injectFaults(t, simFault{ID: "stale", Service: "ssm", Action: "GetParameter", Kind: "stale", Count: 1})
+
+	got, err := c.GetParameter(ctx, &ssm.GetParameterInput{Name: name})
+	require.NoError(t, err)
+	assert.Equal(t, "v1", *got.Parameter.Value)
+
+	_, err = c.PutParameter(ctx, &ssm.PutParameterInput{Name: name, Type: ssmtypes.ParameterTypeString, Value: aws.String("v2"), Overwrite: aws.Bool(true)})
+	require.NoError(t, err)
+
+	got, err = c.GetParameter(ctx, &ssm.GetParameterInput{Name: name})
+	require.NoError(t, err)
+	assert.Equal(t, "v1", *got.Parameter.Value, "stale fault replays the earlier read")
+
+	got, err = c.GetParameter(ctx, &ssm.GetParameterInput{Name: name})
+	require.NoError(t, err)
+	assert.Equal(t, "v2", *got.Parameter.Value)
+	assert.Equal(t, 1, faultHits(t, "stale"))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -70,3 +71,53 @@ func TestIAM_PutRolePolicy(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "s3-read", *getOut.PolicyName)
 }
+
+func TestIAM_SimulatePrincipalPolicy(t *testing.T) {
+	client := iamClient()
+	assumePolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ecs-tasks.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+
+	role, err := client.CreateRole(ctx, &iam.CreateRoleInput{
+		RoleName:                 aws.String("simulate-role"),
+		AssumeRolePolicyDocument: aws.String(assumePolicy),
+	})
+	require.NoError(t, err)
+	inlinePolicy := `{"Version":"2012-10-17","Statement":[` +
+		`{"Effect":"Allow","Action":["ecs:*","iam:PassRole"],"Resource":"*"},` +
+		`{"Effect":"Deny","Action":"ecs:ExecuteCommand","Resource":"*"}]}`
+	_, err = client.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String("simulate-role"),
+		PolicyName:     aws.String("sockerless"),
+		PolicyDocument: aws.String(inlinePolicy),
+	})
+	require.NoError(t, err)
+
+	out, err := client.SimulatePrincipalPolicy(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: role.Role.Arn,
+		ActionNames:     []string{"ecs:RunTask", "ecs:ExecuteCommand", "elasticfilesystem:CreateAccessPoint"},
+	})
+	require.NoError(t, err)
+	decisions := map[string]string{}
+	for _, r := range out.EvaluationResults {
+		decisions[aws.ToString(r.EvalActionName)] = string(r.EvalDecision)
+	}
+	assert.Equal(t, "allowed", decisions["ecs:RunTask"])
+	assert.Equal(t, "explicitDeny", decisions["ecs:ExecuteCommand"])
+	assert.Equal(t, "implicitDeny", decisions["elasticfilesystem:CreateAccessPoint"])
+
+	// The simulator's own caller identity holds every action.
+	ident, err := stsClient().GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	require.NoError(t, err)
+	out, err = client.SimulatePrincipalPolicy(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: ident.Arn,
+		ActionNames:     []string{"ecs:ExecuteCommand"},
+	})
+	require.NoError(t, err)
+	require.Len(t, out.EvaluationResults, 1)
+	assert.Equal(t, "allowed", string(out.EvaluationResults[0].EvalDecision))
+
+	_, err = client.SimulatePrincipalPolicy(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String("arn:aws:iam::123456789012:role/does-not-exist"),
+		ActionNames:     []string{"ecs:RunTask"},
+	})
+	require.Error(t, err)
+}
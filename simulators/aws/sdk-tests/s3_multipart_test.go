@@ -0,0 +1,390 @@
+package aws_sdk_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mib = 1 << 20
+
+// patternReader streams n deterministic pseudo-random bytes without
+// holding them in memory, so multi-GB uploads can be checked end to end.
+type patternReader struct {
+	state uint64
+	left  int64
+}
+
+func newPatternReader(seed uint64, n int64) *patternReader {
+	return &patternReader{state: seed | 1, left: n}
+}
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	if p.left == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.left {
+		b = b[:p.left]
+	}
+	n := len(b) &^ 7
+	if n == 0 {
+		n = len(b)
+	}
+	var word [8]byte
+	for i := 0; i < n; i += 8 {
+		p.state ^= p.state << 13
+		p.state ^= p.state >> 7
+		p.state ^= p.state << 17
+		binary.LittleEndian.PutUint64(word[:], p.state)
+		copy(b[i:n], word[:])
+	}
+	p.left -= int64(n)
+	return n, nil
+}
+
+func createMultipartBucket(t *testing.T, name string) *s3.Client {
+	t.Helper()
+	client := s3Client()
+	_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(name)})
+	require.NoError(t, err)
+	return client
+}
+
+func requireS3ErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+	require.Error(t, err)
+	var apiErr smithy.APIError
+	require.True(t, errors.As(err, &apiErr), "expected API error, got %v", err)
+	assert.Equal(t, code, apiErr.ErrorCode())
+}
+
+// expectedMultipartETag is S3's "md5 of part md5s" ETag.
+func expectedMultipartETag(partMD5s [][]byte) string {
+	h := md5.New()
+	for _, m := range partMD5s {
+		h.Write(m)
+	}
+	return fmt.Sprintf("\"%x-%d\"", h.Sum(nil), len(partMD5s))
+}
+
+func TestS3Multipart_ManagerUploadDownload(t *testing.T) {
+	client := createMultipartBucket(t, "mpu-manager")
+
+	const size = 23*mib + 12345
+	data, err := io.ReadAll(newPatternReader(42, size))
+	require.NoError(t, err)
+
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = 5 * mib
+		u.Concurrency = 3
+	})
+	out, err := uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String("mpu-manager"),
+		Key:         aws.String("ctx/context.tar"),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/x-tar"),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, out.UploadID, "payload above PartSize must go multipart")
+
+	var partMD5s [][]byte
+	for off := 0; off < len(data); off += 5 * mib {
+		end := min(off+5*mib, len(data))
+		sum := md5.Sum(data[off:end])
+		partMD5s = append(partMD5s, sum[:])
+	}
+	assert.Equal(t, expectedMultipartETag(partMD5s), aws.ToString(out.ETag))
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String("mpu-manager"),
+		Key:    aws.String("ctx/context.tar"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), aws.ToInt64(head.ContentLength))
+	assert.Equal(t, "application/x-tar", aws.ToString(head.ContentType))
+
+	buf := manager.NewWriteAtBuffer(make([]byte, 0, size))
+	downloader := manager.NewDownloader(client, func(d *manager.Downloader) {
+		d.PartSize = 4 * mib
+	})
+	n, err := downloader.Download(ctx, buf, &s3.GetObjectInput{
+		Bucket: aws.String("mpu-manager"),
+		Key:    aws.String("ctx/context.tar"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), n)
+	assert.Equal(t, sha256.Sum256(data), sha256.Sum256(buf.Bytes()))
+}
+
+func TestS3Multipart_CompositeChecksum(t *testing.T) {
+	client := createMultipartBucket(t, "mpu-checksum")
+
+	create, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String("mpu-checksum"),
+		Key:               aws.String("obj"),
+		ChecksumAlgorithm: s3types.ChecksumAlgorithmCrc32c,
+	})
+	require.NoError(t, err)
+
+	castagnoli := crc32.MakeTable(crc32.Castagnoli)
+	parts := [][]byte{bytes.Repeat([]byte("a"), 5*mib), []byte("tail")}
+	var completed []s3types.CompletedPart
+	composite := crc32.New(castagnoli)
+	for i, p := range parts {
+		up, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:            aws.String("mpu-checksum"),
+			Key:               aws.String("obj"),
+			UploadId:          create.UploadId,
+			PartNumber:        aws.Int32(int32(i + 1)),
+			Body:              bytes.NewReader(p),
+			ChecksumAlgorithm: s3types.ChecksumAlgorithmCrc32c,
+		})
+		require.NoError(t, err)
+		var raw [4]byte
+		binary.BigEndian.PutUint32(raw[:], crc32.Checksum(p, castagnoli))
+		assert.Equal(t, base64.StdEncoding.EncodeToString(raw[:]), aws.ToString(up.ChecksumCRC32C))
+		composite.Write(raw[:])
+		completed = append(completed, s3types.CompletedPart{
+			PartNumber:     aws.Int32(int32(i + 1)),
+			ETag:           up.ETag,
+			ChecksumCRC32C: up.ChecksumCRC32C,
+		})
+	}
+
+	done, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("mpu-checksum"),
+		Key:             aws.String("obj"),
+		UploadId:        create.UploadId,
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: completed},
+	})
+	require.NoError(t, err)
+	want := base64.StdEncoding.EncodeToString(composite.Sum(nil)) + "-2"
+	assert.Equal(t, want, aws.ToString(done.ChecksumCRC32C))
+	assert.Equal(t, s3types.ChecksumTypeComposite, done.ChecksumType)
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String("mpu-checksum"),
+		Key:          aws.String("obj"),
+		ChecksumMode: s3types.ChecksumModeEnabled,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, want, aws.ToString(head.ChecksumCRC32C))
+}
+
+func TestS3Multipart_FullObjectChecksum(t *testing.T) {
+	client := createMultipartBucket(t, "mpu-full-object")
+
+	create, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String("mpu-full-object"),
+		Key:               aws.String("obj"),
+		ChecksumAlgorithm: s3types.ChecksumAlgorithmCrc32,
+		ChecksumType:      s3types.ChecksumTypeFullObject,
+	})
+	require.NoError(t, err)
+
+	parts := [][]byte{bytes.Repeat([]byte("x"), 5*mib), bytes.Repeat([]byte("y"), 1000)}
+	var completed []s3types.CompletedPart
+	whole := crc32.NewIEEE()
+	for i, p := range parts {
+		up, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:            aws.String("mpu-full-object"),
+			Key:               aws.String("obj"),
+			UploadId:          create.UploadId,
+			PartNumber:        aws.Int32(int32(i + 1)),
+			Body:              bytes.NewReader(p),
+			ChecksumAlgorithm: s3types.ChecksumAlgorithmCrc32,
+		})
+		require.NoError(t, err)
+		whole.Write(p)
+		completed = append(completed, s3types.CompletedPart{PartNumber: aws.Int32(int32(i + 1)), ETag: up.ETag})
+	}
+
+	done, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("mpu-full-object"),
+		Key:             aws.String("obj"),
+		UploadId:        create.UploadId,
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: completed},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(whole.Sum(nil)), aws.ToString(done.ChecksumCRC32))
+	assert.Equal(t, s3types.ChecksumTypeFullObject, done.ChecksumType)
+}
+
+func TestS3Multipart_ListPartsAndUploads(t *testing.T) {
+	client := createMultipartBucket(t, "mpu-list")
+
+	create, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String("mpu-list"),
+		Key:    aws.String("pending/obj"),
+	})
+	require.NoError(t, err)
+	for i := int32(1); i <= 3; i++ {
+		_, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String("mpu-list"),
+			Key:        aws.String("pending/obj"),
+			UploadId:   create.UploadId,
+			PartNumber: aws.Int32(i),
+			Body:       strings.NewReader(strings.Repeat("p", int(i))),
+		})
+		require.NoError(t, err)
+	}
+
+	page, err := client.ListParts(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String("mpu-list"),
+		Key:      aws.String("pending/obj"),
+		UploadId: create.UploadId,
+		MaxParts: aws.Int32(2),
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Parts, 2)
+	assert.True(t, aws.ToBool(page.IsTruncated))
+	assert.Equal(t, int64(2), aws.ToInt64(page.Parts[1].Size))
+
+	rest, err := client.ListParts(ctx, &s3.ListPartsInput{
+		Bucket:           aws.String("mpu-list"),
+		Key:              aws.String("pending/obj"),
+		UploadId:         create.UploadId,
+		PartNumberMarker: page.NextPartNumberMarker,
+	})
+	require.NoError(t, err)
+	require.Len(t, rest.Parts, 1)
+	assert.Equal(t, int32(3), aws.ToInt32(rest.Parts[0].PartNumber))
+
+	uploads, err := client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String("mpu-list"),
+		Prefix: aws.String("pending/"),
+	})
+	require.NoError(t, err)
+	require.Len(t, uploads.Uploads, 1)
+	assert.Equal(t, aws.ToString(create.UploadId), aws.ToString(uploads.Uploads[0].UploadId))
+
+	_, err = client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String("mpu-list"),
+		Key:      aws.String("pending/obj"),
+		UploadId: create.UploadId,
+	})
+	require.NoError(t, err)
+
+	_, err = client.ListParts(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String("mpu-list"),
+		Key:      aws.String("pending/obj"),
+		UploadId: create.UploadId,
+	})
+	requireS3ErrorCode(t, err, "NoSuchUpload")
+
+	uploads, err = client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: aws.String("mpu-list")})
+	require.NoError(t, err)
+	assert.Empty(t, uploads.Uploads)
+}
+
+func TestS3Multipart_CompleteErrors(t *testing.T) {
+	client := createMultipartBucket(t, "mpu-errors")
+
+	create, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String("mpu-errors"),
+		Key:    aws.String("obj"),
+	})
+	require.NoError(t, err)
+	etags := map[int32]*string{}
+	for i := int32(1); i <= 2; i++ {
+		up, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String("mpu-errors"),
+			Key:        aws.String("obj"),
+			UploadId:   create.UploadId,
+			PartNumber: aws.Int32(i),
+			Body:       strings.NewReader("small"),
+		})
+		require.NoError(t, err)
+		etags[i] = up.ETag
+	}
+
+	complete := func(parts ...s3types.CompletedPart) error {
+		_, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String("mpu-errors"),
+			Key:             aws.String("obj"),
+			UploadId:        create.UploadId,
+			MultipartUpload: &s3types.CompletedMultipartUpload{Parts: parts},
+		})
+		return err
+	}
+	part := func(n int32, etag *string) s3types.CompletedPart {
+		return s3types.CompletedPart{PartNumber: aws.Int32(n), ETag: etag}
+	}
+
+	requireS3ErrorCode(t, complete(part(2, etags[2]), part(1, etags[1])), "InvalidPartOrder")
+	requireS3ErrorCode(t, complete(part(1, aws.String("\"deadbeef\""))), "InvalidPart")
+	requireS3ErrorCode(t, complete(part(1, etags[1]), part(3, etags[2])), "InvalidPart")
+	requireS3ErrorCode(t, complete(part(1, etags[1]), part(2, etags[2])), "EntityTooSmall")
+
+	_, err = client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String("mpu-errors"),
+		Key:        aws.String("obj"),
+		UploadId:   create.UploadId,
+		PartNumber: aws.Int32(10001),
+		Body:       strings.NewReader("x"),
+	})
+	requireS3ErrorCode(t, err, "InvalidArgument")
+
+	// A single undersized part is a valid (last) part.
+	require.NoError(t, complete(part(1, etags[1])))
+	requireS3ErrorCode(t, complete(part(1, etags[1])), "NoSuchUpload")
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("mpu-errors"), Key: aws.String("obj")})
+	require.NoError(t, err)
+	defer get.Body.Close()
+	body, err := io.ReadAll(get.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "small", string(body))
+}
+
+// TestS3Multipart_MultiGigabyteStream pushes a 3 GiB stream through the
+// upload manager and reads it back, comparing digests on both sides.
+func TestS3Multipart_MultiGigabyteStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("multi-GB upload skipped in -short mode")
+	}
+	client := createMultipartBucket(t, "mpu-large")
+	const size = 3<<30 + 7
+
+	sent := sha256.New()
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = 64 * mib
+		u.Concurrency = 4
+	})
+	_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("mpu-large"),
+		Key:    aws.String("big.bin"),
+		Body:   io.TeeReader(newPatternReader(7, size), sent),
+	})
+	require.NoError(t, err)
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("mpu-large"), Key: aws.String("big.bin")})
+	require.NoError(t, err)
+	defer get.Body.Close()
+	assert.Equal(t, int64(size), aws.ToInt64(get.ContentLength))
+	assert.True(t, strings.HasSuffix(aws.ToString(get.ETag), fmt.Sprintf("-%d\"", (size+64*mib-1)/(64*mib))), "ETag %s", aws.ToString(get.ETag))
+	received := sha256.New()
+	n, err := io.Copy(received, get.Body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), n)
+	assert.Equal(t, sent.Sum(nil), received.Sum(nil))
+
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("mpu-large"), Key: aws.String("big.bin")})
+	require.NoError(t, err)
+}
@@ -0,0 +1,802 @@
+package simulator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault injection. Integration tests use it to make an otherwise
+// deterministic simulator throttle, slow down, drop connections or
+// serve stale reads for selected requests, and then assert that the
+// backend under test retries (or fails loud) the way it must against
+// the real cloud.
+//
+// Faults are configured at startup from SIM_FAULTS / SIM_FAULTS_FILE
+// and at runtime through the /sim/v1/faults control API. Each fault
+// selects requests by service, action, resource path and method glob;
+// probability, count and after limit how many of the selected
+// requests it fires on.
+
+// FaultKind is what a fault does to a request it fires on.
+type FaultKind string
+
+const (
+	// FaultError answers with the provider's throttling error (or the
+	// status / code / message the fault overrides).
+	FaultError FaultKind = "error"
+	// FaultLatency delays the request before it is handled.
+	FaultLatency FaultKind = "latency"
+	// FaultDrop closes the connection without a response.
+	FaultDrop FaultKind = "drop"
+	// FaultStale replays the last successful response to the same
+	// request instead of reading current state.
+	FaultStale FaultKind = "stale"
+)
+
+// maxFaultBody caps how much of a request or response body is buffered
+// for action and stale-read matching. Larger payloads are never
+// replayed.
+const maxFaultBody = 1 << 20
+
+// Fault is one injection rule. Empty selectors match every request.
+// Selectors are globs where `*` matches any run of characters
+// (including `/`) and `?` matches one; service, action and method
+// compare case-insensitively, resource (the URL path) exactly.
+type Fault struct {
+	ID string `json:"id"`
+
+	Service  string `json:"service,omitempty"`
+	Action   string `json:"action,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	Method   string `json:"method,omitempty"`
+
+	Kind FaultKind `json:"kind"`
+
+	// Error overrides. Zero values use the provider default: AWS
+	// ThrottlingException (SlowDown for S3, RequestLimitExceeded for
+	// EC2, Throttling for IAM/STS), GCP 429 RESOURCE_EXHAUSTED, Azure
+	// 429 TooManyRequests (503 ServerBusy on the storage data planes).
+	Status  int    `json:"status,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// RetryAfter is the Retry-After header in seconds. Azure errors
+	// default to 1; other providers only send it when set.
+	RetryAfter int `json:"retryAfter,omitempty"`
+
+	// Latency is the delay for latency faults, as a Go duration.
+	Latency string `json:"latency,omitempty"`
+
+	// Probability of firing on a selected request; 0 means always.
+	Probability float64 `json:"probability,omitempty"`
+	// Count caps how many times the fault fires; 0 means unlimited.
+	Count int `json:"count,omitempty"`
+	// After lets the first N selected requests through untouched.
+	After int `json:"after,omitempty"`
+
+	// Matched and Hits are maintained by the simulator: requests the
+	// selectors matched, and requests the fault actually fired on.
+	Matched int `json:"matched"`
+	Hits    int `json:"hits"`
+
+	latency time.Duration
+}
+
+// validate checks a fault from the control API or startup config and
+// resolves its latency.
+func (f *Fault) validate() error {
+	switch f.Kind {
+	case FaultError, FaultDrop, FaultStale:
+	case FaultLatency:
+		if f.Latency == "" {
+			return errors.New("latency fault requires latency")
+		}
+	case "":
+		return errors.New("kind is required (error, latency, drop or stale)")
+	default:
+		return fmt.Errorf("unknown fault kind %q", f.Kind)
+	}
+	if f.Latency != "" {
+		d, err := time.ParseDuration(f.Latency)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid latency %q", f.Latency)
+		}
+		f.latency = d
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("probability %v outside [0, 1]", f.Probability)
+	}
+	if f.Count < 0 || f.After < 0 || f.RetryAfter < 0 {
+		return errors.New("count, after and retryAfter must not be negative")
+	}
+	if f.Status != 0 && (f.Status < 400 || f.Status > 599) {
+		return fmt.Errorf("status %d is not an error status", f.Status)
+	}
+	for _, p := range []string{f.Service, f.Action, f.Resource, f.Method} {
+		if strings.Contains(p, "**") {
+			return fmt.Errorf("invalid pattern %q: use a single *", p)
+		}
+	}
+	return nil
+}
+
+// Faults is the simulator's set of active faults plus the responses
+// recorded for stale reads.
+type Faults struct {
+	provider string
+
+	mu        sync.Mutex
+	faults    []*Fault
+	nextID    int
+	snapshots map[string]faultSnapshot
+}
+
+// faultSnapshot is a recorded successful response for stale reads.
+type faultSnapshot struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewFaults returns an empty fault set for the given provider.
+func NewFaults(provider string) *Faults {
+	return &Faults{provider: provider, snapshots: make(map[string]faultSnapshot)}
+}
+
+// FaultsFromEnv builds the startup fault set from SIM_FAULTS (a JSON
+// array of faults) or SIM_FAULTS_FILE (a file holding one). Setting
+// both, or a malformed fault, is an error.
+func FaultsFromEnv(provider string) (*Faults, error) {
+	fs := NewFaults(provider)
+	raw := os.Getenv("SIM_FAULTS")
+	if file := os.Getenv("SIM_FAULTS_FILE"); file != "" {
+		if raw != "" {
+			return nil, errors.New("SIM_FAULTS and SIM_FAULTS_FILE are mutually exclusive")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read SIM_FAULTS_FILE: %w", err)
+		}
+		raw = string(data)
+	}
+	if strings.TrimSpace(raw) == "" {
+		return fs, nil
+	}
+	var list []Fault
+	if err := json.Unmarshal([]byte(raw), &list); err != nil {
+		return nil, fmt.Errorf("parse faults: %w", err)
+	}
+	if err := fs.Replace(list); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// Add validates and installs a fault, assigning an ID when it has
+// none. Returns the installed fault.
+func (fs *Faults) Add(f Fault) (Fault, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.addLocked(&f); err != nil {
+		return Fault{}, err
+	}
+	return f, nil
+}
+
+// Replace swaps the whole fault set for list, atomically: on error
+// the previous set stays in place.
+func (fs *Faults) Replace(list []Fault) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	prev, prevID := fs.faults, fs.nextID
+	fs.faults = nil
+	for i := range list {
+		if err := fs.addLocked(&list[i]); err != nil {
+			fs.faults, fs.nextID = prev, prevID
+			return fmt.Errorf("fault %d: %w", i, err)
+		}
+	}
+	fs.snapshots = make(map[string]faultSnapshot)
+	return nil
+}
+
+func (fs *Faults) addLocked(f *Fault) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+	if f.ID == "" {
+		fs.nextID++
+		f.ID = "fault-" + strconv.Itoa(fs.nextID)
+	}
+	for _, existing := range fs.faults {
+		if existing.ID == f.ID {
+			return fmt.Errorf("fault %q already exists", f.ID)
+		}
+	}
+	f.Matched, f.Hits = 0, 0
+	stored := *f
+	fs.faults = append(fs.faults, &stored)
+	return nil
+}
+
+// List returns a copy of the installed faults in evaluation order.
+func (fs *Faults) List() []Fault {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	out := make([]Fault, 0, len(fs.faults))
+	for _, f := range fs.faults {
+		out = append(out, *f)
+	}
+	return out
+}
+
+// Get returns the fault with the given ID.
+func (fs *Faults) Get(id string) (Fault, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, f := range fs.faults {
+		if f.ID == id {
+			return *f, true
+		}
+	}
+	return Fault{}, false
+}
+
+// Remove deletes the fault with the given ID.
+func (fs *Faults) Remove(id string) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for i, f := range fs.faults {
+		if f.ID == id {
+			fs.faults = append(fs.faults[:i], fs.faults[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Reset removes every fault and forgets recorded stale responses.
+func (fs *Faults) Reset() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.faults = nil
+	fs.snapshots = make(map[string]faultSnapshot)
+}
+
+// faultTarget is what fault selectors are matched against.
+type faultTarget struct {
+	service  string
+	action   string
+	resource string
+	method   string
+}
+
+func (f *Fault) selects(t faultTarget) bool {
+	return faultGlob(strings.ToLower(f.Service), strings.ToLower(t.service)) &&
+		faultGlob(strings.ToLower(f.Action), strings.ToLower(t.action)) &&
+		faultGlob(f.Resource, t.resource) &&
+		faultGlob(strings.ToUpper(f.Method), t.method)
+}
+
+// faultGlob matches s against a pattern where `*` is any run of
+// characters and `?` any single one. The empty pattern matches all.
+func faultGlob(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	p, i := 0, 0
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// faultDecision is the outcome of evaluating the fault set for one
+// request: a total delay, at most one terminal fault, and whether the
+// response should be recorded for later stale reads.
+type faultDecision struct {
+	delay    time.Duration
+	terminal *Fault
+	snapshot *faultSnapshot
+	record   bool
+}
+
+// decide evaluates faults in order. Latency faults accumulate; the
+// first error, drop or stale fault that fires ends evaluation.
+func (fs *Faults) decide(t faultTarget, key string) faultDecision {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	var d faultDecision
+	for _, f := range fs.faults {
+		if !f.selects(t) {
+			continue
+		}
+		if f.Kind == FaultStale && key == "" {
+			continue // body too large to key
+		}
+		f.Matched++
+		if f.Matched <= f.After || (f.Count > 0 && f.Hits >= f.Count) {
+			if f.Kind == FaultStale {
+				d.record = true
+			}
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() >= f.Probability {
+			if f.Kind == FaultStale {
+				d.record = true
+			}
+			continue
+		}
+		switch f.Kind {
+		case FaultLatency:
+			f.Hits++
+			d.delay += f.latency
+			continue
+		case FaultStale:
+			snap, ok := fs.snapshots[key]
+			if !ok {
+				// Nothing read yet — let this one through and record it.
+				d.record = true
+				continue
+			}
+			f.Hits++
+			d.snapshot = &snap
+		default:
+			f.Hits++
+		}
+		fault := *f
+		d.terminal = &fault
+		return d
+	}
+	return d
+}
+
+// hasStale reports whether any stale fault selects the request, so
+// the middleware only buffers bodies when it has to.
+func (fs *Faults) hasStale(t faultTarget) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, f := range fs.faults {
+		if f.Kind == FaultStale && f.selects(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *Faults) empty() bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.faults) == 0
+}
+
+// Middleware applies the fault set to every cloud API request. The
+// simulator's own endpoints (/sim/, /health, /ui/) are never faulted.
+func (fs *Faults) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fs.empty() || isSimulatorPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t := classifyFaultTarget(fs.provider, r)
+		var key string
+		if fs.hasStale(t) {
+			key = staleKey(r)
+		}
+		d := fs.decide(t, key)
+
+		if d.delay > 0 {
+			timer := time.NewTimer(d.delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		if d.terminal != nil {
+			switch d.terminal.Kind {
+			case FaultDrop:
+				dropConnection(w)
+			case FaultStale:
+				writeSnapshot(w, d.snapshot)
+			default:
+				writeFaultError(w, r, fs.provider, t, d.terminal)
+			}
+			return
+		}
+
+		if !d.record {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status >= 200 && rec.status < 300 && !rec.overflow {
+			fs.mu.Lock()
+			fs.snapshots[key] = faultSnapshot{status: rec.status, header: rec.Header().Clone(), body: rec.buf.Bytes()}
+			fs.mu.Unlock()
+		}
+	})
+}
+
+func isSimulatorPath(p string) bool {
+	return strings.HasPrefix(p, "/sim/") || p == "/health" || strings.HasPrefix(p, "/ui/")
+}
+
+// peekBody reads up to maxFaultBody bytes of the request body and puts
+// them back so the handler still sees the full payload. ok is false
+// when the body is larger than the cap.
+func peekBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > maxFaultBody {
+		return nil, false
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxFaultBody+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil || len(buf) > maxFaultBody {
+		return nil, false
+	}
+	return buf, true
+}
+
+// staleKey identifies "the same read": method, host, URI, JSON target
+// and body. Empty when the body is too large to key.
+func staleKey(r *http.Request) string {
+	body, ok := peekBody(r)
+	if !ok {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", r.Method, r.Host, r.URL.RequestURI(), r.Header.Get("X-Amz-Target"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// classifyFaultTarget derives the service and action a request
+// addresses, in each provider's own vocabulary:
+//
+//   - aws: the SigV4 credential-scope service (ecs, lambda, s3, ...)
+//     and the X-Amz-Target operation or Query protocol Action.
+//   - gcp: the API prefix for storage/compute-style paths, otherwise
+//     the resource collection (services, jobs, topics, ...), and the
+//     custom method after `:` (run, publish, ...).
+//   - azure: the ARM provider namespace (Microsoft.App, ...) and the
+//     trailing POST action, or for storage data-plane hosts the
+//     service (blob, queue, file) and the comp parameter.
+func classifyFaultTarget(provider string, r *http.Request) faultTarget {
+	t := faultTarget{resource: r.URL.Path, method: r.Method}
+	switch provider {
+	case "aws":
+		t.service, t.action = classifyAWS(r)
+	case "gcp":
+		t.service, t.action = classifyGCP(r.URL.Path)
+	case "azure":
+		t.service, t.action = classifyAzure(r)
+	}
+	return t
+}
+
+func classifyAWS(r *http.Request) (service, action string) {
+	cred := r.URL.Query().Get("X-Amz-Credential")
+	if auth := r.Header.Get("Authorization"); cred == "" && strings.Contains(auth, "Credential=") {
+		cred = auth[strings.Index(auth, "Credential=")+len("Credential="):]
+		cred, _, _ = strings.Cut(cred, ",")
+	}
+	// AKID/date/region/service/aws4_request
+	if parts := strings.Split(cred, "/"); len(parts) >= 5 {
+		service = parts[3]
+	} else if seg, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/"); seg != "" {
+		service = seg
+	}
+
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		return service, target[strings.LastIndex(target, ".")+1:]
+	}
+	if a := r.URL.Query().Get("Action"); a != "" {
+		return service, a
+	}
+	if r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if body, ok := peekBody(r); ok {
+			if form, err := url.ParseQuery(string(body)); err == nil {
+				action = form.Get("Action")
+			}
+		}
+	}
+	return service, action
+}
+
+func classifyGCP(p string) (service, action string) {
+	if i := strings.LastIndex(p, ":"); i > strings.LastIndex(p, "/") {
+		p, action = p[:i], p[i+1:]
+	}
+	segs := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segs) == 0 {
+		return "", action
+	}
+	if segs[0] == "upload" || segs[0] == "download" {
+		segs = segs[1:]
+	}
+	if len(segs) > 0 && !isGCPVersion(segs[0]) {
+		return segs[0], action
+	}
+	// /v1/projects/{p}[/locations/{l}]/{collection}/...
+	for i := 1; i+2 < len(segs); i += 2 {
+		if segs[i] != "projects" && segs[i] != "locations" {
+			return segs[i], action
+		}
+		if segs[i+2] != "locations" {
+			return segs[i+2], action
+		}
+	}
+	if len(segs) > 1 {
+		return segs[1], action
+	}
+	return "", action
+}
+
+func isGCPVersion(s string) bool {
+	return len(s) > 1 && s[0] == 'v' && s[1] >= '0' && s[1] <= '9'
+}
+
+func classifyAzure(r *http.Request) (service, action string) {
+	if _, svc, ok := storageDataPlaneHost(r.Host); ok {
+		return svc, r.URL.Query().Get("comp")
+	}
+	segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for i, s := range segs {
+		if !strings.EqualFold(s, "providers") || i+1 >= len(segs) {
+			continue
+		}
+		service = segs[i+1]
+		// type/name pairs follow the namespace; an odd trailing
+		// segment on a POST is an action (start, stop, listKeys, ...).
+		if rest := segs[i+2:]; r.Method == http.MethodPost && len(rest)%2 == 1 && len(rest) > 1 {
+			action = rest[len(rest)-1]
+		}
+	}
+	return service, action
+}
+
+// storageDataPlaneHost splits a {account}.{service}.localhost host.
+func storageDataPlaneHost(host string) (account, service string, ok bool) {
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	prefix, found := strings.CutSuffix(host, ".localhost")
+	if !found {
+		return "", "", false
+	}
+	return strings.Cut(prefix, ".")
+}
+
+// writeFaultError answers with the provider's throttling error, using
+// the wire format the addressed API speaks so SDK retryers classify it
+// exactly as they would the real one.
+func writeFaultError(w http.ResponseWriter, r *http.Request, provider string, t faultTarget, f *Fault) {
+	retryAfter := f.RetryAfter
+	if provider == "azure" && retryAfter == 0 {
+		retryAfter = 1
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	pick := func(v, def string) string {
+		if v != "" {
+			return v
+		}
+		return def
+	}
+	status := func(def int) int {
+		if f.Status != 0 {
+			return f.Status
+		}
+		return def
+	}
+
+	switch provider {
+	case "aws":
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/s3/"):
+			S3ErrorXML(w, pick(f.Code, "SlowDown"), pick(f.Message, "Please reduce your request rate."), r.URL.Path, RequestID(r.Context()), status(http.StatusServiceUnavailable))
+		case r.Header.Get("X-Amz-Target") == "" && t.action != "" && t.service == "ec2":
+			EC2ErrorXML(w, pick(f.Code, "RequestLimitExceeded"), pick(f.Message, "Request limit exceeded."), RequestID(r.Context()), status(http.StatusServiceUnavailable))
+		case r.Header.Get("X-Amz-Target") == "" && t.action != "":
+			// IAM, STS and the other Query protocol services.
+			w.Header().Set("Content-Type", "text/xml")
+			w.WriteHeader(status(http.StatusBadRequest))
+			fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>%s</RequestId></ErrorResponse>`,
+				pick(f.Code, "Throttling"), pick(f.Message, "Rate exceeded"), RequestID(r.Context()))
+		default:
+			code := pick(f.Code, "ThrottlingException")
+			w.Header().Set("X-Amzn-ErrorType", code)
+			AWSError(w, code, pick(f.Message, "Rate exceeded"), status(http.StatusBadRequest))
+		}
+	case "gcp":
+		code := status(http.StatusTooManyRequests)
+		GCPError(w, code, pick(f.Message, "Quota exceeded for quota metric 'Requests' and limit 'Requests per minute'"), pick(f.Code, gcpStatusName(code)))
+	case "azure":
+		if _, _, ok := storageDataPlaneHost(r.Host); ok {
+			code := pick(f.Code, "ServerBusy")
+			w.Header().Set("Content-Type", "application/xml")
+			w.Header().Set("x-ms-error-code", code)
+			w.WriteHeader(status(http.StatusServiceUnavailable))
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`,
+				code, pick(f.Message, "The server is busy."))
+			return
+		}
+		AzureError(w, pick(f.Code, "TooManyRequests"), pick(f.Message, "The request is being throttled."), status(http.StatusTooManyRequests))
+	default:
+		http.Error(w, pick(f.Message, "injected fault"), status(http.StatusServiceUnavailable))
+	}
+}
+
+// gcpStatusName maps an HTTP status to the canonical google.rpc code
+// name GCP puts in error.status.
+func gcpStatusName(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ABORTED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusNotImplemented:
+		return "UNIMPLEMENTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	}
+	return "INTERNAL"
+}
+
+// dropConnection closes the client connection without writing a
+// response, the way a load balancer reset looks to an SDK.
+func dropConnection(w http.ResponseWriter) {
+	if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+		_ = conn.Close()
+		return
+	}
+	// HTTP/2 and other non-hijackable writers: abort the stream.
+	panic(http.ErrAbortHandler)
+}
+
+func writeSnapshot(w http.ResponseWriter, s *faultSnapshot) {
+	for k, v := range s.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(s.status)
+	_, _ = w.Write(s.body)
+}
+
+// recordingWriter tees a response into a buffer for stale reads.
+type recordingWriter struct {
+	http.ResponseWriter
+	status   int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if !w.overflow {
+		if w.buf.Len()+len(p) > maxFaultBody {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// registerFaultAPI mounts the /sim/v1/faults control API:
+//
+//	GET    /sim/v1/faults        list faults with their counters
+//	POST   /sim/v1/faults        add one fault
+//	PUT    /sim/v1/faults        replace the whole set
+//	DELETE /sim/v1/faults        remove all faults
+//	GET    /sim/v1/faults/{id}   one fault
+//	DELETE /sim/v1/faults/{id}   remove one fault
+func registerFaultAPI(mux *http.ServeMux, fs *Faults) {
+	badRequest := func(w http.ResponseWriter, err error) {
+		WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	mux.HandleFunc("GET /sim/v1/faults", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]any{"faults": fs.List()})
+	})
+	mux.HandleFunc("POST /sim/v1/faults", func(w http.ResponseWriter, r *http.Request) {
+		var f Fault
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			badRequest(w, fmt.Errorf("parse fault: %w", err))
+			return
+		}
+		added, err := fs.Add(f)
+		if err != nil {
+			badRequest(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusCreated, added)
+	})
+	mux.HandleFunc("PUT /sim/v1/faults", func(w http.ResponseWriter, r *http.Request) {
+		var list []Fault
+		if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+			badRequest(w, fmt.Errorf("parse faults: %w", err))
+			return
+		}
+		if err := fs.Replace(list); err != nil {
+			badRequest(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{"faults": fs.List()})
+	})
+	mux.HandleFunc("DELETE /sim/v1/faults", func(w http.ResponseWriter, r *http.Request) {
+		fs.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /sim/v1/faults/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := fs.Get(r.PathValue("id"))
+		if !ok {
+			WriteJSON(w, http.StatusNotFound, map[string]string{"error": "fault not found"})
+			return
+		}
+		WriteJSON(w, http.StatusOK, f)
+	})
+	mux.HandleFunc("DELETE /sim/v1/faults/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !fs.Remove(r.PathValue("id")) {
+			WriteJSON(w, http.StatusNotFound, map[string]string{"error": "fault not found"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
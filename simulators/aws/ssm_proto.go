@@ -44,6 +44,9 @@ const (
 	ssmPayloadStdout   = uint32(1)
 	ssmPayloadStderr   = uint32(11)
 	ssmPayloadExitCode = uint32(12)
+	// ssmPayloadSize is the inbound input_stream_data PayloadType the
+	// session-manager-plugin sends on SIGWINCH: `{"cols":W,"rows":H}`.
+	ssmPayloadSize = uint32(3)
 )
 
 // outputSequence is a monotonic per-process counter applied to every
@@ -107,6 +110,12 @@ func decodeSSMInputFrame(b []byte) (payload []byte, messageType string, fin bool
 	return b[ssmFixedHeaderLen : ssmFixedHeaderLen+int(payloadLen)], mt, (flags & 2) != 0, nil
 }
 
+// ssmInputPayloadType returns an inbound frame's PayloadType; call it
+// only on frames decodeSSMInputFrame accepted.
+func ssmInputPayloadType(b []byte) uint32 {
+	return binary.BigEndian.Uint32(b[112:116])
+}
+
 // buildSSMChannelClosed builds the channel_closed frame that tells the
 // backend decoder to terminate cleanly. Payload can be empty.
 func buildSSMChannelClosed() []byte {
@@ -24,6 +24,8 @@ import (
 //   - KMS: GetKeyPolicy, PutKeyPolicy, ListResourceTags, GetKeyRotationStatus
 //   - Secrets Manager: GetResourcePolicy
 //   - SSM: AddTagsToResource, RemoveTagsFromResource, ListTagsForResource
+//   - ECS: PutClusterCapacityProviders, CreateService, DescribeServices,
+//     UpdateService, DeleteService
 //
 // What this proves end-to-end:
 //   - WAFv2 association resource_arn == CloudFront distribution ARN
@@ -108,6 +110,11 @@ func TestStackProductionShape(t *testing.T) {
 	require.Contains(t, ssmARN, ":parameter/tf-test/runner/config",
 		"SSM Parameter ARN must include :parameter<leading-slash><name>; got %s", ssmARN)
 
+	ecsServiceARN := outputs.must(t, "ecs_service_arn")
+	require.Contains(t, ecsServiceARN, ":service/tf-test-cluster/tf-test-svc",
+		"ECS service ARN must be the long service/<cluster>/<name> form; got %s", ecsServiceARN)
+	require.Equal(t, "FARGATE_SPOT", outputs.must(t, "ecs_service_capacity_provider"))
+
 	destroy := terraformCmd("destroy", "-auto-approve")
 	out, err = destroy.CombinedOutput()
 	require.NoError(t, err, "terraform destroy failed:\n%s", out)
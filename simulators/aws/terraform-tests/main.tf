@@ -45,6 +45,53 @@ resource "aws_ecs_cluster" "main" {
   name = "tf-test-cluster"
 }
 
+# Fargate capacity providers + a long-running service placed on Spot.
+# Exercises PutClusterCapacityProviders and the service scheduler
+# (CreateService / DescribeServices / UpdateService to 0 /
+# DeleteService → INACTIVE on destroy).
+resource "aws_ecs_cluster_capacity_providers" "main" {
+  cluster_name       = aws_ecs_cluster.main.name
+  capacity_providers = ["FARGATE", "FARGATE_SPOT"]
+
+  default_capacity_provider_strategy {
+    capacity_provider = "FARGATE"
+    base              = 1
+    weight            = 1
+  }
+}
+
+resource "aws_ecs_task_definition" "tf_svc" {
+  family                   = "tf-test-svc"
+  requires_compatibilities = ["FARGATE"]
+  network_mode             = "awsvpc"
+  cpu                      = "256"
+  memory                   = "512"
+  container_definitions = jsonencode([{
+    name      = "app"
+    image     = "alpine:latest"
+    command   = ["tail", "-f", "/dev/null"]
+    essential = true
+  }])
+}
+
+resource "aws_ecs_service" "tf_svc" {
+  name            = "tf-test-svc"
+  cluster         = aws_ecs_cluster.main.id
+  task_definition = aws_ecs_task_definition.tf_svc.arn
+  desired_count   = 1
+
+  capacity_provider_strategy {
+    capacity_provider = "FARGATE_SPOT"
+    weight            = 1
+  }
+
+  network_configuration {
+    subnets = ["subnet-0123456789abcdef0"]
+  }
+
+  depends_on = [aws_ecs_cluster_capacity_providers.main]
+}
+
 # Exercise the pull-through-cache APIs added to the simulator in
 # BUG-696's fix. Terraform's aws_ecr_pull_through_cache_rule resource
 # wraps the same CreatePullThroughCacheRule / DescribePullThroughCacheRules
@@ -470,3 +517,9 @@ output "secretsmanager_secret_arn" {
 output "ssm_parameter_arn" {
   value = aws_ssm_parameter.tf_param.arn
 }
+output "ecs_service_arn" {
+  value = aws_ecs_service.tf_svc.id
+}
+output "ecs_service_capacity_provider" {
+  value = one(aws_ecs_service.tf_svc.capacity_provider_strategy).capacity_provider
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	sim "github.com/sockerless/simulator"
 )
@@ -80,6 +81,25 @@ type ACRCacheRuleProperties struct {
 	ProvisioningState       string `json:"provisioningState,omitempty"`
 }
 
+// ACRCredentialSet models an ACR credential set: the upstream login a
+// cache rule authenticates with, held as Key Vault secret URIs the
+// set's system-assigned identity reads.
+type ACRCredentialSet struct {
+	ID         string                     `json:"id,omitempty"`
+	Name       string                     `json:"name"`
+	Type       string                     `json:"type,omitempty"`
+	Identity   map[string]any             `json:"identity,omitempty"`
+	Properties ACRCredentialSetProperties `json:"properties"`
+}
+
+// ACRCredentialSetProperties mirrors armcontainerregistry.CredentialSetProperties.
+type ACRCredentialSetProperties struct {
+	LoginServer       string `json:"loginServer,omitempty"`
+	AuthCredentials   []any  `json:"authCredentials,omitempty"`
+	CreationDate      string `json:"creationDate,omitempty"`
+	ProvisioningState string `json:"provisioningState,omitempty"`
+}
+
 // Package-level store for dashboard access.
 var acrRegistries sim.Store[Registry]
 
@@ -88,12 +108,15 @@ func registerACR(srv *sim.Server) {
 	acrRegistries = registries
 	// manifests stores manifests keyed by "repo:reference" (tag or digest)
 	manifests := sim.MakeStore[OCIManifest](srv.DB(), "acr_manifests")
+	acrReferrers = sim.MakeStore[[]OCIReferrer](srv.DB(), "acr_referrers")
 	// blobs stores blobs keyed by "repo@digest"
 	blobs := sim.MakeStore[BlobData](srv.DB(), "acr_blobs")
 	// uploads stores in-progress uploads keyed by uuid
 	uploads := sim.MakeStore[BlobUpload](srv.DB(), "acr_uploads")
 	// cacheRules stores pull-through cache rules keyed by ARM resource ID.
 	cacheRules := sim.MakeStore[ACRCacheRule](srv.DB(), "acr_cache_rules")
+	// credentialSets stores cache rule credential sets keyed by ARM resource ID.
+	credentialSets := sim.MakeStore[ACRCredentialSet](srv.DB(), "acr_credential_sets")
 
 	const armBase = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.ContainerRegistry"
 
@@ -245,6 +268,14 @@ func registerACR(srv *sim.Server) {
 				return
 			}
 
+			if id := req.Properties.CredentialSetResourceID; id != "" {
+				if _, ok := credentialSets.Get(id); !ok {
+					sim.AzureErrorf(w, "ResourceNotFound", http.StatusNotFound,
+						"Credential set '%s' was not found.", id)
+					return
+				}
+			}
+
 			ruleID := fmt.Sprintf("%s/cacheRules/%s", regID, ruleName)
 			rule := ACRCacheRule{
 				ID:   ruleID,
@@ -316,6 +347,117 @@ func registerACR(srv *sim.Server) {
 			w.WriteHeader(http.StatusNoContent)
 		})
 
+	// --- Credential Sets ---
+	//
+	// Matches armcontainerregistry.CredentialSetsClient endpoints:
+	//   registries/{registry}/credentialSets[/{credentialSet}]
+	// Same sync LRO collapse as the cache rules above. A system-assigned
+	// identity gets a fresh principal ID on create, like real ACR.
+
+	srv.HandleFunc("PUT "+armBase+"/registries/{registryName}/credentialSets/{credentialSetName}",
+		func(w http.ResponseWriter, r *http.Request) {
+			sub := sim.PathParam(r, "subscriptionId")
+			rg := sim.PathParam(r, "resourceGroupName")
+			regName := sim.PathParam(r, "registryName")
+			setName := sim.PathParam(r, "credentialSetName")
+
+			regID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerRegistry/registries/%s",
+				sub, rg, regName)
+			if _, ok := registries.Get(regID); !ok {
+				sim.AzureErrorf(w, "ResourceNotFound", http.StatusNotFound,
+					"Registry '%s' under resource group '%s' was not found.", regName, rg)
+				return
+			}
+
+			var req ACRCredentialSet
+			if err := sim.ReadJSON(r, &req); err != nil {
+				sim.AzureError(w, "InvalidRequestContent",
+					"Failed to parse request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.Properties.LoginServer == "" || len(req.Properties.AuthCredentials) == 0 {
+				sim.AzureError(w, "InvalidRequestContent",
+					"properties.loginServer and properties.authCredentials are required",
+					http.StatusBadRequest)
+				return
+			}
+
+			setID := fmt.Sprintf("%s/credentialSets/%s", regID, setName)
+			identity := req.Identity
+			if identity != nil && identity["type"] == "SystemAssigned" {
+				identity = map[string]any{
+					"type":        "SystemAssigned",
+					"principalId": generateUUID(),
+					"tenantId":    generateUUID(),
+				}
+			}
+			set := ACRCredentialSet{
+				ID:       setID,
+				Name:     setName,
+				Type:     "Microsoft.ContainerRegistry/registries/credentialSets",
+				Identity: identity,
+				Properties: ACRCredentialSetProperties{
+					LoginServer:       req.Properties.LoginServer,
+					AuthCredentials:   req.Properties.AuthCredentials,
+					CreationDate:      time.Now().UTC().Format(time.RFC3339),
+					ProvisioningState: "Succeeded",
+				},
+			}
+			credentialSets.Put(setID, set)
+
+			sim.WriteJSON(w, http.StatusOK, set)
+		})
+
+	srv.HandleFunc("GET "+armBase+"/registries/{registryName}/credentialSets/{credentialSetName}",
+		func(w http.ResponseWriter, r *http.Request) {
+			setID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerRegistry/registries/%s/credentialSets/%s",
+				sim.PathParam(r, "subscriptionId"), sim.PathParam(r, "resourceGroupName"),
+				sim.PathParam(r, "registryName"), sim.PathParam(r, "credentialSetName"))
+			set, ok := credentialSets.Get(setID)
+			if !ok {
+				sim.AzureErrorf(w, "ResourceNotFound", http.StatusNotFound,
+					"Credential set '%s' under registry '%s' was not found.",
+					sim.PathParam(r, "credentialSetName"), sim.PathParam(r, "registryName"))
+				return
+			}
+			sim.WriteJSON(w, http.StatusOK, set)
+		})
+
+	srv.HandleFunc("GET "+armBase+"/registries/{registryName}/credentialSets",
+		func(w http.ResponseWriter, r *http.Request) {
+			regPrefix := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerRegistry/registries/%s/credentialSets/",
+				sim.PathParam(r, "subscriptionId"), sim.PathParam(r, "resourceGroupName"), sim.PathParam(r, "registryName"))
+			matched := credentialSets.Filter(func(cs ACRCredentialSet) bool {
+				return strings.HasPrefix(cs.ID, regPrefix)
+			})
+			if matched == nil {
+				matched = []ACRCredentialSet{}
+			}
+			sim.WriteJSON(w, http.StatusOK, map[string]any{
+				"value": matched,
+			})
+		})
+
+	// DELETE credential set. Real ACR refuses while a cache rule still
+	// references the set.
+	srv.HandleFunc("DELETE "+armBase+"/registries/{registryName}/credentialSets/{credentialSetName}",
+		func(w http.ResponseWriter, r *http.Request) {
+			setID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerRegistry/registries/%s/credentialSets/%s",
+				sim.PathParam(r, "subscriptionId"), sim.PathParam(r, "resourceGroupName"),
+				sim.PathParam(r, "registryName"), sim.PathParam(r, "credentialSetName"))
+			inUse := cacheRules.Filter(func(cr ACRCacheRule) bool {
+				return strings.EqualFold(cr.Properties.CredentialSetResourceID, setID)
+			})
+			if len(inUse) > 0 {
+				sim.AzureErrorf(w, "CredentialSetInUse", http.StatusConflict,
+					"Credential set '%s' is used by cache rule '%s'.",
+					sim.PathParam(r, "credentialSetName"), inUse[0].Name)
+				return
+			}
+			credentialSets.Delete(setID)
+			w.WriteHeader(http.StatusNoContent)
+		})
+
 	// --- OCI Distribution API ---
 
 	// GET /v2/ and GET /v2/{path...} - OCI Distribution API
@@ -328,6 +470,10 @@ func registerACR(srv *sim.Server) {
 			sim.WriteJSON(w, http.StatusOK, map[string]any{})
 			return
 		}
+		if repo, digest, ok := parseReferrersPath(fullPath); ok {
+			handleOCIReferrers(w, r, repo, digest)
+			return
+		}
 		if repo, ref, ok := parseManifestPath(fullPath); ok {
 			key := repo + ":" + ref
 			manifest, found := manifests.Get(key)
@@ -471,6 +617,9 @@ func registerACR(srv *sim.Server) {
 			manifests.Put(repo+":"+ref, manifest)
 			// Also store by digest
 			manifests.Put(repo+":"+digest, manifest)
+			if subject := recordOCIReferrer(repo, manifest); subject != "" {
+				w.Header().Set("OCI-Subject", subject)
+			}
 
 			w.Header().Set("Docker-Content-Digest", digest)
 			w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", repo, digest))
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// OCIReferrer is one entry of a manifest's referrers index: a pushed
+// manifest whose subject names it (signatures, attestations, SBOMs).
+type OCIReferrer struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int               `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// acrReferrers indexes referrers by {repo}@{subject digest}.
+var acrReferrers sim.Store[[]OCIReferrer]
+
+// recordOCIReferrer indexes a pushed manifest under its subject and
+// returns the subject digest, "" for a manifest without one.
+func recordOCIReferrer(repo string, manifest OCIManifest) string {
+	var m struct {
+		ArtifactType string `json:"artifactType"`
+		Config       struct {
+			MediaType string `json:"mediaType"`
+		} `json:"config"`
+		Subject *struct {
+			Digest string `json:"digest"`
+		} `json:"subject"`
+		Annotations map[string]string `json:"annotations"`
+	}
+	if json.Unmarshal(manifest.Data, &m) != nil || m.Subject == nil || m.Subject.Digest == "" {
+		return ""
+	}
+	artifactType := m.ArtifactType
+	if artifactType == "" {
+		artifactType = m.Config.MediaType
+	}
+	key := repo + "@" + m.Subject.Digest
+	refs, _ := acrReferrers.Get(key)
+	for _, r := range refs {
+		if r.Digest == manifest.Digest {
+			return m.Subject.Digest
+		}
+	}
+	acrReferrers.Put(key, append(refs, OCIReferrer{
+		MediaType:    manifest.ContentType,
+		Digest:       manifest.Digest,
+		Size:         len(manifest.Data),
+		ArtifactType: artifactType,
+		Annotations:  m.Annotations,
+	}))
+	return m.Subject.Digest
+}
+
+// parseReferrersPath parses paths like "{name}/referrers/{digest}" from
+// the /v2/ prefix.
+func parseReferrersPath(path string) (repo, digest string, ok bool) {
+	const marker = "/referrers/"
+	idx := strings.LastIndex(path, marker)
+	if idx < 0 {
+		return "", "", false
+	}
+	repo, digest = path[:idx], path[idx+len(marker):]
+	return repo, digest, repo != "" && digest != ""
+}
+
+// handleOCIReferrers serves GET /v2/{name}/referrers/{digest}, with
+// the optional artifactType filter.
+func handleOCIReferrers(w http.ResponseWriter, r *http.Request, repo, digest string) {
+	refs, _ := acrReferrers.Get(repo + "@" + digest)
+	if at := r.URL.Query().Get("artifactType"); at != "" {
+		var filtered []OCIReferrer
+		for _, ref := range refs {
+			if ref.ArtifactType == at {
+				filtered = append(filtered, ref)
+			}
+		}
+		refs = filtered
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	if refs == nil {
+		refs = []OCIReferrer{}
+	}
+	w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.index.v1+json",
+		"manifests":     refs,
+	})
+}
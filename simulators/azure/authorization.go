@@ -187,6 +187,21 @@ func registerAuthorization(srv *sim.Server) {
 				return
 			}
 
+			// Caller permissions: GET {scope}/providers/Microsoft.Authorization/permissions.
+			// The simulator doesn't enforce RBAC, so the caller holds
+			// every control- and data-plane operation at every scope.
+			if r.Method == http.MethodGet && strings.HasSuffix(lowerPath, "/providers/microsoft.authorization/permissions") {
+				sim.WriteJSON(w, http.StatusOK, map[string]any{
+					"value": []map[string]any{{
+						"actions":        []string{"*"},
+						"notActions":     []string{},
+						"dataActions":    []string{"*"},
+						"notDataActions": []string{},
+					}},
+				})
+				return
+			}
+
 			// Role assignments: PUT/GET/DELETE {scope}/providers/Microsoft.Authorization/roleAssignments/{name}
 			if strings.Contains(lowerPath, "/providers/microsoft.authorization/roleassignments/") {
 				scope, raName, ok := parseRoleAssignmentPath(path)
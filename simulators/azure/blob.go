@@ -0,0 +1,1319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// blobContainerState is a container as the Blob data plane sees it.
+// Containers created through ARM (azurerm_storage_container with
+// storage_account_id, armstorage) and through the data plane
+// (azblob, `az storage container create`) land in the same store.
+type blobContainerState struct {
+	Account      string            `json:"account"`
+	Name         string            `json:"name"`
+	PublicAccess string            `json:"publicAccess,omitempty"` // "", "blob" or "container"
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	ETag         string            `json:"etag"`
+	LastModified time.Time         `json:"lastModified"`
+	Lease        storageLease      `json:"lease"`
+}
+
+// storedBlob is a committed block or append blob. Data is the
+// concatenation of CommittedBlocks, so Put Block List can re-commit
+// existing blocks by slicing it.
+type storedBlob struct {
+	Account            string            `json:"account"`
+	Container          string            `json:"container"`
+	Name               string            `json:"name"`
+	Type               string            `json:"type"` // BlockBlob or AppendBlob
+	Data               []byte            `json:"data"`
+	ContentType        string            `json:"contentType,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	ContentLanguage    string            `json:"contentLanguage,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentMD5         []byte            `json:"contentMD5,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	ETag               string            `json:"etag"`
+	Created            time.Time         `json:"created"`
+	LastModified       time.Time         `json:"lastModified"`
+	CommittedBlocks    []blobBlock       `json:"committedBlocks,omitempty"`
+	AppendBlocks       int               `json:"appendBlocks,omitempty"`
+	Lease              storageLease      `json:"lease"`
+}
+
+// blobBlock is one entry of a block list.
+type blobBlock struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+}
+
+// pendingBlocks are the uncommitted blocks staged against a blob name
+// by Put Block, in staging order. Re-staging an ID replaces its data.
+type pendingBlocks struct {
+	Account   string            `json:"account"`
+	Container string            `json:"container"`
+	Blob      string            `json:"blob"`
+	Order     []string          `json:"order"`
+	Data      map[string][]byte `json:"data"`
+}
+
+// Azure limits enforced by the sim.
+const (
+	maxBlockSize        = 4000 << 20
+	maxBlockListEntries = 50000
+	maxAppendBlockSize  = 100 << 20
+	maxListResults      = 5000
+)
+
+var (
+	dataPlaneContainers sim.Store[blobContainerState]
+	dataPlaneBlobs      sim.Store[storedBlob]
+	dataPlaneBlocks     sim.Store[pendingBlocks]
+
+	// azBlobContainers is the ARM view of blob containers, registered
+	// by registerAzureFiles; data-plane creates are mirrored into it.
+	azBlobContainers sim.Store[BlobContainer]
+
+	// blobMu serialises blob and container mutations so conditional
+	// headers and leases are checked and applied atomically.
+	blobMu sync.Mutex
+)
+
+func registerBlobStorage(srv *sim.Server) {
+	dataPlaneContainers = sim.MakeStore[blobContainerState](srv.DB(), "blob_data_plane_containers")
+	dataPlaneBlobs = sim.MakeStore[storedBlob](srv.DB(), "blob_data_plane_blobs")
+	dataPlaneBlocks = sim.MakeStore[pendingBlocks](srv.DB(), "blob_data_plane_blocks")
+}
+
+func containerKey(account, container string) string { return account + "/" + container }
+
+func blobKey(account, container, blob string) string {
+	return account + "/" + container + "/" + blob
+}
+
+// dataPlanePublicAccess and armPublicAccess map between the ARM enum
+// (None / Blob / Container) and the data-plane x-ms-blob-public-access
+// values ("", blob, container).
+func dataPlanePublicAccess(arm string) string {
+	switch strings.ToLower(arm) {
+	case "blob":
+		return "blob"
+	case "container":
+		return "container"
+	}
+	return ""
+}
+
+func armPublicAccess(dp string) string {
+	switch dp {
+	case "blob":
+		return "Blob"
+	case "container":
+		return "Container"
+	}
+	return "None"
+}
+
+// ensureBlobContainer creates or updates the data-plane container behind
+// an ARM container PUT.
+func ensureBlobContainer(account, name, publicAccess string, metadata map[string]string) {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	key := containerKey(account, name)
+	now := time.Now().UTC()
+	if !dataPlaneContainers.Update(key, func(c *blobContainerState) {
+		c.PublicAccess = dataPlanePublicAccess(publicAccess)
+		c.Metadata = metadata
+		c.ETag = newStorageETag()
+		c.LastModified = now
+	}) {
+		dataPlaneContainers.Put(key, blobContainerState{
+			Account: account, Name: name, PublicAccess: dataPlanePublicAccess(publicAccess),
+			Metadata: metadata, ETag: newStorageETag(), LastModified: now, Lease: storageLease{State: "available"},
+		})
+	}
+}
+
+// deleteBlobContainer drops a container and every blob in it.
+func deleteBlobContainer(account, name string) {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	dropBlobContainer(account, name)
+}
+
+func dropBlobContainer(account, name string) {
+	dataPlaneContainers.Delete(containerKey(account, name))
+	for _, b := range dataPlaneBlobs.Filter(func(b storedBlob) bool { return b.Account == account && b.Container == name }) {
+		dataPlaneBlobs.Delete(blobKey(b.Account, b.Container, b.Name))
+	}
+	for _, p := range dataPlaneBlocks.Filter(func(p pendingBlocks) bool { return p.Account == account && p.Container == name }) {
+		dataPlaneBlocks.Delete(blobKey(p.Account, p.Container, p.Blob))
+	}
+}
+
+// mirrorARMContainer keeps the ARM container resource in step with a
+// data-plane create, ACL change or delete, so containers made with
+// `az storage container create` show up in armstorage listings.
+func mirrorARMContainer(c blobContainerState, deleted bool) {
+	acct, ok := storageAccountByName(c.Account)
+	if !ok {
+		return
+	}
+	id := acct.ID + "/blobServices/default/containers/" + c.Name
+	if deleted {
+		azBlobContainers.Delete(id)
+		return
+	}
+	azBlobContainers.Put(id, BlobContainer{
+		ID:   id,
+		Name: c.Name,
+		Type: "Microsoft.Storage/storageAccounts/blobServices/containers",
+		Etag: c.ETag,
+		Properties: BlobContainerProps{
+			PublicAccess:     armPublicAccess(c.PublicAccess),
+			LeaseStatus:      "Unlocked",
+			LeaseState:       "Available",
+			Metadata:         c.Metadata,
+			LastModifiedTime: c.LastModified.Format(time.RFC3339),
+		},
+	})
+}
+
+// storageServiceVersion is the x-ms-version the sim reports when the
+// client doesn't send one.
+const storageServiceVersion = "2021-12-02"
+
+// setStorageResponseHeaders stamps the headers every Blob and Queue
+// response carries. Data-plane requests are dispatched ahead of the
+// shared request-ID middleware, so the request ID is minted here.
+func setStorageResponseHeaders(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("x-ms-request-id", generateUUID())
+	v := r.Header.Get("x-ms-version")
+	if v == "" {
+		v = storageServiceVersion
+	}
+	h.Set("x-ms-version", v)
+	h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	if id := r.Header.Get("x-ms-client-request-id"); id != "" {
+		h.Set("x-ms-client-request-id", id)
+	}
+}
+
+// storageServiceEndpoint is the account endpoint echoed in listings.
+func storageServiceEndpoint(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/"
+}
+
+// handleBlobDataPlane serves the Blob service REST API for one storage
+// account: container create / properties / metadata / ACL / lease /
+// delete / list, and block and append blob upload, ranged download,
+// metadata, properties, lease and delete. Page blobs aren't simulated.
+func handleBlobDataPlane(w http.ResponseWriter, r *http.Request, account string) {
+	setStorageResponseHeaders(w, r)
+	if _, ok := storageAccountByName(account); !ok {
+		storageErrorf(w, http.StatusNotFound, "ResourceNotFound", "The storage account %q does not exist.", account)
+		return
+	}
+	q := r.URL.Query()
+	restype, comp := q.Get("restype"), q.Get("comp")
+	container, blob, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	switch {
+	case container == "":
+		if comp != "list" || r.Method != http.MethodGet {
+			storageErrorf(w, http.StatusBadRequest, "UnsupportedOperation",
+				"%s on the blob service root with comp=%q is not supported by the simulator.", r.Method, comp)
+			return
+		}
+		if authorizeStorage(w, r, account, storageAccess{service: "blob", perms: "l", level: 's'}) {
+			listBlobContainers(w, r, account)
+		}
+	case blob == "" && restype == "container":
+		handleBlobContainer(w, r, account, container, comp)
+	case blob == "":
+		storageError(w, http.StatusBadRequest, "InvalidUri", "The requested URI does not represent any resource on the server.")
+	default:
+		handleBlob(w, r, account, container, blob, comp)
+	}
+}
+
+func handleBlobContainer(w http.ResponseWriter, r *http.Request, account, name, comp string) {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	key := containerKey(account, name)
+	c, exists := dataPlaneContainers.Get(key)
+	now := time.Now().UTC()
+	c.Lease.refresh(now)
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	// authorize checks credentials, then that the container exists.
+	authorize := func(perms string, anonymous bool) bool {
+		access := storageAccess{service: "blob", container: name, perms: perms, level: 'c',
+			anonymous: anonymous && exists && c.PublicAccess == "container"}
+		if !authorizeStorage(w, r, account, access) {
+			return false
+		}
+		if !exists {
+			storageError(w, http.StatusNotFound, "ContainerNotFound", "The specified container does not exist.")
+			return false
+		}
+		return true
+	}
+	setCommon := func() {
+		w.Header().Set("ETag", c.ETag)
+		w.Header().Set("Last-Modified", c.LastModified.Format(http.TimeFormat))
+	}
+
+	switch {
+	case comp == "" && r.Method == http.MethodPut:
+		access := storageAccess{service: "blob", container: name, perms: "c", level: 'c'}
+		if !authorizeStorage(w, r, account, access) {
+			return
+		}
+		if !validStorageName(name) && !strings.HasPrefix(name, "$") {
+			storageErrorf(w, http.StatusBadRequest, "InvalidResourceName", "The specified resource name %q is not valid.", name)
+			return
+		}
+		if exists {
+			storageError(w, http.StatusConflict, "ContainerAlreadyExists", "The specified container already exists.")
+			return
+		}
+		pa := r.Header.Get("x-ms-blob-public-access")
+		if pa != "" && pa != "blob" && pa != "container" {
+			storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue", "x-ms-blob-public-access %q is not one of blob, container.", pa)
+			return
+		}
+		c = blobContainerState{Account: account, Name: name, PublicAccess: pa, Metadata: storageMetadata(r),
+			ETag: newStorageETag(), LastModified: now, Lease: storageLease{State: "available"}}
+		dataPlaneContainers.Put(key, c)
+		mirrorARMContainer(c, false)
+		setCommon()
+		w.WriteHeader(http.StatusCreated)
+
+	case (comp == "" || comp == "metadata") && read:
+		if !authorize("r", true) || !c.Lease.checkRead(w, r, "container") {
+			return
+		}
+		setCommon()
+		setMetadataHeaders(w, c.Metadata)
+		if comp == "" {
+			c.Lease.setHeaders(w)
+			if c.PublicAccess != "" {
+				w.Header().Set("x-ms-blob-public-access", c.PublicAccess)
+			}
+			w.Header().Set("x-ms-has-immutability-policy", "false")
+			w.Header().Set("x-ms-has-legal-hold", "false")
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case comp == "" && r.Method == http.MethodDelete:
+		if !authorize("d", false) ||
+			!checkStorageConditions(w, r, true, c.ETag, c.LastModified, false) ||
+			!c.Lease.checkWrite(w, r, "container") {
+			return
+		}
+		dropBlobContainer(account, name)
+		mirrorARMContainer(c, true)
+		w.WriteHeader(http.StatusAccepted)
+
+	case comp == "metadata" && r.Method == http.MethodPut:
+		if !authorize("w", false) || !c.Lease.checkRead(w, r, "container") {
+			return
+		}
+		c.Metadata = storageMetadata(r)
+		c.ETag, c.LastModified = newStorageETag(), now
+		dataPlaneContainers.Put(key, c)
+		mirrorARMContainer(c, false)
+		setCommon()
+		w.WriteHeader(http.StatusOK)
+
+	case comp == "acl" && read:
+		if !authorize("r", false) || !c.Lease.checkRead(w, r, "container") {
+			return
+		}
+		setCommon()
+		if c.PublicAccess != "" {
+			w.Header().Set("x-ms-blob-public-access", c.PublicAccess)
+		}
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><SignedIdentifiers />`)
+
+	case comp == "acl" && r.Method == http.MethodPut:
+		if !authorize("w", false) || !c.Lease.checkRead(w, r, "container") {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			storageErrorf(w, http.StatusBadRequest, "InvalidInput", "read request body: %v", err)
+			return
+		}
+		if bytes.Contains(body, []byte("<SignedIdentifier>")) {
+			storageError(w, http.StatusNotImplemented, "NotImplemented",
+				"Stored access policies (signed identifiers) are not supported by the simulator.")
+			return
+		}
+		pa := r.Header.Get("x-ms-blob-public-access")
+		if pa != "" && pa != "blob" && pa != "container" {
+			storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue", "x-ms-blob-public-access %q is not one of blob, container.", pa)
+			return
+		}
+		c.PublicAccess = pa
+		c.ETag, c.LastModified = newStorageETag(), now
+		dataPlaneContainers.Put(key, c)
+		mirrorARMContainer(c, false)
+		setCommon()
+		w.WriteHeader(http.StatusOK)
+
+	case comp == "lease" && r.Method == http.MethodPut:
+		if !authorize("w", false) || !checkStorageConditions(w, r, true, c.ETag, c.LastModified, false) {
+			return
+		}
+		setCommon()
+		if applyLeaseAction(w, r, &c.Lease, now) {
+			dataPlaneContainers.Put(key, c)
+		}
+
+	case comp == "list" && r.Method == http.MethodGet:
+		if authorize("l", true) {
+			listBlobs(w, r, account, name)
+		}
+
+	default:
+		storageErrorf(w, http.StatusBadRequest, "UnsupportedOperation",
+			"%s on a container with comp=%q is not supported by the simulator.", r.Method, comp)
+	}
+}
+
+func handleBlob(w http.ResponseWriter, r *http.Request, account, container, name, comp string) {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	c, containerExists := dataPlaneContainers.Get(containerKey(account, container))
+	key := blobKey(account, container, name)
+	b, exists := dataPlaneBlobs.Get(key)
+	if !exists {
+		b.Account, b.Container, b.Name = account, container, name
+	}
+	now := time.Now().UTC()
+	b.Lease.refresh(now)
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	// authorize checks credentials, then that the container exists.
+	authorize := func(perms string, anonymous bool) bool {
+		access := storageAccess{service: "blob", container: container, blob: name, perms: perms, level: 'o',
+			anonymous: anonymous && containerExists && c.PublicAccess != ""}
+		if !authorizeStorage(w, r, account, access) {
+			return false
+		}
+		if !containerExists {
+			storageError(w, http.StatusNotFound, "ContainerNotFound", "The specified container does not exist.")
+			return false
+		}
+		return true
+	}
+	requireBlob := func() bool {
+		if !exists {
+			storageError(w, http.StatusNotFound, "BlobNotFound", "The specified blob does not exist.")
+			return false
+		}
+		return true
+	}
+	// mutable runs the checks shared by every write to an existing blob.
+	mutable := func(perms string) bool {
+		return authorize(perms, false) && requireBlob() &&
+			checkStorageConditions(w, r, true, b.ETag, b.LastModified, false) &&
+			b.Lease.checkWrite(w, r, "blob")
+	}
+
+	switch {
+	case comp == "" && read:
+		if !authorize("r", true) || !requireBlob() || !b.Lease.checkRead(w, r, "blob") ||
+			!checkStorageConditions(w, r, true, b.ETag, b.LastModified, true) {
+			return
+		}
+		serveBlob(w, r, b)
+
+	case comp == "metadata" && read:
+		if !authorize("r", true) || !requireBlob() || !b.Lease.checkRead(w, r, "blob") ||
+			!checkStorageConditions(w, r, true, b.ETag, b.LastModified, true) {
+			return
+		}
+		w.Header().Set("ETag", b.ETag)
+		w.Header().Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+		setMetadataHeaders(w, b.Metadata)
+		w.WriteHeader(http.StatusOK)
+
+	case comp == "" && r.Method == http.MethodPut:
+		if authorize("cw", false) {
+			putBlob(w, r, b, exists, now)
+		}
+
+	case comp == "block" && r.Method == http.MethodPut:
+		if authorize("w", false) {
+			putBlock(w, r, b, exists)
+		}
+
+	case comp == "blocklist" && r.Method == http.MethodPut:
+		if authorize("w", false) {
+			putBlockList(w, r, b, exists, now)
+		}
+
+	case comp == "blocklist" && r.Method == http.MethodGet:
+		if authorize("r", true) {
+			getBlockList(w, r, b, exists)
+		}
+
+	case comp == "appendblock" && r.Method == http.MethodPut:
+		if mutable("aw") {
+			appendBlock(w, r, b, now)
+		}
+
+	case comp == "" && r.Method == http.MethodDelete:
+		if !mutable("d") {
+			return
+		}
+		dataPlaneBlobs.Delete(key)
+		dataPlaneBlocks.Delete(key)
+		w.Header().Set("x-ms-delete-type-permanent", "true")
+		w.WriteHeader(http.StatusAccepted)
+
+	case comp == "metadata" && r.Method == http.MethodPut:
+		if !mutable("w") {
+			return
+		}
+		b.Metadata = storageMetadata(r)
+		b.ETag, b.LastModified = newStorageETag(), now
+		dataPlaneBlobs.Put(key, b)
+		w.Header().Set("ETag", b.ETag)
+		w.Header().Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+		w.Header().Set("x-ms-request-server-encrypted", "true")
+		w.WriteHeader(http.StatusOK)
+
+	case comp == "properties" && r.Method == http.MethodPut:
+		if !mutable("w") {
+			return
+		}
+		// Set Blob Properties replaces every content header; one the
+		// request omits is cleared.
+		h := r.Header
+		b.ContentType = h.Get("x-ms-blob-content-type")
+		b.ContentEncoding = h.Get("x-ms-blob-content-encoding")
+		b.ContentLanguage = h.Get("x-ms-blob-content-language")
+		b.ContentDisposition = h.Get("x-ms-blob-content-disposition")
+		b.CacheControl = h.Get("x-ms-blob-cache-control")
+		md5sum, ok := decodeContentMD5(w, h.Get("x-ms-blob-content-md5"))
+		if !ok {
+			return
+		}
+		b.ContentMD5 = md5sum
+		b.ETag, b.LastModified = newStorageETag(), now
+		dataPlaneBlobs.Put(key, b)
+		w.Header().Set("ETag", b.ETag)
+		w.Header().Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+
+	case comp == "lease" && r.Method == http.MethodPut:
+		if !authorize("w", false) || !requireBlob() ||
+			!checkStorageConditions(w, r, true, b.ETag, b.LastModified, false) {
+			return
+		}
+		w.Header().Set("ETag", b.ETag)
+		w.Header().Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+		if applyLeaseAction(w, r, &b.Lease, now) {
+			dataPlaneBlobs.Put(key, b)
+		}
+
+	default:
+		storageErrorf(w, http.StatusBadRequest, "UnsupportedOperation",
+			"%s on a blob with comp=%q is not supported by the simulator.", r.Method, comp)
+	}
+}
+
+// putBlob handles Put Blob for block blobs (the body is the content)
+// and append blobs (created empty).
+func putBlob(w http.ResponseWriter, r *http.Request, old storedBlob, exists bool, now time.Time) {
+	if r.Header.Get("x-ms-copy-source") != "" {
+		storageError(w, http.StatusNotImplemented, "NotImplemented",
+			"Copy Blob and Put Blob From URL (x-ms-copy-source) are not supported by the simulator.")
+		return
+	}
+	typ := r.Header.Get("x-ms-blob-type")
+	switch typ {
+	case "BlockBlob", "AppendBlob":
+	case "PageBlob":
+		storageError(w, http.StatusNotImplemented, "NotImplemented", "Page blobs are not supported by the simulator.")
+		return
+	case "":
+		storageError(w, http.StatusBadRequest, "MissingRequiredHeader", "x-ms-blob-type is required.")
+		return
+	default:
+		storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue", "x-ms-blob-type %q is not one of BlockBlob, AppendBlob, PageBlob.", typ)
+		return
+	}
+	if !checkStorageConditions(w, r, exists, old.ETag, old.LastModified, false) || !old.Lease.checkWrite(w, r, "blob") {
+		return
+	}
+	body, ok := readStorageBody(w, r, maxBlockSize)
+	if !ok {
+		return
+	}
+	if typ == "AppendBlob" && len(body) > 0 {
+		storageError(w, http.StatusBadRequest, "InvalidHeaderValue", "Content-Length must be 0 when creating an append blob.")
+		return
+	}
+
+	b := storedBlob{
+		Account:      old.Account,
+		Container:    old.Container,
+		Name:         old.Name,
+		Type:         typ,
+		Data:         body,
+		Metadata:     storageMetadata(r),
+		ETag:         newStorageETag(),
+		Created:      now,
+		LastModified: now,
+		Lease:        old.Lease,
+	}
+	if exists {
+		b.Created = old.Created
+	}
+	if !applyBlobContentHeaders(w, r, &b, true) {
+		return
+	}
+	if typ == "BlockBlob" && b.ContentMD5 == nil {
+		sum := md5.Sum(body)
+		b.ContentMD5 = sum[:]
+	}
+	key := blobKey(b.Account, b.Container, b.Name)
+	dataPlaneBlobs.Put(key, b)
+	// Put Blob discards any blocks staged against the name.
+	dataPlaneBlocks.Delete(key)
+
+	w.Header().Set("ETag", b.ETag)
+	w.Header().Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+	if typ == "BlockBlob" {
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(b.ContentMD5))
+	}
+	w.Header().Set("x-ms-request-server-encrypted", "true")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// applyBlobContentHeaders sets the blob's content properties from the
+// x-ms-blob-content-* headers. For Put Blob the plain Content-* request
+// headers describe the body too and are used when the x-ms- form is
+// absent.
+func applyBlobContentHeaders(w http.ResponseWriter, r *http.Request, b *storedBlob, usePlain bool) bool {
+	h := r.Header
+	pick := func(xms, plain string) string {
+		if v := h.Get(xms); v != "" || !usePlain {
+			return v
+		}
+		return h.Get(plain)
+	}
+	b.ContentType = pick("x-ms-blob-content-type", "Content-Type")
+	b.ContentEncoding = pick("x-ms-blob-content-encoding", "Content-Encoding")
+	b.ContentLanguage = pick("x-ms-blob-content-language", "Content-Language")
+	b.ContentDisposition = h.Get("x-ms-blob-content-disposition")
+	b.CacheControl = h.Get("x-ms-blob-cache-control")
+	if b.ContentType == "" {
+		b.ContentType = "application/octet-stream"
+	}
+	sum, ok := decodeContentMD5(w, h.Get("x-ms-blob-content-md5"))
+	b.ContentMD5 = sum
+	return ok
+}
+
+func decodeContentMD5(w http.ResponseWriter, v string) ([]byte, bool) {
+	if v == "" {
+		return nil, true
+	}
+	sum, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(sum) != md5.Size {
+		storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue", "%q is not a base64-encoded MD5 digest.", v)
+		return nil, false
+	}
+	return sum, true
+}
+
+// readStorageBody reads the request body, enforcing a size limit and
+// any transactional Content-MD5 the client sent.
+func readStorageBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		storageErrorf(w, http.StatusBadRequest, "InvalidInput", "read request body: %v", err)
+		return nil, false
+	}
+	if int64(len(body)) > limit {
+		storageErrorf(w, http.StatusRequestEntityTooLarge, "RequestBodyTooLarge",
+			"The request body is too large and exceeds the maximum permissible limit of %d bytes.", limit)
+		return nil, false
+	}
+	if want := r.Header.Get("Content-MD5"); want != "" {
+		sum := md5.Sum(body)
+		if got := base64.StdEncoding.EncodeToString(sum[:]); got != want {
+			storageErrorf(w, http.StatusBadRequest, "Md5Mismatch",
+				"The MD5 value specified in the request did not match with the MD5 value calculated by the server (%s vs %s).", want, got)
+			return nil, false
+		}
+	}
+	return body, true
+}
+
+// putBlock stages an uncommitted block for a later Put Block List.
+func putBlock(w http.ResponseWriter, r *http.Request, b storedBlob, exists bool) {
+	id := r.URL.Query().Get("blockid")
+	raw, err := base64.StdEncoding.DecodeString(id)
+	if id == "" || err != nil || len(raw) > 64 {
+		storageErrorf(w, http.StatusBadRequest, "InvalidQueryParameterValue",
+			"blockid %q must be a base64 string of at most 64 bytes before encoding.", id)
+		return
+	}
+	if exists && b.Type != "BlockBlob" {
+		storageError(w, http.StatusConflict, "InvalidBlobType", "The blob type is invalid for this operation.")
+		return
+	}
+	if !b.Lease.checkWrite(w, r, "blob") {
+		return
+	}
+	body, ok := readStorageBody(w, r, maxBlockSize)
+	if !ok {
+		return
+	}
+	key := blobKey(b.Account, b.Container, b.Name)
+	pending, _ := dataPlaneBlocks.Get(key)
+	if pending.Data == nil {
+		pending = pendingBlocks{Account: b.Account, Container: b.Container, Blob: b.Name, Data: map[string][]byte{}}
+	}
+	if _, staged := pending.Data[id]; !staged {
+		if len(pending.Order) >= 2*maxBlockListEntries {
+			storageError(w, http.StatusConflict, "BlockCountExceedsLimit",
+				"The uncommitted block count cannot exceed the maximum limit of 100,000 blocks.")
+			return
+		}
+		pending.Order = append(pending.Order, id)
+	}
+	pending.Data[id] = body
+	dataPlaneBlocks.Put(key, pending)
+
+	sum := md5.Sum(body)
+	w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	w.Header().Set("x-ms-request-server-encrypted", "true")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// blockListEntry is one <Committed>, <Uncommitted> or <Latest> element
+// of a Put Block List body, in document order.
+type blockListEntry struct {
+	kind string
+	id   string
+}
+
+func parseBlockList(body []byte) ([]blockListEntry, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	var entries []blockListEntry
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "BlockList":
+		case "Committed", "Uncommitted", "Latest":
+			var id string
+			if err := dec.DecodeElement(&id, &se); err != nil {
+				return nil, err
+			}
+			entries = append(entries, blockListEntry{kind: se.Name.Local, id: strings.TrimSpace(id)})
+		default:
+			return nil, fmt.Errorf("unexpected element <%s>", se.Name.Local)
+		}
+	}
+}
+
+// putBlockList commits a block blob from staged and previously
+// committed blocks, discarding whatever staged blocks it doesn't use.
+func putBlockList(w http.ResponseWriter, r *http.Request, old storedBlob, exists bool, now time.Time) {
+	if exists && old.Type != "BlockBlob" {
+		storageError(w, http.StatusConflict, "InvalidBlobType", "The blob type is invalid for this operation.")
+		return
+	}
+	if !checkStorageConditions(w, r, exists, old.ETag, old.LastModified, false) || !old.Lease.checkWrite(w, r, "blob") {
+		return
+	}
+	body, ok := readStorageBody(w, r, 8<<20)
+	if !ok {
+		return
+	}
+	entries, err := parseBlockList(body)
+	if err != nil {
+		storageErrorf(w, http.StatusBadRequest, "InvalidXmlDocument", "XML specified is not syntactically valid: %v", err)
+		return
+	}
+	if len(entries) > maxBlockListEntries {
+		storageErrorf(w, http.StatusConflict, "BlockCountExceedsLimit",
+			"The committed block count cannot exceed the maximum limit of %d blocks.", maxBlockListEntries)
+		return
+	}
+
+	key := blobKey(old.Account, old.Container, old.Name)
+	pending, _ := dataPlaneBlocks.Get(key)
+	committed := map[string][]byte{}
+	var off int64
+	for _, blk := range old.CommittedBlocks {
+		committed[blk.ID] = old.Data[off : off+blk.Size]
+		off += blk.Size
+	}
+
+	var data bytes.Buffer
+	blocks := make([]blobBlock, 0, len(entries))
+	for _, e := range entries {
+		var chunk []byte
+		var found bool
+		switch e.kind {
+		case "Committed":
+			chunk, found = committed[e.id]
+		case "Uncommitted":
+			chunk, found = pending.Data[e.id]
+		case "Latest":
+			if chunk, found = pending.Data[e.id]; !found {
+				chunk, found = committed[e.id]
+			}
+		}
+		if !found {
+			storageErrorf(w, http.StatusBadRequest, "InvalidBlockList",
+				"The specified block list is invalid: %s block %q was not found.", strings.ToLower(e.kind), e.id)
+			return
+		}
+		data.Write(chunk)
+		blocks = append(blocks, blobBlock{ID: e.id, Size: int64(len(chunk))})
+	}
+
+	b := storedBlob{
+		Account:         old.Account,
+		Container:       old.Container,
+		Name:            old.Name,
+		Type:            "BlockBlob",
+		Data:            data.Bytes(),
+		Metadata:        storageMetadata(r),
+		ETag:            newStorageETag(),
+		Created:         now,
+		LastModified:    now,
+		CommittedBlocks: blocks,
+		Lease:           old.Lease,
+	}
+	if exists {
+		b.Created = old.Created
+	}
+	if !applyBlobContentHeaders(w, r, &b, false) {
+		return
+	}
+	dataPlaneBlobs.Put(key, b)
+	dataPlaneBlocks.Delete(key)
+
+	w.Header().Set("ETag", b.ETag)
+	w.Header().Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+	w.Header().Set("x-ms-request-server-encrypted", "true")
+	w.WriteHeader(http.StatusCreated)
+}
+
+type blockXML struct {
+	Name string `xml:"Name"`
+	Size int64  `xml:"Size"`
+}
+
+type blockListXML struct {
+	XMLName     xml.Name   `xml:"BlockList"`
+	Committed   []blockXML `xml:"CommittedBlocks>Block"`
+	Uncommitted []blockXML `xml:"UncommittedBlocks>Block"`
+}
+
+func getBlockList(w http.ResponseWriter, r *http.Request, b storedBlob, exists bool) {
+	pending, hasPending := dataPlaneBlocks.Get(blobKey(b.Account, b.Container, b.Name))
+	if !exists && !hasPending {
+		storageError(w, http.StatusNotFound, "BlobNotFound", "The specified blob does not exist.")
+		return
+	}
+	if !b.Lease.checkRead(w, r, "blob") {
+		return
+	}
+	listType := r.URL.Query().Get("blocklisttype")
+	if listType == "" {
+		listType = "committed"
+	}
+	if listType != "committed" && listType != "uncommitted" && listType != "all" {
+		storageErrorf(w, http.StatusBadRequest, "InvalidQueryParameterValue",
+			"blocklisttype %q is not one of committed, uncommitted, all.", listType)
+		return
+	}
+	var out blockListXML
+	if listType != "uncommitted" {
+		for _, blk := range b.CommittedBlocks {
+			out.Committed = append(out.Committed, blockXML{Name: blk.ID, Size: blk.Size})
+		}
+	}
+	if listType != "committed" {
+		for _, id := range pending.Order {
+			out.Uncommitted = append(out.Uncommitted, blockXML{Name: id, Size: int64(len(pending.Data[id]))})
+		}
+	}
+	if exists {
+		w.Header().Set("ETag", b.ETag)
+		w.Header().Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+		w.Header().Set("x-ms-blob-content-length", strconv.Itoa(len(b.Data)))
+	}
+	sim.WriteXML(w, http.StatusOK, out)
+}
+
+// appendBlock appends the body to an append blob, honouring the
+// max-size and append-position conditions.
+func appendBlock(w http.ResponseWriter, r *http.Request, b storedBlob, now time.Time) {
+	if b.Type != "AppendBlob" {
+		storageError(w, http.StatusConflict, "InvalidBlobType", "The blob type is invalid for this operation.")
+		return
+	}
+	body, ok := readStorageBody(w, r, maxAppendBlockSize)
+	if !ok {
+		return
+	}
+	if len(body) == 0 {
+		storageError(w, http.StatusBadRequest, "InvalidHeaderValue", "Append Block requires a non-empty body.")
+		return
+	}
+	if v := r.Header.Get("x-ms-blob-condition-maxsize"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue", "x-ms-blob-condition-maxsize %q is not an integer.", v)
+			return
+		}
+		if len(b.Data)+len(body) > limit {
+			storageError(w, http.StatusPreconditionFailed, "MaxBlobSizeConditionNotMet",
+				"The max blob size condition specified was not met.")
+			return
+		}
+	}
+	if v := r.Header.Get("x-ms-blob-condition-appendpos"); v != "" {
+		pos, err := strconv.Atoi(v)
+		if err != nil {
+			storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue", "x-ms-blob-condition-appendpos %q is not an integer.", v)
+			return
+		}
+		if pos != len(b.Data) {
+			storageError(w, http.StatusPreconditionFailed, "AppendPositionConditionNotMet",
+				"The append position condition specified was not met.")
+			return
+		}
+	}
+	if b.AppendBlocks >= maxBlockListEntries {
+		storageErrorf(w, http.StatusConflict, "BlockCountExceedsLimit",
+			"The committed block count cannot exceed the maximum limit of %d blocks.", maxBlockListEntries)
+		return
+	}
+	offset := len(b.Data)
+	b.Data = append(b.Data, body...)
+	b.AppendBlocks++
+	b.ETag, b.LastModified = newStorageETag(), now
+	dataPlaneBlobs.Put(blobKey(b.Account, b.Container, b.Name), b)
+
+	sum := md5.Sum(body)
+	w.Header().Set("ETag", b.ETag)
+	w.Header().Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+	w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	w.Header().Set("x-ms-blob-append-offset", strconv.Itoa(offset))
+	w.Header().Set("x-ms-blob-committed-block-count", strconv.Itoa(b.AppendBlocks))
+	w.Header().Set("x-ms-request-server-encrypted", "true")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// setBlobHeaders writes the properties Get Blob and Get Blob Properties
+// return.
+func setBlobHeaders(w http.ResponseWriter, b storedBlob) {
+	h := w.Header()
+	h.Set("ETag", b.ETag)
+	h.Set("Last-Modified", b.LastModified.Format(http.TimeFormat))
+	h.Set("x-ms-creation-time", b.Created.Format(http.TimeFormat))
+	h.Set("x-ms-blob-type", b.Type)
+	h.Set("Content-Type", b.ContentType)
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "application/octet-stream")
+	}
+	for name, v := range map[string]string{
+		"Content-Encoding":    b.ContentEncoding,
+		"Content-Language":    b.ContentLanguage,
+		"Content-Disposition": b.ContentDisposition,
+		"Cache-Control":       b.CacheControl,
+	} {
+		if v != "" {
+			h.Set(name, v)
+		}
+	}
+	setMetadataHeaders(w, b.Metadata)
+	b.Lease.setHeaders(w)
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("x-ms-server-encrypted", "true")
+	if b.Type == "AppendBlob" {
+		h.Set("x-ms-blob-committed-block-count", strconv.Itoa(b.AppendBlocks))
+	} else {
+		h.Set("x-ms-access-tier", "Hot")
+		h.Set("x-ms-access-tier-inferred", "true")
+	}
+}
+
+// serveBlob answers Get Blob (optionally ranged via Range / x-ms-range)
+// and Get Blob Properties (HEAD).
+func serveBlob(w http.ResponseWriter, r *http.Request, b storedBlob) {
+	setBlobHeaders(w, b)
+	size := int64(len(b.Data))
+	rng := r.Header.Get("x-ms-range")
+	if rng == "" {
+		rng = r.Header.Get("Range")
+	}
+	if rng == "" || r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		if len(b.ContentMD5) > 0 {
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(b.ContentMD5))
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(b.Data)
+		}
+		return
+	}
+
+	start, end, err := parseStorageRange(rng, size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		storageErrorf(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange",
+			"The range specified is invalid for the current size of the resource: %v.", err)
+		return
+	}
+	part := b.Data[start : end+1]
+	if r.Header.Get("x-ms-range-get-content-md5") == "true" {
+		if len(part) > 4<<20 {
+			storageError(w, http.StatusBadRequest, "OutOfRangeInput",
+				"x-ms-range-get-content-md5 is only supported for ranges up to 4 MiB.")
+			return
+		}
+		sum := md5.Sum(part)
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	if len(b.ContentMD5) > 0 {
+		w.Header().Set("x-ms-blob-content-md5", base64.StdEncoding.EncodeToString(b.ContentMD5))
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	w.Header().Set("Content-Length", strconv.Itoa(len(part)))
+	w.WriteHeader(http.StatusPartialContent)
+	_, _ = w.Write(part)
+}
+
+// parseStorageRange parses `bytes=start-` / `bytes=start-end` against a
+// resource of the given size, clamping end to the last byte.
+func parseStorageRange(v string, size int64) (start, end int64, err error) {
+	spec, ok := strings.CutPrefix(v, "bytes=")
+	if !ok {
+		return 0, 0, fmt.Errorf("range %q must start with bytes=", v)
+	}
+	a, z, ok := strings.Cut(spec, "-")
+	if !ok || a == "" {
+		return 0, 0, fmt.Errorf("range %q must be start-[end]", v)
+	}
+	if start, err = strconv.ParseInt(a, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("range %q: %w", v, err)
+	}
+	end = size - 1
+	if z != "" {
+		if end, err = strconv.ParseInt(z, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("range %q: %w", v, err)
+		}
+		if end < start {
+			return 0, 0, fmt.Errorf("range %q ends before it starts", v)
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, fmt.Errorf("range start %d is beyond blob size %d", start, size)
+	}
+	return start, end, nil
+}
+
+// metadataXML renders a metadata map as <Metadata><key>value</key>…
+// with keys in sorted order.
+type metadataXML map[string]string
+
+func (m metadataXML) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := e.EncodeElement(m[k], xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+type containerPropertiesXML struct {
+	LastModified          string `xml:"Last-Modified"`
+	Etag                  string `xml:"Etag"`
+	LeaseStatus           string `xml:"LeaseStatus"`
+	LeaseState            string `xml:"LeaseState"`
+	LeaseDuration         string `xml:"LeaseDuration,omitempty"`
+	PublicAccess          string `xml:"PublicAccess,omitempty"`
+	HasImmutabilityPolicy bool   `xml:"HasImmutabilityPolicy"`
+	HasLegalHold          bool   `xml:"HasLegalHold"`
+}
+
+type containerItemXML struct {
+	Name       string                 `xml:"Name"`
+	Properties containerPropertiesXML `xml:"Properties"`
+	Metadata   metadataXML            `xml:"Metadata,omitempty"`
+}
+
+type containerListXML struct {
+	XMLName         xml.Name           `xml:"EnumerationResults"`
+	ServiceEndpoint string             `xml:"ServiceEndpoint,attr"`
+	Prefix          string             `xml:"Prefix,omitempty"`
+	Marker          string             `xml:"Marker,omitempty"`
+	MaxResults      int                `xml:"MaxResults,omitempty"`
+	Containers      []containerItemXML `xml:"Containers>Container"`
+	NextMarker      string             `xml:"NextMarker"`
+}
+
+// listParams reads the prefix / marker / maxresults / include query
+// parameters shared by the list operations.
+func listParams(w http.ResponseWriter, r *http.Request) (prefix, marker string, max int, include []string, ok bool) {
+	q := r.URL.Query()
+	max = maxListResults
+	if v := q.Get("maxresults"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListResults {
+			storageErrorf(w, http.StatusBadRequest, "OutOfRangeQueryParameterValue",
+				"maxresults %q must be between 1 and %d.", v, maxListResults)
+			return "", "", 0, nil, false
+		}
+		max = n
+	}
+	if v := q.Get("include"); v != "" {
+		include = strings.Split(v, ",")
+	}
+	return q.Get("prefix"), q.Get("marker"), max, include, true
+}
+
+func listBlobContainers(w http.ResponseWriter, r *http.Request, account string) {
+	prefix, marker, max, include, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	cs := dataPlaneContainers.Filter(func(c blobContainerState) bool {
+		return c.Account == account && strings.HasPrefix(c.Name, prefix) && c.Name >= marker
+	})
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+
+	out := containerListXML{ServiceEndpoint: storageServiceEndpoint(r), Prefix: prefix, Marker: marker}
+	if r.URL.Query().Get("maxresults") != "" {
+		out.MaxResults = max
+	}
+	for i, c := range cs {
+		if i == max {
+			out.NextMarker = c.Name
+			break
+		}
+		c.Lease.refresh(now)
+		item := containerItemXML{Name: c.Name, Properties: containerPropertiesXML{
+			LastModified: c.LastModified.Format(http.TimeFormat),
+			Etag:         strings.Trim(c.ETag, `"`),
+			LeaseStatus:  "unlocked",
+			LeaseState:   c.Lease.State,
+			PublicAccess: c.PublicAccess,
+		}}
+		if c.Lease.active() {
+			item.Properties.LeaseStatus = "locked"
+		}
+		if c.Lease.State == "leased" {
+			item.Properties.LeaseDuration = c.Lease.durationName()
+		}
+		if slices.Contains(include, "metadata") {
+			item.Metadata = c.Metadata
+		}
+		out.Containers = append(out.Containers, item)
+	}
+	sim.WriteXML(w, http.StatusOK, out)
+}
+
+type blobPropertiesXML struct {
+	CreationTime       string `xml:"Creation-Time"`
+	LastModified       string `xml:"Last-Modified"`
+	Etag               string `xml:"Etag"`
+	ContentLength      int    `xml:"Content-Length"`
+	ContentType        string `xml:"Content-Type"`
+	ContentEncoding    string `xml:"Content-Encoding"`
+	ContentLanguage    string `xml:"Content-Language"`
+	ContentMD5         string `xml:"Content-MD5"`
+	ContentDisposition string `xml:"Content-Disposition"`
+	CacheControl       string `xml:"Cache-Control"`
+	BlobType           string `xml:"BlobType"`
+	AccessTier         string `xml:"AccessTier,omitempty"`
+	AccessTierInferred bool   `xml:"AccessTierInferred,omitempty"`
+	LeaseStatus        string `xml:"LeaseStatus"`
+	LeaseState         string `xml:"LeaseState"`
+	LeaseDuration      string `xml:"LeaseDuration,omitempty"`
+	ServerEncrypted    bool   `xml:"ServerEncrypted"`
+}
+
+type blobItemXML struct {
+	Name       string            `xml:"Name"`
+	Properties blobPropertiesXML `xml:"Properties"`
+	Metadata   metadataXML       `xml:"Metadata,omitempty"`
+}
+
+type blobPrefixXML struct {
+	Name string `xml:"Name"`
+}
+
+type blobListXML struct {
+	XMLName         xml.Name `xml:"EnumerationResults"`
+	ServiceEndpoint string   `xml:"ServiceEndpoint,attr"`
+	ContainerName   string   `xml:"ContainerName,attr"`
+	Prefix          string   `xml:"Prefix,omitempty"`
+	Marker          string   `xml:"Marker,omitempty"`
+	MaxResults      int      `xml:"MaxResults,omitempty"`
+	Delimiter       string   `xml:"Delimiter,omitempty"`
+	Blobs           struct {
+		BlobPrefix []blobPrefixXML `xml:"BlobPrefix"`
+		Blob       []blobItemXML   `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// listBlobs answers List Blobs. With a delimiter, names sharing a
+// prefix up to the next delimiter collapse into one BlobPrefix entry;
+// each entry (blob or prefix) counts once toward maxresults and the
+// continuation marker is the name of the first entry not returned.
+func listBlobs(w http.ResponseWriter, r *http.Request, account, container string) {
+	prefix, marker, max, include, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+	delimiter := r.URL.Query().Get("delimiter")
+	now := time.Now().UTC()
+	blobs := dataPlaneBlobs.Filter(func(b storedBlob) bool {
+		return b.Account == account && b.Container == container && strings.HasPrefix(b.Name, prefix)
+	})
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Name < blobs[j].Name })
+
+	type entry struct {
+		name   string
+		prefix bool
+		blob   storedBlob
+	}
+	var entries []entry
+	seen := map[string]bool{}
+	for _, b := range blobs {
+		if delimiter != "" {
+			if i := strings.Index(b.Name[len(prefix):], delimiter); i >= 0 {
+				p := b.Name[:len(prefix)+i+len(delimiter)]
+				if !seen[p] {
+					seen[p] = true
+					entries = append(entries, entry{name: p, prefix: true})
+				}
+				continue
+			}
+		}
+		entries = append(entries, entry{name: b.Name, blob: b})
+	}
+
+	out := blobListXML{ServiceEndpoint: storageServiceEndpoint(r), ContainerName: container,
+		Prefix: prefix, Marker: marker, Delimiter: delimiter}
+	if r.URL.Query().Get("maxresults") != "" {
+		out.MaxResults = max
+	}
+	n := 0
+	for _, e := range entries {
+		if e.name < marker {
+			continue
+		}
+		if n == max {
+			out.NextMarker = e.name
+			break
+		}
+		n++
+		if e.prefix {
+			out.Blobs.BlobPrefix = append(out.Blobs.BlobPrefix, blobPrefixXML{Name: e.name})
+			continue
+		}
+		b := e.blob
+		b.Lease.refresh(now)
+		props := blobPropertiesXML{
+			CreationTime:       b.Created.Format(http.TimeFormat),
+			LastModified:       b.LastModified.Format(http.TimeFormat),
+			Etag:               strings.Trim(b.ETag, `"`),
+			ContentLength:      len(b.Data),
+			ContentType:        b.ContentType,
+			ContentEncoding:    b.ContentEncoding,
+			ContentLanguage:    b.ContentLanguage,
+			ContentDisposition: b.ContentDisposition,
+			CacheControl:       b.CacheControl,
+			BlobType:           b.Type,
+			LeaseStatus:        "unlocked",
+			LeaseState:         b.Lease.State,
+			ServerEncrypted:    true,
+		}
+		if len(b.ContentMD5) > 0 {
+			props.ContentMD5 = base64.StdEncoding.EncodeToString(b.ContentMD5)
+		}
+		if b.Type == "BlockBlob" {
+			props.AccessTier, props.AccessTierInferred = "Hot", true
+		}
+		if b.Lease.active() {
+			props.LeaseStatus = "locked"
+		}
+		if b.Lease.State == "leased" {
+			props.LeaseDuration = b.Lease.durationName()
+		}
+		item := blobItemXML{Name: b.Name, Properties: props}
+		if slices.Contains(include, "metadata") {
+			item.Metadata = b.Metadata
+		}
+		out.Blobs.Blob = append(out.Blobs.Blob, item)
+	}
+	sim.WriteXML(w, http.StatusOK, out)
+}
@@ -0,0 +1,111 @@
+package azure_cli_test
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const storageAPIVersion = "2023-05-01"
+
+// cliStorageKey is the sim's primary account key (listKeys returns it).
+const cliStorageKey = "dGVzdGtleTEK"
+
+// createCLIStorageAccount provisions an account through ARM and returns
+// a connection string pointing the `az storage` data-plane commands at
+// the sim's {account}.{service}.localhost endpoints.
+func createCLIStorageAccount(t *testing.T, name string) string {
+	t.Helper()
+	runCLI(t, azRest("PUT", armURL("Microsoft.Storage", "storageAccounts/"+name, storageAPIVersion),
+		`{"location":"eastus","kind":"StorageV2","sku":{"name":"Standard_LRS"}}`))
+	u, _ := url.Parse(baseURL)
+	return fmt.Sprintf("DefaultEndpointsProtocol=http;AccountName=%s;AccountKey=%s;"+
+		"BlobEndpoint=http://%s.blob.localhost:%s/;QueueEndpoint=http://%s.queue.localhost:%s/",
+		name, cliStorageKey, name, u.Port(), name, u.Port())
+}
+
+// azStorage runs an `az storage` data-plane command with the shared
+// config isolation and the given connection string.
+func azStorage(connStr string, args ...string) *exec.Cmd {
+	args = append([]string{"storage"}, args...)
+	args = append(args, "--connection-string", connStr, "--output", "json")
+	cmd := exec.Command("az", args...)
+	cmd.Env = append(os.Environ(),
+		"AZURE_CONFIG_DIR="+filepath.Join(tmpDir, "azure-config"),
+		"AZURE_CORE_NO_COLOR=1",
+	)
+	return cmd
+}
+
+func TestStorageBlob_UploadListDownload(t *testing.T) {
+	conn := createCLIStorageAccount(t, "clistorageblob")
+
+	runCLI(t, azStorage(conn, "container", "create", "--name", "artifacts"))
+
+	src := filepath.Join(tmpDir, "blob-upload.txt")
+	require.NoError(t, os.WriteFile(src, []byte("hello from the cli\n"), 0o644))
+	runCLI(t, azStorage(conn, "blob", "upload", "--container-name", "artifacts",
+		"--name", "logs/run-1.txt", "--file", src, "--content-type", "text/plain"))
+
+	out := runCLI(t, azStorage(conn, "blob", "list", "--container-name", "artifacts", "--prefix", "logs/"))
+	var blobs []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			ContentLength int64 `json:"contentLength"`
+		} `json:"properties"`
+	}
+	parseJSON(t, out, &blobs)
+	require.Len(t, blobs, 1)
+	assert.Equal(t, "logs/run-1.txt", blobs[0].Name)
+	assert.Equal(t, int64(19), blobs[0].Properties.ContentLength)
+
+	dst := filepath.Join(tmpDir, "blob-download.txt")
+	runCLI(t, azStorage(conn, "blob", "download", "--container-name", "artifacts",
+		"--name", "logs/run-1.txt", "--file", dst))
+	got, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "hello from the cli\n", string(got))
+
+	// The container is visible through ARM too.
+	out = runCLI(t, azRest("GET", armURL("Microsoft.Storage",
+		"storageAccounts/clistorageblob/blobServices/default/containers/artifacts", storageAPIVersion), ""))
+	assert.Contains(t, out, `"name": "artifacts"`)
+
+	runCLI(t, azStorage(conn, "blob", "delete", "--container-name", "artifacts", "--name", "logs/run-1.txt"))
+	runCLI(t, azStorage(conn, "container", "delete", "--name", "artifacts"))
+}
+
+func TestStorageQueue_PutGetDelete(t *testing.T) {
+	conn := createCLIStorageAccount(t, "clistoragequeue")
+
+	runCLI(t, azStorage(conn, "queue", "create", "--name", "jobs"))
+	runCLI(t, azStorage(conn, "message", "put", "--queue-name", "jobs", "--content", "build 42"))
+
+	out := runCLI(t, azStorage(conn, "message", "peek", "--queue-name", "jobs"))
+	assert.Contains(t, out, "build 42")
+
+	out = runCLI(t, azStorage(conn, "message", "get", "--queue-name", "jobs", "--visibility-timeout", "60"))
+	var msgs []struct {
+		ID         string `json:"id"`
+		PopReceipt string `json:"popReceipt"`
+		Content    string `json:"content"`
+	}
+	parseJSON(t, out, &msgs)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "build 42", msgs[0].Content)
+
+	// Hidden for the visibility timeout.
+	out = runCLI(t, azStorage(conn, "message", "get", "--queue-name", "jobs"))
+	assert.Equal(t, "[]", strings.TrimSpace(out))
+
+	runCLI(t, azStorage(conn, "message", "delete", "--queue-name", "jobs",
+		"--id", msgs[0].ID, "--pop-receipt", msgs[0].PopReceipt))
+	runCLI(t, azStorage(conn, "queue", "delete", "--name", "jobs"))
+}
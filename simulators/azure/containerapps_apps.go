@@ -111,6 +111,9 @@ type AsyncOperationStatus struct {
 // in registerContainerAppsApps + reused by registerContainerApps (Jobs).
 var acaOps sim.Store[AsyncOperationStatus]
 
+// acaApps is the container-app store, shared with the metrics endpoint.
+var acaApps sim.Store[ContainerApp]
+
 var acaAppReplicaHandles sync.Map // map[resourceID][]*sim.ContainerHandle
 
 // acaIssueAsyncOp records a new operation in the Creating→Succeeded
@@ -152,6 +155,7 @@ func acaAsyncOpHeader(r *http.Request, sub, loc, opID string) string {
 
 func registerContainerAppsApps(srv *sim.Server) {
 	apps := sim.MakeStore[ContainerApp](srv.DB(), "aca_apps")
+	acaApps = apps
 	acaOps = sim.MakeStore[AsyncOperationStatus](srv.DB(), "aca_ops")
 
 	const basePath = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.App"
@@ -23,6 +23,9 @@ func handleDashboardSummary(w http.ResponseWriter, _ *http.Request) {
 			"function_sites":     azfSites.Len(),
 			"acr_registries":     acrRegistries.Len(),
 			"storage_accounts":   azStorageAccounts.Len(),
+			"blob_containers":    dataPlaneContainers.Len(),
+			"storage_blobs":      dataPlaneBlobs.Len(),
+			"storage_queues":     dataPlaneQueues.Len(),
 			"monitor_logs":       monitorLogs.Len(),
 		},
 	})
@@ -372,8 +372,8 @@ func registerAzureFiles(srv *sim.Server) {
 
 		sim.WriteJSON(w, http.StatusOK, map[string]any{
 			"keys": []map[string]any{
-				{"keyName": "key1", "value": "dGVzdGtleTEK", "permissions": "FULL"},
-				{"keyName": "key2", "value": "dGVzdGtleTIK", "permissions": "FULL"},
+				{"keyName": "key1", "value": storageAccountKeys[0], "permissions": "FULL"},
+				{"keyName": "key2", "value": storageAccountKeys[1], "permissions": "FULL"},
 			},
 		})
 	})
@@ -431,6 +431,7 @@ func registerAzureFiles(srv *sim.Server) {
 	// surface, terraform's `azurerm_storage_container` and the SDK's
 	// `armstorage.NewBlobContainersClient` 404.
 	blobContainers := sim.MakeStore[BlobContainer](srv.DB(), "blob_containers")
+	azBlobContainers = blobContainers
 	containerBasePath := armBase + "/storageAccounts/{accountName}/blobServices/default/containers"
 
 	srv.HandleFunc("PUT "+containerBasePath+"/{containerName}", func(w http.ResponseWriter, r *http.Request) {
@@ -467,6 +468,7 @@ func registerAzureFiles(srv *sim.Server) {
 			container.Properties.PublicAccess = "None"
 		}
 		blobContainers.Put(resourceID, container)
+		ensureBlobContainer(acct, name, container.Properties.PublicAccess, container.Properties.Metadata)
 		sim.WriteJSON(w, http.StatusOK, container)
 	})
 
@@ -496,6 +498,7 @@ func registerAzureFiles(srv *sim.Server) {
 			"/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Storage/storageAccounts/%s/blobServices/default/containers/%s",
 			sub, rg, acct, name)
 		blobContainers.Delete(resourceID)
+		deleteBlobContainer(acct, name)
 		w.WriteHeader(http.StatusOK)
 	})
 
@@ -516,12 +519,12 @@ func registerAzureFiles(srv *sim.Server) {
 	})
 
 	// --- Storage Data Plane Middleware ---
-	// The azurerm provider makes data-plane calls to the storage account's
-	// primaryEndpoints using subdomain-format URLs like:
+	// The azurerm provider and the storage SDKs make data-plane calls to the
+	// storage account's primaryEndpoints using subdomain-format URLs like:
 	//   https://{accountName}.blob.localhost:4568/?restype=service&comp=properties
 	// These requests arrive at the simulator with a Host header containing
 	// the subdomain. The middleware intercepts them based on the Host pattern
-	// {accountName}.{service}.localhost and returns mock XML responses.
+	// {accountName}.{service}.localhost and dispatches to the service.
 	//
 	srv.WrapHandler(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -552,9 +555,11 @@ func registerAzureFiles(srv *sim.Server) {
 	_ = fileData
 }
 
-// handleStorageDataPlane returns mock XML responses for Azure Storage data plane
-// requests. The azurerm provider reads service properties after creating storage
-// accounts (static website for blob, share retention for file, CORS for queue).
+// handleStorageDataPlane serves Azure Storage data plane requests. Service
+// properties are canned (the azurerm provider reads static website for blob,
+// share retention for file, CORS for queue after creating storage accounts);
+// blob and queue requests go to their full REST implementations in blob.go
+// and queue.go; file requests are served against the on-disk share.
 func handleStorageDataPlane(w http.ResponseWriter, r *http.Request, serviceType, accountName string, shares sim.Store[bool]) {
 	restype := r.URL.Query().Get("restype")
 	comp := r.URL.Query().Get("comp")
@@ -573,6 +578,15 @@ func handleStorageDataPlane(w http.ResponseWriter, r *http.Request, serviceType,
 		return
 	}
 
+	switch serviceType {
+	case "blob":
+		handleBlobDataPlane(w, r, accountName)
+		return
+	case "queue":
+		handleQueueDataPlane(w, r, accountName)
+		return
+	}
+
 	// File share operations: ?restype=share
 	if restype == "share" && serviceType == "file" {
 		shareName := strings.TrimPrefix(r.URL.Path, "/")
@@ -2,7 +2,8 @@
 //
 // It simulates the subset of Azure APIs used by the Sockerless ACA and
 // Azure Functions backends: Container Apps Jobs, Azure Monitor, Azure Files,
-// ACR, Private DNS, Azure Functions, and Application Insights.
+// Blob and Queue storage, ACR, Private DNS, Azure Functions, and
+// Application Insights.
 //
 // Configure with environment variables:
 //
@@ -67,10 +68,14 @@ func main() {
 	registerContainerApps(srv)
 	registerContainerAppsApps(srv)
 	registerAzureFiles(srv)
+	registerBlobStorage(srv)
+	registerQueueStorage(srv)
 	registerACR(srv)
 	registerPrivateDNS(srv)
 	registerAzureFunctions(srv)
 	registerApplicationInsights(srv)
+	registerAzureMonitorMetrics(srv)
+	registerResourceGraph(srv)
 
 	// Cloud metadata (for Terraform provider metadata_host)
 	registerMetadata(srv)
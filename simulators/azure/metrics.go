@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// Azure Monitor metrics types (Microsoft.Insights/metrics, REST JSON).
+
+type MetricsResponse struct {
+	Cost           int32         `json:"cost"`
+	Timespan       string        `json:"timespan"`
+	Interval       string        `json:"interval"`
+	Namespace      string        `json:"namespace,omitempty"`
+	ResourceRegion string        `json:"resourceregion,omitempty"`
+	Value          []MetricEntry `json:"value"`
+}
+
+type MetricEntry struct {
+	ID         string             `json:"id"`
+	Type       string             `json:"type"`
+	Name       MetricName         `json:"name"`
+	Unit       string             `json:"unit"`
+	TimeSeries []MetricTimeSeries `json:"timeseries"`
+}
+
+type MetricName struct {
+	Value          string `json:"value"`
+	LocalizedValue string `json:"localizedValue"`
+}
+
+type MetricTimeSeries struct {
+	Data []MetricDataPoint `json:"data"`
+}
+
+type MetricDataPoint struct {
+	TimeStamp string   `json:"timeStamp"`
+	Average   *float64 `json:"average,omitempty"`
+	Total     *float64 `json:"total,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+}
+
+// simMetricValues are the per-minute values the sim reports for a
+// running resource: 0.15 cores and 128 MiB, in each metric's unit.
+var simMetricValues = map[string]struct {
+	unit  string
+	value float64
+}{
+	"UsageNanoCores":   {"NanoCores", 0.15e9},
+	"CpuTime":          {"Seconds", 0.15 * 60},
+	"WorkingSetBytes":  {"Bytes", 128 << 20},
+	"UsageBytes":       {"Bytes", 128 << 20},
+	"MemoryWorkingSet": {"Bytes", 128 << 20},
+}
+
+func registerAzureMonitorMetrics(srv *sim.Server) {
+	const rgBase = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers"
+	const metrics = "/providers/Microsoft.Insights/metrics"
+	srv.HandleFunc("GET "+rgBase+"/Microsoft.App/jobs/{name}"+metrics, handleListMetrics)
+	srv.HandleFunc("GET "+rgBase+"/Microsoft.App/containerApps/{name}"+metrics, handleListMetrics)
+	srv.HandleFunc("GET "+rgBase+"/Microsoft.Web/sites/{name}"+metrics, handleListMetrics)
+}
+
+// handleListMetrics serves Metrics - List for container app jobs,
+// container apps and function apps. The sim has no real usage data, so
+// every requested metric it knows gets one datapoint for the last full
+// minute, with the value in every requested aggregation.
+func handleListMetrics(w http.ResponseWriter, r *http.Request) {
+	resourceID := strings.TrimSuffix(r.URL.Path, "/providers/Microsoft.Insights/metrics")
+	if !metricsResourceExists(resourceID) {
+		sim.AzureErrorf(w, "ResourceNotFound", http.StatusNotFound, "The Resource '%s' was not found.", resourceID)
+		return
+	}
+	q := r.URL.Query()
+	names := q.Get("metricnames")
+	if names == "" {
+		sim.AzureError(w, "BadRequest", "metricnames is required.", http.StatusBadRequest)
+		return
+	}
+	aggregations := strings.ToLower(q.Get("aggregation"))
+	if aggregations == "" {
+		aggregations = "average"
+	}
+
+	grain := time.Now().UTC().Truncate(time.Minute).Add(-time.Minute)
+	resp := MetricsResponse{
+		Timespan: q.Get("timespan"),
+		Interval: "PT1M",
+		Value:    []MetricEntry{},
+	}
+	for _, name := range strings.Split(names, ",") {
+		mv, ok := simMetricValues[name]
+		if !ok {
+			sim.AzureErrorf(w, "BadRequest", http.StatusBadRequest, "Metric '%s' is not supported for this resource.", name)
+			return
+		}
+		v := mv.value
+		point := MetricDataPoint{TimeStamp: grain.Format(time.RFC3339)}
+		for _, agg := range strings.Split(aggregations, ",") {
+			switch agg {
+			case "average":
+				point.Average = &v
+			case "total":
+				point.Total = &v
+			case "maximum":
+				point.Maximum = &v
+			}
+		}
+		resp.Value = append(resp.Value, MetricEntry{
+			ID:         resourceID + "/providers/Microsoft.Insights/metrics/" + name,
+			Type:       "Microsoft.Insights/metrics",
+			Name:       MetricName{Value: name, LocalizedValue: name},
+			Unit:       mv.unit,
+			TimeSeries: []MetricTimeSeries{{Data: []MetricDataPoint{point}}},
+		})
+	}
+	sim.WriteJSON(w, http.StatusOK, resp)
+}
+
+func metricsResourceExists(resourceID string) bool {
+	if _, ok := acaJobs.Get(resourceID); ok {
+		return true
+	}
+	if _, ok := acaApps.Get(resourceID); ok {
+		return true
+	}
+	_, ok := azfSites.Get(resourceID)
+	return ok
+}
@@ -0,0 +1,658 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// storageQueue is a queue in a storage account's Queue service. The
+// Function App runtime and queue-triggered workloads reach it through
+// AzureWebJobsStorage.
+type storageQueue struct {
+	Account  string            `json:"account"`
+	Name     string            `json:"name"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// queueMessage is one message. A message is visible to Get Messages
+// once NextVisible has passed and disappears at Expires (zero means it
+// never expires, messagettl=-1).
+type queueMessage struct {
+	Account      string    `json:"account"`
+	Queue        string    `json:"queue"`
+	ID           string    `json:"id"`
+	Text         string    `json:"text"`
+	Inserted     time.Time `json:"inserted"`
+	Expires      time.Time `json:"expires,omitempty"`
+	NextVisible  time.Time `json:"nextVisible"`
+	DequeueCount int       `json:"dequeueCount"`
+	PopReceipt   string    `json:"popReceipt,omitempty"`
+	// Seq orders messages inserted within the same clock tick.
+	Seq uint64 `json:"seq"`
+}
+
+// Queue service limits enforced by the sim.
+const (
+	maxQueueMessageSize    = 64 << 10
+	maxQueueVisibility     = 7 * 24 * time.Hour
+	defaultQueueTTL        = 7 * 24 * time.Hour
+	defaultQueueVisibility = 30 * time.Second
+	maxMessagesPerGet      = 32
+)
+
+var (
+	dataPlaneQueues   sim.Store[storageQueue]
+	dataPlaneMessages sim.Store[queueMessage]
+
+	// queueMu serialises message visibility changes so two consumers
+	// can never dequeue the same message.
+	queueMu  sync.Mutex
+	queueSeq atomic.Uint64
+)
+
+// StorageQueueResource is the ARM view of a queue
+// (Microsoft.Storage/storageAccounts/queueServices/queues), as managed
+// by azurerm_storage_queue with storage_account_id and armstorage's
+// QueueClient. It is derived from the data-plane queue on each read.
+type StorageQueueResource struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	Properties StorageQueueProperties `json:"properties"`
+}
+
+// StorageQueueProperties mirrors the ARM `QueueProperties` shape.
+type StorageQueueProperties struct {
+	Metadata                map[string]string `json:"metadata,omitempty"`
+	ApproximateMessageCount int               `json:"approximateMessageCount"`
+}
+
+func registerQueueStorage(srv *sim.Server) {
+	dataPlaneQueues = sim.MakeStore[storageQueue](srv.DB(), "queue_data_plane_queues")
+	dataPlaneMessages = sim.MakeStore[queueMessage](srv.DB(), "queue_data_plane_messages")
+
+	// --- Queues (ARM control plane) ---
+	// ARM and the data plane share one queue store, so a queue created
+	// by terraform is immediately usable with a SAS or account key.
+	const queueBasePath = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Storage/storageAccounts/{accountName}/queueServices/default/queues"
+
+	// armQueueAccount resolves the storage account a queue route names,
+	// writing a 404 when it doesn't exist in that resource group.
+	armQueueAccount := func(w http.ResponseWriter, r *http.Request) (StorageAccount, bool) {
+		id := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Storage/storageAccounts/%s",
+			sim.PathParam(r, "subscriptionId"), sim.PathParam(r, "resourceGroupName"), sim.PathParam(r, "accountName"))
+		acct, ok := azStorageAccounts.Get(id)
+		if !ok {
+			sim.AzureErrorf(w, "ResourceNotFound", http.StatusNotFound,
+				"Storage account %q not found", sim.PathParam(r, "accountName"))
+		}
+		return acct, ok
+	}
+	armQueue := func(acct StorageAccount, q storageQueue) StorageQueueResource {
+		return StorageQueueResource{
+			ID:   acct.ID + "/queueServices/default/queues/" + q.Name,
+			Name: q.Name,
+			Type: "Microsoft.Storage/storageAccounts/queueServices/queues",
+			Properties: StorageQueueProperties{
+				Metadata:                q.Metadata,
+				ApproximateMessageCount: len(liveMessages(q.Account, q.Name, time.Now().UTC())),
+			},
+		}
+	}
+
+	srv.HandleFunc("PUT "+queueBasePath+"/{queueName}", func(w http.ResponseWriter, r *http.Request) {
+		acct, ok := armQueueAccount(w, r)
+		if !ok {
+			return
+		}
+		name := sim.PathParam(r, "queueName")
+		if !validStorageName(name) {
+			sim.AzureErrorf(w, "InvalidResourceName", http.StatusBadRequest, "The specified resource name %q is not valid.", name)
+			return
+		}
+		var req StorageQueueResource
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.AzureErrorf(w, "BadRequest", http.StatusBadRequest, "invalid request body: %v", err)
+			return
+		}
+		queueMu.Lock()
+		q := storageQueue{Account: acct.Name, Name: name, Metadata: req.Properties.Metadata}
+		dataPlaneQueues.Put(queueKey(acct.Name, name), q)
+		res := armQueue(acct, q)
+		queueMu.Unlock()
+		sim.WriteJSON(w, http.StatusOK, res)
+	})
+
+	srv.HandleFunc("GET "+queueBasePath+"/{queueName}", func(w http.ResponseWriter, r *http.Request) {
+		acct, ok := armQueueAccount(w, r)
+		if !ok {
+			return
+		}
+		name := sim.PathParam(r, "queueName")
+		queueMu.Lock()
+		defer queueMu.Unlock()
+		q, ok := dataPlaneQueues.Get(queueKey(acct.Name, name))
+		if !ok {
+			sim.AzureErrorf(w, "ResourceNotFound", http.StatusNotFound,
+				"Queue %q not found in storage account %q", name, acct.Name)
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, armQueue(acct, q))
+	})
+
+	srv.HandleFunc("DELETE "+queueBasePath+"/{queueName}", func(w http.ResponseWriter, r *http.Request) {
+		acct, ok := armQueueAccount(w, r)
+		if !ok {
+			return
+		}
+		name := sim.PathParam(r, "queueName")
+		queueMu.Lock()
+		defer queueMu.Unlock()
+		if _, ok := dataPlaneQueues.Get(queueKey(acct.Name, name)); !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		dataPlaneQueues.Delete(queueKey(acct.Name, name))
+		clearQueueMessages(acct.Name, name)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv.HandleFunc("GET "+queueBasePath, func(w http.ResponseWriter, r *http.Request) {
+		acct, ok := armQueueAccount(w, r)
+		if !ok {
+			return
+		}
+		queueMu.Lock()
+		defer queueMu.Unlock()
+		qs := dataPlaneQueues.Filter(func(q storageQueue) bool { return q.Account == acct.Name })
+		sort.Slice(qs, func(i, j int) bool { return qs[i].Name < qs[j].Name })
+		all := []StorageQueueResource{}
+		for _, q := range qs {
+			all = append(all, armQueue(acct, q))
+		}
+		sim.WriteJSON(w, http.StatusOK, map[string]any{"value": all})
+	})
+}
+
+func queueKey(account, queue string) string { return account + "/" + queue }
+
+func messageKey(account, queue, id string) string { return account + "/" + queue + "/" + id }
+
+// validStorageName reports whether name is a valid container or queue
+// name: 3-63 lowercase letters, digits and single hyphens, starting and
+// ending with a letter or digit.
+func validStorageName(name string) bool {
+	if len(name) < 3 || len(name) > 63 || name[0] == '-' || name[len(name)-1] == '-' || strings.Contains(name, "--") {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// liveMessages returns a queue's unexpired messages in insertion order,
+// deleting any that have expired.
+func liveMessages(account, queue string, now time.Time) []queueMessage {
+	var live []queueMessage
+	for _, m := range dataPlaneMessages.Filter(func(m queueMessage) bool { return m.Account == account && m.Queue == queue }) {
+		if !m.Expires.IsZero() && !now.Before(m.Expires) {
+			dataPlaneMessages.Delete(messageKey(m.Account, m.Queue, m.ID))
+			continue
+		}
+		live = append(live, m)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].Seq < live[j].Seq })
+	return live
+}
+
+// handleQueueDataPlane serves the Queue service REST API for one
+// storage account: queue create / delete / metadata / ACL / list and
+// message put, get (with visibility timeout), peek, update, delete and
+// clear.
+func handleQueueDataPlane(w http.ResponseWriter, r *http.Request, account string) {
+	setStorageResponseHeaders(w, r)
+	if _, ok := storageAccountByName(account); !ok {
+		storageErrorf(w, http.StatusNotFound, "ResourceNotFound", "The storage account %q does not exist.", account)
+		return
+	}
+	comp := r.URL.Query().Get("comp")
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case parts[0] == "":
+		if comp != "list" || r.Method != http.MethodGet {
+			storageErrorf(w, http.StatusBadRequest, "UnsupportedOperation",
+				"%s on the queue service root with comp=%q is not supported by the simulator.", r.Method, comp)
+			return
+		}
+		if authorizeStorage(w, r, account, storageAccess{service: "queue", perms: "l", level: 's'}) {
+			listQueues(w, r, account)
+		}
+	case len(parts) == 1:
+		handleQueue(w, r, account, parts[0], comp)
+	case len(parts) == 2 && parts[1] == "messages":
+		handleQueueMessages(w, r, account, parts[0])
+	case len(parts) == 3 && parts[1] == "messages":
+		handleQueueMessage(w, r, account, parts[0], parts[2])
+	default:
+		storageError(w, http.StatusBadRequest, "InvalidUri", "The requested URI does not represent any resource on the server.")
+	}
+}
+
+func handleQueue(w http.ResponseWriter, r *http.Request, account, name, comp string) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+
+	key := queueKey(account, name)
+	q, exists := dataPlaneQueues.Get(key)
+	authorize := func(perms string) bool {
+		if !authorizeStorage(w, r, account, storageAccess{service: "queue", container: name, perms: perms, level: 'c'}) {
+			return false
+		}
+		if !exists {
+			storageError(w, http.StatusNotFound, "QueueNotFound", "The specified queue does not exist.")
+			return false
+		}
+		return true
+	}
+
+	switch {
+	case comp == "" && r.Method == http.MethodPut:
+		if !authorizeStorage(w, r, account, storageAccess{service: "queue", container: name, perms: "c", level: 'c'}) {
+			return
+		}
+		if !validStorageName(name) {
+			storageErrorf(w, http.StatusBadRequest, "InvalidResourceName", "The specified resource name %q is not valid.", name)
+			return
+		}
+		md := storageMetadata(r)
+		if exists {
+			// Re-creating with identical metadata is idempotent.
+			if !maps.Equal(q.Metadata, md) {
+				storageError(w, http.StatusConflict, "QueueAlreadyExists",
+					"The specified queue already exists with different metadata.")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		dataPlaneQueues.Put(key, storageQueue{Account: account, Name: name, Metadata: md})
+		w.WriteHeader(http.StatusCreated)
+
+	case comp == "" && r.Method == http.MethodDelete:
+		if !authorize("d") {
+			return
+		}
+		dataPlaneQueues.Delete(key)
+		clearQueueMessages(account, name)
+		w.WriteHeader(http.StatusNoContent)
+
+	case comp == "metadata" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		if !authorize("r") {
+			return
+		}
+		setMetadataHeaders(w, q.Metadata)
+		w.Header().Set("x-ms-approximate-messages-count", strconv.Itoa(len(liveMessages(account, name, time.Now().UTC()))))
+		w.WriteHeader(http.StatusOK)
+
+	case comp == "metadata" && r.Method == http.MethodPut:
+		if !authorize("w") {
+			return
+		}
+		q.Metadata = storageMetadata(r)
+		dataPlaneQueues.Put(key, q)
+		w.WriteHeader(http.StatusNoContent)
+
+	case comp == "acl" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		if !authorize("r") {
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><SignedIdentifiers />`)
+		}
+
+	case comp == "acl" && r.Method == http.MethodPut:
+		if !authorize("w") {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			storageErrorf(w, http.StatusBadRequest, "InvalidInput", "read request body: %v", err)
+			return
+		}
+		if strings.Contains(string(body), "<SignedIdentifier>") {
+			storageError(w, http.StatusNotImplemented, "NotImplemented",
+				"Stored access policies (signed identifiers) are not supported by the simulator.")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		storageErrorf(w, http.StatusBadRequest, "UnsupportedOperation",
+			"%s on a queue with comp=%q is not supported by the simulator.", r.Method, comp)
+	}
+}
+
+func clearQueueMessages(account, queue string) {
+	for _, m := range dataPlaneMessages.Filter(func(m queueMessage) bool { return m.Account == account && m.Queue == queue }) {
+		dataPlaneMessages.Delete(messageKey(m.Account, m.Queue, m.ID))
+	}
+}
+
+// queueSeconds parses an integer-seconds query parameter, returning def
+// when absent and an error when outside [lo, hi].
+func queueSeconds(r *http.Request, name string, def, lo, hi time.Duration) (time.Duration, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	d := time.Duration(n) * time.Second
+	if err != nil || d < lo || d > hi {
+		return 0, fmt.Errorf("%s %q must be between %d and %d seconds", name, v, int(lo/time.Second), int(hi/time.Second))
+	}
+	return d, nil
+}
+
+type queueMessageXML struct {
+	MessageID       string  `xml:"MessageId"`
+	InsertionTime   string  `xml:"InsertionTime"`
+	ExpirationTime  string  `xml:"ExpirationTime"`
+	PopReceipt      string  `xml:"PopReceipt,omitempty"`
+	TimeNextVisible string  `xml:"TimeNextVisible,omitempty"`
+	DequeueCount    *int    `xml:"DequeueCount,omitempty"`
+	MessageText     *string `xml:"MessageText,omitempty"`
+}
+
+type queueMessagesListXML struct {
+	XMLName  xml.Name          `xml:"QueueMessagesList"`
+	Messages []queueMessageXML `xml:"QueueMessage"`
+}
+
+// neverExpires is what Azure reports as the expiration time of a
+// message put with messagettl=-1.
+var neverExpires = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+func (m queueMessage) expiration() string {
+	if m.Expires.IsZero() {
+		return neverExpires.Format(http.TimeFormat)
+	}
+	return m.Expires.Format(http.TimeFormat)
+}
+
+func handleQueueMessages(w http.ResponseWriter, r *http.Request, account, queue string) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+
+	_, exists := dataPlaneQueues.Get(queueKey(account, queue))
+	peek := r.URL.Query().Get("peekonly") == "true"
+	perms := map[string]string{http.MethodPost: "a", http.MethodGet: "p", http.MethodDelete: "d"}[r.Method]
+	if peek {
+		perms = "r"
+	}
+	if perms == "" {
+		storageErrorf(w, http.StatusMethodNotAllowed, "UnsupportedHttpVerb", "%s is not supported on messages.", r.Method)
+		return
+	}
+	if !authorizeStorage(w, r, account, storageAccess{service: "queue", container: queue, perms: perms, level: 'o'}) {
+		return
+	}
+	if !exists {
+		storageError(w, http.StatusNotFound, "QueueNotFound", "The specified queue does not exist.")
+		return
+	}
+	now := time.Now().UTC()
+
+	switch r.Method {
+	case http.MethodPost:
+		putQueueMessage(w, r, account, queue, now)
+
+	case http.MethodGet:
+		n := 1
+		if v := r.URL.Query().Get("numofmessages"); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil || n < 1 || n > maxMessagesPerGet {
+				storageErrorf(w, http.StatusBadRequest, "OutOfRangeQueryParameterValue",
+					"numofmessages %q must be between 1 and %d.", v, maxMessagesPerGet)
+				return
+			}
+		}
+		vt, err := queueSeconds(r, "visibilitytimeout", defaultQueueVisibility, time.Second, maxQueueVisibility)
+		if err != nil && !peek {
+			storageError(w, http.StatusBadRequest, "OutOfRangeQueryParameterValue", err.Error())
+			return
+		}
+		var out queueMessagesListXML
+		for _, m := range liveMessages(account, queue, now) {
+			if len(out.Messages) == n {
+				break
+			}
+			if now.Before(m.NextVisible) {
+				continue
+			}
+			if !peek {
+				m.DequeueCount++
+				m.PopReceipt = generateUUID()
+				m.NextVisible = now.Add(vt)
+				dataPlaneMessages.Put(messageKey(account, queue, m.ID), m)
+			}
+			text, count := m.Text, m.DequeueCount
+			item := queueMessageXML{
+				MessageID:      m.ID,
+				InsertionTime:  m.Inserted.Format(http.TimeFormat),
+				ExpirationTime: m.expiration(),
+				DequeueCount:   &count,
+				MessageText:    &text,
+			}
+			if !peek {
+				item.PopReceipt = m.PopReceipt
+				item.TimeNextVisible = m.NextVisible.Format(http.TimeFormat)
+			}
+			out.Messages = append(out.Messages, item)
+		}
+		sim.WriteXML(w, http.StatusOK, out)
+
+	case http.MethodDelete:
+		clearQueueMessages(account, queue)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func putQueueMessage(w http.ResponseWriter, r *http.Request, account, queue string, now time.Time) {
+	vt, err := queueSeconds(r, "visibilitytimeout", 0, 0, maxQueueVisibility)
+	if err != nil {
+		storageError(w, http.StatusBadRequest, "OutOfRangeQueryParameterValue", err.Error())
+		return
+	}
+	ttl := defaultQueueTTL
+	if v := r.URL.Query().Get("messagettl"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n == 0 || n < -1 {
+			storageErrorf(w, http.StatusBadRequest, "OutOfRangeQueryParameterValue",
+				"messagettl %q must be -1 or a positive number of seconds.", v)
+			return
+		}
+		ttl = time.Duration(n) * time.Second
+	}
+	if ttl > 0 && vt >= ttl {
+		storageError(w, http.StatusBadRequest, "OutOfRangeQueryParameterValue",
+			"visibilitytimeout must be smaller than messagettl.")
+		return
+	}
+	text, ok := readQueueMessageText(w, r)
+	if !ok {
+		return
+	}
+	m := queueMessage{
+		Account:     account,
+		Queue:       queue,
+		ID:          generateUUID(),
+		Text:        text,
+		Inserted:    now,
+		NextVisible: now.Add(vt),
+		PopReceipt:  generateUUID(),
+		Seq:         queueSeq.Add(1),
+	}
+	if ttl > 0 {
+		m.Expires = now.Add(ttl)
+	}
+	dataPlaneMessages.Put(messageKey(account, queue, m.ID), m)
+	sim.WriteXML(w, http.StatusCreated, queueMessagesListXML{Messages: []queueMessageXML{{
+		MessageID:       m.ID,
+		InsertionTime:   m.Inserted.Format(http.TimeFormat),
+		ExpirationTime:  m.expiration(),
+		PopReceipt:      m.PopReceipt,
+		TimeNextVisible: m.NextVisible.Format(http.TimeFormat),
+	}}})
+}
+
+// readQueueMessageText decodes a <QueueMessage><MessageText> body. The
+// text is stored verbatim: SDKs that base64-encode messages decode them
+// again on receipt.
+func readQueueMessageText(w http.ResponseWriter, r *http.Request) (string, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 2*maxQueueMessageSize))
+	if err != nil {
+		storageErrorf(w, http.StatusBadRequest, "InvalidInput", "read request body: %v", err)
+		return "", false
+	}
+	var msg struct {
+		XMLName     xml.Name `xml:"QueueMessage"`
+		MessageText string   `xml:"MessageText"`
+	}
+	if err := xml.Unmarshal(body, &msg); err != nil {
+		storageErrorf(w, http.StatusBadRequest, "InvalidXmlDocument", "XML specified is not syntactically valid: %v", err)
+		return "", false
+	}
+	if len(msg.MessageText) > maxQueueMessageSize {
+		storageErrorf(w, http.StatusRequestEntityTooLarge, "RequestBodyTooLarge",
+			"The message exceeds the maximum allowed size of %d bytes.", maxQueueMessageSize)
+		return "", false
+	}
+	return msg.MessageText, true
+}
+
+// handleQueueMessage serves Delete Message and Update Message, both of
+// which must present the pop receipt of the latest Get Messages.
+func handleQueueMessage(w http.ResponseWriter, r *http.Request, account, queue, id string) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+
+	perms := map[string]string{http.MethodDelete: "p", http.MethodPut: "u"}[r.Method]
+	if perms == "" {
+		storageErrorf(w, http.StatusMethodNotAllowed, "UnsupportedHttpVerb", "%s is not supported on a message.", r.Method)
+		return
+	}
+	if !authorizeStorage(w, r, account, storageAccess{service: "queue", container: queue, perms: perms, level: 'o'}) {
+		return
+	}
+	if _, ok := dataPlaneQueues.Get(queueKey(account, queue)); !ok {
+		storageError(w, http.StatusNotFound, "QueueNotFound", "The specified queue does not exist.")
+		return
+	}
+	now := time.Now().UTC()
+	key := messageKey(account, queue, id)
+	m, ok := dataPlaneMessages.Get(key)
+	if !ok || (!m.Expires.IsZero() && !now.Before(m.Expires)) {
+		storageError(w, http.StatusNotFound, "MessageNotFound", "The specified message does not exist.")
+		return
+	}
+	pop := r.URL.Query().Get("popreceipt")
+	if pop == "" {
+		storageError(w, http.StatusBadRequest, "MissingRequiredQueryParameter", "popreceipt is required.")
+		return
+	}
+	if pop != m.PopReceipt {
+		storageError(w, http.StatusBadRequest, "PopReceiptMismatch",
+			"The specified pop receipt did not match the pop receipt for a dequeued message.")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		dataPlaneMessages.Delete(key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.URL.Query().Get("visibilitytimeout") == "" {
+		storageError(w, http.StatusBadRequest, "MissingRequiredQueryParameter", "visibilitytimeout is required.")
+		return
+	}
+	vt, err := queueSeconds(r, "visibilitytimeout", 0, 0, maxQueueVisibility)
+	if err != nil {
+		storageError(w, http.StatusBadRequest, "OutOfRangeQueryParameterValue", err.Error())
+		return
+	}
+	if r.ContentLength != 0 {
+		text, ok := readQueueMessageText(w, r)
+		if !ok {
+			return
+		}
+		m.Text = text
+	}
+	m.PopReceipt = generateUUID()
+	m.NextVisible = now.Add(vt)
+	dataPlaneMessages.Put(key, m)
+	w.Header().Set("x-ms-popreceipt", m.PopReceipt)
+	w.Header().Set("x-ms-time-next-visible", m.NextVisible.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type queueItemXML struct {
+	Name     string      `xml:"Name"`
+	Metadata metadataXML `xml:"Metadata,omitempty"`
+}
+
+type queueListXML struct {
+	XMLName         xml.Name       `xml:"EnumerationResults"`
+	ServiceEndpoint string         `xml:"ServiceEndpoint,attr"`
+	Prefix          string         `xml:"Prefix,omitempty"`
+	Marker          string         `xml:"Marker,omitempty"`
+	MaxResults      int            `xml:"MaxResults,omitempty"`
+	Queues          []queueItemXML `xml:"Queues>Queue"`
+	NextMarker      string         `xml:"NextMarker"`
+}
+
+func listQueues(w http.ResponseWriter, r *http.Request, account string) {
+	prefix, marker, max, include, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+	qs := dataPlaneQueues.Filter(func(q storageQueue) bool {
+		return q.Account == account && strings.HasPrefix(q.Name, prefix) && q.Name >= marker
+	})
+	sort.Slice(qs, func(i, j int) bool { return qs[i].Name < qs[j].Name })
+
+	out := queueListXML{ServiceEndpoint: storageServiceEndpoint(r), Prefix: prefix, Marker: marker}
+	if r.URL.Query().Get("maxresults") != "" {
+		out.MaxResults = max
+	}
+	for i, q := range qs {
+		if i == max {
+			out.NextMarker = q.Name
+			break
+		}
+		item := queueItemXML{Name: q.Name}
+		if slices.Contains(include, "metadata") {
+			item.Metadata = q.Metadata
+		}
+		out.Queues = append(out.Queues, item)
+	}
+	sim.WriteXML(w, http.StatusOK, out)
+}
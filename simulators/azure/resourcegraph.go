@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// Azure Resource Graph (Microsoft.ResourceGraph/resources, REST JSON).
+// The sim serves the securityresources table only, holding the
+// container-image vulnerability sub-assessments Defender for Containers
+// publishes.
+
+// SecurityResource is one securityresources row.
+type SecurityResource struct {
+	ID             string         `json:"id"`
+	Name           string         `json:"name"`
+	Type           string         `json:"type"`
+	SubscriptionID string         `json:"subscriptionId"`
+	Properties     map[string]any `json:"properties"`
+}
+
+// defenderACRAssessment is the assessment key of "Azure registry
+// container images should have vulnerabilities resolved".
+const defenderACRAssessment = "c0b7cfc6-3172-465a-b378-53c7ff2cc0d5"
+
+var securityResources sim.Store[SecurityResource]
+
+func registerResourceGraph(srv *sim.Server) {
+	securityResources = sim.MakeStore[SecurityResource](srv.DB(), "resourcegraph_securityresources")
+
+	srv.HandleFunc("POST /providers/Microsoft.ResourceGraph/resources", handleResourceGraphQuery)
+
+	// Sub-assessment seeding. Defender assesses images on push against
+	// its own vulnerability database, which the sim doesn't have; tests
+	// register the sub-assessments an assessment would produce:
+	// {"subscriptionId", "subAssessments": [{<properties>}, …]}.
+	srv.HandleFunc("POST /sim/v1/defender/subassessments", handleSeedSubAssessments)
+}
+
+func handleResourceGraphQuery(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Subscriptions []string `json:"subscriptions"`
+		Query         string   `json:"query"`
+		Options       struct {
+			Top       int    `json:"$top"`
+			SkipToken string `json:"$skipToken"`
+		} `json:"options"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.AzureError(w, "InvalidRequest", "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	q := parseKQL(req.Query)
+	if !strings.EqualFold(q.Table, "securityresources") {
+		sim.AzureErrorf(w, "BadRequest", http.StatusBadRequest, "Table '%s' is not supported by the simulator; only securityresources is", q.Table)
+		return
+	}
+	for _, f := range q.Filters {
+		if f.Operator != "==" {
+			sim.AzureErrorf(w, "BadRequest", http.StatusBadRequest, "Operator '%s' on '%s' is not supported by the simulator", f.Operator, f.Field)
+			return
+		}
+	}
+	subs := map[string]bool{}
+	for _, s := range req.Subscriptions {
+		subs[s] = true
+	}
+
+	var rows []map[string]any
+	for _, res := range securityResources.List() {
+		if len(subs) > 0 && !subs[res.SubscriptionID] {
+			continue
+		}
+		row := map[string]any{
+			"id":             res.ID,
+			"name":           res.Name,
+			"type":           res.Type,
+			"subscriptionId": res.SubscriptionID,
+			"properties":     res.Properties,
+		}
+		if resourceGraphMatches(row, q.Filters) {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i]["id"].(string) < rows[j]["id"].(string) })
+	if q.Limit >= 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+	}
+	if len(q.Project) > 0 {
+		for i, row := range rows {
+			projected := map[string]any{}
+			for _, col := range q.Project {
+				v, _ := resourceGraphLookup(row, col)
+				projected[col[strings.LastIndex(col, ".")+1:]] = v
+			}
+			rows[i] = projected
+		}
+	}
+
+	total := len(rows)
+	start := 0
+	if req.Options.SkipToken != "" {
+		n, err := strconv.Atoi(req.Options.SkipToken)
+		if err != nil || n < 0 || n > total {
+			sim.AzureErrorf(w, "BadRequest", http.StatusBadRequest, "Invalid $skipToken '%s'", req.Options.SkipToken)
+			return
+		}
+		start = n
+	}
+	top := 1000
+	if req.Options.Top > 0 && req.Options.Top < top {
+		top = req.Options.Top
+	}
+	end := min(start+top, total)
+	page := rows[start:end]
+	if page == nil {
+		page = []map[string]any{}
+	}
+	resp := map[string]any{
+		"totalRecords":    total,
+		"count":           len(page),
+		"resultTruncated": "false",
+		"data":            page,
+		"facets":          []any{},
+	}
+	if end < total {
+		resp["$skipToken"] = strconv.Itoa(end)
+	}
+	sim.WriteJSON(w, http.StatusOK, resp)
+}
+
+// resourceGraphMatches reports whether every == filter matches the
+// row. Fields are dotted paths into the row (properties.status.code).
+func resourceGraphMatches(row map[string]any, filters []kqlFilter) bool {
+	for _, f := range filters {
+		v, ok := resourceGraphLookup(row, f.Field)
+		if !ok || fmt.Sprint(v) != f.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func resourceGraphLookup(row map[string]any, path string) (any, bool) {
+	var cur any = row
+	for _, seg := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[seg]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func handleSeedSubAssessments(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SubscriptionID string           `json:"subscriptionId"`
+		SubAssessments []map[string]any `json:"subAssessments"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.SubscriptionID == "" {
+		http.Error(w, "subscriptionId is required", http.StatusBadRequest)
+		return
+	}
+	for _, props := range req.SubAssessments {
+		artifact, _ := resourceGraphLookup(props, "additionalData.artifactDetails")
+		a, _ := artifact.(map[string]any)
+		if a["registryHost"] == nil || a["repositoryName"] == nil || a["digest"] == nil {
+			http.Error(w, "each sub-assessment needs additionalData.artifactDetails registryHost, repositoryName and digest", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, props := range req.SubAssessments {
+		name := generateUUID()
+		securityResources.Put(name, SecurityResource{
+			ID: fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Security/assessments/%s/subAssessments/%s",
+				req.SubscriptionID, defenderACRAssessment, name),
+			Name:           name,
+			Type:           "microsoft.security/assessments/subassessments",
+			SubscriptionID: req.SubscriptionID,
+			Properties:     props,
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,6 +3,7 @@ package azure_sdk_test
 import (
 	"testing"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/stretchr/testify/assert"
@@ -142,3 +143,90 @@ func TestACR_CacheRuleIdempotentUpsert(t *testing.T) {
 }
 
 func ptrSKU(s armcontainerregistry.SKUName) *armcontainerregistry.SKUName { return &s }
+
+// TestACR_CredentialSetCacheRule covers an authenticated cache rule:
+// the credential set must exist before the rule references it, and
+// cannot be deleted while the rule still does.
+func TestACR_CredentialSetCacheRule(t *testing.T) {
+	const (
+		rgName       = "acr-credset-rg"
+		registryName = "credsetregistry"
+		setName      = "sockerless-ghcr-io"
+	)
+
+	rgClient, err := armresources.NewResourceGroupsClient(subscriptionID, &fakeCredential{}, clientOpts())
+	require.NoError(t, err)
+	_, err = rgClient.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{
+		Location: ptrStr("eastus"),
+	}, nil)
+	require.NoError(t, err)
+
+	registriesClient, err := armcontainerregistry.NewRegistriesClient(subscriptionID, &fakeCredential{}, clientOpts())
+	require.NoError(t, err)
+	regPoller, err := registriesClient.BeginCreate(ctx, rgName, registryName, armcontainerregistry.Registry{
+		Location: ptrStr("eastus"),
+		SKU:      &armcontainerregistry.SKU{Name: ptrSKU(armcontainerregistry.SKUNameBasic)},
+	}, nil)
+	require.NoError(t, err)
+	_, err = regPoller.PollUntilDone(ctx, nil)
+	require.NoError(t, err)
+
+	setsClient, err := armcontainerregistry.NewCredentialSetsClient(subscriptionID, &fakeCredential{}, clientOpts())
+	require.NoError(t, err)
+	rulesClient, err := armcontainerregistry.NewCacheRulesClient(subscriptionID, &fakeCredential{}, clientOpts())
+	require.NoError(t, err)
+
+	// A rule naming a credential set that does not exist is rejected.
+	_, err = rulesClient.BeginCreate(ctx, rgName, registryName, setName, armcontainerregistry.CacheRule{
+		Properties: &armcontainerregistry.CacheRuleProperties{
+			SourceRepository:        ptrStr("ghcr.io/*"),
+			TargetRepository:        ptrStr("ghcr-io/*"),
+			CredentialSetResourceID: ptrStr("/subscriptions/" + subscriptionID + "/resourceGroups/" + rgName + "/providers/Microsoft.ContainerRegistry/registries/" + registryName + "/credentialSets/missing"),
+		},
+	}, nil)
+	require.Error(t, err)
+
+	setPoller, err := setsClient.BeginCreate(ctx, rgName, registryName, setName, armcontainerregistry.CredentialSet{
+		Identity: &armcontainerregistry.IdentityProperties{Type: to.Ptr(armcontainerregistry.ResourceIdentityTypeSystemAssigned)},
+		Properties: &armcontainerregistry.CredentialSetProperties{
+			LoginServer: ptrStr("ghcr.io"),
+			AuthCredentials: []*armcontainerregistry.AuthCredential{{
+				Name:                     to.Ptr(armcontainerregistry.CredentialNameCredential1),
+				UsernameSecretIdentifier: ptrStr("https://vault.vault.azure.net/secrets/" + setName + "-username"),
+				PasswordSecretIdentifier: ptrStr("https://vault.vault.azure.net/secrets/" + setName + "-password"),
+			}},
+		},
+	}, nil)
+	require.NoError(t, err)
+	set, err := setPoller.PollUntilDone(ctx, nil)
+	require.NoError(t, err)
+	require.NotNil(t, set.Identity)
+	assert.NotEmpty(t, set.Identity.PrincipalID)
+
+	rulePoller, err := rulesClient.BeginCreate(ctx, rgName, registryName, setName, armcontainerregistry.CacheRule{
+		Properties: &armcontainerregistry.CacheRuleProperties{
+			SourceRepository:        ptrStr("ghcr.io/*"),
+			TargetRepository:        ptrStr("ghcr-io/*"),
+			CredentialSetResourceID: set.ID,
+		},
+	}, nil)
+	require.NoError(t, err)
+	rule, err := rulePoller.PollUntilDone(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, *set.ID, *rule.Properties.CredentialSetResourceID)
+
+	// In use by the rule.
+	_, err = setsClient.BeginDelete(ctx, rgName, registryName, setName, nil)
+	require.Error(t, err)
+
+	delRule, err := rulesClient.BeginDelete(ctx, rgName, registryName, setName, nil)
+	require.NoError(t, err)
+	_, err = delRule.PollUntilDone(ctx, nil)
+	require.NoError(t, err)
+	delSet, err := setsClient.BeginDelete(ctx, rgName, registryName, setName, nil)
+	require.NoError(t, err)
+	_, err = delSet.PollUntilDone(ctx, nil)
+	require.NoError(t, err)
+	_, err = setsClient.Get(ctx, rgName, registryName, setName, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,27 @@
+package azure_sdk_test
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorization_ListPermissionsForResourceGroup(t *testing.T) {
+	client, err := armauthorization.NewPermissionsClient(subscriptionID, &fakeCredential{}, clientOpts())
+	require.NoError(t, err)
+
+	pager := client.NewListForResourceGroupPager("perm-rg", nil)
+	var actions []string
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		require.NoError(t, err)
+		for _, p := range page.Value {
+			for _, a := range p.Actions {
+				actions = append(actions, *a)
+			}
+		}
+	}
+	assert.Contains(t, actions, "*")
+}
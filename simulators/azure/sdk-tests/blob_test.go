@@ -0,0 +1,340 @@
+package azure_sdk_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/appendblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/lease"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storageAccountKey is key1 from listKeys; the sim reports the same pair
+// for every account.
+const storageAccountKey = "dGVzdGtleTEK"
+
+// storageHTTPClient reaches the sim's storage data plane at
+// {account}.{service}.localhost:{port}. CI resolves *.localhost through
+// dnsmasq; dialing loopback directly keeps the tests independent of the
+// host resolver while leaving the Host header (which carries the
+// account and service) untouched.
+func storageHTTPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err == nil && strings.HasSuffix(host, ".localhost") {
+				addr = net.JoinHostPort("127.0.0.1", port)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+// storageEndpoint is the data-plane URL for an account's service.
+func storageEndpoint(account, service string) string {
+	u, _ := url.Parse(baseURL)
+	return fmt.Sprintf("http://%s.%s.localhost:%s/", account, service, u.Port())
+}
+
+// createStorageAccount creates an account through ARM so its data plane
+// accepts requests.
+func createStorageAccount(t *testing.T, name string) {
+	t.Helper()
+	do := func(url, body string) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPut, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer fake-token")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Less(t, resp.StatusCode, 300, "PUT %s", url)
+	}
+	rg := baseURL + "/subscriptions/" + subscriptionID + "/resourceGroups/storage-dp-rg"
+	do(rg+"?api-version=2023-07-01", `{"location":"eastus"}`)
+	body, _ := json.Marshal(map[string]any{"location": "eastus", "kind": "StorageV2", "sku": map[string]string{"name": "Standard_LRS"}})
+	do(rg+"/providers/Microsoft.Storage/storageAccounts/"+name+"?api-version=2023-05-01", string(body))
+}
+
+func blobClientOptions() *azblob.ClientOptions {
+	return &azblob.ClientOptions{ClientOptions: azcore.ClientOptions{
+		Transport:                       storageHTTPClient(),
+		InsecureAllowCredentialWithHTTP: true,
+		Retry:                           policy.RetryOptions{MaxRetries: -1},
+	}}
+}
+
+func newSharedKeyBlobClient(t *testing.T, account string) (*azblob.Client, *azblob.SharedKeyCredential) {
+	t.Helper()
+	cred, err := azblob.NewSharedKeyCredential(account, storageAccountKey)
+	require.NoError(t, err)
+	client, err := azblob.NewClientWithSharedKeyCredential(storageEndpoint(account, "blob"), cred, blobClientOptions())
+	require.NoError(t, err)
+	return client, cred
+}
+
+func TestBlob_BlockBlobLifecycle(t *testing.T) {
+	const account = "sdkblobacct"
+	createStorageAccount(t, account)
+	client, _ := newSharedKeyBlobClient(t, account)
+
+	_, err := client.CreateContainer(ctx, "artifacts", &container.CreateOptions{Metadata: map[string]*string{"team": to.Ptr("ci")}})
+	require.NoError(t, err)
+	_, err = client.CreateContainer(ctx, "artifacts", nil)
+	assert.True(t, bloberror.HasCode(err, bloberror.ContainerAlreadyExists), "recreate: %v", err)
+
+	// Stage four blocks and commit them, as chunked uploaders do.
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 1024) // 16 KiB
+	bb := client.ServiceClient().NewContainerClient("artifacts").NewBlockBlobClient("builds/1/out.bin")
+	var ids []string
+	for i := 0; i < 4; i++ {
+		id := base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "block-%04d", i))
+		_, err = bb.StageBlock(ctx, id, streamingBody(string(payload[i*4096:(i+1)*4096])), nil)
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	bl, err := bb.GetBlockList(ctx, blockblob.BlockListTypeAll, nil)
+	require.NoError(t, err)
+	assert.Empty(t, bl.CommittedBlocks)
+	assert.Len(t, bl.UncommittedBlocks, 4)
+
+	_, err = bb.CommitBlockList(ctx, ids, &blockblob.CommitBlockListOptions{
+		Metadata:    map[string]*string{"build": to.Ptr("1")},
+		HTTPHeaders: &blob.HTTPHeaders{BlobContentType: to.Ptr("application/x-test")},
+	})
+	require.NoError(t, err)
+
+	bl, err = bb.GetBlockList(ctx, blockblob.BlockListTypeAll, nil)
+	require.NoError(t, err)
+	require.Len(t, bl.CommittedBlocks, 4)
+	assert.Empty(t, bl.UncommittedBlocks)
+
+	props, err := bb.GetProperties(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(payload)), *props.ContentLength)
+	assert.Equal(t, "application/x-test", *props.ContentType)
+	assert.Equal(t, "1", *props.Metadata["Build"])
+
+	// Ranged download.
+	resp, err := client.DownloadStream(ctx, "artifacts", "builds/1/out.bin", &azblob.DownloadStreamOptions{
+		Range: azblob.HTTPRange{Offset: 4090, Count: 12},
+	})
+	require.NoError(t, err)
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, payload[4090:4102], got)
+	assert.Equal(t, fmt.Sprintf("bytes 4090-4101/%d", len(payload)), *resp.ContentRange)
+
+	// Full download round-trips.
+	buf := make([]byte, len(payload))
+	_, err = client.DownloadBuffer(ctx, "artifacts", "builds/1/out.bin", buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, payload, buf)
+
+	// Re-commit one existing block plus a newly staged one.
+	tailID := base64.StdEncoding.EncodeToString([]byte("block-0004"))
+	_, err = bb.StageBlock(ctx, tailID, streamingBody("tail"), nil)
+	require.NoError(t, err)
+	_, err = bb.CommitBlockList(ctx, []string{*bl.CommittedBlocks[1].Name, tailID}, nil)
+	require.NoError(t, err)
+	resp, err = client.DownloadStream(ctx, "artifacts", "builds/1/out.bin", nil)
+	require.NoError(t, err)
+	got, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, append(append([]byte{}, payload[4096:8192]...), "tail"...), got)
+
+	_, err = bb.CommitBlockList(ctx, []string{"bm9wZQ=="}, nil)
+	assert.True(t, bloberror.HasCode(err, bloberror.InvalidBlockList), "unknown block: %v", err)
+}
+
+func TestBlob_ListPrefixDelimiter(t *testing.T) {
+	const account = "sdkblobacct"
+	createStorageAccount(t, account)
+	client, _ := newSharedKeyBlobClient(t, account)
+	_, err := client.CreateContainer(ctx, "listing", nil)
+	require.NoError(t, err)
+	for _, name := range []string{"logs/a.txt", "logs/b.txt", "logs/2024/c.txt", "readme.md", "logsx"} {
+		_, err := client.UploadBuffer(ctx, "listing", name, []byte(name), nil)
+		require.NoError(t, err)
+	}
+
+	cc := client.ServiceClient().NewContainerClient("listing")
+	pager := cc.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{Prefix: to.Ptr("logs/")})
+	var names, prefixes []string
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		require.NoError(t, err)
+		for _, b := range page.Segment.BlobItems {
+			names = append(names, *b.Name)
+		}
+		for _, p := range page.Segment.BlobPrefixes {
+			prefixes = append(prefixes, *p.Name)
+		}
+	}
+	assert.Equal(t, []string{"logs/a.txt", "logs/b.txt"}, names)
+	assert.Equal(t, []string{"logs/2024/"}, prefixes)
+
+	// Flat listing pages through every blob with a continuation marker.
+	flat := client.NewListBlobsFlatPager("listing", &azblob.ListBlobsFlatOptions{MaxResults: to.Ptr[int32](2)})
+	var all []string
+	pages := 0
+	for flat.More() {
+		page, err := flat.NextPage(ctx)
+		require.NoError(t, err)
+		pages++
+		for _, b := range page.Segment.BlobItems {
+			all = append(all, *b.Name)
+		}
+	}
+	assert.Equal(t, []string{"logs/2024/c.txt", "logs/a.txt", "logs/b.txt", "logsx", "readme.md"}, all)
+	assert.Equal(t, 3, pages)
+}
+
+func TestBlob_Lease(t *testing.T) {
+	const account = "sdkblobacct"
+	createStorageAccount(t, account)
+	client, _ := newSharedKeyBlobClient(t, account)
+	_, err := client.CreateContainer(ctx, "leases", nil)
+	require.NoError(t, err)
+	_, err = client.UploadBuffer(ctx, "leases", "locked.txt", []byte("v1"), nil)
+	require.NoError(t, err)
+
+	bb := client.ServiceClient().NewContainerClient("leases").NewBlockBlobClient("locked.txt")
+	lc, err := lease.NewBlobClient(bb, &lease.BlobClientOptions{LeaseID: to.Ptr("11111111-2222-3333-4444-555555555555")})
+	require.NoError(t, err)
+	_, err = lc.AcquireLease(ctx, -1, nil)
+	require.NoError(t, err)
+
+	props, err := bb.GetProperties(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, lease.StateTypeLeased, *props.LeaseState)
+
+	_, err = bb.Upload(ctx, streamingBody("v2"), nil)
+	assert.True(t, bloberror.HasCode(err, bloberror.LeaseIDMissing), "write without lease: %v", err)
+
+	_, err = bb.Upload(ctx, streamingBody("v2"), &blockblob.UploadOptions{
+		AccessConditions: &blob.AccessConditions{LeaseAccessConditions: &blob.LeaseAccessConditions{LeaseID: lc.LeaseID()}},
+	})
+	require.NoError(t, err)
+
+	_, err = lc.BreakLease(ctx, &lease.BlobBreakOptions{BreakPeriod: to.Ptr[int32](0)})
+	require.NoError(t, err)
+	props, err = bb.GetProperties(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, lease.StateTypeBroken, *props.LeaseState)
+
+	_, err = bb.Delete(ctx, nil)
+	require.NoError(t, err)
+}
+
+func TestBlob_AppendBlob(t *testing.T) {
+	const account = "sdkblobacct"
+	createStorageAccount(t, account)
+	client, _ := newSharedKeyBlobClient(t, account)
+	_, err := client.CreateContainer(ctx, "appends", nil)
+	require.NoError(t, err)
+
+	ab := client.ServiceClient().NewContainerClient("appends").NewAppendBlobClient("job.log")
+	_, err = ab.Create(ctx, nil)
+	require.NoError(t, err)
+	for _, line := range []string{"step 1\n", "step 2\n"} {
+		_, err := ab.AppendBlock(ctx, streamingBody(line), nil)
+		require.NoError(t, err)
+	}
+	_, err = ab.AppendBlock(ctx, streamingBody("late\n"), &appendblob.AppendBlockOptions{
+		AppendPositionAccessConditions: &appendblob.AppendPositionAccessConditions{AppendPosition: to.Ptr[int64](0)},
+	})
+	assert.True(t, bloberror.HasCode(err, bloberror.AppendPositionConditionNotMet), "stale append: %v", err)
+
+	resp, err := client.DownloadStream(ctx, "appends", "job.log", nil)
+	require.NoError(t, err)
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "step 1\nstep 2\n", string(got))
+	assert.Equal(t, int32(2), *resp.BlobCommittedBlockCount)
+}
+
+func TestBlob_SASAndBearer(t *testing.T) {
+	const account = "sdkblobacct"
+	createStorageAccount(t, account)
+	client, cred := newSharedKeyBlobClient(t, account)
+	_, err := client.CreateContainer(ctx, "shared", nil)
+	require.NoError(t, err)
+	_, err = client.UploadBuffer(ctx, "shared", "doc.txt", []byte("hello"), nil)
+	require.NoError(t, err)
+
+	sasURL := func(perms sas.BlobPermissions, expiry time.Time) string {
+		qp, err := sas.BlobSignatureValues{
+			Protocol:      sas.ProtocolHTTPSandHTTP,
+			ExpiryTime:    expiry,
+			Permissions:   perms.String(),
+			ContainerName: "shared",
+			BlobName:      "doc.txt",
+		}.SignWithSharedKey(cred)
+		require.NoError(t, err)
+		return storageEndpoint(account, "blob") + "shared/doc.txt?" + qp.Encode()
+	}
+
+	readOnly, err := blockblob.NewClientWithNoCredential(sasURL(sas.BlobPermissions{Read: true}, time.Now().Add(time.Hour)), &blockblob.ClientOptions{ClientOptions: blobClientOptions().ClientOptions})
+	require.NoError(t, err)
+	resp, err := readOnly.DownloadStream(ctx, nil)
+	require.NoError(t, err)
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "hello", string(got))
+
+	_, err = readOnly.Upload(ctx, streamingBody("overwrite"), nil)
+	assert.True(t, bloberror.HasCode(err, bloberror.AuthorizationPermissionMismatch), "write with read SAS: %v", err)
+
+	expired, err := blockblob.NewClientWithNoCredential(sasURL(sas.BlobPermissions{Read: true}, time.Now().Add(-time.Minute)), &blockblob.ClientOptions{ClientOptions: blobClientOptions().ClientOptions})
+	require.NoError(t, err)
+	_, err = expired.DownloadStream(ctx, nil)
+	assert.True(t, bloberror.HasCode(err, bloberror.AuthenticationFailed), "expired SAS: %v", err)
+
+	anon, err := blockblob.NewClientWithNoCredential(storageEndpoint(account, "blob")+"shared/doc.txt", &blockblob.ClientOptions{ClientOptions: blobClientOptions().ClientOptions})
+	require.NoError(t, err)
+	_, err = anon.DownloadStream(ctx, nil)
+	assert.True(t, bloberror.HasCode(err, bloberror.NoAuthenticationInformation), "anonymous on private container: %v", err)
+
+	bearer, err := azblob.NewClient(storageEndpoint(account, "blob"), &fakeCredential{}, blobClientOptions())
+	require.NoError(t, err)
+	resp, err = bearer.DownloadStream(ctx, "shared", "doc.txt", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestBlob_BadSharedKeyRejected(t *testing.T) {
+	const account = "sdkblobacct"
+	createStorageAccount(t, account)
+	cred, err := azblob.NewSharedKeyCredential(account, "d3Jvbmcta2V5Cg==")
+	require.NoError(t, err)
+	client, err := azblob.NewClientWithSharedKeyCredential(storageEndpoint(account, "blob"), cred, blobClientOptions())
+	require.NoError(t, err)
+	_, err = client.CreateContainer(ctx, "nope", nil)
+	assert.True(t, bloberror.HasCode(err, bloberror.AuthenticationFailed), "wrong key: %v", err)
+}
+
+func streamingBody(s string) io.ReadSeekCloser {
+	return streaming.NopCloser(strings.NewReader(s))
+}
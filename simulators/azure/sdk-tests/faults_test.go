@@ -0,0 +1,131 @@
+package azure_sdk_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simFault mirrors the /sim/v1/faults wire shape.
+type simFault struct {
+	ID         string `json:"id,omitempty"`
+	Service    string `json:"service,omitempty"`
+	Action     string `json:"action,omitempty"`
+	Resource   string `json:"resource,omitempty"`
+	Method     string `json:"method,omitempty"`
+	Kind       string `json:"kind"`
+	Status     int    `json:"status,omitempty"`
+	RetryAfter int    `json:"retryAfter,omitempty"`
+	Latency    string `json:"latency,omitempty"`
+	Count      int    `json:"count,omitempty"`
+	Hits       int    `json:"hits,omitempty"`
+}
+
+// injectFaults replaces the simulator's fault set for the duration of
+// the test.
+func injectFaults(t *testing.T, faults ...simFault) {
+	t.Helper()
+	body, err := json.Marshal(faults)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, baseURL+"/sim/v1/faults", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	msg, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(msg))
+	t.Cleanup(func() {
+		req, _ := http.NewRequest(http.MethodDelete, baseURL+"/sim/v1/faults", nil)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+}
+
+func faultHits(t *testing.T, id string) int {
+	t.Helper()
+	resp, err := http.Get(baseURL + "/sim/v1/faults/" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var f simFault
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&f))
+	return f.Hits
+}
+
+// TestFaults_ARMThrottlingHonoursRetryAfter checks the azcore retry
+// policy waits out the injected Retry-After before retrying.
+func TestFaults_ARMThrottlingHonoursRetryAfter(t *testing.T) {
+	client, err := armresources.NewResourceGroupsClient(subscriptionID, &fakeCredential{}, clientOpts())
+	require.NoError(t, err)
+	_, err = client.CreateOrUpdate(ctx, "fault-rg", armresources.ResourceGroup{Location: ptrStr("eastus")}, nil)
+	require.NoError(t, err)
+
+	injectFaults(t, simFault{ID: "arm", Resource: "*/resourceGroups/fault-rg", Method: "GET", Kind: "error", Count: 1})
+
+	start := time.Now()
+	got, err := client.Get(ctx, "fault-rg", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "fault-rg", *got.Name)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "retry must wait the 1s Retry-After")
+	assert.Equal(t, 1, faultHits(t, "arm"))
+}
+
+// TestFaults_ARMRetryAfterBeyondCap checks a Retry-After longer than
+// the client's MaxRetryDelay surfaces the 429 instead of retrying.
+func TestFaults_ARMRetryAfterBeyondCap(t *testing.T) {
+	opts := clientOpts()
+	opts.Retry = policy.RetryOptions{MaxRetryDelay: 2 * time.Second}
+	client, err := armresources.NewResourceGroupsClient(subscriptionID, &fakeCredential{}, opts)
+	require.NoError(t, err)
+
+	injectFaults(t, simFault{ID: "arm", Resource: "*/resourceGroups/capped-rg", Kind: "error", RetryAfter: 30})
+
+	_, err = client.Get(ctx, "capped-rg", nil)
+	require.Error(t, err)
+	var respErr *azcore.ResponseError
+	require.True(t, errors.As(err, &respErr), "want ResponseError, got %T: %v", err, err)
+	assert.Equal(t, http.StatusTooManyRequests, respErr.StatusCode)
+	assert.Equal(t, "TooManyRequests", respErr.ErrorCode)
+	assert.Equal(t, "30", respErr.RawResponse.Header.Get("Retry-After"))
+	assert.Equal(t, 1, faultHits(t, "arm"))
+}
+
+// TestFaults_BlobServerBusyRetried throttles the Blob data plane, which
+// the azblob client retries like the real ServerBusy response.
+func TestFaults_BlobServerBusyRetried(t *testing.T) {
+	const account = "sdkfaultacct"
+	createStorageAccount(t, account)
+	cred, err := azblob.NewSharedKeyCredential(account, storageAccountKey)
+	require.NoError(t, err)
+	opts := blobClientOptions()
+	opts.Retry = policy.RetryOptions{MaxRetries: 2}
+	client, err := azblob.NewClientWithSharedKeyCredential(storageEndpoint(account, "blob"), cred, opts)
+	require.NoError(t, err)
+
+	_, err = client.CreateContainer(ctx, "busy", nil)
+	require.NoError(t, err)
+	_, err = client.UploadBuffer(ctx, "busy", "blob.txt", []byte("payload"), nil)
+	require.NoError(t, err)
+
+	injectFaults(t, simFault{ID: "busy", Service: "blob", Resource: "/busy/blob.txt", Method: "GET", Kind: "error", Count: 1})
+
+	resp, err := client.DownloadStream(ctx, "busy", "blob.txt", nil)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(data))
+	assert.Equal(t, 1, faultHits(t, "busy"))
+}
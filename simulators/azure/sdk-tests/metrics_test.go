@@ -0,0 +1,71 @@
+package azure_sdk_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metricsResponse struct {
+	Value []struct {
+		Name struct {
+			Value string `json:"value"`
+		} `json:"name"`
+		TimeSeries []struct {
+			Data []struct {
+				TimeStamp string   `json:"timeStamp"`
+				Average   *float64 `json:"average"`
+			} `json:"data"`
+		} `json:"timeseries"`
+	} `json:"value"`
+}
+
+func listMetrics(t *testing.T, resourceID, names string) (int, metricsResponse) {
+	t.Helper()
+	q := url.Values{
+		"api-version": {"2024-02-01"},
+		"metricnames": {names},
+		"aggregation": {"Average"},
+		"interval":    {"PT1M"},
+	}
+	req, _ := http.NewRequestWithContext(ctx, "GET",
+		baseURL+resourceID+"/providers/Microsoft.Insights/metrics?"+q.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer fake-token")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var result metricsResponse
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.Unmarshal(data, &result))
+	}
+	return resp.StatusCode, result
+}
+
+func TestMonitor_ListMetricsForJob(t *testing.T) {
+	acaCreateJob(t, "metrics-rg", "metrics-job")
+	resourceID := "/subscriptions/" + subscriptionID + "/resourceGroups/metrics-rg/providers/Microsoft.App/jobs/metrics-job"
+
+	code, result := listMetrics(t, resourceID, "UsageNanoCores,WorkingSetBytes")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Value, 2)
+	for _, m := range result.Value {
+		require.Len(t, m.TimeSeries, 1, m.Name.Value)
+		require.NotEmpty(t, m.TimeSeries[0].Data, m.Name.Value)
+		point := m.TimeSeries[0].Data[0]
+		require.NotNil(t, point.Average, m.Name.Value)
+		assert.Greater(t, *point.Average, 0.0, m.Name.Value)
+		assert.NotEmpty(t, point.TimeStamp, m.Name.Value)
+	}
+}
+
+func TestMonitor_ListMetricsUnknownResource(t *testing.T) {
+	resourceID := "/subscriptions/" + subscriptionID + "/resourceGroups/metrics-rg/providers/Microsoft.App/jobs/no-such-job"
+	code, _ := listMetrics(t, resourceID, "UsageNanoCores")
+	assert.Equal(t, http.StatusNotFound, code)
+}
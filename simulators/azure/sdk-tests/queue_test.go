@@ -0,0 +1,211 @@
+package azure_sdk_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Queue service tests drive the REST API directly: the Go SDK's azqueue
+// module isn't part of this suite's dependency set, and the `az storage
+// message` CLI tests cover the SharedKey path through the Python SDK.
+
+type queueMessagesList struct {
+	Messages []struct {
+		MessageID       string `xml:"MessageId"`
+		PopReceipt      string `xml:"PopReceipt"`
+		TimeNextVisible string `xml:"TimeNextVisible"`
+		DequeueCount    int    `xml:"DequeueCount"`
+		MessageText     string `xml:"MessageText"`
+	} `xml:"QueueMessage"`
+}
+
+// queueSAS signs a queue service SAS with the account key, following
+// the documented string-to-sign for version 2018-11-09 and later.
+func queueSAS(t *testing.T, account, queue, perms string, expiry time.Time) string {
+	t.Helper()
+	const sv = "2021-12-02"
+	se := expiry.UTC().Format("2006-01-02T15:04:05Z")
+	sts := strings.Join([]string{perms, "", se, "/queue/" + account + "/" + queue, "", "", "", sv}, "\n")
+	key, err := base64.StdEncoding.DecodeString(storageAccountKey)
+	require.NoError(t, err)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sts))
+	return url.Values{
+		"sv":  {sv},
+		"se":  {se},
+		"sp":  {perms},
+		"sig": {base64.StdEncoding.EncodeToString(mac.Sum(nil))},
+	}.Encode()
+}
+
+func queueRequest(t *testing.T, method, rawURL, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("x-ms-version", "2021-12-02")
+	resp, err := storageHTTPClient().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, data
+}
+
+func TestQueue_MessageLifecycle(t *testing.T) {
+	const account = "sdkqueueacct"
+	createStorageAccount(t, account)
+	base := storageEndpoint(account, "queue") + "jobs"
+	auth := queueSAS(t, account, "jobs", "raup", time.Now().Add(time.Hour))
+
+	// Creating a queue needs an account-level grant; a bearer token
+	// stands in for the Function App's managed identity.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, base, nil)
+	req.Header.Set("Authorization", "Bearer fake-token")
+	req.Header.Set("x-ms-version", "2021-12-02")
+	resp, err := storageHTTPClient().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	for _, text := range []string{"first", "second"} {
+		resp, body := queueRequest(t, http.MethodPost, base+"/messages?"+auth,
+			"<QueueMessage><MessageText>"+text+"</MessageText></QueueMessage>")
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	}
+
+	// Peek leaves messages visible.
+	resp, body := queueRequest(t, http.MethodGet, base+"/messages?peekonly=true&numofmessages=32&"+auth, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var peeked queueMessagesList
+	require.NoError(t, xml.Unmarshal(body, &peeked))
+	require.Len(t, peeked.Messages, 2)
+	assert.Equal(t, "first", peeked.Messages[0].MessageText)
+	assert.Empty(t, peeked.Messages[0].PopReceipt)
+
+	// Get hides the message for the visibility timeout.
+	resp, body = queueRequest(t, http.MethodGet, base+"/messages?visibilitytimeout=1&"+auth, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var got queueMessagesList
+	require.NoError(t, xml.Unmarshal(body, &got))
+	require.Len(t, got.Messages, 1)
+	first := got.Messages[0]
+	assert.Equal(t, "first", first.MessageText)
+	assert.Equal(t, 1, first.DequeueCount)
+	assert.NotEmpty(t, first.PopReceipt)
+
+	resp, body = queueRequest(t, http.MethodGet, base+"/messages?visibilitytimeout=30&"+auth, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var next queueMessagesList
+	require.NoError(t, xml.Unmarshal(body, &next))
+	require.Len(t, next.Messages, 1)
+	assert.Equal(t, "second", next.Messages[0].MessageText)
+
+	// After the timeout lapses the first message is redelivered.
+	time.Sleep(1100 * time.Millisecond)
+	resp, body = queueRequest(t, http.MethodGet, base+"/messages?"+auth, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var again queueMessagesList
+	require.NoError(t, xml.Unmarshal(body, &again))
+	require.Len(t, again.Messages, 1)
+	assert.Equal(t, first.MessageID, again.Messages[0].MessageID)
+	assert.Equal(t, 2, again.Messages[0].DequeueCount)
+
+	// A stale pop receipt can't delete it; the current one can.
+	msgURL := base + "/messages/" + first.MessageID + "?popreceipt=" + url.QueryEscape(first.PopReceipt) + "&" + auth
+	resp, body = queueRequest(t, http.MethodDelete, msgURL, "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(body), "PopReceiptMismatch")
+
+	msgURL = base + "/messages/" + first.MessageID + "?popreceipt=" + url.QueryEscape(again.Messages[0].PopReceipt) + "&" + auth
+	resp, _ = queueRequest(t, http.MethodDelete, msgURL, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, body = queueRequest(t, http.MethodDelete, msgURL, "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, string(body), "MessageNotFound")
+}
+
+func TestQueue_SASPermissions(t *testing.T) {
+	const account = "sdkqueueacct"
+	createStorageAccount(t, account)
+	base := storageEndpoint(account, "queue") + "readonly"
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, base, nil)
+	req.Header.Set("Authorization", "Bearer fake-token")
+	req.Header.Set("x-ms-version", "2021-12-02")
+	resp, err := storageHTTPClient().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	readOnly := queueSAS(t, account, "readonly", "r", time.Now().Add(time.Hour))
+	resp, body := queueRequest(t, http.MethodPost, base+"/messages?"+readOnly,
+		"<QueueMessage><MessageText>x</MessageText></QueueMessage>")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, string(body), "AuthorizationPermissionMismatch")
+
+	// A SAS for another queue doesn't verify.
+	other := queueSAS(t, account, "other", "raup", time.Now().Add(time.Hour))
+	resp, body = queueRequest(t, http.MethodPost, base+"/messages?"+other,
+		"<QueueMessage><MessageText>x</MessageText></QueueMessage>")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, string(body), "AuthenticationFailed")
+
+	resp, body = queueRequest(t, http.MethodGet, base+"/messages", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, string(body), "NoAuthenticationInformation")
+}
+
+func TestQueue_ARMControlPlane(t *testing.T) {
+	const account = "sdkqueueacct"
+	createStorageAccount(t, account)
+	armQueue := baseURL + "/subscriptions/" + subscriptionID +
+		"/resourceGroups/storage-dp-rg/providers/Microsoft.Storage/storageAccounts/" + account +
+		"/queueServices/default/queues/armjobs?api-version=2023-05-01"
+	arm := func(method, body string) (*http.Response, []byte) {
+		req, _ := http.NewRequestWithContext(ctx, method, armQueue, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer fake-token")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, data
+	}
+
+	resp, body := arm(http.MethodPut, `{"properties":{"metadata":{"owner":"tf"}}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	// The ARM-created queue is live on the data plane.
+	auth := queueSAS(t, account, "armjobs", "a", time.Now().Add(time.Hour))
+	resp, body = queueRequest(t, http.MethodPost, storageEndpoint(account, "queue")+"armjobs/messages?"+auth,
+		"<QueueMessage><MessageText>hi</MessageText></QueueMessage>")
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+	resp, body = arm(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var q struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Metadata                map[string]string `json:"metadata"`
+			ApproximateMessageCount int               `json:"approximateMessageCount"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(body, &q))
+	assert.Equal(t, "armjobs", q.Name)
+	assert.Equal(t, "tf", q.Properties.Metadata["owner"])
+	assert.Equal(t, 1, q.Properties.ApproximateMessageCount)
+
+	resp, _ = arm(http.MethodDelete, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = arm(http.MethodGet, "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,103 @@
+package azure_sdk_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postJSON(t *testing.T, path string, body any) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer fake-token")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// TestResourceGraph_DefenderSubAssessments covers the query sockerless's
+// DefenderScanner sends: container-image vulnerability sub-assessments
+// of one ACR image digest, paged with $skipToken.
+func TestResourceGraph_DefenderSubAssessments(t *testing.T) {
+	artifact := func(digest string) map[string]any {
+		return map[string]any{"registryHost": "scanacr.azurecr.io", "repositoryName": "app", "digest": digest}
+	}
+	finding := func(cve, digest string) map[string]any {
+		return map[string]any{
+			"id":          cve,
+			"displayName": cve,
+			"status":      map[string]any{"code": "Unhealthy", "severity": "High"},
+			"additionalData": map[string]any{
+				"assessedResourceType": "AzureContainerRegistryVulnerability",
+				"artifactDetails":      artifact(digest),
+				"vulnerabilityDetails": map[string]any{"cveId": cve, "severity": "High"},
+				"softwareDetails":      map[string]any{"packageName": "openssl", "version": "3.0.11-1"},
+			},
+		}
+	}
+	resp := postJSON(t, "/sim/v1/defender/subassessments", map[string]any{
+		"subscriptionId": subscriptionID,
+		"subAssessments": []any{
+			finding("CVE-2024-0001", "sha256:aaa"),
+			finding("CVE-2024-0002", "sha256:aaa"),
+			finding("CVE-2024-0003", "sha256:bbb"),
+		},
+	})
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	query := "securityresources" +
+		" | where type == 'microsoft.security/assessments/subassessments'" +
+		" | where properties.additionalData.artifactDetails.registryHost == 'scanacr.azurecr.io'" +
+		" | where properties.additionalData.artifactDetails.digest == 'sha256:aaa'"
+	type page struct {
+		TotalRecords int `json:"totalRecords"`
+		Data         []struct {
+			Type       string `json:"type"`
+			Properties struct {
+				AdditionalData struct {
+					VulnerabilityDetails struct {
+						CveID string `json:"cveId"`
+					} `json:"vulnerabilityDetails"`
+				} `json:"additionalData"`
+			} `json:"properties"`
+		} `json:"data"`
+		SkipToken string `json:"$skipToken"`
+	}
+	var cves []string
+	options := map[string]any{"$top": 1}
+	for {
+		resp := postJSON(t, "/providers/Microsoft.ResourceGraph/resources?api-version=2021-03-01", map[string]any{
+			"subscriptions": []string{subscriptionID},
+			"query":         query,
+			"options":       options,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var p page
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+		resp.Body.Close()
+		assert.Equal(t, 2, p.TotalRecords)
+		for _, row := range p.Data {
+			assert.Equal(t, "microsoft.security/assessments/subassessments", row.Type)
+			cves = append(cves, row.Properties.AdditionalData.VulnerabilityDetails.CveID)
+		}
+		if p.SkipToken == "" {
+			break
+		}
+		options = map[string]any{"$top": 1, "$skipToken": p.SkipToken}
+	}
+	assert.ElementsMatch(t, []string{"CVE-2024-0001", "CVE-2024-0002"}, cves)
+
+	resp = postJSON(t, "/providers/Microsoft.ResourceGraph/resources?api-version=2021-03-01", map[string]any{
+		"subscriptions": []string{subscriptionID},
+		"query":         "resources | take 1",
+	})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,419 @@
+package simulator
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestFaultGlob(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "anything", true},
+		{"*", "", true},
+		{"ecs", "ecs", true},
+		{"ecs", "ecsx", false},
+		{"Run*", "RunTask", true},
+		{"*Task", "DescribeTask", true},
+		{"*Task", "DescribeTasks", false},
+		{"/s3/*/key", "/s3/bucket/key", true},
+		{"/s3/*", "/s3/bucket/a/b/c", true},
+		{"/v?/projects/*", "/v2/projects/p", true},
+		{"/v?/projects/*", "/v22/projects/p", false},
+	}
+	for _, c := range cases {
+		if got := faultGlob(c.pattern, c.s); got != c.want {
+			t.Errorf("faultGlob(%q, %q) = %v, want %v", c.pattern, c.s, got, c.want)
+		}
+	}
+}
+
+func TestClassifyFaultTarget(t *testing.T) {
+	req := func(method, target string, h map[string]string, body string) *http.Request {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range h {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+	sigv4 := "AWS4-HMAC-SHA256 Credential=AKID/20260101/us-east-1/%s/aws4_request, SignedHeaders=host, Signature=x"
+	cases := []struct {
+		name            string
+		provider        string
+		r               *http.Request
+		service, action string
+	}{
+		{"aws json", "aws", req("POST", "/", map[string]string{
+			"Authorization": strings.Replace(sigv4, "%s", "ecs", 1),
+			"X-Amz-Target":  "AmazonEC2ContainerServiceV20141113.RunTask",
+		}, "{}"), "ecs", "RunTask"},
+		{"aws query", "aws", req("POST", "/", map[string]string{
+			"Authorization": strings.Replace(sigv4, "%s", "ec2", 1),
+			"Content-Type":  "application/x-www-form-urlencoded",
+		}, "Action=DescribeInstances&Version=2016-11-15"), "ec2", "DescribeInstances"},
+		{"aws s3 unsigned", "aws", req("GET", "/s3/bucket/key", nil, ""), "s3", ""},
+		{"aws presigned", "aws", req("GET", "/s3/b/k?X-Amz-Credential=AKID%2F20260101%2Fus-east-1%2Fs3%2Faws4_request", nil, ""), "s3", ""},
+		{"gcp storage", "gcp", req("GET", "/storage/v1/b/bkt/o/obj", nil, ""), "storage", ""},
+		{"gcp upload", "gcp", req("POST", "/upload/storage/v1/b/bkt/o", nil, ""), "storage", ""},
+		{"gcp run", "gcp", req("POST", "/v2/projects/p/locations/us-central1/jobs/j:run", nil, ""), "jobs", "run"},
+		{"gcp pubsub", "gcp", req("POST", "/v1/projects/p/topics/t:publish", nil, ""), "topics", "publish"},
+		{"azure arm", "azure", req("PUT", "/subscriptions/s/resourceGroups/rg/providers/Microsoft.App/jobs/j", nil, ""), "Microsoft.App", ""},
+		{"azure action", "azure", req("POST", "/subscriptions/s/resourceGroups/rg/providers/Microsoft.App/jobs/j/start", nil, ""), "Microsoft.App", "start"},
+		{"azure data plane", "azure", func() *http.Request {
+			r := req("PUT", "/ctr/blob?comp=block", nil, "")
+			r.Host = "acct.blob.localhost:4568"
+			return r
+		}(), "blob", "block"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := classifyFaultTarget(c.provider, c.r)
+			if got.service != c.service || got.action != c.action {
+				t.Fatalf("got service=%q action=%q, want %q %q", got.service, got.action, c.service, c.action)
+			}
+		})
+	}
+
+	// Peeking the Query protocol body must leave it intact for the handler.
+	r := req("POST", "/", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, "Action=GetCallerIdentity")
+	classifyFaultTarget("aws", r)
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != "Action=GetCallerIdentity" {
+		t.Fatalf("body after classify = %q", body)
+	}
+}
+
+func TestFaultValidation(t *testing.T) {
+	bad := []Fault{
+		{},
+		{Kind: "explode"},
+		{Kind: FaultLatency},
+		{Kind: FaultLatency, Latency: "soon"},
+		{Kind: FaultError, Probability: 1.5},
+		{Kind: FaultError, Count: -1},
+		{Kind: FaultError, Status: 200},
+	}
+	fs := NewFaults("aws")
+	for _, f := range bad {
+		if _, err := fs.Add(f); err == nil {
+			t.Errorf("Add(%+v) succeeded, want error", f)
+		}
+	}
+	if _, err := fs.Add(Fault{ID: "x", Kind: FaultError}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Add(Fault{ID: "x", Kind: FaultError}); err == nil {
+		t.Fatal("duplicate ID accepted")
+	}
+	// A failed Replace leaves the previous set in place.
+	if err := fs.Replace([]Fault{{Kind: FaultError}, {Kind: "nope"}}); err == nil {
+		t.Fatal("Replace with invalid fault succeeded")
+	}
+	if got := fs.List(); len(got) != 1 || got[0].ID != "x" {
+		t.Fatalf("faults after failed Replace = %+v", got)
+	}
+}
+
+func TestFaultsFromEnv(t *testing.T) {
+	t.Setenv("SIM_FAULTS", `[{"service":"ecs","kind":"error","count":2}]`)
+	t.Setenv("SIM_FAULTS_FILE", "")
+	fs, err := FaultsFromEnv("aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.List(); len(got) != 1 || got[0].ID != "fault-1" || got[0].Count != 2 {
+		t.Fatalf("faults = %+v", got)
+	}
+
+	t.Setenv("SIM_FAULTS", `[{"kind":"latency"}]`)
+	if _, err := FaultsFromEnv("aws"); err == nil {
+		t.Fatal("invalid SIM_FAULTS accepted")
+	}
+
+	file := filepath.Join(t.TempDir(), "faults.json")
+	if err := os.WriteFile(file, []byte(`[{"kind":"drop"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIM_FAULTS", "")
+	t.Setenv("SIM_FAULTS_FILE", file)
+	if fs, err = FaultsFromEnv("aws"); err != nil || len(fs.List()) != 1 {
+		t.Fatalf("SIM_FAULTS_FILE: %v %+v", err, fs)
+	}
+	t.Setenv("SIM_FAULTS", "[]")
+	if _, err := FaultsFromEnv("aws"); err == nil {
+		t.Fatal("SIM_FAULTS with SIM_FAULTS_FILE accepted")
+	}
+}
+
+// faultServer serves ok handler responses through a fault set.
+func faultServer(t *testing.T, provider string, handler http.HandlerFunc) (*Faults, *httptest.Server) {
+	t.Helper()
+	fs := NewFaults(provider)
+	mux := http.NewServeMux()
+	registerFaultAPI(mux, fs)
+	mux.Handle("/", handler)
+	var h http.Handler = fs.Middleware(mux)
+	h = LoggingMiddleware(zerolog.Nop(), provider)(h)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return fs, srv
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]string{"ok": "yes"})
+}
+
+func TestFaultLimits(t *testing.T) {
+	fs, srv := faultServer(t, "gcp", okHandler)
+	if _, err := fs.Add(Fault{ID: "f", Resource: "/v1/*", Kind: FaultError, After: 1, Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var statuses []int
+	for range 5 {
+		resp, err := http.Get(srv.URL + "/v1/projects/p/topics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	want := []int{200, 429, 429, 200, 200}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", statuses, want)
+		}
+	}
+	// Non-matching paths and the control API are never faulted.
+	resp, _ := http.Get(srv.URL + "/storage/v1/b")
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("unselected path status = %d", resp.StatusCode)
+	}
+	f, _ := fs.Get("f")
+	if f.Matched != 5 || f.Hits != 2 {
+		t.Fatalf("matched=%d hits=%d, want 5 2", f.Matched, f.Hits)
+	}
+}
+
+func TestFaultProbability(t *testing.T) {
+	fs, srv := faultServer(t, "gcp", okHandler)
+	fs.Add(Fault{Kind: FaultError, Probability: 0.5})
+	failed := 0
+	for range 200 {
+		resp, err := http.Get(srv.URL + "/v1/x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == 429 {
+			failed++
+		}
+	}
+	if failed < 50 || failed > 150 {
+		t.Fatalf("probability 0.5 failed %d/200 requests", failed)
+	}
+}
+
+func TestFaultErrorShapes(t *testing.T) {
+	cases := []struct {
+		name       string
+		provider   string
+		build      func(url string) *http.Request
+		status     int
+		body       string
+		retryAfter string
+	}{
+		{"aws json", "aws", func(u string) *http.Request {
+			r, _ := http.NewRequest("POST", u+"/", strings.NewReader("{}"))
+			r.Header.Set("X-Amz-Target", "Logs_20140328.GetLogEvents")
+			return r
+		}, 400, `"__type":"ThrottlingException"`, ""},
+		{"aws query", "aws", func(u string) *http.Request {
+			r, _ := http.NewRequest("POST", u+"/", strings.NewReader("Action=GetCallerIdentity"))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return r
+		}, 400, "<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code>", ""},
+		{"aws ec2", "aws", func(u string) *http.Request {
+			r, _ := http.NewRequest("POST", u+"/", strings.NewReader("Action=DescribeInstances"))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20260101/us-east-1/ec2/aws4_request, SignedHeaders=host, Signature=x")
+			return r
+		}, 503, "<Code>RequestLimitExceeded</Code>", ""},
+		{"aws s3", "aws", func(u string) *http.Request {
+			r, _ := http.NewRequest("GET", u+"/s3/b/k", nil)
+			return r
+		}, 503, "<Code>SlowDown</Code>", ""},
+		{"gcp", "gcp", func(u string) *http.Request {
+			r, _ := http.NewRequest("GET", u+"/storage/v1/b/x", nil)
+			return r
+		}, 429, `"status":"RESOURCE_EXHAUSTED"`, ""},
+		{"azure arm", "azure", func(u string) *http.Request {
+			r, _ := http.NewRequest("GET", u+"/subscriptions/s/providers/Microsoft.App/jobs", nil)
+			return r
+		}, 429, `"code":"TooManyRequests"`, "1"},
+		{"azure blob", "azure", func(u string) *http.Request {
+			r, _ := http.NewRequest("GET", u+"/ctr?restype=container", nil)
+			r.Host = "acct.blob.localhost"
+			return r
+		}, 503, "<Code>ServerBusy</Code>", "1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fs, srv := faultServer(t, c.provider, okHandler)
+			fs.Add(Fault{Kind: FaultError})
+			resp, err := http.DefaultClient.Do(c.build(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != c.status || !strings.Contains(string(body), c.body) {
+				t.Fatalf("got %d %s, want %d containing %s", resp.StatusCode, body, c.status, c.body)
+			}
+			if got := resp.Header.Get("Retry-After"); got != c.retryAfter {
+				t.Fatalf("Retry-After = %q, want %q", got, c.retryAfter)
+			}
+		})
+	}
+
+	// Overrides replace the provider default.
+	fs, srv := faultServer(t, "gcp", okHandler)
+	fs.Add(Fault{Kind: FaultError, Status: 503, RetryAfter: 7, Message: "backend unavailable"})
+	resp, err := http.Get(srv.URL + "/v1/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 503 || !strings.Contains(string(body), `"status":"UNAVAILABLE"`) ||
+		!strings.Contains(string(body), "backend unavailable") || resp.Header.Get("Retry-After") != "7" {
+		t.Fatalf("override: %d %v %s", resp.StatusCode, resp.Header, body)
+	}
+}
+
+func TestFaultLatency(t *testing.T) {
+	fs, srv := faultServer(t, "aws", okHandler)
+	fs.Add(Fault{Kind: FaultLatency, Latency: "150ms"})
+	fs.Add(Fault{Kind: FaultLatency, Latency: "100ms"})
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/s3/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Fatalf("latency faults added %v, want >= 250ms", elapsed)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("latency fault changed status to %d", resp.StatusCode)
+	}
+}
+
+func TestFaultDrop(t *testing.T) {
+	var served atomic.Int32
+	fs, srv := faultServer(t, "aws", func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		okHandler(w, r)
+	})
+	fs.Add(Fault{Kind: FaultDrop, Method: "get", Count: 1})
+	if _, err := http.Get(srv.URL + "/s3/b"); err == nil {
+		t.Fatal("dropped request returned a response")
+	}
+	resp, err := http.Get(srv.URL + "/s3/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if served.Load() != 1 {
+		t.Fatalf("handler served %d requests, want 1", served.Load())
+	}
+}
+
+func TestFaultStaleRead(t *testing.T) {
+	var version atomic.Int32
+	fs, srv := faultServer(t, "gcp", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			version.Add(1)
+		}
+		WriteJSON(w, http.StatusOK, map[string]int32{"version": version.Load()})
+	})
+	fs.Add(Fault{Kind: FaultStale, Method: "GET", Count: 1})
+
+	get := func() int32 {
+		resp, err := http.Get(srv.URL + "/v1/projects/p/topics/t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]int32
+		json.NewDecoder(resp.Body).Decode(&out)
+		return out["version"]
+	}
+	if v := get(); v != 0 {
+		t.Fatalf("first read = %d", v)
+	}
+	resp, _ := http.Post(srv.URL+"/v1/projects/p/topics/t", "application/json", nil)
+	resp.Body.Close()
+	if v := get(); v != 0 {
+		t.Fatalf("stale read = %d, want the recorded 0", v)
+	}
+	if v := get(); v != 1 {
+		t.Fatalf("read after count exhausted = %d, want 1", v)
+	}
+}
+
+func TestFaultAPI(t *testing.T) {
+	fs, srv := faultServer(t, "azure", okHandler)
+	do := func(method, path, body string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(b)
+	}
+
+	resp, body := do("POST", "/sim/v1/faults", `{"service":"Microsoft.App","kind":"error","count":1}`)
+	if resp.StatusCode != 201 || !strings.Contains(body, `"id":"fault-1"`) {
+		t.Fatalf("POST: %d %s", resp.StatusCode, body)
+	}
+	if resp, body = do("POST", "/sim/v1/faults", `{"kind":"latency"}`); resp.StatusCode != 400 {
+		t.Fatalf("POST invalid: %d %s", resp.StatusCode, body)
+	}
+	do("GET", "/subscriptions/s/providers/Microsoft.App/jobs", "")
+	if resp, body = do("GET", "/sim/v1/faults/fault-1", ""); resp.StatusCode != 200 || !strings.Contains(body, `"hits":1`) {
+		t.Fatalf("GET one: %d %s", resp.StatusCode, body)
+	}
+
+	if resp, body = do("PUT", "/sim/v1/faults", `[{"id":"a","kind":"drop"},{"id":"b","kind":"stale"}]`); resp.StatusCode != 200 {
+		t.Fatalf("PUT: %d %s", resp.StatusCode, body)
+	}
+	if got := fs.List(); len(got) != 2 || got[0].ID != "a" {
+		t.Fatalf("after PUT = %+v", got)
+	}
+	if resp, _ = do("DELETE", "/sim/v1/faults/a", ""); resp.StatusCode != 204 {
+		t.Fatalf("DELETE one: %d", resp.StatusCode)
+	}
+	if resp, _ = do("DELETE", "/sim/v1/faults/a", ""); resp.StatusCode != 404 {
+		t.Fatalf("DELETE missing: %d", resp.StatusCode)
+	}
+	if resp, _ = do("DELETE", "/sim/v1/faults", ""); resp.StatusCode != 204 || len(fs.List()) != 0 {
+		t.Fatalf("DELETE all: %d %+v", resp.StatusCode, fs.List())
+	}
+	if resp, body = do("GET", "/sim/v1/faults", ""); body != "{\"faults\":[]}\n" {
+		t.Fatalf("GET list: %d %q", resp.StatusCode, body)
+	}
+}
@@ -31,6 +31,12 @@ type Server struct {
 	handler http.Handler
 	db      *sql.DB         // nil when persistence disabled
 	tracker *ProcessTracker // nil when persistence disabled
+	faults  *Faults
+
+	// inner is the mux plus any WrapHandler middleware; chain wraps
+	// it in the shared middleware to produce handler.
+	inner http.Handler
+	chain func(http.Handler) http.Handler
 }
 
 // NewServer creates a new simulator server with the given configuration.
@@ -60,6 +66,13 @@ func NewServer(cfg Config) (*Server, error) {
 		Str("provider", cfg.Provider).
 		Logger()
 
+	// Startup faults fail loud: a test that asked for injected
+	// throttling must not silently run against a well-behaved sim.
+	faults, err := FaultsFromEnv(cfg.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("load SIM_FAULTS: %w", err)
+	}
+
 	mux := http.NewServeMux()
 
 	// Health check endpoint
@@ -69,20 +82,25 @@ func NewServer(cfg Config) (*Server, error) {
 			"provider": cfg.Provider,
 		})
 	})
+	registerFaultAPI(mux, faults)
 
 	// Build middleware chain. otelhttp.NewHandler is outermost so
 	// per-request spans see the post-routing path; the existing
 	// middlewares run inside the span. Spans emit to a no-op tracer
 	// unless main.go calls InitObservability with OTEL_EXPORTER_OTLP_ENDPOINT
-	// set in the env.
-	var handler http.Handler = mux
-	if cfg.Provider == "azure" {
-		handler = AzurePathNormalizationMiddleware(handler)
+	// set in the env. Azure path normalization runs before fault
+	// matching so resource patterns see the canonical /resourceGroups/
+	// casing.
+	chain := func(handler http.Handler) http.Handler {
+		handler = faults.Middleware(handler)
+		if cfg.Provider == "azure" {
+			handler = AzurePathNormalizationMiddleware(handler)
+		}
+		handler = AuthPassthroughMiddleware(cfg.Provider)(handler)
+		handler = LoggingMiddleware(logger, cfg.Provider)(handler)
+		handler = RequestIDMiddleware(cfg.Provider)(handler)
+		return otelhttp.NewHandler(handler, "sockerless-sim-"+cfg.Provider)
 	}
-	handler = AuthPassthroughMiddleware(cfg.Provider)(handler)
-	handler = LoggingMiddleware(logger, cfg.Provider)(handler)
-	handler = RequestIDMiddleware(cfg.Provider)(handler)
-	handler = otelhttp.NewHandler(handler, "sockerless-sim-"+cfg.Provider)
 
 	// Initialize container runtime (Docker/Podman) — required for execution
 	runtime := os.Getenv("SIM_RUNTIME")
@@ -98,7 +116,10 @@ func NewServer(cfg Config) (*Server, error) {
 		config:  cfg,
 		logger:  logger,
 		mux:     mux,
-		handler: handler,
+		handler: chain(mux),
+		faults:  faults,
+		inner:   mux,
+		chain:   chain,
 	}
 
 	// Persistence opens fail loud — operator asked for durable state.
@@ -129,10 +150,19 @@ func (s *Server) Tracker() *ProcessTracker {
 	return s.tracker
 }
 
-// WrapHandler wraps the server's handler with an additional middleware.
-// This is applied outside the existing middleware chain.
+// WrapHandler wraps the server's mux with an additional middleware.
+// It is applied inside the shared middleware chain, so requests it
+// serves itself (the storage data planes) still get request IDs,
+// logging and fault injection.
 func (s *Server) WrapHandler(wrapper func(http.Handler) http.Handler) {
-	s.handler = wrapper(s.handler)
+	s.inner = wrapper(s.inner)
+	s.handler = s.chain(s.inner)
+}
+
+// Faults returns the server's fault set, shared with the
+// /sim/v1/faults control API.
+func (s *Server) Faults() *Faults {
+	return s.faults
 }
 
 // Handle registers a pattern on the server's mux.
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// storageAccountKeys are the two access keys every simulated storage
+// account reports from listKeys. The blob and queue data planes verify
+// SharedKey signatures and SAS tokens against these same values, so a
+// client that fetched keys through ARM (azurerm, `az storage`) can sign
+// data-plane requests exactly as it would against real Azure.
+var storageAccountKeys = []string{"dGVzdGtleTEK", "dGVzdGtleTIK"}
+
+// storageClockSkew is how far x-ms-date / Date may drift from the sim's
+// clock before a SharedKey request is rejected, matching Azure's window.
+const storageClockSkew = 15 * time.Minute
+
+// storageAccess describes what a data-plane request touches, so the
+// authorizer can check a SAS token's scope and permissions against it.
+type storageAccess struct {
+	service   string // "blob" or "queue"
+	container string // container or queue name; empty for service-level ops
+	blob      string // blob name; empty for container-level ops
+	// perms lists the SAS permission letters that grant the operation;
+	// holding any one of them is sufficient.
+	perms string
+	// level is the account-SAS resource type the operation needs:
+	// 's' service, 'c' container/queue, 'o' object (blob/message).
+	level byte
+	// anonymous reports whether a public container lets an
+	// unauthenticated caller perform the operation.
+	anonymous bool
+}
+
+// storageErrorXML is the error envelope every Azure Storage data-plane
+// service returns.
+type storageErrorXML struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// storageError writes an Azure Storage XML error with the matching
+// x-ms-error-code header the SDKs key their typed errors on.
+func storageError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("x-ms-error-code", code)
+	sim.WriteXML(w, status, storageErrorXML{Code: code, Message: message})
+}
+
+// storageErrorf writes an Azure Storage error with a formatted message.
+func storageErrorf(w http.ResponseWriter, status int, code, format string, args ...any) {
+	storageError(w, status, code, fmt.Sprintf(format, args...))
+}
+
+// storageAccountByName resolves the storage account a data-plane host
+// names. Account names are globally unique in Azure, so the first match
+// across subscriptions and resource groups is the account.
+func storageAccountByName(name string) (StorageAccount, bool) {
+	matches := azStorageAccounts.Filter(func(sa StorageAccount) bool { return sa.Name == name })
+	if len(matches) == 0 {
+		return StorageAccount{}, false
+	}
+	return matches[0], true
+}
+
+// authorizeStorage authenticates a blob or queue data-plane request and
+// checks it is allowed to perform access. It supports SharedKey and
+// SharedKeyLite signatures, bearer tokens, account SAS and service SAS;
+// requests without credentials succeed only when access.anonymous is
+// set. On failure it writes the Azure error response and returns false.
+func authorizeStorage(w http.ResponseWriter, r *http.Request, account string, access storageAccess) bool {
+	auth := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(auth, "SharedKey ") || strings.HasPrefix(auth, "SharedKeyLite "):
+		return authorizeSharedKey(w, r, account, auth)
+	case strings.HasPrefix(auth, "Bearer "):
+		// The sim mints AAD tokens itself and doesn't verify them
+		// anywhere (see auth.go); only the version gate Azure applies
+		// to OAuth on the data plane is enforced.
+		if v := r.Header.Get("x-ms-version"); v < "2017-11-09" {
+			storageErrorf(w, http.StatusBadRequest, "InvalidAuthenticationInfo",
+				"Authentication scheme Bearer is not supported in version %q; use x-ms-version 2017-11-09 or later.", v)
+			return false
+		}
+		return true
+	case auth != "":
+		storageErrorf(w, http.StatusForbidden, "AuthenticationFailed", "Unsupported authorization scheme in %q.", auth)
+		return false
+	case r.URL.Query().Get("sig") != "":
+		return authorizeSAS(w, r, account, access)
+	case access.anonymous:
+		return true
+	}
+	storageError(w, http.StatusUnauthorized, "NoAuthenticationInformation",
+		"Server failed to authenticate the request. Please refer to the information in the www-authenticate header.")
+	return false
+}
+
+// authorizeSharedKey verifies a SharedKey / SharedKeyLite signature.
+func authorizeSharedKey(w http.ResponseWriter, r *http.Request, account, auth string) bool {
+	scheme, cred, _ := strings.Cut(auth, " ")
+	acct, sig, ok := strings.Cut(cred, ":")
+	if !ok || acct != account {
+		storageErrorf(w, http.StatusForbidden, "AuthenticationFailed",
+			"The Authorization header names account %q but the request targets %q.", acct, account)
+		return false
+	}
+
+	date := r.Header.Get("x-ms-date")
+	if date == "" {
+		date = r.Header.Get("Date")
+	}
+	ts, err := http.ParseTime(date)
+	if err != nil {
+		storageError(w, http.StatusForbidden, "AuthenticationFailed", "Request date header is missing or malformed.")
+		return false
+	}
+	if d := time.Since(ts); d > storageClockSkew || d < -storageClockSkew {
+		storageErrorf(w, http.StatusForbidden, "RequestTimeTooSkewed",
+			"Request date %s differs from server time by more than %s.", date, storageClockSkew)
+		return false
+	}
+
+	var candidates []string
+	if scheme == "SharedKeyLite" {
+		candidates = []string{sharedKeyLiteStringToSign(r, account)}
+	} else {
+		candidates = sharedKeyStringsToSign(r, account)
+	}
+	for _, key := range storageAccountKeys {
+		for _, sts := range candidates {
+			if hmac.Equal([]byte(sig), []byte(storageSign(key, sts))) {
+				return true
+			}
+		}
+	}
+	storageErrorf(w, http.StatusForbidden, "AuthenticationFailed",
+		"The MAC signature found in the HTTP request '%s' is not the same as any computed signature. Server used following string to sign: '%s'.",
+		sig, candidates[0])
+	return false
+}
+
+// sharedKeyStringsToSign builds the SharedKey string-to-sign (service
+// version 2015-02-21 and later). Go clients that set the request body
+// without populating the Content-Length header sign an empty length
+// while the transport still sends one, so for bodies the variant with
+// the length blanked is offered too.
+func sharedKeyStringsToSign(r *http.Request, account string) []string {
+	build := func(contentLength string) string {
+		h := r.Header
+		return strings.Join([]string{
+			r.Method,
+			h.Get("Content-Encoding"),
+			h.Get("Content-Language"),
+			contentLength,
+			h.Get("Content-MD5"),
+			h.Get("Content-Type"),
+			h.Get("Date"),
+			h.Get("If-Modified-Since"),
+			h.Get("If-Match"),
+			h.Get("If-None-Match"),
+			h.Get("If-Unmodified-Since"),
+			h.Get("Range"),
+			canonicalizedStorageHeaders(h) + canonicalizedStorageResource(r.URL, account),
+		}, "\n")
+	}
+	length := r.Header.Get("Content-Length")
+	if length == "0" {
+		length = ""
+	}
+	if length == "" {
+		return []string{build("")}
+	}
+	return []string{build(length), build("")}
+}
+
+// sharedKeyLiteStringToSign builds the SharedKeyLite string-to-sign for
+// the blob and queue services.
+func sharedKeyLiteStringToSign(r *http.Request, account string) string {
+	resource := "/" + account + escapedStoragePath(r.URL)
+	if comp := r.URL.Query().Get("comp"); comp != "" {
+		resource += "?comp=" + comp
+	}
+	return strings.Join([]string{
+		r.Method,
+		r.Header.Get("Content-MD5"),
+		r.Header.Get("Content-Type"),
+		r.Header.Get("Date"),
+		canonicalizedStorageHeaders(r.Header) + resource,
+	}, "\n")
+}
+
+// canonicalizedStorageHeaders renders the x-ms-* headers sorted by name,
+// one `name:value\n` line each.
+func canonicalizedStorageHeaders(h http.Header) string {
+	var names []string
+	for k := range h {
+		if name := strings.ToLower(k); strings.HasPrefix(name, "x-ms-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		vals := h.Values(name)
+		trimmed := make([]string, len(vals))
+		for i, v := range vals {
+			trimmed[i] = strings.TrimSpace(v)
+		}
+		b.WriteString(name + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+	return b.String()
+}
+
+// canonicalizedStorageResource renders `/account/path` followed by each
+// query parameter as `\nname:v1,v2`, names lowercased and sorted.
+func canonicalizedStorageResource(u *url.URL, account string) string {
+	var b strings.Builder
+	b.WriteString("/" + account + escapedStoragePath(u))
+	params := map[string][]string{}
+	for k, v := range u.Query() {
+		name := strings.ToLower(k)
+		params[name] = append(params[name], v...)
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vals := params[name]
+		sort.Strings(vals)
+		b.WriteString("\n" + name + ":" + strings.Join(vals, ","))
+	}
+	return b.String()
+}
+
+func escapedStoragePath(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	return u.EscapedPath()
+}
+
+// storageSign is the base64 HMAC-SHA256 of stringToSign under the
+// base64-encoded account key.
+func storageSign(key, stringToSign string) string {
+	k, _ := base64.StdEncoding.DecodeString(key)
+	mac := hmac.New(sha256.New, k)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sasTimeLayouts are the ISO-8601 forms Azure accepts for st / se.
+var sasTimeLayouts = []string{"2006-01-02T15:04:05Z", "2006-01-02T15:04:05.0000000Z", "2006-01-02T15:04Z", "2006-01-02"}
+
+func parseSASTime(s string) (time.Time, error) {
+	for _, layout := range sasTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid SAS time %q", s)
+}
+
+// authorizeSAS validates an account or service SAS token on the query
+// string: signature, validity window, protocol, IP range, scope and
+// permissions.
+func authorizeSAS(w http.ResponseWriter, r *http.Request, account string, access storageAccess) bool {
+	q := r.URL.Query()
+	deny := func(format string, args ...any) bool {
+		storageErrorf(w, http.StatusForbidden, "AuthenticationFailed",
+			"Server failed to authenticate the request. "+format, args...)
+		return false
+	}
+
+	sv := q.Get("sv")
+	if sv < "2018-11-09" {
+		return deny("SAS version %q is not supported; use 2018-11-09 or later.", sv)
+	}
+	now := time.Now().UTC()
+	se, err := parseSASTime(q.Get("se"))
+	if err != nil {
+		return deny("Signed expiry time is missing or malformed: %v.", err)
+	}
+	if !now.Before(se) {
+		return deny("Signed expiry time [%s] must be after signed start time and the current time.", q.Get("se"))
+	}
+	if st := q.Get("st"); st != "" {
+		start, err := parseSASTime(st)
+		if err != nil {
+			return deny("Signed start time is malformed: %v.", err)
+		}
+		if now.Before(start) {
+			return deny("Signed start time [%s] is in the future.", st)
+		}
+	}
+	if q.Get("spr") == "https" && r.TLS == nil {
+		storageError(w, http.StatusForbidden, "AuthorizationProtocolMismatch",
+			"This request is not authorized to perform this operation using this protocol.")
+		return false
+	}
+	if sip := q.Get("sip"); sip != "" && !sasIPAllowed(sip, r.RemoteAddr) {
+		storageErrorf(w, http.StatusForbidden, "AuthorizationSourceIPMismatch",
+			"This request is not authorized to perform this operation using this source IP %s.", r.RemoteAddr)
+		return false
+	}
+
+	var sts string
+	sp := q.Get("sp")
+	if ss := q.Get("ss"); ss != "" {
+		// Account SAS.
+		if !strings.ContainsRune(ss, rune(access.service[0])) {
+			storageErrorf(w, http.StatusForbidden, "AuthorizationServiceMismatch",
+				"This request is not authorized to perform this operation using this service.")
+			return false
+		}
+		if !strings.ContainsRune(q.Get("srt"), rune(access.level)) {
+			storageErrorf(w, http.StatusForbidden, "AuthorizationResourceTypeMismatch",
+				"This request is not authorized to perform this operation using this resource type.")
+			return false
+		}
+		fields := []string{account, sp, ss, q.Get("srt"), q.Get("st"), q.Get("se"), q.Get("sip"), q.Get("spr"), sv}
+		if sv >= "2020-12-06" {
+			fields = append(fields, q.Get("ses"))
+		}
+		sts = strings.Join(append(fields, ""), "\n")
+	} else {
+		if q.Get("si") != "" {
+			return deny("Stored access policies (signedIdentifier %q) are not supported by the simulator.", q.Get("si"))
+		}
+		if access.container == "" {
+			return deny("A service SAS cannot authorize service-level operations.")
+		}
+		switch access.service {
+		case "blob":
+			canonical := "/blob/" + account + "/" + access.container
+			switch sr := q.Get("sr"); sr {
+			case "c":
+			case "b":
+				if access.blob == "" {
+					return deny("A blob SAS cannot authorize container-level operations.")
+				}
+				canonical += "/" + access.blob
+			default:
+				return deny("Signed resource %q is not supported.", sr)
+			}
+			fields := []string{sp, q.Get("st"), q.Get("se"), canonical, q.Get("si"), q.Get("sip"), q.Get("spr"), sv,
+				q.Get("sr"), q.Get("snapshot")}
+			if sv >= "2020-12-06" {
+				fields = append(fields, q.Get("ses"))
+			}
+			fields = append(fields, q.Get("rscc"), q.Get("rscd"), q.Get("rsce"), q.Get("rscl"), q.Get("rsct"))
+			sts = strings.Join(fields, "\n")
+		case "queue":
+			sts = strings.Join([]string{sp, q.Get("st"), q.Get("se"), "/queue/" + account + "/" + access.container,
+				q.Get("si"), q.Get("sip"), q.Get("spr"), sv}, "\n")
+		}
+	}
+
+	sig := q.Get("sig")
+	valid := false
+	for _, key := range storageAccountKeys {
+		if hmac.Equal([]byte(sig), []byte(storageSign(key, sts))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return deny("Signature did not match. String to sign used was %s", sts)
+	}
+	if !strings.ContainsAny(sp, access.perms) {
+		storageErrorf(w, http.StatusForbidden, "AuthorizationPermissionMismatch",
+			"This request is not authorized to perform this operation using this permission (requires one of %q, SAS grants %q).", access.perms, sp)
+		return false
+	}
+	return true
+}
+
+// sasIPAllowed reports whether the client address falls inside a SAS
+// `sip` value: a single IPv4 address or an inclusive `low-high` range.
+func sasIPAllowed(sip, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return false
+	}
+	lowS, highS, isRange := strings.Cut(sip, "-")
+	if !isRange {
+		highS = lowS
+	}
+	low, high := net.ParseIP(lowS).To4(), net.ParseIP(highS).To4()
+	if low == nil || high == nil {
+		return false
+	}
+	return string(ip) >= string(low) && string(ip) <= string(high)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanonicalizedStorageResource(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/logs?restype=container&comp=list&Include=metadata&include=snapshots", nil)
+	got := canonicalizedStorageResource(req.URL, "acct")
+	want := "/acct/logs\ncomp:list\ninclude:metadata,snapshots\nrestype:container"
+	if got != want {
+		t.Errorf("canonicalizedStorageResource = %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://acct.blob.localhost/?comp=list", nil)
+	req.URL.Path = ""
+	if got := canonicalizedStorageResource(req.URL, "acct"); got != "/acct/\ncomp:list" {
+		t.Errorf("empty path resource = %q", got)
+	}
+}
+
+func TestSharedKeyStringToSign(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/c/b.txt", strings.NewReader("hello"))
+	req.Header.Set("Content-Length", "5")
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("x-ms-version", "2021-12-02")
+	req.Header.Set("X-Ms-Date", "Sat, 17 Oct 2026 10:00:00 GMT")
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+
+	sts := sharedKeyStringsToSign(req, "acct")
+	if len(sts) != 2 {
+		t.Fatalf("got %d variants, want 2 (with and without Content-Length)", len(sts))
+	}
+	want := "PUT\n\n\n5\n\ntext/plain\n\n\n\n\n\n\n" +
+		"x-ms-blob-type:BlockBlob\nx-ms-date:Sat, 17 Oct 2026 10:00:00 GMT\nx-ms-version:2021-12-02\n" +
+		"/acct/c/b.txt"
+	if sts[0] != want {
+		t.Errorf("string-to-sign =\n%q\nwant\n%q", sts[0], want)
+	}
+	if !strings.HasPrefix(sts[1], "PUT\n\n\n\n") {
+		t.Errorf("blank-length variant = %q", sts[1])
+	}
+}
+
+func TestAuthorizeSharedKey_SignedRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/c/b.txt", nil)
+	req.Header.Set("x-ms-version", "2021-12-02")
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	sig := storageSign(storageAccountKeys[1], sharedKeyStringsToSign(req, "acct")[0])
+
+	req.Header.Set("Authorization", "SharedKey acct:"+sig)
+	rec := httptest.NewRecorder()
+	if !authorizeSharedKey(rec, req, "acct", req.Header.Get("Authorization")) {
+		t.Fatalf("signed with the secondary key: rejected with %d %s", rec.Code, rec.Body.String())
+	}
+
+	req.Header.Set("Authorization", "SharedKey acct:"+storageSign(storageAccountKeys[0], "something else"))
+	rec = httptest.NewRecorder()
+	if authorizeSharedKey(rec, req, "acct", req.Header.Get("Authorization")) {
+		t.Fatal("bad signature accepted")
+	}
+	if rec.Code != http.StatusForbidden || rec.Header().Get("x-ms-error-code") != "AuthenticationFailed" {
+		t.Errorf("bad signature: %d %q", rec.Code, rec.Header().Get("x-ms-error-code"))
+	}
+}
+
+func TestSASIPAllowed(t *testing.T) {
+	cases := []struct {
+		sip, remote string
+		want        bool
+	}{
+		{"10.0.0.5", "10.0.0.5:4000", true},
+		{"10.0.0.5", "10.0.0.6:4000", false},
+		{"10.0.0.1-10.0.0.255", "10.0.0.77:1", true},
+		{"10.0.0.1-10.0.0.255", "10.0.1.1:1", false},
+		{"10.0.0.1-10.0.0.255", "[::1]:1", false},
+		{"not-an-ip", "10.0.0.5:1", false},
+	}
+	for _, tc := range cases {
+		if got := sasIPAllowed(tc.sip, tc.remote); got != tc.want {
+			t.Errorf("sasIPAllowed(%q, %q) = %v, want %v", tc.sip, tc.remote, got, tc.want)
+		}
+	}
+}
+
+func TestParseStorageRange(t *testing.T) {
+	cases := []struct {
+		in         string
+		size       int64
+		start, end int64
+		wantErr    bool
+	}{
+		{"bytes=0-9", 100, 0, 9, false},
+		{"bytes=90-", 100, 90, 99, false},
+		{"bytes=90-200", 100, 90, 99, false},
+		{"bytes=100-", 100, 0, 0, true},
+		{"bytes=9-3", 100, 0, 0, true},
+		{"bytes=-5", 100, 0, 0, true},
+		{"items=0-1", 100, 0, 0, true},
+	}
+	for _, tc := range cases {
+		start, end, err := parseStorageRange(tc.in, tc.size)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseStorageRange(%q) err = %v, wantErr %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if !tc.wantErr && (start != tc.start || end != tc.end) {
+			t.Errorf("parseStorageRange(%q) = %d-%d, want %d-%d", tc.in, start, end, tc.start, tc.end)
+		}
+	}
+}
+
+func TestParseBlockList(t *testing.T) {
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<BlockList><Committed>YQ==</Committed><Latest> Yg== </Latest><Uncommitted>Yw==</Uncommitted></BlockList>`
+	got, err := parseBlockList([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []blockListEntry{{"Committed", "YQ=="}, {"Latest", "Yg=="}, {"Uncommitted", "Yw=="}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := parseBlockList([]byte(`<BlockList><Block>YQ==</Block></BlockList>`)); err == nil {
+		t.Error("unknown element accepted")
+	}
+}
+
+func TestStorageLeaseLifecycle(t *testing.T) {
+	now := time.Now()
+	var l storageLease
+	l.refresh(now)
+
+	lease := func(action string, hdr map[string]string) (*httptest.ResponseRecorder, bool) {
+		req := httptest.NewRequest(http.MethodPut, "/c?comp=lease", nil)
+		req.Header.Set("x-ms-lease-action", action)
+		for k, v := range hdr {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		return rec, applyLeaseAction(rec, req, &l, now)
+	}
+
+	if rec, ok := lease("acquire", map[string]string{"x-ms-lease-duration": "10"}); ok || rec.Code != http.StatusBadRequest {
+		t.Fatalf("duration 10 accepted: %d", rec.Code)
+	}
+	rec, ok := lease("acquire", map[string]string{"x-ms-lease-duration": "15"})
+	if !ok || rec.Code != http.StatusCreated || l.State != "leased" {
+		t.Fatalf("acquire: ok=%v code=%d state=%s", ok, rec.Code, l.State)
+	}
+	id := rec.Header().Get("x-ms-lease-id")
+
+	if rec, ok := lease("acquire", map[string]string{"x-ms-lease-duration": "15"}); ok || rec.Code != http.StatusConflict {
+		t.Fatalf("second acquire: %d", rec.Code)
+	}
+
+	// Writes need the lease ID while it's held.
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/c/b", nil)
+	if l.checkWrite(w, req, "blob") || w.Header().Get("x-ms-error-code") != "LeaseIdMissing" {
+		t.Errorf("write without lease ID: %q", w.Header().Get("x-ms-error-code"))
+	}
+	req.Header.Set("x-ms-lease-id", id)
+	if !l.checkWrite(httptest.NewRecorder(), req, "blob") {
+		t.Error("write with lease ID rejected")
+	}
+
+	// A fixed lease expires on its own.
+	l.refresh(now.Add(16 * time.Second))
+	if l.State != "expired" || l.active() {
+		t.Errorf("after duration: state=%s", l.State)
+	}
+
+	l = storageLease{ID: id, State: "leased", Duration: -1}
+	rec, ok = lease("break", map[string]string{"x-ms-lease-break-period": "5"})
+	if !ok || rec.Code != http.StatusAccepted || l.State != "breaking" || rec.Header().Get("x-ms-lease-time") != "5" {
+		t.Fatalf("break: ok=%v code=%d state=%s time=%s", ok, rec.Code, l.State, rec.Header().Get("x-ms-lease-time"))
+	}
+	if rec, ok := lease("renew", map[string]string{"x-ms-lease-id": id}); ok || rec.Code != http.StatusConflict {
+		t.Errorf("renew while breaking: %d", rec.Code)
+	}
+	l.refresh(now.Add(5 * time.Second))
+	if l.State != "broken" {
+		t.Errorf("after break period: state=%s", l.State)
+	}
+	if _, ok := lease("release", map[string]string{"x-ms-lease-id": id}); !ok || l.State != "available" {
+		t.Errorf("release broken lease: state=%s", l.State)
+	}
+}
+
+func TestCheckStorageConditions(t *testing.T) {
+	lm := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	check := func(read bool, hdr, val string) int {
+		req := httptest.NewRequest(http.MethodGet, "/c/b", nil)
+		req.Header.Set(hdr, val)
+		rec := httptest.NewRecorder()
+		if checkStorageConditions(rec, req, true, `"0x1"`, lm, read) {
+			return http.StatusOK
+		}
+		return rec.Code
+	}
+	if got := check(true, "If-None-Match", `"0x1"`); got != http.StatusNotModified {
+		t.Errorf("read If-None-Match current = %d, want 304", got)
+	}
+	if got := check(false, "If-Match", `"0x2"`); got != http.StatusPreconditionFailed {
+		t.Errorf("write If-Match stale = %d, want 412", got)
+	}
+	if got := check(false, "If-None-Match", "*"); got != http.StatusConflict {
+		t.Errorf("write If-None-Match * = %d, want 409", got)
+	}
+	if got := check(true, "If-Modified-Since", lm.Add(-time.Hour).Format(http.TimeFormat)); got != http.StatusOK {
+		t.Errorf("read If-Modified-Since earlier = %d, want 200", got)
+	}
+	if got := check(false, "If-Unmodified-Since", lm.Add(-time.Hour).Format(http.TimeFormat)); got != http.StatusPreconditionFailed {
+		t.Errorf("write If-Unmodified-Since earlier = %d, want 412", got)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// storageLease is the lease state shared by blob containers and blobs.
+// State follows the Azure lease state machine: available → leased →
+// (expired | breaking → broken) → available/leased again.
+type storageLease struct {
+	ID    string `json:"id,omitempty"`
+	State string `json:"state,omitempty"` // available, leased, expired, breaking, broken
+	// Duration is the lease length in seconds, or -1 for infinite.
+	Duration int       `json:"duration,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	BreakAt  time.Time `json:"breakAt,omitempty"`
+}
+
+// refresh advances time-driven transitions (expiry, break completion).
+func (l *storageLease) refresh(now time.Time) {
+	switch l.State {
+	case "":
+		l.State = "available"
+	case "leased":
+		if l.Duration > 0 && !now.Before(l.Expires) {
+			l.State = "expired"
+		}
+	case "breaking":
+		if !now.Before(l.BreakAt) {
+			l.State = "broken"
+		}
+	}
+}
+
+// active reports whether the lease currently restricts writes.
+func (l storageLease) active() bool {
+	return l.State == "leased" || l.State == "breaking"
+}
+
+// setHeaders writes the x-ms-lease-* response headers.
+func (l storageLease) setHeaders(w http.ResponseWriter) {
+	status := "unlocked"
+	if l.active() {
+		status = "locked"
+	}
+	w.Header().Set("x-ms-lease-status", status)
+	w.Header().Set("x-ms-lease-state", l.State)
+	if l.State == "leased" {
+		w.Header().Set("x-ms-lease-duration", l.durationName())
+	}
+}
+
+func (l storageLease) durationName() string {
+	if l.Duration < 0 {
+		return "infinite"
+	}
+	return "fixed"
+}
+
+// checkWrite enforces the lease on a write or delete: an active lease
+// requires a matching x-ms-lease-id, and supplying an ID when no lease
+// is held is an error. what names the resource kind for messages
+// ("blob" or "container").
+func (l storageLease) checkWrite(w http.ResponseWriter, r *http.Request, what string) bool {
+	id := r.Header.Get("x-ms-lease-id")
+	op := "Blob"
+	if what == "container" {
+		op = "Container"
+	}
+	switch {
+	case l.active() && id == "":
+		storageErrorf(w, http.StatusPreconditionFailed, "LeaseIdMissing",
+			"There is currently a lease on the %s and no lease ID was specified in the request.", what)
+		return false
+	case l.active() && id != l.ID:
+		storageErrorf(w, http.StatusPreconditionFailed, "LeaseIdMismatchWith"+op+"Operation",
+			"The lease ID specified did not match the lease ID for the %s.", what)
+		return false
+	case !l.active() && id != "":
+		storageErrorf(w, http.StatusPreconditionFailed, "LeaseNotPresentWith"+op+"Operation",
+			"There is currently no lease on the %s.", what)
+		return false
+	}
+	return true
+}
+
+// checkRead enforces an x-ms-lease-id supplied on a read: it must name
+// the active lease. Reads without a lease ID are always allowed.
+func (l storageLease) checkRead(w http.ResponseWriter, r *http.Request, what string) bool {
+	if r.Header.Get("x-ms-lease-id") == "" {
+		return true
+	}
+	return l.checkWrite(w, r, what)
+}
+
+// applyLeaseAction performs the x-ms-lease-action on l (Lease Blob /
+// Lease Container) and writes the response. The caller persists l when
+// it returns true.
+func applyLeaseAction(w http.ResponseWriter, r *http.Request, l *storageLease, now time.Time) bool {
+	id := r.Header.Get("x-ms-lease-id")
+	proposed := r.Header.Get("x-ms-proposed-lease-id")
+	conflict := func(code, msg string) bool {
+		storageError(w, http.StatusConflict, code, msg)
+		return false
+	}
+
+	switch action := strings.ToLower(r.Header.Get("x-ms-lease-action")); action {
+	case "acquire":
+		d, err := strconv.Atoi(r.Header.Get("x-ms-lease-duration"))
+		if err != nil || (d != -1 && (d < 15 || d > 60)) {
+			storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue",
+				"x-ms-lease-duration must be -1 or between 15 and 60, got %q.", r.Header.Get("x-ms-lease-duration"))
+			return false
+		}
+		if l.active() && (proposed == "" || proposed != l.ID || l.State == "breaking") {
+			return conflict("LeaseAlreadyPresent", "There is already a lease present.")
+		}
+		if proposed == "" {
+			proposed = generateUUID()
+		}
+		*l = storageLease{ID: proposed, State: "leased", Duration: d}
+		if d > 0 {
+			l.Expires = now.Add(time.Duration(d) * time.Second)
+		}
+		w.Header().Set("x-ms-lease-id", l.ID)
+		w.WriteHeader(http.StatusCreated)
+
+	case "renew":
+		if id == "" || id != l.ID {
+			return conflict("LeaseIdMismatchWithLeaseOperation", "The lease ID specified did not match the lease ID for the resource.")
+		}
+		if l.State == "breaking" || l.State == "broken" {
+			return conflict("LeaseIsBrokenAndCannotBeRenewed", "The lease has been broken and cannot be renewed.")
+		}
+		l.State = "leased"
+		if l.Duration > 0 {
+			l.Expires = now.Add(time.Duration(l.Duration) * time.Second)
+		}
+		w.Header().Set("x-ms-lease-id", l.ID)
+		w.WriteHeader(http.StatusOK)
+
+	case "change":
+		if proposed == "" {
+			storageError(w, http.StatusBadRequest, "MissingRequiredHeader", "x-ms-proposed-lease-id is required to change a lease.")
+			return false
+		}
+		if l.State != "leased" {
+			return conflict("LeaseNotPresentWithLeaseOperation", "There is currently no lease on the resource.")
+		}
+		if id != l.ID && proposed != l.ID {
+			return conflict("LeaseIdMismatchWithLeaseOperation", "The lease ID specified did not match the lease ID for the resource.")
+		}
+		l.ID = proposed
+		w.Header().Set("x-ms-lease-id", l.ID)
+		w.WriteHeader(http.StatusOK)
+
+	case "release":
+		if id == "" || id != l.ID || l.State == "available" {
+			return conflict("LeaseIdMismatchWithLeaseOperation", "The lease ID specified did not match the lease ID for the resource.")
+		}
+		*l = storageLease{State: "available"}
+		w.WriteHeader(http.StatusOK)
+
+	case "break":
+		if !l.active() && l.State != "broken" {
+			return conflict("LeaseNotPresentWithLeaseOperation", "There is currently no lease on the resource.")
+		}
+		if l.State == "leased" {
+			remaining := time.Duration(0)
+			if l.Duration > 0 {
+				remaining = l.Expires.Sub(now)
+			}
+			if v := r.Header.Get("x-ms-lease-break-period"); v != "" {
+				p, err := strconv.Atoi(v)
+				if err != nil || p < 0 || p > 60 {
+					storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue",
+						"x-ms-lease-break-period must be between 0 and 60, got %q.", v)
+					return false
+				}
+				if period := time.Duration(p) * time.Second; l.Duration < 0 || period < remaining {
+					remaining = period
+				}
+			}
+			l.State = "breaking"
+			l.BreakAt = now.Add(remaining)
+			l.refresh(now)
+		}
+		breakIn := 0
+		if l.State == "breaking" {
+			breakIn = int(l.BreakAt.Sub(now).Round(time.Second) / time.Second)
+		}
+		w.Header().Set("x-ms-lease-time", strconv.Itoa(breakIn))
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		storageErrorf(w, http.StatusBadRequest, "InvalidHeaderValue",
+			"x-ms-lease-action %q is not one of acquire, renew, change, release, break.", action)
+		return false
+	}
+	return true
+}
+
+// etagSeq disambiguates ETags minted within the same clock tick.
+var etagSeq atomic.Uint64
+
+// newStorageETag returns a fresh quoted ETag in Azure's 0x8D… style.
+func newStorageETag() string {
+	return fmt.Sprintf(`"0x8D%X%04X"`, time.Now().UnixNano(), etagSeq.Add(1)&0xffff)
+}
+
+// checkStorageConditions evaluates If-Match / If-None-Match /
+// If-Modified-Since / If-Unmodified-Since against the resource's
+// current state. Reads answer a failed If-None-Match or
+// If-Modified-Since with 304; writes get 412. A missing resource only
+// fails If-Match.
+func checkStorageConditions(w http.ResponseWriter, r *http.Request, exists bool, etag string, lastModified time.Time, read bool) bool {
+	fail := func() bool {
+		if read {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return false
+		}
+		storageError(w, http.StatusPreconditionFailed, "ConditionNotMet",
+			"The condition specified using HTTP conditional header(s) is not met.")
+		return false
+	}
+	h := r.Header
+	if m := h.Get("If-Match"); m != "" {
+		if !exists || (m != "*" && m != etag) {
+			storageError(w, http.StatusPreconditionFailed, "ConditionNotMet",
+				"The condition specified using HTTP conditional header(s) is not met.")
+			return false
+		}
+	}
+	if !exists {
+		return true
+	}
+	if m := h.Get("If-None-Match"); m != "" && (m == "*" || m == etag) {
+		if m == "*" && !read {
+			storageError(w, http.StatusConflict, "BlobAlreadyExists", "The specified blob already exists.")
+			return false
+		}
+		return fail()
+	}
+	lm := lastModified.Truncate(time.Second)
+	if v := h.Get("If-Modified-Since"); v != "" {
+		if t, err := http.ParseTime(v); err == nil && !lm.After(t) {
+			return fail()
+		}
+	}
+	if v := h.Get("If-Unmodified-Since"); v != "" {
+		if t, err := http.ParseTime(v); err == nil && lm.After(t) {
+			storageError(w, http.StatusPreconditionFailed, "ConditionNotMet",
+				"The condition specified using HTTP conditional header(s) is not met.")
+			return false
+		}
+	}
+	return true
+}
+
+// storageMetadata collects the x-ms-meta-* request headers.
+func storageMetadata(r *http.Request) map[string]string {
+	md := map[string]string{}
+	for k, v := range r.Header {
+		if name, ok := strings.CutPrefix(strings.ToLower(k), "x-ms-meta-"); ok && len(v) > 0 {
+			md[name] = v[0]
+		}
+	}
+	if len(md) == 0 {
+		return nil
+	}
+	return md
+}
+
+// setMetadataHeaders writes metadata back as x-ms-meta-* headers.
+func setMetadataHeaders(w http.ResponseWriter, md map[string]string) {
+	for k, v := range md {
+		w.Header()["x-ms-meta-"+k] = []string{v}
+	}
+}
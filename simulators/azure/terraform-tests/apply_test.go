@@ -37,6 +37,8 @@ import (
 //   - Microsoft.App/managedEnvironments + containerApps + jobs
 //   - Microsoft.Web/serverfarms + sites (Function App)
 //   - Microsoft.Storage/storageAccounts (azurerm-managed)
+//   - Microsoft.Storage/storageAccounts/blobServices/containers
+//   - Microsoft.Storage/storageAccounts/queueServices/queues
 func TestTerraformApplyDestroy(t *testing.T) {
 	// The azurestack + azurerm terraform providers validate the sim's
 	// self-signed HTTPS cert against the OS trust store. On Linux they
@@ -147,6 +149,14 @@ func TestTerraformApplyDestroy(t *testing.T) {
 	require.Contains(t, azrmST, "/providers/Microsoft.Storage/storageAccounts/tfazrmst12345",
 		"azurerm storage account id must include canonical ARM path; got %s", azrmST)
 
+	azrmContainer := outputs.must(t, "azrm_storage_container_id")
+	require.Contains(t, azrmContainer, "/storageAccounts/tfazrmst12345/blobServices/default/containers/runner-artifacts",
+		"azurerm storage container id must include canonical ARM path; got %s", azrmContainer)
+
+	azrmQueue := outputs.must(t, "azrm_storage_queue_id")
+	require.Contains(t, azrmQueue, "/storageAccounts/tfazrmst12345/queueServices/default/queues/runner-jobs",
+		"azurerm storage queue id must include canonical ARM path; got %s", azrmQueue)
+
 	azrmFA := outputs.must(t, "azrm_function_app_id")
 	require.Contains(t, azrmFA, "/providers/Microsoft.Web/sites/tf-azrm-fa",
 		"azurerm Function App id must include canonical ARM path; got %s", azrmFA)
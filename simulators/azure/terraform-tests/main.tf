@@ -256,6 +256,26 @@ resource "azurerm_storage_account" "az_st" {
   account_replication_type = "LRS"
 }
 
+# Blob container + queue through the ARM control plane
+# (storage_account_id); the sim backs both with the same store its
+# Blob / Queue data planes serve, so runner artifacts and queue
+# triggers see what terraform created.
+resource "azurerm_storage_container" "az_artifacts" {
+  provider              = azurerm
+  name                  = "runner-artifacts"
+  storage_account_id    = azurerm_storage_account.az_st.id
+  container_access_type = "private"
+  metadata = {
+    purpose = "artifacts"
+  }
+}
+
+resource "azurerm_storage_queue" "az_jobs" {
+  provider           = azurerm
+  name               = "runner-jobs"
+  storage_account_id = azurerm_storage_account.az_st.id
+}
+
 # Linux Function App — AZF runner backend's host primitive.
 resource "azurerm_linux_function_app" "az_fa" {
   provider                   = azurerm
@@ -351,6 +371,14 @@ output "azrm_storage_account_id" {
   value = azurerm_storage_account.az_st.id
 }
 
+output "azrm_storage_container_id" {
+  value = azurerm_storage_container.az_artifacts.id
+}
+
+output "azrm_storage_queue_id" {
+  value = azurerm_storage_queue.az_jobs.id
+}
+
 output "azrm_function_app_id" {
   value = azurerm_linux_function_app.az_fa.id
 }
@@ -20,14 +20,15 @@ import (
 
 // Repository represents an Artifact Registry repository.
 type Repository struct {
-	Name                   string         `json:"name"`
-	Format                 string         `json:"format"`
-	Mode                   string         `json:"mode,omitempty"`
-	Description            string         `json:"description,omitempty"`
-	RemoteRepositoryConfig map[string]any `json:"remoteRepositoryConfig,omitempty"`
-	RegistryURI            string         `json:"registryUri,omitempty"`
-	CreateTime             string         `json:"createTime"`
-	UpdateTime             string         `json:"updateTime"`
+	Name                   string            `json:"name"`
+	Format                 string            `json:"format"`
+	Mode                   string            `json:"mode,omitempty"`
+	Description            string            `json:"description,omitempty"`
+	Labels                 map[string]string `json:"labels,omitempty"`
+	RemoteRepositoryConfig map[string]any    `json:"remoteRepositoryConfig,omitempty"`
+	RegistryURI            string            `json:"registryUri,omitempty"`
+	CreateTime             string            `json:"createTime"`
+	UpdateTime             string            `json:"updateTime"`
 }
 
 // DockerImage represents a Docker image in Artifact Registry.
@@ -69,6 +70,7 @@ func registerArtifactRegistry(srv *sim.Server) {
 
 	// OCI Distribution stores
 	manifests := sim.MakeStore[OCIManifest](srv.DB(), "ar_manifests")
+	arReferrers = sim.MakeStore[[]OCIReferrer](srv.DB(), "ar_referrers")
 	blobs := sim.MakeStore[OCIBlob](srv.DB(), "ar_blobs")
 	uploads := sim.MakeStore[OCIUpload](srv.DB(), "ar_uploads")
 
@@ -258,9 +260,15 @@ func registerArtifactRegistry(srv *sim.Server) {
 		}
 
 		// Parse OCI paths: /v2/{name}/manifests/{reference}
+		//                   v2/{name}/referrers/{digest}
 		//                   v2/{name}/blobs/{digest}
 		//                   v2/{name}/blobs/uploads/
 		//                   v2/{name}/blobs/uploads/{uuid}
+		if idx := strings.Index(rest, "/referrers/"); idx >= 0 {
+			handleOCIReferrers(w, r, rest[:idx], rest[idx+len("/referrers/"):])
+			return
+		}
+
 		if idx := strings.Index(rest, "/manifests/"); idx >= 0 {
 			imageName := rest[:idx]
 			reference := rest[idx+len("/manifests/"):]
@@ -332,6 +340,16 @@ func handleOCIManifest(w http.ResponseWriter, r *http.Request, manifests sim.Sto
 			ContentType: contentType,
 			Data:        data,
 		})
+		// Also addressable by digest, as referrers are listed by it.
+		if digest := digestBytes(data); digest != reference {
+			manifests.Put(imageName+"/manifests/"+digest, OCIManifest{
+				ContentType: contentType,
+				Data:        data,
+			})
+		}
+		if subject := recordOCIReferrer(imageName, contentType, data); subject != "" {
+			w.Header().Set("OCI-Subject", subject)
+		}
 
 		registerDockerImageFromManifest(dockerImages, imageName, reference, contentType, data)
 
@@ -607,6 +625,7 @@ func registerDockerImageFromManifest(dockerImages sim.Store[DockerImage], imageN
 		MediaType:  contentType,
 	}
 	dockerImages.Put(imgName, img)
+	recordImageDiscovery(project, "https://"+img.URI)
 }
 
 func artifactRegistryImageParts(imageName string) (project, location, repoID, imagePath string, ok bool) {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// OCIReferrer is one entry of a manifest's referrers index: a pushed
+// manifest whose subject names it (signatures, attestations, SBOMs).
+type OCIReferrer struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int               `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// arReferrers indexes referrers by {name}@{subject digest}.
+var arReferrers sim.Store[[]OCIReferrer]
+
+// recordOCIReferrer indexes a pushed manifest under its subject and
+// returns the subject digest, "" for a manifest without one.
+func recordOCIReferrer(imageName, contentType string, data []byte) string {
+	var m struct {
+		ArtifactType string `json:"artifactType"`
+		Config       struct {
+			MediaType string `json:"mediaType"`
+		} `json:"config"`
+		Subject *struct {
+			Digest string `json:"digest"`
+		} `json:"subject"`
+		Annotations map[string]string `json:"annotations"`
+	}
+	if json.Unmarshal(data, &m) != nil || m.Subject == nil || m.Subject.Digest == "" {
+		return ""
+	}
+	artifactType := m.ArtifactType
+	if artifactType == "" {
+		artifactType = m.Config.MediaType
+	}
+	digest := digestBytes(data)
+	key := imageName + "@" + m.Subject.Digest
+	refs, _ := arReferrers.Get(key)
+	for _, r := range refs {
+		if r.Digest == digest {
+			return m.Subject.Digest
+		}
+	}
+	arReferrers.Put(key, append(refs, OCIReferrer{
+		MediaType:    contentType,
+		Digest:       digest,
+		Size:         len(data),
+		ArtifactType: artifactType,
+		Annotations:  m.Annotations,
+	}))
+	return m.Subject.Digest
+}
+
+// handleOCIReferrers serves GET /v2/{name}/referrers/{digest}, with
+// the optional artifactType filter.
+func handleOCIReferrers(w http.ResponseWriter, r *http.Request, imageName, digest string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	refs, _ := arReferrers.Get(imageName + "@" + digest)
+	if at := r.URL.Query().Get("artifactType"); at != "" {
+		var filtered []OCIReferrer
+		for _, ref := range refs {
+			if ref.ArtifactType == at {
+				filtered = append(filtered, ref)
+			}
+		}
+		refs = filtered
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	if refs == nil {
+		refs = []OCIReferrer{}
+	}
+	w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.index.v1+json",
+		"manifests":     refs,
+	})
+}
@@ -0,0 +1,91 @@
+package gcp_cli_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudScheduler_HTTPJobLifecycle(t *testing.T) {
+	hit := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case hit <- r.Header.Get("X-CloudScheduler-JobName"):
+		default:
+		}
+	}))
+	defer target.Close()
+
+	runCLI(t, gcloudCLI("scheduler", "jobs", "create", "http", "cli-http-job",
+		"--location="+location,
+		"--schedule=0 3 * * *",
+		"--time-zone=Asia/Tokyo",
+		"--uri="+target.URL+"/cron",
+		"--http-method=POST",
+	))
+
+	out := runCLI(t, gcloudCLI("scheduler", "jobs", "describe", "cli-http-job",
+		"--location="+location, "--format=json"))
+	var job struct {
+		Name         string `json:"name"`
+		State        string `json:"state"`
+		Schedule     string `json:"schedule"`
+		TimeZone     string `json:"timeZone"`
+		ScheduleTime string `json:"scheduleTime"`
+	}
+	parseJSON(t, out, &job)
+	assert.Equal(t, "projects/"+project+"/locations/"+location+"/jobs/cli-http-job", job.Name)
+	assert.Equal(t, "ENABLED", job.State)
+	assert.Equal(t, "0 3 * * *", job.Schedule)
+	assert.Equal(t, "Asia/Tokyo", job.TimeZone)
+	assert.NotEmpty(t, job.ScheduleTime)
+
+	runCLI(t, gcloudCLI("scheduler", "jobs", "run", "cli-http-job", "--location="+location))
+	select {
+	case name := <-hit:
+		assert.Equal(t, "cli-http-job", name)
+	case <-time.After(10 * time.Second):
+		t.Fatal("HTTP target never received the forced run")
+	}
+
+	runCLI(t, gcloudCLI("scheduler", "jobs", "pause", "cli-http-job", "--location="+location))
+	out = runCLI(t, gcloudCLI("scheduler", "jobs", "describe", "cli-http-job",
+		"--location="+location, "--format=json"))
+	parseJSON(t, out, &job)
+	assert.Equal(t, "PAUSED", job.State)
+
+	runCLI(t, gcloudCLI("scheduler", "jobs", "delete", "cli-http-job", "--location="+location))
+}
+
+func TestCloudScheduler_PubSubJob(t *testing.T) {
+	runCLI(t, gcloudCLI("pubsub", "topics", "create", "cli-scheduler-topic"))
+	runCLI(t, gcloudCLI("scheduler", "jobs", "create", "pubsub", "cli-pubsub-job",
+		"--location="+location,
+		"--schedule=*/10 * * * *",
+		"--topic=cli-scheduler-topic",
+		"--message-body=tick",
+	))
+
+	out := runCLI(t, gcloudCLI("scheduler", "jobs", "list", "--location="+location, "--format=json"))
+	var jobs []struct {
+		Name         string `json:"name"`
+		PubsubTarget struct {
+			TopicName string `json:"topicName"`
+		} `json:"pubsubTarget"`
+	}
+	parseJSON(t, out, &jobs)
+	found := false
+	for _, j := range jobs {
+		if j.Name == "projects/"+project+"/locations/"+location+"/jobs/cli-pubsub-job" {
+			found = true
+			assert.Equal(t, "projects/"+project+"/topics/cli-scheduler-topic", j.PubsubTarget.TopicName)
+		}
+	}
+	assert.True(t, found, "created job should be listed")
+
+	runCLI(t, gcloudCLI("scheduler", "jobs", "delete", "cli-pubsub-job", "--location="+location))
+	runCLI(t, gcloudCLI("pubsub", "topics", "delete", "cli-scheduler-topic"))
+}
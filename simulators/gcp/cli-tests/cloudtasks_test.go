@@ -0,0 +1,83 @@
+package gcp_cli_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudTasks_QueueLifecycle(t *testing.T) {
+	runCLI(t, gcloudCLI("tasks", "queues", "create", "cli-queue",
+		"--location="+location,
+		"--max-dispatches-per-second=10",
+		"--max-attempts=5",
+	))
+
+	out := runCLI(t, gcloudCLI("tasks", "queues", "describe", "cli-queue",
+		"--location="+location, "--format=json"))
+	var q struct {
+		Name       string `json:"name"`
+		State      string `json:"state"`
+		RateLimits struct {
+			MaxDispatchesPerSecond float64 `json:"maxDispatchesPerSecond"`
+		} `json:"rateLimits"`
+		RetryConfig struct {
+			MaxAttempts int `json:"maxAttempts"`
+		} `json:"retryConfig"`
+	}
+	parseJSON(t, out, &q)
+	assert.Equal(t, "projects/"+project+"/locations/"+location+"/queues/cli-queue", q.Name)
+	assert.Equal(t, "RUNNING", q.State)
+	assert.Equal(t, 10.0, q.RateLimits.MaxDispatchesPerSecond)
+	assert.Equal(t, 5, q.RetryConfig.MaxAttempts)
+
+	runCLI(t, gcloudCLI("tasks", "queues", "pause", "cli-queue", "--location="+location))
+	out = runCLI(t, gcloudCLI("tasks", "queues", "describe", "cli-queue",
+		"--location="+location, "--format=json"))
+	parseJSON(t, out, &q)
+	assert.Equal(t, "PAUSED", q.State)
+
+	runCLI(t, gcloudCLI("tasks", "queues", "resume", "cli-queue", "--location="+location))
+	runCLI(t, gcloudCLI("tasks", "queues", "delete", "cli-queue", "--location="+location))
+}
+
+func TestCloudTasks_CreateHTTPTaskDispatches(t *testing.T) {
+	hit := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case hit <- r.Header.Get("X-CloudTasks-QueueName"):
+		default:
+		}
+	}))
+	defer target.Close()
+
+	runCLI(t, gcloudCLI("tasks", "queues", "create", "cli-http-queue", "--location="+location))
+	runCLI(t, gcloudCLI("tasks", "create-http-task",
+		"--queue=cli-http-queue",
+		"--location="+location,
+		"--url="+target.URL+"/task",
+		"--method=POST",
+		"--body-content=work",
+	))
+
+	select {
+	case q := <-hit:
+		assert.Equal(t, "cli-http-queue", q)
+	case <-time.After(10 * time.Second):
+		t.Fatal("task was never dispatched")
+	}
+
+	require.Eventually(t, func() bool {
+		out := runCLI(t, gcloudCLI("tasks", "list", "--queue=cli-http-queue",
+			"--location="+location, "--format=json"))
+		var tasks []map[string]any
+		parseJSON(t, out, &tasks)
+		return len(tasks) == 0
+	}, 10*time.Second, 500*time.Millisecond)
+
+	runCLI(t, gcloudCLI("tasks", "queues", "delete", "cli-http-queue", "--location="+location))
+}
@@ -140,6 +140,13 @@ func gcloudCLI(args ...string) *exec.Cmd {
 		"CLOUDSDK_API_ENDPOINT_OVERRIDES_VPCACCESS="+baseURL+"/",
 		"CLOUDSDK_API_ENDPOINT_OVERRIDES_COMPUTE="+baseURL+"/",
 		"CLOUDSDK_API_ENDPOINT_OVERRIDES_ARTIFACTREGISTRY="+baseURL+"/",
+		"CLOUDSDK_API_ENDPOINT_OVERRIDES_PUBSUB="+baseURL+"/",
+		"CLOUDSDK_API_ENDPOINT_OVERRIDES_CLOUDTASKS="+baseURL+"/",
+		"CLOUDSDK_API_ENDPOINT_OVERRIDES_CLOUDSCHEDULER="+baseURL+"/",
+		"CLOUDSDK_API_ENDPOINT_OVERRIDES_STORAGE="+baseURL+"/storage/v1/",
+		// Composite uploads need the compose API; keep large copies on
+		// the resumable path.
+		"CLOUDSDK_STORAGE_PARALLEL_COMPOSITE_UPLOAD_ENABLED=False",
 	)
 	return cmd
 }
@@ -0,0 +1,67 @@
+package gcp_cli_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSub_TopicSubscriptionPublishPull(t *testing.T) {
+	runCLI(t, gcloudCLI("pubsub", "topics", "create", "cli-topic"))
+	runCLI(t, gcloudCLI("pubsub", "subscriptions", "create", "cli-sub",
+		"--topic=cli-topic",
+		"--ack-deadline=20",
+	))
+
+	out := runCLI(t, gcloudCLI("pubsub", "subscriptions", "describe", "cli-sub", "--format=json"))
+	var sub struct {
+		Name               string `json:"name"`
+		Topic              string `json:"topic"`
+		AckDeadlineSeconds int    `json:"ackDeadlineSeconds"`
+	}
+	parseJSON(t, out, &sub)
+	assert.Equal(t, "projects/"+project+"/subscriptions/cli-sub", sub.Name)
+	assert.Equal(t, "projects/"+project+"/topics/cli-topic", sub.Topic)
+	assert.Equal(t, 20, sub.AckDeadlineSeconds)
+
+	runCLI(t, gcloudCLI("pubsub", "topics", "publish", "cli-topic",
+		"--message=hello from gcloud",
+		"--attribute=origin=cli",
+	))
+
+	out = runCLI(t, gcloudCLI("pubsub", "subscriptions", "pull", "cli-sub",
+		"--auto-ack", "--limit=1", "--format=json"))
+	var pulled []struct {
+		Message struct {
+			Data       string            `json:"data"`
+			Attributes map[string]string `json:"attributes"`
+		} `json:"message"`
+	}
+	parseJSON(t, out, &pulled)
+	require.Len(t, pulled, 1)
+	data, _ := base64.StdEncoding.DecodeString(pulled[0].Message.Data)
+	assert.Equal(t, "hello from gcloud", string(data))
+	assert.Equal(t, "cli", pulled[0].Message.Attributes["origin"])
+
+	runCLI(t, gcloudCLI("pubsub", "subscriptions", "delete", "cli-sub"))
+	runCLI(t, gcloudCLI("pubsub", "topics", "delete", "cli-topic"))
+}
+
+func TestPubSub_ListTopics(t *testing.T) {
+	runCLI(t, gcloudCLI("pubsub", "topics", "create", "cli-list-topic"))
+
+	out := runCLI(t, gcloudCLI("pubsub", "topics", "list", "--format=json"))
+	var topics []struct {
+		Name string `json:"name"`
+	}
+	parseJSON(t, out, &topics)
+	found := false
+	for _, tp := range topics {
+		found = found || tp.Name == "projects/"+project+"/topics/cli-list-topic"
+	}
+	assert.True(t, found, "created topic should be listed")
+
+	runCLI(t, gcloudCLI("pubsub", "topics", "delete", "cli-list-topic"))
+}
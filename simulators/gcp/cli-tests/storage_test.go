@@ -0,0 +1,42 @@
+package gcp_cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_ResumableCopy(t *testing.T) {
+	runCLI(t, gcloudCLI("storage", "buckets", "create", "gs://cli-resumable-bucket"))
+
+	// 20 MiB is above gcloud's resumable threshold, so the copy runs
+	// as a resumable session.
+	content := bytes.Repeat([]byte("0123456789abcdef"), 20<<20/16)
+	localFile := filepath.Join(tmpDir, "large.bin")
+	require.NoError(t, os.WriteFile(localFile, content, 0644))
+
+	runCLI(t, gcloudCLI("storage", "cp", localFile, "gs://cli-resumable-bucket/large.bin"))
+
+	out := runCLI(t, gcloudCLI("storage", "objects", "describe",
+		"gs://cli-resumable-bucket/large.bin", "--format=json"))
+	var obj struct {
+		CRC32CHash string `json:"crc32c_hash"`
+		MD5Hash    string `json:"md5_hash"`
+	}
+	parseJSON(t, out, &obj)
+	assert.NotEmpty(t, obj.CRC32CHash)
+	assert.NotEmpty(t, obj.MD5Hash)
+
+	downloadFile := filepath.Join(tmpDir, "large-downloaded.bin")
+	runCLI(t, gcloudCLI("storage", "cp", "gs://cli-resumable-bucket/large.bin", downloadFile))
+	data, err := os.ReadFile(downloadFile)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(content, data), "downloaded object differs from upload")
+
+	runCLI(t, gcloudCLI("storage", "rm", "gs://cli-resumable-bucket/large.bin"))
+	runCLI(t, gcloudCLI("storage", "buckets", "delete", "gs://cli-resumable-bucket"))
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// Cloud Scheduler v1 slice: cron jobs with Pub/Sub or HTTP targets. HTTP
+// targets go through httptarget.go, so a job aimed at the Cloud Run Admin
+// `jobs.run` URL starts a simulated Cloud Run job execution. App Engine
+// targets are rejected. Real API: https://cloud.google.com/scheduler/docs/reference/rest
+
+// SchedulerPubsubTarget publishes a message to a topic on each run.
+type SchedulerPubsubTarget struct {
+	TopicName  string            `json:"topicName"`
+	Data       string            `json:"data,omitempty"` // base64-encoded
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// SchedulerHTTPTarget sends an HTTP request on each run.
+type SchedulerHTTPTarget struct {
+	URI        string            `json:"uri"`
+	HTTPMethod string            `json:"httpMethod,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"` // base64-encoded
+	OAuthToken *OAuthToken       `json:"oauthToken,omitempty"`
+	OidcToken  *OidcToken        `json:"oidcToken,omitempty"`
+}
+
+// SchedulerRetryConfig controls retries of a failed run.
+type SchedulerRetryConfig struct {
+	RetryCount         int    `json:"retryCount,omitempty"`
+	MaxRetryDuration   string `json:"maxRetryDuration,omitempty"`
+	MinBackoffDuration string `json:"minBackoffDuration,omitempty"`
+	MaxBackoffDuration string `json:"maxBackoffDuration,omitempty"`
+	MaxDoublings       int    `json:"maxDoublings,omitempty"`
+}
+
+// SchedulerJob is a Cloud Scheduler job resource.
+type SchedulerJob struct {
+	Name                string                 `json:"name"`
+	Description         string                 `json:"description,omitempty"`
+	PubsubTarget        *SchedulerPubsubTarget `json:"pubsubTarget,omitempty"`
+	HTTPTarget          *SchedulerHTTPTarget   `json:"httpTarget,omitempty"`
+	AppEngineHTTPTarget map[string]any         `json:"appEngineHttpTarget,omitempty"`
+	Schedule            string                 `json:"schedule,omitempty"`
+	TimeZone            string                 `json:"timeZone,omitempty"`
+	UserUpdateTime      string                 `json:"userUpdateTime,omitempty"`
+	State               string                 `json:"state,omitempty"`
+	Status              map[string]any         `json:"status,omitempty"`
+	ScheduleTime        string                 `json:"scheduleTime,omitempty"`
+	LastAttemptTime     string                 `json:"lastAttemptTime,omitempty"`
+	RetryConfig         *SchedulerRetryConfig  `json:"retryConfig,omitempty"`
+	AttemptDeadline     string                 `json:"attemptDeadline,omitempty"`
+}
+
+const (
+	schedulerDefaultMinBackoff      = 5 * time.Second
+	schedulerDefaultMaxBackoff      = time.Hour
+	schedulerDefaultMaxDoublings    = 5
+	schedulerDefaultAttemptDeadline = 3 * time.Minute
+	schedulerMaxRetryCount          = 5
+)
+
+var (
+	csJobs sim.Store[SchedulerJob]
+
+	// csRunning tracks jobs with a run in progress so a slow target
+	// doesn't pile up overlapping runs; real Cloud Scheduler skips a
+	// tick while the previous run is still retrying.
+	csMu      sync.Mutex
+	csRunning = map[string]bool{}
+)
+
+func registerCloudScheduler(srv *sim.Server) {
+	csJobs = sim.MakeStore[SchedulerJob](srv.DB(), "cloudscheduler_jobs")
+
+	// CreateJob: POST /v1/projects/{project}/locations/{location}/jobs
+	srv.HandleFunc("POST /v1/projects/{project}/locations/{location}/jobs", func(w http.ResponseWriter, r *http.Request) {
+		parent := fmt.Sprintf("projects/%s/locations/%s", sim.PathParam(r, "project"), sim.PathParam(r, "location"))
+		var job SchedulerJob
+		if err := sim.ReadJSON(r, &job); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		if !strings.HasPrefix(job.Name, parent+"/jobs/") || strings.Count(job.Name, "/") != 5 {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Job name %q must be of the form %s/jobs/JOB_ID", job.Name, parent)
+			return
+		}
+		if _, exists := csJobs.Get(job.Name); exists {
+			sim.GCPError(w, http.StatusConflict, "Job already exists.", "ALREADY_EXISTS")
+			return
+		}
+		job.State = "ENABLED"
+		job.Status = nil
+		job.LastAttemptTime = ""
+		if msg := normalizeSchedulerJob(&job, time.Now()); msg != "" {
+			sim.GCPError(w, http.StatusBadRequest, msg, "INVALID_ARGUMENT")
+			return
+		}
+		csJobs.Put(job.Name, job)
+		sim.WriteJSON(w, http.StatusOK, job)
+	})
+
+	// GetJob: GET /v1/projects/{project}/locations/{location}/jobs/{job}
+	srv.HandleFunc("GET /v1/projects/{project}/locations/{location}/jobs/{job}", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := csJobs.Get(schedulerJobNameFromPath(r))
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Job not found.", "NOT_FOUND")
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, job)
+	})
+
+	// ListJobs: GET /v1/projects/{project}/locations/{location}/jobs
+	srv.HandleFunc("GET /v1/projects/{project}/locations/{location}/jobs", func(w http.ResponseWriter, r *http.Request) {
+		prefix := fmt.Sprintf("projects/%s/locations/%s/jobs/", sim.PathParam(r, "project"), sim.PathParam(r, "location"))
+		jobs := csJobs.Filter(func(j SchedulerJob) bool { return strings.HasPrefix(j.Name, prefix) })
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+		sim.WriteJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+	})
+
+	// UpdateJob: PATCH /v1/projects/{project}/locations/{location}/jobs/{job}?updateMask=...
+	srv.HandleFunc("PATCH /v1/projects/{project}/locations/{location}/jobs/{job}", func(w http.ResponseWriter, r *http.Request) {
+		name := schedulerJobNameFromPath(r)
+		var in SchedulerJob
+		if err := sim.ReadJSON(r, &in); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		job, ok := csJobs.Get(name)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Job not found.", "NOT_FOUND")
+			return
+		}
+		mask := splitUpdateMask(r.URL.Query().Get("updateMask"))
+		if len(mask) == 0 {
+			mask = []string{"description", "pubsubTarget", "httpTarget", "appEngineHttpTarget", "schedule", "timeZone", "retryConfig", "attemptDeadline"}
+		}
+		for _, f := range mask {
+			top, _, _ := strings.Cut(f, ".")
+			switch top {
+			case "description":
+				job.Description = in.Description
+			case "pubsubTarget", "pubsub_target":
+				job.PubsubTarget = in.PubsubTarget
+			case "httpTarget", "http_target":
+				job.HTTPTarget = in.HTTPTarget
+			case "appEngineHttpTarget", "app_engine_http_target":
+				job.AppEngineHTTPTarget = in.AppEngineHTTPTarget
+			case "schedule":
+				job.Schedule = in.Schedule
+			case "timeZone", "time_zone":
+				job.TimeZone = in.TimeZone
+			case "retryConfig", "retry_config":
+				job.RetryConfig = in.RetryConfig
+			case "attemptDeadline", "attempt_deadline":
+				job.AttemptDeadline = in.AttemptDeadline
+			default:
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid update_mask path %q", f)
+				return
+			}
+		}
+		if msg := normalizeSchedulerJob(&job, time.Now()); msg != "" {
+			sim.GCPError(w, http.StatusBadRequest, msg, "INVALID_ARGUMENT")
+			return
+		}
+		csJobs.Put(name, job)
+		sim.WriteJSON(w, http.StatusOK, job)
+	})
+
+	// DeleteJob: DELETE /v1/projects/{project}/locations/{location}/jobs/{job}
+	srv.HandleFunc("DELETE /v1/projects/{project}/locations/{location}/jobs/{job}", func(w http.ResponseWriter, r *http.Request) {
+		if !csJobs.Delete(schedulerJobNameFromPath(r)) {
+			sim.GCPError(w, http.StatusNotFound, "Job not found.", "NOT_FOUND")
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+	})
+
+	// Job actions:
+	//   POST .../jobs/{job}:pause
+	//   POST .../jobs/{job}:resume
+	//   POST .../jobs/{job}:run   — forced run; ignores the schedule and a paused state
+	srv.HandleFunc("POST /v1/projects/{project}/locations/{location}/jobs/{jobAction}", func(w http.ResponseWriter, r *http.Request) {
+		jobID, action, found := strings.Cut(sim.PathParam(r, "jobAction"), ":")
+		if !found {
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "missing :action suffix on job %q", jobID)
+			return
+		}
+		name := fmt.Sprintf("projects/%s/locations/%s/jobs/%s", sim.PathParam(r, "project"), sim.PathParam(r, "location"), jobID)
+		job, ok := csJobs.Get(name)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Job not found.", "NOT_FOUND")
+			return
+		}
+		switch action {
+		case "pause":
+			csJobs.Update(name, func(j *SchedulerJob) {
+				j.State = "PAUSED"
+				j.ScheduleTime = ""
+			})
+		case "resume":
+			csJobs.Update(name, func(j *SchedulerJob) {
+				j.State = "ENABLED"
+				_ = normalizeSchedulerJob(j, time.Now())
+			})
+		case "run":
+			if !schedulerClaim(name) {
+				sim.GCPError(w, http.StatusConflict, "Job is already running.", "ABORTED")
+				return
+			}
+			go runSchedulerJob(context.Background(), job, time.Now().UTC())
+		default:
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "unknown job action %q", action)
+			return
+		}
+		job, _ = csJobs.Get(name)
+		sim.WriteJSON(w, http.StatusOK, job)
+	})
+
+	go runCloudSchedulerTicker(context.Background())
+}
+
+func schedulerJobNameFromPath(r *http.Request) string {
+	return fmt.Sprintf("projects/%s/locations/%s/jobs/%s",
+		sim.PathParam(r, "project"), sim.PathParam(r, "location"), sim.PathParam(r, "job"))
+}
+
+// normalizeSchedulerJob validates the target and schedule, fills the
+// server-side defaults, and recomputes the next scheduleTime of an
+// ENABLED job.
+func normalizeSchedulerJob(job *SchedulerJob, now time.Time) string {
+	targets := 0
+	if job.PubsubTarget != nil {
+		targets++
+		if _, ok := psTopics.Get(job.PubsubTarget.TopicName); !ok {
+			return fmt.Sprintf("Pub/Sub topic %q not found", job.PubsubTarget.TopicName)
+		}
+		if _, err := base64.StdEncoding.DecodeString(job.PubsubTarget.Data); err != nil {
+			return fmt.Sprintf("pubsubTarget.data must be base64: %v", err)
+		}
+		if job.PubsubTarget.Data == "" && len(job.PubsubTarget.Attributes) == 0 {
+			return "pubsubTarget must set data or at least one attribute"
+		}
+	}
+	if job.HTTPTarget != nil {
+		targets++
+		if _, _, err := resolveHTTPTarget(job.HTTPTarget.URI); err != nil {
+			return err.Error()
+		}
+		if job.HTTPTarget.OAuthToken != nil && job.HTTPTarget.OidcToken != nil {
+			return "Only one of oauth_token and oidc_token may be set"
+		}
+		if _, err := base64.StdEncoding.DecodeString(job.HTTPTarget.Body); err != nil {
+			return fmt.Sprintf("httpTarget.body must be base64: %v", err)
+		}
+		if job.HTTPTarget.HTTPMethod == "" {
+			job.HTTPTarget.HTTPMethod = http.MethodPost
+		}
+	}
+	if job.AppEngineHTTPTarget != nil {
+		return "App Engine targets are not supported by the simulator; use httpTarget or pubsubTarget"
+	}
+	if targets != 1 {
+		return "exactly one of pubsubTarget or httpTarget must be set"
+	}
+	if job.TimeZone == "" {
+		job.TimeZone = "Etc/UTC"
+	}
+	sched, err := parseCron(job.Schedule, job.TimeZone)
+	if err != nil {
+		return fmt.Sprintf("Schedule or time zone is invalid: %v", err)
+	}
+
+	if job.RetryConfig == nil {
+		job.RetryConfig = &SchedulerRetryConfig{}
+	}
+	rc := job.RetryConfig
+	if rc.RetryCount < 0 || rc.RetryCount > schedulerMaxRetryCount {
+		return fmt.Sprintf("retryConfig.retryCount must be in [0, %d], got %d", schedulerMaxRetryCount, rc.RetryCount)
+	}
+	if rc.MinBackoffDuration == "" {
+		rc.MinBackoffDuration = protoDuration(schedulerDefaultMinBackoff)
+	}
+	if rc.MaxBackoffDuration == "" {
+		rc.MaxBackoffDuration = protoDuration(schedulerDefaultMaxBackoff)
+	}
+	if rc.MaxRetryDuration == "" {
+		rc.MaxRetryDuration = "0s"
+	}
+	if rc.MaxDoublings == 0 {
+		rc.MaxDoublings = schedulerDefaultMaxDoublings
+	}
+	minB, err1 := time.ParseDuration(rc.MinBackoffDuration)
+	maxB, err2 := time.ParseDuration(rc.MaxBackoffDuration)
+	_, err3 := time.ParseDuration(rc.MaxRetryDuration)
+	if err1 != nil || err2 != nil || err3 != nil || minB > maxB {
+		return fmt.Sprintf("invalid retryConfig: minBackoffDuration=%q maxBackoffDuration=%q maxRetryDuration=%q",
+			rc.MinBackoffDuration, rc.MaxBackoffDuration, rc.MaxRetryDuration)
+	}
+	if job.AttemptDeadline == "" {
+		job.AttemptDeadline = protoDuration(schedulerDefaultAttemptDeadline)
+	} else if _, err := time.ParseDuration(job.AttemptDeadline); err != nil {
+		return fmt.Sprintf("invalid attemptDeadline %q", job.AttemptDeadline)
+	}
+
+	job.UserUpdateTime = now.UTC().Format(time.RFC3339Nano)
+	if job.State == "ENABLED" {
+		next := sched.next(now)
+		if next.IsZero() {
+			return fmt.Sprintf("Schedule %q never fires", job.Schedule)
+		}
+		job.ScheduleTime = next.UTC().Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+func schedulerClaim(name string) bool {
+	csMu.Lock()
+	defer csMu.Unlock()
+	if csRunning[name] {
+		return false
+	}
+	csRunning[name] = true
+	return true
+}
+
+// runCloudSchedulerTicker fires ENABLED jobs whose scheduleTime has
+// passed and advances them to their next slot.
+func runCloudSchedulerTicker(ctx context.Context) {
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		now := time.Now()
+		for _, job := range csJobs.List() {
+			if job.State != "ENABLED" || job.ScheduleTime == "" {
+				continue
+			}
+			due, err := time.Parse(time.RFC3339Nano, job.ScheduleTime)
+			if err != nil || due.After(now) {
+				continue
+			}
+			sched, err := parseCron(job.Schedule, job.TimeZone)
+			if err != nil {
+				continue
+			}
+			csJobs.Update(job.Name, func(j *SchedulerJob) {
+				j.ScheduleTime = sched.next(now).UTC().Format(time.RFC3339Nano)
+			})
+			if !schedulerClaim(job.Name) {
+				continue
+			}
+			go runSchedulerJob(ctx, job, due)
+		}
+	}
+}
+
+// runSchedulerJob executes one run of job, retrying per its retryConfig,
+// and records the final status. The caller must have claimed the job.
+func runSchedulerJob(ctx context.Context, job SchedulerJob, scheduled time.Time) {
+	defer func() {
+		csMu.Lock()
+		delete(csRunning, job.Name)
+		csMu.Unlock()
+	}()
+
+	rc := job.RetryConfig
+	backoff := &QueueRetryConfig{
+		MinBackoff:   rc.MinBackoffDuration,
+		MaxBackoff:   rc.MaxBackoffDuration,
+		MaxDoublings: rc.MaxDoublings,
+	}
+	maxRetryDuration, _ := time.ParseDuration(rc.MaxRetryDuration)
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		attemptTime := time.Now().UTC()
+		status := schedulerAttempt(ctx, job, scheduled)
+		csJobs.Update(job.Name, func(j *SchedulerJob) {
+			j.LastAttemptTime = attemptTime.Format(time.RFC3339Nano)
+			j.Status = status
+		})
+		if status == nil || attempt >= rc.RetryCount {
+			return
+		}
+		if maxRetryDuration > 0 && time.Since(start) >= maxRetryDuration {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(taskBackoff(backoff, attempt)):
+		}
+		if _, ok := csJobs.Get(job.Name); !ok {
+			return
+		}
+	}
+}
+
+// schedulerAttempt performs one delivery of job's target. It returns nil
+// on success, otherwise the google.rpc.Status recorded on the job.
+func schedulerAttempt(ctx context.Context, job SchedulerJob, scheduled time.Time) map[string]any {
+	switch {
+	case job.PubsubTarget != nil:
+		_, err := pubsubPublish(job.PubsubTarget.TopicName, []PubsubMessage{{
+			Data:       job.PubsubTarget.Data,
+			Attributes: job.PubsubTarget.Attributes,
+		}})
+		if err != nil {
+			return map[string]any{"code": 5, "message": err.Error()}
+		}
+		return nil
+
+	case job.HTTPTarget != nil:
+		t := job.HTTPTarget
+		body, _ := base64.StdEncoding.DecodeString(t.Body)
+		headers := make(map[string]string, len(t.Headers)+4)
+		for k, v := range t.Headers {
+			headers[k] = v
+		}
+		headers["User-Agent"] = "Google-Cloud-Scheduler"
+		headers["X-CloudScheduler"] = "true"
+		headers["X-CloudScheduler-JobName"] = job.Name[strings.LastIndex(job.Name, "/")+1:]
+		headers["X-CloudScheduler-ScheduleTime"] = scheduled.UTC().Format(time.RFC3339)
+		if len(body) > 0 {
+			if _, set := headers["Content-Type"]; !set {
+				headers["Content-Type"] = "application/octet-stream"
+			}
+		}
+		deadline, _ := time.ParseDuration(job.AttemptDeadline)
+		auth := targetAuth{OIDC: t.OidcToken, OAuth: t.OAuthToken}
+		status, err := dispatchHTTPTarget(ctx, t.HTTPMethod, t.URI, headers, body, auth, deadline)
+		if err != nil {
+			return map[string]any{"code": 4, "message": err.Error()}
+		}
+		code, _ := httpStatusToRPCCode(status)
+		if code == 0 {
+			return nil
+		}
+		return map[string]any{"code": code, "message": fmt.Sprintf("URL_ERROR-ERROR_OTHER. Original HTTP response code number = %d", status)}
+	}
+	return map[string]any{"code": 3, "message": "job has no target"}
+}
@@ -0,0 +1,678 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// Cloud Tasks v2 slice: queues with rate limits + retry config, HTTP
+// tasks dispatched to arbitrary URLs (including simulated Cloud Run
+// service URLs, see httptarget.go), pause / resume / purge, and forced
+// dispatch via tasks.run. App Engine targets are rejected — the sim has
+// no App Engine. Real API: https://cloud.google.com/tasks/docs/reference/rest
+
+// RateLimits controls how fast a queue dispatches. MaxBurstSize is
+// output-only and derived from MaxDispatchesPerSecond.
+type RateLimits struct {
+	MaxDispatchesPerSecond  float64 `json:"maxDispatchesPerSecond,omitempty"`
+	MaxBurstSize            int     `json:"maxBurstSize,omitempty"`
+	MaxConcurrentDispatches int     `json:"maxConcurrentDispatches,omitempty"`
+}
+
+// QueueRetryConfig controls task retry on a queue.
+type QueueRetryConfig struct {
+	MaxAttempts      int    `json:"maxAttempts,omitempty"`
+	MaxRetryDuration string `json:"maxRetryDuration,omitempty"`
+	MinBackoff       string `json:"minBackoff,omitempty"`
+	MaxBackoff       string `json:"maxBackoff,omitempty"`
+	MaxDoublings     int    `json:"maxDoublings,omitempty"`
+}
+
+// Queue is a Cloud Tasks queue resource.
+type Queue struct {
+	Name                     string            `json:"name"`
+	RateLimits               *RateLimits       `json:"rateLimits,omitempty"`
+	RetryConfig              *QueueRetryConfig `json:"retryConfig,omitempty"`
+	State                    string            `json:"state,omitempty"`
+	PurgeTime                string            `json:"purgeTime,omitempty"`
+	StackdriverLoggingConfig map[string]any    `json:"stackdriverLoggingConfig,omitempty"`
+	AppEngineRoutingOverride map[string]any    `json:"appEngineRoutingOverride,omitempty"`
+}
+
+// TaskHTTPRequest is the HTTP request a task dispatches.
+type TaskHTTPRequest struct {
+	URL        string            `json:"url"`
+	HTTPMethod string            `json:"httpMethod,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"` // base64-encoded
+	OAuthToken *OAuthToken       `json:"oauthToken,omitempty"`
+	OidcToken  *OidcToken        `json:"oidcToken,omitempty"`
+}
+
+// TaskAttempt records one dispatch of a task.
+type TaskAttempt struct {
+	ScheduleTime   string         `json:"scheduleTime,omitempty"`
+	DispatchTime   string         `json:"dispatchTime,omitempty"`
+	ResponseTime   string         `json:"responseTime,omitempty"`
+	ResponseStatus map[string]any `json:"responseStatus,omitempty"`
+}
+
+// Task is a Cloud Tasks task resource.
+type Task struct {
+	Name                 string           `json:"name"`
+	HTTPRequest          *TaskHTTPRequest `json:"httpRequest,omitempty"`
+	AppEngineHTTPRequest map[string]any   `json:"appEngineHttpRequest,omitempty"`
+	ScheduleTime         string           `json:"scheduleTime,omitempty"`
+	CreateTime           string           `json:"createTime,omitempty"`
+	DispatchDeadline     string           `json:"dispatchDeadline,omitempty"`
+	DispatchCount        int              `json:"dispatchCount,omitempty"`
+	ResponseCount        int              `json:"responseCount,omitempty"`
+	FirstAttempt         *TaskAttempt     `json:"firstAttempt,omitempty"`
+	LastAttempt          *TaskAttempt     `json:"lastAttempt,omitempty"`
+	View                 string           `json:"view,omitempty"`
+}
+
+const (
+	tasksDefaultMaxDispatchesPerSecond  = 500
+	tasksDefaultMaxConcurrentDispatches = 1000
+	tasksDefaultMaxAttempts             = 100
+	tasksDefaultMinBackoff              = 100 * time.Millisecond
+	tasksDefaultMaxBackoff              = time.Hour
+	tasksDefaultMaxDoublings            = 16
+	tasksDefaultDispatchDeadline        = 10 * time.Minute
+	tasksMinDispatchDeadline            = 15 * time.Second
+	tasksMaxDispatchDeadline            = 30 * time.Minute
+)
+
+var (
+	ctQueues sim.Store[Queue]
+	ctTasks  sim.Store[Task]
+
+	// ctMu guards the dispatcher's in-memory bookkeeping: per-queue token
+	// buckets and the set of tasks currently being dispatched.
+	ctMu       sync.Mutex
+	ctBuckets  = map[string]*tokenBucket{}
+	ctInflight = map[string]bool{}
+	ctKick     = make(chan struct{}, 1)
+)
+
+func registerCloudTasks(srv *sim.Server) {
+	ctQueues = sim.MakeStore[Queue](srv.DB(), "cloudtasks_queues")
+	ctTasks = sim.MakeStore[Task](srv.DB(), "cloudtasks_tasks")
+
+	// CreateQueue: POST /v2/projects/{project}/locations/{location}/queues
+	srv.HandleFunc("POST /v2/projects/{project}/locations/{location}/queues", func(w http.ResponseWriter, r *http.Request) {
+		parent := fmt.Sprintf("projects/%s/locations/%s", sim.PathParam(r, "project"), sim.PathParam(r, "location"))
+		var req Queue
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		if !strings.HasPrefix(req.Name, parent+"/queues/") || strings.Count(req.Name, "/") != 5 {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Queue name %q must be of the form %s/queues/QUEUE_ID", req.Name, parent)
+			return
+		}
+		if _, exists := ctQueues.Get(req.Name); exists {
+			sim.GCPError(w, http.StatusConflict, "Queue already exists", "ALREADY_EXISTS")
+			return
+		}
+		if msg := normalizeQueue(&req); msg != "" {
+			sim.GCPError(w, http.StatusBadRequest, msg, "INVALID_ARGUMENT")
+			return
+		}
+		req.State = "RUNNING"
+		ctQueues.Put(req.Name, req)
+		sim.WriteJSON(w, http.StatusOK, req)
+	})
+
+	// GetQueue: GET /v2/projects/{project}/locations/{location}/queues/{queue}
+	srv.HandleFunc("GET /v2/projects/{project}/locations/{location}/queues/{queue}", func(w http.ResponseWriter, r *http.Request) {
+		q, ok := ctQueues.Get(queueNameFromPath(r))
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, q)
+	})
+
+	// ListQueues: GET /v2/projects/{project}/locations/{location}/queues
+	srv.HandleFunc("GET /v2/projects/{project}/locations/{location}/queues", func(w http.ResponseWriter, r *http.Request) {
+		prefix := fmt.Sprintf("projects/%s/locations/%s/queues/", sim.PathParam(r, "project"), sim.PathParam(r, "location"))
+		queues := ctQueues.Filter(func(q Queue) bool { return strings.HasPrefix(q.Name, prefix) })
+		sort.Slice(queues, func(i, j int) bool { return queues[i].Name < queues[j].Name })
+		sim.WriteJSON(w, http.StatusOK, map[string]any{"queues": queues})
+	})
+
+	// UpdateQueue: PATCH /v2/projects/{project}/locations/{location}/queues/{queue}?updateMask=...
+	// Real Cloud Tasks creates the queue when it doesn't exist (upsert).
+	srv.HandleFunc("PATCH /v2/projects/{project}/locations/{location}/queues/{queue}", func(w http.ResponseWriter, r *http.Request) {
+		name := queueNameFromPath(r)
+		var in Queue
+		if err := sim.ReadJSON(r, &in); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		q, exists := ctQueues.Get(name)
+		if !exists {
+			q = Queue{Name: name, State: "RUNNING"}
+		}
+		mask := splitUpdateMask(r.URL.Query().Get("updateMask"))
+		if len(mask) == 0 {
+			mask = []string{"rateLimits", "retryConfig", "stackdriverLoggingConfig", "appEngineRoutingOverride"}
+		}
+		for _, f := range mask {
+			switch {
+			case f == "rateLimits" || f == "rate_limits":
+				q.RateLimits = in.RateLimits
+			case strings.HasPrefix(f, "rateLimits.") || strings.HasPrefix(f, "rate_limits."):
+				if q.RateLimits == nil {
+					q.RateLimits = &RateLimits{}
+				}
+				if in.RateLimits != nil {
+					switch f[strings.Index(f, ".")+1:] {
+					case "maxDispatchesPerSecond", "max_dispatches_per_second":
+						q.RateLimits.MaxDispatchesPerSecond = in.RateLimits.MaxDispatchesPerSecond
+					case "maxConcurrentDispatches", "max_concurrent_dispatches":
+						q.RateLimits.MaxConcurrentDispatches = in.RateLimits.MaxConcurrentDispatches
+					}
+				}
+			case f == "retryConfig" || f == "retry_config" || strings.HasPrefix(f, "retryConfig.") || strings.HasPrefix(f, "retry_config."):
+				q.RetryConfig = in.RetryConfig
+			case f == "stackdriverLoggingConfig" || f == "stackdriver_logging_config" || strings.HasPrefix(f, "stackdriverLoggingConfig."):
+				q.StackdriverLoggingConfig = in.StackdriverLoggingConfig
+			case f == "appEngineRoutingOverride" || f == "app_engine_routing_override" || strings.HasPrefix(f, "appEngineRoutingOverride."):
+				q.AppEngineRoutingOverride = in.AppEngineRoutingOverride
+			default:
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid update_mask path %q", f)
+				return
+			}
+		}
+		if msg := normalizeQueue(&q); msg != "" {
+			sim.GCPError(w, http.StatusBadRequest, msg, "INVALID_ARGUMENT")
+			return
+		}
+		ctQueues.Put(name, q)
+		ctMu.Lock()
+		delete(ctBuckets, name)
+		ctMu.Unlock()
+		sim.WriteJSON(w, http.StatusOK, q)
+	})
+
+	// DeleteQueue: DELETE /v2/projects/{project}/locations/{location}/queues/{queue}
+	srv.HandleFunc("DELETE /v2/projects/{project}/locations/{location}/queues/{queue}", func(w http.ResponseWriter, r *http.Request) {
+		name := queueNameFromPath(r)
+		if !ctQueues.Delete(name) {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		for _, t := range ctTasks.Filter(func(t Task) bool { return strings.HasPrefix(t.Name, name+"/tasks/") }) {
+			ctTasks.Delete(t.Name)
+		}
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+	})
+
+	// Queue actions:
+	//   POST .../queues/{queue}:pause
+	//   POST .../queues/{queue}:resume
+	//   POST .../queues/{queue}:purge
+	srv.HandleFunc("POST /v2/projects/{project}/locations/{location}/queues/{queueAction}", func(w http.ResponseWriter, r *http.Request) {
+		queueID, action, found := strings.Cut(sim.PathParam(r, "queueAction"), ":")
+		if !found {
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "missing :action suffix on queue %q", queueID)
+			return
+		}
+		name := fmt.Sprintf("projects/%s/locations/%s/queues/%s", sim.PathParam(r, "project"), sim.PathParam(r, "location"), queueID)
+		if _, ok := ctQueues.Get(name); !ok {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		switch action {
+		case "pause":
+			ctQueues.Update(name, func(q *Queue) { q.State = "PAUSED" })
+		case "resume":
+			ctQueues.Update(name, func(q *Queue) { q.State = "RUNNING" })
+			tasksKick()
+		case "purge":
+			for _, t := range ctTasks.Filter(func(t Task) bool { return strings.HasPrefix(t.Name, name+"/tasks/") }) {
+				ctTasks.Delete(t.Name)
+			}
+			ctQueues.Update(name, func(q *Queue) { q.PurgeTime = nowTimestamp() })
+		default:
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "unknown queue action %q", action)
+			return
+		}
+		q, _ := ctQueues.Get(name)
+		sim.WriteJSON(w, http.StatusOK, q)
+	})
+
+	// CreateTask: POST .../queues/{queue}/tasks
+	srv.HandleFunc("POST /v2/projects/{project}/locations/{location}/queues/{queue}/tasks", func(w http.ResponseWriter, r *http.Request) {
+		queueName := queueNameFromPath(r)
+		if _, ok := ctQueues.Get(queueName); !ok {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		var req struct {
+			Task         Task   `json:"task"`
+			ResponseView string `json:"responseView"`
+		}
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		task := req.Task
+		if task.AppEngineHTTPRequest != nil {
+			sim.GCPError(w, http.StatusBadRequest, "App Engine targets are not supported by the simulator; use httpRequest", "INVALID_ARGUMENT")
+			return
+		}
+		if task.HTTPRequest == nil || task.HTTPRequest.URL == "" {
+			sim.GCPError(w, http.StatusBadRequest, "Task.http_request.url is required", "INVALID_ARGUMENT")
+			return
+		}
+		if task.HTTPRequest.OAuthToken != nil && task.HTTPRequest.OidcToken != nil {
+			sim.GCPError(w, http.StatusBadRequest, "Only one of oauth_token and oidc_token may be set", "INVALID_ARGUMENT")
+			return
+		}
+		if _, err := base64.StdEncoding.DecodeString(task.HTTPRequest.Body); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "httpRequest.body must be base64: %v", err)
+			return
+		}
+		if task.Name == "" {
+			task.Name = fmt.Sprintf("%s/tasks/%s", queueName, randomTaskID())
+		} else if !strings.HasPrefix(task.Name, queueName+"/tasks/") {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Task name %q must be in queue %s", task.Name, queueName)
+			return
+		}
+		if _, exists := ctTasks.Get(task.Name); exists {
+			sim.GCPError(w, http.StatusConflict, "Requested entity already exists", "ALREADY_EXISTS")
+			return
+		}
+		now := time.Now().UTC()
+		if task.ScheduleTime == "" {
+			task.ScheduleTime = now.Format(time.RFC3339Nano)
+		} else if _, err := time.Parse(time.RFC3339Nano, task.ScheduleTime); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid scheduleTime %q", task.ScheduleTime)
+			return
+		}
+		if task.DispatchDeadline == "" {
+			task.DispatchDeadline = protoDuration(tasksDefaultDispatchDeadline)
+		} else if d, err := time.ParseDuration(task.DispatchDeadline); err != nil || d < tasksMinDispatchDeadline || d > tasksMaxDispatchDeadline {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "dispatchDeadline %q must be between 15s and 30m", task.DispatchDeadline)
+			return
+		}
+		if task.HTTPRequest.HTTPMethod == "" {
+			task.HTTPRequest.HTTPMethod = http.MethodPost
+		}
+		task.CreateTime = now.Format(time.RFC3339Nano)
+		task.DispatchCount = 0
+		task.ResponseCount = 0
+		task.FirstAttempt = nil
+		task.LastAttempt = nil
+		ctTasks.Put(task.Name, task)
+		tasksKick()
+		sim.WriteJSON(w, http.StatusOK, taskView(task, req.ResponseView))
+	})
+
+	// ListTasks: GET .../queues/{queue}/tasks
+	srv.HandleFunc("GET /v2/projects/{project}/locations/{location}/queues/{queue}/tasks", func(w http.ResponseWriter, r *http.Request) {
+		queueName := queueNameFromPath(r)
+		if _, ok := ctQueues.Get(queueName); !ok {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		view := r.URL.Query().Get("responseView")
+		tasks := ctTasks.Filter(func(t Task) bool { return strings.HasPrefix(t.Name, queueName+"/tasks/") })
+		sort.Slice(tasks, func(i, j int) bool { return tasks[i].ScheduleTime < tasks[j].ScheduleTime })
+		out := make([]Task, 0, len(tasks))
+		for _, t := range tasks {
+			out = append(out, taskView(t, view))
+		}
+		sim.WriteJSON(w, http.StatusOK, map[string]any{"tasks": out})
+	})
+
+	// GetTask: GET .../queues/{queue}/tasks/{task}
+	srv.HandleFunc("GET /v2/projects/{project}/locations/{location}/queues/{queue}/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+		t, ok := ctTasks.Get(queueNameFromPath(r) + "/tasks/" + sim.PathParam(r, "task"))
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, taskView(t, r.URL.Query().Get("responseView")))
+	})
+
+	// DeleteTask: DELETE .../queues/{queue}/tasks/{task}
+	srv.HandleFunc("DELETE /v2/projects/{project}/locations/{location}/queues/{queue}/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+		if !ctTasks.Delete(queueNameFromPath(r) + "/tasks/" + sim.PathParam(r, "task")) {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+	})
+
+	// RunTask: POST .../queues/{queue}/tasks/{task}:run — dispatches now,
+	// ignoring schedule time, rate limits and a paused queue. Returns the
+	// task as it was before the dispatch completes, like the real API.
+	srv.HandleFunc("POST /v2/projects/{project}/locations/{location}/queues/{queue}/tasks/{taskAction}", func(w http.ResponseWriter, r *http.Request) {
+		taskID, action, found := strings.Cut(sim.PathParam(r, "taskAction"), ":")
+		if !found || action != "run" {
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "unknown task action %q", sim.PathParam(r, "taskAction"))
+			return
+		}
+		var req struct {
+			ResponseView string `json:"responseView"`
+		}
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		queueName := queueNameFromPath(r)
+		q, ok := ctQueues.Get(queueName)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		name := queueName + "/tasks/" + taskID
+		t, ok := ctTasks.Get(name)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Requested entity was not found.", "NOT_FOUND")
+			return
+		}
+		ctMu.Lock()
+		if ctInflight[name] {
+			ctMu.Unlock()
+			sim.GCPError(w, http.StatusBadRequest, "Task is already running", "FAILED_PRECONDITION")
+			return
+		}
+		ctInflight[name] = true
+		ctMu.Unlock()
+		go dispatchTask(context.Background(), q, t)
+		sim.WriteJSON(w, http.StatusOK, taskView(t, req.ResponseView))
+	})
+
+	go runCloudTasksDispatcher(context.Background())
+}
+
+func queueNameFromPath(r *http.Request) string {
+	return fmt.Sprintf("projects/%s/locations/%s/queues/%s",
+		sim.PathParam(r, "project"), sim.PathParam(r, "location"), sim.PathParam(r, "queue"))
+}
+
+// normalizeQueue fills the server-side defaults real Cloud Tasks reports
+// on Get and rejects out-of-range values.
+func normalizeQueue(q *Queue) string {
+	if q.RateLimits == nil {
+		q.RateLimits = &RateLimits{}
+	}
+	rl := q.RateLimits
+	if rl.MaxDispatchesPerSecond == 0 {
+		rl.MaxDispatchesPerSecond = tasksDefaultMaxDispatchesPerSecond
+	}
+	if rl.MaxDispatchesPerSecond < 0 || rl.MaxDispatchesPerSecond > 500 {
+		return fmt.Sprintf("rateLimits.maxDispatchesPerSecond must be in (0, 500], got %v", rl.MaxDispatchesPerSecond)
+	}
+	if rl.MaxConcurrentDispatches == 0 {
+		rl.MaxConcurrentDispatches = tasksDefaultMaxConcurrentDispatches
+	}
+	if rl.MaxConcurrentDispatches < 1 || rl.MaxConcurrentDispatches > 5000 {
+		return fmt.Sprintf("rateLimits.maxConcurrentDispatches must be in [1, 5000], got %d", rl.MaxConcurrentDispatches)
+	}
+	rl.MaxBurstSize = int(math.Max(1, math.Ceil(rl.MaxDispatchesPerSecond)))
+
+	if q.RetryConfig == nil {
+		q.RetryConfig = &QueueRetryConfig{}
+	}
+	rc := q.RetryConfig
+	if rc.MaxAttempts == 0 {
+		rc.MaxAttempts = tasksDefaultMaxAttempts
+	}
+	if rc.MaxAttempts < -1 {
+		return fmt.Sprintf("retryConfig.maxAttempts must be -1 (unlimited) or positive, got %d", rc.MaxAttempts)
+	}
+	if rc.MinBackoff == "" {
+		rc.MinBackoff = protoDuration(tasksDefaultMinBackoff)
+	}
+	if rc.MaxBackoff == "" {
+		rc.MaxBackoff = protoDuration(tasksDefaultMaxBackoff)
+	}
+	if rc.MaxRetryDuration == "" {
+		rc.MaxRetryDuration = "0s"
+	}
+	if rc.MaxDoublings == 0 {
+		rc.MaxDoublings = tasksDefaultMaxDoublings
+	}
+	minB, err1 := time.ParseDuration(rc.MinBackoff)
+	maxB, err2 := time.ParseDuration(rc.MaxBackoff)
+	_, err3 := time.ParseDuration(rc.MaxRetryDuration)
+	if err1 != nil || err2 != nil || err3 != nil || minB > maxB {
+		return fmt.Sprintf("invalid retryConfig: minBackoff=%q maxBackoff=%q maxRetryDuration=%q", rc.MinBackoff, rc.MaxBackoff, rc.MaxRetryDuration)
+	}
+	return ""
+}
+
+// taskView applies the BASIC / FULL response view. BASIC (the default)
+// omits the request body, which can be large or sensitive.
+func taskView(t Task, view string) Task {
+	if view == "" {
+		view = "BASIC"
+	}
+	t.View = view
+	if view != "FULL" && t.HTTPRequest != nil {
+		req := *t.HTTPRequest
+		req.Body = ""
+		t.HTTPRequest = &req
+	}
+	return t
+}
+
+// randomTaskID returns a system-assigned task ID: a long decimal string,
+// the same shape real Cloud Tasks generates.
+func randomTaskID() string {
+	n, _ := rand.Int(rand.Reader, new(big.Int).Exp(big.NewInt(10), big.NewInt(19), nil))
+	return fmt.Sprintf("%019d", n)
+}
+
+func tasksKick() {
+	select {
+	case ctKick <- struct{}{}:
+	default:
+	}
+}
+
+// tokenBucket enforces a queue's maxDispatchesPerSecond with a burst of
+// maxBurstSize.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(rate float64, burst int, now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// runCloudTasksDispatcher dispatches due tasks on RUNNING queues within
+// each queue's rate and concurrency limits.
+func runCloudTasksDispatcher(ctx context.Context) {
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ctKick:
+		case <-tick.C:
+		}
+		now := time.Now()
+		for _, q := range ctQueues.List() {
+			if q.State != "RUNNING" || q.RateLimits == nil {
+				continue
+			}
+			due := ctTasks.Filter(func(t Task) bool {
+				if !strings.HasPrefix(t.Name, q.Name+"/tasks/") {
+					return false
+				}
+				st, err := time.Parse(time.RFC3339Nano, t.ScheduleTime)
+				return err == nil && !st.After(now)
+			})
+			sort.Slice(due, func(i, j int) bool { return due[i].ScheduleTime < due[j].ScheduleTime })
+
+			ctMu.Lock()
+			running := 0
+			for name := range ctInflight {
+				if strings.HasPrefix(name, q.Name+"/tasks/") {
+					running++
+				}
+			}
+			bucket := ctBuckets[q.Name]
+			if bucket == nil {
+				bucket = &tokenBucket{}
+				ctBuckets[q.Name] = bucket
+			}
+			for _, t := range due {
+				if running >= q.RateLimits.MaxConcurrentDispatches {
+					break
+				}
+				if ctInflight[t.Name] {
+					continue
+				}
+				if !bucket.take(q.RateLimits.MaxDispatchesPerSecond, q.RateLimits.MaxBurstSize, now) {
+					break
+				}
+				ctInflight[t.Name] = true
+				running++
+				go dispatchTask(ctx, q, t)
+			}
+			ctMu.Unlock()
+		}
+	}
+}
+
+// dispatchTask performs one attempt of t and records the outcome: the
+// task is deleted on a 2xx response or once its retry budget is spent,
+// otherwise rescheduled with the queue's backoff. The caller must have
+// marked t in-flight.
+func dispatchTask(ctx context.Context, q Queue, t Task) {
+	defer func() {
+		ctMu.Lock()
+		delete(ctInflight, t.Name)
+		ctMu.Unlock()
+	}()
+
+	dispatchTime := time.Now().UTC()
+	req := t.HTTPRequest
+	body, _ := base64.StdEncoding.DecodeString(req.Body)
+	headers := make(map[string]string, len(req.Headers)+6)
+	for k, v := range req.Headers {
+		headers[k] = v
+	}
+	queueID := q.Name[strings.LastIndex(q.Name, "/")+1:]
+	taskID := t.Name[strings.LastIndex(t.Name, "/")+1:]
+	eta, _ := time.Parse(time.RFC3339Nano, t.ScheduleTime)
+	headers["User-Agent"] = "Google-Cloud-Tasks"
+	headers["X-CloudTasks-QueueName"] = queueID
+	headers["X-CloudTasks-TaskName"] = taskID
+	headers["X-CloudTasks-TaskRetryCount"] = strconv.Itoa(t.DispatchCount)
+	headers["X-CloudTasks-TaskExecutionCount"] = strconv.Itoa(t.ResponseCount)
+	headers["X-CloudTasks-TaskETA"] = strconv.FormatFloat(float64(eta.UnixNano())/1e9, 'f', 6, 64)
+	auth := targetAuth{OIDC: req.OidcToken, OAuth: req.OAuthToken}
+	deadline, _ := time.ParseDuration(t.DispatchDeadline)
+
+	status, err := dispatchHTTPTarget(ctx, req.HTTPMethod, req.URL, headers, body, auth, deadline)
+	responseTime := time.Now().UTC()
+
+	attempt := &TaskAttempt{
+		ScheduleTime: t.ScheduleTime,
+		DispatchTime: dispatchTime.Format(time.RFC3339Nano),
+	}
+	if err == nil {
+		code, _ := httpStatusToRPCCode(status)
+		attempt.ResponseTime = responseTime.Format(time.RFC3339Nano)
+		attempt.ResponseStatus = map[string]any{"code": code, "message": http.StatusText(status)}
+	} else {
+		attempt.ResponseStatus = map[string]any{"code": 4, "message": err.Error()}
+	}
+
+	cur, ok := ctTasks.Get(t.Name)
+	if !ok {
+		// Deleted (or purged) while in flight.
+		return
+	}
+	cur.DispatchCount++
+	if err == nil {
+		cur.ResponseCount++
+	}
+	if cur.FirstAttempt == nil {
+		cur.FirstAttempt = &TaskAttempt{DispatchTime: attempt.DispatchTime}
+	}
+	cur.LastAttempt = attempt
+
+	if err == nil && status >= 200 && status < 300 {
+		ctTasks.Delete(t.Name)
+		return
+	}
+	rc := q.RetryConfig
+	firstDispatch, _ := time.Parse(time.RFC3339Nano, cur.FirstAttempt.DispatchTime)
+	if retriesExhausted(rc, cur.DispatchCount, responseTime.Sub(firstDispatch)) {
+		ctTasks.Delete(t.Name)
+		return
+	}
+	cur.ScheduleTime = responseTime.Add(taskBackoff(rc, cur.DispatchCount-1)).Format(time.RFC3339Nano)
+	ctTasks.Put(t.Name, cur)
+}
+
+// retriesExhausted reports whether a task that has been attempted
+// `attempts` times over `elapsed` has used its retry budget. When both
+// maxAttempts and maxRetryDuration are set the task keeps retrying until
+// both limits are reached.
+func retriesExhausted(rc *QueueRetryConfig, attempts int, elapsed time.Duration) bool {
+	maxDur, _ := time.ParseDuration(rc.MaxRetryDuration)
+	attemptsDone := rc.MaxAttempts > 0 && attempts >= rc.MaxAttempts
+	durationDone := maxDur > 0 && elapsed >= maxDur
+	switch {
+	case maxDur == 0:
+		return attemptsDone
+	case rc.MaxAttempts == -1:
+		return durationDone
+	default:
+		return attemptsDone && durationDone
+	}
+}
+
+// taskBackoff returns the delay before retry number `retry` (0-based):
+// minBackoff doubles maxDoublings times, then grows linearly by
+// 2^maxDoublings * minBackoff, capped at maxBackoff.
+func taskBackoff(rc *QueueRetryConfig, retry int) time.Duration {
+	minB, _ := time.ParseDuration(rc.MinBackoff)
+	maxB, _ := time.ParseDuration(rc.MaxBackoff)
+	var d float64
+	if retry <= rc.MaxDoublings {
+		d = float64(minB) * math.Pow(2, float64(retry))
+	} else {
+		d = float64(minB) * math.Pow(2, float64(rc.MaxDoublings)) * float64(retry-rc.MaxDoublings+1)
+	}
+	if d > float64(maxB) {
+		return maxB
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Backoff doubles from minBackoff maxDoublings times, then grows
+// linearly by 2^maxDoublings * minBackoff, capped at maxBackoff — the
+// schedule documented on Cloud Tasks RetryConfig.
+func TestTaskBackoff_DoublesThenLinearThenCapped(t *testing.T) {
+	rc := &QueueRetryConfig{MinBackoff: "10s", MaxBackoff: "300s", MaxDoublings: 3}
+	want := []time.Duration{
+		10 * time.Second,
+		20 * time.Second,
+		40 * time.Second,
+		80 * time.Second,
+		160 * time.Second,
+		240 * time.Second,
+		300 * time.Second,
+		300 * time.Second,
+	}
+	for i, w := range want {
+		if got := taskBackoff(rc, i); got != w {
+			t.Errorf("retry %d: backoff = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		rc       QueueRetryConfig
+		attempts int
+		elapsed  time.Duration
+		want     bool
+	}{
+		{"attempts only, under", QueueRetryConfig{MaxAttempts: 3, MaxRetryDuration: "0s"}, 2, time.Hour, false},
+		{"attempts only, reached", QueueRetryConfig{MaxAttempts: 3, MaxRetryDuration: "0s"}, 3, 0, true},
+		{"unlimited attempts, duration reached", QueueRetryConfig{MaxAttempts: -1, MaxRetryDuration: "60s"}, 50, time.Minute, true},
+		{"unlimited attempts, no duration", QueueRetryConfig{MaxAttempts: -1, MaxRetryDuration: "0s"}, 1000, 24 * time.Hour, false},
+		{"both set, only attempts reached", QueueRetryConfig{MaxAttempts: 3, MaxRetryDuration: "60s"}, 5, time.Second, false},
+		{"both set, both reached", QueueRetryConfig{MaxAttempts: 3, MaxRetryDuration: "60s"}, 5, 2 * time.Minute, true},
+	} {
+		if got := retriesExhausted(&tc.rc, tc.attempts, tc.elapsed); got != tc.want {
+			t.Errorf("%s: retriesExhausted = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTokenBucket_BurstThenRate(t *testing.T) {
+	var b tokenBucket
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if !b.take(1, 2, now) {
+			t.Fatalf("take %d within burst rejected", i)
+		}
+	}
+	if b.take(1, 2, now) {
+		t.Fatal("take past burst accepted")
+	}
+	if !b.take(1, 2, now.Add(time.Second)) {
+		t.Fatal("take after one second at 1/s rejected")
+	}
+}
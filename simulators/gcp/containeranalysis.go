@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// Container Analysis (Grafeas) v1 types (REST JSON). Only the DISCOVERY
+// and VULNERABILITY kinds Artifact Analysis records for images.
+
+type GrafeasOccurrence struct {
+	Name          string                    `json:"name"`
+	ResourceURI   string                    `json:"resourceUri"`
+	NoteName      string                    `json:"noteName"`
+	Kind          string                    `json:"kind"`
+	CreateTime    string                    `json:"createTime"`
+	UpdateTime    string                    `json:"updateTime"`
+	Discovery     *GrafeasDiscovery         `json:"discovery,omitempty"`
+	Vulnerability *GrafeasVulnerabilityInfo `json:"vulnerability,omitempty"`
+}
+
+type GrafeasDiscovery struct {
+	AnalysisStatus      string `json:"analysisStatus"`
+	ContinuousAnalysis  string `json:"continuousAnalysis,omitempty"`
+	AnalysisStatusError any    `json:"analysisStatusError,omitempty"`
+}
+
+type GrafeasVulnerabilityInfo struct {
+	Severity          string           `json:"severity,omitempty"`
+	EffectiveSeverity string           `json:"effectiveSeverity,omitempty"`
+	CvssScore         float64          `json:"cvssScore,omitempty"`
+	ShortDescription  string           `json:"shortDescription,omitempty"`
+	LongDescription   string           `json:"longDescription,omitempty"`
+	FixAvailable      bool             `json:"fixAvailable,omitempty"`
+	RelatedUrls       []map[string]any `json:"relatedUrls,omitempty"`
+	PackageIssue      []map[string]any `json:"packageIssue,omitempty"`
+}
+
+// Package-level store: Artifact Registry pushes record discovery.
+var grafeasOccurrences sim.Store[GrafeasOccurrence]
+
+func registerContainerAnalysis(srv *sim.Server) {
+	grafeasOccurrences = sim.MakeStore[GrafeasOccurrence](srv.DB(), "containeranalysis_occurrences")
+
+	srv.HandleFunc("GET /v1/projects/{project}/occurrences", handleListOccurrences)
+	// Create is the Grafeas API third-party scanners write findings
+	// through; tests use it to register VULNERABILITY occurrences, as
+	// the sim has no vulnerability database of its own.
+	srv.HandleFunc("POST /v1/projects/{project}/occurrences", handleCreateOccurrence)
+	srv.HandleFunc("GET /v1/projects/{project}/occurrences/{occurrence}", func(w http.ResponseWriter, r *http.Request) {
+		name := "projects/" + sim.PathParam(r, "project") + "/occurrences/" + sim.PathParam(r, "occurrence")
+		o, ok := grafeasOccurrences.Get(name)
+		if !ok {
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "Occurrence %s not found", name)
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, o)
+	})
+}
+
+// recordImageDiscovery writes the DISCOVERY occurrence automatic
+// scanning (containerscanning.googleapis.com) records when an image is
+// pushed. The sim treats scanning as enabled and finishes at once.
+func recordImageDiscovery(project, resourceURI string) {
+	now := nowTimestamp()
+	name := fmt.Sprintf("projects/%s/occurrences/%s", project, generateUUID())
+	for _, o := range grafeasOccurrences.Filter(func(o GrafeasOccurrence) bool {
+		return o.Kind == "DISCOVERY" && o.ResourceURI == resourceURI
+	}) {
+		name = o.Name
+	}
+	grafeasOccurrences.Put(name, GrafeasOccurrence{
+		Name:        name,
+		ResourceURI: resourceURI,
+		NoteName:    "projects/goog-analysis/notes/PACKAGE_VULNERABILITY",
+		Kind:        "DISCOVERY",
+		CreateTime:  now,
+		UpdateTime:  now,
+		Discovery: &GrafeasDiscovery{
+			AnalysisStatus:     "FINISHED_SUCCESS",
+			ContinuousAnalysis: "ACTIVE",
+		},
+	})
+}
+
+// handleListOccurrences serves projects.occurrences.list, filtering on
+// the `resourceUrl="…" AND kind="…"` clauses Artifact Analysis clients
+// use.
+func handleListOccurrences(w http.ResponseWriter, r *http.Request) {
+	parent := "projects/" + sim.PathParam(r, "project") + "/occurrences/"
+	var resourceURL, kind string
+	for _, c := range parseFilter(r.URL.Query().Get("filter")) {
+		if c.op != opEq {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "unsupported filter operator on %s", c.field)
+			return
+		}
+		switch c.field {
+		case "resourceUrl":
+			resourceURL = c.value
+		case "kind":
+			kind = c.value
+		default:
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "unsupported filter field %s", c.field)
+			return
+		}
+	}
+	matches := grafeasOccurrences.Filter(func(o GrafeasOccurrence) bool {
+		return strings.HasPrefix(o.Name, parent) &&
+			(resourceURL == "" || o.ResourceURI == resourceURL) &&
+			(kind == "" || o.Kind == kind)
+	})
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+
+	pageSize := 1000
+	if s := r.URL.Query().Get("pageSize"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 && n < pageSize {
+			pageSize = n
+		}
+	}
+	start := 0
+	if tok := r.URL.Query().Get("pageToken"); tok != "" {
+		n, err := strconv.Atoi(tok)
+		if err != nil || n < 0 || n > len(matches) {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid page token %q", tok)
+			return
+		}
+		start = n
+	}
+	end := min(start+pageSize, len(matches))
+	resp := map[string]any{"occurrences": matches[start:end]}
+	if end < len(matches) {
+		resp["nextPageToken"] = strconv.Itoa(end)
+	}
+	sim.WriteJSON(w, http.StatusOK, resp)
+}
+
+func handleCreateOccurrence(w http.ResponseWriter, r *http.Request) {
+	project := sim.PathParam(r, "project")
+	var o GrafeasOccurrence
+	if err := sim.ReadJSON(r, &o); err != nil {
+		sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid occurrence: %v", err)
+		return
+	}
+	if o.ResourceURI == "" || o.NoteName == "" {
+		sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "resourceUri and noteName are required")
+		return
+	}
+	switch {
+	case o.Vulnerability != nil:
+		o.Kind = "VULNERABILITY"
+	case o.Discovery != nil:
+		o.Kind = "DISCOVERY"
+	default:
+		sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "occurrence needs a vulnerability or discovery detail")
+		return
+	}
+	now := nowTimestamp()
+	o.Name = fmt.Sprintf("projects/%s/occurrences/%s", project, generateUUID())
+	o.CreateTime, o.UpdateTime = now, now
+	grafeasOccurrences.Put(o.Name, o)
+	sim.WriteJSON(w, http.StatusOK, o)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_NextFiresInScheduleTimeZone(t *testing.T) {
+	s, err := parseCron("30 9 * * MON-FRI", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+	// Saturday 2026-10-17 12:00 New York → next weekday slot is Monday 09:30.
+	got := s.next(time.Date(2026, 10, 17, 12, 0, 0, 0, ny))
+	want := time.Date(2026, 10, 19, 9, 30, 0, 0, ny)
+	if !got.Equal(want) {
+		t.Fatalf("next = %v, want %v", got, want)
+	}
+}
+
+func TestParseCron_StepsListsAndNames(t *testing.T) {
+	s, err := parseCron("*/15 0,12 1 JAN,jul *", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 1, 1, 0, 50, 0, 0, time.UTC)
+	want := []time.Time{
+		time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 12, 15, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 12, 30, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 12, 45, 0, 0, time.UTC),
+		time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for i, w := range want {
+		from = s.next(from)
+		if !from.Equal(w) {
+			t.Fatalf("fire %d = %v, want %v", i, from, w)
+		}
+	}
+}
+
+// When both day fields are restricted a day matches if either does —
+// standard cron semantics that Cloud Scheduler inherits.
+func TestParseCron_DayOfMonthOrDayOfWeek(t *testing.T) {
+	s, err := parseCron("0 0 13 * 5", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-10-13 is a Tuesday (day 13); 2026-10-16 is a Friday.
+	got := s.next(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("next = %v, want %v", got, want)
+	}
+	got = s.next(got)
+	if want := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("next = %v, want %v", got, want)
+	}
+}
+
+func TestParseCron_SundayAsSeven(t *testing.T) {
+	s, err := parseCron("0 0 * * 7", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.next(time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC))
+	if got.Weekday() != time.Sunday {
+		t.Fatalf("next = %v (%v), want a Sunday", got, got.Weekday())
+	}
+}
+
+func TestParseCron_Rejects(t *testing.T) {
+	for _, tc := range []struct{ expr, tz string }{
+		{"* * * *", ""},
+		{"60 * * * *", ""},
+		{"* 24 * * *", ""},
+		{"* * 0 * *", ""},
+		{"* * * 13 *", ""},
+		{"*/0 * * * *", ""},
+		{"5-1 * * * *", ""},
+		{"* * * FOO *", ""},
+		{"* * * * *", "Mars/Olympus_Mons"},
+	} {
+		if _, err := parseCron(tc.expr, tc.tz); err == nil {
+			t.Errorf("parseCron(%q, %q) succeeded, want error", tc.expr, tc.tz)
+		}
+	}
+}
+
+func TestParseCron_NeverFires(t *testing.T) {
+	s, err := parseCron("0 0 30 2 *", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.next(time.Now()); !got.IsZero() {
+		t.Fatalf("next = %v, want zero time for Feb 30", got)
+	}
+}
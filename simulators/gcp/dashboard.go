@@ -16,14 +16,19 @@ func registerDashboard(srv *sim.Server) {
 }
 
 func handleDashboardSummary(w http.ResponseWriter, _ *http.Request) {
+	openUploads := gcsResumableUploads.Filter(func(u GCSResumableUpload) bool { return !u.Completed })
 	sim.WriteJSON(w, http.StatusOK, map[string]any{
 		"provider": "gcp",
 		"services": map[string]int{
-			"cloudrun_jobs": crjJobs.Len(),
-			"functions":     gcfFunctions.Len(),
-			"ar_repos":      arRepos.Len(),
-			"gcs_buckets":   gcsBuckets.Len(),
-			"log_entries":   logEntries.Len(),
+			"cloudrun_jobs":  crjJobs.Len(),
+			"functions":      gcfFunctions.Len(),
+			"ar_repos":       arRepos.Len(),
+			"gcs_buckets":    gcsBuckets.Len(),
+			"gcs_uploads":    len(openUploads),
+			"log_entries":    logEntries.Len(),
+			"pubsub_topics":  psTopics.Len(),
+			"tasks_queues":   ctQueues.Len(),
+			"scheduler_jobs": csJobs.Len(),
 		},
 	})
 }
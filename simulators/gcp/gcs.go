@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"mime"
 	"mime/multipart"
@@ -13,6 +16,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	sim "github.com/sockerless/simulator"
 )
@@ -55,6 +59,7 @@ type GCSObject struct {
 	TimeCreated string `json:"timeCreated"`
 	Updated     string `json:"updated"`
 	Md5Hash     string `json:"md5Hash,omitempty"`
+	Crc32c      string `json:"crc32c,omitempty"`
 	Etag        string `json:"etag,omitempty"`
 	data        []byte // unexported: raw object data
 }
@@ -72,6 +77,7 @@ func registerGCS(srv *sim.Server) {
 	buckets := sim.MakeStore[Bucket](srv.DB(), "gcs_buckets")
 	gcsBuckets = buckets
 	gcsObjects = sim.MakeStore[GCSObject](srv.DB(), "gcs_objects")
+	gcsResumableUploads = sim.MakeStore[GCSResumableUpload](srv.DB(), "gcs_resumable_uploads")
 	objects := gcsObjects
 
 	// Create bucket
@@ -196,6 +202,7 @@ func registerGCS(srv *sim.Server) {
 			TimeCreated string `json:"timeCreated"`
 			Updated     string `json:"updated"`
 			Md5Hash     string `json:"md5Hash,omitempty"`
+			Crc32c      string `json:"crc32c,omitempty"`
 			Etag        string `json:"etag,omitempty"`
 		}
 
@@ -233,6 +240,7 @@ func registerGCS(srv *sim.Server) {
 				TimeCreated: obj.TimeCreated,
 				Updated:     obj.Updated,
 				Md5Hash:     obj.Md5Hash,
+				Crc32c:      obj.Crc32c,
 				Etag:        obj.Etag,
 			})
 		}
@@ -263,33 +271,11 @@ func registerGCS(srv *sim.Server) {
 			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "object %q not found in bucket %q", objectName, bucketName)
 			return
 		}
-
-		scheme := "http"
-		if r.TLS != nil {
-			scheme = "https"
+		if r.URL.Query().Get("alt") == "media" {
+			serveGCSObject(w, r, obj)
+			return
 		}
-		// Real GCS percent-encodes the object component in selfLink /
-		// mediaLink (object names commonly contain `/`, ` `, `?`, `#`).
-		// Use url.PathEscape so consumers parsing the URL back into the
-		// (bucket, object) pair don't pick up a different object.
-		escapedObject := url.PathEscape(objectName)
-		selfLink := fmt.Sprintf("%s://%s/storage/v1/b/%s/o/%s", scheme, r.Host, bucketName, escapedObject)
-		mediaLink := fmt.Sprintf("%s://%s/download/storage/v1/b/%s/o/%s?alt=media", scheme, r.Host, bucketName, escapedObject)
-		sim.WriteJSON(w, http.StatusOK, map[string]any{
-			"kind":        "storage#object",
-			"id":          fmt.Sprintf("%s/%s/1", bucketName, objectName),
-			"selfLink":    selfLink,
-			"mediaLink":   mediaLink,
-			"name":        obj.Name,
-			"bucket":      obj.Bucket,
-			"generation":  "1",
-			"size":        obj.Size,
-			"contentType": obj.ContentType,
-			"timeCreated": obj.TimeCreated,
-			"updated":     obj.Updated,
-			"md5Hash":     obj.Md5Hash,
-			"etag":        obj.Etag,
-		})
+		sim.WriteJSON(w, http.StatusOK, gcsObjectResource(r, obj))
 	})
 
 	// Delete object
@@ -302,12 +288,27 @@ func registerGCS(srv *sim.Server) {
 			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "object %q not found in bucket %q", objectName, bucketName)
 			return
 		}
+		_ = os.Remove(filepath.Join(GCSBucketHostDir(bucketName), objectName))
 
 		w.WriteHeader(http.StatusNoContent)
 	})
 
+	// Resumable upload chunks may arrive as PUT (the documented form) or
+	// POST (google-api-go-client); DELETE cancels the session.
+	srv.HandleFunc("PUT /upload/storage/v1/b/{bucket}/o", handleGCSResumableChunk)
+	srv.HandleFunc("DELETE /upload/storage/v1/b/{bucket}/o", handleGCSResumableCancel)
+
 	// Upload object
 	srv.HandleFunc("POST /upload/storage/v1/b/{bucket}/o", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("upload_id") != "":
+			handleGCSResumableChunk(w, r)
+			return
+		case r.URL.Query().Get("uploadType") == "resumable":
+			handleGCSResumableStart(w, r)
+			return
+		}
+
 		bucketName := sim.PathParam(r, "bucket")
 		objectName := r.URL.Query().Get("name")
 
@@ -323,6 +324,10 @@ func registerGCS(srv *sim.Server) {
 
 		var data []byte
 		var objContentType string
+		var declared struct {
+			Md5Hash string `json:"md5Hash"`
+			Crc32c  string `json:"crc32c"`
+		}
 
 		ct := r.Header.Get("Content-Type")
 		mediaType, params, _ := mime.ParseMediaType(ct)
@@ -332,12 +337,16 @@ func registerGCS(srv *sim.Server) {
 		if mediaType == "multipart/related" {
 			// Multipart upload: first part is metadata JSON, second part is data
 			mr := multipart.NewReader(r.Body, params["boundary"])
-			// Skip metadata part
+			// Only the declared hashes are taken from the metadata part.
 			metaPart, err := mr.NextPart()
 			if err != nil {
 				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "failed to read metadata part: %v", err)
 				return
 			}
+			if err := json.NewDecoder(metaPart).Decode(&declared); err != nil && err != io.EOF {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid object metadata: %v", err)
+				return
+			}
 			_ = metaPart.Close()
 			// Read data part
 			dataPart, err := mr.NextPart()
@@ -369,7 +378,15 @@ func registerGCS(srv *sim.Server) {
 		now := nowTimestamp()
 		hash := md5.Sum(data)
 		md5Hash := base64.StdEncoding.EncodeToString(hash[:])
+		crc32c := gcsCRC32C(crc32.Checksum(data, crc32cTable))
 		etag := fmt.Sprintf("%x", hash)
+		if m, c := parseGoogHash(r.Header.Get("X-Goog-Hash")); m != "" || c != "" {
+			declared.Md5Hash, declared.Crc32c = m, c
+		}
+		if msg := gcsHashMismatch(declared.Md5Hash, md5Hash, declared.Crc32c, crc32c); msg != "" {
+			sim.GCPError(w, http.StatusBadRequest, msg, "INVALID_ARGUMENT")
+			return
+		}
 
 		// Persist the object bytes on disk (real GCS-shape storage —
 		// objects survive sim process restart). Metadata goes through
@@ -396,43 +413,14 @@ func registerGCS(srv *sim.Server) {
 			TimeCreated: now,
 			Updated:     now,
 			Md5Hash:     md5Hash,
+			Crc32c:      crc32c,
 			Etag:        etag,
 			data:        data,
 		}
 
 		key := bucketName + "/" + objectName
 		objects.Put(key, obj)
-
-		// Real GCS object responses include `kind` + `id` + `selfLink` +
-		// `mediaLink`. terraform-provider-google's `google_storage_bucket_object`
-		// reads `selfLink` into the resource's `self_link` attribute on
-		// apply, so missing it means the attribute is empty downstream.
-		scheme := "http"
-		if r.TLS != nil {
-			scheme = "https"
-		}
-		// Real GCS percent-encodes the object component in selfLink /
-		// mediaLink (object names commonly contain `/`, ` `, `?`, `#`).
-		// Use url.PathEscape so consumers parsing the URL back into the
-		// (bucket, object) pair don't pick up a different object.
-		escapedObject := url.PathEscape(objectName)
-		selfLink := fmt.Sprintf("%s://%s/storage/v1/b/%s/o/%s", scheme, r.Host, bucketName, escapedObject)
-		mediaLink := fmt.Sprintf("%s://%s/download/storage/v1/b/%s/o/%s?alt=media", scheme, r.Host, bucketName, escapedObject)
-		sim.WriteJSON(w, http.StatusOK, map[string]any{
-			"kind":        "storage#object",
-			"id":          fmt.Sprintf("%s/%s/1", bucketName, objectName),
-			"selfLink":    selfLink,
-			"mediaLink":   mediaLink,
-			"name":        obj.Name,
-			"bucket":      obj.Bucket,
-			"generation":  "1",
-			"size":        obj.Size,
-			"contentType": obj.ContentType,
-			"timeCreated": obj.TimeCreated,
-			"updated":     obj.Updated,
-			"md5Hash":     obj.Md5Hash,
-			"etag":        obj.Etag,
-		})
+		sim.WriteJSON(w, http.StatusOK, gcsObjectResource(r, obj))
 	})
 
 	// XML API style object access (used by cloud.google.com/go/storage for reads)
@@ -457,11 +445,7 @@ func registerGCS(srv *sim.Server) {
 			return
 		}
 
-		body := gcsObjectBytes(obj, bucketName, objectName)
-		w.Header().Set("Content-Type", obj.ContentType)
-		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
-		w.WriteHeader(http.StatusOK)
-		w.Write(body)
+		serveGCSObject(w, r, obj)
 	})
 
 	// Download object data (JSON API)
@@ -476,14 +460,76 @@ func registerGCS(srv *sim.Server) {
 			return
 		}
 
-		body := gcsObjectBytes(obj, bucketName, objectName)
-		w.Header().Set("Content-Type", obj.ContentType)
-		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
-		w.WriteHeader(http.StatusOK)
-		w.Write(body)
+		serveGCSObject(w, r, obj)
 	})
 }
 
+// gcsObjectResource is the JSON object resource returned by insert and
+// get. Real GCS object responses include `kind` + `id` + `selfLink` +
+// `mediaLink`; terraform-provider-google's `google_storage_bucket_object`
+// reads `selfLink` into the resource's `self_link` attribute on apply,
+// so missing it means the attribute is empty downstream.
+func gcsObjectResource(r *http.Request, obj GCSObject) map[string]any {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// Real GCS percent-encodes the object component in selfLink /
+	// mediaLink (object names commonly contain `/`, ` `, `?`, `#`).
+	// Use url.PathEscape so consumers parsing the URL back into the
+	// (bucket, object) pair don't pick up a different object.
+	escapedObject := url.PathEscape(obj.Name)
+	selfLink := fmt.Sprintf("%s://%s/storage/v1/b/%s/o/%s", scheme, r.Host, obj.Bucket, escapedObject)
+	mediaLink := fmt.Sprintf("%s://%s/download/storage/v1/b/%s/o/%s?alt=media", scheme, r.Host, obj.Bucket, escapedObject)
+	res := map[string]any{
+		"kind":        "storage#object",
+		"id":          fmt.Sprintf("%s/%s/1", obj.Bucket, obj.Name),
+		"selfLink":    selfLink,
+		"mediaLink":   mediaLink,
+		"name":        obj.Name,
+		"bucket":      obj.Bucket,
+		"generation":  "1",
+		"size":        obj.Size,
+		"contentType": obj.ContentType,
+		"timeCreated": obj.TimeCreated,
+		"updated":     obj.Updated,
+		"md5Hash":     obj.Md5Hash,
+		"etag":        obj.Etag,
+	}
+	if obj.Crc32c != "" {
+		res["crc32c"] = obj.Crc32c
+	}
+	return res
+}
+
+// serveGCSObject streams an object's payload from disk, honouring
+// Range requests. X-Goog-Hash lets clients verify full reads.
+func serveGCSObject(w http.ResponseWriter, r *http.Request, obj GCSObject) {
+	var hashes []string
+	if obj.Crc32c != "" {
+		hashes = append(hashes, "crc32c="+obj.Crc32c)
+	}
+	if obj.Md5Hash != "" {
+		hashes = append(hashes, "md5="+obj.Md5Hash)
+	}
+	if len(hashes) > 0 {
+		w.Header().Set("X-Goog-Hash", strings.Join(hashes, ","))
+	}
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("X-Goog-Stored-Content-Length", obj.Size)
+	if len(obj.data) > 0 {
+		http.ServeContent(w, r, obj.Name, time.Time{}, bytes.NewReader(obj.data))
+		return
+	}
+	f, err := os.Open(filepath.Join(GCSBucketHostDir(obj.Bucket), obj.Name))
+	if err != nil {
+		sim.GCPErrorf(w, http.StatusInternalServerError, "INTERNAL", "read object %q: %v", obj.Name, err)
+		return
+	}
+	defer f.Close()
+	http.ServeContent(w, r, obj.Name, time.Time{}, f)
+}
+
 // gcsObjectBytes returns the object's payload bytes. Prefers the
 // in-memory copy when present (uploaded in the same process lifetime);
 // falls back to the on-disk file at <gcsHostRoot>/<bucket>/<object>
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// GCS resumable uploads (uploadType=resumable). A POST opens a session
+// and returns its URL in Location; the client then sends the payload in
+// chunks, each carrying a Content-Range. Intermediate chunks are
+// acknowledged with 308 Resume Incomplete and a Range header naming the
+// persisted bytes; the chunk that completes the object returns the
+// object resource. Session payloads are spooled under
+// <gcsHostRoot>/.resumable so multi-GB uploads never sit in memory.
+
+// gcsResumableChunkGranularity is the multiple every non-final chunk
+// must be sized to.
+const gcsResumableChunkGranularity = 256 << 10
+
+// GCSResumableUpload is an open resumable upload session.
+type GCSResumableUpload struct {
+	ID          string
+	Bucket      string
+	Name        string
+	ContentType string
+	// Md5Hash / Crc32c are the client-declared hashes from the session
+	// metadata, checked against the payload on finalize.
+	Md5Hash   string
+	Crc32c    string
+	TotalSize int64 // -1 until the client states it
+	Persisted int64
+	Created   string
+	Completed bool
+}
+
+var (
+	gcsResumableUploads sim.Store[GCSResumableUpload]
+	// gcsResumableLocks serialises chunks within a session; distinct
+	// sessions upload in parallel.
+	gcsResumableLocks sync.Map
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func gcsResumablePath(id string) string {
+	return filepath.Join(gcsHostRoot(), ".resumable", id)
+}
+
+func gcsResumableLock(id string) *sync.Mutex {
+	mu, _ := gcsResumableLocks.LoadOrStore(id, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// gcsContentRange is a parsed upload Content-Range. First/Last are -1
+// for a status query ("bytes */…"); Total is -1 when the client
+// doesn't know the object size yet ("…/*").
+type gcsContentRange struct {
+	First, Last, Total int64
+}
+
+// parseGCSContentRange parses the Content-Range forms the resumable
+// protocol uses: "bytes a-b/total", "bytes a-b/*", "bytes */total" and
+// "bytes */*".
+func parseGCSContentRange(v string) (gcsContentRange, error) {
+	cr := gcsContentRange{First: -1, Last: -1, Total: -1}
+	spec, ok := strings.CutPrefix(strings.TrimSpace(v), "bytes ")
+	if !ok {
+		return cr, fmt.Errorf("Content-Range %q must start with \"bytes \"", v)
+	}
+	rng, total, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return cr, fmt.Errorf("Content-Range %q is missing the total size", v)
+	}
+	if total != "*" {
+		n, err := strconv.ParseInt(total, 10, 64)
+		if err != nil || n < 0 {
+			return cr, fmt.Errorf("Content-Range %q has an invalid total size", v)
+		}
+		cr.Total = n
+	}
+	if rng == "*" {
+		return cr, nil
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return cr, fmt.Errorf("Content-Range %q has an invalid byte range", v)
+	}
+	var err1, err2 error
+	cr.First, err1 = strconv.ParseInt(first, 10, 64)
+	cr.Last, err2 = strconv.ParseInt(last, 10, 64)
+	if err1 != nil || err2 != nil || cr.First < 0 || cr.Last < cr.First {
+		return gcsContentRange{}, fmt.Errorf("Content-Range %q has an invalid byte range", v)
+	}
+	if cr.Total >= 0 && cr.Last >= cr.Total {
+		return gcsContentRange{}, fmt.Errorf("Content-Range %q ends past the total size", v)
+	}
+	return cr, nil
+}
+
+// gcsUploadURL is the session URL handed back in Location.
+func gcsUploadURL(r *http.Request, bucket, id string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/upload/storage/v1/b/%s/o?uploadType=resumable&upload_id=%s", scheme, r.Host, bucket, id)
+}
+
+// handleGCSResumableStart opens a session. The object resource comes
+// from the JSON body; the name may instead be on ?name=.
+func handleGCSResumableStart(w http.ResponseWriter, r *http.Request) {
+	bucketName := sim.PathParam(r, "bucket")
+	if _, ok := gcsBuckets.Get(bucketName); !ok {
+		sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "bucket %q not found", bucketName)
+		return
+	}
+
+	var meta struct {
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+		Md5Hash     string `json:"md5Hash"`
+		Crc32c      string `json:"crc32c"`
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "read metadata: %v", err)
+		return
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &meta); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid object metadata: %v", err)
+			return
+		}
+	}
+	if meta.Name == "" {
+		meta.Name = r.URL.Query().Get("name")
+	}
+	if meta.Name == "" {
+		sim.GCPError(w, http.StatusBadRequest, "object name is required", "INVALID_ARGUMENT")
+		return
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = r.Header.Get("X-Upload-Content-Type")
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = "application/octet-stream"
+	}
+	total := int64(-1)
+	if v := r.Header.Get("X-Upload-Content-Length"); v != "" {
+		total, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || total < 0 {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid X-Upload-Content-Length %q", v)
+			return
+		}
+	}
+
+	session := GCSResumableUpload{
+		ID:          strings.ReplaceAll(generateUUID(), "-", ""),
+		Bucket:      bucketName,
+		Name:        meta.Name,
+		ContentType: meta.ContentType,
+		Md5Hash:     meta.Md5Hash,
+		Crc32c:      meta.Crc32c,
+		TotalSize:   total,
+		Created:     nowTimestamp(),
+	}
+	path := gcsResumablePath(session.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		sim.GCPErrorf(w, http.StatusInternalServerError, "INTERNAL", "create upload dir: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		sim.GCPErrorf(w, http.StatusInternalServerError, "INTERNAL", "create upload file: %v", err)
+		return
+	}
+	gcsResumableUploads.Put(session.ID, session)
+
+	w.Header().Set("Location", gcsUploadURL(r, bucketName, session.ID))
+	w.Header().Set("X-GUploader-UploadID", session.ID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleGCSResumableChunk accepts a chunk (or a status query) for an
+// open session.
+func handleGCSResumableChunk(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("upload_id")
+	mu := gcsResumableLock(id)
+	mu.Lock()
+	defer mu.Unlock()
+
+	session, ok := gcsResumableUploads.Get(id)
+	if !ok || session.Bucket != sim.PathParam(r, "bucket") {
+		gcsResumableLocks.Delete(id)
+		sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "upload session %q not found", id)
+		return
+	}
+	if session.Completed {
+		// A retried final chunk whose response was lost.
+		obj, ok := gcsObjects.Get(session.Bucket + "/" + session.Name)
+		if !ok {
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "object %q not found in bucket %q", session.Name, session.Bucket)
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, gcsObjectResource(r, obj))
+		return
+	}
+	defer r.Body.Close()
+
+	// No Content-Range: the body is the whole object in one request.
+	cr := gcsContentRange{First: -1, Last: -1, Total: -1}
+	whole := r.Header.Get("Content-Range") == ""
+	if !whole {
+		var err error
+		if cr, err = parseGCSContentRange(r.Header.Get("Content-Range")); err != nil {
+			sim.GCPError(w, http.StatusBadRequest, err.Error(), "INVALID_ARGUMENT")
+			return
+		}
+	} else if session.Persisted > 0 {
+		sim.GCPError(w, http.StatusBadRequest, "Content-Range is required once bytes have been persisted", "INVALID_ARGUMENT")
+		return
+	}
+	if cr.Total >= 0 && session.TotalSize >= 0 && cr.Total != session.TotalSize {
+		sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT",
+			"Content-Range total %d does not match X-Upload-Content-Length %d", cr.Total, session.TotalSize)
+		return
+	}
+	if cr.Total < 0 {
+		cr.Total = session.TotalSize
+	}
+
+	if whole || cr.First >= 0 {
+		if cr.First > session.Persisted {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT",
+				"chunk starts at byte %d but only %d bytes have been persisted", cr.First, session.Persisted)
+			return
+		}
+		final := whole || (cr.Total >= 0 && cr.Last+1 == cr.Total)
+		if !final && (cr.Last-cr.First+1)%gcsResumableChunkGranularity != 0 {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT",
+				"Invalid request. The number of bytes uploaded is required to be equal or greater than %d, except for the final request (it's recommended to be the exact multiple of %d).",
+				gcsResumableChunkGranularity, gcsResumableChunkGranularity)
+			return
+		}
+		n, err := appendGCSResumableChunk(session, cr, r.Body, whole)
+		if err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "%v", err)
+			return
+		}
+		if whole && session.TotalSize >= 0 && n != session.TotalSize {
+			discardGCSResumableUpload(session.ID)
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT",
+				"received %d bytes but X-Upload-Content-Length declared %d", n, session.TotalSize)
+			return
+		}
+		session.Persisted = n
+		if whole {
+			cr.Total = n
+		}
+	} else if cr.Total >= 0 && cr.Total < session.Persisted {
+		sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT",
+			"total size %d is less than the %d bytes already persisted", cr.Total, session.Persisted)
+		return
+	}
+	if cr.Total >= 0 {
+		session.TotalSize = cr.Total
+	}
+
+	if session.TotalSize >= 0 && session.Persisted == session.TotalSize {
+		finalizeGCSResumableUpload(w, r, session)
+		return
+	}
+	gcsResumableUploads.Put(session.ID, session)
+	writeGCSResumeIncomplete(w, r, session.Persisted)
+}
+
+// appendGCSResumableChunk writes the not-yet-persisted tail of the
+// chunk to the session file and returns the new persisted size. Bytes
+// the server already holds (a client retrying after a lost 308) are
+// read and dropped.
+func appendGCSResumableChunk(session GCSResumableUpload, cr gcsContentRange, body io.Reader, whole bool) (int64, error) {
+	f, err := os.OpenFile(gcsResumablePath(session.ID), os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("open upload file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(session.Persisted, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek upload file: %w", err)
+	}
+	if whole {
+		n, err := io.Copy(f, body)
+		if err != nil {
+			return 0, fmt.Errorf("read upload body: %w", err)
+		}
+		return n, f.Truncate(n)
+	}
+
+	length := cr.Last - cr.First + 1
+	skip := session.Persisted - cr.First
+	if skip > length {
+		skip = length
+	}
+	if _, err := io.CopyN(io.Discard, body, skip); err != nil {
+		return 0, fmt.Errorf("chunk body shorter than Content-Range: %w", err)
+	}
+	n, err := io.CopyN(f, body, length-skip)
+	if err != nil {
+		// Keep the persisted offset where it was so the client can
+		// resend the chunk.
+		_ = f.Truncate(session.Persisted)
+		return 0, fmt.Errorf("chunk body shorter than Content-Range: %w", err)
+	}
+	return session.Persisted + n, nil
+}
+
+// writeGCSResumeIncomplete acknowledges a partial upload. Clients that
+// send X-GUploader-No-308 get 200 plus X-Http-Status-Code-Override,
+// which keeps 308 away from HTTP stacks that treat it as a redirect.
+func writeGCSResumeIncomplete(w http.ResponseWriter, r *http.Request, persisted int64) {
+	if persisted > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", persisted-1))
+	}
+	w.Header().Set("Content-Length", "0")
+	if strings.EqualFold(r.Header.Get("X-GUploader-No-308"), "yes") {
+		w.Header().Set("X-Http-Status-Code-Override", "308")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+// finalizeGCSResumableUpload checks the payload against any declared
+// hashes and publishes it as the object.
+func finalizeGCSResumableUpload(w http.ResponseWriter, r *http.Request, session GCSResumableUpload) {
+	path := gcsResumablePath(session.ID)
+	md5Hash, crc32c, err := gcsFileHashes(path)
+	if err != nil {
+		sim.GCPErrorf(w, http.StatusInternalServerError, "INTERNAL", "hash upload: %v", err)
+		return
+	}
+	wantMD5, wantCRC := session.Md5Hash, session.Crc32c
+	if m, c := parseGoogHash(r.Header.Get("X-Goog-Hash")); m != "" || c != "" {
+		if m != "" {
+			wantMD5 = m
+		}
+		if c != "" {
+			wantCRC = c
+		}
+	}
+	if msg := gcsHashMismatch(wantMD5, md5Hash, wantCRC, crc32c); msg != "" {
+		discardGCSResumableUpload(session.ID)
+		sim.GCPError(w, http.StatusBadRequest, msg, "INVALID_ARGUMENT")
+		return
+	}
+
+	objPath := filepath.Join(GCSBucketHostDir(session.Bucket), session.Name)
+	if err := os.MkdirAll(filepath.Dir(objPath), 0o755); err != nil {
+		sim.GCPErrorf(w, http.StatusInternalServerError, "INTERNAL", "create object dir: %v", err)
+		return
+	}
+	if err := os.Rename(path, objPath); err != nil {
+		sim.GCPErrorf(w, http.StatusInternalServerError, "INTERNAL", "write object: %v", err)
+		return
+	}
+
+	now := nowTimestamp()
+	raw, _ := base64.StdEncoding.DecodeString(md5Hash)
+	obj := GCSObject{
+		Name:        session.Name,
+		Bucket:      session.Bucket,
+		Size:        strconv.FormatInt(session.Persisted, 10),
+		ContentType: session.ContentType,
+		TimeCreated: now,
+		Updated:     now,
+		Md5Hash:     md5Hash,
+		Crc32c:      crc32c,
+		Etag:        fmt.Sprintf("%x", raw),
+	}
+	gcsObjects.Put(session.Bucket+"/"+session.Name, obj)
+
+	session.Completed = true
+	gcsResumableUploads.Put(session.ID, session)
+	gcsResumableLocks.Delete(session.ID)
+	sim.WriteJSON(w, http.StatusOK, gcsObjectResource(r, obj))
+}
+
+// handleGCSResumableCancel deletes a session; GCS answers 499.
+func handleGCSResumableCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("upload_id")
+	mu := gcsResumableLock(id)
+	mu.Lock()
+	defer mu.Unlock()
+
+	session, ok := gcsResumableUploads.Get(id)
+	if !ok || session.Bucket != sim.PathParam(r, "bucket") || session.Completed {
+		sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "upload session %q not found", id)
+		return
+	}
+	discardGCSResumableUpload(id)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(499)
+}
+
+func discardGCSResumableUpload(id string) {
+	gcsResumableUploads.Delete(id)
+	_ = os.Remove(gcsResumablePath(id))
+	gcsResumableLocks.Delete(id)
+}
+
+// gcsCRC32C is the base64 big-endian CRC32C GCS reports in crc32c and
+// X-Goog-Hash.
+func gcsCRC32C(sum uint32) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], sum)
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// gcsFileHashes streams a file through MD5 and CRC32C.
+func gcsFileHashes(path string) (md5Hash, crc32c string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	m := md5.New()
+	c := crc32.New(crc32cTable)
+	if _, err := io.Copy(io.MultiWriter(m, c), f); err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(m.Sum(nil)), gcsCRC32C(c.Sum32()), nil
+}
+
+// parseGoogHash splits an X-Goog-Hash value ("crc32c=…,md5=…").
+func parseGoogHash(v string) (md5Hash, crc32c string) {
+	for _, part := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "md5":
+			md5Hash = val
+		case "crc32c":
+			crc32c = val
+		}
+	}
+	return md5Hash, crc32c
+}
+
+// gcsHashMismatch returns the GCS error message when a client-declared
+// hash disagrees with the received payload.
+func gcsHashMismatch(wantMD5, gotMD5, wantCRC, gotCRC string) string {
+	if wantMD5 != "" && wantMD5 != gotMD5 {
+		return fmt.Sprintf("Provided MD5 hash %q doesn't match calculated MD5 hash %q.", wantMD5, gotMD5)
+	}
+	if wantCRC != "" && wantCRC != gotCRC {
+		return fmt.Sprintf("Provided CRC32C %q doesn't match calculated CRC32C %q.", wantCRC, gotCRC)
+	}
+	return ""
+}
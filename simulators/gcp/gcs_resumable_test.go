@@ -0,0 +1,62 @@
+package main
+
+import (
+	"hash/crc32"
+	"testing"
+)
+
+func TestParseGCSContentRange(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want gcsContentRange
+	}{
+		{"bytes 0-262143/*", gcsContentRange{First: 0, Last: 262143, Total: -1}},
+		{"bytes 262144-262149/262150", gcsContentRange{First: 262144, Last: 262149, Total: 262150}},
+		{"bytes */262150", gcsContentRange{First: -1, Last: -1, Total: 262150}},
+		{"bytes */*", gcsContentRange{First: -1, Last: -1, Total: -1}},
+		{"bytes */0", gcsContentRange{First: -1, Last: -1, Total: 0}},
+	} {
+		got, err := parseGCSContentRange(tc.in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseGCSContentRange_Invalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"0-10/11",
+		"bytes 0-10",
+		"bytes 10-5/*",
+		"bytes 0-10/10",
+		"bytes a-b/*",
+		"bytes 0-1/-3",
+	} {
+		if _, err := parseGCSContentRange(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+// The reference value is the CRC32C check value of "123456789"
+// (0xe3069283), base64-encoded big-endian as GCS reports it.
+func TestGCSCRC32C(t *testing.T) {
+	if got := gcsCRC32C(crc32.Checksum([]byte("123456789"), crc32cTable)); got != "4waSgw==" {
+		t.Fatalf("gcsCRC32C = %q, want 4waSgw==", got)
+	}
+}
+
+func TestParseGoogHash(t *testing.T) {
+	md5Hash, crc := parseGoogHash("crc32c=4waSgw==,md5=JfnnlDI7RTiF9RgfG2JNCw==")
+	if md5Hash != "JfnnlDI7RTiF9RgfG2JNCw==" || crc != "4waSgw==" {
+		t.Fatalf("got md5=%q crc32c=%q", md5Hash, crc)
+	}
+	if md5Hash, crc := parseGoogHash("crc32c=AAAAAA=="); md5Hash != "" || crc != "AAAAAA==" {
+		t.Fatalf("crc-only: got md5=%q crc32c=%q", md5Hash, crc)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTP-target dispatch shared by Pub/Sub push subscriptions, Cloud Tasks
+// HTTP tasks and Cloud Scheduler HTTP jobs. All three let the caller name
+// an arbitrary URL plus an optional OIDC or OAuth service-account token;
+// real GCP mints the token and POSTs from Google's edge. The sim does the
+// same from its own process.
+//
+// Targets that name a GCP surface the sim fronts are rewritten onto the
+// sim's own listener so a scheduled `jobs.run` or an Eventarc-style push
+// into a Cloud Run service executes locally instead of dialing Google:
+//
+//   - `https://*.googleapis.com/...` (including regional
+//     `{region}-run.googleapis.com`) routes to the sim at the same path.
+//     The Cloud Run Admin v1 `namespaces/{project}/jobs/{job}:run` shape
+//     is translated to the v2 `jobs/{job}:run` the sim serves.
+//   - `https://{service}-{hash}.{region}.run.app` (and the `.a.run.app`
+//     form) routes to the matching Cloud Run v2 service's sim URI.
+//
+// Everything else (e.g. a test's httptest server) is dialed as-is.
+
+// simListenTLS records whether the sim serves HTTPS, so self-targeted
+// dispatch uses the right scheme.
+var simListenTLS bool
+
+// targetAuth is the union of the OIDC / OAuth token blocks the three
+// services accept on an HTTP target. At most one of the two is set.
+type targetAuth struct {
+	OIDC  *OidcToken
+	OAuth *OAuthToken
+}
+
+// OidcToken mirrors the `oidcToken` message shared by Pub/Sub push
+// configs, Cloud Tasks HttpRequest and Cloud Scheduler HttpTarget.
+type OidcToken struct {
+	ServiceAccountEmail string `json:"serviceAccountEmail,omitempty"`
+	Audience            string `json:"audience,omitempty"`
+}
+
+// OAuthToken mirrors the `oauthToken` message on Cloud Tasks HttpRequest
+// and Cloud Scheduler HttpTarget.
+type OAuthToken struct {
+	ServiceAccountEmail string `json:"serviceAccountEmail,omitempty"`
+	Scope               string `json:"scope,omitempty"`
+}
+
+// targetClient dispatches HTTP targets. Self-targeted requests may hit a
+// sim started with a self-signed certificate, so verification is skipped
+// for those only.
+var (
+	targetClient     = &http.Client{}
+	targetSelfClient = &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // loopback to the sim's own listener
+	}}
+)
+
+// simSelfBaseURL returns the loopback base URL of the sim's own listener.
+func simSelfBaseURL() string {
+	port := simListenAddr
+	if idx := strings.LastIndex(simListenAddr, ":"); idx >= 0 {
+		port = simListenAddr[idx+1:]
+	}
+	scheme := "http"
+	if simListenTLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://127.0.0.1:%s", scheme, port)
+}
+
+// resolveHTTPTarget maps a target URL onto the address the sim actually
+// dials. The boolean reports whether the result points at the sim itself.
+func resolveHTTPTarget(raw string) (string, bool, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid target URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false, fmt.Errorf("target URL %q must use http or https", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+
+	switch {
+	case strings.HasSuffix(host, ".googleapis.com"):
+		path := u.EscapedPath()
+		if region, ok := strings.CutSuffix(host, "-run.googleapis.com"); ok {
+			path = translateRunV1JobPath(path, region)
+		}
+		out := simSelfBaseURL() + path
+		if u.RawQuery != "" {
+			out += "?" + u.RawQuery
+		}
+		return out, true, nil
+
+	case strings.HasSuffix(host, ".run.app"):
+		svc, ok := cloudRunServiceForHost(host)
+		if !ok {
+			return "", false, fmt.Errorf("no Cloud Run service matches target host %q", host)
+		}
+		return svc.URI, true, nil
+	}
+	return raw, false, nil
+}
+
+// translateRunV1JobPath rewrites the Cloud Run Admin v1 job-run path
+// (`/apis/run.googleapis.com/v1/namespaces/{project}/jobs/{job}:run`)
+// that Cloud Scheduler's console wires by default onto the v2 path the
+// sim serves. Other paths pass through unchanged.
+func translateRunV1JobPath(path, region string) string {
+	const prefix = "/apis/run.googleapis.com/v1/namespaces/"
+	rest, ok := strings.CutPrefix(path, prefix)
+	if !ok {
+		return path
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 || parts[1] != "jobs" {
+		return path
+	}
+	return fmt.Sprintf("/v2/projects/%s/locations/%s/jobs/%s", parts[0], region, parts[2])
+}
+
+// cloudRunServiceForHost picks the Cloud Run v2 service whose ID is the
+// longest prefix of the first DNS label of a `*.run.app` host. Real hosts
+// look like `{service}-{projectHash}.{region}.run.app` or
+// `{service}-{hash}-{regionCode}.a.run.app`.
+func cloudRunServiceForHost(host string) (ServiceV2, bool) {
+	if crv2Services == nil {
+		return ServiceV2{}, false
+	}
+	label, _, _ := strings.Cut(host, ".")
+	var best ServiceV2
+	bestLen := 0
+	for _, svc := range crv2Services.List() {
+		id := svc.Name[strings.LastIndex(svc.Name, "/")+1:]
+		if label != id && !strings.HasPrefix(label, id+"-") {
+			continue
+		}
+		if len(id) > bestLen && svc.URI != "" {
+			best, bestLen = svc, len(id)
+		}
+	}
+	return best, bestLen > 0
+}
+
+// dispatchHTTPTarget sends one HTTP-target request and returns the
+// response status. A non-nil error means no HTTP response was received
+// (DNS, connect, deadline); callers treat both as a failed attempt.
+func dispatchHTTPTarget(ctx context.Context, method, target string, headers map[string]string, body []byte, auth targetAuth, deadline time.Duration) (int, error) {
+	resolved, self, err := resolveHTTPTarget(target)
+	if err != nil {
+		return 0, err
+	}
+	if method == "" {
+		method = http.MethodPost
+	}
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, resolved, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	now := time.Now()
+	switch {
+	case auth.OIDC != nil:
+		aud := auth.OIDC.Audience
+		if aud == "" {
+			aud = target
+		}
+		req.Header.Set("Authorization", "Bearer "+mintSimIdToken(idTokenSignKey(),
+			auth.OIDC.ServiceAccountEmail, aud, true, now, now.Add(time.Hour)))
+	case auth.OAuth != nil:
+		req.Header.Set("Authorization", "Bearer "+mintSimJWT(idTokenSignKey(),
+			auth.OAuth.ServiceAccountEmail, auth.OAuth.ServiceAccountEmail, now, now.Add(time.Hour)))
+	}
+
+	client := targetClient
+	if self {
+		client = targetSelfClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// httpStatusToRPCCode maps an HTTP target's response status onto the
+// google.rpc.Code that Cloud Scheduler and Cloud Tasks report for the
+// attempt.
+func httpStatusToRPCCode(status int) (int, string) {
+	switch {
+	case status >= 200 && status < 300:
+		return 0, "OK"
+	case status == http.StatusBadRequest:
+		return 3, "INVALID_ARGUMENT"
+	case status == http.StatusUnauthorized:
+		return 16, "UNAUTHENTICATED"
+	case status == http.StatusForbidden:
+		return 7, "PERMISSION_DENIED"
+	case status == http.StatusNotFound:
+		return 5, "NOT_FOUND"
+	case status == http.StatusConflict:
+		return 10, "ABORTED"
+	case status == http.StatusTooManyRequests:
+		return 8, "RESOURCE_EXHAUSTED"
+	case status == http.StatusNotImplemented:
+		return 12, "UNIMPLEMENTED"
+	case status == http.StatusServiceUnavailable:
+		return 14, "UNAVAILABLE"
+	case status == http.StatusGatewayTimeout:
+		return 4, "DEADLINE_EXCEEDED"
+	case status >= 500:
+		return 13, "INTERNAL"
+	default:
+		return 2, "UNKNOWN"
+	}
+}
@@ -192,7 +192,7 @@ func registerIAM(srv *sim.Server) {
 		})
 	})
 
-	// Project IAM - getIamPolicy / setIamPolicy
+	// Project IAM - getIamPolicy / setIamPolicy / testIamPermissions
 	srv.HandleFunc("POST /v1/projects/{projectAction}", func(w http.ResponseWriter, r *http.Request) {
 		projectAction := sim.PathParam(r, "projectAction")
 		project, action, _ := strings.Cut(projectAction, ":")
@@ -223,6 +223,28 @@ func registerIAM(srv *sim.Server) {
 			}
 			projectPolicies.Put(project, req.Policy)
 			sim.WriteJSON(w, http.StatusOK, req.Policy)
+		case "testIamPermissions":
+			// The simulator doesn't enforce IAM, so the caller holds
+			// every well-formed permission it asks about.
+			var req struct {
+				Permissions []string `json:"permissions"`
+			}
+			if err := sim.ReadJSON(r, &req); err != nil {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+				return
+			}
+			if len(req.Permissions) > 100 {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "at most 100 permissions may be tested per request, got %d", len(req.Permissions))
+				return
+			}
+			for _, perm := range req.Permissions {
+				parts := strings.Split(perm, ".")
+				if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" || strings.Contains(perm, "*") {
+					sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Permission %s is not valid.", perm)
+					return
+				}
+			}
+			sim.WriteJSON(w, http.StatusOK, map[string]any{"permissions": req.Permissions})
 		default:
 			http.NotFound(w, r)
 		}
@@ -2,7 +2,8 @@
 //
 // It simulates the subset of GCP APIs used by the Sockerless Cloud Run and
 // Cloud Functions backends: Cloud Run Jobs, Cloud Logging, Cloud DNS, GCS,
-// Artifact Registry, and Cloud Functions v2.
+// Artifact Registry, and Cloud Functions v2, plus the event plumbing
+// around them: Pub/Sub, Cloud Tasks and Cloud Scheduler.
 //
 // Configure with environment variables:
 //
@@ -44,6 +45,7 @@ func main() {
 	// Stash the listen addr so cloud-product host translators can wire
 	// GCE_METADATA_HOST + sidecar URLs onto workload containers.
 	simListenAddr = cfg.ListenAddr
+	simListenTLS = cfg.TLSCert != "" && cfg.TLSKey != ""
 
 	obs, err := sim.InitObservability("sockerless-sim-gcp")
 	if err != nil {
@@ -69,12 +71,17 @@ func main() {
 	registerCloudRunServicesV2(srv)
 	registerCloudLogging(srv)
 	registerCloudDNS(srv)
+	registerCloudMonitoring(srv)
+	registerContainerAnalysis(srv)
 	registerGCS(srv)
 	registerArtifactRegistry(srv)
 	registerCloudFunctions(srv)
 	registerOperations(srv)
 	registerSecretManager(srv)
 	registerCloudBuild(srv)
+	registerPubSub(srv)
+	registerCloudTasks(srv)
+	registerCloudScheduler(srv)
 
 	// Infrastructure services
 	registerServiceUsage(srv)
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// Cloud Monitoring v3 types (REST JSON).
+
+type MonitoringTimeSeries struct {
+	Metric     MonitoringMetric   `json:"metric"`
+	Resource   MonitoringResource `json:"resource"`
+	MetricKind string             `json:"metricKind"`
+	ValueType  string             `json:"valueType"`
+	Points     []MonitoringPoint  `json:"points"`
+}
+
+type MonitoringMetric struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type MonitoringResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type MonitoringPoint struct {
+	Interval MonitoringInterval `json:"interval"`
+	Value    MonitoringValue    `json:"value"`
+}
+
+type MonitoringInterval struct {
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime"`
+}
+
+type MonitoringValue struct {
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func registerCloudMonitoring(srv *sim.Server) {
+	srv.HandleFunc("GET /v3/projects/{project}/timeSeries", handleListTimeSeries)
+}
+
+// handleListTimeSeries serves projects.timeSeries.list for the Cloud
+// Run container utilization metrics. The sim has no real usage data, so
+// it reports a fixed fraction of the allocation (as the AWS sim does
+// for Container Insights) for any job, service or function that exists.
+// The caller's aligner and reducer are accepted and ignored: the result
+// is already one aligned series.
+func handleListTimeSeries(w http.ResponseWriter, r *http.Request) {
+	filter := r.URL.Query().Get("filter")
+	if filter == "" {
+		sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "filter is required")
+		return
+	}
+	var metricType, resourceType string
+	labels := map[string]string{}
+	for _, c := range parseFilter(filter) {
+		switch c.field {
+		case "metric.type":
+			metricType = c.value
+		case "resource.type":
+			resourceType = c.value
+		case "resource.labels.job_name":
+			labels["job_name"] = c.value
+		case "resource.labels.service_name":
+			labels["service_name"] = c.value
+		}
+	}
+
+	var value float64
+	switch metricType {
+	case "run.googleapis.com/container/cpu/utilizations":
+		value = 0.15
+	case "run.googleapis.com/container/memory/utilizations":
+		value = 0.25
+	default:
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+		return
+	}
+	if !monitoredRunResourceExists(resourceType, labels) {
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+		return
+	}
+
+	end := time.Now().UTC().Truncate(time.Minute)
+	sim.WriteJSON(w, http.StatusOK, map[string]any{
+		"timeSeries": []MonitoringTimeSeries{{
+			Metric:     MonitoringMetric{Type: metricType},
+			Resource:   MonitoringResource{Type: resourceType, Labels: labels},
+			MetricKind: "GAUGE",
+			ValueType:  "DOUBLE",
+			Points: []MonitoringPoint{{
+				Interval: MonitoringInterval{
+					StartTime: end.Add(-time.Minute).Format(time.RFC3339),
+					EndTime:   end.Format(time.RFC3339),
+				},
+				Value: MonitoringValue{DoubleValue: &value},
+			}},
+		}},
+	})
+}
+
+// monitoredRunResourceExists matches a cloud_run_job / cloud_run_revision
+// monitored resource against the sim's jobs, services and functions by
+// short name. Gen2 functions publish under the function's name.
+func monitoredRunResourceExists(resourceType string, labels map[string]string) bool {
+	switch resourceType {
+	case "cloud_run_job":
+		for _, j := range crjJobs.List() {
+			if path.Base(j.Name) == labels["job_name"] {
+				return true
+			}
+		}
+	case "cloud_run_revision":
+		for _, s := range crv2Services.List() {
+			if path.Base(s.Name) == labels["service_name"] {
+				return true
+			}
+		}
+		for _, f := range gcfFunctions.List() {
+			if path.Base(f.Name) == labels["service_name"] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,908 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sim "github.com/sockerless/simulator"
+)
+
+// Pub/Sub v1 slice: topics, pull + push subscriptions, ack deadlines,
+// ordering keys, retry policy and dead-letter topics. Pub/Sub-triggered
+// Cloud Run services (Eventarc, push subscriptions) and Cloud Scheduler
+// Pub/Sub targets both land here. Real API:
+// https://cloud.google.com/pubsub/docs/reference/rest
+
+// Topic is a Pub/Sub topic resource.
+type Topic struct {
+	Name                     string            `json:"name"`
+	Labels                   map[string]string `json:"labels,omitempty"`
+	KmsKeyName               string            `json:"kmsKeyName,omitempty"`
+	MessageRetentionDuration string            `json:"messageRetentionDuration,omitempty"`
+	MessageStoragePolicy     map[string]any    `json:"messageStoragePolicy,omitempty"`
+	SchemaSettings           map[string]any    `json:"schemaSettings,omitempty"`
+	State                    string            `json:"state,omitempty"`
+}
+
+// PushConfig configures push delivery for a subscription. An empty
+// PushEndpoint makes the subscription a pull subscription.
+type PushConfig struct {
+	PushEndpoint string            `json:"pushEndpoint,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	OidcToken    *OidcToken        `json:"oidcToken,omitempty"`
+	NoWrapper    *struct {
+		WriteMetadata bool `json:"writeMetadata,omitempty"`
+	} `json:"noWrapper,omitempty"`
+}
+
+// DeadLetterPolicy forwards messages to DeadLetterTopic once they have
+// been delivered MaxDeliveryAttempts times without an ack.
+type DeadLetterPolicy struct {
+	DeadLetterTopic     string `json:"deadLetterTopic,omitempty"`
+	MaxDeliveryAttempts int    `json:"maxDeliveryAttempts,omitempty"`
+}
+
+// RetryPolicy delays redelivery of nacked / expired messages with an
+// exponential backoff between MinimumBackoff and MaximumBackoff.
+type RetryPolicy struct {
+	MinimumBackoff string `json:"minimumBackoff,omitempty"`
+	MaximumBackoff string `json:"maximumBackoff,omitempty"`
+}
+
+// Subscription is a Pub/Sub subscription resource.
+type Subscription struct {
+	Name                      string            `json:"name"`
+	Topic                     string            `json:"topic"`
+	PushConfig                *PushConfig       `json:"pushConfig,omitempty"`
+	AckDeadlineSeconds        int               `json:"ackDeadlineSeconds,omitempty"`
+	RetainAckedMessages       bool              `json:"retainAckedMessages,omitempty"`
+	MessageRetentionDuration  string            `json:"messageRetentionDuration,omitempty"`
+	Labels                    map[string]string `json:"labels,omitempty"`
+	EnableMessageOrdering     bool              `json:"enableMessageOrdering,omitempty"`
+	ExpirationPolicy          map[string]any    `json:"expirationPolicy,omitempty"`
+	Filter                    string            `json:"filter,omitempty"`
+	DeadLetterPolicy          *DeadLetterPolicy `json:"deadLetterPolicy,omitempty"`
+	RetryPolicy               *RetryPolicy      `json:"retryPolicy,omitempty"`
+	EnableExactlyOnceDelivery bool              `json:"enableExactlyOnceDelivery,omitempty"`
+	State                     string            `json:"state,omitempty"`
+}
+
+// PubsubMessage is the wire shape of a published message.
+type PubsubMessage struct {
+	Data        string            `json:"data,omitempty"` // base64-encoded
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	MessageID   string            `json:"messageId,omitempty"`
+	PublishTime string            `json:"publishTime,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+// pubsubPending is one message outstanding on one subscription. Keyed
+// by `{subscription}/{messageId}` in psMessages; Seq orders delivery.
+type pubsubPending struct {
+	Subscription    string        `json:"subscription"`
+	Message         PubsubMessage `json:"message"`
+	Seq             int64         `json:"seq"`
+	DeliveryAttempt int           `json:"deliveryAttempt"`
+	// LeaseExpiry is when the current delivery's ack deadline runs out.
+	// Zero means never delivered.
+	LeaseExpiry time.Time `json:"leaseExpiry"`
+}
+
+const (
+	pubsubDefaultAckDeadline  = 10
+	pubsubMaxAckDeadline      = 600
+	pubsubDefaultRetention    = 7 * 24 * time.Hour
+	pubsubDefaultMaxDelivery  = 5
+	pubsubDefaultMinBackoff   = 10 * time.Second
+	pubsubDefaultMaxBackoff   = 600 * time.Second
+	pubsubPullWait            = 5 * time.Second
+	pubsubPushRequestDeadline = 60 * time.Second
+	pubsubDeletedTopic        = "_deleted-topic_"
+)
+
+var (
+	psTopics        sim.Store[Topic]
+	psSubscriptions sim.Store[Subscription]
+	psMessages      sim.Store[pubsubPending]
+
+	// psMu serialises lease decisions so two concurrent pulls never hand
+	// out the same message.
+	psMu sync.Mutex
+	// psSeq issues message IDs and delivery order. Seeded past the
+	// highest persisted Seq at registration.
+	psSeq atomic.Int64
+	// psKick wakes the push dispatcher after a publish.
+	psKick = make(chan struct{}, 1)
+	// psPushInflight tracks messages currently being pushed so the
+	// dispatcher doesn't double-send while a request is in flight.
+	psPushInflight sync.Map // map[pendingKey]struct{}
+)
+
+func registerPubSub(srv *sim.Server) {
+	psTopics = sim.MakeStore[Topic](srv.DB(), "pubsub_topics")
+	psSubscriptions = sim.MakeStore[Subscription](srv.DB(), "pubsub_subscriptions")
+	psMessages = sim.MakeStore[pubsubPending](srv.DB(), "pubsub_messages")
+	for _, m := range psMessages.List() {
+		if m.Seq > psSeq.Load() {
+			psSeq.Store(m.Seq)
+		}
+	}
+
+	// CreateTopic: PUT /v1/projects/{project}/topics/{topic}
+	srv.HandleFunc("PUT /v1/projects/{project}/topics/{topic}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/topics/%s", sim.PathParam(r, "project"), sim.PathParam(r, "topic"))
+		var req Topic
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		if _, exists := psTopics.Get(name); exists {
+			sim.GCPError(w, http.StatusConflict, "Resource already exists in the project (resource="+sim.PathParam(r, "topic")+").", "ALREADY_EXISTS")
+			return
+		}
+		req.Name = name
+		req.State = "ACTIVE"
+		psTopics.Put(name, req)
+		sim.WriteJSON(w, http.StatusOK, req)
+	})
+
+	// GetTopic: GET /v1/projects/{project}/topics/{topic}
+	srv.HandleFunc("GET /v1/projects/{project}/topics/{topic}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/topics/%s", sim.PathParam(r, "project"), sim.PathParam(r, "topic"))
+		topic, ok := psTopics.Get(name)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+sim.PathParam(r, "topic")+").", "NOT_FOUND")
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, topic)
+	})
+
+	// UpdateTopic: PATCH /v1/projects/{project}/topics/{topic}
+	// Body: {"topic": {...}, "updateMask": "labels,messageRetentionDuration"}
+	srv.HandleFunc("PATCH /v1/projects/{project}/topics/{topic}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/topics/%s", sim.PathParam(r, "project"), sim.PathParam(r, "topic"))
+		var req struct {
+			Topic      Topic  `json:"topic"`
+			UpdateMask string `json:"updateMask"`
+		}
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		topic, ok := psTopics.Get(name)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+sim.PathParam(r, "topic")+").", "NOT_FOUND")
+			return
+		}
+		for _, f := range splitUpdateMask(req.UpdateMask) {
+			switch f {
+			case "labels":
+				topic.Labels = req.Topic.Labels
+			case "kmsKeyName", "kms_key_name":
+				topic.KmsKeyName = req.Topic.KmsKeyName
+			case "messageRetentionDuration", "message_retention_duration":
+				topic.MessageRetentionDuration = req.Topic.MessageRetentionDuration
+			case "messageStoragePolicy", "message_storage_policy":
+				topic.MessageStoragePolicy = req.Topic.MessageStoragePolicy
+			case "schemaSettings", "schema_settings":
+				topic.SchemaSettings = req.Topic.SchemaSettings
+			default:
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid update_mask provided in the UpdateTopicRequest: %q is not a known Topic field", f)
+				return
+			}
+		}
+		psTopics.Put(name, topic)
+		sim.WriteJSON(w, http.StatusOK, topic)
+	})
+
+	// DeleteTopic: DELETE /v1/projects/{project}/topics/{topic}
+	// Existing subscriptions survive with topic set to `_deleted-topic_`.
+	srv.HandleFunc("DELETE /v1/projects/{project}/topics/{topic}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/topics/%s", sim.PathParam(r, "project"), sim.PathParam(r, "topic"))
+		if !psTopics.Delete(name) {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+sim.PathParam(r, "topic")+").", "NOT_FOUND")
+			return
+		}
+		for _, sub := range psSubscriptions.Filter(func(s Subscription) bool { return s.Topic == name }) {
+			psSubscriptions.Update(sub.Name, func(s *Subscription) { s.Topic = pubsubDeletedTopic })
+		}
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+	})
+
+	// ListTopics: GET /v1/projects/{project}/topics
+	srv.HandleFunc("GET /v1/projects/{project}/topics", func(w http.ResponseWriter, r *http.Request) {
+		prefix := fmt.Sprintf("projects/%s/topics/", sim.PathParam(r, "project"))
+		topics := psTopics.Filter(func(t Topic) bool { return strings.HasPrefix(t.Name, prefix) })
+		sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+		sim.WriteJSON(w, http.StatusOK, map[string]any{"topics": topics})
+	})
+
+	// ListTopicSubscriptions: GET /v1/projects/{project}/topics/{topic}/subscriptions
+	srv.HandleFunc("GET /v1/projects/{project}/topics/{topic}/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/topics/%s", sim.PathParam(r, "project"), sim.PathParam(r, "topic"))
+		if _, ok := psTopics.Get(name); !ok {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+sim.PathParam(r, "topic")+").", "NOT_FOUND")
+			return
+		}
+		names := []string{}
+		for _, s := range psSubscriptions.Filter(func(s Subscription) bool { return s.Topic == name }) {
+			names = append(names, s.Name)
+		}
+		sort.Strings(names)
+		sim.WriteJSON(w, http.StatusOK, map[string]any{"subscriptions": names})
+	})
+
+	// Publish: POST /v1/projects/{project}/topics/{topic}:publish.
+	// Go's ServeMux doesn't allow `{wild}:suffix`, so the action is parsed
+	// out of the last segment.
+	srv.HandleFunc("POST /v1/projects/{project}/topics/{topicAction}", func(w http.ResponseWriter, r *http.Request) {
+		topicID, action, found := strings.Cut(sim.PathParam(r, "topicAction"), ":")
+		if !found || action != "publish" {
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "unknown topic action %q", sim.PathParam(r, "topicAction"))
+			return
+		}
+		name := fmt.Sprintf("projects/%s/topics/%s", sim.PathParam(r, "project"), topicID)
+		var req struct {
+			Messages []PubsubMessage `json:"messages"`
+		}
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		if len(req.Messages) == 0 {
+			sim.GCPError(w, http.StatusBadRequest, "The request contains no messages.", "INVALID_ARGUMENT")
+			return
+		}
+		for i, m := range req.Messages {
+			if m.Data == "" && len(m.Attributes) == 0 {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "message %d has neither data nor attributes", i)
+				return
+			}
+			if _, err := base64.StdEncoding.DecodeString(m.Data); err != nil {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "message %d data must be base64: %v", i, err)
+				return
+			}
+		}
+		ids, err := pubsubPublish(name, req.Messages)
+		if err != nil {
+			sim.GCPError(w, http.StatusNotFound, err.Error(), "NOT_FOUND")
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, map[string]any{"messageIds": ids})
+	})
+
+	// CreateSubscription: PUT /v1/projects/{project}/subscriptions/{subscription}
+	srv.HandleFunc("PUT /v1/projects/{project}/subscriptions/{subscription}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/subscriptions/%s", sim.PathParam(r, "project"), sim.PathParam(r, "subscription"))
+		var req Subscription
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		if _, exists := psSubscriptions.Get(name); exists {
+			sim.GCPError(w, http.StatusConflict, "Resource already exists in the project (resource="+sim.PathParam(r, "subscription")+").", "ALREADY_EXISTS")
+			return
+		}
+		if _, ok := psTopics.Get(req.Topic); !ok {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+req.Topic+").", "NOT_FOUND")
+			return
+		}
+		req.Name = name
+		if msg := normalizeSubscription(&req); msg != "" {
+			sim.GCPError(w, http.StatusBadRequest, msg, "INVALID_ARGUMENT")
+			return
+		}
+		req.State = "ACTIVE"
+		psSubscriptions.Put(name, req)
+		sim.WriteJSON(w, http.StatusOK, req)
+	})
+
+	// GetSubscription: GET /v1/projects/{project}/subscriptions/{subscription}
+	srv.HandleFunc("GET /v1/projects/{project}/subscriptions/{subscription}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/subscriptions/%s", sim.PathParam(r, "project"), sim.PathParam(r, "subscription"))
+		sub, ok := psSubscriptions.Get(name)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+sim.PathParam(r, "subscription")+").", "NOT_FOUND")
+			return
+		}
+		sim.WriteJSON(w, http.StatusOK, sub)
+	})
+
+	// UpdateSubscription: PATCH /v1/projects/{project}/subscriptions/{subscription}
+	// Body: {"subscription": {...}, "updateMask": "ackDeadlineSeconds,..."}
+	srv.HandleFunc("PATCH /v1/projects/{project}/subscriptions/{subscription}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/subscriptions/%s", sim.PathParam(r, "project"), sim.PathParam(r, "subscription"))
+		var req struct {
+			Subscription Subscription `json:"subscription"`
+			UpdateMask   string       `json:"updateMask"`
+		}
+		if err := sim.ReadJSON(r, &req); err != nil {
+			sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+			return
+		}
+		sub, ok := psSubscriptions.Get(name)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+sim.PathParam(r, "subscription")+").", "NOT_FOUND")
+			return
+		}
+		in := req.Subscription
+		for _, f := range splitUpdateMask(req.UpdateMask) {
+			switch f {
+			case "ackDeadlineSeconds", "ack_deadline_seconds":
+				sub.AckDeadlineSeconds = in.AckDeadlineSeconds
+			case "pushConfig", "push_config":
+				sub.PushConfig = in.PushConfig
+			case "labels":
+				sub.Labels = in.Labels
+			case "retainAckedMessages", "retain_acked_messages":
+				sub.RetainAckedMessages = in.RetainAckedMessages
+			case "messageRetentionDuration", "message_retention_duration":
+				sub.MessageRetentionDuration = in.MessageRetentionDuration
+			case "expirationPolicy", "expiration_policy":
+				sub.ExpirationPolicy = in.ExpirationPolicy
+			case "deadLetterPolicy", "dead_letter_policy":
+				sub.DeadLetterPolicy = in.DeadLetterPolicy
+			case "retryPolicy", "retry_policy":
+				sub.RetryPolicy = in.RetryPolicy
+			case "enableExactlyOnceDelivery", "enable_exactly_once_delivery":
+				sub.EnableExactlyOnceDelivery = in.EnableExactlyOnceDelivery
+			case "enableMessageOrdering", "enable_message_ordering", "filter", "topic":
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid update_mask provided in the UpdateSubscriptionRequest: the %q field can not be updated", f)
+				return
+			default:
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid update_mask provided in the UpdateSubscriptionRequest: %q is not a known Subscription field", f)
+				return
+			}
+		}
+		if msg := normalizeSubscription(&sub); msg != "" {
+			sim.GCPError(w, http.StatusBadRequest, msg, "INVALID_ARGUMENT")
+			return
+		}
+		psSubscriptions.Put(name, sub)
+		sim.WriteJSON(w, http.StatusOK, sub)
+	})
+
+	// DeleteSubscription: DELETE /v1/projects/{project}/subscriptions/{subscription}
+	srv.HandleFunc("DELETE /v1/projects/{project}/subscriptions/{subscription}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/subscriptions/%s", sim.PathParam(r, "project"), sim.PathParam(r, "subscription"))
+		if !psSubscriptions.Delete(name) {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+sim.PathParam(r, "subscription")+").", "NOT_FOUND")
+			return
+		}
+		psMu.Lock()
+		for _, m := range psMessages.Filter(func(m pubsubPending) bool { return m.Subscription == name }) {
+			psMessages.Delete(pendingKey(m.Subscription, m.Message.MessageID))
+		}
+		psMu.Unlock()
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+	})
+
+	// ListSubscriptions: GET /v1/projects/{project}/subscriptions
+	srv.HandleFunc("GET /v1/projects/{project}/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		prefix := fmt.Sprintf("projects/%s/subscriptions/", sim.PathParam(r, "project"))
+		subs := psSubscriptions.Filter(func(s Subscription) bool { return strings.HasPrefix(s.Name, prefix) })
+		sort.Slice(subs, func(i, j int) bool { return subs[i].Name < subs[j].Name })
+		sim.WriteJSON(w, http.StatusOK, map[string]any{"subscriptions": subs})
+	})
+
+	// Subscription actions:
+	//   POST .../subscriptions/{sub}:pull
+	//   POST .../subscriptions/{sub}:acknowledge
+	//   POST .../subscriptions/{sub}:modifyAckDeadline
+	//   POST .../subscriptions/{sub}:modifyPushConfig
+	srv.HandleFunc("POST /v1/projects/{project}/subscriptions/{subscriptionAction}", func(w http.ResponseWriter, r *http.Request) {
+		subID, action, found := strings.Cut(sim.PathParam(r, "subscriptionAction"), ":")
+		if !found {
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "missing :action suffix on subscription %q", subID)
+			return
+		}
+		name := fmt.Sprintf("projects/%s/subscriptions/%s", sim.PathParam(r, "project"), subID)
+		sub, ok := psSubscriptions.Get(name)
+		if !ok {
+			sim.GCPError(w, http.StatusNotFound, "Resource not found (resource="+subID+").", "NOT_FOUND")
+			return
+		}
+		switch action {
+		case "pull":
+			handlePubSubPull(w, r, sub)
+		case "acknowledge":
+			var req struct {
+				AckIds []string `json:"ackIds"`
+			}
+			if err := sim.ReadJSON(r, &req); err != nil {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+				return
+			}
+			if err := pubsubAcknowledge(sub.Name, req.AckIds); err != nil {
+				sim.GCPError(w, http.StatusBadRequest, err.Error(), "INVALID_ARGUMENT")
+				return
+			}
+			sim.WriteJSON(w, http.StatusOK, map[string]any{})
+		case "modifyAckDeadline":
+			var req struct {
+				AckIds             []string `json:"ackIds"`
+				AckDeadlineSeconds int      `json:"ackDeadlineSeconds"`
+			}
+			if err := sim.ReadJSON(r, &req); err != nil {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+				return
+			}
+			if req.AckDeadlineSeconds < 0 || req.AckDeadlineSeconds > pubsubMaxAckDeadline {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid ack deadline given: %d; must be between 0 and %d", req.AckDeadlineSeconds, pubsubMaxAckDeadline)
+				return
+			}
+			if err := pubsubModifyAckDeadline(sub.Name, req.AckIds, time.Duration(req.AckDeadlineSeconds)*time.Second); err != nil {
+				sim.GCPError(w, http.StatusBadRequest, err.Error(), "INVALID_ARGUMENT")
+				return
+			}
+			sim.WriteJSON(w, http.StatusOK, map[string]any{})
+		case "modifyPushConfig":
+			var req struct {
+				PushConfig *PushConfig `json:"pushConfig"`
+			}
+			if err := sim.ReadJSON(r, &req); err != nil {
+				sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+				return
+			}
+			psSubscriptions.Update(sub.Name, func(s *Subscription) {
+				s.PushConfig = req.PushConfig
+				if s.PushConfig == nil {
+					s.PushConfig = &PushConfig{}
+				}
+			})
+			pubsubKickPush()
+			sim.WriteJSON(w, http.StatusOK, map[string]any{})
+		default:
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "unknown subscription action %q", action)
+		}
+	})
+
+	go runPubSubPushDispatcher(context.Background())
+}
+
+// normalizeSubscription applies server-side defaults and validates the
+// fields that real Pub/Sub range-checks. Returns a non-empty message on
+// INVALID_ARGUMENT.
+func normalizeSubscription(s *Subscription) string {
+	if s.Filter != "" {
+		return "subscription filters are not supported by the simulator"
+	}
+	if s.AckDeadlineSeconds == 0 {
+		s.AckDeadlineSeconds = pubsubDefaultAckDeadline
+	}
+	if s.AckDeadlineSeconds < pubsubDefaultAckDeadline || s.AckDeadlineSeconds > pubsubMaxAckDeadline {
+		return fmt.Sprintf("Invalid ack deadline given: %d; must be between %d and %d",
+			s.AckDeadlineSeconds, pubsubDefaultAckDeadline, pubsubMaxAckDeadline)
+	}
+	if s.MessageRetentionDuration == "" {
+		s.MessageRetentionDuration = protoDuration(pubsubDefaultRetention)
+	} else if _, err := time.ParseDuration(s.MessageRetentionDuration); err != nil {
+		return fmt.Sprintf("invalid messageRetentionDuration %q", s.MessageRetentionDuration)
+	}
+	if s.PushConfig == nil {
+		s.PushConfig = &PushConfig{}
+	}
+	if s.ExpirationPolicy == nil {
+		s.ExpirationPolicy = map[string]any{"ttl": "2678400s"}
+	}
+	if dl := s.DeadLetterPolicy; dl != nil {
+		if dl.MaxDeliveryAttempts == 0 {
+			dl.MaxDeliveryAttempts = pubsubDefaultMaxDelivery
+		}
+		if dl.MaxDeliveryAttempts < 5 || dl.MaxDeliveryAttempts > 100 {
+			return fmt.Sprintf("Invalid max_delivery_attempts given: %d; must be between 5 and 100", dl.MaxDeliveryAttempts)
+		}
+		if _, ok := psTopics.Get(dl.DeadLetterTopic); !ok {
+			return fmt.Sprintf("Invalid resource name given (name=%s): dead letter topic not found", dl.DeadLetterTopic)
+		}
+	}
+	if rp := s.RetryPolicy; rp != nil {
+		if rp.MinimumBackoff == "" {
+			rp.MinimumBackoff = protoDuration(pubsubDefaultMinBackoff)
+		}
+		if rp.MaximumBackoff == "" {
+			rp.MaximumBackoff = protoDuration(pubsubDefaultMaxBackoff)
+		}
+		minB, err1 := time.ParseDuration(rp.MinimumBackoff)
+		maxB, err2 := time.ParseDuration(rp.MaximumBackoff)
+		if err1 != nil || err2 != nil || minB < 0 || maxB > pubsubDefaultMaxBackoff || minB > maxB {
+			return fmt.Sprintf("Invalid retry policy: minimumBackoff=%q maximumBackoff=%q", rp.MinimumBackoff, rp.MaximumBackoff)
+		}
+	}
+	return ""
+}
+
+// pubsubPublish fans the messages out to every subscription attached to
+// the topic and returns the assigned message IDs in request order.
+func pubsubPublish(topic string, msgs []PubsubMessage) ([]string, error) {
+	if _, ok := psTopics.Get(topic); !ok {
+		return nil, fmt.Errorf("Resource not found (resource=%s).", topic) //nolint:staticcheck // matches the real API's message text
+	}
+	subs := psSubscriptions.Filter(func(s Subscription) bool { return s.Topic == topic })
+	now := time.Now().UTC()
+	ids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		seq := psSeq.Add(1)
+		m.MessageID = strconv.FormatInt(seq, 10)
+		m.PublishTime = now.Format(time.RFC3339Nano)
+		ids = append(ids, m.MessageID)
+		for _, s := range subs {
+			psMessages.Put(pendingKey(s.Name, m.MessageID), pubsubPending{
+				Subscription: s.Name,
+				Message:      m,
+				Seq:          seq,
+			})
+		}
+	}
+	pubsubKickPush()
+	return ids, nil
+}
+
+func pubsubKickPush() {
+	select {
+	case psKick <- struct{}{}:
+	default:
+	}
+}
+
+func pendingKey(sub, messageID string) string {
+	return sub + "/" + messageID
+}
+
+// receivedMessage is the pull response element.
+type receivedMessage struct {
+	AckID           string        `json:"ackId"`
+	Message         PubsubMessage `json:"message"`
+	DeliveryAttempt int           `json:"deliveryAttempt,omitempty"`
+}
+
+func handlePubSubPull(w http.ResponseWriter, r *http.Request, sub Subscription) {
+	var req struct {
+		MaxMessages       int  `json:"maxMessages"`
+		ReturnImmediately bool `json:"returnImmediately"`
+	}
+	if err := sim.ReadJSON(r, &req); err != nil {
+		sim.GCPErrorf(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %v", err)
+		return
+	}
+	if req.MaxMessages <= 0 {
+		sim.GCPError(w, http.StatusBadRequest, "max_messages must be greater than 0", "INVALID_ARGUMENT")
+		return
+	}
+	if sub.PushConfig != nil && sub.PushConfig.PushEndpoint != "" {
+		sim.GCPError(w, http.StatusBadRequest, "Pull is not supported on a push subscription.", "FAILED_PRECONDITION")
+		return
+	}
+
+	// Real Pub/Sub holds a pull open for a while when nothing is
+	// available; the sim waits a bounded interval so tests that pull an
+	// empty subscription don't hang.
+	deadline := time.Now().Add(pubsubPullWait)
+	for {
+		got := pubsubLease(sub, req.MaxMessages)
+		if len(got) > 0 || req.ReturnImmediately || time.Now().After(deadline) {
+			out := make([]receivedMessage, 0, len(got))
+			for _, p := range got {
+				rm := receivedMessage{AckID: encodeAckID(p), Message: p.Message}
+				if sub.DeadLetterPolicy != nil {
+					rm.DeliveryAttempt = p.DeliveryAttempt
+				}
+				out = append(out, rm)
+			}
+			sim.WriteJSON(w, http.StatusOK, map[string]any{"receivedMessages": out})
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// pubsubLease picks up to max deliverable messages for sub, marks them
+// leased for the subscription's ack deadline and returns them in
+// delivery order. Messages past their delivery-attempt budget are
+// forwarded to the dead-letter topic instead; expired messages are
+// dropped.
+func pubsubLease(sub Subscription, max int) []pubsubPending {
+	psMu.Lock()
+	defer psMu.Unlock()
+
+	now := time.Now()
+	retention := pubsubDefaultRetention
+	if d, err := time.ParseDuration(sub.MessageRetentionDuration); err == nil && d > 0 {
+		retention = d
+	}
+	pending := psMessages.Filter(func(m pubsubPending) bool { return m.Subscription == sub.Name })
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Seq < pending[j].Seq })
+
+	var out []pubsubPending
+	blockedKeys := map[string]bool{}
+	for _, p := range pending {
+		if len(out) >= max {
+			break
+		}
+		key := pendingKey(p.Subscription, p.Message.MessageID)
+		if pt, err := time.Parse(time.RFC3339Nano, p.Message.PublishTime); err == nil && now.Sub(pt) > retention {
+			psMessages.Delete(key)
+			continue
+		}
+		ordered := sub.EnableMessageOrdering && p.Message.OrderingKey != ""
+		if ordered && blockedKeys[p.Message.OrderingKey] {
+			continue
+		}
+		if _, pushing := psPushInflight.Load(key); pushing || now.Before(pubsubAvailableAt(sub, p)) {
+			// An earlier message for this ordering key is still
+			// outstanding; nothing after it may be delivered.
+			if ordered {
+				blockedKeys[p.Message.OrderingKey] = true
+			}
+			continue
+		}
+		if dl := sub.DeadLetterPolicy; dl != nil && p.DeliveryAttempt >= dl.MaxDeliveryAttempts {
+			pubsubForwardDeadLetter(sub, p)
+			psMessages.Delete(key)
+			continue
+		}
+		p.DeliveryAttempt++
+		p.LeaseExpiry = now.Add(time.Duration(sub.AckDeadlineSeconds) * time.Second)
+		psMessages.Put(key, p)
+		out = append(out, p)
+	}
+	return out
+}
+
+// pubsubAvailableAt is when a pending message may next be delivered: now
+// for never-delivered messages, otherwise the lease expiry plus the
+// subscription's retry-policy backoff.
+func pubsubAvailableAt(sub Subscription, p pubsubPending) time.Time {
+	if p.DeliveryAttempt == 0 {
+		return time.Time{}
+	}
+	rp := sub.RetryPolicy
+	if rp == nil {
+		return p.LeaseExpiry
+	}
+	minB, _ := time.ParseDuration(rp.MinimumBackoff)
+	maxB, _ := time.ParseDuration(rp.MaximumBackoff)
+	backoff := minB
+	for i := 1; i < p.DeliveryAttempt && backoff < maxB; i++ {
+		backoff *= 2
+	}
+	if backoff > maxB {
+		backoff = maxB
+	}
+	return p.LeaseExpiry.Add(backoff)
+}
+
+// pubsubForwardDeadLetter republishes p onto the subscription's
+// dead-letter topic with the source attributes real Pub/Sub attaches.
+func pubsubForwardDeadLetter(sub Subscription, p pubsubPending) {
+	attrs := make(map[string]string, len(p.Message.Attributes)+4)
+	for k, v := range p.Message.Attributes {
+		attrs[k] = v
+	}
+	project, _, _ := strings.Cut(strings.TrimPrefix(sub.Name, "projects/"), "/")
+	attrs["CloudPubSubDeadLetterSourceDeliveryCount"] = strconv.Itoa(p.DeliveryAttempt)
+	attrs["CloudPubSubDeadLetterSourceSubscription"] = sub.Name[strings.LastIndex(sub.Name, "/")+1:]
+	attrs["CloudPubSubDeadLetterSourceSubscriptionProject"] = project
+	attrs["CloudPubSubDeadLetterSourceTopicPublishTime"] = p.Message.PublishTime
+	msg := PubsubMessage{Data: p.Message.Data, Attributes: attrs, OrderingKey: p.Message.OrderingKey}
+	// Publishing runs under psMu; pubsubPublish only takes store locks.
+	if _, err := pubsubPublish(sub.DeadLetterPolicy.DeadLetterTopic, []PubsubMessage{msg}); err != nil {
+		injectPubSubLog(sub.Name, fmt.Sprintf("dead-letter forward of message %s failed: %v", p.Message.MessageID, err))
+	}
+}
+
+// encodeAckID packs (subscription, message, attempt) so an ack from an
+// earlier delivery can't settle a later redelivery of the same message.
+func encodeAckID(p pubsubPending) string {
+	raw := fmt.Sprintf("%s\x00%s\x00%d", p.Subscription, p.Message.MessageID, p.DeliveryAttempt)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeAckID(ackID string) (sub, messageID string, attempt int, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(ackID)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid ack ID %q", ackID)
+	}
+	parts := strings.Split(string(raw), "\x00")
+	if len(parts) != 3 {
+		return "", "", 0, fmt.Errorf("invalid ack ID %q", ackID)
+	}
+	attempt, err = strconv.Atoi(parts[2])
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid ack ID %q", ackID)
+	}
+	return parts[0], parts[1], attempt, nil
+}
+
+// pubsubAcknowledge settles the messages named by ackIDs. Ack IDs from a
+// superseded delivery are ignored, matching real at-least-once
+// semantics where a late ack may not take effect.
+func pubsubAcknowledge(subName string, ackIDs []string) error {
+	if len(ackIDs) == 0 {
+		return fmt.Errorf("No ack ids specified.") //nolint:staticcheck // matches the real API's message text
+	}
+	psMu.Lock()
+	defer psMu.Unlock()
+	for _, id := range ackIDs {
+		sub, msgID, attempt, err := decodeAckID(id)
+		if err != nil {
+			return err
+		}
+		if sub != subName {
+			return fmt.Errorf("ack ID %q belongs to a different subscription", id)
+		}
+		key := pendingKey(sub, msgID)
+		if p, ok := psMessages.Get(key); ok && p.DeliveryAttempt == attempt {
+			psMessages.Delete(key)
+		}
+	}
+	return nil
+}
+
+// pubsubModifyAckDeadline extends (or, with zero, releases) the leases
+// named by ackIDs.
+func pubsubModifyAckDeadline(subName string, ackIDs []string, d time.Duration) error {
+	if len(ackIDs) == 0 {
+		return fmt.Errorf("No ack ids specified.") //nolint:staticcheck // matches the real API's message text
+	}
+	psMu.Lock()
+	defer psMu.Unlock()
+	now := time.Now()
+	for _, id := range ackIDs {
+		sub, msgID, attempt, err := decodeAckID(id)
+		if err != nil {
+			return err
+		}
+		if sub != subName {
+			return fmt.Errorf("ack ID %q belongs to a different subscription", id)
+		}
+		key := pendingKey(sub, msgID)
+		psMessages.Update(key, func(p *pubsubPending) {
+			if p.DeliveryAttempt == attempt {
+				p.LeaseExpiry = now.Add(d)
+			}
+		})
+	}
+	return nil
+}
+
+// runPubSubPushDispatcher delivers messages on push subscriptions. It
+// wakes on every publish and on a short tick so redeliveries after a
+// failed push or expired lease go out without a new publish.
+func runPubSubPushDispatcher(ctx context.Context) {
+	tick := time.NewTicker(250 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-psKick:
+		case <-tick.C:
+		}
+		for _, sub := range psSubscriptions.List() {
+			if sub.PushConfig == nil || sub.PushConfig.PushEndpoint == "" {
+				continue
+			}
+			for _, p := range pubsubLease(sub, 100) {
+				key := pendingKey(p.Subscription, p.Message.MessageID)
+				psPushInflight.Store(key, struct{}{})
+				go func(sub Subscription, p pubsubPending, key string) {
+					defer psPushInflight.Delete(key)
+					pubsubPush(ctx, sub, p)
+				}(sub, p, key)
+			}
+		}
+	}
+}
+
+// pubsubPush performs one push delivery. 102/200/201/202/204 ack the
+// message; anything else releases the lease so retry policy applies.
+func pubsubPush(ctx context.Context, sub Subscription, p pubsubPending) {
+	cfg := sub.PushConfig
+	headers := map[string]string{}
+	var body []byte
+	if cfg.NoWrapper != nil {
+		body, _ = base64.StdEncoding.DecodeString(p.Message.Data)
+		if cfg.NoWrapper.WriteMetadata {
+			for k, v := range p.Message.Attributes {
+				headers["x-goog-pubsub-"+k] = v
+			}
+			headers["x-goog-pubsub-subscription-name"] = sub.Name
+			headers["x-goog-pubsub-message-id"] = p.Message.MessageID
+			headers["x-goog-pubsub-publish-time"] = p.Message.PublishTime
+			if p.Message.OrderingKey != "" {
+				headers["x-goog-pubsub-ordering-key"] = p.Message.OrderingKey
+			}
+		}
+	} else {
+		headers["Content-Type"] = "application/json"
+		env := map[string]any{
+			"message": map[string]any{
+				"data":         p.Message.Data,
+				"attributes":   p.Message.Attributes,
+				"messageId":    p.Message.MessageID,
+				"message_id":   p.Message.MessageID,
+				"publishTime":  p.Message.PublishTime,
+				"publish_time": p.Message.PublishTime,
+				"orderingKey":  p.Message.OrderingKey,
+			},
+			"subscription": sub.Name,
+		}
+		if sub.DeadLetterPolicy != nil {
+			env["deliveryAttempt"] = p.DeliveryAttempt
+		}
+		body, _ = json.Marshal(env)
+	}
+
+	var auth targetAuth
+	if cfg.OidcToken != nil {
+		auth.OIDC = cfg.OidcToken
+	}
+	status, err := dispatchHTTPTarget(ctx, http.MethodPost, cfg.PushEndpoint, headers, body, auth, pubsubPushRequestDeadline)
+	key := pendingKey(p.Subscription, p.Message.MessageID)
+
+	psMu.Lock()
+	defer psMu.Unlock()
+	switch {
+	case err == nil && (status == 102 || status == 200 || status == 201 || status == 202 || status == 204):
+		if cur, ok := psMessages.Get(key); ok && cur.DeliveryAttempt == p.DeliveryAttempt {
+			psMessages.Delete(key)
+		}
+	default:
+		if err != nil {
+			injectPubSubLog(sub.Name, fmt.Sprintf("push of message %s to %s failed: %v", p.Message.MessageID, cfg.PushEndpoint, err))
+		} else {
+			injectPubSubLog(sub.Name, fmt.Sprintf("push of message %s to %s returned HTTP %d", p.Message.MessageID, cfg.PushEndpoint, status))
+		}
+		now := time.Now()
+		psMessages.Update(key, func(cur *pubsubPending) {
+			if cur.DeliveryAttempt == p.DeliveryAttempt {
+				cur.LeaseExpiry = now
+			}
+		})
+	}
+}
+
+// injectPubSubLog records push / dead-letter delivery failures under the
+// subscription's `pubsub_subscription` monitored resource, where real
+// Pub/Sub surfaces them.
+func injectPubSubLog(subName, text string) {
+	parts := strings.Split(subName, "/")
+	if len(parts) != 4 {
+		return
+	}
+	logName := fmt.Sprintf("projects/%s/logs/pubsub.googleapis.com%%2Fsubscriptions", parts[1])
+	writeLogEntries(logName, &MonitoredResource{
+		Type:   "pubsub_subscription",
+		Labels: map[string]string{"project_id": parts[1], "subscription_id": parts[3]},
+	}, nil, []LogEntry{{Severity: "WARNING", TextPayload: text}})
+}
+
+// splitUpdateMask splits a FieldMask string into its paths.
+func splitUpdateMask(mask string) []string {
+	var out []string
+	for _, f := range strings.Split(mask, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// protoDuration renders d in proto-JSON Duration form ("3.5s").
+func protoDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
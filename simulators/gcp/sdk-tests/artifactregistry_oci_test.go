@@ -1,6 +1,7 @@
 package gcp_sdk_test
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 	artifactregistry "google.golang.org/api/artifactregistry/v1"
 	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
 )
 
 func TestArtifactRegistryDockerHubRemoteRepositorySDKAndOCI(t *testing.T) {
@@ -85,3 +87,59 @@ func TestArtifactRegistryDockerHubRemoteRepositorySDKAndOCI(t *testing.T) {
 	require.Contains(t, images.DockerImages[0].Name, "projects/test-project/locations/us-central1/repositories/docker-hub/dockerImages/sockerless-eval-arithmetic@sha256:")
 	require.Equal(t, []string{"test"}, images.DockerImages[0].Tags)
 }
+
+// TestArtifactRegistryRemoteRepositoryCredentials covers the resources a
+// sockerless-managed mirror consists of: a labelled remote repository
+// whose upstream password lives in a Secret Manager secret version.
+func TestArtifactRegistryRemoteRepositoryCredentials(t *testing.T) {
+	secrets, err := secretmanager.NewService(ctx,
+		option.WithEndpoint(baseURL+"/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	_, err = secrets.Projects.Secrets.Create("projects/test-project", &secretmanager.Secret{
+		Labels:      map[string]string{"sockerless_managed": "true"},
+		Replication: &secretmanager.Replication{Automatic: &secretmanager.Automatic{}},
+	}).SecretId("sockerless-mirror-ghcr-io").Do()
+	require.NoError(t, err)
+	version, err := secrets.Projects.Secrets.AddVersion("projects/test-project/secrets/sockerless-mirror-ghcr-io", &secretmanager.AddSecretVersionRequest{
+		Payload: &secretmanager.SecretPayload{Data: base64.StdEncoding.EncodeToString([]byte("pat"))},
+	}).Do()
+	require.NoError(t, err)
+
+	service, err := artifactregistry.NewService(ctx,
+		option.WithEndpoint(baseURL+"/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	parent := "projects/test-project/locations/us-central1"
+	_, err = service.Projects.Locations.Repositories.Create(parent, &artifactregistry.Repository{
+		Format: "DOCKER",
+		Mode:   "REMOTE_REPOSITORY",
+		Labels: map[string]string{"sockerless_managed": "true"},
+		RemoteRepositoryConfig: &artifactregistry.RemoteRepositoryConfig{
+			DockerRepository: &artifactregistry.DockerRepository{
+				CustomRepository: &artifactregistry.GoogleDevtoolsArtifactregistryV1RemoteRepositoryConfigDockerRepositoryCustomRepository{
+					Uri: "https://ghcr.io",
+				},
+			},
+			UpstreamCredentials: &artifactregistry.UpstreamCredentials{
+				UsernamePasswordCredentials: &artifactregistry.UsernamePasswordCredentials{
+					Username:              "user",
+					PasswordSecretVersion: version.Name,
+				},
+			},
+		},
+	}).RepositoryId("ghcr-io").Do()
+	require.NoError(t, err)
+
+	repo, err := service.Projects.Locations.Repositories.Get(parent + "/repositories/ghcr-io").Do()
+	require.NoError(t, err)
+	require.Equal(t, "true", repo.Labels["sockerless_managed"])
+	require.Equal(t, version.Name, repo.RemoteRepositoryConfig.UpstreamCredentials.UsernamePasswordCredentials.PasswordSecretVersion)
+
+	_, err = secrets.Projects.Secrets.Delete("projects/test-project/secrets/sockerless-mirror-ghcr-io").Do()
+	require.NoError(t, err)
+	_, err = secrets.Projects.Secrets.Get("projects/test-project/secrets/sockerless-mirror-ghcr-io").Do()
+	require.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package gcp_sdk_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func putARManifest(t *testing.T, repo, ref string, manifest any) (string, *http.Response) {
+	t.Helper()
+	data, _ := json.Marshal(manifest)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, baseURL+"/v2/"+repo+"/manifests/"+ref, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), resp
+}
+
+// TestArtifactRegistry_Referrers covers the OCI referrers API sockerless reads
+// Notation and cosign signatures through.
+func TestArtifactRegistry_Referrers(t *testing.T) {
+	empty := map[string]any{"mediaType": "application/vnd.oci.empty.v1+json", "digest": "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", "size": 2}
+	image, _ := putARManifest(t, "test-project/referrers/app", "v1", map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        empty,
+		"layers":        []any{},
+	})
+	sig, resp := putARManifest(t, "test-project/referrers/app", "notation-sig", map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"artifactType":  "application/vnd.cncf.notary.signature",
+		"config":        empty,
+		"layers":        []any{},
+		"subject":       map[string]any{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": image, "size": 1},
+	})
+	require.Equal(t, image, resp.Header.Get("OCI-Subject"))
+
+	get := func(query string) []map[string]any {
+		resp, err := http.Get(baseURL + "/v2/test-project/referrers/app/referrers/" + image + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/vnd.oci.image.index.v1+json", resp.Header.Get("Content-Type"))
+		var idx struct {
+			Manifests []map[string]any `json:"manifests"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&idx))
+		return idx.Manifests
+	}
+	refs := get("")
+	require.Len(t, refs, 1)
+	require.Equal(t, sig, refs[0]["digest"])
+	require.Equal(t, "application/vnd.cncf.notary.signature", refs[0]["artifactType"])
+	require.Empty(t, get("?artifactType=application/vnd.dev.cosign.artifact.sig.v1+json"))
+}
@@ -0,0 +1,175 @@
+package gcp_sdk_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cloudscheduler "google.golang.org/api/cloudscheduler/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+const schedulerParent = "projects/test-project/locations/us-central1"
+
+func cloudSchedulerService(t *testing.T) *cloudscheduler.Service {
+	t.Helper()
+	svc, err := cloudscheduler.NewService(ctx,
+		option.WithEndpoint(baseURL),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	return svc
+}
+
+func TestCloudScheduler_JobCRUDAndPause(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	svc := cloudSchedulerService(t)
+	name := schedulerParent + "/jobs/crud-job"
+	job, err := svc.Projects.Locations.Jobs.Create(schedulerParent, &cloudscheduler.Job{
+		Name:       name,
+		Schedule:   "0 9 * * 1",
+		TimeZone:   "Europe/Berlin",
+		HttpTarget: &cloudscheduler.HttpTarget{Uri: target.URL},
+	}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "ENABLED", job.State)
+	assert.Equal(t, "POST", job.HttpTarget.HttpMethod)
+	next, err := time.Parse(time.RFC3339Nano, job.ScheduleTime)
+	require.NoError(t, err)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	assert.Equal(t, time.Monday, next.In(berlin).Weekday())
+	assert.Equal(t, 9, next.In(berlin).Hour())
+
+	patched, err := svc.Projects.Locations.Jobs.Patch(name, &cloudscheduler.Job{
+		Schedule: "*/5 * * * *",
+	}).UpdateMask("schedule").Do()
+	require.NoError(t, err)
+	assert.Equal(t, "*/5 * * * *", patched.Schedule)
+	assert.Equal(t, "Europe/Berlin", patched.TimeZone)
+
+	paused, err := svc.Projects.Locations.Jobs.Pause(name, &cloudscheduler.PauseJobRequest{}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "PAUSED", paused.State)
+	resumed, err := svc.Projects.Locations.Jobs.Resume(name, &cloudscheduler.ResumeJobRequest{}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "ENABLED", resumed.State)
+	assert.NotEmpty(t, resumed.ScheduleTime)
+
+	_, err = svc.Projects.Locations.Jobs.Create(schedulerParent, &cloudscheduler.Job{
+		Name:       schedulerParent + "/jobs/bad-cron",
+		Schedule:   "every day",
+		HttpTarget: &cloudscheduler.HttpTarget{Uri: target.URL},
+	}).Do()
+	var gerr *googleapi.Error
+	require.ErrorAs(t, err, &gerr)
+	assert.Equal(t, http.StatusBadRequest, gerr.Code)
+
+	_, err = svc.Projects.Locations.Jobs.Delete(name).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Locations.Jobs.Get(name).Do()
+	require.ErrorAs(t, err, &gerr)
+	assert.Equal(t, http.StatusNotFound, gerr.Code)
+}
+
+func TestCloudScheduler_RunHTTPTarget(t *testing.T) {
+	got := make(chan *http.Request, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case got <- r:
+		default:
+		}
+	}))
+	defer target.Close()
+
+	svc := cloudSchedulerService(t)
+	name := schedulerParent + "/jobs/http-job"
+	_, err := svc.Projects.Locations.Jobs.Create(schedulerParent, &cloudscheduler.Job{
+		Name:     name,
+		Schedule: "0 0 1 1 *",
+		HttpTarget: &cloudscheduler.HttpTarget{
+			Uri:        target.URL + "/tick",
+			HttpMethod: "GET",
+		},
+	}).Do()
+	require.NoError(t, err)
+
+	_, err = svc.Projects.Locations.Jobs.Run(name, &cloudscheduler.RunJobRequest{}).Do()
+	require.NoError(t, err)
+
+	select {
+	case r := <-got:
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/tick", r.URL.Path)
+		assert.Equal(t, "true", r.Header.Get("X-CloudScheduler"))
+		assert.Equal(t, "http-job", r.Header.Get("X-CloudScheduler-JobName"))
+	case <-time.After(10 * time.Second):
+		t.Fatal("HTTP target never received the run")
+	}
+	require.Eventually(t, func() bool {
+		job, err := svc.Projects.Locations.Jobs.Get(name).Do()
+		return err == nil && job.LastAttemptTime != ""
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestCloudScheduler_FailedRunRecordsStatus(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer target.Close()
+
+	svc := cloudSchedulerService(t)
+	name := schedulerParent + "/jobs/failing-job"
+	_, err := svc.Projects.Locations.Jobs.Create(schedulerParent, &cloudscheduler.Job{
+		Name:       name,
+		Schedule:   "0 0 1 1 *",
+		HttpTarget: &cloudscheduler.HttpTarget{Uri: target.URL},
+	}).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Locations.Jobs.Run(name, &cloudscheduler.RunJobRequest{}).Do()
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		job, err := svc.Projects.Locations.Jobs.Get(name).Do()
+		return err == nil && job.Status != nil && job.Status.Code == 5
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestCloudScheduler_RunPubSubTarget(t *testing.T) {
+	ps := pubsubService(t)
+	topic := "projects/test-project/topics/scheduler-topic"
+	sub := "projects/test-project/subscriptions/scheduler-sub"
+	_, err := ps.Projects.Topics.Create(topic, &pubsub.Topic{}).Do()
+	require.NoError(t, err)
+	_, err = ps.Projects.Subscriptions.Create(sub, &pubsub.Subscription{Topic: topic}).Do()
+	require.NoError(t, err)
+
+	svc := cloudSchedulerService(t)
+	name := schedulerParent + "/jobs/pubsub-job"
+	_, err = svc.Projects.Locations.Jobs.Create(schedulerParent, &cloudscheduler.Job{
+		Name:     name,
+		Schedule: "0 0 1 1 *",
+		PubsubTarget: &cloudscheduler.PubsubTarget{
+			TopicName:  topic,
+			Data:       base64.StdEncoding.EncodeToString([]byte("tick")),
+			Attributes: map[string]string{"source": "scheduler"},
+		},
+	}).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Locations.Jobs.Run(name, &cloudscheduler.RunJobRequest{}).Do()
+	require.NoError(t, err)
+
+	pulled, err := ps.Projects.Subscriptions.Pull(sub, &pubsub.PullRequest{MaxMessages: 1}).Do()
+	require.NoError(t, err)
+	require.Len(t, pulled.ReceivedMessages, 1)
+	data, _ := base64.StdEncoding.DecodeString(pulled.ReceivedMessages[0].Message.Data)
+	assert.Equal(t, "tick", string(data))
+	assert.Equal(t, "scheduler", pulled.ReceivedMessages[0].Message.Attributes["source"])
+}
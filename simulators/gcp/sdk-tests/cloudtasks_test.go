@@ -0,0 +1,182 @@
+package gcp_sdk_test
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cloudtasks "google.golang.org/api/cloudtasks/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+const tasksParent = "projects/test-project/locations/us-central1"
+
+func cloudTasksService(t *testing.T) *cloudtasks.Service {
+	t.Helper()
+	svc, err := cloudtasks.NewService(ctx,
+		option.WithEndpoint(baseURL),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	return svc
+}
+
+func TestCloudTasks_QueueCRUD(t *testing.T) {
+	svc := cloudTasksService(t)
+	name := tasksParent + "/queues/crud-queue"
+
+	q, err := svc.Projects.Locations.Queues.Create(tasksParent, &cloudtasks.Queue{
+		Name:       name,
+		RateLimits: &cloudtasks.RateLimits{MaxDispatchesPerSecond: 5},
+	}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "RUNNING", q.State)
+	assert.Equal(t, 5.0, q.RateLimits.MaxDispatchesPerSecond)
+	assert.Equal(t, int64(5), q.RateLimits.MaxBurstSize)
+	require.NotNil(t, q.RetryConfig)
+	assert.Equal(t, int64(100), q.RetryConfig.MaxAttempts)
+
+	patched, err := svc.Projects.Locations.Queues.Patch(name, &cloudtasks.Queue{
+		RetryConfig: &cloudtasks.RetryConfig{MaxAttempts: 3, MinBackoff: "1s", MaxBackoff: "10s"},
+	}).UpdateMask("retryConfig").Do()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), patched.RetryConfig.MaxAttempts)
+
+	paused, err := svc.Projects.Locations.Queues.Pause(name, &cloudtasks.PauseQueueRequest{}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "PAUSED", paused.State)
+	resumed, err := svc.Projects.Locations.Queues.Resume(name, &cloudtasks.ResumeQueueRequest{}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "RUNNING", resumed.State)
+
+	_, err = svc.Projects.Locations.Queues.Delete(name).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Locations.Queues.Get(name).Do()
+	var gerr *googleapi.Error
+	require.ErrorAs(t, err, &gerr)
+	assert.Equal(t, http.StatusNotFound, gerr.Code)
+}
+
+func TestCloudTasks_HTTPTaskDispatched(t *testing.T) {
+	got := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got <- r
+		bodies <- string(b)
+	}))
+	defer target.Close()
+
+	svc := cloudTasksService(t)
+	queue := tasksParent + "/queues/dispatch-queue"
+	_, err := svc.Projects.Locations.Queues.Create(tasksParent, &cloudtasks.Queue{Name: queue}).Do()
+	require.NoError(t, err)
+
+	task, err := svc.Projects.Locations.Queues.Tasks.Create(queue, &cloudtasks.CreateTaskRequest{
+		Task: &cloudtasks.Task{
+			HttpRequest: &cloudtasks.HttpRequest{
+				Url:        target.URL + "/work",
+				HttpMethod: "PUT",
+				Headers:    map[string]string{"X-Custom": "yes"},
+				Body:       base64.StdEncoding.EncodeToString([]byte("payload")),
+				OidcToken:  &cloudtasks.OidcToken{ServiceAccountEmail: "tasks@test-project.iam.gserviceaccount.com"},
+			},
+		},
+	}).Do()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(task.Name, queue+"/tasks/"))
+
+	select {
+	case r := <-got:
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/work", r.URL.Path)
+		assert.Equal(t, "yes", r.Header.Get("X-Custom"))
+		assert.Equal(t, "dispatch-queue", r.Header.Get("X-CloudTasks-QueueName"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "))
+		assert.Equal(t, "payload", <-bodies)
+	case <-time.After(10 * time.Second):
+		t.Fatal("task was never dispatched")
+	}
+
+	// A 2xx response deletes the task.
+	require.Eventually(t, func() bool {
+		_, err := svc.Projects.Locations.Queues.Tasks.Get(task.Name).Do()
+		return err != nil
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestCloudTasks_FailedTaskRetriesThenDrops(t *testing.T) {
+	var hits atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	svc := cloudTasksService(t)
+	queue := tasksParent + "/queues/retry-queue"
+	_, err := svc.Projects.Locations.Queues.Create(tasksParent, &cloudtasks.Queue{
+		Name:        queue,
+		RetryConfig: &cloudtasks.RetryConfig{MaxAttempts: 3, MinBackoff: "0.1s", MaxBackoff: "0.2s"},
+	}).Do()
+	require.NoError(t, err)
+
+	task, err := svc.Projects.Locations.Queues.Tasks.Create(queue, &cloudtasks.CreateTaskRequest{
+		Task: &cloudtasks.Task{HttpRequest: &cloudtasks.HttpRequest{Url: target.URL}},
+	}).Do()
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := svc.Projects.Locations.Queues.Tasks.Get(task.Name).Do()
+		return err != nil
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(t, int32(3), hits.Load())
+}
+
+func TestCloudTasks_PausedQueueHoldsUntilRun(t *testing.T) {
+	var hits atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer target.Close()
+
+	svc := cloudTasksService(t)
+	queue := tasksParent + "/queues/paused-queue"
+	_, err := svc.Projects.Locations.Queues.Create(tasksParent, &cloudtasks.Queue{Name: queue}).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Locations.Queues.Pause(queue, &cloudtasks.PauseQueueRequest{}).Do()
+	require.NoError(t, err)
+
+	task, err := svc.Projects.Locations.Queues.Tasks.Create(queue, &cloudtasks.CreateTaskRequest{
+		Task: &cloudtasks.Task{HttpRequest: &cloudtasks.HttpRequest{Url: target.URL}},
+	}).Do()
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+	assert.Zero(t, hits.Load(), "paused queue must not dispatch")
+	list, err := svc.Projects.Locations.Queues.Tasks.List(queue).Do()
+	require.NoError(t, err)
+	assert.Len(t, list.Tasks, 1)
+
+	_, err = svc.Projects.Locations.Queues.Tasks.Run(task.Name, &cloudtasks.RunTaskRequest{}).Do()
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return hits.Load() == 1 }, 5*time.Second, 50*time.Millisecond)
+
+	_, err = svc.Projects.Locations.Queues.Tasks.Create(queue, &cloudtasks.CreateTaskRequest{
+		Task: &cloudtasks.Task{HttpRequest: &cloudtasks.HttpRequest{Url: target.URL}},
+	}).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Locations.Queues.Purge(queue, &cloudtasks.PurgeQueueRequest{}).Do()
+	require.NoError(t, err)
+	list, err = svc.Projects.Locations.Queues.Tasks.List(queue).Do()
+	require.NoError(t, err)
+	assert.Empty(t, list.Tasks)
+}
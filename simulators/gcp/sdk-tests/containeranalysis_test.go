@@ -0,0 +1,65 @@
+package gcp_sdk_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	containeranalysis "google.golang.org/api/containeranalysis/v1"
+	"google.golang.org/api/option"
+)
+
+// TestContainerAnalysis_ImageOccurrences covers the path sockerless's
+// ArtifactAnalysisScanner takes: a push records a finished DISCOVERY
+// occurrence for the image digest, and VULNERABILITY occurrences
+// written through the Grafeas API are listed by resourceUrl.
+func TestContainerAnalysis_ImageOccurrences(t *testing.T) {
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:` + strings.Repeat("0", 64) + `","size":2},"layers":[]}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, baseURL+"/v2/test-project/scan-repo/app/manifests/v1", strings.NewReader(manifest))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resourceURL := fmt.Sprintf("https://us-central1-docker.pkg.dev/test-project/scan-repo/app@sha256:%x", sha256.Sum256([]byte(manifest)))
+
+	svc, err := containeranalysis.NewService(ctx, option.WithEndpoint(baseURL+"/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	list := func(kind string) []*containeranalysis.Occurrence {
+		out, err := svc.Projects.Occurrences.List("projects/test-project").
+			Filter(fmt.Sprintf(`resourceUrl=%q AND kind=%q`, resourceURL, kind)).Do()
+		require.NoError(t, err)
+		return out.Occurrences
+	}
+
+	discovery := list("DISCOVERY")
+	require.Len(t, discovery, 1)
+	assert.Equal(t, "FINISHED_SUCCESS", discovery[0].Discovery.AnalysisStatus)
+	assert.Empty(t, list("VULNERABILITY"))
+
+	_, err = svc.Projects.Occurrences.Create("projects/test-project", &containeranalysis.Occurrence{
+		ResourceUri: resourceURL,
+		NoteName:    "projects/goog-vulnz/notes/CVE-2024-0001",
+		Vulnerability: &containeranalysis.VulnerabilityOccurrence{
+			EffectiveSeverity: "HIGH",
+			PackageIssue: []*containeranalysis.PackageIssue{{
+				AffectedPackage: "openssl",
+				AffectedVersion: &containeranalysis.Version{FullName: "3.0.11-1", Kind: "NORMAL"},
+				FixedVersion:    &containeranalysis.Version{FullName: "3.0.11-2", Kind: "NORMAL"},
+			}},
+		},
+	}).Do()
+	require.NoError(t, err)
+
+	vulns := list("VULNERABILITY")
+	require.Len(t, vulns, 1)
+	assert.Equal(t, "projects/goog-vulnz/notes/CVE-2024-0001", vulns[0].NoteName)
+	assert.Equal(t, "HIGH", vulns[0].Vulnerability.EffectiveSeverity)
+	require.Len(t, vulns[0].Vulnerability.PackageIssue, 1)
+	assert.Equal(t, "openssl", vulns[0].Vulnerability.PackageIssue[0].AffectedPackage)
+}
@@ -0,0 +1,196 @@
+package gcp_sdk_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	run "cloud.google.com/go/run/apiv2"
+	"cloud.google.com/go/run/apiv2/runpb"
+	"cloud.google.com/go/storage"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// simFault mirrors the /sim/v1/faults wire shape.
+type simFault struct {
+	ID       string `json:"id,omitempty"`
+	Service  string `json:"service,omitempty"`
+	Action   string `json:"action,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	Method   string `json:"method,omitempty"`
+	Kind     string `json:"kind"`
+	Status   int    `json:"status,omitempty"`
+	Latency  string `json:"latency,omitempty"`
+	Count    int    `json:"count,omitempty"`
+	After    int    `json:"after,omitempty"`
+	Hits     int    `json:"hits,omitempty"`
+}
+
+// injectFaults replaces the simulator's fault set for the duration of
+// the test.
+func injectFaults(t *testing.T, url string, faults ...simFault) {
+	t.Helper()
+	body, err := json.Marshal(faults)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, url+"/sim/v1/faults", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	msg, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(msg))
+	t.Cleanup(func() {
+		req, _ := http.NewRequest(http.MethodDelete, url+"/sim/v1/faults", nil)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+}
+
+func faultHits(t *testing.T, url, id string) int {
+	t.Helper()
+	resp, err := http.Get(url + "/sim/v1/faults/" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var f simFault
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&f))
+	return f.Hits
+}
+
+// fastStorageRetry keeps the client's retry policy but shrinks the
+// backoff so retry tests don't sleep for seconds.
+var fastStorageRetry = storage.WithBackoff(gax.Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond})
+
+func TestFaults_GCSThrottlingRetried(t *testing.T) {
+	client := storageClient(t)
+	defer client.Close()
+	client.SetRetry(fastStorageRetry)
+
+	bkt := client.Bucket("fault-retry-bucket")
+	require.NoError(t, bkt.Create(ctx, "test-project", nil))
+	w := bkt.Object("obj").NewWriter(ctx)
+	_, err := w.Write([]byte("payload"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	injectFaults(t, baseURL, simFault{ID: "gcs", Service: "storage", Resource: "*/fault-retry-bucket/o/obj", Method: "GET", Kind: "error", Count: 2})
+
+	attrs, err := bkt.Object("obj").Attrs(ctx)
+	require.NoError(t, err, "storage client retries 429")
+	assert.Equal(t, int64(7), attrs.Size)
+	assert.Equal(t, 2, faultHits(t, baseURL, "gcs"))
+}
+
+func TestFaults_GCSThrottlingSurfaced(t *testing.T) {
+	client := storageClient(t)
+	defer client.Close()
+
+	bkt := client.Bucket("fault-noretry-bucket")
+	require.NoError(t, bkt.Create(ctx, "test-project", nil))
+
+	injectFaults(t, baseURL, simFault{ID: "gcs", Service: "storage", Resource: "*/fault-noretry-bucket", Kind: "error"})
+
+	_, err := bkt.Retryer(storage.WithPolicy(storage.RetryNever)).Attrs(ctx)
+	require.Error(t, err)
+	var gerr *googleapi.Error
+	require.True(t, errors.As(err, &gerr), "want googleapi.Error, got %T: %v", err, err)
+	assert.Equal(t, http.StatusTooManyRequests, gerr.Code)
+	assert.Equal(t, 1, faultHits(t, baseURL, "gcs"))
+}
+
+func TestFaults_GCSLatency(t *testing.T) {
+	client := storageClient(t)
+	defer client.Close()
+
+	bkt := client.Bucket("fault-latency-bucket")
+	require.NoError(t, bkt.Create(ctx, "test-project", nil))
+
+	injectFaults(t, baseURL, simFault{Service: "storage", Resource: "*/fault-latency-bucket", Kind: "latency", Latency: "300ms"})
+
+	start := time.Now()
+	_, err := bkt.Attrs(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}
+
+// TestFaults_StartupConfig starts a dedicated simulator with SIM_FAULTS
+// set and checks the Cloud Run REST client surfaces the injected
+// RESOURCE_EXHAUSTED on create rather than anything succeeding
+// silently; the next create goes through once the fault's count is
+// spent.
+func TestFaults_StartupConfig(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	grpcPort := ln2.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	ln2.Close()
+
+	cmd := exec.Command(binaryPath)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("SIM_LISTEN_ADDR=:%d", port),
+		fmt.Sprintf("SIM_GCP_GRPC_PORT=%d", grpcPort),
+		`SIM_FAULTS=[{"id":"deploy","service":"services","method":"POST","kind":"error","count":1}]`,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	url := fmt.Sprintf("http://127.0.0.1:%d", port)
+	if err := waitForHealth(url + "/health"); err != nil {
+		_ = cmd.Process.Kill()
+		log.Fatalf("fault sim did not become healthy: %v", err)
+	}
+
+	client, err := run.NewServicesRESTClient(ctx, option.WithEndpoint(url), option.WithoutAuthentication())
+	require.NoError(t, err)
+	defer client.Close()
+
+	req := &runpb.CreateServiceRequest{
+		Parent:    "projects/fault-test/locations/us-central1",
+		ServiceId: "svc",
+		Service: &runpb.Service{
+			Template: &runpb.RevisionTemplate{
+				Containers: []*runpb.Container{{Image: "gcr.io/fault-test/hello"}},
+			},
+		},
+	}
+	_, err = client.CreateService(ctx, req)
+	require.Error(t, err)
+	var gerr *googleapi.Error
+	require.True(t, errors.As(err, &gerr), "want googleapi.Error, got %T: %v", err, err)
+	assert.Equal(t, http.StatusTooManyRequests, gerr.Code)
+	assert.Contains(t, gerr.Body, "RESOURCE_EXHAUSTED")
+
+	op, err := client.CreateService(ctx, req)
+	require.NoError(t, err)
+	_, err = op.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, faultHits(t, url, "deploy"))
+
+	// A bad SIM_FAULTS stops the simulator at startup.
+	bad := exec.Command(binaryPath)
+	bad.Env = append(os.Environ(), "SIM_LISTEN_ADDR=127.0.0.1:0", `SIM_FAULTS=[{"kind":"latency"}]`)
+	out, err := bad.CombinedOutput()
+	require.Error(t, err, "simulator must refuse an invalid SIM_FAULTS")
+	assert.Contains(t, string(out), "SIM_FAULTS")
+}
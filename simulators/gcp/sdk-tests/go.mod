@@ -8,6 +8,7 @@ require (
 	cloud.google.com/go/logging v1.18.0
 	cloud.google.com/go/run v1.21.0
 	cloud.google.com/go/storage v1.62.2
+	github.com/googleapis/gax-go/v2 v2.22.0
 	github.com/stretchr/testify v1.11.1
 	google.golang.org/api v0.279.0
 	google.golang.org/genproto/googleapis/api v0.0.0-20260504160031-60b97b32f348
@@ -38,7 +39,6 @@ require (
 	github.com/google/s2a-go v0.1.9 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/googleapis/enterprise-certificate-proxy v0.3.15 // indirect
-	github.com/googleapis/gax-go/v2 v2.22.0 // indirect
 	github.com/planetscale/vtprotobuf v0.6.1-0.20240319094008-0393e58bdf10 // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/spiffe/go-spiffe/v2 v2.6.0 // indirect
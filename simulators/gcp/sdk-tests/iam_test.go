@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/api/cloudresourcemanager/v1"
 	"google.golang.org/api/iam/v1"
 	iamcredentials "google.golang.org/api/iamcredentials/v1"
 	"google.golang.org/api/option"
@@ -167,3 +168,21 @@ func TestIAMCredentials_GenerateIdToken_RequiresAudience(t *testing.T) {
 	).Do()
 	require.Error(t, err)
 }
+
+func TestCRM_TestIamPermissions(t *testing.T) {
+	svc, err := cloudresourcemanager.NewService(ctx,
+		option.WithEndpoint(baseURL),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+
+	perms := []string{"run.jobs.run", "storage.objects.create", "iam.serviceAccounts.actAs"}
+	resp, err := svc.Projects.TestIamPermissions("test-project",
+		&cloudresourcemanager.TestIamPermissionsRequest{Permissions: perms}).Do()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, perms, resp.Permissions)
+
+	_, err = svc.Projects.TestIamPermissions("test-project",
+		&cloudresourcemanager.TestIamPermissionsRequest{Permissions: []string{"run.*"}}).Do()
+	require.Error(t, err)
+}
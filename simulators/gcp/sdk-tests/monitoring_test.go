@@ -0,0 +1,55 @@
+package gcp_sdk_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+func monitoringService(t *testing.T) *monitoring.Service {
+	t.Helper()
+	svc, err := monitoring.NewService(ctx,
+		option.WithEndpoint(baseURL),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	return svc
+}
+
+func listRunUtilization(t *testing.T, metric, jobName string) *monitoring.ListTimeSeriesResponse {
+	t.Helper()
+	now := time.Now().UTC()
+	resp, err := monitoringService(t).Projects.TimeSeries.List("projects/test-project").
+		Filter(`metric.type="run.googleapis.com/container/` + metric + `/utilizations" AND resource.type="cloud_run_job" AND resource.labels.job_name="` + jobName + `"`).
+		IntervalStartTime(now.Add(-5 * time.Minute).Format(time.RFC3339)).
+		IntervalEndTime(now.Format(time.RFC3339)).
+		AggregationAlignmentPeriod("60s").
+		AggregationPerSeriesAligner("ALIGN_MEAN").
+		AggregationCrossSeriesReducer("REDUCE_MEAN").
+		Do()
+	require.NoError(t, err)
+	return resp
+}
+
+func TestMonitoring_ListTimeSeriesForJob(t *testing.T) {
+	createAndRunJob(t, "monitored-job")
+
+	for _, metric := range []string{"cpu", "memory"} {
+		resp := listRunUtilization(t, metric, "monitored-job")
+		require.Len(t, resp.TimeSeries, 1, metric)
+		points := resp.TimeSeries[0].Points
+		require.NotEmpty(t, points, metric)
+		require.NotNil(t, points[0].Value.DoubleValue, metric)
+		assert.Greater(t, *points[0].Value.DoubleValue, 0.0, metric)
+		assert.NotEmpty(t, points[0].Interval.EndTime, metric)
+	}
+}
+
+func TestMonitoring_ListTimeSeriesUnknownJob(t *testing.T) {
+	resp := listRunUtilization(t, "cpu", "no-such-job")
+	assert.Empty(t, resp.TimeSeries)
+}
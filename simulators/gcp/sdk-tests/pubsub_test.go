@@ -0,0 +1,222 @@
+package gcp_sdk_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+func pubsubService(t *testing.T) *pubsub.Service {
+	t.Helper()
+	svc, err := pubsub.NewService(ctx,
+		option.WithEndpoint(baseURL),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	return svc
+}
+
+func TestPubSub_TopicCRUD(t *testing.T) {
+	svc := pubsubService(t)
+	name := "projects/test-project/topics/crud-topic"
+
+	created, err := svc.Projects.Topics.Create(name, &pubsub.Topic{
+		Labels: map[string]string{"env": "test"},
+	}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, name, created.Name)
+
+	_, err = svc.Projects.Topics.Create(name, &pubsub.Topic{}).Do()
+	var gerr *googleapi.Error
+	require.ErrorAs(t, err, &gerr)
+	assert.Equal(t, http.StatusConflict, gerr.Code)
+
+	patched, err := svc.Projects.Topics.Patch(name, &pubsub.UpdateTopicRequest{
+		Topic:      &pubsub.Topic{Labels: map[string]string{"env": "prod"}},
+		UpdateMask: "labels",
+	}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "prod", patched.Labels["env"])
+
+	list, err := svc.Projects.Topics.List("projects/test-project").Do()
+	require.NoError(t, err)
+	found := false
+	for _, tp := range list.Topics {
+		found = found || tp.Name == name
+	}
+	assert.True(t, found, "created topic should be listed")
+
+	_, err = svc.Projects.Topics.Delete(name).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Topics.Get(name).Do()
+	require.ErrorAs(t, err, &gerr)
+	assert.Equal(t, http.StatusNotFound, gerr.Code)
+}
+
+func TestPubSub_PublishPullAck(t *testing.T) {
+	svc := pubsubService(t)
+	topic := "projects/test-project/topics/pull-topic"
+	sub := "projects/test-project/subscriptions/pull-sub"
+
+	_, err := svc.Projects.Topics.Create(topic, &pubsub.Topic{}).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Subscriptions.Create(sub, &pubsub.Subscription{
+		Topic:              topic,
+		AckDeadlineSeconds: 10,
+	}).Do()
+	require.NoError(t, err)
+
+	pub, err := svc.Projects.Topics.Publish(topic, &pubsub.PublishRequest{
+		Messages: []*pubsub.PubsubMessage{{
+			Data:       base64.StdEncoding.EncodeToString([]byte("hello")),
+			Attributes: map[string]string{"k": "v"},
+		}},
+	}).Do()
+	require.NoError(t, err)
+	require.Len(t, pub.MessageIds, 1)
+
+	pulled, err := svc.Projects.Subscriptions.Pull(sub, &pubsub.PullRequest{MaxMessages: 10}).Do()
+	require.NoError(t, err)
+	require.Len(t, pulled.ReceivedMessages, 1)
+	rm := pulled.ReceivedMessages[0]
+	assert.Equal(t, pub.MessageIds[0], rm.Message.MessageId)
+	assert.Equal(t, "v", rm.Message.Attributes["k"])
+	data, _ := base64.StdEncoding.DecodeString(rm.Message.Data)
+	assert.Equal(t, "hello", string(data))
+
+	// Leased: a second pull sees nothing until the deadline lapses.
+	again, err := svc.Projects.Subscriptions.Pull(sub, &pubsub.PullRequest{MaxMessages: 10, ReturnImmediately: true}).Do()
+	require.NoError(t, err)
+	assert.Empty(t, again.ReceivedMessages)
+
+	// Nack (deadline 0) makes it immediately redeliverable.
+	_, err = svc.Projects.Subscriptions.ModifyAckDeadline(sub, &pubsub.ModifyAckDeadlineRequest{
+		AckIds: []string{rm.AckId}, AckDeadlineSeconds: 0,
+	}).Do()
+	require.NoError(t, err)
+	redelivered, err := svc.Projects.Subscriptions.Pull(sub, &pubsub.PullRequest{MaxMessages: 10}).Do()
+	require.NoError(t, err)
+	require.Len(t, redelivered.ReceivedMessages, 1)
+
+	_, err = svc.Projects.Subscriptions.Acknowledge(sub, &pubsub.AcknowledgeRequest{
+		AckIds: []string{redelivered.ReceivedMessages[0].AckId},
+	}).Do()
+	require.NoError(t, err)
+	empty, err := svc.Projects.Subscriptions.Pull(sub, &pubsub.PullRequest{MaxMessages: 10, ReturnImmediately: true}).Do()
+	require.NoError(t, err)
+	assert.Empty(t, empty.ReceivedMessages)
+}
+
+func TestPubSub_PushSubscriptionDelivers(t *testing.T) {
+	type pushBody struct {
+		Message struct {
+			Data      string `json:"data"`
+			MessageID string `json:"messageId"`
+		} `json:"message"`
+		Subscription string `json:"subscription"`
+	}
+	got := make(chan pushBody, 1)
+	var authHeader string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b pushBody
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &b)
+		authHeader = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+		select {
+		case got <- b:
+		default:
+		}
+	}))
+	defer target.Close()
+
+	svc := pubsubService(t)
+	topic := "projects/test-project/topics/push-topic"
+	sub := "projects/test-project/subscriptions/push-sub"
+	_, err := svc.Projects.Topics.Create(topic, &pubsub.Topic{}).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Subscriptions.Create(sub, &pubsub.Subscription{
+		Topic: topic,
+		PushConfig: &pubsub.PushConfig{
+			PushEndpoint: target.URL + "/push",
+			OidcToken:    &pubsub.OidcToken{ServiceAccountEmail: "pusher@test-project.iam.gserviceaccount.com"},
+		},
+	}).Do()
+	require.NoError(t, err)
+
+	_, err = svc.Projects.Topics.Publish(topic, &pubsub.PublishRequest{
+		Messages: []*pubsub.PubsubMessage{{Data: base64.StdEncoding.EncodeToString([]byte("pushed"))}},
+	}).Do()
+	require.NoError(t, err)
+
+	select {
+	case b := <-got:
+		assert.Equal(t, sub, b.Subscription)
+		data, _ := base64.StdEncoding.DecodeString(b.Message.Data)
+		assert.Equal(t, "pushed", string(data))
+		assert.True(t, strings.HasPrefix(authHeader, "Bearer "), "OIDC push should carry a bearer token")
+	case <-time.After(10 * time.Second):
+		t.Fatal("push endpoint never received the message")
+	}
+}
+
+func TestPubSub_DeadLetterAfterMaxDeliveryAttempts(t *testing.T) {
+	svc := pubsubService(t)
+	topic := "projects/test-project/topics/dlq-src"
+	dlq := "projects/test-project/topics/dlq-dst"
+	sub := "projects/test-project/subscriptions/dlq-src-sub"
+	dlqSub := "projects/test-project/subscriptions/dlq-dst-sub"
+
+	for _, tp := range []string{topic, dlq} {
+		_, err := svc.Projects.Topics.Create(tp, &pubsub.Topic{}).Do()
+		require.NoError(t, err)
+	}
+	_, err := svc.Projects.Subscriptions.Create(dlqSub, &pubsub.Subscription{Topic: dlq}).Do()
+	require.NoError(t, err)
+	_, err = svc.Projects.Subscriptions.Create(sub, &pubsub.Subscription{
+		Topic: topic,
+		DeadLetterPolicy: &pubsub.DeadLetterPolicy{
+			DeadLetterTopic:     dlq,
+			MaxDeliveryAttempts: 5,
+		},
+	}).Do()
+	require.NoError(t, err)
+
+	_, err = svc.Projects.Topics.Publish(topic, &pubsub.PublishRequest{
+		Messages: []*pubsub.PubsubMessage{{Data: base64.StdEncoding.EncodeToString([]byte("poison"))}},
+	}).Do()
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		pulled, err := svc.Projects.Subscriptions.Pull(sub, &pubsub.PullRequest{MaxMessages: 1}).Do()
+		require.NoError(t, err)
+		require.Len(t, pulled.ReceivedMessages, 1, "delivery %d", i)
+		assert.Equal(t, int64(i), pulled.ReceivedMessages[0].DeliveryAttempt)
+		_, err = svc.Projects.Subscriptions.ModifyAckDeadline(sub, &pubsub.ModifyAckDeadlineRequest{
+			AckIds: []string{pulled.ReceivedMessages[0].AckId}, AckDeadlineSeconds: 0,
+		}).Do()
+		require.NoError(t, err)
+	}
+
+	// The sixth lease forwards to the dead-letter topic instead.
+	src, err := svc.Projects.Subscriptions.Pull(sub, &pubsub.PullRequest{MaxMessages: 1, ReturnImmediately: true}).Do()
+	require.NoError(t, err)
+	assert.Empty(t, src.ReceivedMessages)
+
+	dead, err := svc.Projects.Subscriptions.Pull(dlqSub, &pubsub.PullRequest{MaxMessages: 1}).Do()
+	require.NoError(t, err)
+	require.Len(t, dead.ReceivedMessages, 1)
+	data, _ := base64.StdEncoding.DecodeString(dead.ReceivedMessages[0].Message.Data)
+	assert.Equal(t, "poison", string(data))
+}
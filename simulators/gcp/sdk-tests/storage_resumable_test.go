@@ -0,0 +1,288 @@
+package gcp_sdk_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mib            = 1 << 20
+	resumableChunk = 256 << 10
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// patternReader streams n deterministic pseudo-random bytes without
+// holding them in memory, so multi-GB uploads can be checked end to end.
+type patternReader struct {
+	state uint64
+	left  int64
+}
+
+func newPatternReader(seed uint64, n int64) *patternReader {
+	return &patternReader{state: seed | 1, left: n}
+}
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	if p.left == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.left {
+		b = b[:p.left]
+	}
+	n := len(b) &^ 7
+	if n == 0 {
+		n = len(b)
+	}
+	var word [8]byte
+	for i := 0; i < n; i += 8 {
+		p.state ^= p.state << 13
+		p.state ^= p.state >> 7
+		p.state ^= p.state << 17
+		binary.LittleEndian.PutUint64(word[:], p.state)
+		copy(b[i:n], word[:])
+	}
+	p.left -= int64(n)
+	return n, nil
+}
+
+func TestGCS_ResumableWriter(t *testing.T) {
+	client := storageClient(t)
+	defer client.Close()
+	require.NoError(t, client.Bucket("resumable-bucket").Create(ctx, "test-project", nil))
+
+	data, err := io.ReadAll(newPatternReader(3, 5*mib+123))
+	require.NoError(t, err)
+
+	obj := client.Bucket("resumable-bucket").Object("context/source.tgz")
+	w := obj.NewWriter(ctx)
+	w.ChunkSize = 4 * resumableChunk
+	w.ContentType = "application/gzip"
+	_, err = io.Copy(w, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	wantMD5 := md5.Sum(data)
+	attrs := w.Attrs()
+	assert.Equal(t, int64(len(data)), attrs.Size)
+	assert.Equal(t, wantMD5[:], attrs.MD5)
+	assert.Equal(t, crc32.Checksum(data, castagnoli), attrs.CRC32C)
+	assert.Equal(t, "application/gzip", attrs.ContentType)
+
+	r, err := obj.NewReader(ctx)
+	require.NoError(t, err)
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, got), "downloaded object differs from upload")
+
+	// Ranged read of the object tail.
+	rr, err := obj.NewRangeReader(ctx, int64(len(data))-100, -1)
+	require.NoError(t, err)
+	defer rr.Close()
+	tail, err := io.ReadAll(rr)
+	require.NoError(t, err)
+	assert.Equal(t, data[len(data)-100:], tail)
+}
+
+func TestGCS_ResumableWriterCRC32CMismatch(t *testing.T) {
+	client := storageClient(t)
+	defer client.Close()
+	require.NoError(t, client.Bucket("resumable-crc-bucket").Create(ctx, "test-project", nil))
+
+	data := bytes.Repeat([]byte("z"), 2*resumableChunk+5)
+	w := client.Bucket("resumable-crc-bucket").Object("bad.bin").NewWriter(ctx)
+	w.ChunkSize = resumableChunk
+	w.SendCRC32C = true
+	w.CRC32C = crc32.Checksum(data, castagnoli) + 1
+	_, err := w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	require.Error(t, err)
+
+	_, err = client.Bucket("resumable-crc-bucket").Object("bad.bin").Attrs(ctx)
+	assert.ErrorIs(t, err, storage.ErrObjectNotExist)
+}
+
+// startResumableSession opens a session over raw HTTP and returns its URL.
+func startResumableSession(t *testing.T, bucket, name string, total int64) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost,
+		fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable", baseURL, bucket),
+		strings.NewReader(fmt.Sprintf(`{"name":%q,"contentType":"text/plain"}`, name)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if total >= 0 {
+		req.Header.Set("X-Upload-Content-Length", fmt.Sprint(total))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	loc := resp.Header.Get("Location")
+	require.Contains(t, loc, "upload_id=")
+	return loc
+}
+
+func putChunk(t *testing.T, session, contentRange string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, session, bytes.NewReader(body))
+	require.NoError(t, err)
+	if contentRange != "" {
+		req.Header.Set("Content-Range", contentRange)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestGCS_ResumableProtocol(t *testing.T) {
+	client := storageClient(t)
+	defer client.Close()
+	require.NoError(t, client.Bucket("resumable-raw-bucket").Create(ctx, "test-project", nil))
+
+	data, err := io.ReadAll(newPatternReader(9, 2*resumableChunk+10))
+	require.NoError(t, err)
+	total := int64(len(data))
+	session := startResumableSession(t, "resumable-raw-bucket", "raw.txt", total)
+
+	// Nothing persisted yet: 308 without a Range header.
+	resp := putChunk(t, session, "bytes */*", nil)
+	assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Range"))
+
+	resp = putChunk(t, session, fmt.Sprintf("bytes 0-%d/*", resumableChunk-1), data[:resumableChunk])
+	assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	assert.Equal(t, fmt.Sprintf("bytes=0-%d", resumableChunk-1), resp.Header.Get("Range"))
+
+	// Non-final chunks must be multiples of 256 KiB.
+	resp = putChunk(t, session, fmt.Sprintf("bytes %d-%d/*", resumableChunk, resumableChunk+9), data[resumableChunk:resumableChunk+10])
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// A chunk past the persisted offset is rejected.
+	resp = putChunk(t, session, fmt.Sprintf("bytes %d-%d/*", 2*resumableChunk, 3*resumableChunk-1), make([]byte, resumableChunk))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Resending an overlapping chunk only appends the new bytes.
+	resp = putChunk(t, session, fmt.Sprintf("bytes 0-%d/*", 2*resumableChunk-1), data[:2*resumableChunk])
+	assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	assert.Equal(t, fmt.Sprintf("bytes=0-%d", 2*resumableChunk-1), resp.Header.Get("Range"))
+
+	// Status query reports the same offset.
+	resp = putChunk(t, session, fmt.Sprintf("bytes */%d", total), nil)
+	assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	assert.Equal(t, fmt.Sprintf("bytes=0-%d", 2*resumableChunk-1), resp.Header.Get("Range"))
+
+	resp = putChunk(t, session, fmt.Sprintf("bytes %d-%d/%d", 2*resumableChunk, total-1, total), data[2*resumableChunk:])
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var obj struct {
+		Name    string `json:"name"`
+		Size    string `json:"size"`
+		Md5Hash string `json:"md5Hash"`
+		Crc32c  string `json:"crc32c"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&obj))
+	assert.Equal(t, "raw.txt", obj.Name)
+	assert.Equal(t, fmt.Sprint(total), obj.Size)
+
+	attrs, err := client.Bucket("resumable-raw-bucket").Object("raw.txt").Attrs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, crc32.Checksum(data, castagnoli), attrs.CRC32C)
+	wantMD5 := md5.Sum(data)
+	assert.Equal(t, wantMD5[:], attrs.MD5)
+	assert.Equal(t, "text/plain", attrs.ContentType)
+
+	// A retried final chunk gets the object again.
+	resp = putChunk(t, session, fmt.Sprintf("bytes */%d", total), nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGCS_ResumableNo308Override(t *testing.T) {
+	client := storageClient(t)
+	defer client.Close()
+	require.NoError(t, client.Bucket("resumable-no308-bucket").Create(ctx, "test-project", nil))
+
+	session := startResumableSession(t, "resumable-no308-bucket", "obj", -1)
+	req, err := http.NewRequest(http.MethodPost, session, bytes.NewReader(make([]byte, resumableChunk)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes 0-%d/*", resumableChunk-1))
+	req.Header.Set("X-GUploader-No-308", "yes")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "308", resp.Header.Get("X-Http-Status-Code-Override"))
+	assert.Equal(t, fmt.Sprintf("bytes=0-%d", resumableChunk-1), resp.Header.Get("Range"))
+}
+
+func TestGCS_ResumableCancel(t *testing.T) {
+	client := storageClient(t)
+	defer client.Close()
+	require.NoError(t, client.Bucket("resumable-cancel-bucket").Create(ctx, "test-project", nil))
+
+	session := startResumableSession(t, "resumable-cancel-bucket", "gone.txt", -1)
+	resp := putChunk(t, session, fmt.Sprintf("bytes 0-%d/*", resumableChunk-1), make([]byte, resumableChunk))
+	require.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodDelete, session, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 499, resp.StatusCode)
+
+	resp = putChunk(t, session, "bytes */*", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, err = client.Bucket("resumable-cancel-bucket").Object("gone.txt").Attrs(ctx)
+	assert.ErrorIs(t, err, storage.ErrObjectNotExist)
+}
+
+// TestGCS_ResumableMultiGigabyteStream pushes a 3 GiB stream through the
+// storage Writer and reads it back, comparing digests on both sides.
+func TestGCS_ResumableMultiGigabyteStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("multi-GB upload skipped in -short mode")
+	}
+	client := storageClient(t)
+	defer client.Close()
+	require.NoError(t, client.Bucket("resumable-large-bucket").Create(ctx, "test-project", nil))
+	const size = 3<<30 + 7
+
+	obj := client.Bucket("resumable-large-bucket").Object("big.bin")
+	sent := sha256.New()
+	sentCRC := crc32.New(castagnoli)
+	w := obj.NewWriter(ctx)
+	w.ChunkSize = 32 * mib
+	_, err := io.Copy(w, io.TeeReader(newPatternReader(11, size), io.MultiWriter(sent, sentCRC)))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, int64(size), w.Attrs().Size)
+	assert.Equal(t, sentCRC.Sum32(), w.Attrs().CRC32C)
+
+	r, err := obj.NewReader(ctx)
+	require.NoError(t, err)
+	defer r.Close()
+	received := sha256.New()
+	n, err := io.Copy(received, r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), n)
+	assert.Equal(t, sent.Sum(nil), received.Sum(nil))
+
+	require.NoError(t, obj.Delete(ctx))
+}
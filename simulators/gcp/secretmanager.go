@@ -88,6 +88,24 @@ func registerSecretManager(srv *sim.Server) {
 		sim.WriteJSON(w, http.StatusOK, secret)
 	})
 
+	// DeleteSecret: DELETE /v1/projects/{project}/secrets/{secret}.
+	// Deletes every version with it, like the real API.
+	srv.HandleFunc("DELETE /v1/projects/{project}/secrets/{secret}", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("projects/%s/secrets/%s",
+			sim.PathParam(r, "project"), sim.PathParam(r, "secret"))
+		if _, ok := smSecrets.Get(name); !ok {
+			sim.GCPErrorf(w, http.StatusNotFound, "NOT_FOUND", "secret %s not found", name)
+			return
+		}
+		for _, v := range smSecretVersions.List() {
+			if strings.HasPrefix(v.Name, name+"/versions/") {
+				smSecretVersions.Delete(v.Name)
+			}
+		}
+		smSecrets.Delete(name)
+		sim.WriteJSON(w, http.StatusOK, map[string]any{})
+	})
+
 	// AddSecretVersion: POST /v1/projects/{project}/secrets/{secret}:addVersion.
 	// Go's ServeMux doesn't allow `{wild}:suffix` — register a generic
 	// POST /secrets/{secretAction} handler and parse the colon suffix.
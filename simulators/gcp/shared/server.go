@@ -31,6 +31,7 @@ type Server struct {
 	handler http.Handler
 	db      *sql.DB         // nil when persistence disabled
 	tracker *ProcessTracker // nil when persistence disabled
+	faults  *Faults
 }
 
 // NewServer creates a new simulator server with the given configuration.
@@ -60,6 +61,13 @@ func NewServer(cfg Config) (*Server, error) {
 		Str("provider", cfg.Provider).
 		Logger()
 
+	// Startup faults fail loud: a test that asked for injected
+	// throttling must not silently run against a well-behaved sim.
+	faults, err := FaultsFromEnv(cfg.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("load SIM_FAULTS: %w", err)
+	}
+
 	mux := http.NewServeMux()
 
 	// Health check endpoint
@@ -69,6 +77,7 @@ func NewServer(cfg Config) (*Server, error) {
 			"provider": cfg.Provider,
 		})
 	})
+	registerFaultAPI(mux, faults)
 
 	// Build middleware chain. otelhttp.NewHandler is outermost so
 	// per-request spans see the post-routing path; the existing
@@ -76,6 +85,7 @@ func NewServer(cfg Config) (*Server, error) {
 	// unless main.go calls InitObservability with OTEL_EXPORTER_OTLP_ENDPOINT
 	// set in the env.
 	var handler http.Handler = mux
+	handler = faults.Middleware(handler)
 	handler = AuthPassthroughMiddleware(cfg.Provider)(handler)
 	handler = LoggingMiddleware(logger, cfg.Provider)(handler)
 	handler = RequestIDMiddleware(cfg.Provider)(handler)
@@ -96,6 +106,7 @@ func NewServer(cfg Config) (*Server, error) {
 		logger:  logger,
 		mux:     mux,
 		handler: handler,
+		faults:  faults,
 	}
 
 	// Open SQLite database if persistence enabled. No fallback —
@@ -144,6 +155,12 @@ func (s *Server) Tracker() *ProcessTracker {
 	return s.tracker
 }
 
+// Faults returns the server's fault set, shared with the
+// /sim/v1/faults control API.
+func (s *Server) Faults() *Faults {
+	return s.faults
+}
+
 // Logger returns the server's logger for use by service handlers.
 func (s *Server) Logger() zerolog.Logger {
 	return s.logger
@@ -11,7 +11,8 @@ import (
 // TestTerraformApplyDestroy provisions the full GCP-sim coverage stack
 // (compute network + disk + subnet + firewall, public + private DNS zones,
 // Artifact Registry, Cloud Run v2 Service + Job, Cloud Storage bucket +
-// object, Secret Manager, IAM service account) in a single terraform
+// object, Secret Manager, IAM service account, Pub/Sub, Cloud Tasks,
+// Cloud Scheduler) in a single terraform
 // apply round-trip and asserts the cross-resource references converged.
 //
 // Slices exercised against the simulator:
@@ -24,6 +25,9 @@ import (
 //   - iam.googleapis.com (service account — via iam_beta_custom_endpoint;
 //     terraform-provider-google routes the resource through iambeta.NewClient
 //     which uses iam_beta_custom_endpoint, NOT iam_custom_endpoint)
+//   - pubsub.googleapis.com (topics + subscription with dead-letter policy)
+//   - cloudtasks.googleapis.com v2 (queue with rate limits + retry config)
+//   - cloudscheduler.googleapis.com (cron job with a Pub/Sub target)
 func TestTerraformApplyDestroy(t *testing.T) {
 	init := terraformCmd("init")
 	init.Stdout = nil
@@ -90,6 +94,20 @@ func TestTerraformApplyDestroy(t *testing.T) {
 	require.Equal(t, "projects/test-project/serviceAccounts/tf-test-runner-sa@test-project.iam.gserviceaccount.com", saName,
 		"service-account name must include the canonical projects/{project}/serviceAccounts/{email} resource path; got %s", saName)
 
+	subTopic := outputs.must(t, "pubsub_subscription_topic")
+	require.Equal(t, "projects/test-project/topics/tf-test-topic", subTopic,
+		"subscription topic must be the canonical projects/{p}/topics/{t} path; got %s", subTopic)
+
+	queueID := outputs.must(t, "cloud_tasks_queue_id")
+	require.Equal(t, "projects/test-project/locations/us-central1/queues/tf-test-queue", queueID,
+		"Cloud Tasks queue id must be the canonical projects/{p}/locations/{l}/queues/{q} path; got %s", queueID)
+
+	schedulerJobID := outputs.must(t, "cloud_scheduler_job_id")
+	require.Equal(t, "projects/test-project/locations/us-central1/jobs/tf-test-scheduler-job", schedulerJobID,
+		"Cloud Scheduler job id must be the canonical projects/{p}/locations/{l}/jobs/{j} path; got %s", schedulerJobID)
+	require.Equal(t, "ENABLED", outputs.must(t, "cloud_scheduler_job_state"),
+		"a freshly created scheduler job must be ENABLED")
+
 	destroy := terraformCmd("destroy", "-auto-approve")
 	out, err = destroy.CombinedOutput()
 	require.NoError(t, err, "terraform destroy failed:\n%s", out)
@@ -26,6 +26,10 @@ provider "google" {
   # iambeta.NewClient → iam.googleapis.com surface; without it the resource
   # hits real iam.googleapis.com regardless of `iam_custom_endpoint`.
   iam_beta_custom_endpoint = "${var.endpoint}/v1/"
+
+  pubsub_custom_endpoint          = "${var.endpoint}/v1/"
+  cloud_tasks_custom_endpoint     = "${var.endpoint}/v2/"
+  cloud_scheduler_custom_endpoint = "${var.endpoint}/v1/"
 }
 
 # ---------- Compute (network + disks) ----------
@@ -218,6 +222,64 @@ resource "google_service_account" "tf_sa" {
   display_name = "tf-test runner service account"
 }
 
+# ---------- Pub/Sub + Cloud Tasks + Cloud Scheduler ----------
+
+# Event plumbing around runner workloads: a scheduler job publishes to a
+# topic, a pull subscription with a dead-letter policy drains it, and a
+# Cloud Tasks queue fans HTTP work out under rate limits.
+resource "google_pubsub_topic" "tf_topic" {
+  name = "tf-test-topic"
+}
+
+resource "google_pubsub_topic" "tf_dead_letter" {
+  name = "tf-test-dead-letter"
+}
+
+resource "google_pubsub_subscription" "tf_sub" {
+  name                 = "tf-test-sub"
+  topic                = google_pubsub_topic.tf_topic.id
+  ack_deadline_seconds = 30
+
+  dead_letter_policy {
+    dead_letter_topic     = google_pubsub_topic.tf_dead_letter.id
+    max_delivery_attempts = 5
+  }
+
+  retry_policy {
+    minimum_backoff = "10s"
+    maximum_backoff = "300s"
+  }
+}
+
+resource "google_cloud_tasks_queue" "tf_queue" {
+  name     = "tf-test-queue"
+  location = "us-central1"
+
+  rate_limits {
+    max_dispatches_per_second = 5
+    max_concurrent_dispatches = 2
+  }
+
+  retry_config {
+    max_attempts  = 4
+    min_backoff   = "1s"
+    max_backoff   = "60s"
+    max_doublings = 3
+  }
+}
+
+resource "google_cloud_scheduler_job" "tf_job" {
+  name      = "tf-test-scheduler-job"
+  region    = "us-central1"
+  schedule  = "*/15 * * * *"
+  time_zone = "Europe/Berlin"
+
+  pubsub_target {
+    topic_name = google_pubsub_topic.tf_topic.id
+    data       = base64encode("tick")
+  }
+}
+
 # ---------- Outputs (cross-resource invariants) ----------
 
 output "compute_disk_self_link" {
@@ -271,3 +333,19 @@ output "service_account_email" {
 output "service_account_name" {
   value = google_service_account.tf_sa.name
 }
+
+output "pubsub_subscription_topic" {
+  value = google_pubsub_subscription.tf_sub.topic
+}
+
+output "cloud_tasks_queue_id" {
+  value = google_cloud_tasks_queue.tf_queue.id
+}
+
+output "cloud_scheduler_job_id" {
+  value = google_cloud_scheduler_job.tf_job.id
+}
+
+output "cloud_scheduler_job_state" {
+  value = google_cloud_scheduler_job.tf_job.state
+}
@@ -319,6 +319,18 @@ resource "aws_ecs_cluster" "main" {
   })
 }
 
+# FARGATE_SPOT backs containers labelled sockerless.capacity=spot; plain
+# containers keep the FARGATE launch type.
+resource "aws_ecs_cluster_capacity_providers" "main" {
+  cluster_name       = aws_ecs_cluster.main.name
+  capacity_providers = ["FARGATE", "FARGATE_SPOT"]
+
+  default_capacity_provider_strategy {
+    capacity_provider = "FARGATE"
+    weight            = 1
+  }
+}
+
 # =============================================================================
 # EFS Filesystem
 # =============================================================================
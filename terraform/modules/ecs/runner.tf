@@ -86,9 +86,10 @@ resource "aws_iam_role" "runner_task" {
 
 data "aws_iam_policy_document" "runner_task" {
   # Sockerless-backend-ecs dispatches sub-tasks. Needs full ECS task
-  # lifecycle + task-definition management + ExecuteCommand for the
-  # `docker exec` flow that GitHub Actions' runner uses to run each
-  # workflow step inside the spawned job container.
+  # lifecycle + services (restart-policy containers) + task-definition
+  # management + ExecuteCommand for the `docker exec` flow that GitHub
+  # Actions' runner uses to run each workflow step inside the spawned
+  # job container.
   statement {
     sid    = "SockerlessECSDispatch"
     effect = "Allow"
@@ -103,7 +104,11 @@ data "aws_iam_policy_document" "runner_task" {
       "ecs:ListTaskDefinitions",
       "ecs:TagResource",
       "ecs:UntagResource",
+      "ecs:CreateService",
+      "ecs:UpdateService",
+      "ecs:DeleteService",
       "ecs:DescribeServices",
+      "ecs:ListServices",
       "ecs:DescribeContainerInstances",
       "ecs:DescribeClusters",
       "ecs:ExecuteCommand",
@@ -3,7 +3,6 @@ package tests
 import (
 	"context"
 	"encoding/json"
-	"strings"
 	"testing"
 	"time"
 
@@ -77,22 +76,34 @@ func TestContainerStatsStream(t *testing.T) {
 		t.Fatalf("start failed: %v", err)
 	}
 
-	// Streaming `docker stats` is an accepted gap on cloud backends —
-	// cloud metrics lag 30-60s so a streaming response would be a
-	// polling reskin that misleads callers. The integration tests run
-	// against simulator-backed cloud backends (CloudState is set), so
-	// the API returns NotImplementedError. Asserting the gap here so
-	// any regression that re-introduces fake streaming gets caught.
-	streamCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	// Streaming stats emit one frame per interval; each frame's preread
+	// is the previous frame's read, so the client can compute CPU% from
+	// the precpu_stats delta.
+	streamCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	resp, err := dockerClient.ContainerStats(streamCtx, id, true)
-	if err == nil {
-		resp.Body.Close()
-		t.Fatal("expected streaming stats to return NotImplemented on cloud backends, got success")
+	if err != nil {
+		t.Fatalf("streaming stats failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	var first, second map[string]any
+	if err := dec.Decode(&first); err != nil {
+		t.Fatalf("failed to decode first frame: %v", err)
+	}
+	if err := dec.Decode(&second); err != nil {
+		t.Fatalf("failed to decode second frame: %v", err)
+	}
+	if second["preread"] != first["read"] {
+		t.Errorf("second frame preread = %v, want first frame read %v", second["preread"], first["read"])
+	}
+	if _, ok := second["precpu_stats"].(map[string]any); !ok {
+		t.Error("second frame missing 'precpu_stats'")
 	}
-	if !strings.Contains(err.Error(), "not supported") && !strings.Contains(err.Error(), "Not Implemented") {
-		t.Fatalf("expected NotImplemented-shaped error, got: %v", err)
+	if _, ok := second["sockerless"].(map[string]any); !ok {
+		t.Error("second frame missing 'sockerless' source metadata")
 	}
 }
 
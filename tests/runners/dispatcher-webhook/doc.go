@@ -0,0 +1,8 @@
+// Package dispatcherwebhook drives the GitHub runner dispatchers'
+// `workflow_job` webhook ingress end to end against bleephub's webhook
+// delivery: bleephub signs and POSTs the delivery, the dispatcher's
+// webhook.Handler verifies it and emits a poller.Job, and the
+// reconciliation poll picks up deliveries the ingress rejected or
+// never saw. Runs in-process with no credentials, so it sits in the
+// default `go test ./...`; see e2e_test.go.
+package dispatcherwebhook
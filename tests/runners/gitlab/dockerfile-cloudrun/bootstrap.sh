@@ -15,6 +15,11 @@
 #   GITLAB_RUNNER_TAGS        - csv (e.g. "sockerless-cloudrun")
 #   GITLAB_RUNNER_NAME        - display name (default: hostname)
 #
+# Ephemeral env (set by gitlab-runner-dispatcher):
+#
+#   GITLAB_RUNNER_MAX_BUILDS  - run this many jobs, then exit (1)
+#   GITLAB_RUNNER_IDLE_SECONDS - exit if no job arrives (default 60)
+#
 # Optional env (sockerless-backend-cloudrun config):
 #
 #   SOCKERLESS_GCR_PROJECT, SOCKERLESS_GCR_REGION,
@@ -134,6 +139,18 @@ EOF
 echo "bootstrap: gitlab-runner config.toml:"
 cat /etc/gitlab-runner/config.toml
 
+# Ephemeral mode (gitlab-runner-dispatcher): the dispatcher creates a
+# runner per pending job and sets GITLAB_RUNNER_MAX_BUILDS=1. run-single
+# takes that one job and exits; --wait-timeout exits an idle runner
+# whose job was picked up elsewhere.
+if [ -n "${GITLAB_RUNNER_MAX_BUILDS:-}" ]; then
+    exec gitlab-runner run-single --config /etc/gitlab-runner/config.toml \
+        --runner-name "${GITLAB_RUNNER_NAME:-$(hostname)}" \
+        --working-directory /tmp/runner-work \
+        --max-builds "${GITLAB_RUNNER_MAX_BUILDS}" \
+        --wait-timeout "${GITLAB_RUNNER_IDLE_SECONDS:-60}"
+fi
+
 # Long-lived polling loop. gitlab-runner re-execs itself on SIGHUP for
 # config reloads; SIGTERM stops gracefully.
 exec gitlab-runner run --config /etc/gitlab-runner/config.toml --working-directory /tmp/runner-work
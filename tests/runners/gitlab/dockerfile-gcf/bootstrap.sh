@@ -80,4 +80,13 @@ EOF
 
 cat /etc/gitlab-runner/config.toml
 
+# Ephemeral mode — see gitlab/dockerfile-cloudrun/bootstrap.sh.
+if [ -n "${GITLAB_RUNNER_MAX_BUILDS:-}" ]; then
+    exec gitlab-runner run-single --config /etc/gitlab-runner/config.toml \
+        --runner-name "${GITLAB_RUNNER_NAME:-$(hostname)}" \
+        --working-directory /tmp/runner-work \
+        --max-builds "${GITLAB_RUNNER_MAX_BUILDS}" \
+        --wait-timeout "${GITLAB_RUNNER_IDLE_SECONDS:-60}"
+fi
+
 exec gitlab-runner run --config /etc/gitlab-runner/config.toml --working-directory /tmp/runner-work
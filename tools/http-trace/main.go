@@ -11,6 +11,9 @@
 //
 // The proxy supports HTTP/1.1 hijacked connections (attach, exec) by
 // switching to raw TCP bridging on 101 Switching Protocols.
+//
+// To capture a backend's cloud API traffic as a replayable regression
+// test instead, see specs/RECORD_REPLAY.md.
 package main
 
 import (
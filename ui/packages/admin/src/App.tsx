@@ -19,6 +19,7 @@ import { TopologyPage } from "./pages/TopologyPage.js";
 import { InstanceLogsPage } from "./pages/InstanceLogsPage.js";
 import { ProjectConsolePage } from "./pages/ProjectConsolePage.js";
 import { TopologyResourcesPage } from "./pages/TopologyResourcesPage.js";
+import { AuthGate, useAuth } from "./components/AuthGate.js";
 
 const navItems: NavItem[] = [
   { label: "Dashboard", to: "/ui/" },
@@ -39,16 +40,42 @@ function renderNavLink(item: NavItem) {
   );
 }
 
+/** Sidebar footer: who is signed in, and a way out. Keeps the stock
+ * label when the admin runs without authentication. */
+function SessionFooter() {
+  const auth = useAuth();
+  if (!auth || auth.principal.method === "none") return <span>sockerless · operator</span>;
+  return (
+    <span className="flex items-center gap-2">
+      <span title={`signed in via ${auth.principal.method}`}>
+        {auth.principal.name} · {auth.principal.role}
+      </span>
+      {auth.principal.method !== "token" && (
+        <button
+          type="button"
+          onClick={() => void auth.logout()}
+          className="uppercase tracking-[0.2em]"
+          style={{ color: "var(--color-accent)" }}
+        >
+          sign out
+        </button>
+      )}
+    </span>
+  );
+}
+
 export function App() {
   return (
     <ErrorBoundary>
       <ToastProvider>
+        <AuthGate>
         <BrowserRouter>
         <AppShell
           kicker="sockerless · operator"
           title="Admin"
           navItems={navItems}
           renderLink={renderNavLink}
+          footer={<SessionFooter />}
         >
           <Routes>
             <Route path="/ui/" element={<DashboardPage />} />
@@ -90,6 +117,7 @@ export function App() {
           </Routes>
         </AppShell>
         </BrowserRouter>
+        </AuthGate>
       </ToastProvider>
     </ErrorBoundary>
   );
@@ -0,0 +1,89 @@
+import { describe, it, expect, vi, afterEach } from "vitest";
+import { render, cleanup, screen, waitFor, fireEvent } from "@testing-library/react";
+import { QueryClient, QueryClientProvider } from "@tanstack/react-query";
+import { AuthGate } from "../components/AuthGate.js";
+
+const mockFetch = vi.fn();
+globalThis.fetch = mockFetch;
+
+function jsonResponse(data: unknown, status = 200) {
+  return new Response(JSON.stringify(data), {
+    status,
+    headers: { "Content-Type": "application/json" },
+  });
+}
+
+afterEach(() => {
+  cleanup();
+  mockFetch.mockReset();
+});
+
+function renderGate() {
+  const queryClient = new QueryClient({
+    defaultOptions: { queries: { retry: false } },
+  });
+  return render(
+    <QueryClientProvider client={queryClient}>
+      <AuthGate>
+        <div>protected content</div>
+      </AuthGate>
+    </QueryClientProvider>,
+  );
+}
+
+/** Routes mock fetches by path; `me` is mutable so a login can flip it. */
+function mockAdmin(providers: unknown, me: { status: number; body: unknown }) {
+  mockFetch.mockImplementation((path: string, init?: RequestInit) => {
+    if (path === "/api/v1/auth/providers") return Promise.resolve(jsonResponse(providers));
+    if (path === "/api/v1/auth/me") return Promise.resolve(jsonResponse(me.body, me.status));
+    if (path === "/api/v1/auth/login" && init?.method === "POST") {
+      const { password } = JSON.parse(init.body as string);
+      if (password !== "s3cret") {
+        return Promise.resolve(jsonResponse({ error: "invalid username or password" }, 401));
+      }
+      me.status = 200;
+      me.body = { name: "alice", role: "admin", method: "password" };
+      return Promise.resolve(jsonResponse(me.body));
+    }
+    return Promise.resolve(jsonResponse({ error: "not found" }, 404));
+  });
+}
+
+describe("AuthGate", () => {
+  it("renders children straight away when auth is disabled", async () => {
+    mockAdmin(
+      { enabled: false, password: false },
+      { status: 200, body: { name: "anonymous", role: "admin", method: "none" } },
+    );
+    renderGate();
+    await waitFor(() => {
+      expect(screen.getByText("protected content")).toBeInTheDocument();
+    });
+  });
+
+  it("shows the login form on 401 and the app after signing in", async () => {
+    mockAdmin(
+      { enabled: true, password: true, oidc: "bleephub" },
+      { status: 401, body: { error: "authentication required" } },
+    );
+    renderGate();
+    await waitFor(() => {
+      expect(screen.getByRole("heading", { name: /sign in/i })).toBeInTheDocument();
+    });
+    expect(screen.getByRole("button", { name: /sign in with bleephub/i })).toBeInTheDocument();
+    expect(screen.queryByText("protected content")).not.toBeInTheDocument();
+
+    fireEvent.change(screen.getByLabelText(/username/i), { target: { value: "alice" } });
+    fireEvent.change(screen.getByLabelText(/password/i), { target: { value: "wrong" } });
+    fireEvent.click(screen.getByRole("button", { name: /^sign in$/i }));
+    await waitFor(() => {
+      expect(screen.getByRole("alert")).toHaveTextContent(/invalid username or password/i);
+    });
+
+    fireEvent.change(screen.getByLabelText(/password/i), { target: { value: "s3cret" } });
+    fireEvent.click(screen.getByRole("button", { name: /^sign in$/i }));
+    await waitFor(() => {
+      expect(screen.getByText("protected content")).toBeInTheDocument();
+    });
+  });
+});
@@ -162,23 +162,50 @@ export class AdminApiError extends Error {
   }
 }
 
+/**
+ * Event fired on `window` whenever an API call answers 401, so the
+ * auth gate can drop back to the login screen (expired session,
+ * rotated key) without every page handling it.
+ */
+export const UNAUTHORIZED_EVENT = "sockerless-admin:unauthorized";
+
+async function failed(res: Response): Promise<never> {
+  if (res.status === 401) {
+    window.dispatchEvent(new Event(UNAUTHORIZED_EVENT));
+  }
+  const body = await res.text();
+  throw new AdminApiError(res.status, res.statusText, body);
+}
+
+/** Which login methods the admin has enabled. */
+export interface AuthProviders {
+  /** false = no admin-auth.yaml; every caller is an anonymous admin. */
+  enabled: boolean;
+  password: boolean;
+  /** Display name of the OIDC provider, when configured. */
+  oidc?: string;
+}
+
+export type AdminRole = "viewer" | "operator" | "admin";
+
+/** The caller, as resolved by the admin. */
+export interface Principal {
+  name: string;
+  role: AdminRole;
+  method: "password" | "token" | "oidc" | "none";
+}
+
 /** Admin API client. */
 export class AdminApiClient {
   private async request<T>(path: string): Promise<T> {
     const res = await fetch(path);
-    if (!res.ok) {
-      const body = await res.text();
-      throw new AdminApiError(res.status, res.statusText, body);
-    }
+    if (!res.ok) return failed(res);
     return res.json() as Promise<T>;
   }
 
   private async post<T>(path: string): Promise<T> {
     const res = await fetch(path, { method: "POST" });
-    if (!res.ok) {
-      const body = await res.text();
-      throw new AdminApiError(res.status, res.statusText, body);
-    }
+    if (!res.ok) return failed(res);
     return res.json() as Promise<T>;
   }
 
@@ -188,10 +215,7 @@ export class AdminApiClient {
       headers: { "Content-Type": "application/json" },
       body: JSON.stringify(body),
     });
-    if (!res.ok) {
-      const text = await res.text();
-      throw new AdminApiError(res.status, res.statusText, text);
-    }
+    if (!res.ok) return failed(res);
     return res.json() as Promise<T>;
   }
 
@@ -201,22 +225,33 @@ export class AdminApiClient {
       headers: { "Content-Type": "application/json" },
       body: JSON.stringify(body),
     });
-    if (!res.ok) {
-      const text = await res.text();
-      throw new AdminApiError(res.status, res.statusText, text);
-    }
+    if (!res.ok) return failed(res);
     return res.json() as Promise<T>;
   }
 
   private async del<T>(path: string): Promise<T> {
     const res = await fetch(path, { method: "DELETE" });
-    if (!res.ok) {
-      const body = await res.text();
-      throw new AdminApiError(res.status, res.statusText, body);
-    }
+    if (!res.ok) return failed(res);
     return res.json() as Promise<T>;
   }
 
+  // Authentication
+  authProviders(): Promise<AuthProviders> {
+    return this.request("/api/v1/auth/providers");
+  }
+
+  authMe(): Promise<Principal> {
+    return this.request("/api/v1/auth/me");
+  }
+
+  login(username: string, password: string): Promise<Principal> {
+    return this.postJSON("/api/v1/auth/login", { username, password });
+  }
+
+  logout(): Promise<{ status: string }> {
+    return this.post("/api/v1/auth/logout");
+  }
+
   components(): Promise<AdminComponent[]> {
     return this.request("/api/v1/components");
   }
@@ -0,0 +1,97 @@
+import { createContext, useContext, useEffect, type ReactNode } from "react";
+import { useQuery, useQueryClient } from "@tanstack/react-query";
+import { Spinner } from "@sockerless/ui-core/components";
+import {
+  AdminApiClient,
+  AdminApiError,
+  UNAUTHORIZED_EVENT,
+  type AuthProviders,
+  type Principal,
+} from "../api.js";
+import { LoginPage } from "../pages/LoginPage.js";
+import { ErrorPanel } from "./ErrorPanel.js";
+
+const api = new AdminApiClient();
+
+interface AuthState {
+  principal: Principal;
+  providers: AuthProviders;
+  logout: () => Promise<void>;
+}
+
+const AuthContext = createContext<AuthState | null>(null);
+
+/** The signed-in caller; null outside AuthGate. */
+export function useAuth(): AuthState | null {
+  return useContext(AuthContext);
+}
+
+/**
+ * AuthGate — renders its children only once the admin knows who the
+ * browser is. With authentication off the admin answers /auth/me with
+ * an anonymous admin and the gate is invisible. With it on, a missing
+ * or expired session shows LoginPage; any later 401 from any page
+ * (session expiry, key rotation) drops back to it.
+ */
+export function AuthGate({ children }: { children: ReactNode }) {
+  const queryClient = useQueryClient();
+  const providers = useQuery({
+    queryKey: ["auth-providers"],
+    queryFn: () => api.authProviders(),
+    refetchInterval: false,
+    staleTime: Infinity,
+  });
+  const me = useQuery({
+    queryKey: ["auth-me"],
+    queryFn: () => api.authMe(),
+    refetchInterval: false,
+    retry: false,
+  });
+
+  useEffect(() => {
+    const onUnauthorized = () => {
+      void queryClient.invalidateQueries({ queryKey: ["auth-me"] });
+    };
+    window.addEventListener(UNAUTHORIZED_EVENT, onUnauthorized);
+    return () => window.removeEventListener(UNAUTHORIZED_EVENT, onUnauthorized);
+  }, [queryClient]);
+
+  if (providers.isLoading || me.isLoading) {
+    return (
+      <div className="flex h-screen items-center justify-center">
+        <Spinner />
+      </div>
+    );
+  }
+  if (providers.error) {
+    return <ErrorPanel kicker="admin unreachable" message={providers.error.message} />;
+  }
+
+  const unauthorized = me.error instanceof AdminApiError && me.error.status === 401;
+  if (unauthorized || !me.data) {
+    if (!unauthorized && me.error) {
+      return <ErrorPanel kicker="auth" message={me.error.message} />;
+    }
+    return (
+      <LoginPage
+        providers={providers.data!}
+        onLogin={async (username, password) => {
+          const principal = await api.login(username, password);
+          queryClient.setQueryData(["auth-me"], principal);
+          await queryClient.invalidateQueries({ predicate: (q) => q.queryKey[0] !== "auth-me" });
+        }}
+      />
+    );
+  }
+
+  const state: AuthState = {
+    principal: me.data,
+    providers: providers.data!,
+    logout: async () => {
+      await api.logout();
+      // Full reload: drops every cached query along with the session.
+      window.location.assign("/ui/");
+    },
+  };
+  return <AuthContext.Provider value={state}>{children}</AuthContext.Provider>;
+}
@@ -0,0 +1,153 @@
+import { useState, type CSSProperties } from "react";
+import { Button, Spinner } from "@sockerless/ui-core/components";
+import type { AuthProviders } from "../api.js";
+
+const inputStyle: CSSProperties = {
+  width: "100%",
+  padding: "0.4rem 0.55rem",
+  background: "var(--color-bg)",
+  color: "var(--color-fg)",
+  border: "1px solid var(--color-border-strong)",
+  borderRadius: "var(--radius-sm)",
+  fontFamily: "var(--font-mono, ui-monospace, monospace)",
+  fontSize: "0.85rem",
+};
+
+const labelStyle: CSSProperties = {
+  display: "block",
+  marginBottom: 4,
+  fontSize: "0.68rem",
+  letterSpacing: "0.18em",
+  textTransform: "uppercase",
+  color: "var(--color-fg-subtle)",
+};
+
+export interface LoginPageProps {
+  providers: AuthProviders;
+  onLogin: (username: string, password: string) => Promise<void>;
+}
+
+/**
+ * LoginPage — shown by AuthGate in place of the app when the admin
+ * has authentication on and the browser has no valid session.
+ *
+ * Renders the password form when local users are configured and a
+ * "Sign in with …" button when an OIDC provider is. That button is a
+ * full-page navigation: the admin redirects to the provider and back,
+ * landing on the page the operator was on.
+ */
+export function LoginPage({ providers, onLogin }: LoginPageProps) {
+  const [username, setUsername] = useState("");
+  const [password, setPassword] = useState("");
+  const [submitting, setSubmitting] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  const submit = async (event: { preventDefault: () => void }) => {
+    event.preventDefault();
+    setError(null);
+    setSubmitting(true);
+    try {
+      await onLogin(username.trim(), password);
+    } catch {
+      setError("Invalid username or password.");
+      setPassword("");
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const next = encodeURIComponent(window.location.pathname + window.location.search);
+
+  return (
+    <div
+      className="flex h-screen w-full items-center justify-center"
+      style={{ background: "var(--color-bg)", color: "var(--color-fg)" }}
+    >
+      <div
+        className="flex w-full max-w-sm flex-col gap-5 border p-8"
+        style={{ borderColor: "var(--color-border)", borderRadius: "var(--radius-sm)" }}
+      >
+        <div>
+          <div
+            className="text-[10px] uppercase tracking-[0.18em]"
+            style={{ color: "var(--color-fg-subtle)" }}
+          >
+            sockerless · operator
+          </div>
+          <h1
+            className="text-2xl font-display"
+            style={{ fontStyle: "italic", fontWeight: 600, letterSpacing: "-0.02em" }}
+          >
+            Sign in
+          </h1>
+        </div>
+
+        {providers.password && (
+          <form onSubmit={submit} className="flex flex-col gap-3" aria-label="Sign in">
+            <label>
+              <span style={labelStyle}>username</span>
+              <input
+                type="text"
+                name="username"
+                autoComplete="username"
+                value={username}
+                onChange={(e) => setUsername(e.target.value)}
+                required
+                autoFocus
+                style={inputStyle}
+              />
+            </label>
+            <label>
+              <span style={labelStyle}>password</span>
+              <input
+                type="password"
+                name="password"
+                autoComplete="current-password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                required
+                style={inputStyle}
+              />
+            </label>
+            <Button type="submit" variant="primary" disabled={submitting}>
+              {submitting ? <Spinner label="" /> : "Sign in"}
+            </Button>
+          </form>
+        )}
+
+        {providers.oidc && (
+          <Button
+            variant="secondary"
+            style={{ width: "100%" }}
+            onClick={() => window.location.assign(`/api/v1/auth/oidc/login?next=${next}`)}
+          >
+            Sign in with {providers.oidc}
+          </Button>
+        )}
+
+        {!providers.password && !providers.oidc && (
+          <p style={{ color: "var(--color-fg-muted)", fontSize: "0.85rem" }}>
+            This admin accepts API tokens only. Use the REST API with an
+            <code> Authorization: Bearer</code> header.
+          </p>
+        )}
+
+        {error && (
+          <div
+            role="alert"
+            className="px-3 py-2 font-mono"
+            style={{
+              background: "var(--color-status-error-soft)",
+              color: "var(--color-status-error)",
+              border: "1px solid var(--color-status-error)",
+              borderRadius: "var(--radius-sm)",
+              fontSize: "0.78rem",
+            }}
+          >
+            {error}
+          </div>
+        )}
+      </div>
+    </div>
+  );
+}
@@ -12,6 +12,8 @@ export interface AppShellProps {
   kicker?: string;
   navItems: NavItem[];
   renderLink: (item: NavItem, isActive?: boolean) => ReactNode;
+  /** Replaces the sidebar footer's label, e.g. the signed-in user. */
+  footer?: ReactNode;
   children: ReactNode;
 }
 
@@ -22,7 +24,7 @@ export interface AppShellProps {
  * itself without needing a logo. Content area dropped onto a textured
  * page (the dotted grid is in the global stylesheet).
  */
-export function AppShell({ title, kicker, navItems, renderLink, children }: AppShellProps) {
+export function AppShell({ title, kicker, navItems, renderLink, footer, children }: AppShellProps) {
   return (
     <div
       className="grid h-screen w-full"
@@ -100,7 +102,7 @@ export function AppShell({ title, kicker, navItems, renderLink, children }: AppS
             color: "var(--color-fg-subtle)",
           }}
         >
-          <span>sockerless · operator</span>
+          {footer ?? <span>sockerless · operator</span>}
           <ThemeToggle />
         </div>
       </aside>